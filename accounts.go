@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// apiKeyPrefix starts every API key, so leaked keys are easy to grep for.
+	apiKeyPrefix = "wot_"
+	// accountUsageDays is how many days of per-endpoint usage are kept.
+	accountUsageDays = 30
+	// accountTopupMin and accountTopupMax bound one Lightning top-up.
+	accountTopupMin = 10
+	accountTopupMax = 1000000
+	// accountTopupTTL is how long an unpaid top-up invoice is checked for.
+	accountTopupTTL = 24 * time.Hour
+	// accountMaxTopups caps the top-ups kept per account, oldest settled first.
+	accountMaxTopups = 50
+	// accountSaveInterval is how often balances are written to ACCOUNTS_FILE.
+	accountSaveInterval = 30 * time.Second
+)
+
+// Topup is a Lightning invoice that credits an account once paid.
+type Topup struct {
+	PaymentHash string    `json:"payment_hash"`
+	Invoice     string    `json:"invoice"`
+	Sats        int64     `json:"sats"`
+	Status      string    `json:"status"` // pending, paid or expired
+	CreatedAt   time.Time `json:"created_at"`
+	PaidAt      time.Time `json:"paid_at,omitempty"`
+}
+
+// EndpointUsage counts the requests and sats an account spent on one endpoint.
+type EndpointUsage struct {
+	Requests int   `json:"requests"`
+	Sats     int64 `json:"sats"`
+}
+
+// Account is an integrator's prepaid balance, spent with an API key instead of
+// a per-request L402 payment.
+type Account struct {
+	Pubkey    string                               `json:"pubkey"`
+	KeyHash   string                               `json:"key_hash"` // SHA-256 of the API key; the key itself is never stored
+	CreatedAt time.Time                            `json:"created_at"`
+	Balance   int64                                `json:"balance_sats"`
+	Spent     int64                                `json:"spent_sats"`
+	Topups    []*Topup                             `json:"topups"`
+	Usage     map[string]map[string]*EndpointUsage `json:"usage"` // day (YYYY-MM-DD) -> endpoint -> usage
+}
+
+// AccountStore holds API key accounts, one per pubkey.
+type AccountStore struct {
+	mu       sync.Mutex
+	path     string
+	accounts map[string]*Account // pubkey -> account
+	byKey    map[string]string   // key hash -> pubkey
+	dirty    bool
+	now      func() time.Time
+}
+
+func NewAccountStore(path string) *AccountStore {
+	return &AccountStore{
+		path:     path,
+		accounts: make(map[string]*Account),
+		byKey:    make(map[string]string),
+		now:      time.Now,
+	}
+}
+
+var accountStore = NewAccountStore(os.Getenv("ACCOUNTS_FILE"))
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Load restores accounts from the file. A missing file is not an error.
+func (s *AccountStore) Load() error {
+	if s.path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var accounts []*Account
+	if err := json.Unmarshal(raw, &accounts); err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range accounts {
+		if a.Usage == nil {
+			a.Usage = make(map[string]map[string]*EndpointUsage)
+		}
+		s.accounts[a.Pubkey] = a
+		s.byKey[a.KeyHash] = a.Pubkey
+	}
+	if len(accounts) > 0 {
+		log.Printf("Accounts: restored %d API key accounts", len(accounts))
+	}
+	return nil
+}
+
+// save writes the accounts to the file if they changed.
+func (s *AccountStore) save() {
+	s.mu.Lock()
+	if s.path == "" || !s.dirty {
+		s.mu.Unlock()
+		return
+	}
+	accounts := make([]*Account, 0, len(s.accounts))
+	for _, a := range s.accounts {
+		accounts = append(accounts, a)
+	}
+	raw, err := json.Marshal(accounts)
+	s.dirty = false
+	s.mu.Unlock()
+	if err == nil {
+		err = writeFileAtomic(s.path, raw)
+	}
+	if err != nil {
+		log.Printf("Accounts: saving failed: %v", err)
+	}
+}
+
+// Run saves changed accounts periodically until ctx ends.
+func (s *AccountStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(accountSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.save()
+			return
+		case <-ticker.C:
+			s.save()
+		}
+	}
+}
+
+// Create issues a new API key for pubkey. An existing account keeps its
+// balance and history, and its old key stops working.
+func (s *AccountStore) Create(pubkey string) (key string, a *Account) {
+	b := make([]byte, 24)
+	rand.Read(b)
+	key = apiKeyPrefix + hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a = s.accounts[pubkey]
+	if a == nil {
+		a = &Account{Pubkey: pubkey, CreatedAt: s.now(), Topups: []*Topup{}, Usage: make(map[string]map[string]*EndpointUsage)}
+		s.accounts[pubkey] = a
+	} else {
+		delete(s.byKey, a.KeyHash)
+	}
+	a.KeyHash = hashAPIKey(key)
+	s.byKey[a.KeyHash] = pubkey
+	s.dirty = true
+	return key, a
+}
+
+// Lookup returns the pubkey an API key belongs to.
+func (s *AccountStore) Lookup(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pubkey, ok := s.byKey[hashAPIKey(key)]
+	return pubkey, ok
+}
+
+// Charge takes sats for a request to endpoint from pubkey's balance and records
+// the usage, reporting the balance left and whether it covered the price.
+func (s *AccountStore) Charge(pubkey, endpoint string, sats int64) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.accounts[pubkey]
+	if a == nil {
+		return 0, false
+	}
+	if a.Balance < sats {
+		return a.Balance, false
+	}
+	a.Balance -= sats
+	a.Spent += sats
+
+	now := s.now().UTC()
+	day := now.Format("2006-01-02")
+	byEndpoint := a.Usage[day]
+	if byEndpoint == nil {
+		byEndpoint = make(map[string]*EndpointUsage)
+		a.Usage[day] = byEndpoint
+		cutoff := now.AddDate(0, 0, -accountUsageDays).Format("2006-01-02")
+		for d := range a.Usage {
+			if d <= cutoff {
+				delete(a.Usage, d)
+			}
+		}
+	}
+	u := byEndpoint[endpoint]
+	if u == nil {
+		u = &EndpointUsage{}
+		byEndpoint[endpoint] = u
+	}
+	u.Requests++
+	u.Sats += sats
+	s.dirty = true
+	return a.Balance, true
+}
+
+// AddTopup records an unpaid top-up invoice.
+func (s *AccountStore) AddTopup(pubkey string, t *Topup) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.accounts[pubkey]
+	if a == nil {
+		return
+	}
+	a.Topups = append(a.Topups, t)
+	for i := 0; len(a.Topups) > accountMaxTopups && i < len(a.Topups); {
+		if a.Topups[i].Status == "pending" {
+			i++
+			continue
+		}
+		a.Topups = append(a.Topups[:i], a.Topups[i+1:]...)
+	}
+	s.dirty = true
+}
+
+// SettleTopups checks pubkey's pending top-ups with paid and credits the paid
+// ones. Top-ups unpaid after accountTopupTTL expire.
+func (s *AccountStore) SettleTopups(pubkey string, paid func(paymentHash string) bool) {
+	s.mu.Lock()
+	a := s.accounts[pubkey]
+	var pending []*Topup
+	if a != nil {
+		for _, t := range a.Topups {
+			if t.Status == "pending" {
+				pending = append(pending, t)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for _, t := range pending {
+		ok := paid(t.PaymentHash)
+		s.mu.Lock()
+		switch {
+		case ok && t.Status == "pending":
+			t.Status = "paid"
+			t.PaidAt = s.now()
+			a.Balance += t.Sats
+			s.dirty = true
+			log.Printf("Accounts: credited %d sats to %s", t.Sats, pubkey)
+		case !ok && s.now().Sub(t.CreatedAt) > accountTopupTTL:
+			t.Status = "expired"
+			s.dirty = true
+		}
+		s.mu.Unlock()
+	}
+}
+
+// AccountUsage is GET /account/usage.
+type AccountUsage struct {
+	Pubkey     string                    `json:"pubkey"`
+	CreatedAt  time.Time                 `json:"created_at"`
+	Balance    int64                     `json:"balance_sats"`
+	Spent      int64                     `json:"spent_sats"`
+	Days       int                       `json:"days"`
+	ByEndpoint map[string]*EndpointUsage `json:"by_endpoint"` // totals over the window
+	History    []AccountUsageDay         `json:"history"`     // newest first
+	Topups     []*Topup                  `json:"topups"`
+}
+
+// AccountUsageDay is one day's usage.
+type AccountUsageDay struct {
+	Date      string                    `json:"date"`
+	Requests  int                       `json:"requests"`
+	Sats      int64                     `json:"sats"`
+	Endpoints map[string]*EndpointUsage `json:"endpoints"`
+}
+
+// Usage reports pubkey's balance and the last days of usage.
+func (s *AccountStore) Usage(pubkey string, days int) (*AccountUsage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.accounts[pubkey]
+	if a == nil {
+		return nil, false
+	}
+	out := &AccountUsage{
+		Pubkey: a.Pubkey, CreatedAt: a.CreatedAt, Balance: a.Balance, Spent: a.Spent, Days: days,
+		ByEndpoint: make(map[string]*EndpointUsage),
+		History:    []AccountUsageDay{},
+		Topups:     make([]*Topup, 0, len(a.Topups)),
+	}
+	cutoff := s.now().UTC().AddDate(0, 0, -days).Format("2006-01-02")
+	for day, byEndpoint := range a.Usage {
+		if day <= cutoff {
+			continue
+		}
+		d := AccountUsageDay{Date: day, Endpoints: make(map[string]*EndpointUsage)}
+		for endpoint, u := range byEndpoint {
+			cp := *u
+			d.Endpoints[endpoint] = &cp
+			d.Requests += u.Requests
+			d.Sats += u.Sats
+			total := out.ByEndpoint[endpoint]
+			if total == nil {
+				total = &EndpointUsage{}
+				out.ByEndpoint[endpoint] = total
+			}
+			total.Requests += u.Requests
+			total.Sats += u.Sats
+		}
+		out.History = append(out.History, d)
+	}
+	sort.Slice(out.History, func(i, j int) bool { return out.History[i].Date > out.History[j].Date })
+	for _, t := range a.Topups {
+		cp := *t
+		out.Topups = append(out.Topups, &cp)
+	}
+	return out, true
+}
+
+// apiKeyFrom returns the API key a request carries in X-API-Key.
+func apiKeyFrom(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// accountFor resolves the request's API key, writing a 401 when it is missing
+// or unknown.
+func accountFor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	key := apiKeyFrom(r)
+	if key == "" {
+		writeAPIError(w, &APIError{Status: http.StatusUnauthorized, Message: "X-API-Key header required"})
+		return "", false
+	}
+	pubkey, ok := accountStore.Lookup(key)
+	if !ok {
+		writeAPIError(w, &APIError{Status: http.StatusUnauthorized, Code: "invalid_api_key", Message: "unknown API key"})
+		return "", false
+	}
+	return pubkey, true
+}
+
+// handleAccount handles POST /account
+// Creates an API key for the pubkey signing Authorization: Nostr <base64 kind
+// 22242 event>. Calling it again rotates the key. The key is only shown here.
+func handleAccount(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 4<<10))
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "failed to read body"})
+		return
+	}
+	// Minting a key hands over the balance, so the proof must be NIP-98: bound to
+	// this URL and method and at most a minute old, unlike the reusable rate-tier
+	// event, which anyone who saw one request could replay here.
+	pubkey, err := verifyNIP98(r, body)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Nostr")
+		writeAPIError(w, &APIError{Status: http.StatusUnauthorized, Message: "unauthorized: " + err.Error()})
+		return
+	}
+	key, a := accountStore.Create(pubkey)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pubkey":       pubkey,
+		"api_key":      key,
+		"balance_sats": a.Balance,
+		"message":      "Store this key: it is not shown again. Send it as X-API-Key; top up with POST /account/topup.",
+	})
+}
+
+// handleAccountTopup handles POST /account/topup?sats=N
+// Returns a Lightning invoice that credits the account once paid. Payment is
+// picked up by the next GET /account/usage.
+func handleAccountTopup(w http.ResponseWriter, r *http.Request, m *L402Middleware) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	pubkey, ok := accountFor(w, r)
+	if !ok {
+		return
+	}
+	q := bindQuery(r)
+	q.String("sats", true)
+	sats := q.Int("sats", 0, accountTopupMin, accountTopupMax)
+	if q.Failed(w) {
+		return
+	}
+	if m == nil {
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "Lightning payments are not configured"})
+		return
+	}
+	invoice, hash, err := m.createInvoice(int64(sats), fmt.Sprintf("WoT API credit (%d sats)", sats))
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadGateway, Message: "failed to create invoice"})
+		return
+	}
+	t := &Topup{PaymentHash: hash, Invoice: invoice, Sats: int64(sats), Status: "pending", CreatedAt: time.Now()}
+	accountStore.AddTopup(pubkey, t)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(t)
+}
+
+// handleAccountUsage handles GET /account/usage?days=N
+// Returns the balance, per-endpoint usage over the last N days (default 7, max
+// 30), and top-ups, crediting any that have been paid.
+func handleAccountUsage(w http.ResponseWriter, r *http.Request, m *L402Middleware) {
+	pubkey, ok := accountFor(w, r)
+	if !ok {
+		return
+	}
+	q := bindQuery(r)
+	days := q.Int("days", 7, 1, accountUsageDays)
+	if q.Failed(w) {
+		return
+	}
+	if m != nil {
+		accountStore.SettleTopups(pubkey, m.verifyPayment)
+	}
+	usage, _ := accountStore.Usage(pubkey, days)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
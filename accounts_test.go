@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func setupAccounts(t *testing.T, path string) *AccountStore {
+	t.Helper()
+	old := accountStore
+	t.Cleanup(func() { accountStore = old })
+	accountStore = NewAccountStore(path)
+	return accountStore
+}
+
+func TestAccountStoreCharge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	s := setupAccounts(t, path)
+	pub := padHex(48001)
+	key, _ := s.Create(pub)
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		t.Fatalf("expected a %s key, got %q", apiKeyPrefix, key)
+	}
+	if got, ok := s.Lookup(key); !ok || got != pub {
+		t.Fatalf("expected the key to resolve to its pubkey, got %q %v", got, ok)
+	}
+
+	if _, ok := s.Charge(pub, "/score", 1); ok {
+		t.Errorf("expected an empty balance to refuse a charge")
+	}
+	s.accounts[pub].Balance = 10
+	s.Charge(pub, "/score", 1)
+	s.Charge(pub, "/score", 1)
+	if left, ok := s.Charge(pub, "/batch", 5); !ok || left != 3 {
+		t.Errorf("expected 3 sats left, got %d %v", left, ok)
+	}
+	if left, ok := s.Charge(pub, "/batch", 5); ok || left != 3 {
+		t.Errorf("expected an overdraft refused, got %d %v", left, ok)
+	}
+
+	usage, _ := s.Usage(pub, 7)
+	if usage.Balance != 3 || usage.Spent != 7 || usage.ByEndpoint["/score"].Requests != 2 || usage.ByEndpoint["/batch"].Sats != 5 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+	if len(usage.History) != 1 || usage.History[0].Requests != 3 || usage.History[0].Sats != 7 {
+		t.Errorf("expected one day of history, got %+v", usage.History)
+	}
+
+	// Rotating the key keeps the balance and retires the old key
+	newKey, a := s.Create(pub)
+	if _, ok := s.Lookup(key); ok || a.Balance != 3 {
+		t.Errorf("expected the old key retired and the balance kept")
+	}
+
+	s.save()
+	restored := NewAccountStore(path)
+	if err := restored.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := restored.Lookup(newKey); !ok || got != pub || restored.accounts[pub].Balance != 3 {
+		t.Errorf("expected the account restored")
+	}
+}
+
+func TestAccountUsageWindow(t *testing.T) {
+	s := setupAccounts(t, "")
+	pub := padHex(48002)
+	s.Create(pub)
+	s.accounts[pub].Balance = 100
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return day }
+	s.Charge(pub, "/score", 1)
+	day = day.AddDate(0, 0, 10)
+	s.Charge(pub, "/score", 1)
+
+	if usage, _ := s.Usage(pub, 7); len(usage.History) != 1 || usage.ByEndpoint["/score"].Requests != 1 {
+		t.Errorf("expected only the recent day in a 7-day window, got %+v", usage.History)
+	}
+	if usage, _ := s.Usage(pub, 30); len(usage.History) != 2 || usage.History[0].Date != "2026-03-11" {
+		t.Errorf("expected both days newest first, got %+v", usage.History)
+	}
+
+	// Days past the retention window are dropped on the next new day
+	day = day.AddDate(0, 0, accountUsageDays)
+	s.Charge(pub, "/score", 1)
+	if _, ok := s.accounts[pub].Usage["2026-03-01"]; ok {
+		t.Errorf("expected old usage pruned")
+	}
+}
+
+func TestAccountTopupSettle(t *testing.T) {
+	s := setupAccounts(t, "")
+	pub := padHex(48003)
+	s.Create(pub)
+	start := time.Now()
+	s.now = func() time.Time { return start }
+	s.AddTopup(pub, &Topup{PaymentHash: "paid", Sats: 500, Status: "pending", CreatedAt: start})
+	s.AddTopup(pub, &Topup{PaymentHash: "unpaid", Sats: 100, Status: "pending", CreatedAt: start})
+
+	paid := func(hash string) bool { return hash == "paid" }
+	s.SettleTopups(pub, paid)
+	s.SettleTopups(pub, paid) // settled top-ups aren't credited twice
+	if s.accounts[pub].Balance != 500 {
+		t.Errorf("expected 500 sats credited once, got %d", s.accounts[pub].Balance)
+	}
+
+	s.now = func() time.Time { return start.Add(accountTopupTTL + time.Minute) }
+	s.SettleTopups(pub, paid)
+	if got := s.accounts[pub].Topups[1].Status; got != "expired" {
+		t.Errorf("expected the unpaid top-up expired, got %s", got)
+	}
+}
+
+func TestHandleAccountEndpoints(t *testing.T) {
+	s := setupAccounts(t, "")
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+
+	r := httptest.NewRequest(http.MethodPost, "http://wot.example/account", nil)
+	r.Header.Set("Authorization", nip98Header(t, sk, "POST", "https://wot.example/account", nil, time.Now()))
+	rr := httptest.NewRecorder()
+	handleAccount(rr, r)
+	var created struct {
+		Pubkey string `json:"pubkey"`
+		APIKey string `json:"api_key"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &created)
+	if rr.Code != http.StatusCreated || created.Pubkey != pub || created.APIKey == "" {
+		t.Fatalf("expected an account created, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handleAccount(rr, httptest.NewRequest(http.MethodPost, "/account", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a signed header, got %d", rr.Code)
+	}
+
+	// a rate-tier auth event isn't bound to this request, so it can't rotate the key
+	r = httptest.NewRequest(http.MethodPost, "http://wot.example/account", nil)
+	r.Header.Set("Authorization", nostrAuthHeader(t, sk, "wss://wot.example", time.Now()))
+	rr = httptest.NewRecorder()
+	handleAccount(rr, r)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a kind 22242 event, got %d", rr.Code)
+	}
+
+	mockLNbits := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{"payment_request": "lnbc10u1ptest", "payment_hash": "topuphash"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"paid": true})
+	}))
+	defer mockLNbits.Close()
+	m := newTestL402(t, mockLNbits.URL)
+
+	withKey := func(method, path string) *http.Request {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("X-API-Key", created.APIKey)
+		return r
+	}
+	rr = httptest.NewRecorder()
+	handleAccountTopup(rr, withKey(http.MethodPost, "/account/topup?sats=1000"), m)
+	if rr.Code != http.StatusCreated || !strings.Contains(rr.Body.String(), "topuphash") {
+		t.Fatalf("expected a top-up invoice, got %d %s", rr.Code, rr.Body.String())
+	}
+	for query, field := range map[string]string{"": "sats", "?sats=5": "sats", "?sats=x": "sats"} {
+		rr = httptest.NewRecorder()
+		handleAccountTopup(rr, withKey(http.MethodPost, "/account/topup"+query), m)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"field":"`+field+`"`) {
+			t.Errorf("%q: expected 400 naming %s, got %d %s", query, field, rr.Code, rr.Body.String())
+		}
+	}
+
+	// The paid top-up is credited, and priced requests draw on it
+	rr = httptest.NewRecorder()
+	handleAccountUsage(rr, withKey(http.MethodGet, "/account/usage"), m)
+	if !strings.Contains(rr.Body.String(), `"balance_sats":1000`) {
+		t.Fatalf("expected the top-up credited, got %s", rr.Body.String())
+	}
+	handler := m.Wrap(dummyHandler())
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, withKey(http.MethodGet, "/batch"))
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Account-Balance") != "990" {
+		t.Errorf("expected /batch charged 10 sats, got %d %q", rr.Code, rr.Header().Get("X-Account-Balance"))
+	}
+	if u, _ := s.Usage(pub, 1); u.ByEndpoint["/batch"].Requests != 1 {
+		t.Errorf("expected the request recorded, got %+v", u.ByEndpoint)
+	}
+
+	s.accounts[pub].Balance = 0
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, withKey(http.MethodGet, "/batch"))
+	if rr.Code != http.StatusPaymentRequired || !strings.Contains(rr.Body.String(), "insufficient_balance") {
+		t.Errorf("expected 402 insufficient_balance, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/batch", nil)
+	r.Header.Set("X-API-Key", "wot_unknown")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an unknown key, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	// activeWithinDays and dormantWithinDays bound the active/dormant/abandoned classes.
+	activeWithinDays  = 30
+	dormantWithinDays = 180
+)
+
+// dormancyDiscounts scale decay scores when ?dormancy=true. Unknown activity is not
+// penalized: most of the graph is outside the metadata crawl.
+var dormancyDiscounts = map[string]float64{
+	"active":    1.0,
+	"dormant":   0.8,
+	"abandoned": 0.5,
+	"unknown":   1.0,
+}
+
+// PostingCadence summarizes the sampled note timestamps.
+type PostingCadence struct {
+	SampledNotes   int     `json:"sampled_notes"`
+	NotesPerWeek   float64 `json:"notes_per_week"`
+	MedianGapHours float64 `json:"median_gap_hours"`
+	SampleSpanDays float64 `json:"sample_span_days"`
+}
+
+// ActivityResponse is the response for GET /active (and its alias /activity).
+type ActivityResponse struct {
+	Pubkey            string             `json:"pubkey"`
+	Status            string             `json:"status"` // active, dormant, abandoned, unknown
+	LastSeen          string             `json:"last_seen,omitempty"`
+	LastSeenSource    string             `json:"last_seen_source,omitempty"` // events, follow_list
+	DaysSinceLastSeen *int               `json:"days_since_last_seen,omitempty"`
+	LastEvent         string             `json:"last_event,omitempty"`
+	FollowListUpdated string             `json:"follow_list_updated,omitempty"`
+	Cadence           *PostingCadence    `json:"cadence,omitempty"`
+	Automation        AutomationAnalysis `json:"automation"`
+	DormancyDiscount  float64            `json:"dormancy_discount"`
+	GraphSize         int                `json:"graph_size"`
+}
+
+// followListUpdated returns the newest follow timestamp on pubkey's contact list,
+// which is when the list was last published.
+func followListUpdated(pubkey string) time.Time {
+	var latest time.Time
+	for _, f := range graph.GetFollows(pubkey) {
+		if t := graph.GetFollowTime(pubkey, f); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// postingCadence derives note frequency from a newest-first timestamp sample.
+// It needs at least two notes.
+func postingCadence(noteTimes []int64) *PostingCadence {
+	if len(noteTimes) < 2 {
+		return nil
+	}
+	gaps := make([]float64, 0, len(noteTimes)-1)
+	for i := 1; i < len(noteTimes); i++ {
+		gaps = append(gaps, float64(noteTimes[i-1]-noteTimes[i])/3600)
+	}
+	sort.Float64s(gaps)
+	median := gaps[len(gaps)/2]
+	if len(gaps)%2 == 0 {
+		median = (gaps[len(gaps)/2-1] + gaps[len(gaps)/2]) / 2
+	}
+	spanDays := float64(noteTimes[0]-noteTimes[len(noteTimes)-1]) / 86400
+	perWeek := 0.0
+	if spanDays > 0 {
+		perWeek = float64(len(noteTimes)-1) / spanDays * 7
+	}
+	return &PostingCadence{
+		SampledNotes:   len(noteTimes),
+		NotesPerWeek:   math.Round(perWeek*100) / 100,
+		MedianGapHours: math.Round(median*10) / 10,
+		SampleSpanDays: math.Round(spanDays*10) / 10,
+	}
+}
+
+// classifyActivity maps days since last seen to a status.
+func classifyActivity(days int, known bool) string {
+	switch {
+	case !known:
+		return "unknown"
+	case days <= activeWithinDays:
+		return "active"
+	case days <= dormantWithinDays:
+		return "dormant"
+	default:
+		return "abandoned"
+	}
+}
+
+// computeActivity reports last-seen activity for pubkey from the metadata crawl
+// (authored notes and reactions) and the follow-list timestamp, whichever is newer.
+func computeActivity(pubkey string, now time.Time) ActivityResponse {
+	resp := ActivityResponse{Pubkey: pubkey}
+
+	lastEvent, noteTimes := meta.Activity(pubkey)
+	listUpdated := followListUpdated(pubkey)
+
+	var lastSeen time.Time
+	if lastEvent > 0 {
+		lastSeen = time.Unix(lastEvent, 0)
+		resp.LastEvent = lastSeen.UTC().Format(time.RFC3339)
+		resp.LastSeenSource = "events"
+	}
+	if !listUpdated.IsZero() {
+		resp.FollowListUpdated = listUpdated.UTC().Format(time.RFC3339)
+		if listUpdated.After(lastSeen) {
+			lastSeen = listUpdated
+			resp.LastSeenSource = "follow_list"
+		}
+	}
+
+	days := 0
+	if !lastSeen.IsZero() {
+		days = int(math.Max(0, now.Sub(lastSeen).Hours()/24))
+		resp.LastSeen = lastSeen.UTC().Format(time.RFC3339)
+		resp.DaysSinceLastSeen = &days
+	}
+	resp.Status = classifyActivity(days, !lastSeen.IsZero())
+	resp.Cadence = postingCadence(noteTimes)
+	resp.Automation = pubkeyAutomation(pubkey)
+	resp.DormancyDiscount = dormancyDiscounts[resp.Status]
+	return resp
+}
+
+// handleActive serves GET /active?pubkey=<hex|npub>.
+func handleActive(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
+		return
+	}
+
+	resp := computeActivity(pubkey, time.Now())
+	resp.GraphSize = graph.Stats().Nodes
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
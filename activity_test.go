@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRecordNoteTimeKeepsNewestUnique(t *testing.T) {
+	m := &PubkeyMeta{}
+	for _, ts := range []int64{100, 300, 200, 300, 50} {
+		m.recordNoteTime(ts)
+	}
+	want := []int64{300, 200, 100, 50}
+	if len(m.NoteTimes) != len(want) {
+		t.Fatalf("expected %v, got %v", want, m.NoteTimes)
+	}
+	for i := range want {
+		if m.NoteTimes[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, m.NoteTimes)
+		}
+	}
+
+	m = &PubkeyMeta{}
+	for i := 0; i < maxNoteTimes+10; i++ {
+		m.recordNoteTime(int64(1000 + i))
+	}
+	if len(m.NoteTimes) != maxNoteTimes || m.NoteTimes[0] != int64(1000+maxNoteTimes+9) {
+		t.Errorf("expected newest %d notes kept, got %d starting at %d", maxNoteTimes, len(m.NoteTimes), m.NoteTimes[0])
+	}
+}
+
+func TestPostingCadence(t *testing.T) {
+	if postingCadence([]int64{100}) != nil {
+		t.Error("a single note has no cadence")
+	}
+	day := int64(86400)
+	c := postingCadence([]int64{8 * day, 6 * day, 4 * day, 2 * day, 0})
+	if c.SampledNotes != 5 || c.MedianGapHours != 48 || c.SampleSpanDays != 8 || c.NotesPerWeek != 3.5 {
+		t.Errorf("unexpected cadence %+v", c)
+	}
+}
+
+func TestComputeActivityClassification(t *testing.T) {
+	oldGraph, oldMeta := graph, meta
+	defer func() { graph, meta = oldGraph, oldMeta }()
+	graph = NewGraph()
+	meta = NewMetaStore()
+
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	poster, lister, gone := padHex(23001), padHex(23002), padHex(23003)
+
+	m := meta.Get(poster)
+	m.recordActivity(now.AddDate(0, 0, -3).Unix())
+	m.recordNoteTime(now.AddDate(0, 0, -3).Unix())
+	m.recordNoteTime(now.AddDate(0, 0, -5).Unix())
+	graph.AddFollowWithTime(poster, lister, now.AddDate(0, 0, -90))
+
+	graph.AddFollowWithTime(lister, poster, now.AddDate(0, 0, -60))
+	graph.AddFollowWithTime(gone, poster, now.AddDate(-2, 0, 0))
+
+	a := computeActivity(poster, now)
+	if a.Status != "active" || a.LastSeenSource != "events" || *a.DaysSinceLastSeen != 3 || a.Cadence == nil {
+		t.Errorf("unexpected activity for poster %+v", a)
+	}
+	if a.FollowListUpdated == "" {
+		t.Error("expected follow list timestamp")
+	}
+
+	a = computeActivity(lister, now)
+	if a.Status != "dormant" || a.LastSeenSource != "follow_list" || a.DormancyDiscount != dormancyDiscounts["dormant"] {
+		t.Errorf("unexpected activity for lister %+v", a)
+	}
+
+	if a = computeActivity(gone, now); a.Status != "abandoned" || a.DormancyDiscount != 0.5 {
+		t.Errorf("unexpected activity for gone %+v", a)
+	}
+	if a = computeActivity(padHex(23004), now); a.Status != "unknown" || a.DormancyDiscount != 1 || a.DaysSinceLastSeen != nil {
+		t.Errorf("unexpected activity for unseen pubkey %+v", a)
+	}
+}
+
+func TestActiveEndpoint(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handleActive(rr, httptest.NewRequest("GET", "/active", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without pubkey, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handleActive(rr, httptest.NewRequest("GET", "/active?pubkey="+padHex(23100), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var resp ActivityResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Pubkey != padHex(23100) || resp.Status == "" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestDecayDormancyDiscount(t *testing.T) {
+	oldGraph, oldMeta := graph, meta
+	defer func() { graph, meta = oldGraph, oldMeta }()
+	graph = NewGraph()
+	meta = NewMetaStore()
+
+	// The subject last published its follow list two years ago
+	now := time.Now()
+	subject := padHex(23201)
+	graph.AddFollowWithTime(subject, padHex(23202), now.AddDate(-2, 0, 0))
+	for i := 0; i < 20; i++ {
+		graph.AddFollowWithTime(padHex(23300+i), subject, now.AddDate(0, -1, 0))
+	}
+	graph.ComputePageRank(20, 0.85)
+
+	get := func(q string) map[string]interface{} {
+		rr := httptest.NewRecorder()
+		handleDecay(rr, httptest.NewRequest("GET", "/decay?pubkey="+subject+q, nil))
+		var resp map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return resp
+	}
+	plain := get("")
+	discounted := get("&dormancy=true")
+	if discounted["activity_status"] != "abandoned" || discounted["dormancy_discount"] != 0.5 {
+		t.Fatalf("expected abandoned discount, got %+v", discounted)
+	}
+	if _, ok := plain["dormancy_discount"]; ok {
+		t.Error("dormancy fields must be opt-in")
+	}
+	if discounted["decay_score"].(float64) >= plain["decay_score"].(float64) {
+		t.Errorf("expected discounted score below %v, got %v", plain["decay_score"], discounted["decay_score"])
+	}
+}
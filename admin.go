@@ -0,0 +1,27 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// requireAdmin checks the request for "Authorization: Bearer <ADMIN_TOKEN>".
+// It writes an error response and returns false if the caller is not the operator.
+// Admin endpoints are disabled entirely when ADMIN_TOKEN is unset.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" {
+		writeAPIError(w, &APIError{Status: http.StatusForbidden, Message: "admin API disabled (ADMIN_TOKEN not set)"})
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	given, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeAPIError(w, &APIError{Status: http.StatusUnauthorized, Message: "admin authorization required"})
+		return false
+	}
+	return true
+}
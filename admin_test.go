@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		ok     bool
+		code   int
+	}{
+		{"disabled", "", "Bearer anything", false, http.StatusForbidden},
+		{"missing header", "tok", "", false, http.StatusUnauthorized},
+		{"wrong scheme", "tok", "Basic tok", false, http.StatusUnauthorized},
+		{"wrong token", "tok", "Bearer nope", false, http.StatusUnauthorized},
+		{"valid", "tok", "Bearer tok", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Setenv("ADMIN_TOKEN", tt.token)
+		req := httptest.NewRequest("POST", "/rebuild", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		rr := httptest.NewRecorder()
+		if got := requireAdmin(rr, req); got != tt.ok {
+			t.Errorf("%s: requireAdmin = %v, want %v", tt.name, got, tt.ok)
+		}
+		if rr.Code != tt.code {
+			t.Errorf("%s: status %d, want %d", tt.name, rr.Code, tt.code)
+		}
+	}
+}
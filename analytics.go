@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// analyticsMaxSubjects bounds memory; when exceeded the least-queried subjects are dropped.
+const analyticsMaxSubjects = 50000
+
+// analyticsDTagKinds maps endpoints to the NIP-85 assertion kind whose d-tag they serve.
+var analyticsDTagKinds = map[string]int{
+	"/score":          30382,
+	"/metadata":       30382,
+	"/batch":          30382,
+	"/metadata/batch": 30382,
+	"/event":          30383,
+	"/external":       30385,
+}
+
+// analyticsSubjectParams are the query parameters that name the subject of a request.
+var analyticsSubjectParams = []string{"pubkey", "target", "subject", "viewer"}
+
+// analyticsBatchPaths take a JSON body of {"pubkeys": [...]}; each pubkey counts as a query.
+var analyticsBatchPaths = map[string]bool{
+	"/batch":           true,
+	"/metadata/batch":  true,
+	"/spam/batch":      true,
+	"/sybil/batch":     true,
+	"/influence/batch": true,
+	"/org-score":       true,
+}
+
+// SubjectStats is the aggregate query count for one subject.
+type SubjectStats struct {
+	Subject   string           `json:"subject"`
+	Total     int64            `json:"total"`
+	Endpoints map[string]int64 `json:"endpoints"`
+	FirstSeen int64            `json:"first_seen"`
+	LastSeen  int64            `json:"last_seen"`
+}
+
+// DTagStats counts how often an assertion d-tag was served.
+type DTagStats struct {
+	Kind    int    `json:"kind"`
+	DTag    string `json:"d"`
+	Fetches int64  `json:"fetches"`
+}
+
+type dtagKey struct {
+	kind int
+	d    string
+}
+
+// AnalyticsStore aggregates which subjects are queried, through which endpoints,
+// and which assertion d-tags are served. It keeps counts only — no caller
+// identity, IP, or timing beyond first/last seen per subject.
+type AnalyticsStore struct {
+	mu       sync.Mutex
+	subjects map[string]*SubjectStats
+	dtags    map[dtagKey]int64
+	since    time.Time
+	max      int
+}
+
+func NewAnalyticsStore() *AnalyticsStore {
+	return &AnalyticsStore{
+		subjects: make(map[string]*SubjectStats),
+		dtags:    make(map[dtagKey]int64),
+		since:    time.Now(),
+		max:      analyticsMaxSubjects,
+	}
+}
+
+// Record counts one query of endpoint for each subject. If the endpoint serves an
+// assertion kind, the subject is also counted as a fetch of that kind's d-tag.
+func (a *AnalyticsStore) Record(endpoint string, subjects []string, now time.Time) {
+	if len(subjects) == 0 {
+		return
+	}
+	kind := analyticsDTagKinds[endpoint]
+	ts := now.Unix()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, subject := range subjects {
+		s := a.subjects[subject]
+		if s == nil {
+			s = &SubjectStats{Subject: subject, Endpoints: make(map[string]int64), FirstSeen: ts}
+			a.subjects[subject] = s
+		}
+		s.Total++
+		s.Endpoints[endpoint]++
+		s.LastSeen = ts
+		if kind != 0 {
+			a.dtags[dtagKey{kind, subject}]++
+		}
+	}
+	if len(a.subjects) > a.max {
+		a.pruneLocked()
+	}
+}
+
+// pruneLocked drops the least-queried subjects (oldest first on ties) down to 90% of max.
+func (a *AnalyticsStore) pruneLocked() {
+	all := make([]*SubjectStats, 0, len(a.subjects))
+	for _, s := range a.subjects {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Total != all[j].Total {
+			return all[i].Total < all[j].Total
+		}
+		return all[i].LastSeen < all[j].LastSeen
+	})
+	target := a.max * 9 / 10
+	for _, s := range all[:len(all)-target] {
+		delete(a.subjects, s.Subject)
+		for key := range a.dtags {
+			if key.d == s.Subject {
+				delete(a.dtags, key)
+			}
+		}
+	}
+}
+
+// TopSubjects returns the most-queried subjects, optionally counting only one endpoint.
+func (a *AnalyticsStore) TopSubjects(n int, endpoint string) []SubjectStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]SubjectStats, 0, len(a.subjects))
+	for _, s := range a.subjects {
+		if endpoint != "" && s.Endpoints[endpoint] == 0 {
+			continue
+		}
+		cp := *s
+		cp.Endpoints = make(map[string]int64, len(s.Endpoints))
+		for k, v := range s.Endpoints {
+			cp.Endpoints[k] = v
+		}
+		out = append(out, cp)
+	}
+	count := func(s SubjectStats) int64 {
+		if endpoint != "" {
+			return s.Endpoints[endpoint]
+		}
+		return s.Total
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if ci, cj := count(out[i]), count(out[j]); ci != cj {
+			return ci > cj
+		}
+		return out[i].Subject < out[j].Subject
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// TopDTags returns the most-fetched assertion d-tags, optionally for one kind.
+func (a *AnalyticsStore) TopDTags(n, kind int) []DTagStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]DTagStats, 0, len(a.dtags))
+	for key, c := range a.dtags {
+		if kind != 0 && key.kind != kind {
+			continue
+		}
+		out = append(out, DTagStats{Kind: key.kind, DTag: key.d, Fetches: c})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Fetches != out[j].Fetches {
+			return out[i].Fetches > out[j].Fetches
+		}
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].DTag < out[j].DTag
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// Counts returns the number of tracked subjects and d-tags.
+func (a *AnalyticsStore) Counts() (subjects, dtags int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.subjects), len(a.dtags)
+}
+
+// analyticsSubjects extracts the subjects a request is about. Pubkey parameters
+// must resolve to 64-char hex; /event and /external also count their id parameter.
+// For batch endpoints the body is read, parsed, and restored for the handler.
+func analyticsSubjects(r *http.Request) []string {
+	var subjects []string
+	q := r.URL.Query()
+	for _, param := range analyticsSubjectParams {
+		if raw := q.Get(param); raw != "" {
+			if pk, err := resolvePubkey(raw); err == nil && hex64Pattern.MatchString(pk) {
+				subjects = append(subjects, pk)
+			}
+		}
+	}
+	if id := q.Get("id"); id != "" && len(id) <= 256 {
+		switch r.URL.Path {
+		case "/event":
+			if hex64Pattern.MatchString(id) {
+				subjects = append(subjects, id)
+			}
+		case "/external":
+			subjects = append(subjects, id)
+		}
+	}
+
+	if r.Method == http.MethodPost && analyticsBatchPaths[r.URL.Path] && r.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return subjects
+		}
+		var req struct {
+			Pubkeys []string `json:"pubkeys"`
+		}
+		if json.Unmarshal(body, &req) == nil && len(req.Pubkeys) <= 100 {
+			for _, raw := range req.Pubkeys {
+				if pk, err := resolvePubkey(raw); err == nil && hex64Pattern.MatchString(pk) {
+					subjects = append(subjects, pk)
+				}
+			}
+		}
+	}
+	return subjects
+}
+
+// AnalyticsMiddleware records per-subject query counts before passing the request on.
+func AnalyticsMiddleware(store *AnalyticsStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subjects := analyticsSubjects(r); len(subjects) > 0 {
+			store.Record(r.URL.Path, subjects, time.Now())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleAnalyticsSubjects serves GET /analytics/subjects (admin):
+// ?limit=50&endpoint=/score&kind=30382
+func handleAnalyticsSubjects(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	q := bindQuery(r)
+	limit := q.Int("limit", 50, 1, 1000)
+	kind, _ := strconv.Atoi(q.OneOf("kind", "30382", "30383", "30384", "30385"))
+	endpoint := q.String("endpoint", false)
+	if q.Failed(w) {
+		return
+	}
+
+	trackedSubjects, trackedDTags := analytics.Counts()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subjects":         analytics.TopSubjects(limit, endpoint),
+		"dtags":            analytics.TopDTags(limit, kind),
+		"tracked_subjects": trackedSubjects,
+		"tracked_dtags":    trackedDTags,
+		"since":            analytics.since.UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAnalyticsStoreRecordAndTop(t *testing.T) {
+	a := NewAnalyticsStore()
+	now := time.Unix(1000, 0)
+	hot, cold := padHex(17001), padHex(17002)
+
+	for i := 0; i < 3; i++ {
+		a.Record("/score", []string{hot}, now)
+	}
+	a.Record("/sybil", []string{hot, cold}, now.Add(time.Minute))
+	a.Record("/event", []string{padHex(17003)}, now)
+
+	top := a.TopSubjects(10, "")
+	if len(top) != 3 || top[0].Subject != hot || top[0].Total != 4 {
+		t.Fatalf("expected hot subject first with 4 queries, got %+v", top)
+	}
+	if top[0].Endpoints["/score"] != 3 || top[0].Endpoints["/sybil"] != 1 {
+		t.Errorf("unexpected per-endpoint counts %+v", top[0].Endpoints)
+	}
+	if top[0].FirstSeen != 1000 || top[0].LastSeen != 1060 {
+		t.Errorf("unexpected first/last seen %d/%d", top[0].FirstSeen, top[0].LastSeen)
+	}
+
+	if got := a.TopSubjects(10, "/sybil"); len(got) != 2 {
+		t.Errorf("expected 2 subjects queried via /sybil, got %d", len(got))
+	}
+
+	dtags := a.TopDTags(10, 0)
+	if len(dtags) != 2 || dtags[0].Kind != 30382 || dtags[0].DTag != hot || dtags[0].Fetches != 3 {
+		t.Fatalf("expected 30382 d-tag fetched 3 times first, got %+v", dtags)
+	}
+	if got := a.TopDTags(10, 30383); len(got) != 1 || got[0].DTag != padHex(17003) {
+		t.Errorf("expected one 30383 d-tag, got %+v", got)
+	}
+}
+
+func TestAnalyticsStorePrunesLeastQueried(t *testing.T) {
+	a := NewAnalyticsStore()
+	a.max = 10
+	keep := padHex(17100)
+	for i := 0; i < 5; i++ {
+		a.Record("/score", []string{keep}, time.Unix(1, 0))
+	}
+	for i := 0; i < 10; i++ {
+		a.Record("/score", []string{padHex(17200 + i)}, time.Unix(int64(2+i), 0))
+	}
+	subjects, dtags := a.Counts()
+	if subjects != 9 || dtags != 9 {
+		t.Fatalf("expected prune to 9 subjects and d-tags, got %d/%d", subjects, dtags)
+	}
+	if top := a.TopSubjects(1, ""); top[0].Subject != keep {
+		t.Error("most-queried subject must survive pruning")
+	}
+}
+
+func TestAnalyticsMiddlewareExtractsSubjects(t *testing.T) {
+	a := NewAnalyticsStore()
+	var gotBody string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+	})
+	h := AnalyticsMiddleware(a, next)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/score?pubkey="+padHex(17301), nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/external?id=%23bitcoin", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/score?pubkey=not-a-key", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/top", nil))
+
+	body := `{"pubkeys":["` + padHex(17302) + `","` + padHex(17303) + `"]}`
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/batch", bytes.NewBufferString(body)))
+	if gotBody != body {
+		t.Fatalf("batch body must be restored for the handler, got %q", gotBody)
+	}
+
+	subjects, _ := a.Counts()
+	if subjects != 4 {
+		t.Fatalf("expected 4 tracked subjects, got %d", subjects)
+	}
+	if got := a.TopDTags(10, 30385); len(got) != 1 || got[0].DTag != "#bitcoin" {
+		t.Errorf("expected #bitcoin 30385 d-tag, got %+v", got)
+	}
+	if got := a.TopSubjects(10, "/batch"); len(got) != 2 {
+		t.Errorf("expected 2 subjects via /batch, got %d", len(got))
+	}
+}
+
+func TestAnalyticsSubjectsEndpointRequiresAdmin(t *testing.T) {
+	oldAnalytics := analytics
+	defer func() { analytics = oldAnalytics }()
+	analytics = NewAnalyticsStore()
+	analytics.Record("/score", []string{padHex(17401)}, time.Now())
+
+	t.Setenv("ADMIN_TOKEN", "")
+	rr := httptest.NewRecorder()
+	handleAnalyticsSubjects(rr, httptest.NewRequest("GET", "/analytics/subjects", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 when admin disabled, got %d", rr.Code)
+	}
+
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	req := httptest.NewRequest("GET", "/analytics/subjects?kind=30382", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rr = httptest.NewRecorder()
+	handleAnalyticsSubjects(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var resp struct {
+		Subjects        []SubjectStats `json:"subjects"`
+		DTags           []DTagStats    `json:"dtags"`
+		TrackedSubjects int            `json:"tracked_subjects"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.TrackedSubjects != 1 || len(resp.Subjects) != 1 || len(resp.DTags) != 1 {
+		t.Errorf("unexpected analytics response %+v", resp)
+	}
+
+	req = httptest.NewRequest("GET", "/analytics/subjects?kind=1", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rr = httptest.NewRecorder()
+	handleAnalyticsSubjects(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid kind, got %d", rr.Code)
+	}
+}
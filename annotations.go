@@ -0,0 +1,380 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Annotation is a namespaced label a third party attaches to a pubkey,
+// e.g. namespace "bitcoin-core", label "contributor".
+type Annotation struct {
+	Namespace string `json:"namespace"`
+	Label     string `json:"label"`
+	Value     string `json:"value,omitempty"`
+	Provider  string `json:"provider"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// AnnotationSummary aggregates all providers' claims for one namespace:label on a subject.
+type AnnotationSummary struct {
+	Namespace  string               `json:"namespace"`
+	Label      string               `json:"label"`
+	Confidence float64              `json:"confidence"` // sum of provider weights, capped at 1
+	Providers  []AnnotationProvider `json:"providers"`
+}
+
+// AnnotationProvider is one provider's contribution to an AnnotationSummary.
+type AnnotationProvider struct {
+	Pubkey    string  `json:"pubkey"`
+	Weight    float64 `json:"weight"`
+	Value     string  `json:"value,omitempty"`
+	CreatedAt int64   `json:"created_at"`
+}
+
+// AnnotationStore holds third-party annotations keyed by subject pubkey.
+type AnnotationStore struct {
+	mu sync.RWMutex
+	// subject -> "namespace:label" -> provider -> annotation
+	data map[string]map[string]map[string]*Annotation
+	// namespace -> provider -> operator-configured weight (overrides WoT-derived weight)
+	weights map[string]map[string]float64
+}
+
+const (
+	annotationMinProviderScore = 20 // providers below this WoT score need an explicit weight
+	annotationMaxPerRequest    = 20
+	annotationMaxValueLen      = 256
+	annotationLabelThreshold   = 0.5 // minimum confidence for inclusion as NIP-32 labels
+)
+
+var annotationNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+func NewAnnotationStore() *AnnotationStore {
+	return &AnnotationStore{
+		data:    make(map[string]map[string]map[string]*Annotation),
+		weights: make(map[string]map[string]float64),
+	}
+}
+
+// NewAnnotationStoreFromEnv creates a store with provider weights from
+// ANNOTATION_PROVIDER_WEIGHTS ("namespace:pubkey=weight,...").
+func NewAnnotationStoreFromEnv() *AnnotationStore {
+	s := NewAnnotationStore()
+	for _, entry := range splitCommaList(os.Getenv("ANNOTATION_PROVIDER_WEIGHTS")) {
+		key, val, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		ns, provider, ok := strings.Cut(key, ":")
+		if !ok {
+			continue
+		}
+		w, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			continue
+		}
+		s.SetWeight(ns, provider, w)
+	}
+	return s
+}
+
+// SetWeight configures an explicit trust weight (0-1) for a provider within a namespace.
+func (s *AnnotationStore) SetWeight(namespace, provider string, weight float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if weight < 0 {
+		weight = 0
+	}
+	if weight > 1 {
+		weight = 1
+	}
+	if s.weights[namespace] == nil {
+		s.weights[namespace] = make(map[string]float64)
+	}
+	s.weights[namespace][provider] = weight
+}
+
+// ExplicitWeight returns the configured weight for a provider in a namespace, if any.
+func (s *AnnotationStore) ExplicitWeight(namespace, provider string) (float64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	w, ok := s.weights[namespace][provider]
+	return w, ok
+}
+
+// Add stores an annotation, replacing any older one from the same provider.
+func (s *AnnotationStore) Add(subject string, a *Annotation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := a.Namespace + ":" + a.Label
+	if s.data[subject] == nil {
+		s.data[subject] = make(map[string]map[string]*Annotation)
+	}
+	if s.data[subject][key] == nil {
+		s.data[subject][key] = make(map[string]*Annotation)
+	}
+	if existing := s.data[subject][key][a.Provider]; existing != nil && existing.CreatedAt > a.CreatedAt {
+		return
+	}
+	s.data[subject][key][a.Provider] = a
+}
+
+// Remove deletes a provider's annotation from a subject. Providers can only remove their own.
+func (s *AnnotationStore) Remove(subject, namespace, label, provider string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := namespace + ":" + label
+	byProvider := s.data[subject][key]
+	if byProvider[provider] == nil {
+		return false
+	}
+	delete(byProvider, provider)
+	if len(byProvider) == 0 {
+		delete(s.data[subject], key)
+	}
+	if len(s.data[subject]) == 0 {
+		delete(s.data, subject)
+	}
+	return true
+}
+
+// Get returns all raw annotations on a subject.
+func (s *AnnotationStore) Get(subject string) []*Annotation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []*Annotation
+	for _, byProvider := range s.data[subject] {
+		for _, a := range byProvider {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// SubjectCount returns how many pubkeys carry at least one annotation.
+func (s *AnnotationStore) SubjectCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.data)
+}
+
+// annotationProviderWeight returns how much a provider's annotations count within a namespace.
+// Operator-configured weights win; otherwise the provider's normalized WoT score is used.
+func annotationProviderWeight(store *AnnotationStore, namespace, provider string) float64 {
+	if w, ok := store.ExplicitWeight(namespace, provider); ok {
+		return w
+	}
+	raw, found := graph.GetScore(provider)
+	if !found {
+		return 0
+	}
+	return float64(normalizeScore(raw, graph.Stats().Nodes)) / 100.0
+}
+
+// annotationSummaries aggregates a subject's annotations by namespace:label with trust weighting.
+func annotationSummaries(store *AnnotationStore, subject string) []AnnotationSummary {
+	grouped := make(map[string]*AnnotationSummary)
+	for _, a := range store.Get(subject) {
+		key := a.Namespace + ":" + a.Label
+		sum, ok := grouped[key]
+		if !ok {
+			sum = &AnnotationSummary{Namespace: a.Namespace, Label: a.Label}
+			grouped[key] = sum
+		}
+		w := annotationProviderWeight(store, a.Namespace, a.Provider)
+		sum.Confidence += w
+		sum.Providers = append(sum.Providers, AnnotationProvider{
+			Pubkey:    a.Provider,
+			Weight:    math.Round(w*1000) / 1000,
+			Value:     a.Value,
+			CreatedAt: a.CreatedAt,
+		})
+	}
+
+	out := make([]AnnotationSummary, 0, len(grouped))
+	for _, sum := range grouped {
+		if sum.Confidence > 1 {
+			sum.Confidence = 1
+		}
+		sum.Confidence = math.Round(sum.Confidence*1000) / 1000
+		sort.Slice(sum.Providers, func(i, j int) bool {
+			return sum.Providers[i].Weight > sum.Providers[j].Weight
+		})
+		out = append(out, *sum)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Confidence != out[j].Confidence {
+			return out[i].Confidence > out[j].Confidence
+		}
+		return out[i].Namespace+":"+out[i].Label < out[j].Namespace+":"+out[j].Label
+	})
+	return out
+}
+
+// annotationLabelTags returns NIP-32 label tags for a subject's well-supported annotations.
+func annotationLabelTags(store *AnnotationStore, subject string) nostr.Tags {
+	var tags nostr.Tags
+	seenNS := make(map[string]bool)
+	for _, sum := range annotationSummaries(store, subject) {
+		if sum.Confidence < annotationLabelThreshold {
+			continue
+		}
+		if !seenNS[sum.Namespace] {
+			seenNS[sum.Namespace] = true
+			tags = append(tags, nostr.Tag{"L", sum.Namespace})
+		}
+		tags = append(tags, nostr.Tag{"l", sum.Label, sum.Namespace})
+	}
+	return tags
+}
+
+// publishAnnotationLabels reports whether NIP-32 labels should be added to kind 30382 events.
+func publishAnnotationLabels() bool {
+	v := os.Getenv("PUBLISH_ANNOTATION_LABELS")
+	return v == "1" || strings.EqualFold(v, "true")
+}
+
+type annotationInput struct {
+	Namespace string `json:"namespace"`
+	Label     string `json:"label"`
+	Value     string `json:"value,omitempty"`
+}
+
+func validateAnnotationInput(a annotationInput) error {
+	if !annotationNamePattern.MatchString(a.Namespace) {
+		return fmt.Errorf("invalid namespace %q (lowercase a-z, 0-9, '.', '_', '-'; max 64)", a.Namespace)
+	}
+	if !annotationNamePattern.MatchString(a.Label) {
+		return fmt.Errorf("invalid label %q (lowercase a-z, 0-9, '.', '_', '-'; max 64)", a.Label)
+	}
+	if len(a.Value) > annotationMaxValueLen {
+		return fmt.Errorf("value for %s:%s exceeds %d bytes", a.Namespace, a.Label, annotationMaxValueLen)
+	}
+	return nil
+}
+
+// handleAnnotations serves GET /annotations?pubkey= (aggregated annotations) and
+// POST /annotations (NIP-98 signed) for third parties to add or remove their annotations.
+func handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := bindQuery(r)
+		pubkey := q.Pubkey("pubkey", true)
+		if q.Failed(w) {
+			return
+		}
+		summaries := annotationSummaries(annotations, pubkey)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"pubkey":      pubkey,
+			"annotations": summaries,
+			"count":       len(summaries),
+		})
+	case http.MethodPost:
+		handleAnnotationsPost(w, r)
+	default:
+		writeAPIError(w, &APIError{Status: http.StatusMethodNotAllowed, Message: "GET or POST required"})
+	}
+}
+
+func handleAnnotationsPost(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "failed to read body"})
+		return
+	}
+
+	provider, err := verifyNIP98(r, body)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Nostr")
+		writeAPIError(w, &APIError{Status: http.StatusUnauthorized, Message: fmt.Sprintf("unauthorized: %s", err.Error())})
+		return
+	}
+
+	var req struct {
+		Subject     string            `json:"subject"`
+		Annotations []annotationInput `json:"annotations"`
+		Remove      []annotationInput `json:"remove"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "invalid_json", Message: "invalid JSON: " + err.Error()})
+		return
+	}
+	subject, err := resolvePubkey(req.Subject)
+	if err != nil || len(subject) != 64 {
+		writeAPIError(w, invalidParam("subject", "subject must be a 64-char hex pubkey or npub"))
+		return
+	}
+	if len(req.Annotations) == 0 && len(req.Remove) == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "annotations or remove array required"})
+		return
+	}
+	if len(req.Annotations)+len(req.Remove) > annotationMaxPerRequest {
+		writeAPIError(w, invalidParam("annotations", "max %d annotations per request", annotationMaxPerRequest))
+		return
+	}
+
+	for _, a := range append(append([]annotationInput{}, req.Annotations...), req.Remove...) {
+		if err := validateAnnotationInput(a); err != nil {
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: err.Error()})
+			return
+		}
+	}
+
+	// A provider may write to a namespace if the operator granted it a weight there,
+	// or if its own WoT score is high enough that its claims carry meaning.
+	raw, _ := graph.GetScore(provider)
+	providerScore := normalizeScore(raw, graph.Stats().Nodes)
+	for _, a := range req.Annotations {
+		if weight, ok := annotations.ExplicitWeight(a.Namespace, provider); ok {
+			if weight <= 0 {
+				writeAPIError(w, &APIError{Status: http.StatusForbidden, Message: fmt.Sprintf("provider not permitted in namespace %s", a.Namespace)})
+				return
+			}
+			continue
+		}
+		if providerScore < annotationMinProviderScore {
+			writeAPIError(w, &APIError{Status: http.StatusForbidden, Message: fmt.Sprintf("provider WoT score %d below minimum %d for namespace %s",
+				providerScore, annotationMinProviderScore, a.Namespace)})
+			return
+		}
+	}
+
+	now := time.Now().Unix()
+	for _, a := range req.Annotations {
+		annotations.Add(subject, &Annotation{
+			Namespace: a.Namespace,
+			Label:     a.Label,
+			Value:     a.Value,
+			Provider:  provider,
+			CreatedAt: now,
+		})
+	}
+	removed := 0
+	for _, a := range req.Remove {
+		if annotations.Remove(subject, a.Namespace, a.Label, provider) {
+			removed++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subject":     subject,
+		"provider":    provider,
+		"added":       len(req.Annotations),
+		"removed":     removed,
+		"annotations": annotationSummaries(annotations, subject),
+	})
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestAnnotationStoreAddReplaceRemove(t *testing.T) {
+	store := NewAnnotationStore()
+	subject := padHex(8001)
+	provider := padHex(8002)
+
+	store.Add(subject, &Annotation{Namespace: "bitcoin-core", Label: "contributor", Value: "v1", Provider: provider, CreatedAt: 100})
+	store.Add(subject, &Annotation{Namespace: "bitcoin-core", Label: "contributor", Value: "v2", Provider: provider, CreatedAt: 200})
+	store.Add(subject, &Annotation{Namespace: "bitcoin-core", Label: "contributor", Value: "old", Provider: provider, CreatedAt: 50})
+
+	got := store.Get(subject)
+	if len(got) != 1 {
+		t.Fatalf("expected 1 annotation after replace, got %d", len(got))
+	}
+	if got[0].Value != "v2" {
+		t.Errorf("expected newest value v2, got %s", got[0].Value)
+	}
+
+	if store.Remove(subject, "bitcoin-core", "contributor", padHex(8003)) {
+		t.Error("other provider should not be able to remove annotation")
+	}
+	if !store.Remove(subject, "bitcoin-core", "contributor", provider) {
+		t.Error("expected provider to remove own annotation")
+	}
+	if store.SubjectCount() != 0 {
+		t.Errorf("expected empty store, got %d subjects", store.SubjectCount())
+	}
+}
+
+func TestAnnotationSummariesWeighting(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+
+	subject := padHex(8010)
+	trusted := padHex(8011)
+	configured := padHex(8012)
+	unknown := padHex(8013)
+	for i := 0; i < 20; i++ {
+		graph.AddFollow(padHex(8100+i), trusted)
+	}
+	graph.AddFollow(trusted, padHex(8100))
+	graph.ComputePageRank(20, 0.85)
+
+	store := NewAnnotationStore()
+	store.SetWeight("bitcoin-core", configured, 0.4)
+	for _, p := range []string{trusted, configured, unknown} {
+		store.Add(subject, &Annotation{Namespace: "bitcoin-core", Label: "contributor", Provider: p, CreatedAt: 1})
+	}
+
+	sums := annotationSummaries(store, subject)
+	if len(sums) != 1 {
+		t.Fatalf("expected 1 summary, got %d", len(sums))
+	}
+	s := sums[0]
+	if len(s.Providers) != 3 {
+		t.Fatalf("expected 3 providers, got %d", len(s.Providers))
+	}
+	weights := map[string]float64{}
+	for _, p := range s.Providers {
+		weights[p.Pubkey] = p.Weight
+	}
+	if weights[configured] != 0.4 {
+		t.Errorf("expected configured weight 0.4, got %f", weights[configured])
+	}
+	if weights[unknown] != 0 {
+		t.Errorf("expected unknown provider weight 0, got %f", weights[unknown])
+	}
+	if weights[trusted] <= 0 {
+		t.Errorf("expected trusted provider to carry WoT weight, got %f", weights[trusted])
+	}
+	if s.Confidence > 1 || s.Confidence < 0.4 {
+		t.Errorf("confidence out of range: %f", s.Confidence)
+	}
+}
+
+func TestAnnotationLabelTags(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+
+	store := NewAnnotationStore()
+	subject := padHex(8020)
+	strong := padHex(8021)
+	weak := padHex(8022)
+	store.SetWeight("bitcoin-core", strong, 0.9)
+	store.SetWeight("nostr-dev", weak, 0.1)
+	store.Add(subject, &Annotation{Namespace: "bitcoin-core", Label: "contributor", Provider: strong, CreatedAt: 1})
+	store.Add(subject, &Annotation{Namespace: "nostr-dev", Label: "builder", Provider: weak, CreatedAt: 1})
+
+	tags := annotationLabelTags(store, subject)
+	if len(tags) != 2 {
+		t.Fatalf("expected L + l tags for the strong annotation only, got %v", tags)
+	}
+	if tags[0][0] != "L" || tags[0][1] != "bitcoin-core" {
+		t.Errorf("expected L tag first, got %v", tags[0])
+	}
+	if tags[1][0] != "l" || tags[1][1] != "contributor" || tags[1][2] != "bitcoin-core" {
+		t.Errorf("unexpected l tag %v", tags[1])
+	}
+}
+
+func TestAnnotationStoreFromEnv(t *testing.T) {
+	provider := padHex(8030)
+	t.Setenv("ANNOTATION_PROVIDER_WEIGHTS", "bitcoin-core:"+provider+"=0.7, bad-entry, x:y=notanumber")
+	store := NewAnnotationStoreFromEnv()
+	w, ok := store.ExplicitWeight("bitcoin-core", provider)
+	if !ok || w != 0.7 {
+		t.Errorf("expected weight 0.7, got %f (ok=%v)", w, ok)
+	}
+	if _, ok := store.ExplicitWeight("x", "y"); ok {
+		t.Error("expected invalid weight to be skipped")
+	}
+}
+
+func TestAnnotationsEndpointGet(t *testing.T) {
+	oldStore := annotations
+	defer func() { annotations = oldStore }()
+	annotations = NewAnnotationStore()
+
+	subject := padHex(8040)
+	provider := padHex(8041)
+	annotations.SetWeight("bitcoin-core", provider, 1)
+	annotations.Add(subject, &Annotation{Namespace: "bitcoin-core", Label: "contributor", Provider: provider, CreatedAt: 1})
+
+	req := httptest.NewRequest("GET", "/annotations?pubkey="+subject, nil)
+	rr := httptest.NewRecorder()
+	handleAnnotations(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Count       int                 `json:"count"`
+		Annotations []AnnotationSummary `json:"annotations"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Count != 1 || resp.Annotations[0].Confidence != 1 {
+		t.Errorf("unexpected response: %s", rr.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/annotations", nil)
+	rr = httptest.NewRecorder()
+	handleAnnotations(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for missing pubkey, got %d", rr.Code)
+	}
+}
+
+func postAnnotations(t *testing.T, sk, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", "http://wot.example/annotations", strings.NewReader(body))
+	req.Header.Set("Authorization", nip98Header(t, sk, "POST", "https://wot.example/annotations", []byte(body), time.Now()))
+	rr := httptest.NewRecorder()
+	handleAnnotations(rr, req)
+	return rr
+}
+
+func TestAnnotationsEndpointPost(t *testing.T) {
+	oldStore, oldGraph := annotations, graph
+	defer func() { annotations, graph = oldStore, oldGraph }()
+	annotations = NewAnnotationStore()
+	graph = NewGraph()
+
+	sk := nostr.GeneratePrivateKey()
+	provider, _ := nostr.GetPublicKey(sk)
+	subject := padHex(8050)
+	body := `{"subject":"` + subject + `","annotations":[{"namespace":"bitcoin-core","label":"contributor"}]}`
+
+	// Unknown provider with no configured weight is rejected.
+	if rr := postAnnotations(t, sk, body); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for untrusted provider, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	annotations.SetWeight("bitcoin-core", provider, 0.8)
+	rr := postAnnotations(t, sk, body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := annotations.Get(subject); len(got) != 1 || got[0].Provider != provider {
+		t.Fatalf("expected annotation from provider, got %+v", got)
+	}
+
+	// Remove it again.
+	rr = postAnnotations(t, sk, `{"subject":"`+subject+`","remove":[{"namespace":"bitcoin-core","label":"contributor"}]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 on remove, got %d", rr.Code)
+	}
+	if got := annotations.Get(subject); len(got) != 0 {
+		t.Errorf("expected annotation removed, got %d", len(got))
+	}
+}
+
+func TestAnnotationsEndpointPostValidation(t *testing.T) {
+	oldStore := annotations
+	defer func() { annotations = oldStore }()
+	annotations = NewAnnotationStore()
+
+	sk := nostr.GeneratePrivateKey()
+	subject := padHex(8060)
+
+	// Unsigned request
+	req := httptest.NewRequest("POST", "/annotations", strings.NewReader(`{}`))
+	rr := httptest.NewRecorder()
+	handleAnnotations(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without NIP-98 auth, got %d", rr.Code)
+	}
+
+	bad := []string{
+		`{"subject":"nope","annotations":[{"namespace":"a","label":"b"}]}`,
+		`{"subject":"` + subject + `"}`,
+		`{"subject":"` + subject + `","annotations":[{"namespace":"Bad NS","label":"b"}]}`,
+		`{"subject":"` + subject + `","annotations":[{"namespace":"a","label":""}]}`,
+	}
+	for _, body := range bad {
+		if rr := postAnnotations(t, sk, body); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body, rr.Code)
+		}
+	}
+}
@@ -6,11 +6,12 @@ import (
 	"math"
 	"net/http"
 	"sort"
+	"time"
 )
 
 // AnomalyFlag represents a single detected anomaly in a pubkey's trust graph.
 type AnomalyFlag struct {
-	Type        string  `json:"type"`        // e.g. "follow_farming", "bot_followers", "trust_concentration", "ghost_followers"
+	Type        string  `json:"type"`        // e.g. "follow_farming", "bot_followers", "trust_concentration", "ghost_followers", "burst_acquisition", "zap_wash_trading", "mass_following", "follow_burst"
 	Severity    string  `json:"severity"`    // "low", "medium", "high"
 	Description string  `json:"description"` // human-readable explanation
 	Value       float64 `json:"value"`       // the metric value that triggered this flag
@@ -19,37 +20,41 @@ type AnomalyFlag struct {
 
 // AnomaliesResponse is the response for the /anomalies endpoint.
 type AnomaliesResponse struct {
-	Pubkey           string        `json:"pubkey"`
-	Score            int           `json:"score"`
-	Rank             int           `json:"rank"`
-	Followers        int           `json:"followers"`
-	Follows          int           `json:"follows"`
-	FollowBackRatio  float64       `json:"follow_back_ratio"`  // fraction of followers followed back
-	GhostFollowers   int           `json:"ghost_followers"`    // followers with 0 WoT score
-	GhostRatio       float64       `json:"ghost_ratio"`        // ghost_followers / total followers
-	TopFollowerShare float64       `json:"top_follower_share"` // fraction of PageRank from top follower
-	ScorePercentile  float64       `json:"score_percentile"`   // 0.0-1.0
-	Anomalies        []AnomalyFlag `json:"anomalies"`
-	AnomalyCount     int           `json:"anomaly_count"`
-	RiskLevel        string        `json:"risk_level"` // "clean", "low", "medium", "high"
-	GraphSize        int           `json:"graph_size"`
+	Pubkey           string           `json:"pubkey"`
+	Score            int              `json:"score"`
+	Rank             int              `json:"rank"`
+	Followers        int              `json:"followers"`
+	Follows          int              `json:"follows"`
+	FollowBackRatio  float64          `json:"follow_back_ratio"`  // fraction of followers followed back
+	GhostFollowers   int              `json:"ghost_followers"`    // followers with 0 WoT score
+	GhostRatio       float64          `json:"ghost_ratio"`        // ghost_followers / total followers
+	TopFollowerShare float64          `json:"top_follower_share"` // fraction of PageRank from top follower
+	ScorePercentile  float64          `json:"score_percentile"`   // 0.0-1.0
+	Anomalies        []AnomalyFlag    `json:"anomalies"`
+	AnomalyCount     int              `json:"anomaly_count"`
+	RiskLevel        string           `json:"risk_level"`            // "clean", "low", "medium", "high"
+	ZapWash          []ZapWashPattern `json:"zap_wash,omitempty"`    // detected zap wash-trading patterns
+	MassFollow       *MassFollower    `json:"mass_follow,omitempty"` // how this pubkey's follows are dampened
+	GraphSize        int              `json:"graph_size"`
 }
 
 // handleAnomalies detects trust anomalies for a pubkey.
 // GET /anomalies?pubkey=<hex|npub>
 func handleAnomalies(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
 		return
 	}
 
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
-		return
-	}
+	resp := computeAnomalies(pubkey)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
 
+// computeAnomalies runs all anomaly detectors for a pubkey against the current graph.
+func computeAnomalies(pubkey string) AnomaliesResponse {
 	stats := graph.Stats()
 	rawScore, _ := graph.GetScore(pubkey)
 	score := normalizeScore(rawScore, stats.Nodes)
@@ -200,6 +205,64 @@ func handleAnomalies(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	// Mass following and follow bursts, as flagged by the last rebuild, whose
+	// follows are dampened in scoring
+	massFollow, isMassFollower := massFollows.Get(pubkey)
+	if isMassFollower {
+		anomalies = append(anomalies, massFollowAnomalies(massFollow, massFollowPolicy)...)
+	}
+
+	// Burst acquisition: >= 50% of dated followers arrived in spikes far above the
+	// pubkey's normal pace — bought followers tend to arrive in batches
+	if len(followers) >= 20 {
+		growth := computeGrowthSources(pubkey, time.Now())
+		if growth.FollowersWithDates >= 20 && growth.Pacing.BurstRatio >= 0.5 {
+			severity := "medium"
+			zeroShare := 0.0
+			for _, b := range growth.Pacing.Bursts {
+				zeroShare += b.ZeroScoreShare * float64(b.Follows)
+			}
+			if growth.Pacing.BurstFollows > 0 {
+				zeroShare /= float64(growth.Pacing.BurstFollows)
+			}
+			if zeroShare >= 0.7 {
+				severity = "high"
+			}
+			anomalies = append(anomalies, AnomalyFlag{
+				Type:        "burst_acquisition",
+				Severity:    severity,
+				Description: fmt.Sprintf("%.0f%% of dated followers arrived in %d burst(s), %.0f%% of them with zero trust score", growth.Pacing.BurstRatio*100, len(growth.Pacing.Bursts), zeroShare*100),
+				Value:       growth.Pacing.BurstRatio,
+				Threshold:   0.5,
+			})
+		}
+	}
+
+	// Zap wash trading: self-zaps, circular zap loops, or alt-key funding
+	zapWash, _ := detectZapWash(zapStore, pubkey)
+	if len(zapWash) > 0 {
+		var washed int64
+		types := make(map[string]bool)
+		for _, p := range zapWash {
+			washed += p.Sats
+			types[p.Type] = true
+		}
+		severity := "low"
+		if types["self_zap"] || types["circular"] {
+			severity = "medium"
+		}
+		if len(zapWash) >= 3 {
+			severity = "high"
+		}
+		anomalies = append(anomalies, AnomalyFlag{
+			Type:        "zap_wash_trading",
+			Severity:    severity,
+			Description: fmt.Sprintf("%d zap wash pattern(s) covering %d sats — excluded from zap-weighted scoring", len(zapWash), washed),
+			Value:       float64(len(zapWash)),
+			Threshold:   1,
+		})
+	}
+
 	// Determine risk level from anomaly severities
 	riskLevel := "clean"
 	if len(anomalies) > 0 {
@@ -224,11 +287,13 @@ func handleAnomalies(w http.ResponseWriter, r *http.Request) {
 		Anomalies:        anomalies,
 		AnomalyCount:     len(anomalies),
 		RiskLevel:        riskLevel,
+		ZapWash:          zapWash,
 		GraphSize:        stats.Nodes,
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	if isMassFollower {
+		resp.MassFollow = &massFollow
+	}
+	return resp
 }
 
 func severityRank(s string) int {
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// apiVersionPrefix serves every endpoint under a versioned path. The
+	// unprefixed paths stay as aliases of the current version.
+	apiVersionPrefix = "/v1"
+	// schemaVersion is the version of the response shapes, bumped when a field
+	// is removed, renamed or changes type. New optional fields don't bump it.
+	schemaVersion = 1
+)
+
+// originalPathKey carries the path a request arrived on, before the version
+// prefix was stripped.
+type originalPathKey struct{}
+
+// requestPath is the path the client actually requested, /v1 prefix included.
+// Checks bound to the URL the client signed, like NIP-98, compare against it.
+func requestPath(r *http.Request) string {
+	if p, ok := r.Context().Value(originalPathKey{}).(string); ok {
+		return p
+	}
+	return r.URL.Path
+}
+
+// schemaVersionWriter splices "schema_version" in as the first field of a
+// successful JSON object response, without holding back the rest of the body,
+// so streamed responses still stream. Arrays, errors and other content types
+// pass through untouched.
+type schemaVersionWriter struct {
+	http.ResponseWriter
+	status  int
+	splice  bool   // still looking for the body's opening brace
+	pending []byte // body held back while deciding
+}
+
+func (sw *schemaVersionWriter) WriteHeader(code int) {
+	if code >= 200 && sw.status == 0 {
+		sw.status = code
+		h := sw.Header()
+		sw.splice = code < 300 && strings.HasPrefix(h.Get("Content-Type"), "application/json") && h.Get("Content-Encoding") == ""
+		if sw.splice {
+			h.Del("Content-Length")
+		}
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *schemaVersionWriter) Write(p []byte) (int, error) {
+	if sw.status == 0 {
+		sw.WriteHeader(http.StatusOK)
+	}
+	if !sw.splice {
+		return sw.ResponseWriter.Write(p)
+	}
+	sw.pending = append(sw.pending, p...)
+	body := bytes.TrimLeft(sw.pending, " \t\r\n")
+	if len(body) == 0 {
+		return len(p), nil
+	}
+	if body[0] != '{' {
+		return len(p), sw.release(nil)
+	}
+	rest := bytes.TrimLeft(body[1:], " \t\r\n")
+	if len(rest) == 0 {
+		return len(p), nil // {"schema_version":1} or {"schema_version":1, depends on what follows
+	}
+	field := `{"schema_version":` + strconv.Itoa(schemaVersion)
+	if rest[0] != '}' {
+		field += ","
+	}
+	return len(p), sw.release(append([]byte(field), rest...))
+}
+
+// release ends splicing, writing body in place of what was held back, or the
+// held-back bytes themselves when body is nil.
+func (sw *schemaVersionWriter) release(body []byte) error {
+	sw.splice = false
+	if body == nil {
+		body = sw.pending
+	}
+	sw.pending = nil
+	_, err := sw.ResponseWriter.Write(body)
+	return err
+}
+
+func (sw *schemaVersionWriter) Flush() {
+	if sw.splice {
+		return // nothing decided to send yet
+	}
+	http.NewResponseController(sw.ResponseWriter).Flush()
+}
+
+func (sw *schemaVersionWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// APIVersionMiddleware serves /v1/... as the unprefixed path, so clients can pin
+// the version they were generated against, and stamps responses with the
+// schema version: an X-Schema-Version header on all of them and a
+// schema_version field on JSON objects. It runs outermost, so rate limits,
+// pricing and every handler see the unprefixed path.
+func APIVersionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rest, ok := strings.CutPrefix(r.URL.Path, apiVersionPrefix); ok && (rest == "" || rest[0] == '/') {
+			if rest == "" {
+				rest = "/"
+			}
+			r = r.WithContext(context.WithValue(r.Context(), originalPathKey{}, r.URL.Path))
+			u := *r.URL
+			r.URL = &u
+			r.URL.Path = rest
+			r.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, apiVersionPrefix)
+		}
+		w.Header().Set("X-Schema-Version", strconv.Itoa(schemaVersion))
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sw := &schemaVersionWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.splice && len(sw.pending) > 0 {
+			sw.release(nil)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveVersioned(path string, h http.HandlerFunc) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	APIVersionMiddleware(h).ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestAPIVersionPaths(t *testing.T) {
+	var seen string
+	h := func(w http.ResponseWriter, r *http.Request) { seen = r.URL.Path }
+	for path, want := range map[string]string{
+		"/v1/score":  "/score",
+		"/v1":        "/",
+		"/v1/":       "/",
+		"/score":     "/score",
+		"/v1beta/x":  "/v1beta/x",
+		"/v1/v1/top": "/v1/top",
+	} {
+		w := serveVersioned(path, h)
+		if seen != want || w.Header().Get("X-Schema-Version") != "1" {
+			t.Errorf("%s: expected %s, got %s", path, want, seen)
+		}
+	}
+}
+
+func TestSchemaVersionField(t *testing.T) {
+	writeJSON := func(status int, ct string, chunks ...string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ct)
+			w.WriteHeader(status)
+			for _, c := range chunks {
+				w.Write([]byte(c))
+			}
+		}
+	}
+	for name, tc := range map[string]struct {
+		h    http.HandlerFunc
+		want string
+	}{
+		"object":     {writeJSON(200, "application/json", `{"score":5}`+"\n"), `{"schema_version":1,"score":5}` + "\n"},
+		"empty":      {writeJSON(200, "application/json", "{ }"), `{"schema_version":1}`},
+		"split":      {writeJSON(200, "application/json", "  ", "{", "\n", `"a":1}`), `{"schema_version":1,"a":1}`},
+		"array":      {writeJSON(200, "application/json", `[{"a":1}]`), `[{"a":1}]`},
+		"error":      {writeJSON(400, "application/json", `{"error":"x"}`), `{"error":"x"}`},
+		"not json":   {writeJSON(200, "text/csv", "{a}"), "{a}"},
+		"no body":    {writeJSON(200, "application/json"), ""},
+		"unfinished": {writeJSON(200, "application/json", "{"), "{"},
+		"charset ct": {writeJSON(200, "application/json; charset=utf-8", `{"a":1}`), `{"schema_version":1,"a":1}`},
+		"ndjson":     {writeJSON(200, "application/x-ndjson", `{"a":1}`), `{"a":1}`},
+	} {
+		if got := serveVersioned("/v1/x", tc.h).Body.String(); got != tc.want {
+			t.Errorf("%s: expected %q, got %q", name, tc.want, got)
+		}
+	}
+}
+
+func TestVersionedScoreResponse(t *testing.T) {
+	old := graph
+	t.Cleanup(func() { graph = old })
+	graph = NewGraph()
+	graph.AddFollow(padHex(51901), padHex(51902))
+	graph.ComputePageRank(20, 0.85)
+
+	w := serveVersioned("/v1/score?pubkey="+padHex(51902), func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/score" {
+			t.Errorf("expected /score, got %s", r.URL.Path)
+		}
+		handleScore(w, r)
+	})
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(w.Body.String(), `{"schema_version":1,"pubkey":"`) || resp["found"] != true || resp["staleness"] == nil {
+		t.Errorf("unexpected body %s", w.Body.String())
+	}
+	for _, field := range []string{"view", "crawl", "active_hours_start", "composite_score", "algorithm", "hub_score", "inherited_trust"} {
+		if _, ok := resp[field]; ok {
+			t.Errorf("expected %s left out when it doesn't apply", field)
+		}
+	}
+}
+
+func TestBatchScoreShape(t *testing.T) {
+	w := httptest.NewRecorder()
+	handleBatch(w, httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(`{"pubkeys":["npub1bad","`+padHex(51903)+`"]}`)))
+	var resp struct {
+		Results []map[string]interface{} `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Results) != 2 {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
+	// every result has the same fields; only error is extra
+	bad, good := resp.Results[0], resp.Results[1]
+	if bad["error"] == nil || bad["score"] != float64(0) || bad["found"] != false || good["error"] != nil || len(good) != 4 {
+		t.Errorf("unexpected results %v", resp.Results)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	// articleAuthorWeight and articleEngagementWeight blend an article's two 0-100
+	// components into its /articles/top score.
+	articleAuthorWeight     = 0.6
+	articleEngagementWeight = 0.4
+)
+
+// TopArticle is one long-form article in the /articles/top feed.
+type TopArticle struct {
+	Address        string   `json:"address"`
+	Author         string   `json:"author"`
+	Title          string   `json:"title,omitempty"`
+	Summary        string   `json:"summary,omitempty"`
+	Hashtags       []string `json:"hashtags"`
+	PublishedAt    int64    `json:"published_at"`
+	Score          float64  `json:"score"`
+	AuthorScore    int      `json:"author_score"`    // the author's normalized WoT score
+	EngagementRank float64  `json:"engagement_rank"` // 0-100, 25 points per tenfold engagement
+	Reactions      int      `json:"reactions"`
+	ZapCount       int      `json:"zap_count"`
+	ZapAmount      int64    `json:"zap_sats"`
+	UniqueEngagers int      `json:"unique_engagers"`
+}
+
+// TopArticles ranks the crawled long-form articles (kind 30023) by a blend of
+// their authors' WoT scores and their engagement, best first. topic, when set,
+// keeps only articles tagged with that hashtag; articles by authors scoring below
+// minAuthorScore, and by unscored authors, are left out.
+func (es *EventStore) TopArticles(g *Graph, topic string, minAuthorScore, limit int) []TopArticle {
+	topic = strings.ToLower(strings.TrimPrefix(topic, "#"))
+	nodes := g.Stats().Nodes
+
+	var articles []TopArticle
+	es.mu.Lock()
+	for _, m := range es.addressable {
+		if m.Kind != 30023 || (topic != "" && !slices.Contains(m.Hashtags, topic)) {
+			continue
+		}
+		raw, ok := g.GetScore(m.AuthorPubkey)
+		if !ok {
+			continue
+		}
+		a := TopArticle{
+			Address:        m.Address,
+			Author:         m.AuthorPubkey,
+			Title:          m.Title,
+			Summary:        m.Summary,
+			Hashtags:       append([]string{}, m.Hashtags...),
+			PublishedAt:    m.PublishedAt,
+			AuthorScore:    normalizeScore(raw, nodes),
+			Reactions:      m.Reactions,
+			ZapCount:       m.ZapCount,
+			ZapAmount:      m.ZapAmount,
+			UniqueEngagers: m.Engagers.Count(),
+		}
+		if a.AuthorScore == 0 || a.AuthorScore < minAuthorScore {
+			continue
+		}
+		a.EngagementRank = engagementComponent(EventEngagement{
+			Reactions: m.Reactions,
+			Reposts:   m.Reposts,
+			Comments:  m.Comments,
+			ZapAmount: m.ZapAmount,
+		})
+		a.Score = math.Round((float64(a.AuthorScore)*articleAuthorWeight+a.EngagementRank*articleEngagementWeight)*10) / 10
+		a.EngagementRank = math.Round(a.EngagementRank*10) / 10
+		articles = append(articles, a)
+	}
+	es.mu.Unlock()
+
+	sort.Slice(articles, func(i, j int) bool {
+		if articles[i].Score != articles[j].Score {
+			return articles[i].Score > articles[j].Score
+		}
+		if articles[i].PublishedAt != articles[j].PublishedAt {
+			return articles[i].PublishedAt > articles[j].PublishedAt
+		}
+		return articles[i].Address < articles[j].Address
+	})
+	if limit > 0 && len(articles) > limit {
+		articles = articles[:limit]
+	}
+	return articles
+}
+
+// handleTopArticles serves GET /articles/top?topic=&min_author_score=&limit=: a
+// trust-curated long-form feed of kind 30023 articles ranked by author WoT score
+// and engagement.
+func handleTopArticles(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	topic := q.String("topic", false)
+	minAuthor := q.Int("min_author_score", 0, 0, 100)
+	limit := q.Int("limit", 20, 1, 100)
+	if q.Failed(w) {
+		return
+	}
+
+	articles := events.TopArticles(graph.Snapshot(), topic, minAuthor, limit)
+	if articles == nil {
+		articles = []TopArticle{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"topic":    strings.ToLower(strings.TrimPrefix(topic, "#")),
+		"articles": articles,
+		"count":    len(articles),
+		"weights": map[string]float64{
+			"author":     articleAuthorWeight,
+			"engagement": articleEngagementWeight,
+		},
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// setupArticles installs a graph where star has a dozen followers and minor one,
+// plus an article store with star's, minor's and an unscored author's articles.
+func setupArticles(t *testing.T) (star, minor string) {
+	t.Helper()
+	oldGraph, oldEvents := graph, events
+	t.Cleanup(func() { graph, events = oldGraph, oldEvents })
+	graph, events = NewGraph(), NewEventStore()
+	star, minor = padHex(51001), padHex(51002)
+	for i := 0; i < 12; i++ {
+		graph.AddFollow(padHex(51100+i), star)
+	}
+	graph.AddFollow(star, minor)
+	graph.ComputePageRank(20, 0.85)
+
+	article := func(author, d string, at nostr.Timestamp, tags ...nostr.Tag) string {
+		return events.RecordAddressable(&nostr.Event{
+			Kind: 30023, PubKey: author, CreatedAt: at,
+			Tags: append(nostr.Tags{{"d", d}, {"title", d}}, tags...),
+		})
+	}
+	article(star, "quiet", 1000, nostr.Tag{"t", "Bitcoin"})
+	popular := article(minor, "popular", 1000, nostr.Tag{"t", "#bitcoin"}, nostr.Tag{"t", "nostr"})
+	article(padHex(51200), "unscored", 1000, nostr.Tag{"t", "bitcoin"})
+	m := events.GetAddressable(popular)
+	m.Reactions, m.ZapAmount = 500, 50000
+	return star, minor
+}
+
+func TestRecordAddressable(t *testing.T) {
+	es := NewEventStore()
+	pk := padHex(51300)
+	addr := es.RecordAddressable(&nostr.Event{Kind: 30023, PubKey: pk, CreatedAt: 2000, Tags: nostr.Tags{
+		{"d", "essay"}, {"title", "An Essay"}, {"summary", "short"}, {"published_at", "1500"},
+		{"t", "Nostr"}, {"t", "nostr"}, {"t", "#zaps"},
+	}})
+	if addr != "30023:"+pk+":essay" {
+		t.Fatalf("unexpected address %q", addr)
+	}
+	m := es.GetAddressable(addr)
+	if m.Title != "An Essay" || m.Summary != "short" || m.PublishedAt != 1500 || len(m.Hashtags) != 2 || m.Hashtags[1] != "zaps" {
+		t.Errorf("expected article fields recorded, got %+v", m)
+	}
+
+	// an older version seen later doesn't replace the newer one
+	es.RecordAddressable(&nostr.Event{Kind: 30023, PubKey: pk, CreatedAt: 1000, Tags: nostr.Tags{{"d", "essay"}, {"title", "Draft"}}})
+	if m.Title != "An Essay" {
+		t.Errorf("expected the newer version kept, got %q", m.Title)
+	}
+}
+
+func TestTopArticles(t *testing.T) {
+	star, minor := setupArticles(t)
+
+	all := events.TopArticles(graph, "", 0, 10)
+	if len(all) != 2 {
+		t.Fatalf("expected the unscored author's article left out, got %+v", all)
+	}
+	if all[0].Author != minor || all[1].Author != star {
+		t.Errorf("expected engagement to lift minor's article above star's, got %+v", all)
+	}
+	if all[0].EngagementRank <= all[1].EngagementRank || all[1].AuthorScore <= all[0].AuthorScore {
+		t.Errorf("unexpected components %+v", all)
+	}
+
+	nostrOnly := events.TopArticles(graph, "#NOSTR", 0, 10)
+	if len(nostrOnly) != 1 || nostrOnly[0].Author != minor {
+		t.Errorf("expected only the nostr-tagged article, got %+v", nostrOnly)
+	}
+
+	trusted := events.TopArticles(graph, "bitcoin", all[1].AuthorScore, 10)
+	if len(trusted) != 1 || trusted[0].Author != star {
+		t.Errorf("expected min_author_score to drop minor, got %+v", trusted)
+	}
+}
+
+func TestHandleTopArticles(t *testing.T) {
+	setupArticles(t)
+
+	w := httptest.NewRecorder()
+	handleTopArticles(w, httptest.NewRequest(http.MethodGet, "/articles/top?topic=bitcoin&limit=1", nil))
+	var resp struct {
+		Topic    string       `json:"topic"`
+		Articles []TopArticle `json:"articles"`
+		Count    int          `json:"count"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if resp.Topic != "bitcoin" || resp.Count != 1 || resp.Articles[0].Title != "popular" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	w = httptest.NewRecorder()
+	handleTopArticles(w, httptest.NewRequest(http.MethodGet, "/articles/top?limit=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a zero limit, got %d", w.Code)
+	}
+}
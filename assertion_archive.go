@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// defaultArchiveRetention is how long superseded assertion versions are kept for audits.
+const defaultArchiveRetention = 30 * 24 * time.Hour
+
+// archivedVersion is one stored version of a provider's assertion about a subject.
+type archivedVersion struct {
+	ID           string `json:"id"`
+	CreatedAt    int64  `json:"created_at"`
+	SupersededAt int64  `json:"superseded_at,omitempty"` // when a newer version arrived (0 = current)
+}
+
+// AssertionArchive keeps the full signed kind 30382 events consumed from external
+// providers. Events are content-addressed by their NIP-01 id (sha256 of the serialized
+// event), so identical events are stored once and any stored event can be re-verified.
+// When dir is set, events are written to dir/<id[:2]>/<id>.json and reloaded on startup.
+type AssertionArchive struct {
+	mu        sync.RWMutex
+	dir       string
+	retention time.Duration
+	events    map[string]*nostr.Event // event id -> event
+	// subject -> provider -> versions, newest first
+	index map[string]map[string][]*archivedVersion
+}
+
+// NewAssertionArchive creates an archive. An empty dir keeps events in memory only.
+func NewAssertionArchive(dir string, retention time.Duration) *AssertionArchive {
+	if retention <= 0 {
+		retention = defaultArchiveRetention
+	}
+	return &AssertionArchive{
+		dir:       dir,
+		retention: retention,
+		events:    make(map[string]*nostr.Event),
+		index:     make(map[string]map[string][]*archivedVersion),
+	}
+}
+
+// NewAssertionArchiveFromEnv reads ASSERTION_ARCHIVE_DIR and ASSERTION_ARCHIVE_RETENTION_DAYS.
+func NewAssertionArchiveFromEnv() *AssertionArchive {
+	retention := defaultArchiveRetention
+	if v := os.Getenv("ASSERTION_ARCHIVE_RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days > 0 {
+			retention = time.Duration(days) * 24 * time.Hour
+		} else {
+			log.Printf("Ignoring ASSERTION_ARCHIVE_RETENTION_DAYS=%q: want a positive integer", v)
+		}
+	}
+	return NewAssertionArchive(os.Getenv("ASSERTION_ARCHIVE_DIR"), retention)
+}
+
+// Put archives a kind 30382 event. It returns false if the event is malformed,
+// its id does not match its content, or it is already stored.
+func (a *AssertionArchive) Put(ev *nostr.Event) bool {
+	parsed := parseAssertion(ev)
+	if parsed == nil || !ev.CheckID() {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exists := a.events[ev.ID]; exists {
+		return false
+	}
+	if a.dir != "" {
+		if err := a.writeEvent(ev); err != nil {
+			log.Printf("assertion archive: write %s: %v", ev.ID, err)
+			return false
+		}
+	}
+	a.events[ev.ID] = ev
+	a.indexLocked(parsed.SubjectPubkey, ev.PubKey, ev.ID, int64(ev.CreatedAt), time.Now().Unix())
+	return true
+}
+
+// indexLocked records a version and marks older versions as superseded.
+func (a *AssertionArchive) indexLocked(subject, provider, id string, createdAt, now int64) {
+	if a.index[subject] == nil {
+		a.index[subject] = make(map[string][]*archivedVersion)
+	}
+	versions := append(a.index[subject][provider], &archivedVersion{ID: id, CreatedAt: createdAt})
+	sort.Slice(versions, func(i, j int) bool { return versions[i].CreatedAt > versions[j].CreatedAt })
+	versions[0].SupersededAt = 0
+	for _, v := range versions[1:] {
+		if v.SupersededAt == 0 {
+			v.SupersededAt = now
+		}
+	}
+	a.index[subject][provider] = versions
+}
+
+// Get returns archived events for a subject, optionally limited to one provider.
+// Without history only the current version per provider is returned; with history
+// superseded versions that have not yet been collected are included, newest first.
+func (a *AssertionArchive) Get(subject, provider string, history bool) []*nostr.Event {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var out []*nostr.Event
+	for p, versions := range a.index[subject] {
+		if provider != "" && p != provider {
+			continue
+		}
+		for i, v := range versions {
+			if i > 0 && !history {
+				break
+			}
+			if ev := a.events[v.ID]; ev != nil {
+				out = append(out, ev)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt != out[j].CreatedAt {
+			return out[i].CreatedAt > out[j].CreatedAt
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// EventCount returns the number of archived events.
+func (a *AssertionArchive) EventCount() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return len(a.events)
+}
+
+// GC removes superseded versions that were replaced longer than the retention period ago.
+// Current versions are never removed. Returns the number of events deleted.
+func (a *AssertionArchive) GC(now time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cutoff := now.Add(-a.retention).Unix()
+	removed := 0
+	for subject, byProvider := range a.index {
+		for provider, versions := range byProvider {
+			kept := versions[:1]
+			for _, v := range versions[1:] {
+				if v.SupersededAt != 0 && v.SupersededAt <= cutoff {
+					delete(a.events, v.ID)
+					if a.dir != "" {
+						os.Remove(a.eventPath(v.ID))
+					}
+					removed++
+					continue
+				}
+				kept = append(kept, v)
+			}
+			byProvider[provider] = kept
+		}
+		a.index[subject] = byProvider
+	}
+	return removed
+}
+
+// Load reads archived events from dir, rebuilds the index, and replays each event
+// into store so composite scores survive restarts. Returns the number loaded.
+func (a *AssertionArchive) Load(store *AssertionStore) (int, error) {
+	if a.dir == "" {
+		return 0, nil
+	}
+	var loaded []*nostr.Event
+	err := filepath.WalkDir(a.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		var ev nostr.Event
+		if err := json.Unmarshal(data, &ev); err != nil || !ev.CheckID() {
+			log.Printf("assertion archive: skipping corrupt file %s", path)
+			return nil
+		}
+		loaded = append(loaded, &ev)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	a.mu.Lock()
+	// Superseded timestamps aren't persisted; treat reloaded history as superseded now.
+	now := time.Now().Unix()
+	for _, ev := range loaded {
+		parsed := parseAssertion(ev)
+		if parsed == nil {
+			continue
+		}
+		a.events[ev.ID] = ev
+		a.indexLocked(parsed.SubjectPubkey, ev.PubKey, ev.ID, int64(ev.CreatedAt), now)
+	}
+	a.mu.Unlock()
+
+	for _, ev := range loaded {
+		if parsed := parseAssertion(ev); parsed != nil && store != nil {
+			store.Add(parsed)
+		}
+	}
+	return len(loaded), nil
+}
+
+func (a *AssertionArchive) eventPath(id string) string {
+	return filepath.Join(a.dir, id[:2], id+".json")
+}
+
+// writeEvent writes an event atomically (temp file + rename).
+func (a *AssertionArchive) writeEvent(ev *nostr.Event) error {
+	path := a.eventPath(ev.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// AssertionsResponse is the /assertions body. provider echoes the provider
+// filter when one was given.
+type AssertionsResponse struct {
+	Subject  string         `json:"subject"`
+	Provider string         `json:"provider,omitempty"`
+	History  bool           `json:"history"`
+	Count    int            `json:"count"`
+	Events   []*nostr.Event `json:"events"`
+}
+
+// handleAssertions serves GET /assertions?subject=<hex|npub>&provider=<hex|npub>&history=true,
+// returning the raw signed external assertion events behind composite scores.
+func handleAssertions(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	subject := q.Pubkey("subject", true)
+	provider := q.Pubkey("provider", false)
+	history := q.Bool("history", false)
+	if q.Failed(w) {
+		return
+	}
+
+	evs := assertionArchive.Get(subject, provider, history)
+	if evs == nil {
+		evs = []*nostr.Event{}
+	}
+
+	resp := AssertionsResponse{
+		Subject:  subject,
+		Provider: provider,
+		History:  history,
+		Count:    len(evs),
+		Events:   evs,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func signedAssertion(t *testing.T, sk, subject string, rank int, createdAt int64) *nostr.Event {
+	t.Helper()
+	ev := &nostr.Event{
+		Kind:      30382,
+		CreatedAt: nostr.Timestamp(createdAt),
+		Tags: nostr.Tags{
+			{"d", subject},
+			{"rank", strconv.Itoa(rank)},
+		},
+	}
+	if err := ev.Sign(sk); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return ev
+}
+
+func TestAssertionArchivePutAndGet(t *testing.T) {
+	archive := NewAssertionArchive("", 0)
+	sk := nostr.GeneratePrivateKey()
+	provider, _ := nostr.GetPublicKey(sk)
+	subject := padHex(10001)
+
+	old := signedAssertion(t, sk, subject, 40, 1000)
+	newer := signedAssertion(t, sk, subject, 55, 2000)
+
+	if !archive.Put(old) {
+		t.Fatal("expected first put to succeed")
+	}
+	if archive.Put(old) {
+		t.Error("expected duplicate event to be ignored")
+	}
+	archive.Put(newer)
+
+	current := archive.Get(subject, "", false)
+	if len(current) != 1 || current[0].ID != newer.ID {
+		t.Fatalf("expected only current version, got %d events", len(current))
+	}
+	all := archive.Get(subject, provider, true)
+	if len(all) != 2 || all[0].ID != newer.ID || all[1].ID != old.ID {
+		t.Fatalf("expected history newest first, got %d events", len(all))
+	}
+	if got := archive.Get(subject, padHex(10002), true); len(got) != 0 {
+		t.Errorf("expected no events for other provider, got %d", len(got))
+	}
+}
+
+func TestAssertionArchiveRejectsTampered(t *testing.T) {
+	archive := NewAssertionArchive("", 0)
+	sk := nostr.GeneratePrivateKey()
+	ev := signedAssertion(t, sk, padHex(10010), 40, 1000)
+	ev.Tags = nostr.Tags{{"d", padHex(10010)}, {"rank", "99"}}
+	if archive.Put(ev) {
+		t.Fatal("expected tampered event (id mismatch) to be rejected")
+	}
+
+	notAssertion := &nostr.Event{Kind: 1, CreatedAt: 1}
+	notAssertion.Sign(sk)
+	if archive.Put(notAssertion) {
+		t.Fatal("expected non-30382 event to be rejected")
+	}
+}
+
+func TestNewAssertionArchiveFromEnv(t *testing.T) {
+	t.Setenv("ASSERTION_ARCHIVE_DIR", "")
+	t.Setenv("ASSERTION_ARCHIVE_RETENTION_DAYS", "7")
+	if a := NewAssertionArchiveFromEnv(); a.retention != 7*24*time.Hour {
+		t.Errorf("expected 7 day retention, got %v", a.retention)
+	}
+	t.Setenv("ASSERTION_ARCHIVE_RETENTION_DAYS", "7days")
+	if a := NewAssertionArchiveFromEnv(); a.retention != defaultArchiveRetention {
+		t.Errorf("expected malformed retention ignored, got %v", a.retention)
+	}
+}
+
+func TestAssertionArchiveGC(t *testing.T) {
+	archive := NewAssertionArchive("", 24*time.Hour)
+	sk := nostr.GeneratePrivateKey()
+	subject := padHex(10020)
+
+	archive.Put(signedAssertion(t, sk, subject, 10, 1000))
+	archive.Put(signedAssertion(t, sk, subject, 20, 2000))
+	latest := signedAssertion(t, sk, subject, 30, 3000)
+	archive.Put(latest)
+
+	if n := archive.GC(time.Now()); n != 0 {
+		t.Fatalf("expected nothing collected inside retention, got %d", n)
+	}
+	if n := archive.GC(time.Now().Add(48 * time.Hour)); n != 2 {
+		t.Fatalf("expected 2 superseded events collected, got %d", n)
+	}
+	if archive.EventCount() != 1 {
+		t.Fatalf("expected only the current event to remain, got %d", archive.EventCount())
+	}
+	if got := archive.Get(subject, "", true); len(got) != 1 || got[0].ID != latest.ID {
+		t.Error("current version must never be collected")
+	}
+}
+
+func TestAssertionArchivePersistsAndReloads(t *testing.T) {
+	dir := t.TempDir()
+	sk := nostr.GeneratePrivateKey()
+	provider, _ := nostr.GetPublicKey(sk)
+	subject := padHex(10030)
+
+	archive := NewAssertionArchive(dir, 0)
+	ev := signedAssertion(t, sk, subject, 77, time.Now().Unix())
+	archive.Put(ev)
+
+	path := filepath.Join(dir, ev.ID[:2], ev.ID+".json")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected event file at %s: %v", path, err)
+	}
+	// Corrupt files are skipped, not fatal
+	os.WriteFile(filepath.Join(dir, "zz.json"), []byte("{bad"), 0o644)
+
+	reloaded := NewAssertionArchive(dir, 0)
+	store := NewAssertionStore()
+	n, err := reloaded.Load(store)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 event loaded, got %d", n)
+	}
+	got := store.GetForSubject(subject)
+	if len(got) != 1 || got[0].Rank != 77 || got[0].ProviderPubkey != provider {
+		t.Fatalf("expected assertion replayed into store, got %+v", got)
+	}
+
+	// Missing directory is not an error
+	if _, err := NewAssertionArchive(filepath.Join(dir, "missing"), 0).Load(nil); err != nil {
+		t.Errorf("expected no error for missing dir, got %v", err)
+	}
+}
+
+func TestAssertionsEndpoint(t *testing.T) {
+	oldArchive := assertionArchive
+	defer func() { assertionArchive = oldArchive }()
+	assertionArchive = NewAssertionArchive("", 0)
+
+	sk := nostr.GeneratePrivateKey()
+	provider, _ := nostr.GetPublicKey(sk)
+	subject := padHex(10040)
+	assertionArchive.Put(signedAssertion(t, sk, subject, 10, 1000))
+	assertionArchive.Put(signedAssertion(t, sk, subject, 20, 2000))
+
+	req := httptest.NewRequest("GET", "/assertions?subject="+subject+"&provider="+provider+"&history=true", nil)
+	rr := httptest.NewRecorder()
+	handleAssertions(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var resp struct {
+		Count  int           `json:"count"`
+		Events []nostr.Event `json:"events"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Count != 2 {
+		t.Fatalf("expected 2 events, got %d", resp.Count)
+	}
+	if ok, _ := resp.Events[0].CheckSignature(); !ok {
+		t.Error("returned events should carry valid signatures")
+	}
+
+	req = httptest.NewRequest("GET", "/assertions", nil)
+	rr = httptest.NewRecorder()
+	handleAssertions(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without subject, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Value types used in assertion tag specs.
+const (
+	tagTypePubkey     = "pubkey"     // 64-char lowercase hex
+	tagTypeEventID    = "event_id"   // 64-char lowercase hex
+	tagTypeAddress    = "address"    // <kind>:<pubkey>:<d-tag>
+	tagTypeIdentifier = "identifier" // NIP-73 external identifier (non-empty)
+	tagTypeInteger    = "integer"    // base-10 integer
+	tagTypeTimestamp  = "timestamp"  // unix seconds
+	tagTypeString     = "string"     // non-empty free text
+)
+
+// AssertionTagSpec describes one tag we emit in a NIP-85 assertion event.
+type AssertionTagSpec struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Unit        string `json:"unit,omitempty"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	Repeatable  bool   `json:"repeatable,omitempty"`
+	Values      int    `json:"values"` // number of values after the tag name
+	Min         *int64 `json:"min,omitempty"`
+	Max         *int64 `json:"max,omitempty"`
+}
+
+// AssertionKindSpec describes one NIP-85 assertion kind and the tags it carries.
+type AssertionKindSpec struct {
+	Kind        int                `json:"kind"`
+	Name        string             `json:"name"`
+	Subject     string             `json:"subject"` // what the d tag identifies
+	Description string             `json:"description"`
+	Tags        []AssertionTagSpec `json:"tags"`
+}
+
+func int64Ptr(v int64) *int64 { return &v }
+
+// countTag is a required non-negative integer count.
+func countTag(name, unit, description string) AssertionTagSpec {
+	return AssertionTagSpec{Name: name, Type: tagTypeInteger, Unit: unit, Description: description, Required: true, Values: 1, Min: int64Ptr(0)}
+}
+
+var rankTag = AssertionTagSpec{
+	Name: "rank", Type: tagTypeInteger, Unit: "score", Required: true, Values: 1,
+	Min: int64Ptr(0), Max: int64Ptr(100),
+	Description: "Normalized trust/engagement score, 0-100",
+}
+
+// engagementTags are shared by the event, addressable event, and external identifier kinds.
+var engagementTags = []AssertionTagSpec{
+	countTag("comments", "count", "Replies/comments referencing the subject"),
+	countTag("reposts", "count", "Reposts (kind 6/16) of the subject"),
+	countTag("reactions", "count", "Reactions (kind 7) to the subject"),
+	countTag("zap_count", "count", "Zap receipts (kind 9735) for the subject"),
+	countTag("zap_amount", "sats", "Total sats zapped to the subject"),
+}
+
+// uniqueEngagersTag is shared by the event and addressable event kinds. Counts are exact
+// up to 256 engagers and HyperLogLog estimates beyond that.
+var uniqueEngagersTag = AssertionTagSpec{Name: "unique_engagers", Type: tagTypeInteger, Unit: "count", Values: 1, Min: int64Ptr(0), Description: "Distinct pubkeys that reacted, reposted, replied, or zapped"}
+
+// assertionSchema is the single source of truth for every tag published in kinds
+// 30382-30385. Publishers validate against it before signing, and GET /assertion-schema
+// serves it as documentation.
+var assertionSchema = []AssertionKindSpec{
+	{
+		Kind: 30382, Name: "User Assertion", Subject: "pubkey",
+		Description: "Trust score and activity metrics for a pubkey, from PageRank over the follow graph",
+		Tags: []AssertionTagSpec{
+			{Name: "d", Type: tagTypePubkey, Required: true, Values: 1, Description: "Subject pubkey"},
+			{Name: "p", Type: tagTypePubkey, Required: true, Values: 1, Description: "Subject pubkey (for #p filters)"},
+			rankTag,
+			countTag("followers", "count", "Followers in the crawled graph"),
+			countTag("post_cnt", "count", "Notes (kind 1) authored"),
+			countTag("reply_cnt", "count", "Replies authored"),
+			countTag("reactions_cnt", "count", "Reactions received"),
+			countTag("zap_amt_recd", "sats", "Total sats received in zaps"),
+			countTag("zap_cnt_recd", "count", "Zaps received"),
+			countTag("zap_amt_sent", "sats", "Total sats sent in zaps"),
+			countTag("zap_cnt_sent", "count", "Zaps sent"),
+			{Name: "percentile", Type: tagTypeInteger, Unit: "percent", Values: 1, Min: int64Ptr(1), Max: int64Ptr(100), Description: "Smallest top-N% tier the pubkey is in (1, 5, 10, 25, 50), or 100 below the top half"},
+			{Name: "verified_zap_amt_recd", Type: tagTypeInteger, Unit: "sats", Values: 1, Min: int64Ptr(1), Description: "Sats received in zap receipts that pass NIP-57 verification; omitted when zero"},
+			{Name: "verified_zap_cnt_recd", Type: tagTypeInteger, Unit: "count", Values: 1, Min: int64Ptr(1), Description: "Zap receipts received that pass NIP-57 verification; omitted when zero"},
+			{Name: "first_created_at", Type: tagTypeTimestamp, Unit: "unix seconds", Values: 1, Min: int64Ptr(0), Description: "Earliest event seen from the pubkey"},
+			{Name: "zap_avg_amt_day_recd", Type: tagTypeInteger, Unit: "sats/day", Values: 1, Min: int64Ptr(0), Description: "Average sats received per day since first_created_at"},
+			{Name: "zap_avg_amt_day_sent", Type: tagTypeInteger, Unit: "sats/day", Values: 1, Min: int64Ptr(0), Description: "Average sats sent per day since first_created_at"},
+			{Name: "active_hours_start", Type: tagTypeInteger, Unit: "hour (UTC)", Values: 1, Min: int64Ptr(0), Max: int64Ptr(23), Description: "Start of the most active posting window"},
+			{Name: "active_hours_end", Type: tagTypeInteger, Unit: "hour (UTC)", Values: 1, Min: int64Ptr(0), Max: int64Ptr(23), Description: "End of the most active posting window"},
+			{Name: "reports_cnt_recd", Type: tagTypeInteger, Unit: "count", Values: 1, Min: int64Ptr(1), Description: "Reports (kind 1984) received; omitted when zero"},
+			{Name: "reports_cnt_sent", Type: tagTypeInteger, Unit: "count", Values: 1, Min: int64Ptr(1), Description: "Reports (kind 1984) sent; omitted when zero"},
+			{Name: "t", Type: tagTypeString, Repeatable: true, Values: 1, Description: "Top hashtags used by the pubkey (up to 5)"},
+			{Name: "L", Type: tagTypeString, Repeatable: true, Values: 1, Description: "NIP-32 label namespace from trusted annotations (opt-in)"},
+			{Name: "l", Type: tagTypeString, Repeatable: true, Values: 2, Description: "NIP-32 label and its namespace from trusted annotations (opt-in)"},
+		},
+	},
+	{
+		Kind: 30383, Name: "Event Assertion", Subject: "event_id",
+		Description: "Engagement score for a single event",
+		Tags: append([]AssertionTagSpec{
+			{Name: "d", Type: tagTypeEventID, Required: true, Values: 1, Description: "Subject event id"},
+			{Name: "e", Type: tagTypeEventID, Required: true, Values: 1, Description: "Subject event id (for #e filters)"},
+			{Name: "p", Type: tagTypePubkey, Values: 1, Description: "Event author; omitted when the author is unknown"},
+			rankTag,
+			uniqueEngagersTag,
+		}, engagementTags...),
+	},
+	{
+		Kind: 30384, Name: "Addressable Event Assertion", Subject: "address",
+		Description: "Engagement score for an addressable event (e.g. long-form article)",
+		Tags: append([]AssertionTagSpec{
+			{Name: "d", Type: tagTypeAddress, Required: true, Values: 1, Description: "Subject address (kind:pubkey:d-tag)"},
+			{Name: "a", Type: tagTypeAddress, Required: true, Values: 1, Description: "Subject address (for #a filters)"},
+			{Name: "p", Type: tagTypePubkey, Values: 1, Description: "Event author; omitted when the author is unknown"},
+			rankTag,
+			uniqueEngagersTag,
+		}, engagementTags...),
+	},
+	{
+		Kind: 30385, Name: "External Identifier Assertion", Subject: "identifier",
+		Description: "Engagement score for a NIP-73 external identifier (hashtag, URL, ...)",
+		Tags: append([]AssertionTagSpec{
+			{Name: "d", Type: tagTypeIdentifier, Required: true, Values: 1, Description: "Subject identifier"},
+			rankTag,
+			countTag("mentions", "count", "Events referencing the identifier"),
+			countTag("unique_authors", "count", "Distinct pubkeys referencing the identifier"),
+		}, engagementTags...),
+	},
+	{
+		Kind: 30385, Name: "Relay Trust Assertion", Subject: "relay",
+		Description: "Trust score for a relay (d is its wss:// URL), from trust-weighted NIP-65 usage and its NIP-11 operator's score",
+		Tags: []AssertionTagSpec{
+			{Name: "d", Type: tagTypeIdentifier, Required: true, Values: 1, Description: "Relay URL"},
+			rankTag,
+			countTag("users", "count", "Graph members listing the relay in their NIP-65 relay list"),
+			countTag("write_users", "count", "Graph members listing it as a write relay"),
+			countTag("read_users", "count", "Graph members listing it as a read relay"),
+			{Name: "operator", Type: tagTypePubkey, Values: 1, Description: "Operator pubkey from the relay's NIP-11 document; omitted when unknown"},
+			{Name: "operator_rank", Type: tagTypeInteger, Unit: "score", Values: 1, Min: int64Ptr(0), Max: int64Ptr(100), Description: "Operator's WoT score, 0-100; omitted when the operator is unknown"},
+		},
+	},
+}
+
+var tagAddressPattern = regexp.MustCompile(`^[0-9]+:[0-9a-f]{64}:.*$`)
+
+// assertionKindSpec returns the spec for an event of kind whose d tag is d, or nil
+// if we don't publish it. Kind 30385 identifiers that are relay URLs use the relay
+// trust spec.
+func assertionKindSpec(kind int, d string) *AssertionKindSpec {
+	subject := ""
+	if kind == 30385 && (strings.HasPrefix(d, "wss://") || strings.HasPrefix(d, "ws://")) {
+		subject = "relay"
+	}
+	for i := range assertionSchema {
+		s := &assertionSchema[i]
+		if s.Kind == kind && (subject == "" || s.Subject == subject) {
+			return s
+		}
+	}
+	return nil
+}
+
+// validateTagValue checks one value against its spec.
+func validateTagValue(spec AssertionTagSpec, v string) error {
+	switch spec.Type {
+	case tagTypePubkey, tagTypeEventID:
+		if !hex64Pattern.MatchString(v) {
+			return fmt.Errorf("tag %s: %q is not 64-char lowercase hex", spec.Name, v)
+		}
+	case tagTypeAddress:
+		if !tagAddressPattern.MatchString(v) {
+			return fmt.Errorf("tag %s: %q is not a kind:pubkey:d address", spec.Name, v)
+		}
+	case tagTypeInteger, tagTypeTimestamp:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("tag %s: %q is not an integer", spec.Name, v)
+		}
+		if spec.Min != nil && n < *spec.Min {
+			return fmt.Errorf("tag %s: %d below minimum %d", spec.Name, n, *spec.Min)
+		}
+		if spec.Max != nil && n > *spec.Max {
+			return fmt.Errorf("tag %s: %d above maximum %d", spec.Name, n, *spec.Max)
+		}
+	case tagTypeIdentifier, tagTypeString:
+		if v == "" {
+			return fmt.Errorf("tag %s: empty value", spec.Name)
+		}
+	}
+	return nil
+}
+
+// validateAssertionEvent checks an unsigned assertion event against assertionSchema:
+// the kind must be one we publish, every tag must be known with the right number
+// of well-typed values, required tags must be present, non-repeatable tags must
+// appear once, and the p/e/a subject tag must repeat the d value.
+func validateAssertionEvent(ev *nostr.Event) error {
+	spec := assertionKindSpec(ev.Kind, ev.Tags.GetD())
+	if spec == nil {
+		return fmt.Errorf("kind %d is not a published assertion kind", ev.Kind)
+	}
+	byName := make(map[string]AssertionTagSpec, len(spec.Tags))
+	for _, t := range spec.Tags {
+		byName[t.Name] = t
+	}
+
+	seen := make(map[string]int)
+	for _, tag := range ev.Tags {
+		if len(tag) == 0 {
+			return fmt.Errorf("kind %d: empty tag", ev.Kind)
+		}
+		ts, ok := byName[tag[0]]
+		if !ok {
+			return fmt.Errorf("kind %d: unknown tag %q", ev.Kind, tag[0])
+		}
+		if len(tag)-1 != ts.Values {
+			return fmt.Errorf("kind %d: tag %s has %d values, want %d", ev.Kind, ts.Name, len(tag)-1, ts.Values)
+		}
+		for _, v := range tag[1:] {
+			if err := validateTagValue(ts, v); err != nil {
+				return fmt.Errorf("kind %d: %w", ev.Kind, err)
+			}
+		}
+		seen[ts.Name]++
+		if seen[ts.Name] > 1 && !ts.Repeatable {
+			return fmt.Errorf("kind %d: tag %s appears more than once", ev.Kind, ts.Name)
+		}
+	}
+	for _, t := range spec.Tags {
+		if t.Required && seen[t.Name] == 0 {
+			return fmt.Errorf("kind %d: missing required tag %s", ev.Kind, t.Name)
+		}
+	}
+
+	// The subject mirror tag (p for users, e for events, a for addresses) must match d.
+	d := ev.Tags.GetD()
+	mirror := map[int]string{30382: "p", 30383: "e", 30384: "a"}[ev.Kind]
+	if mirror != "" {
+		if tag := ev.Tags.Find(mirror); tag != nil && tag[1] != d {
+			return fmt.Errorf("kind %d: %s tag %q does not match d tag %q", ev.Kind, mirror, tag[1], d)
+		}
+	}
+	return nil
+}
+
+// handleAssertionSchema serves GET /assertion-schema: the tag schema for every
+// NIP-85 assertion kind this service publishes.
+func handleAssertionSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"nip":   85,
+		"kinds": assertionSchema,
+		"types": map[string]string{
+			tagTypePubkey:     "64-char lowercase hex pubkey",
+			tagTypeEventID:    "64-char lowercase hex event id",
+			tagTypeAddress:    "<kind>:<pubkey>:<d-tag>",
+			tagTypeIdentifier: "NIP-73 external identifier",
+			tagTypeInteger:    "base-10 integer encoded as a string",
+			tagTypeTimestamp:  "unix timestamp in seconds encoded as a string",
+			tagTypeString:     "non-empty string",
+		},
+	})
+}
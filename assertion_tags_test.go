@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func validUserAssertion() *nostr.Event {
+	pk := padHex(18001)
+	return &nostr.Event{
+		Kind: 30382,
+		Tags: nostr.Tags{
+			{"d", pk}, {"p", pk}, {"rank", "42"}, {"followers", "10"},
+			{"post_cnt", "1"}, {"reply_cnt", "0"}, {"reactions_cnt", "3"},
+			{"zap_amt_recd", "0"}, {"zap_cnt_recd", "0"}, {"zap_amt_sent", "0"}, {"zap_cnt_sent", "0"},
+			{"active_hours_start", "9"}, {"active_hours_end", "17"},
+			{"t", "bitcoin"}, {"t", "nostr"},
+			{"L", "bitcoin-core"}, {"l", "contributor", "bitcoin-core"},
+		},
+	}
+}
+
+func TestValidateAssertionEventAcceptsPublishedShapes(t *testing.T) {
+	if err := validateAssertionEvent(validUserAssertion()); err != nil {
+		t.Errorf("valid 30382 rejected: %v", err)
+	}
+
+	engagement := nostr.Tags{{"rank", "7"}, {"comments", "1"}, {"reposts", "2"}, {"reactions", "3"}, {"zap_count", "0"}, {"zap_amount", "0"}}
+	id := padHex(18002)
+	ev := &nostr.Event{Kind: 30383, Tags: append(nostr.Tags{{"d", id}, {"e", id}}, engagement...)}
+	if err := validateAssertionEvent(ev); err != nil {
+		t.Errorf("30383 without author rejected: %v", err)
+	}
+	addr := "30023:" + padHex(18003) + ":my-article"
+	ev = &nostr.Event{Kind: 30384, Tags: append(nostr.Tags{{"d", addr}, {"a", addr}, {"p", padHex(18003)}}, engagement...)}
+	if err := validateAssertionEvent(ev); err != nil {
+		t.Errorf("valid 30384 rejected: %v", err)
+	}
+	ev = &nostr.Event{Kind: 30385, Tags: append(nostr.Tags{{"d", "#bitcoin"}, {"mentions", "5"}, {"unique_authors", "4"}}, engagement...)}
+	if err := validateAssertionEvent(ev); err != nil {
+		t.Errorf("valid 30385 rejected: %v", err)
+	}
+	ev = &nostr.Event{Kind: 30385, Tags: nostr.Tags{{"d", "wss://relay.example.com"}, {"rank", "60"}, {"users", "12"}, {"write_users", "10"}, {"read_users", "11"}, {"operator", padHex(18004)}, {"operator_rank", "30"}}}
+	if err := validateAssertionEvent(ev); err != nil {
+		t.Errorf("valid 30385 relay assertion rejected: %v", err)
+	}
+	ev.Tags = append(ev.Tags, nostr.Tag{"mentions", "5"})
+	if err := validateAssertionEvent(ev); err == nil {
+		t.Error("expected identifier tags to be rejected on a relay assertion")
+	}
+}
+
+func TestValidateAssertionEventRejectsDrift(t *testing.T) {
+	cases := map[string]func(ev *nostr.Event){
+		"unknown tag":       func(ev *nostr.Event) { ev.Tags = append(ev.Tags, nostr.Tag{"score", "1"}) },
+		"rank out of range": func(ev *nostr.Event) { ev.Tags[2] = nostr.Tag{"rank", "101"} },
+		"non-integer":       func(ev *nostr.Event) { ev.Tags[3] = nostr.Tag{"followers", "ten"} },
+		"duplicate":         func(ev *nostr.Event) { ev.Tags = append(ev.Tags, nostr.Tag{"rank", "5"}) },
+		"missing required":  func(ev *nostr.Event) { ev.Tags = ev.Tags[:3] },
+		"bad pubkey":        func(ev *nostr.Event) { ev.Tags[1] = nostr.Tag{"p", ""} },
+		"p mismatch":        func(ev *nostr.Event) { ev.Tags[1] = nostr.Tag{"p", padHex(18999)} },
+		"wrong arity":       func(ev *nostr.Event) { ev.Tags = append(ev.Tags, nostr.Tag{"l", "contributor"}) },
+		"hour range":        func(ev *nostr.Event) { ev.Tags[11] = nostr.Tag{"active_hours_start", "24"} },
+		"unpublished kind":  func(ev *nostr.Event) { ev.Kind = 30386 },
+	}
+	for name, mutate := range cases {
+		ev := validUserAssertion()
+		mutate(ev)
+		if err := validateAssertionEvent(ev); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestAssertionSchemaEndpoint(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handleAssertionSchema(rr, httptest.NewRequest("GET", "/assertion-schema", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var resp struct {
+		Kinds []AssertionKindSpec `json:"kinds"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Kinds) != 5 {
+		t.Fatalf("expected 4 assertion kinds plus relay assertions, got %d", len(resp.Kinds))
+	}
+	for _, k := range resp.Kinds {
+		hasD := false
+		for _, tag := range k.Tags {
+			if tag.Description == "" || tag.Type == "" {
+				t.Errorf("kind %d tag %s missing type or description", k.Kind, tag.Name)
+			}
+			if tag.Name == "d" && tag.Required {
+				hasD = true
+			}
+		}
+		if !hasD {
+			t.Errorf("kind %d must require a d tag", k.Kind)
+		}
+	}
+	if !strings.Contains(rr.Body.String(), `"unit":"sats"`) {
+		t.Error("expected units in schema output")
+	}
+}
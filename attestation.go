@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// attestationKind is in the ephemeral range (20000-29999) so relays never store an
+	// attestation if someone republishes it; it is meant for off-Nostr use.
+	attestationKind = 21385
+
+	attestationDefaultTTL = 24 * time.Hour
+	attestationMaxTTL     = 7 * 24 * time.Hour
+)
+
+// ProviderKey lazily loads the service's Nostr signing key for on-demand signatures.
+// The key (or the error loading it) is cached after the first call.
+type ProviderKey struct {
+	mu     sync.Mutex
+	load   func() (string, error)
+	loaded bool
+	sk     string
+	pub    string
+	err    error
+}
+
+var providerKey = &ProviderKey{load: getNsec}
+
+// Get returns the secret key and pubkey.
+func (k *ProviderKey) Get() (sk, pub string, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.loaded {
+		k.loaded = true
+		nsec, err := k.load()
+		if err == nil {
+			k.sk, k.pub, err = decodeKey(nsec)
+		}
+		k.err = err
+	}
+	return k.sk, k.pub, k.err
+}
+
+// AttestationClaim is the signed statement. Field names follow JWT registered claims
+// so the same payload works as a Nostr event's content and as a JWS payload.
+type AttestationClaim struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Score     *int   `json:"score,omitempty"`
+	ScoreGTE  *int   `json:"score_gte,omitempty"`
+	Rank      int    `json:"rank,omitempty"`
+	Build     uint64 `json:"build"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// buildAttestationEvent signs claim as a Nostr event. The expiration tag (NIP-40)
+// carries the validity window.
+func buildAttestationEvent(claim AttestationClaim, sk string) (*nostr.Event, error) {
+	content, _ := json.Marshal(claim)
+	tags := nostr.Tags{
+		{"p", claim.Subject},
+		{"build", strconv.FormatUint(claim.Build, 10)},
+		{"expiration", strconv.FormatInt(claim.ExpiresAt, 10)},
+		{"alt", "Web of Trust score attestation"},
+	}
+	if claim.ScoreGTE != nil {
+		tags = append(tags, nostr.Tag{"score_gte", strconv.Itoa(*claim.ScoreGTE)})
+	} else {
+		tags = append(tags, nostr.Tag{"score", strconv.Itoa(*claim.Score)}, nostr.Tag{"rank", strconv.Itoa(claim.Rank)})
+	}
+	ev := &nostr.Event{
+		Kind:      attestationKind,
+		CreatedAt: nostr.Timestamp(claim.IssuedAt),
+		Tags:      tags,
+		Content:   string(content),
+	}
+	if err := ev.Sign(sk); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// attestationJWK returns the provider key as an EC JWK. Its x coordinate is the
+// provider's Nostr pubkey.
+func attestationJWK(priv *btcec.PrivateKey) map[string]string {
+	pub := priv.PubKey().SerializeUncompressed()
+	return map[string]string{
+		"kty": "EC",
+		"crv": "secp256k1",
+		"x":   base64.RawURLEncoding.EncodeToString(pub[1:33]),
+		"y":   base64.RawURLEncoding.EncodeToString(pub[33:]),
+		"alg": "ES256K",
+		"kid": hex.EncodeToString(pub[1:33]),
+	}
+}
+
+// buildAttestationJWS signs claim as a compact JWS (RFC 7515) using ES256K (RFC 8812)
+// with the provider's secp256k1 key.
+func buildAttestationJWS(claim AttestationClaim, sk string) (string, map[string]string, error) {
+	payload, _ := json.Marshal(claim)
+	return signES256K(payload, sk)
+}
+
+// signES256K signs payload as a compact JWS with the secp256k1 key sk and returns the
+// token and the signing key as a JWK.
+func signES256K(payload []byte, sk string) (string, map[string]string, error) {
+	skBytes, err := hex.DecodeString(sk)
+	if err != nil || len(skBytes) != 32 {
+		return "", nil, fmt.Errorf("invalid signing key")
+	}
+	priv, _ := btcec.PrivKeyFromBytes(skBytes)
+	jwk := attestationJWK(priv)
+
+	header, _ := json.Marshal(map[string]string{"alg": "ES256K", "typ": "JWT", "kid": jwk["kid"]})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signingInput))
+	compact := ecdsa.SignCompact(priv, hash[:], true) // recovery byte || R || S
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(compact[1:]), jwk, nil
+}
+
+// verifyES256K checks a compact JWS from signES256K against key and returns its
+// payload. The header's kid must be key's x coordinate.
+func verifyES256K(token string, key *btcec.PublicKey) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token is not a compact JWS")
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token header")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if json.Unmarshal(headerJSON, &header) != nil || header.Alg != "ES256K" {
+		return nil, fmt.Errorf("token must be signed with ES256K")
+	}
+	if header.Kid != hex.EncodeToString(key.SerializeUncompressed()[1:33]) {
+		return nil, fmt.Errorf("token was not issued by this provider")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token payload")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	var r, s btcec.ModNScalar
+	r.SetByteSlice(sig[:32])
+	s.SetByteSlice(sig[32:])
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.NewSignature(&r, &s).Verify(hash[:], key) {
+		return nil, fmt.Errorf("token signature does not verify")
+	}
+	return payload, nil
+}
+
+// AttestationResponse is the /attestation body: the claim plus event for
+// type=nostr, or the claim plus jws and jwk for type=jws.
+type AttestationResponse struct {
+	Type      string            `json:"type"`
+	Claim     AttestationClaim  `json:"claim"`
+	Issuer    string            `json:"issuer"`
+	ExpiresAt string            `json:"expires_at"`
+	Event     *nostr.Event      `json:"event,omitempty"`
+	JWS       string            `json:"jws,omitempty"`
+	JWK       map[string]string `json:"jwk,omitempty"`
+}
+
+// handleAttestation serves GET /attestation?pubkey=<hex|npub>: a statement of the
+// subject's current score signed by this provider, for use off Nostr. With min_score
+// the statement only asserts score >= min_score, and is refused if that is false.
+func handleAttestation(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	pubkey := q.HexPubkey("pubkey", true)
+	typ := q.OneOf("type", "nostr", "jws")
+	ttlHours := q.IntBetween("ttl", int(attestationDefaultTTL/time.Hour), 1, int(attestationMaxTTL/time.Hour))
+	minScore := q.IntBetween("min_score", -1, 0, 100)
+	if q.Failed(w) {
+		return
+	}
+	if typ == "" {
+		typ = "nostr"
+	}
+	ttl := time.Duration(ttlHours) * time.Hour
+
+	build, _, _ := graphBuild.Current()
+	if build == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "scores have not been computed yet"})
+		return
+	}
+
+	rawScore, _ := graph.GetScore(pubkey)
+	score := normalizeScore(rawScore, graph.Stats().Nodes)
+	if minScore >= 0 && score < minScore {
+		writeAPIError(w, &APIError{Status: http.StatusForbidden, Message: fmt.Sprintf("score is below min_score %d", minScore)})
+		return
+	}
+
+	sk, pub, err := providerKey.Get()
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "attestation signing key not configured"})
+		return
+	}
+
+	now := time.Now()
+	claim := AttestationClaim{
+		Issuer:    pub,
+		Subject:   pubkey,
+		Build:     build,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+	if minScore >= 0 {
+		claim.ScoreGTE = &minScore
+	} else {
+		claim.Score = &score
+		claim.Rank = graph.Rank(pubkey)
+	}
+
+	resp := AttestationResponse{
+		Type:      typ,
+		Claim:     claim,
+		Issuer:    pub,
+		ExpiresAt: time.Unix(claim.ExpiresAt, 0).UTC().Format(time.RFC3339),
+	}
+	if typ == "jws" {
+		token, jwk, err := buildAttestationJWS(claim, sk)
+		if err != nil {
+			writeAPIError(w, &APIError{Status: http.StatusInternalServerError, Message: "failed to sign attestation"})
+			return
+		}
+		resp.JWS, resp.JWK = token, jwk
+	} else {
+		ev, err := buildAttestationEvent(claim, sk)
+		if err != nil {
+			writeAPIError(w, &APIError{Status: http.StatusInternalServerError, Message: "failed to sign attestation"})
+			return
+		}
+		resp.Event = ev
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
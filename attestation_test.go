@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// setupAttestation installs a scored graph, a build, and a fresh provider key.
+func setupAttestation(t *testing.T) (subject, providerPub string) {
+	oldGraph, oldBuild, oldKey := graph, graphBuild, providerKey
+	t.Cleanup(func() { graph, graphBuild, providerKey = oldGraph, oldBuild, oldKey })
+
+	graph = NewGraph()
+	subject = padHex(26001)
+	for i := 0; i < 20; i++ {
+		graph.AddFollow(padHex(26100+i), subject)
+	}
+	graph.ComputePageRank(20, 0.85)
+	graphBuild = NewGraphBuild()
+	graphBuild.Advance(time.Now())
+
+	sk := nostr.GeneratePrivateKey()
+	providerPub, _ = nostr.GetPublicKey(sk)
+	providerKey = &ProviderKey{load: func() (string, error) { return sk, nil }}
+	return subject, providerPub
+}
+
+func getAttestation(query string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+	rr := httptest.NewRecorder()
+	handleAttestation(rr, httptest.NewRequest("GET", "/attestation?"+query, nil))
+	var resp map[string]json.RawMessage
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+func TestAttestationNostrEvent(t *testing.T) {
+	subject, providerPub := setupAttestation(t)
+
+	rr, resp := getAttestation("pubkey=" + subject)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var ev nostr.Event
+	json.Unmarshal(resp["event"], &ev)
+	if ok, err := ev.CheckSignature(); !ok || err != nil {
+		t.Fatalf("event signature invalid: %v", err)
+	}
+	if ev.PubKey != providerPub || ev.Kind != attestationKind {
+		t.Errorf("unexpected event %+v", ev)
+	}
+	if tag := ev.Tags.Find("p"); tag == nil || tag[1] != subject {
+		t.Error("expected p tag for subject")
+	}
+	if ev.Tags.Find("expiration") == nil || ev.Tags.Find("score") == nil || ev.Tags.Find("build") == nil {
+		t.Errorf("missing tags %v", ev.Tags)
+	}
+	var claim AttestationClaim
+	json.Unmarshal([]byte(ev.Content), &claim)
+	if claim.Subject != subject || claim.Issuer != providerPub || claim.Score == nil || claim.Build != 1 {
+		t.Errorf("unexpected claim %+v", claim)
+	}
+	if claim.ExpiresAt-claim.IssuedAt != int64(attestationDefaultTTL.Seconds()) {
+		t.Errorf("expected default validity window, got %d", claim.ExpiresAt-claim.IssuedAt)
+	}
+}
+
+func TestAttestationJWS(t *testing.T) {
+	subject, providerPub := setupAttestation(t)
+
+	rr, resp := getAttestation("pubkey=" + subject + "&type=jws&min_score=0&ttl=2")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var token string
+	json.Unmarshal(resp["jws"], &token)
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected compact JWS, got %q", token)
+	}
+
+	var jwk map[string]string
+	json.Unmarshal(resp["jwk"], &jwk)
+	x, _ := base64.RawURLEncoding.DecodeString(jwk["x"])
+	y, _ := base64.RawURLEncoding.DecodeString(jwk["y"])
+	if hex.EncodeToString(x) != providerPub {
+		t.Errorf("jwk x must be the provider pubkey")
+	}
+	pub, err := btcec.ParsePubKey(append(append([]byte{4}, x...), y...))
+	if err != nil {
+		t.Fatalf("bad jwk: %v", err)
+	}
+
+	sigBytes, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	var r, s btcec.ModNScalar
+	r.SetByteSlice(sigBytes[:32])
+	s.SetByteSlice(sigBytes[32:])
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.NewSignature(&r, &s).Verify(hash[:], pub) {
+		t.Error("JWS signature does not verify against the JWK")
+	}
+
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var claim AttestationClaim
+	json.Unmarshal(payload, &claim)
+	if claim.ScoreGTE == nil || *claim.ScoreGTE != 0 || claim.Score != nil || claim.Rank != 0 {
+		t.Errorf("threshold attestation must not reveal the exact score: %+v", claim)
+	}
+	if claim.ExpiresAt-claim.IssuedAt != 7200 {
+		t.Errorf("expected 2h validity window, got %d", claim.ExpiresAt-claim.IssuedAt)
+	}
+}
+
+func TestAttestationFormatNpub(t *testing.T) {
+	subject, providerPub := setupAttestation(t)
+	handler := NIP19FormatMiddleware(http.HandlerFunc(handleAttestation))
+
+	for _, typ := range []string{"nostr", "jws"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/attestation?format=npub&type="+typ+"&pubkey="+subject, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", typ, rr.Code, rr.Body.String())
+		}
+		var resp AttestationResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		if resp.Claim.Subject != subject || resp.Claim.Issuer != providerPub || resp.Issuer != providerPub {
+			t.Errorf("%s: expected the claim left in hex, got %+v", typ, resp)
+		}
+		if typ == "nostr" {
+			if ok, err := resp.Event.CheckSignature(); !ok || err != nil {
+				t.Errorf("expected the signed event untouched: %v", err)
+			}
+		} else if resp.JWK["kid"] != providerPub {
+			t.Errorf("expected the JWK left in hex, got %v", resp.JWK)
+		}
+	}
+}
+
+func TestAttestationErrors(t *testing.T) {
+	subject, _ := setupAttestation(t)
+
+	for _, tc := range []struct {
+		query string
+		code  int
+	}{
+		{"", http.StatusBadRequest},
+		{"pubkey=alice", http.StatusBadRequest},
+		{"pubkey=" + subject + "&type=jwt", http.StatusBadRequest},
+		{"pubkey=" + subject + "&ttl=200", http.StatusBadRequest},
+		{"pubkey=" + subject + "&min_score=101", http.StatusBadRequest},
+		{"pubkey=" + padHex(26999) + "&min_score=50", http.StatusForbidden},
+	} {
+		if rr, _ := getAttestation(tc.query); rr.Code != tc.code {
+			t.Errorf("%q: expected %d, got %d", tc.query, tc.code, rr.Code)
+		}
+	}
+
+	providerKey = &ProviderKey{load: func() (string, error) { return "", errors.New("no key") }}
+	if rr, _ := getAttestation("pubkey=" + subject); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without a signing key, got %d", rr.Code)
+	}
+
+	graphBuild = NewGraphBuild()
+	if rr, _ := getAttestation("pubkey=" + subject); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before the first build, got %d", rr.Code)
+	}
+}
@@ -96,6 +96,13 @@ func (s *AuthStore) TotalAuthorizations() int {
 	return count
 }
 
+// Get returns the authorization a user gave a provider, or nil.
+func (s *AuthStore) Get(userPubkey, providerPubkey string) *Authorization {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.auths[userPubkey][providerPubkey]
+}
+
 // GetForUser returns all authorizations a user has published.
 func (s *AuthStore) GetForUser(userPubkey string) []*Authorization {
 	s.mu.RLock()
@@ -174,7 +181,7 @@ func consumeAuthorizations(ctx context.Context, store *AuthStore) {
 	}
 
 	total := 0
-	for ev := range pool.SubManyEose(ctx, relays, nostr.Filters{filter}) {
+	for ev := range pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter}) {
 		auths := parseAuthorization(ev.Event)
 		for _, a := range auths {
 			store.Add(a)
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// authorizedMaxFollows caps the follows assertions are published about for one
+// authorizer, highest-scored first.
+const authorizedMaxFollows = 1000
+
+// AuthorizerQueue is the publishing state for one user who authorized us for
+// kind 30382 via kind 10040.
+type AuthorizerQueue struct {
+	User          string    `json:"user"`
+	Relays        []string  `json:"relays"`
+	Follows       int       `json:"follows"` // follows in our graph
+	Queued        int       `json:"queued"`  // assertions queued in the last cycle
+	Failed        int       `json:"failed"`
+	LastPublished time.Time `json:"last_published"`
+}
+
+// AuthorizedPublisher publishes kind 30382 assertions about the accounts each
+// authorizer follows, to the relay named in their kind 10040 plus ours — the
+// NIP-85 flow where clients read a user's chosen provider for the people they see.
+type AuthorizedPublisher struct {
+	mu     sync.RWMutex
+	queues map[string]*AuthorizerQueue
+}
+
+func NewAuthorizedPublisher() *AuthorizedPublisher {
+	return &AuthorizedPublisher{queues: make(map[string]*AuthorizerQueue)}
+}
+
+var authorizedPublisher = NewAuthorizedPublisher()
+
+// authorizesKind reports whether a covers assertions of kind (e.g. "30382").
+func authorizesKind(a *Authorization, kind string) bool {
+	if a == nil {
+		return false
+	}
+	for _, k := range a.Kinds {
+		if strings.HasPrefix(k, kind+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizerRelays is the authorizer's relay hint, if valid, followed by ours.
+func authorizerRelays(a *Authorization) []string {
+	relays := config.Relays()
+	hint := normalizeRelayURL(a.RelayHint)
+	if hint == "" {
+		return relays
+	}
+	out := []string{hint}
+	for _, r := range relays {
+		if normalizeRelayURL(r) != hint {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Publish queues assertions for every current authorizer. Each subject is signed
+// once per cycle however many authorizers follow it; the publish queue merges
+// the relays. Authorizers who revoked drop out of the status.
+func (p *AuthorizedPublisher) Publish(ctx context.Context, signer EventSigner, store *AuthStore, g *Graph) (int, error) {
+	own := signer.PublicKey()
+	users := store.AuthorizedUsers(own)
+	sort.Strings(users)
+
+	signed := make(map[string]*nostr.Event)
+	queues := make(map[string]*AuthorizerQueue)
+	total := 0
+	for _, user := range users {
+		if ctx.Err() != nil {
+			break
+		}
+		a := store.Get(user, own)
+		if !authorizesKind(a, "30382") {
+			continue
+		}
+		q := &AuthorizerQueue{User: user, Relays: authorizerRelays(a)}
+		queues[user] = q
+
+		var follows []ScoreEntry
+		for _, pk := range g.GetFollows(user) {
+			if raw, ok := g.GetScore(pk); ok {
+				follows = append(follows, ScoreEntry{Pubkey: pk, Score: raw})
+			}
+		}
+		q.Follows = len(follows)
+		sort.Slice(follows, func(i, j int) bool {
+			if follows[i].Score != follows[j].Score {
+				return follows[i].Score > follows[j].Score
+			}
+			return follows[i].Pubkey < follows[j].Pubkey
+		})
+		if len(follows) > authorizedMaxFollows {
+			follows = follows[:authorizedMaxFollows]
+		}
+
+		batch := make([]nostr.Event, 0, len(follows))
+		for _, f := range follows {
+			ev, ok := signed[f.Pubkey]
+			if !ok {
+				built := buildUserAssertion(own, f.Pubkey, f.Score)
+				if err := validateAssertionEvent(&built); err != nil {
+					log.Printf("Skipping kind 30382 for %s: %v", f.Pubkey, err)
+				} else if err := signer.Sign(ctx, &built); err != nil {
+					log.Printf("Failed to sign event for %s: %v", f.Pubkey, err)
+				} else {
+					ev = &built
+				}
+				signed[f.Pubkey] = ev
+			}
+			if ev == nil {
+				q.Failed++
+				continue
+			}
+			batch = append(batch, *ev)
+		}
+		q.Queued = publishQueue.Enqueue(batch, q.Relays)
+		q.LastPublished = time.Now()
+		total += q.Queued
+	}
+
+	p.mu.Lock()
+	p.queues = queues
+	p.mu.Unlock()
+	log.Printf("Queued %d kind 30382 events for %d authorizers (%d subjects)", total, len(queues), len(signed))
+	return total, ctx.Err()
+}
+
+// Status returns a copy of an authorizer's publishing state, or nil.
+func (p *AuthorizedPublisher) Status(user string) *AuthorizerQueue {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	q, ok := p.queues[user]
+	if !ok {
+		return nil
+	}
+	cp := *q
+	return &cp
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// setupAuthorizedPublish has two authorizers sharing a follow, one user who
+// only authorized kind 30383, and one who authorized another provider.
+func setupAuthorizedPublish(t *testing.T) (keySigner, *AuthStore) {
+	t.Helper()
+	oldGraph, oldQueue, oldPublisher := graph, publishQueue, authorizedPublisher
+	t.Cleanup(func() { graph, publishQueue, authorizedPublisher = oldGraph, oldQueue, oldPublisher })
+	graph, publishQueue, authorizedPublisher = NewGraph(), NewPublishQueue(""), NewAuthorizedPublisher()
+
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	alice, bob, carol, dave := padHex(45001), padHex(45002), padHex(45003), padHex(45004)
+	graph.AddFollow(alice, padHex(45010))
+	graph.AddFollow(alice, padHex(45011))
+	graph.AddFollow(bob, padHex(45011))
+	graph.AddFollow(carol, padHex(45012))
+	graph.AddFollow(dave, padHex(45013))
+	graph.ComputePageRank(20, 0.85)
+
+	store := NewAuthStore()
+	store.Add(&Authorization{UserPubkey: alice, ProviderPubkey: pub, Kinds: []string{"30382:rank"}, RelayHint: "wss://Alice.example/", CreatedAt: 1})
+	store.Add(&Authorization{UserPubkey: bob, ProviderPubkey: pub, Kinds: []string{"30382:rank", "30383:rank"}, CreatedAt: 1})
+	store.Add(&Authorization{UserPubkey: carol, ProviderPubkey: pub, Kinds: []string{"30383:rank"}, CreatedAt: 1})
+	store.Add(&Authorization{UserPubkey: dave, ProviderPubkey: padHex(45999), Kinds: []string{"30382:rank"}, CreatedAt: 1})
+	return keySigner{sk: sk, pub: pub}, store
+}
+
+func TestAuthorizedPublisherPublish(t *testing.T) {
+	signer, store := setupAuthorizedPublish(t)
+	n, err := authorizedPublisher.Publish(context.Background(), signer, store, graph)
+	if err != nil || n != 3 {
+		t.Fatalf("expected alice's two follows and bob's one queued, got %d %v", n, err)
+	}
+	if len(publishQueue.items) != 2 {
+		t.Errorf("expected the shared follow queued once, got %d items", len(publishQueue.items))
+	}
+
+	item, ok := publishQueue.items["30382:"+padHex(45011)]
+	if !ok {
+		t.Fatalf("expected the shared follow's assertion in the queue, got %v", publishQueue.items)
+	}
+	if ok, err := item.Event.CheckSignature(); !ok || err != nil || item.Event.PubKey != signer.pub {
+		t.Errorf("expected a signed assertion from us, got %v %v", ok, err)
+	}
+	if _, ok := item.Relays["wss://alice.example"]; !ok {
+		t.Errorf("expected alice's relay hint among the relays, got %v", item.Relays)
+	}
+	for _, pk := range []string{padHex(45012), padHex(45013)} {
+		if _, ok := publishQueue.items["30382:"+pk]; ok {
+			t.Errorf("expected nothing queued for %s", pk)
+		}
+	}
+
+	q := authorizedPublisher.Status(padHex(45001))
+	if q == nil || q.Follows != 2 || q.Queued != 2 || q.Relays[0] != "wss://alice.example" || q.LastPublished.IsZero() {
+		t.Errorf("unexpected status for alice %+v", q)
+	}
+	if authorizedPublisher.Status(padHex(45003)) != nil {
+		t.Errorf("expected no status for a kind 30383-only authorizer")
+	}
+
+	// Revoking drops the authorizer at the next cycle
+	store.Add(&Authorization{UserPubkey: padHex(45001), ProviderPubkey: signer.pub, Kinds: []string{"30383:rank"}, CreatedAt: 2})
+	authorizedPublisher.Publish(context.Background(), signer, store, graph)
+	if authorizedPublisher.Status(padHex(45001)) != nil {
+		t.Errorf("expected alice dropped after revoking")
+	}
+}
+
+func TestHandleAuthorizedIncludesPublishing(t *testing.T) {
+	signer, store := setupAuthorizedPublish(t)
+	oldStore := authStore
+	t.Cleanup(func() { authStore = oldStore })
+	authStore = store
+	authorizedPublisher.Publish(context.Background(), signer, store, graph)
+
+	rr := httptest.NewRecorder()
+	handleAuthorized(rr, httptest.NewRequest(http.MethodGet, "/authorized?pubkey="+signer.pub, nil))
+	var resp struct {
+		AuthorizedUsers []struct {
+			Pubkey     string           `json:"pubkey"`
+			Publishing *AuthorizerQueue `json:"publishing"`
+		} `json:"authorized_users"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); rr.Code != http.StatusOK || err != nil {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	publishing := 0
+	for _, u := range resp.AuthorizedUsers {
+		if u.Publishing != nil {
+			publishing++
+		}
+	}
+	if len(resp.AuthorizedUsers) != 3 || publishing != 2 {
+		t.Errorf("expected publishing state for alice and bob, got %s", rr.Body.String())
+	}
+}
+
+func TestAuthorizesKind(t *testing.T) {
+	a := &Authorization{Kinds: []string{"30383:rank", "30382:followers"}}
+	if !authorizesKind(a, "30382") || authorizesKind(a, "30384") || authorizesKind(nil, "30382") {
+		t.Errorf("unexpected authorizesKind results for %v", a.Kinds)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import "math"
+
+const (
+	// automationMinNotes is how many sampled notes cadence analysis needs before
+	// it judges a pubkey; with fewer the classification is unknown.
+	automationMinNotes = 10
+	// automationMinHourEvents is how many events the hour histogram needs before
+	// round-the-clock posting counts.
+	automationMinHourEvents = 24
+	// automationBurstSeconds is the gap under which consecutive notes count as a
+	// burst: faster than a person types.
+	automationBurstSeconds = 10
+	// automationQuietHours is the window a person's sleep leaves nearly empty.
+	automationQuietHours = 6
+)
+
+// IntervalStats summarizes the gaps between consecutive sampled notes.
+type IntervalStats struct {
+	Gaps          int     `json:"gaps"`
+	MeanMinutes   float64 `json:"mean_minutes"`
+	StdDevMinutes float64 `json:"stddev_minutes"`
+	CV            float64 `json:"coefficient_of_variation"` // stddev over mean; near 0 is clockwork
+	MinSeconds    int64   `json:"min_seconds"`
+	BurstGaps     int     `json:"burst_gaps"` // gaps under automationBurstSeconds
+}
+
+// AutomationAnalysis is how likely a pubkey's posting cadence is a bot's or a
+// scheduler's. Each component runs 0 (human) to 1 (automated).
+type AutomationAnalysis struct {
+	Likelihood       float64        `json:"likelihood"`
+	Classification   string         `json:"classification"`  // human, possibly_automated, likely_automated, unknown
+	Indicators       []string       `json:"indicators"`      // regular_intervals, round_the_clock, bursts
+	Regularity       float64        `json:"regularity"`      // 1 - the gaps' coefficient of variation
+	RoundTheClock    float64        `json:"round_the_clock"` // activity left in the quietest hours
+	Burstiness       float64        `json:"burstiness"`      // share of gaps that are bursts
+	Intervals        *IntervalStats `json:"intervals,omitempty"`
+	HourHistogram    [24]int        `json:"hour_histogram"`    // UTC
+	WeekdayHistogram [7]int         `json:"weekday_histogram"` // UTC, Sunday first
+}
+
+// intervalStats summarizes the gaps in a newest-first timestamp sample. It
+// needs at least two notes.
+func intervalStats(noteTimes []int64) *IntervalStats {
+	if len(noteTimes) < 2 {
+		return nil
+	}
+	s := &IntervalStats{Gaps: len(noteTimes) - 1, MinSeconds: math.MaxInt64}
+	var sum float64
+	for i := 1; i < len(noteTimes); i++ {
+		gap := noteTimes[i-1] - noteTimes[i]
+		sum += float64(gap)
+		s.MinSeconds = min(s.MinSeconds, gap)
+		if gap < automationBurstSeconds {
+			s.BurstGaps++
+		}
+	}
+	mean := sum / float64(s.Gaps)
+	var sq float64
+	for i := 1; i < len(noteTimes); i++ {
+		d := float64(noteTimes[i-1]-noteTimes[i]) - mean
+		sq += d * d
+	}
+	std := math.Sqrt(sq / float64(s.Gaps))
+	s.MeanMinutes = math.Round(mean/60*10) / 10
+	s.StdDevMinutes = math.Round(std/60*10) / 10
+	if mean > 0 {
+		s.CV = math.Round(std/mean*1000) / 1000
+	}
+	return s
+}
+
+// quietShare is the share of hours' events in the quietest automationQuietHours
+// contiguous window. People sleep, so theirs is near 0; a uniform 24/7 poster's
+// is a quarter.
+func quietShare(hours [24]int) float64 {
+	total := 0
+	for _, c := range hours {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+	quietest := total
+	for s := 0; s < 24; s++ {
+		sum := 0
+		for i := 0; i < automationQuietHours; i++ {
+			sum += hours[(s+i)%24]
+		}
+		quietest = min(quietest, sum)
+	}
+	return float64(quietest) / float64(total)
+}
+
+// analyzeAutomation scores posting cadence for signs of automation: clockwork
+// gaps between notes, posting through the hours people sleep, and bursts of
+// notes seconds apart. The components combine as independent evidence, so any
+// one of them can raise the likelihood alone: clockwork gaps up to 0.7, bursts
+// 0.6 and round-the-clock posting 0.5.
+func analyzeAutomation(hours [24]int, weekdays [7]int, noteTimes []int64) AutomationAnalysis {
+	a := AutomationAnalysis{
+		Classification:   "unknown",
+		Indicators:       []string{},
+		Intervals:        intervalStats(noteTimes),
+		HourHistogram:    hours,
+		WeekdayHistogram: weekdays,
+	}
+	if len(noteTimes) < automationMinNotes {
+		return a
+	}
+
+	round := func(x float64) float64 { return math.Round(math.Max(0, math.Min(1, x))*1000) / 1000 }
+	a.Regularity = round(1 - a.Intervals.CV)
+	a.Burstiness = round(float64(a.Intervals.BurstGaps) / float64(a.Intervals.Gaps) / 0.5)
+	total := 0
+	for _, c := range hours {
+		total += c
+	}
+	if total >= automationMinHourEvents {
+		a.RoundTheClock = round(quietShare(hours) / (float64(automationQuietHours) / 24))
+	}
+	a.Likelihood = round(1 - (1-0.7*a.Regularity)*(1-0.6*a.Burstiness)*(1-0.5*a.RoundTheClock))
+
+	if a.Regularity >= 0.6 {
+		a.Indicators = append(a.Indicators, "regular_intervals")
+	}
+	if a.RoundTheClock >= 0.8 {
+		a.Indicators = append(a.Indicators, "round_the_clock")
+	}
+	if a.Burstiness >= 0.5 {
+		a.Indicators = append(a.Indicators, "bursts")
+	}
+	switch {
+	case a.Likelihood >= 0.6:
+		a.Classification = "likely_automated"
+	case a.Likelihood >= 0.35:
+		a.Classification = "possibly_automated"
+	default:
+		a.Classification = "human"
+	}
+	return a
+}
+
+// pubkeyAutomation analyzes pubkey's posting cadence from the metadata crawl.
+func pubkeyAutomation(pubkey string) AutomationAnalysis {
+	return analyzeAutomation(meta.Cadence(pubkey))
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// cadenceFrom turns gaps in seconds into a newest-first note sample ending at
+// start, with the hour and weekday histograms crawlNotes would build from it.
+func cadenceFrom(start time.Time, gaps []int64) (hours [24]int, weekdays [7]int, noteTimes []int64) {
+	ts := start.Unix()
+	for i := 0; i <= len(gaps); i++ {
+		at := time.Unix(ts, 0).UTC()
+		hours[at.Hour()]++
+		weekdays[at.Weekday()]++
+		noteTimes = append(noteTimes, ts)
+		if i < len(gaps) {
+			ts -= gaps[i]
+		}
+	}
+	return hours, weekdays, noteTimes
+}
+
+func TestAnalyzeAutomation(t *testing.T) {
+	start := time.Date(2026, 6, 1, 23, 0, 0, 0, time.UTC)
+	hourly := make([]int64, 47)
+	for i := range hourly {
+		hourly[i] = 3600
+	}
+	a := analyzeAutomation(cadenceFrom(start, hourly))
+	if a.Classification != "likely_automated" || a.Regularity != 1 || a.RoundTheClock != 1 ||
+		!slices.Equal(a.Indicators, []string{"regular_intervals", "round_the_clock"}) {
+		t.Errorf("expected a clockwork poster flagged, got %+v", a)
+	}
+	if a.Intervals.CV != 0 || a.Intervals.MeanMinutes != 60 || a.Intervals.Gaps != 47 {
+		t.Errorf("unexpected intervals %+v", a.Intervals)
+	}
+
+	// a person posting in clumps through the afternoon and evening, days apart
+	h := int64(3600)
+	human := []int64{20 * 60, 3 * h, 45 * 60, 20 * h, 2 * h, 90, 6 * h, 3 * 24 * h, 40 * 60, 5 * h, 21 * h, 15 * 60}
+	a = analyzeAutomation(cadenceFrom(start.Add(-4*time.Hour), human))
+	if a.Classification != "human" || len(a.Indicators) != 0 || a.Intervals.CV < 1 {
+		t.Errorf("expected an irregular poster judged human, got %+v", a)
+	}
+
+	bursts := make([]int64, 15)
+	for i := range bursts {
+		bursts[i] = 2
+		if i%3 == 2 {
+			bursts[i] = 5 * h
+		}
+	}
+	a = analyzeAutomation(cadenceFrom(start, bursts))
+	if a.Intervals.BurstGaps != 10 || a.Burstiness != 1 || !slices.Contains(a.Indicators, "bursts") || a.Likelihood != 0.6 {
+		t.Errorf("expected bursts flagged, got %+v", a)
+	}
+
+	a = analyzeAutomation(cadenceFrom(start, hourly[:5]))
+	if a.Classification != "unknown" || a.Likelihood != 0 || a.Intervals == nil || a.HourHistogram[23] != 1 {
+		t.Errorf("expected too few notes left unknown, got %+v", a)
+	}
+}
+
+func TestQuietShare(t *testing.T) {
+	var hours [24]int
+	if quietShare(hours) != 0 {
+		t.Error("expected 0 for no notes")
+	}
+	for i := range hours {
+		hours[i] = 2
+	}
+	if quietShare(hours) != 0.25 {
+		t.Errorf("expected a quarter for uniform posting, got %v", quietShare(hours))
+	}
+	// asleep 22:00-05:00, wrapping midnight
+	for _, hr := range []int{22, 23, 0, 1, 2, 3, 4} {
+		hours[hr] = 0
+	}
+	if quietShare(hours) != 0 {
+		t.Errorf("expected the overnight gap found, got %v", quietShare(hours))
+	}
+}
+
+func TestAutomationInActivityAndSpam(t *testing.T) {
+	oldGraph, oldMeta := graph, meta
+	t.Cleanup(func() { graph, meta = oldGraph, oldMeta })
+	graph = NewGraph()
+	meta = NewMetaStore()
+
+	bot := padHex(51910)
+	m := meta.Get(bot)
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 48; i++ {
+		at := now.Add(-time.Duration(i) * 30 * time.Minute)
+		m.recordActivity(at.Unix())
+		m.recordNoteTime(at.Unix())
+		m.HourBuckets[at.Hour()]++
+		m.WeekdayBuckets[at.Weekday()]++
+	}
+
+	rr := httptest.NewRecorder()
+	handleActive(rr, httptest.NewRequest("GET", "/activity?pubkey="+bot, nil))
+	var resp ActivityResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Automation.Classification != "likely_automated" || resp.Automation.WeekdayHistogram[now.Weekday()] != 1 {
+		t.Errorf("unexpected automation %+v", resp.Automation)
+	}
+
+	s := spamSignalAutomation(resp.Automation)
+	if s.Name != "automation_likelihood" || s.Weight != 0.10 || s.Value != resp.Automation.Likelihood || s.Score <= 0.07 {
+		t.Errorf("unexpected spam signal %+v", s)
+	}
+	if s = spamSignalAutomation(pubkeyAutomation(padHex(51911))); s.Score != 0 {
+		t.Errorf("expected no spam weight for an unsampled pubkey, got %+v", s)
+	}
+}
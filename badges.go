@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// badgeDefinitionKind and badgeAwardKind are the NIP-58 badge kinds.
+	badgeDefinitionKind = 30009
+	badgeAwardKind      = 8
+	// badgeMaxAwardsPerRebuild caps the awards published after one rebuild; the
+	// rest follow after the next ones.
+	badgeMaxAwardsPerRebuild = 500
+	// badgeEstablishedMinScore and badgeEstablishedMinAge are what the
+	// Established Account badge asks for.
+	badgeEstablishedMinScore = 20
+	badgeEstablishedMinAge   = 365 * 24 * time.Hour
+)
+
+// BadgeTier is a trust tier published as a NIP-58 badge.
+type BadgeTier struct {
+	ID          string `json:"id"` // the badge definition's d tag
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+var badgeTiers = []BadgeTier{
+	{ID: "wot-top-1", Name: "WoT Top 1%", Description: "Among the top 1% of accounts by web-of-trust score."},
+	{ID: "wot-established", Name: "Established Account", Description: fmt.Sprintf(
+		"Active for over a year with a web-of-trust score of %d or more out of 100.", badgeEstablishedMinScore)},
+}
+
+// BadgeAward is a badge awarded to a pubkey, by the kind 8 event EventID.
+type BadgeAward struct {
+	Tier      string `json:"tier"`
+	Pubkey    string `json:"pubkey"`
+	EventID   string `json:"event_id"`
+	AwardedAt int64  `json:"awarded_at"`
+}
+
+// BadgeRun summarizes one publishing pass.
+type BadgeRun struct {
+	At      time.Time      `json:"at"`
+	Holders map[string]int `json:"holders"` // tier -> pubkeys qualifying
+	Awarded int            `json:"awarded"`
+	Revoked int            `json:"revoked"`
+	Pending int            `json:"pending"` // qualifying but over the per-rebuild cap
+	Failed  int            `json:"failed"`
+}
+
+// BadgeIssuer publishes NIP-58 badges for the trust tiers from the service key,
+// so clients can show a tier without calling the API. Each holder gets one kind 8
+// award that stands while they stay in the tier; when they drop out it is revoked
+// with a NIP-09 deletion. With a path set, the awards are saved there as JSON, so
+// a restart neither awards everyone again nor forgets what to revoke.
+type BadgeIssuer struct {
+	mu     sync.Mutex
+	path   string
+	awards map[string]map[string]BadgeAward // tier -> pubkey -> award
+	last   *BadgeRun
+}
+
+func NewBadgeIssuer(path string) *BadgeIssuer {
+	return &BadgeIssuer{path: path, awards: make(map[string]map[string]BadgeAward)}
+}
+
+// badgeIssuer is configured with BADGES_FILE, e.g. /var/lib/wot-scoring/badges.json.
+var badgeIssuer = NewBadgeIssuer(os.Getenv("BADGES_FILE"))
+
+type badgesFile struct {
+	Awards []BadgeAward `json:"awards"`
+}
+
+// Load reads the saved awards. A missing file is not an error.
+func (b *BadgeIssuer) Load() error {
+	if b.path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var f badgesFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return fmt.Errorf("%s: %w", b.path, err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, a := range f.Awards {
+		b.holdersOf(a.Tier)[a.Pubkey] = a
+	}
+	return nil
+}
+
+// save writes the awards out. Callers hold b.mu.
+func (b *BadgeIssuer) save() error {
+	if b.path == "" {
+		return nil
+	}
+	var f badgesFile
+	for _, held := range b.awards {
+		for _, a := range held {
+			f.Awards = append(f.Awards, a)
+		}
+	}
+	sort.Slice(f.Awards, func(i, j int) bool {
+		if f.Awards[i].Tier != f.Awards[j].Tier {
+			return f.Awards[i].Tier < f.Awards[j].Tier
+		}
+		return f.Awards[i].Pubkey < f.Awards[j].Pubkey
+	})
+	raw, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(b.path, raw)
+}
+
+// holdersOf returns tier's awards, creating the map. Callers hold b.mu.
+func (b *BadgeIssuer) holdersOf(tier string) map[string]BadgeAward {
+	held, ok := b.awards[tier]
+	if !ok {
+		held = make(map[string]BadgeAward)
+		b.awards[tier] = held
+	}
+	return held
+}
+
+// badgeHolders returns the pubkeys in each tier, highest-scored first. The top 1%
+// is at least one pubkey; an established account has a normalized score of at
+// least badgeEstablishedMinScore and a first known event over a year before now.
+func badgeHolders(g *Graph, ms *MetaStore, now time.Time) map[string][]string {
+	entries := g.TopN(0)
+	top := len(entries) / 100
+	if top == 0 && len(entries) > 0 {
+		top = 1
+	}
+	holders := map[string][]string{"wot-top-1": nil, "wot-established": nil}
+	for i, e := range entries {
+		if i < top {
+			holders["wot-top-1"] = append(holders["wot-top-1"], e.Pubkey)
+		}
+		if g.NormalizeScore(e.Score, "") < badgeEstablishedMinScore {
+			continue
+		}
+		if first := ms.FirstCreated(e.Pubkey); first > 0 && now.Sub(time.Unix(first, 0)) >= badgeEstablishedMinAge {
+			holders["wot-established"] = append(holders["wot-established"], e.Pubkey)
+		}
+	}
+	return holders
+}
+
+// badgeAddress is the a tag of tier's badge definition by pub.
+func badgeAddress(pub string, tier BadgeTier) string {
+	return fmt.Sprintf("%d:%s:%s", badgeDefinitionKind, pub, tier.ID)
+}
+
+// buildBadgeDefinition builds the unsigned kind 30009 definition of tier.
+func buildBadgeDefinition(pub string, tier BadgeTier) nostr.Event {
+	return nostr.Event{
+		PubKey:    pub,
+		CreatedAt: nostr.Now(),
+		Kind:      badgeDefinitionKind,
+		Tags: nostr.Tags{
+			{"d", tier.ID},
+			{"name", tier.Name},
+			{"description", tier.Description + " Re-evaluated each rebuild; the award is deleted when the account drops out."},
+		},
+	}
+}
+
+// buildBadgeAward builds the unsigned kind 8 award of tier to pubkey.
+func buildBadgeAward(pub string, tier BadgeTier, pubkey string) nostr.Event {
+	return nostr.Event{
+		PubKey:    pub,
+		CreatedAt: nostr.Now(),
+		Kind:      badgeAwardKind,
+		Tags:      nostr.Tags{{"a", badgeAddress(pub, tier)}, {"p", pubkey}},
+	}
+}
+
+// buildBadgeRevocation builds the unsigned NIP-09 deletion of award.
+func buildBadgeRevocation(pub string, tier BadgeTier, award BadgeAward) nostr.Event {
+	return nostr.Event{
+		PubKey:    pub,
+		CreatedAt: nostr.Now(),
+		Kind:      5,
+		Tags:      nostr.Tags{{"e", award.EventID}, {"k", fmt.Sprintf("%d", badgeAwardKind)}},
+		Content:   fmt.Sprintf("No longer in the %s tier.", tier.Name),
+	}
+}
+
+// Publish signs and queues the badge definitions, an award for each new tier
+// holder (at most badgeMaxAwardsPerRebuild, highest-scored first) and a deletion
+// of each award whose holder dropped out of its tier. It returns how many events
+// were queued.
+func (b *BadgeIssuer) Publish(ctx context.Context, signer EventSigner, cfg Config) (int, error) {
+	if !cfg.PublishBadges {
+		return 0, nil
+	}
+	now := time.Now()
+	run := &BadgeRun{At: now, Holders: make(map[string]int)}
+	holders := badgeHolders(graph, meta, now)
+	if len(holders["wot-top-1"]) == 0 {
+		return 0, nil // nothing scored yet; an empty graph mustn't revoke every award
+	}
+	pub := signer.PublicKey()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var batch []nostr.Event
+	for _, tier := range badgeTiers {
+		if ctx.Err() != nil {
+			break
+		}
+		def := buildBadgeDefinition(pub, tier)
+		if err := signer.Sign(ctx, &def); err != nil {
+			log.Printf("Failed to sign badge definition %s: %v", tier.ID, err)
+			run.Failed++
+			continue
+		}
+		batch = append(batch, def)
+
+		held := b.holdersOf(tier.ID)
+		qualified := make(map[string]bool, len(holders[tier.ID]))
+		run.Holders[tier.ID] = len(holders[tier.ID])
+		for _, pk := range holders[tier.ID] {
+			qualified[pk] = true
+			if _, ok := held[pk]; ok {
+				continue
+			}
+			if run.Awarded == badgeMaxAwardsPerRebuild {
+				run.Pending++
+				continue
+			}
+			ev := buildBadgeAward(pub, tier, pk)
+			if err := signer.Sign(ctx, &ev); err != nil {
+				log.Printf("Failed to sign %s badge award for %s: %v", tier.ID, shortKey(pk), err)
+				run.Failed++
+				continue
+			}
+			held[pk] = BadgeAward{Tier: tier.ID, Pubkey: pk, EventID: ev.ID, AwardedAt: now.Unix()}
+			batch = append(batch, ev)
+			run.Awarded++
+		}
+		for pk, award := range held {
+			if qualified[pk] {
+				continue
+			}
+			ev := buildBadgeRevocation(pub, tier, award)
+			if err := signer.Sign(ctx, &ev); err != nil {
+				log.Printf("Failed to sign %s badge revocation for %s: %v", tier.ID, shortKey(pk), err)
+				run.Failed++
+				continue
+			}
+			delete(held, pk)
+			batch = append(batch, ev)
+			run.Revoked++
+		}
+	}
+	queued := publishQueue.Enqueue(batch, config.Relays())
+	b.last = run
+	if err := b.save(); err != nil {
+		log.Printf("Badge awards save failed: %v", err)
+	}
+
+	log.Printf("Queued %d NIP-58 badge events (%d awarded, %d revoked, %d pending, %d failed)",
+		queued, run.Awarded, run.Revoked, run.Pending, run.Failed)
+	return queued, ctx.Err()
+}
+
+// Stats summarizes the tiers and awards for /stats.
+func (b *BadgeIssuer) Stats(cfg Config) map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	awarded := make(map[string]int, len(badgeTiers))
+	for _, tier := range badgeTiers {
+		awarded[tier.ID] = len(b.awards[tier.ID])
+	}
+	stats := map[string]interface{}{
+		"enabled": cfg.PublishBadges,
+		"tiers":   badgeTiers,
+		"awarded": awarded,
+	}
+	if b.last != nil {
+		stats["last_run"] = *b.last
+	}
+	return stats
+}
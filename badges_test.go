@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// setupBadges installs a 100-pubkey graph whose top pubkey is the returned star,
+// followed by everyone else, with star's first event two years old, and a badge
+// issuer saving to a temp file.
+func setupBadges(t *testing.T) (star string, path string) {
+	oldGraph, oldMeta, oldQueue, oldIssuer := graph, meta, publishQueue, badgeIssuer
+	t.Cleanup(func() {
+		graph, meta, publishQueue, badgeIssuer = oldGraph, oldMeta, oldQueue, oldIssuer
+	})
+	star = padHex(51400)
+	graph, meta = NewGraph(), NewMetaStore()
+	for i := 1; i < 100; i++ {
+		graph.AddFollow(padHex(51400+i), star)
+		graph.AddFollow(star, padHex(51400+i))
+	}
+	graph.ComputePageRank(20, 0.85)
+	meta.Get(star).FirstCreated = time.Now().Add(-2 * 365 * 24 * time.Hour).Unix()
+	meta.Get(padHex(51401)).FirstCreated = time.Now().Add(-2 * 365 * 24 * time.Hour).Unix()
+
+	path = filepath.Join(t.TempDir(), "badges.json")
+	publishQueue, badgeIssuer = NewPublishQueue(""), NewBadgeIssuer(path)
+	return star, path
+}
+
+// queuedBadgeEvents returns the queued events by kind and empties the queue.
+func queuedBadgeEvents() map[int][]nostr.Event {
+	byKind := make(map[int][]nostr.Event)
+	for _, item := range publishQueue.items {
+		byKind[item.Event.Kind] = append(byKind[item.Event.Kind], item.Event)
+	}
+	publishQueue = NewPublishQueue("")
+	return byKind
+}
+
+func TestBadgeHolders(t *testing.T) {
+	star, _ := setupBadges(t)
+
+	holders := badgeHolders(graph, meta, time.Now())
+	if top := holders["wot-top-1"]; len(top) != 1 || top[0] != star {
+		t.Errorf("expected only star in the top 1%%, got %v", top)
+	}
+	// 51401 is old enough but scores too low
+	if est := holders["wot-established"]; len(est) != 1 || est[0] != star {
+		t.Errorf("expected only star established, got %v", est)
+	}
+}
+
+func TestBadgeIssuerPublish(t *testing.T) {
+	star, path := setupBadges(t)
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	signer := keySigner{sk: sk, pub: pub}
+	cfg := defaultConfig
+
+	if n, err := badgeIssuer.Publish(context.Background(), signer, cfg); n != 0 || err != nil {
+		t.Errorf("expected nothing published while disabled, got %d %v", n, err)
+	}
+
+	cfg.PublishBadges = true
+	n, err := badgeIssuer.Publish(context.Background(), signer, cfg)
+	if err != nil || n != 4 {
+		t.Fatalf("expected two definitions and two awards, got %d %v", n, err)
+	}
+	queued := queuedBadgeEvents()
+	if defs := queued[badgeDefinitionKind]; len(defs) != 2 || defs[0].Tags.GetFirst([]string{"name"}) == nil {
+		t.Errorf("expected the two badge definitions, got %+v", defs)
+	}
+	awards := queued[badgeAwardKind]
+	if len(awards) != 2 {
+		t.Fatalf("expected two awards, got %+v", awards)
+	}
+	var topAward nostr.Event
+	for _, ev := range awards {
+		if ok, err := ev.CheckSignature(); !ok || err != nil || ev.Tags.GetFirst([]string{"p", star}) == nil {
+			t.Errorf("expected a signed award to star, got %+v", ev)
+		}
+		if a := ev.Tags.GetFirst([]string{"a"}); a != nil && a.Value() == "30009:"+pub+":wot-top-1" {
+			topAward = ev
+		}
+	}
+	if topAward.ID == "" {
+		t.Fatalf("expected a wot-top-1 award, got %+v", awards)
+	}
+
+	// the next rebuild, or a restart with the saved awards, awards nobody again
+	if n, _ := badgeIssuer.Publish(context.Background(), signer, cfg); n != 2 {
+		t.Errorf("expected only the definitions refreshed, got %d events", n)
+	}
+	queuedBadgeEvents()
+	badgeIssuer = NewBadgeIssuer(path)
+	if err := badgeIssuer.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := badgeIssuer.Publish(context.Background(), signer, cfg); n != 2 {
+		t.Errorf("expected the saved awards kept after a restart, got %d events", n)
+	}
+	queuedBadgeEvents()
+
+	// an empty graph revokes nothing
+	graph = NewGraph()
+	if n, _ := badgeIssuer.Publish(context.Background(), signer, cfg); n != 0 {
+		t.Errorf("expected nothing published for an empty graph, got %d", n)
+	}
+
+	// star drops out when another pubkey takes the lead
+	graph = NewGraph()
+	for i := 0; i < 100; i++ {
+		if i != 1 {
+			graph.AddFollow(padHex(51400+i), padHex(51401))
+			graph.AddFollow(padHex(51401), padHex(51400+i))
+		}
+	}
+	graph.ComputePageRank(20, 0.85)
+	n, _ = badgeIssuer.Publish(context.Background(), signer, cfg)
+	queued = queuedBadgeEvents()
+	revocations := queued[5]
+	if n != 6 || len(revocations) != 2 || len(queued[badgeAwardKind]) != 2 {
+		t.Fatalf("expected star's awards revoked and the new leader's issued, got %d events: %+v", n, queued)
+	}
+	revoked := false
+	for _, ev := range revocations {
+		if ev.Tags.GetFirst([]string{"e", topAward.ID}) != nil && ev.Tags.GetFirst([]string{"k", "8"}) != nil {
+			revoked = true
+		}
+	}
+	if !revoked {
+		t.Errorf("expected a deletion of star's top 1%% award, got %+v", revocations)
+	}
+	stats := badgeIssuer.Stats(cfg)
+	if awarded := stats["awarded"].(map[string]int); awarded["wot-top-1"] != 1 || awarded["wot-established"] != 1 {
+		t.Errorf("expected one holder per tier, got %v", awarded)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	auditBatchMax        = 50
+	personalizedBatchMax = 100
+	graphBatchMax        = 25
+)
+
+// AuditBatchResponse is the POST /audit/batch body, in request order.
+type AuditBatchResponse struct {
+	Results   []AuditBatchResult `json:"results"`
+	Count     int                `json:"count"`
+	GraphSize int                `json:"graph_size"`
+}
+
+// AuditBatchResult is one /audit/batch result: the /audit breakdown, or the
+// input as pubkey with error when it can't be read.
+type AuditBatchResult struct {
+	Pubkey string `json:"pubkey"`
+	*AuditResponse
+	Error string `json:"error,omitempty"`
+}
+
+// PersonalizedBatchResponse is the POST /personalized/batch body, in request order.
+type PersonalizedBatchResponse struct {
+	Viewer    string                    `json:"viewer"`
+	Algorithm string                    `json:"algorithm"`
+	Results   []PersonalizedBatchResult `json:"results"`
+	Count     int                       `json:"count"`
+	GraphSize int                       `json:"graph_size"`
+}
+
+// PersonalizedBatchResult is one /personalized/batch result: the /personalized
+// fields that vary by target, or the input as target with error when it can't
+// be read.
+type PersonalizedBatchResult struct {
+	Target string `json:"target"`
+	*PersonalizedResponse
+	Error string `json:"error,omitempty"`
+}
+
+// GraphBatchResponse is the POST /graph/batch body, in request order.
+type GraphBatchResponse struct {
+	Results   []GraphBatchResult `json:"results"`
+	Count     int                `json:"count"`
+	Depth     int                `json:"depth"`
+	Limit     int                `json:"limit"`
+	GraphSize int                `json:"graph_size"`
+}
+
+// GraphBatchResult is one /graph/batch neighborhood, or the input as pubkey
+// with error when it can't be read.
+type GraphBatchResult struct {
+	Pubkey string `json:"pubkey"`
+	*GraphNeighborhood
+	Error string `json:"error,omitempty"`
+}
+
+// handleAuditBatch serves POST /audit/batch with {"pubkeys": [...], "normalization": ""}:
+// the /audit breakdown of up to 50 pubkeys, in request order.
+func handleAuditBatch(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req struct {
+		Pubkeys       []string `json:"pubkeys"`
+		Normalization string   `json:"normalization"`
+	}
+	if apiErr := bindJSON(w, r, &req, 1<<20); apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	if len(req.Pubkeys) == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "pubkeys", Message: "pubkeys array required"})
+		return
+	}
+	if len(req.Pubkeys) > auditBatchMax {
+		writeAPIError(w, invalidParam("pubkeys", "max %d pubkeys per request", auditBatchMax))
+		return
+	}
+	curve := req.Normalization
+	if curve == "" {
+		curve = config.Get().Normalization
+	}
+	if _, ok := normalizationCurves[curve]; !ok {
+		writeAPIError(w, invalidParam("normalization", "normalization must be log, percentile, zscore or minmax"))
+		return
+	}
+
+	results := make([]AuditBatchResult, 0, len(req.Pubkeys))
+	for _, raw := range req.Pubkeys {
+		pubkey, err := resolvePubkey(raw)
+		if err != nil {
+			results = append(results, AuditBatchResult{Pubkey: raw, Error: err.Error()})
+			continue
+		}
+		audit := auditPubkey(g, pubkey, curve)
+		results = append(results, AuditBatchResult{Pubkey: pubkey, AuditResponse: &audit})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuditBatchResponse{
+		Results:   results,
+		Count:     len(results),
+		GraphSize: g.NodeCount(),
+	})
+}
+
+// handlePersonalizedBatch serves POST /personalized/batch with
+// {"viewer": "", "targets": [...], "algorithm": "blend"}: how up to 100 targets
+// look from one viewer, in request order. A feed client scores a page of authors
+// with one request instead of one per author.
+func handlePersonalizedBatch(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req struct {
+		Viewer    string   `json:"viewer"`
+		Targets   []string `json:"targets"`
+		Algorithm string   `json:"algorithm"`
+	}
+	if apiErr := bindJSON(w, r, &req, 1<<20); apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	if req.Viewer == "" {
+		writeAPIError(w, missingParam("viewer"))
+		return
+	}
+	if len(req.Targets) == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "targets", Message: "targets array required"})
+		return
+	}
+	if len(req.Targets) > personalizedBatchMax {
+		writeAPIError(w, invalidParam("targets", "max %d targets per request", personalizedBatchMax))
+		return
+	}
+	if req.Algorithm == "" {
+		req.Algorithm = "blend"
+	}
+	if req.Algorithm != "blend" && req.Algorithm != "ppr" {
+		writeAPIError(w, invalidParam("algorithm", "algorithm must be blend or ppr"))
+		return
+	}
+	viewer, err := resolvePubkey(req.Viewer)
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid viewer: %s", err.Error())})
+		return
+	}
+
+	results := make([]PersonalizedBatchResult, 0, len(req.Targets))
+	for _, raw := range req.Targets {
+		target, err := resolvePubkey(raw)
+		if err != nil {
+			results = append(results, PersonalizedBatchResult{Target: raw, Error: err.Error()})
+			continue
+		}
+		entry := personalizeScore(g, viewer, target, req.Algorithm)
+		// viewer, algorithm and graph_size are the same for every target
+		entry.Viewer, entry.Algorithm, entry.GraphSize = "", "", 0
+		results = append(results, PersonalizedBatchResult{Target: target, PersonalizedResponse: &entry})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PersonalizedBatchResponse{
+		Viewer:    viewer,
+		Algorithm: req.Algorithm,
+		Results:   results,
+		Count:     len(results),
+		GraphSize: g.NodeCount(),
+	})
+}
+
+// handleGraphBatch serves POST /graph/batch with {"pubkeys": [...], "depth": 1, "limit": 50}:
+// the /graph neighborhood of up to 25 pubkeys, in request order. depth (1-2) and
+// limit (1-200) are clamped as in /graph.
+func handleGraphBatch(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req struct {
+		Pubkeys []string `json:"pubkeys"`
+		Depth   int      `json:"depth"`
+		Limit   int      `json:"limit"`
+	}
+	if apiErr := bindJSON(w, r, &req, 1<<20); apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	if len(req.Pubkeys) == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "pubkeys", Message: "pubkeys array required"})
+		return
+	}
+	if len(req.Pubkeys) > graphBatchMax {
+		writeAPIError(w, invalidParam("pubkeys", "max %d pubkeys per request", graphBatchMax))
+		return
+	}
+	depth := min(max(req.Depth, 1), 2)
+	limit := req.Limit
+	if limit < 1 {
+		limit = 50
+	}
+	limit = min(limit, 200)
+
+	results := make([]GraphBatchResult, 0, len(req.Pubkeys))
+	for _, raw := range req.Pubkeys {
+		pubkey, err := resolvePubkey(raw)
+		if err != nil {
+			results = append(results, GraphBatchResult{Pubkey: raw, Error: err.Error()})
+			continue
+		}
+		entry := graphNeighborhood(g, pubkey, depth, limit)
+		// depth and graph_size are the same for every pubkey
+		entry.Depth, entry.GraphSize = 0, 0
+		results = append(results, GraphBatchResult{Pubkey: pubkey, GraphNeighborhood: &entry})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(GraphBatchResponse{
+		Results:   results,
+		Count:     len(results),
+		Depth:     depth,
+		Limit:     limit,
+		GraphSize: g.NodeCount(),
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func batchLookupGraph() *Graph {
+	g := NewGraph()
+	g.AddFollow(padHex(1), padHex(2))
+	g.AddFollow(padHex(1), padHex(3))
+	g.AddFollow(padHex(2), padHex(3))
+	g.AddFollow(padHex(3), padHex(1))
+	g.AddFollow(padHex(4), padHex(3))
+	g.ComputePageRank(20, 0.85)
+	return g
+}
+
+type batchLookupResponse struct {
+	Results []map[string]interface{} `json:"results"`
+	Count   int                      `json:"count"`
+}
+
+func postBatch(t *testing.T, h http.HandlerFunc, path, body string) (int, batchLookupResponse) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	h(rr, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body)))
+	var resp batchLookupResponse
+	if rr.Code == http.StatusOK {
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+	return rr.Code, resp
+}
+
+func TestAuditBatchMatchesAudit(t *testing.T) {
+	oldGraph, oldMeta := graph, meta
+	defer func() { graph, meta = oldGraph, oldMeta }()
+	graph, meta = batchLookupGraph(), NewMetaStore()
+
+	code, resp := postBatch(t, handleAuditBatch, "/audit/batch", `{"pubkeys":["`+padHex(3)+`","npub1bad","`+padHex(1)+`"]}`)
+	if code != http.StatusOK || resp.Count != 3 {
+		t.Fatalf("expected 3 results, got %d %+v", code, resp)
+	}
+	if resp.Results[1]["error"] == nil || resp.Results[1]["pubkey"] != "npub1bad" {
+		t.Errorf("expected an error entry for an invalid pubkey, got %v", resp.Results[1])
+	}
+
+	rr := httptest.NewRecorder()
+	handleAudit(rr, httptest.NewRequest(http.MethodGet, "/audit?pubkey="+padHex(3), nil))
+	var single map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &single)
+	got, _ := json.Marshal(resp.Results[0]["pagerank"])
+	want, _ := json.Marshal(single["pagerank"])
+	if string(got) != string(want) {
+		t.Errorf("batch audit pagerank %s, want %s", got, want)
+	}
+	if resp.Results[2]["pubkey"] != padHex(1) {
+		t.Errorf("expected results in request order, got %v", resp.Results[2]["pubkey"])
+	}
+}
+
+func TestPersonalizedBatch(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = batchLookupGraph()
+
+	code, resp := postBatch(t, handlePersonalizedBatch, "/personalized/batch",
+		`{"viewer":"`+padHex(1)+`","targets":["`+padHex(2)+`","`+padHex(4)+`"]}`)
+	if code != http.StatusOK || resp.Count != 2 {
+		t.Fatalf("expected 2 results, got %d %+v", code, resp)
+	}
+	if resp.Results[0]["viewer_follows_target"] != true || resp.Results[1]["viewer_follows_target"] != false {
+		t.Errorf("expected the viewer to follow only the first target, got %v", resp.Results)
+	}
+	if _, ok := resp.Results[0]["viewer"]; ok {
+		t.Error("expected viewer given once at the top, not per result")
+	}
+
+	rr := httptest.NewRecorder()
+	handlePersonalized(rr, httptest.NewRequest(http.MethodGet, "/personalized?viewer="+padHex(1)+"&target="+padHex(2), nil))
+	var single map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &single)
+	if single["personalized_score"] != resp.Results[0]["personalized_score"] {
+		t.Errorf("batch score %v, want %v", resp.Results[0]["personalized_score"], single["personalized_score"])
+	}
+}
+
+func TestGraphBatch(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = batchLookupGraph()
+
+	code, resp := postBatch(t, handleGraphBatch, "/graph/batch", `{"pubkeys":["`+padHex(3)+`","`+padHex(4)+`"],"depth":5,"limit":1}`)
+	if code != http.StatusOK || resp.Count != 2 {
+		t.Fatalf("expected 2 results, got %d %+v", code, resp)
+	}
+	if n := len(resp.Results[0]["neighbors"].([]interface{})); n != 1 {
+		t.Errorf("expected limit applied per pubkey, got %d neighbors", n)
+	}
+	if resp.Results[1]["follows_count"].(float64) != 1 {
+		t.Errorf("expected one follow for the second pubkey, got %v", resp.Results[1])
+	}
+}
+
+func TestBatchLookupErrors(t *testing.T) {
+	many := `["` + strings.Repeat(padHex(1)+`","`, 100) + padHex(1) + `"]`
+	for _, tc := range []struct {
+		name string
+		h    http.HandlerFunc
+		body string
+	}{
+		{"audit empty", handleAuditBatch, `{"pubkeys":[]}`},
+		{"audit too many", handleAuditBatch, `{"pubkeys":` + many + `}`},
+		{"audit curve", handleAuditBatch, `{"pubkeys":["` + padHex(1) + `"],"normalization":"cubic"}`},
+		{"personalized no viewer", handlePersonalizedBatch, `{"targets":["` + padHex(1) + `"]}`},
+		{"personalized too many", handlePersonalizedBatch, `{"viewer":"` + padHex(1) + `","targets":` + many + `}`},
+		{"personalized algorithm", handlePersonalizedBatch, `{"viewer":"` + padHex(1) + `","targets":["` + padHex(2) + `"],"algorithm":"hits"}`},
+		{"graph too many", handleGraphBatch, `{"pubkeys":` + many + `}`},
+		{"graph bad json", handleGraphBatch, `{`},
+	} {
+		if code, _ := postBatch(t, tc.h, "/batch", tc.body); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", tc.name, code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handleGraphBatch(rr, httptest.NewRequest(http.MethodGet, "/graph/batch", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rr.Code)
+	}
+}
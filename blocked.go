@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
 	"sort"
@@ -134,7 +133,7 @@ func consumeMuteLists(ctx context.Context, store *MuteStore) {
 	}
 
 	total := 0
-	for ev := range pool.SubManyEose(ctx, relays, nostr.Filters{filter}) {
+	for ev := range pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter}) {
 		muted := parseMuteList(ev.Event)
 		if len(muted) > 0 {
 			store.Add(ev.Event.PubKey, muted)
@@ -172,7 +171,7 @@ func handleBlocked(w http.ResponseWriter, r *http.Request) {
 	rawTarget := r.URL.Query().Get("target")
 
 	if rawPubkey == "" && rawTarget == "" {
-		http.Error(w, `{"error":"pubkey or target parameter required"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "pubkey or target parameter required"})
 		return
 	}
 
@@ -181,7 +180,7 @@ func handleBlocked(w http.ResponseWriter, r *http.Request) {
 	if rawPubkey != "" {
 		pubkey, err := resolvePubkey(rawPubkey)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: err.Error()})
 			return
 		}
 
@@ -200,7 +199,7 @@ func handleBlocked(w http.ResponseWriter, r *http.Request) {
 
 	target, err := resolvePubkey(rawTarget)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: err.Error()})
 		return
 	}
 
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const (
+	bloomDefaultDepth = 2
+	bloomMaxDepth     = 3
+	bloomDefaultFPR   = 0.01
+	bloomMinFPR       = 0.0001
+	bloomMaxFPR       = 0.5
+	// bloomHashScheme names how members map to bits, so clients can check their
+	// implementation matches; see bloomFilter.
+	bloomHashScheme = "sha256-km"
+)
+
+// bloomFilter is a Bloom filter over pubkeys that a client can query offline.
+// A member's k bit positions are (h1 + i*h2) mod m for i in 0..k-1, where h1 and
+// h2 are the first two big-endian uint64s of SHA-256 of the 32-byte pubkey (h2 with
+// its low bit set). Bit j is bit j%8 (least significant first) of byte j/8. Hashing
+// the pubkey, rather than using its bytes, keeps vanity prefixes from skewing bits.
+type bloomFilter struct {
+	bits []byte
+	m    uint64 // bit count, a multiple of 8
+	k    int
+}
+
+// newBloomFilter sizes a filter for n members at false positive rate fpr.
+func newBloomFilter(n int, fpr float64) *bloomFilter {
+	m := uint64(8)
+	k := 1
+	if n > 0 {
+		m = uint64(math.Ceil(-float64(n) * math.Log(fpr) / (math.Ln2 * math.Ln2)))
+		m = (m + 7) / 8 * 8
+		k = max(1, int(math.Round(float64(m)/float64(n)*math.Ln2)))
+	}
+	return &bloomFilter{bits: make([]byte, m/8), m: m, k: k}
+}
+
+func (f *bloomFilter) positions(pubkey string, fn func(bit uint64)) bool {
+	raw, err := hex.DecodeString(pubkey)
+	if err != nil || len(raw) != 32 {
+		return false
+	}
+	sum := sha256.Sum256(raw)
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	for i := 0; i < f.k; i++ {
+		fn((h1 + uint64(i)*h2) % f.m)
+	}
+	return true
+}
+
+func (f *bloomFilter) Add(pubkey string) {
+	f.positions(pubkey, func(bit uint64) { f.bits[bit/8] |= 1 << (bit % 8) })
+}
+
+func (f *bloomFilter) Contains(pubkey string) bool {
+	found := true
+	ok := f.positions(pubkey, func(bit uint64) {
+		if f.bits[bit/8]&(1<<(bit%8)) == 0 {
+			found = false
+		}
+	})
+	return ok && found
+}
+
+// followSet returns the pubkeys within depth follow hops of viewer in g, not
+// counting viewer, that score at least minScore.
+func followSet(g *Graph, viewer string, depth, minScore int) []string {
+	nodes := g.NodeCount()
+	seen := map[string]bool{viewer: true}
+	frontier := []string{viewer}
+	var out []string
+	for d := 0; d < depth && len(frontier) > 0; d++ {
+		var next []string
+		for _, pk := range frontier {
+			for _, f := range g.GetFollows(pk) {
+				if seen[f] {
+					continue
+				}
+				seen[f] = true
+				next = append(next, f)
+				raw, _ := g.GetScore(f)
+				if normalizeScore(raw, nodes) >= minScore {
+					out = append(out, f)
+				}
+			}
+		}
+		frontier = next
+	}
+	return out
+}
+
+// handleExportBloom serves GET /export/bloom?pubkey=&depth=2&fpr=0.01&min_score=:
+// a Bloom filter of the viewer's depth-hop follow set as raw bytes, with its
+// parameters in X-Bloom-* headers.
+func handleExportBloom(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
+	if g.NodeCount() == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "graph not built yet"})
+		return
+	}
+	q := r.URL.Query()
+	raw := q.Get("pubkey")
+	if raw == "" {
+		writeAPIError(w, missingParam("pubkey"))
+		return
+	}
+	viewer, err := resolvePubkey(raw)
+	if err != nil || !hex64Pattern.MatchString(viewer) {
+		writeAPIError(w, invalidParam("pubkey", "pubkey must be 64 hex characters or an npub"))
+		return
+	}
+	depth := bloomDefaultDepth
+	if v := q.Get("depth"); v != "" {
+		depth, err = strconv.Atoi(v)
+		if err != nil || depth < 1 || depth > bloomMaxDepth {
+			writeAPIError(w, invalidParam("depth", "depth must be between 1 and %d", bloomMaxDepth))
+			return
+		}
+	}
+	fpr := bloomDefaultFPR
+	if v := q.Get("fpr"); v != "" {
+		fpr, err = strconv.ParseFloat(v, 64)
+		if err != nil || fpr < bloomMinFPR || fpr > bloomMaxFPR {
+			writeAPIError(w, invalidParam("fpr", "fpr must be between %g and %g", bloomMinFPR, bloomMaxFPR))
+			return
+		}
+	}
+	minScore := 0
+	if v := q.Get("min_score"); v != "" {
+		minScore, err = strconv.Atoi(v)
+		if err != nil || minScore < 0 || minScore > 100 {
+			writeAPIError(w, invalidParam("min_score", "min_score must be between 0 and 100"))
+			return
+		}
+	}
+
+	members := followSet(g, viewer, depth, minScore)
+	f := newBloomFilter(len(members), fpr)
+	for _, pk := range members {
+		f.Add(pk)
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "application/octet-stream")
+	h.Set("Content-Length", strconv.Itoa(len(f.bits)))
+	h.Set("X-Bloom-Bits", strconv.FormatUint(f.m, 10))
+	h.Set("X-Bloom-Hashes", strconv.Itoa(f.k))
+	h.Set("X-Bloom-Count", strconv.Itoa(len(members)))
+	h.Set("X-Bloom-FPR", strconv.FormatFloat(fpr, 'g', -1, 64))
+	h.Set("X-Bloom-Hash", bloomHashScheme)
+	h.Set("X-Bloom-Depth", strconv.Itoa(depth))
+	h.Set("X-Bloom-Min-Score", strconv.Itoa(minScore))
+	w.Write(f.bits)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestBloomFilterFalsePositiveRate(t *testing.T) {
+	f := newBloomFilter(2000, 0.01)
+	for i := 0; i < 2000; i++ {
+		f.Add(padHex(51000 + i))
+	}
+	for i := 0; i < 2000; i++ {
+		if !f.Contains(padHex(51000 + i)) {
+			t.Fatalf("member %d missing", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 20000; i++ {
+		if f.Contains(padHex(60000 + i)) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 20000; rate > 0.02 {
+		t.Errorf("false positive rate %.4f, expected about 0.01", rate)
+	}
+	if f.m%8 != 0 || f.k != 7 {
+		t.Errorf("expected m a multiple of 8 and k 7 at 1%%, got m=%d k=%d", f.m, f.k)
+	}
+	if f.Contains("not-a-pubkey") {
+		t.Error("expected a malformed pubkey never contained")
+	}
+	if empty := newBloomFilter(0, 0.01); len(empty.bits) != 1 || empty.Contains(padHex(1)) {
+		t.Errorf("expected a one-byte empty filter, got %d bytes", len(empty.bits))
+	}
+}
+
+// bloomTestGraph: viewer follows a and b, a follows c, c follows d. Plenty of
+// accounts follow b so it outscores the rest.
+func bloomTestGraph() (g *Graph, viewer, a, b, c, d string) {
+	g = NewGraph()
+	viewer, a, b, c, d = padHex(52000), padHex(52001), padHex(52002), padHex(52003), padHex(52004)
+	g.AddFollow(viewer, a)
+	g.AddFollow(viewer, b)
+	g.AddFollow(a, c)
+	g.AddFollow(a, viewer)
+	g.AddFollow(c, d)
+	for i := 0; i < 20; i++ {
+		g.AddFollow(padHex(52100+i), b)
+	}
+	g.ComputePageRank(30, 0.85)
+	return
+}
+
+func TestFollowSet(t *testing.T) {
+	g, viewer, a, b, c, d := bloomTestGraph()
+	set := func(depth, minScore int) map[string]bool {
+		out := make(map[string]bool)
+		for _, pk := range followSet(g, viewer, depth, minScore) {
+			out[pk] = true
+		}
+		return out
+	}
+	if s := set(1, 0); len(s) != 2 || !s[a] || !s[b] {
+		t.Errorf("expected the direct follows, got %v", s)
+	}
+	if s := set(2, 0); len(s) != 3 || !s[c] || s[viewer] {
+		t.Errorf("expected 2 hops without the viewer, got %v", s)
+	}
+	if s := set(3, 0); !s[d] {
+		t.Errorf("expected d at 3 hops, got %v", s)
+	}
+	raw, _ := g.GetScore(b)
+	if s := set(3, normalizeScore(raw, g.NodeCount())); len(s) != 1 || !s[b] {
+		t.Errorf("expected only the top scorer above its own score, got %v", s)
+	}
+}
+
+func TestExportBloomEndpoint(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	var viewer, c, d string
+	graph, viewer, _, _, c, d = bloomTestGraph()
+
+	rr := httptest.NewRecorder()
+	handleExportBloom(rr, httptest.NewRequest("GET", "/export/bloom?pubkey="+viewer+"&fpr=0.001", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	h := rr.Header()
+	if h.Get("Content-Type") != "application/octet-stream" || h.Get("X-Bloom-Count") != "3" || h.Get("X-Bloom-Depth") != "2" || h.Get("X-Bloom-Hash") != "sha256-km" {
+		t.Errorf("unexpected headers %v", h)
+	}
+	m, _ := strconv.ParseUint(h.Get("X-Bloom-Bits"), 10, 64)
+	k, _ := strconv.Atoi(h.Get("X-Bloom-Hashes"))
+	if m == 0 || uint64(rr.Body.Len()*8) != m {
+		t.Fatalf("expected %d bits in the body, got %d bytes", m, rr.Body.Len())
+	}
+	// a client rebuilds the filter from the body and headers alone
+	f := &bloomFilter{bits: rr.Body.Bytes(), m: m, k: k}
+	if !f.Contains(c) || f.Contains(d) {
+		t.Error("expected c in and d out of the 2-hop filter")
+	}
+
+	for _, q := range []string{"", "?pubkey=nobody", "?pubkey=" + viewer + "&depth=4", "?pubkey=" + viewer + "&fpr=0.9", "?pubkey=" + viewer + "&min_score=101"} {
+		rr := httptest.NewRecorder()
+		handleExportBloom(rr, httptest.NewRequest("GET", "/export/bloom"+q, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", q, rr.Code)
+		}
+	}
+}
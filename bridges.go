@@ -0,0 +1,409 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const (
+	bridgesDefaultSamples = 64
+	bridgesMaxSamples     = 256
+	bridgesDefaultLimit   = 20
+	bridgesMaxLimit       = 100
+	bridgesMaxConnects    = 5
+)
+
+// BridgeLink is one community a bridge account touches, with how many of the
+// account's neighbors (follows and followers) belong to it.
+type BridgeLink struct {
+	Community int `json:"community"`
+	Neighbors int `json:"neighbors"`
+}
+
+// BridgeEntry is one account on many cross-community shortest paths.
+type BridgeEntry struct {
+	Pubkey      string       `json:"pubkey"`
+	Betweenness float64      `json:"betweenness"`
+	Score       int          `json:"score"`
+	Community   int          `json:"community"` // -1 if unlabeled
+	Connects    []BridgeLink `json:"connects"`
+	BrokerScore int          `json:"broker_score,omitempty"` // scope=graph only
+}
+
+// BridgesResponse is the response for GET /bridges.
+type BridgesResponse struct {
+	Mode             string        `json:"mode"` // pair, global, graph
+	CommunityA       *int          `json:"community_a,omitempty"`
+	CommunityB       *int          `json:"community_b,omitempty"`
+	Bridges          []BridgeEntry `json:"bridges"`
+	SampledSources   int           `json:"sampled_sources"`
+	CandidateSources int           `json:"candidate_sources"`
+	Exact            bool          `json:"exact"`
+	GraphSize        int           `json:"graph_size"`
+}
+
+// bridgeIndex is the follow graph as an undirected, deduplicated adjacency list over
+// integer node ids, matching how community detection treats edges.
+type bridgeIndex struct {
+	pubkeys []string
+	labels  []int // -1 if unlabeled
+	adj     [][]int
+}
+
+func newBridgeIndex(g *Graph, labels map[string]int) *bridgeIndex {
+	follows, followers := g.FollowsSnapshot()
+	ids := make(map[string]int)
+	idx := &bridgeIndex{}
+	id := func(pk string) int {
+		if i, ok := ids[pk]; ok {
+			return i
+		}
+		i := len(idx.pubkeys)
+		ids[pk] = i
+		idx.pubkeys = append(idx.pubkeys, pk)
+		l, ok := labels[pk]
+		if !ok {
+			l = -1
+		}
+		idx.labels = append(idx.labels, l)
+		idx.adj = append(idx.adj, nil)
+		return i
+	}
+	for from, tos := range follows {
+		f := id(from)
+		for _, to := range tos {
+			t := id(to)
+			idx.adj[f] = append(idx.adj[f], t)
+			idx.adj[t] = append(idx.adj[t], f)
+		}
+	}
+	for to := range followers {
+		id(to)
+	}
+	for i, ns := range idx.adj {
+		sort.Ints(ns)
+		out := ns[:0]
+		for j, n := range ns {
+			if n != i && (j == 0 || n != ns[j-1]) {
+				out = append(out, n)
+			}
+		}
+		idx.adj[i] = out
+	}
+	return idx
+}
+
+// crossBetweenness runs Brandes' algorithm from each source, counting only shortest
+// paths that end at a node isTarget accepts for that source.
+func (idx *bridgeIndex) crossBetweenness(sources []int, isTarget func(src, node int) bool) []float64 {
+	n := len(idx.pubkeys)
+	bc := make([]float64, n)
+	dist := make([]int, n)
+	sigma := make([]float64, n)
+	delta := make([]float64, n)
+	for i := range dist {
+		dist[i] = -1
+	}
+	order := make([]int, 0, n)
+
+	for _, s := range sources {
+		order = order[:0]
+		dist[s], sigma[s] = 0, 1
+		order = append(order, s)
+		for head := 0; head < len(order); head++ {
+			v := order[head]
+			for _, w := range idx.adj[v] {
+				if dist[w] < 0 {
+					dist[w] = dist[v] + 1
+					order = append(order, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+				}
+			}
+		}
+		for i := len(order) - 1; i >= 0; i-- {
+			v := order[i]
+			for _, w := range idx.adj[v] {
+				if dist[w] == dist[v]+1 {
+					t := 0.0
+					if isTarget(s, w) {
+						t = 1
+					}
+					delta[v] += sigma[v] / sigma[w] * (t + delta[w])
+				}
+			}
+			if v != s {
+				bc[v] += delta[v]
+			}
+		}
+		for _, v := range order {
+			dist[v], sigma[v], delta[v] = -1, 0, 0
+		}
+	}
+	return bc
+}
+
+// bridgeCache keeps computed rankings until the graph or its build changes.
+type bridgeCache struct {
+	mu      sync.Mutex
+	graph   *Graph
+	etag    string
+	results map[string]BridgesResponse
+}
+
+var bridgesCache = &bridgeCache{}
+
+func (c *bridgeCache) get(key string) (BridgesResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.graph != graph || c.etag != graphBuild.ETag() {
+		c.graph, c.etag = graph, graphBuild.ETag()
+		c.results = make(map[string]BridgesResponse)
+	}
+	resp, ok := c.results[key]
+	return resp, ok
+}
+
+func (c *bridgeCache) put(key string, resp BridgesResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results != nil {
+		c.results[key] = resp
+	}
+}
+
+// computeBridges ranks accounts by betweenness on shortest paths between different
+// communities: between a and b when pair is set, otherwise between any two. Sources
+// are sampled when there are more candidates than samples, seeded by the build ID so
+// a build always returns the same ranking.
+func computeBridges(a, b int, pair bool, samples int) BridgesResponse {
+	labels := communities.Snapshot()
+	idx := newBridgeIndex(graph, labels)
+
+	resp := BridgesResponse{Mode: "global"}
+	var candidates []int
+	var isTarget func(src, node int) bool
+	if pair {
+		resp.Mode = "pair"
+		resp.CommunityA, resp.CommunityB = &a, &b
+		for i, l := range idx.labels {
+			if l == a || l == b {
+				candidates = append(candidates, i)
+			}
+		}
+		isTarget = func(src, node int) bool {
+			ls, ln := idx.labels[src], idx.labels[node]
+			return (ls == a && ln == b) || (ls == b && ln == a)
+		}
+	} else {
+		for i, l := range idx.labels {
+			if l >= 0 {
+				candidates = append(candidates, i)
+			}
+		}
+		isTarget = func(src, node int) bool {
+			ln := idx.labels[node]
+			return ln >= 0 && ln != idx.labels[src]
+		}
+	}
+
+	resp.CandidateSources = len(candidates)
+	sources := candidates
+	if len(candidates) > samples {
+		buildID, _, _ := graphBuild.Current()
+		rng := rand.New(rand.NewSource(int64(buildID)))
+		sources = make([]int, len(candidates))
+		copy(sources, candidates)
+		sort.Slice(sources, func(i, j int) bool { return idx.pubkeys[sources[i]] < idx.pubkeys[sources[j]] })
+		rng.Shuffle(len(sources), func(i, j int) { sources[i], sources[j] = sources[j], sources[i] })
+		sources = sources[:samples]
+	}
+	resp.SampledSources = len(sources)
+	resp.Exact = len(sources) == len(candidates)
+
+	bc := idx.crossBetweenness(sources, isTarget)
+	scale := 1.0
+	if len(sources) > 0 {
+		scale = float64(len(candidates)) / float64(len(sources))
+	}
+
+	order := make([]int, 0)
+	for i, v := range bc {
+		if v > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.Slice(order, func(i, j int) bool {
+		if bc[order[i]] != bc[order[j]] {
+			return bc[order[i]] > bc[order[j]]
+		}
+		return idx.pubkeys[order[i]] < idx.pubkeys[order[j]]
+	})
+	if len(order) > bridgesMaxLimit {
+		order = order[:bridgesMaxLimit]
+	}
+
+	nodes := graph.Stats().Nodes
+	resp.Bridges = make([]BridgeEntry, 0, len(order))
+	for _, i := range order {
+		counts := make(map[int]int)
+		for _, n := range idx.adj[i] {
+			if l := idx.labels[n]; l >= 0 {
+				counts[l]++
+			}
+		}
+		links := make([]BridgeLink, 0, len(counts))
+		for l, c := range counts {
+			links = append(links, BridgeLink{Community: l, Neighbors: c})
+		}
+		sort.Slice(links, func(x, y int) bool {
+			if links[x].Neighbors != links[y].Neighbors {
+				return links[x].Neighbors > links[y].Neighbors
+			}
+			return links[x].Community < links[y].Community
+		})
+		if len(links) > bridgesMaxConnects {
+			links = links[:bridgesMaxConnects]
+		}
+
+		raw, _ := graph.GetScore(idx.pubkeys[i])
+		resp.Bridges = append(resp.Bridges, BridgeEntry{
+			Pubkey:      idx.pubkeys[i],
+			Betweenness: math.Round(bc[i]*scale*100) / 100,
+			Score:       normalizeScore(raw, nodes),
+			Community:   idx.labels[i],
+			Connects:    links,
+		})
+	}
+	return resp
+}
+
+// graphBrokers lists the top accounts by graph-wide betweenness from the last
+// rebuild, in the /bridges format.
+func graphBrokers(limit int) BridgesResponse {
+	g := graph.Snapshot()
+	labels := communities.Snapshot()
+	sampled, nodes := brokerScores.Sampling()
+	resp := BridgesResponse{
+		Mode:             "graph",
+		Bridges:          []BridgeEntry{},
+		SampledSources:   sampled,
+		CandidateSources: nodes,
+		Exact:            sampled == nodes,
+	}
+	for _, b := range brokerScores.Top(limit) {
+		seen := map[string]bool{b.Pubkey: true}
+		counts := make(map[int]int)
+		for _, n := range append(g.GetFollows(b.Pubkey), g.GetFollowers(b.Pubkey)...) {
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+			if l, ok := labels[n]; ok {
+				counts[l]++
+			}
+		}
+		links := make([]BridgeLink, 0, len(counts))
+		for l, c := range counts {
+			links = append(links, BridgeLink{Community: l, Neighbors: c})
+		}
+		sort.Slice(links, func(x, y int) bool {
+			if links[x].Neighbors != links[y].Neighbors {
+				return links[x].Neighbors > links[y].Neighbors
+			}
+			return links[x].Community < links[y].Community
+		})
+		if len(links) > bridgesMaxConnects {
+			links = links[:bridgesMaxConnects]
+		}
+		community, ok := labels[b.Pubkey]
+		if !ok {
+			community = -1
+		}
+		raw, _ := g.GetScore(b.Pubkey)
+		resp.Bridges = append(resp.Bridges, BridgeEntry{
+			Pubkey:      b.Pubkey,
+			Betweenness: math.Round(b.Betweenness*100) / 100,
+			Score:       normalizeScore(raw, g.Stats().Nodes),
+			Community:   community,
+			Connects:    links,
+			BrokerScore: b.BrokerScore,
+		})
+	}
+	return resp
+}
+
+// handleBridges serves GET /bridges: accounts that sit between communities, either
+// between a and b (?a=<id>&b=<id>) or across all communities. With scope=graph it
+// ranks brokers by betweenness over the whole graph instead, from the last rebuild.
+func handleBridges(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	limit := q.Int("limit", bridgesDefaultLimit, 1, bridgesMaxLimit)
+	samples := q.Int("samples", bridgesDefaultSamples, 1, bridgesMaxSamples)
+	scope := q.OneOf("scope", "communities", "graph")
+	rawA, rawB := q.String("a", false), q.String("b", false)
+	if q.Failed(w) {
+		return
+	}
+
+	var a, b int
+	pair := rawA != "" || rawB != ""
+
+	if scope == "graph" {
+		if pair || r.URL.Query().Get("samples") != "" {
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "scope=graph takes no a, b or samples"})
+			return
+		}
+		resp := graphBrokers(limit)
+		resp.GraphSize = graph.Stats().Nodes
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	if pair {
+		var errA, errB error
+		a, errA = strconv.Atoi(rawA)
+		b, errB = strconv.Atoi(rawB)
+		if errA != nil || errB != nil {
+			writeAPIError(w, invalidParam("a", "a and b must both be community ids"))
+			return
+		}
+		if a == b {
+			writeAPIError(w, invalidParam("b", "a and b must be different communities"))
+			return
+		}
+		found := map[int]bool{}
+		for _, l := range communities.Snapshot() {
+			if l == a || l == b {
+				found[l] = true
+			}
+		}
+		for _, id := range []int{a, b} {
+			if !found[id] {
+				writeAPIError(w, &APIError{Status: http.StatusNotFound, Message: fmt.Sprintf("community %d not found", id)})
+				return
+			}
+		}
+	}
+
+	key := fmt.Sprintf("%v:%d:%d:%d", pair, a, b, samples)
+	resp, ok := bridgesCache.get(key)
+	if !ok {
+		resp = computeBridges(a, b, pair, samples)
+		bridgesCache.put(key, resp)
+	}
+	if len(resp.Bridges) > limit {
+		resp.Bridges = resp.Bridges[:limit]
+	}
+	resp.GraphSize = graph.Stats().Nodes
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
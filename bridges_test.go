@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// buildBridgeGraph creates two 4-cliques (communities 1 and 2) joined through x,
+// and a third clique (community 3) hanging off community 2 through y.
+func buildBridgeGraph() (x, y string) {
+	graph = NewGraph()
+	communities = NewCommunityDetector()
+	mutual := func(a, b string) {
+		graph.AddFollow(a, b)
+		graph.AddFollow(b, a)
+	}
+	clique := func(base, label int) []string {
+		members := make([]string, 4)
+		for i := range members {
+			members[i] = padHex(base + i)
+			communities.labels[members[i]] = label
+		}
+		for i := range members {
+			for j := i + 1; j < len(members); j++ {
+				mutual(members[i], members[j])
+			}
+		}
+		return members
+	}
+	a := clique(27000, 1)
+	b := clique(27100, 2)
+	c := clique(27200, 3)
+
+	x, y = padHex(27900), padHex(27901)
+	communities.labels[x] = 1
+	communities.labels[y] = 3
+	mutual(x, a[0])
+	mutual(x, b[0])
+	mutual(y, b[1])
+	mutual(y, c[0])
+	graph.ComputePageRank(20, 0.85)
+	return x, y
+}
+
+func TestComputeBridgesPair(t *testing.T) {
+	oldGraph, oldCommunities := graph, communities
+	defer func() { graph, communities = oldGraph, oldCommunities }()
+	x, y := buildBridgeGraph()
+
+	resp := computeBridges(1, 2, true, bridgesDefaultSamples)
+	if resp.Mode != "pair" || !resp.Exact || resp.CandidateSources != 9 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if len(resp.Bridges) == 0 || resp.Bridges[0].Pubkey != x {
+		t.Fatalf("expected x as top bridge, got %+v", resp.Bridges)
+	}
+	top := resp.Bridges[0]
+	if top.Community != 1 || len(top.Connects) != 2 {
+		t.Errorf("expected x to connect communities 1 and 2, got %+v", top)
+	}
+	for _, br := range resp.Bridges {
+		if br.Pubkey == y {
+			t.Error("y is not between communities 1 and 2")
+		}
+	}
+}
+
+func TestComputeBridgesGlobal(t *testing.T) {
+	oldGraph, oldCommunities := graph, communities
+	defer func() { graph, communities = oldGraph, oldCommunities }()
+	x, y := buildBridgeGraph()
+
+	resp := computeBridges(0, 0, false, bridgesDefaultSamples)
+	found := map[string]bool{}
+	for _, br := range resp.Bridges {
+		found[br.Pubkey] = true
+	}
+	if resp.Mode != "global" || !found[x] || !found[y] {
+		t.Errorf("expected both x and y as global bridges, got %+v", resp.Bridges)
+	}
+
+	// Sampling a subset of sources still finds bridges and reports the estimate
+	sampled := computeBridges(0, 0, false, 3)
+	if sampled.Exact || sampled.SampledSources != 3 || len(sampled.Bridges) == 0 {
+		t.Errorf("unexpected sampled response %+v", sampled)
+	}
+	if again := computeBridges(0, 0, false, 3); len(again.Bridges) != len(sampled.Bridges) || again.Bridges[0].Pubkey != sampled.Bridges[0].Pubkey || again.Bridges[0].Betweenness != sampled.Bridges[0].Betweenness {
+		t.Error("sampling must be deterministic within a build")
+	}
+}
+
+func TestBridgesEndpoint(t *testing.T) {
+	oldGraph, oldCommunities := graph, communities
+	defer func() { graph, communities = oldGraph, oldCommunities }()
+	x, _ := buildBridgeGraph()
+
+	for _, tc := range []struct {
+		query string
+		code  int
+	}{
+		{"a=1", http.StatusBadRequest},
+		{"a=1&b=1", http.StatusBadRequest},
+		{"a=1&b=99", http.StatusNotFound},
+		{"limit=0", http.StatusBadRequest},
+		{"samples=x", http.StatusBadRequest},
+		{"samples=1000", http.StatusOK}, // capped at the maximum, like every ?limit=
+	} {
+		rr := httptest.NewRecorder()
+		handleBridges(rr, httptest.NewRequest("GET", "/bridges?"+tc.query, nil))
+		if rr.Code != tc.code {
+			t.Errorf("%q: expected %d, got %d", tc.query, tc.code, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handleBridges(rr, httptest.NewRequest("GET", "/bridges?a=1&b=2&limit=1", nil))
+	var resp BridgesResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusOK || len(resp.Bridges) != 1 || resp.Bridges[0].Pubkey != x || *resp.CommunityB != 2 {
+		t.Errorf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+}
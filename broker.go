@@ -0,0 +1,122 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// brokerSamples is the BFS sources sampled for graph-wide betweenness each rebuild.
+const brokerSamples = 256
+
+// BrokerStore holds the last graph-wide betweenness pass: how many shortest paths
+// between any two accounts run through each one, ignoring communities. Accounts
+// with high betweenness are brokers; trust paths between whole regions of the
+// graph depend on them.
+type BrokerStore struct {
+	mu      sync.RWMutex
+	scores  map[string]float64 // only accounts on at least one path
+	max     float64
+	sampled int
+	nodes   int
+}
+
+func NewBrokerStore() *BrokerStore {
+	return &BrokerStore{scores: make(map[string]float64)}
+}
+
+var brokerScores = NewBrokerStore()
+
+// ComputeBrokerScores estimates betweenness over g, with follows as undirected
+// edges as in community detection, by Brandes' algorithm from up to samples
+// sources picked by seed and scaled up to the whole graph.
+func ComputeBrokerScores(g *Graph, samples int, seed int64) (scores map[string]float64, sampled, nodes int) {
+	idx := newBridgeIndex(g, nil)
+	sources := make([]int, len(idx.pubkeys))
+	for i := range sources {
+		sources[i] = i
+	}
+	if len(sources) > samples {
+		sort.Slice(sources, func(i, j int) bool { return idx.pubkeys[sources[i]] < idx.pubkeys[sources[j]] })
+		rand.New(rand.NewSource(seed)).Shuffle(len(sources), func(i, j int) { sources[i], sources[j] = sources[j], sources[i] })
+		sources = sources[:samples]
+	}
+	bc := idx.crossBetweenness(sources, func(int, int) bool { return true })
+	scale := 1.0
+	if len(sources) > 0 {
+		scale = float64(len(idx.pubkeys)) / float64(len(sources))
+	}
+	scores = make(map[string]float64)
+	for i, v := range bc {
+		if v > 0 {
+			scores[idx.pubkeys[i]] = v * scale
+		}
+	}
+	return scores, len(sources), len(idx.pubkeys)
+}
+
+func (s *BrokerStore) Set(scores map[string]float64, sampled, nodes int) {
+	max := 0.0
+	for _, v := range scores {
+		max = math.Max(max, v)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scores, s.max, s.sampled, s.nodes = scores, max, sampled, nodes
+}
+
+// brokerScore maps betweenness onto 0-100 on a log scale against the top broker,
+// since betweenness spans orders of magnitude. Callers hold s.mu.
+func (s *BrokerStore) brokerScore(bc float64) int {
+	if s.max <= 0 || bc <= 0 {
+		return 0
+	}
+	return int(math.Round(100 * math.Log1p(bc) / math.Log1p(s.max)))
+}
+
+// Get returns pubkey's estimated betweenness and broker score; ok is false before
+// the first pass.
+func (s *BrokerStore) Get(pubkey string) (betweenness float64, score int, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.nodes == 0 {
+		return 0, 0, false
+	}
+	bc := s.scores[pubkey]
+	return bc, s.brokerScore(bc), true
+}
+
+// BrokerEntry is one account ranked by graph-wide betweenness.
+type BrokerEntry struct {
+	Pubkey      string
+	Betweenness float64
+	BrokerScore int
+}
+
+// Top returns the n accounts with the highest betweenness, ties by pubkey.
+func (s *BrokerStore) Top(n int) []BrokerEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	top := make([]BrokerEntry, 0, len(s.scores))
+	for pk, bc := range s.scores {
+		top = append(top, BrokerEntry{Pubkey: pk, Betweenness: bc, BrokerScore: s.brokerScore(bc)})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Betweenness != top[j].Betweenness {
+			return top[i].Betweenness > top[j].Betweenness
+		}
+		return top[i].Pubkey < top[j].Pubkey
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// Sampling reports the sources the last pass sampled out of the graph's nodes.
+func (s *BrokerStore) Sampling() (sampled, nodes int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sampled, s.nodes
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// buildBrokerChain links five accounts in a line; c sits on the most paths.
+func buildBrokerChain(t *testing.T) (b, c string) {
+	t.Helper()
+	oldGraph, oldCommunities, oldBrokers := graph, communities, brokerScores
+	t.Cleanup(func() { graph, communities, brokerScores = oldGraph, oldCommunities, oldBrokers })
+	graph, communities, brokerScores = NewGraph(), NewCommunityDetector(), NewBrokerStore()
+	chain := []string{padHex(31001), padHex(31002), padHex(31003), padHex(31004), padHex(31005)}
+	for i := 0; i+1 < len(chain); i++ {
+		graph.AddFollow(chain[i], chain[i+1])
+	}
+	graph.ComputePageRank(20, 0.85)
+	return chain[1], chain[2]
+}
+
+func TestComputeBrokerScores(t *testing.T) {
+	b, c := buildBrokerChain(t)
+
+	if _, _, ok := brokerScores.Get(c); ok {
+		t.Error("expected no broker scores before the first pass")
+	}
+	scores, sampled, nodes := ComputeBrokerScores(graph, brokerSamples, 1)
+	if sampled != 5 || nodes != 5 {
+		t.Fatalf("expected every node sampled, got %d of %d", sampled, nodes)
+	}
+	// both directions of each pair count: c is on 4 pairs, b on 3
+	if scores[c] != 8 || scores[b] != 6 || len(scores) != 3 {
+		t.Errorf("unexpected betweenness %v", scores)
+	}
+	brokerScores.Set(scores, sampled, nodes)
+
+	if bc, score, ok := brokerScores.Get(c); !ok || bc != 8 || score != 100 {
+		t.Errorf("expected c as the top broker, got %v %d %v", bc, score, ok)
+	}
+	if _, score, _ := brokerScores.Get(padHex(31001)); score != 0 {
+		t.Errorf("expected an endpoint to broker nothing, got %d", score)
+	}
+	if top := brokerScores.Top(2); len(top) != 2 || top[0].Pubkey != c || top[1].BrokerScore >= 100 {
+		t.Errorf("unexpected top brokers %+v", top)
+	}
+
+	// sampling scales the estimate to the whole graph and is repeatable per seed
+	sampledScores, n, _ := ComputeBrokerScores(graph, 2, 7)
+	again, _, _ := ComputeBrokerScores(graph, 2, 7)
+	if n != 2 || sampledScores[c] != again[c] {
+		t.Errorf("expected a repeatable 2-source sample, got %v and %v", sampledScores, again)
+	}
+}
+
+func TestBridgesGraphScopeAndAudit(t *testing.T) {
+	_, c := buildBrokerChain(t)
+	brokerScores.Set(ComputeBrokerScores(graph, brokerSamples, 1))
+
+	w := httptest.NewRecorder()
+	handleBridges(w, httptest.NewRequest(http.MethodGet, "/bridges?scope=graph&limit=1", nil))
+	var resp BridgesResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Mode != "graph" || !resp.Exact || len(resp.Bridges) != 1 {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+	if top := resp.Bridges[0]; top.Pubkey != c || top.BrokerScore != 100 || top.Community != -1 {
+		t.Errorf("expected c as the top broker, got %+v", top)
+	}
+
+	for _, q := range []string{"scope=graph&a=1&b=2", "scope=graph&samples=8", "scope=everything"} {
+		w := httptest.NewRecorder()
+		handleBridges(w, httptest.NewRequest(http.MethodGet, "/bridges?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, w.Code)
+		}
+	}
+
+	audit := auditPubkey(graph.Snapshot(), c, "log")
+	if audit.BrokerScore == nil || *audit.BrokerScore != 100 || *audit.Betweenness != 8.0 {
+		t.Errorf("expected broker fields on /audit, got %v %v", audit.BrokerScore, audit.Betweenness)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+const (
+	challengeDefaultTTL = 10 * time.Minute
+	challengeMaxTTL     = time.Hour
+	challengeMaxNonce   = 128
+	challengeMaxAud     = 253
+	// challengePurpose marks a JWS as a challenge token so a /attestation JWS, signed
+	// with the same key, cannot be replayed at /challenge/verify.
+	challengePurpose = "wot-challenge"
+)
+
+// ChallengeClaim is the payload of a challenge token: the subject proved control of
+// sub (via NIP-98) and had a score of at least score_gte at build.
+type ChallengeClaim struct {
+	Purpose   string `json:"purpose"`
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	ScoreGTE  int    `json:"score_gte"`
+	Build     uint64 `json:"build"`
+	Audience  string `json:"aud,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// ChallengeVerifyResponse is the response for GET /challenge/verify.
+type ChallengeVerifyResponse struct {
+	Valid        bool            `json:"valid"`
+	Reason       string          `json:"reason,omitempty"`
+	Claim        *ChallengeClaim `json:"claim,omitempty"`
+	CurrentBuild uint64          `json:"current_build"`
+	Stale        bool            `json:"stale"` // issued against an older build
+}
+
+// providerPublicKey derives the full secp256k1 public key from a hex secret key.
+func providerPublicKey(sk string) (*btcec.PublicKey, error) {
+	skBytes, err := hex.DecodeString(sk)
+	if err != nil || len(skBytes) != 32 {
+		return nil, fmt.Errorf("invalid signing key")
+	}
+	priv, _ := btcec.PrivKeyFromBytes(skBytes)
+	return priv.PubKey(), nil
+}
+
+// handleChallenge serves POST /challenge: the caller authenticates with NIP-98 and
+// receives a short-lived token asserting that their score is at least threshold.
+// Sites verify the token (at /challenge/verify or offline with the JWK) instead of
+// querying a score on every visitor action.
+func handleChallenge(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 4<<10))
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "failed to read body"})
+		return
+	}
+	subject, err := verifyNIP98(r, body)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Nostr")
+		writeAPIError(w, &APIError{Status: http.StatusUnauthorized, Message: fmt.Sprintf("unauthorized: %s", err.Error())})
+		return
+	}
+
+	var req struct {
+		Threshold  *int   `json:"threshold"`
+		Audience   string `json:"audience"`
+		Nonce      string `json:"nonce"`
+		TTLMinutes int    `json:"ttl_minutes"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "invalid_json", Message: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.Threshold == nil || *req.Threshold < 0 || *req.Threshold > 100 {
+		writeAPIError(w, invalidParam("threshold", "threshold must be 0-100"))
+		return
+	}
+	if len(req.Audience) > challengeMaxAud || len(req.Nonce) > challengeMaxNonce {
+		writeAPIError(w, invalidParam("audience", "audience max %d bytes, nonce max %d bytes", challengeMaxAud, challengeMaxNonce))
+		return
+	}
+	ttl := challengeDefaultTTL
+	if req.TTLMinutes != 0 {
+		ttl = time.Duration(req.TTLMinutes) * time.Minute
+		if req.TTLMinutes < 1 || ttl > challengeMaxTTL {
+			writeAPIError(w, invalidParam("ttl_minutes", "ttl_minutes must be 1-60"))
+			return
+		}
+	}
+
+	build, _, _ := graphBuild.Current()
+	if build == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "scores have not been computed yet"})
+		return
+	}
+	rawScore, _ := graph.GetScore(subject)
+	if score := normalizeScore(rawScore, graph.Stats().Nodes); score < *req.Threshold {
+		writeAPIError(w, &APIError{Status: http.StatusForbidden, Message: fmt.Sprintf("score is below threshold %d", *req.Threshold)})
+		return
+	}
+
+	sk, pub, err := providerKey.Get()
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "challenge signing key not configured"})
+		return
+	}
+
+	jti := make([]byte, 16)
+	rand.Read(jti)
+	now := time.Now()
+	claim := ChallengeClaim{
+		Purpose:   challengePurpose,
+		Issuer:    pub,
+		Subject:   subject,
+		ScoreGTE:  *req.Threshold,
+		Build:     build,
+		Audience:  req.Audience,
+		Nonce:     req.Nonce,
+		ID:        hex.EncodeToString(jti),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+	payload, _ := json.Marshal(claim)
+	token, jwk, err := signES256K(payload, sk)
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusInternalServerError, Message: "failed to sign challenge token"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":      token,
+		"claim":      claim,
+		"jwk":        jwk,
+		"expires_at": time.Unix(claim.ExpiresAt, 0).UTC().Format(time.RFC3339),
+	})
+}
+
+// handleChallengeVerify serves GET /challenge/verify?token=: checks a challenge
+// token's signature and expiry, and optionally that it was issued for audience and
+// nonce with a threshold of at least min_score. Invalid tokens are reported with
+// valid=false and a reason rather than an error status.
+func handleChallengeVerify(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	token := q.String("token", true)
+	minScore := q.IntBetween("min_score", -1, 0, 100)
+	audience := q.String("audience", false)
+	nonce := q.String("nonce", false)
+	if q.Failed(w) {
+		return
+	}
+
+	sk, _, err := providerKey.Get()
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "challenge signing key not configured"})
+		return
+	}
+	key, err := providerPublicKey(sk)
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "challenge signing key not configured"})
+		return
+	}
+
+	current, _, _ := graphBuild.Current()
+	resp := ChallengeVerifyResponse{CurrentBuild: current}
+	reject := func(reason string) {
+		resp.Valid, resp.Reason = false, reason
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(resp)
+	}
+
+	payload, err := verifyES256K(token, key)
+	if err != nil {
+		reject(err.Error())
+		return
+	}
+	var claim ChallengeClaim
+	if json.Unmarshal(payload, &claim) != nil || claim.Purpose != challengePurpose {
+		reject("token is not a challenge token")
+		return
+	}
+	resp.Claim = &claim
+	resp.Stale = claim.Build != current
+
+	switch {
+	case time.Now().Unix() >= claim.ExpiresAt:
+		reject("token expired")
+	case audience != "" && audience != claim.Audience:
+		reject("token was issued for a different audience")
+	case nonce != "" && nonce != claim.Nonce:
+		reject("nonce does not match")
+	case minScore >= 0 && claim.ScoreGTE < minScore:
+		reject(fmt.Sprintf("token asserts score >= %d, below min_score %d", claim.ScoreGTE, minScore))
+	default:
+		resp.Valid = true
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(resp)
+	}
+}
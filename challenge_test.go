@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// setupChallenge scores a user whose key the test controls.
+func setupChallenge(t *testing.T) (userSK, userPub string) {
+	setupAttestation(t)
+	userSK = nostr.GeneratePrivateKey()
+	userPub, _ = nostr.GetPublicKey(userSK)
+	for i := 0; i < 20; i++ {
+		graph.AddFollow(padHex(26200+i), userPub)
+	}
+	graph.ComputePageRank(20, 0.85)
+	return userSK, userPub
+}
+
+func postChallenge(t *testing.T, sk, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	u := "http://example.com/challenge"
+	req := httptest.NewRequest(http.MethodPost, u, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", nip98Header(t, sk, "POST", u, []byte(body), time.Now()))
+	rr := httptest.NewRecorder()
+	handleChallenge(rr, req)
+	return rr
+}
+
+func verifyChallenge(query string) ChallengeVerifyResponse {
+	rr := httptest.NewRecorder()
+	handleChallengeVerify(rr, httptest.NewRequest("GET", "/challenge/verify?"+query, nil))
+	var resp ChallengeVerifyResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return resp
+}
+
+func TestChallengeIssueAndVerify(t *testing.T) {
+	sk, pub := setupChallenge(t)
+
+	rr := postChallenge(t, sk, `{"threshold":0,"audience":"example.org","nonce":"n1"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var issued struct {
+		Token string         `json:"token"`
+		Claim ChallengeClaim `json:"claim"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &issued)
+	if issued.Claim.Subject != pub || issued.Claim.Build != 1 || issued.Claim.ID == "" {
+		t.Errorf("unexpected claim %+v", issued.Claim)
+	}
+	if issued.Claim.ExpiresAt-issued.Claim.IssuedAt != int64(challengeDefaultTTL.Seconds()) {
+		t.Errorf("expected default ttl, got %d", issued.Claim.ExpiresAt-issued.Claim.IssuedAt)
+	}
+
+	tok := url.QueryEscape(issued.Token)
+	resp := verifyChallenge("token=" + tok + "&audience=example.org&nonce=n1&min_score=0")
+	if !resp.Valid || resp.Claim == nil || resp.Claim.Subject != pub || resp.Stale {
+		t.Errorf("expected valid fresh token, got %+v", resp)
+	}
+
+	for query, reason := range map[string]string{
+		"audience=other.org": "audience",
+		"nonce=n2":           "nonce",
+		"min_score=50":       "min_score",
+	} {
+		if resp := verifyChallenge("token=" + tok + "&" + query); resp.Valid || !strings.Contains(resp.Reason, reason) {
+			t.Errorf("%s: expected rejection mentioning %q, got %+v", query, reason, resp)
+		}
+	}
+
+	graphBuild.Advance(time.Now())
+	if resp := verifyChallenge("token=" + tok); !resp.Valid || !resp.Stale || resp.CurrentBuild != 2 {
+		t.Errorf("expected valid but stale token after a rebuild, got %+v", resp)
+	}
+}
+
+func TestChallengeVerifyRejectsForgedAndForeignTokens(t *testing.T) {
+	sk, _ := setupChallenge(t)
+
+	rr := postChallenge(t, sk, `{"threshold":0}`)
+	var issued struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &issued)
+	parts := strings.Split(issued.Token, ".")
+
+	// tampered payload
+	forged := parts[0] + "." + strings.TrimSuffix(parts[1], parts[1][len(parts[1])-2:]) + "xx." + parts[2]
+	if resp := verifyChallenge("token=" + url.QueryEscape(forged)); resp.Valid {
+		t.Error("forged token must not verify")
+	}
+
+	// a token signed by another key
+	other := nostr.GeneratePrivateKey()
+	foreign, _, _ := signES256K([]byte(`{"purpose":"wot-challenge","exp":9999999999}`), other)
+	if resp := verifyChallenge("token=" + url.QueryEscape(foreign)); resp.Valid || !strings.Contains(resp.Reason, "provider") {
+		t.Errorf("foreign token must not verify, got %+v", resp)
+	}
+
+	// an /attestation JWS is signed with the same key but is not a challenge token
+	providerSK, _, _ := providerKey.Get()
+	att, _, _ := buildAttestationJWS(AttestationClaim{Subject: "x", ExpiresAt: 9999999999}, providerSK)
+	if resp := verifyChallenge("token=" + url.QueryEscape(att)); resp.Valid || !strings.Contains(resp.Reason, "not a challenge") {
+		t.Errorf("attestation must not verify as a challenge, got %+v", resp)
+	}
+
+	// expired
+	expired, _ := json.Marshal(ChallengeClaim{Purpose: challengePurpose, ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	tok, _, _ := signES256K(expired, providerSK)
+	if resp := verifyChallenge("token=" + url.QueryEscape(tok)); resp.Valid || resp.Reason != "token expired" {
+		t.Errorf("expected expired token to be rejected, got %+v", resp)
+	}
+}
+
+func TestChallengeErrors(t *testing.T) {
+	sk, _ := setupChallenge(t)
+
+	for body, code := range map[string]int{
+		`{}`:                                http.StatusBadRequest,
+		`{"threshold":101}`:                 http.StatusBadRequest,
+		`{"threshold":10,"ttl_minutes":90}`: http.StatusBadRequest,
+		`not json`:                          http.StatusBadRequest,
+		`{"threshold":100}`:                 http.StatusForbidden,
+	} {
+		if rr := postChallenge(t, sk, body); rr.Code != code {
+			t.Errorf("%s: expected %d, got %d", body, code, rr.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/challenge", strings.NewReader(`{"threshold":0}`))
+	rr := httptest.NewRecorder()
+	handleChallenge(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without NIP-98 auth, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handleChallenge(rr, httptest.NewRequest(http.MethodGet, "/challenge", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handleChallengeVerify(rr, httptest.NewRequest("GET", "/challenge/verify", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without token, got %d", rr.Code)
+	}
+}
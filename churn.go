@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// followLogPerTarget caps the follow/unfollow events kept per followed pubkey;
+	// the oldest are dropped first.
+	followLogPerTarget = 2000
+	churnDefaultDays   = 30
+	churnMaxDays       = 365
+	// churnTopUnfollowers caps the unfollowers listed by /churn.
+	churnTopUnfollowers = 20
+	// A pubkey has high churn when at least churnHighMin followers and
+	// churnHighRate of its followers at the start of the window unfollowed it.
+	churnHighMin  = 10
+	churnHighRate = 0.2
+)
+
+// FollowEvent is one follow or unfollow, seen as the difference between two
+// successive contact lists of Follower.
+type FollowEvent struct {
+	Follower string
+	Unfollow bool
+	At       time.Time // created_at of the contact list that made the change
+	EventID  string
+}
+
+// FollowLog is the follow/unfollow event log, by followed pubkey. Contact lists
+// are replaceable, so an unfollow is only visible by comparing a new list with
+// the one it replaced; ContactHistory does that and records the result here.
+type FollowLog struct {
+	mu       sync.RWMutex
+	byTarget map[string][]FollowEvent // oldest first
+}
+
+func NewFollowLog() *FollowLog {
+	return &FollowLog{byTarget: make(map[string][]FollowEvent)}
+}
+
+var followLog = NewFollowLog()
+
+// Record logs the follows follower added and removed in the contact list
+// eventID, created at at.
+func (l *FollowLog) Record(follower, eventID string, at time.Time, added, removed []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, pks := range []struct {
+		targets  []string
+		unfollow bool
+	}{{added, false}, {removed, true}} {
+		for _, target := range pks.targets {
+			events := append(l.byTarget[target], FollowEvent{Follower: follower, Unfollow: pks.unfollow, At: at, EventID: eventID})
+			if len(events) > followLogPerTarget {
+				events = append([]FollowEvent(nil), events[len(events)-followLogPerTarget:]...)
+			}
+			l.byTarget[target] = events
+		}
+	}
+}
+
+// Events returns the logged follows and unfollows of target, oldest first.
+func (l *FollowLog) Events(target string) []FollowEvent {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]FollowEvent(nil), l.byTarget[target]...)
+}
+
+// ChurnUnfollower is one account that unfollowed the pubkey within the window.
+type ChurnUnfollower struct {
+	Pubkey       string `json:"pubkey"`
+	Score        int    `json:"score"`
+	UnfollowedAt string `json:"unfollowed_at"`
+}
+
+// ChurnResponse is the response for GET /churn.
+type ChurnResponse struct {
+	Pubkey           string            `json:"pubkey"`
+	WindowDays       int               `json:"window_days"`
+	Followers        int               `json:"followers"`
+	Follows          int               `json:"follows"`   // follow events in the window
+	Unfollows        int               `json:"unfollows"` // unfollow events in the window
+	NetGrowth        int               `json:"net_growth"`
+	UnfollowRate     float64           `json:"unfollow_rate"` // unfollows over followers at the start of the window
+	TrustedUnfollows int               `json:"trusted_unfollows"`
+	HighChurn        bool              `json:"high_churn"`
+	ObservedSince    string            `json:"observed_since,omitempty"` // oldest logged event
+	TopUnfollowers   []ChurnUnfollower `json:"top_unfollowers"`
+}
+
+// computeChurn summarizes the follows and unfollows of pubkey in the window
+// days before now. g supplies the follower count and the unfollowers' scores.
+func computeChurn(pubkey string, events []FollowEvent, g *Graph, days int, now time.Time) ChurnResponse {
+	resp := ChurnResponse{
+		Pubkey:         pubkey,
+		WindowDays:     days,
+		Followers:      len(g.GetFollowers(pubkey)),
+		TopUnfollowers: []ChurnUnfollower{},
+	}
+	if len(events) > 0 {
+		resp.ObservedSince = events[0].At.UTC().Format(time.RFC3339)
+	}
+
+	since := now.Add(-time.Duration(days) * 24 * time.Hour)
+	latest := make(map[string]FollowEvent) // follower -> their last event in the window
+	for _, ev := range events {
+		if ev.At.Before(since) {
+			continue
+		}
+		if ev.Unfollow {
+			resp.Unfollows++
+		} else {
+			resp.Follows++
+		}
+		if prev, ok := latest[ev.Follower]; !ok || !ev.At.Before(prev.At) {
+			latest[ev.Follower] = ev
+		}
+	}
+	resp.NetGrowth = resp.Follows - resp.Unfollows
+	if start := resp.Followers - resp.NetGrowth; start > 0 {
+		resp.UnfollowRate = math.Round(float64(resp.Unfollows)/float64(start)*1000) / 1000
+	}
+	resp.HighChurn = resp.Unfollows >= churnHighMin && resp.UnfollowRate >= churnHighRate
+
+	// an account that unfollowed and then followed again isn't an unfollower
+	nodes := g.NodeCount()
+	for follower, ev := range latest {
+		if !ev.Unfollow {
+			continue
+		}
+		raw, _ := g.GetScore(follower)
+		u := ChurnUnfollower{Pubkey: follower, Score: normalizeScore(raw, nodes), UnfollowedAt: ev.At.UTC().Format(time.RFC3339)}
+		if u.Score >= distrustMinSourceScore {
+			resp.TrustedUnfollows++
+		}
+		resp.TopUnfollowers = append(resp.TopUnfollowers, u)
+	}
+	sort.Slice(resp.TopUnfollowers, func(i, j int) bool {
+		a, b := resp.TopUnfollowers[i], resp.TopUnfollowers[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.UnfollowedAt != b.UnfollowedAt {
+			return a.UnfollowedAt > b.UnfollowedAt
+		}
+		return a.Pubkey < b.Pubkey
+	})
+	if len(resp.TopUnfollowers) > churnTopUnfollowers {
+		resp.TopUnfollowers = resp.TopUnfollowers[:churnTopUnfollowers]
+	}
+	return resp
+}
+
+// handleChurn serves GET /churn?pubkey=&days=: follows and unfollows of pubkey
+// over the last days (default 30), its unfollow rate and net growth, and its
+// highest-scoring recent unfollowers.
+func handleChurn(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	raw := q.Get("pubkey")
+	if raw == "" {
+		writeAPIError(w, missingParam("pubkey"))
+		return
+	}
+	pubkey, err := resolvePubkey(raw)
+	if err != nil || !hex64Pattern.MatchString(pubkey) {
+		writeAPIError(w, invalidParam("pubkey", "pubkey must be 64 hex characters or an npub"))
+		return
+	}
+	days := churnDefaultDays
+	if v := q.Get("days"); v != "" {
+		days, err = strconv.Atoi(v)
+		if err != nil || days < 1 || days > churnMaxDays {
+			writeAPIError(w, invalidParam("days", "days must be between 1 and 365"))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(computeChurn(pubkey, followLog.Events(pubkey), graph.Snapshot(), days, time.Now()))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestContactHistoryLogsFollowChanges(t *testing.T) {
+	log := NewFollowLog()
+	h := NewContactHistory(log)
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	v1 := followsEvent(t, sk, start, 50000, 50003)                   // follows 0, 1, 2
+	v3 := followsEvent(t, sk, start.Add(48*time.Hour), 50001, 50004) // drops 0, adds 3
+	v2 := followsEvent(t, sk, start.Add(24*time.Hour), 50000, 50002) // arrives late
+	for _, ev := range []*nostr.Event{v1, v3, v2} {
+		h.Record(ev, "crawl")
+	}
+
+	if evs := log.Events(padHex(50000)); len(evs) != 1 || !evs[0].Unfollow || evs[0].Follower != pub || evs[0].EventID != v3.ID {
+		t.Errorf("expected one unfollow of 50000 from v3, got %+v", evs)
+	}
+	if evs := log.Events(padHex(50003)); len(evs) != 1 || evs[0].Unfollow {
+		t.Errorf("expected one follow of 50003, got %+v", evs)
+	}
+	if evs := log.Events(padHex(50002)); len(evs) != 0 {
+		t.Errorf("expected the late, older version not logged, got %+v", evs)
+	}
+}
+
+func TestComputeChurn(t *testing.T) {
+	g, trusted, newcomer := reportGraph()
+	target := padHex(50100)
+	now := time.Unix(1_700_000_000, 0)
+	day := 24 * time.Hour
+	for i := 0; i < 20; i++ {
+		g.AddFollow(padHex(50200+i), target)
+	}
+	g.ComputePageRank(30, 0.85)
+
+	var events []FollowEvent
+	for i := 0; i < 12; i++ {
+		events = append(events, FollowEvent{Follower: padHex(50300 + i), Unfollow: true, At: now.Add(-2 * day)})
+	}
+	events = append(events,
+		FollowEvent{Follower: trusted, Unfollow: true, At: now.Add(-40 * day)}, // outside the window
+		FollowEvent{Follower: trusted, Unfollow: true, At: now.Add(-3 * day)},
+		FollowEvent{Follower: newcomer, Unfollow: true, At: now.Add(-5 * day)},
+		FollowEvent{Follower: newcomer, At: now.Add(-day)}, // came back
+		FollowEvent{Follower: padHex(50400), At: now.Add(-day)},
+	)
+
+	c := computeChurn(target, events, g, 30, now)
+	if c.Followers != 20 || c.Unfollows != 14 || c.Follows != 2 || c.NetGrowth != -12 {
+		t.Errorf("unexpected counts %+v", c)
+	}
+	// 14 unfollows over 32 followers at the start of the window
+	if c.UnfollowRate != 0.438 || !c.HighChurn {
+		t.Errorf("expected high churn at rate 0.438, got %v %v", c.UnfollowRate, c.HighChurn)
+	}
+	if len(c.TopUnfollowers) != 13 || c.TopUnfollowers[0].Pubkey != trusted || c.TrustedUnfollows != 1 {
+		t.Errorf("expected the trusted unfollower first and the refollower left out, got %+v", c.TopUnfollowers)
+	}
+	for _, u := range c.TopUnfollowers {
+		if u.Pubkey == newcomer {
+			t.Error("expected an account that followed again not listed")
+		}
+	}
+	if c.ObservedSince == "" {
+		t.Error("expected observed_since set")
+	}
+
+	if quiet := computeChurn(target, nil, g, 30, now); quiet.HighChurn || quiet.UnfollowRate != 0 || quiet.TopUnfollowers == nil {
+		t.Errorf("expected no churn without events, got %+v", quiet)
+	}
+}
+
+func TestChurnEndpoint(t *testing.T) {
+	old := followLog
+	t.Cleanup(func() { followLog = old })
+	followLog = NewFollowLog()
+	target := padHex(50500)
+	followLog.Record(padHex(50501), padHex(50502), time.Now().Add(-time.Hour), nil, []string{target})
+
+	rr := httptest.NewRecorder()
+	handleChurn(rr, httptest.NewRequest("GET", "/churn?pubkey="+target+"&days=7", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var c ChurnResponse
+	json.Unmarshal(rr.Body.Bytes(), &c)
+	if c.WindowDays != 7 || c.Unfollows != 1 || len(c.TopUnfollowers) != 1 {
+		t.Errorf("unexpected response %+v", c)
+	}
+
+	for _, q := range []string{"", "?pubkey=nobody", "?pubkey=" + target + "&days=0", "?pubkey=" + target + "&days=400"} {
+		rr := httptest.NewRecorder()
+		handleChurn(rr, httptest.NewRequest("GET", "/churn"+q, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", q, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+)
+
+const (
+	communitiesMapDefaultLimit = 20
+	communitiesMapMaxLimit     = 100
+	communitiesMapBridges      = 10 // bridges listed for the whole map
+	communitiesMapEdgeBridges  = 3  // bridges listed per edge
+)
+
+// CommunityMapNode is one community on the trust map.
+type CommunityMapNode struct {
+	ID            int      `json:"id"`
+	Size          int      `json:"size"`
+	AvgScore      float64  `json:"avg_score"`
+	TopScore      int      `json:"top_score"`
+	InternalEdges int      `json:"internal_edges"` // follows between members
+	OutgoingEdges int      `json:"outgoing_edges"` // follows from members to other labeled accounts
+	Cohesion      float64  `json:"cohesion"`       // internal share of members' labeled follows
+	TopMembers    []string `json:"top_members"`
+}
+
+// CommunityMapEdge is how much one community follows another.
+type CommunityMapEdge struct {
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+	Follows int      `json:"follows"` // follow edges from members of From to members of To
+	Weight  float64  `json:"weight"`  // follows / From's labeled follows
+	Bridges []string `json:"bridges,omitempty"`
+}
+
+// CommunitiesMapResponse is the response for GET /communities/map.
+type CommunitiesMapResponse struct {
+	Communities      []CommunityMapNode `json:"communities"`
+	Edges            []CommunityMapEdge `json:"edges"`
+	Bridges          []BridgeEntry      `json:"bridges"`
+	BridgesExact     bool               `json:"bridges_exact"`
+	TotalCommunities int                `json:"total_communities"`
+	GraphSize        int                `json:"graph_size"`
+}
+
+// handleCommunitiesMap serves GET /communities/map?limit=20&min_weight=0.01: the
+// largest communities as nodes and the follows between them as weighted, directed
+// edges, with the accounts most between communities, for drawing the macro-level
+// trust map. Follows into communities outside the map still count toward each
+// node's outgoing edges and edge weights, so weights don't change with limit.
+func handleCommunitiesMap(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	limit := q.Int("limit", communitiesMapDefaultLimit, 1, communitiesMapMaxLimit)
+	minWeight := q.Float("min_weight", 0, 0, 1)
+	if q.Failed(w) {
+		return
+	}
+
+	top := communities.TopCommunities(g, 0, 3)
+	sort.SliceStable(top, func(i, j int) bool {
+		if top[i].Size != top[j].Size {
+			return top[i].Size > top[j].Size
+		}
+		return top[i].ID < top[j].ID
+	})
+	resp := CommunitiesMapResponse{
+		Communities:      []CommunityMapNode{},
+		Edges:            []CommunityMapEdge{},
+		Bridges:          []BridgeEntry{},
+		TotalCommunities: len(top),
+		GraphSize:        g.Stats().Nodes,
+	}
+	if len(top) > limit {
+		top = top[:limit]
+	}
+	onMap := make(map[int]int, len(top)) // community -> index in resp.Communities
+	for i, c := range top {
+		onMap[c.ID] = i
+		resp.Communities = append(resp.Communities, CommunityMapNode{
+			ID:         c.ID,
+			Size:       c.Size,
+			AvgScore:   math.Round(c.AvgRank*10) / 10,
+			TopScore:   c.TopRank,
+			TopMembers: c.Members,
+		})
+	}
+
+	// Tally follows between labeled accounts
+	labels := communities.Snapshot()
+	type pair struct{ from, to int }
+	follows := make(map[pair]int)
+	labeled := make(map[int]int) // community -> members' follows of labeled accounts
+	for _, pk := range g.AllFollowers() {
+		from, ok := labels[pk]
+		if !ok {
+			continue
+		}
+		i, mapped := onMap[from]
+		for _, f := range g.GetFollows(pk) {
+			to, ok := labels[f]
+			if !ok {
+				continue
+			}
+			labeled[from]++
+			if !mapped {
+				continue
+			}
+			if to == from {
+				resp.Communities[i].InternalEdges++
+				continue
+			}
+			resp.Communities[i].OutgoingEdges++
+			if _, ok := onMap[to]; ok {
+				follows[pair{from, to}]++
+			}
+		}
+	}
+	for i := range resp.Communities {
+		c := &resp.Communities[i]
+		if total := labeled[c.ID]; total > 0 {
+			c.Cohesion = round3(float64(c.InternalEdges) / float64(total))
+		}
+	}
+
+	// Accounts most between communities, as on /bridges
+	key := fmt.Sprintf("%v:%d:%d:%d", false, 0, 0, bridgesDefaultSamples)
+	bridges, ok := bridgesCache.get(key)
+	if !ok {
+		bridges = computeBridges(0, 0, false, bridgesDefaultSamples)
+		bridgesCache.put(key, bridges)
+	}
+	resp.BridgesExact = bridges.Exact
+	for _, b := range bridges.Bridges {
+		if len(resp.Bridges) < communitiesMapBridges {
+			resp.Bridges = append(resp.Bridges, b)
+		}
+	}
+
+	for p, n := range follows {
+		weight := float64(n) / float64(labeled[p.from])
+		if weight < minWeight {
+			continue
+		}
+		e := CommunityMapEdge{From: p.from, To: p.to, Follows: n, Weight: round3(weight)}
+		for _, b := range bridges.Bridges {
+			if len(e.Bridges) == communitiesMapEdgeBridges {
+				break
+			}
+			if bridgeConnects(b, p.from, p.to) {
+				e.Bridges = append(e.Bridges, b.Pubkey)
+			}
+		}
+		resp.Edges = append(resp.Edges, e)
+	}
+	sort.Slice(resp.Edges, func(i, j int) bool {
+		a, b := resp.Edges[i], resp.Edges[j]
+		if a.Follows != b.Follows {
+			return a.Follows > b.Follows
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// bridgeConnects reports whether bridge b neighbors both communities, counting its
+// own community.
+func bridgeConnects(b BridgeEntry, x, y int) bool {
+	hasX, hasY := b.Community == x, b.Community == y
+	for _, l := range b.Connects {
+		hasX = hasX || l.Community == x
+		hasY = hasY || l.Community == y
+	}
+	return hasX && hasY
+}
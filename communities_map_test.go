@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func getCommunitiesMap(t *testing.T, query string) (int, CommunitiesMapResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handleCommunitiesMap(w, httptest.NewRequest(http.MethodGet, "/communities/map"+query, nil))
+	var resp CommunitiesMapResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestCommunitiesMap(t *testing.T) {
+	oldGraph, oldCommunities := graph, communities
+	defer func() { graph, communities = oldGraph, oldCommunities }()
+	x, _ := buildBridgeGraph()
+
+	code, resp := getCommunitiesMap(t, "")
+	if code != http.StatusOK || resp.TotalCommunities != 3 || len(resp.Communities) != 3 {
+		t.Fatalf("unexpected response %d %+v", code, resp)
+	}
+	c1 := resp.Communities[0]
+	if c1.ID != 1 || c1.Size != 5 || c1.InternalEdges != 14 || c1.OutgoingEdges != 1 || c1.Cohesion != 0.933 || len(c1.TopMembers) != 3 {
+		t.Errorf("unexpected community 1 %+v", c1)
+	}
+
+	edges := make(map[[2]int]CommunityMapEdge)
+	for _, e := range resp.Edges {
+		edges[[2]int{e.From, e.To}] = e
+	}
+	if len(edges) != 4 {
+		t.Fatalf("expected edges 1-2, 2-1, 2-3, 3-2, got %+v", resp.Edges)
+	}
+	e := edges[[2]int{1, 2}]
+	if e.Follows != 1 || e.Weight != 0.067 {
+		t.Errorf("unexpected 1-2 edge %+v", e)
+	}
+	found := false
+	for _, b := range e.Bridges {
+		found = found || b == x
+	}
+	if !found {
+		t.Errorf("expected x among the 1-2 edge's bridges, got %v", e.Bridges)
+	}
+	for _, b := range edges[[2]int{2, 3}].Bridges {
+		if b == x {
+			t.Error("x doesn't neighbor community 3")
+		}
+	}
+	if len(resp.Bridges) == 0 || !resp.BridgesExact {
+		t.Errorf("expected exact bridges listed, got %+v", resp.Bridges)
+	}
+}
+
+func TestCommunitiesMapLimitAndMinWeight(t *testing.T) {
+	oldGraph, oldCommunities := graph, communities
+	defer func() { graph, communities = oldGraph, oldCommunities }()
+	buildBridgeGraph()
+
+	// the two largest are communities 1 and 3, which don't follow each other
+	_, resp := getCommunitiesMap(t, "?limit=2")
+	if len(resp.Communities) != 2 || resp.Communities[0].ID != 1 || resp.Communities[1].ID != 3 || len(resp.Edges) != 0 {
+		t.Errorf("unexpected limited map %+v", resp)
+	}
+	if resp.Communities[0].OutgoingEdges != 1 {
+		t.Errorf("expected follows off the map still counted, got %+v", resp.Communities[0])
+	}
+
+	_, resp = getCommunitiesMap(t, "?min_weight=0.07")
+	for _, e := range resp.Edges {
+		if e.Weight < 0.07 {
+			t.Errorf("expected edges below min_weight dropped, got %+v", e)
+		}
+	}
+	if len(resp.Edges) != 2 {
+		t.Errorf("expected community 2's two edges, got %+v", resp.Edges)
+	}
+
+	for _, q := range []string{"?limit=0", "?limit=x", "?min_weight=-1"} {
+		if code, _ := getCommunitiesMap(t, q); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, code)
+		}
+	}
+}
@@ -123,6 +123,17 @@ func (cd *CommunityDetector) GetCommunity(pubkey string) (int, bool) {
 	return l, ok
 }
 
+// Snapshot returns a copy of the pubkey -> community label map.
+func (cd *CommunityDetector) Snapshot() map[string]int {
+	cd.mu.RLock()
+	defer cd.mu.RUnlock()
+	snap := make(map[string]int, len(cd.labels))
+	for k, v := range cd.labels {
+		snap[k] = v
+	}
+	return snap
+}
+
 // GetCommunityMembers returns all pubkeys in the same community as the given pubkey.
 func (cd *CommunityDetector) GetCommunityMembers(pubkey string) []string {
 	cd.mu.RLock()
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxCommunityBuilds is how many community detection runs are kept (30 days of
+	// 6-hourly rebuilds).
+	maxCommunityBuilds = 120
+	// maxMembershipChanges caps the change log kept per pubkey.
+	maxMembershipChanges = 50
+)
+
+// MembershipChange records a pubkey moving between communities at a build. From or
+// To is -1 when the pubkey was not in the community graph.
+type MembershipChange struct {
+	Build uint64 `json:"build"`
+	At    string `json:"at"`
+	From  int    `json:"from"`
+	To    int    `json:"to"`
+}
+
+// CommunityBuild is one recorded community detection run.
+type CommunityBuild struct {
+	Build       uint64    `json:"build"`
+	At          time.Time `json:"at"`
+	Communities int       `json:"communities"`
+}
+
+// CommunityHistory keeps community membership across graph builds. Label propagation
+// numbers communities arbitrarily on every run, so each run is first aligned with the
+// previous one: a new community keeps the id of the old community it overlaps most,
+// and communities with no predecessor get fresh ids. Only changes are stored, so
+// memory grows with migrations rather than with builds x pubkeys.
+type CommunityHistory struct {
+	mu      sync.RWMutex
+	builds  []CommunityBuild // oldest first
+	current map[string]int
+	nextID  int
+	changes map[string][]MembershipChange // oldest first
+}
+
+func NewCommunityHistory() *CommunityHistory {
+	return &CommunityHistory{
+		current: make(map[string]int),
+		changes: make(map[string][]MembershipChange),
+	}
+}
+
+// alignCommunityLabels maps next's labels onto prev's ids by member overlap. Larger
+// communities claim ids first; each previous id is reused at most once. nextID is
+// the first unused id and is advanced for every fresh id handed out.
+func alignCommunityLabels(prev, next map[string]int, nextID *int) map[string]int {
+	groups := make(map[int][]string)
+	for pk, l := range next {
+		groups[l] = append(groups[l], pk)
+	}
+	labels := make([]int, 0, len(groups))
+	for l := range groups {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if len(groups[labels[i]]) != len(groups[labels[j]]) {
+			return len(groups[labels[i]]) > len(groups[labels[j]])
+		}
+		return labels[i] < labels[j]
+	})
+
+	claimed := make(map[int]bool)
+	aligned := make(map[string]int, len(next))
+	for _, l := range labels {
+		overlap := make(map[int]int)
+		for _, pk := range groups[l] {
+			if p, ok := prev[pk]; ok && !claimed[p] {
+				overlap[p]++
+			}
+		}
+		id, best := -1, 0
+		for p, n := range overlap {
+			if n > best || (n == best && p < id) {
+				id, best = p, n
+			}
+		}
+		if id < 0 {
+			id = *nextID
+			*nextID++
+		}
+		claimed[id] = true
+		for _, pk := range groups[l] {
+			aligned[pk] = id
+		}
+	}
+	return aligned
+}
+
+// Record aligns the detector's current labels with the previous build, installs the
+// aligned labels back into cd so ids stay stable across builds, and logs every
+// membership change.
+func (h *CommunityHistory) Record(cd *CommunityDetector, build uint64, at time.Time) {
+	next := cd.Snapshot()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.builds) == 0 {
+		for _, l := range next {
+			if l >= h.nextID {
+				h.nextID = l + 1
+			}
+		}
+	}
+	aligned := alignCommunityLabels(h.current, next, &h.nextID)
+
+	stamp := at.UTC().Format(time.RFC3339)
+	add := func(pk string, from, to int) {
+		entries := append(h.changes[pk], MembershipChange{Build: build, At: stamp, From: from, To: to})
+		if len(entries) > maxMembershipChanges {
+			entries = entries[len(entries)-maxMembershipChanges:]
+		}
+		h.changes[pk] = entries
+	}
+	for pk, to := range aligned {
+		from, ok := h.current[pk]
+		if !ok {
+			from = -1
+		}
+		if from != to {
+			add(pk, from, to)
+		}
+	}
+	for pk, from := range h.current {
+		if _, ok := aligned[pk]; !ok {
+			add(pk, from, -1)
+		}
+	}
+
+	seen := make(map[int]bool)
+	for _, l := range aligned {
+		seen[l] = true
+	}
+	h.builds = append(h.builds, CommunityBuild{Build: build, At: at, Communities: len(seen)})
+	if len(h.builds) > maxCommunityBuilds {
+		h.builds = h.builds[len(h.builds)-maxCommunityBuilds:]
+	}
+	h.current = aligned
+
+	cd.mu.Lock()
+	cd.labels = aligned
+	cd.mu.Unlock()
+}
+
+// errInvalidAsOf is returned by ResolveBuild for values it cannot parse.
+var errInvalidAsOf = errors.New("as_of must be a build id, RFC3339 time, or YYYY-MM-DD")
+
+// ResolveBuild finds the recorded build for an as_of value: a build ID, or a time
+// (RFC3339 or YYYY-MM-DD) resolved to the latest build at or before it.
+func (h *CommunityHistory) ResolveBuild(asOf string) (CommunityBuild, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if id, err := strconv.ParseUint(asOf, 10, 64); err == nil {
+		for _, b := range h.builds {
+			if b.Build == id {
+				return b, nil
+			}
+		}
+		return CommunityBuild{}, fmt.Errorf("build %d not in community history", id)
+	}
+
+	t, err := time.Parse(time.RFC3339, asOf)
+	if err != nil {
+		d, derr := time.Parse("2006-01-02", asOf)
+		if derr != nil {
+			return CommunityBuild{}, errInvalidAsOf
+		}
+		t = d.Add(24*time.Hour - time.Second) // end of that day
+	}
+	for i := len(h.builds) - 1; i >= 0; i-- {
+		if !h.builds[i].At.After(t) {
+			return h.builds[i], nil
+		}
+	}
+	return CommunityBuild{}, fmt.Errorf("no community history at or before %s", asOf)
+}
+
+// MembershipAt returns pubkey's community at build (-1 if it was not in the graph).
+// ok is false when the build predates the pubkey's retained change log.
+func (h *CommunityHistory) MembershipAt(pubkey string, build uint64) (community int, ok bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	entries := h.changes[pubkey]
+	community = -1
+	for _, c := range entries {
+		if c.Build > build {
+			break
+		}
+		community = c.To
+	}
+	if len(entries) == maxMembershipChanges && entries[0].Build > build {
+		return -1, false
+	}
+	return community, true
+}
+
+// Changes returns pubkey's membership change log, oldest first.
+func (h *CommunityHistory) Changes(pubkey string) []MembershipChange {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make([]MembershipChange, len(h.changes[pubkey]))
+	copy(out, h.changes[pubkey])
+	return out
+}
+
+// handleCommunitiesAsOf serves GET /communities?pubkey=&as_of=: the pubkey's
+// community at a historical build plus its membership change log.
+func handleCommunitiesAsOf(w http.ResponseWriter, pubkey, asOf string) {
+	if pubkey == "" {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "as_of requires a pubkey"})
+		return
+	}
+	b, err := communityHistory.ResolveBuild(asOf)
+	if err == errInvalidAsOf {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusNotFound, Message: err.Error()})
+		return
+	}
+	community, ok := communityHistory.MembershipAt(pubkey, b.Build)
+	if !ok {
+		writeAPIError(w, &APIError{Status: http.StatusNotFound, Message: "membership at that build is older than the retained change log"})
+		return
+	}
+	current, inGraph := communities.GetCommunity(pubkey)
+	if !inGraph {
+		current = -1
+	}
+
+	changes := communityHistory.Changes(pubkey)
+	migrations := 0
+	for _, c := range changes {
+		if c.From >= 0 && c.To >= 0 {
+			migrations++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pubkey":            pubkey,
+		"as_of_build":       b.Build,
+		"as_of":             b.At.UTC().Format(time.RFC3339),
+		"community_id":      community,
+		"current_community": current,
+		"migrations":        migrations,
+		"changes":           changes,
+	})
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAlignCommunityLabels(t *testing.T) {
+	prev := map[string]int{"a": 7, "b": 7, "c": 7, "d": 9, "e": 9}
+	// Relabelled run: a, b, c stay together; d moves in with them; e is alone; f is new
+	next := map[string]int{"a": 100, "b": 100, "c": 100, "d": 100, "e": 200, "f": 300}
+	nextID := 10
+	got := alignCommunityLabels(prev, next, &nextID)
+
+	if got["a"] != 7 || got["d"] != 7 {
+		t.Errorf("largest community should keep id 7, got %v", got)
+	}
+	if got["e"] != 9 {
+		t.Errorf("e should keep its old community id 9, got %d", got["e"])
+	}
+	if got["f"] != 10 || nextID != 11 {
+		t.Errorf("new community should get fresh id 10, got %d (next %d)", got["f"], nextID)
+	}
+}
+
+func TestCommunityHistoryRecord(t *testing.T) {
+	h := NewCommunityHistory()
+	cd := NewCommunityDetector()
+	t0 := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	cd.labels = map[string]int{"a": 1, "b": 1, "c": 1, "d": 2, "e": 2}
+	h.Record(cd, 1, t0)
+	first, _ := cd.GetCommunity("a")
+	other, _ := cd.GetCommunity("d")
+
+	// Next run uses unrelated raw labels; d migrates to a's community and e leaves
+	cd.labels = map[string]int{"a": 50, "b": 50, "c": 50, "d": 50}
+	h.Record(cd, 2, t0.Add(6*time.Hour))
+
+	if l, _ := cd.GetCommunity("a"); l != first {
+		t.Fatalf("ids must stay stable across builds: a was %d, now %d", first, l)
+	}
+	if l, ok := h.MembershipAt("d", 1); !ok || l != other {
+		t.Errorf("d at build 1: expected %d, got %d", other, l)
+	}
+	if l, _ := h.MembershipAt("d", 2); l != first {
+		t.Errorf("d at build 2: expected %d, got %d", first, l)
+	}
+	if l, _ := h.MembershipAt("e", 2); l != -1 {
+		t.Errorf("e left the graph at build 2, got %d", l)
+	}
+	if n := len(h.Changes("a")); n != 1 {
+		t.Errorf("a never moved, expected only its first appearance, got %d changes", n)
+	}
+	if c := h.Changes("d"); len(c) != 2 || c[1].From != other || c[1].To != first {
+		t.Errorf("unexpected change log for d: %+v", c)
+	}
+
+	if b, err := h.ResolveBuild("2026-03-01"); err != nil || b.Build != 2 {
+		t.Errorf("expected build 2 for the day, got %+v %v", b, err)
+	}
+	if b, err := h.ResolveBuild("2026-03-01T03:00:00Z"); err != nil || b.Build != 1 {
+		t.Errorf("expected build 1 before the second run, got %+v %v", b, err)
+	}
+	if _, err := h.ResolveBuild("2026-02-01"); err == nil {
+		t.Error("expected error before the first recorded build")
+	}
+}
+
+func TestCommunitiesAsOfEndpoint(t *testing.T) {
+	oldCommunities, oldHistory := communities, communityHistory
+	defer func() { communities, communityHistory = oldCommunities, oldHistory }()
+	communities = NewCommunityDetector()
+	communityHistory = NewCommunityHistory()
+
+	pk := padHex(28001)
+	communities.labels = map[string]int{pk: 3, padHex(28002): 3, padHex(28003): 4}
+	communityHistory.Record(communities, 1, time.Now().Add(-time.Hour))
+	communities.labels = map[string]int{pk: 8, padHex(28003): 8, padHex(28002): 9}
+	communityHistory.Record(communities, 2, time.Now())
+
+	for _, tc := range []struct {
+		query string
+		code  int
+	}{
+		{"as_of=1", http.StatusBadRequest},
+		{"pubkey=" + pk + "&as_of=yesterday", http.StatusBadRequest},
+		{"pubkey=" + pk + "&as_of=99", http.StatusNotFound},
+	} {
+		rr := httptest.NewRecorder()
+		handleCommunities(rr, httptest.NewRequest("GET", "/communities?"+tc.query, nil))
+		if rr.Code != tc.code {
+			t.Errorf("%q: expected %d, got %d", tc.query, tc.code, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handleCommunities(rr, httptest.NewRequest("GET", "/communities?pubkey="+pk+"&as_of=1", nil))
+	var resp struct {
+		AsOfBuild        uint64             `json:"as_of_build"`
+		CommunityID      int                `json:"community_id"`
+		CurrentCommunity int                `json:"current_community"`
+		Changes          []MembershipChange `json:"changes"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusOK || resp.AsOfBuild != 1 || len(resp.Changes) == 0 {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	if l, _ := communities.GetCommunity(pk); resp.CurrentCommunity != l {
+		t.Errorf("current community %d, detector has %d", resp.CurrentCommunity, l)
+	}
+}
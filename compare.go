@@ -8,48 +8,88 @@ import (
 	"sort"
 )
 
+// CompareProfile is one side of a /compare.
+type CompareProfile struct {
+	Pubkey     string  `json:"pubkey"`
+	InGraph    bool    `json:"in_graph"`
+	WotScore   int     `json:"wot_score"`
+	Rank       int     `json:"rank"`
+	Percentile float64 `json:"percentile"`
+	Follows    int     `json:"follows_count"`
+	Followers  int     `json:"followers_count"`
+}
+
+// CompareScoredPubkey is a shared follow or follower in a /compare.
+type CompareScoredPubkey struct {
+	Pubkey   string `json:"pubkey"`
+	WotScore int    `json:"wot_score"`
+}
+
+// CompareTrustPath is the shortest follow path from a to b. path is empty when
+// there is none within 6 hops.
+type CompareTrustPath struct {
+	Found bool     `json:"found"`
+	Hops  int      `json:"hops"`
+	Path  []string `json:"path"`
+}
+
+// CompareResponse is the /compare body.
+type CompareResponse struct {
+	A                    CompareProfile        `json:"a"`
+	B                    CompareProfile        `json:"b"`
+	Relationship         string                `json:"relationship"` // "mutual", "a_follows_b", "b_follows_a" or "none"
+	SharedFollowsCount   int                   `json:"shared_follows_count"`
+	SharedFollowersCount int                   `json:"shared_followers_count"`
+	FollowSimilarity     float64               `json:"follow_similarity"`
+	TopSharedFollows     []CompareScoredPubkey `json:"top_shared_follows"`
+	TopSharedFollowers   []CompareScoredPubkey `json:"top_shared_followers"`
+	TrustPath            CompareTrustPath      `json:"trust_path"`
+	GraphSize            int                   `json:"graph_size"`
+}
+
 // handleCompare shows the relationship between two pubkeys in the Web of Trust.
 // GET /compare?a=<pubkey|npub>&b=<pubkey|npub>
 func handleCompare(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
 	rawA := r.URL.Query().Get("a")
 	rawB := r.URL.Query().Get("b")
 	if rawA == "" || rawB == "" {
-		http.Error(w, `{"error":"both 'a' and 'b' parameters required"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "both 'a' and 'b' parameters required"})
 		return
 	}
 
 	pubkeyA, err := resolvePubkey(rawA)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid pubkey a: %s"}`, err.Error()), http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid pubkey a: %s", err.Error())})
 		return
 	}
 	pubkeyB, err := resolvePubkey(rawB)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid pubkey b: %s"}`, err.Error()), http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid pubkey b: %s", err.Error())})
 		return
 	}
 	if pubkeyA == pubkeyB {
-		http.Error(w, `{"error":"a and b are the same pubkey"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "a and b are the same pubkey"})
 		return
 	}
 
-	stats := graph.Stats()
+	stats := g.Stats()
 
 	// Scores and ranks
-	rawScoreA, okA := graph.GetScore(pubkeyA)
-	rawScoreB, okB := graph.GetScore(pubkeyB)
+	rawScoreA, okA := g.GetScore(pubkeyA)
+	rawScoreB, okB := g.GetScore(pubkeyB)
 	normA := normalizeScore(rawScoreA, stats.Nodes)
 	normB := normalizeScore(rawScoreB, stats.Nodes)
-	rankA := graph.Rank(pubkeyA)
-	rankB := graph.Rank(pubkeyB)
-	pctA := graph.Percentile(pubkeyA)
-	pctB := graph.Percentile(pubkeyB)
+	rankA := g.Rank(pubkeyA)
+	rankB := g.Rank(pubkeyB)
+	pctA := g.Percentile(pubkeyA)
+	pctB := g.Percentile(pubkeyB)
 
 	// Follow relationships
-	followsA := graph.GetFollows(pubkeyA)
-	followsB := graph.GetFollows(pubkeyB)
-	followersA := graph.GetFollowers(pubkeyA)
-	followersB := graph.GetFollowers(pubkeyB)
+	followsA := g.GetFollows(pubkeyA)
+	followsB := g.GetFollows(pubkeyB)
+	followersA := g.GetFollowers(pubkeyA)
+	followersB := g.GetFollowers(pubkeyB)
 
 	// Direct relationship
 	aFollowsB := false
@@ -113,15 +153,10 @@ func handleCompare(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Sort shared follows by WoT score (highest first), return top 20
-	type scoredPubkey struct {
-		Pubkey   string `json:"pubkey"`
-		WotScore int    `json:"wot_score"`
-	}
-
-	sortedSharedFollows := make([]scoredPubkey, len(sharedFollows))
+	sortedSharedFollows := make([]CompareScoredPubkey, len(sharedFollows))
 	for i, pk := range sharedFollows {
-		raw, _ := graph.GetScore(pk)
-		sortedSharedFollows[i] = scoredPubkey{pk, normalizeScore(raw, stats.Nodes)}
+		raw, _ := g.GetScore(pk)
+		sortedSharedFollows[i] = CompareScoredPubkey{pk, normalizeScore(raw, stats.Nodes)}
 	}
 	sort.Slice(sortedSharedFollows, func(i, j int) bool {
 		return sortedSharedFollows[i].WotScore > sortedSharedFollows[j].WotScore
@@ -130,10 +165,10 @@ func handleCompare(w http.ResponseWriter, r *http.Request) {
 		sortedSharedFollows = sortedSharedFollows[:20]
 	}
 
-	sortedSharedFollowers := make([]scoredPubkey, len(sharedFollowers))
+	sortedSharedFollowers := make([]CompareScoredPubkey, len(sharedFollowers))
 	for i, pk := range sharedFollowers {
-		raw, _ := graph.GetScore(pk)
-		sortedSharedFollowers[i] = scoredPubkey{pk, normalizeScore(raw, stats.Nodes)}
+		raw, _ := g.GetScore(pk)
+		sortedSharedFollowers[i] = CompareScoredPubkey{pk, normalizeScore(raw, stats.Nodes)}
 	}
 	sort.Slice(sortedSharedFollowers, func(i, j int) bool {
 		return sortedSharedFollowers[i].WotScore > sortedSharedFollowers[j].WotScore
@@ -147,20 +182,12 @@ func handleCompare(w http.ResponseWriter, r *http.Request) {
 	hops := 0
 	if pathFound {
 		hops = len(path) - 1
+	} else {
+		path = []string{}
 	}
 
-	type profileInfo struct {
-		Pubkey     string  `json:"pubkey"`
-		InGraph    bool    `json:"in_graph"`
-		WotScore   int     `json:"wot_score"`
-		Rank       int     `json:"rank"`
-		Percentile float64 `json:"percentile"`
-		Follows    int     `json:"follows_count"`
-		Followers  int     `json:"followers_count"`
-	}
-
-	resp := map[string]interface{}{
-		"a": profileInfo{
+	resp := CompareResponse{
+		A: CompareProfile{
 			Pubkey:     pubkeyA,
 			InGraph:    okA,
 			WotScore:   normA,
@@ -169,7 +196,7 @@ func handleCompare(w http.ResponseWriter, r *http.Request) {
 			Follows:    len(followsA),
 			Followers:  len(followersA),
 		},
-		"b": profileInfo{
+		B: CompareProfile{
 			Pubkey:     pubkeyB,
 			InGraph:    okB,
 			WotScore:   normB,
@@ -178,18 +205,14 @@ func handleCompare(w http.ResponseWriter, r *http.Request) {
 			Follows:    len(followsB),
 			Followers:  len(followersB),
 		},
-		"relationship":          relationship,
-		"shared_follows_count":  len(sharedFollows),
-		"shared_followers_count": len(sharedFollowers),
-		"follow_similarity":     math.Round(jaccard*1000) / 1000,
-		"top_shared_follows":    sortedSharedFollows,
-		"top_shared_followers":  sortedSharedFollowers,
-		"trust_path": map[string]interface{}{
-			"found": pathFound,
-			"hops":  hops,
-			"path":  path,
-		},
-		"graph_size": stats.Nodes,
+		Relationship:         relationship,
+		SharedFollowsCount:   len(sharedFollows),
+		SharedFollowersCount: len(sharedFollowers),
+		FollowSimilarity:     math.Round(jaccard*1000) / 1000,
+		TopSharedFollows:     sortedSharedFollows,
+		TopSharedFollowers:   sortedSharedFollowers,
+		TrustPath:            CompareTrustPath{Found: pathFound, Hops: hops, Path: path},
+		GraphSize:            stats.Nodes,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
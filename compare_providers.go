@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"math"
 	"net/http"
 	"sort"
@@ -45,15 +44,9 @@ type CompareProvidersResponse struct {
 // handleCompareProviders returns WoT scores from multiple NIP-85 providers for a pubkey.
 // GET /compare-providers?pubkey=<hex|npub>
 func handleCompareProviders(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
-		return
-	}
-
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid pubkey: %s"}`, err.Error()), http.StatusBadRequest)
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
 		return
 	}
 
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Config holds the crawl and scoring settings an operator can change without
+// recompiling. Changes take effect at the next rebuild.
+type Config struct {
+	Seeds                   []string   `json:"seeds"`
+	SeedsFile               string     `json:"seeds_file,omitempty"` // more seeds, one hex pubkey or npub per line
+	Relays                  []string   `json:"relays"`
+	CrawlDepth              int        `json:"crawl_depth"`       // 1 = direct follows, 2 = follows-of-follows
+	SeedCrawlBudget         int        `json:"seed_crawl_budget"` // pubkeys each seed's neighborhood may add to a crawl; 0 is no limit
+	PageRankIterations      int        `json:"pagerank_iterations"`
+	Damping                 float64    `json:"damping"`
+	PublishTopN             int        `json:"publish_top_n"`              // pubkeys that get a kind 30382 event each rebuild
+	PublishLists            []int      `json:"publish_lists"`              // score thresholds that get a kind 30000 people list each rebuild
+	PublishSpamReports      bool       `json:"publish_spam_reports"`       // publish kind 1984 spam reports for accounts flagged with high confidence
+	SpamReportMinConfidence float64    `json:"spam_report_min_confidence"` // spam probability an account needs before it is reported
+	PublishBadges           bool       `json:"publish_badges"`             // publish NIP-58 badges for the trust tiers
+	DMNotifications         bool       `json:"dm_notifications"`           // DM opted-in authorizers when their score changes
+	NostrQueries            bool       `json:"nostr_queries"`              // answer kind 5312 WoT queries sent over relays
+	Normalization           string     `json:"normalization"`              // curve for /score, /audit and the published rank: log, percentile, zscore or minmax
+	PruneInactiveMonths     int        `json:"prune_inactive_months"`      // drop pubkeys with no contact list or authored event for this long; 0 keeps everyone
+	MaxFollows              int        `json:"max_follows"`                // follows counted per contact list; 0 is no cap
+	SnapshotRetention       int        `json:"snapshot_retention"`         // score snapshots kept for /snapshots; 0 keeps none
+	SnapshotRetentionDays   int        `json:"snapshot_retention_days"`    // drop snapshots older than this; 0 is no age limit
+	KeyProvider             string     `json:"key_provider,omitempty"`     // env, file, keychain, command or 1password; see keyProviderFor
+	KeyFile                 string     `json:"key_file,omitempty"`
+	KeyCommand              string     `json:"key_command,omitempty"`
+	KeychainService         string     `json:"keychain_service,omitempty"`
+	Views                   []SeedView `json:"views,omitempty"` // seed groups scored as separate views, selected with ?view=
+}
+
+// defaultConfig is what the public instance runs with.
+var defaultConfig = Config{
+	// Seed pubkeys: well-known Nostr accounts for initial graph crawl
+	Seeds: []string{
+		"82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2", // jack
+		"fa984bd7dbb282f07e16e7ae87b26a2a7b9b90b7246a44771f0cf5ae58018f52", // pablo
+		"32e1827635450ebb3c5a7d12c1f8e7b2b514439ac10a67eef3d9fd9c5c68e245", // jb55
+		"f2da54d2d1edfe02c052972e2eeb192a5046751ed38e94e2f9be0c156456e2aa", // max (SATMAX)
+	},
+	Relays: []string{
+		"wss://relay.damus.io",
+		"wss://nos.lol",
+		"wss://relay.primal.net",
+		"wss://nip85.nostr1.com",
+		"wss://nip85.brainstorm.world",
+	},
+	CrawlDepth:              2,
+	PageRankIterations:      20,
+	Damping:                 0.85,
+	PublishTopN:             10000,
+	SpamReportMinConfidence: 0.9,
+	SnapshotRetention:       28,
+	Normalization:           "log",
+	KeychainService:         "wot-scoring",
+}
+
+// LoadConfig builds a Config from the defaults, then the file at path (if path is
+// not empty), then the SEEDS, SEEDS_FILE, RELAYS, CRAWL_DEPTH, SEED_CRAWL_BUDGET,
+// PAGERANK_ITERATIONS, PAGERANK_DAMPING, PUBLISH_TOP_N, PUBLISH_LISTS,
+// PUBLISH_SPAM_REPORTS, SPAM_REPORT_MIN_CONFIDENCE, PUBLISH_BADGES, DM_NOTIFICATIONS, SCORE_NORMALIZATION,
+// PRUNE_INACTIVE_MONTHS, MAX_FOLLOWS, SNAPSHOT_RETENTION, SNAPSHOT_RETENTION_DAYS,
+// KEY_PROVIDER, KEY_FILE, KEY_COMMAND and KEYCHAIN_SERVICE environment variables.
+//
+// Seeds from the seeds file are added to the seeds list. The seeds file is re-read
+// whenever the config is.
+//
+// The file uses a TOML subset: one key = value per line, with quoted strings,
+// numbers, booleans, and arrays of strings or integers that may span lines. # starts
+// a comment.
+func LoadConfig(path string) (Config, error) {
+	cfg := defaultConfig
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		if err := parseConfig(string(data), &cfg); err != nil {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if v := os.Getenv("SEEDS"); v != "" {
+		cfg.Seeds = splitCommaList(v)
+	}
+	if v := os.Getenv("RELAYS"); v != "" {
+		cfg.Relays = splitCommaList(v)
+	}
+	for env, field := range map[string]*int{
+		"CRAWL_DEPTH":             &cfg.CrawlDepth,
+		"SEED_CRAWL_BUDGET":       &cfg.SeedCrawlBudget,
+		"PAGERANK_ITERATIONS":     &cfg.PageRankIterations,
+		"PUBLISH_TOP_N":           &cfg.PublishTopN,
+		"PRUNE_INACTIVE_MONTHS":   &cfg.PruneInactiveMonths,
+		"MAX_FOLLOWS":             &cfg.MaxFollows,
+		"SNAPSHOT_RETENTION":      &cfg.SnapshotRetention,
+		"SNAPSHOT_RETENTION_DAYS": &cfg.SnapshotRetentionDays,
+	} {
+		if v := os.Getenv(env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return cfg, fmt.Errorf("%s: not an integer", env)
+			}
+			*field = n
+		}
+	}
+	if v := os.Getenv("PUBLISH_LISTS"); v != "" {
+		cfg.PublishLists = nil
+		for _, s := range splitCommaList(v) {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return cfg, fmt.Errorf("PUBLISH_LISTS: not a list of integers")
+			}
+			cfg.PublishLists = append(cfg.PublishLists, n)
+		}
+	}
+	for env, field := range map[string]*float64{
+		"PAGERANK_DAMPING":           &cfg.Damping,
+		"SPAM_REPORT_MIN_CONFIDENCE": &cfg.SpamReportMinConfidence,
+	} {
+		if v := os.Getenv(env); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return cfg, fmt.Errorf("%s: not a number", env)
+			}
+			*field = f
+		}
+	}
+	for env, field := range map[string]*bool{
+		"PUBLISH_SPAM_REPORTS": &cfg.PublishSpamReports,
+		"PUBLISH_BADGES":       &cfg.PublishBadges,
+		"DM_NOTIFICATIONS":     &cfg.DMNotifications,
+		"NOSTR_QUERIES":        &cfg.NostrQueries,
+	} {
+		if v := os.Getenv(env); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return cfg, fmt.Errorf("%s: not true or false", env)
+			}
+			*field = b
+		}
+	}
+	for env, field := range map[string]*string{
+		"SEEDS_FILE":          &cfg.SeedsFile,
+		"SCORE_NORMALIZATION": &cfg.Normalization,
+		"KEY_PROVIDER":        &cfg.KeyProvider,
+		"KEY_FILE":            &cfg.KeyFile,
+		"KEY_COMMAND":         &cfg.KeyCommand,
+		"KEYCHAIN_SERVICE":    &cfg.KeychainService,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*field = v
+		}
+	}
+	return cfg, cfg.normalize()
+}
+
+// normalize adds the seeds file to the seeds, resolves npub seeds to hex and checks
+// every setting is usable.
+func (c *Config) normalize() error {
+	listed := c.Seeds
+	if c.SeedsFile != "" {
+		fromFile, err := readSeedsFile(c.SeedsFile)
+		if err != nil {
+			return fmt.Errorf("seeds_file: %w", err)
+		}
+		listed = append(append([]string(nil), listed...), fromFile...)
+	}
+	if len(listed) == 0 {
+		return fmt.Errorf("at least one seed is required")
+	}
+	seeds := make([]string, 0, len(listed))
+	unique := make(map[string]bool, len(listed))
+	for _, s := range listed {
+		pk, err := resolvePubkey(s)
+		if err != nil || !hex64Pattern.MatchString(pk) {
+			return fmt.Errorf("invalid seed %q", s)
+		}
+		if !unique[pk] {
+			unique[pk] = true
+			seeds = append(seeds, pk)
+		}
+	}
+	c.Seeds = seeds
+	if len(c.Relays) == 0 {
+		return fmt.Errorf("at least one relay is required")
+	}
+	for _, r := range c.Relays {
+		if !strings.HasPrefix(r, "wss://") && !strings.HasPrefix(r, "ws://") {
+			return fmt.Errorf("invalid relay %q", r)
+		}
+	}
+	if c.CrawlDepth < 1 || c.CrawlDepth > 4 {
+		return fmt.Errorf("crawl_depth must be between 1 and 4")
+	}
+	if c.SeedCrawlBudget < 0 {
+		return fmt.Errorf("seed_crawl_budget must not be negative")
+	}
+	if c.PageRankIterations < 1 || c.PageRankIterations > 200 {
+		return fmt.Errorf("pagerank_iterations must be between 1 and 200")
+	}
+	if c.Damping <= 0 || c.Damping >= 1 {
+		return fmt.Errorf("damping must be between 0 and 1")
+	}
+	if c.PublishTopN < 1 || c.PublishTopN > 1000000 {
+		return fmt.Errorf("publish_top_n must be between 1 and 1000000")
+	}
+	if c.PruneInactiveMonths < 0 || c.PruneInactiveMonths > 120 {
+		return fmt.Errorf("prune_inactive_months must be between 0 and 120")
+	}
+	if c.MaxFollows < 0 {
+		return fmt.Errorf("max_follows must not be negative")
+	}
+	if c.SnapshotRetention < 0 || c.SnapshotRetention > maxScoreSnapshots {
+		return fmt.Errorf("snapshot_retention must be between 0 and %d", maxScoreSnapshots)
+	}
+	if c.SnapshotRetentionDays < 0 || c.SnapshotRetentionDays > 3650 {
+		return fmt.Errorf("snapshot_retention_days must be between 0 and 3650")
+	}
+	if len(c.PublishLists) > maxPeopleLists {
+		return fmt.Errorf("publish_lists takes at most %d thresholds", maxPeopleLists)
+	}
+	seen := make(map[int]bool)
+	lists := make([]int, 0, len(c.PublishLists))
+	for _, t := range c.PublishLists {
+		if t < 1 || t > 100 {
+			return fmt.Errorf("publish_lists thresholds must be between 1 and 100")
+		}
+		if !seen[t] {
+			seen[t] = true
+			lists = append(lists, t)
+		}
+	}
+	sort.Ints(lists)
+	c.PublishLists = lists
+	if c.SpamReportMinConfidence < spamReportFloor || c.SpamReportMinConfidence > 1 {
+		return fmt.Errorf("spam_report_min_confidence must be between %g and 1", spamReportFloor)
+	}
+	if _, ok := normalizationCurves[c.Normalization]; !ok {
+		return fmt.Errorf("normalization must be log, percentile, zscore or minmax")
+	}
+	if err := normalizeViews(c.Views); err != nil {
+		return err
+	}
+	switch c.KeyProvider {
+	case "", "env", "1password":
+	case "file":
+		if c.KeyFile == "" {
+			return fmt.Errorf("key_provider file needs key_file")
+		}
+	case "command":
+		if c.KeyCommand == "" {
+			return fmt.Errorf("key_provider command needs key_command")
+		}
+	case "keychain":
+		if c.KeychainService == "" {
+			return fmt.Errorf("key_provider keychain needs keychain_service")
+		}
+	default:
+		return fmt.Errorf("key_provider must be env, file, keychain, command or 1password")
+	}
+	return nil
+}
+
+// parseConfig applies the key = value lines in src to cfg. Unknown keys are an
+// error so typos don't silently fall back to defaults. A [views.<name>] line
+// starts a seed view; the keys after it, up to the next one, set that view.
+func parseConfig(src string, cfg *Config) error {
+	lines := strings.Split(src, "\n")
+	view := -1 // index in cfg.Views of the table being read
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := stripConfigComment(lines[i])
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") && !strings.Contains(line, "=") {
+			name, ok := strings.CutPrefix(strings.TrimSpace(line[1:len(line)-1]), "views.")
+			if !ok {
+				return fmt.Errorf("line %d: unknown table %s", lineNo, line)
+			}
+			cfg.Views = append(cfg.Views, SeedView{Name: name})
+			view = len(cfg.Views) - 1
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		// arrays may continue over the following lines
+		for strings.HasPrefix(value, "[") && !strings.HasSuffix(value, "]") {
+			i++
+			if i >= len(lines) {
+				return fmt.Errorf("line %d: unterminated array", lineNo)
+			}
+			value += " " + stripConfigComment(lines[i])
+		}
+
+		var err error
+		if view >= 0 {
+			v := &cfg.Views[view]
+			switch key {
+			case "seeds":
+				v.Seeds, err = parseConfigStrings(value)
+			case "crawl_depth":
+				v.CrawlDepth, err = strconv.Atoi(value)
+			case "rebuild_hours":
+				v.RebuildHours, err = strconv.Atoi(value)
+			default:
+				return fmt.Errorf("line %d: unknown view key %q", lineNo, key)
+			}
+			if err != nil {
+				return fmt.Errorf("line %d: invalid %s", lineNo, key)
+			}
+			continue
+		}
+		switch key {
+		case "seeds":
+			cfg.Seeds, err = parseConfigStrings(value)
+		case "seeds_file":
+			cfg.SeedsFile, err = strconv.Unquote(value)
+		case "relays":
+			cfg.Relays, err = parseConfigStrings(value)
+		case "crawl_depth":
+			cfg.CrawlDepth, err = strconv.Atoi(value)
+		case "seed_crawl_budget":
+			cfg.SeedCrawlBudget, err = strconv.Atoi(value)
+		case "pagerank_iterations":
+			cfg.PageRankIterations, err = strconv.Atoi(value)
+		case "damping":
+			cfg.Damping, err = strconv.ParseFloat(value, 64)
+		case "publish_top_n":
+			cfg.PublishTopN, err = strconv.Atoi(value)
+		case "publish_lists":
+			cfg.PublishLists, err = parseConfigInts(value)
+		case "publish_spam_reports":
+			cfg.PublishSpamReports, err = strconv.ParseBool(value)
+		case "spam_report_min_confidence":
+			cfg.SpamReportMinConfidence, err = strconv.ParseFloat(value, 64)
+		case "publish_badges":
+			cfg.PublishBadges, err = strconv.ParseBool(value)
+		case "dm_notifications":
+			cfg.DMNotifications, err = strconv.ParseBool(value)
+		case "nostr_queries":
+			cfg.NostrQueries, err = strconv.ParseBool(value)
+		case "normalization":
+			cfg.Normalization, err = strconv.Unquote(value)
+		case "prune_inactive_months":
+			cfg.PruneInactiveMonths, err = strconv.Atoi(value)
+		case "snapshot_retention":
+			cfg.SnapshotRetention, err = strconv.Atoi(value)
+		case "snapshot_retention_days":
+			cfg.SnapshotRetentionDays, err = strconv.Atoi(value)
+		case "max_follows":
+			cfg.MaxFollows, err = strconv.Atoi(value)
+		case "key_provider":
+			cfg.KeyProvider, err = strconv.Unquote(value)
+		case "key_file":
+			cfg.KeyFile, err = strconv.Unquote(value)
+		case "key_command":
+			cfg.KeyCommand, err = strconv.Unquote(value)
+		case "keychain_service":
+			cfg.KeychainService, err = strconv.Unquote(value)
+		default:
+			return fmt.Errorf("line %d: unknown key %q", lineNo, key)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: invalid %s", lineNo, key)
+		}
+	}
+	return nil
+}
+
+// stripConfigComment drops a # comment that isn't inside a quoted string.
+func stripConfigComment(line string) string {
+	inString := false
+	for i, c := range line {
+		switch {
+		case c == '"':
+			inString = !inString
+		case c == '#' && !inString:
+			line = line[:i]
+			return strings.TrimSpace(line)
+		}
+	}
+	return strings.TrimSpace(line)
+}
+
+func parseConfigInts(value string) ([]int, error) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("expected an array")
+	}
+	var out []int
+	for _, item := range strings.Split(value[1:len(value)-1], ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue // trailing comma
+		}
+		n, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+func parseConfigStrings(value string) ([]string, error) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("expected an array")
+	}
+	var out []string
+	for _, item := range strings.Split(value[1:len(value)-1], ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue // trailing comma
+		}
+		s, err := strconv.Unquote(item)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// ConfigStore holds the active Config. Reload swaps it atomically; readers take a
+// copy with Get, so a reload never changes settings under a running rebuild phase.
+type ConfigStore struct {
+	mu        sync.RWMutex
+	cfg       Config
+	path      string
+	modTime   time.Time
+	loadedAt  time.Time
+	lastError string
+}
+
+func NewConfigStore(path string) *ConfigStore {
+	return &ConfigStore{cfg: defaultConfig, path: path}
+}
+
+var config = NewConfigStore(os.Getenv("CONFIG_FILE"))
+
+// Get returns the active config. Its slices are replaced, never modified, on reload.
+func (s *ConfigStore) Get() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// Relays returns the active relay list.
+func (s *ConfigStore) Relays() []string {
+	return s.Get().Relays
+}
+
+// Reload re-reads the file and environment. An invalid config is logged and
+// reported by Status, and the previous config stays active.
+func (s *ConfigStore) Reload() error {
+	var modTime time.Time
+	if s.path != "" {
+		if fi, err := os.Stat(s.path); err == nil {
+			modTime = fi.ModTime()
+		}
+	}
+	cfg, err := LoadConfig(s.path)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.modTime = modTime
+	if err != nil {
+		s.lastError = err.Error()
+		return err
+	}
+	s.cfg = cfg
+	s.loadedAt = time.Now()
+	s.lastError = ""
+	return nil
+}
+
+// changed reports whether the file's modification time differs from the last load.
+func (s *ConfigStore) changed() bool {
+	if s.path == "" {
+		return false
+	}
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !fi.ModTime().Equal(s.modTime)
+}
+
+// Watch reloads the config when the file changes (checked every interval) or the
+// process receives SIGHUP, until ctx is done.
+func (s *ConfigStore) Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.changed() {
+				continue
+			}
+		case <-hup:
+		}
+		if err := s.Reload(); err != nil {
+			log.Printf("Config reload failed, keeping previous config: %v", err)
+			continue
+		}
+		cfg := s.Get()
+		log.Printf("Config reloaded: %d seeds, %d relays, depth %d, %d iterations, damping %.2f (applies from the next rebuild)",
+			len(cfg.Seeds), len(cfg.Relays), cfg.CrawlDepth, cfg.PageRankIterations, cfg.Damping)
+	}
+}
+
+// ConfigStatus is the config section of /stats.
+type ConfigStatus struct {
+	Config
+	File      string     `json:"file,omitempty"`
+	LoadedAt  *time.Time `json:"loaded_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+func (s *ConfigStore) Status() ConfigStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st := ConfigStatus{Config: s.cfg, File: s.path, LastError: s.lastError}
+	if !s.loadedAt.IsZero() {
+		t := s.loadedAt
+		st.LoadedAt = &t
+	}
+	return st
+}
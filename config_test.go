@@ -0,0 +1,214 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func TestLoadConfigFile(t *testing.T) {
+	npub, _ := nip19.EncodePublicKey(padHex(38002))
+	path := filepath.Join(t.TempDir(), "wot-scoring.toml")
+	os.WriteFile(path, []byte(`# our own instance
+seeds = [
+  "`+padHex(38001)+`", # alice
+  "`+npub+`",
+]
+relays = ["wss://relay.example#main", "wss://other.example"]
+crawl_depth = 1
+damping = 0.9
+publish_top_n = 2000
+publish_lists = [50, 20, 50]
+prune_inactive_months = 18
+max_follows = 5000
+publish_spam_reports = true
+spam_report_min_confidence = 0.95
+publish_badges = true
+dm_notifications = true
+nostr_queries = true
+snapshot_retention = 8
+snapshot_retention_days = 30
+key_provider = "file"
+key_file = "/etc/wot-scoring/key.ncryptsec"
+`), 0o644)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Seeds) != 2 || cfg.Seeds[1] != padHex(38002) {
+		t.Errorf("expected two hex seeds, got %v", cfg.Seeds)
+	}
+	if len(cfg.Relays) != 2 || cfg.Relays[0] != "wss://relay.example#main" {
+		t.Errorf("expected # inside strings kept, got %v", cfg.Relays)
+	}
+	if cfg.CrawlDepth != 1 || cfg.Damping != 0.9 || cfg.PublishTopN != 2000 || cfg.PageRankIterations != defaultConfig.PageRankIterations ||
+		cfg.KeyProvider != "file" || cfg.KeyFile != "/etc/wot-scoring/key.ncryptsec" || cfg.PruneInactiveMonths != 18 || cfg.MaxFollows != 5000 ||
+		!cfg.PublishSpamReports || cfg.SpamReportMinConfidence != 0.95 || !cfg.PublishBadges || !cfg.DMNotifications || !cfg.NostrQueries ||
+		cfg.SnapshotRetention != 8 || cfg.SnapshotRetentionDays != 30 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if len(cfg.PublishLists) != 2 || cfg.PublishLists[0] != 20 || cfg.PublishLists[1] != 50 {
+		t.Errorf("expected list thresholds sorted and deduplicated, got %v", cfg.PublishLists)
+	}
+
+	// environment overrides the file
+	t.Setenv("RELAYS", "wss://env.example")
+	t.Setenv("PAGERANK_ITERATIONS", "30")
+	t.Setenv("PUBLISH_LISTS", "10")
+	t.Setenv("PUBLISH_SPAM_REPORTS", "false")
+	t.Setenv("PUBLISH_BADGES", "0")
+	t.Setenv("SNAPSHOT_RETENTION", "0")
+	cfg, err = LoadConfig(path)
+	if err != nil || len(cfg.Relays) != 1 || cfg.Relays[0] != "wss://env.example" || cfg.PageRankIterations != 30 || cfg.CrawlDepth != 1 ||
+		len(cfg.PublishLists) != 1 || cfg.PublishLists[0] != 10 || cfg.PublishSpamReports || cfg.PublishBadges || cfg.SnapshotRetention != 0 {
+		t.Errorf("expected env overrides, got %+v (%v)", cfg, err)
+	}
+
+	if cfg, err := LoadConfig(""); err != nil || len(cfg.Seeds) != len(defaultConfig.Seeds) {
+		t.Errorf("expected defaults without a file, got %+v (%v)", cfg, err)
+	}
+}
+
+func TestLoadConfigViews(t *testing.T) {
+	npub, _ := nip19.EncodePublicKey(padHex(38006))
+	path := filepath.Join(t.TempDir(), "wot-scoring.toml")
+	os.WriteFile(path, []byte(`crawl_depth = 3
+
+[views.bitcoin-devs]   # protocol people
+seeds = ["`+padHex(38005)+`", "`+npub+`"]
+rebuild_hours = 12
+
+[views.artists]
+seeds = ["`+npub+`"]
+crawl_depth = 1
+`), 0o644)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.CrawlDepth != 3 || len(cfg.Views) != 2 {
+		t.Fatalf("expected two views and the top-level key kept, got %+v", cfg)
+	}
+	devs, artists := cfg.Views[0], cfg.Views[1]
+	if devs.Name != "bitcoin-devs" || len(devs.Seeds) != 2 || devs.Seeds[1] != padHex(38006) || devs.CrawlDepth != 2 || devs.RebuildHours != 12 {
+		t.Errorf("expected npub seeds resolved and depth defaulted, got %+v", devs)
+	}
+	if artists.Name != "artists" || artists.CrawlDepth != 1 || artists.RebuildHours != 6 {
+		t.Errorf("expected the rebuild interval defaulted, got %+v", artists)
+	}
+}
+
+func TestLoadConfigSeedsFile(t *testing.T) {
+	npub, _ := nip19.EncodePublicKey(padHex(38004))
+	dir := t.TempDir()
+	seedsPath := filepath.Join(dir, "seeds.txt")
+	os.WriteFile(seedsPath, []byte(`# community seeds
+`+padHex(38003)+`
+`+npub+`  # carol
+
+`+padHex(38001)+`
+`), 0o644)
+	path := filepath.Join(dir, "config.toml")
+	os.WriteFile(path, []byte(`seeds = ["`+padHex(38001)+`"]
+seeds_file = "`+seedsPath+`"
+seed_crawl_budget = 2000
+`), 0o644)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Seeds) != 3 || cfg.Seeds[0] != padHex(38001) || cfg.Seeds[2] != padHex(38004) {
+		t.Errorf("expected the file's seeds added once each, got %v", cfg.Seeds)
+	}
+	if cfg.SeedCrawlBudget != 2000 {
+		t.Errorf("expected the crawl budget, got %d", cfg.SeedCrawlBudget)
+	}
+
+	t.Setenv("SEED_CRAWL_BUDGET", "50")
+	os.WriteFile(path, []byte(`seeds = []
+seeds_file = "`+seedsPath+`"
+`), 0o644)
+	if cfg, err := LoadConfig(path); err != nil || len(cfg.Seeds) != 3 || cfg.SeedCrawlBudget != 50 {
+		t.Errorf("expected seeds from the file alone and the env budget, got %+v (%v)", cfg, err)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"unknown key":    `seed = ["x"]`,
+		"bad value":      `crawl_depth = two`,
+		"unterminated":   `relays = ["wss://a.example",`,
+		"bad seed":       `seeds = ["nobody"]`,
+		"bad relay":      `relays = ["https://a.example"]`,
+		"damping range":  `damping = 1.5`,
+		"no relays":      `relays = []`,
+		"top n range":    `publish_top_n = 0`,
+		"list range":     `publish_lists = [0]`,
+		"list value":     `publish_lists = ["20"]`,
+		"too many lists": `publish_lists = [1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11]`,
+		"key provider":   `key_provider = "vault"`,
+		"no key file":    `key_provider = "file"`,
+		"normalization":  `normalization = "sigmoid"`,
+		"prune range":    `prune_inactive_months = 200`,
+		"max follows":    `max_follows = -1`,
+		"seed budget":    `seed_crawl_budget = -1`,
+		"seeds file":     `seeds_file = "/nonexistent/seeds.txt"`,
+		"spam reports":   `publish_spam_reports = yes`,
+		"spam threshold": `spam_report_min_confidence = 0.5`,
+		"badges":         `publish_badges = "on"`,
+		"snapshots":      `snapshot_retention = 5000`,
+		"snapshot age":   `snapshot_retention_days = -1`,
+		"unknown table":  "[seeds]",
+		"view key":       "[views.artists]\nseeds = [\"" + padHex(38001) + "\"]\ndamping = 0.9",
+		"view name":      "[views.Artists]\nseeds = [\"" + padHex(38001) + "\"]",
+		"view seeds":     "[views.artists]\ncrawl_depth = 1",
+		"view depth":     "[views.artists]\nseeds = [\"" + padHex(38001) + "\"]\ncrawl_depth = 5",
+		"view twice":     "[views.a]\nseeds = [\"" + padHex(38001) + "\"]\n[views.a]\nseeds = [\"" + padHex(38001) + "\"]",
+	} {
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte(body), 0o644)
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "missing.toml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestConfigStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("crawl_depth = 1\n"), 0o644)
+	s := NewConfigStore(path)
+	if s.Get().CrawlDepth != defaultConfig.CrawlDepth {
+		t.Fatal("expected defaults before the first load")
+	}
+	if err := s.Reload(); err != nil || s.Get().CrawlDepth != 1 {
+		t.Fatalf("expected depth 1 loaded, got %d (%v)", s.Get().CrawlDepth, err)
+	}
+	if s.changed() {
+		t.Error("expected no change right after loading")
+	}
+
+	// a broken edit keeps the previous config and reports the error
+	os.WriteFile(path, []byte("crawl_depth = 9\n"), 0o644)
+	os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	if !s.changed() {
+		t.Error("expected the edit detected")
+	}
+	if err := s.Reload(); err == nil || s.Get().CrawlDepth != 1 || s.Status().LastError == "" {
+		t.Errorf("expected the invalid edit rejected, got depth %d status %+v", s.Get().CrawlDepth, s.Status())
+	}
+
+	os.WriteFile(path, []byte("crawl_depth = 3\n"), 0o644)
+	if err := s.Reload(); err != nil || s.Get().CrawlDepth != 3 || s.Status().LastError != "" || s.Status().LoadedAt == nil {
+		t.Errorf("expected the fixed config applied, got %+v", s.Status())
+	}
+}
@@ -189,7 +189,7 @@ func consumeExternalAssertions(ctx context.Context, store *AssertionStore, ownPu
 	total := 0
 	skippedOwn := 0
 
-	for ev := range pool.SubManyEose(ctx, relays, nostr.Filters{filter}) {
+	for ev := range pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter}) {
 		// Skip our own assertions
 		if ev.Event.PubKey == ownPubkey {
 			skippedOwn++
@@ -199,6 +199,7 @@ func consumeExternalAssertions(ctx context.Context, store *AssertionStore, ownPu
 		a := parseAssertion(ev.Event)
 		if a != nil {
 			store.Add(a)
+			assertionArchive.Put(ev.Event)
 			total++
 		}
 	}
@@ -243,32 +244,58 @@ func parseAssertion(ev *nostr.Event) *ExternalAssertion {
 	return a
 }
 
+// ProvidersResponse is the /providers body: the NIP-85 providers whose
+// assertions we consume.
+type ProvidersResponse struct {
+	Providers       []*ProviderInfo `json:"providers"`
+	ProviderCount   int             `json:"provider_count"`
+	TotalAssertions int             `json:"total_assertions"`
+}
+
+// ExternalSource is one provider's assertion in a composite score breakdown.
+type ExternalSource struct {
+	Provider       string  `json:"provider"`
+	RawRank        int     `json:"raw_rank"`
+	NormalizedRank int     `json:"normalized_rank"`
+	Weight         float64 `json:"weight"` // the provider's measured accuracy
+	Age            string  `json:"age"`
+}
+
 // CompositeScore blends our internal score with external assertions.
-// It normalizes each provider's rank to 0-100 using their observed scale.
+// It normalizes each provider's rank to 0-100 using their observed scale and,
+// when a store is given, weights each provider by its measured accuracy.
 // Returns the composite score and a breakdown of sources.
-func CompositeScore(internalScore int, externalAssertions []*ExternalAssertion, store *AssertionStore) (int, []map[string]interface{}) {
+func CompositeScore(internalScore int, externalAssertions []*ExternalAssertion, store *AssertionStore) (int, []ExternalSource) {
 	if len(externalAssertions) == 0 {
 		return internalScore, nil
 	}
 
-	// Weight: 70% internal, 30% external average (normalized to 0-100)
-	normalizedSum := 0
-	sources := make([]map[string]interface{}, len(externalAssertions))
+	// Weight: 70% internal, 30% accuracy-weighted external average (normalized to 0-100)
+	var weightedSum, totalWeight float64
+	sources := make([]ExternalSource, len(externalAssertions))
 	for i, a := range externalAssertions {
 		var provider *ProviderInfo
+		weight := 1.0
 		if store != nil {
 			provider = store.GetProvider(a.ProviderPubkey)
+			weight = providerAccuracy.Weight(a.ProviderPubkey)
 		}
 		norm := NormalizeRank(a.Rank, provider)
-		normalizedSum += norm
-		sources[i] = map[string]interface{}{
-			"provider":        a.ProviderPubkey,
-			"raw_rank":        a.Rank,
-			"normalized_rank": norm,
-			"age":             fmt.Sprintf("%ds", time.Now().Unix()-a.CreatedAt),
+		weightedSum += float64(norm) * weight
+		totalWeight += weight
+		sources[i] = ExternalSource{
+			Provider:       a.ProviderPubkey,
+			RawRank:        a.Rank,
+			NormalizedRank: norm,
+			Weight:         weight,
+			Age:            fmt.Sprintf("%ds", time.Now().Unix()-a.CreatedAt),
 		}
 	}
-	externalAvg := float64(normalizedSum) / float64(len(externalAssertions))
+	if totalWeight == 0 {
+		// Every provider disagrees with our graph; ignore them
+		return internalScore, sources
+	}
+	externalAvg := weightedSum / totalWeight
 
 	composite := int(float64(internalScore)*0.7 + externalAvg*0.3)
 	if composite > 100 {
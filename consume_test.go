@@ -225,8 +225,8 @@ func TestCompositeScoreNormalizesLargeRanks(t *testing.T) {
 	if score < 60 || score > 70 {
 		t.Errorf("expected ~65 after normalization, got %d", score)
 	}
-	if sources[0]["normalized_rank"].(int) < 90 {
-		t.Errorf("expected normalized_rank >= 90 for max provider value, got %v", sources[0]["normalized_rank"])
+	if sources[0].NormalizedRank < 90 {
+		t.Errorf("expected normalized_rank >= 90 for max provider value, got %v", sources[0].NormalizedRank)
 	}
 }
 
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// maxContactVersions caps the contact list versions kept per pubkey; older ones
+	// are folded into the base list.
+	maxContactVersions = 20
+	// A version is a mass unfollow when it drops at least massUnfollowMin follows and
+	// at least massUnfollowShare of the previous list.
+	massUnfollowMin   = 20
+	massUnfollowShare = 0.5
+	// contactDiffLimit caps the added/removed pubkeys listed per version.
+	contactDiffLimit = 100
+)
+
+// ContactVersion is one observed kind 3 contact list. Only the first kept version
+// holds a full list; later ones store what changed from the version before.
+type ContactVersion struct {
+	EventID    string
+	CreatedAt  time.Time
+	ObservedAt time.Time
+	Source     string // crawl, hint, ingest
+	Count      int
+	added      []string
+	removed    []string
+}
+
+type contactTimeline struct {
+	base     []string
+	versions []*ContactVersion // oldest first; versions[0] is base
+}
+
+// lists reconstructs the full follow list of every version.
+func (t *contactTimeline) lists() [][]string {
+	out := make([][]string, len(t.versions))
+	cur := t.base
+	for i, v := range t.versions {
+		if i > 0 {
+			gone := make(map[string]bool, len(v.removed))
+			for _, pk := range v.removed {
+				gone[pk] = true
+			}
+			next := make([]string, 0, len(cur)+len(v.added))
+			for _, pk := range cur {
+				if !gone[pk] {
+					next = append(next, pk)
+				}
+			}
+			cur = append(next, v.added...)
+		}
+		out[i] = cur
+	}
+	return out
+}
+
+// contactDiff returns the pubkeys in next but not prev, and in prev but not next.
+func contactDiff(prev, next []string) (added, removed []string) {
+	inPrev := make(map[string]bool, len(prev))
+	for _, pk := range prev {
+		inPrev[pk] = true
+	}
+	inNext := make(map[string]bool, len(next))
+	for _, pk := range next {
+		inNext[pk] = true
+		if !inPrev[pk] {
+			added = append(added, pk)
+		}
+	}
+	for _, pk := range prev {
+		if !inNext[pk] {
+			removed = append(removed, pk)
+		}
+	}
+	return added, removed
+}
+
+// ContactHistory keeps the contact list versions observed for each pubkey by the
+// crawler, hints, and partner ingestion, as a base list plus per-version diffs.
+type ContactHistory struct {
+	mu      sync.RWMutex
+	data    map[string]*contactTimeline
+	now     func() time.Time
+	follows *FollowLog
+}
+
+// NewContactHistory returns an empty history. If follows is not nil, each new
+// version's follows and unfollows are logged to it.
+func NewContactHistory(follows *FollowLog) *ContactHistory {
+	return &ContactHistory{data: make(map[string]*contactTimeline), now: time.Now, follows: follows}
+}
+
+var contactHistory = NewContactHistory(followLog)
+
+// Record adds a kind 3 event as a version of its author's contact list. Versions
+// may arrive out of order; they are kept sorted by created_at. It returns false if
+// the event was already recorded.
+func (h *ContactHistory) Record(ev *nostr.Event, source string) bool {
+	if ev == nil || ev.Kind != 3 {
+		return false
+	}
+	follows := make([]string, 0, len(ev.Tags))
+	seen := make(map[string]bool)
+	for _, pk := range contactListFollows(ev) {
+		if pk != ev.PubKey && !seen[pk] {
+			seen[pk] = true
+			follows = append(follows, pk)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t := h.data[ev.PubKey]
+	if t == nil {
+		t = &contactTimeline{}
+		h.data[ev.PubKey] = t
+	}
+	for _, v := range t.versions {
+		if v.EventID == ev.ID {
+			return false
+		}
+	}
+
+	lists := t.lists()
+	v := &ContactVersion{
+		EventID:    ev.ID,
+		CreatedAt:  ev.CreatedAt.Time(),
+		ObservedAt: h.now(),
+		Source:     source,
+		Count:      len(follows),
+	}
+	i := sort.Search(len(t.versions), func(i int) bool {
+		return t.versions[i].CreatedAt.After(v.CreatedAt)
+	})
+	// only a version newer than every other is a change the author made since;
+	// an older one arriving late just fills in the past
+	newest := i > 0 && i == len(t.versions)
+	t.versions = append(t.versions, nil)
+	copy(t.versions[i+1:], t.versions[i:])
+	t.versions[i] = v
+	lists = append(lists, nil)
+	copy(lists[i+1:], lists[i:])
+	lists[i] = follows
+
+	if len(t.versions) > maxContactVersions {
+		drop := len(t.versions) - maxContactVersions
+		t.versions, lists = t.versions[drop:], lists[drop:]
+	}
+	t.base = lists[0]
+	t.versions[0].added, t.versions[0].removed = nil, nil
+	for j := 1; j < len(t.versions); j++ {
+		t.versions[j].added, t.versions[j].removed = contactDiff(lists[j-1], lists[j])
+	}
+	if newest && h.follows != nil {
+		h.follows.Record(ev.PubKey, v.EventID, v.CreatedAt, v.added, v.removed)
+	}
+	return true
+}
+
+// ContactVersionView is one contact list version in a /contacts/snapshot response.
+type ContactVersionView struct {
+	EventID      string   `json:"event_id"`
+	CreatedAt    string   `json:"created_at"`
+	ObservedAt   string   `json:"observed_at"`
+	Source       string   `json:"source"`
+	FollowCount  int      `json:"follow_count"`
+	AddedCount   int      `json:"added_count"`
+	RemovedCount int      `json:"removed_count"`
+	Added        []string `json:"added,omitempty"`
+	Removed      []string `json:"removed,omitempty"`
+	MassUnfollow bool     `json:"mass_unfollow"`
+}
+
+// ContactSnapshot is the full follow list of one version, with an unsigned kind 3
+// template the owner can sign and publish to restore it.
+type ContactSnapshot struct {
+	EventID   string      `json:"event_id"`
+	CreatedAt string      `json:"created_at"`
+	Follows   []string    `json:"follows"`
+	Restore   nostr.Event `json:"restore_event"`
+}
+
+// ContactHistoryResponse is the response for GET /contacts/snapshot.
+type ContactHistoryResponse struct {
+	Pubkey        string               `json:"pubkey"`
+	VersionCount  int                  `json:"version_count"`
+	MassUnfollows int                  `json:"mass_unfollows"`
+	Versions      []ContactVersionView `json:"versions"` // newest first
+	Snapshot      *ContactSnapshot     `json:"snapshot,omitempty"`
+}
+
+// View returns pubkey's versions newest first and, when want selects a version, its
+// full list. want is called with each version's event id and created_at, newest
+// first, and the first match is returned. ok is false if nothing was recorded.
+func (h *ContactHistory) View(pubkey string, want func(id string, at time.Time) bool) (resp ContactHistoryResponse, ok bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	t := h.data[pubkey]
+	if t == nil || len(t.versions) == 0 {
+		return resp, false
+	}
+
+	lists := t.lists()
+	resp.Pubkey = pubkey
+	resp.VersionCount = len(t.versions)
+	for i := len(t.versions) - 1; i >= 0; i-- {
+		v := t.versions[i]
+		view := ContactVersionView{
+			EventID:      v.EventID,
+			CreatedAt:    v.CreatedAt.UTC().Format(time.RFC3339),
+			ObservedAt:   v.ObservedAt.UTC().Format(time.RFC3339),
+			Source:       v.Source,
+			FollowCount:  v.Count,
+			AddedCount:   len(v.added),
+			RemovedCount: len(v.removed),
+			Added:        capStrings(v.added, contactDiffLimit),
+			Removed:      capStrings(v.removed, contactDiffLimit),
+		}
+		if i > 0 {
+			prev := t.versions[i-1].Count
+			view.MassUnfollow = len(v.removed) >= massUnfollowMin && float64(len(v.removed)) >= massUnfollowShare*float64(prev)
+		}
+		if view.MassUnfollow {
+			resp.MassUnfollows++
+		}
+		resp.Versions = append(resp.Versions, view)
+
+		if resp.Snapshot == nil && want != nil && want(v.EventID, v.CreatedAt) {
+			follows := append([]string(nil), lists[i]...)
+			restore := nostr.Event{Kind: 3, PubKey: pubkey, Tags: make(nostr.Tags, 0, len(follows))}
+			for _, pk := range follows {
+				restore.Tags = append(restore.Tags, nostr.Tag{"p", pk})
+			}
+			resp.Snapshot = &ContactSnapshot{
+				EventID:   v.EventID,
+				CreatedAt: view.CreatedAt,
+				Follows:   follows,
+				Restore:   restore,
+			}
+		}
+	}
+	return resp, true
+}
+
+func capStrings(s []string, n int) []string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// handleContactsSnapshot serves GET /contacts/snapshot?pubkey=: the contact list
+// versions observed for pubkey, newest first, with what each added and removed and
+// mass unfollows flagged. With event_id=, or before= (unix seconds or RFC 3339) for
+// the newest version created before that time, the full list of that version is
+// included with a kind 3 template for restoring it.
+func handleContactsSnapshot(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	raw := q.Get("pubkey")
+	if raw == "" {
+		writeAPIError(w, missingParam("pubkey"))
+		return
+	}
+	pubkey, err := resolvePubkey(raw)
+	if err != nil || !hex64Pattern.MatchString(pubkey) {
+		writeAPIError(w, invalidParam("pubkey", "pubkey must be 64 hex characters or an npub"))
+		return
+	}
+
+	var want func(id string, at time.Time) bool
+	switch {
+	case q.Get("event_id") != "" && q.Get("before") != "":
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "use either event_id or before, not both"})
+		return
+	case q.Get("event_id") != "":
+		id := q.Get("event_id")
+		want = func(eid string, _ time.Time) bool { return eid == id }
+	case q.Get("before") != "":
+		before, err := parseContactTime(q.Get("before"))
+		if err != nil {
+			writeAPIError(w, invalidParam("before", "before must be unix seconds or RFC 3339"))
+			return
+		}
+		want = func(_ string, at time.Time) bool { return at.Before(before) }
+	}
+
+	resp, ok := contactHistory.View(pubkey, want)
+	if !ok {
+		writeAPIError(w, &APIError{Status: http.StatusNotFound, Message: "no contact list versions observed for pubkey"})
+		return
+	}
+	if want != nil && resp.Snapshot == nil {
+		writeAPIError(w, &APIError{Status: http.StatusNotFound, Message: "no matching contact list version"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func parseContactTime(s string) (time.Time, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", s)
+	}
+	return t, nil
+}
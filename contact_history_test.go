@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func followsEvent(t *testing.T, sk string, at time.Time, from, to int) *nostr.Event {
+	t.Helper()
+	ev := &nostr.Event{Kind: 3, CreatedAt: nostr.Timestamp(at.Unix())}
+	for i := from; i < to; i++ {
+		ev.Tags = append(ev.Tags, nostr.Tag{"p", padHex(i)})
+	}
+	if err := ev.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+func TestContactHistoryRecordsVersionsInOrder(t *testing.T) {
+	h := NewContactHistory(nil)
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	v1 := followsEvent(t, sk, start, 32000, 32030)                   // 30 follows
+	v2 := followsEvent(t, sk, start.Add(24*time.Hour), 32000, 32032) // +2
+	v3 := followsEvent(t, sk, start.Add(48*time.Hour), 32000, 32003) // -29
+	// the middle version arrives last
+	for _, ev := range []*nostr.Event{v1, v3, v2} {
+		if !h.Record(ev, "crawl") {
+			t.Fatalf("expected %s to be recorded", ev.ID)
+		}
+	}
+	if h.Record(v2, "ingest") {
+		t.Error("re-recording an event should be a no-op")
+	}
+
+	resp, ok := h.View(pub, func(id string, _ time.Time) bool { return id == v2.ID })
+	if !ok || resp.VersionCount != 3 {
+		t.Fatalf("expected 3 versions, got %+v", resp)
+	}
+	if resp.Versions[0].EventID != v3.ID || resp.Versions[2].EventID != v1.ID {
+		t.Error("expected versions newest first")
+	}
+	newest, middle := resp.Versions[0], resp.Versions[1]
+	if middle.AddedCount != 2 || middle.RemovedCount != 0 || middle.MassUnfollow {
+		t.Errorf("unexpected middle version diff %+v", middle)
+	}
+	if newest.RemovedCount != 29 || newest.FollowCount != 3 || !newest.MassUnfollow || resp.MassUnfollows != 1 {
+		t.Errorf("expected newest version flagged as mass unfollow, got %+v", newest)
+	}
+
+	snap := resp.Snapshot
+	if snap == nil || snap.EventID != v2.ID || len(snap.Follows) != 32 {
+		t.Fatalf("expected the 32-follow snapshot of v2, got %+v", snap)
+	}
+	if snap.Restore.Kind != 3 || snap.Restore.PubKey != pub || len(snap.Restore.Tags) != 32 || snap.Restore.Sig != "" {
+		t.Errorf("expected unsigned kind 3 restore event with 32 p tags, got %+v", snap.Restore)
+	}
+}
+
+func TestContactHistoryFoldsOldVersions(t *testing.T) {
+	h := NewContactHistory(nil)
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// each version follows one more account than the last
+	for i := 0; i < maxContactVersions+5; i++ {
+		h.Record(followsEvent(t, sk, start.Add(time.Duration(i)*time.Hour), 32100, 32101+i), "crawl")
+	}
+	resp, _ := h.View(pub, func(string, time.Time) bool { return true })
+	if resp.VersionCount != maxContactVersions {
+		t.Fatalf("expected %d versions kept, got %d", maxContactVersions, resp.VersionCount)
+	}
+	oldest := resp.Versions[len(resp.Versions)-1]
+	if oldest.FollowCount != 6 || oldest.AddedCount != 0 {
+		t.Errorf("expected the oldest kept version to be the base with 6 follows, got %+v", oldest)
+	}
+	if len(resp.Snapshot.Follows) != maxContactVersions+5 {
+		t.Errorf("expected newest list reconstructed from diffs, got %d follows", len(resp.Snapshot.Follows))
+	}
+}
+
+func TestHandleContactsSnapshot(t *testing.T) {
+	old := contactHistory
+	defer func() { contactHistory = old }()
+	contactHistory = NewContactHistory(nil)
+
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	v1 := followsEvent(t, sk, start, 32200, 32240)
+	v2 := followsEvent(t, sk, start.Add(time.Hour), 32200, 32201)
+	contactHistory.Record(v1, "crawl")
+	contactHistory.Record(v2, "hint")
+
+	get := func(query string) (*httptest.ResponseRecorder, ContactHistoryResponse) {
+		rr := httptest.NewRecorder()
+		handleContactsSnapshot(rr, httptest.NewRequest(http.MethodGet, "/contacts/snapshot?"+query, nil))
+		var resp ContactHistoryResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr, resp
+	}
+
+	rr, resp := get("pubkey=" + pub)
+	if rr.Code != http.StatusOK || resp.VersionCount != 2 || resp.Snapshot != nil {
+		t.Fatalf("expected 2 versions without snapshot, got %d %+v", rr.Code, resp)
+	}
+	if resp.Versions[0].Source != "hint" || len(resp.Versions[0].Removed) != 39 {
+		t.Errorf("unexpected newest version %+v", resp.Versions[0])
+	}
+
+	_, resp = get(fmt.Sprintf("pubkey=%s&before=%d", pub, start.Add(30*time.Minute).Unix()))
+	if resp.Snapshot == nil || resp.Snapshot.EventID != v1.ID || len(resp.Snapshot.Follows) != 40 {
+		t.Errorf("expected v1 snapshot before the wipe, got %+v", resp.Snapshot)
+	}
+	_, resp = get("pubkey=" + pub + "&event_id=" + v2.ID)
+	if resp.Snapshot == nil || len(resp.Snapshot.Follows) != 1 {
+		t.Errorf("expected v2 snapshot, got %+v", resp.Snapshot)
+	}
+
+	for query, code := range map[string]int{
+		"":                                    http.StatusBadRequest,
+		"pubkey=alice":                        http.StatusBadRequest,
+		"pubkey=" + pub + "&before=yesterday": http.StatusBadRequest,
+		"pubkey=" + pub + "&before=1&event_id=" + v1.ID: http.StatusBadRequest,
+		"pubkey=" + pub + "&before=1":                   http.StatusNotFound,
+		"pubkey=" + padHex(32299):                       http.StatusNotFound,
+	} {
+		if rr, _ := get(query); rr.Code != code {
+			t.Errorf("%q: expected %d, got %d", query, code, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// crawlJobTimeout bounds one on-demand crawl: contact list, profile and engagement.
+	crawlJobTimeout = 45 * time.Second
+	// crawlJobTTL is how long a finished job can still be polled.
+	crawlJobTTL = time.Hour
+	// crawlJobCooldown is how often one pubkey may be crawled on demand; a request
+	// within it gets the last job back instead of a new one.
+	crawlJobCooldown = 10 * time.Minute
+	// maxCrawlJobs caps the jobs kept for polling.
+	maxCrawlJobs = 1000
+	// crawlJobWorkers caps on-demand crawls running at once; the rest wait queued.
+	crawlJobWorkers = 4
+)
+
+// crawlRequestLimiter caps on-demand crawls per client IP, on top of the global rate
+// limit, since each one costs several relay round trips.
+var crawlRequestLimiter = NewRateLimiter(5, time.Minute)
+
+// CrawlJobContactList is the contact list step of an on-demand crawl.
+type CrawlJobContactList struct {
+	Status   string `json:"status"` // updated, unchanged, stale_event, not_found, failed
+	Follows  int    `json:"follows"`
+	Added    int    `json:"added"`
+	Removed  int    `json:"removed"`
+	Rescored int    `json:"rescored"`
+	EventAt  string `json:"event_at,omitempty"`
+}
+
+// CrawlJobProfile is the kind 0 profile found by an on-demand crawl.
+type CrawlJobProfile struct {
+	Status      string `json:"status"` // found, not_found, failed
+	Name        string `json:"name,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	NIP05       string `json:"nip05,omitempty"`
+	Lud16       string `json:"lud16,omitempty"`
+	EventAt     string `json:"event_at,omitempty"`
+}
+
+// CrawlJobEngagement is the metadata known for the pubkey after an on-demand crawl.
+// Status is "skipped" when its activity was already crawled, since recounting the
+// same notes and reactions would double them.
+type CrawlJobEngagement struct {
+	Status        string `json:"status"` // crawled, skipped
+	Posts         int    `json:"posts"`
+	Replies       int    `json:"replies"`
+	ReactionsSent int    `json:"reactions_sent"`
+	ReactionsRecd int    `json:"reactions_received"`
+	ZapsRecd      int    `json:"zaps_received"`
+	ZapAmountRecd int64  `json:"zap_amount_received"`
+	LastActive    string `json:"last_active,omitempty"`
+}
+
+// CrawlJob is one on-demand crawl of a pubkey, polled at /crawl/status?id=.
+type CrawlJob struct {
+	ID          string               `json:"id"`
+	Pubkey      string               `json:"pubkey"`
+	State       string               `json:"state"` // queued, running, done, failed
+	CreatedAt   time.Time            `json:"created_at"`
+	StartedAt   *time.Time           `json:"started_at,omitempty"`
+	FinishedAt  *time.Time           `json:"finished_at,omitempty"`
+	FoundBefore bool                 `json:"found_before"`
+	Found       bool                 `json:"found"`
+	Score       int                  `json:"score"`
+	ContactList *CrawlJobContactList `json:"contact_list,omitempty"`
+	Profile     *CrawlJobProfile     `json:"profile,omitempty"`
+	Engagement  *CrawlJobEngagement  `json:"engagement,omitempty"`
+	Error       string               `json:"error,omitempty"`
+}
+
+// fetchProfile returns the newest kind 0 event for pubkey from the crawl relays, or
+// nil if none was found. Tests replace it.
+var fetchProfile = func(ctx context.Context, pubkey string) (*nostr.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, hintFetchTimeout)
+	defer cancel()
+	pool := nostr.NewSimplePool(ctx)
+	filter := nostr.Filter{Kinds: []int{0}, Authors: []string{pubkey}, Limit: 1}
+
+	var newest *nostr.Event
+	for ev := range pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter}) {
+		if newest == nil || ev.Event.CreatedAt > newest.CreatedAt {
+			newest = ev.Event
+		}
+	}
+	return newest, nil
+}
+
+// fetchEngagement crawls pubkey's recent notes, reactions and zap receipts into ms.
+// Tests replace it.
+var fetchEngagement = func(ctx context.Context, ms *MetaStore, pubkey string) {
+	pool := nostr.NewSimplePool(ctx)
+	pubkeys := []string{pubkey}
+	ms.crawlNotes(ctx, pool, pubkeys)
+	ms.crawlReactions(ctx, pool, pubkeys)
+	ms.crawlZaps(ctx, pool, pubkeys)
+}
+
+// CrawlJobStore runs on-demand crawls and keeps them for polling. Jobs are copied
+// out under the lock, so callers never see one change underneath them.
+type CrawlJobStore struct {
+	mu       sync.Mutex
+	jobs     map[string]*CrawlJob
+	byPubkey map[string]string // pubkey -> its latest job
+	slots    chan struct{}
+	now      func() time.Time
+}
+
+func NewCrawlJobStore(workers int) *CrawlJobStore {
+	return &CrawlJobStore{
+		jobs:     make(map[string]*CrawlJob),
+		byPubkey: make(map[string]string),
+		slots:    make(chan struct{}, workers),
+		now:      time.Now,
+	}
+}
+
+var crawlJobs = NewCrawlJobStore(crawlJobWorkers)
+
+// Submit queues a crawl of pubkey and returns its job. A pubkey already queued or
+// running, or crawled within crawlJobCooldown, gets its existing job back and
+// created is false.
+func (s *CrawlJobStore) Submit(pubkey string) (job CrawlJob, created bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.expire(now)
+	if id, ok := s.byPubkey[pubkey]; ok {
+		j := s.jobs[id]
+		if j.FinishedAt == nil || now.Sub(*j.FinishedAt) < crawlJobCooldown {
+			return *j, false
+		}
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+	j := &CrawlJob{ID: hex.EncodeToString(b), Pubkey: pubkey, State: "queued", CreatedAt: now}
+	s.jobs[j.ID] = j
+	s.byPubkey[pubkey] = j.ID
+	go s.run(j.ID)
+	return *j, true
+}
+
+// expire drops finished jobs past crawlJobTTL, then the oldest finished ones while
+// more than maxCrawlJobs are kept. Callers hold s.mu.
+func (s *CrawlJobStore) expire(now time.Time) {
+	var finished []*CrawlJob
+	for id, j := range s.jobs {
+		if j.FinishedAt == nil {
+			continue
+		}
+		if now.Sub(*j.FinishedAt) > crawlJobTTL {
+			s.remove(id)
+			continue
+		}
+		finished = append(finished, j)
+	}
+	if len(s.jobs) < maxCrawlJobs {
+		return
+	}
+	sort.Slice(finished, func(a, b int) bool { return finished[a].FinishedAt.Before(*finished[b].FinishedAt) })
+	for _, j := range finished {
+		if len(s.jobs) < maxCrawlJobs {
+			break
+		}
+		s.remove(j.ID)
+	}
+}
+
+// remove drops a job. Callers hold s.mu.
+func (s *CrawlJobStore) remove(id string) {
+	if j, ok := s.jobs[id]; ok && s.byPubkey[j.Pubkey] == id {
+		delete(s.byPubkey, j.Pubkey)
+	}
+	delete(s.jobs, id)
+}
+
+// Get returns the job with id.
+func (s *CrawlJobStore) Get(id string) (CrawlJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return CrawlJob{}, false
+	}
+	return *j, true
+}
+
+// update applies fn to the job under the lock.
+func (s *CrawlJobStore) update(id string, fn func(j *CrawlJob)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[id]; ok {
+		fn(j)
+	}
+}
+
+// run waits for a free slot, then crawls the job's pubkey: its contact list is
+// applied to the live graph like a hint, its profile is read, and its recent
+// activity is crawled into the metadata if none was known.
+func (s *CrawlJobStore) run(id string) {
+	s.slots <- struct{}{}
+	defer func() { <-s.slots }()
+
+	var pubkey string
+	s.update(id, func(j *CrawlJob) {
+		t := s.now()
+		j.State, j.StartedAt = "running", &t
+		pubkey = j.Pubkey
+	})
+	if pubkey == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), crawlJobTimeout)
+	defer cancel()
+
+	_, foundBefore := graph.GetScore(pubkey)
+	cl := crawlJobContactList(ctx, pubkey)
+	profile := crawlJobProfile(ctx, pubkey)
+	engagement := crawlJobEngagement(ctx, pubkey)
+	if cl.Status == "updated" {
+		graphBuild.Touch()
+	}
+	raw, found := graph.GetScore(pubkey)
+
+	s.update(id, func(j *CrawlJob) {
+		t := s.now()
+		j.FinishedAt = &t
+		j.FoundBefore, j.Found = foundBefore, found
+		if found {
+			j.Score = graph.NormalizeScore(raw, "")
+		}
+		j.ContactList, j.Profile, j.Engagement = cl, profile, engagement
+		j.State = "done"
+		if cl.Status == "failed" && profile.Status == "failed" {
+			j.State, j.Error = "failed", "relays unreachable"
+		}
+	})
+	log.Printf("On-demand crawl %s of %s: contact list %s, profile %s, found=%v", id, shortKey(pubkey), cl.Status, profile.Status, found)
+}
+
+// crawlJobContactList fetches pubkey's newest contact list and applies it to the graph.
+func crawlJobContactList(ctx context.Context, pubkey string) *CrawlJobContactList {
+	ev, err := fetchContactList(ctx, pubkey)
+	if err != nil {
+		return &CrawlJobContactList{Status: "failed"}
+	}
+	if ev == nil {
+		return &CrawlJobContactList{Status: "not_found"}
+	}
+	cl := &CrawlJobContactList{Follows: len(contactListFollows(ev)), EventAt: ev.CreatedAt.Time().UTC().Format(time.RFC3339)}
+	contactHistory.Record(ev, "on_demand")
+	var ok bool
+	cl.Added, cl.Removed, cl.Rescored, ok = applyContactList(ev)
+	switch {
+	case !ok:
+		cl.Status = "stale_event"
+	case cl.Added+cl.Removed == 0:
+		cl.Status = "unchanged"
+	default:
+		cl.Status = "updated"
+	}
+	return cl
+}
+
+// crawlJobProfile fetches pubkey's newest kind 0 profile.
+func crawlJobProfile(ctx context.Context, pubkey string) *CrawlJobProfile {
+	ev, err := fetchProfile(ctx, pubkey)
+	if err != nil {
+		return &CrawlJobProfile{Status: "failed"}
+	}
+	if ev == nil {
+		return &CrawlJobProfile{Status: "not_found"}
+	}
+	p := &CrawlJobProfile{}
+	json.Unmarshal([]byte(ev.Content), p) // a malformed profile still counts as found
+	p.Status, p.EventAt = "found", ev.CreatedAt.Time().UTC().Format(time.RFC3339)
+	m := meta.Get(pubkey)
+	meta.mu.Lock()
+	m.recordActivity(int64(ev.CreatedAt))
+	meta.mu.Unlock()
+	return p
+}
+
+// crawlJobEngagement crawls pubkey's recent activity unless some is already known,
+// and reports what the metadata holds.
+func crawlJobEngagement(ctx context.Context, pubkey string) *CrawlJobEngagement {
+	e := &CrawlJobEngagement{Status: "skipped"}
+	m := meta.Get(pubkey)
+	meta.mu.Lock()
+	known := m.PostCount+m.ReplyCount+m.ReactionsSent > 0
+	meta.mu.Unlock()
+	if !known {
+		fetchEngagement(ctx, meta, pubkey)
+		e.Status = "crawled"
+	}
+
+	meta.mu.Lock()
+	defer meta.mu.Unlock()
+	e.Posts, e.Replies = m.PostCount, m.ReplyCount
+	e.ReactionsSent, e.ReactionsRecd = m.ReactionsSent, m.ReactionsRecd
+	e.ZapsRecd, e.ZapAmountRecd = m.ZapCntRecd, m.ZapAmtRecd
+	if m.LastCreated > 0 {
+		e.LastActive = time.Unix(m.LastCreated, 0).UTC().Format(time.RFC3339)
+	}
+	return e
+}
+
+// CrawlRequestResponse is the response for POST /crawl.
+type CrawlRequestResponse struct {
+	CrawlJob
+	Created bool   `json:"created"`
+	Poll    string `json:"poll"`
+}
+
+// handleCrawlRequest serves POST /crawl: a pubkey missing from the graph, or known
+// only from a stale crawl, is fetched from the relays in the background — its
+// contact list, profile and recent activity — and added to the live graph. The
+// response carries a job ID to poll at /crawl/status?id=.
+func handleCrawlRequest(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if res := crawlRequestLimiter.Take(clientIP(r)); !res.Allowed {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(time.Until(res.Reset).Seconds())+1))
+		writeAPIError(w, &APIError{Status: http.StatusTooManyRequests, Message: "crawl rate limit exceeded"})
+		return
+	}
+	var req struct {
+		Pubkey string `json:"pubkey"`
+	}
+	if apiErr := bindJSON(w, r, &req, 4<<10); apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	if req.Pubkey == "" {
+		writeAPIError(w, invalidParam("pubkey", "pubkey required"))
+		return
+	}
+	pubkey, err := resolvePubkey(req.Pubkey)
+	if err != nil || !hex64Pattern.MatchString(pubkey) {
+		writeAPIError(w, invalidParam("pubkey", "pubkey must be 64 hex characters or an npub"))
+		return
+	}
+
+	job, created := crawlJobs.Submit(pubkey)
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(CrawlRequestResponse{CrawlJob: job, Created: created, Poll: "/crawl/status?id=" + job.ID})
+}
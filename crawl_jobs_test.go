@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// setupCrawlJobs installs a small scored graph, empty metadata and a fresh job
+// store, with relay fetches stubbed to answer for pub only.
+func setupCrawlJobs(t *testing.T, sk, pub string) (engagementCrawls *int) {
+	oldGraph, oldBuild, oldMeta, oldJobs, oldLimiter := graph, graphBuild, meta, crawlJobs, crawlRequestLimiter
+	oldContacts, oldProfile, oldEngagement := fetchContactList, fetchProfile, fetchEngagement
+	t.Cleanup(func() {
+		graph, graphBuild, meta, crawlJobs, crawlRequestLimiter = oldGraph, oldBuild, oldMeta, oldJobs, oldLimiter
+		fetchContactList, fetchProfile, fetchEngagement = oldContacts, oldProfile, oldEngagement
+	})
+	graph = NewGraph()
+	for i := 0; i < 5; i++ {
+		graph.AddFollow(padHex(49700+i), padHex(49710))
+	}
+	graph.ComputePageRank(20, 0.85)
+	graphBuild = NewGraphBuild()
+	graphBuild.Advance(time.Now())
+	meta = NewMetaStore()
+	crawlJobs = NewCrawlJobStore(2)
+	crawlRequestLimiter = NewRateLimiter(5, time.Minute)
+
+	list := contactList(t, sk, time.Now(), padHex(49710), padHex(49711))
+	profile := &nostr.Event{Kind: 0, PubKey: pub, CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Content: `{"name":"alice","nip05":"alice@example.com","status":"ignored"}`}
+	fetchContactList = func(ctx context.Context, pubkey string) (*nostr.Event, error) {
+		if pubkey == pub {
+			return list, nil
+		}
+		return nil, nil
+	}
+	fetchProfile = func(ctx context.Context, pubkey string) (*nostr.Event, error) {
+		if pubkey == pub {
+			return profile, nil
+		}
+		return nil, nil
+	}
+	engagementCrawls = new(int)
+	fetchEngagement = func(ctx context.Context, ms *MetaStore, pubkey string) {
+		*engagementCrawls++
+		m := ms.Get(pubkey)
+		ms.mu.Lock()
+		m.PostCount += 3
+		m.ReactionsRecd += 2
+		ms.mu.Unlock()
+	}
+	return engagementCrawls
+}
+
+func postCrawl(body string) (*httptest.ResponseRecorder, CrawlRequestResponse) {
+	rr := httptest.NewRecorder()
+	handleCrawlRequest(rr, httptest.NewRequest(http.MethodPost, "/crawl", bytes.NewBufferString(body)))
+	var resp CrawlRequestResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+// pollCrawlJob polls /crawl/status?id= until the job finishes.
+func pollCrawlJob(t *testing.T, id string) CrawlJob {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rr := httptest.NewRecorder()
+		handleCrawlStatus(rr, httptest.NewRequest(http.MethodGet, "/crawl/status?id="+id, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200 polling the job, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var job CrawlJob
+		if err := json.Unmarshal(rr.Body.Bytes(), &job); err != nil {
+			t.Fatal(err)
+		}
+		if job.State == "done" || job.State == "failed" {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job still %s", job.State)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCrawlRequestAddsPubkeyToGraph(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	engagementCrawls := setupCrawlJobs(t, sk, pub)
+	_, rev, _ := graphBuild.Current()
+
+	rr, resp := postCrawl(`{"pubkey":"` + pub + `"}`)
+	if rr.Code != http.StatusAccepted || !resp.Created || resp.ID == "" || resp.Poll != "/crawl/status?id="+resp.ID {
+		t.Fatalf("expected a new job, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	job := pollCrawlJob(t, resp.ID)
+	if job.State != "done" || job.FoundBefore || !job.Found || job.Score <= 0 {
+		t.Errorf("expected the pubkey scored after the crawl, got %+v", job)
+	}
+	if cl := job.ContactList; cl == nil || cl.Status != "updated" || cl.Follows != 2 || cl.Added != 2 {
+		t.Errorf("expected the contact list applied, got %+v", cl)
+	}
+	if p := job.Profile; p == nil || p.Status != "found" || p.Name != "alice" || p.NIP05 != "alice@example.com" {
+		t.Errorf("expected the profile read, got %+v", p)
+	}
+	if e := job.Engagement; e == nil || e.Status != "crawled" || e.Posts != 3 || e.ReactionsRecd != 2 || e.LastActive == "" {
+		t.Errorf("expected engagement crawled, got %+v", e)
+	}
+	if _, ok := graph.GetScore(padHex(49711)); !ok {
+		t.Errorf("expected the new follow in the graph")
+	}
+	if _, rev2, _ := graphBuild.Current(); rev2 <= rev {
+		t.Errorf("expected the build revision bumped")
+	}
+
+	// within the cooldown the finished job comes back
+	rr, again := postCrawl(`{"pubkey":"` + pub + `"}`)
+	if rr.Code != http.StatusOK || again.Created || again.ID != resp.ID {
+		t.Errorf("expected the existing job back, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// after it, a new crawl doesn't count known activity twice
+	crawlJobs.now = func() time.Time { return time.Now().Add(crawlJobCooldown + time.Minute) }
+	_, third := postCrawl(`{"pubkey":"` + pub + `"}`)
+	job = pollCrawlJob(t, third.ID)
+	if !third.Created || job.Engagement.Status != "skipped" || job.Engagement.Posts != 3 || *engagementCrawls != 1 {
+		t.Errorf("expected the engagement step skipped, got %+v after %d crawls", job.Engagement, *engagementCrawls)
+	}
+	if !job.FoundBefore || job.ContactList.Status != "stale_event" {
+		t.Errorf("expected the same contact list not applied again, got %+v", job.ContactList)
+	}
+}
+
+func TestCrawlRequestNotFound(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	setupCrawlJobs(t, sk, pub)
+
+	_, resp := postCrawl(`{"pubkey":"` + padHex(49720) + `"}`)
+	job := pollCrawlJob(t, resp.ID)
+	if job.State != "done" || job.Found || job.ContactList.Status != "not_found" || job.Profile.Status != "not_found" {
+		t.Errorf("expected nothing found, got %+v", job)
+	}
+}
+
+func TestCrawlRequestValidation(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	setupCrawlJobs(t, sk, pub)
+
+	rr := httptest.NewRecorder()
+	handleCrawlRequest(rr, httptest.NewRequest(http.MethodGet, "/crawl", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rr.Code)
+	}
+	for _, body := range []string{`{}`, `{"pubkey":"nope"}`, `not json`} {
+		if rr, _ := postCrawl(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", body, rr.Code)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	handleCrawlStatus(rr, httptest.NewRequest(http.MethodGet, "/crawl/status?id=missing", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown job, got %d", rr.Code)
+	}
+
+	// rejected requests count against the limit too
+	postCrawl(`{}`)
+	postCrawl(`{}`)
+	if rr, _ := postCrawl(`{"pubkey":"` + padHex(49730) + `"}`); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the per-IP limit enforced, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// CrawlSchedulerConfig sets how hard the crawler may press each relay.
+type CrawlSchedulerConfig struct {
+	Workers          int           `json:"workers"`
+	RelayConcurrency int           `json:"relay_concurrency"` // requests in flight per relay
+	RelayRate        float64       `json:"relay_rate"`        // requests started per second per relay
+	MaxRetries       int           `json:"max_retries"`       // per request, after the first attempt
+	RetryBase        time.Duration `json:"-"`                 // doubles with each retry up to RetryMax
+	RetryMax         time.Duration `json:"-"`
+	Timeout          time.Duration `json:"-"` // per request
+}
+
+var defaultCrawlSchedulerConfig = CrawlSchedulerConfig{
+	Workers:          16,
+	RelayConcurrency: 3,
+	RelayRate:        5,
+	MaxRetries:       3,
+	RetryBase:        time.Second,
+	RetryMax:         30 * time.Second,
+	Timeout:          15 * time.Second,
+}
+
+// NewCrawlSchedulerConfigFromEnv overrides the defaults with CRAWL_WORKERS,
+// CRAWL_RELAY_CONCURRENCY, CRAWL_RELAY_RATE and CRAWL_RETRIES.
+func NewCrawlSchedulerConfigFromEnv() CrawlSchedulerConfig {
+	c := defaultCrawlSchedulerConfig
+	for env, field := range map[string]*int{
+		"CRAWL_WORKERS":           &c.Workers,
+		"CRAWL_RELAY_CONCURRENCY": &c.RelayConcurrency,
+		"CRAWL_RETRIES":           &c.MaxRetries,
+	} {
+		if v := os.Getenv(env); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && (n > 0 || env == "CRAWL_RETRIES" && n == 0) {
+				*field = n
+			} else {
+				log.Printf("Ignoring %s=%q: want a positive integer", env, v)
+			}
+		}
+	}
+	if v := os.Getenv("CRAWL_RELAY_RATE"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 {
+			c.RelayRate = f
+		} else {
+			log.Printf("Ignoring CRAWL_RELAY_RATE=%q: want a positive number", v)
+		}
+	}
+	return c
+}
+
+// crawlFetch runs one filter against one relay.
+type crawlFetch func(ctx context.Context, relay string, filter nostr.Filter) ([]*nostr.Event, error)
+
+// poolFetch queries relays through pool's connections.
+func poolFetch(pool *nostr.SimplePool) crawlFetch {
+	return func(ctx context.Context, url string, filter nostr.Filter) ([]*nostr.Event, error) {
+		relay, err := pool.EnsureRelay(url)
+		if err != nil {
+			return nil, err
+		}
+		return relay.QuerySync(ctx, filter)
+	}
+}
+
+// RelayCrawlStats counts one relay's crawl requests since startup.
+type RelayCrawlStats struct {
+	URL          string  `json:"url"`
+	InFlight     int     `json:"in_flight"`
+	Requests     int     `json:"requests"` // attempts, retries included
+	Errors       int     `json:"errors"`
+	Retries      int     `json:"retries"`
+	Failed       int     `json:"failed"` // requests given up after the last retry
+	Events       int     `json:"events"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	LastError    string  `json:"last_error,omitempty"`
+	LastErrorAt  string  `json:"last_error_at,omitempty"`
+
+	latency time.Duration
+}
+
+// CrawlProgress is how far one crawl has come.
+type CrawlProgress struct {
+	Crawl       string     `json:"crawl"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Depth       int        `json:"depth"`
+	MaxDepth    int        `json:"max_depth"`
+	Queued      int        `json:"queued"` // pubkeys at the current depth
+	TasksTotal  int        `json:"tasks_total"`
+	TasksDone   int        `json:"tasks_done"`
+	TasksFailed int        `json:"tasks_failed"`
+	Retries     int        `json:"retries"`
+	Events      int        `json:"events"`
+}
+
+type relaySlot struct {
+	sem  chan struct{}
+	next time.Time // when the rate limit lets the next request start
+}
+
+// CrawlScheduler runs crawl requests on a pool of workers. Each filter is sent to
+// every relay as its own task; a relay gets at most RelayConcurrency tasks at once
+// and RelayRate new ones per second, and a failed request is retried with jittered
+// exponential backoff. Events are handed back on the caller's goroutine, so crawl
+// code can update its own state without locking.
+type CrawlScheduler struct {
+	cfg    CrawlSchedulerConfig
+	jitter func() float64 // in [0, 1)
+
+	mu      sync.Mutex
+	slots   map[string]*relaySlot
+	stats   map[string]*RelayCrawlStats
+	current *CrawlProgress
+	last    *CrawlProgress
+}
+
+func NewCrawlScheduler(cfg CrawlSchedulerConfig) *CrawlScheduler {
+	return &CrawlScheduler{
+		cfg:    cfg,
+		jitter: rand.Float64,
+		slots:  make(map[string]*relaySlot),
+		stats:  make(map[string]*RelayCrawlStats),
+	}
+}
+
+var crawlScheduler = NewCrawlScheduler(NewCrawlSchedulerConfigFromEnv())
+
+// Begin starts tracking a crawl of up to maxDepth levels.
+func (s *CrawlScheduler) Begin(crawl string, maxDepth int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = &CrawlProgress{Crawl: crawl, StartedAt: time.Now(), MaxDepth: maxDepth}
+}
+
+// SetDepth records that the crawl moved to depth with queued pubkeys to fetch.
+func (s *CrawlScheduler) SetDepth(depth, queued int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		s.current.Depth, s.current.Queued = depth, queued
+	}
+}
+
+// End finishes the crawl started by Begin.
+func (s *CrawlScheduler) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		return
+	}
+	now := time.Now()
+	s.current.FinishedAt = &now
+	s.last, s.current = s.current, nil
+}
+
+// Run sends every filter to every relay and calls handle with each event, once per
+// event ID, until all requests are done or ctx is cancelled. progress, if non-nil,
+// receives the fraction of requests finished.
+func (s *CrawlScheduler) Run(ctx context.Context, fetch crawlFetch, relays []string, filters []nostr.Filter, handle func(*nostr.Event), progress func(float64)) {
+	type task struct {
+		relay  string
+		filter nostr.Filter
+	}
+	total := len(relays) * len(filters)
+	if total == 0 {
+		return
+	}
+	s.mu.Lock()
+	if s.current != nil {
+		s.current.TasksTotal += total
+	}
+	s.mu.Unlock()
+
+	tasks := make(chan task)
+	results := make(chan []*nostr.Event)
+	go func() {
+		defer close(tasks)
+		// relays alternate so consecutive tasks don't queue on one relay
+		for _, f := range filters {
+			for _, r := range relays {
+				select {
+				case tasks <- task{r, f}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < min(s.cfg.Workers, total); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range tasks {
+				select {
+				case results <- s.do(ctx, fetch, t.relay, t.filter):
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	seen := make(map[string]bool)
+	done := 0
+	for events := range results {
+		for _, ev := range events {
+			if !seen[ev.ID] {
+				seen[ev.ID] = true
+				handle(ev)
+			}
+		}
+		done++
+		if progress != nil {
+			progress(float64(done) / float64(total))
+		}
+	}
+}
+
+// do runs one task, retrying failures, and returns its events (none if it failed).
+func (s *CrawlScheduler) do(ctx context.Context, fetch crawlFetch, relay string, filter nostr.Filter) []*nostr.Event {
+	for attempt := 0; ; attempt++ {
+		if !s.acquire(ctx, relay) {
+			s.finish(relay, false, 0)
+			return nil
+		}
+		start := time.Now()
+		rctx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+		events, err := fetch(rctx, relay, filter)
+		cancel()
+		s.release(relay, time.Since(start), len(events), err)
+		if err == nil {
+			s.finish(relay, true, len(events))
+			return events
+		}
+		if ctx.Err() != nil || attempt >= s.cfg.MaxRetries {
+			s.finish(relay, false, 0)
+			return nil
+		}
+		s.mu.Lock()
+		s.stats[relay].Retries++
+		if s.current != nil {
+			s.current.Retries++
+		}
+		s.mu.Unlock()
+		if !sleepContext(ctx, s.backoff(attempt)) {
+			s.finish(relay, false, 0)
+			return nil
+		}
+	}
+}
+
+// backoff is the wait before retry attempt+1, with +/-25% jitter.
+func (s *CrawlScheduler) backoff(attempt int) time.Duration {
+	d := s.cfg.RetryBase << attempt
+	if d > s.cfg.RetryMax || d <= 0 {
+		d = s.cfg.RetryMax
+	}
+	return time.Duration(float64(d) * (0.75 + s.jitter()/2))
+}
+
+// acquire waits for a concurrency slot on relay, then for its rate limit.
+func (s *CrawlScheduler) acquire(ctx context.Context, relay string) bool {
+	s.mu.Lock()
+	slot, ok := s.slots[relay]
+	if !ok {
+		slot = &relaySlot{sem: make(chan struct{}, max(s.cfg.RelayConcurrency, 1))}
+		s.slots[relay] = slot
+		s.stats[relay] = &RelayCrawlStats{URL: relay}
+	}
+	s.mu.Unlock()
+	select {
+	case slot.sem <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	start := slot.next
+	if start.Before(now) {
+		start = now
+	}
+	slot.next = start.Add(time.Duration(float64(time.Second) / s.cfg.RelayRate))
+	s.stats[relay].InFlight++
+	s.stats[relay].Requests++
+	s.mu.Unlock()
+	if !sleepContext(ctx, start.Sub(now)) {
+		s.release(relay, 0, 0, nil)
+		return false
+	}
+	return true
+}
+
+func (s *CrawlScheduler) release(relay string, took time.Duration, events int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats[relay]
+	st.InFlight--
+	st.latency += took
+	st.Events += events
+	if err != nil {
+		st.Errors++
+		st.LastError = err.Error()
+		st.LastErrorAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	<-s.slots[relay].sem
+}
+
+func (s *CrawlScheduler) finish(relay string, ok bool, events int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !ok {
+		if st, known := s.stats[relay]; known {
+			st.Failed++
+		}
+	}
+	if s.current == nil {
+		return
+	}
+	s.current.TasksDone++
+	s.current.Events += events
+	if !ok {
+		s.current.TasksFailed++
+	}
+}
+
+// sleepContext waits for d or until ctx is done, reporting whether d elapsed.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// CrawlStatus is the response for GET /crawl/status.
+type CrawlStatus struct {
+	Running bool                 `json:"running"`
+	Config  CrawlSchedulerConfig `json:"config"`
+	Current *CrawlProgress       `json:"current,omitempty"`
+	Last    *CrawlProgress       `json:"last,omitempty"`
+	Relays  []RelayCrawlStats    `json:"relays"`
+}
+
+func (s *CrawlScheduler) Status() CrawlStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := CrawlStatus{Running: s.current != nil, Config: s.cfg, Relays: make([]RelayCrawlStats, 0, len(s.stats))}
+	if s.current != nil {
+		c := *s.current
+		st.Current = &c
+	}
+	if s.last != nil {
+		l := *s.last
+		st.Last = &l
+	}
+	for _, r := range s.stats {
+		rs := *r
+		if done := r.Requests - r.InFlight; done > 0 {
+			rs.AvgLatencyMs = float64(r.latency.Milliseconds()) / float64(done)
+		}
+		st.Relays = append(st.Relays, rs)
+	}
+	sort.Slice(st.Relays, func(i, j int) bool { return st.Relays[i].URL < st.Relays[j].URL })
+	return st
+}
+
+// handleCrawlStatus serves GET /crawl/status: the running crawl's progress, the
+// last finished crawl, and per-relay request counts. With ?id= it returns that
+// on-demand crawl job instead (see POST /crawl).
+func handleCrawlStatus(w http.ResponseWriter, r *http.Request) {
+	if id := r.URL.Query().Get("id"); id != "" {
+		job, ok := crawlJobs.Get(id)
+		if !ok {
+			writeAPIError(w, &APIError{Status: http.StatusNotFound, Message: "unknown or expired crawl job"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(job)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(crawlScheduler.Status())
+}
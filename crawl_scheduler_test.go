@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func testCrawlScheduler(workers, perRelay int) *CrawlScheduler {
+	s := NewCrawlScheduler(CrawlSchedulerConfig{
+		Workers:          workers,
+		RelayConcurrency: perRelay,
+		RelayRate:        1000,
+		MaxRetries:       2,
+		RetryBase:        time.Millisecond,
+		RetryMax:         4 * time.Millisecond,
+		Timeout:          time.Second,
+	})
+	s.jitter = func() float64 { return 0.5 }
+	return s
+}
+
+func crawlFilters(n int) []nostr.Filter {
+	filters := make([]nostr.Filter, n)
+	for i := range filters {
+		filters[i] = nostr.Filter{Kinds: []int{3}, Authors: []string{padHex(49600 + i)}}
+	}
+	return filters
+}
+
+func TestNewCrawlSchedulerConfigFromEnv(t *testing.T) {
+	t.Setenv("CRAWL_WORKERS", "12abc")
+	t.Setenv("CRAWL_RETRIES", "0")
+	t.Setenv("CRAWL_RELAY_RATE", "2.5")
+	c := NewCrawlSchedulerConfigFromEnv()
+	if c.Workers != defaultCrawlSchedulerConfig.Workers {
+		t.Errorf("expected malformed CRAWL_WORKERS ignored, got %d", c.Workers)
+	}
+	if c.MaxRetries != 0 || c.RelayRate != 2.5 {
+		t.Errorf("unexpected config from env %+v", c)
+	}
+	t.Setenv("CRAWL_RELAY_RATE", "2/s")
+	if c := NewCrawlSchedulerConfigFromEnv(); c.RelayRate != defaultCrawlSchedulerConfig.RelayRate {
+		t.Errorf("expected malformed CRAWL_RELAY_RATE ignored, got %v", c.RelayRate)
+	}
+}
+
+func TestCrawlSchedulerLimitsEachRelay(t *testing.T) {
+	s := testCrawlScheduler(8, 2)
+	var mu sync.Mutex
+	inFlight, peak := map[string]int{}, map[string]int{}
+	fetch := func(ctx context.Context, relay string, f nostr.Filter) ([]*nostr.Event, error) {
+		mu.Lock()
+		inFlight[relay]++
+		peak[relay] = max(peak[relay], inFlight[relay])
+		mu.Unlock()
+		time.Sleep(2 * time.Millisecond)
+		mu.Lock()
+		inFlight[relay]--
+		mu.Unlock()
+		// every relay returns the same event for the filter
+		return []*nostr.Event{{ID: f.Authors[0], PubKey: f.Authors[0]}}, nil
+	}
+
+	s.Begin("follows", 1)
+	var handled []string
+	var fractions []float64
+	s.Run(context.Background(), fetch, []string{"wss://a", "wss://b"}, crawlFilters(10),
+		func(ev *nostr.Event) { handled = append(handled, ev.ID) },
+		func(f float64) { fractions = append(fractions, f) })
+	s.End()
+
+	if peak["wss://a"] > 2 || peak["wss://b"] > 2 || peak["wss://a"] == 0 {
+		t.Errorf("expected at most 2 requests in flight per relay, got %v", peak)
+	}
+	if len(handled) != 10 {
+		t.Errorf("expected each event handled once, got %d", len(handled))
+	}
+	if len(fractions) != 20 || fractions[19] != 1 {
+		t.Errorf("expected progress after every request, got %v", fractions)
+	}
+
+	st := s.Status()
+	if st.Running || st.Last == nil || st.Last.TasksTotal != 20 || st.Last.TasksDone != 20 || st.Last.Events != 20 {
+		t.Errorf("unexpected crawl progress %+v", st.Last)
+	}
+	if len(st.Relays) != 2 || st.Relays[0].URL != "wss://a" || st.Relays[0].Requests != 10 || st.Relays[0].InFlight != 0 {
+		t.Errorf("unexpected relay stats %+v", st.Relays)
+	}
+}
+
+func TestCrawlSchedulerRetries(t *testing.T) {
+	s := testCrawlScheduler(4, 1)
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	fetch := func(ctx context.Context, relay string, f nostr.Filter) ([]*nostr.Event, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[relay]++
+		switch {
+		case relay == "wss://down":
+			return nil, errors.New("connection refused")
+		case attempts[relay] == 1:
+			return nil, errors.New("rate-limited")
+		}
+		return []*nostr.Event{{ID: "ev", PubKey: padHex(49600)}}, nil
+	}
+
+	s.Begin("follows", 1)
+	handled := 0
+	s.Run(context.Background(), fetch, []string{"wss://flaky", "wss://down"}, crawlFilters(1),
+		func(*nostr.Event) { handled++ }, nil)
+	s.End()
+
+	if handled != 1 || attempts["wss://flaky"] != 2 || attempts["wss://down"] != 3 {
+		t.Errorf("expected one retry for the flaky relay and two for the failing one, got %v (%d handled)", attempts, handled)
+	}
+	st := s.Status()
+	if st.Last.TasksFailed != 1 || st.Last.Retries != 3 {
+		t.Errorf("unexpected crawl progress %+v", st.Last)
+	}
+	down := st.Relays[0]
+	if down.URL != "wss://down" || down.Errors != 3 || down.Retries != 2 || down.Failed != 1 || down.LastError != "connection refused" {
+		t.Errorf("unexpected stats for the failing relay %+v", down)
+	}
+}
+
+func TestCrawlSchedulerRateAndBackoff(t *testing.T) {
+	s := testCrawlScheduler(4, 4)
+	s.cfg.RelayRate = 100 // one request every 10ms
+	var mu sync.Mutex
+	var starts []time.Time
+	fetch := func(ctx context.Context, relay string, f nostr.Filter) ([]*nostr.Event, error) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+		return nil, nil
+	}
+	s.Run(context.Background(), fetch, []string{"wss://a"}, crawlFilters(4), func(*nostr.Event) {}, nil)
+	if len(starts) != 4 {
+		t.Fatalf("expected 4 requests, got %d", len(starts))
+	}
+	if spread := starts[3].Sub(starts[0]); spread < 25*time.Millisecond {
+		t.Errorf("expected requests spaced by the rate limit, got %v for 4", spread)
+	}
+
+	if d := s.backoff(0); d != time.Millisecond {
+		t.Errorf("expected the base delay first, got %v", d)
+	}
+	if d := s.backoff(10); d != 4*time.Millisecond {
+		t.Errorf("expected the delay capped, got %v", d)
+	}
+}
+
+func TestCrawlSchedulerCancel(t *testing.T) {
+	s := testCrawlScheduler(2, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	fetch := func(ctx context.Context, relay string, f nostr.Filter) ([]*nostr.Event, error) {
+		calls++
+		cancel()
+		return nil, ctx.Err()
+	}
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx, fetch, []string{"wss://a"}, crawlFilters(50), func(*nostr.Event) {}, nil)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+	if calls > 2 {
+		t.Errorf("expected no requests after cancellation, got %d", calls)
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// adminAuditLogMax bounds the audit log; the oldest actions are dropped first.
+const adminAuditLogMax = 1000
+
+// rescorePhases are the rebuild phases that work from the graph already crawled:
+// scoring, the analyses built on the scores, and publishing the result.
+var rescorePhases = map[string]bool{
+	"prune":          true,
+	"pagerank":       true,
+	"hits":           true,
+	"distrust":       true,
+	"communities":    true,
+	"publish":        true,
+	"share_snapshot": true,
+}
+
+// CuratedPubkey is a banned pubkey or a pinned seed.
+type CuratedPubkey struct {
+	Pubkey  string `json:"pubkey"`
+	Reason  string `json:"reason,omitempty"`
+	AddedAt int64  `json:"added_at"`
+}
+
+// AdminAction is one entry in the admin audit log.
+type AdminAction struct {
+	Time   int64  `json:"time"`
+	Action string `json:"action"`
+	Target string `json:"target,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Source string `json:"source"` // the admin's IP
+}
+
+var (
+	errBannedSeed = errors.New("pubkey is banned; unban it first")
+	errPinnedBan  = errors.New("pubkey is a pinned seed; unpin it first")
+)
+
+// CurationStore holds the operator's manual corrections to the graph: banned
+// pubkeys, which PageRank and HITS leave out along with their follow edges and
+// which are never published, and pinned seeds the crawl always starts from. It
+// also keeps the audit log of admin actions. With a path set, all three are
+// saved there as JSON after every change and reloaded on startup.
+type CurationStore struct {
+	mu    sync.RWMutex
+	path  string
+	bans  map[string]CuratedPubkey
+	seeds map[string]CuratedPubkey
+	log   []AdminAction
+}
+
+func NewCurationStore(path string) *CurationStore {
+	return &CurationStore{path: path, bans: make(map[string]CuratedPubkey), seeds: make(map[string]CuratedPubkey)}
+}
+
+// curation is configured with CURATION_FILE, e.g. /var/lib/wot-scoring/curation.json.
+var curation = NewCurationStore(os.Getenv("CURATION_FILE"))
+
+type curationFile struct {
+	Bans     []CuratedPubkey `json:"bans"`
+	Seeds    []CuratedPubkey `json:"seeds"`
+	AuditLog []AdminAction   `json:"audit_log"`
+}
+
+// Load reads the saved state. A missing file is not an error.
+func (s *CurationStore) Load() error {
+	if s.path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var f curationFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range f.Bans {
+		s.bans[b.Pubkey] = b
+	}
+	for _, p := range f.Seeds {
+		s.seeds[p.Pubkey] = p
+	}
+	s.log = f.AuditLog
+	return nil
+}
+
+// save writes the state out. Callers hold s.mu.
+func (s *CurationStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(curationFile{
+		Bans:     sortedCurated(s.bans),
+		Seeds:    sortedCurated(s.seeds),
+		AuditLog: s.log,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, raw)
+}
+
+func sortedCurated(m map[string]CuratedPubkey) []CuratedPubkey {
+	out := make([]CuratedPubkey, 0, len(m))
+	for _, c := range m {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].AddedAt != out[j].AddedAt {
+			return out[i].AddedAt > out[j].AddedAt
+		}
+		return out[i].Pubkey < out[j].Pubkey
+	})
+	return out
+}
+
+// Ban bans pubkey. Banning it again updates the reason.
+func (s *CurationStore) Ban(pubkey, reason string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seeds[pubkey]; ok {
+		return errPinnedBan
+	}
+	s.bans[pubkey] = CuratedPubkey{Pubkey: pubkey, Reason: reason, AddedAt: now.Unix()}
+	return s.save()
+}
+
+// Unban lifts a ban, reporting whether there was one.
+func (s *CurationStore) Unban(pubkey string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.bans[pubkey]; !ok {
+		return false, nil
+	}
+	delete(s.bans, pubkey)
+	return true, s.save()
+}
+
+// Pin makes pubkey a crawl seed.
+func (s *CurationStore) Pin(pubkey, reason string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.bans[pubkey]; ok {
+		return errBannedSeed
+	}
+	s.seeds[pubkey] = CuratedPubkey{Pubkey: pubkey, Reason: reason, AddedAt: now.Unix()}
+	return s.save()
+}
+
+// Unpin removes a pinned seed, reporting whether there was one.
+func (s *CurationStore) Unpin(pubkey string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.seeds[pubkey]; !ok {
+		return false, nil
+	}
+	delete(s.seeds, pubkey)
+	return true, s.save()
+}
+
+func (s *CurationStore) Banned(pubkey string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.bans[pubkey]
+	return ok
+}
+
+// BannedSet returns a copy of the banned pubkeys, nil when there are none.
+func (s *CurationStore) BannedSet() map[string]bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.bans) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(s.bans))
+	for pk := range s.bans {
+		set[pk] = true
+	}
+	return set
+}
+
+func (s *CurationStore) Bans() []CuratedPubkey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedCurated(s.bans)
+}
+
+func (s *CurationStore) Seeds() []CuratedPubkey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sortedCurated(s.seeds)
+}
+
+// CrawlSeeds returns the configured seeds plus the pinned ones, without banned
+// pubkeys or duplicates.
+func (s *CurationStore) CrawlSeeds(configured []string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := make(map[string]bool)
+	var out []string
+	add := func(pk string) {
+		if _, banned := s.bans[pk]; !banned && !seen[pk] {
+			seen[pk] = true
+			out = append(out, pk)
+		}
+	}
+	for _, pk := range configured {
+		add(pk)
+	}
+	for _, c := range sortedCurated(s.seeds) {
+		add(c.Pubkey)
+	}
+	return out
+}
+
+// Record appends an action to the audit log.
+func (s *CurationStore) Record(r *http.Request, action, target, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.log = append(s.log, AdminAction{
+		Time:   time.Now().Unix(),
+		Action: action,
+		Target: target,
+		Reason: reason,
+		Source: clientIP(r),
+	})
+	if len(s.log) > adminAuditLogMax {
+		s.log = append([]AdminAction(nil), s.log[len(s.log)-adminAuditLogMax:]...)
+	}
+	s.save()
+}
+
+// AuditLog returns up to limit actions, newest first.
+func (s *CurationStore) AuditLog(limit int) []AdminAction {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]AdminAction, 0, min(limit, len(s.log)))
+	for i := len(s.log) - 1; i >= 0 && len(out) < limit; i-- {
+		out = append(out, s.log[i])
+	}
+	return out
+}
+
+// curationRequest is the body of POST /admin/bans and /admin/seeds.
+type curationRequest struct {
+	Pubkey string `json:"pubkey"`
+	Reason string `json:"reason"`
+}
+
+// handleAdminCurated serves a list of curated pubkeys: GET lists it, POST adds
+// {"pubkey","reason"}, DELETE ?pubkey= removes one.
+func handleAdminCurated(kind string, list func() []CuratedPubkey, add func(pk, reason string, now time.Time) error, remove func(pk string) (bool, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireAdmin(w, r) {
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			entries := list()
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{kind: entries, "count": len(entries)})
+
+		case http.MethodPost:
+			var req curationRequest
+			if apiErr := bindJSON(w, r, &req, 4<<10); apiErr != nil {
+				writeAPIError(w, apiErr)
+				return
+			}
+			pubkey, err := resolvePubkey(strings.TrimSpace(req.Pubkey))
+			if err != nil || !hex64Pattern.MatchString(pubkey) {
+				writeAPIError(w, invalidParam("pubkey", "pubkey must be 64 hex characters or an npub"))
+				return
+			}
+			if len(req.Reason) > 500 {
+				writeAPIError(w, invalidParam("reason", "reason must be at most 500 characters"))
+				return
+			}
+			if err := add(pubkey, req.Reason, time.Now()); err != nil {
+				status := http.StatusInternalServerError
+				if err == errBannedSeed || err == errPinnedBan {
+					status = http.StatusConflict
+				}
+				writeAPIError(w, &APIError{Status: status, Message: err.Error()})
+				return
+			}
+			action := map[string]string{"bans": "ban", "seeds": "pin_seed"}[kind]
+			curation.Record(r, action, pubkey, req.Reason)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"pubkey": pubkey,
+				"status": action,
+				"note":   "takes effect from the next rebuild; POST /admin/rescore to apply it now",
+			})
+
+		case http.MethodDelete:
+			q := bindQuery(r)
+			pubkey := q.HexPubkey("pubkey", true)
+			if q.Failed(w) {
+				return
+			}
+			removed, err := remove(pubkey)
+			if err != nil {
+				writeAPIError(w, &APIError{Status: http.StatusInternalServerError, Message: err.Error()})
+				return
+			}
+			if !removed {
+				writeAPIError(w, &APIError{Status: http.StatusNotFound, Message: "pubkey not found"})
+				return
+			}
+			action := map[string]string{"bans": "unban", "seeds": "unpin_seed"}[kind]
+			curation.Record(r, action, pubkey, "")
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"pubkey": pubkey, "status": action})
+
+		default:
+			requireMethod(w, r, http.MethodGet, http.MethodPost, http.MethodDelete)
+		}
+	}
+}
+
+// handleAdminRebuild serves POST /admin/recrawl and /admin/rescore: start a full
+// rebuild, or one limited to keep's phases.
+func handleAdminRebuild(action string, keep func(name string) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		if !requireAdmin(w, r) {
+			return
+		}
+		if err := rebuilder.StartPhases(context.Background(), "admin_"+action, keep); err != nil {
+			writeAPIError(w, &APIError{Status: http.StatusConflict, Message: err.Error()})
+			return
+		}
+		curation.Record(r, action, "", "")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(rebuilder.Status())
+	}
+}
+
+// handleAdminAuditLog serves GET /admin/audit-log?limit= (default 100, max 1000).
+func handleAdminAuditLog(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	q := bindQuery(r)
+	limit := q.Int("limit", 100, 1, adminAuditLogMax)
+	if q.Failed(w) {
+		return
+	}
+	actions := curation.AuditLog(limit)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"actions": actions, "count": len(actions)})
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withCuration swaps in an empty curation store for a test.
+func withCuration(t *testing.T, path string) *CurationStore {
+	old := curation
+	t.Cleanup(func() { curation = old })
+	curation = NewCurationStore(path)
+	return curation
+}
+
+func TestCurationStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "curation.json")
+	s := withCuration(t, path)
+	spam, seed := padHex(48001), padHex(48002)
+	now := time.Unix(1_700_000_000, 0)
+
+	if err := s.Ban(spam, "follow farm", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Pin(seed, "", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Pin(spam, "", now); err != errBannedSeed {
+		t.Errorf("expected pinning a banned pubkey to fail, got %v", err)
+	}
+	if err := s.Ban(seed, "", now); err != errPinnedBan {
+		t.Errorf("expected banning a pinned seed to fail, got %v", err)
+	}
+	s.Record(httptest.NewRequest("POST", "/admin/bans", nil), "ban", spam, "follow farm")
+
+	loaded := NewCurationStore(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Banned(spam) || loaded.Banned(seed) {
+		t.Error("expected the ban to survive a reload")
+	}
+	if seeds := loaded.Seeds(); len(seeds) != 1 || seeds[0].Pubkey != seed {
+		t.Errorf("expected the pinned seed back, got %+v", seeds)
+	}
+	if log := loaded.AuditLog(10); len(log) != 1 || log[0].Target != spam || log[0].Source != "192.0.2.1" {
+		t.Errorf("expected the audit log back, got %+v", log)
+	}
+
+	if ok, _ := loaded.Unban(spam); !ok || loaded.Banned(spam) {
+		t.Error("expected the ban lifted")
+	}
+	if ok, _ := loaded.Unban(spam); ok {
+		t.Error("expected a second unban to find nothing")
+	}
+	if err := NewCurationStore(filepath.Join(t.TempDir(), "missing.json")).Load(); err != nil {
+		t.Errorf("expected a missing file to load empty, got %v", err)
+	}
+}
+
+func TestCurationAuditLogCapped(t *testing.T) {
+	s := withCuration(t, "")
+	req := httptest.NewRequest("POST", "/admin/rescore", nil)
+	for i := 0; i < adminAuditLogMax+5; i++ {
+		s.Record(req, "rescore", padHex(i), "")
+	}
+	log := s.AuditLog(adminAuditLogMax + 5)
+	if len(log) != adminAuditLogMax {
+		t.Fatalf("expected %d actions kept, got %d", adminAuditLogMax, len(log))
+	}
+	if log[0].Target != padHex(adminAuditLogMax+4) || log[len(log)-1].Target != padHex(5) {
+		t.Errorf("expected newest first with the oldest dropped, got %s .. %s", log[0].Target, log[len(log)-1].Target)
+	}
+}
+
+func TestCrawlSeedsAddsPinnedAndDropsBanned(t *testing.T) {
+	s := withCuration(t, "")
+	a, b, c := padHex(48101), padHex(48102), padHex(48103)
+	s.Ban(b, "", time.Now())
+	s.Pin(c, "", time.Now())
+	s.Pin(a, "", time.Now())
+	got := s.CrawlSeeds([]string{a, b})
+	if strings.Join(got, ",") != a+","+c {
+		t.Errorf("expected [a c], got %v", got)
+	}
+}
+
+func TestBannedPubkeysExcludedFromPageRank(t *testing.T) {
+	withCuration(t, "")
+	a, b, spam, c := padHex(48201), padHex(48202), padHex(48203), padHex(48204)
+	build := func() *Graph {
+		g := NewGraph()
+		g.AddFollow(a, b)
+		g.AddFollow(a, spam)
+		g.AddFollow(spam, c)
+		g.AddFollow(b, c)
+		g.ComputePageRank(30, 0.85)
+		return g
+	}
+	before := build()
+	if _, ok := before.GetScore(spam); !ok {
+		t.Fatal("expected the spammer scored before the ban")
+	}
+
+	curation.Ban(spam, "", time.Now())
+	after := build()
+	if _, ok := after.GetScore(spam); ok {
+		t.Error("expected a banned pubkey to get no score")
+	}
+	// a's whole share now goes to b, and c is reached through b alone
+	bBefore, _ := before.GetScore(b)
+	bAfter, _ := after.GetScore(b)
+	if bAfter <= bBefore {
+		t.Errorf("expected b to gain a's share once the spammer is gone, got %v -> %v", bBefore, bAfter)
+	}
+	if hits := ComputeHITS(after, 20); len(hits) != 3 {
+		t.Errorf("expected HITS over the 3 remaining pubkeys, got %d", len(hits))
+	}
+}
+
+func TestAdminBansHandler(t *testing.T) {
+	withCuration(t, "")
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	handler := handleAdminCurated("bans", curation.Bans, curation.Ban, curation.Unban)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+	spam := padHex(48301)
+
+	if rr := do("POST", "/admin/bans", `{"pubkey":"nope"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad pubkey, got %d", rr.Code)
+	}
+	if rr := do("POST", "/admin/bans", `{"pubkey":"`+spam+`","reason":"ring"}`); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr := do("GET", "/admin/bans", "")
+	var list struct {
+		Bans  []CuratedPubkey `json:"bans"`
+		Count int             `json:"count"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	if list.Count != 1 || list.Bans[0].Pubkey != spam || list.Bans[0].Reason != "ring" {
+		t.Errorf("unexpected list %s", rr.Body.String())
+	}
+
+	curation.Pin(padHex(48302), "", time.Now())
+	if rr := do("POST", "/admin/bans", `{"pubkey":"`+padHex(48302)+`"}`); rr.Code != http.StatusConflict {
+		t.Errorf("expected 409 banning a pinned seed, got %d", rr.Code)
+	}
+	if rr := do("DELETE", "/admin/bans?pubkey="+spam, ""); rr.Code != http.StatusOK {
+		t.Errorf("expected 200 on unban, got %d", rr.Code)
+	}
+	if rr := do("DELETE", "/admin/bans?pubkey="+spam, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 unbanning twice, got %d", rr.Code)
+	}
+
+	log := curation.AuditLog(10)
+	if len(log) != 2 || log[0].Action != "unban" || log[1].Action != "ban" || log[1].Reason != "ring" {
+		t.Errorf("expected ban and unban audited, got %+v", log)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/audit-log?limit=1", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rr = httptest.NewRecorder()
+	handleAdminAuditLog(rr, req)
+	if !strings.Contains(rr.Body.String(), `"count":1`) {
+		t.Errorf("expected one action, got %s", rr.Body.String())
+	}
+}
+
+func TestAdminRescoreRunsScoringPhasesOnly(t *testing.T) {
+	withCuration(t, "")
+	old := rebuilder
+	t.Cleanup(func() { rebuilder = old })
+	var ran []string
+	phase := func(name string) RebuildPhase {
+		return RebuildPhase{Name: name, Weight: 1, Run: func(context.Context, func(float64)) { ran = append(ran, name) }}
+	}
+	rebuilder = NewRebuildController(func() []RebuildPhase {
+		return []RebuildPhase{phase("crawl_follows"), phase("pagerank"), phase("metadata"), phase("hits"), phase("publish")}
+	})
+
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	req := httptest.NewRequest("POST", "/admin/rescore", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rr := httptest.NewRecorder()
+	handleAdminRebuild("rescore", func(name string) bool { return rescorePhases[name] })(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rebuilder.Wait()
+	if strings.Join(ran, ",") != "pagerank,hits,publish" {
+		t.Errorf("expected only the scoring phases, got %v", ran)
+	}
+	if s := rebuilder.Status(); s.Trigger != "admin_rescore" || len(s.Phases) != 3 {
+		t.Errorf("unexpected status %+v", s)
+	}
+	if log := curation.AuditLog(1); len(log) != 1 || log[0].Action != "rescore" {
+		t.Errorf("expected the rescore audited, got %+v", log)
+	}
+}
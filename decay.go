@@ -2,13 +2,22 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"math"
 	"net/http"
 	"sort"
 	"time"
 )
 
+const (
+	// decayMomentumWindow is how far back follows count toward recent follow velocity.
+	decayMomentumWindow = 30 * 24 * time.Hour
+	// A pubkey is rising when its decayed score is at least decayRisingRatio times its
+	// static score and recent follows arrive at least as fast as its lifetime average;
+	// fading is the reverse below decayFadingRatio.
+	decayRisingRatio = 1.1
+	decayFadingRatio = 0.9
+)
+
 // followEdge stores the timestamp of a follow relationship.
 type followEdge struct {
 	From      string
@@ -34,6 +43,9 @@ func (g *Graph) AddFollowWithTime(from, to string, createdAt time.Time) {
 
 // GetFollowTime returns the timestamp of a follow, or zero if unknown.
 func (g *Graph) GetFollowTime(from, to string) time.Time {
+	if g.origin != nil {
+		return g.origin.GetFollowTime(from, to)
+	}
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 	if g.followTimes == nil {
@@ -60,6 +72,9 @@ func decayWeight(createdAt time.Time, now time.Time, halfLifeDays float64) float
 // ComputeDecayedPageRank runs PageRank with time-decayed edge weights.
 // Newer follows contribute more to a node's score than older ones.
 func (g *Graph) ComputeDecayedPageRank(iterations int, damping float64, halfLifeDays float64) map[string]float64 {
+	if g.origin != nil {
+		return g.origin.ComputeDecayedPageRank(iterations, damping, halfLifeDays) // follow times live there
+	}
 	g.mu.RLock()
 
 	now := time.Now()
@@ -144,46 +159,56 @@ type DecayScoreEntry struct {
 	NewestFollow string `json:"newest_follow,omitempty"`
 }
 
-func handleDecay(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
-		return
-	}
+// DecayResponse is the /decay body. activity_status and dormancy_discount appear
+// with dormancy=true; oldest_follow and newest_follow when any follow of the
+// pubkey has a known time.
+type DecayResponse struct {
+	Pubkey                string  `json:"pubkey"`
+	DecayScore            int     `json:"decay_score"`
+	StaticScore           int     `json:"static_score"`
+	Delta                 int     `json:"delta"`
+	HalfLifeDays          float64 `json:"half_life_days"`
+	Found                 bool    `json:"found"`
+	FollowerCount         int     `json:"follower_count"`
+	FollowersWithTimeData int     `json:"followers_with_time_data"`
+	ActivityStatus        string  `json:"activity_status,omitempty"`
+	DormancyDiscount      float64 `json:"dormancy_discount,omitempty"`
+	OldestFollow          string  `json:"oldest_follow,omitempty"`
+	NewestFollow          string  `json:"newest_follow,omitempty"`
+	GraphSize             int     `json:"graph_size"`
+}
 
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+func handleDecay(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	halfLifeDays := q.Float("half_life", 365, 1, 3650) // default 1 year, capped at 10
+	if q.Failed(w) {
 		return
 	}
 
-	halfLifeStr := r.URL.Query().Get("half_life")
-	halfLifeDays := 365.0 // default: 1 year half-life
-	if halfLifeStr != "" {
-		if n, err := fmt.Sscanf(halfLifeStr, "%f", &halfLifeDays); n != 1 || err != nil || halfLifeDays < 1 {
-			halfLifeDays = 365.0
-		}
-		if halfLifeDays > 3650 {
-			halfLifeDays = 3650 // cap at 10 years
-		}
-	}
-
-	stats := graph.Stats()
+	stats := g.Stats()
 
 	// Static score (standard PageRank)
-	staticRaw, found := graph.GetScore(pubkey)
+	staticRaw, found := g.GetScore(pubkey)
 	staticScore := normalizeScore(staticRaw, stats.Nodes)
 
-	// Decay-adjusted score
-	decayScores := graph.ComputeDecayedPageRank(20, 0.85, halfLifeDays)
+	// Decay-adjusted score, optionally discounted for the pubkey's own dormancy
+	decayScores := g.ComputeDecayedPageRank(20, 0.85, halfLifeDays)
 	decayRaw := decayScores[pubkey]
+	dormancy := r.URL.Query().Get("dormancy") == "true"
+	var activity ActivityResponse
+	if dormancy {
+		activity = computeActivity(pubkey, time.Now())
+		decayRaw *= activity.DormancyDiscount
+	}
 	decayScore := normalizeScore(decayRaw, stats.Nodes)
 
 	// Find oldest and newest follow times for this pubkey's followers
-	followers := graph.GetFollowers(pubkey)
+	followers := g.GetFollowers(pubkey)
 	var oldest, newest time.Time
 	for _, f := range followers {
-		t := graph.GetFollowTime(f, pubkey)
+		t := g.GetFollowTime(f, pubkey)
 		if t.IsZero() {
 			continue
 		}
@@ -195,86 +220,159 @@ func handleDecay(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	resp := map[string]interface{}{
-		"pubkey":         pubkey,
-		"decay_score":    decayScore,
-		"static_score":   staticScore,
-		"delta":          decayScore - staticScore,
-		"half_life_days": halfLifeDays,
-		"found":          found,
-		"follower_count": len(followers),
-		"graph_size":     stats.Nodes,
+	resp := DecayResponse{
+		Pubkey:        pubkey,
+		DecayScore:    decayScore,
+		StaticScore:   staticScore,
+		Delta:         decayScore - staticScore,
+		HalfLifeDays:  halfLifeDays,
+		Found:         found,
+		FollowerCount: len(followers),
+		GraphSize:     stats.Nodes,
 	}
 
+	if dormancy {
+		resp.ActivityStatus = activity.Status
+		resp.DormancyDiscount = activity.DormancyDiscount
+	}
 	if !oldest.IsZero() {
-		resp["oldest_follow"] = oldest.UTC().Format(time.RFC3339)
+		resp.OldestFollow = oldest.UTC().Format(time.RFC3339)
 	}
 	if !newest.IsZero() {
-		resp["newest_follow"] = newest.UTC().Format(time.RFC3339)
+		resp.NewestFollow = newest.UTC().Format(time.RFC3339)
 	}
 
 	// Count follows with time data
-	withTime := 0
 	for _, f := range followers {
-		if !graph.GetFollowTime(f, pubkey).IsZero() {
-			withTime++
+		if !g.GetFollowTime(f, pubkey).IsZero() {
+			resp.FollowersWithTimeData++
 		}
 	}
-	resp["followers_with_time_data"] = withTime
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-// handleDecayTop returns the top N pubkeys by decay-adjusted score, showing
-// who gains and loses rank when temporal freshness is factored in.
-func handleDecayTop(w http.ResponseWriter, r *http.Request) {
-	halfLifeStr := r.URL.Query().Get("half_life")
-	halfLifeDays := 365.0
-	if halfLifeStr != "" {
-		if n, err := fmt.Sscanf(halfLifeStr, "%f", &halfLifeDays); n != 1 || err != nil || halfLifeDays < 1 {
-			halfLifeDays = 365.0
-		}
-		if halfLifeDays > 3650 {
-			halfLifeDays = 3650
-		}
+// DecayMomentum classifies whether a pubkey is gaining or losing traction.
+type DecayMomentum struct {
+	Momentum       string  // rising, steady, fading
+	DecayRatio     float64 // decayed / static raw score
+	RecentFollows  int     // dated follows within decayMomentumWindow
+	FollowVelocity float64 // recent follows per day
+	LifetimeRate   float64 // dated follows per day since the first one
+}
+
+// computeDecayMomentum compares the decayed and static scores (above 1 means the
+// follower base is fresher than the graph's average) with the pubkey's recent follow
+// velocity against its lifetime rate, so a single burst of old follows cannot look
+// like traction and a long-established account is not fading just because it is old.
+func computeDecayMomentum(pubkey string, decayRaw, staticRaw float64, now time.Time) DecayMomentum {
+	m := DecayMomentum{Momentum: "steady"}
+	if staticRaw > 0 {
+		m.DecayRatio = math.Round(decayRaw/staticRaw*1000) / 1000
 	}
 
-	limitStr := r.URL.Query().Get("limit")
-	limit := 50
-	if limitStr != "" {
-		if n, err := fmt.Sscanf(limitStr, "%d", &limit); n != 1 || err != nil || limit < 1 {
-			limit = 50
+	var first time.Time
+	dated := 0
+	for _, f := range graph.GetFollowers(pubkey) {
+		t := graph.GetFollowTime(f, pubkey)
+		if t.IsZero() {
+			continue
 		}
-		if limit > 200 {
-			limit = 200
+		dated++
+		if now.Sub(t) <= decayMomentumWindow {
+			m.RecentFollows++
 		}
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+	}
+	if dated == 0 {
+		return m
 	}
+	windowDays := decayMomentumWindow.Hours() / 24
+	spanDays := math.Max(now.Sub(first).Hours()/24, windowDays)
+	m.FollowVelocity = math.Round(float64(m.RecentFollows)/windowDays*100) / 100
+	m.LifetimeRate = math.Round(float64(dated)/spanDays*100) / 100
+
+	switch {
+	case m.DecayRatio >= decayRisingRatio && m.RecentFollows > 0 && m.FollowVelocity >= m.LifetimeRate:
+		m.Momentum = "rising"
+	case m.DecayRatio > 0 && m.DecayRatio <= decayFadingRatio && m.FollowVelocity < m.LifetimeRate:
+		m.Momentum = "fading"
+	}
+	return m
+}
 
-	stats := graph.Stats()
-	decayScores := graph.ComputeDecayedPageRank(20, 0.85, halfLifeDays)
+// DecayTopEntry is one pubkey on the /decay/top leaderboard.
+type DecayTopEntry struct {
+	Pubkey         string  `json:"pubkey"`
+	DecayScore     int     `json:"decay_score"`
+	StaticScore    int     `json:"static_score"`
+	Delta          int     `json:"delta"`
+	DecayRank      int     `json:"decay_rank"`
+	StaticRank     int     `json:"static_rank"`
+	RankChange     int     `json:"rank_change"`
+	Momentum       string  `json:"momentum"`
+	DecayRatio     float64 `json:"decay_ratio"`
+	RecentFollows  int     `json:"recent_follows"`
+	FollowVelocity float64 `json:"follow_velocity"`
+	Followers      int     `json:"followers"`
+	CommunityID    int     `json:"community_id"` // -1 when unclustered
+
+	decayRaw, staticRaw float64
+}
+
+// DecayTopResponse is the /decay/top body. community, min_followers and momentum
+// echo the filters that were applied.
+type DecayTopResponse struct {
+	Entries      []DecayTopEntry `json:"entries"`
+	HalfLifeDays float64         `json:"half_life_days"`
+	Dormancy     bool            `json:"dormancy"`
+	Community    *int            `json:"community,omitempty"`
+	MinFollowers int             `json:"min_followers,omitempty"`
+	Momentum     string          `json:"momentum,omitempty"`
+	GraphSize    int             `json:"graph_size"`
+	Algorithm    string          `json:"algorithm"`
+}
 
-	type entry struct {
-		Pubkey      string  `json:"pubkey"`
-		DecayScore  int     `json:"decay_score"`
-		StaticScore int     `json:"static_score"`
-		Delta       int     `json:"delta"`
-		DecayRank   int     `json:"decay_rank"`
-		StaticRank  int     `json:"static_rank"`
-		RankChange  int     `json:"rank_change"`
+// handleDecayTop returns the top N pubkeys by decay-adjusted score, showing
+// who gains and loses rank when temporal freshness is factored in. Each entry
+// carries a momentum class, and the list can be narrowed to one community,
+// a minimum follower count, or a single momentum class.
+func handleDecayTop(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	halfLifeDays := q.Float("half_life", 365, 1, 3650)
+	limit := q.Int("limit", 50, 1, 200)
+	community := q.Int("community", -1, 0, math.MaxInt)
+	minFollowers := q.Int("min_followers", 0, 0, math.MaxInt)
+	momentum := q.OneOf("momentum", "rising", "steady", "fading")
+	if q.Failed(w) {
+		return
 	}
 
+	stats := g.Stats()
+	decayScores := g.ComputeDecayedPageRank(20, 0.85, halfLifeDays)
+	dormancy := r.URL.Query().Get("dormancy") == "true"
+	now := time.Now()
+
 	// Build sorted list by decay score
-	entries := make([]entry, 0, len(decayScores))
+	entries := make([]DecayTopEntry, 0, len(decayScores))
 	for pk, decayRaw := range decayScores {
-		staticRaw, _ := graph.GetScore(pk)
+		if dormancy {
+			decayRaw *= computeActivity(pk, now).DormancyDiscount
+		}
+		staticRaw, _ := g.GetScore(pk)
 		ds := normalizeScore(decayRaw, stats.Nodes)
 		ss := normalizeScore(staticRaw, stats.Nodes)
-		entries = append(entries, entry{
+		entries = append(entries, DecayTopEntry{
 			Pubkey:      pk,
 			DecayScore:  ds,
 			StaticScore: ss,
 			Delta:       ds - ss,
+			decayRaw:    decayRaw,
+			staticRaw:   staticRaw,
 		})
 	}
 
@@ -289,7 +387,7 @@ func handleDecayTop(w http.ResponseWriter, r *http.Request) {
 
 	// Build static rank lookup
 	staticRanks := make(map[string]int)
-	staticSorted := make([]entry, len(entries))
+	staticSorted := make([]DecayTopEntry, len(entries))
 	copy(staticSorted, entries)
 	sort.Slice(staticSorted, func(i, j int) bool {
 		return staticSorted[i].StaticScore > staticSorted[j].StaticScore
@@ -304,15 +402,45 @@ func handleDecayTop(w http.ResponseWriter, r *http.Request) {
 		entries[i].RankChange = entries[i].StaticRank - entries[i].DecayRank // positive = improved with decay
 	}
 
-	if len(entries) > limit {
-		entries = entries[:limit]
+	// Filter in decay-rank order, classifying momentum only for candidates
+	// that pass the cheaper filters, until the page is full
+	results := make([]DecayTopEntry, 0, limit)
+	for _, e := range entries {
+		if len(results) == limit {
+			break
+		}
+		e.Followers = len(g.GetFollowers(e.Pubkey))
+		if e.Followers < minFollowers {
+			continue
+		}
+		e.CommunityID = -1
+		if id, ok := communities.GetCommunity(e.Pubkey); ok {
+			e.CommunityID = id
+		}
+		if community >= 0 && e.CommunityID != community {
+			continue
+		}
+		m := computeDecayMomentum(e.Pubkey, e.decayRaw, e.staticRaw, now)
+		if momentum != "" && m.Momentum != momentum {
+			continue
+		}
+		e.Momentum, e.DecayRatio = m.Momentum, m.DecayRatio
+		e.RecentFollows, e.FollowVelocity = m.RecentFollows, m.FollowVelocity
+		results = append(results, e)
 	}
 
+	resp := DecayTopResponse{
+		Entries:      results,
+		HalfLifeDays: halfLifeDays,
+		Dormancy:     dormancy,
+		MinFollowers: minFollowers,
+		Momentum:     momentum,
+		GraphSize:    stats.Nodes,
+		Algorithm:    "PageRank with exponential time decay",
+	}
+	if community >= 0 {
+		resp.Community = &community
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"entries":        entries,
-		"half_life_days": halfLifeDays,
-		"graph_size":     stats.Nodes,
-		"algorithm":      "PageRank with exponential time decay",
-	})
+	json.NewEncoder(w).Encode(resp)
 }
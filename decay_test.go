@@ -258,3 +258,72 @@ func TestHandleDecayTop(t *testing.T) {
 		t.Errorf("unexpected algorithm: %v", resp["algorithm"])
 	}
 }
+
+func TestHandleDecayTopMomentumAndFilters(t *testing.T) {
+	oldGraph, oldCommunities := graph, communities
+	defer func() { graph, communities = oldGraph, oldCommunities }()
+
+	graph = NewGraph()
+	communities = NewCommunityDetector()
+	now := time.Now()
+	riser, fader := padHex(29001), padHex(29002)
+	// every follower splits its trust between a follow from last week and one from
+	// three years ago, so decay shifts score from fader to riser
+	for i := 0; i < 6; i++ {
+		f := padHex(29100 + i)
+		graph.AddFollowWithTime(f, riser, now.Add(-7*24*time.Hour))
+		graph.AddFollowWithTime(f, fader, now.Add(-1000*24*time.Hour))
+		communities.labels[f] = 3
+	}
+	communities.labels[riser] = 7
+	communities.labels[fader] = 3
+	graph.ComputePageRank(20, 0.85)
+
+	get := func(query string) (int, []map[string]interface{}) {
+		w := httptest.NewRecorder()
+		handleDecayTop(w, httptest.NewRequest(http.MethodGet, "/decay/top?"+query, nil))
+		var resp struct {
+			Entries []map[string]interface{} `json:"entries"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Entries
+	}
+
+	_, entries := get("limit=50")
+	byPubkey := make(map[string]map[string]interface{})
+	for _, e := range entries {
+		byPubkey[e["pubkey"].(string)] = e
+	}
+	if m := byPubkey[riser]["momentum"]; m != "rising" {
+		t.Errorf("expected riser rising, got %v (%v)", m, byPubkey[riser])
+	}
+	if byPubkey[riser]["recent_follows"] != 6.0 || byPubkey[riser]["decay_ratio"].(float64) <= decayRisingRatio {
+		t.Errorf("unexpected riser signals %v", byPubkey[riser])
+	}
+	if m := byPubkey[fader]["momentum"]; m != "fading" {
+		t.Errorf("expected fader fading, got %v (%v)", m, byPubkey[fader])
+	}
+	if m := byPubkey[padHex(29100)]["momentum"]; m != "steady" {
+		t.Errorf("expected pubkey without followers to be steady, got %v", m)
+	}
+
+	if _, entries := get("community=7"); len(entries) != 1 || entries[0]["pubkey"] != riser || entries[0]["community_id"] != 7.0 {
+		t.Errorf("expected only riser in community 7, got %v", entries)
+	}
+	if _, entries := get("min_followers=1"); len(entries) != 2 {
+		t.Errorf("expected riser and fader with min_followers=1, got %d entries", len(entries))
+	}
+	_, entries = get("momentum=fading&community=3")
+	if len(entries) != 1 || entries[0]["pubkey"] != fader {
+		t.Errorf("expected only fader, got %v", entries)
+	}
+	if entries[0]["decay_rank"].(float64) < 2 {
+		t.Errorf("filtered entries should keep global ranks, got %v", entries[0]["decay_rank"])
+	}
+
+	for _, q := range []string{"community=x", "min_followers=-1", "momentum=up"} {
+		if code, _ := get(q); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, code)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	// distrustMinSourceScore is the score a reporter or muter needs before their
+	// reports and mutes count, so throwaway accounts can't push distrust.
+	distrustMinSourceScore = 10
+	// distrustReportWeight and distrustMuteWeight scale the trust a source spends on
+	// each judgement. A mute is a weaker statement than a report.
+	distrustReportWeight = 0.5
+	distrustMuteWeight   = 0.25
+	// distrustPropagation is the share of a pubkey's distrust passed on to its
+	// followers per hop, and distrustHops how far it travels.
+	distrustPropagation = 0.3
+	distrustHops        = 2
+)
+
+// Snapshot returns each muter's muted pubkeys.
+func (s *MuteStore) Snapshot() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string][]string, len(s.mutes))
+	for muter, set := range s.mutes {
+		for pk := range set {
+			out[muter] = append(out[muter], pk)
+		}
+	}
+	return out
+}
+
+// DistrustEntry is one pubkey's share of propagated distrust.
+type DistrustEntry struct {
+	Direct     float64 `json:"direct"`     // from reports and mutes of this pubkey
+	Propagated float64 `json:"propagated"` // passed on from distrusted pubkeys it follows
+	Reporters  int     `json:"reporters"`  // reporters above the minimum score
+	Muters     int     `json:"muters"`     // muters above the minimum score
+	Penalty    float64 `json:"penalty"`    // distrust / (distrust + trust), 0-1
+}
+
+// ComputeDistrust runs an Anti-TrustRank pass over the graph. Each reporter or
+// muter with at least distrustMinSourceScore spends a fraction of its own PageRank,
+// split evenly across everyone it reports or mutes, as distrust. Distrust then
+// flows backwards along follow edges: a distrusted pubkey passes
+// distrustPropagation of it, split across its followers, to the accounts that
+// vouch for it, for distrustHops hops. The penalty compares a pubkey's distrust to
+// its own trust, so a heavily followed account shrugs off a stray report while a
+// sybil cluster that follows its reported members shares their penalty.
+func ComputeDistrust(g *Graph, reports, mutes map[string][]string) map[string]DistrustEntry {
+	scores := g.ScoresSnapshot()
+	n := len(scores)
+	direct := make(map[string]float64)
+	reporters := make(map[string]int)
+	muters := make(map[string]int)
+
+	spend := func(sources map[string][]string, weight float64, counts map[string]int) {
+		for src, targets := range sources {
+			trust := scores[src]
+			if len(targets) == 0 || normalizeScore(trust, n) < distrustMinSourceScore {
+				continue
+			}
+			share := weight * trust / float64(len(targets))
+			for _, t := range targets {
+				if t == src {
+					continue
+				}
+				direct[t] += share
+				counts[t]++
+			}
+		}
+	}
+	spend(reports, distrustReportWeight, reporters)
+	spend(mutes, distrustMuteWeight, muters)
+
+	propagated := make(map[string]float64)
+	frontier := direct
+	for hop := 0; hop < distrustHops && len(frontier) > 0; hop++ {
+		next := make(map[string]float64)
+		for pk, d := range frontier {
+			followers := g.GetFollowers(pk)
+			if len(followers) == 0 {
+				continue
+			}
+			share := distrustPropagation * d / float64(len(followers))
+			for _, f := range followers {
+				next[f] += share
+			}
+		}
+		for pk, d := range next {
+			propagated[pk] += d
+		}
+		frontier = next
+	}
+
+	out := make(map[string]DistrustEntry, len(direct)+len(propagated))
+	add := func(pk string) {
+		if _, done := out[pk]; done {
+			return
+		}
+		e := DistrustEntry{Direct: direct[pk], Propagated: propagated[pk], Reporters: reporters[pk], Muters: muters[pk]}
+		total := e.Direct + e.Propagated
+		e.Penalty = total / (total + scores[pk])
+		out[pk] = e
+	}
+	for pk := range direct {
+		add(pk)
+	}
+	for pk := range propagated {
+		add(pk)
+	}
+	return out
+}
+
+// DistrustStore holds the last distrust pass.
+type DistrustStore struct {
+	mu      sync.RWMutex
+	entries map[string]DistrustEntry
+}
+
+func NewDistrustStore() *DistrustStore {
+	return &DistrustStore{entries: make(map[string]DistrustEntry)}
+}
+
+var distrust = NewDistrustStore()
+
+func (s *DistrustStore) Set(entries map[string]DistrustEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = entries
+}
+
+func (s *DistrustStore) Get(pubkey string) (DistrustEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.entries[pubkey]
+	return e, ok
+}
+
+// Count returns how many pubkeys carry any distrust.
+func (s *DistrustStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// distrustAdjustedScore applies the penalty to a 0-100 score.
+func distrustAdjustedScore(score int, e DistrustEntry) int {
+	return int(float64(score)*(1-e.Penalty) + 0.5)
+}
+
+// DistrustSource is a reporter or muter listed in /audit.
+type DistrustSource struct {
+	Pubkey     string `json:"pubkey"`
+	Score      int    `json:"score"`
+	Kind       string `json:"kind"`                  // report or mute
+	ReportType string `json:"report_type,omitempty"` // NIP-56 type, e.g. spam, impersonation
+	Counted    bool   `json:"counted"`               // score meets the minimum
+}
+
+// DistrustAudit is the distrust section of /audit.
+type DistrustAudit struct {
+	DistrustEntry
+	AdjustedScore  int              `json:"adjusted_score"`
+	MinSourceScore int              `json:"min_source_score"`
+	Sources        []DistrustSource `json:"sources"` // highest-scored first, at most 10
+}
+
+// auditDistrust explains pubkey's distrust penalty, or returns nil when it has none.
+func auditDistrust(pubkey string, score int) *DistrustAudit {
+	e, ok := distrust.Get(pubkey)
+	if !ok {
+		return nil
+	}
+	nodes := graph.Stats().Nodes
+	a := &DistrustAudit{
+		DistrustEntry:  e,
+		AdjustedScore:  distrustAdjustedScore(score, e),
+		MinSourceScore: distrustMinSourceScore,
+		Sources:        []DistrustSource{},
+	}
+	source := func(pk, kind, reportType string) DistrustSource {
+		raw, _ := graph.GetScore(pk)
+		s := normalizeScore(raw, nodes)
+		return DistrustSource{Pubkey: pk, Score: s, Kind: kind, ReportType: reportType, Counted: s >= distrustMinSourceScore}
+	}
+	for pk, reportType := range reportStore.ReportedBy(pubkey) {
+		a.Sources = append(a.Sources, source(pk, "report", reportType))
+	}
+	for _, pk := range muteStore.GetMutedBy(pubkey) {
+		a.Sources = append(a.Sources, source(pk, "mute", ""))
+	}
+	sort.Slice(a.Sources, func(i, j int) bool {
+		if a.Sources[i].Score != a.Sources[j].Score {
+			return a.Sources[i].Score > a.Sources[j].Score
+		}
+		return a.Sources[i].Pubkey < a.Sources[j].Pubkey
+	})
+	if len(a.Sources) > 10 {
+		a.Sources = a.Sources[:10]
+	}
+	return a
+}
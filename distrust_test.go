@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// distrustGraph builds a trusted hub followed by many accounts, a spammer, a sybil
+// that follows the spammer, and a bystander the spammer follows.
+func distrustGraph() (g *Graph, hub, spammer, sybil, bystander, celebrity string) {
+	g = NewGraph()
+	hub, spammer, sybil, bystander, celebrity = padHex(39100), padHex(39101), padHex(39102), padHex(39103), padHex(39104)
+	for i := 0; i < 30; i++ {
+		fan := padHex(39200 + i)
+		g.AddFollow(fan, hub)
+		g.AddFollow(fan, celebrity)
+	}
+	g.AddFollow(hub, celebrity)
+	g.AddFollow(sybil, spammer)
+	g.AddFollow(spammer, sybil)
+	g.AddFollow(spammer, bystander)
+	g.AddFollow(spammer, celebrity)
+	g.ComputePageRank(20, 0.85)
+	return
+}
+
+func TestComputeDistrust(t *testing.T) {
+	g, hub, spammer, sybil, bystander, celebrity := distrustGraph()
+	lowTrust := padHex(39300)
+	g.AddFollow(lowTrust, bystander)
+	g.ComputePageRank(20, 0.85)
+
+	entries := ComputeDistrust(g,
+		map[string][]string{hub: {spammer}, lowTrust: {bystander}},
+		map[string][]string{hub: {celebrity}},
+	)
+
+	s := entries[spammer]
+	if s.Reporters != 1 || s.Direct == 0 || s.Penalty < 0.5 {
+		t.Errorf("expected a heavy penalty for the reported spammer, got %+v", s)
+	}
+	// the sybil vouches for the spammer, so shares some of the distrust
+	if y := entries[sybil]; y.Propagated == 0 || y.Direct != 0 || y.Penalty == 0 || y.Penalty >= s.Penalty {
+		t.Errorf("expected a smaller propagated penalty for the sybil, got %+v", y)
+	}
+	// being followed by the spammer carries no blame, and low-trust reports don't count
+	if b, ok := entries[bystander]; ok {
+		t.Errorf("expected no distrust for the bystander, got %+v", b)
+	}
+	// one mute barely dents a widely followed account
+	if c := entries[celebrity]; c.Muters != 1 || c.Penalty == 0 || c.Penalty > 0.2 {
+		t.Errorf("expected a small penalty for the muted celebrity, got %+v", c)
+	}
+	if got := distrustAdjustedScore(80, DistrustEntry{Penalty: 0.25}); got != 60 {
+		t.Errorf("expected 60, got %d", got)
+	}
+}
+
+func TestDistrustInScoreAndAudit(t *testing.T) {
+	oldGraph, oldMeta, oldReports, oldMutes, oldDistrust := graph, meta, reportStore, muteStore, distrust
+	defer func() { graph, meta, reportStore, muteStore, distrust = oldGraph, oldMeta, oldReports, oldMutes, oldDistrust }()
+	meta, reportStore, muteStore, distrust = NewMetaStore(), NewReportStore(), NewMuteStore(), NewDistrustStore()
+	g, hub, spammer, _, _, _ := distrustGraph()
+	graph = g
+
+	reportStore.reports[hub] = map[string]string{spammer: "spam"}
+	reportStore.reportedBy[spammer] = map[string]string{hub: "spam"}
+	muteStore.Add(padHex(39200), []string{spammer})
+	distrust.Set(ComputeDistrust(graph, reportStore.Snapshot(), muteStore.Snapshot()))
+
+	rr := httptest.NewRecorder()
+	handleScore(rr, httptest.NewRequest(http.MethodGet, "/score?pubkey="+spammer, nil))
+	var score map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &score)
+	if score["distrust_penalty"] == nil || score["distrust_adjusted_score"].(float64) > score["score"].(float64) {
+		t.Errorf("expected the distrust penalty in /score, got %v", score)
+	}
+
+	rr = httptest.NewRecorder()
+	handleAudit(rr, httptest.NewRequest(http.MethodGet, "/audit?pubkey="+spammer, nil))
+	var audit struct {
+		Distrust *DistrustAudit `json:"distrust"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &audit)
+	d := audit.Distrust
+	if d == nil || len(d.Sources) != 2 {
+		t.Fatalf("expected the reporter and muter listed, got %s", rr.Body.String())
+	}
+	if d.Sources[0].Pubkey != hub || d.Sources[0].Kind != "report" || d.Sources[0].ReportType != "spam" || !d.Sources[0].Counted {
+		t.Errorf("expected the trusted reporter first, got %+v", d.Sources[0])
+	}
+	if d.Sources[1].Kind != "mute" || d.Sources[1].Counted || d.Muters != 0 {
+		t.Errorf("expected the low-score muter listed but not counted, got %+v (muters %d)", d.Sources[1], d.Muters)
+	}
+
+	rr = httptest.NewRecorder()
+	handleAudit(rr, httptest.NewRequest(http.MethodGet, "/audit?pubkey="+hub, nil))
+	audit.Distrust = nil
+	if json.Unmarshal(rr.Body.Bytes(), &audit); audit.Distrust != nil {
+		t.Errorf("expected no distrust section for an unreported pubkey, got %+v", audit.Distrust)
+	}
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/nbd-wtf/go-nostr/nip17"
+	"github.com/nbd-wtf/go-nostr/nip44"
+	"github.com/nbd-wtf/go-nostr/nip59"
+)
+
+const (
+	// notifyMinScoreChange is how far a subscriber's normalized score has to move
+	// from the last one we told them about before it is worth a DM.
+	notifyMinScoreChange = 10
+	// notifyMinInterval is the least time between two change DMs to one subscriber.
+	// Changes in between are kept and sent together afterwards.
+	notifyMinInterval = 24 * time.Hour
+	// notifyMaxPerRun caps the change DMs sent after one rebuild.
+	notifyMaxPerRun = 200
+	// notifyInboxLookback is how far back the inbox is read: gift wraps are
+	// backdated by up to two days, and the first run picks up older commands.
+	notifyInboxLookback = 7 * 24 * time.Hour
+)
+
+// notifyState is what a subscriber hears about: their normalized score, their
+// percentile tier (see PercentileBucket) and their /spam classification.
+type notifyState struct {
+	Score     int    `json:"score"`
+	Tier      int    `json:"tier"`
+	SpamClass string `json:"spam_class"`
+}
+
+// NotifySubscriber is an authorizer who asked for DMs, with the state we last
+// told them about.
+type NotifySubscriber struct {
+	Pubkey       string      `json:"pubkey"`
+	SubscribedAt int64       `json:"subscribed_at"`
+	Notified     notifyState `json:"notified"`
+	LastNotified int64       `json:"last_notified,omitempty"`
+}
+
+// NotifyRun summarizes one notification pass.
+type NotifyRun struct {
+	At          time.Time `json:"at"`
+	Commands    int       `json:"commands"` // subscribe and unsubscribe DMs handled
+	Subscribers int       `json:"subscribers"`
+	Notified    int       `json:"notified"`
+	Deferred    int       `json:"deferred"` // changed, but messaged too recently or over the per-run cap
+	Failed      int       `json:"failed"`
+}
+
+// DMNotifier tells users who authorized us via kind 10040, and opted in by DM,
+// when their score, percentile tier or spam classification changes. Messages are
+// NIP-17 private DMs. Subscribers reply "unsubscribe" or "stop" to opt out; NIP-04
+// replies are understood too. With a path set, subscribers and the commands seen
+// are saved there as JSON after every pass and reloaded on startup.
+type DMNotifier struct {
+	mu       sync.Mutex
+	path     string
+	subs     map[string]*NotifySubscriber
+	commands map[string]int64 // pubkey -> created_at of the last command handled
+	last     *NotifyRun
+	sent     int
+	now      func() time.Time
+}
+
+func NewDMNotifier(path string) *DMNotifier {
+	return &DMNotifier{
+		path:     path,
+		subs:     make(map[string]*NotifySubscriber),
+		commands: make(map[string]int64),
+		now:      time.Now,
+	}
+}
+
+// dmNotifier is configured with NOTIFICATIONS_FILE, e.g. /var/lib/wot-scoring/notifications.json.
+var dmNotifier = NewDMNotifier(os.Getenv("NOTIFICATIONS_FILE"))
+
+type notificationsFile struct {
+	Subscribers []*NotifySubscriber `json:"subscribers"`
+	Commands    map[string]int64    `json:"commands"`
+}
+
+// Load reads the saved subscribers. A missing file is not an error.
+func (n *DMNotifier) Load() error {
+	if n.path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(n.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var f notificationsFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return fmt.Errorf("%s: %w", n.path, err)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, s := range f.Subscribers {
+		n.subs[s.Pubkey] = s
+	}
+	for pk, at := range f.Commands {
+		n.commands[pk] = at
+	}
+	return nil
+}
+
+// save writes the subscribers out. Callers hold n.mu.
+func (n *DMNotifier) save() error {
+	if n.path == "" {
+		return nil
+	}
+	f := notificationsFile{Commands: n.commands}
+	for _, s := range n.subs {
+		f.Subscribers = append(f.Subscribers, s)
+	}
+	sort.Slice(f.Subscribers, func(i, j int) bool { return f.Subscribers[i].Pubkey < f.Subscribers[j].Pubkey })
+	raw, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(n.path, raw)
+}
+
+// fetchDMInbox returns the kind 1059 gift wraps and kind 4 DMs addressed to pub
+// since since. Tests replace it.
+var fetchDMInbox = func(ctx context.Context, pub string, since int64) []*nostr.Event {
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	pool := nostr.NewSimplePool(ctx)
+	ts := nostr.Timestamp(since)
+	filter := nostr.Filter{Kinds: []int{nostr.KindGiftWrap, nostr.KindEncryptedDirectMessage}, Tags: nostr.TagMap{"p": {pub}}, Since: &ts}
+
+	var inbox []*nostr.Event
+	seen := make(map[string]bool)
+	for ev := range pool.SubManyEose(ctx, config.Relays(), nostr.Filters{filter}) {
+		if !seen[ev.Event.ID] {
+			seen[ev.Event.ID] = true
+			inbox = append(inbox, ev.Event)
+		}
+	}
+	return inbox
+}
+
+// fetchDMRelays returns the relays of pubkey's kind 10050 DM relay list, if it
+// has one. Tests replace it.
+var fetchDMRelays = func(ctx context.Context, pubkey string) []string {
+	ctx, cancel := context.WithTimeout(ctx, hintFetchTimeout)
+	defer cancel()
+	return nip17.GetDMRelays(ctx, pubkey, nostr.NewSimplePool(ctx), crawlRelays())
+}
+
+// currentNotifyState is pubkey's state in g now.
+func currentNotifyState(g *Graph, pubkey string) notifyState {
+	nodes := g.Stats().Nodes
+	raw, _ := g.GetScore(pubkey)
+	return notifyState{
+		Score:     normalizeScore(raw, nodes),
+		Tier:      g.PercentileBucket(pubkey),
+		SpamClass: computeSpamWithPercentile(pubkey, nodes, 0).Classification,
+	}
+}
+
+func notifyTierName(tier int) string {
+	switch tier {
+	case 0:
+		return "unscored"
+	case 100:
+		return "the bottom 50%"
+	}
+	return fmt.Sprintf("the top %d%%", tier)
+}
+
+// notifyChanges describes what changed significantly between was and now, one
+// sentence each; nothing if no change is worth a DM.
+func notifyChanges(was, now notifyState) []string {
+	var changes []string
+	if d := now.Score - was.Score; d >= notifyMinScoreChange || d <= -notifyMinScoreChange {
+		changes = append(changes, fmt.Sprintf("Your WoT score went from %d to %d out of 100.", was.Score, now.Score))
+	}
+	if now.Tier != was.Tier {
+		changes = append(changes, fmt.Sprintf("You moved from %s to %s of scored accounts.", notifyTierName(was.Tier), notifyTierName(now.Tier)))
+	}
+	if now.SpamClass != was.SpamClass && was.SpamClass != "" {
+		changes = append(changes, fmt.Sprintf("Your spam classification changed from %s to %s.", was.SpamClass, now.SpamClass))
+	}
+	return changes
+}
+
+// parseNotifyCommand reads a DM's first word as "subscribe" (subscribe, start) or
+// "unsubscribe" (unsubscribe, stop), or "" for anything else.
+func parseNotifyCommand(content string) string {
+	fields := strings.Fields(strings.ToLower(content))
+	if len(fields) == 0 {
+		return ""
+	}
+	switch strings.Trim(fields[0], ".!/") {
+	case "subscribe", "start":
+		return "subscribe"
+	case "unsubscribe", "stop":
+		return "unsubscribe"
+	}
+	return ""
+}
+
+// wrapDM makes a NIP-17 DM from pub to recipient: a kind 14 rumor, sealed with
+// sk and gift-wrapped with a one-time key.
+func wrapDM(sk, pub, recipient, content string) (nostr.Event, error) {
+	rumor := nostr.Event{
+		PubKey:    pub,
+		CreatedAt: nostr.Now(),
+		Kind:      nostr.KindDirectMessage,
+		Tags:      nostr.Tags{{"p", recipient}},
+		Content:   content,
+	}
+	rumor.ID = rumor.GetID()
+	convKey, err := nip44.GenerateConversationKey(recipient, sk)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+	return nip59.GiftWrap(rumor, recipient,
+		func(plain string) (string, error) { return nip44.Encrypt(plain, convKey) },
+		func(ev *nostr.Event) error { return ev.Sign(sk) },
+		nil)
+}
+
+// readDM decrypts a gift wrap or kind 4 DM to us and returns its verified
+// sender, text and timestamp.
+func readDM(sk string, ev *nostr.Event) (sender, content string, at int64, err error) {
+	switch ev.Kind {
+	case nostr.KindGiftWrap:
+		rumor, err := nip59.GiftUnwrap(*ev, func(other, ciphertext string) (string, error) {
+			key, err := nip44.GenerateConversationKey(other, sk)
+			if err != nil {
+				return "", err
+			}
+			return nip44.Decrypt(ciphertext, key)
+		})
+		if err != nil {
+			return "", "", 0, err
+		}
+		if rumor.Kind != nostr.KindDirectMessage {
+			return "", "", 0, fmt.Errorf("not a chat message (kind %d)", rumor.Kind)
+		}
+		return rumor.PubKey, rumor.Content, int64(rumor.CreatedAt), nil
+	case nostr.KindEncryptedDirectMessage:
+		if ok, _ := ev.CheckSignature(); !ok {
+			return "", "", 0, fmt.Errorf("invalid signature")
+		}
+		key, err := nip04.ComputeSharedSecret(ev.PubKey, sk)
+		if err != nil {
+			return "", "", 0, err
+		}
+		plain, err := nip04.Decrypt(ev.Content, key)
+		if err != nil {
+			return "", "", 0, err
+		}
+		return ev.PubKey, plain, int64(ev.CreatedAt), nil
+	}
+	return "", "", 0, fmt.Errorf("unexpected kind %d", ev.Kind)
+}
+
+// dmRelays is where a DM to recipient goes: their kind 10050 relays, or else
+// their kind 10040 relay hint followed by ours.
+func dmRelays(ctx context.Context, recipient string, a *Authorization) []string {
+	if relays := fetchDMRelays(ctx, recipient); len(relays) > 0 {
+		return relays
+	}
+	return authorizerRelays(a)
+}
+
+// Run handles the subscribe and unsubscribe DMs sent to pub since the last pass,
+// drops subscribers who revoked their kind 10040 authorization, and DMs each
+// remaining subscriber whose state in g changed significantly since they were
+// last told. It returns how many DMs were queued, confirmations included.
+func (n *DMNotifier) Run(ctx context.Context, sk, pub string, store *AuthStore, g *Graph) (int, error) {
+	now := n.now()
+	run := &NotifyRun{At: now}
+	var batch []queuedDM
+
+	// newest command per sender wins
+	type command struct {
+		action string
+		at     int64
+	}
+	latest := make(map[string]command)
+	for _, ev := range fetchDMInbox(ctx, pub, now.Add(-notifyInboxLookback).Unix()) {
+		sender, content, at, err := readDM(sk, ev)
+		if err != nil {
+			continue
+		}
+		action := parseNotifyCommand(content)
+		if action == "" || at <= latest[sender].at {
+			continue
+		}
+		latest[sender] = command{action, at}
+	}
+
+	n.mu.Lock()
+	senders := make([]string, 0, len(latest))
+	for s := range latest {
+		senders = append(senders, s)
+	}
+	sort.Strings(senders)
+	for _, sender := range senders {
+		c := latest[sender]
+		if c.at <= n.commands[sender] {
+			continue // handled in an earlier pass
+		}
+		a := store.Get(sender, pub)
+		if a == nil {
+			continue // only authorizers can subscribe
+		}
+		n.commands[sender] = c.at
+		run.Commands++
+		var reply string
+		if c.action == "subscribe" {
+			if _, ok := n.subs[sender]; !ok {
+				n.subs[sender] = &NotifySubscriber{Pubkey: sender, SubscribedAt: now.Unix(), Notified: currentNotifyState(g, sender)}
+			}
+			s := n.subs[sender].Notified
+			reply = fmt.Sprintf("Subscribed. Your WoT score is %d out of 100, in %s of scored accounts. "+
+				"We'll DM you when it moves by %d or more, your tier changes or your spam classification changes, at most once a day. "+
+				"Reply \"unsubscribe\" to stop.", s.Score, notifyTierName(s.Tier), notifyMinScoreChange)
+		} else {
+			delete(n.subs, sender)
+			reply = "Unsubscribed. You won't get score change DMs any more. Reply \"subscribe\" to start again."
+		}
+		batch = append(batch, queuedDM{sender, a, reply})
+	}
+
+	pubkeys := make([]string, 0, len(n.subs))
+	for pk := range n.subs {
+		pubkeys = append(pubkeys, pk)
+	}
+	sort.Strings(pubkeys)
+	notified := 0
+	for _, pk := range pubkeys {
+		s := n.subs[pk]
+		a := store.Get(pk, pub)
+		if a == nil {
+			delete(n.subs, pk) // authorization revoked
+			continue
+		}
+		state := currentNotifyState(g, pk)
+		changes := notifyChanges(s.Notified, state)
+		if len(changes) == 0 {
+			continue
+		}
+		if now.Sub(time.Unix(s.LastNotified, 0)) < notifyMinInterval || notified == notifyMaxPerRun {
+			run.Deferred++
+			continue
+		}
+		s.Notified, s.LastNotified = state, now.Unix()
+		batch = append(batch, queuedDM{pk, a, strings.Join(changes, " ") + " Reply \"unsubscribe\" to stop these messages."})
+		notified++
+	}
+	run.Subscribers, run.Notified = len(n.subs), notified
+	n.mu.Unlock()
+
+	queued := 0
+	for _, dm := range batch {
+		if ctx.Err() != nil {
+			break
+		}
+		ev, err := wrapDM(sk, pub, dm.to, dm.content)
+		if err != nil {
+			log.Printf("Failed to wrap DM to %s: %v", shortKey(dm.to), err)
+			run.Failed++
+			continue
+		}
+		queued += publishQueue.Enqueue([]nostr.Event{ev}, dmRelays(ctx, dm.to, dm.auth))
+	}
+	n.mu.Lock()
+	n.sent += queued
+	n.last = run
+	if err := n.save(); err != nil {
+		log.Printf("Notification subscribers save failed: %v", err)
+	}
+	n.mu.Unlock()
+	log.Printf("Queued %d DMs (%d commands, %d subscribers, %d notified, %d deferred)",
+		queued, run.Commands, run.Subscribers, run.Notified, run.Deferred)
+	return queued, ctx.Err()
+}
+
+// queuedDM is a DM Run has decided to send.
+type queuedDM struct {
+	to      string
+	auth    *Authorization
+	content string
+}
+
+// runDMNotifications runs the notifier with the service's secret key, when
+// dm_notifications is on. DMs need the raw key for NIP-44 encryption, so a
+// remote signer alone isn't enough.
+func runDMNotifications(ctx context.Context, cfg Config) (int, error) {
+	if !cfg.DMNotifications {
+		return 0, nil
+	}
+	nsec, err := loadSecretKey(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sk, pub, err := decodeKey(nsec)
+	if err != nil {
+		return 0, err
+	}
+	return dmNotifier.Run(ctx, sk, pub, authStore, graph.Snapshot())
+}
+
+// Stats summarizes the subscribers and the last pass for /stats.
+func (n *DMNotifier) Stats(cfg Config) map[string]interface{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	stats := map[string]interface{}{
+		"enabled":     cfg.DMNotifications,
+		"subscribers": len(n.subs),
+		"sent":        n.sent,
+	}
+	if n.last != nil {
+		stats["last_run"] = *n.last
+	}
+	return stats
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip04"
+)
+
+func TestParseNotifyCommand(t *testing.T) {
+	for content, want := range map[string]string{
+		"subscribe":         "subscribe",
+		"  Start please":    "subscribe",
+		"STOP":              "unsubscribe",
+		"/unsubscribe":      "unsubscribe",
+		"unsubscribe me!":   "unsubscribe",
+		"what is my score?": "",
+		"":                  "",
+	} {
+		if got := parseNotifyCommand(content); got != want {
+			t.Errorf("%q: expected %q, got %q", content, want, got)
+		}
+	}
+}
+
+func TestNotifyChanges(t *testing.T) {
+	was := notifyState{Score: 40, Tier: 25, SpamClass: "likely_human"}
+	if c := notifyChanges(was, notifyState{Score: 45, Tier: 25, SpamClass: "likely_human"}); len(c) != 0 {
+		t.Errorf("expected a small move ignored, got %v", c)
+	}
+	c := notifyChanges(was, notifyState{Score: 28, Tier: 50, SpamClass: "suspicious"})
+	if len(c) != 3 || !strings.Contains(c[0], "from 40 to 28") || !strings.Contains(c[1], "the top 25% to the top 50%") {
+		t.Errorf("expected score, tier and spam changes, got %v", c)
+	}
+}
+
+// setupDMNotifier installs a scored graph with user in it, a notifier saving to a
+// temp file and a stubbed inbox, and returns the inbox to fill.
+func setupDMNotifier(t *testing.T, user string) (inbox *[]*nostr.Event) {
+	oldGraph, oldMeta, oldQueue, oldInbox, oldRelays := graph, meta, publishQueue, fetchDMInbox, fetchDMRelays
+	t.Cleanup(func() {
+		graph, meta, publishQueue, fetchDMInbox, fetchDMRelays = oldGraph, oldMeta, oldQueue, oldInbox, oldRelays
+	})
+	graph, meta, publishQueue = NewGraph(), NewMetaStore(), NewPublishQueue("")
+	for i := 0; i < 30; i++ {
+		graph.AddFollow(padHex(51500+i), padHex(51500+(i+1)%30))
+	}
+	graph.AddFollow(padHex(51500), user)
+	graph.ComputePageRank(20, 0.85)
+	graph.RefreshPercentileBuckets()
+
+	inbox = new([]*nostr.Event)
+	fetchDMInbox = func(ctx context.Context, pub string, since int64) []*nostr.Event { return *inbox }
+	fetchDMRelays = func(ctx context.Context, pubkey string) []string { return nil }
+	return inbox
+}
+
+// takeDMs decrypts the queued DMs as the recipient and empties the queue.
+func takeDMs(t *testing.T, sk string) []string {
+	t.Helper()
+	var texts []string
+	for _, item := range publishQueue.items {
+		if item.Event.Kind != nostr.KindGiftWrap {
+			t.Fatalf("expected a gift wrap, got kind %d", item.Event.Kind)
+		}
+		_, content, _, err := readDM(sk, &item.Event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		texts = append(texts, content)
+	}
+	publishQueue = NewPublishQueue("")
+	return texts
+}
+
+func TestDMNotifierRun(t *testing.T) {
+	serviceSK := nostr.GeneratePrivateKey()
+	servicePub, _ := nostr.GetPublicKey(serviceSK)
+	userSK := nostr.GeneratePrivateKey()
+	user, _ := nostr.GetPublicKey(userSK)
+	strangerSK := nostr.GeneratePrivateKey()
+	inbox := setupDMNotifier(t, user)
+
+	store := NewAuthStore()
+	store.Add(&Authorization{UserPubkey: user, ProviderPubkey: servicePub, Kinds: []string{"30382:rank"}, CreatedAt: 1})
+	path := filepath.Join(t.TempDir(), "notifications.json")
+	n := NewDMNotifier(path)
+	now := time.Now()
+	n.now = func() time.Time { return now }
+
+	subscribe, _ := wrapDM(userSK, user, servicePub, "subscribe")
+	strangerPub, _ := nostr.GetPublicKey(strangerSK)
+	stranger, _ := wrapDM(strangerSK, strangerPub, servicePub, "subscribe")
+	*inbox = []*nostr.Event{&subscribe, &stranger}
+
+	if sent, err := n.Run(context.Background(), serviceSK, servicePub, store, graph); sent != 1 || err != nil {
+		t.Fatalf("expected one confirmation, got %d %v", sent, err)
+	}
+	if dms := takeDMs(t, userSK); len(dms) != 1 || !strings.HasPrefix(dms[0], "Subscribed.") {
+		t.Errorf("expected the user's confirmation only, got %v", dms)
+	}
+
+	// the same inbox again is nothing new, and a restart remembers it
+	n = NewDMNotifier(path)
+	n.now = func() time.Time { return now }
+	if err := n.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if sent, _ := n.Run(context.Background(), serviceSK, servicePub, store, graph); sent != 0 {
+		t.Errorf("expected no DMs without a change, got %d", sent)
+	}
+
+	// a big score change is reported, once a day at most
+	for i := 1; i < 30; i++ {
+		graph.AddFollow(padHex(51500+i), user)
+	}
+	graph.ComputePageRank(20, 0.85)
+	graph.RefreshPercentileBuckets()
+	if sent, _ := n.Run(context.Background(), serviceSK, servicePub, store, graph); sent != 1 {
+		t.Fatalf("expected a change DM, got %d", sent)
+	}
+	if dms := takeDMs(t, userSK); len(dms) != 1 || !strings.Contains(dms[0], "Your WoT score went from") {
+		t.Errorf("expected the score change described, got %v", dms)
+	}
+	graph = NewGraph()
+	graph.AddFollow(padHex(51500), padHex(51501))
+	graph.ComputePageRank(20, 0.85)
+	n.Run(context.Background(), serviceSK, servicePub, store, graph)
+	if n.last.Deferred != 1 || len(publishQueue.items) != 0 {
+		t.Errorf("expected the drop deferred within a day, got %+v", *n.last)
+	}
+	now = now.Add(notifyMinInterval + time.Hour)
+	if sent, _ := n.Run(context.Background(), serviceSK, servicePub, store, graph); sent != 1 {
+		t.Errorf("expected the drop sent a day later, got %d", sent)
+	}
+	takeDMs(t, userSK)
+
+	// a NIP-04 "stop" reply unsubscribes
+	key, _ := nip04.ComputeSharedSecret(servicePub, userSK)
+	ciphertext, _ := nip04.Encrypt("STOP", key)
+	stop := nostr.Event{Kind: nostr.KindEncryptedDirectMessage, CreatedAt: nostr.Timestamp(now.Unix()),
+		Tags: nostr.Tags{{"p", servicePub}}, Content: ciphertext}
+	stop.Sign(userSK)
+	*inbox = append(*inbox, &stop)
+	n.Run(context.Background(), serviceSK, servicePub, store, graph)
+	if dms := takeDMs(t, userSK); len(dms) != 1 || !strings.HasPrefix(dms[0], "Unsubscribed.") {
+		t.Errorf("expected an unsubscribe confirmation, got %v", dms)
+	}
+	if n.Stats(defaultConfig)["subscribers"] != 0 {
+		t.Errorf("expected no subscribers left, got %v", n.Stats(defaultConfig))
+	}
+}
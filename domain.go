@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// domainListTTL is how long a fetched nostr.json directory is reused.
+	domainListTTL = time.Hour
+	// domainMaxMembers caps the pubkeys scored for one domain.
+	domainMaxMembers = 5000
+	// domainMaxTracked clears the directory cache once it holds this many domains.
+	domainMaxTracked = 10000
+	// domainTopMembers is how many of the best-scored members are listed.
+	domainTopMembers = 10
+	// A NIP-05 checkmark is worth rendering for domains at or above this
+	// reputation whose in-graph members are at most this share likely spam.
+	domainCheckmarkMinScore = 10
+	domainCheckmarkMaxSpam  = 0.2
+)
+
+var domainPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+type domainList struct {
+	names   map[string]string
+	err     string
+	fetched time.Time
+}
+
+// DomainDirectory knows which pubkeys are verified under a NIP-05 domain: the
+// domain's full nostr.json (most servers list every name when no name is asked
+// for), plus the identifiers /nip05 lookups have already resolved there.
+type DomainDirectory struct {
+	mu       sync.Mutex
+	client   *SafeHTTPClient
+	lists    map[string]*domainList
+	resolved map[string]map[string]string // domain -> name -> pubkey
+	urlFor   func(domain string) string
+}
+
+func NewDomainDirectory(client *SafeHTTPClient) *DomainDirectory {
+	return &DomainDirectory{
+		client:   client,
+		lists:    make(map[string]*domainList),
+		resolved: make(map[string]map[string]string),
+		urlFor: func(domain string) string {
+			return "https://" + domain + "/.well-known/nostr.json"
+		},
+	}
+}
+
+var domainDirectory = NewDomainDirectory(externalHTTP)
+
+// normalizeDomain lowercases a domain or NIP-05 identifier's domain part and
+// checks it is a plain hostname.
+func normalizeDomain(raw string) (string, error) {
+	d := strings.ToLower(strings.TrimSpace(raw))
+	if i := strings.LastIndex(d, "@"); i >= 0 {
+		d = d[i+1:]
+	}
+	d = strings.TrimSuffix(d, ".")
+	if !domainPattern.MatchString(d) || len(d) > 253 {
+		return "", fmt.Errorf("invalid domain %q", raw)
+	}
+	return d, nil
+}
+
+// Record notes that name@domain resolved to pubkey.
+func (dd *DomainDirectory) Record(domain, name, pubkey string) {
+	domain, err := normalizeDomain(domain)
+	if err != nil {
+		return
+	}
+	dd.mu.Lock()
+	defer dd.mu.Unlock()
+	if dd.resolved[domain] == nil {
+		if len(dd.resolved) >= domainMaxTracked {
+			dd.resolved = make(map[string]map[string]string)
+		}
+		dd.resolved[domain] = make(map[string]string)
+	}
+	dd.resolved[domain][strings.ToLower(name)] = pubkey
+}
+
+// fetch reads the domain's full nostr.json.
+func (dd *DomainDirectory) fetch(ctx context.Context, domain string) *domainList {
+	l := &domainList{fetched: time.Now()}
+	resp, err := dd.client.Get(ctx, dd.urlFor(domain))
+	if err != nil {
+		l.err = err.Error()
+		return l
+	}
+	if resp.StatusCode != http.StatusOK {
+		l.err = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		return l
+	}
+	var doc NIP05Response
+	if err := json.Unmarshal(resp.Body, &doc); err != nil {
+		l.err = "invalid nostr.json"
+		return l
+	}
+	l.names = make(map[string]string, len(doc.Names))
+	for name, pk := range doc.Names {
+		pk = strings.ToLower(pk)
+		if hex64Pattern.MatchString(pk) {
+			l.names[strings.ToLower(name)] = pk
+		}
+	}
+	return l
+}
+
+// Members returns the names known under domain, refreshing its nostr.json when
+// missing or older than domainListTTL. fetchErr is set when the directory couldn't
+// be read; names from earlier lookups are still returned.
+func (dd *DomainDirectory) Members(ctx context.Context, domain string) (names map[string]string, fetchErr string) {
+	dd.mu.Lock()
+	l, ok := dd.lists[domain]
+	dd.mu.Unlock()
+	if !ok || time.Since(l.fetched) >= domainListTTL {
+		l = dd.fetch(ctx, domain)
+		dd.mu.Lock()
+		if len(dd.lists) >= domainMaxTracked {
+			dd.lists = make(map[string]*domainList)
+		}
+		dd.lists[domain] = l
+		dd.mu.Unlock()
+	}
+
+	dd.mu.Lock()
+	defer dd.mu.Unlock()
+	names = make(map[string]string, len(l.names)+len(dd.resolved[domain]))
+	for name, pk := range l.names {
+		names[name] = pk
+	}
+	for name, pk := range dd.resolved[domain] {
+		if _, listed := names[name]; !listed {
+			names[name] = pk
+		}
+	}
+	return names, l.err
+}
+
+// DomainMember is one of a domain's best-scored pubkeys.
+type DomainMember struct {
+	Name   string `json:"name"`
+	Pubkey string `json:"pubkey"`
+	Score  int    `json:"score"`
+}
+
+// DomainReputation aggregates the trust of the pubkeys verified under a domain.
+type DomainReputation struct {
+	Domain          string         `json:"domain"`
+	Reputation      int            `json:"reputation"`
+	TrustLevel      string         `json:"trust_level"`
+	RenderCheckmark bool           `json:"render_checkmark"`
+	Confidence      string         `json:"confidence"`
+	Names           int            `json:"names"`
+	Pubkeys         int            `json:"pubkeys"`
+	Scored          int            `json:"scored"` // pubkeys scored, at most domainMaxMembers
+	InGraph         int            `json:"in_graph"`
+	AverageScore    float64        `json:"average_score"`
+	MedianScore     int            `json:"median_score"`
+	SpamRate        float64        `json:"spam_rate"`       // in-graph members classified likely_spam
+	SuspiciousRate  float64        `json:"suspicious_rate"` // in-graph members classified suspicious
+	TopMembers      []DomainMember `json:"top_members"`
+	DirectoryError  string         `json:"directory_error,omitempty"`
+	GraphSize       int            `json:"graph_size"`
+}
+
+// computeDomainReputation scores a domain's members. Members outside the graph
+// count as 0 toward the average and median, so a domain handing out names to
+// unknown keys scores low. The reputation is the mean of the average and median,
+// scaled down by the share of in-graph members that look like spam.
+func computeDomainReputation(g *Graph, domain string, names map[string]string) DomainReputation {
+	nodes := g.Stats().Nodes
+	rep := DomainReputation{Domain: domain, Names: len(names), GraphSize: nodes, TopMembers: []DomainMember{}}
+
+	// One entry per pubkey, under its alphabetically first name
+	byPubkey := make(map[string]string)
+	for name, pk := range names {
+		if existing, ok := byPubkey[pk]; !ok || name < existing {
+			byPubkey[pk] = name
+		}
+	}
+	rep.Pubkeys = len(byPubkey)
+	pubkeys := make([]string, 0, len(byPubkey))
+	for pk := range byPubkey {
+		pubkeys = append(pubkeys, pk)
+	}
+	sort.Strings(pubkeys)
+	if len(pubkeys) > domainMaxMembers {
+		pubkeys = pubkeys[:domainMaxMembers]
+	}
+	rep.Scored = len(pubkeys)
+
+	members := make([]DomainMember, 0, len(pubkeys))
+	scores := make([]int, 0, len(pubkeys))
+	var sum float64
+	var spam, suspicious int
+	for _, pk := range pubkeys {
+		raw, found := g.GetScore(pk)
+		score := 0
+		if found {
+			rep.InGraph++
+			score = normalizeScore(raw, nodes)
+			switch computeSpamWithPercentile(pk, nodes, 0).Classification {
+			case "likely_spam":
+				spam++
+			case "suspicious":
+				suspicious++
+			}
+		}
+		scores = append(scores, score)
+		sum += float64(score)
+		members = append(members, DomainMember{Name: byPubkey[pk], Pubkey: pk, Score: score})
+	}
+
+	rep.TrustLevel = nip05TrustLevel(0, false)
+	rep.Confidence = "low"
+	if len(scores) == 0 {
+		return rep
+	}
+	sort.Ints(scores)
+	rep.MedianScore = scores[len(scores)/2]
+	if len(scores)%2 == 0 {
+		rep.MedianScore = (scores[len(scores)/2-1] + scores[len(scores)/2]) / 2
+	}
+	rep.AverageScore = math.Round(sum/float64(len(scores))*10) / 10
+	if rep.InGraph > 0 {
+		rep.SpamRate = math.Round(float64(spam)/float64(rep.InGraph)*1000) / 1000
+		rep.SuspiciousRate = math.Round(float64(suspicious)/float64(rep.InGraph)*1000) / 1000
+	}
+	rep.Reputation = int(math.Round((rep.AverageScore + float64(rep.MedianScore)) / 2 * (1 - rep.SpamRate)))
+	rep.TrustLevel = nip05TrustLevel(rep.Reputation, rep.InGraph > 0)
+	rep.RenderCheckmark = rep.InGraph > 0 && rep.Reputation >= domainCheckmarkMinScore && rep.SpamRate <= domainCheckmarkMaxSpam
+	switch {
+	case rep.InGraph >= 50:
+		rep.Confidence = "high"
+	case rep.InGraph >= 5:
+		rep.Confidence = "medium"
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Score != members[j].Score {
+			return members[i].Score > members[j].Score
+		}
+		return members[i].Pubkey < members[j].Pubkey
+	})
+	for _, m := range members {
+		if len(rep.TopMembers) == domainTopMembers || m.Score == 0 {
+			break
+		}
+		rep.TopMembers = append(rep.TopMembers, m)
+	}
+	return rep
+}
+
+// handleDomain handles GET /domain?name=example.com
+// Aggregates the WoT scores of the pubkeys verified under a NIP-05 domain into a
+// domain reputation, so clients can decide whether to render its checkmark.
+func handleDomain(w http.ResponseWriter, r *http.Request) {
+	raw := r.URL.Query().Get("name")
+	if raw == "" {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "name parameter required (e.g. example.com)"})
+		return
+	}
+	domain, err := normalizeDomain(raw)
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+	names, fetchErr := domainDirectory.Members(ctx, domain)
+	if len(names) == 0 && fetchErr != "" {
+		writeAPIError(w, &APIError{Status: http.StatusBadGateway, Message: fmt.Sprintf("could not read nostr.json for %s: %s", domain, fetchErr)})
+		return
+	}
+
+	rep := computeDomainReputation(graph.Snapshot(), domain, names)
+	rep.DirectoryError = fetchErr
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rep)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testDomainDirectory serves nostr.json documents by domain from a local server.
+func testDomainDirectory(t *testing.T, docs map[string]string) (*DomainDirectory, *int) {
+	t.Helper()
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		doc, ok := docs[r.URL.Query().Get("domain")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(doc))
+	}))
+	t.Cleanup(srv.Close)
+	dd := NewDomainDirectory(NewSafeHTTPClient(FetchConfig{Timeout: 2 * time.Second, MaxBodyBytes: 1 << 16, AllowPrivate: true}))
+	dd.urlFor = func(domain string) string { return srv.URL + "/.well-known/nostr.json?domain=" + domain }
+	return dd, &fetches
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	for raw, want := range map[string]string{
+		"Example.COM":          "example.com",
+		"alice@example.com":    "example.com",
+		" sub.example.co.uk. ": "sub.example.co.uk",
+	} {
+		if got, err := normalizeDomain(raw); err != nil || got != want {
+			t.Errorf("normalizeDomain(%q) = %q, %v; want %q", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"localhost", "example.com/path", "example.com:443", "-bad.com", "http://example.com"} {
+		if _, err := normalizeDomain(raw); err == nil {
+			t.Errorf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestDomainDirectoryMembers(t *testing.T) {
+	dd, fetches := testDomainDirectory(t, map[string]string{
+		"example.com": fmt.Sprintf(`{"names":{"Alice":%q,"bob":%q,"bad":"npub1bad"}}`, padHex(39001), padHex(39002)),
+	})
+	dd.Record("example.com", "carol", padHex(39003))
+	dd.Record("example.com", "alice", padHex(39009)) // the directory wins
+
+	names, fetchErr := dd.Members(context.Background(), "example.com")
+	if fetchErr != "" || len(names) != 3 || names["alice"] != padHex(39001) || names["carol"] != padHex(39003) {
+		t.Fatalf("unexpected members %v %q", names, fetchErr)
+	}
+	dd.Members(context.Background(), "example.com")
+	if *fetches != 1 {
+		t.Errorf("expected the directory cached, got %d fetches", *fetches)
+	}
+
+	dd.Record("quiet.org", "dave", padHex(39004))
+	names, fetchErr = dd.Members(context.Background(), "quiet.org")
+	if fetchErr == "" || len(names) != 1 {
+		t.Errorf("expected resolved names despite a missing directory, got %v %q", names, fetchErr)
+	}
+}
+
+func TestComputeDomainReputation(t *testing.T) {
+	oldGraph, oldMeta := graph, meta
+	t.Cleanup(func() { graph, meta = oldGraph, oldMeta })
+	graph, meta = NewGraph(), NewMetaStore()
+	for i := 0; i < 8; i++ {
+		graph.AddFollow(fmt.Sprintf("%064x", 0x39100+i), padHex(39010))
+		if i < 4 {
+			graph.AddFollow(fmt.Sprintf("%064x", 0x39100+i), padHex(39011))
+		}
+	}
+	graph.ComputePageRank(20, 0.85)
+
+	rep := computeDomainReputation(graph, "example.com", map[string]string{
+		"alice":  padHex(39010),
+		"alias":  padHex(39010),
+		"bob":    padHex(39011),
+		"nobody": padHex(39012),
+	})
+	if rep.Names != 4 || rep.Pubkeys != 3 || rep.InGraph != 2 {
+		t.Fatalf("unexpected counts %+v", rep)
+	}
+	if len(rep.TopMembers) != 2 || rep.TopMembers[0].Pubkey != padHex(39010) || rep.TopMembers[0].Name != "alias" {
+		t.Errorf("expected the best-scored member first under its first name, got %+v", rep.TopMembers)
+	}
+	if rep.MedianScore != rep.TopMembers[1].Score {
+		t.Errorf("expected the middle score as median, got %d", rep.MedianScore)
+	}
+	want := float64(rep.TopMembers[0].Score+rep.TopMembers[1].Score) / 3
+	if rep.AverageScore < want-0.1 || rep.AverageScore > want+0.1 {
+		t.Errorf("expected unknown members to count as 0 in the average %.1f, got %.1f", want, rep.AverageScore)
+	}
+	if rep.Confidence != "low" || rep.Reputation == 0 {
+		t.Errorf("unexpected reputation %+v", rep)
+	}
+
+	empty := computeDomainReputation(graph, "empty.com", nil)
+	if empty.Reputation != 0 || empty.TrustLevel != "unknown" || empty.RenderCheckmark || empty.TopMembers == nil {
+		t.Errorf("unexpected empty domain %+v", empty)
+	}
+}
+
+func TestHandleDomain(t *testing.T) {
+	oldDir := domainDirectory
+	t.Cleanup(func() { domainDirectory = oldDir })
+	domainDirectory, _ = testDomainDirectory(t, map[string]string{
+		"example.com": fmt.Sprintf(`{"names":{"alice":%q}}`, padHex(39020)),
+	})
+
+	rr := httptest.NewRecorder()
+	handleDomain(rr, httptest.NewRequest(http.MethodGet, "/domain?name=alice@Example.com", nil))
+	var rep DomainReputation
+	if err := json.Unmarshal(rr.Body.Bytes(), &rep); rr.Code != http.StatusOK || err != nil {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rep.Domain != "example.com" || rep.Pubkeys != 1 {
+		t.Errorf("unexpected response %+v", rep)
+	}
+
+	for q, code := range map[string]int{
+		"":                    http.StatusBadRequest,
+		"?name=not_a_domain":  http.StatusBadRequest,
+		"?name=missing.org":   http.StatusBadGateway,
+		"?name=example.com/x": http.StatusBadRequest,
+	} {
+		rr = httptest.NewRecorder()
+		handleDomain(rr, httptest.NewRequest(http.MethodGet, "/domain"+q, nil))
+		if rr.Code != code {
+			t.Errorf("GET /domain%s: expected %d, got %d", q, code, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Community endorsements are NIP-32 label events (kind 1985) in which members of a
+// subject's trust community vouch for it:
+//
+//	["L", "wot.endorsement"]
+//	["l", "endorse", "wot.endorsement"]
+//	["p", <subject>]
+//
+// Once a quorum of well-scored community members has endorsed a subject, its score
+// receives a bounded boost. The boost is capped, can never lift the subject above
+// the median score of its endorsers (the ceiling), and every input is shown in /audit.
+const (
+	endorsementKind      = 1985
+	endorsementNamespace = "wot.endorsement"
+	endorsementLabel     = "endorse"
+
+	endorsementQuorum           = 3   // qualifying endorsers required before any boost applies
+	endorsementMinEndorserScore = 30  // endorsers below this WoT score are ignored
+	endorsementBoostPerEndorser = 2   // points per qualifying endorser
+	endorsementMaxBoost         = 10  // hard cap on the total boost
+	endorsementMaxAgeDays       = 180 // older endorsements expire
+)
+
+// Endorsement is one signed vouch from an endorser for a subject.
+type Endorsement struct {
+	Endorser  string `json:"endorser"`
+	Subject   string `json:"subject"`
+	EventID   string `json:"event_id"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// EndorsementStore holds the latest endorsement per (subject, endorser).
+type EndorsementStore struct {
+	mu sync.RWMutex
+	// subject -> endorser -> endorsement
+	data map[string]map[string]*Endorsement
+}
+
+func NewEndorsementStore() *EndorsementStore {
+	return &EndorsementStore{
+		data: make(map[string]map[string]*Endorsement),
+	}
+}
+
+// Add stores an endorsement, keeping only the newest per endorser.
+func (s *EndorsementStore) Add(e *Endorsement) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data[e.Subject] == nil {
+		s.data[e.Subject] = make(map[string]*Endorsement)
+	}
+	if existing := s.data[e.Subject][e.Endorser]; existing != nil && existing.CreatedAt >= e.CreatedAt {
+		return
+	}
+	s.data[e.Subject][e.Endorser] = e
+}
+
+// Get returns all endorsements for a subject.
+func (s *EndorsementStore) Get(subject string) []*Endorsement {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]*Endorsement, 0, len(s.data[subject]))
+	for _, e := range s.data[subject] {
+		out = append(out, e)
+	}
+	return out
+}
+
+// TotalEndorsements returns the number of stored endorsements.
+func (s *EndorsementStore) TotalEndorsements() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, byEndorser := range s.data {
+		n += len(byEndorser)
+	}
+	return n
+}
+
+// parseEndorsement extracts an endorsement from a kind 1985 label event.
+// Returns nil if the event is not a well-formed community endorsement.
+func parseEndorsement(ev *nostr.Event) *Endorsement {
+	if ev.Kind != endorsementKind {
+		return nil
+	}
+	hasNamespace, hasLabel := false, false
+	subject := ""
+	for _, tag := range ev.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "L":
+			if tag[1] == endorsementNamespace {
+				hasNamespace = true
+			}
+		case "l":
+			if tag[1] == endorsementLabel && len(tag) >= 3 && tag[2] == endorsementNamespace {
+				hasLabel = true
+			}
+		case "p":
+			if subject == "" && len(tag[1]) == 64 {
+				subject = tag[1]
+			}
+		}
+	}
+	if !hasNamespace || !hasLabel || subject == "" || subject == ev.PubKey {
+		return nil
+	}
+	return &Endorsement{
+		Endorser:  ev.PubKey,
+		Subject:   subject,
+		EventID:   ev.ID,
+		CreatedAt: int64(ev.CreatedAt),
+	}
+}
+
+// consumeEndorsements fetches community endorsement labels from relays.
+func consumeEndorsements(ctx context.Context, store *EndorsementStore) {
+	log.Printf("Consuming community endorsements (kind 1985, L=%s) from relays...", endorsementNamespace)
+
+	pool := nostr.NewSimplePool(ctx)
+
+	since := nostr.Timestamp(time.Now().Add(-endorsementMaxAgeDays * 24 * time.Hour).Unix())
+	filter := nostr.Filter{
+		Kinds: []int{endorsementKind},
+		Tags:  nostr.TagMap{"L": []string{endorsementNamespace}},
+		Since: &since,
+		Limit: 10000,
+	}
+
+	for ev := range pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter}) {
+		if e := parseEndorsement(ev.Event); e != nil {
+			store.Add(e)
+		}
+	}
+
+	log.Printf("Consumed %d community endorsements", store.TotalEndorsements())
+}
+
+// EndorserDetail describes one endorser and whether it counted toward the boost.
+type EndorserDetail struct {
+	Pubkey     string `json:"pubkey"`
+	Score      int    `json:"score"`
+	EventID    string `json:"event_id"`
+	CreatedAt  int64  `json:"created_at"`
+	Qualifying bool   `json:"qualifying"`
+	Reason     string `json:"reason,omitempty"` // why a non-qualifying endorsement was ignored
+}
+
+// EndorsementResult is the transparent breakdown of a community endorsement adjustment.
+type EndorsementResult struct {
+	BaseScore     int              `json:"base_score"`
+	AdjustedScore int              `json:"adjusted_score"`
+	Boost         int              `json:"boost"`
+	Ceiling       int              `json:"ceiling"`
+	Qualifying    int              `json:"qualifying_endorsers"`
+	Quorum        int              `json:"quorum"`
+	QuorumMet     bool             `json:"quorum_met"`
+	Endorsers     []EndorserDetail `json:"endorsers"`
+	Rules         map[string]int   `json:"rules"`
+	CommunityID   int              `json:"community_id"`
+	InCommunity   bool             `json:"in_community"`
+}
+
+// evaluateEndorsements applies the community endorsement rules to a subject's base score.
+// Endorsers must be in the subject's community and meet the minimum score. With a quorum,
+// the boost is endorsementBoostPerEndorser per endorser, capped at endorsementMaxBoost, and
+// the adjusted score may not exceed the median endorser score or 100. Scores never go down.
+func evaluateEndorsements(store *EndorsementStore, subject string, baseScore int) EndorsementResult {
+	result := EndorsementResult{
+		BaseScore:     baseScore,
+		AdjustedScore: baseScore,
+		Quorum:        endorsementQuorum,
+		Endorsers:     []EndorserDetail{},
+		Rules: map[string]int{
+			"quorum":             endorsementQuorum,
+			"min_endorser_score": endorsementMinEndorserScore,
+			"boost_per_endorser": endorsementBoostPerEndorser,
+			"max_boost":          endorsementMaxBoost,
+			"max_age_days":       endorsementMaxAgeDays,
+		},
+	}
+
+	subjectCommunity, inCommunity := communities.GetCommunity(subject)
+	result.CommunityID = subjectCommunity
+	result.InCommunity = inCommunity
+
+	nodes := graph.Stats().Nodes
+	cutoff := time.Now().Add(-endorsementMaxAgeDays * 24 * time.Hour).Unix()
+	var qualifyingScores []int
+
+	for _, e := range store.Get(subject) {
+		raw, _ := graph.GetScore(e.Endorser)
+		d := EndorserDetail{
+			Pubkey:    e.Endorser,
+			Score:     normalizeScore(raw, nodes),
+			EventID:   e.EventID,
+			CreatedAt: e.CreatedAt,
+		}
+		endorserCommunity, ok := communities.GetCommunity(e.Endorser)
+		switch {
+		case e.CreatedAt < cutoff:
+			d.Reason = "expired"
+		case !inCommunity || !ok || endorserCommunity != subjectCommunity:
+			d.Reason = "not in subject's community"
+		case d.Score < endorsementMinEndorserScore:
+			d.Reason = "endorser score below minimum"
+		default:
+			d.Qualifying = true
+			qualifyingScores = append(qualifyingScores, d.Score)
+		}
+		result.Endorsers = append(result.Endorsers, d)
+	}
+
+	sort.Slice(result.Endorsers, func(i, j int) bool {
+		if result.Endorsers[i].Qualifying != result.Endorsers[j].Qualifying {
+			return result.Endorsers[i].Qualifying
+		}
+		return result.Endorsers[i].Score > result.Endorsers[j].Score
+	})
+
+	result.Qualifying = len(qualifyingScores)
+	if result.Qualifying < endorsementQuorum {
+		return result
+	}
+	result.QuorumMet = true
+
+	sort.Ints(qualifyingScores)
+	result.Ceiling = qualifyingScores[len(qualifyingScores)/2]
+
+	boost := result.Qualifying * endorsementBoostPerEndorser
+	if boost > endorsementMaxBoost {
+		boost = endorsementMaxBoost
+	}
+	adjusted := baseScore + boost
+	if adjusted > result.Ceiling {
+		adjusted = result.Ceiling
+	}
+	if adjusted > 100 {
+		adjusted = 100
+	}
+	if adjusted < baseScore {
+		adjusted = baseScore
+	}
+	result.AdjustedScore = adjusted
+	result.Boost = adjusted - baseScore
+	return result
+}
+
+// handleEndorsements serves GET /endorsements?pubkey= (endorsement breakdown) and
+// POST /endorsements (submit a signed kind 1985 endorsement event directly).
+func handleEndorsements(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
+	switch r.Method {
+	case http.MethodGet:
+		q := bindQuery(r)
+		pubkey := q.Pubkey("pubkey", true)
+		if q.Failed(w) {
+			return
+		}
+		rawScore, _ := g.GetScore(pubkey)
+		result := evaluateEndorsements(endorsements, pubkey, normalizeScore(rawScore, g.Stats().Nodes))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"pubkey":      pubkey,
+			"endorsement": result,
+		})
+	case http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+		if err != nil {
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "failed to read body"})
+			return
+		}
+		var ev nostr.Event
+		if err := json.Unmarshal(body, &ev); err != nil {
+			writeAPIError(w, invalidParam("event", "invalid event JSON"))
+			return
+		}
+		if !ev.CheckID() {
+			writeAPIError(w, invalidParam("event", "event id mismatch"))
+			return
+		}
+		if ok, err := ev.CheckSignature(); err != nil || !ok {
+			writeAPIError(w, invalidParam("event", "invalid event signature"))
+			return
+		}
+		e := parseEndorsement(&ev)
+		if e == nil {
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("not a community endorsement (kind %d with L=%s, l=%s, p=<subject>)",
+				endorsementKind, endorsementNamespace, endorsementLabel)})
+			return
+		}
+		endorsements.Add(e)
+
+		rawScore, _ := g.GetScore(e.Subject)
+		result := evaluateEndorsements(endorsements, e.Subject, normalizeScore(rawScore, g.Stats().Nodes))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"accepted":    true,
+			"subject":     e.Subject,
+			"endorser":    e.Endorser,
+			"endorsement": result,
+		})
+	default:
+		writeAPIError(w, &APIError{Status: http.StatusMethodNotAllowed, Message: "GET or POST required"})
+	}
+}
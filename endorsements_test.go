@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func endorsementEvent(t *testing.T, sk, subject string) nostr.Event {
+	t.Helper()
+	ev := nostr.Event{
+		Kind:      endorsementKind,
+		CreatedAt: nostr.Now(),
+		Tags: nostr.Tags{
+			{"L", endorsementNamespace},
+			{"l", endorsementLabel, endorsementNamespace},
+			{"p", subject},
+		},
+	}
+	if err := ev.Sign(sk); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return ev
+}
+
+func TestParseEndorsement(t *testing.T) {
+	subject := padHex(9001)
+	ev := &nostr.Event{
+		ID:        "abc",
+		PubKey:    padHex(9002),
+		Kind:      endorsementKind,
+		CreatedAt: 1000,
+		Tags: nostr.Tags{
+			{"L", endorsementNamespace},
+			{"l", endorsementLabel, endorsementNamespace},
+			{"p", subject},
+		},
+	}
+	e := parseEndorsement(ev)
+	if e == nil {
+		t.Fatal("expected endorsement to parse")
+	}
+	if e.Subject != subject || e.Endorser != padHex(9002) || e.CreatedAt != 1000 {
+		t.Errorf("unexpected endorsement %+v", e)
+	}
+
+	// Wrong namespace
+	ev.Tags = nostr.Tags{{"L", "other"}, {"l", endorsementLabel, "other"}, {"p", subject}}
+	if parseEndorsement(ev) != nil {
+		t.Error("expected nil for wrong namespace")
+	}
+
+	// Self-endorsement
+	ev.Tags = nostr.Tags{{"L", endorsementNamespace}, {"l", endorsementLabel, endorsementNamespace}, {"p", ev.PubKey}}
+	if parseEndorsement(ev) != nil {
+		t.Error("expected nil for self-endorsement")
+	}
+
+	// Wrong kind
+	ev.Kind = 1
+	if parseEndorsement(ev) != nil {
+		t.Error("expected nil for wrong kind")
+	}
+}
+
+func TestEndorsementStoreKeepsNewest(t *testing.T) {
+	store := NewEndorsementStore()
+	subject := padHex(9010)
+	endorser := padHex(9011)
+	store.Add(&Endorsement{Endorser: endorser, Subject: subject, EventID: "new", CreatedAt: 200})
+	store.Add(&Endorsement{Endorser: endorser, Subject: subject, EventID: "old", CreatedAt: 100})
+
+	got := store.Get(subject)
+	if len(got) != 1 || got[0].EventID != "new" {
+		t.Fatalf("expected newest endorsement only, got %+v", got)
+	}
+	if store.TotalEndorsements() != 1 {
+		t.Errorf("expected 1 total, got %d", store.TotalEndorsements())
+	}
+}
+
+// setupEndorsementGraph builds a graph where endorsers[] are well-scored and all
+// pubkeys share one community except outsider.
+func setupEndorsementGraph(t *testing.T, subject, outsider string, endorsers []string) {
+	t.Helper()
+	graph = NewGraph()
+	for i, e := range endorsers {
+		for j := 0; j < 10; j++ {
+			graph.AddFollow(padHex(9500+i*10+j), e)
+		}
+		graph.AddFollow(e, subject)
+	}
+	graph.AddFollow(subject, endorsers[0])
+	graph.AddFollow(outsider, subject)
+	graph.ComputePageRank(20, 0.85)
+	// Pin endorsers well above the minimum endorser score.
+	for _, e := range endorsers {
+		graph.scores[e] = 1.0
+	}
+
+	communities = NewCommunityDetector()
+	communities.labels[subject] = 1
+	communities.labels[outsider] = 2
+	for _, e := range endorsers {
+		communities.labels[e] = 1
+	}
+}
+
+func TestEvaluateEndorsementsQuorumAndBounds(t *testing.T) {
+	oldGraph, oldCommunities := graph, communities
+	defer func() { graph, communities = oldGraph, oldCommunities }()
+
+	subject := padHex(9100)
+	outsider := padHex(9101)
+	endorsers := []string{padHex(9102), padHex(9103), padHex(9104), padHex(9105), padHex(9106), padHex(9107)}
+	setupEndorsementGraph(t, subject, outsider, endorsers)
+
+	store := NewEndorsementStore()
+	now := time.Now().Unix()
+
+	// Below quorum: no boost
+	store.Add(&Endorsement{Endorser: endorsers[0], Subject: subject, CreatedAt: now})
+	store.Add(&Endorsement{Endorser: endorsers[1], Subject: subject, CreatedAt: now})
+	r := evaluateEndorsements(store, subject, 10)
+	if r.QuorumMet || r.Boost != 0 || r.AdjustedScore != 10 {
+		t.Fatalf("expected no boost below quorum, got %+v", r)
+	}
+
+	// Out-of-community and expired endorsements never count
+	store.Add(&Endorsement{Endorser: outsider, Subject: subject, CreatedAt: now})
+	store.Add(&Endorsement{Endorser: endorsers[2], Subject: subject, CreatedAt: now - 365*24*3600})
+	r = evaluateEndorsements(store, subject, 10)
+	if r.Qualifying != 2 {
+		t.Fatalf("expected 2 qualifying endorsers, got %d", r.Qualifying)
+	}
+	reasons := map[string]string{}
+	for _, d := range r.Endorsers {
+		reasons[d.Pubkey] = d.Reason
+	}
+	if reasons[outsider] != "not in subject's community" {
+		t.Errorf("expected outsider rejected for community, got %q", reasons[outsider])
+	}
+	if reasons[endorsers[2]] != "expired" {
+		t.Errorf("expected expired endorsement, got %q", reasons[endorsers[2]])
+	}
+
+	// Reach quorum with six endorsers: boost capped at max
+	for _, e := range endorsers[2:] {
+		store.Add(&Endorsement{Endorser: e, Subject: subject, CreatedAt: now + 1})
+	}
+	r = evaluateEndorsements(store, subject, 10)
+	if !r.QuorumMet {
+		t.Fatalf("expected quorum met, got %+v", r)
+	}
+	if r.Boost == 0 {
+		t.Errorf("expected a boost once quorum is met, got %+v", r)
+	}
+	if r.Boost > endorsementMaxBoost {
+		t.Errorf("boost %d exceeds cap %d", r.Boost, endorsementMaxBoost)
+	}
+	if r.AdjustedScore > r.Ceiling && r.AdjustedScore != r.BaseScore {
+		t.Errorf("adjusted score %d exceeds ceiling %d", r.AdjustedScore, r.Ceiling)
+	}
+	if r.AdjustedScore < r.BaseScore {
+		t.Errorf("endorsements must never lower a score: %d < %d", r.AdjustedScore, r.BaseScore)
+	}
+
+	// A base score already above the endorsers' median is left alone
+	r = evaluateEndorsements(store, subject, 99)
+	if r.AdjustedScore != 99 || r.Boost != 0 {
+		t.Errorf("expected no change above ceiling, got %+v", r)
+	}
+}
+
+func TestEndorsementsEndpointPost(t *testing.T) {
+	oldStore := endorsements
+	defer func() { endorsements = oldStore }()
+	endorsements = NewEndorsementStore()
+
+	sk := nostr.GeneratePrivateKey()
+	subject := padHex(9200)
+	ev := endorsementEvent(t, sk, subject)
+	body, _ := json.Marshal(ev)
+
+	req := httptest.NewRequest("POST", "/endorsements", strings.NewReader(string(body)))
+	rr := httptest.NewRecorder()
+	handleEndorsements(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(endorsements.Get(subject)) != 1 {
+		t.Fatal("expected endorsement stored")
+	}
+
+	// Tampered event is rejected
+	ev.Tags = append(ev.Tags, nostr.Tag{"p", padHex(9201)})
+	body, _ = json.Marshal(ev)
+	req = httptest.NewRequest("POST", "/endorsements", strings.NewReader(string(body)))
+	rr = httptest.NewRecorder()
+	handleEndorsements(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for tampered event, got %d", rr.Code)
+	}
+}
+
+func TestEndorsementsEndpointGet(t *testing.T) {
+	req := httptest.NewRequest("GET", "/endorsements", nil)
+	rr := httptest.NewRecorder()
+	handleEndorsements(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without pubkey, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/endorsements?pubkey="+padHex(9300), nil)
+	rr = httptest.NewRecorder()
+	handleEndorsements(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var resp struct {
+		Endorsement EndorsementResult `json:"endorsement"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Endorsement.Quorum != endorsementQuorum {
+		t.Errorf("expected quorum %d in response", endorsementQuorum)
+	}
+}
+
+func TestAuditShowsCommunityEndorsement(t *testing.T) {
+	oldGraph, oldCommunities, oldStore := graph, communities, endorsements
+	defer func() { graph, communities, endorsements = oldGraph, oldCommunities, oldStore }()
+
+	subject := padHex(9400)
+	endorsers := []string{padHex(9401), padHex(9402), padHex(9403)}
+	setupEndorsementGraph(t, subject, padHex(9404), endorsers)
+	endorsements = NewEndorsementStore()
+	for _, e := range endorsers {
+		endorsements.Add(&Endorsement{Endorser: e, Subject: subject, CreatedAt: time.Now().Unix()})
+	}
+
+	req := httptest.NewRequest("GET", "/audit?pubkey="+subject, nil)
+	rr := httptest.NewRecorder()
+	handleAudit(rr, req)
+
+	var resp map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	ce, ok := resp["community_endorsement"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected community_endorsement in audit, got %s", rr.Body.String())
+	}
+	if ce["qualifying_endorsers"].(float64) < 1 {
+		t.Errorf("expected qualifying endorsers in audit breakdown")
+	}
+	if resp["final_score"].(float64) != ce["adjusted_score"].(float64) {
+		t.Errorf("final_score should reflect the endorsement adjustment")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,7 +23,8 @@ type EventMeta struct {
 	Reposts      int
 	Reactions    int
 	ZapCount     int
-	ZapAmount    int64 // sats
+	ZapAmount    int64           // sats
+	Engagers     DistinctCounter // unique pubkeys that reacted, reposted, replied, or zapped
 	CreatedAt    int64
 }
 
@@ -36,8 +39,13 @@ type AddressableEventMeta struct {
 	Reposts      int
 	Reactions    int
 	ZapCount     int
-	ZapAmount    int64 // sats
+	ZapAmount    int64           // sats
+	Engagers     DistinctCounter // unique pubkeys that reacted, reposted, replied, or zapped
 	CreatedAt    int64
+	Title        string   // long-form articles (kind 30023) only
+	Summary      string   // long-form articles only
+	Hashtags     []string // lowercased t tags
+	PublishedAt  int64    // the article's published_at tag, falling back to CreatedAt
 }
 
 // EventStore holds engagement metrics for events.
@@ -76,6 +84,35 @@ func (es *EventStore) GetAddressable(address string) *AddressableEventMeta {
 	return m
 }
 
+// EventEngagement is a copy of an event's counted engagement.
+type EventEngagement struct {
+	Reactions int   `json:"reactions"`
+	Reposts   int   `json:"reposts"`
+	Comments  int   `json:"comments"`
+	ZapCount  int   `json:"zap_count"`
+	ZapAmount int64 `json:"zap_sats"`
+	CreatedAt int64 `json:"-"`
+}
+
+// Engagement returns the engagement counted for event id, if it was crawled.
+// Unlike GetEvent it doesn't create an entry for unknown events.
+func (es *EventStore) Engagement(id string) (EventEngagement, bool) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	m, ok := es.events[id]
+	if !ok {
+		return EventEngagement{}, false
+	}
+	return EventEngagement{
+		Reactions: m.Reactions,
+		Reposts:   m.Reposts,
+		Comments:  m.Comments,
+		ZapCount:  m.ZapCount,
+		ZapAmount: m.ZapAmount,
+		CreatedAt: m.CreatedAt,
+	}, true
+}
+
 func (es *EventStore) EventCount() int {
 	es.mu.Lock()
 	defer es.mu.Unlock()
@@ -139,7 +176,7 @@ func (es *EventStore) CrawlEventEngagement(ctx context.Context, authorPubkeys []
 	pool := nostr.NewSimplePool(ctx)
 
 	// Step 1: Fetch recent kind 1 events from top authors
-	batchSize := 50
+	batchSize := crawlBatchSize(50, 10)
 	for i := 0; i < len(authorPubkeys); i += batchSize {
 		end := i + batchSize
 		if end > len(authorPubkeys) {
@@ -156,7 +193,7 @@ func (es *EventStore) CrawlEventEngagement(ctx context.Context, authorPubkeys []
 			Limit:   len(batch) * 10,
 		}
 
-		evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+		evCh := pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter})
 		eventIDs := make([]string, 0)
 		for ev := range evCh {
 			m := es.GetEvent(ev.Event.ID)
@@ -199,13 +236,14 @@ func (es *EventStore) crawlEventReactions(ctx context.Context, pool *nostr.Simpl
 		Limit: len(eventIDs) * 5,
 	}
 
-	evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+	evCh := pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter})
 	for ev := range evCh {
 		for _, tag := range ev.Event.Tags {
 			if tag[0] == "e" && len(tag) >= 2 {
 				m := es.GetEvent(tag[1])
 				es.mu.Lock()
 				m.Reactions++
+				m.Engagers.Add(ev.Event.PubKey)
 				es.mu.Unlock()
 				break
 			}
@@ -223,13 +261,14 @@ func (es *EventStore) crawlEventReposts(ctx context.Context, pool *nostr.SimpleP
 		Limit: len(eventIDs) * 3,
 	}
 
-	evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+	evCh := pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter})
 	for ev := range evCh {
 		for _, tag := range ev.Event.Tags {
 			if tag[0] == "e" && len(tag) >= 2 {
 				m := es.GetEvent(tag[1])
 				es.mu.Lock()
 				m.Reposts++
+				m.Engagers.Add(ev.Event.PubKey)
 				es.mu.Unlock()
 				break
 			}
@@ -247,13 +286,14 @@ func (es *EventStore) crawlEventReplies(ctx context.Context, pool *nostr.SimpleP
 		Limit: len(eventIDs) * 5,
 	}
 
-	evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+	evCh := pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter})
 	for ev := range evCh {
 		for _, tag := range ev.Event.Tags {
 			if tag[0] == "e" && len(tag) >= 2 {
 				m := es.GetEvent(tag[1])
 				es.mu.Lock()
 				m.Comments++
+				m.Engagers.Add(ev.Event.PubKey)
 				es.mu.Unlock()
 				break
 			}
@@ -271,7 +311,7 @@ func (es *EventStore) crawlEventZaps(ctx context.Context, pool *nostr.SimplePool
 		Limit: len(eventIDs) * 3,
 	}
 
-	evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+	evCh := pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter})
 	for ev := range evCh {
 		amount := extractZapAmount(ev.Event)
 		if amount <= 0 {
@@ -283,6 +323,9 @@ func (es *EventStore) crawlEventZaps(ctx context.Context, pool *nostr.SimplePool
 				es.mu.Lock()
 				m.ZapCount++
 				m.ZapAmount += amount
+				if sender := zapSender(ev.Event); sender != "" {
+					m.Engagers.Add(sender)
+				}
 				es.mu.Unlock()
 				break
 			}
@@ -292,7 +335,7 @@ func (es *EventStore) crawlEventZaps(ctx context.Context, pool *nostr.SimplePool
 
 // crawlAddressableEvents fetches long-form articles (kind 30023) and other addressable events.
 func (es *EventStore) crawlAddressableEvents(ctx context.Context, pool *nostr.SimplePool, authorPubkeys []string) {
-	batchSize := 50
+	batchSize := crawlBatchSize(50, 5)
 	for i := 0; i < len(authorPubkeys); i += batchSize {
 		end := i + batchSize
 		if end > len(authorPubkeys) {
@@ -307,26 +350,10 @@ func (es *EventStore) crawlAddressableEvents(ctx context.Context, pool *nostr.Si
 			Limit:   len(batch) * 5,
 		}
 
-		evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+		evCh := pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter})
 		addresses := make([]string, 0)
 		for ev := range evCh {
-			dTag := ""
-			for _, tag := range ev.Event.Tags {
-				if tag[0] == "d" && len(tag) >= 2 {
-					dTag = tag[1]
-					break
-				}
-			}
-			address := fmt.Sprintf("%d:%s:%s", ev.Event.Kind, ev.Event.PubKey, dTag)
-
-			m := es.GetAddressable(address)
-			es.mu.Lock()
-			m.AuthorPubkey = ev.Event.PubKey
-			m.Kind = ev.Event.Kind
-			m.DTag = dTag
-			m.CreatedAt = int64(ev.Event.CreatedAt)
-			es.mu.Unlock()
-			addresses = append(addresses, address)
+			addresses = append(addresses, es.RecordAddressable(ev.Event))
 		}
 
 		// Fetch engagement for addressable events by their a-tags
@@ -337,6 +364,56 @@ func (es *EventStore) crawlAddressableEvents(ctx context.Context, pool *nostr.Si
 	}
 }
 
+// RecordAddressable stores the author, d tag and, for articles, the title,
+// summary and hashtags of an addressable event and returns its address. An older
+// version of an event already seen doesn't overwrite the newer one.
+func (es *EventStore) RecordAddressable(ev *nostr.Event) string {
+	dTag := ""
+	if d := ev.Tags.GetFirst([]string{"d", ""}); d != nil {
+		dTag = d.Value()
+	}
+	address := fmt.Sprintf("%d:%s:%s", ev.Kind, ev.PubKey, dTag)
+
+	m := es.GetAddressable(address)
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if int64(ev.CreatedAt) < m.CreatedAt {
+		return address
+	}
+	m.AuthorPubkey = ev.PubKey
+	m.Kind = ev.Kind
+	m.DTag = dTag
+	m.CreatedAt = int64(ev.CreatedAt)
+	m.Title, m.Summary, m.Hashtags = "", "", nil
+	m.PublishedAt = m.CreatedAt
+	if ev.Kind != 30023 {
+		return address
+	}
+	seen := make(map[string]bool)
+	for _, tag := range ev.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "title":
+			m.Title = tag[1]
+		case "summary":
+			m.Summary = tag[1]
+		case "published_at":
+			if at, err := strconv.ParseInt(tag[1], 10, 64); err == nil && at > 0 {
+				m.PublishedAt = at
+			}
+		case "t":
+			t := strings.ToLower(strings.TrimPrefix(tag[1], "#"))
+			if t != "" && !seen[t] {
+				seen[t] = true
+				m.Hashtags = append(m.Hashtags, t)
+			}
+		}
+	}
+	return address
+}
+
 func (es *EventStore) crawlAddressableReactions(ctx context.Context, pool *nostr.SimplePool, addresses []string) {
 	filter := nostr.Filter{
 		Kinds: []int{7},
@@ -346,13 +423,14 @@ func (es *EventStore) crawlAddressableReactions(ctx context.Context, pool *nostr
 		Limit: len(addresses) * 5,
 	}
 
-	evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+	evCh := pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter})
 	for ev := range evCh {
 		for _, tag := range ev.Event.Tags {
 			if tag[0] == "a" && len(tag) >= 2 {
 				m := es.GetAddressable(tag[1])
 				es.mu.Lock()
 				m.Reactions++
+				m.Engagers.Add(ev.Event.PubKey)
 				es.mu.Unlock()
 				break
 			}
@@ -369,7 +447,7 @@ func (es *EventStore) crawlAddressableZaps(ctx context.Context, pool *nostr.Simp
 		Limit: len(addresses) * 3,
 	}
 
-	evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+	evCh := pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter})
 	for ev := range evCh {
 		amount := extractZapAmount(ev.Event)
 		if amount <= 0 {
@@ -381,6 +459,9 @@ func (es *EventStore) crawlAddressableZaps(ctx context.Context, pool *nostr.Simp
 				es.mu.Lock()
 				m.ZapCount++
 				m.ZapAmount += amount
+				if sender := zapSender(ev.Event); sender != "" {
+					m.Engagers.Add(sender)
+				}
 				es.mu.Unlock()
 				break
 			}
@@ -388,65 +469,60 @@ func (es *EventStore) crawlAddressableZaps(ctx context.Context, pool *nostr.Simp
 	}
 }
 
-// publishEventAssertions publishes kind 30383 events for top-scored events.
-func publishEventAssertions(ctx context.Context, es *EventStore, sk, pub string) (int, error) {
+// publishEventAssertions signs kind 30383 events for top-scored events and
+// queues them for the relays.
+func publishEventAssertions(ctx context.Context, es *EventStore, signer EventSigner) (int, error) {
 	topEvents := es.TopEvents(100)
 	if len(topEvents) == 0 {
 		return 0, nil
 	}
 
 	maxEng := eventEngagement(topEvents[0])
-	pool := nostr.NewSimplePool(ctx)
-	published := 0
+	batch := make([]nostr.Event, 0, len(topEvents))
 
-	for i, m := range topEvents {
+	for _, m := range topEvents {
 		rank := eventRank(m, maxEng)
 
 		ev := nostr.Event{
-			PubKey:    pub,
+			PubKey:    signer.PublicKey(),
 			CreatedAt: nostr.Now(),
 			Kind:      30383,
 			Tags: nostr.Tags{
 				{"d", m.EventID},
 				{"e", m.EventID},
-				{"p", m.AuthorPubkey},
 				{"rank", fmt.Sprintf("%d", rank)},
 				{"comments", fmt.Sprintf("%d", m.Comments)},
 				{"reposts", fmt.Sprintf("%d", m.Reposts)},
 				{"reactions", fmt.Sprintf("%d", m.Reactions)},
 				{"zap_count", fmt.Sprintf("%d", m.ZapCount)},
 				{"zap_amount", fmt.Sprintf("%d", m.ZapAmount)},
+				{"unique_engagers", fmt.Sprintf("%d", m.Engagers.Count())},
 			},
 		}
-
-		if err := ev.Sign(sk); err != nil {
-			log.Printf("Failed to sign kind 30383 for %s: %v", m.EventID, err)
-			continue
+		if m.AuthorPubkey != "" {
+			ev.Tags = append(ev.Tags, nostr.Tag{"p", m.AuthorPubkey})
 		}
 
-		ok := false
-		for result := range pool.PublishMany(ctx, relays, ev) {
-			if result.Error == nil {
-				ok = true
-			}
+		if err := validateAssertionEvent(&ev); err != nil {
+			log.Printf("Skipping kind 30383 for %s: %v", m.EventID, err)
+			continue
 		}
-		if ok {
-			published++
+		if err := signer.Sign(ctx, &ev); err != nil {
+			log.Printf("Failed to sign kind 30383 for %s: %v", m.EventID, err)
+			continue
 		}
 
-		time.Sleep(100 * time.Millisecond)
-		if (i+1)%50 == 0 {
-			log.Printf("Published %d/%d kind 30383 events", published, i+1)
-			time.Sleep(2 * time.Second)
-		}
+		batch = append(batch, ev)
 	}
 
-	log.Printf("Published %d kind 30383 (event assertion) events", published)
-	return published, nil
+	queued := publishQueue.Enqueue(batch, config.Relays())
+	log.Printf("Queued %d kind 30383 (event assertion) events", queued)
+	return queued, nil
 }
 
-// publishAddressableAssertions publishes kind 30384 events for addressable events.
-func publishAddressableAssertions(ctx context.Context, es *EventStore, sk, pub string) (int, error) {
+// publishAddressableAssertions signs kind 30384 events for addressable events and
+// queues them for the relays.
+func publishAddressableAssertions(ctx context.Context, es *EventStore, signer EventSigner) (int, error) {
 	es.mu.Lock()
 	entries := make([]*AddressableEventMeta, 0, len(es.addressable))
 	for _, m := range es.addressable {
@@ -467,10 +543,9 @@ func publishAddressableAssertions(ctx context.Context, es *EventStore, sk, pub s
 		}
 	}
 
-	pool := nostr.NewSimplePool(ctx)
-	published := 0
+	batch := make([]nostr.Event, 0, len(entries))
 
-	for i, m := range entries {
+	for _, m := range entries {
 		eng := int64(m.Reactions) + int64(m.Reposts)*2 + int64(m.Comments)*3 + m.ZapAmount
 		rank := 0
 		if maxEng > 0 {
@@ -483,44 +558,38 @@ func publishAddressableAssertions(ctx context.Context, es *EventStore, sk, pub s
 		}
 
 		ev := nostr.Event{
-			PubKey:    pub,
+			PubKey:    signer.PublicKey(),
 			CreatedAt: nostr.Now(),
 			Kind:      30384,
 			Tags: nostr.Tags{
 				{"d", m.Address},
 				{"a", m.Address},
-				{"p", m.AuthorPubkey},
 				{"rank", fmt.Sprintf("%d", rank)},
 				{"comments", fmt.Sprintf("%d", m.Comments)},
 				{"reposts", fmt.Sprintf("%d", m.Reposts)},
 				{"reactions", fmt.Sprintf("%d", m.Reactions)},
 				{"zap_count", fmt.Sprintf("%d", m.ZapCount)},
 				{"zap_amount", fmt.Sprintf("%d", m.ZapAmount)},
+				{"unique_engagers", fmt.Sprintf("%d", m.Engagers.Count())},
 			},
 		}
-
-		if err := ev.Sign(sk); err != nil {
-			log.Printf("Failed to sign kind 30384 for %s: %v", m.Address, err)
-			continue
+		if m.AuthorPubkey != "" {
+			ev.Tags = append(ev.Tags, nostr.Tag{"p", m.AuthorPubkey})
 		}
 
-		ok := false
-		for result := range pool.PublishMany(ctx, relays, ev) {
-			if result.Error == nil {
-				ok = true
-			}
+		if err := validateAssertionEvent(&ev); err != nil {
+			log.Printf("Skipping kind 30384 for %s: %v", m.Address, err)
+			continue
 		}
-		if ok {
-			published++
+		if err := signer.Sign(ctx, &ev); err != nil {
+			log.Printf("Failed to sign kind 30384 for %s: %v", m.Address, err)
+			continue
 		}
 
-		time.Sleep(100 * time.Millisecond)
-		if (i+1)%50 == 0 {
-			log.Printf("Published %d/%d kind 30384 events", published, i+1)
-			time.Sleep(2 * time.Second)
-		}
+		batch = append(batch, ev)
 	}
 
-	log.Printf("Published %d kind 30384 (addressable event assertion) events", published)
-	return published, nil
+	queued := publishQueue.Enqueue(batch, config.Relays())
+	log.Printf("Queued %d kind 30384 (addressable event assertion) events", queued)
+	return queued, nil
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"encoding/json"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -204,3 +206,23 @@ func TestEventStoreCount(t *testing.T) {
 		t.Errorf("expected 1 addressable, got %d", es.AddressableCount())
 	}
 }
+
+func TestEventScoreUniqueEngagers(t *testing.T) {
+	oldEvents := events
+	defer func() { events = oldEvents }()
+	events = NewEventStore()
+
+	m := events.GetEvent("e1")
+	for _, pk := range []string{"alice", "bob", "alice"} {
+		m.Reactions++
+		m.Engagers.Add(pk)
+	}
+
+	w := httptest.NewRecorder()
+	handleEventScore(w, httptest.NewRequest("GET", "/event?id=e1", nil))
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["reactions"] != float64(3) || resp["unique_engagers"] != float64(2) {
+		t.Errorf("expected 3 reactions from 2 engagers, got %v", resp)
+	}
+}
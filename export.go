@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// exportMaxLimit caps one page of a paginated export.
+	exportMaxLimit = 100000
+	// exportFlushEvery is how many entries are written between flushes, so clients
+	// see data arrive while a large export is still being written.
+	exportFlushEvery = 5000
+)
+
+type ExportEntry struct {
+	Pubkey string  `json:"pubkey"`
+	Rank   int     `json:"rank"`
+	Raw    float64 `json:"raw"`
+}
+
+// exportFormats are the body formats /export and /top can stream, chosen with
+// ?format=. NIP19FormatMiddleware lets them through on exportFormatPaths.
+var exportFormats = map[string]bool{"json": true, "ndjson": true, "csv": true, "parquet": true}
+
+var exportFormatPaths = map[string]bool{"/export": true, "/top": true}
+
+// exportFormatPath reports whether path serves the export formats: the paths in
+// exportFormatPaths and each snapshot's /snapshots/{id}/export.
+func exportFormatPath(path string) bool {
+	if exportFormatPaths[path] {
+		return true
+	}
+	id, ok := strings.CutPrefix(path, "/snapshots/")
+	id, ok2 := strings.CutSuffix(id, "/export")
+	return ok && ok2 && id != "" && !strings.Contains(id, "/")
+}
+
+// exportSnapshot caches the score table sorted by score (highest first), then
+// pubkey, so paginated exports sort the graph once per build revision rather than
+// once per page.
+type exportSnapshot struct {
+	mu      sync.Mutex
+	graph   *Graph
+	id, rev uint64
+	nodes   int
+	entries []ScoreEntry
+}
+
+var exportCache = &exportSnapshot{}
+
+// Entries returns the sorted score table for g and the build it belongs to.
+func (s *exportSnapshot) Entries(g *Graph) ([]ScoreEntry, uint64) {
+	id, rev, _ := graphBuild.Current()
+	nodes := g.Stats().Nodes
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries != nil && s.graph == g && s.id == id && s.rev == rev && s.nodes == nodes {
+		return s.entries, id
+	}
+	scores := g.ScoresSnapshot()
+	entries := make([]ScoreEntry, 0, len(scores))
+	for pk, score := range scores {
+		entries = append(entries, ScoreEntry{Pubkey: pk, Score: score})
+	}
+	sort.Slice(entries, func(i, j int) bool { return exportBefore(entries[i], entries[j]) })
+	s.graph, s.id, s.rev, s.nodes, s.entries = g, id, rev, nodes, entries
+	return entries, id
+}
+
+// exportBefore is the export order: score descending, then pubkey ascending.
+func exportBefore(a, b ScoreEntry) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.Pubkey < b.Pubkey
+}
+
+// exportCursor marks the last entry of a page. Pages are keyed on the entry
+// itself rather than an offset, so small score changes within a build (hints,
+// live writes) don't skip or repeat entries at page boundaries.
+type exportCursor struct {
+	Build  uint64
+	Score  float64
+	Pubkey string
+}
+
+func (c exportCursor) String() string {
+	raw := fmt.Sprintf("%d:%s:%s", c.Build, strconv.FormatFloat(c.Score, 'g', -1, 64), c.Pubkey)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseExportCursor(s string) (exportCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return exportCursor{}, err
+	}
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) != 3 || !hex64Pattern.MatchString(parts[2]) {
+		return exportCursor{}, fmt.Errorf("malformed cursor")
+	}
+	var c exportCursor
+	if c.Build, err = strconv.ParseUint(parts[0], 10, 64); err != nil {
+		return exportCursor{}, err
+	}
+	if c.Score, err = strconv.ParseFloat(parts[1], 64); err != nil {
+		return exportCursor{}, err
+	}
+	c.Pubkey = parts[2]
+	return c, nil
+}
+
+// acceptsGzip reports whether the client listed gzip in Accept-Encoding.
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.EqualFold(strings.TrimSpace(name), "gzip") && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// exportWriter buffers entries into the response, optionally through gzip, and
+// flushes every exportFlushEvery entries so the body streams.
+type exportWriter struct {
+	w       http.ResponseWriter
+	gz      *gzip.Writer
+	buf     *bufio.Writer
+	written int
+}
+
+func newExportWriter(w http.ResponseWriter, compress bool) *exportWriter {
+	ew := &exportWriter{w: w}
+	var out io.Writer = w
+	if compress {
+		ew.gz = gzip.NewWriter(w)
+		out = ew.gz
+	}
+	ew.buf = bufio.NewWriterSize(out, 64*1024)
+	return ew
+}
+
+// entryDone counts one written entry and flushes when a batch is complete.
+func (ew *exportWriter) entryDone() {
+	ew.written++
+	if ew.written%exportFlushEvery == 0 {
+		ew.flush()
+	}
+}
+
+func (ew *exportWriter) flush() {
+	ew.buf.Flush()
+	if ew.gz != nil {
+		ew.gz.Flush()
+	}
+	http.NewResponseController(ew.w).Flush()
+}
+
+func (ew *exportWriter) Close() {
+	ew.buf.Flush()
+	if ew.gz != nil {
+		ew.gz.Close()
+	}
+}
+
+// handleExport streams every scored pubkey, highest score first. Without limit the
+// whole table is returned in one response (a JSON array, as before); with limit it
+// is returned a page at a time, and the X-Next-Cursor header and a Link rel="next"
+// header point at the next page until the last one. format=ndjson, csv or parquet
+// writes the full score table (see ExportRow) instead of the array. The body is
+// gzipped when the client accepts it.
+func handleExport(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
+	stats := g.Stats()
+	if stats.Nodes == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "graph not built yet"})
+		return
+	}
+	q := bindQuery(r)
+	format := q.String("format", false)
+	limit := q.Int("limit", 0, 1, exportMaxLimit)
+	cursor := q.String("cursor", false)
+	if q.Failed(w) {
+		return
+	}
+	switch format {
+	case "", "hex", "npub":
+		format = "json"
+	}
+	if !exportFormats[format] {
+		writeAPIError(w, invalidParam("format", "format must be json, ndjson, csv or parquet"))
+		return
+	}
+
+	entries, build := exportCache.Entries(g)
+	start := 0
+	if cursor != "" {
+		c, err := parseExportCursor(cursor)
+		if err != nil {
+			writeAPIError(w, invalidParam("cursor", "invalid cursor"))
+			return
+		}
+		if c.Build != build {
+			writeAPIError(w, &APIError{Status: http.StatusGone, Message: "scores were rebuilt since this cursor was issued; restart the export"})
+			return
+		}
+		last := ScoreEntry{Pubkey: c.Pubkey, Score: c.Score}
+		start = sort.Search(len(entries), func(i int) bool { return exportBefore(last, entries[i]) })
+	}
+	page := entries[start:]
+	if limit > 0 && limit < len(page) {
+		page = page[:limit]
+		last := page[len(page)-1]
+		next := exportCursor{Build: build, Score: last.Score, Pubkey: last.Pubkey}.String()
+		nextQuery := r.URL.Query()
+		nextQuery.Set("cursor", next)
+		w.Header().Set("X-Next-Cursor", next)
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, nextQuery.Encode()))
+	}
+
+	serveExportTable(w, r, g, format, page, start)
+}
+
+// ExportRow is one row of the score table in the ndjson, csv and parquet formats.
+type ExportRow struct {
+	Rank      int     `json:"rank"` // position by score, 1 = highest
+	Pubkey    string  `json:"pubkey"`
+	Score     int     `json:"score"` // normalized 0-100
+	RawScore  float64 `json:"raw_score"`
+	Followers int     `json:"followers"`
+	Follows   int     `json:"follows"`
+	Community *int    `json:"community"` // label from community detection, null when unassigned
+}
+
+var exportColumns = []ParquetColumn{
+	{Name: "rank", Type: parquetInt64},
+	{Name: "pubkey", Type: parquetByteArray, String: true},
+	{Name: "score", Type: parquetInt32},
+	{Name: "raw_score", Type: parquetDouble},
+	{Name: "followers", Type: parquetInt32},
+	{Name: "follows", Type: parquetInt32},
+	{Name: "community", Type: parquetInt32, Optional: true},
+}
+
+// exportTableWriter writes rows in one export format.
+type exportTableWriter interface {
+	WriteRow(row ExportRow) error
+	Close() error
+}
+
+// serveExportTable streams entries of g (which start at position offset in the
+// score table) in format, gzipped when the client accepts it. json keeps the original
+// /export shape; the other formats write full ExportRows.
+func serveExportTable(w http.ResponseWriter, r *http.Request, g *Graph, format string, entries []ScoreEntry, offset int) {
+	nodes := g.Stats().Nodes
+	serveExportRows(w, r, format, "wot-scores", func(emit func(ExportRow) bool) {
+		for i, e := range entries {
+			row := ExportRow{
+				Rank:      offset + i + 1,
+				Pubkey:    e.Pubkey,
+				Score:     normalizeScore(e.Score, nodes),
+				RawScore:  e.Score,
+				Followers: len(g.GetFollowers(e.Pubkey)),
+				Follows:   len(g.GetFollows(e.Pubkey)),
+			}
+			if c, ok := communities.GetCommunity(e.Pubkey); ok {
+				row.Community = &c
+			}
+			if !emit(row) {
+				return
+			}
+		}
+	})
+}
+
+// serveExportRows streams the rows rows emits in format, gzipped when the client
+// accepts it. csv and parquet download as filename plus the format's extension.
+// emit returns false once the client has gone away.
+func serveExportRows(w http.ResponseWriter, r *http.Request, format, filename string, rows func(emit func(ExportRow) bool)) {
+	h := w.Header()
+	switch format {
+	case "ndjson":
+		h.Set("Content-Type", "application/x-ndjson")
+	case "csv":
+		h.Set("Content-Type", "text/csv; charset=utf-8")
+		h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+	case "parquet":
+		h.Set("Content-Type", "application/vnd.apache.parquet")
+		h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.parquet"`, filename))
+	default:
+		h.Set("Content-Type", "application/json")
+	}
+	h.Add("Vary", "Accept-Encoding")
+	// ?format=npub rewrites the finished body, which it can't do once compressed
+	compress := acceptsGzip(r) && r.URL.Query().Get("format") != "npub"
+	if compress {
+		h.Set("Content-Encoding", "gzip")
+	}
+
+	ew := newExportWriter(w, compress)
+	defer ew.Close()
+	var tw exportTableWriter
+	switch format {
+	case "ndjson":
+		tw = &ndjsonExportWriter{w: ew.buf}
+	case "csv":
+		tw = newCSVExportWriter(ew.buf)
+	case "parquet":
+		tw = &parquetExportWriter{pw: NewParquetWriter(ew.buf, exportColumns)}
+	default:
+		tw = &jsonExportWriter{w: ew.buf}
+	}
+	rows(func(row ExportRow) bool {
+		if err := tw.WriteRow(row); err != nil {
+			return false // client went away
+		}
+		ew.entryDone()
+		return true
+	})
+	tw.Close()
+}
+
+// jsonExportWriter writes the original /export array of {pubkey, rank, raw}, where
+// rank is the normalized score.
+type jsonExportWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func (j *jsonExportWriter) WriteRow(row ExportRow) error {
+	sep := byte(',')
+	if j.count == 0 {
+		sep = '['
+	}
+	j.count++
+	j.w.WriteByte(sep)
+	line, _ := json.Marshal(ExportEntry{Pubkey: row.Pubkey, Rank: row.Score, Raw: row.RawScore})
+	_, err := j.w.Write(line)
+	return err
+}
+
+func (j *jsonExportWriter) Close() error {
+	if j.count == 0 {
+		j.w.WriteByte('[')
+	}
+	_, err := j.w.WriteString("]\n")
+	return err
+}
+
+type ndjsonExportWriter struct {
+	w *bufio.Writer
+}
+
+func (n *ndjsonExportWriter) WriteRow(row ExportRow) error {
+	line, _ := json.Marshal(row)
+	n.w.Write(line)
+	return n.w.WriteByte('\n')
+}
+
+func (n *ndjsonExportWriter) Close() error { return nil }
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer) *csvExportWriter {
+	c := &csvExportWriter{w: csv.NewWriter(w)}
+	header := make([]string, len(exportColumns))
+	for i, col := range exportColumns {
+		header[i] = col.Name
+	}
+	c.w.Write(header)
+	return c
+}
+
+func (c *csvExportWriter) WriteRow(row ExportRow) error {
+	community := ""
+	if row.Community != nil {
+		community = strconv.Itoa(*row.Community)
+	}
+	return c.w.Write([]string{
+		strconv.Itoa(row.Rank),
+		row.Pubkey,
+		strconv.Itoa(row.Score),
+		strconv.FormatFloat(row.RawScore, 'g', -1, 64),
+		strconv.Itoa(row.Followers),
+		strconv.Itoa(row.Follows),
+		community,
+	})
+}
+
+func (c *csvExportWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type parquetExportWriter struct {
+	pw *ParquetWriter
+}
+
+func (p *parquetExportWriter) WriteRow(row ExportRow) error {
+	var community interface{}
+	if row.Community != nil {
+		community = *row.Community
+	}
+	return p.pw.WriteRow(row.Rank, row.Pubkey, row.Score, row.RawScore, row.Followers, row.Follows, community)
+}
+
+func (p *parquetExportWriter) Close() error { return p.pw.Close() }
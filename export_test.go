@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func exportTestGraph() *Graph {
+	g := NewGraph()
+	for i := 0; i < 25; i++ {
+		for j := 0; j <= i%5; j++ {
+			g.AddFollow(padHex(40000+i), padHex(40100+j))
+		}
+	}
+	g.ComputePageRank(20, 0.85)
+	return g
+}
+
+func TestExportDefaultArray(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = exportTestGraph()
+
+	rr := httptest.NewRecorder()
+	handleExport(rr, httptest.NewRequest(http.MethodGet, "/export", nil))
+	var entries []ExportEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("expected a JSON array: %v", err)
+	}
+	if len(entries) != graph.Stats().Nodes || rr.Header().Get("X-Next-Cursor") != "" {
+		t.Errorf("expected every node in one response, got %d", len(entries))
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Raw > entries[i-1].Raw {
+			t.Fatalf("expected highest scores first at %d", i)
+		}
+	}
+}
+
+func TestExportPagination(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = exportTestGraph()
+
+	seen := make(map[string]bool)
+	url, pages := "/export?limit=7&format=ndjson", 0
+	for url != "" {
+		rr := httptest.NewRecorder()
+		handleExport(rr, httptest.NewRequest(http.MethodGet, url, nil))
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/x-ndjson" {
+			t.Fatalf("page %d: got %d %q", pages, rr.Code, rr.Header().Get("Content-Type"))
+		}
+		lines := 0
+		sc := bufio.NewScanner(rr.Body)
+		for sc.Scan() {
+			var e ExportEntry
+			if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+				t.Fatalf("page %d: bad line %q", pages, sc.Text())
+			}
+			if seen[e.Pubkey] {
+				t.Fatalf("page %d: %s repeated", pages, e.Pubkey)
+			}
+			seen[e.Pubkey] = true
+			lines++
+		}
+		if lines == 0 || lines > 7 {
+			t.Fatalf("page %d: expected 1-7 entries, got %d", pages, lines)
+		}
+		pages++
+
+		url = ""
+		if next := rr.Header().Get("X-Next-Cursor"); next != "" {
+			if !strings.Contains(rr.Header().Get("Link"), next) {
+				t.Errorf("expected the Link header to carry the cursor, got %q", rr.Header().Get("Link"))
+			}
+			url = "/export?limit=7&format=ndjson&cursor=" + next
+		}
+	}
+	if nodes := graph.Stats().Nodes; len(seen) != nodes || pages != (nodes+6)/7 {
+		t.Errorf("expected %d entries over %d pages, got %d over %d", nodes, (nodes+6)/7, len(seen), pages)
+	}
+}
+
+func TestExportCursorErrors(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = exportTestGraph()
+
+	rr := httptest.NewRecorder()
+	handleExport(rr, httptest.NewRequest(http.MethodGet, "/export?limit=5", nil))
+	next := rr.Header().Get("X-Next-Cursor")
+	c, err := parseExportCursor(next)
+	if err != nil {
+		t.Fatalf("expected a valid cursor, got %q: %v", next, err)
+	}
+
+	c.Build++
+	for url, want := range map[string]int{
+		"/export?cursor=" + c.String(): http.StatusGone,
+		"/export?cursor=not-a-cursor":  http.StatusBadRequest,
+		"/export?limit=0":              http.StatusBadRequest,
+		"/export?format=xml":           http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		handleExport(rr, httptest.NewRequest(http.MethodGet, url, nil))
+		if rr.Code != want {
+			t.Errorf("%s: expected %d, got %d", url, want, rr.Code)
+		}
+	}
+}
+
+func TestExportGzip(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = exportTestGraph()
+
+	// through the middleware, so ?format=ndjson isn't rejected as a key format
+	handler := NIP19FormatMiddleware(http.HandlerFunc(handleExport))
+	req := httptest.NewRequest(http.MethodGet, "/export?format=ndjson", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a gzipped body, got %d %q", rr.Code, rr.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	lines := 0
+	for sc := bufio.NewScanner(zr); sc.Scan(); {
+		lines++
+	}
+	if lines != graph.Stats().Nodes {
+		t.Errorf("expected %d lines, got %d", graph.Stats().Nodes, lines)
+	}
+
+	for _, enc := range []string{"", "gzip;q=0", "deflate"} {
+		req.Header.Set("Accept-Encoding", enc)
+		if acceptsGzip(req) {
+			t.Errorf("expected %q not to accept gzip", enc)
+		}
+	}
+}
+
+func TestExportCSVAndParquet(t *testing.T) {
+	oldGraph, oldCommunities := graph, communities
+	defer func() { graph, communities = oldGraph, oldCommunities }()
+	graph = exportTestGraph()
+	communities = NewCommunityDetector()
+	communities.DetectCommunities(graph, 10)
+
+	handler := NIP19FormatMiddleware(http.HandlerFunc(handleExport))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/export?format=csv&limit=10", nil))
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil || rr.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("expected CSV, got %q (%v)", rr.Header().Get("Content-Type"), err)
+	}
+	if len(records) != 11 || strings.Join(records[0], ",") != "rank,pubkey,score,raw_score,followers,follows,community" {
+		t.Fatalf("expected a header and 10 rows, got %v", records)
+	}
+	top := records[1]
+	if top[0] != "1" || top[4] != strconv.Itoa(len(graph.GetFollowers(top[1]))) {
+		t.Errorf("unexpected first row %v", top)
+	}
+	// accounts without a follow list aren't assigned a community
+	labelled := 0
+	for _, rec := range records[1:] {
+		want := ""
+		if c, ok := communities.GetCommunity(rec[1]); ok {
+			want = strconv.Itoa(c)
+			labelled++
+		}
+		if rec[6] != want {
+			t.Errorf("expected community %q for %s, got %q", want, rec[1], rec[6])
+		}
+	}
+	if labelled == 0 {
+		t.Error("expected some rows with a community")
+	}
+
+	// the second page continues the ranks
+	rr2 := httptest.NewRecorder()
+	handler.ServeHTTP(rr2, httptest.NewRequest(http.MethodGet, "/export?format=csv&limit=10&cursor="+rr.Header().Get("X-Next-Cursor"), nil))
+	if records, _ := csv.NewReader(rr2.Body).ReadAll(); len(records) < 2 || records[1][0] != "11" {
+		t.Errorf("expected the second page to start at rank 11, got %v", records)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/export?format=parquet", nil))
+	body := rr.Body.Bytes()
+	if rr.Code != http.StatusOK || !bytes.HasPrefix(body, []byte("PAR1")) || !bytes.HasSuffix(body, []byte("PAR1")) {
+		t.Fatalf("expected a parquet file, got %d", rr.Code)
+	}
+	footerLen := int(binary.LittleEndian.Uint32(body[len(body)-8:]))
+	meta := (&thriftReader{b: body, p: len(body) - 8 - footerLen}).structure()
+	if meta[3].(int64) != int64(graph.Stats().Nodes) || len(meta[2].([]interface{})) != len(exportColumns)+1 {
+		t.Errorf("expected every node and column in the parquet footer, got %v", meta)
+	}
+}
+
+func TestTopFormats(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+	for i := 0; i < 80; i++ {
+		graph.AddFollow(padHex(40500+i), padHex(40600+i%60))
+	}
+	graph.ComputePageRank(20, 0.85)
+
+	handler := NIP19FormatMiddleware(http.HandlerFunc(handleTop))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/top?format=ndjson", nil))
+	var rows []ExportRow
+	for sc := bufio.NewScanner(rr.Body); sc.Scan(); {
+		var row ExportRow
+		json.Unmarshal(sc.Bytes(), &row)
+		rows = append(rows, row)
+	}
+	if len(rows) != 50 || rows[0].Rank != 1 || rows[49].Rank != 50 || rows[0].Followers == 0 {
+		t.Fatalf("expected the top 50 as rows, got %d", len(rows))
+	}
+
+	// the default stays the JSON array /top always returned
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/top", nil))
+	var top []TopEntry
+	if err := json.Unmarshal(rr.Body.Bytes(), &top); err != nil || len(top) != 50 {
+		t.Errorf("expected the JSON top list, got %d (%v)", len(top), err)
+	}
+
+	// other endpoints still reject export formats
+	rr = httptest.NewRecorder()
+	NIP19FormatMiddleware(http.HandlerFunc(handleScore)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/score?pubkey="+padHex(40600)+"&format=csv", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for /score?format=csv, got %d", rr.Code)
+	}
+}
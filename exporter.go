@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// exporterBatch is the most pubkeys /batch and /spam/batch take per request.
+	exporterBatch = 100
+	// exporterDefaultInterval keeps a list of up to 100 pubkeys (two requests per
+	// refresh) inside the public instance's 50 free requests per day.
+	exporterDefaultInterval = time.Hour
+	exporterMinInterval     = time.Minute
+	exporterDefaultSource   = "https://wot.klabo.world"
+	exporterDefaultPort     = "9185"
+)
+
+// exporterSpamClasses are the /spam classifications counted per refresh.
+var exporterSpamClasses = []string{"likely_human", "suspicious", "likely_spam"}
+
+// ExporterSample is the latest known trust data for one watched pubkey.
+type ExporterSample struct {
+	Score           int
+	Followers       int
+	SpamProbability float64
+	Classification  string
+}
+
+// Exporter periodically looks up scores for a fixed list of pubkeys on a WoT
+// scoring instance and serves them as Prometheus gauges, so relay operators can
+// alert on their user base with existing monitoring.
+type Exporter struct {
+	source   string
+	interval time.Duration
+	pubkeys  func() ([]string, error)
+	client   *http.Client
+
+	mu          sync.RWMutex
+	samples     map[string]ExporterSample
+	graphSize   int
+	lastSuccess time.Time
+	lastError   string
+	errors      int
+	refreshes   int
+}
+
+func NewExporter(source string, interval time.Duration, pubkeys func() ([]string, error)) *Exporter {
+	return &Exporter{
+		source:   strings.TrimRight(source, "/"),
+		interval: interval,
+		pubkeys:  pubkeys,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		samples:  make(map[string]ExporterSample),
+	}
+}
+
+// readExporterPubkeys returns the pubkeys in EXPORTER_PUBKEYS (comma-separated) and
+// in the file named by EXPORTER_PUBKEYS_FILE (one per line, # comments), as hex,
+// deduplicated. The file is re-read on every refresh so it can be regenerated from a
+// relay's active users without restarting the exporter.
+func readExporterPubkeys() ([]string, error) {
+	entries := splitCommaList(os.Getenv("EXPORTER_PUBKEYS"))
+	if path := os.Getenv("EXPORTER_PUBKEYS_FILE"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			line, _, _ := strings.Cut(sc.Text(), "#")
+			if line = strings.TrimSpace(line); line != "" {
+				entries = append(entries, line)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]bool)
+	var out []string
+	for _, e := range entries {
+		pk, err := resolvePubkey(e)
+		if err != nil || !hex64Pattern.MatchString(pk) {
+			log.Printf("Exporter: skipping invalid pubkey %q", e)
+			continue
+		}
+		if !seen[pk] {
+			seen[pk] = true
+			out = append(out, pk)
+		}
+	}
+	return out, nil
+}
+
+// post sends {"pubkeys": batch} to path on the source instance and decodes the reply.
+func (e *Exporter) post(ctx context.Context, path string, batch []string, out interface{}) error {
+	return postPubkeys(ctx, e.client, e.source, path, batch, out)
+}
+
+// postPubkeys sends {"pubkeys": batch} to path on a scoring instance and decodes
+// the reply. The exporter and the strfry plugin both look pubkeys up this way.
+func postPubkeys(ctx context.Context, client *http.Client, source, path string, batch []string, out interface{}) error {
+	body, _ := json.Marshal(map[string][]string{"pubkeys": batch})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, source+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPaymentRequired {
+		return fmt.Errorf("%s: payment required (free tier exhausted)", path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP %d", path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Refresh fetches scores and spam classifications for every watched pubkey. On
+// error the previous samples are kept and the failure is counted.
+func (e *Exporter) Refresh(ctx context.Context) error {
+	err := e.refresh(ctx)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.refreshes++
+	if err != nil {
+		e.errors++
+		e.lastError = err.Error()
+		return err
+	}
+	e.lastError = ""
+	e.lastSuccess = time.Now()
+	return nil
+}
+
+func (e *Exporter) refresh(ctx context.Context) error {
+	pubkeys, err := e.pubkeys()
+	if err != nil {
+		return fmt.Errorf("reading pubkeys: %w", err)
+	}
+
+	samples := make(map[string]ExporterSample, len(pubkeys))
+	graphSize := 0
+	for i := 0; i < len(pubkeys); i += exporterBatch {
+		end := i + exporterBatch
+		if end > len(pubkeys) {
+			end = len(pubkeys)
+		}
+		batch := pubkeys[i:end]
+
+		var scores struct {
+			Results []struct {
+				Pubkey    string `json:"pubkey"`
+				Score     int    `json:"score"`
+				Followers int    `json:"followers"`
+				Error     string `json:"error"`
+			} `json:"results"`
+			GraphSize int `json:"graph_size"`
+		}
+		if err := e.post(ctx, "/batch", batch, &scores); err != nil {
+			return err
+		}
+		var spam struct {
+			Results []struct {
+				Pubkey          string  `json:"pubkey"`
+				SpamProbability float64 `json:"spam_probability"`
+				Classification  string  `json:"classification"`
+			} `json:"results"`
+		}
+		if err := e.post(ctx, "/spam/batch", batch, &spam); err != nil {
+			return err
+		}
+
+		graphSize = scores.GraphSize
+		for _, r := range scores.Results {
+			if r.Error != "" {
+				continue
+			}
+			samples[r.Pubkey] = ExporterSample{Score: r.Score, Followers: r.Followers}
+		}
+		for _, r := range spam.Results {
+			s, ok := samples[r.Pubkey]
+			if !ok || r.Classification == "" {
+				continue
+			}
+			s.SpamProbability, s.Classification = r.SpamProbability, r.Classification
+			samples[r.Pubkey] = s
+		}
+	}
+
+	e.mu.Lock()
+	e.samples, e.graphSize = samples, graphSize
+	e.mu.Unlock()
+	return nil
+}
+
+// Run refreshes immediately and then every interval until ctx is done.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		if err := e.Refresh(ctx); err != nil {
+			log.Printf("Exporter refresh failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ServeHTTP writes the gauges in the Prometheus text exposition format.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	pubkeys := make([]string, 0, len(e.samples))
+	classes := make(map[string]int)
+	for pk, s := range e.samples {
+		pubkeys = append(pubkeys, pk)
+		if s.Classification != "" {
+			classes[s.Classification]++
+		}
+	}
+	sort.Strings(pubkeys)
+
+	var b strings.Builder
+	gauge := func(name, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+
+	gauge("wot_score", "Normalized WoT trust score (0-100).")
+	for _, pk := range pubkeys {
+		fmt.Fprintf(&b, "wot_score{pubkey=%q} %d\n", pk, e.samples[pk].Score)
+	}
+	gauge("wot_followers", "Follower count in the WoT graph.")
+	for _, pk := range pubkeys {
+		fmt.Fprintf(&b, "wot_followers{pubkey=%q} %d\n", pk, e.samples[pk].Followers)
+	}
+	gauge("wot_spam_probability", "Spam probability (0-1).")
+	for _, pk := range pubkeys {
+		if s := e.samples[pk]; s.Classification != "" {
+			fmt.Fprintf(&b, "wot_spam_probability{pubkey=%q} %g\n", pk, s.SpamProbability)
+		}
+	}
+	gauge("wot_spam_pubkeys", "Watched pubkeys per spam classification.")
+	for _, c := range exporterSpamClasses {
+		fmt.Fprintf(&b, "wot_spam_pubkeys{classification=%q} %d\n", c, classes[c])
+	}
+
+	gauge("wot_exporter_pubkeys", "Watched pubkeys with a score in the last successful refresh.")
+	fmt.Fprintf(&b, "wot_exporter_pubkeys %d\n", len(e.samples))
+	gauge("wot_exporter_graph_size", "Graph size reported by the scoring instance.")
+	fmt.Fprintf(&b, "wot_exporter_graph_size %d\n", e.graphSize)
+	gauge("wot_exporter_last_success_timestamp_seconds", "Unix time of the last successful refresh (0 if none).")
+	var last int64
+	if !e.lastSuccess.IsZero() {
+		last = e.lastSuccess.Unix()
+	}
+	fmt.Fprintf(&b, "wot_exporter_last_success_timestamp_seconds %d\n", last)
+	fmt.Fprintf(&b, "# HELP wot_exporter_refreshes_total Refresh attempts.\n# TYPE wot_exporter_refreshes_total counter\n")
+	fmt.Fprintf(&b, "wot_exporter_refreshes_total %d\n", e.refreshes)
+	fmt.Fprintf(&b, "# HELP wot_exporter_refresh_errors_total Failed refreshes.\n# TYPE wot_exporter_refresh_errors_total counter\n")
+	fmt.Fprintf(&b, "wot_exporter_refresh_errors_total %d\n", e.errors)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// runExporter is the "exporter" mode entry point: instead of crawling and scoring,
+// it watches EXPORTER_PUBKEYS / EXPORTER_PUBKEYS_FILE on EXPORTER_SOURCE every
+// EXPORTER_INTERVAL and serves /metrics on PORT.
+func runExporter() {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = exporterDefaultPort
+	}
+	source := os.Getenv("EXPORTER_SOURCE")
+	if source == "" {
+		source = exporterDefaultSource
+	}
+	interval := exporterDefaultInterval
+	if v := os.Getenv("EXPORTER_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("Exporter: invalid EXPORTER_INTERVAL %q: %v", v, err)
+		}
+		if d < exporterMinInterval {
+			d = exporterMinInterval
+		}
+		interval = d
+	}
+	pubkeys, err := readExporterPubkeys()
+	if err != nil {
+		log.Fatalf("Exporter: %v", err)
+	}
+	if len(pubkeys) == 0 {
+		log.Fatalf("Exporter: no pubkeys (set EXPORTER_PUBKEYS or EXPORTER_PUBKEYS_FILE)")
+	}
+
+	exp := NewExporter(source, interval, readExporterPubkeys)
+	log.Printf("Exporter watching %d pubkeys on %s every %s", len(pubkeys), source, interval)
+	go exp.Run(context.Background())
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exp)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		exp.mu.RLock()
+		defer exp.mu.RUnlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":     "ok",
+			"pubkeys":    len(exp.samples),
+			"last_error": exp.lastError,
+		})
+	})
+	log.Printf("Exporter listening on :%s", port)
+	log.Fatal(http.ListenAndServe(":"+port, mux))
+}
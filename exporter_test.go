@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exporterSource serves /batch and /spam/batch from the real handlers over a small graph.
+func exporterSource(t *testing.T) *httptest.Server {
+	oldGraph, oldMeta := graph, meta
+	t.Cleanup(func() { graph, meta = oldGraph, oldMeta })
+	graph, meta = NewGraph(), NewMetaStore()
+	for i := 0; i < 10; i++ {
+		graph.AddFollow(padHex(31100+i), padHex(31001))
+	}
+	graph.AddFollow(padHex(31001), padHex(31002))
+	graph.ComputePageRank(20, 0.85)
+	meta.CountFollowers(graph)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/batch", handleBatch)
+	mux.HandleFunc("/spam/batch", handleSpamBatch)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestExporterRefreshAndMetrics(t *testing.T) {
+	srv := exporterSource(t)
+	watched := []string{padHex(31001), padHex(31002)}
+	exp := NewExporter(srv.URL+"/", time.Hour, func() ([]string, error) { return watched, nil })
+
+	if err := exp.Refresh(context.Background()); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	exp.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	for _, want := range []string{
+		"# TYPE wot_score gauge",
+		`wot_followers{pubkey="` + padHex(31001) + `"} 10`,
+		`wot_spam_probability{pubkey="` + padHex(31002) + `"}`,
+		`wot_spam_pubkeys{classification="likely_spam"}`,
+		"wot_exporter_pubkeys 2",
+		"wot_exporter_graph_size 12",
+		"wot_exporter_refreshes_total 1",
+		"wot_exporter_refresh_errors_total 0",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "wot_exporter_last_success_timestamp_seconds 0\n") {
+		t.Error("expected a last success timestamp")
+	}
+	exp.mu.RLock()
+	if exp.samples[padHex(31001)].Score <= exp.samples[padHex(31002)].Score {
+		t.Errorf("expected the followed pubkey to score higher, got %+v", exp.samples)
+	}
+	exp.mu.RUnlock()
+}
+
+func TestExporterKeepsSamplesOnFailure(t *testing.T) {
+	srv := exporterSource(t)
+	exp := NewExporter(srv.URL, time.Hour, func() ([]string, error) { return []string{padHex(31001)}, nil })
+	if err := exp.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	paywall := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPaymentRequired)
+	}))
+	defer paywall.Close()
+	exp.source = paywall.URL
+	err := exp.Refresh(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "payment required") {
+		t.Fatalf("expected payment required error, got %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	exp.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+	if !strings.Contains(body, "wot_exporter_pubkeys 1") || !strings.Contains(body, "wot_exporter_refresh_errors_total 1") {
+		t.Errorf("expected previous samples and one error, got:\n%s", body)
+	}
+}
+
+func TestReadExporterPubkeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pubkeys.txt")
+	content := "# active users\n" + padHex(31001) + "\n\n" + padHex(31002) + "  # bob\nnot-a-pubkey\n" + padHex(31001) + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EXPORTER_PUBKEYS", padHex(31003))
+	t.Setenv("EXPORTER_PUBKEYS_FILE", path)
+
+	got, err := readExporterPubkeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != padHex(31003) || got[1] != padHex(31001) || got[2] != padHex(31002) {
+		t.Errorf("expected env then file pubkeys, deduplicated, got %v", got)
+	}
+
+	t.Setenv("EXPORTER_PUBKEYS_FILE", filepath.Join(t.TempDir(), "missing.txt"))
+	if _, err := readExporterPubkeys(); err == nil {
+		t.Error("expected an error for a missing pubkeys file")
+	}
+}
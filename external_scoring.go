@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -22,17 +23,36 @@ type ExternalMeta struct {
 	Comments   int    // replies to events mentioning this identifier
 	ZapCount   int
 	ZapAmount  int64
-	Authors    map[string]bool // unique authors who mentioned it
+	Authors    DistinctCounter // unique authors who mentioned it
+
+	TrustedMentions  float64 // mentions weighted by each author's WoT score over 100
+	TrustedReactions float64 // reactions weighted by each reactor's WoT score over 100
+
+	activity []externalActivity // dated mentions and reactions within externalActivityWindow
 }
 
+// externalActivity is one dated mention of an identifier, or reaction to a note
+// mentioning it, for /external/trending.
+type externalActivity struct {
+	at       int64
+	author   string
+	weight   float64
+	reaction bool
+}
+
+// externalActivityWindow is how far back the crawl reads notes, and how long dated
+// activity is kept for trending.
+const externalActivityWindow = 30 * 24 * time.Hour
+
 // ExternalStore holds engagement metrics for external identifiers.
 type ExternalStore struct {
 	mu   sync.Mutex
 	data map[string]*ExternalMeta // NIP-73 identifier -> metrics
+	seen map[string]int64         // event id -> created_at, so re-crawls don't count an event twice
 }
 
 func NewExternalStore() *ExternalStore {
-	return &ExternalStore{data: make(map[string]*ExternalMeta)}
+	return &ExternalStore{data: make(map[string]*ExternalMeta), seen: make(map[string]int64)}
 }
 
 func (xs *ExternalStore) Get(identifier string) *ExternalMeta {
@@ -40,10 +60,7 @@ func (xs *ExternalStore) Get(identifier string) *ExternalMeta {
 	defer xs.mu.Unlock()
 	m, ok := xs.data[identifier]
 	if !ok {
-		m = &ExternalMeta{
-			Identifier: identifier,
-			Authors:    make(map[string]bool),
-		}
+		m = &ExternalMeta{Identifier: identifier}
 		xs.data[identifier] = m
 	}
 	return m
@@ -55,7 +72,8 @@ func (xs *ExternalStore) Count() int {
 	return len(xs.data)
 }
 
-// TopExternal returns the top N external identifiers by engagement.
+// TopExternal returns the top N external identifiers by trust-weighted engagement,
+// then raw engagement.
 func (xs *ExternalStore) TopExternal(n int) []*ExternalMeta {
 	xs.mu.Lock()
 	defer xs.mu.Unlock()
@@ -64,15 +82,16 @@ func (xs *ExternalStore) TopExternal(n int) []*ExternalMeta {
 	for _, m := range xs.data {
 		entries = append(entries, m)
 	}
-
-	// Sort by total engagement
-	for i := 0; i < len(entries); i++ {
-		for j := i + 1; j < len(entries); j++ {
-			if externalEngagement(entries[j]) > externalEngagement(entries[i]) {
-				entries[i], entries[j] = entries[j], entries[i]
-			}
+	sort.Slice(entries, func(i, j int) bool {
+		ti, tj := externalTrustEngagement(entries[i]), externalTrustEngagement(entries[j])
+		if ti != tj {
+			return ti > tj
 		}
-	}
+		if ei, ej := externalEngagement(entries[i]), externalEngagement(entries[j]); ei != ej {
+			return ei > ej
+		}
+		return entries[i].Identifier < entries[j].Identifier
+	})
 
 	if n > 0 && n < len(entries) {
 		entries = entries[:n]
@@ -84,12 +103,24 @@ func externalEngagement(m *ExternalMeta) int64 {
 	return int64(m.Mentions) + int64(m.Reactions) + int64(m.Reposts)*2 + int64(m.Comments)*3 + m.ZapAmount
 }
 
-func externalRank(m *ExternalMeta, maxEngagement int64) int {
-	if maxEngagement == 0 {
+// externalTrustEngagement is externalEngagement with mentions and reactions
+// weighted by their authors' WoT scores, so a swarm of unscored accounts adds
+// nothing however much it posts.
+func externalTrustEngagement(m *ExternalMeta) float64 {
+	return m.TrustedMentions + m.TrustedReactions + float64(m.Reposts)*2 + float64(m.Comments)*3 + float64(m.ZapAmount)
+}
+
+// externalRank maps m's trust-weighted engagement to 0-100 on a log scale relative
+// to maxEngagement, the top identifier's.
+func externalRank(m *ExternalMeta, maxEngagement float64) int {
+	return externalRankOf(externalTrustEngagement(m), maxEngagement)
+}
+
+func externalRankOf(eng, maxEngagement float64) int {
+	if maxEngagement <= 0 {
 		return 0
 	}
-	eng := externalEngagement(m)
-	ratio := float64(eng) / float64(maxEngagement)
+	ratio := eng / maxEngagement
 	score := math.Log10(ratio*99+1) * 50
 	if score > 100 {
 		score = 100
@@ -97,14 +128,25 @@ func externalRank(m *ExternalMeta, maxEngagement int64) int {
 	return int(math.Round(score))
 }
 
-// CrawlExternalIdentifiers extracts hashtags and URLs from events by top-scored authors.
+// externalTrustWeight is pubkey's normalized WoT score over 100; 0 outside the graph.
+func externalTrustWeight(pubkey string) float64 {
+	raw, found := graph.GetScore(pubkey)
+	if !found {
+		return 0
+	}
+	return float64(normalizeScore(raw, graph.Stats().Nodes)) / 100.0
+}
+
+// CrawlExternalIdentifiers extracts hashtags and URLs from events by top-scored
+// authors, then counts the reactions to the notes that mention them.
 func (xs *ExternalStore) CrawlExternalIdentifiers(ctx context.Context, authorPubkeys []string) {
 	if len(authorPubkeys) == 0 {
 		return
 	}
+	xs.prune(time.Now().Add(-externalActivityWindow))
 	pool := nostr.NewSimplePool(ctx)
 
-	batchSize := 50
+	batchSize := crawlBatchSize(50, 10)
 	for i := 0; i < len(authorPubkeys); i += batchSize {
 		end := i + batchSize
 		if end > len(authorPubkeys) {
@@ -112,7 +154,7 @@ func (xs *ExternalStore) CrawlExternalIdentifiers(ctx context.Context, authorPub
 		}
 		batch := authorPubkeys[i:end]
 
-		since := nostr.Timestamp(time.Now().Add(-30 * 24 * time.Hour).Unix())
+		since := nostr.Timestamp(time.Now().Add(-externalActivityWindow).Unix())
 		filter := nostr.Filter{
 			Kinds:   []int{1},
 			Authors: batch,
@@ -120,10 +162,14 @@ func (xs *ExternalStore) CrawlExternalIdentifiers(ctx context.Context, authorPub
 			Limit:   len(batch) * 10,
 		}
 
-		evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+		notes := make(map[string][]string) // note id -> identifiers it mentions
+		evCh := pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter})
 		for ev := range evCh {
-			xs.extractIdentifiers(ev.Event)
+			if ids := xs.extractIdentifiers(ev.Event); len(ids) > 0 {
+				notes[ev.Event.ID] = ids
+			}
 		}
+		xs.crawlReactions(ctx, pool, notes, since)
 
 		if (i/batchSize+1)%5 == 0 {
 			log.Printf("External identifier crawl: processed %d/%d authors, %d identifiers tracked",
@@ -134,34 +180,139 @@ func (xs *ExternalStore) CrawlExternalIdentifiers(ctx context.Context, authorPub
 	log.Printf("External identifier crawl complete: %d identifiers", xs.Count())
 }
 
-// extractIdentifiers pulls hashtags from t-tags and URLs from content.
-func (xs *ExternalStore) extractIdentifiers(ev *nostr.Event) {
-	// Extract hashtags from t-tags
+// crawlReactions counts kind 7 reactions to notes, each for every identifier the
+// note mentions.
+func (xs *ExternalStore) crawlReactions(ctx context.Context, pool *nostr.SimplePool, notes map[string][]string, since nostr.Timestamp) {
+	ids := make([]string, 0, len(notes))
+	for id := range notes {
+		ids = append(ids, id)
+	}
+	for i := 0; i < len(ids); i += 100 {
+		chunk := ids[i:min(i+100, len(ids))]
+		filter := nostr.Filter{
+			Kinds: []int{7},
+			Tags:  nostr.TagMap{"e": chunk},
+			Since: &since,
+			Limit: len(chunk) * 20,
+		}
+		for ev := range pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter}) {
+			xs.recordReaction(ev.Event, notes)
+		}
+	}
+}
+
+// markSeen reports whether ev is new, remembering it. Events without an id are
+// always new. Callers hold xs.mu.
+func (xs *ExternalStore) markSeen(ev *nostr.Event) bool {
+	if ev.ID == "" {
+		return true
+	}
+	if _, ok := xs.seen[ev.ID]; ok {
+		return false
+	}
+	xs.seen[ev.ID] = int64(ev.CreatedAt)
+	return true
+}
+
+// record counts one mention or reaction by author for identifier. Callers hold xs.mu.
+func (xs *ExternalStore) record(identifier, kind, author string, at int64, weight float64, reaction bool) {
+	m, ok := xs.data[identifier]
+	if !ok {
+		m = &ExternalMeta{Identifier: identifier}
+		xs.data[identifier] = m
+	}
+	if kind != "" {
+		m.Kind = kind
+	}
+	if reaction {
+		m.Reactions++
+		m.TrustedReactions += weight
+	} else {
+		m.Mentions++
+		m.TrustedMentions += weight
+		m.Authors.Add(author)
+	}
+	m.activity = append(m.activity, externalActivity{at: at, author: author, weight: weight, reaction: reaction})
+}
+
+// extractIdentifiers pulls hashtags from t-tags and URLs from r-tags, weighting
+// each mention by the author's WoT score. It returns the identifiers mentioned,
+// or none if the event was already counted.
+func (xs *ExternalStore) extractIdentifiers(ev *nostr.Event) []string {
+	weight := externalTrustWeight(ev.PubKey)
+	xs.mu.Lock()
+	defer xs.mu.Unlock()
+	if !xs.markSeen(ev) {
+		return nil
+	}
+
+	var ids []string
 	for _, tag := range ev.Tags {
-		if tag[0] == "t" && len(tag) >= 2 {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "t":
 			hashtag := "#" + strings.ToLower(tag[1])
-			m := xs.Get(hashtag)
-			xs.mu.Lock()
-			m.Kind = "hashtag"
-			m.Mentions++
-			m.Authors[ev.PubKey] = true
-			xs.mu.Unlock()
+			xs.record(hashtag, "hashtag", ev.PubKey, int64(ev.CreatedAt), weight, false)
+			ids = append(ids, hashtag)
+		case "r":
+			// URLs from r-tags (reference tags used by some clients)
+			if url := normalizeURL(tag[1]); url != "" {
+				xs.record(url, "url", ev.PubKey, int64(ev.CreatedAt), weight, false)
+				ids = append(ids, url)
+			}
 		}
 	}
+	return ids
+}
 
-	// Extract URLs from r-tags (NIP-58 reference tags used by some clients)
+// recordReaction counts a kind 7 reaction to one of notes for each identifier the
+// note mentions, weighted by the reactor's WoT score. The reacted-to note is the
+// last e tag (NIP-25); downvotes ("-") and reactions to one's own note don't count.
+func (xs *ExternalStore) recordReaction(ev *nostr.Event, notes map[string][]string) {
+	if ev.Content == "-" {
+		return
+	}
+	target := ""
 	for _, tag := range ev.Tags {
-		if tag[0] == "r" && len(tag) >= 2 {
-			url := normalizeURL(tag[1])
-			if url != "" {
-				m := xs.Get(url)
-				xs.mu.Lock()
-				m.Kind = "url"
-				m.Mentions++
-				m.Authors[ev.PubKey] = true
-				xs.mu.Unlock()
+		if len(tag) >= 2 && tag[0] == "e" {
+			target = tag[1]
+		}
+	}
+	ids := notes[target]
+	if len(ids) == 0 {
+		return
+	}
+	weight := externalTrustWeight(ev.PubKey)
+	xs.mu.Lock()
+	defer xs.mu.Unlock()
+	if !xs.markSeen(ev) {
+		return
+	}
+	for _, id := range ids {
+		xs.record(id, "", ev.PubKey, int64(ev.CreatedAt), weight, true)
+	}
+}
+
+// prune drops dated activity and seen event ids older than before. Totals are kept.
+func (xs *ExternalStore) prune(before time.Time) {
+	cutoff := before.Unix()
+	xs.mu.Lock()
+	defer xs.mu.Unlock()
+	for id, at := range xs.seen {
+		if at < cutoff {
+			delete(xs.seen, id)
+		}
+	}
+	for _, m := range xs.data {
+		kept := m.activity[:0]
+		for _, a := range m.activity {
+			if a.at >= cutoff {
+				kept = append(kept, a)
 			}
 		}
+		m.activity = kept
 	}
 }
 
@@ -190,29 +341,29 @@ func normalizeURL(raw string) string {
 	return raw
 }
 
-// publishExternalAssertions publishes kind 30385 events for top external identifiers.
-func publishExternalAssertions(ctx context.Context, xs *ExternalStore, sk, pub string) (int, error) {
+// publishExternalAssertions signs kind 30385 events for top external identifiers
+// and queues them for the relays.
+func publishExternalAssertions(ctx context.Context, xs *ExternalStore, signer EventSigner) (int, error) {
 	topExternal := xs.TopExternal(100)
 	if len(topExternal) == 0 {
 		return 0, nil
 	}
 
-	maxEng := externalEngagement(topExternal[0])
-	pool := nostr.NewSimplePool(ctx)
-	published := 0
+	maxEng := externalTrustEngagement(topExternal[0])
+	batch := make([]nostr.Event, 0, len(topExternal))
 
-	for i, m := range topExternal {
+	for _, m := range topExternal {
 		rank := externalRank(m, maxEng)
 
 		ev := nostr.Event{
-			PubKey:    pub,
+			PubKey:    signer.PublicKey(),
 			CreatedAt: nostr.Now(),
 			Kind:      30385,
 			Tags: nostr.Tags{
 				{"d", m.Identifier},
 				{"rank", fmt.Sprintf("%d", rank)},
 				{"mentions", fmt.Sprintf("%d", m.Mentions)},
-				{"unique_authors", fmt.Sprintf("%d", len(m.Authors))},
+				{"unique_authors", fmt.Sprintf("%d", m.Authors.Count())},
 				{"reactions", fmt.Sprintf("%d", m.Reactions)},
 				{"reposts", fmt.Sprintf("%d", m.Reposts)},
 				{"comments", fmt.Sprintf("%d", m.Comments)},
@@ -221,28 +372,19 @@ func publishExternalAssertions(ctx context.Context, xs *ExternalStore, sk, pub s
 			},
 		}
 
-		if err := ev.Sign(sk); err != nil {
-			log.Printf("Failed to sign kind 30385 for %s: %v", m.Identifier, err)
+		if err := validateAssertionEvent(&ev); err != nil {
+			log.Printf("Skipping kind 30385 for %s: %v", m.Identifier, err)
 			continue
 		}
-
-		ok := false
-		for result := range pool.PublishMany(ctx, relays, ev) {
-			if result.Error == nil {
-				ok = true
-			}
-		}
-		if ok {
-			published++
+		if err := signer.Sign(ctx, &ev); err != nil {
+			log.Printf("Failed to sign kind 30385 for %s: %v", m.Identifier, err)
+			continue
 		}
 
-		time.Sleep(100 * time.Millisecond)
-		if (i+1)%50 == 0 {
-			log.Printf("Published %d/%d kind 30385 events", published, i+1)
-			time.Sleep(2 * time.Second)
-		}
+		batch = append(batch, ev)
 	}
 
-	log.Printf("Published %d kind 30385 (external identifier assertion) events", published)
-	return published, nil
+	queued := publishQueue.Enqueue(batch, config.Relays())
+	log.Printf("Queued %d kind 30385 (external identifier assertion) events", queued)
+	return queued, nil
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	"github.com/nbd-wtf/go-nostr"
 )
@@ -62,32 +63,32 @@ func TestExternalEngagement(t *testing.T) {
 	}{
 		{
 			name: "zero engagement",
-			meta: &ExternalMeta{Authors: make(map[string]bool)},
+			meta: &ExternalMeta{},
 			want: 0,
 		},
 		{
 			name: "mentions only",
-			meta: &ExternalMeta{Mentions: 10, Authors: make(map[string]bool)},
+			meta: &ExternalMeta{Mentions: 10},
 			want: 10,
 		},
 		{
 			name: "reposts weighted 2x",
-			meta: &ExternalMeta{Reposts: 5, Authors: make(map[string]bool)},
+			meta: &ExternalMeta{Reposts: 5},
 			want: 10,
 		},
 		{
 			name: "comments weighted 3x",
-			meta: &ExternalMeta{Comments: 3, Authors: make(map[string]bool)},
+			meta: &ExternalMeta{Comments: 3},
 			want: 9,
 		},
 		{
 			name: "zaps add raw amount",
-			meta: &ExternalMeta{ZapAmount: 1000, Authors: make(map[string]bool)},
+			meta: &ExternalMeta{ZapAmount: 1000},
 			want: 1000,
 		},
 		{
 			name: "combined",
-			meta: &ExternalMeta{Mentions: 10, Reactions: 5, Reposts: 2, Comments: 1, ZapAmount: 100, Authors: make(map[string]bool)},
+			meta: &ExternalMeta{Mentions: 10, Reactions: 5, Reposts: 2, Comments: 1, ZapAmount: 100},
 			want: 10 + 5 + 4 + 3 + 100,
 		},
 	}
@@ -106,31 +107,38 @@ func TestExternalRank(t *testing.T) {
 	tests := []struct {
 		name    string
 		meta    *ExternalMeta
-		maxEng  int64
+		maxEng  float64
 		wantMin int
 		wantMax int
 	}{
 		{
 			name:    "zero max engagement",
-			meta:    &ExternalMeta{Mentions: 10, Authors: make(map[string]bool)},
+			meta:    &ExternalMeta{TrustedMentions: 10},
 			maxEng:  0,
 			wantMin: 0,
 			wantMax: 0,
 		},
 		{
 			name:    "max engagement item",
-			meta:    &ExternalMeta{Mentions: 100, Authors: make(map[string]bool)},
+			meta:    &ExternalMeta{TrustedMentions: 100},
 			maxEng:  100,
 			wantMin: 90,
 			wantMax: 100,
 		},
 		{
 			name:    "zero engagement item",
-			meta:    &ExternalMeta{Authors: make(map[string]bool)},
+			meta:    &ExternalMeta{},
 			maxEng:  100,
 			wantMin: 0,
 			wantMax: 5,
 		},
+		{
+			name:    "untrusted mentions count nothing",
+			meta:    &ExternalMeta{Mentions: 1000},
+			maxEng:  100,
+			wantMin: 0,
+			wantMax: 0,
+		},
 	}
 
 	for _, tt := range tests {
@@ -147,17 +155,21 @@ func TestTopExternal(t *testing.T) {
 	xs := NewExternalStore()
 
 	m1 := xs.Get("#low")
-	m1.Mentions = 1
+	m1.TrustedMentions = 1
 
 	m2 := xs.Get("#mid")
-	m2.Mentions = 10
-	m2.Reactions = 5
+	m2.TrustedMentions = 10
+	m2.TrustedReactions = 5
 
 	m3 := xs.Get("#high")
-	m3.Mentions = 100
-	m3.Reactions = 50
+	m3.TrustedMentions = 100
+	m3.TrustedReactions = 50
 	m3.ZapAmount = 10000
 
+	// a swarm's raw mentions don't outrank trusted ones
+	m4 := xs.Get("#swarm")
+	m4.Mentions = 5000
+
 	top := xs.TopExternal(2)
 	if len(top) != 2 {
 		t.Fatalf("expected 2 entries, got %d", len(top))
@@ -200,7 +212,7 @@ func TestExtractIdentifiers(t *testing.T) {
 	if btc.Kind != "hashtag" {
 		t.Errorf("expected kind 'hashtag', got %q", btc.Kind)
 	}
-	if !btc.Authors["abc123"] {
+	if !btc.Authors.Has("abc123") {
 		t.Error("expected abc123 in authors")
 	}
 
@@ -243,3 +255,85 @@ func TestNormalizeURL(t *testing.T) {
 		})
 	}
 }
+
+// useExternalGraph installs a graph where 46001 is well trusted and 46100-46119
+// are unscored.
+func useExternalGraph(t *testing.T) (trusted string) {
+	oldGraph := graph
+	t.Cleanup(func() { graph = oldGraph })
+	graph = NewGraph()
+	for i := 0; i < 10; i++ {
+		graph.AddFollow(padHex(46010+i), padHex(46001))
+		graph.AddFollow(padHex(46001), padHex(46010+i))
+	}
+	graph.ComputePageRank(20, 0.85)
+	return padHex(46001)
+}
+
+func TestExtractIdentifiersTrustWeighted(t *testing.T) {
+	trusted := useExternalGraph(t)
+	xs := NewExternalStore()
+	now := nostr.Timestamp(time.Now().Unix())
+
+	note := &nostr.Event{ID: "note1", PubKey: trusted, CreatedAt: now, Tags: nostr.Tags{{"t", "nostr"}}}
+	if ids := xs.extractIdentifiers(note); len(ids) != 1 || ids[0] != "#nostr" {
+		t.Fatalf("expected #nostr mentioned, got %v", ids)
+	}
+	if ids := xs.extractIdentifiers(note); ids != nil {
+		t.Errorf("expected a re-crawled note ignored, got %v", ids)
+	}
+	for i := 0; i < 20; i++ {
+		xs.extractIdentifiers(&nostr.Event{ID: padHex(46200 + i), PubKey: padHex(46100 + i), CreatedAt: now, Tags: nostr.Tags{{"t", "spam"}}})
+	}
+
+	m, spam := xs.Get("#nostr"), xs.Get("#spam")
+	if m.Mentions != 1 || m.TrustedMentions <= 0 || m.TrustedMentions > 1 {
+		t.Errorf("expected one mention weighted by the author's score, got %+v", m)
+	}
+	if spam.Mentions != 20 || spam.TrustedMentions != 0 {
+		t.Errorf("expected unscored mentions to carry no weight, got %+v", spam)
+	}
+	if top := xs.TopExternal(1); top[0].Identifier != "#nostr" {
+		t.Errorf("expected the trusted hashtag first, got %s", top[0].Identifier)
+	}
+
+	notes := map[string][]string{"note1": {"#nostr"}}
+	xs.recordReaction(&nostr.Event{ID: "r1", PubKey: padHex(46010), CreatedAt: now, Content: "+", Tags: nostr.Tags{{"e", "note1"}}}, notes)
+	xs.recordReaction(&nostr.Event{ID: "r2", PubKey: padHex(46011), CreatedAt: now, Content: "-", Tags: nostr.Tags{{"e", "note1"}}}, notes)
+	xs.recordReaction(&nostr.Event{ID: "r3", PubKey: padHex(46012), CreatedAt: now, Content: "+", Tags: nostr.Tags{{"e", "other"}}}, notes)
+	if m.Reactions != 1 || m.TrustedReactions <= 0 {
+		t.Errorf("expected one weighted reaction, got %+v", m)
+	}
+}
+
+func TestExternalTrending(t *testing.T) {
+	trusted := useExternalGraph(t)
+	xs := NewExternalStore()
+	now := time.Now()
+	at := func(d time.Duration) nostr.Timestamp { return nostr.Timestamp(now.Add(-d).Unix()) }
+
+	xs.extractIdentifiers(&nostr.Event{ID: "a", PubKey: trusted, CreatedAt: at(time.Hour), Tags: nostr.Tags{{"t", "nostr"}, {"r", "https://example.com/a"}}})
+	xs.extractIdentifiers(&nostr.Event{ID: "b", PubKey: padHex(46010), CreatedAt: at(3 * 24 * time.Hour), Tags: nostr.Tags{{"t", "bitcoin"}}})
+	for i := 0; i < 5; i++ {
+		xs.extractIdentifiers(&nostr.Event{ID: padHex(46300 + i), PubKey: padHex(46100 + i), CreatedAt: at(time.Hour), Tags: nostr.Tags{{"t", "spam"}}})
+	}
+
+	day := xs.Trending(24*time.Hour, "", now, 10)
+	if len(day) != 3 || day[0].Rank != 100 || day[len(day)-1].Identifier != "#spam" {
+		t.Fatalf("expected the swarm's hashtag last despite most mentions, got %+v", day)
+	}
+	spam := day[2]
+	if spam.Mentions != 5 || spam.TrustScore != 0 || spam.Rank != 0 || spam.RawPosition != 1 || spam.UniqueAuthors != 5 || spam.TrustedAuthors != 0 {
+		t.Errorf("unexpected swarm entry %+v", spam)
+	}
+
+	week := xs.Trending(7*24*time.Hour, "hashtag", now, 10)
+	if len(week) != 3 || week[0].Kind != "hashtag" {
+		t.Errorf("expected the hashtags from the last week, got %+v", week)
+	}
+
+	xs.prune(now.Add(-2 * 24 * time.Hour))
+	if week := xs.Trending(7*24*time.Hour, "hashtag", now, 10); len(week) != 2 || xs.Get("#bitcoin").Mentions != 1 {
+		t.Errorf("expected old activity pruned and totals kept, got %+v", week)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// externalTrendingWindows are the windows /external/trending ranks over.
+var externalTrendingWindows = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+// TrendingExternal is one identifier's activity within a trending window.
+type TrendingExternal struct {
+	Identifier     string  `json:"identifier"`
+	Kind           string  `json:"kind"`
+	Rank           int     `json:"rank"`        // 0-100, log scale relative to the top trust score
+	TrustScore     float64 `json:"trust_score"` // mentions and reactions weighted by their authors' WoT scores over 100
+	Mentions       int     `json:"mentions"`
+	Reactions      int     `json:"reactions"`
+	UniqueAuthors  int     `json:"unique_authors"`  // distinct pubkeys that mentioned or reacted
+	TrustedAuthors int     `json:"trusted_authors"` // of those, pubkeys with a WoT score above 0
+	RawPosition    int     `json:"raw_position"`    // position when ranked by raw mention and reaction counts
+}
+
+// Trending ranks identifiers by trust-weighted activity since now-window,
+// optionally only those of kind (hashtag or url), and returns the top limit. Each
+// entry also carries its position in a raw count ranking, so identifiers pushed
+// up by unscored accounts show a large gap.
+func (xs *ExternalStore) Trending(window time.Duration, kind string, now time.Time, limit int) []TrendingExternal {
+	since := now.Add(-window).Unix()
+	var entries []TrendingExternal
+	xs.mu.Lock()
+	for id, m := range xs.data {
+		if kind != "" && m.Kind != kind {
+			continue
+		}
+		e := TrendingExternal{Identifier: id, Kind: m.Kind}
+		authors := make(map[string]bool)
+		trusted := make(map[string]bool)
+		for _, a := range m.activity {
+			if a.at < since {
+				continue
+			}
+			if a.reaction {
+				e.Reactions++
+			} else {
+				e.Mentions++
+			}
+			e.TrustScore += a.weight
+			authors[a.author] = true
+			if a.weight > 0 {
+				trusted[a.author] = true
+			}
+		}
+		if e.Mentions+e.Reactions == 0 {
+			continue
+		}
+		e.UniqueAuthors, e.TrustedAuthors = len(authors), len(trusted)
+		entries = append(entries, e)
+	}
+	xs.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		ci, cj := entries[i].Mentions+entries[i].Reactions, entries[j].Mentions+entries[j].Reactions
+		if ci != cj {
+			return ci > cj
+		}
+		return entries[i].Identifier < entries[j].Identifier
+	})
+	for i := range entries {
+		entries[i].RawPosition = i + 1
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].TrustScore > entries[j].TrustScore })
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	if len(entries) > 0 {
+		top := entries[0].TrustScore
+		for i := range entries {
+			entries[i].Rank = externalRankOf(entries[i].TrustScore, top)
+			entries[i].TrustScore = round3(entries[i].TrustScore)
+		}
+	}
+	return entries
+}
+
+// handleExternalTrending serves GET /external/trending: the hashtags and URLs most
+// mentioned and reacted to in the last 24 hours or 7 days, with every mention and
+// reaction weighted by its author's WoT score.
+func handleExternalTrending(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	window := q.OneOf("window", "24h", "7d")
+	kind := q.OneOf("kind", "hashtag", "url")
+	limit := q.Int("limit", 50, 1, 100)
+	if q.Failed(w) {
+		return
+	}
+	if window == "" {
+		window = "24h"
+	}
+
+	trending := external.Trending(externalTrendingWindows[window], kind, time.Now(), limit)
+	if trending == nil {
+		trending = []TrendingExternal{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"window":   window,
+		"kind":     kind,
+		"trending": trending,
+		"count":    len(trending),
+	})
+}
@@ -48,31 +48,19 @@ type FollowSuggestion struct {
 // handleFollowQuality analyzes the quality of a pubkey's follow list.
 // GET /follow-quality?pubkey=<hex|npub>&suggestions=10
 func handleFollowQuality(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	suggLimit := q.Int("suggestions", 10, 0, 50)
+	if q.Failed(w) {
 		return
 	}
 
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid pubkey: %s"}`, err.Error()), http.StatusBadRequest)
-		return
-	}
-
-	suggLimit := 10
-	if s := r.URL.Query().Get("suggestions"); s != "" {
-		var n int
-		if cnt, _ := fmt.Sscanf(s, "%d", &n); cnt == 1 && n >= 0 && n <= 50 {
-			suggLimit = n
-		}
-	}
-
-	stats := graph.Stats()
-	rawScore, _ := graph.GetScore(pubkey)
+	stats := g.Stats()
+	rawScore, _ := g.GetScore(pubkey)
 	selfScore := normalizeScore(rawScore, stats.Nodes)
 
-	follows := graph.GetFollows(pubkey)
+	follows := g.GetFollows(pubkey)
 	if len(follows) == 0 {
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -90,7 +78,7 @@ func handleFollowQuality(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Build follower set for reciprocity check
-	followers := graph.GetFollowers(pubkey)
+	followers := g.GetFollowers(pubkey)
 	followerSet := make(map[string]bool, len(followers))
 	for _, f := range followers {
 		followerSet[f] = true
@@ -112,7 +100,7 @@ func handleFollowQuality(w http.ResponseWriter, r *http.Request) {
 	var categories FollowCategories
 
 	for i, f := range follows {
-		fRaw, found := graph.GetScore(f)
+		fRaw, found := g.GetScore(f)
 		fScore := normalizeScore(fRaw, stats.Nodes)
 		followsBack := followerSet[f]
 
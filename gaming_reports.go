@@ -0,0 +1,509 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	gamingReportMaxPubkeys     = 20
+	gamingReportMaxEventIDs    = 20
+	gamingReportMaxDescription = 2000
+	gamingReportMaxQueue       = 1000
+	// gamingSweepThreshold is the cumulative reporter weight against a subject
+	// (sum of reporter score/100 over open reports) that triggers an anomaly sweep.
+	gamingSweepThreshold = 1.0
+	gamingSweepCooldown  = 6 * time.Hour
+	// gamingSweepRingLimit caps how many mutual follows are swept alongside a ring suspect.
+	gamingSweepRingLimit = 20
+)
+
+var gamingReportCategories = map[string]bool{
+	"follower_buying": true,
+	"follow_ring":     true,
+	"other":           true,
+}
+
+var hex64Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// GamingReport is a user-submitted report of follower-buying or ring behavior.
+type GamingReport struct {
+	ID            string         `json:"id"`
+	Reporter      string         `json:"reporter"`
+	ReporterScore int            `json:"reporter_score"`
+	Weight        float64        `json:"weight"` // reporter_score / 100
+	Category      string         `json:"category"`
+	Pubkeys       []string       `json:"pubkeys"`
+	EventIDs      []string       `json:"event_ids,omitempty"`
+	Description   string         `json:"description,omitempty"`
+	CreatedAt     int64          `json:"created_at"`
+	Status        string         `json:"status"` // queued, reviewed, dismissed
+	ReviewedAt    int64          `json:"reviewed_at,omitempty"`
+	Sweeps        []*GamingSweep `json:"sweeps,omitempty"`
+}
+
+// GamingSweep is the result of a targeted anomaly sweep around a reported pubkey.
+type GamingSweep struct {
+	Subject string              `json:"subject"`
+	Trigger string              `json:"trigger"` // "auto" or "admin"
+	RanAt   int64               `json:"ran_at"`
+	Swept   int                 `json:"swept"`
+	Flagged int                 `json:"flagged"` // pubkeys with medium or high risk
+	Results []GamingSweepResult `json:"results"`
+}
+
+// GamingSweepResult is the anomaly summary for one swept pubkey.
+type GamingSweepResult struct {
+	Pubkey       string   `json:"pubkey"`
+	Score        int      `json:"score"`
+	RiskLevel    string   `json:"risk_level"`
+	AnomalyCount int      `json:"anomaly_count"`
+	Anomalies    []string `json:"anomalies,omitempty"`
+}
+
+// GamingSubjectWeight is the cumulative report weight against one pubkey.
+type GamingSubjectWeight struct {
+	Pubkey    string  `json:"pubkey"`
+	Weight    float64 `json:"weight"`
+	Reports   int     `json:"reports"`
+	LastSweep int64   `json:"last_sweep,omitempty"`
+}
+
+// GamingReportStore is a bounded queue of gaming reports with per-subject
+// cumulative weights. Only queued and reviewed reports count toward a subject's
+// weight; dismissed reports are kept for the record but carry no weight.
+type GamingReportStore struct {
+	mu        sync.Mutex
+	reports   []*GamingReport // oldest first
+	byID      map[string]*GamingReport
+	lastSweep map[string]int64 // subject -> unix time of last sweep
+}
+
+func NewGamingReportStore() *GamingReportStore {
+	return &GamingReportStore{
+		byID:      make(map[string]*GamingReport),
+		lastSweep: make(map[string]int64),
+	}
+}
+
+// Add queues a report, evicting the oldest when the queue is full. It returns
+// the subjects whose cumulative weight now meets the sweep threshold and that
+// have not been swept within the cooldown; those subjects are marked as swept.
+func (s *GamingReportStore) Add(rep *GamingReport) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reports = append(s.reports, rep)
+	s.byID[rep.ID] = rep
+	for len(s.reports) > gamingReportMaxQueue {
+		delete(s.byID, s.reports[0].ID)
+		s.reports = s.reports[1:]
+	}
+
+	var due []string
+	for _, pk := range rep.Pubkeys {
+		weight, _ := s.subjectWeightLocked(pk)
+		if weight < gamingSweepThreshold {
+			continue
+		}
+		if last := s.lastSweep[pk]; last != 0 && rep.CreatedAt-last < int64(gamingSweepCooldown.Seconds()) {
+			continue
+		}
+		s.lastSweep[pk] = rep.CreatedAt
+		due = append(due, pk)
+	}
+	return due
+}
+
+func (s *GamingReportStore) subjectWeightLocked(pubkey string) (float64, int) {
+	weight, n := 0.0, 0
+	for _, rep := range s.reports {
+		if rep.Status == "dismissed" {
+			continue
+		}
+		for _, pk := range rep.Pubkeys {
+			if pk == pubkey {
+				weight += rep.Weight
+				n++
+				break
+			}
+		}
+	}
+	return weight, n
+}
+
+// SubjectWeight returns the cumulative weight and number of open reports against a pubkey.
+func (s *GamingReportStore) SubjectWeight(pubkey string) (float64, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subjectWeightLocked(pubkey)
+}
+
+// HasOpenReport reports whether reporter already has a non-dismissed report naming pubkey.
+func (s *GamingReportStore) HasOpenReport(reporter, pubkey string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rep := range s.reports {
+		if rep.Reporter != reporter || rep.Status == "dismissed" {
+			continue
+		}
+		for _, pk := range rep.Pubkeys {
+			if pk == pubkey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AttachSweep records a sweep result on a report.
+func (s *GamingReportStore) AttachSweep(id string, sweep *GamingSweep) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rep := s.byID[id]; rep != nil {
+		rep.Sweeps = append(rep.Sweeps, sweep)
+	}
+	s.lastSweep[sweep.Subject] = sweep.RanAt
+}
+
+// SetStatus marks a report reviewed or dismissed. Returns false if the id is unknown.
+func (s *GamingReportStore) SetStatus(id, status string, now int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rep := s.byID[id]
+	if rep == nil {
+		return false
+	}
+	rep.Status = status
+	rep.ReviewedAt = now
+	return true
+}
+
+// Get returns a copy of a report by id.
+func (s *GamingReportStore) Get(id string) (GamingReport, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rep := s.byID[id]
+	if rep == nil {
+		return GamingReport{}, false
+	}
+	return *rep, true
+}
+
+// List returns reports (optionally filtered by status), highest weight first,
+// then newest first.
+func (s *GamingReportStore) List(status string) []GamingReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []GamingReport
+	for _, rep := range s.reports {
+		if status != "" && rep.Status != status {
+			continue
+		}
+		out = append(out, *rep)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Weight != out[j].Weight {
+			return out[i].Weight > out[j].Weight
+		}
+		return out[i].CreatedAt > out[j].CreatedAt
+	})
+	return out
+}
+
+// Subjects returns every reported pubkey with its cumulative weight, highest first.
+func (s *GamingReportStore) Subjects() []GamingSubjectWeight {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]bool)
+	var out []GamingSubjectWeight
+	for _, rep := range s.reports {
+		for _, pk := range rep.Pubkeys {
+			if seen[pk] {
+				continue
+			}
+			seen[pk] = true
+			weight, n := s.subjectWeightLocked(pk)
+			if n == 0 {
+				continue
+			}
+			out = append(out, GamingSubjectWeight{Pubkey: pk, Weight: weight, Reports: n, LastSweep: s.lastSweep[pk]})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Weight != out[j].Weight {
+			return out[i].Weight > out[j].Weight
+		}
+		return out[i].Pubkey < out[j].Pubkey
+	})
+	return out
+}
+
+// Len returns the number of queued reports of any status.
+func (s *GamingReportStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.reports)
+}
+
+// gamingReportID derives a stable report id from the reporter, time and evidence.
+func gamingReportID(rep *GamingReport) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%d|%s", rep.Reporter, rep.CreatedAt, rep.Category)
+	for _, pk := range rep.Pubkeys {
+		h.Write([]byte(pk))
+	}
+	for _, id := range rep.EventIDs {
+		h.Write([]byte(id))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// runGamingSweep runs the anomaly detectors on a reported pubkey. For suspected
+// follow rings the sweep also covers the subject's mutual follows, since ring
+// members inflate each other and are likely to show the same patterns.
+func runGamingSweep(subject, category, trigger string, now time.Time) *GamingSweep {
+	targets := []string{subject}
+	if category == "follow_ring" {
+		followers := make(map[string]bool)
+		for _, f := range graph.GetFollowers(subject) {
+			followers[f] = true
+		}
+		var mutuals []string
+		for _, f := range graph.GetFollows(subject) {
+			if followers[f] {
+				mutuals = append(mutuals, f)
+			}
+		}
+		sort.Strings(mutuals)
+		if len(mutuals) > gamingSweepRingLimit {
+			mutuals = mutuals[:gamingSweepRingLimit]
+		}
+		targets = append(targets, mutuals...)
+	}
+
+	sweep := &GamingSweep{Subject: subject, Trigger: trigger, RanAt: now.Unix()}
+	for _, pk := range targets {
+		a := computeAnomalies(pk)
+		result := GamingSweepResult{
+			Pubkey:       pk,
+			Score:        a.Score,
+			RiskLevel:    a.RiskLevel,
+			AnomalyCount: a.AnomalyCount,
+		}
+		for _, flag := range a.Anomalies {
+			result.Anomalies = append(result.Anomalies, flag.Type)
+		}
+		if a.RiskLevel == "medium" || a.RiskLevel == "high" {
+			sweep.Flagged++
+		}
+		sweep.Results = append(sweep.Results, result)
+	}
+	sweep.Swept = len(sweep.Results)
+	return sweep
+}
+
+// handleReportGaming serves POST /report-gaming. The reporter authenticates with
+// NIP-98 and submits evidence; the report is weighted by the reporter's WoT score.
+func handleReportGaming(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "failed to read body"})
+		return
+	}
+	reporter, err := verifyNIP98(r, body)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Nostr")
+		writeAPIError(w, &APIError{Status: http.StatusUnauthorized, Message: fmt.Sprintf("unauthorized: %s", err.Error())})
+		return
+	}
+
+	var req struct {
+		Category    string   `json:"category"`
+		Pubkeys     []string `json:"pubkeys"`
+		EventIDs    []string `json:"event_ids"`
+		Description string   `json:"description"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "invalid_json", Message: "invalid JSON: " + err.Error()})
+		return
+	}
+	if !gamingReportCategories[req.Category] {
+		writeAPIError(w, invalidParam("category", "category must be follower_buying, follow_ring, or other"))
+		return
+	}
+	if len(req.Pubkeys) == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "pubkeys", Message: "pubkeys array required"})
+		return
+	}
+	if len(req.Pubkeys) > gamingReportMaxPubkeys || len(req.EventIDs) > gamingReportMaxEventIDs {
+		writeAPIError(w, invalidParam("pubkeys", "max %d pubkeys and %d event_ids per report",
+			gamingReportMaxPubkeys, gamingReportMaxEventIDs))
+		return
+	}
+	if len(req.Description) > gamingReportMaxDescription {
+		writeAPIError(w, invalidParam("description", "description exceeds %d bytes", gamingReportMaxDescription))
+		return
+	}
+
+	seen := make(map[string]bool)
+	var pubkeys []string
+	for _, raw := range req.Pubkeys {
+		pk, err := resolvePubkey(raw)
+		if err != nil || !hex64Pattern.MatchString(pk) {
+			writeAPIError(w, invalidParam("pubkeys", "invalid pubkey: %s", raw))
+			return
+		}
+		if pk == reporter {
+			writeAPIError(w, invalidParam("pubkeys", "cannot report yourself"))
+			return
+		}
+		if seen[pk] {
+			continue
+		}
+		seen[pk] = true
+		if gamingReports.HasOpenReport(reporter, pk) {
+			writeAPIError(w, &APIError{Status: http.StatusConflict, Message: fmt.Sprintf("you already have an open report for %s", pk)})
+			return
+		}
+		pubkeys = append(pubkeys, pk)
+	}
+	for _, id := range req.EventIDs {
+		if !hex64Pattern.MatchString(id) {
+			writeAPIError(w, invalidParam("event_ids", "invalid event id: %s", id))
+			return
+		}
+	}
+
+	raw, _ := graph.GetScore(reporter)
+	reporterScore := normalizeScore(raw, graph.Stats().Nodes)
+	now := time.Now()
+	rep := &GamingReport{
+		Reporter:      reporter,
+		ReporterScore: reporterScore,
+		Weight:        float64(reporterScore) / 100,
+		Category:      req.Category,
+		Pubkeys:       pubkeys,
+		EventIDs:      req.EventIDs,
+		Description:   req.Description,
+		CreatedAt:     now.Unix(),
+		Status:        "queued",
+	}
+	rep.ID = gamingReportID(rep)
+
+	due := gamingReports.Add(rep)
+	for _, pk := range due {
+		gamingReports.AttachSweep(rep.ID, runGamingSweep(pk, rep.Category, "auto", now))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":             rep.ID,
+		"status":         rep.Status,
+		"reporter_score": rep.ReporterScore,
+		"weight":         rep.Weight,
+		"pubkeys":        rep.Pubkeys,
+		"sweeps_run":     len(due),
+	})
+}
+
+// handleAdminGamingReports serves GET /admin/gaming-reports?status=&limit= (admin).
+func handleAdminGamingReports(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r) {
+		return
+	}
+	q := bindQuery(r)
+	status := q.OneOf("status", "queued", "reviewed", "dismissed")
+	limit := q.Int("limit", 50, 1, 500)
+	if q.Failed(w) {
+		return
+	}
+
+	reports := gamingReports.List(status)
+	total := len(reports)
+	if len(reports) > limit {
+		reports = reports[:limit]
+	}
+	if reports == nil {
+		reports = []GamingReport{}
+	}
+	subjects := gamingReports.Subjects()
+	if len(subjects) > limit {
+		subjects = subjects[:limit]
+	}
+	if subjects == nil {
+		subjects = []GamingSubjectWeight{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reports":         reports,
+		"count":           len(reports),
+		"total":           total,
+		"subjects":        subjects,
+		"sweep_threshold": gamingSweepThreshold,
+	})
+}
+
+// handleAdminGamingReview serves POST /admin/gaming-reports/review (admin):
+// {"id": "...", "status": "reviewed"|"dismissed", "sweep": true}.
+// Setting sweep re-runs the targeted anomaly sweep for every pubkey in the report.
+func handleAdminGamingReview(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	var req struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+		Sweep  bool   `json:"sweep"`
+	}
+	if apiErr := bindJSON(w, r, &req, 4<<10); apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	if req.Status != "" && req.Status != "reviewed" && req.Status != "dismissed" {
+		writeAPIError(w, invalidParam("status", "status must be reviewed or dismissed"))
+		return
+	}
+	rep, ok := gamingReports.Get(req.ID)
+	if !ok {
+		writeAPIError(w, &APIError{Status: http.StatusNotFound, Message: "report not found"})
+		return
+	}
+
+	now := time.Now()
+	if req.Status != "" {
+		gamingReports.SetStatus(rep.ID, req.Status, now.Unix())
+	}
+	if req.Sweep {
+		for _, pk := range rep.Pubkeys {
+			gamingReports.AttachSweep(rep.ID, runGamingSweep(pk, rep.Category, "admin", now))
+		}
+	}
+
+	if req.Status != "" {
+		curation.Record(r, "gaming_report_"+req.Status, rep.ID, "")
+	}
+	if req.Sweep {
+		curation.Record(r, "gaming_report_sweep", rep.ID, "")
+	}
+
+	rep, _ = gamingReports.Get(rep.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rep)
+}
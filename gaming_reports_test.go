@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func postGamingReport(t *testing.T, sk string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	url := "http://example.com/report-gaming"
+	req := httptest.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+	req.Header.Set("Authorization", nip98Header(t, sk, "POST", url, []byte(body), time.Now()))
+	rr := httptest.NewRecorder()
+	handleReportGaming(rr, req)
+	return rr
+}
+
+func TestGamingReportStoreWeightsAndSweepThreshold(t *testing.T) {
+	s := NewGamingReportStore()
+	subject := padHex(11001)
+
+	due := s.Add(&GamingReport{ID: "a", Reporter: padHex(11002), Weight: 0.6, Pubkeys: []string{subject}, CreatedAt: 1000, Status: "queued"})
+	if len(due) != 0 {
+		t.Fatalf("expected no sweep below threshold, got %v", due)
+	}
+	due = s.Add(&GamingReport{ID: "b", Reporter: padHex(11003), Weight: 0.5, Pubkeys: []string{subject}, CreatedAt: 1010, Status: "queued"})
+	if len(due) != 1 || due[0] != subject {
+		t.Fatalf("expected sweep once weight reaches threshold, got %v", due)
+	}
+	due = s.Add(&GamingReport{ID: "c", Reporter: padHex(11004), Weight: 0.5, Pubkeys: []string{subject}, CreatedAt: 1020, Status: "queued"})
+	if len(due) != 0 {
+		t.Errorf("expected cooldown to suppress repeat sweep, got %v", due)
+	}
+
+	if w, n := s.SubjectWeight(subject); n != 3 || w < 1.59 || w > 1.61 {
+		t.Errorf("expected weight 1.6 over 3 reports, got %v over %d", w, n)
+	}
+	s.SetStatus("a", "dismissed", 2000)
+	if w, n := s.SubjectWeight(subject); n != 2 || w < 0.99 || w > 1.01 {
+		t.Errorf("dismissed reports should carry no weight, got %v over %d", w, n)
+	}
+	if !s.HasOpenReport(padHex(11003), subject) || s.HasOpenReport(padHex(11002), subject) {
+		t.Error("HasOpenReport should ignore dismissed reports")
+	}
+
+	list := s.List("")
+	if len(list) != 3 || list[0].ID != "a" {
+		t.Errorf("expected highest-weight report first, got %+v", list)
+	}
+	if got := s.List("dismissed"); len(got) != 1 {
+		t.Errorf("expected 1 dismissed report, got %d", len(got))
+	}
+}
+
+func TestGamingReportStoreBoundedQueue(t *testing.T) {
+	s := NewGamingReportStore()
+	for i := 0; i < gamingReportMaxQueue+5; i++ {
+		s.Add(&GamingReport{ID: padHex(12000 + i), Pubkeys: []string{padHex(1)}, Status: "queued"})
+	}
+	if s.Len() != gamingReportMaxQueue {
+		t.Fatalf("expected queue capped at %d, got %d", gamingReportMaxQueue, s.Len())
+	}
+	if _, ok := s.Get(padHex(12000)); ok {
+		t.Error("expected oldest report evicted")
+	}
+}
+
+func TestRunGamingSweepCoversRingMembers(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+
+	subject, a, b, outsider := padHex(13001), padHex(13002), padHex(13003), padHex(13004)
+	for _, m := range []string{a, b} {
+		graph.AddFollow(subject, m)
+		graph.AddFollow(m, subject)
+	}
+	graph.AddFollow(outsider, subject)
+	graph.ComputePageRank(20, 0.85)
+
+	sweep := runGamingSweep(subject, "follow_ring", "auto", time.Unix(5000, 0))
+	if sweep.Swept != 3 {
+		t.Fatalf("expected subject + 2 mutual follows swept, got %d", sweep.Swept)
+	}
+	for _, r := range sweep.Results {
+		if r.Pubkey == outsider {
+			t.Error("non-mutual follower should not be swept")
+		}
+		if r.RiskLevel == "" {
+			t.Errorf("expected risk level for %s", r.Pubkey)
+		}
+	}
+
+	if got := runGamingSweep(subject, "follower_buying", "auto", time.Now()); got.Swept != 1 {
+		t.Errorf("expected only the subject swept for follower_buying, got %d", got.Swept)
+	}
+}
+
+func TestReportGamingEndpoint(t *testing.T) {
+	oldGraph, oldReports := graph, gamingReports
+	defer func() { graph, gamingReports = oldGraph, oldReports }()
+	graph = NewGraph()
+	gamingReports = NewGamingReportStore()
+
+	sk := nostr.GeneratePrivateKey()
+	reporter, _ := nostr.GetPublicKey(sk)
+	subject := padHex(14001)
+	graph.AddFollow(padHex(14002), reporter)
+	graph.AddFollow(reporter, subject)
+	graph.ComputePageRank(20, 0.85)
+	graph.scores[reporter] = 1.0
+
+	body := `{"category":"follower_buying","pubkeys":["` + subject + `"],"event_ids":["` + padHex(14003) + `"],"description":"bought 5k followers"}`
+	rr := postGamingReport(t, sk, body)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		ID            string  `json:"id"`
+		ReporterScore int     `json:"reporter_score"`
+		Weight        float64 `json:"weight"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.ID == "" || resp.ReporterScore == 0 || resp.Weight != float64(resp.ReporterScore)/100 {
+		t.Errorf("expected report weighted by reporter score, got %+v", resp)
+	}
+
+	if rr := postGamingReport(t, sk, body); rr.Code != http.StatusConflict {
+		t.Errorf("expected 409 for duplicate open report, got %d", rr.Code)
+	}
+
+	bad := []string{
+		`{"category":"spam","pubkeys":["` + subject + `"]}`,
+		`{"category":"other","pubkeys":[]}`,
+		`{"category":"other","pubkeys":["npub1invalid"]}`,
+		`{"category":"other","pubkeys":["` + reporter + `"]}`,
+		`{"category":"other","pubkeys":["` + padHex(14004) + `"],"event_ids":["xyz"]}`,
+	}
+	for _, b := range bad {
+		if rr := postGamingReport(t, sk, b); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", b, rr.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/report-gaming", bytes.NewBufferString(body))
+	rr = httptest.NewRecorder()
+	handleReportGaming(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without NIP-98, got %d", rr.Code)
+	}
+}
+
+func TestAdminGamingReports(t *testing.T) {
+	oldGraph, oldReports := graph, gamingReports
+	defer func() { graph, gamingReports = oldGraph, oldReports }()
+	graph = NewGraph()
+	gamingReports = NewGamingReportStore()
+
+	subject := padHex(15001)
+	graph.AddFollow(padHex(15002), subject)
+	graph.ComputePageRank(20, 0.85)
+	gamingReports.Add(&GamingReport{ID: "r1", Reporter: padHex(15003), Weight: 0.4, Category: "other", Pubkeys: []string{subject}, CreatedAt: 1, Status: "queued"})
+
+	t.Setenv("ADMIN_TOKEN", "")
+	rr := httptest.NewRecorder()
+	handleAdminGamingReports(rr, httptest.NewRequest("GET", "/admin/gaming-reports", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 when admin disabled, got %d", rr.Code)
+	}
+
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	req := httptest.NewRequest("GET", "/admin/gaming-reports?status=queued", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rr = httptest.NewRecorder()
+	handleAdminGamingReports(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var list struct {
+		Count    int                   `json:"count"`
+		Subjects []GamingSubjectWeight `json:"subjects"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &list)
+	if list.Count != 1 || len(list.Subjects) != 1 || list.Subjects[0].Pubkey != subject {
+		t.Fatalf("unexpected admin listing %+v", list)
+	}
+
+	req = httptest.NewRequest("POST", "/admin/gaming-reports/review", bytes.NewBufferString(`{"id":"r1","status":"reviewed","sweep":true}`))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rr = httptest.NewRecorder()
+	handleAdminGamingReview(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var rep GamingReport
+	json.Unmarshal(rr.Body.Bytes(), &rep)
+	if rep.Status != "reviewed" || len(rep.Sweeps) != 1 || rep.Sweeps[0].Trigger != "admin" {
+		t.Errorf("expected reviewed report with admin sweep, got %+v", rep)
+	}
+
+	req = httptest.NewRequest("POST", "/admin/gaming-reports/review", bytes.NewBufferString(`{"id":"missing","status":"reviewed"}`))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rr = httptest.NewRecorder()
+	handleAdminGamingReview(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown report, got %d", rr.Code)
+	}
+}
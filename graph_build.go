@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// graphBuildExempt lists paths whose responses are not derived from the scored graph:
+// static pages, operational status, and endpoints that do live lookups. They get no
+// X-Graph-Build header and never answer 304.
+var graphBuildExempt = map[string]bool{
+	"/":                 true,
+	"/docs":             true,
+	"/swagger":          true,
+	"/demo":             true,
+	"/openapi.json":     true,
+	"/assertion-schema": true,
+	"/health":           true,
+	"/pricing":          true,
+	"/l402/info":        true,
+	"/zap/request":      true,
+	"/zap/balance":      true,
+	"/account":          true,
+	"/account/topup":    true,
+	"/account/usage":    true,
+	"/rebuild":          true,
+	"/rebuild/status":   true,
+	"/publish/status":   true,
+	"/crawl":            true,
+	"/crawl/status":     true,
+	"/rebuild/cancel":   true,
+	"/ws/scores":        true,
+	"/nip05":            true,
+	"/nip05/batch":      true,
+	"/nip05/reverse":    true,
+	"/domain":           true,
+	"/relay":            true,
+	"/relay/suggest":    true,
+	"/verify":           true,
+	"/report-gaming":    true,
+	"/attestation":      true,
+	"/challenge":        true,
+	"/challenge/verify": true,
+	"/ingest":           true,
+	"/sandbox/score":    true,
+}
+
+// graphBuildExemptPrefixes are exempt path prefixes (admin and analytics views).
+var graphBuildExemptPrefixes = []string{"/admin/", "/analytics/"}
+
+// graphBuildLiveWrites are POST endpoints that change served data between rebuilds.
+// A successful write bumps the build revision so cached responses are invalidated.
+var graphBuildLiveWrites = map[string]bool{
+	"/annotations":  true,
+	"/endorsements": true,
+	"/migrations":   true,
+}
+
+// graphBuildViewerPaths are GET endpoints that read a viewer's imported contact
+// list (see /personalized/import), mapped to the query parameter naming the
+// viewer. Their validators also carry that viewer's import revision, so an import
+// invalidates only that viewer's cached responses.
+var graphBuildViewerPaths = map[string]string{
+	"/personalized": "viewer",
+	"/recommend":    "pubkey",
+}
+
+// GraphBuild identifies the data currently being served. The build ID increases by
+// one each time PageRank installs new scores; the revision counts changes within a
+// build (later rebuild phases and live annotation/endorsement submissions) and resets
+// when the build ID advances. Build 0 means no scores have been computed yet.
+type GraphBuild struct {
+	mu      sync.RWMutex
+	id      uint64
+	rev     uint64
+	builtAt time.Time
+	modAt   time.Time               // last Advance or Touch
+	viewers map[string]viewerChange // viewer -> contact list imports within the build
+}
+
+// viewerChange counts a viewer's contact list imports within a build.
+type viewerChange struct {
+	rev uint64
+	at  time.Time
+}
+
+func NewGraphBuild() *GraphBuild {
+	return &GraphBuild{}
+}
+
+// Advance starts a new build.
+func (b *GraphBuild) Advance(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.id++
+	b.rev = 0
+	b.builtAt = now
+	b.modAt = now
+	b.viewers = nil
+}
+
+// Touch records a change to served data within the current build.
+func (b *GraphBuild) Touch() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rev++
+	b.modAt = time.Now()
+}
+
+// TouchViewer records a change to what one viewer's personalized responses
+// read, leaving everyone else's cached responses valid.
+func (b *GraphBuild) TouchViewer(viewer string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.viewers == nil {
+		b.viewers = make(map[string]viewerChange)
+	}
+	b.viewers[viewer] = viewerChange{rev: b.viewers[viewer].rev + 1, at: time.Now()}
+}
+
+// viewerValidators returns the ETag and Last-Modified for a response personalized
+// to viewer: the build's, extended with the viewer's import revision ("<id>.<rev>.v<n>")
+// once the viewer has imported a contact list in this build.
+func (b *GraphBuild) viewerValidators(viewer string) (string, time.Time) {
+	etag, modified := b.ETag(), b.LastModified()
+	b.mu.RLock()
+	change, ok := b.viewers[viewer]
+	id, rev := b.id, b.rev
+	b.mu.RUnlock()
+	if !ok {
+		return etag, modified
+	}
+	if at := change.at.UTC().Truncate(time.Second); at.After(modified) {
+		modified = at
+	}
+	return fmt.Sprintf(`"%d.%d.v%d"`, id, rev, change.rev), modified
+}
+
+// LastModified returns when served data last changed, to the second as in
+// Last-Modified.
+func (b *GraphBuild) LastModified() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.modAt.UTC().Truncate(time.Second)
+}
+
+// CacheControl returns the Cache-Control value for data responses at now: cacheable
+// until the next scheduled rebuild, then revalidated with the ETag. A rebuild that
+// is overdue gives max-age=0.
+func (b *GraphBuild) CacheControl(now time.Time) string {
+	_, _, builtAt := b.Current()
+	left := rebuildInterval - now.Sub(builtAt)
+	left = max(0, min(left, rebuildInterval))
+	return fmt.Sprintf("max-age=%d, must-revalidate", int(left/time.Second))
+}
+
+// Current returns the build ID, revision, and when the build was installed.
+func (b *GraphBuild) Current() (id, rev uint64, builtAt time.Time) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.id, b.rev, b.builtAt
+}
+
+// ETag returns the strong entity tag for the current data: "<id>" or "<id>.<rev>".
+func (b *GraphBuild) ETag() string {
+	id, rev, _ := b.Current()
+	if rev == 0 {
+		return fmt.Sprintf(`"%d"`, id)
+	}
+	return fmt.Sprintf(`"%d.%d"`, id, rev)
+}
+
+// Header returns the X-Graph-Build value: "<id>; rev=<rev>; built_at=<RFC3339>".
+func (b *GraphBuild) Header() string {
+	id, rev, builtAt := b.Current()
+	if id == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d; rev=%d; built_at=%s", id, rev, builtAt.UTC().Format(time.RFC3339))
+}
+
+// etagMatches reports whether an If-None-Match header matches etag. Weak tags and
+// "*" match too; If-None-Match only needs weak comparison.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func graphBuildExemptPath(path string) bool {
+	if graphBuildExempt[path] {
+		return true
+	}
+	for _, prefix := range graphBuildExemptPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// statusRecorder captures the response status for the live-write check.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// notModifiedSince reports whether an If-Modified-Since header is at or after
+// modified. An unparseable date never matches.
+func notModifiedSince(header string, modified time.Time) bool {
+	since, err := http.ParseTime(header)
+	return err == nil && !modified.After(since)
+}
+
+// GraphBuildMiddleware stamps data responses with X-Graph-Build, an ETag, Last-Modified
+// and a Cache-Control lifetime that runs to the next scheduled rebuild, and answers
+// GET/HEAD requests whose If-None-Match names the current build (or, without
+// If-None-Match, whose If-Modified-Since is not older than the data) with 304 Not
+// Modified, so polling clients can skip unchanged payloads. Nothing is cached before
+// the first build completes.
+func GraphBuildMiddleware(b *GraphBuild, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if graphBuildExemptPath(r.URL.Path) || seedViewRequested(r) {
+			next.ServeHTTP(w, r) // seed views rebuild on their own schedule
+			return
+		}
+
+		if r.Method == http.MethodPost && graphBuildLiveWrites[r.URL.Path] {
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			if rec.status < 300 {
+				b.Touch()
+			}
+			return
+		}
+
+		w.Header().Set("X-Graph-Build", b.Header())
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if id, _, _ := b.Current(); id == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		etag, modified := b.ETag(), b.LastModified()
+		if param, ok := graphBuildViewerPaths[r.URL.Path]; ok {
+			if viewer, err := resolvePubkey(r.URL.Query().Get(param)); err == nil && viewer != "" {
+				etag, modified = b.viewerValidators(viewer)
+			}
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Header().Set("Cache-Control", b.CacheControl(time.Now()))
+		inm := r.Header.Get("If-None-Match")
+		if inm != "" && etagMatches(inm, etag) || inm == "" && notModifiedSince(r.Header.Get("If-Modified-Since"), modified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphBuildETagAndHeader(t *testing.T) {
+	b := NewGraphBuild()
+	if b.Header() != "0" || b.ETag() != `"0"` {
+		t.Fatalf("unexpected initial build %q %q", b.Header(), b.ETag())
+	}
+	b.Advance(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if b.ETag() != `"1"` || b.Header() != "1; rev=0; built_at=2026-01-02T03:04:05Z" {
+		t.Errorf("unexpected build after advance %q %q", b.ETag(), b.Header())
+	}
+	b.Touch()
+	b.Touch()
+	if b.ETag() != `"1.2"` {
+		t.Errorf("expected revision in etag, got %q", b.ETag())
+	}
+	b.Advance(time.Now())
+	if b.ETag() != `"2"` {
+		t.Errorf("advance must reset the revision, got %q", b.ETag())
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	cases := map[string]bool{
+		`"3"`:        true,
+		`W/"3"`:      true,
+		`"2", "3"`:   true,
+		`*`:          true,
+		`"3.1"`:      false,
+		`3`:          false,
+		`"2", W/"4"`: false,
+	}
+	for header, want := range cases {
+		if got := etagMatches(header, `"3"`); got != want {
+			t.Errorf("etagMatches(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestGraphBuildMiddleware(t *testing.T) {
+	b := NewGraphBuild()
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Method == http.MethodPost && r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+	h := GraphBuildMiddleware(b, next)
+
+	get := func(path, inm string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if inm != "" {
+			req.Header.Set("If-None-Match", inm)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// Before the first build nothing is cacheable
+	rr := get("/score?pubkey=x", `"0"`)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != "" || rr.Header().Get("X-Graph-Build") != "0" {
+		t.Fatalf("expected uncached 200 before first build, got %d etag=%q", rr.Code, rr.Header().Get("ETag"))
+	}
+
+	b.Advance(time.Now())
+	rr = get("/score?pubkey=x", "")
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag != `"1"` || !strings.HasPrefix(rr.Header().Get("X-Graph-Build"), "1; rev=0; built_at=") {
+		t.Fatalf("expected build headers, got %d %v", rr.Code, rr.Header())
+	}
+
+	before := calls
+	rr = get("/score?pubkey=x", etag)
+	if rr.Code != http.StatusNotModified || calls != before || rr.Body.Len() != 0 {
+		t.Errorf("expected 304 without calling handler, got %d", rr.Code)
+	}
+
+	// Exempt paths pass through untouched
+	rr = get("/health", etag)
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Graph-Build") != "" {
+		t.Errorf("expected /health exempt, got %d %v", rr.Code, rr.Header())
+	}
+	rr = get("/crawl/status?id=unknown", etag)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != "" {
+		t.Errorf("expected crawl job status exempt, got %d %v", rr.Code, rr.Header())
+	}
+	rr = get("/admin/gaming-reports", etag)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != "" {
+		t.Errorf("expected admin paths exempt, got %d", rr.Code)
+	}
+
+	// A failed live write leaves the build alone; a successful one invalidates caches
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/endorsements?fail=1", nil))
+	if b.ETag() != `"1"` {
+		t.Errorf("failed write must not bump revision, got %s", b.ETag())
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/endorsements", nil))
+	if b.ETag() != `"1.1"` {
+		t.Errorf("expected revision bump after live write, got %s", b.ETag())
+	}
+	rr = get("/score?pubkey=x", etag)
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"1.1"` {
+		t.Errorf("stale etag must get a full response, got %d", rr.Code)
+	}
+}
+
+func TestGraphBuildLastModifiedAndCacheControl(t *testing.T) {
+	b := NewGraphBuild()
+	built := time.Now().Add(-time.Hour)
+	b.Advance(built)
+	if got := b.LastModified(); !got.Equal(built.UTC().Truncate(time.Second)) {
+		t.Errorf("expected Last-Modified at the build, got %v", got)
+	}
+	if got, want := b.CacheControl(built.Add(time.Hour)), fmt.Sprintf("max-age=%d, must-revalidate", int((rebuildInterval-time.Hour)/time.Second)); got != want {
+		t.Errorf("CacheControl = %q, want %q", got, want)
+	}
+	if got := b.CacheControl(built.Add(2 * rebuildInterval)); got != "max-age=0, must-revalidate" {
+		t.Errorf("expected max-age=0 for an overdue rebuild, got %q", got)
+	}
+	b.Touch()
+	if !b.LastModified().After(built) {
+		t.Error("expected Touch to move Last-Modified")
+	}
+}
+
+func TestGraphBuildMiddlewareIfModifiedSince(t *testing.T) {
+	b := NewGraphBuild()
+	b.Advance(time.Now().Add(-time.Minute))
+	h := GraphBuildMiddleware(b, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/score?pubkey=x", nil)
+		req.Header.Set(header, value)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("X-None", "")
+	modified := rr.Header().Get("Last-Modified")
+	if modified == "" || !strings.HasPrefix(rr.Header().Get("Cache-Control"), "max-age=") {
+		t.Fatalf("expected Last-Modified and Cache-Control, got %v", rr.Header())
+	}
+	if rr = get("If-Modified-Since", modified); rr.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for an unchanged If-Modified-Since, got %d", rr.Code)
+	}
+	if rr = get("If-Modified-Since", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)); rr.Code != http.StatusOK {
+		t.Errorf("expected 200 for an older If-Modified-Since, got %d", rr.Code)
+	}
+	if rr = get("If-Modified-Since", "yesterday"); rr.Code != http.StatusOK {
+		t.Errorf("expected 200 for an unparseable If-Modified-Since, got %d", rr.Code)
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since
+	req := httptest.NewRequest("GET", "/score?pubkey=x", nil)
+	req.Header.Set("If-None-Match", `"9"`)
+	req.Header.Set("If-Modified-Since", modified)
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected a stale If-None-Match to win over If-Modified-Since, got %d", rr.Code)
+	}
+}
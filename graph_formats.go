@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// graphExportDefaultNodes is the neighbor limit of a graph file export.
+	graphExportDefaultNodes = 500
+	// graphExportMaxNodes caps the neighbors of a graph file export, so one
+	// download stays a size desktop tools like Gephi and Graphviz open quickly.
+	graphExportMaxNodes = 5000
+)
+
+// graphFormats are the graph file formats the /graph neighborhood can be
+// downloaded in with ?format=, for Gephi, Cytoscape, yEd or Graphviz.
+// NIP19FormatMiddleware lets them through on /graph.
+var graphFormats = map[string]bool{"graphml": true, "gexf": true, "dot": true}
+
+// graphExportNode is a node of a graph file export.
+type graphExportNode struct {
+	Pubkey    string
+	Score     int
+	Followers int
+	Community *int   // nil when the pubkey has no community
+	Relation  string // "ego" for the center, otherwise as in graphNeighbor
+}
+
+// graphExportEdge is a follow between two nodes of a graph file export.
+type graphExportEdge struct {
+	From, To   string
+	Relation   string    // "mutual" or "follows"
+	FollowedAt time.Time // zero when unknown
+}
+
+// graphFileWriter writes a graph file: every node, then every edge, then Close.
+type graphFileWriter interface {
+	Node(n graphExportNode) error
+	Edge(e graphExportEdge) error
+	Close() error
+}
+
+// serveGraphNeighborhood streams the neighborhood of pk in g, up to limit
+// neighbors, as a graph file in format. Nodes carry score, followers,
+// community and their relation to pk; edges are every follow among the nodes,
+// with whether it is mutual and when it was made. The body is gzipped when the
+// client accepts it.
+func serveGraphNeighborhood(w http.ResponseWriter, r *http.Request, g *Graph, pk string, depth, limit int, format string) {
+	stats := g.Stats()
+	exportNode := func(pubkey, relation string) graphExportNode {
+		raw, _ := g.GetScore(pubkey)
+		n := graphExportNode{
+			Pubkey:    pubkey,
+			Score:     normalizeScore(raw, stats.Nodes),
+			Followers: len(g.GetFollowers(pubkey)),
+			Relation:  relation,
+		}
+		if c, ok := communities.GetCommunity(pubkey); ok {
+			n.Community = &c
+		}
+		return n
+	}
+	neighbors := graphNeighbors(g, pk, depth, limit)
+	order := make(map[string]int, len(neighbors)+1)
+	order[pk] = 0
+	for i, n := range neighbors {
+		order[n.Pubkey] = i + 1
+	}
+
+	h := w.Header()
+	switch format {
+	case "graphml":
+		h.Set("Content-Type", "application/graphml+xml")
+	case "gexf":
+		h.Set("Content-Type", "application/gexf+xml")
+	case "dot":
+		h.Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	}
+	h.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="wot-%s.%s"`, shortKey(pk), format))
+	h.Set("X-Node-Count", strconv.Itoa(len(order)))
+	h.Add("Vary", "Accept-Encoding")
+	compress := acceptsGzip(r)
+	if compress {
+		h.Set("Content-Encoding", "gzip")
+	}
+
+	ew := newExportWriter(w, compress)
+	defer ew.Close()
+	var gw graphFileWriter
+	switch format {
+	case "graphml":
+		gw = newGraphMLWriter(ew.buf)
+	case "gexf":
+		gw = newGEXFWriter(ew.buf)
+	default:
+		gw = newDOTWriter(ew.buf)
+	}
+	defer gw.Close()
+
+	if gw.Node(exportNode(pk, "ego")) != nil {
+		return // client went away
+	}
+	ew.entryDone()
+	for _, n := range neighbors {
+		if gw.Node(exportNode(n.Pubkey, n.Relation)) != nil {
+			return
+		}
+		ew.entryDone()
+	}
+
+	// every follow among the nodes, in node order
+	nodes := make([]string, len(order))
+	for pubkey, i := range order {
+		nodes[i] = pubkey
+	}
+	for _, from := range nodes {
+		var targets []string
+		for _, to := range g.GetFollows(from) {
+			if _, ok := order[to]; ok && to != from {
+				targets = append(targets, to)
+			}
+		}
+		sort.Slice(targets, func(i, j int) bool { return order[targets[i]] < order[targets[j]] })
+		for _, to := range targets {
+			e := graphExportEdge{From: from, To: to, Relation: "follows", FollowedAt: g.GetFollowTime(from, to)}
+			if slices.Contains(g.GetFollows(to), from) {
+				e.Relation = "mutual"
+			}
+			if gw.Edge(e) != nil {
+				return
+			}
+			ew.entryDone()
+		}
+	}
+}
+
+// graphMLWriter writes GraphML, which Gephi, Cytoscape and yEd read.
+type graphMLWriter struct {
+	w *bufio.Writer
+}
+
+func newGraphMLWriter(w *bufio.Writer) *graphMLWriter {
+	w.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="score" for="node" attr.name="score" attr.type="int"/>
+  <key id="followers" for="node" attr.name="followers" attr.type="int"/>
+  <key id="community" for="node" attr.name="community" attr.type="int"/>
+  <key id="relation" for="node" attr.name="relation" attr.type="string"/>
+  <key id="edge_relation" for="edge" attr.name="relation" attr.type="string"/>
+  <key id="followed_at" for="edge" attr.name="followed_at" attr.type="string"/>
+  <graph id="wot" edgedefault="directed">
+`)
+	return &graphMLWriter{w: w}
+}
+
+func (gw *graphMLWriter) Node(n graphExportNode) error {
+	fmt.Fprintf(gw.w, `    <node id="%s"><data key="score">%d</data><data key="followers">%d</data>`, n.Pubkey, n.Score, n.Followers)
+	if n.Community != nil {
+		fmt.Fprintf(gw.w, `<data key="community">%d</data>`, *n.Community)
+	}
+	_, err := fmt.Fprintf(gw.w, `<data key="relation">%s</data></node>`+"\n", n.Relation)
+	return err
+}
+
+func (gw *graphMLWriter) Edge(e graphExportEdge) error {
+	fmt.Fprintf(gw.w, `    <edge source="%s" target="%s"><data key="edge_relation">%s</data>`, e.From, e.To, e.Relation)
+	if !e.FollowedAt.IsZero() {
+		fmt.Fprintf(gw.w, `<data key="followed_at">%s</data>`, e.FollowedAt.UTC().Format(time.RFC3339))
+	}
+	_, err := gw.w.WriteString("</edge>\n")
+	return err
+}
+
+func (gw *graphMLWriter) Close() error {
+	_, err := gw.w.WriteString("  </graph>\n</graphml>\n")
+	return err
+}
+
+// gexfWriter writes GEXF 1.3, Gephi's own format. Nodes and edges sit in
+// separate sections, so the first edge closes the nodes.
+type gexfWriter struct {
+	w     *bufio.Writer
+	edges int
+}
+
+func newGEXFWriter(w *bufio.Writer) *gexfWriter {
+	w.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<gexf xmlns="http://gexf.net/1.3" version="1.3">
+  <graph defaultedgetype="directed" mode="static">
+    <attributes class="node">
+      <attribute id="score" title="score" type="integer"/>
+      <attribute id="followers" title="followers" type="integer"/>
+      <attribute id="community" title="community" type="integer"/>
+      <attribute id="relation" title="relation" type="string"/>
+    </attributes>
+    <attributes class="edge">
+      <attribute id="relation" title="relation" type="string"/>
+      <attribute id="followed_at" title="followed_at" type="string"/>
+    </attributes>
+    <nodes>
+`)
+	return &gexfWriter{w: w}
+}
+
+func (gw *gexfWriter) Node(n graphExportNode) error {
+	fmt.Fprintf(gw.w, `      <node id="%s" label="%s"><attvalues><attvalue for="score" value="%d"/><attvalue for="followers" value="%d"/>`,
+		n.Pubkey, shortKey(n.Pubkey), n.Score, n.Followers)
+	if n.Community != nil {
+		fmt.Fprintf(gw.w, `<attvalue for="community" value="%d"/>`, *n.Community)
+	}
+	_, err := fmt.Fprintf(gw.w, `<attvalue for="relation" value="%s"/></attvalues></node>`+"\n", n.Relation)
+	return err
+}
+
+func (gw *gexfWriter) Edge(e graphExportEdge) error {
+	if gw.edges == 0 {
+		gw.w.WriteString("    </nodes>\n    <edges>\n")
+	}
+	fmt.Fprintf(gw.w, `      <edge id="%d" source="%s" target="%s"><attvalues><attvalue for="relation" value="%s"/>`, gw.edges, e.From, e.To, e.Relation)
+	gw.edges++
+	if !e.FollowedAt.IsZero() {
+		fmt.Fprintf(gw.w, `<attvalue for="followed_at" value="%s"/>`, e.FollowedAt.UTC().Format(time.RFC3339))
+	}
+	_, err := gw.w.WriteString("</attvalues></edge>\n")
+	return err
+}
+
+func (gw *gexfWriter) Close() error {
+	if gw.edges == 0 {
+		gw.w.WriteString("    </nodes>\n    <edges>\n")
+	}
+	_, err := gw.w.WriteString("    </edges>\n  </graph>\n</gexf>\n")
+	return err
+}
+
+// dotWriter writes a Graphviz digraph.
+type dotWriter struct {
+	w *bufio.Writer
+}
+
+func newDOTWriter(w *bufio.Writer) *dotWriter {
+	w.WriteString("digraph wot {\n")
+	return &dotWriter{w: w}
+}
+
+func (gw *dotWriter) Node(n graphExportNode) error {
+	fmt.Fprintf(gw.w, `  "%s" [label="%s", score=%d, followers=%d`, n.Pubkey, shortKey(n.Pubkey), n.Score, n.Followers)
+	if n.Community != nil {
+		fmt.Fprintf(gw.w, ", community=%d", *n.Community)
+	}
+	_, err := fmt.Fprintf(gw.w, ", relation=%q];\n", n.Relation)
+	return err
+}
+
+func (gw *dotWriter) Edge(e graphExportEdge) error {
+	fmt.Fprintf(gw.w, `  "%s" -> "%s" [relation=%q`, e.From, e.To, e.Relation)
+	if !e.FollowedAt.IsZero() {
+		fmt.Fprintf(gw.w, ", followed_at=%q", e.FollowedAt.UTC().Format(time.RFC3339))
+	}
+	_, err := gw.w.WriteString("];\n")
+	return err
+}
+
+func (gw *dotWriter) Close() error {
+	_, err := gw.w.WriteString("}\n")
+	return err
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupGraphFormats installs a graph where 51800 and 51801 follow each other,
+// 51800 follows 51802 and 51803..51810 follow 51800.
+func setupGraphFormats(t *testing.T) {
+	old := graph
+	t.Cleanup(func() { graph = old })
+	graph = NewGraph()
+	at := time.Unix(1_800_000_000, 0)
+	graph.SetFollows(padHex(51800), []string{padHex(51801), padHex(51802)}, at)
+	graph.AddFollow(padHex(51801), padHex(51800))
+	for i := 3; i <= 10; i++ {
+		graph.AddFollow(padHex(51800+i), padHex(51800))
+	}
+	graph.ComputePageRank(20, 0.85)
+}
+
+func getGraphFile(query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	NIP19FormatMiddleware(http.HandlerFunc(handleGraph)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/graph?"+query, nil))
+	return w
+}
+
+func TestGraphNeighborhoodGraphML(t *testing.T) {
+	setupGraphFormats(t)
+	w := getGraphFile("pubkey=" + padHex(51800) + "&format=graphml")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/graphml+xml" {
+		t.Fatalf("expected GraphML, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="wot-`+shortKey(padHex(51800))+`.graphml"` {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+	type data struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+	var doc struct {
+		Nodes []struct {
+			ID   string `xml:"id,attr"`
+			Data []data `xml:"data"`
+		} `xml:"graph>node"`
+		Edges []struct {
+			Source string `xml:"source,attr"`
+			Target string `xml:"target,attr"`
+			Data   []data `xml:"data"`
+		} `xml:"graph>edge"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Nodes) != 11 || doc.Nodes[0].ID != padHex(51800) || doc.Nodes[0].Data[len(doc.Nodes[0].Data)-1].Value != "ego" {
+		t.Fatalf("expected the ego and its 10 neighbors, got %+v", doc.Nodes)
+	}
+	// 51800 -> 51801 and back, 51800 -> 51802, and 8 followers
+	if len(doc.Edges) != 11 {
+		t.Fatalf("expected 11 follows among the nodes, got %d", len(doc.Edges))
+	}
+	mutual := doc.Edges[0]
+	if mutual.Source != padHex(51800) || mutual.Target != padHex(51801) || len(mutual.Data) != 2 ||
+		mutual.Data[0].Value != "mutual" || mutual.Data[1].Value != "2027-01-15T08:00:00Z" {
+		t.Errorf("expected the dated mutual follow first, got %+v", mutual)
+	}
+}
+
+func TestGraphNeighborhoodGEXFAndDOT(t *testing.T) {
+	setupGraphFormats(t)
+	w := getGraphFile("pubkey=" + padHex(51800) + "&format=gexf&limit=2")
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Nodes   []struct {
+			ID string `xml:"id,attr"`
+		} `xml:"graph>nodes>node"`
+		Edges []struct {
+			ID     string `xml:"id,attr"`
+			Source string `xml:"source,attr"`
+		} `xml:"graph>edges>edge"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("%v: %s", err, w.Body.String())
+	}
+	if doc.Version != "1.3" || len(doc.Nodes) != 3 || len(doc.Edges) != 3 || w.Header().Get("X-Node-Count") != "3" {
+		t.Errorf("expected the ego, two neighbors and their three follows, got %+v", doc)
+	}
+
+	w = getGraphFile("pubkey=" + padHex(51800) + "&format=dot")
+	body := w.Body.String()
+	if !strings.HasPrefix(body, "digraph wot {\n") || !strings.HasSuffix(body, "}\n") ||
+		!strings.Contains(body, `"`+padHex(51800)+`" -> "`+padHex(51802)+`" [relation="follows", followed_at=`) ||
+		strings.Count(body, " -> ") != 11 {
+		t.Errorf("unexpected DOT %s", body)
+	}
+}
+
+func TestGraphNeighborhoodFormatLimits(t *testing.T) {
+	setupGraphFormats(t)
+	pk := padHex(51800)
+	if w := getGraphFile("pubkey=" + pk + "&format=dot&limit=9000"); w.Code != http.StatusOK || w.Header().Get("X-Node-Count") != "11" {
+		t.Errorf("expected a limit above the cap clamped, got %d", w.Code)
+	}
+	if w := getGraphFile("pubkey=" + pk + "&limit=500"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"neighbors"`) {
+		t.Errorf("expected the JSON neighborhood, got %d", w.Code)
+	}
+	if w := getGraphFile("pubkey=" + pk + "&format=csv"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a non-graph format, got %d", w.Code)
+	}
+}
@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Horizontal scaling: one primary node crawls and scores as usual and, after each
+// rebuild, writes the result to a shared graph store. Any number of replicas run
+// without crawling, load each new snapshot from the store, and serve it, so the
+// HTTP API can sit behind a load balancer. Replicas reject requests that would
+// change data only the primary keeps.
+
+const (
+	// graphStoreKeep is how many snapshots a store keeps, so a replica that is
+	// still loading one doesn't lose it to the next.
+	graphStoreKeep = 3
+	// graphStoreDefaultPoll is how often replicas check for a new snapshot.
+	graphStoreDefaultPoll = 30 * time.Second
+)
+
+// GraphStore holds graph snapshots shared between a primary and its replicas.
+// Snapshots are named by version, "<build>-<built_at unix>": build IDs start
+// over when the primary restarts, and the time keeps them apart.
+type GraphStore interface {
+	// Put stores a snapshot and makes it the latest.
+	Put(version string, data []byte) error
+	// Latest returns the version of the latest snapshot, "" if there is none yet.
+	Latest() (string, error)
+	// Get returns the snapshot for version.
+	Get(version string) ([]byte, error)
+}
+
+func graphSnapshotVersion(build uint64, builtAt time.Time) string {
+	return fmt.Sprintf("%d-%d", build, builtAt.Unix())
+}
+
+// graphSnapshotData is what a primary shares: the graph and its scores, the
+// metadata and HITS scores derived from it, and the build it all belongs to.
+type graphSnapshotData struct {
+	Build       uint64
+	Rev         uint64
+	BuiltAt     time.Time
+	LastBuild   time.Time
+	Follows     map[string][]string
+	Scores      map[string]float64
+	FollowTimes map[string]time.Time
+	ListTimes   map[string]time.Time
+	Updated     map[string]time.Time // scores changed by incremental updates since LastBuild
+	Drift       IncrementalDrift
+	Meta        map[string]PubkeyMeta
+	HITS        map[string]HITSEntry
+}
+
+// encodeGraphSnapshot serializes the live stores as gzipped gob.
+func encodeGraphSnapshot(g *Graph, ms *MetaStore, hits *HITSStore, build *GraphBuild) ([]byte, *graphSnapshotData, error) {
+	data := g.exportData()
+	data.Build, data.Rev, data.BuiltAt = build.Current()
+	data.Meta = ms.exportData()
+	hits.mu.RLock()
+	data.HITS = hits.entries // replaced by Set, never changed in place
+	hits.mu.RUnlock()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := gob.NewEncoder(zw).Encode(data); err != nil {
+		return nil, nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), &data, nil
+}
+
+func decodeGraphSnapshot(raw []byte) (*graphSnapshotData, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	var data graphSnapshotData
+	if err := gob.NewDecoder(zr).Decode(&data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// exportData copies the graph's state for a snapshot.
+func (g *Graph) exportData() graphSnapshotData {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	data := graphSnapshotData{
+		LastBuild:   g.lastBuild,
+		Follows:     make(map[string][]string, len(g.follows)),
+		Scores:      g.scores, // copied before the next incremental update changes it
+		FollowTimes: make(map[string]time.Time, len(g.followTimes)),
+		ListTimes:   make(map[string]time.Time, len(g.listTimes)),
+		Updated:     g.scoreUpdated, // copied on write like scores
+		Drift:       g.drift,
+	}
+	g.scoresShared.Store(true)
+	for k, v := range g.follows {
+		data.Follows[k] = v
+	}
+	for k, v := range g.followTimes {
+		data.FollowTimes[k] = v
+	}
+	for k, v := range g.listTimes {
+		data.ListTimes[k] = v
+	}
+	return data
+}
+
+// replaceData swaps in the state of a loaded snapshot. Readers move to it on
+// their next Snapshot call.
+func (g *Graph) replaceData(data *graphSnapshotData) {
+	followers := make(map[string][]string)
+	for from, tos := range data.Follows {
+		for _, to := range tos {
+			followers[to] = append(followers[to], from)
+		}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.changed()
+	g.follows = data.Follows
+	g.followers = followers
+	g.scores = data.Scores
+	g.followTimes = data.FollowTimes
+	g.listTimes = data.ListTimes
+	g.lastBuild = data.LastBuild
+	g.scoreUpdated = data.Updated
+	g.drift = data.Drift
+	g.buckets = computePercentileBuckets(g.scores)
+	g.dist = computeRawScoreDistribution(g.scores)
+	if g.follows == nil {
+		g.follows = make(map[string][]string)
+	}
+	if g.scores == nil {
+		g.scores = make(map[string]float64)
+	}
+	if g.followTimes == nil {
+		g.followTimes = make(map[string]time.Time)
+	}
+	if g.listTimes == nil {
+		g.listTimes = make(map[string]time.Time)
+	}
+}
+
+// exportData copies every pubkey's metadata.
+func (ms *MetaStore) exportData() map[string]PubkeyMeta {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	out := make(map[string]PubkeyMeta, len(ms.data))
+	for pk, m := range ms.data {
+		c := *m
+		c.NoteTimes = append([]int64(nil), m.NoteTimes...)
+		c.Topics = make(map[string]int, len(m.Topics))
+		for topic, n := range m.Topics {
+			c.Topics[topic] = n
+		}
+		out[pk] = c
+	}
+	return out
+}
+
+func (ms *MetaStore) replaceData(data map[string]PubkeyMeta) {
+	fresh := make(map[string]*PubkeyMeta, len(data))
+	for pk, m := range data {
+		m := m
+		fresh[pk] = &m
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.data = fresh
+}
+
+// Install serves a build made elsewhere, so every replica reports the same
+// build ID and ETags as the primary.
+func (b *GraphBuild) Install(id, rev uint64, builtAt time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.id = id
+	b.rev = rev
+	b.builtAt = builtAt
+	b.modAt = time.Now() // when this node started serving it
+}
+
+// FileGraphStore keeps snapshots in a directory every node can reach, such as a
+// mounted volume or network filesystem: graph-<version>.gob.gz plus a LATEST
+// file holding the latest version.
+type FileGraphStore struct {
+	dir string
+}
+
+func NewFileGraphStore(dir string) (*FileGraphStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileGraphStore{dir: dir}, nil
+}
+
+func (s *FileGraphStore) path(version string) string {
+	return filepath.Join(s.dir, "graph-"+version+".gob.gz")
+}
+
+// writeFileAtomic writes through a temporary file so readers never see part of it.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileGraphStore) Put(version string, data []byte) error {
+	if err := writeFileAtomic(s.path(version), data); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, "LATEST"), []byte(version)); err != nil {
+		return err
+	}
+	matches, _ := filepath.Glob(filepath.Join(s.dir, "graph-*.gob.gz"))
+	sort.Slice(matches, func(i, j int) bool {
+		a, _ := os.Stat(matches[i])
+		b, _ := os.Stat(matches[j])
+		return a != nil && b != nil && a.ModTime().After(b.ModTime())
+	})
+	for i, path := range matches {
+		if i >= graphStoreKeep && path != s.path(version) {
+			os.Remove(path)
+		}
+	}
+	return nil
+}
+
+func (s *FileGraphStore) Latest() (string, error) {
+	raw, err := os.ReadFile(filepath.Join(s.dir, "LATEST"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(raw)), err
+}
+
+func (s *FileGraphStore) Get(version string) ([]byte, error) {
+	return os.ReadFile(s.path(version))
+}
+
+// RedisGraphStore keeps snapshots in Redis as wot:graph:<version>, with
+// wot:graph:latest holding the latest version. Snapshots expire after a day.
+type RedisGraphStore struct {
+	client *redisClient
+}
+
+func NewRedisGraphStore(rawURL string) (*RedisGraphStore, error) {
+	client, err := newRedisClient(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	client.timeout = time.Minute // snapshots of large graphs run to tens of megabytes
+	return &RedisGraphStore{client: client}, nil
+}
+
+func (s *RedisGraphStore) Put(version string, data []byte) error {
+	replies, err := s.client.Do(
+		[]string{"SET", "wot:graph:" + version, string(data), "EX", "86400"},
+		[]string{"SET", "wot:graph:latest", version},
+	)
+	if err != nil {
+		return err
+	}
+	return firstRedisError(replies)
+}
+
+func (s *RedisGraphStore) Latest() (string, error) {
+	replies, err := s.client.Do([]string{"GET", "wot:graph:latest"})
+	if err != nil {
+		return "", err
+	}
+	if err := firstRedisError(replies); err != nil {
+		return "", err
+	}
+	v, _ := replies[0].(string)
+	return v, nil
+}
+
+func (s *RedisGraphStore) Get(version string) ([]byte, error) {
+	replies, err := s.client.Do([]string{"GET", "wot:graph:" + version})
+	if err != nil {
+		return nil, err
+	}
+	if err := firstRedisError(replies); err != nil {
+		return nil, err
+	}
+	v, ok := replies[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("graph snapshot %s has expired", version)
+	}
+	return []byte(v), nil
+}
+
+// NewGraphStore opens the store at rawURL: file:///path or redis[s]://host/db.
+func NewGraphStore(rawURL string) (GraphStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "file":
+		return NewFileGraphStore(u.Path)
+	case "redis", "rediss":
+		return NewRedisGraphStore(rawURL)
+	}
+	return nil, fmt.Errorf("unsupported graph store %q (want file:// or redis://)", rawURL)
+}
+
+// GraphSharing is this node's part in horizontal scaling. Role is "standalone"
+// without a store, else "primary" or "replica".
+type GraphSharing struct {
+	Role  string
+	Store GraphStore
+	Poll  time.Duration
+
+	mu       sync.Mutex
+	backend  string
+	version  string // last snapshot written (primary) or loaded (replica)
+	syncedAt time.Time
+	lastErr  string
+}
+
+// NewGraphSharingFromEnv reads GRAPH_STORE (file:///path or redis://...),
+// GRAPH_ROLE (primary, the default with a store, or replica) and
+// GRAPH_STORE_POLL_SECONDS (30).
+func NewGraphSharingFromEnv() *GraphSharing {
+	gs := &GraphSharing{Role: "standalone", Poll: graphStoreDefaultPoll}
+	rawURL := os.Getenv("GRAPH_STORE")
+	if rawURL == "" {
+		return gs
+	}
+	store, err := NewGraphStore(rawURL)
+	if err != nil {
+		log.Fatalf("Invalid GRAPH_STORE: %v", err)
+	}
+	gs.Store, gs.Role = store, "primary"
+	gs.backend, _, _ = strings.Cut(rawURL, ":")
+	if role := os.Getenv("GRAPH_ROLE"); role == "replica" {
+		gs.Role = role
+	} else if role != "" && role != "primary" {
+		log.Fatalf("Invalid GRAPH_ROLE %q (want primary or replica)", role)
+	}
+	if n, err := strconv.Atoi(os.Getenv("GRAPH_STORE_POLL_SECONDS")); err == nil && n > 0 {
+		gs.Poll = time.Duration(n) * time.Second
+	}
+	return gs
+}
+
+var graphSharing = &GraphSharing{Role: "standalone"}
+
+// Replica reports whether this node serves snapshots instead of building them.
+func (gs *GraphSharing) Replica() bool {
+	return gs.Role == "replica"
+}
+
+func (gs *GraphSharing) record(version string, err error) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	if err != nil {
+		gs.lastErr = err.Error()
+		return
+	}
+	gs.version, gs.syncedAt, gs.lastErr = version, time.Now(), ""
+}
+
+// Share writes the current build to the store. The primary calls it at the end
+// of each rebuild.
+func (gs *GraphSharing) Share() error {
+	raw, data, err := encodeGraphSnapshot(graph, meta, hitsScores, graphBuild)
+	if err != nil {
+		gs.record("", err)
+		return err
+	}
+	version := graphSnapshotVersion(data.Build, data.BuiltAt)
+	err = gs.Store.Put(version, raw)
+	gs.record(version, err)
+	if err == nil {
+		log.Printf("Shared graph snapshot %s (%d KB)", version, len(raw)>>10)
+	}
+	return err
+}
+
+// Sync loads the latest snapshot if it isn't the one being served, reporting
+// whether it did.
+func (gs *GraphSharing) Sync() (bool, error) {
+	version, data, err := gs.fetch()
+	if err != nil {
+		gs.record("", err)
+		return false, err
+	}
+	if data == nil {
+		return false, nil
+	}
+	graph.replaceData(data)
+	meta.replaceData(data.Meta)
+	hitsScores.Set(data.HITS)
+	similarityIndex.Build(graph.Snapshot())
+	brokerScores.Set(ComputeBrokerScores(graph.Snapshot(), brokerSamples, int64(data.Build)))
+	graphBuild.Install(data.Build, data.Rev, data.BuiltAt)
+	gs.record(version, nil)
+	log.Printf("Loaded graph snapshot %s: %d nodes", version, graph.Stats().Nodes)
+	wsHub.BroadcastScoreUpdate()
+	return true, nil
+}
+
+// fetch returns the latest snapshot, or nil if it is already being served or
+// the primary hasn't shared one yet.
+func (gs *GraphSharing) fetch() (string, *graphSnapshotData, error) {
+	latest, err := gs.Store.Latest()
+	if err != nil || latest == "" {
+		return "", nil, err
+	}
+	gs.mu.Lock()
+	current := gs.version
+	gs.mu.Unlock()
+	if latest == current {
+		return "", nil, nil
+	}
+	raw, err := gs.Store.Get(latest)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := decodeGraphSnapshot(raw)
+	return latest, data, err
+}
+
+// Follow keeps a replica on the latest snapshot until ctx ends.
+func (gs *GraphSharing) Follow(ctx context.Context) {
+	ticker := time.NewTicker(gs.Poll)
+	defer ticker.Stop()
+	for {
+		if _, err := gs.Sync(); err != nil {
+			log.Printf("Graph store sync failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status reports the node's role and its last sync for /health and /stats.
+func (gs *GraphSharing) Status() map[string]interface{} {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	status := map[string]interface{}{"role": gs.Role}
+	if gs.Store == nil {
+		return status
+	}
+	status["backend"] = gs.backend
+	if gs.version != "" {
+		status["snapshot"] = gs.version
+	}
+	if !gs.syncedAt.IsZero() {
+		status["synced_at"] = gs.syncedAt.UTC().Format(time.RFC3339)
+	}
+	if gs.lastErr != "" {
+		status["error"] = gs.lastErr
+	}
+	return status
+}
+
+// replicaWritePaths change data only the primary keeps: rebuilds, publishing,
+// live graph updates, and submissions that feed later rebuilds.
+var replicaWritePaths = map[string]bool{
+	"/rebuild":                     true,
+	"/rebuild/cancel":              true,
+	"/publish":                     true,
+	"/hint":                        true,
+	"/crawl":                       true,
+	"/ingest":                      true,
+	"/annotations":                 true,
+	"/endorsements":                true,
+	"/migrations":                  true,
+	"/report-gaming":               true,
+	"/admin/gaming-reports/review": true,
+	"/admin/bans":                  true,
+	"/admin/seeds":                 true,
+	"/admin/recrawl":               true,
+	"/admin/rescore":               true,
+	"/account":                     true,
+	"/account/topup":               true,
+}
+
+// ReplicaMiddleware turns away writes on a replica; the load balancer should
+// send them to the primary.
+func ReplicaMiddleware(gs *GraphSharing, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+		if gs.Replica() && !readOnly && replicaWritePaths[r.URL.Path] {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeAPIError(w, &APIError{Status: http.StatusMethodNotAllowed, Message: "read-only replica: send writes to the primary"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
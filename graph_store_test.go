@@ -0,0 +1,173 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// withGraphStores swaps in empty stores for a test and restores the old ones.
+func withGraphStores(t *testing.T) {
+	oldGraph, oldMeta, oldHITS, oldBuild, oldSimilar, oldBrokers := graph, meta, hitsScores, graphBuild, similarityIndex, brokerScores
+	t.Cleanup(func() {
+		graph, meta, hitsScores, graphBuild, similarityIndex, brokerScores = oldGraph, oldMeta, oldHITS, oldBuild, oldSimilar, oldBrokers
+	})
+	graph, meta, hitsScores, graphBuild = NewGraph(), NewMetaStore(), NewHITSStore(), NewGraphBuild()
+	similarityIndex, brokerScores = NewSimilarityIndex(), NewBrokerStore()
+}
+
+func TestGraphSharingPrimaryToReplica(t *testing.T) {
+	withGraphStores(t)
+	store, err := NewFileGraphStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the primary builds and shares
+	a, b, c := padHex(47001), padHex(47002), padHex(47003)
+	graph.AddFollow(a, b)
+	graph.AddFollow(c, b)
+	graph.SetFollows(a, []string{b, c}, time.Unix(1_700_000_000, 0))
+	graph.ComputePageRank(20, 0.85)
+	graphBuild.Advance(time.Unix(1_700_000_100, 0))
+	graphBuild.Touch()
+	meta.Get(b).PostCount = 12
+	meta.Get(b).Topics = map[string]int{"nostr": 3}
+	hitsScores.Set(ComputeHITS(graph.Snapshot(), 20))
+	wantScore, _ := graph.GetScore(b)
+	wantETag := graphBuild.ETag()
+
+	primary := &GraphSharing{Role: "primary", Store: store}
+	if err := primary.Share(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a replica starts empty and loads it
+	graph, meta, hitsScores, graphBuild = NewGraph(), NewMetaStore(), NewHITSStore(), NewGraphBuild()
+	replica := &GraphSharing{Role: "replica", Store: store}
+	loaded, err := replica.Sync()
+	if err != nil || !loaded {
+		t.Fatalf("expected the snapshot to load, got %v %v", loaded, err)
+	}
+	g := graph.Snapshot()
+	if score, ok := g.GetScore(b); !ok || score != wantScore {
+		t.Errorf("expected score %v, got %v", wantScore, score)
+	}
+	if len(g.GetFollowers(b)) != 2 || len(g.GetFollows(a)) != 2 {
+		t.Errorf("expected followers rebuilt from follows, got %v / %v", g.GetFollowers(b), g.GetFollows(a))
+	}
+	if m := meta.Get(b); m.PostCount != 12 || m.Topics["nostr"] != 3 {
+		t.Errorf("expected metadata copied, got %+v", m)
+	}
+	if _, ok := hitsScores.Get(b); !ok {
+		t.Error("expected HITS scores copied")
+	}
+	if graphBuild.ETag() != wantETag {
+		t.Errorf("expected the primary's ETag %s, got %s", wantETag, graphBuild.ETag())
+	}
+	if status := replica.Status(); status["snapshot"] != "1-1700000100" || status["error"] != nil {
+		t.Errorf("unexpected status %v", status)
+	}
+
+	if loaded, err := replica.Sync(); loaded || err != nil {
+		t.Errorf("expected nothing new to load, got %v %v", loaded, err)
+	}
+
+	// a restarted primary counts builds from 1 again, and is still picked up
+	graphBuild = NewGraphBuild()
+	graphBuild.Advance(time.Unix(1_700_009_000, 0))
+	if err := primary.Share(); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, _ := replica.Sync(); !loaded {
+		t.Error("expected the restarted primary's build 1 to load")
+	}
+}
+
+func TestGraphSharingEmptyStore(t *testing.T) {
+	store, _ := NewFileGraphStore(t.TempDir())
+	replica := &GraphSharing{Role: "replica", Store: store}
+	if loaded, err := replica.Sync(); loaded || err != nil {
+		t.Errorf("expected nothing to load before the primary shares, got %v %v", loaded, err)
+	}
+}
+
+func TestFileGraphStorePrunes(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileGraphStore(dir)
+	for i := 1; i <= graphStoreKeep+2; i++ {
+		version := graphSnapshotVersion(uint64(i), time.Unix(int64(i), 0))
+		if err := store.Put(version, []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+		// mtimes decide what is pruned; make sure they differ
+		os.Chtimes(store.path(version), time.Unix(int64(i), 0), time.Unix(int64(i), 0))
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "graph-*.gob.gz"))
+	if len(matches) != graphStoreKeep {
+		t.Errorf("expected %d snapshots kept, got %v", graphStoreKeep, matches)
+	}
+	latest, _ := store.Latest()
+	if raw, err := store.Get(latest); err != nil || raw[0] != graphStoreKeep+2 {
+		t.Errorf("expected the latest snapshot kept, got %v %v", raw, err)
+	}
+}
+
+func TestRedisGraphStore(t *testing.T) {
+	f, addr := startFakeRedis(t, "")
+	store, err := NewGraphStore("redis://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest, err := store.Latest(); latest != "" || err != nil {
+		t.Fatalf("expected no snapshot yet, got %q %v", latest, err)
+	}
+	if err := store.Put("3-100", []byte("snapshot\r\nbytes")); err != nil {
+		t.Fatal(err)
+	}
+	latest, _ := store.Latest()
+	raw, err := store.Get(latest)
+	if latest != "3-100" || string(raw) != "snapshot\r\nbytes" || err != nil {
+		t.Errorf("expected the snapshot back, got %q %q %v", latest, raw, err)
+	}
+	f.mu.Lock()
+	ttl := f.ttls["wot:graph:3-100"]
+	f.mu.Unlock()
+	if ttl != "86400" {
+		t.Errorf("expected snapshots to expire, got ttl %q", ttl)
+	}
+	if _, err := store.Get("2-50"); err == nil {
+		t.Error("expected an error for a missing snapshot")
+	}
+	if _, err := NewGraphStore("s3://bucket/graph"); err == nil {
+		t.Error("expected an unsupported scheme to fail")
+	}
+}
+
+func TestReplicaMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	do := func(gs *GraphSharing, method, path string) int {
+		rec := httptest.NewRecorder()
+		ReplicaMiddleware(gs, next).ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec.Code
+	}
+	replica := &GraphSharing{Role: "replica"}
+	if code := do(replica, http.MethodPost, "/hint"); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected a replica to reject POST /hint, got %d", code)
+	}
+	if code := do(replica, http.MethodDelete, "/admin/bans"); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected a replica to reject DELETE /admin/bans, got %d", code)
+	}
+	if code := do(replica, http.MethodPost, "/batch"); code != http.StatusOK {
+		t.Errorf("expected a replica to serve POST /batch, got %d", code)
+	}
+	if code := do(replica, http.MethodGet, "/annotations"); code != http.StatusOK {
+		t.Errorf("expected a replica to serve GET /annotations, got %d", code)
+	}
+	if code := do(&GraphSharing{Role: "primary"}, http.MethodPost, "/hint"); code != http.StatusOK {
+		t.Errorf("expected a primary to accept POST /hint, got %d", code)
+	}
+}
@@ -0,0 +1,857 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	gqlMaxDepth    = 8
+	gqlMaxProfiles = 1000 // profiles resolved per request, across all nesting
+	gqlMaxList     = 100  // limit on top, followers and follows
+	gqlMaxPubkeys  = 50   // pubkeys per profiles() call
+)
+
+// graphqlSchema documents what /graphql serves. The executor below implements it
+// by hand: queries with nested selections, aliases, arguments and variables.
+// Fragments, directives, mutations and introspection are not supported.
+const graphqlSchema = `type Query {
+  profile(pubkey: String!): Profile
+  profiles(pubkeys: [String!]!): [Profile!]!
+  top(limit: Int = 10): [Profile!]!
+  stats: Stats!
+}
+
+type Profile {
+  pubkey: String!
+  found: Boolean!
+  score: Int!
+  rawScore: Float!
+  rank: Int!
+  percentile: Float!
+  followersCount: Int!
+  followsCount: Int!
+  followers(limit: Int = 10): [Profile!]!
+  follows(limit: Int = 10): [Profile!]!
+  community: Community
+  anomalies: Anomalies!
+  spam: Spam!
+  activity: Activity!
+  assertions: [Assertion!]!
+}
+
+type Community { id: Int! size: Int! }
+
+type Anomalies {
+  riskLevel: String!
+  count: Int!
+  followBackRatio: Float!
+  ghostFollowers: Int!
+  ghostRatio: Float!
+  topFollowerShare: Float!
+  flags: [AnomalyFlag!]!
+}
+
+type AnomalyFlag { type: String! severity: String! description: String! value: Float! threshold: Float! }
+
+type Spam { probability: Float! classification: String! summary: String! }
+
+type Activity {
+  posts: Int!
+  replies: Int!
+  reactionsSent: Int!
+  reactionsReceived: Int!
+  zapsSent: Int!
+  zapsReceived: Int!
+  zapSatsSent: Int!
+  zapSatsReceived: Int!
+  verifiedZapSatsReceived: Int!
+  reportsReceived: Int!
+  firstSeen: Int!
+  lastSeen: Int!
+}
+
+type Assertion { provider: String! rank: Int! followers: Int! createdAt: Int! }
+
+type Stats { nodes: Int! edges: Int! communities: Int! lastBuild: String! }
+`
+
+// GraphQLRequest is the body for POST /graphql.
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// GraphQLError is one entry of a GraphQL response's errors array.
+type GraphQLError struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// GraphQLResponse is the response for /graphql.
+type GraphQLResponse struct {
+	Data   *gqlObject     `json:"data,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// gqlField is one field of a parsed selection set.
+type gqlField struct {
+	Alias      string
+	Name       string
+	Args       map[string]any // literals, gqlVar references, or []any of either
+	Selections []*gqlField
+}
+
+func (f *gqlField) key() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+type gqlVar string
+
+type gqlOperation struct {
+	Name       string
+	Defaults   map[string]any
+	Selections []*gqlField
+}
+
+// gqlObject is an executed object; it keeps fields in query order, as GraphQL
+// responses must.
+type gqlObject struct {
+	keys   []string
+	values []any
+}
+
+func (o *gqlObject) set(k string, v any) {
+	o.keys = append(o.keys, k)
+	o.values = append(o.values, v)
+}
+
+func (o *gqlObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, _ := json.Marshal(k)
+		buf.Write(kb)
+		buf.WriteByte(':')
+		vb, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// --- parsing ---
+
+type gqlParser struct {
+	src string
+	pos int
+}
+
+func (p *gqlParser) skip() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *gqlParser) peek() byte {
+	p.skip()
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *gqlParser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *gqlParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("syntax error at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func (p *gqlParser) name() (string, error) {
+	p.skip()
+	start := p.pos
+	if p.pos >= len(p.src) || !isNameStart(p.src[p.pos]) {
+		return "", p.errorf("expected name")
+	}
+	for p.pos < len(p.src) && (isNameStart(p.src[p.pos]) || (p.src[p.pos] >= '0' && p.src[p.pos] <= '9')) {
+		p.pos++
+	}
+	return p.src[start:p.pos], nil
+}
+
+// parseGraphQL parses a document of one or more query operations.
+func parseGraphQL(src string) ([]gqlOperation, error) {
+	p := &gqlParser{src: src}
+	var ops []gqlOperation
+	for p.peek() != 0 {
+		op := gqlOperation{Defaults: make(map[string]any)}
+		if p.peek() != '{' {
+			kw, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if kw != "query" {
+				return nil, fmt.Errorf("only query operations are supported, got %q", kw)
+			}
+			if isNameStart(p.peek()) {
+				if op.Name, err = p.name(); err != nil {
+					return nil, err
+				}
+			}
+			if p.peek() == '(' {
+				if err := p.variableDefinitions(op.Defaults); err != nil {
+					return nil, err
+				}
+			}
+		}
+		sel, err := p.selectionSet(1)
+		if err != nil {
+			return nil, err
+		}
+		op.Selections = sel
+		ops = append(ops, op)
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("query contains no operations")
+	}
+	return ops, nil
+}
+
+// variableDefinitions parses ($name: Type = default, ...). Types aren't checked;
+// arguments are validated where they're used.
+func (p *gqlParser) variableDefinitions(defaults map[string]any) error {
+	p.pos++ // (
+	for p.peek() != ')' {
+		if err := p.expect('$'); err != nil {
+			return err
+		}
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(':'); err != nil {
+			return err
+		}
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if p.peek() == '=' {
+			p.pos++
+			v, err := p.value(true)
+			if err != nil {
+				return err
+			}
+			defaults[name] = v
+		}
+	}
+	p.pos++ // )
+	return nil
+}
+
+func (p *gqlParser) typeRef() error {
+	if p.peek() == '[' {
+		p.pos++
+		if err := p.typeRef(); err != nil {
+			return err
+		}
+		if err := p.expect(']'); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.peek() == '!' {
+		p.pos++
+	}
+	return nil
+}
+
+func (p *gqlParser) selectionSet(depth int) ([]*gqlField, error) {
+	if depth > gqlMaxDepth {
+		return nil, fmt.Errorf("query exceeds maximum depth of %d", gqlMaxDepth)
+	}
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	var fields []*gqlField
+	for p.peek() != '}' {
+		if p.peek() == 0 {
+			return nil, p.errorf("unterminated selection set")
+		}
+		if strings.HasPrefix(p.src[p.pos:], "...") {
+			return nil, fmt.Errorf("fragments are not supported")
+		}
+		if p.peek() == '@' {
+			return nil, fmt.Errorf("directives are not supported")
+		}
+		f := &gqlField{}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() == ':' {
+			p.pos++
+			f.Alias = name
+			if name, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		f.Name = name
+		if p.peek() == '(' {
+			p.pos++
+			f.Args = make(map[string]any)
+			for p.peek() != ')' {
+				arg, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(':'); err != nil {
+					return nil, err
+				}
+				if f.Args[arg], err = p.value(false); err != nil {
+					return nil, err
+				}
+			}
+			p.pos++ // )
+		}
+		if p.peek() == '{' {
+			if f.Selections, err = p.selectionSet(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, f)
+	}
+	p.pos++ // }
+	if len(fields) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+	return fields, nil
+}
+
+// value parses an argument value. Object literals aren't needed by the schema.
+func (p *gqlParser) value(constant bool) (any, error) {
+	switch c := p.peek(); {
+	case c == '$':
+		if constant {
+			return nil, p.errorf("variables are not allowed here")
+		}
+		p.pos++
+		name, err := p.name()
+		return gqlVar(name), err
+	case c == '"':
+		return p.stringValue()
+	case c == '[':
+		p.pos++
+		list := []any{}
+		for p.peek() != ']' {
+			if p.peek() == 0 {
+				return nil, p.errorf("unterminated list")
+			}
+			v, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		p.pos++
+		return list, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) >= 0 {
+			p.pos++
+		}
+		lit := p.src[start:p.pos]
+		if n, err := strconv.Atoi(lit); err == nil {
+			return n, nil
+		}
+		f, err := strconv.ParseFloat(lit, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", lit)
+		}
+		return f, nil
+	case isNameStart(c):
+		name, _ := p.name()
+		switch name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return nil, p.errorf("unexpected %q", name)
+	}
+	return nil, p.errorf("expected value")
+}
+
+func (p *gqlParser) stringValue() (string, error) {
+	start := p.pos
+	p.pos++ // opening quote
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '\\':
+			p.pos += 2
+		case '"':
+			p.pos++
+			// GraphQL string escapes are a subset of JSON's
+			var s string
+			if err := json.Unmarshal([]byte(p.src[start:p.pos]), &s); err != nil {
+				return "", p.errorf("invalid string")
+			}
+			return s, nil
+		default:
+			p.pos++
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// --- execution ---
+
+// gqlResolver resolves one field. Object fields return a gqlLazy (or a slice of
+// them) that the executor expands with the field's selection set.
+type gqlResolver func(e *gqlExec, f *gqlField) (any, error)
+
+type gqlLazy struct {
+	typ    string
+	fields map[string]gqlResolver
+}
+
+type gqlExec struct {
+	vars     map[string]any
+	errors   []GraphQLError
+	profiles int
+}
+
+func (e *gqlExec) arg(f *gqlField, name string) (any, bool) {
+	v, ok := f.Args[name]
+	if !ok {
+		return nil, false
+	}
+	v = e.substitute(v)
+	return v, v != nil
+}
+
+func (e *gqlExec) substitute(v any) any {
+	switch t := v.(type) {
+	case gqlVar:
+		return e.vars[string(t)]
+	case []any:
+		out := make([]any, len(t))
+		for i, item := range t {
+			out[i] = e.substitute(item)
+		}
+		return out
+	}
+	return v
+}
+
+func (e *gqlExec) intArg(f *gqlField, name string, def, max int) (int, error) {
+	v, ok := e.arg(f, name)
+	if !ok {
+		return def, nil
+	}
+	var n int
+	switch t := v.(type) {
+	case int:
+		n = t
+	case float64: // JSON variables
+		if t != float64(int(t)) {
+			return 0, fmt.Errorf("argument %q must be an integer", name)
+		}
+		n = int(t)
+	default:
+		return 0, fmt.Errorf("argument %q must be an integer", name)
+	}
+	if n < 1 || n > max {
+		return 0, fmt.Errorf("argument %q must be between 1 and %d", name, max)
+	}
+	return n, nil
+}
+
+func (e *gqlExec) pubkeyArg(v any) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("pubkey must be a string")
+	}
+	pubkey, err := resolvePubkey(s)
+	if err != nil {
+		return "", err
+	}
+	if !hex64Pattern.MatchString(pubkey) {
+		return "", fmt.Errorf("invalid pubkey %q", s)
+	}
+	return pubkey, nil
+}
+
+func (e *gqlExec) fail(path []any, err error) {
+	e.errors = append(e.errors, GraphQLError{Message: err.Error(), Path: append([]any(nil), path...)})
+}
+
+func (e *gqlExec) object(obj gqlLazy, sel []*gqlField, path []any) *gqlObject {
+	out := &gqlObject{}
+	for _, f := range sel {
+		fieldPath := append(path, f.key())
+		if f.Name == "__typename" {
+			out.set(f.key(), obj.typ)
+			continue
+		}
+		resolve, ok := obj.fields[f.Name]
+		if !ok {
+			e.fail(fieldPath, fmt.Errorf("cannot query field %q on type %q", f.Name, obj.typ))
+			out.set(f.key(), nil)
+			continue
+		}
+		v, err := resolve(e, f)
+		if err != nil {
+			e.fail(fieldPath, err)
+			out.set(f.key(), nil)
+			continue
+		}
+		out.set(f.key(), e.complete(v, f, fieldPath))
+	}
+	return out
+}
+
+// complete expands object values with the field's selection set and rejects
+// selections on scalars.
+func (e *gqlExec) complete(v any, f *gqlField, path []any) any {
+	switch t := v.(type) {
+	case *gqlLazy:
+		if t == nil {
+			return nil
+		}
+		if len(f.Selections) == 0 {
+			e.fail(path, fmt.Errorf("field %q of type %q must have a selection of subfields", f.Name, t.typ))
+			return nil
+		}
+		return e.object(*t, f.Selections, path)
+	case []*gqlLazy:
+		out := make([]any, len(t))
+		for i, item := range t {
+			out[i] = e.complete(item, f, append(path, i))
+		}
+		return out
+	}
+	if len(f.Selections) > 0 {
+		e.fail(path, fmt.Errorf("field %q is a scalar and cannot have a selection", f.Name))
+		return nil
+	}
+	return v
+}
+
+// --- schema ---
+
+func gqlQueryType() gqlLazy {
+	return gqlLazy{typ: "Query", fields: map[string]gqlResolver{
+		"profile": func(e *gqlExec, f *gqlField) (any, error) {
+			v, ok := e.arg(f, "pubkey")
+			if !ok {
+				return nil, fmt.Errorf("argument \"pubkey\" is required")
+			}
+			pubkey, err := e.pubkeyArg(v)
+			if err != nil {
+				return nil, err
+			}
+			return e.profile(pubkey)
+		},
+		"profiles": func(e *gqlExec, f *gqlField) (any, error) {
+			v, _ := e.arg(f, "pubkeys")
+			list, ok := v.([]any)
+			if !ok || len(list) == 0 {
+				return nil, fmt.Errorf("argument \"pubkeys\" must be a non-empty list")
+			}
+			if len(list) > gqlMaxPubkeys {
+				return nil, fmt.Errorf("maximum %d pubkeys per profiles query", gqlMaxPubkeys)
+			}
+			pubkeys := make([]string, len(list))
+			for i, item := range list {
+				pubkey, err := e.pubkeyArg(item)
+				if err != nil {
+					return nil, err
+				}
+				pubkeys[i] = pubkey
+			}
+			return e.profileList(pubkeys)
+		},
+		"top": func(e *gqlExec, f *gqlField) (any, error) {
+			limit, err := e.intArg(f, "limit", 10, gqlMaxList)
+			if err != nil {
+				return nil, err
+			}
+			entries := graph.TopN(limit)
+			pubkeys := make([]string, len(entries))
+			for i, entry := range entries {
+				pubkeys[i] = entry.Pubkey
+			}
+			return e.profileList(pubkeys)
+		},
+		"stats": func(e *gqlExec, f *gqlField) (any, error) {
+			stats := graph.Stats()
+			return &gqlLazy{typ: "Stats", fields: map[string]gqlResolver{
+				"nodes":       gqlValue(stats.Nodes),
+				"edges":       gqlValue(stats.Edges),
+				"communities": func(*gqlExec, *gqlField) (any, error) { return communities.TotalCommunities(), nil },
+				"lastBuild":   gqlValue(stats.LastBuild.UTC().Format(time.RFC3339)),
+			}}, nil
+		},
+	}}
+}
+
+func gqlValue(v any) gqlResolver {
+	return func(*gqlExec, *gqlField) (any, error) { return v, nil }
+}
+
+func (e *gqlExec) profileList(pubkeys []string) ([]*gqlLazy, error) {
+	out := make([]*gqlLazy, len(pubkeys))
+	for i, pk := range pubkeys {
+		p, err := e.profile(pk)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = p
+	}
+	return out, nil
+}
+
+// profile returns a Profile whose fields are computed only when selected, so a
+// query for scores never pays for anomaly detection.
+func (e *gqlExec) profile(pubkey string) (*gqlLazy, error) {
+	e.profiles++
+	if e.profiles > gqlMaxProfiles {
+		return nil, fmt.Errorf("query resolves more than %d profiles", gqlMaxProfiles)
+	}
+	raw, found := graph.GetScore(pubkey)
+	return &gqlLazy{typ: "Profile", fields: map[string]gqlResolver{
+		"pubkey":   gqlValue(pubkey),
+		"found":    gqlValue(found),
+		"rawScore": gqlValue(raw),
+		"score": func(*gqlExec, *gqlField) (any, error) {
+			return normalizeScore(raw, graph.Stats().Nodes), nil
+		},
+		"rank":           func(*gqlExec, *gqlField) (any, error) { return graph.Rank(pubkey), nil },
+		"percentile":     func(*gqlExec, *gqlField) (any, error) { return graph.Percentile(pubkey), nil },
+		"followersCount": func(*gqlExec, *gqlField) (any, error) { return len(graph.GetFollowers(pubkey)), nil },
+		"followsCount":   func(*gqlExec, *gqlField) (any, error) { return len(graph.GetFollows(pubkey)), nil },
+		"followers": func(e *gqlExec, f *gqlField) (any, error) {
+			return e.topOf(f, graph.GetFollowers(pubkey))
+		},
+		"follows": func(e *gqlExec, f *gqlField) (any, error) {
+			return e.topOf(f, graph.GetFollows(pubkey))
+		},
+		"community": func(*gqlExec, *gqlField) (any, error) {
+			id, ok := communities.GetCommunity(pubkey)
+			if !ok {
+				return (*gqlLazy)(nil), nil
+			}
+			return &gqlLazy{typ: "Community", fields: map[string]gqlResolver{
+				"id": gqlValue(id),
+				"size": func(*gqlExec, *gqlField) (any, error) {
+					return len(communities.GetCommunityMembers(pubkey)), nil
+				},
+			}}, nil
+		},
+		"anomalies": func(*gqlExec, *gqlField) (any, error) {
+			a := computeAnomalies(pubkey)
+			flags := make([]*gqlLazy, len(a.Anomalies))
+			for i, flag := range a.Anomalies {
+				flags[i] = &gqlLazy{typ: "AnomalyFlag", fields: map[string]gqlResolver{
+					"type":        gqlValue(flag.Type),
+					"severity":    gqlValue(flag.Severity),
+					"description": gqlValue(flag.Description),
+					"value":       gqlValue(flag.Value),
+					"threshold":   gqlValue(flag.Threshold),
+				}}
+			}
+			return &gqlLazy{typ: "Anomalies", fields: map[string]gqlResolver{
+				"riskLevel":        gqlValue(a.RiskLevel),
+				"count":            gqlValue(a.AnomalyCount),
+				"followBackRatio":  gqlValue(a.FollowBackRatio),
+				"ghostFollowers":   gqlValue(a.GhostFollowers),
+				"ghostRatio":       gqlValue(a.GhostRatio),
+				"topFollowerShare": gqlValue(a.TopFollowerShare),
+				"flags":            gqlValue(flags),
+			}}, nil
+		},
+		"spam": func(*gqlExec, *gqlField) (any, error) {
+			s := computeSpam(pubkey, graph.Stats().Nodes)
+			return &gqlLazy{typ: "Spam", fields: map[string]gqlResolver{
+				"probability":    gqlValue(s.SpamProbability),
+				"classification": gqlValue(s.Classification),
+				"summary":        gqlValue(s.Summary),
+			}}, nil
+		},
+		"activity": func(*gqlExec, *gqlField) (any, error) {
+			m := meta.Get(pubkey)
+			return &gqlLazy{typ: "Activity", fields: map[string]gqlResolver{
+				"posts":             gqlValue(m.PostCount),
+				"replies":           gqlValue(m.ReplyCount),
+				"reactionsSent":     gqlValue(m.ReactionsSent),
+				"reactionsReceived": gqlValue(m.ReactionsRecd),
+				"zapsSent":          gqlValue(m.ZapCntSent),
+				"zapsReceived":      gqlValue(m.ZapCntRecd),
+				"zapSatsSent":       gqlValue(m.ZapAmtSent),
+				"zapSatsReceived":   gqlValue(m.ZapAmtRecd),
+				"verifiedZapSatsReceived": gqlValue(m.VerifiedZapAmtRecd),
+				"reportsReceived":   gqlValue(m.ReportsRecd),
+				"firstSeen":         gqlValue(m.FirstCreated),
+				"lastSeen":          gqlValue(m.LastCreated),
+			}}, nil
+		},
+		"assertions": func(*gqlExec, *gqlField) (any, error) {
+			list := externalAssertions.GetForSubject(pubkey)
+			out := make([]*gqlLazy, len(list))
+			for i, a := range list {
+				out[i] = &gqlLazy{typ: "Assertion", fields: map[string]gqlResolver{
+					"provider":  gqlValue(a.ProviderPubkey),
+					"rank":      gqlValue(a.Rank),
+					"followers": gqlValue(a.Followers),
+					"createdAt": gqlValue(a.CreatedAt),
+				}}
+			}
+			return out, nil
+		},
+	}}, nil
+}
+
+// topOf returns the highest-scored of pubkeys as Profiles, honoring the field's
+// limit argument.
+func (e *gqlExec) topOf(f *gqlField, pubkeys []string) ([]*gqlLazy, error) {
+	limit, err := e.intArg(f, "limit", 10, gqlMaxList)
+	if err != nil {
+		return nil, err
+	}
+	type scored struct {
+		pubkey string
+		score  float64
+	}
+	ranked := make([]scored, len(pubkeys))
+	for i, pk := range pubkeys {
+		s, _ := graph.GetScore(pk)
+		ranked[i] = scored{pk, s}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].pubkey < ranked[j].pubkey
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	top := make([]string, len(ranked))
+	for i, r := range ranked {
+		top[i] = r.pubkey
+	}
+	return e.profileList(top)
+}
+
+// executeGraphQL parses and runs a query against the live stores.
+func executeGraphQL(req GraphQLRequest) GraphQLResponse {
+	ops, err := parseGraphQL(req.Query)
+	if err != nil {
+		return GraphQLResponse{Errors: []GraphQLError{{Message: err.Error()}}}
+	}
+	var op *gqlOperation
+	for i := range ops {
+		if req.OperationName == "" || ops[i].Name == req.OperationName {
+			op = &ops[i]
+			break
+		}
+	}
+	switch {
+	case op == nil:
+		return GraphQLResponse{Errors: []GraphQLError{{Message: fmt.Sprintf("unknown operation %q", req.OperationName)}}}
+	case req.OperationName == "" && len(ops) > 1:
+		return GraphQLResponse{Errors: []GraphQLError{{Message: "operationName required for documents with multiple operations"}}}
+	}
+
+	e := &gqlExec{vars: op.Defaults}
+	for k, v := range req.Variables {
+		e.vars[k] = v
+	}
+	data := e.object(gqlQueryType(), op.Selections, nil)
+	return GraphQLResponse{Data: data, Errors: e.errors}
+}
+
+// handleGraphQL serves /graphql. POST takes {"query", "variables", "operationName"};
+// GET takes the same as query parameters, and with no query returns the schema.
+// Field errors are reported in the errors array alongside partial data, so the
+// status is 200 unless the request itself is malformed.
+func handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	switch r.Method {
+	case http.MethodPost:
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+			http.Error(w, `{"errors":[{"message":"invalid JSON body"}]}`, http.StatusBadRequest)
+			return
+		}
+	case http.MethodGet:
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				http.Error(w, `{"errors":[{"message":"variables must be a JSON object"}]}`, http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Query == "" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"schema": graphqlSchema})
+			return
+		}
+	default:
+		http.Error(w, `{"errors":[{"message":"GET or POST required"}]}`, http.StatusMethodNotAllowed)
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		http.Error(w, `{"errors":[{"message":"query required"}]}`, http.StatusBadRequest)
+		return
+	}
+
+	resp := executeGraphQL(req)
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Data == nil {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postGraphQL(t *testing.T, body string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handleGraphQL(rr, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewBufferString(body)))
+	var resp map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", rr.Body.String(), err)
+	}
+	return rr, resp
+}
+
+func TestGraphQLProfileQuery(t *testing.T) {
+	oldGraph, oldMeta, oldComm, oldAssertions := graph, meta, communities, externalAssertions
+	defer func() { graph, meta, communities, externalAssertions = oldGraph, oldMeta, oldComm, oldAssertions }()
+	graph, meta, communities, externalAssertions = NewGraph(), NewMetaStore(), NewCommunityDetector(), NewAssertionStore()
+
+	alice, bob, carol, dave := padHex(36001), padHex(36002), padHex(36003), padHex(36004)
+	graph.AddFollow(bob, alice)
+	graph.AddFollow(carol, alice)
+	graph.AddFollow(dave, alice)
+	graph.AddFollow(dave, bob)
+	graph.AddFollow(alice, bob)
+	graph.ComputePageRank(20, 0.85)
+	communities.DetectCommunities(graph, 10)
+	meta.Get(alice).PostCount = 7
+	externalAssertions.Add(&ExternalAssertion{ProviderPubkey: padHex(36100), SubjectPubkey: alice, Rank: 42, CreatedAt: 1})
+
+	query := `query Profile($pk: String!, $n: Int = 2) {
+		profile(pubkey: $pk) {
+			pubkey
+			score
+			trust: rawScore
+			followersCount
+			followers(limit: $n) { pubkey followsCount }
+			community { id size }
+			anomalies { riskLevel flags { type } }
+			activity { posts }
+			assertions { provider rank }
+			__typename
+		}
+		stats { nodes edges }
+	}`
+	body, _ := json.Marshal(GraphQLRequest{Query: query, Variables: map[string]any{"pk": alice}})
+	rr, resp := postGraphQL(t, string(body))
+	if rr.Code != http.StatusOK || resp["errors"] != nil {
+		t.Fatalf("expected clean 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	// fields come back in query order, with aliases applied
+	if !strings.Contains(rr.Body.String(), `{"profile":{"pubkey":"`+alice+`","score":`) || !strings.Contains(rr.Body.String(), `"trust":`) {
+		t.Errorf("expected ordered, aliased fields, got %s", rr.Body.String())
+	}
+
+	profile := resp["data"].(map[string]any)["profile"].(map[string]any)
+	if profile["followersCount"] != float64(3) || profile["__typename"] != "Profile" {
+		t.Errorf("unexpected profile %v", profile)
+	}
+	followers := profile["followers"].([]any)
+	if len(followers) != 2 {
+		t.Fatalf("expected the limit variable default of 2, got %d followers", len(followers))
+	}
+	// bob is followed by dave and alice, so ranks above the other followers
+	if first := followers[0].(map[string]any); first["pubkey"] != bob || first["followsCount"] != float64(1) {
+		t.Errorf("expected bob as the top follower, got %v", first)
+	}
+	if profile["community"] == nil || profile["community"].(map[string]any)["size"].(float64) < 1 {
+		t.Errorf("expected a community, got %v", profile["community"])
+	}
+	if profile["activity"].(map[string]any)["posts"] != float64(7) {
+		t.Errorf("expected activity from the metadata store, got %v", profile["activity"])
+	}
+	if a := profile["assertions"].([]any); len(a) != 1 || a[0].(map[string]any)["rank"] != float64(42) {
+		t.Errorf("expected the external assertion, got %v", a)
+	}
+	if stats := resp["data"].(map[string]any)["stats"].(map[string]any); stats["nodes"] != float64(4) || stats["edges"] != float64(5) {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+	// a dozen mutual follows, so nested lists fan out past the profile budget
+	for i := 0; i < 12; i++ {
+		for j := 0; j < 12; j++ {
+			if i != j {
+				graph.AddFollow(padHex(36300+i), padHex(36300+j))
+			}
+		}
+	}
+	graph.ComputePageRank(20, 0.85)
+
+	// field errors leave the rest of the data intact
+	rr, resp := postGraphQL(t, `{"query":"{ top(limit: 1) { pubkey nope } profile(pubkey: \"bad\") { score } }"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 with partial data, got %d", rr.Code)
+	}
+	errs := resp["errors"].([]any)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	if path := errs[0].(map[string]any)["path"].([]any); len(path) != 3 || path[0] != "top" || path[1] != float64(0) || path[2] != "nope" {
+		t.Errorf("unexpected error path %v", path)
+	}
+	data := resp["data"].(map[string]any)
+	if top := data["top"].([]any); len(top) != 1 || top[0].(map[string]any)["pubkey"] == nil {
+		t.Errorf("expected the top profile despite the bad field, got %v", data["top"])
+	}
+	if data["profile"] != nil {
+		t.Errorf("expected null profile for an invalid pubkey, got %v", data["profile"])
+	}
+
+	for name, query := range map[string]string{
+		"syntax":     `{ top(limit: 1) { pubkey }`,
+		"mutation":   `mutation { top { pubkey } }`,
+		"fragment":   `{ top { ...F } }`,
+		"ambiguous":  `query A { stats { nodes } } query B { stats { edges } }`,
+		"too deep":   `{ top { follows { follows { follows { follows { follows { follows { follows { pubkey } } } } } } } } }`,
+		"empty body": ``,
+	} {
+		body, _ := json.Marshal(GraphQLRequest{Query: query})
+		if rr, _ := postGraphQL(t, string(body)); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rr.Code)
+		}
+	}
+
+	for name, query := range map[string]string{
+		"missing selection": `{ top }`,
+		"scalar selection":  `{ stats { nodes { x } } }`,
+		"limit range":       `{ top(limit: 500) { pubkey } }`,
+		"too many profiles": `{ top(limit: 100) { followers(limit: 100) { follows(limit: 100) { pubkey } } } }`,
+	} {
+		body, _ := json.Marshal(GraphQLRequest{Query: query})
+		if _, resp := postGraphQL(t, string(body)); resp["errors"] == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestGraphQLGet(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handleGraphQL(rr, httptest.NewRequest(http.MethodGet, "/graphql", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "type Profile") {
+		t.Errorf("expected the schema, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handleGraphQL(rr, httptest.NewRequest(http.MethodGet, `/graphql?query=query+Q($n:Int){top(limit:$n){pubkey}}&variables={"n":1}`, nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"data":{"top":[`) {
+		t.Errorf("expected query results, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handleGraphQL(rr, httptest.NewRequest(http.MethodDelete, "/graphql", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	// A day counts as a burst when it brings at least growthBurstMinFollows new
+	// followers and at least growthBurstFactor times the pubkey's mean daily rate.
+	growthBurstMinFollows = 10
+	growthBurstFactor     = 5.0
+	growthTopCommunities  = 10
+	growthRecentWindow    = 30 * 24 * time.Hour
+)
+
+// GrowthCommunity is the share of a pubkey's followers coming from one community.
+type GrowthCommunity struct {
+	CommunityID    int     `json:"community_id"`
+	Followers      int     `json:"followers"`
+	Share          float64 `json:"share"`
+	RecentFollows  int     `json:"recent_follows"` // dated follows in the last 30 days
+	AvgScore       float64 `json:"avg_score"`
+	FirstFollowAt  string  `json:"first_follow_at,omitempty"`
+	LatestFollowAt string  `json:"latest_follow_at,omitempty"`
+}
+
+// GrowthTier counts followers in one score tier.
+type GrowthTier struct {
+	Tier      string  `json:"tier"` // strong (>=60), moderate (30-59), weak (1-29), unknown (0)
+	Followers int     `json:"followers"`
+	Share     float64 `json:"share"`
+}
+
+// GrowthBurst is a run of consecutive days with unusually fast follower acquisition.
+type GrowthBurst struct {
+	Start          string  `json:"start"` // YYYY-MM-DD
+	End            string  `json:"end"`
+	Follows        int     `json:"follows"`
+	AvgScore       float64 `json:"avg_score"`
+	ZeroScoreShare float64 `json:"zero_score_share"`
+	TopCommunity   int     `json:"top_community"` // -1 when no follower has a community
+}
+
+// GrowthPacing summarizes burst vs organic follower acquisition.
+type GrowthPacing struct {
+	MeanDailyRate  float64       `json:"mean_daily_rate"`
+	PeakDay        string        `json:"peak_day,omitempty"`
+	PeakDayFollows int           `json:"peak_day_follows"`
+	BurstFollows   int           `json:"burst_follows"`
+	OrganicFollows int           `json:"organic_follows"`
+	BurstRatio     float64       `json:"burst_ratio"`
+	Classification string        `json:"classification"` // "organic", "mixed", "burst-driven", "unknown"
+	Bursts         []GrowthBurst `json:"bursts"`
+}
+
+// GrowthPeriod is follower acquisition within one calendar month.
+type GrowthPeriod struct {
+	Month          string  `json:"month"` // YYYY-MM
+	NewFollowers   int     `json:"new_followers"`
+	BurstFollows   int     `json:"burst_follows"`
+	AvgScore       float64 `json:"avg_score"`
+	ZeroScoreShare float64 `json:"zero_score_share"`
+	TopCommunity   int     `json:"top_community"` // -1 when no follower has a community
+}
+
+// GrowthSourcesResponse is the response for GET /growth-sources.
+type GrowthSourcesResponse struct {
+	Pubkey             string            `json:"pubkey"`
+	TotalFollowers     int               `json:"total_followers"`
+	FollowersWithDates int               `json:"followers_with_dates"`
+	Communities        []GrowthCommunity `json:"communities"`
+	Unclustered        int               `json:"unclustered"` // followers without a community label
+	Tiers              []GrowthTier      `json:"tiers"`
+	Pacing             GrowthPacing      `json:"pacing"`
+	Periods            []GrowthPeriod    `json:"periods"`
+	GraphSize          int               `json:"graph_size"`
+}
+
+func growthTier(score int) string {
+	switch {
+	case score >= 60:
+		return "strong"
+	case score >= 30:
+		return "moderate"
+	case score >= 1:
+		return "weak"
+	default:
+		return "unknown"
+	}
+}
+
+// computeGrowthSources breaks down where a pubkey's followers came from: which
+// communities, which score tiers, and whether they arrived in bursts or organically.
+// Pacing and periods only use followers with a known follow timestamp.
+func computeGrowthSources(pubkey string, now time.Time) GrowthSourcesResponse {
+	stats := graph.Stats()
+	followers := graph.GetFollowers(pubkey)
+	resp := GrowthSourcesResponse{
+		Pubkey:         pubkey,
+		TotalFollowers: len(followers),
+		Communities:    []GrowthCommunity{},
+		Tiers:          []GrowthTier{},
+		Pacing:         GrowthPacing{Classification: "unknown", Bursts: []GrowthBurst{}},
+		Periods:        []GrowthPeriod{},
+		GraphSize:      stats.Nodes,
+	}
+	if len(followers) == 0 {
+		return resp
+	}
+
+	type follower struct {
+		pubkey    string
+		score     int
+		community int
+		hasComm   bool
+		at        time.Time
+	}
+	all := make([]follower, 0, len(followers))
+	for _, f := range followers {
+		raw, _ := graph.GetScore(f)
+		fl := follower{pubkey: f, score: normalizeScore(raw, stats.Nodes), at: graph.GetFollowTime(f, pubkey)}
+		fl.community, fl.hasComm = communities.GetCommunity(f)
+		all = append(all, fl)
+	}
+
+	// Communities
+	type commAgg struct {
+		followers, recent int
+		scoreSum          int
+		first, latest     time.Time
+	}
+	byComm := make(map[int]*commAgg)
+	for _, f := range all {
+		if !f.hasComm {
+			resp.Unclustered++
+			continue
+		}
+		agg := byComm[f.community]
+		if agg == nil {
+			agg = &commAgg{}
+			byComm[f.community] = agg
+		}
+		agg.followers++
+		agg.scoreSum += f.score
+		if !f.at.IsZero() {
+			if now.Sub(f.at) <= growthRecentWindow {
+				agg.recent++
+			}
+			if agg.first.IsZero() || f.at.Before(agg.first) {
+				agg.first = f.at
+			}
+			if f.at.After(agg.latest) {
+				agg.latest = f.at
+			}
+		}
+	}
+	for id, agg := range byComm {
+		gc := GrowthCommunity{
+			CommunityID:   id,
+			Followers:     agg.followers,
+			Share:         math.Round(float64(agg.followers)/float64(len(all))*1000) / 1000,
+			RecentFollows: agg.recent,
+			AvgScore:      math.Round(float64(agg.scoreSum)/float64(agg.followers)*10) / 10,
+		}
+		if !agg.first.IsZero() {
+			gc.FirstFollowAt = agg.first.UTC().Format(time.RFC3339)
+			gc.LatestFollowAt = agg.latest.UTC().Format(time.RFC3339)
+		}
+		resp.Communities = append(resp.Communities, gc)
+	}
+	sort.Slice(resp.Communities, func(i, j int) bool {
+		if resp.Communities[i].Followers != resp.Communities[j].Followers {
+			return resp.Communities[i].Followers > resp.Communities[j].Followers
+		}
+		return resp.Communities[i].CommunityID < resp.Communities[j].CommunityID
+	})
+	if len(resp.Communities) > growthTopCommunities {
+		resp.Communities = resp.Communities[:growthTopCommunities]
+	}
+
+	// Score tiers
+	tierCounts := make(map[string]int)
+	for _, f := range all {
+		tierCounts[growthTier(f.score)]++
+	}
+	for _, tier := range []string{"strong", "moderate", "weak", "unknown"} {
+		resp.Tiers = append(resp.Tiers, GrowthTier{
+			Tier:      tier,
+			Followers: tierCounts[tier],
+			Share:     math.Round(float64(tierCounts[tier])/float64(len(all))*1000) / 1000,
+		})
+	}
+
+	// Pacing: bucket dated follows by day and flag days far above the mean rate.
+	var dated []follower
+	for _, f := range all {
+		if !f.at.IsZero() {
+			dated = append(dated, f)
+		}
+	}
+	resp.FollowersWithDates = len(dated)
+	if len(dated) == 0 {
+		return resp
+	}
+	sort.Slice(dated, func(i, j int) bool { return dated[i].at.Before(dated[j].at) })
+
+	byDay := make(map[string][]follower)
+	for _, f := range dated {
+		day := f.at.UTC().Format("2006-01-02")
+		byDay[day] = append(byDay[day], f)
+	}
+	spanDays := now.Sub(dated[0].at).Hours() / 24
+	if spanDays < 1 {
+		spanDays = 1
+	}
+	mean := float64(len(dated)) / spanDays
+	resp.Pacing.MeanDailyRate = math.Round(mean*100) / 100
+
+	days := make([]string, 0, len(byDay))
+	for d := range byDay {
+		days = append(days, d)
+	}
+	sort.Strings(days)
+
+	burstDay := make(map[string]bool)
+	for _, d := range days {
+		n := len(byDay[d])
+		if n > resp.Pacing.PeakDayFollows {
+			resp.Pacing.PeakDay, resp.Pacing.PeakDayFollows = d, n
+		}
+		if n >= growthBurstMinFollows && float64(n) >= growthBurstFactor*mean {
+			burstDay[d] = true
+			resp.Pacing.BurstFollows += n
+		}
+	}
+	resp.Pacing.OrganicFollows = len(dated) - resp.Pacing.BurstFollows
+	resp.Pacing.BurstRatio = math.Round(float64(resp.Pacing.BurstFollows)/float64(len(dated))*1000) / 1000
+	switch {
+	case resp.Pacing.BurstRatio >= 0.5:
+		resp.Pacing.Classification = "burst-driven"
+	case resp.Pacing.BurstRatio >= 0.2:
+		resp.Pacing.Classification = "mixed"
+	default:
+		resp.Pacing.Classification = "organic"
+	}
+
+	summarize := func(fs []follower) (avg, zeroShare float64, topComm int) {
+		sum, zero := 0, 0
+		comm := make(map[int]int)
+		for _, f := range fs {
+			sum += f.score
+			if f.score == 0 {
+				zero++
+			}
+			if f.hasComm {
+				comm[f.community]++
+			}
+		}
+		best, topComm := 0, -1
+		for id, n := range comm {
+			if n > best || (n == best && id < topComm) {
+				best, topComm = n, id
+			}
+		}
+		return math.Round(float64(sum)/float64(len(fs))*10) / 10,
+			math.Round(float64(zero)/float64(len(fs))*1000) / 1000, topComm
+	}
+
+	// Merge consecutive burst days into windows.
+	var cur []follower
+	var start, end string
+	flush := func() {
+		if len(cur) == 0 {
+			return
+		}
+		avg, zeroShare, top := summarize(cur)
+		resp.Pacing.Bursts = append(resp.Pacing.Bursts, GrowthBurst{
+			Start: start, End: end, Follows: len(cur),
+			AvgScore: avg, ZeroScoreShare: zeroShare, TopCommunity: top,
+		})
+		cur = nil
+	}
+	for _, d := range days {
+		if !burstDay[d] {
+			flush()
+			continue
+		}
+		t, _ := time.Parse("2006-01-02", d)
+		if len(cur) == 0 || t.AddDate(0, 0, -1).Format("2006-01-02") != end {
+			flush()
+			start = d
+		}
+		end = d
+		cur = append(cur, byDay[d]...)
+	}
+	flush()
+
+	// Monthly periods
+	byMonth := make(map[string][]follower)
+	burstByMonth := make(map[string]int)
+	for _, d := range days {
+		month := d[:7]
+		byMonth[month] = append(byMonth[month], byDay[d]...)
+		if burstDay[d] {
+			burstByMonth[month] += len(byDay[d])
+		}
+	}
+	months := make([]string, 0, len(byMonth))
+	for m := range byMonth {
+		months = append(months, m)
+	}
+	sort.Strings(months)
+	for _, m := range months {
+		avg, zeroShare, top := summarize(byMonth[m])
+		resp.Periods = append(resp.Periods, GrowthPeriod{
+			Month:          m,
+			NewFollowers:   len(byMonth[m]),
+			BurstFollows:   burstByMonth[m],
+			AvgScore:       avg,
+			ZeroScoreShare: zeroShare,
+			TopCommunity:   top,
+		})
+	}
+	return resp
+}
+
+// handleGrowthSources serves GET /growth-sources?pubkey=<hex|npub>.
+func handleGrowthSources(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(computeGrowthSources(pubkey, time.Now()))
+}
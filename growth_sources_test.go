@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// setupGrowthGraph gives target 10 organic followers spread over 100 days and a
+// 30-follower burst on a single day, with the burst in its own community.
+func setupGrowthGraph(t *testing.T, now time.Time) (target string, restore func()) {
+	t.Helper()
+	oldGraph, oldComm := graph, communities
+	graph = NewGraph()
+	communities = NewCommunityDetector()
+
+	target = padHex(16000)
+	for i := 0; i < 10; i++ {
+		f := padHex(16100 + i)
+		graph.AddFollowWithTime(f, target, now.AddDate(0, 0, -105+i*10))
+		communities.labels[f] = 1
+	}
+	burstDay := now.AddDate(0, 0, -20)
+	for i := 0; i < 30; i++ {
+		f := padHex(16200 + i)
+		graph.AddFollowWithTime(f, target, burstDay.Add(time.Duration(i)*time.Minute))
+		communities.labels[f] = 2
+	}
+	graph.AddFollow(padHex(16300), target) // undated follower
+	graph.ComputePageRank(20, 0.85)
+
+	return target, func() { graph, communities = oldGraph, oldComm }
+}
+
+func TestComputeGrowthSourcesDetectsBurst(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	target, restore := setupGrowthGraph(t, now)
+	defer restore()
+
+	resp := computeGrowthSources(target, now)
+	if resp.TotalFollowers != 41 || resp.FollowersWithDates != 40 {
+		t.Fatalf("expected 41 followers (40 dated), got %d (%d)", resp.TotalFollowers, resp.FollowersWithDates)
+	}
+	if resp.Unclustered != 1 {
+		t.Errorf("expected 1 unclustered follower, got %d", resp.Unclustered)
+	}
+	if len(resp.Communities) != 2 || resp.Communities[0].CommunityID != 2 || resp.Communities[0].Followers != 30 {
+		t.Errorf("expected burst community first with 30 followers, got %+v", resp.Communities)
+	}
+	if resp.Communities[0].RecentFollows != 30 || resp.Communities[1].RecentFollows != 2 {
+		t.Errorf("unexpected recent follows %+v", resp.Communities)
+	}
+
+	p := resp.Pacing
+	if p.BurstFollows != 30 || p.OrganicFollows != 10 {
+		t.Fatalf("expected 30 burst / 10 organic, got %d / %d", p.BurstFollows, p.OrganicFollows)
+	}
+	if p.Classification != "burst-driven" {
+		t.Errorf("expected burst-driven, got %s", p.Classification)
+	}
+	if len(p.Bursts) != 1 || p.Bursts[0].Start != "2026-05-12" || p.Bursts[0].TopCommunity != 2 {
+		t.Errorf("unexpected bursts %+v", p.Bursts)
+	}
+	if p.PeakDayFollows != 30 {
+		t.Errorf("expected peak day of 30, got %d", p.PeakDayFollows)
+	}
+
+	total := 0
+	for _, tier := range resp.Tiers {
+		total += tier.Followers
+	}
+	if len(resp.Tiers) != 4 || total != 41 {
+		t.Errorf("expected 4 tiers covering all followers, got %+v", resp.Tiers)
+	}
+	burstMonth := false
+	for _, period := range resp.Periods {
+		if period.Month == "2026-05" && period.BurstFollows == 30 {
+			burstMonth = true
+		}
+	}
+	if !burstMonth {
+		t.Errorf("expected May 2026 period to carry the burst, got %+v", resp.Periods)
+	}
+}
+
+func TestComputeGrowthSourcesOrganic(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	target := padHex(16500)
+	for i := 0; i < 30; i++ {
+		graph.AddFollowWithTime(padHex(16600+i), target, now.AddDate(0, 0, -i*3))
+	}
+	resp := computeGrowthSources(target, now)
+	if resp.Pacing.Classification != "organic" || resp.Pacing.BurstFollows != 0 {
+		t.Errorf("expected organic growth, got %+v", resp.Pacing)
+	}
+	if resp.Unclustered != 30 {
+		t.Errorf("expected all followers unclustered, got %d", resp.Unclustered)
+	}
+}
+
+func TestAnomaliesFlagsBurstAcquisition(t *testing.T) {
+	target, restore := setupGrowthGraph(t, time.Now())
+	defer restore()
+
+	resp := computeAnomalies(target)
+	found := false
+	for _, a := range resp.Anomalies {
+		if a.Type == "burst_acquisition" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected burst_acquisition anomaly, got %+v", resp.Anomalies)
+	}
+}
+
+func TestGrowthSourcesEndpoint(t *testing.T) {
+	target, restore := setupGrowthGraph(t, time.Now())
+	defer restore()
+
+	rr := httptest.NewRecorder()
+	handleGrowthSources(rr, httptest.NewRequest("GET", "/growth-sources?pubkey="+target, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var resp GrowthSourcesResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Pubkey != target || resp.TotalFollowers != 41 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	rr = httptest.NewRecorder()
+	handleGrowthSources(rr, httptest.NewRequest("GET", "/growth-sources", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without pubkey, got %d", rr.Code)
+	}
+}
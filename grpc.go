@@ -0,0 +1,269 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// The gRPC API (proto/wot/v1/score.proto) serves score lookups to clients such
+// as relays that check thousands of pubkeys a second and can't afford an HTTP
+// request and a JSON body each. It runs on its own port over HTTP/2 without
+// TLS, as gRPC clients expect of plaintext servers, and implements the gRPC
+// framing on net/http directly. See
+// https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-HTTP2.md.
+
+const (
+	// grpcMaxMessage is the largest request message accepted, gRPC's default.
+	grpcMaxMessage = 4 << 20
+	// grpcMaxBatch is the most pubkeys one BatchScore call may look up.
+	grpcMaxBatch = 10000
+)
+
+// gRPC status codes.
+const (
+	grpcOK                = 0
+	grpcInvalidArgument   = 3
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+	grpcUnavailable       = 14
+	grpcUnauthenticated   = 16
+)
+
+// grpcError ends a call with a status other than OK.
+type grpcError struct {
+	code int
+	msg  string
+}
+
+func (e *grpcError) Error() string { return e.msg }
+
+// ScoreReply is the ScoreReply message.
+type ScoreReply struct {
+	Pubkey    string
+	Found     bool
+	Score     uint32
+	RawScore  float64
+	Followers uint32
+	Build     uint64
+	Error     string
+}
+
+func (s ScoreReply) marshal() []byte {
+	var e protoEncoder
+	e.String(1, s.Pubkey)
+	e.Bool(2, s.Found)
+	e.Uint(3, uint64(s.Score))
+	e.Double(4, s.RawScore)
+	e.Uint(5, uint64(s.Followers))
+	e.Uint(6, s.Build)
+	e.String(7, s.Error)
+	return e.buf
+}
+
+// decodePubkeys returns the values of field 1 of a ScoreRequest or
+// BatchScoreRequest, which is a string in both.
+func decodePubkeys(msg []byte) ([]string, error) {
+	var pubkeys []string
+	err := decodeProto(msg, func(f protoField) error {
+		if f.num != 1 {
+			return nil
+		}
+		if f.wireType != protoBytes {
+			return errors.New("pubkey must be a string")
+		}
+		pubkeys = append(pubkeys, string(f.data))
+		return nil
+	})
+	return pubkeys, err
+}
+
+// scoreLookup answers one pubkey from g, which has nodes scored nodes.
+func scoreLookup(g *Graph, raw string, nodes int, build uint64) ScoreReply {
+	pubkey, err := resolvePubkey(raw)
+	if err != nil || !hex64Pattern.MatchString(pubkey) {
+		return ScoreReply{Pubkey: raw, Error: "pubkey must be 64 hex characters or an npub"}
+	}
+	score, found := g.GetScore(pubkey)
+	return ScoreReply{
+		Pubkey:    pubkey,
+		Found:     found,
+		Score:     uint32(normalizeScore(score, nodes)),
+		RawScore:  score,
+		Followers: uint32(len(g.GetFollowers(pubkey))),
+		Build:     build,
+	}
+}
+
+// grpcServer serves ScoreService. With a token set, calls must carry it as
+// "authorization: Bearer <token>" metadata.
+type grpcServer struct {
+	token string
+}
+
+func (s *grpcServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ct := r.Header.Get("Content-Type")
+	if r.Method != http.MethodPost || (ct != "application/grpc" && ct != "application/grpc+proto") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	http.NewResponseController(w).Flush() // streaming clients wait for headers
+
+	code, msg := grpcOK, ""
+	if err := s.call(w, r); err != nil {
+		var ge *grpcError
+		if errors.As(err, &ge) {
+			code, msg = ge.code, ge.msg
+		} else {
+			code, msg = grpcInternal, err.Error()
+		}
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", msg)
+	}
+}
+
+func (s *grpcServer) call(w http.ResponseWriter, r *http.Request) error {
+	if s.token != "" {
+		auth := r.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+s.token)) != 1 {
+			return &grpcError{grpcUnauthenticated, "missing or invalid bearer token"}
+		}
+	}
+
+	switch r.URL.Path {
+	case "/wot.v1.ScoreService/GetScore":
+		req, err := readGRPCUnary(r.Body)
+		if err != nil {
+			return err
+		}
+		pubkeys, err := decodePubkeys(req)
+		if err != nil || len(pubkeys) == 0 {
+			return &grpcError{grpcInvalidArgument, "pubkey required"}
+		}
+		g := graph.Snapshot()
+		nodes := g.NodeCount()
+		if nodes == 0 {
+			return &grpcError{grpcUnavailable, "graph not built yet"}
+		}
+		build, _, _ := graphBuild.Current()
+		reply := scoreLookup(g, pubkeys[len(pubkeys)-1], nodes, build) // proto3: last value wins
+		if reply.Error != "" {
+			return &grpcError{grpcInvalidArgument, reply.Error}
+		}
+		return writeGRPCMessage(w, reply.marshal())
+
+	case "/wot.v1.ScoreService/BatchScore":
+		req, err := readGRPCUnary(r.Body)
+		if err != nil {
+			return err
+		}
+		pubkeys, err := decodePubkeys(req)
+		if err != nil {
+			return &grpcError{grpcInvalidArgument, err.Error()}
+		}
+		if len(pubkeys) > grpcMaxBatch {
+			return &grpcError{grpcInvalidArgument, fmt.Sprintf("at most %d pubkeys per batch", grpcMaxBatch)}
+		}
+		g := graph.Snapshot()
+		nodes := g.NodeCount()
+		if nodes == 0 {
+			return &grpcError{grpcUnavailable, "graph not built yet"}
+		}
+		build, _, _ := graphBuild.Current()
+		var e protoEncoder
+		for _, pk := range pubkeys {
+			e.Message(1, scoreLookup(g, pk, nodes, build).marshal())
+		}
+		e.Uint(2, uint64(nodes))
+		e.Uint(3, build)
+		return writeGRPCMessage(w, e.buf)
+
+	case "/wot.v1.ScoreService/StreamScores":
+		if graph.Snapshot().NodeCount() == 0 {
+			return &grpcError{grpcUnavailable, "graph not built yet"}
+		}
+		for {
+			req, err := readGRPCMessage(r.Body)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			pubkeys, err := decodePubkeys(req)
+			if err != nil || len(pubkeys) == 0 {
+				pubkeys = []string{""}
+			}
+			// each reply reads the latest graph, since a stream can outlive a rebuild
+			g := graph.Snapshot()
+			build, _, _ := graphBuild.Current()
+			reply := scoreLookup(g, pubkeys[len(pubkeys)-1], g.NodeCount(), build)
+			if err := writeGRPCMessage(w, reply.marshal()); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return &grpcError{grpcUnimplemented, "unknown method " + r.URL.Path}
+	}
+}
+
+// readGRPCMessage reads one length-prefixed message, returning io.EOF at the end
+// of the stream.
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, &grpcError{grpcInvalidArgument, "truncated message"}
+	}
+	if prefix[0] != 0 {
+		return nil, &grpcError{grpcUnimplemented, "compressed messages are not supported"}
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > grpcMaxMessage {
+		return nil, &grpcError{grpcResourceExhausted, fmt.Sprintf("message larger than %d bytes", grpcMaxMessage)}
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, "truncated message"}
+	}
+	return msg, nil
+}
+
+// readGRPCUnary reads the single request message of a unary call.
+func readGRPCUnary(r io.Reader) ([]byte, error) {
+	msg, err := readGRPCMessage(r)
+	if err == io.EOF {
+		return nil, &grpcError{grpcInvalidArgument, "missing request message"}
+	}
+	return msg, err
+}
+
+// writeGRPCMessage writes msg with its length prefix and flushes it to the client.
+func writeGRPCMessage(w http.ResponseWriter, msg []byte) error {
+	buf := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(msg)))
+	if _, err := w.Write(append(buf, msg...)); err != nil {
+		return err
+	}
+	return http.NewResponseController(w).Flush()
+}
+
+// newGRPCServer returns a server for the gRPC API on addr, speaking HTTP/2 with
+// prior knowledge and no TLS.
+func newGRPCServer(addr, token string) *http.Server {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Server{Addr: addr, Handler: &grpcServer{token: token}, Protocols: &protocols}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// grpcTestServer serves the gRPC API over h2c, as newGRPCServer does.
+func grpcTestServer(t *testing.T, token string) (*httptest.Server, *http.Client) {
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config = newGRPCServer("", token)
+	srv.Start()
+	t.Cleanup(srv.Close)
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	return srv, &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+}
+
+func grpcFrame(msg []byte) []byte {
+	buf := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(msg)))
+	return append(buf, msg...)
+}
+
+func scoreRequest(pubkeys ...string) []byte {
+	var e protoEncoder
+	for _, pk := range pubkeys {
+		e.String(1, pk)
+	}
+	return e.buf
+}
+
+func decodeScoreReply(t *testing.T, msg []byte) ScoreReply {
+	var s ScoreReply
+	err := decodeProto(msg, func(f protoField) error {
+		switch f.num {
+		case 1:
+			s.Pubkey = string(f.data)
+		case 2:
+			s.Found = f.v == 1
+		case 3:
+			s.Score = uint32(f.v)
+		case 4:
+			s.RawScore = math.Float64frombits(f.v)
+		case 5:
+			s.Followers = uint32(f.v)
+		case 6:
+			s.Build = f.v
+		case 7:
+			s.Error = string(f.data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("decoding reply: %v", err)
+	}
+	return s
+}
+
+// grpcCall makes a unary call and returns the reply messages and the status.
+func grpcCall(t *testing.T, client *http.Client, url, method string, body []byte, header http.Header) ([][]byte, string, string) {
+	req, _ := http.NewRequest(http.MethodPost, url+"/wot.v1.ScoreService/"+method, bytes.NewReader(body))
+	req.Header = header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s: %v", method, err)
+	}
+	defer resp.Body.Close()
+	var msgs [][]byte
+	for {
+		msg, err := readGRPCMessage(resp.Body)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%s: reading reply: %v", method, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+}
+
+func grpcTestGraph(t *testing.T) (star, fan string) {
+	oldGraph := graph
+	t.Cleanup(func() { graph = oldGraph })
+	star, fan = padHex(45001), padHex(45002)
+	graph = NewGraph()
+	graph.AddFollow(fan, star)
+	graph.AddFollow(padHex(45003), star)
+	graph.ComputePageRank(20, 0.85)
+	return star, fan
+}
+
+func TestGRPCGetScore(t *testing.T) {
+	star, _ := grpcTestGraph(t)
+	srv, client := grpcTestServer(t, "")
+
+	msgs, status, _ := grpcCall(t, client, srv.URL, "GetScore", grpcFrame(scoreRequest(star)), nil)
+	if status != "0" || len(msgs) != 1 {
+		t.Fatalf("expected one reply and status 0, got %d replies, status %q", len(msgs), status)
+	}
+	reply := decodeScoreReply(t, msgs[0])
+	want, _ := graph.GetScore(star)
+	if reply.Pubkey != star || !reply.Found || reply.RawScore != want || reply.Followers != 2 ||
+		reply.Score != uint32(normalizeScore(want, 3)) {
+		t.Errorf("unexpected reply %+v", reply)
+	}
+
+	msgs, status, message := grpcCall(t, client, srv.URL, "GetScore", grpcFrame(scoreRequest("nobody")), nil)
+	if status != "3" || len(msgs) != 0 || !strings.Contains(message, "64 hex") {
+		t.Errorf("expected INVALID_ARGUMENT for a bad pubkey, got %q %q", status, message)
+	}
+	if _, status, _ := grpcCall(t, client, srv.URL, "GetScore", nil, nil); status != "3" {
+		t.Errorf("expected INVALID_ARGUMENT without a request message, got %q", status)
+	}
+	if _, status, _ := grpcCall(t, client, srv.URL, "Ping", grpcFrame(nil), nil); status != "12" {
+		t.Errorf("expected UNIMPLEMENTED for an unknown method, got %q", status)
+	}
+	compressed := grpcFrame(scoreRequest(star))
+	compressed[0] = 1
+	if _, status, _ := grpcCall(t, client, srv.URL, "GetScore", compressed, nil); status != "12" {
+		t.Errorf("expected UNIMPLEMENTED for a compressed message, got %q", status)
+	}
+}
+
+func TestGRPCBatchScore(t *testing.T) {
+	star, fan := grpcTestGraph(t)
+	srv, client := grpcTestServer(t, "")
+
+	unknown := padHex(45099)
+	msgs, status, _ := grpcCall(t, client, srv.URL, "BatchScore", grpcFrame(scoreRequest(star, "bad", fan, unknown)), nil)
+	if status != "0" || len(msgs) != 1 {
+		t.Fatalf("expected one reply and status 0, got %d replies, status %q", len(msgs), status)
+	}
+	var replies []ScoreReply
+	var graphSize uint64
+	decodeProto(msgs[0], func(f protoField) error {
+		switch f.num {
+		case 1:
+			replies = append(replies, decodeScoreReply(t, f.data))
+		case 2:
+			graphSize = f.v
+		}
+		return nil
+	})
+	if len(replies) != 4 || graphSize != 3 {
+		t.Fatalf("expected 4 replies over 3 nodes, got %+v (graph size %d)", replies, graphSize)
+	}
+	if replies[0].Pubkey != star || replies[1].Error == "" || replies[2].Pubkey != fan || replies[3].Found {
+		t.Errorf("expected replies in request order with the bad pubkey flagged, got %+v", replies)
+	}
+
+	huge := make([]string, grpcMaxBatch+1)
+	for i := range huge {
+		huge[i] = star
+	}
+	if _, status, _ := grpcCall(t, client, srv.URL, "BatchScore", grpcFrame(scoreRequest(huge...)), nil); status != "3" {
+		t.Errorf("expected INVALID_ARGUMENT for an oversized batch, got %q", status)
+	}
+}
+
+func TestGRPCStreamScores(t *testing.T) {
+	star, fan := grpcTestGraph(t)
+	srv, client := grpcTestServer(t, "")
+
+	pr, pw := io.Pipe()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/wot.v1.ScoreService/StreamScores", pr)
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// each reply arrives before the next request is sent
+	for _, pk := range []string{star, "bad", fan} {
+		pw.Write(grpcFrame(scoreRequest(pk)))
+		done := make(chan []byte)
+		go func() {
+			msg, _ := readGRPCMessage(resp.Body)
+			done <- msg
+		}()
+		select {
+		case msg := <-done:
+			reply := decodeScoreReply(t, msg)
+			if pk == "bad" && reply.Error == "" || pk != "bad" && reply.Pubkey != pk {
+				t.Errorf("unexpected reply to %s: %+v", pk, reply)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no reply to %s", pk)
+		}
+	}
+	pw.Close()
+	if _, err := readGRPCMessage(resp.Body); err != io.EOF {
+		t.Errorf("expected the stream to end, got %v", err)
+	}
+	if status := resp.Trailer.Get("Grpc-Status"); status != "0" {
+		t.Errorf("expected status 0, got %q", status)
+	}
+}
+
+func TestGRPCToken(t *testing.T) {
+	star, _ := grpcTestGraph(t)
+	srv, client := grpcTestServer(t, "s3cret")
+
+	if _, status, _ := grpcCall(t, client, srv.URL, "GetScore", grpcFrame(scoreRequest(star)), nil); status != "16" {
+		t.Errorf("expected UNAUTHENTICATED without the token, got %q", status)
+	}
+	header := http.Header{"Authorization": {"Bearer s3cret"}}
+	if msgs, status, _ := grpcCall(t, client, srv.URL, "GetScore", grpcFrame(scoreRequest(star)), header); status != "0" || len(msgs) != 1 {
+		t.Errorf("expected the call through with the token, got %q", status)
+	}
+}
+
+func TestGRPCGraphNotBuilt(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+	srv, client := grpcTestServer(t, "")
+	if _, status, _ := grpcCall(t, client, srv.URL, "GetScore", grpcFrame(scoreRequest(padHex(45001))), nil); status != "14" {
+		t.Errorf("expected UNAVAILABLE before the first build, got %q", status)
+	}
+}
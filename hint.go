@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// hintCooldown is how often one pubkey may be refreshed by hints.
+	hintCooldown = time.Minute
+	// hintFetchTimeout bounds the relay query for a hinted contact list.
+	hintFetchTimeout = 10 * time.Second
+)
+
+// hintLimiter caps hints per client IP, on top of the global rate limit, since every
+// hint without an attached event costs a relay round trip.
+var hintLimiter = NewRateLimiter(10, time.Minute)
+
+// hintCooldowns remembers when each pubkey was last refreshed by a hint.
+var hintCooldowns = struct {
+	sync.Mutex
+	last map[string]time.Time
+}{last: make(map[string]time.Time)}
+
+// fetchContactList returns the newest kind 3 event for pubkey from the crawl relays,
+// or nil if none was found. Tests replace it.
+var fetchContactList = func(ctx context.Context, pubkey string) (*nostr.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, hintFetchTimeout)
+	defer cancel()
+	pool := nostr.NewSimplePool(ctx)
+	filter := nostr.Filter{Kinds: []int{3}, Authors: []string{pubkey}, Limit: 1}
+
+	var newest *nostr.Event
+	for ev := range pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter}) {
+		if newest == nil || ev.Event.CreatedAt > newest.CreatedAt {
+			newest = ev.Event
+		}
+	}
+	return newest, nil
+}
+
+// contactListFollows returns the followed pubkeys of a kind 3 event: the values of
+// its p tags that are 64-char hex pubkeys, in tag order.
+func contactListFollows(ev *nostr.Event) []string {
+	var follows []string
+	for _, tag := range ev.Tags {
+		if len(tag) >= 2 && tag[0] == "p" && hex64Pattern.MatchString(tag[1]) {
+			follows = append(follows, tag[1])
+		}
+	}
+	return follows
+}
+
+// applyContactList replaces the author's follows with those of ev and pushes the
+// change through the scores (see PushScores); rescored counts the scores that
+// moved. ok is false, and nothing is changed, when ev is no newer than the contact
+// list the author's follows were last taken from. The caller bumps the build
+// revision.
+func applyContactList(ev *nostr.Event) (added, removed, rescored int, ok bool) {
+	a, r, ok := graph.SetFollows(ev.PubKey, contactListFollows(ev), ev.CreatedAt.Time())
+	if !ok {
+		return 0, 0, 0, false
+	}
+	if len(a)+len(r) == 0 {
+		return 0, 0, 0, true
+	}
+	u := graph.PushScores(ev.PubKey, a, r, config.Get().Damping)
+	rescoreForDrift(graph.Drift())
+	return len(a), len(r), u.Rescored, true
+}
+
+// HintResponse is the response for POST /hint.
+type HintResponse struct {
+	Pubkey   string `json:"pubkey"`
+	Status   string `json:"status"` // updated, unchanged, stale_event, not_found
+	Source   string `json:"source"` // event, relays
+	Added    int    `json:"added"`
+	Removed  int    `json:"removed"`
+	Rescored int    `json:"rescored"`
+	EventAt  string `json:"event_at,omitempty"`
+}
+
+// handleHint serves POST /hint: an integrator that just saw a pubkey publish a new
+// contact list can say so, and the list is applied immediately with a localized score
+// update instead of waiting for the next crawl. The kind 3 event may be attached (it
+// is verified and no relay is queried); otherwise the newest one is fetched.
+func handleHint(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	ip := clientIP(r)
+	if res := hintLimiter.Take(ip); !res.Allowed {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(time.Until(res.Reset).Seconds())+1))
+		writeAPIError(w, &APIError{Status: http.StatusTooManyRequests, Message: "hint rate limit exceeded"})
+		return
+	}
+
+	var req struct {
+		Pubkey string       `json:"pubkey"`
+		Kind   *int         `json:"kind"`
+		Event  *nostr.Event `json:"event"`
+	}
+	// contact lists can hold thousands of p tags
+	if apiErr := bindJSON(w, r, &req, 512<<10); apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	if req.Kind != nil && *req.Kind != 3 {
+		writeAPIError(w, invalidParam("kind", "only kind 3 contact list hints are supported; profiles are fetched live"))
+		return
+	}
+	if req.Pubkey == "" && req.Event != nil {
+		req.Pubkey = req.Event.PubKey
+	}
+	if req.Pubkey == "" {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "pubkey", Message: "pubkey or event required"})
+		return
+	}
+	pubkey, err := resolvePubkey(req.Pubkey)
+	if err != nil || !hex64Pattern.MatchString(pubkey) {
+		writeAPIError(w, invalidParam("pubkey", "pubkey must be 64 hex characters or an npub"))
+		return
+	}
+	if req.Event != nil {
+		if req.Event.Kind != 3 || req.Event.PubKey != pubkey {
+			writeAPIError(w, invalidParam("event", "event must be a kind 3 contact list by pubkey"))
+			return
+		}
+		if ok, err := req.Event.CheckSignature(); !req.Event.CheckID() || err != nil || !ok {
+			writeAPIError(w, invalidParam("event", "invalid event signature"))
+			return
+		}
+	}
+	if graph.Stats().Nodes == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "graph not built yet"})
+		return
+	}
+
+	hintCooldowns.Lock()
+	if last, ok := hintCooldowns.last[pubkey]; ok && time.Since(last) < hintCooldown {
+		hintCooldowns.Unlock()
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(hintCooldown.Seconds()-time.Since(last).Seconds())+1))
+		writeAPIError(w, &APIError{Status: http.StatusTooManyRequests, Message: "pubkey was refreshed recently"})
+		return
+	}
+	if len(hintCooldowns.last) > 10000 {
+		for pk, t := range hintCooldowns.last {
+			if time.Since(t) >= hintCooldown {
+				delete(hintCooldowns.last, pk)
+			}
+		}
+	}
+	hintCooldowns.last[pubkey] = time.Now()
+	hintCooldowns.Unlock()
+
+	resp := HintResponse{Pubkey: pubkey, Source: "event"}
+
+	ev := req.Event
+	if ev == nil {
+		resp.Source = "relays"
+		ev, err = fetchContactList(r.Context(), pubkey)
+		if err != nil {
+			writeAPIError(w, &APIError{Status: http.StatusBadGateway, Message: fmt.Sprintf("relay fetch failed: %s", err.Error())})
+			return
+		}
+	}
+
+	if ev == nil {
+		resp.Status = "not_found"
+	} else {
+		resp.EventAt = ev.CreatedAt.Time().UTC().Format(time.RFC3339)
+		recorded := contactHistory.Record(ev, "hint")
+		var ok bool
+		resp.Added, resp.Removed, resp.Rescored, ok = applyContactList(ev)
+		switch {
+		case !ok:
+			resp.Status = "stale_event"
+		case resp.Added+resp.Removed == 0:
+			resp.Status = "unchanged"
+		default:
+			resp.Status = "updated"
+		}
+		if recorded || resp.Status == "updated" {
+			graphBuild.Touch()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// setupHint installs a small scored graph with a user whose key the test controls,
+// and fresh hint limits. The user was crawled following 27001 and 27002 an hour ago.
+func setupHint(t *testing.T) (sk, pub string) {
+	oldGraph, oldBuild, oldFetch, oldLimiter := graph, graphBuild, fetchContactList, hintLimiter
+	t.Cleanup(func() {
+		graph, graphBuild, fetchContactList, hintLimiter = oldGraph, oldBuild, oldFetch, oldLimiter
+		hintCooldowns.Lock()
+		hintCooldowns.last = make(map[string]time.Time)
+		hintCooldowns.Unlock()
+	})
+	hintCooldowns.Lock()
+	hintCooldowns.last = make(map[string]time.Time)
+	hintCooldowns.Unlock()
+	hintLimiter = NewRateLimiter(10, time.Minute)
+
+	sk = nostr.GeneratePrivateKey()
+	pub, _ = nostr.GetPublicKey(sk)
+	graph = NewGraph()
+	crawled := time.Now().Add(-time.Hour)
+	for i := 0; i < 10; i++ {
+		graph.AddFollow(padHex(27100+i), pub)
+	}
+	graph.AddFollowWithTime(pub, padHex(27001), crawled)
+	graph.AddFollowWithTime(pub, padHex(27002), crawled)
+	graph.AddFollow(padHex(27100), padHex(27003))
+	graph.ComputePageRank(20, 0.85)
+	graphBuild = NewGraphBuild()
+	graphBuild.Advance(time.Now())
+	return sk, pub
+}
+
+func contactList(t *testing.T, sk string, at time.Time, follows ...string) *nostr.Event {
+	t.Helper()
+	ev := &nostr.Event{Kind: 3, CreatedAt: nostr.Timestamp(at.Unix())}
+	for _, f := range follows {
+		ev.Tags = append(ev.Tags, nostr.Tag{"p", f})
+	}
+	if err := ev.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+func postHint(body interface{}) (*httptest.ResponseRecorder, HintResponse) {
+	raw, _ := json.Marshal(body)
+	rr := httptest.NewRecorder()
+	handleHint(rr, httptest.NewRequest(http.MethodPost, "/hint", bytes.NewReader(raw)))
+	var resp HintResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+func TestHintAttachedEventUpdatesGraphLocally(t *testing.T) {
+	sk, pub := setupHint(t)
+	before2, _ := graph.GetScore(padHex(27002))
+	before3, _ := graph.GetScore(padHex(27003))
+	_, rev, _ := graphBuild.Current()
+
+	// drop 27002, add 27003
+	ev := contactList(t, sk, time.Now(), padHex(27001), padHex(27003), padHex(27003))
+	rr, resp := postHint(map[string]interface{}{"event": ev})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	// the out-degree is unchanged, so only 27002 and 27003 move
+	if resp.Status != "updated" || resp.Source != "event" || resp.Added != 1 || resp.Removed != 1 || resp.Rescored != 2 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	follows := graph.GetFollows(pub)
+	if len(follows) != 2 || follows[0] != padHex(27001) || follows[1] != padHex(27003) {
+		t.Errorf("expected deduplicated follows [27001 27003], got %v", follows)
+	}
+	for _, f := range graph.GetFollowers(padHex(27002)) {
+		if f == pub {
+			t.Error("removed follow still lists pub as a follower")
+		}
+	}
+	if after, _ := graph.GetScore(padHex(27002)); after >= before2 {
+		t.Errorf("unfollowed account should lose score: %f -> %f", before2, after)
+	}
+	if after, _ := graph.GetScore(padHex(27003)); after <= before3 {
+		t.Errorf("newly followed account should gain score: %f -> %f", before3, after)
+	}
+	if _, after, _ := graphBuild.Current(); after != rev+1 {
+		t.Errorf("expected build revision bump, got %d -> %d", rev, after)
+	}
+}
+
+func TestHintFetchesFromRelaysAndRejectsStaleLists(t *testing.T) {
+	sk, pub := setupHint(t)
+
+	var fetched string
+	stale := contactList(t, sk, time.Now().Add(-2*time.Hour), padHex(27009))
+	fetchContactList = func(ctx context.Context, pubkey string) (*nostr.Event, error) {
+		fetched = pubkey
+		return stale, nil
+	}
+	npub, _ := nip19.EncodePublicKey(pub)
+	_, resp := postHint(map[string]interface{}{"pubkey": npub, "kind": 3})
+	if fetched != pub || resp.Source != "relays" || resp.Status != "stale_event" {
+		t.Errorf("expected relay fetch rejected as stale, got %+v (fetched %q)", resp, fetched)
+	}
+	if len(graph.GetFollows(pub)) != 2 {
+		t.Error("stale contact list must not replace follows")
+	}
+
+	// a second hint for the same pubkey inside the cooldown is refused
+	if rr, _ := postHint(map[string]interface{}{"pubkey": pub}); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 inside the per-pubkey cooldown, got %d", rr.Code)
+	}
+
+	other := nostr.GeneratePrivateKey()
+	otherPub, _ := nostr.GetPublicKey(other)
+	fetchContactList = func(ctx context.Context, pubkey string) (*nostr.Event, error) { return nil, nil }
+	if _, resp := postHint(map[string]interface{}{"pubkey": otherPub}); resp.Status != "not_found" {
+		t.Errorf("expected not_found, got %+v", resp)
+	}
+}
+
+func TestHintErrors(t *testing.T) {
+	sk, pub := setupHint(t)
+	forged := contactList(t, sk, time.Now(), padHex(27001))
+	forged.Tags = append(forged.Tags, nostr.Tag{"p", padHex(27005)})
+	other := nostr.GeneratePrivateKey()
+
+	for name, tc := range map[string]struct {
+		body interface{}
+		code int
+	}{
+		"empty":          {map[string]interface{}{}, http.StatusBadRequest},
+		"bad pubkey":     {map[string]interface{}{"pubkey": "alice"}, http.StatusBadRequest},
+		"profile kind":   {map[string]interface{}{"pubkey": pub, "kind": 0}, http.StatusBadRequest},
+		"tampered event": {map[string]interface{}{"event": forged}, http.StatusBadRequest},
+		"wrong author":   {map[string]interface{}{"pubkey": pub, "event": contactList(t, other, time.Now())}, http.StatusBadRequest},
+	} {
+		if rr, _ := postHint(tc.body); rr.Code != tc.code {
+			t.Errorf("%s: expected %d, got %d", name, tc.code, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handleHint(rr, httptest.NewRequest(http.MethodGet, "/hint", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rr.Code)
+	}
+
+	hintLimiter = NewRateLimiter(1, time.Minute)
+	postHint(map[string]interface{}{})
+	if rr, _ := postHint(map[string]interface{}{}); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected per-IP hint limit, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import "sync"
+
+// hitsRoleMargin is how many points (0-100) one of a pubkey's HITS scores must
+// lead the other by before it is classed as a curator or a producer.
+const hitsRoleMargin = 10
+
+// HITSEntry is a pubkey's hub and authority scores. Both sum to 1 across the
+// graph, like PageRank, so they normalize to 0-100 the same way.
+type HITSEntry struct {
+	Hub       float64 `json:"hub"`
+	Authority float64 `json:"authority"`
+}
+
+// ComputeHITS runs Kleinberg's HITS over the follow graph. An account's authority
+// is the hub score of everyone following it, and its hub score the authority of
+// everyone it follows, so good hubs are accounts that follow many authorities
+// (curators) and good authorities are followed by many hubs (content producers).
+func ComputeHITS(g *Graph, iterations int) map[string]HITSEntry {
+	g.mu.RLock()
+	pg := newPageRankGraph(g.follows, g.followers, nil, nil)
+	g.mu.RUnlock()
+	return pg.hits(iterations)
+}
+
+// hits iterates hub and authority scores over pg's in-edges, scaling each to sum
+// to 1 after every step.
+func (pg *pageRankGraph) hits(iterations int) map[string]HITSEntry {
+	n := len(pg.names)
+	if n == 0 {
+		return nil
+	}
+	hub := make([]float64, n)
+	auth := make([]float64, n)
+	for i := range hub {
+		hub[i] = 1.0 / float64(n)
+	}
+	scale := func(v []float64) {
+		sum := 0.0
+		for _, x := range v {
+			sum += x
+		}
+		if sum == 0 {
+			return
+		}
+		for i := range v {
+			v[i] /= sum
+		}
+	}
+
+	for it := 0; it < iterations; it++ {
+		parallelRange(n, func(lo, hi int) {
+			for i := lo; i < hi; i++ {
+				sum := 0.0
+				for k := pg.inStart[i]; k < pg.inStart[i+1]; k++ {
+					sum += hub[pg.in[k]]
+				}
+				auth[i] = sum
+			}
+		})
+		scale(auth)
+		// hub scores gather over out-edges, which the index only holds reversed
+		clear(hub)
+		for i := 0; i < n; i++ {
+			for k := pg.inStart[i]; k < pg.inStart[i+1]; k++ {
+				hub[pg.in[k]] += auth[i]
+			}
+		}
+		scale(hub)
+	}
+
+	out := make(map[string]HITSEntry, n)
+	for i, pk := range pg.names {
+		out[pk] = HITSEntry{Hub: hub[i], Authority: auth[i]}
+	}
+	return out
+}
+
+// hitsRole classes a pubkey by its normalized hub and authority scores: a
+// curator follows the accounts that matter, a producer is followed by the
+// accounts that curate, and anything in between is balanced.
+func hitsRole(hubScore, authorityScore int) string {
+	switch {
+	case hubScore >= authorityScore+hitsRoleMargin:
+		return "curator"
+	case authorityScore >= hubScore+hitsRoleMargin:
+		return "producer"
+	default:
+		return "balanced"
+	}
+}
+
+// HITSSummary is a pubkey's HITS scores, normalized like PageRank, with its role.
+type HITSSummary struct {
+	HubScore          int     `json:"hub_score"`
+	AuthorityScore    int     `json:"authority_score"`
+	RawHubScore       float64 `json:"raw_hub_score"`
+	RawAuthorityScore float64 `json:"raw_authority_score"`
+	Role              string  `json:"role"`
+}
+
+func newHITSSummary(e HITSEntry, nodes int) HITSSummary {
+	hub, authority := normalizeScore(e.Hub, nodes), normalizeScore(e.Authority, nodes)
+	return HITSSummary{
+		HubScore:          hub,
+		AuthorityScore:    authority,
+		RawHubScore:       e.Hub,
+		RawAuthorityScore: e.Authority,
+		Role:              hitsRole(hub, authority),
+	}
+}
+
+// AuditHITS is the HITS section of /audit.
+type AuditHITS struct {
+	HITSSummary
+	Algorithm     string `json:"algorithm"`
+	Iterations    int    `json:"iterations"`
+	Normalization string `json:"normalization"`
+}
+
+// HITSStore holds the last HITS pass.
+type HITSStore struct {
+	mu      sync.RWMutex
+	entries map[string]HITSEntry
+}
+
+func NewHITSStore() *HITSStore {
+	return &HITSStore{entries: make(map[string]HITSEntry)}
+}
+
+var hitsScores = NewHITSStore()
+
+func (s *HITSStore) Set(entries map[string]HITSEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = entries
+}
+
+func (s *HITSStore) Get(pubkey string) (HITSEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.entries[pubkey]
+	return e, ok
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// referenceHITS is the textbook map-based HITS, scaled to sum to 1 each step.
+func referenceHITS(follows, followers map[string][]string, iterations int) (hub, auth map[string]float64) {
+	nodes := make(map[string]bool)
+	for k, vs := range follows {
+		nodes[k] = true
+		for _, v := range vs {
+			nodes[v] = true
+		}
+	}
+	hub = make(map[string]float64)
+	for node := range nodes {
+		hub[node] = 1.0 / float64(len(nodes))
+	}
+	scale := func(m map[string]float64) {
+		sum := 0.0
+		for _, v := range m {
+			sum += v
+		}
+		for k := range m {
+			m[k] /= sum
+		}
+	}
+	for i := 0; i < iterations; i++ {
+		auth = make(map[string]float64)
+		for node := range nodes {
+			for _, f := range followers[node] {
+				auth[node] += hub[f]
+			}
+		}
+		scale(auth)
+		hub = make(map[string]float64)
+		for node := range nodes {
+			hub[node] = 0
+			for _, t := range follows[node] {
+				hub[node] += auth[t]
+			}
+		}
+		scale(hub)
+	}
+	return hub, auth
+}
+
+func TestComputeHITSMatchesReference(t *testing.T) {
+	for _, size := range []int{40, pageRankParallelMin + 200} {
+		g := randomFollowGraph(size, size*3, int64(size)+1)
+		got := ComputeHITS(g, 20)
+		hub, auth := referenceHITS(g.follows, g.followers, 20)
+		if len(got) != len(hub) {
+			t.Fatalf("size %d: expected %d entries, got %d", size, len(hub), len(got))
+		}
+		for pk, e := range got {
+			if math.Abs(e.Hub-hub[pk]) > 1e-12 || math.Abs(e.Authority-auth[pk]) > 1e-12 {
+				t.Fatalf("size %d: %s expected hub %v authority %v, got %+v", size, pk, hub[pk], auth[pk], e)
+			}
+		}
+	}
+}
+
+// hitsGraph has a curator following three producers that several readers also
+// follow; nobody follows the curator.
+func hitsGraph() (g *Graph, curator string, producers []string) {
+	g = NewGraph()
+	curator = padHex(43001)
+	producers = []string{padHex(43010), padHex(43011), padHex(43012)}
+	for _, p := range producers {
+		g.AddFollow(curator, p)
+		for i := 0; i < 3; i++ {
+			g.AddFollow(padHex(43100+i), p)
+		}
+	}
+	g.AddFollow(padHex(43100), padHex(43101))
+	return g, curator, producers
+}
+
+func TestComputeHITSRoles(t *testing.T) {
+	g, curator, producers := hitsGraph()
+	entries := ComputeHITS(g, 20)
+	nodes := len(entries)
+
+	c := entries[curator]
+	if c.Authority != 0 || c.Hub == 0 {
+		t.Errorf("expected the curator to be a hub with no authority, got %+v", c)
+	}
+	if got := newHITSSummary(c, nodes).Role; got != "curator" {
+		t.Errorf("expected the curator classed as a curator, got %v", got)
+	}
+	p := entries[producers[0]]
+	if p.Hub != 0 || p.Authority <= entries[padHex(43101)].Authority {
+		t.Errorf("expected a producer to out-rank a reader as an authority, got %+v", p)
+	}
+	if got := newHITSSummary(p, nodes).Role; got != "producer" {
+		t.Errorf("expected a producer classed as a producer, got %v", got)
+	}
+
+	hubSum, authSum := 0.0, 0.0
+	for _, e := range entries {
+		hubSum += e.Hub
+		authSum += e.Authority
+	}
+	if math.Abs(hubSum-1) > 1e-9 || math.Abs(authSum-1) > 1e-9 {
+		t.Errorf("expected both scores to sum to 1, got %v and %v", hubSum, authSum)
+	}
+	if ComputeHITS(NewGraph(), 20) != nil {
+		t.Error("expected no scores for an empty graph")
+	}
+}
+
+func TestHITSRole(t *testing.T) {
+	for _, tc := range []struct {
+		hub, authority int
+		want           string
+	}{
+		{40, 20, "curator"},
+		{20, 40, "producer"},
+		{30, 25, "balanced"},
+		{0, 0, "balanced"},
+	} {
+		if got := hitsRole(tc.hub, tc.authority); got != tc.want {
+			t.Errorf("hitsRole(%d, %d) = %s, want %s", tc.hub, tc.authority, got, tc.want)
+		}
+	}
+}
+
+func TestHITSInScoreAndAudit(t *testing.T) {
+	oldGraph, oldMeta, oldHITS := graph, meta, hitsScores
+	defer func() { graph, meta, hitsScores = oldGraph, oldMeta, oldHITS }()
+	meta, hitsScores = NewMetaStore(), NewHITSStore()
+	g, curator, _ := hitsGraph()
+	g.ComputePageRank(20, 0.85)
+	graph = g
+	hitsScores.Set(ComputeHITS(g, 20))
+
+	rr := httptest.NewRecorder()
+	handleScore(rr, httptest.NewRequest(http.MethodGet, "/score?pubkey="+curator+"&algorithm=hits", nil))
+	var score map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &score)
+	if score["algorithm"] != "hits" || score["role"] != "curator" || score["hub_score"].(float64) <= score["authority_score"].(float64) {
+		t.Errorf("expected the curator's hub and authority scores, got %v", score)
+	}
+	if _, ok := score["score"]; !ok {
+		t.Error("expected the PageRank score kept alongside")
+	}
+
+	rr = httptest.NewRecorder()
+	handleScore(rr, httptest.NewRequest(http.MethodGet, "/score?pubkey="+curator, nil))
+	score = nil
+	if json.Unmarshal(rr.Body.Bytes(), &score); score["hub_score"] != nil {
+		t.Errorf("expected no HITS fields by default, got %v", score)
+	}
+
+	rr = httptest.NewRecorder()
+	handleScore(rr, httptest.NewRequest(http.MethodGet, "/score?pubkey="+curator+"&algorithm=salsa", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown algorithm, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handleAudit(rr, httptest.NewRequest(http.MethodGet, "/audit?pubkey="+curator, nil))
+	var audit struct {
+		HITS map[string]interface{} `json:"hits"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &audit)
+	if audit.HITS == nil || audit.HITS["role"] != "curator" || audit.HITS["algorithm"] != "HITS" {
+		t.Errorf("expected a hits section in the audit, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+)
+
+const (
+	// hllPrecision gives 2^10 registers per sketch: ~1KB and ~3.25% standard error.
+	hllPrecision = 10
+	hllRegisters = 1 << hllPrecision
+)
+
+// hllSeed is shared by every sketch so sketches can be merged.
+var hllSeed = maphash.MakeSeed()
+
+// hyperLogLog is a fixed-precision HyperLogLog cardinality sketch.
+type hyperLogLog struct {
+	registers [hllRegisters]uint8
+}
+
+func (h *hyperLogLog) Add(s string) {
+	x := maphash.String(hllSeed, s)
+	idx := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// Merge folds other into h, giving the sketch of the union.
+func (h *hyperLogLog) Merge(other *hyperLogLog) {
+	for i, r := range other.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+}
+
+// Estimate returns the cardinality estimate, using linear counting for small sets.
+func (h *hyperLogLog) Estimate() int {
+	m := float64(hllRegisters)
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	est := alpha * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+	return int(math.Round(est))
+}
+
+// RelativeError is the sketch's standard error as a fraction of the estimate.
+func (h *hyperLogLog) RelativeError() float64 {
+	return 1.04 / math.Sqrt(hllRegisters)
+}
+
+// distinctExactLimit is how many values a DistinctCounter tracks exactly before it
+// switches to a sketch.
+const distinctExactLimit = 256
+
+// DistinctCounter counts distinct strings in bounded memory: exactly in a set while
+// small, then in a HyperLogLog sketch once it passes distinctExactLimit values. The
+// zero value is ready to use. It is not safe for concurrent use; callers guard it
+// with their store's mutex.
+type DistinctCounter struct {
+	exact  map[string]struct{}
+	sketch *hyperLogLog
+}
+
+func (d *DistinctCounter) Add(s string) {
+	if d.sketch != nil {
+		d.sketch.Add(s)
+		return
+	}
+	if d.exact == nil {
+		d.exact = make(map[string]struct{})
+	}
+	d.exact[s] = struct{}{}
+	if len(d.exact) > distinctExactLimit {
+		d.sketch = &hyperLogLog{}
+		for v := range d.exact {
+			d.sketch.Add(v)
+		}
+		d.exact = nil
+	}
+}
+
+// Count returns the number of distinct values added, estimated once sketched.
+func (d *DistinctCounter) Count() int {
+	if d.sketch != nil {
+		return d.sketch.Estimate()
+	}
+	return len(d.exact)
+}
+
+// Has reports whether s was added. Membership is only known while the counter is
+// exact; a sketched counter always returns false.
+func (d *DistinctCounter) Has(s string) bool {
+	_, ok := d.exact[s]
+	return ok
+}
+
+// Approximate reports whether Count is a sketch estimate.
+func (d *DistinctCounter) Approximate() bool {
+	return d.sketch != nil
+}
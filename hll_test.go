@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestHyperLogLogEstimate(t *testing.T) {
+	for _, n := range []int{0, 100, 20000} {
+		h := &hyperLogLog{}
+		for i := 0; i < n; i++ {
+			h.Add(fmt.Sprintf("pk-%d", i))
+			h.Add(fmt.Sprintf("pk-%d", i)) // duplicates must not count
+		}
+		if got := h.Estimate(); math.Abs(float64(got-n)) > 0.1*float64(n) {
+			t.Errorf("n=%d: estimate %d outside 10%%", n, got)
+		}
+	}
+
+	a, b := &hyperLogLog{}, &hyperLogLog{}
+	for i := 0; i < 6000; i++ {
+		a.Add(fmt.Sprintf("pk-%d", i))
+		b.Add(fmt.Sprintf("pk-%d", i+3000))
+	}
+	a.Merge(b)
+	if got := a.Estimate(); math.Abs(float64(got-9000)) > 900 {
+		t.Errorf("merged estimate %d, want ~9000", got)
+	}
+}
+
+func TestDistinctCounter(t *testing.T) {
+	var d DistinctCounter
+	if d.Count() != 0 || d.Has("a") {
+		t.Fatal("zero value must be empty")
+	}
+	d.Add("a")
+	d.Add("a")
+	d.Add("b")
+	if d.Count() != 2 || !d.Has("a") || d.Approximate() {
+		t.Errorf("expected exact count 2, got %d", d.Count())
+	}
+
+	for i := 0; i < 5000; i++ {
+		d.Add(fmt.Sprintf("pk-%d", i%4000))
+	}
+	if !d.Approximate() || d.exact != nil {
+		t.Fatal("expected counter to switch to a sketch past the exact limit")
+	}
+	if got := d.Count(); math.Abs(float64(got-4002)) > 400 {
+		t.Errorf("sketched count %d, want ~4002", got)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FetchConfig controls the behavior of the shared outbound HTTP client.
+type FetchConfig struct {
+	Timeout          time.Duration // per-attempt timeout
+	MaxBodyBytes     int64         // responses larger than this are rejected
+	MaxRetries       int           // retries after the first attempt on transient errors
+	RetryBackoff     time.Duration // base backoff, doubled on each retry
+	BreakerThreshold int           // consecutive failures before a host's breaker opens
+	BreakerCooldown  time.Duration // how long an open breaker rejects requests
+	AllowPrivate     bool          // allow private/loopback targets (tests only)
+}
+
+// DefaultFetchConfig is used for all third-party fetches (NIP-05, trustedrelays.xyz, LNURL).
+var DefaultFetchConfig = FetchConfig{
+	Timeout:          8 * time.Second,
+	MaxBodyBytes:     1 << 20, // 1MB
+	MaxRetries:       2,
+	RetryBackoff:     250 * time.Millisecond,
+	BreakerThreshold: 5,
+	BreakerCooldown:  time.Minute,
+}
+
+// ErrBlockedAddress is returned when a fetch targets a private or otherwise non-public address.
+var ErrBlockedAddress = errors.New("destination address is not publicly routable")
+
+// ErrResponseTooLarge is returned when a response body exceeds MaxBodyBytes.
+var ErrResponseTooLarge = errors.New("response body exceeds size limit")
+
+// ErrCircuitOpen is returned while a host's circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open for host")
+
+// FetchResponse is the fully-read result of a successful round trip.
+type FetchResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// hostBreaker tracks consecutive failures for a single host.
+type hostBreaker struct {
+	failures  int
+	openUntil time.Time
+}
+
+// SafeHTTPClient is a hardened HTTP client for fetching untrusted, user-influenced URLs.
+// It enforces timeouts and response size limits, refuses to connect to private IP ranges
+// (checked at dial time, so DNS rebinding cannot bypass it), retries transient failures,
+// and trips a per-host circuit breaker when a host keeps failing.
+type SafeHTTPClient struct {
+	config   FetchConfig
+	client   *http.Client
+	mu       sync.Mutex
+	breakers map[string]*hostBreaker
+}
+
+// NewSafeHTTPClient creates a hardened client with the given configuration.
+func NewSafeHTTPClient(config FetchConfig) *SafeHTTPClient {
+	dialer := &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if !config.AllowPrivate {
+		dialer.Control = denyPrivateControl
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = nil // a proxy would hide the real destination from the dial check
+	tr.DialContext = dialer.DialContext
+	tr.MaxIdleConnsPerHost = 4
+	tr.ResponseHeaderTimeout = config.Timeout
+
+	c := &SafeHTTPClient{
+		config:   config,
+		breakers: make(map[string]*hostBreaker),
+	}
+	c.client = &http.Client{
+		Timeout:   config.Timeout,
+		Transport: tr,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return fmt.Errorf("stopped after %d redirects", len(via))
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	return c
+}
+
+// externalHTTP is the shared client for all outbound third-party fetches.
+var externalHTTP = NewSafeHTTPClient(DefaultFetchConfig)
+
+// denyPrivateControl rejects connections to non-public addresses after DNS resolution.
+func denyPrivateControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isBlockedIP(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return nil
+}
+
+// blockedNets are ranges that IsPrivate/IsLoopback don't cover but must never be fetched.
+var blockedNets = mustParseCIDRs(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+	"64:ff9b::/96",  // NAT64 (may map to private IPv4)
+	"2001:db8::/32", // documentation
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	out := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// isBlockedIP reports whether ip is private, loopback, link-local, multicast, or reserved.
+func isBlockedIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get fetches rawURL and returns the fully-read response.
+// Non-2xx responses are returned without error so callers can inspect the status.
+func (c *SafeHTTPClient) Get(ctx context.Context, rawURL string) (*FetchResponse, error) {
+	return c.Do(ctx, http.MethodGet, rawURL, nil)
+}
+
+// Do performs a request with retries, size limits, and circuit breaking.
+func (c *SafeHTTPClient) Do(ctx context.Context, method, rawURL string, header http.Header) (*FetchResponse, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return nil, fmt.Errorf("URL has no host")
+	}
+
+	if !c.allow(host) {
+		return nil, fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+	}
+
+	var lastErr error
+	backoff := c.config.RetryBackoff
+	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		resp, err := c.roundTrip(ctx, method, rawURL, header)
+		if err != nil {
+			lastErr = err
+			// Blocked destinations and oversized bodies won't improve on retry.
+			if errors.Is(err, ErrBlockedAddress) || errors.Is(err, ErrResponseTooLarge) {
+				c.recordFailure(host)
+				return nil, err
+			}
+			continue
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			lastErr = fmt.Errorf("%s returned %d", host, resp.StatusCode)
+			if attempt < c.config.MaxRetries {
+				continue
+			}
+			c.recordFailure(host)
+			return resp, nil
+		}
+
+		c.recordSuccess(host)
+		return resp, nil
+	}
+
+	c.recordFailure(host)
+	return nil, lastErr
+}
+
+func (c *SafeHTTPClient) roundTrip(ctx context.Context, method, rawURL string, header http.Header) (*FetchResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", "wot-scoring/1.0 (+https://github.com/joelklabo/wot-scoring)")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.ContentLength > c.config.MaxBodyBytes {
+		return nil, ErrResponseTooLarge
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.config.MaxBodyBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	if int64(len(body)) > c.config.MaxBodyBytes {
+		return nil, ErrResponseTooLarge
+	}
+
+	return &FetchResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+// allow reports whether requests to host are currently permitted by its breaker.
+func (c *SafeHTTPClient) allow(host string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[host]
+	if !ok {
+		return true
+	}
+	return time.Now().After(b.openUntil)
+}
+
+func (c *SafeHTTPClient) recordSuccess(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.breakers, host)
+}
+
+func (c *SafeHTTPClient) recordFailure(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[host]
+	if !ok {
+		b = &hostBreaker{}
+		c.breakers[host] = b
+	}
+	b.failures++
+	if c.config.BreakerThreshold > 0 && b.failures >= c.config.BreakerThreshold {
+		b.openUntil = time.Now().Add(c.config.BreakerCooldown)
+	}
+}
+
+// BreakerStats returns the number of hosts with recorded failures and with an open breaker.
+func (c *SafeHTTPClient) BreakerStats() (failing, open int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, b := range c.breakers {
+		failing++
+		if now.Before(b.openUntil) {
+			open++
+		}
+	}
+	return failing, open
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// locales are the languages user-facing text is available in. English is the
+// source language and the fallback.
+var locales = []string{"en", "de", "es", "ja"}
+
+// catalog translates user-facing English text, keyed by the English itself, into
+// the other locales. Keys with verbs are fmt formats for API fields and keep
+// their arguments' order; the rest are also page text (see localizeHTML). Text
+// missing a translation stays English.
+var catalog = map[string]map[string]string{
+	// trust levels (see trustLevelLabel)
+	"Highly trusted": {"de": "Sehr vertrauenswürdig", "es": "Muy confiable", "ja": "非常に信頼できる"},
+	"Trusted":        {"de": "Vertrauenswürdig", "es": "Confiable", "ja": "信頼できる"},
+	"Moderate":       {"de": "Mittel", "es": "Moderado", "ja": "中程度"},
+	"Low":            {"de": "Gering", "es": "Bajo", "ja": "低い"},
+	"Untrusted":      {"de": "Nicht vertrauenswürdig", "es": "No confiable", "ja": "信頼できない"},
+	"Unknown":        {"de": "Unbekannt", "es": "Desconocido", "ja": "不明"},
+
+	// /spam summaries and signal reasons
+	"High spam probability. WoT score %d, %d followers, %d reports. This pubkey shows multiple spam indicators.": {
+		"de": "Hohe Spam-Wahrscheinlichkeit. WoT-Score %d, %d Follower, %d Meldungen. Dieser Pubkey zeigt mehrere Spam-Merkmale.",
+		"es": "Alta probabilidad de spam. Puntuación WoT %d, %d seguidores, %d denuncias. Esta pubkey muestra varios indicadores de spam.",
+		"ja": "スパムの可能性が高い。WoTスコア %d、フォロワー %d、通報 %d 件。このpubkeyには複数のスパム兆候があります。",
+	},
+	"Moderate spam risk. WoT score %d, %d followers. Some indicators suggest this may not be a genuine account.": {
+		"de": "Mittleres Spam-Risiko. WoT-Score %d, %d Follower. Einige Merkmale deuten darauf hin, dass dies kein echtes Konto sein könnte.",
+		"es": "Riesgo de spam moderado. Puntuación WoT %d, %d seguidores. Algunos indicadores sugieren que puede no ser una cuenta genuina.",
+		"ja": "スパムのリスクは中程度。WoTスコア %d、フォロワー %d。本物のアカウントではない可能性を示す兆候があります。",
+	},
+	"Likely human. WoT score %d, %d followers. Trust signals are consistent with a real user.": {
+		"de": "Wahrscheinlich ein Mensch. WoT-Score %d, %d Follower. Die Vertrauenssignale passen zu einem echten Nutzer.",
+		"es": "Probablemente humano. Puntuación WoT %d, %d seguidores. Las señales de confianza son coherentes con un usuario real.",
+		"ja": "おそらく人間。WoTスコア %d、フォロワー %d。信頼シグナルは実在のユーザーと一致しています。",
+	},
+	"Pubkey not found in WoT graph — no trust data available": {
+		"de": "Pubkey nicht im WoT-Graphen — keine Vertrauensdaten verfügbar",
+		"es": "Pubkey no encontrada en el grafo WoT — no hay datos de confianza",
+		"ja": "WoTグラフにpubkeyがありません — 信頼データなし",
+	},
+	"WoT score is 0 — no measurable trust": {
+		"de": "WoT-Score ist 0 — kein messbares Vertrauen",
+		"es": "La puntuación WoT es 0 — sin confianza medible",
+		"ja": "WoTスコアは0 — 測定可能な信頼なし",
+	},
+	"WoT score %d (top %.0f%%) — highly trusted": {
+		"de": "WoT-Score %d (oberste %.0f%%) — sehr vertrauenswürdig",
+		"es": "Puntuación WoT %d (%.0f%% superior) — muy confiable",
+		"ja": "WoTスコア %d（上位 %.0f%%）— 非常に信頼できる",
+	},
+	"WoT score %d (top %.0f%%) — moderate trust": {
+		"de": "WoT-Score %d (oberste %.0f%%) — mittleres Vertrauen",
+		"es": "Puntuación WoT %d (%.0f%% superior) — confianza moderada",
+		"ja": "WoTスコア %d（上位 %.0f%%）— 中程度の信頼",
+	},
+	"WoT score %d (bottom %.0f%%) — low trust": {
+		"de": "WoT-Score %d (unterste %.0f%%) — geringes Vertrauen",
+		"es": "Puntuación WoT %d (%.0f%% inferior) — confianza baja",
+		"ja": "WoTスコア %d（下位 %.0f%%）— 低い信頼",
+	},
+	"No follow data — unknown": {
+		"de": "Keine Follow-Daten — unbekannt",
+		"es": "Sin datos de seguimiento — desconocido",
+		"ja": "フォローデータなし — 不明",
+	},
+	"%d followers, follows nobody — passive but not spammy": {
+		"de": "%d Follower, folgt niemandem — passiv, aber nicht spammig",
+		"es": "%d seguidores, no sigue a nadie — pasivo pero no spam",
+		"ja": "フォロワー %d、フォローなし — 受け身だがスパムではない",
+	},
+	"Ratio %.2f (%d followers / %d following) — healthy": {
+		"de": "Verhältnis %.2f (%d Follower / %d gefolgt) — gesund",
+		"es": "Proporción %.2f (%d seguidores / %d seguidos) — saludable",
+		"ja": "比率 %.2f（フォロワー %d / フォロー %d）— 健全",
+	},
+	"Ratio %.2f (%d followers / %d following) — slightly imbalanced": {
+		"de": "Verhältnis %.2f (%d Follower / %d gefolgt) — leicht unausgewogen",
+		"es": "Proporción %.2f (%d seguidores / %d seguidos) — algo desequilibrada",
+		"ja": "比率 %.2f（フォロワー %d / フォロー %d）— やや不均衡",
+	},
+	"Ratio %.2f (%d followers / %d following) — follows many, few follow back": {
+		"de": "Verhältnis %.2f (%d Follower / %d gefolgt) — folgt vielen, wenige folgen zurück",
+		"es": "Proporción %.2f (%d seguidores / %d seguidos) — sigue a muchos, pocos le siguen",
+		"ja": "比率 %.2f（フォロワー %d / フォロー %d）— 多数をフォローし、フォローバックは少ない",
+	},
+	"No event history — account age unknown": {
+		"de": "Kein Event-Verlauf — Kontoalter unbekannt",
+		"es": "Sin historial de eventos — antigüedad de la cuenta desconocida",
+		"ja": "イベント履歴なし — アカウントの年齢は不明",
+	},
+	"Account %.0f days old — established": {
+		"de": "Konto %.0f Tage alt — etabliert",
+		"es": "Cuenta de %.0f días — consolidada",
+		"ja": "作成から %.0f 日 — 定着したアカウント",
+	},
+	"Account %.0f days old — moderate age": {
+		"de": "Konto %.0f Tage alt — mittleres Alter",
+		"es": "Cuenta de %.0f días — antigüedad moderada",
+		"ja": "作成から %.0f 日 — 中程度の年齢",
+	},
+	"Account %.0f days old — relatively new": {
+		"de": "Konto %.0f Tage alt — relativ neu",
+		"es": "Cuenta de %.0f días — relativamente nueva",
+		"ja": "作成から %.0f 日 — 比較的新しい",
+	},
+	"Account %.0f days old — very new": {
+		"de": "Konto %.0f Tage alt — sehr neu",
+		"es": "Cuenta de %.0f días — muy nueva",
+		"ja": "作成から %.0f 日 — 非常に新しい",
+	},
+	"No posts or engagement — lurker (not necessarily spam)": {
+		"de": "Keine Beiträge oder Interaktionen — stiller Mitleser (nicht unbedingt Spam)",
+		"es": "Sin publicaciones ni interacción — observador (no necesariamente spam)",
+		"ja": "投稿も反応もなし — 閲覧専用（スパムとは限らない）",
+	},
+	"%d posts but 0 engagement received — one-way broadcasting": {
+		"de": "%d Beiträge, aber keine Interaktionen erhalten — Einweg-Sendung",
+		"es": "%d publicaciones pero 0 interacciones recibidas — difusión unidireccional",
+		"ja": "投稿 %d 件だが反応は0 — 一方的な発信",
+	},
+	"%d reactions + %d zaps received — well-engaged": {
+		"de": "%d Reaktionen + %d Zaps erhalten — gut vernetzt",
+		"es": "%d reacciones + %d zaps recibidos — buena interacción",
+		"ja": "リアクション %d 件 + zap %d 件を受信 — 反応が良い",
+	},
+	"%d reactions + %d zaps on %d posts — some engagement": {
+		"de": "%d Reaktionen + %d Zaps auf %d Beiträge — etwas Interaktion",
+		"es": "%d reacciones + %d zaps en %d publicaciones — algo de interacción",
+		"ja": "リアクション %d 件 + zap %d 件（投稿 %d 件）— ある程度の反応",
+	},
+	"No reports received": {
+		"de": "Keine Meldungen erhalten",
+		"es": "Sin denuncias recibidas",
+		"ja": "通報なし",
+	},
+	"%d reports received, trust-weighted %.2f — significant spam signal": {
+		"de": "%d Meldungen erhalten, vertrauensgewichtet %.2f — deutliches Spam-Signal",
+		"es": "%d denuncias recibidas, ponderadas por confianza %.2f — señal de spam significativa",
+		"ja": "通報 %d 件、信頼加重 %.2f — 重大なスパムシグナル",
+	},
+	"%d report(s) received, all from accounts with little trust — ignored": {
+		"de": "%d Meldung(en) erhalten, alle von Konten mit wenig Vertrauen — ignoriert",
+		"es": "%d denuncia(s) recibida(s), todas de cuentas con poca confianza — ignoradas",
+		"ja": "通報 %d 件、すべて信頼の低いアカウントから — 無視",
+	},
+	"%d report(s) received, trust-weighted %.2f — minor flag": {
+		"de": "%d Meldung(en) erhalten, vertrauensgewichtet %.2f — geringer Hinweis",
+		"es": "%d denuncia(s) recibida(s), ponderadas por confianza %.2f — señal menor",
+		"ja": "通報 %d 件、信頼加重 %.2f — 軽微なフラグ",
+	},
+	"Too few sampled notes to judge posting cadence": {
+		"de": "Zu wenige Beiträge in der Stichprobe, um den Rhythmus zu beurteilen",
+		"es": "Muy pocas notas en la muestra para juzgar el ritmo de publicación",
+		"ja": "投稿の間隔を判断するにはサンプルが少なすぎます",
+	},
+	"Posting cadence looks automated (%.0f%% automation likelihood)": {
+		"de": "Der Beitragsrhythmus wirkt automatisiert (Automatisierungswahrscheinlichkeit %.0f%%)",
+		"es": "El ritmo de publicación parece automatizado (probabilidad de automatización %.0f%%)",
+		"ja": "投稿の間隔は自動化されているようです（自動化の可能性 %.0f%%）",
+	},
+	"Some automated posting patterns (%.0f%% automation likelihood)": {
+		"de": "Einige automatisierte Beitragsmuster (Automatisierungswahrscheinlichkeit %.0f%%)",
+		"es": "Algunos patrones de publicación automatizados (probabilidad de automatización %.0f%%)",
+		"ja": "自動投稿らしいパターンがあります（自動化の可能性 %.0f%%）",
+	},
+	"Posting cadence looks human (%.0f%% automation likelihood)": {
+		"de": "Der Beitragsrhythmus wirkt menschlich (Automatisierungswahrscheinlichkeit %.0f%%)",
+		"es": "El ritmo de publicación parece humano (probabilidad de automatización %.0f%%)",
+		"ja": "投稿の間隔は人間らしいです（自動化の可能性 %.0f%%）",
+	},
+	"No activity — inactive account": {
+		"de": "Keine Aktivität — inaktives Konto",
+		"es": "Sin actividad — cuenta inactiva",
+		"ja": "活動なし — 非アクティブなアカウント",
+	},
+	"%d posts but no replies or reactions sent — broadcast-only pattern": {
+		"de": "%d Beiträge, aber keine Antworten oder Reaktionen gesendet — reines Senden",
+		"es": "%d publicaciones pero sin respuestas ni reacciones enviadas — patrón de solo difusión",
+		"ja": "投稿 %d 件だが返信もリアクションも送信なし — 発信のみのパターン",
+	},
+	"%.0f%% interaction rate (%d replies, %d reactions sent) — healthy mix": {
+		"de": "%.0f%% Interaktionsrate (%d Antworten, %d Reaktionen gesendet) — gesunde Mischung",
+		"es": "%.0f%% de tasa de interacción (%d respuestas, %d reacciones enviadas) — mezcla saludable",
+		"ja": "交流率 %.0f%%（返信 %d 件、リアクション %d 件を送信）— 健全なバランス",
+	},
+	"%.0f%% interaction rate — mostly posting, limited engagement": {
+		"de": "%.0f%% Interaktionsrate — überwiegend Beiträge, wenig Interaktion",
+		"es": "%.0f%% de tasa de interacción — sobre todo publica, poca interacción",
+		"ja": "交流率 %.0f%% — 主に投稿、交流は限定的",
+	},
+
+	// /reputation summary
+	"%s: Grade %s (%d/100) — WoT score %d, %s, community of %d": {
+		"de": "%s: Note %s (%d/100) — WoT-Score %d, %s, Community mit %d Mitgliedern",
+		"es": "%s: Nota %s (%d/100) — puntuación WoT %d, %s, comunidad de %d",
+		"ja": "%s: 評価 %s（%d/100）— WoTスコア %d、%s、コミュニティ %d 人",
+	},
+	"no anomalies":     {"de": "keine Auffälligkeiten", "es": "sin anomalías", "ja": "異常なし"},
+	"1 anomaly flag":   {"de": "1 Auffälligkeit", "es": "1 anomalía", "ja": "異常フラグ 1 件"},
+	"%d anomaly flags": {"de": "%d Auffälligkeiten", "es": "%d anomalías", "ja": "異常フラグ %d 件"},
+
+	// landing page
+	"WoT Scoring — Nostr Web of Trust": {
+		"de": "WoT Scoring — Nostr Web of Trust",
+		"es": "WoT Scoring — Web of Trust de Nostr",
+		"ja": "WoT Scoring — Nostr の信頼の網",
+	},
+	"NIP-85 Trusted Assertions for the Nostr Web of Trust": {
+		"de": "NIP-85 Trusted Assertions für das Nostr Web of Trust",
+		"es": "Afirmaciones de confianza NIP-85 para la Web of Trust de Nostr",
+		"ja": "Nostr の信頼の網のための NIP-85 Trusted Assertions",
+	},
+	"Nodes":          {"de": "Knoten", "es": "Nodos", "ja": "ノード"},
+	"Edges":          {"de": "Kanten", "es": "Aristas", "ja": "エッジ"},
+	"Events Scored":  {"de": "Bewertete Events", "es": "Eventos puntuados", "ja": "スコア付きイベント"},
+	"Articles":       {"de": "Artikel", "es": "Artículos", "ja": "記事"},
+	"Identifiers":    {"de": "Bezeichner", "es": "Identificadores", "ja": "識別子"},
+	"Uptime":         {"de": "Laufzeit", "es": "Tiempo activo", "ja": "稼働時間"},
+	"Score Lookup":   {"de": "Score-Abfrage", "es": "Consultar puntuación", "ja": "スコア検索"},
+	"Compare":        {"de": "Vergleichen", "es": "Comparar", "ja": "比較"},
+	"Trust Path":     {"de": "Vertrauenspfad", "es": "Ruta de confianza", "ja": "信頼パス"},
+	"NIP-05 Verify":  {"de": "NIP-05 prüfen", "es": "Verificar NIP-05", "ja": "NIP-05 検証"},
+	"NIP-05 Reverse": {"de": "NIP-05 rückwärts", "es": "NIP-05 inverso", "ja": "NIP-05 逆引き"},
+	"Timeline":       {"de": "Verlauf", "es": "Cronología", "ja": "タイムライン"},
+	"Spam Check":     {"de": "Spam-Prüfung", "es": "Comprobar spam", "ja": "スパム判定"},
+	"Trust Graph":    {"de": "Vertrauensgraph", "es": "Grafo de confianza", "ja": "信頼グラフ"},
+	"Enter npub or hex pubkey to look up trust score...": {
+		"de": "npub oder Hex-Pubkey eingeben, um den Vertrauens-Score abzufragen...",
+		"es": "Introduce una npub o pubkey hex para consultar su puntuación de confianza...",
+		"ja": "npub または hex pubkey を入力して信頼スコアを検索...",
+	},
+	"Compare two Nostr identities side-by-side to see their trust relationship": {
+		"de": "Zwei Nostr-Identitäten nebeneinander vergleichen und ihre Vertrauensbeziehung sehen",
+		"es": "Compara dos identidades de Nostr lado a lado para ver su relación de confianza",
+		"ja": "2つの Nostr アイデンティティを並べて信頼関係を比較",
+	},
+	"First pubkey or npub...":  {"de": "Erster Pubkey oder npub...", "es": "Primera pubkey o npub...", "ja": "1つ目の pubkey または npub..."},
+	"Second pubkey or npub...": {"de": "Zweiter Pubkey oder npub...", "es": "Segunda pubkey o npub...", "ja": "2つ目の pubkey または npub..."},
+	"Find the shortest trust path between two Nostr identities through the follow graph": {
+		"de": "Den kürzesten Vertrauenspfad zwischen zwei Nostr-Identitäten im Follow-Graphen finden",
+		"es": "Encuentra la ruta de confianza más corta entre dos identidades de Nostr en el grafo de seguimiento",
+		"ja": "フォローグラフを通じて2つの Nostr アイデンティティ間の最短の信頼パスを探す",
+	},
+	"From pubkey or npub...": {"de": "Von Pubkey oder npub...", "es": "Desde pubkey o npub...", "ja": "始点の pubkey または npub..."},
+	"To pubkey or npub...":   {"de": "Zu Pubkey oder npub...", "es": "Hasta pubkey o npub...", "ja": "終点の pubkey または npub..."},
+	"Find Trust Path":        {"de": "Vertrauenspfad finden", "es": "Buscar ruta de confianza", "ja": "信頼パスを探す"},
+	"Verify a NIP-05 identity and see their Web of Trust profile": {
+		"de": "Eine NIP-05-Identität prüfen und ihr Web-of-Trust-Profil ansehen",
+		"es": "Verifica una identidad NIP-05 y consulta su perfil de Web of Trust",
+		"ja": "NIP-05 アイデンティティを検証し、信頼の網のプロフィールを見る",
+	},
+	"Enter NIP-05 identifier (e.g. user@domain.com)...": {
+		"de": "NIP-05-Bezeichner eingeben (z. B. user@domain.com)...",
+		"es": "Introduce un identificador NIP-05 (p. ej. user@domain.com)...",
+		"ja": "NIP-05 識別子を入力（例: user@domain.com）...",
+	},
+	"Find the NIP-05 identity for a pubkey (reverse lookup). Fetches profile from relays and verifies bidirectionally.": {
+		"de": "Die NIP-05-Identität eines Pubkeys finden (Rückwärtssuche). Lädt das Profil von Relays und prüft in beide Richtungen.",
+		"es": "Encuentra la identidad NIP-05 de una pubkey (búsqueda inversa). Obtiene el perfil de los relays y lo verifica en ambos sentidos.",
+		"ja": "pubkey の NIP-05 アイデンティティを探す（逆引き）。リレーからプロフィールを取得し、双方向で検証します。",
+	},
+	"Enter hex pubkey or npub to find NIP-05 identity...": {
+		"de": "Hex-Pubkey oder npub eingeben, um die NIP-05-Identität zu finden...",
+		"es": "Introduce una pubkey hex o npub para encontrar su identidad NIP-05...",
+		"ja": "hex pubkey または npub を入力して NIP-05 アイデンティティを探す...",
+	},
+	"See how a pubkey's trust grew over time. Uses follow timestamps to reconstruct trust evolution.": {
+		"de": "Sehen, wie das Vertrauen in einen Pubkey gewachsen ist. Rekonstruiert den Verlauf aus Follow-Zeitstempeln.",
+		"es": "Mira cómo creció la confianza de una pubkey con el tiempo. Usa las fechas de seguimiento para reconstruir su evolución.",
+		"ja": "pubkey の信頼がどのように育ったかを見る。フォローのタイムスタンプから信頼の推移を再構成します。",
+	},
+	"Enter hex pubkey or npub to see trust timeline...": {
+		"de": "Hex-Pubkey oder npub eingeben, um den Vertrauensverlauf zu sehen...",
+		"es": "Introduce una pubkey hex o npub para ver su cronología de confianza...",
+		"ja": "hex pubkey または npub を入力して信頼の推移を見る...",
+	},
+	"Analyze a pubkey for spam indicators using WoT graph signals, engagement metrics, and behavioral patterns.": {
+		"de": "Einen Pubkey anhand von WoT-Graphsignalen, Interaktionswerten und Verhaltensmustern auf Spam-Merkmale prüfen.",
+		"es": "Analiza una pubkey en busca de indicadores de spam con señales del grafo WoT, métricas de interacción y patrones de comportamiento.",
+		"ja": "WoT グラフのシグナル、反応の指標、行動パターンから pubkey のスパム兆候を分析します。",
+	},
+	"Enter hex pubkey or npub to check for spam...": {
+		"de": "Hex-Pubkey oder npub eingeben, um auf Spam zu prüfen...",
+		"es": "Introduce una pubkey hex o npub para comprobar si es spam...",
+		"ja": "hex pubkey または npub を入力してスパムを判定...",
+	},
+	"Visualize a pubkey's trust network — who they follow, who follows them, and mutual connections.": {
+		"de": "Das Vertrauensnetz eines Pubkeys darstellen — wem er folgt, wer ihm folgt und gegenseitige Verbindungen.",
+		"es": "Visualiza la red de confianza de una pubkey — a quién sigue, quién la sigue y las conexiones mutuas.",
+		"ja": "pubkey の信頼ネットワークを可視化 — フォロー、フォロワー、相互のつながり。",
+	},
+	"Enter hex pubkey or npub to visualize trust network...": {
+		"de": "Hex-Pubkey oder npub eingeben, um das Vertrauensnetz darzustellen...",
+		"es": "Introduce una pubkey hex o npub para visualizar su red de confianza...",
+		"ja": "hex pubkey または npub を入力して信頼ネットワークを可視化...",
+	},
+	"Trust Leaderboard": {"de": "Vertrauens-Rangliste", "es": "Clasificación de confianza", "ja": "信頼ランキング"},
+	"Rank":              {"de": "Rang", "es": "Puesto", "ja": "順位"},
+	"Score":             {"de": "Score", "es": "Puntuación", "ja": "スコア"},
+	"Followers":         {"de": "Follower", "es": "Seguidores", "ja": "フォロワー"},
+	"Loading...":        {"de": "Wird geladen...", "es": "Cargando...", "ja": "読み込み中..."},
+	"Trust Communities": {"de": "Vertrauens-Communities", "es": "Comunidades de confianza", "ja": "信頼コミュニティ"},
+	"Clusters detected via label propagation over the follow graph": {
+		"de": "Cluster, per Label Propagation im Follow-Graphen erkannt",
+		"es": "Grupos detectados mediante propagación de etiquetas en el grafo de seguimiento",
+		"ja": "フォローグラフ上のラベル伝播で検出したクラスタ",
+	},
+	"Loading communities...": {"de": "Communities werden geladen...", "es": "Cargando comunidades...", "ja": "コミュニティを読み込み中..."},
+	"NIP-85 Event Kinds":     {"de": "NIP-85-Event-Kinds", "es": "Tipos de evento NIP-85", "ja": "NIP-85 イベント種別"},
+	"API Endpoints":          {"de": "API-Endpunkte", "es": "Endpoints de la API", "ja": "API エンドポイント"},
+	"L402 Lightning Paywall": {"de": "L402-Lightning-Bezahlschranke", "es": "Muro de pago Lightning L402", "ja": "L402 Lightning ペイウォール"},
+	"API Docs":               {"de": "API-Dokumentation", "es": "Documentación de la API", "ja": "API ドキュメント"},
+	"API Explorer":           {"de": "API-Explorer", "es": "Explorador de la API", "ja": "API エクスプローラー"},
+	"OpenAPI Spec":           {"de": "OpenAPI-Spezifikation", "es": "Especificación OpenAPI", "ja": "OpenAPI 仕様"},
+	"Source (MIT)":           {"de": "Quellcode (MIT)", "es": "Código fuente (MIT)", "ja": "ソースコード（MIT）"},
+
+	// /docs
+	"API Documentation — WoT Scoring": {"de": "API-Dokumentation — WoT Scoring", "es": "Documentación de la API — WoT Scoring", "ja": "API ドキュメント — WoT Scoring"},
+	"WoT Scoring API":                 {"de": "WoT Scoring API", "es": "API de WoT Scoring", "ja": "WoT Scoring API"},
+	"Complete reference for the Nostr Web of Trust scoring service": {
+		"de": "Vollständige Referenz des Nostr-Web-of-Trust-Scoring-Dienstes",
+		"es": "Referencia completa del servicio de puntuación Web of Trust de Nostr",
+		"ja": "Nostr 信頼の網スコアリングサービスの完全なリファレンス",
+	},
+	"Authentication &amp; Pricing": {"de": "Authentifizierung &amp; Preise", "es": "Autenticación y precios", "ja": "認証と料金"},
+	"Parameters":                   {"de": "Parameter", "es": "Parámetros", "ja": "パラメータ"},
+	"Request Body (JSON)":          {"de": "Request-Body (JSON)", "es": "Cuerpo de la solicitud (JSON)", "ja": "リクエストボディ（JSON）"},
+	"Response":                     {"de": "Antwort", "es": "Respuesta", "ja": "レスポンス"},
+	"Example":                      {"de": "Beispiel", "es": "Ejemplo", "ja": "例"},
+	"Try it":                       {"de": "Ausprobieren", "es": "Probar", "ja": "試す"},
+	"Scoring":                      {"de": "Scoring", "es": "Puntuación", "ja": "スコアリング"},
+	"Personalized":                 {"de": "Personalisiert", "es": "Personalizado", "ja": "パーソナライズ"},
+	"Graph":                        {"de": "Graph", "es": "Grafo", "ja": "グラフ"},
+	"Identity":                     {"de": "Identität", "es": "Identidad", "ja": "アイデンティティ"},
+	"Temporal":                     {"de": "Zeitlich", "es": "Temporal", "ja": "時系列"},
+	"Moderation":                   {"de": "Moderation", "es": "Moderación", "ja": "モデレーション"},
+	"Verification":                 {"de": "Verifizierung", "es": "Verificación", "ja": "検証"},
+	"Sybil Resistance":             {"de": "Sybil-Resistenz", "es": "Resistencia Sybil", "ja": "Sybil 耐性"},
+	"Trust Paths":                  {"de": "Vertrauenspfade", "es": "Rutas de confianza", "ja": "信頼パス"},
+	"Reputation":                   {"de": "Reputation", "es": "Reputación", "ja": "評判"},
+	"Link Prediction":              {"de": "Link-Vorhersage", "es": "Predicción de enlaces", "ja": "リンク予測"},
+	"Influence Analysis":           {"de": "Einflussanalyse", "es": "Análisis de influencia", "ja": "影響力分析"},
+	"Trust Circles":                {"de": "Vertrauenskreise", "es": "Círculos de confianza", "ja": "信頼サークル"},
+	"Follow Quality":               {"de": "Follow-Qualität", "es": "Calidad de seguimiento", "ja": "フォローの質"},
+	"Network Health":               {"de": "Netzwerkgesundheit", "es": "Salud de la red", "ja": "ネットワークの健全性"},
+	"Cross-Provider Comparison":    {"de": "Anbietervergleich", "es": "Comparación entre proveedores", "ja": "プロバイダ間比較"},
+	"Real-Time Streaming":          {"de": "Echtzeit-Streaming", "es": "Streaming en tiempo real", "ja": "リアルタイム配信"},
+	"Engagement":                   {"de": "Interaktion", "es": "Interacción", "ja": "エンゲージメント"},
+	"Ranking":                      {"de": "Ranking", "es": "Clasificación", "ja": "ランキング"},
+	"Infrastructure":               {"de": "Infrastruktur", "es": "Infraestructura", "ja": "インフラ"},
+}
+
+// tr renders format in locale: its translation when the catalog has one, else
+// the English, formatted with args when there are any.
+func tr(locale, format string, args ...interface{}) string {
+	if t, ok := catalog[format][locale]; ok {
+		format = t
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// message is user-facing text kept as its English format and arguments, so a
+// response computed once can be rendered in the requester's locale.
+type message struct {
+	format string
+	args   []interface{}
+}
+
+func msg(format string, args ...interface{}) message {
+	return message{format: format, args: args}
+}
+
+// In renders m in locale.
+func (m message) In(locale string) string {
+	return tr(locale, m.format, m.args...)
+}
+
+// localeBase reduces a language tag such as "es-MX" or "ja_JP" to a supported
+// locale, or "" when there is none.
+func localeBase(tag string) string {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	base, _, _ = strings.Cut(base, "_")
+	if slices.Contains(locales, base) {
+		return base
+	}
+	return ""
+}
+
+// acceptedLocale picks the supported locale the Accept-Language header prefers
+// most, by q-value and then order, or "en".
+func acceptedLocale(header string) string {
+	type choice struct {
+		locale string
+		q      float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		locale := localeBase(tag)
+		if locale == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			choices = append(choices, choice{locale, q})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	if len(choices) == 0 {
+		return "en"
+	}
+	return choices[0].locale
+}
+
+// requestLocale negotiates r's locale: ?lang= when given, otherwise the
+// Accept-Language header, otherwise English. It sets Content-Language, and
+// writes a 400 and returns false for an unsupported ?lang=.
+func requestLocale(w http.ResponseWriter, r *http.Request) (string, bool) {
+	w.Header().Add("Vary", "Accept-Language")
+	locale := acceptedLocale(r.Header.Get("Accept-Language"))
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		if locale = localeBase(lang); locale == "" {
+			writeAPIError(w, invalidParam("lang", "lang must be one of %s", strings.Join(locales, ", ")))
+			return "", false
+		}
+	}
+	w.Header().Set("Content-Language", locale)
+	return locale, true
+}
+
+// htmlLocalizers replace the page text in the catalog, as element text or a
+// placeholder, with each locale's translation. Formats are left out: pages
+// such as the landing page are fmt templates themselves.
+var htmlLocalizers = func() map[string]*strings.Replacer {
+	out := make(map[string]*strings.Replacer)
+	for _, locale := range locales[1:] {
+		pairs := []string{`<html lang="en">`, `<html lang="` + locale + `">`}
+		for en, translations := range catalog {
+			t, ok := translations[locale]
+			if !ok || strings.Contains(en, "%") {
+				continue
+			}
+			pairs = append(pairs, ">"+en+"<", ">"+t+"<", `placeholder="`+en+`"`, `placeholder="`+t+`"`)
+		}
+		out[locale] = strings.NewReplacer(pairs...)
+	}
+	return out
+}()
+
+// localizeHTML translates page into locale. Text the catalog doesn't cover,
+// such as endpoint descriptions, stays English.
+func localizeHTML(page, locale string) string {
+	if l, ok := htmlLocalizers[locale]; ok {
+		return l.Replace(page)
+	}
+	return page
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRequestLocale(t *testing.T) {
+	for name, tc := range map[string]struct {
+		query, accept string
+		want          string
+		status        int
+	}{
+		"default":          {want: "en"},
+		"accept-language":  {accept: "ja-JP,ja;q=0.9,en;q=0.8", want: "ja"},
+		"q-values":         {accept: "fr;q=1, en;q=0.3, de;q=0.7", want: "de"},
+		"refused locale":   {accept: "es;q=0, en;q=0.1", want: "en"},
+		"unsupported only": {accept: "fr-CA, zh", want: "en"},
+		"lang wins":        {query: "?lang=es", accept: "de", want: "es"},
+		"regional lang":    {query: "?lang=es-MX", want: "es"},
+		"unsupported lang": {query: "?lang=fr", status: http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/spam"+tc.query, nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept-Language", tc.accept)
+		}
+		locale, ok := requestLocale(w, r)
+		if tc.status != 0 {
+			if ok || w.Code != tc.status || !strings.Contains(w.Body.String(), "lang must be one of en, de, es, ja") {
+				t.Errorf("%s: expected %d, got %d %s", name, tc.status, w.Code, w.Body.String())
+			}
+			continue
+		}
+		if !ok || locale != tc.want || w.Header().Get("Content-Language") != tc.want || w.Header().Get("Vary") != "Accept-Language" {
+			t.Errorf("%s: expected %s, got %s %v %v", name, tc.want, locale, ok, w.Header())
+		}
+	}
+}
+
+func TestTr(t *testing.T) {
+	if got := tr("de", "%d anomaly flags", 3); got != "3 Auffälligkeiten" {
+		t.Errorf("unexpected %q", got)
+	}
+	if got := tr("ja", "WoT score %d (top %.0f%%) — highly trusted", 90, 5.0); got != "WoTスコア 90（上位 5%）— 非常に信頼できる" {
+		t.Errorf("unexpected %q", got)
+	}
+	if got := tr("es", "No translation for %s", "this"); got != "No translation for this" {
+		t.Errorf("expected the English fallback, got %q", got)
+	}
+	if got := msg("Account %.0f days old — very new", 3.0).In("en"); got != "Account 3 days old — very new" {
+		t.Errorf("unexpected %q", got)
+	}
+}
+
+// TestCatalogKeepsVerbs checks every translation takes the arguments its
+// English does, in the same order.
+func TestCatalogKeepsVerbs(t *testing.T) {
+	verb := regexp.MustCompile(`%%|%[-+# 0]*[0-9]*(\.[0-9]+)?[a-z]`)
+	for en, translations := range catalog {
+		want := fmt.Sprint(verb.FindAllString(en, -1))
+		for locale, tl := range translations {
+			if got := fmt.Sprint(verb.FindAllString(tl, -1)); got != want {
+				t.Errorf("%s %q: verbs %s, want %s", locale, tl, got, want)
+			}
+		}
+	}
+}
+
+func TestSpamLocalized(t *testing.T) {
+	old := graph
+	t.Cleanup(func() { graph = old })
+	graph = NewGraph()
+
+	for _, tc := range []struct {
+		query, accept, summary, reason string
+	}{
+		{"&lang=ja", "", "スパムのリスクは中程度。", "WoTグラフにpubkeyがありません"},
+		{"", "de-AT,de;q=0.9", "Mittleres Spam-Risiko.", "Pubkey nicht im WoT-Graphen"},
+		{"", "", "Moderate spam risk.", "Pubkey not found in WoT graph"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/spam?pubkey="+padHex(51900)+tc.query, nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept-Language", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		handleSpam(w, r)
+		var resp SpamResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(resp.Summary, tc.summary) || !strings.HasPrefix(resp.Signals[0].Reason, tc.reason) {
+			t.Errorf("%s%s: unexpected %q / %q", tc.query, tc.accept, resp.Summary, resp.Signals[0].Reason)
+		}
+		if resp.Classification != "suspicious" {
+			t.Errorf("expected the classification left alone, got %q", resp.Classification)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handleSpam(w, httptest.NewRequest(http.MethodGet, "/spam?pubkey="+padHex(51900)+"&lang=xx", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unsupported lang, got %d", w.Code)
+	}
+}
+
+func TestTrustLevelLabel(t *testing.T) {
+	if got := trustLevelLabel("es", "highly_trusted"); got != "Muy confiable" {
+		t.Errorf("unexpected %q", got)
+	}
+	if got := trustLevelLabel("en", "unknown"); got != "Unknown" {
+		t.Errorf("unexpected %q", got)
+	}
+}
+
+func TestLocalizeHTML(t *testing.T) {
+	if localizeHTML(docsPageHTML, "en") != docsPageHTML {
+		t.Error("expected English pages unchanged")
+	}
+	docs := localizeHTML(docsPageHTML, "de")
+	if !strings.Contains(docs, `<html lang="de">`) || !strings.Contains(docs, ">Parameter<") || !strings.Contains(docs, ">Ausprobieren<") {
+		t.Error("expected the docs page in German")
+	}
+	landing := fmt.Sprintf(localizeHTML(landingPageHTML, "ja"), 1, 2, 3, 4, 5, "1s")
+	if !strings.Contains(landing, `<html lang="ja">`) || !strings.Contains(landing, ">スコア検索<") ||
+		!strings.Contains(landing, `placeholder="npub または hex pubkey を入力して信頼スコアを検索..."`) {
+		t.Error("expected the landing page in Japanese")
+	}
+	if strings.Contains(landing, "%!") {
+		t.Error("expected the localized landing page to stay a valid template")
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"log"
+	"maps"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// incrementalTolerance is the residual, relative to the teleport share (1-d)/n,
+	// below which a score change stops propagating.
+	incrementalTolerance = 1e-3
+	// incrementalMaxPushes bounds the work one live contact list can cause; what is
+	// left unpropagated counts as drift.
+	incrementalMaxPushes = 50000
+)
+
+// incrementalRebuildAfter is how many contact lists may be applied incrementally
+// before a rescore corrects the drift they add up to; 0 leaves it to the scheduled
+// rebuilds. INCREMENTAL_REBUILD_AFTER overrides it.
+var incrementalRebuildAfter = func() int {
+	if n, err := strconv.Atoi(os.Getenv("INCREMENTAL_REBUILD_AFTER")); err == nil && n >= 0 {
+		return n
+	}
+	return 2000
+}()
+
+// IncrementalDrift summarizes the incremental updates applied since scores were
+// last computed in full.
+type IncrementalDrift struct {
+	Updates    int       `json:"updates"`
+	Pushes     int       `json:"pushes"`
+	Rescored   int       `json:"rescored"`  // score changes, counting a pubkey once per update
+	Truncated  int       `json:"truncated"` // updates that hit the push limit
+	Residual   float64   `json:"residual"`  // score mass left unpropagated, an error bound on the scores
+	LastUpdate time.Time `json:"last_update,omitempty"`
+}
+
+// pushResult is the outcome of pushResidual.
+type pushResult struct {
+	delta     map[string]float64
+	pushes    int
+	residual  float64 // total |residual| left below the tolerance or past the limit
+	converged bool
+}
+
+// pushResidual propagates score changes along follows, forward-push style: a node's
+// residual is moved into its delta and damping times it is split evenly among the
+// accounts it follows, until no residual is above eps or maxPushes is reached.
+// Nodes are taken in queue order starting from the sorted seeds, so the result is
+// deterministic.
+func pushResidual(residual map[string]float64, follows func(pk string) []string, damping, eps float64, maxPushes int) pushResult {
+	res := pushResult{delta: make(map[string]float64)}
+	var queue []string
+	queued := make(map[string]bool)
+	for pk, r := range residual {
+		if math.Abs(r) > eps {
+			queue = append(queue, pk)
+			queued[pk] = true
+		}
+	}
+	sort.Strings(queue)
+	for len(queue) > 0 && res.pushes < maxPushes {
+		pk := queue[0]
+		queue = queue[1:]
+		queued[pk] = false
+		r := residual[pk]
+		residual[pk] = 0
+		res.delta[pk] += r
+		res.pushes++
+		targets := follows(pk)
+		if len(targets) == 0 {
+			continue
+		}
+		share := damping * r / float64(len(targets))
+		for _, t := range targets {
+			residual[t] += share
+			if !queued[t] && math.Abs(residual[t]) > eps {
+				queue = append(queue, t)
+				queued[t] = true
+			}
+		}
+	}
+	res.converged = len(queue) == 0
+	for _, r := range residual {
+		res.residual += math.Abs(r)
+	}
+	return res
+}
+
+// IncrementalUpdate is the outcome of one PushScores call.
+type IncrementalUpdate struct {
+	Rescored  int
+	Pushes    int
+	Converged bool
+}
+
+// PushScores updates scores after author's follows changed by added and removed,
+// without a full recompute. The current scores are taken as PageRank of the graph
+// before the change: the author's new out-degree moves the share each current,
+// former and new follow received, and that residual is pushed along follows until
+// it fades out, so only the part of the graph the change reaches is visited.
+// Pubkeys new to the graph shrink the teleport share (1-d)/n; PageRank is linear
+// in it, so the other scores are scaled by n/n'. Only the touched scores are
+// written; the score maps are copied first when a snapshot or export shares them,
+// so a burst of updates between two snapshots copies them once.
+func (g *Graph) PushScores(author string, added, removed []string, damping float64) IncrementalUpdate {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.changed()
+
+	var fresh []string
+	for _, pk := range append([]string{author}, added...) {
+		if _, ok := g.scores[pk]; !ok {
+			fresh = append(fresh, pk)
+		}
+	}
+	n := len(g.scores) + len(fresh)
+	if n == 0 {
+		return IncrementalUpdate{Converged: true}
+	}
+	scale := 1.0
+	if len(g.scores) > 0 {
+		scale = float64(len(g.scores)) / float64(n)
+	}
+	base := (1 - damping) / float64(n)
+
+	residual := make(map[string]float64)
+	for _, pk := range fresh {
+		residual[pk] += base
+	}
+	p := scale * g.scores[author]
+	now := len(g.follows[author])
+	old := now - len(added) + len(removed)
+	isAdded := make(map[string]bool, len(added))
+	for _, pk := range added {
+		isAdded[pk] = true
+	}
+	for _, t := range g.follows[author] {
+		switch {
+		case isAdded[t]:
+			residual[t] += damping * p / float64(now)
+		case old > 0:
+			residual[t] += damping * p * (1/float64(now) - 1/float64(old))
+		}
+	}
+	if old > 0 {
+		for _, t := range removed {
+			residual[t] -= damping * p / float64(old)
+		}
+	}
+
+	res := pushResidual(residual, func(pk string) []string { return g.follows[pk] }, damping,
+		incrementalTolerance*base, incrementalMaxPushes)
+
+	at := time.Now()
+	if g.scoresShared.Swap(false) {
+		scores := make(map[string]float64, n)
+		for pk, s := range g.scores {
+			scores[pk] = scale * s
+		}
+		g.scores = scores
+		g.scoreUpdated = maps.Clone(g.scoreUpdated)
+	} else if scale != 1 {
+		for pk, s := range g.scores {
+			g.scores[pk] = scale * s
+		}
+	}
+	if g.scoreUpdated == nil {
+		g.scoreUpdated = make(map[string]time.Time, len(res.delta))
+	}
+	rescored := 0
+	for pk, d := range res.delta {
+		if d == 0 {
+			continue
+		}
+		g.scores[pk] = max(g.scores[pk]+d, 0)
+		g.scoreUpdated[pk] = at
+		rescored++
+	}
+
+	g.drift.Updates++
+	g.drift.Pushes += res.pushes
+	g.drift.Rescored += rescored
+	g.drift.Residual += res.residual
+	g.drift.LastUpdate = at
+	if !res.converged {
+		g.drift.Truncated++
+	}
+	return IncrementalUpdate{Rescored: rescored, Pushes: res.pushes, Converged: res.converged}
+}
+
+// setComputedScores installs scores from a full computation, which clears the
+// drift of the incremental updates before it. Callers hold g.mu for writing.
+func (g *Graph) setComputedScores(scores map[string]float64) {
+	g.changed()
+	g.scores = scores
+	g.lastBuild = time.Now()
+	g.scoreUpdated = nil
+	g.drift = IncrementalDrift{}
+}
+
+// Drift returns the incremental updates applied since the last full computation.
+func (g *Graph) Drift() IncrementalDrift {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.drift
+}
+
+// ScoreStaleness says how current one pubkey's score is.
+type ScoreStaleness struct {
+	Source        string  `json:"source"` // full_rebuild, or incremental when a live contact list changed it since
+	ComputedAt    string  `json:"computed_at"`
+	UpdatedAt     string  `json:"updated_at,omitempty"`
+	AgeSeconds    int64   `json:"age_seconds"` // since the score last changed
+	PendingDrift  int     `json:"updates_since_rebuild"`
+	DriftResidual float64 `json:"drift_residual"`
+}
+
+// Staleness reports when pubkey's score was last computed in full and whether a
+// live update has changed it since, along with the drift accumulated across the
+// whole graph until the next full computation.
+func (g *Graph) Staleness(pubkey string, now time.Time) ScoreStaleness {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	s := ScoreStaleness{
+		Source:        "full_rebuild",
+		ComputedAt:    g.lastBuild.UTC().Format(time.RFC3339),
+		PendingDrift:  g.drift.Updates,
+		DriftResidual: g.drift.Residual,
+	}
+	changed := g.lastBuild
+	if t, ok := g.scoreUpdated[pubkey]; ok {
+		s.Source = "incremental"
+		s.UpdatedAt = t.UTC().Format(time.RFC3339)
+		changed = t
+	}
+	if !changed.IsZero() {
+		s.AgeSeconds = int64(now.Sub(changed).Seconds())
+	}
+	return s
+}
+
+// rescoreForDrift starts a rescore once the incremental updates since the last
+// full computation reach incrementalRebuildAfter. A rebuild already running will
+// replace the scores anyway.
+func rescoreForDrift(d IncrementalDrift) {
+	if incrementalRebuildAfter == 0 || d.Updates < incrementalRebuildAfter || graphSharing.Replica() {
+		return
+	}
+	err := rebuilder.StartPhases(context.Background(), "drift", func(name string) bool { return rescorePhases[name] })
+	if err == nil {
+		log.Printf("Rescoring after %d incremental updates (residual %.3g)", d.Updates, d.Residual)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+// buildIncrementalGraph is a ring of 30 accounts that each also follow a hub,
+// fully scored.
+func buildIncrementalGraph() *Graph {
+	g := NewGraph()
+	hub := padHex(49200)
+	for i := 0; i < 30; i++ {
+		pk := padHex(49210 + i)
+		g.AddFollow(pk, hub)
+		g.AddFollow(pk, padHex(49210+(i+1)%30))
+		if i%3 == 0 {
+			g.AddFollow(hub, pk)
+		}
+	}
+	g.ComputePageRank(200, 0.85)
+	return g
+}
+
+func TestPushScoresMatchesRecompute(t *testing.T) {
+	g := buildIncrementalGraph()
+	author, fresh := padHex(49215), padHex(49299)
+	before := g.ScoresSnapshot()
+
+	// drop the hub, keep the ring, add two accounts and one new to the graph
+	added, removed, _ := g.SetFollows(author, []string{padHex(49216), padHex(49225), padHex(49230), fresh}, time.Now())
+	u := g.PushScores(author, added, removed, 0.85)
+	if !u.Converged || u.Rescored == 0 {
+		t.Fatalf("unexpected update %+v", u)
+	}
+	got := g.ScoresSnapshot()
+
+	want := buildIncrementalGraph()
+	want.SetFollows(author, []string{padHex(49216), padHex(49225), padHex(49230), fresh}, time.Now())
+	want.ComputePageRank(200, 0.85)
+	for pk, w := range want.ScoresSnapshot() {
+		if math.Abs(got[pk]-w) > 0.005*w {
+			t.Errorf("%s: incremental %.6f, recomputed %.6f", pk[len(pk)-5:], got[pk], w)
+		}
+	}
+	if got[padHex(49200)] >= before[padHex(49200)] || got[padHex(49225)] <= before[padHex(49225)] {
+		t.Errorf("expected the hub to lose and the new follow to gain")
+	}
+
+	d := g.Drift()
+	if d.Updates != 1 || d.Pushes != u.Pushes || d.Rescored != u.Rescored || d.Truncated != 0 {
+		t.Errorf("unexpected drift %+v", d)
+	}
+	if s := g.Staleness(padHex(49225), time.Now()); s.Source != "incremental" || s.UpdatedAt == "" || s.PendingDrift != 1 {
+		t.Errorf("expected the new follow's score marked incremental, got %+v", s)
+	}
+
+	g.ComputePageRank(20, 0.85)
+	if d := g.Drift(); d.Updates != 0 {
+		t.Errorf("expected a full computation to clear the drift, got %+v", d)
+	}
+	if s := g.Staleness(padHex(49225), time.Now()); s.Source != "full_rebuild" || s.UpdatedAt != "" {
+		t.Errorf("expected the score from the full computation, got %+v", s)
+	}
+}
+
+func TestPushScoresLeavesSnapshots(t *testing.T) {
+	g := buildIncrementalGraph()
+	author := padHex(49212)
+	s := g.Snapshot()
+	score, _ := s.GetScore(padHex(49200))
+
+	_, removed, _ := g.SetFollows(author, []string{padHex(49213)}, time.Now())
+	g.PushScores(author, nil, removed, 0.85)
+	if got, _ := s.GetScore(padHex(49200)); got != score {
+		t.Errorf("expected the snapshot's score unchanged, got %v -> %v", score, got)
+	}
+	if st := s.Staleness(padHex(49200), time.Now()); st.Source != "full_rebuild" {
+		t.Errorf("expected the snapshot's staleness unchanged, got %+v", st)
+	}
+
+	// later updates write in place, but never into maps a snapshot or export holds
+	next := g.Snapshot()
+	data := g.exportData()
+	score, _ = next.GetScore(padHex(49200))
+	added, _, _ := g.SetFollows(author, []string{padHex(49213), padHex(49200)}, time.Now().Add(time.Second))
+	g.PushScores(author, added, nil, 0.85)
+	if got, _ := next.GetScore(padHex(49200)); got != score || data.Scores[padHex(49200)] != score {
+		t.Errorf("expected the second snapshot and the export unchanged, got %v and %v -> %v", score, data.Scores[padHex(49200)], got)
+	}
+	if live, _ := g.GetScore(padHex(49200)); live <= score {
+		t.Errorf("expected the refollowed hub to gain, got %v -> %v", score, live)
+	}
+}
+
+// BenchmarkPushScores applies contact list changes to a 50,000 node graph, with
+// and without a snapshot taken between updates.
+func BenchmarkPushScores(b *testing.B) {
+	const nodes = 50000
+	g := NewGraph()
+	for i := 0; i < nodes; i++ {
+		for _, j := range []int{i + 1, i * 7, i * 31} {
+			g.AddFollow(padHex(600000+i), padHex(600000+j%nodes))
+		}
+	}
+	g.ComputePageRank(20, 0.85)
+
+	for _, snapshots := range []bool{false, true} {
+		name := "no_readers"
+		if snapshots {
+			name = "snapshot_each"
+		}
+		b.Run(name, func(b *testing.B) {
+			at := time.Now()
+			for i := 0; i < b.N; i++ {
+				author := padHex(600000 + i%nodes)
+				follows := []string{padHex(600000 + (i+1)%nodes), padHex(600000 + (i*13)%nodes)}
+				at = at.Add(time.Second)
+				added, removed, _ := g.SetFollows(author, follows, at)
+				g.PushScores(author, added, removed, 0.85)
+				if snapshots {
+					g.Snapshot()
+				}
+			}
+		})
+	}
+}
+
+func TestRescoreForDrift(t *testing.T) {
+	oldRebuilder, oldAfter := rebuilder, incrementalRebuildAfter
+	t.Cleanup(func() { rebuilder, incrementalRebuildAfter = oldRebuilder, oldAfter })
+	ran := make(chan struct{}, 1)
+	rebuilder = NewRebuildController(func() []RebuildPhase {
+		return []RebuildPhase{
+			{Name: "crawl_follows", Weight: 1, Run: func(ctx context.Context, _ func(float64)) { t.Error("a drift rescore must not crawl") }},
+			{Name: "pagerank", Weight: 1, Run: func(ctx context.Context, _ func(float64)) { ran <- struct{}{} }},
+		}
+	})
+	incrementalRebuildAfter = 3
+
+	rescoreForDrift(IncrementalDrift{Updates: 2})
+	if rebuilder.Status().State != "idle" {
+		t.Fatalf("expected no rescore below the limit")
+	}
+	rescoreForDrift(IncrementalDrift{Updates: 3})
+	rebuilder.Wait()
+	select {
+	case <-ran:
+	default:
+		t.Fatal("expected the pagerank phase to run")
+	}
+	if st := rebuilder.Status(); st.Trigger != "drift" {
+		t.Errorf("expected a drift-triggered rescore, got %+v", st)
+	}
+
+	incrementalRebuildAfter = 0
+	rescoreForDrift(IncrementalDrift{Updates: 1000})
+	if st := rebuilder.Status(); st.Runs != 1 {
+		t.Errorf("expected no rescore when disabled, got %d runs", st.Runs)
+	}
+}
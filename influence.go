@@ -43,15 +43,10 @@ type InfluenceSummary struct {
 }
 
 func handleInfluence(w http.ResponseWriter, r *http.Request) {
-	pubkeyRaw := r.URL.Query().Get("pubkey")
-	if pubkeyRaw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
-		return
-	}
-
-	pubkey, err := resolvePubkey(pubkeyRaw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid pubkey: %s"}`, err.Error()), http.StatusBadRequest)
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
 		return
 	}
 
@@ -60,34 +55,34 @@ func handleInfluence(w http.ResponseWriter, r *http.Request) {
 		action = "follow"
 	}
 	if action != "follow" && action != "unfollow" {
-		http.Error(w, `{"error":"action must be 'follow' or 'unfollow'"}`, http.StatusBadRequest)
+		writeAPIError(w, invalidParam("action", "action must be 'follow' or 'unfollow'"))
 		return
 	}
 
 	otherRaw := r.URL.Query().Get("other")
 	if otherRaw == "" {
-		http.Error(w, `{"error":"other parameter required (the pubkey that follows/unfollows)"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "other parameter required (the pubkey that follows/unfollows)"})
 		return
 	}
 
 	other, err := resolvePubkey(otherRaw)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid other: %s"}`, err.Error()), http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid other: %s", err.Error())})
 		return
 	}
 
 	if pubkey == other {
-		http.Error(w, `{"error":"pubkey and other must be different"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "pubkey and other must be different"})
 		return
 	}
 
-	stats := graph.Stats()
+	stats := g.Stats()
 
 	// Get current scores snapshot
-	currentScores := graph.ScoresSnapshot()
+	currentScores := g.ScoresSnapshot()
 
 	// Build a simulated graph with the hypothetical change
-	simFollows, simFollowers := graph.FollowsSnapshot()
+	simFollows, simFollowers := g.FollowsSnapshot()
 
 	if action == "follow" {
 		// Add other -> pubkey follow
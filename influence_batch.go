@@ -34,26 +34,26 @@ type InfluenceEntry struct {
 }
 
 func handleInfluenceBatch(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, `{"error":"POST required"}`, http.StatusMethodNotAllowed)
+	g := graph.Snapshot()
+	if !requireMethod(w, r, http.MethodPost) {
 		return
 	}
 
 	var req InfluenceBatchRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"invalid JSON body"}`, http.StatusBadRequest)
+	if apiErr := bindJSON(w, r, &req, 1<<20); apiErr != nil {
+		writeAPIError(w, apiErr)
 		return
 	}
 	if len(req.Pubkeys) == 0 {
-		http.Error(w, `{"error":"pubkeys array required"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "pubkeys", Message: "pubkeys array required"})
 		return
 	}
 	if len(req.Pubkeys) > 50 {
-		http.Error(w, `{"error":"maximum 50 pubkeys per batch"}`, http.StatusBadRequest)
+		writeAPIError(w, invalidParam("pubkeys", "maximum 50 pubkeys per batch"))
 		return
 	}
 
-	stats := graph.Stats()
+	stats := g.Stats()
 	results := make([]InfluenceEntry, 0, len(req.Pubkeys))
 
 	for _, raw := range req.Pubkeys {
@@ -63,12 +63,12 @@ func handleInfluenceBatch(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		rawScore, _ := graph.GetScore(pubkey)
+		rawScore, _ := g.GetScore(pubkey)
 		score := normalizeScore(rawScore, stats.Nodes)
-		followers := graph.GetFollowers(pubkey)
-		follows := graph.GetFollows(pubkey)
-		percentile := graph.Percentile(pubkey)
-		rank := graph.Rank(pubkey)
+		followers := g.GetFollowers(pubkey)
+		follows := g.GetFollows(pubkey)
+		percentile := g.Percentile(pubkey)
+		rank := g.Rank(pubkey)
 
 		// Build follows set for mutual detection
 		followSet := make(map[string]bool, len(follows))
@@ -81,7 +81,7 @@ func handleInfluenceBatch(w http.ResponseWriter, r *http.Request) {
 		scoredFollowers := 0
 		mutualCount := 0
 		for _, f := range followers {
-			fRaw, ok := graph.GetScore(f)
+			fRaw, ok := g.GetScore(f)
 			if ok {
 				followerScoreSum += float64(normalizeScore(fRaw, stats.Nodes))
 				scoredFollowers++
@@ -100,7 +100,7 @@ func handleInfluenceBatch(w http.ResponseWriter, r *http.Request) {
 		reachSet := make(map[string]bool, len(followers)*5)
 		for _, f := range followers {
 			reachSet[f] = true
-			for _, ff := range graph.GetFollowers(f) {
+			for _, ff := range g.GetFollowers(f) {
 				reachSet[ff] = true
 			}
 		}
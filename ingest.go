@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// ingestMaxEvents caps events per POST /ingest batch.
+	ingestMaxEvents = 500
+	// ingestMaxBody caps the request body; contact lists can hold thousands of p tags.
+	ingestMaxBody = 8 << 20
+	// ingestSeenTTL is how long an event id is remembered for deduplication.
+	ingestSeenTTL = 24 * time.Hour
+	// ingestFreshFor is how long a pushed contact list spares its author from the
+	// follow crawl: one scheduled re-crawl interval.
+	ingestFreshFor = 6 * time.Hour
+	// ingestMaxFutureSkew rejects events dated further ahead than this.
+	ingestMaxFutureSkew = 10 * time.Minute
+	// ingestMaxErrors bounds the per-event error list in a response.
+	ingestMaxErrors = 50
+)
+
+// ingestKinds are the event kinds partner relays may push: contact lists,
+// reactions, zap receipts, and reports.
+var ingestKinds = map[int]bool{3: true, 7: true, 9735: true, 1984: true}
+
+// IngestPartnerStats counts what one partner has pushed.
+type IngestPartnerStats struct {
+	Pubkey     string `json:"pubkey"`
+	Batches    int    `json:"batches"`
+	Accepted   int    `json:"accepted"`
+	Duplicates int    `json:"duplicates"`
+	Rejected   int    `json:"rejected"`
+	LastPush   string `json:"last_push,omitempty"`
+
+	lastPush time.Time
+}
+
+// IngestStore tracks partner relays allowed to push events, which event ids have
+// already been applied, and which authors' contact lists arrived by push recently.
+type IngestStore struct {
+	mu           sync.Mutex
+	partners     map[string]*IngestPartnerStats
+	seen         map[string]time.Time // event id -> when applied
+	contactLists map[string]time.Time // author -> when their contact list was last pushed
+	now          func() time.Time
+}
+
+func NewIngestStore(partners []string) *IngestStore {
+	s := &IngestStore{
+		partners:     make(map[string]*IngestPartnerStats),
+		seen:         make(map[string]time.Time),
+		contactLists: make(map[string]time.Time),
+		now:          time.Now,
+	}
+	for _, p := range partners {
+		s.partners[p] = &IngestPartnerStats{Pubkey: p}
+	}
+	return s
+}
+
+// NewIngestStoreFromEnv creates a store whose partners are the hex pubkeys or npubs
+// in INGEST_PARTNERS (comma-separated). Ingest is disabled when none are set.
+func NewIngestStoreFromEnv() *IngestStore {
+	var partners []string
+	for _, entry := range splitCommaList(os.Getenv("INGEST_PARTNERS")) {
+		if pk, err := resolvePubkey(entry); err == nil && hex64Pattern.MatchString(pk) {
+			partners = append(partners, pk)
+		}
+	}
+	return NewIngestStore(partners)
+}
+
+var ingestStore = NewIngestStoreFromEnv()
+
+// Enabled reports whether any partner is configured.
+func (s *IngestStore) Enabled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.partners) > 0
+}
+
+// IsPartner reports whether pubkey may push events.
+func (s *IngestStore) IsPartner(pubkey string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.partners[pubkey] != nil
+}
+
+// MarkSeen records an event id and returns false if it was already applied.
+func (s *IngestStore) MarkSeen(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if at, ok := s.seen[id]; ok && now.Sub(at) < ingestSeenTTL {
+		return false
+	}
+	s.seen[id] = now
+	return true
+}
+
+// MarkContactList records that author's contact list was just pushed.
+func (s *IngestStore) MarkContactList(author string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contactLists[author] = s.now()
+}
+
+// ContactListFresh reports whether author's contact list was pushed within
+// ingestFreshFor, in which case the follow crawl need not fetch it.
+func (s *IngestStore) ContactListFresh(author string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	at, ok := s.contactLists[author]
+	return ok && s.now().Sub(at) < ingestFreshFor
+}
+
+// recordBatch adds one batch's outcome to partner's counters.
+func (s *IngestStore) recordBatch(partner string, accepted, duplicates, rejected int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.partners[partner]
+	if p == nil {
+		return
+	}
+	p.Batches++
+	p.Accepted += accepted
+	p.Duplicates += duplicates
+	p.Rejected += rejected
+	p.lastPush = s.now()
+}
+
+// GC drops expired dedupe entries and stale contact list marks.
+func (s *IngestStore) GC() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for id, at := range s.seen {
+		if now.Sub(at) >= ingestSeenTTL {
+			delete(s.seen, id)
+		}
+	}
+	for pk, at := range s.contactLists {
+		if now.Sub(at) >= ingestFreshFor {
+			delete(s.contactLists, pk)
+		}
+	}
+}
+
+// Snapshot returns per-partner counters sorted by pubkey, for /stats.
+func (s *IngestStore) Snapshot() []IngestPartnerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]IngestPartnerStats, 0, len(s.partners))
+	for _, p := range s.partners {
+		st := *p
+		if !p.lastPush.IsZero() {
+			st.LastPush = p.lastPush.UTC().Format(time.RFC3339)
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Pubkey < out[j].Pubkey })
+	return out
+}
+
+// IngestError explains why one pushed event was rejected.
+type IngestError struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// IngestResponse is the response for POST /ingest.
+type IngestResponse struct {
+	Received      int           `json:"received"`
+	Accepted      int           `json:"accepted"`
+	Duplicates    int           `json:"duplicates"`
+	Rejected      int           `json:"rejected"`
+	ContactLists  int           `json:"contact_lists_applied"`
+	StaleContacts int           `json:"contact_lists_stale"`
+	Errors        []IngestError `json:"errors,omitempty"`
+}
+
+// ingestEvent validates one pushed event and applies it the way the crawler would.
+// It returns a rejection reason, or "" once the event is applied.
+func ingestEvent(ev *nostr.Event, resp *IngestResponse) string {
+	if !ingestKinds[ev.Kind] {
+		return fmt.Sprintf("unsupported kind %d", ev.Kind)
+	}
+	if !ev.CheckID() {
+		return "id does not match event hash"
+	}
+	if ok, err := ev.CheckSignature(); err != nil || !ok {
+		return "invalid signature"
+	}
+	if ev.CreatedAt.Time().After(ingestStore.now().Add(ingestMaxFutureSkew)) {
+		return "created_at in the future"
+	}
+	if !ingestStore.MarkSeen(ev.ID) {
+		resp.Duplicates++
+		return ""
+	}
+
+	switch ev.Kind {
+	case 3:
+		contactHistory.Record(ev, "ingest")
+		if _, _, _, ok := applyContactList(ev); ok {
+			resp.ContactLists++
+		} else {
+			resp.StaleContacts++
+		}
+		ingestStore.MarkContactList(ev.PubKey)
+	case 7:
+		meta.recordReaction(ev)
+	case 9735:
+		meta.recordZap(ev)
+	case 1984:
+		meta.recordReport(ev)
+	}
+	resp.Accepted++
+	return ""
+}
+
+// handleIngest serves POST /ingest: partner relays push batches of kind 3, 7, 9735
+// and 1984 events as {"events": [...]}, authenticated with NIP-98 by a pubkey listed
+// in INGEST_PARTNERS. Every event is signature-checked and deduplicated by id, then
+// applied with the same normalization as the crawler. Contact lists pushed within
+// the last crawl interval are not re-fetched by the follow crawl.
+func handleIngest(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !ingestStore.Enabled() {
+		writeAPIError(w, &APIError{Status: http.StatusForbidden, Message: "ingest disabled (INGEST_PARTNERS not set)"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, ingestMaxBody+1))
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "failed to read body"})
+		return
+	}
+	if len(body) > ingestMaxBody {
+		writeAPIError(w, &APIError{Status: http.StatusRequestEntityTooLarge, Message: "request body too large"})
+		return
+	}
+	partner, err := verifyNIP98(r, body)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Nostr")
+		writeAPIError(w, &APIError{Status: http.StatusUnauthorized, Message: err.Error()})
+		return
+	}
+	if !ingestStore.IsPartner(partner) {
+		writeAPIError(w, &APIError{Status: http.StatusForbidden, Message: "pubkey is not an ingest partner"})
+		return
+	}
+
+	var req struct {
+		Events []*nostr.Event `json:"events"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "invalid_json", Message: "invalid JSON: " + err.Error()})
+		return
+	}
+	if len(req.Events) == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "events", Message: "events array required"})
+		return
+	}
+	if len(req.Events) > ingestMaxEvents {
+		writeAPIError(w, invalidParam("events", "maximum %d events per batch", ingestMaxEvents))
+		return
+	}
+
+	resp := IngestResponse{Received: len(req.Events)}
+	for _, ev := range req.Events {
+		if ev == nil {
+			resp.Rejected++
+			continue
+		}
+		if reason := ingestEvent(ev, &resp); reason != "" {
+			resp.Rejected++
+			if len(resp.Errors) < ingestMaxErrors {
+				resp.Errors = append(resp.Errors, IngestError{ID: ev.ID, Reason: reason})
+			}
+		}
+	}
+	if resp.Accepted > 0 {
+		graphBuild.Touch()
+	}
+	ingestStore.recordBatch(partner, resp.Accepted, resp.Duplicates, resp.Rejected)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
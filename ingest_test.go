@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// setupIngest installs empty stores and one ingest partner, returning its key.
+func setupIngest(t *testing.T) (partnerSK string) {
+	oldGraph, oldMeta, oldZaps, oldIngest, oldBuild := graph, meta, zapStore, ingestStore, graphBuild
+	t.Cleanup(func() {
+		graph, meta, zapStore, ingestStore, graphBuild = oldGraph, oldMeta, oldZaps, oldIngest, oldBuild
+	})
+	partnerSK = nostr.GeneratePrivateKey()
+	partnerPub, _ := nostr.GetPublicKey(partnerSK)
+	graph, meta, zapStore = NewGraph(), NewMetaStore(), NewZapStore()
+	ingestStore = NewIngestStore([]string{partnerPub})
+	graphBuild = NewGraphBuild()
+	return partnerSK
+}
+
+func signedEvent(t *testing.T, sk string, kind int, at time.Time, tags ...nostr.Tag) *nostr.Event {
+	t.Helper()
+	ev := &nostr.Event{Kind: kind, CreatedAt: nostr.Timestamp(at.Unix()), Tags: tags}
+	if err := ev.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+func postIngest(t *testing.T, sk string, events ...*nostr.Event) (*httptest.ResponseRecorder, IngestResponse) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"events": events})
+	u := "http://example.com/ingest"
+	req := httptest.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	req.Header.Set("Authorization", nip98Header(t, sk, "POST", u, body, time.Now()))
+	rr := httptest.NewRecorder()
+	handleIngest(rr, req)
+	var resp IngestResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+func TestIngestAppliesEventsLikeTheCrawler(t *testing.T) {
+	partner := setupIngest(t)
+	alice := nostr.GeneratePrivateKey()
+	alicePub, _ := nostr.GetPublicKey(alice)
+	bob, carol := padHex(30001), padHex(30002)
+	now := time.Now()
+
+	follows := signedEvent(t, alice, 3, now.Add(-time.Minute), nostr.Tag{"p", bob}, nostr.Tag{"p", carol}, nostr.Tag{"p", "not-a-pubkey"})
+	reaction := signedEvent(t, alice, 7, now, nostr.Tag{"e", padHex(1)}, nostr.Tag{"p", bob})
+	report := signedEvent(t, alice, 1984, now, nostr.Tag{"p", carol, "spam"})
+	zap := signedEvent(t, partner, 9735, now, nostr.Tag{"p", bob}, nostr.Tag{"P", alicePub}, nostr.Tag{"bolt11", "lnbc10u1pexample"})
+	_, rev, _ := graphBuild.Current()
+
+	rr, resp := postIngest(t, partner, follows, reaction, report, zap)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if resp.Received != 4 || resp.Accepted != 4 || resp.Rejected != 0 || resp.ContactLists != 1 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	if got := graph.GetFollows(alicePub); len(got) != 2 || got[0] != bob || got[1] != carol {
+		t.Errorf("expected hex p tags as follows, got %v", got)
+	}
+	if !ingestStore.ContactListFresh(alicePub) {
+		t.Error("pushed contact list should spare alice from the next crawl")
+	}
+	if m := meta.Get(bob); m.ReactionsRecd != 1 || m.ZapAmtRecd != 1000 || m.ZapCntRecd != 1 {
+		t.Errorf("unexpected bob metadata %+v", m)
+	}
+	if m := meta.Get(carol); m.ReportsRecd != 1 {
+		t.Errorf("expected carol to have 1 report, got %d", m.ReportsRecd)
+	}
+	if m := meta.Get(alicePub); m.ReactionsSent != 1 || m.ReportsSent != 1 {
+		t.Errorf("unexpected alice metadata %+v", m)
+	}
+	if zapStore.ReceiptCount() != 1 {
+		t.Errorf("expected zap flow recorded, got %d receipts", zapStore.ReceiptCount())
+	}
+	if _, after, _ := graphBuild.Current(); after != rev+1 {
+		t.Errorf("expected build revision bump, got %d -> %d", rev, after)
+	}
+
+	// the same batch again is all duplicates and changes nothing
+	_, resp = postIngest(t, partner, follows, reaction, report, zap)
+	if resp.Accepted != 0 || resp.Duplicates != 4 {
+		t.Errorf("expected 4 duplicates, got %+v", resp)
+	}
+	if m := meta.Get(bob); m.ReactionsRecd != 1 {
+		t.Errorf("duplicate reaction was counted again: %d", m.ReactionsRecd)
+	}
+
+	// an older contact list is accepted but does not roll the graph back
+	older := signedEvent(t, alice, 3, now.Add(-time.Hour), nostr.Tag{"p", padHex(30003)})
+	_, resp = postIngest(t, partner, older)
+	if resp.Accepted != 1 || resp.StaleContacts != 1 || len(graph.GetFollows(alicePub)) != 2 {
+		t.Errorf("expected stale contact list ignored, got %+v", resp)
+	}
+
+	stats := ingestStore.Snapshot()
+	if len(stats) != 1 || stats[0].Batches != 3 || stats[0].Accepted != 5 || stats[0].Duplicates != 4 || stats[0].LastPush == "" {
+		t.Errorf("unexpected partner stats %+v", stats)
+	}
+}
+
+func TestIngestRejectsBadEvents(t *testing.T) {
+	partner := setupIngest(t)
+	alice := nostr.GeneratePrivateKey()
+	now := time.Now()
+
+	note := signedEvent(t, alice, 1, now)
+	forged := signedEvent(t, alice, 7, now, nostr.Tag{"p", padHex(30001)})
+	forged.Tags = append(forged.Tags, nostr.Tag{"p", padHex(30002)})
+	badSig := signedEvent(t, alice, 7, now)
+	badSig.Sig = signedEvent(t, alice, 7, now.Add(time.Second)).Sig
+	future := signedEvent(t, alice, 7, now.Add(time.Hour))
+	good := signedEvent(t, alice, 7, now, nostr.Tag{"p", padHex(30001)})
+
+	_, resp := postIngest(t, partner, note, forged, badSig, future, good)
+	if resp.Accepted != 1 || resp.Rejected != 4 || len(resp.Errors) != 4 {
+		t.Fatalf("expected 1 accepted and 4 rejected, got %+v", resp)
+	}
+	want := []string{"unsupported kind 1", "id does not match event hash", "invalid signature", "created_at in the future"}
+	for i, reason := range want {
+		if resp.Errors[i].Reason != reason {
+			t.Errorf("error %d: expected %q, got %q", i, reason, resp.Errors[i].Reason)
+		}
+	}
+}
+
+func TestIngestAuthorization(t *testing.T) {
+	partner := setupIngest(t)
+	ev := signedEvent(t, partner, 7, time.Now())
+
+	if rr, _ := postIngest(t, nostr.GeneratePrivateKey(), ev); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-partner, got %d", rr.Code)
+	}
+
+	rr := httptest.NewRecorder()
+	handleIngest(rr, httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewBufferString(`{"events":[]}`)))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without NIP-98, got %d", rr.Code)
+	}
+
+	if rr, _ := postIngest(t, partner); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an empty batch, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handleIngest(rr, httptest.NewRequest(http.MethodGet, "/ingest", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rr.Code)
+	}
+
+	ingestStore = NewIngestStore(nil)
+	if rr, _ := postIngest(t, partner, ev); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 with no partners configured, got %d", rr.Code)
+	}
+}
+
+func TestIngestStoreExpiry(t *testing.T) {
+	s := NewIngestStore(nil)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.MarkContactList("alice")
+	if !s.MarkSeen("id1") || s.MarkSeen("id1") {
+		t.Error("expected first MarkSeen true and second false")
+	}
+
+	now = now.Add(ingestFreshFor + time.Minute)
+	if s.ContactListFresh("alice") {
+		t.Error("contact list mark should expire after one crawl interval")
+	}
+	now = now.Add(ingestSeenTTL)
+	s.GC()
+	if len(s.seen) != 0 || len(s.contactLists) != 0 {
+		t.Errorf("expected GC to drop expired entries, got %d seen, %d contact lists", len(s.seen), len(s.contactLists))
+	}
+	if !s.MarkSeen("id1") {
+		t.Error("expired id should be accepted again")
+	}
+}
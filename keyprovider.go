@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// KeyProvider supplies the provider's secret key (nsec or hex) to every signing
+// path: NIP-85 assertions, the NIP-89 announcement, attestations and challenges.
+// key_provider in the config picks the implementation, so operators choose how
+// the key is stored. A signer that must never export the key, such as an HSM,
+// belongs behind a NIP-46 bunker instead (see SignerSource).
+type KeyProvider interface {
+	Name() string
+	SecretKey(ctx context.Context) (string, error)
+}
+
+// envKeyProvider reads the key from NOSTR_NSEC.
+type envKeyProvider struct{}
+
+func (envKeyProvider) Name() string { return "env" }
+
+func (envKeyProvider) SecretKey(context.Context) (string, error) {
+	nsec := strings.TrimSpace(os.Getenv("NOSTR_NSEC"))
+	if nsec == "" {
+		return "", fmt.Errorf("NOSTR_NSEC is not set")
+	}
+	return nsec, nil
+}
+
+// keyFileProvider reads the key from a file: an NIP-49 ncryptsec, decrypted with
+// the password in NOSTR_KEY_PASSWORD, or a plain nsec/hex key that only its owner
+// can read.
+type keyFileProvider struct{ path string }
+
+func (keyFileProvider) Name() string { return "file" }
+
+func (p keyFileProvider) SecretKey(context.Context) (string, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return "", fmt.Errorf("key file: %w", err)
+	}
+	key := strings.TrimSpace(string(data))
+	if strings.HasPrefix(key, "ncryptsec1") {
+		password, ok := os.LookupEnv("NOSTR_KEY_PASSWORD")
+		if !ok {
+			return "", fmt.Errorf("key file %s is encrypted but NOSTR_KEY_PASSWORD is not set", p.path)
+		}
+		return nip49Decrypt(key, password)
+	}
+	fi, err := os.Stat(p.path)
+	if err != nil {
+		return "", fmt.Errorf("key file: %w", err)
+	}
+	if fi.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("key file %s holds an unencrypted key and is readable by others (chmod 600 it)", p.path)
+	}
+	return key, nil
+}
+
+// commandKeyProvider runs a shell command and reads the key from its output.
+type commandKeyProvider struct {
+	name    string
+	command string
+}
+
+func (p commandKeyProvider) Name() string { return p.name }
+
+func (p commandKeyProvider) SecretKey(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "sh", "-c", p.command).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return "", fmt.Errorf("%s key command: %w: %s", p.name, err, strings.TrimSpace(string(ee.Stderr)))
+		}
+		return "", fmt.Errorf("%s key command: %w", p.name, err)
+	}
+	key := strings.TrimSpace(string(out))
+	if key == "" {
+		return "", fmt.Errorf("%s key command printed nothing", p.name)
+	}
+	return key, nil
+}
+
+// onePasswordCommand is the 1Password item the public instance keeps its key in.
+const onePasswordCommand = `op item get "SATMAX Nostr Identity - Max" --vault Agents --fields nsec --reveal`
+
+// keychainCommand looks service up in the OS keychain: the login keychain on
+// macOS, the Secret Service (GNOME Keyring, KWallet) elsewhere.
+func keychainCommand(service string) string {
+	if runtime.GOOS == "darwin" {
+		return "security find-generic-password -w -s " + shellQuote(service)
+	}
+	return "secret-tool lookup service " + shellQuote(service)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// keyProviderFor builds the provider cfg selects. With no key_provider set it
+// keeps the original behavior: NOSTR_NSEC if set, otherwise 1Password.
+func keyProviderFor(cfg Config) (KeyProvider, error) {
+	switch cfg.KeyProvider {
+	case "":
+		if os.Getenv("NOSTR_NSEC") != "" {
+			return envKeyProvider{}, nil
+		}
+		return commandKeyProvider{name: "1password", command: onePasswordCommand}, nil
+	case "env":
+		return envKeyProvider{}, nil
+	case "file":
+		return keyFileProvider{path: cfg.KeyFile}, nil
+	case "keychain":
+		return commandKeyProvider{name: "keychain", command: keychainCommand(cfg.KeychainService)}, nil
+	case "command":
+		return commandKeyProvider{name: "command", command: cfg.KeyCommand}, nil
+	case "1password":
+		return commandKeyProvider{name: "1password", command: onePasswordCommand}, nil
+	}
+	return nil, fmt.Errorf("unknown key_provider %q", cfg.KeyProvider)
+}
+
+// loadSecretKey reads the secret key from the configured provider.
+func loadSecretKey(ctx context.Context) (string, error) {
+	p, err := keyProviderFor(config.Get())
+	if err != nil {
+		return "", err
+	}
+	return p.SecretKey(ctx)
+}
+
+// getNsec is loadSecretKey for callers without a context.
+func getNsec() (string, error) {
+	return loadSecretKey(context.Background())
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKeyFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	encrypted := filepath.Join(dir, "key.ncryptsec")
+	os.WriteFile(encrypted, []byte("ncryptsec1qgg9947rlpvqu76pj5ecreduf9jxhselq2nae2kghhvd5g7dgjtcxfqtd67p9m0w57lspw8gsq6yphnm8623nsl8xn9j4jdzz84zm3frztj3z7s35vpzmqf6ksu8r89qk5z2zxfmu5gv8th8wclt0h4p\n"), 0o644)
+	if _, err := (keyFileProvider{path: encrypted}).SecretKey(ctx); err == nil || !strings.Contains(err.Error(), "NOSTR_KEY_PASSWORD") {
+		t.Errorf("expected the missing password reported, got %v", err)
+	}
+	t.Setenv("NOSTR_KEY_PASSWORD", "nostr")
+	if sk, err := (keyFileProvider{path: encrypted}).SecretKey(ctx); err != nil || sk != "3501454135014541350145413501453fefb02227e449e57cf4d3a3ce05378683" {
+		t.Errorf("expected the decrypted key, got %q (%v)", sk, err)
+	}
+
+	plain := filepath.Join(dir, "key.nsec")
+	os.WriteFile(plain, []byte(padHex(1)+"\n"), 0o644)
+	os.Chmod(plain, 0o644)
+	if _, err := (keyFileProvider{path: plain}).SecretKey(ctx); err == nil {
+		t.Error("expected a world-readable plain key refused")
+	}
+	os.Chmod(plain, 0o600)
+	if sk, err := (keyFileProvider{path: plain}).SecretKey(ctx); err != nil || sk != padHex(1) {
+		t.Errorf("expected the plain key, got %q (%v)", sk, err)
+	}
+	if _, err := (keyFileProvider{path: filepath.Join(dir, "missing")}).SecretKey(ctx); err == nil {
+		t.Error("expected a missing file reported")
+	}
+}
+
+func TestCommandKeyProvider(t *testing.T) {
+	ctx := context.Background()
+	if sk, err := (commandKeyProvider{name: "command", command: "echo '" + padHex(2) + "'"}).SecretKey(ctx); err != nil || sk != padHex(2) {
+		t.Errorf("expected the command's output, got %q (%v)", sk, err)
+	}
+	_, err := commandKeyProvider{name: "command", command: "echo locked >&2; exit 1"}.SecretKey(ctx)
+	if err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Errorf("expected the command's stderr in the error, got %v", err)
+	}
+	if _, err := (commandKeyProvider{name: "command", command: "true"}).SecretKey(ctx); err == nil {
+		t.Error("expected empty output refused")
+	}
+}
+
+func TestKeyProviderFor(t *testing.T) {
+	t.Setenv("NOSTR_NSEC", "")
+	for provider, want := range map[string]string{
+		"":          "1password",
+		"env":       "env",
+		"file":      "file",
+		"keychain":  "keychain",
+		"command":   "command",
+		"1password": "1password",
+	} {
+		p, err := keyProviderFor(Config{KeyProvider: provider, KeychainService: "wot-scoring"})
+		if err != nil || p.Name() != want {
+			t.Errorf("%q: expected %s, got %v (%v)", provider, want, p, err)
+		}
+	}
+	if _, err := keyProviderFor(Config{KeyProvider: "vault"}); err == nil {
+		t.Error("expected an unknown provider refused")
+	}
+
+	t.Setenv("NOSTR_NSEC", padHex(3))
+	if p, _ := keyProviderFor(Config{}); p.Name() != "env" {
+		t.Errorf("expected NOSTR_NSEC preferred by default, got %s", p.Name())
+	}
+	if keychainCommand("it's") == "" || !strings.Contains(keychainCommand("it's"), `'it'\''s'`) {
+		t.Errorf("expected the service quoted, got %s", keychainCommand("it's"))
+	}
+}
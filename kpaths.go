@@ -0,0 +1,343 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// kPathsMax caps k for the k-path searches on /graph and /trust-path.
+	kPathsMax = 5
+	// kPathsBudget caps the nodes settled across all of one request's searches, so
+	// Yen's algorithm between distant pubkeys in a dense graph stays bounded.
+	kPathsBudget = 200000
+	// trustEdgeMutualDiscount scales the cost of a follow that is returned.
+	trustEdgeMutualDiscount = 0.8
+)
+
+// WeightedPath is a follow path and its total edge cost.
+type WeightedPath struct {
+	Nodes []string
+	Cost  float64
+}
+
+// trustPathSearch finds cheapest follow paths in g. With weighted set, a follow
+// from a to b costs 1 / (0.1 + (score(a) + score(b)) / 200), times 0.8 when b
+// follows a back: about 0.9 between two top-scored accounts and 10 between two
+// unscored ones, so paths through trusted, reciprocal follows win. Otherwise every
+// follow costs 1 and the cheapest paths are the shortest ones.
+type trustPathSearch struct {
+	g         *Graph
+	graphSize int
+	weighted  bool
+	allow     func(string) bool // intermediate node filter; nil admits all
+	maxHops   int
+	budget    int
+	truncated bool // the budget ran out
+
+	scores    map[string]int
+	followers map[string]map[string]bool
+}
+
+func newTrustPathSearch(g *Graph, weighted bool, maxHops int, allow func(string) bool) *trustPathSearch {
+	return &trustPathSearch{
+		g:         g,
+		graphSize: g.NodeCount(),
+		weighted:  weighted,
+		allow:     allow,
+		maxHops:   maxHops,
+		budget:    kPathsBudget,
+		scores:    make(map[string]int),
+		followers: make(map[string]map[string]bool),
+	}
+}
+
+func (s *trustPathSearch) score(pk string) int {
+	if v, ok := s.scores[pk]; ok {
+		return v
+	}
+	raw, _ := s.g.GetScore(pk)
+	v := normalizeScore(raw, s.graphSize)
+	s.scores[pk] = v
+	return v
+}
+
+// mutual reports whether to follows from back.
+func (s *trustPathSearch) mutual(from, to string) bool {
+	set, ok := s.followers[from]
+	if !ok {
+		set = make(map[string]bool)
+		for _, f := range s.g.GetFollowers(from) {
+			set[f] = true
+		}
+		s.followers[from] = set
+	}
+	return set[to]
+}
+
+func (s *trustPathSearch) edgeCost(from, to string) float64 {
+	if !s.weighted {
+		return 1
+	}
+	cost := 1 / (0.1 + float64(s.score(from)+s.score(to))/200)
+	if s.mutual(from, to) {
+		cost *= trustEdgeMutualDiscount
+	}
+	return cost
+}
+
+func (s *trustPathSearch) pathCost(nodes []string) float64 {
+	cost := 0.0
+	for i := 0; i+1 < len(nodes); i++ {
+		cost += s.edgeCost(nodes[i], nodes[i+1])
+	}
+	return cost
+}
+
+type pathQueueItem struct {
+	node string
+	cost float64
+}
+
+type pathQueue []pathQueueItem
+
+func (q pathQueue) Len() int            { return len(q) }
+func (q pathQueue) Less(i, j int) bool  { return q[i].cost < q[j].cost }
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathQueueItem)) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// shortest runs Dijkstra from source to target over at most maxHops follows,
+// skipping removedNodes and removedEdges (keyed "from>to"). A node is expanded
+// along its cheapest path only, so with maxHops a cheaper but longer path can hide
+// a shorter one; hop limits are a bound, not part of the cost.
+func (s *trustPathSearch) shortest(source, target string, maxHops int, removedNodes, removedEdges map[string]bool) (WeightedPath, bool) {
+	if maxHops < 1 {
+		return WeightedPath{}, false
+	}
+	dist := map[string]float64{source: 0}
+	hops := map[string]int{source: 0}
+	prev := make(map[string]string)
+	done := make(map[string]bool)
+	q := &pathQueue{{node: source}}
+	for q.Len() > 0 {
+		cur := heap.Pop(q).(pathQueueItem)
+		if done[cur.node] || cur.cost > dist[cur.node] {
+			continue
+		}
+		if cur.node == target {
+			nodes := []string{target}
+			for n := target; n != source; {
+				n = prev[n]
+				nodes = append(nodes, n)
+			}
+			for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+				nodes[i], nodes[j] = nodes[j], nodes[i]
+			}
+			return WeightedPath{Nodes: nodes, Cost: cur.cost}, true
+		}
+		done[cur.node] = true
+		if s.budget--; s.budget < 0 {
+			s.truncated = true
+			return WeightedPath{}, false
+		}
+		if hops[cur.node] >= maxHops {
+			continue
+		}
+		for _, next := range s.g.GetFollows(cur.node) {
+			if done[next] || next == source || removedNodes[next] || removedEdges[cur.node+">"+next] {
+				continue
+			}
+			if next != target && s.allow != nil && !s.allow(next) {
+				continue
+			}
+			c := cur.cost + s.edgeCost(cur.node, next)
+			if d, ok := dist[next]; ok && d <= c {
+				continue
+			}
+			dist[next], hops[next], prev[next] = c, hops[cur.node]+1, cur.node
+			heap.Push(q, pathQueueItem{node: next, cost: c})
+		}
+	}
+	return WeightedPath{}, false
+}
+
+// kShortest returns up to k cheapest loopless paths from source to target, cheapest
+// first, by Yen's algorithm. Paths may share accounts.
+func (s *trustPathSearch) kShortest(source, target string, k int) []WeightedPath {
+	first, ok := s.shortest(source, target, s.maxHops, nil, nil)
+	if !ok {
+		return nil
+	}
+	found := []WeightedPath{first}
+	seen := map[string]bool{strings.Join(first.Nodes, ","): true}
+	var candidates []WeightedPath
+	for len(found) < k {
+		last := found[len(found)-1].Nodes
+		for i := 0; i+1 < len(last); i++ {
+			root := last[:i+1]
+			removedEdges := make(map[string]bool)
+			for _, p := range found {
+				if len(p.Nodes) > i+1 && strings.Join(p.Nodes[:i+1], ",") == strings.Join(root, ",") {
+					removedEdges[p.Nodes[i]+">"+p.Nodes[i+1]] = true
+				}
+			}
+			removedNodes := make(map[string]bool, i)
+			for _, n := range root[:i] {
+				removedNodes[n] = true
+			}
+			spur, ok := s.shortest(last[i], target, s.maxHops-i, removedNodes, removedEdges)
+			if !ok {
+				if s.truncated {
+					break
+				}
+				continue
+			}
+			nodes := append(append([]string{}, root[:i]...), spur.Nodes...)
+			key := strings.Join(nodes, ",")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			candidates = append(candidates, WeightedPath{Nodes: nodes, Cost: s.pathCost(nodes)})
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Cost < candidates[j].Cost })
+		found = append(found, candidates[0])
+		candidates = candidates[1:]
+	}
+	return found
+}
+
+// disjoint returns up to k cheapest paths that share no intermediate account, by
+// removing each path's intermediates (or, for a direct follow, the follow itself)
+// before searching again.
+func (s *trustPathSearch) disjoint(source, target string, k int) []WeightedPath {
+	removedNodes := make(map[string]bool)
+	removedEdges := make(map[string]bool)
+	var found []WeightedPath
+	for len(found) < k {
+		p, ok := s.shortest(source, target, s.maxHops, removedNodes, removedEdges)
+		if !ok {
+			break
+		}
+		found = append(found, p)
+		if len(p.Nodes) == 2 {
+			removedEdges[source+">"+target] = true
+		}
+		for _, n := range p.Nodes[1 : len(p.Nodes)-1] {
+			removedNodes[n] = true
+		}
+	}
+	return found
+}
+
+// pathDiversity is the share of distinct accounts among the intermediates of
+// paths: 1 when no two paths share one, lower the more they overlap. Paths without
+// intermediates count as fully diverse.
+func pathDiversity(paths [][]string) float64 {
+	total := 0
+	distinct := make(map[string]bool)
+	for _, p := range paths {
+		if len(p) <= 2 {
+			continue
+		}
+		for _, n := range p[1 : len(p)-1] {
+			total++
+			distinct[n] = true
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+	return round3(float64(len(distinct)) / float64(total))
+}
+
+// GraphKPath is one entry of paths in a /graph k-paths response.
+type GraphKPath struct {
+	Path                 []PathNode `json:"path"`
+	Hops                 int        `json:"hops"`
+	Cost                 float64    `json:"cost"`
+	HopDetails           []PathHop  `json:"hop_details"`
+	FlaggedIntermediates int        `json:"flagged_intermediates"`
+}
+
+// GraphKPathsResponse is the /graph path mode body for k > 1 or weighted=true.
+// The single-path fields repeat the cheapest path; cost and diversity appear
+// only when a path was found.
+type GraphKPathsResponse struct {
+	From                 string       `json:"from"`
+	To                   string       `json:"to"`
+	Found                bool         `json:"found"`
+	Path                 []PathNode   `json:"path"`
+	Hops                 int          `json:"hops"`
+	Cost                 *float64     `json:"cost,omitempty"`
+	HopDetails           []PathHop    `json:"hop_details"`
+	FlaggedIntermediates int          `json:"flagged_intermediates"`
+	Paths                []GraphKPath `json:"paths"`
+	Diversity            *float64     `json:"diversity,omitempty"`
+	Options              PathOptions  `json:"options"`
+	ExcludedNodes        int          `json:"excluded_nodes"`
+	Truncated            bool         `json:"truncated"`
+	GraphSize            int          `json:"graph_size"`
+}
+
+// graphKPaths builds the /graph path-mode response for k > 1 or weighted=true:
+// the paths cheapest first under "paths", with the cheapest one also in the
+// single-path fields.
+func graphKPaths(g *Graph, from, to string, opts PathOptions, excluded *int) GraphKPathsResponse {
+	graphSize := g.NodeCount()
+	s := newTrustPathSearch(g, opts.Weighted, opts.MaxHops, opts.pathFilter(graphSize, excluded))
+	k := max(opts.K, 1)
+	var found []WeightedPath
+	if opts.Disjoint {
+		found = s.disjoint(from, to, k)
+	} else {
+		found = s.kShortest(from, to, k)
+	}
+
+	resp := GraphKPathsResponse{
+		From:          from,
+		To:            to,
+		Found:         len(found) > 0,
+		Path:          []PathNode{},
+		HopDetails:    []PathHop{},
+		Paths:         []GraphKPath{},
+		Options:       opts,
+		ExcludedNodes: *excluded,
+		Truncated:     s.truncated,
+		GraphSize:     graphSize,
+	}
+	if len(found) == 0 {
+		return resp
+	}
+
+	now := time.Now()
+	raw := make([][]string, 0, len(found))
+	for _, p := range found {
+		nodes, hops, flagged := explainPath(p.Nodes, now)
+		resp.Paths = append(resp.Paths, GraphKPath{
+			Path:                 nodes,
+			Hops:                 len(p.Nodes) - 1,
+			Cost:                 round3(p.Cost),
+			HopDetails:           hops,
+			FlaggedIntermediates: flagged,
+		})
+		raw = append(raw, p.Nodes)
+	}
+	best := resp.Paths[0]
+	resp.Path, resp.Hops, resp.HopDetails = best.Path, best.Hops, best.HopDetails
+	resp.FlaggedIntermediates = best.FlaggedIntermediates
+	diversity := pathDiversity(raw)
+	resp.Cost, resp.Diversity = &best.Cost, &diversity
+	return resp
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// buildKPathsGraph links src to dst three ways: through a well-followed hub, through
+// an unscored account, and over two hops through m1 and m2. m1 also follows the hub.
+func buildKPathsGraph(t *testing.T) (src, dst, hub, low string) {
+	t.Helper()
+	oldGraph := graph
+	t.Cleanup(func() { graph = oldGraph })
+	graph = NewGraph()
+	src, dst, hub, low = padHex(1), padHex(2), padHex(3), padHex(4)
+	m1, m2 := padHex(5), padHex(6)
+	for _, e := range [][2]string{{src, hub}, {hub, dst}, {dst, hub}, {src, low}, {low, dst}, {src, m1}, {m1, m2}, {m2, dst}, {m1, hub}} {
+		graph.AddFollow(e[0], e[1])
+	}
+	for i := 10; i < 30; i++ {
+		graph.AddFollow(padHex(i), hub)
+	}
+	graph.ComputePageRank(20, 0.85)
+	graph.scores[low] = 0
+	return src, dst, hub, low
+}
+
+func TestKShortestWeightedPrefersTrustedHub(t *testing.T) {
+	src, dst, hub, low := buildKPathsGraph(t)
+
+	s := newTrustPathSearch(graph, true, 6, nil)
+	paths := s.kShortest(src, dst, 3)
+	if len(paths) != 3 {
+		t.Fatalf("expected 3 paths, got %v", paths)
+	}
+	if paths[0].Nodes[1] != hub {
+		t.Errorf("expected the cheapest path through the hub, got %v", paths[0].Nodes)
+	}
+	seen := make(map[string]bool)
+	for i, p := range paths {
+		key := strings.Join(p.Nodes, ",")
+		if seen[key] {
+			t.Errorf("path %v returned twice", p.Nodes)
+		}
+		seen[key] = true
+		if i > 0 && p.Cost < paths[i-1].Cost {
+			t.Errorf("expected paths cheapest first, got %v", paths)
+		}
+	}
+	if c := s.pathCost([]string{src, low, dst}); c <= paths[0].Cost {
+		t.Errorf("expected the unscored route to cost more than the hub's, got %.3f <= %.3f", c, paths[0].Cost)
+	}
+}
+
+func TestKShortestUnweightedByHops(t *testing.T) {
+	src, dst, _, _ := buildKPathsGraph(t)
+
+	paths := newTrustPathSearch(graph, false, 6, nil).kShortest(src, dst, 5)
+	if len(paths) != 4 {
+		t.Fatalf("expected all 4 loopless paths, got %v", paths)
+	}
+	for i, want := range []int{2, 2, 3, 3} {
+		if hops := len(paths[i].Nodes) - 1; hops != want || paths[i].Cost != float64(want) {
+			t.Errorf("path %d: expected %d hops, got %v", i, want, paths[i])
+		}
+	}
+}
+
+func TestDisjointPathsShareNoIntermediate(t *testing.T) {
+	src, dst, hub, _ := buildKPathsGraph(t)
+
+	paths := newTrustPathSearch(graph, true, 6, nil).disjoint(src, dst, 5)
+	if len(paths) != 3 {
+		t.Fatalf("expected 3 disjoint paths, got %v", paths)
+	}
+	var raw [][]string
+	for _, p := range paths {
+		raw = append(raw, p.Nodes)
+	}
+	if d := pathDiversity(raw); d != 1 {
+		t.Errorf("expected diversity 1 for disjoint paths, got %v", d)
+	}
+	for _, p := range paths[1:] {
+		for _, n := range p.Nodes {
+			if n == hub {
+				t.Errorf("hub reused in %v", p.Nodes)
+			}
+		}
+	}
+}
+
+func TestPathDiversity(t *testing.T) {
+	if d := pathDiversity([][]string{{"a", "x", "b"}, {"a", "x", "y", "b"}}); d != 0.667 {
+		t.Errorf("expected 2 distinct of 3 intermediates, got %v", d)
+	}
+	if d := pathDiversity([][]string{{"a", "b"}}); d != 1 {
+		t.Errorf("expected a direct follow to count as diverse, got %v", d)
+	}
+}
+
+func TestTrustPathSearchBudget(t *testing.T) {
+	src, dst, _, _ := buildKPathsGraph(t)
+
+	s := newTrustPathSearch(graph, false, 6, nil)
+	s.budget = 1
+	if _, ok := s.shortest(src, dst, 6, nil, nil); ok || !s.truncated {
+		t.Error("expected the search to stop and report truncation")
+	}
+}
+
+func TestHandleGraphKPaths(t *testing.T) {
+	src, dst, hub, _ := buildKPathsGraph(t)
+
+	w := httptest.NewRecorder()
+	handleGraph(w, httptest.NewRequest("GET", "/graph?from="+src+"&to="+dst+"&k=3&weighted=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Found bool       `json:"found"`
+		Path  []PathNode `json:"path"`
+		Cost  float64    `json:"cost"`
+		Paths []struct {
+			Hops int     `json:"hops"`
+			Cost float64 `json:"cost"`
+		} `json:"paths"`
+		Diversity float64     `json:"diversity"`
+		Options   PathOptions `json:"options"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if !resp.Found || len(resp.Paths) != 3 || resp.Path[1].Pubkey != hub || resp.Cost != resp.Paths[0].Cost {
+		t.Errorf("unexpected response %s", w.Body.String())
+	}
+	if resp.Options.K != 3 || !resp.Options.Weighted || resp.Diversity >= 1 {
+		t.Errorf("expected options echoed and overlapping paths, got %s", w.Body.String())
+	}
+
+	for _, q := range []string{"k=9", "k=0", "weighted=maybe"} {
+		w = httptest.NewRecorder()
+		handleGraph(w, httptest.NewRequest("GET", "/graph?from="+src+"&to="+dst+"&"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, w.Code)
+		}
+	}
+}
+
+func TestTrustPathWeighted(t *testing.T) {
+	src, dst, _, _ := buildKPathsGraph(t)
+
+	get := func(q string) (int, TrustPathResponse) {
+		rec := httptest.NewRecorder()
+		handleTrustPath(rec, httptest.NewRequest("GET", "/trust-path?from="+src+"&to="+dst+"&max_paths=5&"+q, nil))
+		var resp TrustPathResponse
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec.Code, resp
+	}
+
+	code, resp := get("weighted=true")
+	if code != http.StatusOK || !resp.Weighted || !resp.Disjoint || len(resp.Paths) != 3 || resp.NodeDiversity != 1 {
+		t.Fatalf("unexpected weighted response %d %+v", code, resp)
+	}
+	for _, p := range resp.Paths {
+		if p.Cost <= 0 {
+			t.Errorf("expected each weighted path to carry its cost, got %+v", p)
+		}
+	}
+
+	code, resp = get("weighted=true&disjoint=false")
+	if code != http.StatusOK || resp.Disjoint || len(resp.Paths) != 4 || resp.NodeDiversity >= 1 {
+		t.Errorf("expected Yen's overlapping paths, got %d %+v", code, resp)
+	}
+
+	if code, _ := get("disjoint=false"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for disjoint=false without weighted, got %d", code)
+	}
+}
@@ -2,14 +2,18 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,10 +21,13 @@ import (
 
 // L402Config holds L402 paywall configuration.
 type L402Config struct {
-	LNbitsURL          string   // LNbits base URL (e.g., https://lnbits.klabo.world)
-	LNbitsFallbackURLs []string // Optional fallbacks used only on transient errors (429/5xx/network)
-	LNbitsAPIKey       string   // LNbits invoice/read key
-	FreeTier           int      // Free requests per IP per day (0 = all paid)
+	LNbitsURL          string        // LNbits base URL (e.g., https://lnbits.klabo.world)
+	LNbitsFallbackURLs []string      // Optional fallbacks used only on transient errors (429/5xx/network)
+	LNbitsAPIKey       string        // LNbits invoice/read key
+	FreeTier           int           // Free requests per IP per day (0 = all paid)
+	RootKey            []byte        // Signs L402 macaroons; random per process if empty
+	TokenTTL           time.Duration // How long a minted macaroon is valid (default 24h)
+	TokenUsesFile      string        // Keeps macaroon request counts across restarts (optional)
 }
 
 // L402Middleware implements an L402 paywall with a free tier.
@@ -31,8 +38,18 @@ type L402Middleware struct {
 	mu              sync.Mutex
 	freeUsage       map[string]*dailyUsage // IP -> usage
 	paidHashes      map[string]bool        // payment_hash -> already used
+	tokenUses       map[string]*tokenUse   // macaroon token ID -> requests made
+	tokenUsesDirty  bool                   // tokenUses changed since the last save
+	zapAuthUsed     map[string]time.Time   // NIP-98 event ID -> when it paid from zap credit
 }
 
+const (
+	// zapSpendMaxBody caps the request body hashed to check a zap spend's payload tag.
+	zapSpendMaxBody = 4 << 20
+	// zapSpendAuthHeader is how the 402 and /zap/request tell clients to sign spends.
+	zapSpendAuthHeader = "Authorization: Nostr <base64 NIP-98 kind 27235 event with u, method and (for a body) payload tags>"
+)
+
 type dailyUsage struct {
 	count   int
 	resetAt time.Time
@@ -46,12 +63,23 @@ type lnbitsEndpoint struct {
 
 // NewL402Middleware creates a new L402 paywall middleware.
 func NewL402Middleware(config L402Config) *L402Middleware {
+	if len(config.RootKey) == 0 {
+		config.RootKey = make([]byte, 32)
+		rand.Read(config.RootKey)
+	}
+	if config.TokenTTL <= 0 {
+		config.TokenTTL = l402DefaultTokenTTL
+	}
 	m := &L402Middleware{
 		config: config,
 		pricedEndpoints: map[string]int64{
 			"/score":                1,
 			"/audit":                5,
 			"/batch":                10,
+			"/audit/batch":          10,
+			"/personalized/batch":   10,
+			"/graph/batch":          10,
+			"/rank":                 10,
 			"/personalized":         2,
 			"/similar":              2,
 			"/recommend":            2,
@@ -60,28 +88,42 @@ func NewL402Middleware(config L402Config) *L402Middleware {
 			"/nip05":                1,
 			"/nip05/batch":          5,
 			"/nip05/reverse":        2,
+			"/domain":               5,
 			"/timeline":             2,
+			"/history":              2,
+			"/contacts/snapshot":    2,
 			"/spam":                 2,
+			"/spam/event":           2,
 			"/spam/batch":           10,
+			"/reports":              2,
 			"/weboftrust":           3,
 			"/blocked":              2,
 			"/verify":               2,
 			"/anomalies":            3,
 			"/sybil":                3,
 			"/sybil/batch":          10,
+			"/org-score":            10,
 			"/trust-path":           5,
 			"/reputation":           5,
 			"/predict":              3,
 			"/influence":            5,
+			"/simulate":             5,
 			"/influence/batch":      10,
 			"/network-health":       5,
+			"/bias":                 5,
 			"/compare-providers":    5,
 			"/trust-circle":         5,
 			"/trust-circle/compare": 5,
 			"/follow-quality":       5,
+			"/relay/suggest":        5,
+			"/hint":                 1,
+			"/crawl":                2,
+			"/graphql":              10,
 		},
-		freeUsage:  make(map[string]*dailyUsage),
-		paidHashes: make(map[string]bool),
+		freeUsage:   make(map[string]*dailyUsage),
+		paidHashes:  make(map[string]bool),
+		tokenUses:   make(map[string]*tokenUse),
+		zapAuthUsed: make(map[string]time.Time),
 	}
 	// Cleanup expired free-tier entries every hour
 	go func() {
@@ -94,6 +136,61 @@ func NewL402Middleware(config L402Config) *L402Middleware {
 	return m
 }
 
+// L402Error is an APIError with a hint on how to get a working credential.
+type L402Error struct {
+	APIError
+	Hint string `json:"message"`
+}
+
+// PaymentRequiredResponse is the 402 body for a priced endpoint, listing
+// every way the request can be paid for.
+type PaymentRequiredResponse struct {
+	Status      string           `json:"status"`
+	PaymentHash string           `json:"payment_hash"`
+	Invoice     string           `json:"invoice"`
+	AmountSats  int64            `json:"amount_sats"`
+	Message     string           `json:"message"`
+	Protocols   PaymentProtocols `json:"protocols"`
+	FreeTier    int              `json:"free_tier"`
+	Endpoint    string           `json:"endpoint"`
+}
+
+// PaymentProtocols holds the challenges a 402 offers; zap is present only
+// when zap credits are configured.
+type PaymentProtocols struct {
+	L402 L402Challenge `json:"l402"`
+	Zap  *ZapChallenge `json:"zap,omitempty"`
+}
+
+// L402Challenge is the invoice and, when one could be minted, the macaroon to
+// present once it is paid.
+type L402Challenge struct {
+	PriceSats        int64  `json:"price_sats"`
+	PaymentRequest   string `json:"payment_request"`
+	PaymentHash      string `json:"payment_hash"`
+	VerifyHeader     string `json:"verify_header"`
+	VerifyQueryArg   string `json:"verify_query_arg"`
+	Macaroon         string `json:"macaroon,omitempty"`
+	MaxRequests      int    `json:"max_requests,omitempty"`
+	ExpiresInSeconds int    `json:"expires_in_seconds,omitempty"`
+}
+
+// ZapChallenge tells a client how to pay from zap credit. BalanceSats is set
+// when the request was signed, so the caller sees what it has left.
+type ZapChallenge struct {
+	ServicePubkey string `json:"service_pubkey"`
+	Request       string `json:"request"`
+	AuthHeader    string `json:"auth_header"`
+	BalanceSats   *int64 `json:"balance_sats,omitempty"`
+}
+
+// writeL402Error writes e as JSON with its status.
+func writeL402Error(w http.ResponseWriter, e *L402Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(e)
+}
+
 // Wrap wraps an http.Handler with L402 paywall logic.
 func (m *L402Middleware) Wrap(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -103,6 +200,37 @@ func (m *L402Middleware) Wrap(next http.Handler) http.Handler {
 			return
 		}
 
+		// Requests with an API key are charged to its account's prepaid balance
+		if key := apiKeyFrom(r); key != "" {
+			pubkey, ok := accountStore.Lookup(key)
+			if !ok {
+				writeAPIError(w, &APIError{Status: http.StatusUnauthorized, Code: "invalid_api_key", Message: "unknown API key"})
+				return
+			}
+			left, ok := accountStore.Charge(pubkey, r.URL.Path, price)
+			w.Header().Set("X-Account-Balance", strconv.FormatInt(left, 10))
+			if !ok {
+				writeAPIError(w, &APIError{Status: http.StatusPaymentRequired, Code: "insufficient_balance",
+					Message: fmt.Sprintf("%s costs %d sats; the account has %d. Top up with POST /account/topup.", r.URL.Path, price, left)})
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// A macaroon with its preimage is verified without asking LNbits
+		if macaroon, preimage, ok := parseL402Credential(r.Header.Get("Authorization")); ok {
+			if _, err := m.authorizeToken(macaroon, preimage, r.URL.Path); err != nil {
+				writeL402Error(w, &L402Error{
+					APIError: APIError{Status: http.StatusUnauthorized, Code: "invalid_token", Message: err.Error()},
+					Hint:     "Check the token with GET /l402/info, or request a new invoice.",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Check if request includes a valid payment proof
 		paymentHash := r.Header.Get("X-Payment-Hash")
 		if paymentHash == "" {
@@ -122,11 +250,9 @@ func (m *L402Middleware) Wrap(next http.Handler) http.Handler {
 				next.ServeHTTP(w, r)
 				return
 			}
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error":   "invalid or expired payment",
-				"message": "Payment hash not found or already used. Request a new invoice.",
+			writeL402Error(w, &L402Error{
+				APIError: APIError{Status: http.StatusUnauthorized, Code: "invalid_payment", Message: "invalid or expired payment"},
+				Hint:     "Payment hash not found or already used. Request a new invoice.",
 			})
 			return
 		}
@@ -140,41 +266,147 @@ func (m *L402Middleware) Wrap(next http.Handler) http.Handler {
 			}
 		}
 
-		// Free tier exhausted or disabled — require payment
+		// A NIP-98 signed request from a pubkey with zap credit is paid from its balance
+		zapper, authID, signed, authErr := zapSpendAuth(r)
+		if signed && authErr == nil {
+			left, ok, err := m.spendZapCredit(zapper, authID, price)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", "Nostr")
+				writeL402Error(w, &L402Error{
+					APIError: APIError{Status: http.StatusUnauthorized, Code: "invalid_auth", Message: err.Error()},
+					Hint:     "Sign a new NIP-98 event for each request paid from zap credit.",
+				})
+				return
+			}
+			if ok {
+				w.Header().Set("X-Zap-Balance", strconv.FormatInt(left, 10))
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		// Free tier exhausted or disabled — require payment, for l402_requests requests
+		requests := 1
+		if v := r.URL.Query().Get("l402_requests"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > l402MaxRequestsPerToken {
+				writeAPIError(w, invalidParam("l402_requests", "l402_requests must be 1-%d", l402MaxRequestsPerToken))
+				return
+			}
+			requests = n
+		}
+		price *= int64(requests)
 		invoice, hash, err := m.createInvoice(price, fmt.Sprintf("WoT %s query", r.URL.Path))
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error": "failed to create invoice",
-			})
+			writeAPIError(w, &APIError{Status: http.StatusInternalServerError, Code: "invoice_failed", Message: "failed to create invoice"})
 			return
 		}
 
+		// Bind a macaroon to the invoice, scoped to this endpoint and request count
+		macaroon, err := m.mintToken(hash, []string{r.URL.Path}, requests)
+		if err != nil {
+			macaroon = "none"
+		}
+		l402 := L402Challenge{
+			PriceSats:      price,
+			PaymentRequest: invoice,
+			PaymentHash:    hash,
+			VerifyHeader:   "X-Payment-Hash",
+			VerifyQueryArg: "payment_hash",
+		}
+		message := fmt.Sprintf("Pay %d sats to access %s. Retry with X-Payment-Hash header (preferred), ?payment_hash= query param, or Authorization: L402 <payment_hash>.", price, r.URL.Path)
+		if macaroon != "none" {
+			l402.Macaroon = macaroon
+			l402.MaxRequests = requests
+			l402.ExpiresInSeconds = int(m.config.TokenTTL.Seconds())
+			message = fmt.Sprintf("Pay %d sats for %d request(s) to %s, then send Authorization: L402 <macaroon>:<preimage>. X-Payment-Hash also works for a single request.", price, requests, r.URL.Path)
+		}
+
+		protocols := PaymentProtocols{L402: l402}
+		if service := zapCredits.Service(); service != "" {
+			protocols.Zap = &ZapChallenge{
+				ServicePubkey: service,
+				Request:       "/zap/request",
+				AuthHeader:    zapSpendAuthHeader,
+			}
+			if signed && authErr == nil {
+				balance := zapCredits.Account(zapper).Balance
+				protocols.Zap.BalanceSats = &balance
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`L402 invoice="%s", macaroon="none"`, invoice))
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`L402 macaroon="%s", invoice="%s"`, macaroon, invoice))
 		w.WriteHeader(http.StatusPaymentRequired)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status":       "payment_required",
-			"payment_hash": hash,
-			"invoice":      invoice,
-			"amount_sats":  price,
-			"message":      fmt.Sprintf("Pay %d sats to access %s. Retry with X-Payment-Hash header (preferred), ?payment_hash= query param, or Authorization: L402 <payment_hash>.", price, r.URL.Path),
-			"protocols": map[string]interface{}{
-				"l402": map[string]interface{}{
-					"price_sats":       price,
-					"payment_request":  invoice,
-					"payment_hash":     hash,
-					"verify_header":    "X-Payment-Hash",
-					"verify_query_arg": "payment_hash",
-				},
-			},
-			"free_tier": m.config.FreeTier,
-			"endpoint":  r.URL.Path,
+		json.NewEncoder(w).Encode(PaymentRequiredResponse{
+			Status:      "payment_required",
+			PaymentHash: hash,
+			Invoice:     invoice,
+			AmountSats:  price,
+			Message:     message,
+			Protocols:   protocols,
+			FreeTier:    m.config.FreeTier,
+			Endpoint:    r.URL.Path,
 		})
 	})
 }
 
+// zapSpendAuth authenticates a request that may spend zap credit. Spending
+// moves money, so it takes a NIP-98 event (kind 27235) bound to this URL and
+// method, and to the body through a payload tag when there is one; the kind
+// 22242 event used for rate limits is valid for any request for 10 minutes.
+// The body is read and put back for the handler. signed is false without a
+// "Nostr" Authorization header.
+func zapSpendAuth(r *http.Request) (pubkey, authID string, signed bool, err error) {
+	if !strings.HasPrefix(strings.TrimSpace(r.Header.Get("Authorization")), "Nostr ") {
+		return "", "", false, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, zapSpendMaxBody+1))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil {
+		return "", "", true, err
+	}
+	if len(body) > zapSpendMaxBody {
+		return "", "", true, fmt.Errorf("body too large to pay from zap credit")
+	}
+	ev, err := verifyNIP98Event(r, body)
+	if err != nil {
+		return "", "", true, err
+	}
+	if len(body) > 0 && ev.Tags.Find("payload") == nil {
+		return "", "", true, fmt.Errorf("auth event needs a payload tag to pay for a request with a body")
+	}
+	return ev.PubKey, ev.ID, true, nil
+}
+
+// spendZapCredit charges price to pubkey's zap credit, once per auth event: a
+// NIP-98 event replayed within its 60-second window is refused rather than
+// charged again. ok is false when the balance is short.
+func (m *L402Middleware) spendZapCredit(pubkey, authID string, price int64) (left int64, ok bool, err error) {
+	now := time.Now()
+	m.mu.Lock()
+	if _, used := m.zapAuthUsed[authID]; used {
+		m.mu.Unlock()
+		return 0, false, fmt.Errorf("auth event already used")
+	}
+	m.zapAuthUsed[authID] = now
+	if len(m.zapAuthUsed) > 10000 {
+		for id, at := range m.zapAuthUsed {
+			if now.Sub(at) > 2*nip98MaxSkew {
+				delete(m.zapAuthUsed, id)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	if left, ok = zapCredits.Spend(pubkey, price); !ok {
+		m.mu.Lock()
+		delete(m.zapAuthUsed, authID)
+		m.mu.Unlock()
+	}
+	return left, ok, nil
+}
+
 // consumeFreeTier checks if the IP has free requests remaining and decrements.
 func (m *L402Middleware) consumeFreeTier(ip string) bool {
 	m.mu.Lock()
@@ -334,11 +566,11 @@ func (m *L402Middleware) verifyPayment(paymentHash string) bool {
 
 // createInvoice creates a Lightning invoice via LNbits.
 func (m *L402Middleware) createInvoice(amountSats int64, memo string) (invoice string, paymentHash string, err error) {
-	payloadBytes, err := json.Marshal(map[string]interface{}{
-		"out":    false,
-		"amount": amountSats,
-		"memo":   memo,
-	})
+	payloadBytes, err := json.Marshal(struct {
+		Out    bool   `json:"out"`
+		Amount int64  `json:"amount"`
+		Memo   string `json:"memo"`
+	}{false, amountSats, memo})
 	if err != nil {
 		return "", "", err
 	}
@@ -426,6 +658,13 @@ func (m *L402Middleware) cleanupFreeUsage() {
 			delete(m.freeUsage, ip)
 		}
 	}
+	// Expired tokens are rejected on their caveat alone
+	for id, use := range m.tokenUses {
+		if now.After(use.ExpiresAt) {
+			delete(m.tokenUses, id)
+			m.tokenUsesDirty = true
+		}
+	}
 }
 
 // clientIP extracts the client IP from the request.
@@ -450,11 +689,25 @@ func L402Enabled() bool {
 // NewL402FromEnv creates an L402 middleware from environment variables.
 func NewL402FromEnv() *L402Middleware {
 	freeTier := 50 // Free requests per IP per day (increased for demo/presentation)
+	rootKey, err := hex.DecodeString(os.Getenv("L402_ROOT_KEY"))
+	if err != nil || len(rootKey) < 32 {
+		log.Printf("L402_ROOT_KEY unset or shorter than 32 bytes of hex: macaroons will not survive a restart")
+		rootKey = nil
+	}
+	ttl := l402DefaultTokenTTL
+	if v := os.Getenv("L402_TOKEN_TTL_HOURS"); v != "" {
+		if h, err := strconv.Atoi(v); err == nil && h > 0 {
+			ttl = time.Duration(h) * time.Hour
+		}
+	}
 	return NewL402Middleware(L402Config{
 		LNbitsURL:          os.Getenv("LNBITS_URL"),
 		LNbitsFallbackURLs: splitCommaList(os.Getenv("LNBITS_FALLBACK_URLS")),
 		LNbitsAPIKey:       os.Getenv("LNBITS_KEY"),
 		FreeTier:           freeTier,
+		RootKey:            rootKey,
+		TokenTTL:           ttl,
+		TokenUsesFile:      os.Getenv("L402_TOKENS_FILE"),
 	})
 }
 
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// l402DefaultTokenTTL is how long a minted token stays valid.
+	l402DefaultTokenTTL = 24 * time.Hour
+	// l402MaxRequestsPerToken caps the requests one token can be bought for.
+	l402MaxRequestsPerToken = 100
+	// l402Location is the location field of minted macaroons.
+	l402Location = "wot-scoring"
+)
+
+// Macaroon is a bearer credential in the libmacaroons V2 binary format. Its
+// signature chains HMAC-SHA256 from a root key through the identifier and every
+// first-party caveat, so a caveat can be added by anyone holding the macaroon
+// but never removed.
+type Macaroon struct {
+	Location string
+	ID       []byte
+	Caveats  []string
+	Sig      []byte
+}
+
+// macaroonKey derives the signing key from a root key, as libmacaroons does.
+func macaroonKey(rootKey []byte) []byte {
+	h := hmac.New(sha256.New, []byte("macaroons-key-generator"))
+	h.Write(rootKey)
+	return h.Sum(nil)
+}
+
+func macaroonHMAC(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// NewMacaroon mints a macaroon with no caveats.
+func NewMacaroon(rootKey, id []byte, location string) *Macaroon {
+	return &Macaroon{Location: location, ID: id, Sig: macaroonHMAC(macaroonKey(rootKey), id)}
+}
+
+// AddCaveat appends a first-party caveat and advances the signature.
+func (m *Macaroon) AddCaveat(caveat string) {
+	m.Caveats = append(m.Caveats, caveat)
+	m.Sig = macaroonHMAC(m.Sig, []byte(caveat))
+}
+
+// Verify reports whether the macaroon was minted with rootKey and its caveats
+// are unaltered. It does not check what the caveats say.
+func (m *Macaroon) Verify(rootKey []byte) bool {
+	sig := macaroonHMAC(macaroonKey(rootKey), m.ID)
+	for _, c := range m.Caveats {
+		sig = macaroonHMAC(sig, []byte(c))
+	}
+	return hmac.Equal(sig, m.Sig)
+}
+
+// V2 binary field types.
+const (
+	macaroonFieldEOS        = 0
+	macaroonFieldLocation   = 1
+	macaroonFieldIdentifier = 2
+	macaroonFieldVID        = 4
+	macaroonFieldSignature  = 6
+)
+
+func appendMacaroonField(b []byte, field int, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field))
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// MarshalBinary encodes the macaroon in the V2 binary format.
+func (m *Macaroon) MarshalBinary() []byte {
+	b := []byte{2}
+	if m.Location != "" {
+		b = appendMacaroonField(b, macaroonFieldLocation, []byte(m.Location))
+	}
+	b = appendMacaroonField(b, macaroonFieldIdentifier, m.ID)
+	b = append(b, macaroonFieldEOS)
+	for _, c := range m.Caveats {
+		b = appendMacaroonField(b, macaroonFieldIdentifier, []byte(c))
+		b = append(b, macaroonFieldEOS)
+	}
+	b = append(b, macaroonFieldEOS)
+	return appendMacaroonField(b, macaroonFieldSignature, m.Sig)
+}
+
+// macaroonReader walks V2 binary fields.
+type macaroonReader struct {
+	r *bytes.Reader
+}
+
+// next reads one field; EOS has no payload.
+func (mr macaroonReader) next() (int, []byte, error) {
+	field, err := binary.ReadUvarint(mr.r)
+	if err != nil {
+		return 0, nil, errors.New("truncated macaroon")
+	}
+	if field == macaroonFieldEOS {
+		return macaroonFieldEOS, nil, nil
+	}
+	n, err := binary.ReadUvarint(mr.r)
+	if err != nil || n > uint64(mr.r.Len()) {
+		return 0, nil, errors.New("truncated macaroon")
+	}
+	data := make([]byte, n)
+	mr.r.Read(data)
+	return int(field), data, nil
+}
+
+// UnmarshalMacaroon decodes a V2 binary macaroon. Third-party caveats (those
+// with a verification ID) are rejected: this service only mints first-party ones.
+func UnmarshalMacaroon(b []byte) (*Macaroon, error) {
+	if len(b) == 0 || b[0] != 2 {
+		return nil, errors.New("not a V2 macaroon")
+	}
+	mr := macaroonReader{r: bytes.NewReader(b[1:])}
+	m := &Macaroon{}
+
+	field, data, err := mr.next()
+	if err != nil {
+		return nil, err
+	}
+	if field == macaroonFieldLocation {
+		m.Location = string(data)
+		if field, data, err = mr.next(); err != nil {
+			return nil, err
+		}
+	}
+	if field != macaroonFieldIdentifier {
+		return nil, errors.New("macaroon has no identifier")
+	}
+	m.ID = data
+	if field, _, err = mr.next(); err != nil || field != macaroonFieldEOS {
+		return nil, errors.New("malformed macaroon header")
+	}
+
+	for {
+		field, data, err = mr.next()
+		if err != nil {
+			return nil, err
+		}
+		if field == macaroonFieldEOS {
+			break
+		}
+		if field == macaroonFieldLocation {
+			if field, data, err = mr.next(); err != nil {
+				return nil, err
+			}
+		}
+		if field != macaroonFieldIdentifier {
+			return nil, errors.New("malformed caveat")
+		}
+		caveat := string(data)
+		if field, _, err = mr.next(); err != nil {
+			return nil, err
+		}
+		if field == macaroonFieldVID {
+			return nil, errors.New("third-party caveats are not supported")
+		}
+		if field != macaroonFieldEOS {
+			return nil, errors.New("malformed caveat")
+		}
+		m.Caveats = append(m.Caveats, caveat)
+	}
+
+	field, data, err = mr.next()
+	if err != nil || field != macaroonFieldSignature || len(data) != sha256.Size {
+		return nil, errors.New("macaroon has no signature")
+	}
+	m.Sig = data
+	if mr.r.Len() != 0 {
+		return nil, errors.New("trailing bytes after macaroon")
+	}
+	return m, nil
+}
+
+// L402Token is what a verified L402 macaroon grants. The identifier follows the
+// L402 spec: a uint16 version (0), the 32-byte payment hash and a 32-byte token ID.
+type L402Token struct {
+	PaymentHash string   `json:"payment_hash"`
+	TokenID     string   `json:"token_id"`
+	ValidUntil  int64    `json:"valid_until"`
+	Endpoints   []string `json:"endpoints"`
+	MaxRequests int      `json:"max_requests"`
+}
+
+func l402Identifier(paymentHash, tokenID []byte) []byte {
+	id := make([]byte, 2, 66)
+	return append(append(id, paymentHash...), tokenID...)
+}
+
+// mintToken mints a macaroon bound to paymentHash granting maxRequests requests
+// to endpoints until now+TokenTTL, encoded as base64.
+func (m *L402Middleware) mintToken(paymentHash string, endpoints []string, maxRequests int) (string, error) {
+	hash, err := hex.DecodeString(paymentHash)
+	if err != nil || len(hash) != sha256.Size {
+		return "", fmt.Errorf("payment hash is not 32 bytes of hex")
+	}
+	tokenID := make([]byte, 32)
+	if _, err := rand.Read(tokenID); err != nil {
+		return "", err
+	}
+	mac := NewMacaroon(m.config.RootKey, l402Identifier(hash, tokenID), l402Location)
+	mac.AddCaveat(fmt.Sprintf("valid_until=%d", time.Now().Add(m.config.TokenTTL).Unix()))
+	mac.AddCaveat("endpoints=" + strings.Join(endpoints, ","))
+	mac.AddCaveat(fmt.Sprintf("max_requests=%d", maxRequests))
+	return base64.StdEncoding.EncodeToString(mac.MarshalBinary()), nil
+}
+
+// decodeToken checks a base64 macaroon's signature and reads its caveats.
+// Caveats can only narrow a token: a repeated caveat keeps the strictest value,
+// and any caveat this service doesn't understand makes the token invalid.
+func (m *L402Middleware) decodeToken(encoded string) (*L402Token, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		if raw, err = base64.URLEncoding.DecodeString(encoded); err != nil {
+			return nil, errors.New("macaroon is not base64")
+		}
+	}
+	mac, err := UnmarshalMacaroon(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !mac.Verify(m.config.RootKey) {
+		return nil, errors.New("invalid macaroon signature")
+	}
+	if len(mac.ID) != 66 || binary.BigEndian.Uint16(mac.ID) != 0 {
+		return nil, errors.New("unsupported macaroon identifier")
+	}
+	tok := &L402Token{
+		PaymentHash: hex.EncodeToString(mac.ID[2:34]),
+		TokenID:     hex.EncodeToString(mac.ID[34:]),
+	}
+	var hasExpiry, hasQuota bool
+	for _, c := range mac.Caveats {
+		key, value, _ := strings.Cut(c, "=")
+		switch key {
+		case "valid_until":
+			t, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || t <= 0 {
+				return nil, fmt.Errorf("invalid caveat %q", c)
+			}
+			if !hasExpiry || t < tok.ValidUntil {
+				tok.ValidUntil = t
+			}
+			hasExpiry = true
+		case "endpoints":
+			allowed := strings.Split(value, ",")
+			if tok.Endpoints != nil {
+				allowed = intersectStrings(tok.Endpoints, allowed)
+			}
+			tok.Endpoints = allowed
+		case "max_requests":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid caveat %q", c)
+			}
+			if !hasQuota || n < tok.MaxRequests {
+				tok.MaxRequests = n
+			}
+			hasQuota = true
+		default:
+			return nil, fmt.Errorf("unknown caveat %q", c)
+		}
+	}
+	if !hasExpiry || tok.Endpoints == nil || !hasQuota {
+		return nil, errors.New("macaroon is missing a required caveat")
+	}
+	return tok, nil
+}
+
+func intersectStrings(a, b []string) []string {
+	out := []string{}
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				out = append(out, x)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// checkPreimage reports whether preimage (hex) is the payment's preimage,
+// which proves the invoice was paid without asking LNbits.
+func (tok *L402Token) checkPreimage(preimage string) bool {
+	raw, err := hex.DecodeString(preimage)
+	if err != nil || len(raw) != 32 {
+		return false
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]) == tok.PaymentHash
+}
+
+func (tok *L402Token) allows(path string) bool {
+	for _, e := range tok.Endpoints {
+		if e == path {
+			return true
+		}
+	}
+	return false
+}
+
+// parseL402Credential splits "L402 <macaroon>:<preimage>" (or the older LSAT
+// scheme). ok is false for anything else, including a bare payment hash.
+func parseL402Credential(auth string) (macaroon, preimage string, ok bool) {
+	auth = strings.TrimSpace(auth)
+	for _, scheme := range []string{"L402 ", "LSAT "} {
+		if strings.HasPrefix(auth, scheme) {
+			macaroon, preimage, ok = strings.Cut(strings.TrimSpace(auth[len(scheme):]), ":")
+			return macaroon, preimage, ok && macaroon != ""
+		}
+	}
+	return "", "", false
+}
+
+// tokenUse counts the requests made with one token until it expires.
+type tokenUse struct {
+	Count     int       `json:"count"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// authorizeToken verifies a macaroon and preimage for a request to path and
+// uses up one of its requests. Counts are kept per process and saved to
+// L402_TOKENS_FILE, so a restart doesn't reset a quota. Replicas sharing
+// L402_ROOT_KEY each count on their own, so across replicas the quota is
+// best-effort: a token can make up to max_requests on each.
+func (m *L402Middleware) authorizeToken(encoded, preimage, path string) (*L402Token, error) {
+	tok, err := m.decodeToken(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if !tok.checkPreimage(preimage) {
+		return nil, errors.New("preimage does not match the macaroon's payment hash")
+	}
+	if time.Now().Unix() >= tok.ValidUntil {
+		return nil, errors.New("token expired")
+	}
+	if !tok.allows(path) {
+		return nil, fmt.Errorf("token is not valid for %s", path)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	use := m.tokenUses[tok.TokenID]
+	if use == nil {
+		use = &tokenUse{ExpiresAt: time.Unix(tok.ValidUntil, 0)}
+		m.tokenUses[tok.TokenID] = use
+	}
+	if use.Count >= tok.MaxRequests {
+		return nil, errors.New("token request quota used up")
+	}
+	use.Count++
+	m.tokenUsesDirty = true
+	return tok, nil
+}
+
+// tokenUsed returns how many requests a token has made.
+func (m *L402Middleware) tokenUsed(tokenID string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if use := m.tokenUses[tokenID]; use != nil {
+		return use.Count
+	}
+	return 0
+}
+
+// LoadTokenUses restores token request counts from the file. A missing file
+// is not an error, and expired tokens are dropped.
+func (m *L402Middleware) LoadTokenUses() error {
+	path := m.config.TokenUsesFile
+	if path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var uses map[string]*tokenUse
+	if err := json.Unmarshal(raw, &uses); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, use := range uses {
+		if use != nil && now.Before(use.ExpiresAt) {
+			m.tokenUses[id] = use
+		}
+	}
+	return nil
+}
+
+// saveTokenUses writes token request counts to the file if they changed.
+func (m *L402Middleware) saveTokenUses() {
+	m.mu.Lock()
+	if m.config.TokenUsesFile == "" || !m.tokenUsesDirty {
+		m.mu.Unlock()
+		return
+	}
+	raw, err := json.Marshal(m.tokenUses)
+	m.tokenUsesDirty = false
+	m.mu.Unlock()
+	if err == nil {
+		err = writeFileAtomic(m.config.TokenUsesFile, raw)
+	}
+	if err != nil {
+		log.Printf("L402: saving token uses failed: %v", err)
+	}
+}
+
+// RunTokenUses saves changed token request counts periodically until ctx ends.
+func (m *L402Middleware) RunTokenUses(ctx context.Context) {
+	ticker := time.NewTicker(accountSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			m.saveTokenUses()
+			return
+		case <-ticker.C:
+			m.saveTokenUses()
+		}
+	}
+}
+
+// L402TokenStatus describes a presented token on /l402/info.
+type L402TokenStatus struct {
+	Valid     bool   `json:"valid"`
+	Paid      bool   `json:"paid"` // a matching preimage was presented
+	Error     string `json:"error,omitempty"`
+	Used      int    `json:"used"`
+	Remaining int    `json:"remaining"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+	*L402Token
+}
+
+// L402InfoResponse is the /l402/info body. token_ttl_seconds,
+// free_tier_per_ip_per_day and priced_endpoints appear when L402 is enabled, and
+// token when a macaroon was presented. zap is free-form.
+type L402InfoResponse struct {
+	Enabled             bool                   `json:"enabled"`
+	Scheme              string                 `json:"scheme"`
+	Authorization       string                 `json:"authorization"`
+	Caveats             map[string]string      `json:"caveats"`
+	MaxRequestsPerToken int                    `json:"max_requests_per_token"`
+	RequestsQueryParam  string                 `json:"requests_query_param"`
+	TokenTTLSeconds     int                    `json:"token_ttl_seconds,omitempty"`
+	FreeTierPerIPPerDay *int                   `json:"free_tier_per_ip_per_day,omitempty"`
+	PricedEndpoints     []PricingEndpoint      `json:"priced_endpoints,omitempty"`
+	Token               *L402TokenStatus       `json:"token,omitempty"`
+	Zap                 map[string]interface{} `json:"zap"`
+}
+
+// handleL402Info handles GET /l402/info
+// Describes L402 pricing and how to present a token. With a token in the
+// Authorization header (the preimage is optional here) or ?macaroon=, it also
+// reports the token's caveats and remaining requests without using one up.
+func handleL402Info(w http.ResponseWriter, r *http.Request, m *L402Middleware) {
+	resp := L402InfoResponse{
+		Enabled:       m != nil,
+		Scheme:        "L402",
+		Authorization: "Authorization: L402 <base64 macaroon>:<hex preimage>",
+		Caveats: map[string]string{
+			"valid_until":  "Unix time after which the token is rejected",
+			"endpoints":    "Comma-separated paths the token may be used on",
+			"max_requests": "Requests the token is good for",
+		},
+		MaxRequestsPerToken: l402MaxRequestsPerToken,
+		RequestsQueryParam:  "l402_requests",
+		Zap:                 zapCredits.Stats(),
+	}
+	if m == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+	resp.TokenTTLSeconds = int(m.config.TokenTTL.Seconds())
+	resp.FreeTierPerIPPerDay = &m.config.FreeTier
+	resp.PricedEndpoints = pricedEndpointsSorted(m.pricedEndpoints)
+
+	encoded, preimage := r.URL.Query().Get("macaroon"), ""
+	if mac, pre, ok := parseL402Credential(r.Header.Get("Authorization")); ok {
+		encoded, preimage = mac, pre
+	} else if auth := strings.TrimSpace(r.Header.Get("Authorization")); strings.HasPrefix(auth, "L402 ") && encoded == "" {
+		encoded = strings.TrimSpace(strings.TrimPrefix(auth, "L402 "))
+	}
+	if encoded != "" {
+		status := L402TokenStatus{}
+		tok, err := m.decodeToken(encoded)
+		if err != nil {
+			status.Error = err.Error()
+		} else {
+			status.L402Token = tok
+			status.Valid = time.Now().Unix() < tok.ValidUntil
+			if !status.Valid {
+				status.Error = "token expired"
+			}
+			status.Paid = preimage != "" && tok.checkPreimage(preimage)
+			status.Used = m.tokenUsed(tok.TokenID)
+			status.Remaining = max(tok.MaxRequests-status.Used, 0)
+			status.ExpiresAt = time.Unix(tok.ValidUntil, 0).UTC().Format(time.RFC3339)
+			sort.Strings(tok.Endpoints)
+		}
+		resp.Token = &status
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
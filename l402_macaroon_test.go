@@ -0,0 +1,302 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPreimage returns a preimage and its payment hash, both hex.
+func testPreimage(b byte) (string, string) {
+	preimage := make([]byte, 32)
+	for i := range preimage {
+		preimage[i] = b
+	}
+	hash := sha256.Sum256(preimage)
+	return hex.EncodeToString(preimage), hex.EncodeToString(hash[:])
+}
+
+func newTestL402(t *testing.T, lnbitsURL string) *L402Middleware {
+	t.Helper()
+	return NewL402Middleware(L402Config{
+		LNbitsURL:    lnbitsURL,
+		LNbitsAPIKey: "test-key",
+		RootKey:      []byte("0123456789abcdef0123456789abcdef"),
+		TokenTTL:     time.Hour,
+	})
+}
+
+func TestMacaroonRoundTrip(t *testing.T) {
+	root := []byte("root key")
+	m := NewMacaroon(root, []byte("id"), "here")
+	m.AddCaveat("a=1")
+	m.AddCaveat("b=2")
+
+	decoded, err := UnmarshalMacaroon(m.MarshalBinary())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if decoded.Location != "here" || string(decoded.ID) != "id" || len(decoded.Caveats) != 2 || decoded.Caveats[1] != "b=2" {
+		t.Errorf("unexpected decoded macaroon %+v", decoded)
+	}
+	if !decoded.Verify(root) || decoded.Verify([]byte("other key")) {
+		t.Errorf("expected the macaroon to verify only against its root key")
+	}
+
+	decoded.Caveats = decoded.Caveats[:1]
+	if decoded.Verify(root) {
+		t.Errorf("expected a removed caveat to break the signature")
+	}
+
+	raw := m.MarshalBinary()
+	for _, bad := range [][]byte{nil, {1}, raw[:len(raw)-1], append(append([]byte{}, raw...), 0)} {
+		if _, err := UnmarshalMacaroon(bad); err == nil {
+			t.Errorf("expected %x rejected", bad)
+		}
+	}
+}
+
+func TestL402TokenVerify(t *testing.T) {
+	m := newTestL402(t, "http://localhost:5000")
+	preimage, hash := testPreimage(7)
+	token, err := m.mintToken(hash, []string{"/score"}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	tok, err := m.decodeToken(token)
+	if err != nil || tok.PaymentHash != hash || tok.MaxRequests != 2 || tok.Endpoints[0] != "/score" {
+		t.Fatalf("unexpected token %+v %v", tok, err)
+	}
+
+	if _, err := m.authorizeToken(token, preimage, "/batch"); err == nil {
+		t.Errorf("expected the token rejected on another endpoint")
+	}
+	wrong, _ := testPreimage(8)
+	if _, err := m.authorizeToken(token, wrong, "/score"); err == nil {
+		t.Errorf("expected the token rejected with the wrong preimage")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := m.authorizeToken(token, preimage, "/score"); err != nil {
+			t.Fatalf("request %d: unexpected error %v", i+1, err)
+		}
+	}
+	if _, err := m.authorizeToken(token, preimage, "/score"); err == nil || !strings.Contains(err.Error(), "quota") {
+		t.Errorf("expected the quota used up, got %v", err)
+	}
+
+	// Another server's root key doesn't verify
+	other := NewL402Middleware(L402Config{FreeTier: 1})
+	if _, err := other.decodeToken(token); err == nil {
+		t.Errorf("expected a foreign root key rejected")
+	}
+}
+
+func TestL402TokenCaveats(t *testing.T) {
+	m := newTestL402(t, "http://localhost:5000")
+	preimage, hash := testPreimage(9)
+	raw, _ := hex.DecodeString(hash)
+	mint := func(caveats ...string) string {
+		mac := NewMacaroon(m.config.RootKey, l402Identifier(raw, make([]byte, 32)), l402Location)
+		for _, c := range caveats {
+			mac.AddCaveat(c)
+		}
+		return base64.StdEncoding.EncodeToString(mac.MarshalBinary())
+	}
+	future := fmt.Sprintf("valid_until=%d", time.Now().Add(time.Hour).Unix())
+
+	if _, err := m.authorizeToken(mint(fmt.Sprintf("valid_until=%d", time.Now().Add(-time.Minute).Unix()), "endpoints=/score", "max_requests=1"), preimage, "/score"); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("expected an expired token rejected, got %v", err)
+	}
+	if _, err := m.decodeToken(mint(future, "endpoints=/score", "max_requests=1", "ip=1.2.3.4")); err == nil {
+		t.Errorf("expected an unknown caveat rejected")
+	}
+	if _, err := m.decodeToken(mint(future, "endpoints=/score")); err == nil {
+		t.Errorf("expected a token without a quota rejected")
+	}
+
+	// Holders can narrow a token, but a later caveat can't widen it
+	tok, err := m.decodeToken(mint(future, "endpoints=/score,/batch", "max_requests=5", "endpoints=/batch,/rank", "max_requests=50"))
+	if err != nil || len(tok.Endpoints) != 1 || tok.Endpoints[0] != "/batch" || tok.MaxRequests != 5 {
+		t.Errorf("expected the strictest caveats kept, got %+v %v", tok, err)
+	}
+
+	// A zero can't reset a limit for a wider one to follow
+	for _, widen := range [][]string{
+		{"valid_until=0", "valid_until=99999999999"},
+		{"max_requests=0", "max_requests=1000000"},
+		{"valid_until=-1"},
+		{"max_requests=-5"},
+	} {
+		caveats := append([]string{future, "endpoints=/score", "max_requests=1"}, widen...)
+		if tok, err := m.decodeToken(mint(caveats...)); err == nil && (tok.MaxRequests != 1 || tok.ValidUntil > time.Now().Add(time.Hour).Unix()) {
+			t.Errorf("%v: expected the token rejected or its limits unchanged, got %+v", widen, tok)
+		}
+	}
+}
+
+func TestL402TokenUsesPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	newServer := func() *L402Middleware {
+		m := newTestL402(t, "http://localhost:5000")
+		m.config.TokenUsesFile = path
+		if err := m.LoadTokenUses(); err != nil {
+			t.Fatalf("unexpected load error %v", err)
+		}
+		return m
+	}
+	preimage, hash := testPreimage(9)
+
+	m := newServer()
+	token, _ := m.mintToken(hash, []string{"/score"}, 2)
+	if _, err := m.authorizeToken(token, preimage, "/score"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	m.tokenUses["expired"] = &tokenUse{Count: 1, ExpiresAt: time.Now().Add(-time.Minute)}
+	m.saveTokenUses()
+
+	// a restart keeps the count, so the token has one request left
+	m = newServer()
+	if _, ok := m.tokenUses["expired"]; ok {
+		t.Error("expected expired tokens dropped on load")
+	}
+	if _, err := m.authorizeToken(token, preimage, "/score"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := m.authorizeToken(token, preimage, "/score"); err == nil || !strings.Contains(err.Error(), "quota") {
+		t.Errorf("expected the quota used up across the restart, got %v", err)
+	}
+}
+
+func TestParseL402Credential(t *testing.T) {
+	for auth, want := range map[string]bool{
+		"L402 abc:def":  true,
+		"LSAT abc:def":  true,
+		"L402 deadbeef": false, // bare payment hash
+		"L402 :def":     false,
+		"Bearer abc":    false,
+	} {
+		if _, _, ok := parseL402Credential(auth); ok != want {
+			t.Errorf("%q: expected %v", auth, want)
+		}
+	}
+}
+
+func TestL402MacaroonFlow(t *testing.T) {
+	preimage, hash := testPreimage(10)
+	var invoiced float64
+	mockLNbits := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&req)
+		invoiced, _ = req["amount"].(float64)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"payment_request": "lnbc30n1ptest",
+			"payment_hash":    hash,
+		})
+	}))
+	defer mockLNbits.Close()
+	m := newTestL402(t, mockLNbits.URL)
+	handler := m.Wrap(dummyHandler())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/score?pubkey=abc&l402_requests=3", nil))
+	if w.Code != http.StatusPaymentRequired {
+		t.Fatalf("expected 402, got %d", w.Code)
+	}
+	var body struct {
+		AmountSats int64 `json:"amount_sats"`
+		Protocols  struct {
+			L402 struct {
+				Macaroon    string `json:"macaroon"`
+				MaxRequests int    `json:"max_requests"`
+			} `json:"l402"`
+		} `json:"protocols"`
+	}
+	json.NewDecoder(w.Body).Decode(&body)
+	macaroon := body.Protocols.L402.Macaroon
+	if body.AmountSats != 3 || invoiced != 3 || body.Protocols.L402.MaxRequests != 3 || macaroon == "" {
+		t.Fatalf("expected a 3-request macaroon for 3 sats, got %+v (invoiced %v)", body, invoiced)
+	}
+	if auth := w.Header().Get("WWW-Authenticate"); !strings.Contains(auth, `macaroon="`+macaroon+`"`) {
+		t.Errorf("expected the macaroon in WWW-Authenticate, got %q", auth)
+	}
+
+	serve := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "L402 "+macaroon+":"+preimage)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	for i := 0; i < 3; i++ {
+		if code := serve("/score?pubkey=abc"); code != http.StatusOK {
+			t.Fatalf("paid request %d: expected 200, got %d", i+1, code)
+		}
+	}
+	if code := serve("/score?pubkey=abc"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 once the quota is used, got %d", code)
+	}
+	if code := serve("/batch"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 outside the token's scope, got %d", code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/score?pubkey=abc&l402_requests=500", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for too many requests, got %d", w.Code)
+	}
+}
+
+func TestHandleL402Info(t *testing.T) {
+	m := newTestL402(t, "http://localhost:5000")
+	preimage, hash := testPreimage(11)
+	token, _ := m.mintToken(hash, []string{"/score"}, 4)
+	m.authorizeToken(token, preimage, "/score")
+
+	req := httptest.NewRequest("GET", "/l402/info", nil)
+	req.Header.Set("Authorization", "L402 "+token+":"+preimage)
+	w := httptest.NewRecorder()
+	handleL402Info(w, req, m)
+
+	var resp struct {
+		Enabled         bool             `json:"enabled"`
+		TokenTTL        int              `json:"token_ttl_seconds"`
+		PricedEndpoints []interface{}    `json:"priced_endpoints"`
+		Token           *L402TokenStatus `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unexpected body %s", w.Body.String())
+	}
+	if !resp.Enabled || resp.TokenTTL != 3600 || len(resp.PricedEndpoints) == 0 {
+		t.Errorf("unexpected metadata %s", w.Body.String())
+	}
+	tok := resp.Token
+	if tok == nil || !tok.Valid || !tok.Paid || tok.Used != 1 || tok.Remaining != 3 || tok.L402Token == nil || tok.PaymentHash != hash {
+		t.Fatalf("unexpected token status %s", w.Body.String())
+	}
+	if m.tokenUsed(tok.TokenID) != 1 {
+		t.Errorf("expected checking status not to use a request")
+	}
+
+	w = httptest.NewRecorder()
+	handleL402Info(w, httptest.NewRequest("GET", "/l402/info?macaroon=garbage", nil), m)
+	if !strings.Contains(w.Body.String(), `"valid":false`) {
+		t.Errorf("expected an invalid token reported, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handleL402Info(w, httptest.NewRequest("GET", "/l402/info", nil), nil)
+	if !strings.Contains(w.Body.String(), `"enabled":false`) {
+		t.Errorf("expected the paywall reported disabled, got %s", w.Body.String())
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"math"
 	"net/http"
 	"os"
-	"os/exec"
 	"sort"
 	"strings"
 	"sync"
@@ -18,22 +17,20 @@ import (
 	"github.com/nbd-wtf/go-nostr/nip19"
 )
 
-var relays = []string{
-	"wss://relay.damus.io",
-	"wss://nos.lol",
-	"wss://relay.primal.net",
-	"wss://nip85.nostr1.com",
-	"wss://nip85.brainstorm.world",
-}
-
 // Graph stores the follow relationships
 type Graph struct {
-	mu          sync.RWMutex
-	follows     map[string][]string    // pubkey -> list of followed pubkeys
-	followers   map[string][]string    // pubkey -> list of followers
-	scores      map[string]float64     // pubkey -> PageRank score
-	followTimes map[string]time.Time   // "from:to" -> when the follow was created
-	lastBuild   time.Time
+	mu           sync.RWMutex
+	follows      map[string][]string   // pubkey -> list of followed pubkeys
+	followers    map[string][]string   // pubkey -> list of followers
+	scores       map[string]float64    // pubkey -> PageRank score
+	followTimes  map[string]time.Time  // "from:to" -> when the follow was created
+	listTimes    map[string]time.Time  // pubkey -> created_at of the contact list its follows came from
+	buckets      []PercentileBucket    // percentile tier boundaries from the last rebuild
+	dist         *rawScoreDistribution // raw score distribution from the last rebuild
+	lastBuild    time.Time
+	scoreUpdated map[string]time.Time // pubkey -> when an incremental update last changed its score
+	drift        IncrementalDrift     // incremental updates since lastBuild
+	graphVersioning
 }
 
 func NewGraph() *Graph {
@@ -42,58 +39,101 @@ func NewGraph() *Graph {
 		followers:   make(map[string][]string),
 		scores:      make(map[string]float64),
 		followTimes: make(map[string]time.Time),
+		listTimes:   make(map[string]time.Time),
 	}
 }
 
 func (g *Graph) AddFollow(from, to string) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	g.changed()
 	g.follows[from] = append(g.follows[from], to)
 	g.followers[to] = append(g.followers[to], from)
 }
 
-// PageRank computes scores over the follow graph
-func (g *Graph) ComputePageRank(iterations int, damping float64) {
+// SetFollows applies a kind 3 contact list with replaceable-event semantics: when
+// createdAt is newer than the list author's follows were last taken from, the
+// author's follows become follows (deduplicated), edges to unfollowed pubkeys are
+// removed from both maps, and the added and removed follows are returned. ok is
+// false, and nothing changes, for a list that is not newer. Follows kept from the
+// previous list keep their original follow time.
+func (g *Graph) SetFollows(author string, follows []string, createdAt time.Time) (added, removed []string, ok bool) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	// Collect all nodes
-	nodes := make(map[string]bool)
-	for k, vs := range g.follows {
-		nodes[k] = true
-		for _, v := range vs {
-			nodes[v] = true
+	if g.followTimes == nil {
+		g.followTimes = make(map[string]time.Time)
+	}
+	if g.listTimes == nil {
+		g.listTimes = make(map[string]time.Time)
+	}
+	// follows added with AddFollowWithTime carry their list's created_at too
+	latest := g.listTimes[author]
+	for _, to := range g.follows[author] {
+		if t := g.followTimes[author+":"+to]; t.After(latest) {
+			latest = t
 		}
 	}
-
-	n := float64(len(nodes))
-	if n == 0 {
-		return
+	if _, known := g.listTimes[author]; (known || !latest.IsZero()) && !createdAt.After(latest) {
+		return nil, nil, false
 	}
+	g.changed()
+	g.listTimes[author] = createdAt
 
-	// Initialize scores uniformly
-	scores := make(map[string]float64)
-	for node := range nodes {
-		scores[node] = 1.0 / n
+	old := make(map[string]bool)
+	for _, to := range g.follows[author] {
+		old[to] = true
 	}
-
-	for i := 0; i < iterations; i++ {
-		newScores := make(map[string]float64)
-		for node := range nodes {
-			sum := 0.0
-			for _, follower := range g.followers[node] {
-				outDegree := len(g.follows[follower])
-				if outDegree > 0 {
-					sum += scores[follower] / float64(outDegree)
-				}
+	next := make(map[string]bool)
+	list := make([]string, 0, len(follows))
+	for _, to := range follows {
+		if to == author || next[to] {
+			continue
+		}
+		next[to] = true
+		list = append(list, to)
+		if !old[to] {
+			added = append(added, to)
+			g.followers[to] = append(g.followers[to], author)
+			if !createdAt.IsZero() {
+				g.followTimes[author+":"+to] = createdAt
+			}
+		}
+	}
+	for to := range old {
+		if next[to] {
+			continue
+		}
+		removed = append(removed, to)
+		delete(g.followTimes, author+":"+to)
+		fs := make([]string, 0, len(g.followers[to])) // snapshots may share the old list
+		for _, f := range g.followers[to] {
+			if f != author {
+				fs = append(fs, f)
 			}
-			newScores[node] = (1-damping)/n + damping*sum
 		}
-		scores = newScores
+		g.followers[to] = fs
 	}
+	g.follows[author] = list
+	return added, removed, true
+}
+
+// ComputePageRank computes scores over the follow graph. The graph is indexed under
+// the read lock, iterated without holding any lock, and the finished scores are
+// swapped in under a brief write lock, so lookups keep being served throughout.
+// Follows added while it runs count from the next computation.
+func (g *Graph) ComputePageRank(iterations int, damping float64) {
+	g.mu.RLock()
+	pg := newPageRankGraph(g.follows, g.followers, nil, nil)
+	g.mu.RUnlock()
 
-	g.scores = scores
-	g.lastBuild = time.Now()
+	scores := pg.run(iterations, damping)
+	if scores == nil {
+		return
+	}
+	g.mu.Lock()
+	g.setComputedScores(scores)
+	g.mu.Unlock()
 }
 
 func (g *Graph) GetScore(pubkey string) (float64, bool) {
@@ -182,6 +222,14 @@ func (g *Graph) Rank(pubkey string) int {
 	return rank
 }
 
+// NodeCount returns how many pubkeys are scored, without the edge count Stats
+// walks the graph for.
+func (g *Graph) NodeCount() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.scores)
+}
+
 func (g *Graph) Stats() GraphStats {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
@@ -247,59 +295,119 @@ var meta = NewMetaStore()
 var events = NewEventStore()
 var external = NewExternalStore()
 var externalAssertions = NewAssertionStore()
+var assertionArchive = NewAssertionArchiveFromEnv()
 var authStore = NewAuthStore()
 var muteStore = NewMuteStore()
 var communities = NewCommunityDetector()
+var communityHistory = NewCommunityHistory()
+var annotations = NewAnnotationStoreFromEnv()
+var endorsements = NewEndorsementStore()
+var gamingReports = NewGamingReportStore()
+var analytics = NewAnalyticsStore()
+var zapStore = NewZapStore()
+var graphBuild = NewGraphBuild()
 var wsHub = NewWSHub(graph)
+var rebuilder = NewRebuildController(func() []RebuildPhase { return nil })
 var startTime = time.Now()
 
-func crawlFollows(ctx context.Context, seedPubkeys []string, depth int) {
+// rebuildInterval is how often the scheduled re-crawl and rebuild runs.
+const rebuildInterval = 6 * time.Hour
+
+// crawlMu serializes follow crawls: the rebuild's and each seed view's.
+var crawlMu sync.Mutex
+
+// crawlFollows walks kind 3 contact lists breadth-first from the seeds into g. With
+// seedBudget above zero, each seed's neighborhood queues at most that many pubkeys.
+// Contact lists are fetched through crawlScheduler, which bounds the load on each
+// relay and retries failed requests. progress, if non-nil, receives the fraction
+// of the crawl completed. It returns how each seed's budget was spent, with ok
+// false when no relay could be reached.
+func crawlFollows(ctx context.Context, g *Graph, seedPubkeys []string, depth, seedBudget int, progress func(float64)) (report SeedCrawlReport, ok bool) {
 	pool := nostr.NewSimplePool(ctx)
+	urls := relayHealth.Probe(relayLimits.Crawlable(config.Relays()), func(url string) error {
+		_, err := pool.EnsureRelay(url)
+		return err
+	})
+	if len(urls) == 0 {
+		return SeedCrawlReport{}, false
+	}
 	seen := make(map[string]bool)
 	queue := seedPubkeys
+	budget := newSeedBudget(seedPubkeys, seedBudget)
+	defer func() { report, ok = budget.report(time.Now()), true }()
+	fetch := poolFetch(pool)
+	crawlScheduler.Begin("follows", depth)
+	defer crawlScheduler.End()
 
 	for d := 0; d < depth && len(queue) > 0; d++ {
 		log.Printf("Crawl depth %d: %d pubkeys to process", d, len(queue))
+		crawlScheduler.SetDepth(d, len(queue))
 		var nextQueue []string
 
-		// Process in batches
-		batchSize := 50
-		for i := 0; i < len(queue); i += batchSize {
-			end := i + batchSize
-			if end > len(queue) {
-				end = len(queue)
+		// Contact lists pushed by partner relays since the last crawl are already
+		// in the shared graph; walk their follows without asking relays again
+		pending := queue[:0:0]
+		pushed := 0
+		for _, pk := range queue {
+			if seen[pk] || !ingestStore.ContactListFresh(pk) {
+				pending = append(pending, pk)
+				continue
+			}
+			seen[pk] = true
+			pushed++
+			if g != graph {
+				g.SetFollows(pk, graph.GetFollows(pk), time.Time{})
+			}
+			for _, target := range g.GetFollows(pk) {
+				if !seen[target] && budget.admit(pk, target) {
+					nextQueue = append(nextQueue, target)
+				}
 			}
-			batch := queue[i:end]
+		}
+		if pushed > 0 {
+			log.Printf("Crawl depth %d: %d contact lists already pushed by partners", d, pushed)
+		}
+		queue = pending
 
-			filter := nostr.Filter{
+		// Batches of one contact list per author, each sent to every relay
+		batchSize := crawlBatchSize(50, 1)
+		var filters []nostr.Filter
+		for i := 0; i < len(queue); i += batchSize {
+			batch := queue[i:min(i+batchSize, len(queue))]
+			filters = append(filters, nostr.Filter{
 				Kinds:   []int{3}, // kind 3 = contact list
 				Authors: batch,
 				Limit:   len(batch),
+			})
+		}
+		var depthProgress func(float64)
+		if progress != nil {
+			depthProgress = func(f float64) { progress((float64(d) + f) / float64(depth)) }
+		}
+		crawlScheduler.Run(ctx, fetch, urls, filters, func(ev *nostr.Event) {
+			// Relays may return different versions of the same list; the newest
+			// one wins, and a list already applied by an earlier crawl is a no-op
+			author := ev.PubKey
+			contactHistory.Record(ev, "crawl")
+			g.SetFollows(author, contactListFollows(ev), ev.CreatedAt.Time())
+			if seen[author] {
+				return
 			}
+			seen[author] = true
 
-			evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
-			for ev := range evCh {
-				author := ev.Event.PubKey
-				if seen[author] {
-					continue
-				}
-				seen[author] = true
-
-				eventTime := ev.Event.CreatedAt.Time()
-				for _, tag := range ev.Event.Tags {
-					if tag[0] == "p" && len(tag) >= 2 {
-						target := tag[1]
-						graph.AddFollowWithTime(author, target, eventTime)
-						if !seen[target] {
-							nextQueue = append(nextQueue, target)
-						}
-					}
+			for _, target := range g.GetFollows(author) {
+				if !seen[target] && budget.admit(author, target) {
+					nextQueue = append(nextQueue, target)
 				}
 			}
+		}, depthProgress)
+		if ctx.Err() != nil {
+			return
 		}
 		queue = nextQueue
-		log.Printf("Crawl depth %d complete: graph has %d nodes, %d edges", d, len(seen), countEdges(graph.follows))
+		log.Printf("Crawl depth %d complete: graph has %d nodes, %d edges", d, len(seen), countEdges(g.follows))
 	}
+	return
 }
 
 func normalizeScore(raw float64, total int) int {
@@ -332,60 +440,155 @@ func resolvePubkey(input string) (string, error) {
 	return input, nil
 }
 
+// ScoreResponse is the /score body. Fields tagged omitempty appear only when
+// they apply to the pubkey or the request.
+type ScoreResponse struct {
+	Pubkey            string          `json:"pubkey"`
+	RawScore          float64         `json:"raw_score"`
+	Score             int             `json:"score"`
+	Normalization     string          `json:"normalization"`
+	View              string          `json:"view,omitempty"`
+	Found             bool            `json:"found"`
+	GraphSize         int             `json:"graph_size"`
+	Followers         int             `json:"followers"`
+	PostCount         int             `json:"post_count"`
+	ReplyCount        int             `json:"reply_count"`
+	Reactions         int             `json:"reactions"`
+	ZapAmount         int64           `json:"zap_amount"`
+	ZapCount          int             `json:"zap_count"`
+	VerifiedZapAmount int64           `json:"verified_zap_amount"`
+	PercentileBucket  int             `json:"percentile_bucket,omitempty"`
+	Staleness         *ScoreStaleness `json:"staleness,omitempty"` // found pubkeys
+	Crawl             string          `json:"crawl,omitempty"`     // pubkeys not found
+
+	Topics           []string `json:"topics,omitempty"`
+	ActiveHoursStart *int     `json:"active_hours_start,omitempty"`
+	ActiveHoursEnd   *int     `json:"active_hours_end,omitempty"`
+	ReportsReceived  int      `json:"reports_received,omitempty"`
+	ReportsSent      int      `json:"reports_sent,omitempty"`
+
+	DistrustPenalty       *float64            `json:"distrust_penalty,omitempty"`
+	DistrustAdjustedScore *int                `json:"distrust_adjusted_score,omitempty"`
+	CompositeScore        *int                `json:"composite_score,omitempty"`
+	ExternalAssertions    []ExternalSource    `json:"external_assertions,omitempty"`
+	EndorsedScore         int                 `json:"endorsed_score,omitempty"`
+	EndorsementBoost      int                 `json:"endorsement_boost,omitempty"`
+	InheritedTrust        *InheritedTrust     `json:"inherited_trust,omitempty"`
+	MigratedScore         *int                `json:"migrated_score,omitempty"`
+	MigratedTo            string              `json:"migrated_to,omitempty"`
+	Annotations           []AnnotationSummary `json:"annotations,omitempty"`
+
+	Algorithm string `json:"algorithm,omitempty"` // "hits" with the HITS fields
+	*HITSSummary
+}
+
+// InheritedTrust is the trust a pubkey inherits from a confirmed key migration.
+type InheritedTrust struct {
+	From        string `json:"from"`
+	OldScore    int    `json:"old_score"`
+	Inherited   int    `json:"inherited"`
+	ConfirmedBy int    `json:"confirmed_by"`
+}
+
 func handleScore(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
+	g, view := viewGraph(w, r)
+	if g == nil {
 		return
 	}
-
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	algorithm := q.OneOf("algorithm", "pagerank", "hits")
+	if q.Failed(w) {
+		return
+	}
+	curve, ok := parseNormalization(r.URL.Query())
+	if !ok {
+		writeAPIError(w, invalidParam("normalization", "normalization must be log, percentile, zscore or minmax"))
 		return
 	}
 
-	score, ok := graph.GetScore(pubkey)
-	stats := graph.Stats()
+	score, ok := g.GetScore(pubkey)
+	stats := g.Stats()
 	m := meta.Get(pubkey)
 
-	internalScore := normalizeScore(score, stats.Nodes)
+	internalScore := g.NormalizeScore(score, curve)
 	extAssertions := externalAssertions.GetForSubject(pubkey)
 	compositeScore, extSources := CompositeScore(internalScore, extAssertions, externalAssertions)
 
-	resp := map[string]interface{}{
-		"pubkey":     pubkey,
-		"raw_score":  score,
-		"score":      internalScore,
-		"found":      ok,
-		"graph_size": stats.Nodes,
-		"followers":     m.Followers,
-		"post_count":    m.PostCount,
-		"reply_count":   m.ReplyCount,
-		"reactions":     m.ReactionsRecd,
-		"zap_amount":    m.ZapAmtRecd,
-		"zap_count":     m.ZapCntRecd,
+	resp := ScoreResponse{
+		Pubkey:            pubkey,
+		RawScore:          score,
+		Score:             internalScore,
+		Normalization:     curve,
+		View:              view,
+		Found:             ok,
+		GraphSize:         stats.Nodes,
+		Followers:         m.Followers,
+		PostCount:         m.PostCount,
+		ReplyCount:        m.ReplyCount,
+		Reactions:         m.ReactionsRecd,
+		ZapAmount:         m.ZapAmtRecd,
+		ZapCount:          m.ZapCntRecd,
+		VerifiedZapAmount: m.VerifiedZapAmtRecd,
+		PercentileBucket:  g.PercentileBucket(pubkey),
+		ReportsReceived:   m.ReportsRecd,
+		ReportsSent:       m.ReportsSent,
+	}
+	if ok {
+		// Live contact lists update scores incrementally between full rebuilds
+		staleness := g.Staleness(pubkey, time.Now())
+		resp.Staleness = &staleness
+	} else {
+		resp.Crawl = "POST /crawl to fetch this pubkey from relays"
 	}
 
 	// NIP-85 extended metadata
-	if topics := m.TopTopics(5); len(topics) > 0 {
-		resp["topics"] = topics
-	}
+	resp.Topics = m.TopTopics(5)
 	activeStart, activeEnd := m.ActiveHours()
 	if activeStart != activeEnd {
-		resp["active_hours_start"] = activeStart
-		resp["active_hours_end"] = activeEnd
-	}
-	if m.ReportsRecd > 0 {
-		resp["reports_received"] = m.ReportsRecd
+		resp.ActiveHoursStart = &activeStart
+		resp.ActiveHoursEnd = &activeEnd
 	}
-	if m.ReportsSent > 0 {
-		resp["reports_sent"] = m.ReportsSent
+	if d, ok := distrust.Get(pubkey); ok {
+		penalty := math.Round(d.Penalty*10000) / 10000
+		adjusted := distrustAdjustedScore(internalScore, d)
+		resp.DistrustPenalty = &penalty
+		resp.DistrustAdjustedScore = &adjusted
 	}
 
 	if len(extSources) > 0 {
-		resp["composite_score"] = compositeScore
-		resp["external_assertions"] = extSources
+		resp.CompositeScore = &compositeScore
+		resp.ExternalAssertions = extSources
+	}
+
+	if e := evaluateEndorsements(endorsements, pubkey, internalScore); e.Boost > 0 {
+		resp.EndorsedScore = e.AdjustedScore
+		resp.EndorsementBoost = e.Boost
+	}
+
+	// Trust passed on by a confirmed key migration; score stays this key's own
+	if inherited, ok := inheritedTrust(g, pubkey); ok {
+		resp.InheritedTrust = &InheritedTrust{
+			From:        inherited.From,
+			OldScore:    inherited.OldScore,
+			Inherited:   inherited.Inherited,
+			ConfirmedBy: inherited.ConfirmedBy,
+		}
+		migrated := max(internalScore, inherited.Inherited)
+		resp.MigratedScore = &migrated
+	}
+	if m, ok := migrations.MigratedTo(pubkey); ok {
+		resp.MigratedTo = m.To
+	}
+
+	resp.Annotations = annotationSummaries(annotations, pubkey)
+
+	// Hub and authority scores; score stays PageRank
+	if algorithm == "hits" {
+		h, _ := hitsScores.Get(pubkey)
+		hits := newHITSSummary(h, stats.Nodes)
+		resp.Algorithm = "hits"
+		resp.HITSSummary = &hits
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -394,206 +597,340 @@ func handleScore(w http.ResponseWriter, r *http.Request) {
 
 // handleAudit explains why a pubkey has its score, breaking down all components.
 func handleAudit(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
 		return
 	}
 
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+	curve, ok := parseNormalization(r.URL.Query())
+	if !ok {
+		writeAPIError(w, invalidParam("normalization", "normalization must be log, percentile, zscore or minmax"))
 		return
 	}
 
-	rawScore, found := graph.GetScore(pubkey)
-	stats := graph.Stats()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(auditPubkey(g, pubkey, curve))
+}
+
+// AuditResponse is the /audit breakdown of a pubkey's score. Sections tagged
+// omitempty appear only when that component touched the score.
+type AuditResponse struct {
+	Pubkey               string             `json:"pubkey"`
+	Found                bool               `json:"found"`
+	PageRank             AuditPageRank      `json:"pagerank"`
+	Engagement           AuditEngagement    `json:"engagement"`
+	TopFollowers         []AuditFollower    `json:"top_followers"` // up to 5, highest-scored first
+	GraphContext         AuditGraphContext  `json:"graph_context"`
+	CommunityEndorsement *EndorsementResult `json:"community_endorsement,omitempty"`
+	Distrust             *DistrustAudit     `json:"distrust,omitempty"`
+	MutePenalty          *MutePenaltyAudit  `json:"mute_penalty,omitempty"`
+	HITS                 *AuditHITS         `json:"hits,omitempty"`
+	BrokerScore          *int               `json:"broker_score,omitempty"`
+	Betweenness          *float64           `json:"betweenness,omitempty"`
+	Composite            *AuditComposite    `json:"composite,omitempty"`   // with external assertions
+	FinalScore           *int               `json:"final_score,omitempty"` // without them
+}
+
+// AuditPageRank is the PageRank section of /audit.
+type AuditPageRank struct {
+	RawScore           float64 `json:"raw_score"`
+	NormalizedScore    int     `json:"normalized_score"`
+	FollowerCount      int     `json:"follower_count"`
+	FollowingCount     int     `json:"following_count"`
+	Percentile         float64 `json:"percentile"`
+	Rank               int     `json:"rank"`
+	Algorithm          string  `json:"algorithm"`
+	Damping            float64 `json:"damping"`
+	Iterations         int     `json:"iterations"`
+	Normalization      string  `json:"normalization"`
+	NormalizationCurve string  `json:"normalization_curve"`
+}
+
+// AuditEngagement is the engagement section of /audit.
+type AuditEngagement struct {
+	Posts                     int    `json:"posts"`
+	Replies                   int    `json:"replies"`
+	ReactionsReceived         int    `json:"reactions_received"`
+	ReactionsSent             int    `json:"reactions_sent"`
+	ZapsReceivedSats          int64  `json:"zaps_received_sats"`
+	ZapsReceivedCount         int    `json:"zaps_received_count"`
+	ZapsSentSats              int64  `json:"zaps_sent_sats"`
+	ZapsSentCount             int    `json:"zaps_sent_count"`
+	VerifiedZapsReceivedSats  int64  `json:"verified_zaps_received_sats"`
+	VerifiedZapsReceivedCount int    `json:"verified_zaps_received_count"`
+	FirstEvent                string `json:"first_event,omitempty"`
+}
+
+// AuditFollower is one of a pubkey's highest-scored followers.
+type AuditFollower struct {
+	Pubkey string `json:"pubkey"`
+	Score  int    `json:"score"`
+}
+
+// AuditGraphContext is the size and age of the graph an audit was run against.
+type AuditGraphContext struct {
+	TotalNodes  int    `json:"total_nodes"`
+	TotalEdges  int    `json:"total_edges"`
+	LastRebuild string `json:"last_rebuild"`
+}
+
+// AuditComposite is how external assertions blend into the final score.
+type AuditComposite struct {
+	FinalScore      int              `json:"final_score"`
+	InternalWeight  float64          `json:"internal_weight"`
+	ExternalWeight  float64          `json:"external_weight"`
+	InternalScore   int              `json:"internal_score"`
+	ExternalAverage float64          `json:"external_average"`
+	ExternalSources []ExternalSource `json:"external_sources"`
+}
+
+// auditPubkey builds the /audit breakdown of pubkey's score in g, with scores on
+// normalization curve.
+func auditPubkey(g *Graph, pubkey, curve string) AuditResponse {
+	rawScore, found := g.GetScore(pubkey)
+	stats := g.Stats()
 	m := meta.Get(pubkey)
-	internalScore := normalizeScore(rawScore, stats.Nodes)
-
-	follows := graph.GetFollows(pubkey)
-	followers := graph.GetFollowers(pubkey)
-	percentile := graph.Percentile(pubkey)
-	rank := graph.Rank(pubkey)
-
-	// PageRank breakdown
-	pagerank := map[string]interface{}{
-		"raw_score":        rawScore,
-		"normalized_score": internalScore,
-		"follower_count":   len(followers),
-		"following_count":  len(follows),
-		"percentile":       math.Round(percentile*10000) / 10000,
-		"rank":             rank,
-		"algorithm":        "PageRank",
-		"damping":          0.85,
-		"iterations":       20,
-		"normalization":    "log10(raw/avg + 1) * 25, capped at 100",
-	}
-
-	// Engagement breakdown
-	engagement := map[string]interface{}{
-		"posts":              m.PostCount,
-		"replies":            m.ReplyCount,
-		"reactions_received": m.ReactionsRecd,
-		"reactions_sent":     m.ReactionsSent,
-		"zaps_received_sats": m.ZapAmtRecd,
-		"zaps_received_count": m.ZapCntRecd,
-		"zaps_sent_sats":     m.ZapAmtSent,
-		"zaps_sent_count":    m.ZapCntSent,
+	internalScore := g.NormalizeScore(rawScore, curve)
+
+	follows := g.GetFollows(pubkey)
+	followers := g.GetFollowers(pubkey)
+	percentile := g.Percentile(pubkey)
+	rank := g.Rank(pubkey)
+
+	resp := AuditResponse{
+		Pubkey: pubkey,
+		Found:  found,
+		PageRank: AuditPageRank{
+			RawScore:           rawScore,
+			NormalizedScore:    internalScore,
+			FollowerCount:      len(followers),
+			FollowingCount:     len(follows),
+			Percentile:         math.Round(percentile*10000) / 10000,
+			Rank:               rank,
+			Algorithm:          "PageRank",
+			Damping:            config.Get().Damping,
+			Iterations:         config.Get().PageRankIterations,
+			Normalization:      normalizationCurves[curve],
+			NormalizationCurve: curve,
+		},
+		Engagement: AuditEngagement{
+			Posts:                     m.PostCount,
+			Replies:                   m.ReplyCount,
+			ReactionsReceived:         m.ReactionsRecd,
+			ReactionsSent:             m.ReactionsSent,
+			ZapsReceivedSats:          m.ZapAmtRecd,
+			ZapsReceivedCount:         m.ZapCntRecd,
+			ZapsSentSats:              m.ZapAmtSent,
+			ZapsSentCount:             m.ZapCntSent,
+			VerifiedZapsReceivedSats:  m.VerifiedZapAmtRecd,
+			VerifiedZapsReceivedCount: m.VerifiedZapCntRecd,
+		},
+		GraphContext: AuditGraphContext{
+			TotalNodes:  stats.Nodes,
+			TotalEdges:  stats.Edges,
+			LastRebuild: stats.LastBuild.UTC().Format(time.RFC3339),
+		},
 	}
 	if m.FirstCreated > 0 {
-		engagement["first_event"] = time.Unix(m.FirstCreated, 0).UTC().Format(time.RFC3339)
+		resp.Engagement.FirstEvent = time.Unix(m.FirstCreated, 0).UTC().Format(time.RFC3339)
 	}
 
 	// External assertions breakdown
 	extAssertions := externalAssertions.GetForSubject(pubkey)
 	compositeScore, extSources := CompositeScore(internalScore, extAssertions, externalAssertions)
-
-	var composite map[string]interface{}
 	if len(extSources) > 0 {
 		normalizedSum := 0
 		for _, src := range extSources {
-			normalizedSum += src["normalized_rank"].(int)
+			normalizedSum += src.NormalizedRank
 		}
 		externalAvg := float64(normalizedSum) / float64(len(extSources))
 
-		composite = map[string]interface{}{
-			"final_score":     compositeScore,
-			"internal_weight": 0.70,
-			"external_weight": 0.30,
-			"internal_score":  internalScore,
-			"external_average": math.Round(externalAvg*100) / 100,
-			"external_sources": extSources,
+		resp.Composite = &AuditComposite{
+			FinalScore:      compositeScore,
+			InternalWeight:  0.70,
+			ExternalWeight:  0.30,
+			InternalScore:   internalScore,
+			ExternalAverage: math.Round(externalAvg*100) / 100,
+			ExternalSources: extSources,
 		}
 	}
 
 	// Top followers by WoT score (up to 5)
-	type followerScore struct {
-		Pubkey string  `json:"pubkey"`
-		Score  int     `json:"score"`
-	}
-	topFollowers := make([]followerScore, 0)
+	resp.TopFollowers = make([]AuditFollower, 0)
 	for _, f := range followers {
-		s, ok := graph.GetScore(f)
+		s, ok := g.GetScore(f)
 		if ok {
-			topFollowers = append(topFollowers, followerScore{
+			resp.TopFollowers = append(resp.TopFollowers, AuditFollower{
 				Pubkey: f,
-				Score:  normalizeScore(s, stats.Nodes),
+				Score:  g.NormalizeScore(s, curve),
 			})
 		}
 	}
-	sort.Slice(topFollowers, func(i, j int) bool {
-		return topFollowers[i].Score > topFollowers[j].Score
+	sort.Slice(resp.TopFollowers, func(i, j int) bool {
+		return resp.TopFollowers[i].Score > resp.TopFollowers[j].Score
 	})
-	if len(topFollowers) > 5 {
-		topFollowers = topFollowers[:5]
-	}
-
-	resp := map[string]interface{}{
-		"pubkey":         pubkey,
-		"found":          found,
-		"pagerank":       pagerank,
-		"engagement":     engagement,
-		"top_followers":  topFollowers,
-		"graph_context": map[string]interface{}{
-			"total_nodes":  stats.Nodes,
-			"total_edges":  stats.Edges,
-			"last_rebuild": stats.LastBuild.UTC().Format(time.RFC3339),
-		},
+	if len(resp.TopFollowers) > 5 {
+		resp.TopFollowers = resp.TopFollowers[:5]
+	}
+
+	// Community endorsement adjustment (bounded, applied on top of the base score)
+	baseScore := internalScore
+	if resp.Composite != nil {
+		baseScore = compositeScore
+	}
+	endorsement := evaluateEndorsements(endorsements, pubkey, baseScore)
+	if len(endorsement.Endorsers) > 0 {
+		resp.CommunityEndorsement = &endorsement
+	}
+	resp.Distrust = auditDistrust(pubkey, internalScore)
+	resp.MutePenalty = auditMutePenalty(pubkey, rawScore, stats.Nodes)
+	if h, ok := hitsScores.Get(pubkey); ok {
+		resp.HITS = &AuditHITS{
+			HITSSummary:   newHITSSummary(h, stats.Nodes),
+			Algorithm:     "HITS",
+			Iterations:    config.Get().PageRankIterations,
+			Normalization: "log10(raw/avg + 1) * 25, capped at 100",
+		}
+	}
+	if bc, broker, ok := brokerScores.Get(pubkey); ok {
+		betweenness := math.Round(bc*100) / 100
+		resp.BrokerScore = &broker
+		resp.Betweenness = &betweenness
 	}
 
-	if composite != nil {
-		resp["composite"] = composite
+	final := endorsement.AdjustedScore
+	if resp.Composite != nil {
+		resp.Composite.FinalScore = final
 	} else {
-		resp["final_score"] = internalScore
+		resp.FinalScore = &final
 	}
+	return resp
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+// BatchResponse is the POST /batch body: one result per requested pubkey, in
+// request order.
+type BatchResponse struct {
+	Results   []BatchScore `json:"results"`
+	GraphSize int          `json:"graph_size"`
+}
+
+// BatchScore is one /batch result. A pubkey that can't be read keeps its input
+// as pubkey and carries error.
+type BatchScore struct {
+	Pubkey         string `json:"pubkey"`
+	Score          int    `json:"score"`
+	Found          bool   `json:"found"`
+	Followers      int    `json:"followers"`
+	CompositeScore *int   `json:"composite_score,omitempty"` // with external assertions
+	Error          string `json:"error,omitempty"`
 }
 
 func handleBatch(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, `{"error":"POST required"}`, http.StatusMethodNotAllowed)
+	g := graph.Snapshot()
+	if !requireMethod(w, r, http.MethodPost) {
 		return
 	}
 
 	var req struct {
 		Pubkeys []string `json:"pubkeys"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"invalid JSON body"}`, http.StatusBadRequest)
+	if apiErr := bindJSON(w, r, &req, 1<<20); apiErr != nil {
+		writeAPIError(w, apiErr)
 		return
 	}
 	if len(req.Pubkeys) == 0 {
-		http.Error(w, `{"error":"pubkeys array required"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "pubkeys", Message: "pubkeys array required"})
 		return
 	}
 	if len(req.Pubkeys) > 100 {
-		http.Error(w, `{"error":"max 100 pubkeys per request"}`, http.StatusBadRequest)
+		writeAPIError(w, invalidParam("pubkeys", "max 100 pubkeys per request"))
 		return
 	}
 
-	stats := graph.Stats()
-	results := make([]map[string]interface{}, 0, len(req.Pubkeys))
+	stats := g.Stats()
+	results := make([]BatchScore, 0, len(req.Pubkeys))
 	for _, raw := range req.Pubkeys {
 		pubkey, err := resolvePubkey(raw)
 		if err != nil {
-			results = append(results, map[string]interface{}{
-				"pubkey": raw,
-				"error":  err.Error(),
-			})
+			results = append(results, BatchScore{Pubkey: raw, Error: err.Error()})
 			continue
 		}
 
-		score, ok := graph.GetScore(pubkey)
+		score, ok := g.GetScore(pubkey)
 		internalScore := normalizeScore(score, stats.Nodes)
 		m := meta.Get(pubkey)
 		extAssertions := externalAssertions.GetForSubject(pubkey)
 		compositeScore, _ := CompositeScore(internalScore, extAssertions, externalAssertions)
 
-		entry := map[string]interface{}{
-			"pubkey":    pubkey,
-			"score":     internalScore,
-			"found":     ok,
-			"followers": m.Followers,
+		entry := BatchScore{
+			Pubkey:    pubkey,
+			Score:     internalScore,
+			Found:     ok,
+			Followers: m.Followers,
 		}
 		if len(extAssertions) > 0 {
-			entry["composite_score"] = compositeScore
+			entry.CompositeScore = &compositeScore
 		}
 		results = append(results, entry)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"results":    results,
-		"graph_size": stats.Nodes,
-	})
+	json.NewEncoder(w).Encode(BatchResponse{Results: results, GraphSize: stats.Nodes})
 }
 
 func handlePersonalized(w http.ResponseWriter, r *http.Request) {
-	viewerRaw := r.URL.Query().Get("viewer")
-	targetRaw := r.URL.Query().Get("target")
-	if viewerRaw == "" || targetRaw == "" {
-		http.Error(w, `{"error":"viewer and target parameters required"}`, http.StatusBadRequest)
-		return
-	}
-
-	viewer, err := resolvePubkey(viewerRaw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid viewer: %s"}`, err.Error()), http.StatusBadRequest)
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	viewer := q.Pubkey("viewer", true)
+	target := q.Pubkey("target", true)
+	algorithm := q.OneOf("algorithm", "blend", "ppr")
+	if q.Failed(w) {
 		return
 	}
-	target, err := resolvePubkey(targetRaw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid target: %s"}`, err.Error()), http.StatusBadRequest)
-		return
+	if algorithm == "" {
+		algorithm = "blend"
 	}
 
-	stats := graph.Stats()
-	viewerFollows := graph.GetFollows(viewer)
-	targetFollows := graph.GetFollows(target)
-	targetFollowers := graph.GetFollowers(target)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(personalizeScore(g, viewer, target, algorithm))
+}
+
+// PersonalizedResponse is the /personalized body. /personalized/batch results
+// leave out viewer, algorithm and graph_size, which it gives once.
+type PersonalizedResponse struct {
+	Viewer                string   `json:"viewer,omitempty"`
+	Target                string   `json:"target"`
+	Algorithm             string   `json:"algorithm,omitempty"`
+	PersonalizedScore     int      `json:"personalized_score"`
+	GlobalScore           int      `json:"global_score"`
+	Found                 bool     `json:"found"`
+	ViewerFollowsTarget   bool     `json:"viewer_follows_target"`
+	TargetFollowsViewer   bool     `json:"target_follows_viewer"`
+	MutualFollow          bool     `json:"mutual_follow"`
+	TrustedFollowers      int      `json:"trusted_followers"`
+	TrustedFollowerSample []string `json:"trusted_follower_sample"`
+	SharedFollows         int      `json:"shared_follows"`
+	ViewerFollowsSource   string   `json:"viewer_follows_source"`
+	GraphSize             int      `json:"graph_size,omitempty"`
+	*PPRScore                      // algorithm=ppr
+}
+
+// PPRScore is where target lands in the viewer's personalized PageRank walk.
+type PPRScore struct {
+	Score     float64 `json:"ppr_score"`
+	Rank      int     `json:"ppr_rank"`
+	Reachable int     `json:"ppr_reachable"`
+}
+
+// personalizeScore builds the /personalized response for target as seen by
+// viewer, with algorithm blend or ppr.
+func personalizeScore(g *Graph, viewer, target, algorithm string) PersonalizedResponse {
+	stats := g.Stats()
+	viewerFollows, imported := followsForViewer(g, viewer)
+	targetFollows := g.GetFollows(target)
+	targetFollowers := g.GetFollowers(target)
 
 	// Check direct follow relationship
 	viewerFollowsTarget := false
@@ -637,7 +974,7 @@ func handlePersonalized(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Global score
-	rawScore, found := graph.GetScore(target)
+	rawScore, found := g.GetScore(target)
 	globalScore := normalizeScore(rawScore, stats.Nodes)
 
 	// Personalized score: blend global score with social proximity signals
@@ -663,54 +1000,74 @@ func handlePersonalized(w http.ResponseWriter, r *http.Request) {
 		personalizedScore = 100
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"viewer":              viewer,
-		"target":              target,
-		"personalized_score":  personalizedScore,
-		"global_score":        globalScore,
-		"found":               found,
-		"viewer_follows_target": viewerFollowsTarget,
-		"target_follows_viewer": targetFollowsViewer,
-		"mutual_follow":       viewerFollowsTarget && targetFollowsViewer,
-		"trusted_followers":   trustedFollowers,
-		"trusted_follower_sample": trustedFollowerList,
-		"shared_follows":      sharedFollows,
-		"graph_size":          stats.Nodes,
-	})
+	resp := PersonalizedResponse{
+		Viewer:                viewer,
+		Target:                target,
+		Algorithm:             algorithm,
+		PersonalizedScore:     personalizedScore,
+		GlobalScore:           globalScore,
+		Found:                 found,
+		ViewerFollowsTarget:   viewerFollowsTarget,
+		TargetFollowsViewer:   targetFollowsViewer,
+		MutualFollow:          viewerFollowsTarget && targetFollowsViewer,
+		TrustedFollowers:      trustedFollowers,
+		TrustedFollowerSample: trustedFollowerList,
+		SharedFollows:         sharedFollows,
+		ViewerFollowsSource:   followsSource(imported),
+		GraphSize:             stats.Nodes,
+	}
+
+	// PPR: random walk with restart at the viewer, normalized over the nodes the
+	// walk reaches rather than the whole graph
+	if algorithm == "ppr" {
+		ppr := personalizedRanks.get(viewer)
+		resp.PersonalizedScore = normalizeScore(ppr[target], len(ppr))
+		resp.PPRScore = &PPRScore{
+			Score:     ppr[target],
+			Rank:      pprRank(ppr, viewer, target),
+			Reachable: len(ppr),
+		}
+	}
+	return resp
 }
 
-func handleSimilar(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
-		return
-	}
+// SimilarResponse is the /similar body. A pubkey with no follows gets an empty
+// list and error.
+type SimilarResponse struct {
+	Pubkey     string           `json:"pubkey"`
+	Similar    []SimilarAccount `json:"similar"`
+	TotalFound int              `json:"total_found"`
+	Method     string           `json:"method,omitempty"` // "lsh" or "scan"
+	GraphSize  int              `json:"graph_size"`
+	Error      string           `json:"error,omitempty"`
+}
 
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
-		return
-	}
+// SimilarAccount is an account whose follows overlap the requested pubkey's.
+type SimilarAccount struct {
+	Pubkey        string  `json:"pubkey"`
+	Similarity    float64 `json:"similarity"` // Jaccard index of the two follow lists
+	SharedFollows int     `json:"shared_follows"`
+	WotScore      int     `json:"wot_score"`
+}
 
-	limitStr := r.URL.Query().Get("limit")
-	limit := 20
-	if limitStr != "" {
-		if n, err := fmt.Sscanf(limitStr, "%d", &limit); n != 1 || err != nil || limit < 1 {
-			limit = 20
-		}
-		if limit > 50 {
-			limit = 50
-		}
+func handleSimilar(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	limit := q.Int("limit", 20, 1, 50)
+	if q.Failed(w) {
+		return
 	}
 
-	targetFollows := graph.GetFollows(pubkey)
+	stats := g.Stats()
+	targetFollows := g.GetFollows(pubkey)
 	if len(targetFollows) == 0 {
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"pubkey":  pubkey,
-			"similar": []interface{}{},
-			"error":   "pubkey has no follows in graph",
+		json.NewEncoder(w).Encode(SimilarResponse{
+			Pubkey:    pubkey,
+			Similar:   []SimilarAccount{},
+			GraphSize: stats.Nodes,
+			Error:     "pubkey has no follows in graph",
 		})
 		return
 	}
@@ -721,8 +1078,6 @@ func handleSimilar(w http.ResponseWriter, r *http.Request) {
 		targetSet[f] = true
 	}
 
-	stats := graph.Stats()
-
 	// Compare with all other pubkeys that have follows
 	type candidate struct {
 		Pubkey     string
@@ -732,14 +1087,21 @@ func handleSimilar(w http.ResponseWriter, r *http.Request) {
 		WotScore   int
 	}
 
-	allPubkeys := graph.AllFollowers()
+	// Exact Jaccard over the MinHash/LSH index's candidates, or over every
+	// account until the first rebuild has built the index
+	method := "lsh"
+	pool, ok := similarityIndex.Candidates(pubkey, targetFollows)
+	if !ok {
+		method = "scan"
+		pool = g.AllFollowers()
+	}
 	candidates := make([]candidate, 0, 256)
 
-	for _, pk := range allPubkeys {
+	for _, pk := range pool {
 		if pk == pubkey {
 			continue
 		}
-		pkFollows := graph.GetFollows(pk)
+		pkFollows := g.GetFollows(pk)
 		if len(pkFollows) < 3 {
 			continue // skip very low-activity accounts
 		}
@@ -758,7 +1120,7 @@ func handleSimilar(w http.ResponseWriter, r *http.Request) {
 		union := len(targetSet) + len(pkFollows) - shared
 		jaccard := float64(shared) / float64(union)
 
-		rawScore, _ := graph.GetScore(pk)
+		rawScore, _ := g.GetScore(pk)
 		wotScore := normalizeScore(rawScore, stats.Nodes)
 
 		candidates = append(candidates, candidate{
@@ -781,16 +1143,9 @@ func handleSimilar(w http.ResponseWriter, r *http.Request) {
 		candidates = candidates[:limit]
 	}
 
-	type resultEntry struct {
-		Pubkey       string  `json:"pubkey"`
-		Similarity   float64 `json:"similarity"`
-		SharedFollows int    `json:"shared_follows"`
-		WotScore     int     `json:"wot_score"`
-	}
-
-	results := make([]resultEntry, len(candidates))
+	results := make([]SimilarAccount, len(candidates))
 	for i, c := range candidates {
-		results[i] = resultEntry{
+		results[i] = SimilarAccount{
 			Pubkey:        c.Pubkey,
 			Similarity:    math.Round(c.Jaccard*1000) / 1000, // 3 decimal places
 			SharedFollows: c.Shared,
@@ -799,45 +1154,75 @@ func handleSimilar(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"pubkey":      pubkey,
-		"similar":     results,
-		"total_found": len(candidates),
-		"graph_size":  stats.Nodes,
+	json.NewEncoder(w).Encode(SimilarResponse{
+		Pubkey:     pubkey,
+		Similar:    results,
+		TotalFound: len(candidates),
+		Method:     method,
+		GraphSize:  stats.Nodes,
 	})
 }
 
+// RecommendResponse is the /recommend body. options and filtered appear when the
+// request reshaped the ranking. A pubkey with no follows gets an empty list and
+// error.
+type RecommendResponse struct {
+	Pubkey          string            `json:"pubkey"`
+	Recommendations []Recommendation  `json:"recommendations"`
+	TotalFound      int               `json:"total_found"`
+	FollowsCount    int               `json:"follows_count"`
+	FollowsSource   string            `json:"follows_source"`
+	GraphSize       int               `json:"graph_size"`
+	Options         *RecommendOptions `json:"options,omitempty"`
+	Filtered        *int              `json:"filtered,omitempty"`
+	Error           string            `json:"error,omitempty"`
+}
+
+// Recommendation is an account the requested pubkey's follows also follow.
+type Recommendation struct {
+	Pubkey         string   `json:"pubkey"`
+	MutualCount    int      `json:"mutual_follows"` // how many of your follows also follow this person
+	MutualRatio    float64  `json:"mutual_ratio"`   // mutual_follows / your total follows (0-1)
+	WotScore       int      `json:"wot_score"`
+	FollowersCount int      `json:"followers_count"`
+	Topics         []string `json:"matched_topics,omitempty"`
+}
+
+// RecommendOptions echoes the ranking options a /recommend request applied.
+type RecommendOptions struct {
+	Diversity    float64  `json:"diversity"`
+	MinFollowers int      `json:"min_followers"`
+	MaxFollowers int      `json:"max_followers"`
+	Topics       []string `json:"topics"`
+	Excluded     int      `json:"excluded"`
+}
+
+// handleRecommend serves GET /recommend?pubkey=, or POST /recommend with a JSON
+// body that can also carry an exclude list of dismissed suggestions. diversity,
+// min_followers/max_followers and topics reshape the friends-of-friends ranking,
+// which otherwise favors the accounts everyone already follows.
 func handleRecommend(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
+	g := graph.Snapshot()
+	if !requireMethod(w, r, http.MethodGet, http.MethodPost) {
 		return
 	}
-
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+	req, apiErr := parseRecommendRequest(w, r)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
 		return
 	}
+	pubkey, limit := req.Pubkey, req.Limit
 
-	limitStr := r.URL.Query().Get("limit")
-	limit := 20
-	if limitStr != "" {
-		if n, err := fmt.Sscanf(limitStr, "%d", &limit); n != 1 || err != nil || limit < 1 {
-			limit = 20
-		}
-		if limit > 50 {
-			limit = 50
-		}
-	}
-
-	targetFollows := graph.GetFollows(pubkey)
+	stats := g.Stats()
+	targetFollows, imported := followsForViewer(g, pubkey)
 	if len(targetFollows) == 0 {
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"pubkey":          pubkey,
-			"recommendations": []interface{}{},
-			"error":           "pubkey has no follows in graph",
+		json.NewEncoder(w).Encode(RecommendResponse{
+			Pubkey:          pubkey,
+			Recommendations: []Recommendation{},
+			FollowsSource:   followsSource(imported),
+			GraphSize:       stats.Nodes,
+			Error:           "pubkey has no follows in graph",
 		})
 		return
 	}
@@ -853,7 +1238,7 @@ func handleRecommend(w http.ResponseWriter, r *http.Request) {
 	// "Friends of friends" — if many of your follows also follow X, you'd probably like X
 	candidateCounts := make(map[string]int)
 	for _, friend := range targetFollows {
-		friendFollows := graph.GetFollows(friend)
+		friendFollows := g.GetFollows(friend)
 		for _, candidate := range friendFollows {
 			if !alreadyFollows[candidate] {
 				candidateCounts[candidate]++
@@ -861,281 +1246,333 @@ func handleRecommend(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	stats := graph.Stats()
-
 	type candidate struct {
 		Pubkey      string
 		MutualCount int // how many of target's follows also follow this candidate
 		WotScore    int
+		Followers   int
+		Topics      []string
+		Rank        float64
 	}
 
+	// Sort by weighted score: 60% mutual ratio + 40% WoT score, scaled down for
+	// widely followed accounts with diversity
+	totalFollows := float64(len(targetFollows))
 	candidates := make([]candidate, 0, len(candidateCounts))
+	filtered := 0
 	for pk, count := range candidateCounts {
 		if count < 2 {
 			continue // need at least 2 mutual connections to be a recommendation
 		}
-		rawScore, _ := graph.GetScore(pk)
+		followers := len(g.GetFollowers(pk))
+		var topics []string
+		if len(req.Topics) > 0 {
+			topics = meta.MatchTopics(pk, req.Topics)
+		}
+		if req.excluded[pk] || !req.inFollowerBand(followers) || len(req.Topics) > 0 && len(topics) == 0 {
+			filtered++
+			continue
+		}
+		rawScore, _ := g.GetScore(pk)
 		wotScore := normalizeScore(rawScore, stats.Nodes)
+		rank := float64(count)/totalFollows*0.6 + float64(wotScore)/100.0*0.4
 		candidates = append(candidates, candidate{
 			Pubkey:      pk,
 			MutualCount: count,
 			WotScore:    wotScore,
+			Followers:   followers,
+			Topics:      topics,
+			Rank:        rank * req.diversityFactor(followers, stats.Nodes),
 		})
 	}
 
-	// Sort by weighted score: 60% mutual ratio + 40% WoT score
-	totalFollows := float64(len(targetFollows))
 	sort.Slice(candidates, func(i, j int) bool {
-		ratioI := float64(candidates[i].MutualCount) / totalFollows
-		ratioJ := float64(candidates[j].MutualCount) / totalFollows
-		scoreI := ratioI*0.6 + float64(candidates[i].WotScore)/100.0*0.4
-		scoreJ := ratioJ*0.6 + float64(candidates[j].WotScore)/100.0*0.4
-		return scoreI > scoreJ
+		if candidates[i].Rank != candidates[j].Rank {
+			return candidates[i].Rank > candidates[j].Rank
+		}
+		return candidates[i].Pubkey < candidates[j].Pubkey
 	})
 
 	if len(candidates) > limit {
 		candidates = candidates[:limit]
 	}
 
-	type resultEntry struct {
-		Pubkey       string  `json:"pubkey"`
-		MutualCount  int     `json:"mutual_follows"`  // how many of your follows also follow this person
-		MutualRatio  float64 `json:"mutual_ratio"`    // mutual_follows / your total follows (0-1)
-		WotScore     int     `json:"wot_score"`
-	}
-
-	results := make([]resultEntry, len(candidates))
+	results := make([]Recommendation, len(candidates))
 	for i, c := range candidates {
-		results[i] = resultEntry{
-			Pubkey:      c.Pubkey,
-			MutualCount: c.MutualCount,
-			MutualRatio: math.Round(float64(c.MutualCount)/totalFollows*1000) / 1000,
-			WotScore:    c.WotScore,
+		results[i] = Recommendation{
+			Pubkey:         c.Pubkey,
+			MutualCount:    c.MutualCount,
+			MutualRatio:    math.Round(float64(c.MutualCount)/totalFollows*1000) / 1000,
+			WotScore:       c.WotScore,
+			FollowersCount: c.Followers,
+			Topics:         c.Topics,
 		}
 	}
 
+	resp := RecommendResponse{
+		Pubkey:          pubkey,
+		Recommendations: results,
+		TotalFound:      len(candidates),
+		FollowsCount:    len(targetFollows),
+		FollowsSource:   followsSource(imported),
+		GraphSize:       stats.Nodes,
+	}
+	if req.Diversity > 0 || req.MinFollowers > 0 || req.MaxFollowers > 0 || len(req.Topics) > 0 || len(req.excluded) > 0 {
+		resp.Options = &RecommendOptions{
+			Diversity:    req.Diversity,
+			MinFollowers: req.MinFollowers,
+			MaxFollowers: req.MaxFollowers,
+			Topics:       req.Topics,
+			Excluded:     len(req.excluded),
+		}
+		resp.Filtered = &filtered
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"pubkey":          pubkey,
-		"recommendations": results,
-		"total_found":     len(candidates),
-		"follows_count":   len(targetFollows),
-		"graph_size":      stats.Nodes,
-	})
+	json.NewEncoder(w).Encode(resp)
 }
 
 // handleGraph serves two modes:
 // Path mode: GET /graph?from=<pubkey>&to=<pubkey> — BFS shortest trust path
 // Neighborhood mode: GET /graph?pubkey=<pubkey>&depth=1 — local graph around a pubkey
 func handleGraph(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
 	from := r.URL.Query().Get("from")
 	to := r.URL.Query().Get("to")
 	pubkey := r.URL.Query().Get("pubkey")
 
 	// Path mode: find shortest path between two pubkeys
 	if from != "" && to != "" {
-		fromHex, err := resolvePubkey(from)
-		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"invalid from pubkey: %s"}`, err.Error()), http.StatusBadRequest)
-			return
-		}
-		toHex, err := resolvePubkey(to)
-		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"invalid to pubkey: %s"}`, err.Error()), http.StatusBadRequest)
+		q := bindQuery(r)
+		fromHex := q.Pubkey("from", true)
+		toHex := q.Pubkey("to", true)
+		if q.Failed(w) {
 			return
 		}
 		if fromHex == toHex {
-			http.Error(w, `{"error":"from and to are the same pubkey"}`, http.StatusBadRequest)
+			writeAPIError(w, invalidParam("to", "from and to are the same pubkey"))
 			return
 		}
 
-		path, found := bfsPath(fromHex, toHex, 6)
-		stats := graph.Stats()
+		opts, err := parsePathOptions(r.URL.Query())
+		if err != nil {
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "invalid_parameter", Message: err.Error()})
+			return
+		}
 
-		if !found {
+		stats := g.Stats()
+		excluded := 0
+		if opts.K > 1 || opts.Weighted {
 			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"from":       fromHex,
-				"to":         toHex,
-				"found":      false,
-				"path":       []string{},
-				"hops":       0,
-				"graph_size": stats.Nodes,
-			})
+			json.NewEncoder(w).Encode(graphKPaths(g, fromHex, toHex, opts, &excluded))
 			return
 		}
-
-		// Annotate each node in the path with WoT score
-		type pathNode struct {
-			Pubkey   string `json:"pubkey"`
-			WotScore int    `json:"wot_score"`
+		path, found := bfsPathFiltered(fromHex, toHex, opts.MaxHops, opts.pathFilter(stats.Nodes, &excluded))
+
+		resp := GraphPathResponse{
+			From:          fromHex,
+			To:            toHex,
+			Path:          []PathNode{},
+			HopDetails:    []PathHop{},
+			Options:       opts,
+			ExcludedNodes: excluded,
+			GraphSize:     stats.Nodes,
 		}
-		nodes := make([]pathNode, len(path))
-		for i, pk := range path {
-			rawScore, _ := graph.GetScore(pk)
-			nodes[i] = pathNode{
-				Pubkey:   pk,
-				WotScore: normalizeScore(rawScore, stats.Nodes),
-			}
+		if found {
+			// Annotate each node with score, community, and spam/sybil flags, and
+			// each hop with mutual/one-way, follow age, and community qualifiers
+			resp.Found, resp.Hops = true, len(path)-1
+			resp.Path, resp.HopDetails, resp.FlaggedIntermediates = explainPath(path, time.Now())
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"from":       fromHex,
-			"to":         toHex,
-			"found":      true,
-			"path":       nodes,
-			"hops":       len(path) - 1,
-			"graph_size": stats.Nodes,
-		})
+		json.NewEncoder(w).Encode(resp)
 		return
 	}
 
 	// Neighborhood mode: local graph around a pubkey
 	if pubkey != "" {
-		pk, err := resolvePubkey(pubkey)
-		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+		q := bindQuery(r)
+		pk := q.Pubkey("pubkey", true)
+		depth := q.Int("depth", 1, 1, 2) // capped at 2 to prevent huge responses
+		format := q.OneOf("format", "hex", "npub", "graphml", "gexf", "dot")
+		var limit int
+		if graphFormats[format] {
+			limit = q.Int("limit", graphExportDefaultNodes, 1, graphExportMaxNodes)
+		} else {
+			limit = q.Int("limit", 50, 1, 200)
+		}
+		if q.Failed(w) {
 			return
 		}
 
-		depthStr := r.URL.Query().Get("depth")
-		depth := 1
-		if depthStr != "" {
-			if n, err := fmt.Sscanf(depthStr, "%d", &depth); n != 1 || err != nil || depth < 1 {
-				depth = 1
-			}
-			if depth > 2 {
-				depth = 2 // cap at 2 to prevent huge responses
-			}
+		if graphFormats[format] {
+			serveGraphNeighborhood(w, r, g, pk, depth, limit, format)
+			return
 		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(graphNeighborhood(g, pk, depth, limit))
+		return
+	}
 
-		limitStr := r.URL.Query().Get("limit")
-		limit := 50
-		if limitStr != "" {
-			if n, err := fmt.Sscanf(limitStr, "%d", &limit); n != 1 || err != nil || limit < 1 {
-				limit = 50
-			}
-			if limit > 200 {
-				limit = 200
-			}
-		}
+	writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "provide either ?from=&to= (path mode) or ?pubkey= (neighborhood mode)"})
+}
 
-		stats := graph.Stats()
-		rawScore, _ := graph.GetScore(pk)
+// GraphPathResponse is the /graph path mode body for a single shortest path.
+// Without a path, path and hop_details are empty.
+type GraphPathResponse struct {
+	From                 string      `json:"from"`
+	To                   string      `json:"to"`
+	Found                bool        `json:"found"`
+	Path                 []PathNode  `json:"path"`
+	Hops                 int         `json:"hops"`
+	HopDetails           []PathHop   `json:"hop_details"`
+	FlaggedIntermediates int         `json:"flagged_intermediates"`
+	Options              PathOptions `json:"options"`
+	ExcludedNodes        int         `json:"excluded_nodes"`
+	GraphSize            int         `json:"graph_size"`
+}
 
-		type neighborNode struct {
-			Pubkey   string `json:"pubkey"`
-			WotScore int    `json:"wot_score"`
-			Relation string `json:"relation"` // "follows", "follower", "mutual"
-		}
+// graphNeighbor is a pubkey in a /graph neighborhood and how it relates to the
+// center.
+type graphNeighbor struct {
+	Pubkey   string `json:"pubkey"`
+	WotScore int    `json:"wot_score"`
+	Relation string `json:"relation"` // "follows", "follower", "mutual" or "extended"
+}
 
-		follows := graph.GetFollows(pk)
-		followers := graph.GetFollowers(pk)
+// GraphNeighborhood is the /graph neighborhood mode body. /graph/batch leaves out
+// depth and graph_size, which it reports once.
+type GraphNeighborhood struct {
+	Pubkey         string          `json:"pubkey"`
+	WotScore       int             `json:"wot_score"`
+	FollowsCount   int             `json:"follows_count"`
+	FollowersCount int             `json:"followers_count"`
+	MutualCount    int             `json:"mutual_count"`
+	Neighbors      []graphNeighbor `json:"neighbors"`
+	Depth          int             `json:"depth,omitempty"`
+	GraphSize      int             `json:"graph_size,omitempty"`
+}
 
-		followSet := make(map[string]bool, len(follows))
-		for _, f := range follows {
-			followSet[f] = true
-		}
-		followerSet := make(map[string]bool, len(followers))
-		for _, f := range followers {
-			followerSet[f] = true
+// graphNeighborhood builds the /graph neighborhood of pk in g: up to limit
+// follows, followers and, at depth 2, follows of follows, by score.
+func graphNeighborhood(g *Graph, pk string, depth, limit int) GraphNeighborhood {
+	stats := g.Stats()
+	rawScore, _ := g.GetScore(pk)
+	neighbors := graphNeighbors(g, pk, depth, limit)
+
+	// Count relation types
+	mutualCount := 0
+	for _, n := range neighbors {
+		if n.Relation == "mutual" {
+			mutualCount++
 		}
+	}
 
-		// Collect unique neighbors with relation type
-		seen := make(map[string]bool)
-		neighbors := make([]neighborNode, 0)
+	return GraphNeighborhood{
+		Pubkey:         pk,
+		WotScore:       normalizeScore(rawScore, stats.Nodes),
+		FollowsCount:   len(g.GetFollows(pk)),
+		FollowersCount: len(g.GetFollowers(pk)),
+		MutualCount:    mutualCount,
+		Neighbors:      neighbors,
+		Depth:          depth,
+		GraphSize:      stats.Nodes,
+	}
+}
 
-		for _, f := range follows {
-			if seen[f] || f == pk {
-				continue
-			}
-			seen[f] = true
-			relation := "follows"
-			if followerSet[f] {
-				relation = "mutual"
-			}
-			raw, _ := graph.GetScore(f)
-			neighbors = append(neighbors, neighborNode{
-				Pubkey:   f,
-				WotScore: normalizeScore(raw, stats.Nodes),
-				Relation: relation,
-			})
+// graphNeighbors returns up to limit of pk's neighbors in g, highest-scored
+// first: its follows and followers and, at depth 2, follows of follows.
+func graphNeighbors(g *Graph, pk string, depth, limit int) []graphNeighbor {
+	stats := g.Stats()
+	follows := g.GetFollows(pk)
+	followers := g.GetFollowers(pk)
+
+	followSet := make(map[string]bool, len(follows))
+	for _, f := range follows {
+		followSet[f] = true
+	}
+	followerSet := make(map[string]bool, len(followers))
+	for _, f := range followers {
+		followerSet[f] = true
+	}
+
+	// Collect unique neighbors with relation type
+	seen := make(map[string]bool)
+	neighbors := make([]graphNeighbor, 0)
+
+	for _, f := range follows {
+		if seen[f] || f == pk {
+			continue
 		}
-		for _, f := range followers {
-			if seen[f] || f == pk {
-				continue
-			}
-			seen[f] = true
-			raw, _ := graph.GetScore(f)
-			neighbors = append(neighbors, neighborNode{
-				Pubkey:   f,
-				WotScore: normalizeScore(raw, stats.Nodes),
-				Relation: "follower",
-			})
+		seen[f] = true
+		relation := "follows"
+		if followerSet[f] {
+			relation = "mutual"
+		}
+		raw, _ := g.GetScore(f)
+		neighbors = append(neighbors, graphNeighbor{
+			Pubkey:   f,
+			WotScore: normalizeScore(raw, stats.Nodes),
+			Relation: relation,
+		})
+	}
+	for _, f := range followers {
+		if seen[f] || f == pk {
+			continue
 		}
+		seen[f] = true
+		raw, _ := g.GetScore(f)
+		neighbors = append(neighbors, graphNeighbor{
+			Pubkey:   f,
+			WotScore: normalizeScore(raw, stats.Nodes),
+			Relation: "follower",
+		})
+	}
 
-		// If depth=2, also include follows-of-follows (trimmed)
-		if depth == 2 {
-			for _, f := range follows {
-				fof := graph.GetFollows(f)
-				for _, ff := range fof {
-					if seen[ff] || ff == pk {
-						continue
-					}
-					if len(neighbors) >= limit {
-						break
-					}
-					seen[ff] = true
-					raw, _ := graph.GetScore(ff)
-					neighbors = append(neighbors, neighborNode{
-						Pubkey:   ff,
-						WotScore: normalizeScore(raw, stats.Nodes),
-						Relation: "extended",
-					})
+	// If depth=2, also include follows-of-follows (trimmed)
+	if depth == 2 {
+		for _, f := range follows {
+			fof := g.GetFollows(f)
+			for _, ff := range fof {
+				if seen[ff] || ff == pk {
+					continue
 				}
 				if len(neighbors) >= limit {
 					break
 				}
+				seen[ff] = true
+				raw, _ := g.GetScore(ff)
+				neighbors = append(neighbors, graphNeighbor{
+					Pubkey:   ff,
+					WotScore: normalizeScore(raw, stats.Nodes),
+					Relation: "extended",
+				})
 			}
-		}
-
-		// Sort by WoT score descending, then trim
-		sort.Slice(neighbors, func(i, j int) bool {
-			return neighbors[i].WotScore > neighbors[j].WotScore
-		})
-		if len(neighbors) > limit {
-			neighbors = neighbors[:limit]
-		}
-
-		// Count relation types
-		mutualCount := 0
-		for _, n := range neighbors {
-			if n.Relation == "mutual" {
-				mutualCount++
+			if len(neighbors) >= limit {
+				break
 			}
 		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"pubkey":          pk,
-			"wot_score":       normalizeScore(rawScore, stats.Nodes),
-			"follows_count":   len(follows),
-			"followers_count": len(followers),
-			"mutual_count":    mutualCount,
-			"neighbors":       neighbors,
-			"depth":           depth,
-			"graph_size":      stats.Nodes,
-		})
-		return
 	}
 
-	http.Error(w, `{"error":"provide either ?from=&to= (path mode) or ?pubkey= (neighborhood mode)"}`, http.StatusBadRequest)
+	// Sort by WoT score descending, then trim
+	sort.Slice(neighbors, func(i, j int) bool {
+		return neighbors[i].WotScore > neighbors[j].WotScore
+	})
+	if len(neighbors) > limit {
+		neighbors = neighbors[:limit]
+	}
+	return neighbors
 }
 
 // bfsPath finds the shortest path from source to target through the follow graph.
 // maxDepth limits search depth to prevent runaway BFS on large graphs.
 func bfsPath(source, target string, maxDepth int) ([]string, bool) {
+	return bfsPathFiltered(source, target, maxDepth, nil)
+}
+
+// bfsPathFiltered is bfsPath that only traverses intermediate nodes for which allow
+// returns true. The source and target are always allowed; a nil allow admits all.
+func bfsPathFiltered(source, target string, maxDepth int, allow func(string) bool) ([]string, bool) {
 	if source == target {
 		return []string{source}, true
 	}
@@ -1164,6 +1601,9 @@ func bfsPath(source, target string, maxDepth int) ([]string, bool) {
 			}
 			if !visited[next] {
 				visited[next] = true
+				if allow != nil && !allow(next) {
+					continue
+				}
 				newPath := make([]string, len(current.path)+1)
 				copy(newPath, current.path)
 				newPath[len(current.path)] = next
@@ -1184,8 +1624,23 @@ type TopEntry struct {
 }
 
 func handleTop(w http.ResponseWriter, r *http.Request) {
-	entries := graph.TopN(50)
-	stats := graph.Stats()
+	g, view := viewGraph(w, r)
+	if g == nil {
+		return
+	}
+	if format := r.URL.Query().Get("format"); exportFormats[format] && format != "json" {
+		var entries []ScoreEntry
+		if view == "" {
+			entries, _ = exportCache.Entries(g)
+			entries = entries[:min(len(entries), 50)]
+		} else {
+			entries = g.TopN(50) // the cache holds the shared graph's table
+		}
+		serveExportTable(w, r, g, format, entries, 0)
+		return
+	}
+	entries := g.TopN(50)
+	stats := g.Stats()
 	result := make([]TopEntry, len(entries))
 	for i, e := range entries {
 		m := meta.Get(e.Pubkey)
@@ -1202,68 +1657,191 @@ func handleTop(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
-func handleStats(w http.ResponseWriter, r *http.Request) {
-	stats := graph.Stats()
-	resp := map[string]interface{}{
-		"service":             "wot-scoring",
-		"protocol":            "NIP-85",
-		"operator":            "max@klabo.world",
-		"graph_nodes":         stats.Nodes,
-		"graph_edges":         stats.Edges,
-		"last_build":          stats.LastBuild,
-		"algorithm":           "PageRank",
-		"iterations":          20,
-		"damping_factor":      0.85,
-		"relays":              relays,
-		"score_range":         "0-100 (normalized)",
-		"rate_limit":          "100 req/min per IP",
-		"timestamp":           time.Now().UTC().Format(time.RFC3339),
-		"verification_method": "follow-graph-crawl",
-	}
+// HealthResponse is the /health body.
+type HealthResponse struct {
+	Status             string                 `json:"status"`
+	RelayHealth        map[string]interface{} `json:"relay_health"`
+	GraphNodes         int                    `json:"graph_nodes"`
+	GraphEdges         int                    `json:"graph_edges"`
+	Events             int                    `json:"events"`
+	Addressable        int                    `json:"addressable"`
+	External           int                    `json:"external"`
+	ExternalProviders  int                    `json:"external_providers"`
+	ExternalAssertions int                    `json:"external_assertions"`
+	Authorizations     int                    `json:"authorizations"`
+	AuthorizedUsers    int                    `json:"authorized_users"`
+	Communities        int                    `json:"communities"`
+	MuteLists          int                    `json:"mute_lists"`
+	MutedPubkeys       int                    `json:"muted_pubkeys"`
+	GraphStore         map[string]interface{} `json:"graph_store"`
+	Uptime             string                 `json:"uptime"`
+}
 
+// handleHealth serves GET /health: service status, data freshness, and store sizes.
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	stats := graph.Stats()
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(HealthResponse{
+		Status:             relayHealth.Status(stats.Nodes),
+		RelayHealth:        relayHealth.Report(config.Relays()),
+		GraphNodes:         stats.Nodes,
+		GraphEdges:         stats.Edges,
+		Events:             events.EventCount(),
+		Addressable:        events.AddressableCount(),
+		External:           external.Count(),
+		ExternalProviders:  externalAssertions.ProviderCount(),
+		ExternalAssertions: externalAssertions.TotalAssertions(),
+		Authorizations:     authStore.TotalAuthorizations(),
+		AuthorizedUsers:    authStore.TotalUsers(),
+		Communities:        communities.TotalCommunities(),
+		MuteLists:          muteStore.TotalMuters(),
+		MutedPubkeys:       muteStore.TotalMuted(),
+		GraphStore:         graphSharing.Status(),
+		Uptime:             time.Since(startTime).String(),
+	})
 }
 
-type ExportEntry struct {
-	Pubkey string  `json:"pubkey"`
-	Rank   int     `json:"rank"`
-	Raw    float64 `json:"raw"`
+// StatsResponse is the /stats body: the scoring setup and the state of each
+// subsystem. Subsystem blocks report whatever that subsystem tracks, so their
+// fields aren't fixed here.
+type StatsResponse struct {
+	Service             string                   `json:"service"`
+	Protocol            string                   `json:"protocol"`
+	Operator            string                   `json:"operator"`
+	GraphNodes          int                      `json:"graph_nodes"`
+	GraphEdges          int                      `json:"graph_edges"`
+	LastBuild           time.Time                `json:"last_build"`
+	Algorithm           string                   `json:"algorithm"`
+	Iterations          int                      `json:"iterations"`
+	DampingFactor       float64                  `json:"damping_factor"`
+	EdgeWeighting       EdgeWeights              `json:"edge_weighting"`
+	MuteScoring         MuteScoring              `json:"mute_scoring"`
+	MassFollow          map[string]interface{}   `json:"mass_follow"`
+	SpamReports         map[string]interface{}   `json:"spam_reports"`
+	Badges              map[string]interface{}   `json:"badges"`
+	DMNotifications     map[string]interface{}   `json:"dm_notifications"`
+	NostrQueries        map[string]interface{}   `json:"nostr_queries"`
+	Views               []map[string]interface{} `json:"views"`
+	InteractionPairs    int                      `json:"interaction_pairs"`
+	DistrustedPubkeys   int                      `json:"distrusted_pubkeys"`
+	Relays              []string                 `json:"relays"`
+	RelayInfo           []RelayInfo              `json:"relay_info"`
+	Config              ConfigStatus             `json:"config"`
+	IngestPartners      []IngestPartnerStats     `json:"ingest_partners"`
+	ScoreRange          string                   `json:"score_range"`
+	RateLimit           string                   `json:"rate_limit"`
+	RateLimitTiers      []RateTier               `json:"rate_limit_tiers"`
+	RateLimitBackend    string                   `json:"rate_limit_backend"`
+	GraphStore          map[string]interface{}   `json:"graph_store"`
+	Pruning             map[string]interface{}   `json:"pruning"`
+	IncrementalPageRank IncrementalStats         `json:"incremental_pagerank"`
+	ScoreCache          map[string]interface{}   `json:"score_cache"`
+	SimilarityIndex     map[string]interface{}   `json:"similarity_index"`
+	ZapVerification     map[string]interface{}   `json:"zap_verification"`
+	PercentileBuckets   []PercentileBucket       `json:"percentile_buckets"`
+	Signer              map[string]interface{}   `json:"signer"`
+	Timestamp           string                   `json:"timestamp"`
+	VerificationMethod  string                   `json:"verification_method"`
+}
+
+// IncrementalStats is how far scores have drifted through incremental updates
+// since the last full rebuild, and how many updates force the next one.
+type IncrementalStats struct {
+	SinceRebuild IncrementalDrift `json:"since_rebuild"`
+	RebuildAfter int              `json:"rebuild_after"`
 }
 
-func handleExport(w http.ResponseWriter, r *http.Request) {
+func handleStats(w http.ResponseWriter, r *http.Request) {
 	stats := graph.Stats()
-	if stats.Nodes == 0 {
-		http.Error(w, `{"error":"graph not built yet"}`, http.StatusServiceUnavailable)
-		return
-	}
-	entries := graph.TopN(0) // 0 = all
-	result := make([]ExportEntry, len(entries))
-	for i, e := range entries {
-		result[i] = ExportEntry{
-			Pubkey: e.Pubkey,
-			Rank:   normalizeScore(e.Score, stats.Nodes),
-			Raw:    e.Score,
-		}
+	cfg := config.Get()
+	algorithm := "PageRank"
+	if edgeWeights.Enabled {
+		algorithm = "Weighted PageRank"
+	}
+	if muteScoring.Enabled {
+		algorithm += " with mute penalties"
+	}
+	resp := StatsResponse{
+		Service:           "wot-scoring",
+		Protocol:          "NIP-85",
+		Operator:          "max@klabo.world",
+		GraphNodes:        stats.Nodes,
+		GraphEdges:        stats.Edges,
+		LastBuild:         stats.LastBuild,
+		Algorithm:         algorithm,
+		Iterations:        cfg.PageRankIterations,
+		DampingFactor:     cfg.Damping,
+		EdgeWeighting:     edgeWeights,
+		MuteScoring:       muteScoring,
+		MassFollow:        massFollows.Stats(),
+		SpamReports:       spamReporter.Stats(cfg),
+		Badges:            badgeIssuer.Stats(cfg),
+		DMNotifications:   dmNotifier.Stats(cfg),
+		NostrQueries:      queryResponder.Stats(cfg),
+		Views:             seedViews.Stats(cfg),
+		InteractionPairs:  interactions.PairCount(),
+		DistrustedPubkeys: distrust.Count(),
+		Relays:            cfg.Relays,
+		RelayInfo:         relayLimits.Snapshot(cfg.Relays),
+		Config:            config.Status(),
+		IngestPartners:    ingestStore.Snapshot(),
+		ScoreRange:        "0-100 (normalized)",
+		RateLimit:         fmt.Sprintf("%d req/min per IP", rateTiers.Anonymous.limit),
+		RateLimitTiers:    rateTiers.Tiers(),
+		RateLimitBackend:  rateTiers.Backend,
+		GraphStore:        graphSharing.Status(),
+		Pruning:           graphPruning.Status(cfg),
+		IncrementalPageRank: IncrementalStats{
+			SinceRebuild: graph.Drift(),
+			RebuildAfter: incrementalRebuildAfter,
+		},
+		ScoreCache:         scoreResponseCache.Stats(),
+		SimilarityIndex:    similarityIndex.Stats(),
+		ZapVerification:    zapVerifier.Stats(),
+		PercentileBuckets:  graph.PercentileBuckets(),
+		Signer:             signers.Status(),
+		Timestamp:          time.Now().UTC().Format(time.RFC3339),
+		VerificationMethod: "follow-graph-crawl",
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(result)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// AuthorizedResponse is the /authorized body: the users who authorized a
+// provider (kind 10040) to publish their assertions.
+type AuthorizedResponse struct {
+	Provider            string           `json:"provider"`
+	AuthorizedUsers     []AuthorizedUser `json:"authorized_users"`
+	AuthorizedCount     int              `json:"authorized_count"`
+	TotalUsers          int              `json:"total_users"`
+	TotalAuthorizations int              `json:"total_authorizations"`
+}
+
+// AuthorizedUser is a user who authorized the provider, with their score and the
+// state of publishing their follows' assertions.
+type AuthorizedUser struct {
+	Pubkey     string           `json:"pubkey"`
+	Rank       int              `json:"rank"`
+	Publishing *AuthorizerQueue `json:"publishing,omitempty"`
 }
 
 func handleAuthorized(w http.ResponseWriter, r *http.Request) {
-	pubkey := r.URL.Query().Get("pubkey")
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", false)
+	if q.Failed(w) {
+		return
+	}
 
 	// If no pubkey specified, show our own authorized users
 	if pubkey == "" {
 		// Get our own pubkey
 		ownPub := ""
-		if nsec, err := getNsec(); err == nil {
-			if _, pub, err := decodeKey(nsec); err == nil {
-				ownPub = pub
-			}
+		if signer, err := signers.Get(r.Context()); err == nil {
+			ownPub = signer.PublicKey()
 		}
 		if ownPub == "" {
-			http.Error(w, `{"error":"provider pubkey not available"}`, http.StatusInternalServerError)
+			writeAPIError(w, &APIError{Status: http.StatusInternalServerError, Message: "provider pubkey not available"})
 			return
 		}
 		pubkey = ownPub
@@ -1273,54 +1851,78 @@ func handleAuthorized(w http.ResponseWriter, r *http.Request) {
 	count := authStore.AuthorizedCount(pubkey)
 
 	// Enrich with scores
-	type AuthUser struct {
-		Pubkey string `json:"pubkey"`
-		Rank   int    `json:"rank"`
-	}
 	stats := graph.Stats()
-	enriched := make([]AuthUser, 0, len(users))
+	enriched := make([]AuthorizedUser, 0, len(users))
 	for _, u := range users {
 		score, _ := graph.GetScore(u)
-		enriched = append(enriched, AuthUser{
-			Pubkey: u,
-			Rank:   normalizeScore(score, stats.Nodes),
+		enriched = append(enriched, AuthorizedUser{
+			Pubkey:     u,
+			Rank:       normalizeScore(score, stats.Nodes),
+			Publishing: authorizedPublisher.Status(u),
 		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"provider":            pubkey,
-		"authorized_users":    enriched,
-		"authorized_count":    count,
-		"total_users":         authStore.TotalUsers(),
-		"total_authorizations": authStore.TotalAuthorizations(),
+	json.NewEncoder(w).Encode(AuthorizedResponse{
+		Provider:            pubkey,
+		AuthorizedUsers:     enriched,
+		AuthorizedCount:     count,
+		TotalUsers:          authStore.TotalUsers(),
+		TotalAuthorizations: authStore.TotalAuthorizations(),
 	})
 }
 
+// CommunityResponse is the /communities?pubkey= body: the pubkey's community and
+// its 20 highest-scored members.
+type CommunityResponse struct {
+	Pubkey      string            `json:"pubkey"`
+	CommunityID int               `json:"community_id"`
+	Size        int               `json:"size"`
+	TopMembers  []CommunityMember `json:"top_members"`
+}
+
+// CommunityMember is a community member and their score.
+type CommunityMember struct {
+	Pubkey string `json:"pubkey"`
+	Rank   int    `json:"rank"`
+}
+
+// CommunitiesResponse is the /communities body without a pubkey: the 20
+// largest communities.
+type CommunitiesResponse struct {
+	TotalCommunities int         `json:"total_communities"`
+	Top              []Community `json:"top"`
+}
+
 func handleCommunities(w http.ResponseWriter, r *http.Request) {
-	pubkey := r.URL.Query().Get("pubkey")
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", false)
+	if q.Failed(w) {
+		return
+	}
+
+	if asOf := q.String("as_of", false); asOf != "" {
+		handleCommunitiesAsOf(w, pubkey, asOf)
+		return
+	}
 
 	if pubkey != "" {
 		// Show community for a specific pubkey
 		label, ok := communities.GetCommunity(pubkey)
 		if !ok {
-			http.Error(w, `{"error":"pubkey not found in community graph"}`, http.StatusNotFound)
+			writeAPIError(w, &APIError{Status: http.StatusNotFound, Message: "pubkey not found in community graph"})
 			return
 		}
 
 		members := communities.GetCommunityMembers(pubkey)
-		stats := graph.Stats()
-
-		type MemberEntry struct {
-			Pubkey string `json:"pubkey"`
-			Rank   int    `json:"rank"`
-		}
+		stats := g.Stats()
 
 		// Sort by score, limit to top 20
-		memberEntries := make([]MemberEntry, 0, len(members))
+		memberEntries := make([]CommunityMember, 0, len(members))
 		for _, m := range members {
-			score, _ := graph.GetScore(m)
-			memberEntries = append(memberEntries, MemberEntry{
+			score, _ := g.GetScore(m)
+			memberEntries = append(memberEntries, CommunityMember{
 				Pubkey: m,
 				Rank:   normalizeScore(score, stats.Nodes),
 			})
@@ -1333,157 +1935,139 @@ func handleCommunities(w http.ResponseWriter, r *http.Request) {
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"pubkey":       pubkey,
-			"community_id": label,
-			"size":         len(members),
-			"top_members":  memberEntries,
+		json.NewEncoder(w).Encode(CommunityResponse{
+			Pubkey:      pubkey,
+			CommunityID: label,
+			Size:        len(members),
+			TopMembers:  memberEntries,
 		})
 		return
 	}
 
 	// No pubkey: return top communities
-	top := communities.TopCommunities(graph, 20, 5)
+	top := communities.TopCommunities(g, 20, 5)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"total_communities": communities.TotalCommunities(),
-		"top":               top,
+	json.NewEncoder(w).Encode(CommunitiesResponse{
+		TotalCommunities: communities.TotalCommunities(),
+		Top:              top,
 	})
 }
 
-// getNsec reads the nsec from env or 1Password
-func getNsec() (string, error) {
-	if nsec := os.Getenv("NOSTR_NSEC"); nsec != "" {
-		return nsec, nil
-	}
-	out, err := exec.Command("op", "item", "get", "SATMAX Nostr Identity - Max", "--vault", "Agents", "--fields", "nsec", "--reveal").Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to get nsec from 1Password: %w", err)
-	}
-	return strings.TrimSpace(string(out)), nil
-}
-
-// publishNIP85 publishes kind 30382 events for top-scored pubkeys
-func publishNIP85(ctx context.Context, topN int) (int, error) {
-	nsec, err := getNsec()
-	if err != nil {
-		return 0, fmt.Errorf("getNsec: %w", err)
-	}
-
-	var sk string
-	if strings.HasPrefix(nsec, "nsec") {
-		_, v, err := nip19.Decode(nsec)
-		if err != nil {
-			return 0, fmt.Errorf("nip19 decode: %w", err)
-		}
-		sk = v.(string)
-	} else {
-		sk = nsec
-	}
-
-	pub, err := nostr.GetPublicKey(sk)
-	if err != nil {
-		return 0, fmt.Errorf("getPublicKey: %w", err)
-	}
-
+// publishNIP85 signs kind 30382 events for the topN scored pubkeys and queues
+// them for the relays in chunks of publishChunkSize. It returns how many were queued.
+func publishNIP85(ctx context.Context, signer EventSigner, topN int) (int, error) {
+	pub := signer.PublicKey()
 	entries := graph.TopN(topN)
-	stats := graph.Stats()
-	pool := nostr.NewSimplePool(ctx)
-	published := 0
+	relays := config.Relays()
+	queued := 0
 	failed := 0
+	chunk := make([]nostr.Event, 0, publishChunkSize)
 
-	for i, entry := range entries {
-		rankScore := normalizeScore(entry.Score, stats.Nodes)
-		m := meta.Get(entry.Pubkey)
-
-		tags := nostr.Tags{
-			{"d", entry.Pubkey},
-			{"p", entry.Pubkey},
-			{"rank", fmt.Sprintf("%d", rankScore)},
-			{"followers", fmt.Sprintf("%d", m.Followers)},
-			{"post_cnt", fmt.Sprintf("%d", m.PostCount)},
-			{"reply_cnt", fmt.Sprintf("%d", m.ReplyCount)},
-			{"reactions_cnt", fmt.Sprintf("%d", m.ReactionsRecd)},
-			{"zap_amt_recd", fmt.Sprintf("%d", m.ZapAmtRecd)},
-			{"zap_cnt_recd", fmt.Sprintf("%d", m.ZapCntRecd)},
-			{"zap_amt_sent", fmt.Sprintf("%d", m.ZapAmtSent)},
-			{"zap_cnt_sent", fmt.Sprintf("%d", m.ZapCntSent)},
-		}
-		if m.FirstCreated > 0 {
-			tags = append(tags, nostr.Tag{"first_created_at", fmt.Sprintf("%d", m.FirstCreated)})
-
-			// Compute avg daily zap amounts
-			daysSinceFirst := float64(time.Now().Unix()-m.FirstCreated) / 86400.0
-			if daysSinceFirst > 1 {
-				tags = append(tags, nostr.Tag{"zap_avg_amt_day_recd", fmt.Sprintf("%d", int64(float64(m.ZapAmtRecd)/daysSinceFirst))})
-				tags = append(tags, nostr.Tag{"zap_avg_amt_day_sent", fmt.Sprintf("%d", int64(float64(m.ZapAmtSent)/daysSinceFirst))})
-			}
-		}
-
-		// Active hours
-		activeStart, activeEnd := m.ActiveHours()
-		if activeStart != activeEnd {
-			tags = append(tags, nostr.Tag{"active_hours_start", fmt.Sprintf("%d", activeStart)})
-			tags = append(tags, nostr.Tag{"active_hours_end", fmt.Sprintf("%d", activeEnd)})
-		}
-
-		// Reports
-		if m.ReportsRecd > 0 {
-			tags = append(tags, nostr.Tag{"reports_cnt_recd", fmt.Sprintf("%d", m.ReportsRecd)})
-		}
-		if m.ReportsSent > 0 {
-			tags = append(tags, nostr.Tag{"reports_cnt_sent", fmt.Sprintf("%d", m.ReportsSent)})
-		}
-
-		// Top topics (up to 5 hashtags)
-		for _, topic := range m.TopTopics(5) {
-			tags = append(tags, nostr.Tag{"t", topic})
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			break
 		}
+		ev := buildUserAssertion(pub, entry.Pubkey, entry.Score)
 
-		ev := nostr.Event{
-			PubKey:    pub,
-			CreatedAt: nostr.Now(),
-			Kind:      30382,
-			Tags:      tags,
+		if err := validateAssertionEvent(&ev); err != nil {
+			log.Printf("Skipping kind 30382 for %s: %v", entry.Pubkey, err)
+			failed++
+			continue
 		}
-
-		err := ev.Sign(sk)
+		err := signer.Sign(ctx, &ev)
 		if err != nil {
 			log.Printf("Failed to sign event for %s: %v", entry.Pubkey, err)
 			failed++
 			continue
 		}
 
-		ok := false
-		for result := range pool.PublishMany(ctx, relays, ev) {
-			if result.Error != nil {
-				log.Printf("Publish to %s failed: %v", result.RelayURL, result.Error)
-			} else {
-				ok = true
-			}
-		}
-		if ok {
-			published++
-		} else {
-			failed++
+		chunk = append(chunk, ev)
+		if len(chunk) == publishChunkSize {
+			queued += publishQueue.Enqueue(chunk, relays)
+			chunk = chunk[:0]
+			log.Printf("Queued %d/%d NIP-85 events (%d failed)", queued, len(entries), failed)
 		}
+	}
+	queued += publishQueue.Enqueue(chunk, relays)
 
-		// Rate limit: sleep between events to avoid relay rate limits
-		time.Sleep(100 * time.Millisecond)
-		if (i+1)%50 == 0 {
-			log.Printf("Published %d/%d NIP-85 events (%d failed)", published, i+1, failed)
-			time.Sleep(2 * time.Second) // longer pause every 50
+	log.Printf("Queued %d NIP-85 kind 30382 events (%d failed)", queued, failed)
+	return queued, ctx.Err()
+}
+
+// buildUserAssertion builds the unsigned kind 30382 assertion about pubkey,
+// signed by pub, from its raw PageRank score and crawled metadata.
+func buildUserAssertion(pub, pubkey string, rawScore float64) nostr.Event {
+	rankScore := graph.NormalizeScore(rawScore, "")
+	m := meta.Get(pubkey)
+
+	tags := nostr.Tags{
+		{"d", pubkey},
+		{"p", pubkey},
+		{"rank", fmt.Sprintf("%d", rankScore)},
+		{"followers", fmt.Sprintf("%d", m.Followers)},
+		{"post_cnt", fmt.Sprintf("%d", m.PostCount)},
+		{"reply_cnt", fmt.Sprintf("%d", m.ReplyCount)},
+		{"reactions_cnt", fmt.Sprintf("%d", m.ReactionsRecd)},
+		{"zap_amt_recd", fmt.Sprintf("%d", m.ZapAmtRecd)},
+		{"zap_cnt_recd", fmt.Sprintf("%d", m.ZapCntRecd)},
+		{"zap_amt_sent", fmt.Sprintf("%d", m.ZapAmtSent)},
+		{"zap_cnt_sent", fmt.Sprintf("%d", m.ZapCntSent)},
+	}
+	if bucket := graph.PercentileBucket(pubkey); bucket > 0 {
+		tags = append(tags, nostr.Tag{"percentile", fmt.Sprintf("%d", bucket)})
+	}
+	if m.FirstCreated > 0 {
+		tags = append(tags, nostr.Tag{"first_created_at", fmt.Sprintf("%d", m.FirstCreated)})
+
+		// Compute avg daily zap amounts
+		daysSinceFirst := float64(time.Now().Unix()-m.FirstCreated) / 86400.0
+		if daysSinceFirst > 1 {
+			tags = append(tags, nostr.Tag{"zap_avg_amt_day_recd", fmt.Sprintf("%d", int64(float64(m.ZapAmtRecd)/daysSinceFirst))})
+			tags = append(tags, nostr.Tag{"zap_avg_amt_day_sent", fmt.Sprintf("%d", int64(float64(m.ZapAmtSent)/daysSinceFirst))})
 		}
 	}
 
-	log.Printf("Published %d NIP-85 kind 30382 events (%d failed)", published, failed)
-	return published, nil
+	// Active hours
+	activeStart, activeEnd := m.ActiveHours()
+	if activeStart != activeEnd {
+		tags = append(tags, nostr.Tag{"active_hours_start", fmt.Sprintf("%d", activeStart)})
+		tags = append(tags, nostr.Tag{"active_hours_end", fmt.Sprintf("%d", activeEnd)})
+	}
+
+	// Reports
+	if m.VerifiedZapCntRecd > 0 {
+		tags = append(tags, nostr.Tag{"verified_zap_amt_recd", fmt.Sprintf("%d", m.VerifiedZapAmtRecd)})
+		tags = append(tags, nostr.Tag{"verified_zap_cnt_recd", fmt.Sprintf("%d", m.VerifiedZapCntRecd)})
+	}
+	if m.ReportsRecd > 0 {
+		tags = append(tags, nostr.Tag{"reports_cnt_recd", fmt.Sprintf("%d", m.ReportsRecd)})
+	}
+	if m.ReportsSent > 0 {
+		tags = append(tags, nostr.Tag{"reports_cnt_sent", fmt.Sprintf("%d", m.ReportsSent)})
+	}
+
+	// Top topics (up to 5 hashtags)
+	for _, topic := range m.TopTopics(5) {
+		tags = append(tags, nostr.Tag{"t", topic})
+	}
+
+	// NIP-32 labels from trusted third-party annotations (opt-in)
+	if publishAnnotationLabels() {
+		tags = append(tags, annotationLabelTags(annotations, pubkey)...)
+	}
+
+	return nostr.Event{
+		PubKey:    pub,
+		CreatedAt: nostr.Now(),
+		Kind:      30382,
+		Tags:      tags,
+	}
 }
 
 // publishNIP89Handler publishes a kind 31990 event announcing this service
 // as a NIP-85 assertion provider (NIP-89 Recommended Application Handlers).
-func publishNIP89Handler(ctx context.Context, sk, pub string) error {
+func publishNIP89Handler(ctx context.Context, signer EventSigner) error {
 	pool := nostr.NewSimplePool(ctx)
 
 	// Content is kind-0-style metadata about the service
@@ -1497,7 +2081,7 @@ func publishNIP89Handler(ctx context.Context, sk, pub string) error {
 	})
 
 	ev := nostr.Event{
-		PubKey:    pub,
+		PubKey:    signer.PublicKey(),
 		CreatedAt: nostr.Now(),
 		Kind:      31990,
 		Content:   string(content),
@@ -1510,13 +2094,16 @@ func publishNIP89Handler(ctx context.Context, sk, pub string) error {
 			{"web", "https://github.com/joelklabo/wot-scoring"},
 		},
 	}
+	if config.Get().NostrQueries {
+		ev.Tags = append(ev.Tags, nostr.Tag{"k", fmt.Sprint(queryRequestKind)})
+	}
 
-	if err := ev.Sign(sk); err != nil {
+	if err := signer.Sign(ctx, &ev); err != nil {
 		return fmt.Errorf("sign kind 31990: %w", err)
 	}
 
 	published := false
-	for result := range pool.PublishMany(ctx, relays, ev) {
+	for result := range pool.PublishMany(ctx, config.Relays(), ev) {
 		if result.Error != nil {
 			log.Printf("NIP-89 publish to %s failed: %v", result.RelayURL, result.Error)
 		} else {
@@ -1530,80 +2117,138 @@ func publishNIP89Handler(ctx context.Context, sk, pub string) error {
 	return nil
 }
 
+// PublishResponse is the POST /publish body: how many events of each kind were
+// queued for the relays.
+type PublishResponse struct {
+	Kind30382       int      `json:"kind_30382"`
+	Authorized30382 int      `json:"authorized_30382"`
+	Kind30383       int      `json:"kind_30383"`
+	Kind30384       int      `json:"kind_30384"`
+	Kind30385       int      `json:"kind_30385"`
+	Relay30385      int      `json:"relay_30385"`
+	Kind30000       int      `json:"kind_30000"`
+	Kind1984        int      `json:"kind_1984"`
+	Badges          int      `json:"badges"`
+	Kind31990       string   `json:"kind_31990"` // "published" or the error
+	Total           int      `json:"total"`
+	QueueDepth      int      `json:"queue_depth"`
+	Algorithm       string   `json:"algorithm"`
+	GraphNodes      int      `json:"graph_nodes"`
+	GraphEdges      int      `json:"graph_edges"`
+	Relays          []string `json:"relays"`
+	Timestamp       string   `json:"timestamp"`
+}
+
 func handlePublish(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, `{"error":"POST required"}`, http.StatusMethodNotAllowed)
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	// Publishing signs as the provider, so only the operator can start it
+	if !requireAdmin(w, r) {
 		return
 	}
 
 	stats := graph.Stats()
 	if stats.Nodes == 0 {
-		http.Error(w, `{"error":"graph not built yet"}`, http.StatusServiceUnavailable)
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "graph not built yet"})
 		return
 	}
 
-	nsec, err := getNsec()
+	ctx := r.Context()
+	signer, err := signers.Get(ctx)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+		writeAPIError(w, &APIError{Status: http.StatusInternalServerError, Message: err.Error()})
 		return
 	}
-	sk, pub, err := decodeKey(nsec)
+
+	// Queue kind 30382 (user assertions)
+	count382, err := publishNIP85(ctx, signer, config.Get().PublishTopN)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+		writeAPIError(w, &APIError{Status: http.StatusInternalServerError, Message: fmt.Sprintf("30382: %s", err.Error())})
 		return
 	}
 
-	ctx := r.Context()
-
-	// Publish kind 30382 (user assertions)
-	count382, err := publishNIP85(ctx, 50)
+	// Queue kind 30382 about the follows of kind 10040 authorizers
+	countAuthorized, err := authorizedPublisher.Publish(ctx, signer, authStore, graph.Snapshot())
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"30382: %s"}`, err.Error()), http.StatusInternalServerError)
-		return
+		log.Printf("Error publishing authorized kind 30382: %v", err)
 	}
 
-	// Publish kind 30383 (event assertions)
-	count383, err := publishEventAssertions(ctx, events, sk, pub)
+	// Queue kind 30383 (event assertions)
+	count383, err := publishEventAssertions(ctx, events, signer)
 	if err != nil {
 		log.Printf("Error publishing kind 30383: %v", err)
 	}
 
-	// Publish kind 30384 (addressable event assertions)
-	count384, err := publishAddressableAssertions(ctx, events, sk, pub)
+	// Queue kind 30384 (addressable event assertions)
+	count384, err := publishAddressableAssertions(ctx, events, signer)
 	if err != nil {
 		log.Printf("Error publishing kind 30384: %v", err)
 	}
 
-	// Publish kind 30385 (external identifier assertions)
-	count385, err := publishExternalAssertions(ctx, external, sk, pub)
+	// Queue kind 30385 (external identifier assertions)
+	count385, err := publishExternalAssertions(ctx, external, signer)
 	if err != nil {
 		log.Printf("Error publishing kind 30385: %v", err)
 	}
 
+	// Queue kind 30385 relay trust assertions (d = relay URL)
+	countRelays, err := publishRelayAssertions(ctx, relayStore, signer)
+	if err != nil {
+		log.Printf("Error publishing kind 30385 relay assertions: %v", err)
+	}
+
+	// Queue kind 30000 (NIP-51 people lists above score thresholds)
+	count30000, err := publishPeopleLists(ctx, signer, config.Get().PublishLists)
+	if err != nil {
+		log.Printf("Error publishing kind 30000: %v", err)
+	}
+
+	// Queue kind 1984 (NIP-56 spam reports, when enabled)
+	count1984, err := spamReporter.Publish(ctx, signer, config.Get())
+	if err != nil {
+		log.Printf("Error publishing kind 1984: %v", err)
+	}
+
+	// Queue NIP-58 badge definitions, awards and revocations (when enabled)
+	countBadges, err := badgeIssuer.Publish(ctx, signer, config.Get())
+	if err != nil {
+		log.Printf("Error publishing badges: %v", err)
+	}
+
 	// Publish NIP-89 handler announcement (kind 31990)
-	nip89Err := publishNIP89Handler(ctx, sk, pub)
+	nip89Err := publishNIP89Handler(ctx, signer)
 	nip89Status := "published"
 	if nip89Err != nil {
 		nip89Status = fmt.Sprintf("error: %s", nip89Err.Error())
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"kind_30382":  count382,
-		"kind_30383":  count383,
-		"kind_30384":  count384,
-		"kind_30385":  count385,
-		"kind_31990":  nip89Status,
-		"total":       count382 + count383 + count384 + count385,
-		"algorithm":   "pagerank + engagement",
-		"graph_nodes": stats.Nodes,
-		"graph_edges": stats.Edges,
-		"relays":      relays,
-		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+	json.NewEncoder(w).Encode(PublishResponse{
+		Kind30382:       count382,
+		Authorized30382: countAuthorized,
+		Kind30383:       count383,
+		Kind30384:       count384,
+		Kind30385:       count385,
+		Relay30385:      countRelays,
+		Kind30000:       count30000,
+		Kind1984:        count1984,
+		Badges:          countBadges,
+		Kind31990:       nip89Status,
+		Total:           count382 + countAuthorized + count383 + count384 + count385 + countRelays + count30000 + count1984 + countBadges,
+		QueueDepth:      publishQueue.Status().QueueDepth,
+		Algorithm:       "pagerank + engagement",
+		GraphNodes:      stats.Nodes,
+		GraphEdges:      stats.Edges,
+		Relays:          config.Relays(),
+		Timestamp:       time.Now().UTC().Format(time.RFC3339),
 	})
 }
 
-// autoPublish runs a full NIP-85 publish cycle (all four assertion kinds + NIP-89).
+// autoPublish queues a full NIP-85 publish cycle (all four assertion kinds, plus
+// kind 30382 for authorizers' follows and kind 30385 relay trust assertions) and
+// any kind 30000 people lists, kind 1984 spam reports and NIP-58 badges, and
+// publishes the NIP-89 handler. Then it DMs subscribers whose scores changed.
 // Called after initial crawl and after each scheduled re-crawl.
 func autoPublish(ctx context.Context) {
 	stats := graph.Stats()
@@ -1612,12 +2257,7 @@ func autoPublish(ctx context.Context) {
 		return
 	}
 
-	nsec, err := getNsec()
-	if err != nil {
-		log.Printf("Auto-publish skipped: %v", err)
-		return
-	}
-	sk, pub, err := decodeKey(nsec)
+	signer, err := signers.Get(ctx)
 	if err != nil {
 		log.Printf("Auto-publish skipped: %v", err)
 		return
@@ -1625,33 +2265,63 @@ func autoPublish(ctx context.Context) {
 
 	log.Printf("Auto-publish starting (graph: %d nodes, %d edges)...", stats.Nodes, stats.Edges)
 
-	count382, err := publishNIP85(ctx, 50)
+	count382, err := publishNIP85(ctx, signer, config.Get().PublishTopN)
 	if err != nil {
 		log.Printf("Auto-publish kind 30382 error: %v", err)
 	}
 
-	count383, err := publishEventAssertions(ctx, events, sk, pub)
+	countAuthorized, err := authorizedPublisher.Publish(ctx, signer, authStore, graph.Snapshot())
+	if err != nil {
+		log.Printf("Auto-publish authorized kind 30382 error: %v", err)
+	}
+
+	count383, err := publishEventAssertions(ctx, events, signer)
 	if err != nil {
 		log.Printf("Auto-publish kind 30383 error: %v", err)
 	}
 
-	count384, err := publishAddressableAssertions(ctx, events, sk, pub)
+	count384, err := publishAddressableAssertions(ctx, events, signer)
 	if err != nil {
 		log.Printf("Auto-publish kind 30384 error: %v", err)
 	}
 
-	count385, err := publishExternalAssertions(ctx, external, sk, pub)
+	count385, err := publishExternalAssertions(ctx, external, signer)
 	if err != nil {
 		log.Printf("Auto-publish kind 30385 error: %v", err)
 	}
 
-	nip89Err := publishNIP89Handler(ctx, sk, pub)
+	countRelays, err := publishRelayAssertions(ctx, relayStore, signer)
+	if err != nil {
+		log.Printf("Auto-publish kind 30385 relay assertion error: %v", err)
+	}
+
+	count30000, err := publishPeopleLists(ctx, signer, config.Get().PublishLists)
+	if err != nil {
+		log.Printf("Auto-publish kind 30000 error: %v", err)
+	}
+
+	count1984, err := spamReporter.Publish(ctx, signer, config.Get())
+	if err != nil {
+		log.Printf("Auto-publish kind 1984 error: %v", err)
+	}
+
+	countBadges, err := badgeIssuer.Publish(ctx, signer, config.Get())
+	if err != nil {
+		log.Printf("Auto-publish badge error: %v", err)
+	}
+
+	nip89Err := publishNIP89Handler(ctx, signer)
 	if nip89Err != nil {
 		log.Printf("Auto-publish NIP-89 error: %v", nip89Err)
 	}
 
-	log.Printf("Auto-publish complete: 30382=%d, 30383=%d, 30384=%d, 30385=%d (total=%d)",
-		count382, count383, count384, count385, count382+count383+count384+count385)
+	log.Printf("Auto-publish queued: 30382=%d (+%d authorized), 30383=%d, 30384=%d, 30385=%d (+%d relays), 30000=%d, 1984=%d, badges=%d (total=%d)",
+		count382, countAuthorized, count383, count384, count385, countRelays, count30000, count1984, countBadges,
+		count382+countAuthorized+count383+count384+count385+countRelays+count30000+count1984+countBadges)
+
+	if _, err := runDMNotifications(ctx, config.Get()); err != nil {
+		log.Printf("Score change DMs skipped: %v", err)
+	}
 }
 
 // decodeKey converts an nsec (or raw hex) into sk and pubkey.
@@ -1673,10 +2343,23 @@ func decodeKey(nsec string) (string, string, error) {
 	return sk, pub, nil
 }
 
+// EventScoreResponse is the /event body: an event's engagement and its rank
+// against the most engaged event.
+type EventScoreResponse struct {
+	EventID        string `json:"event_id"`
+	Rank           int    `json:"rank"`
+	Comments       int    `json:"comments"`
+	Reposts        int    `json:"reposts"`
+	Reactions      int    `json:"reactions"`
+	ZapCount       int    `json:"zap_count"`
+	ZapAmount      int64  `json:"zap_amount"`
+	UniqueEngagers int    `json:"unique_engagers"`
+}
+
 func handleEventScore(w http.ResponseWriter, r *http.Request) {
-	eventID := r.URL.Query().Get("id")
-	if eventID == "" {
-		http.Error(w, `{"error":"id parameter required"}`, http.StatusBadRequest)
+	q := bindQuery(r)
+	eventID := q.String("id", true)
+	if q.Failed(w) {
 		return
 	}
 
@@ -1688,56 +2371,68 @@ func handleEventScore(w http.ResponseWriter, r *http.Request) {
 		maxEng = eventEngagement(topEvents[0])
 	}
 
-	resp := map[string]interface{}{
-		"event_id":  eventID,
-		"rank":      eventRank(m, maxEng),
-		"comments":  m.Comments,
-		"reposts":   m.Reposts,
-		"reactions": m.Reactions,
-		"zap_count": m.ZapCount,
-		"zap_amount": m.ZapAmount,
+	resp := EventScoreResponse{
+		EventID:        eventID,
+		Rank:           eventRank(m, maxEng),
+		Comments:       m.Comments,
+		Reposts:        m.Reposts,
+		Reactions:      m.Reactions,
+		ZapCount:       m.ZapCount,
+		ZapAmount:      m.ZapAmount,
+		UniqueEngagers: m.Engagers.Count(),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+// ExternalScore is the /external engagement profile of a NIP-73 identifier, with
+// its rank against the most engaged one.
+type ExternalScore struct {
+	Identifier       string  `json:"identifier"`
+	Kind             string  `json:"kind"`
+	Rank             int     `json:"rank"`
+	Mentions         int     `json:"mentions"`
+	UniqueAuthors    int     `json:"unique_authors"`
+	Reactions        int     `json:"reactions"`
+	Reposts          int     `json:"reposts"`
+	Comments         int     `json:"comments"`
+	ZapCount         int     `json:"zap_count"`
+	ZapAmount        int64   `json:"zap_amount"`
+	TrustedMentions  float64 `json:"trusted_mentions"`
+	TrustedReactions float64 `json:"trusted_reactions"`
+}
+
+func newExternalScore(identifier string, m *ExternalMeta, maxEng float64) ExternalScore {
+	return ExternalScore{
+		Identifier:       identifier,
+		Kind:             m.Kind,
+		Rank:             externalRank(m, maxEng),
+		Mentions:         m.Mentions,
+		UniqueAuthors:    m.Authors.Count(),
+		Reactions:        m.Reactions,
+		Reposts:          m.Reposts,
+		Comments:         m.Comments,
+		ZapCount:         m.ZapCount,
+		ZapAmount:        m.ZapAmount,
+		TrustedMentions:  round3(m.TrustedMentions),
+		TrustedReactions: round3(m.TrustedReactions),
+	}
+}
+
 func handleExternal(w http.ResponseWriter, r *http.Request) {
 	identifier := r.URL.Query().Get("id")
 	if identifier == "" {
 		// Return top external identifiers
 		topExternal := external.TopExternal(50)
-		var maxEng int64
+		var maxEng float64
 		if len(topExternal) > 0 {
-			maxEng = externalEngagement(topExternal[0])
-		}
-
-		type entry struct {
-			Identifier    string `json:"identifier"`
-			Kind          string `json:"kind"`
-			Rank          int    `json:"rank"`
-			Mentions      int    `json:"mentions"`
-			UniqueAuthors int    `json:"unique_authors"`
-			Reactions     int    `json:"reactions"`
-			Reposts       int    `json:"reposts"`
-			Comments      int    `json:"comments"`
-			ZapCount      int    `json:"zap_count"`
-			ZapAmount     int64  `json:"zap_amount"`
-		}
-		result := make([]entry, len(topExternal))
+			maxEng = externalTrustEngagement(topExternal[0])
+		}
+
+		result := make([]ExternalScore, len(topExternal))
 		for i, m := range topExternal {
-			result[i] = entry{
-				Identifier:    m.Identifier,
-				Kind:          m.Kind,
-				Rank:          externalRank(m, maxEng),
-				Mentions:      m.Mentions,
-				UniqueAuthors: len(m.Authors),
-				Reactions:     m.Reactions,
-				Reposts:       m.Reposts,
-				Comments:      m.Comments,
-				ZapCount:      m.ZapCount,
-				ZapAmount:     m.ZapAmount,
-			}
+			result[i] = newExternalScore(m.Identifier, m, maxEng)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -1747,65 +2442,134 @@ func handleExternal(w http.ResponseWriter, r *http.Request) {
 
 	m := external.Get(identifier)
 	topExternal := external.TopExternal(1)
-	var maxEng int64
+	var maxEng float64
 	if len(topExternal) > 0 {
-		maxEng = externalEngagement(topExternal[0])
+		maxEng = externalTrustEngagement(topExternal[0])
 	}
 
-	resp := map[string]interface{}{
-		"identifier":     identifier,
-		"kind":           m.Kind,
-		"rank":           externalRank(m, maxEng),
-		"mentions":       m.Mentions,
-		"unique_authors": len(m.Authors),
-		"reactions":      m.Reactions,
-		"reposts":        m.Reposts,
-		"comments":       m.Comments,
-		"zap_count":      m.ZapCount,
-		"zap_amount":     m.ZapAmount,
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(newExternalScore(identifier, m, maxEng))
+}
+
+func handleMetadata(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
+		return
 	}
 
+	resp := metadataProfile(pubkey, graph.Stats().Nodes)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-func handleMetadata(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
+// MetadataProfile is the /metadata body: a pubkey's NIP-85 metadata, named as in
+// its kind 30382 tags.
+type MetadataProfile struct {
+	Pubkey             string  `json:"pubkey"`
+	Found              bool    `json:"found"`
+	Rank               int     `json:"rank"`
+	RawScore           float64 `json:"raw_score"`
+	Followers          int     `json:"followers"`
+	PostCnt            int     `json:"post_cnt"`
+	ReplyCnt           int     `json:"reply_cnt"`
+	ReactionsCnt       int     `json:"reactions_cnt"`
+	ZapAmtRecd         int64   `json:"zap_amt_recd"`
+	ZapCntRecd         int     `json:"zap_cnt_recd"`
+	ZapAmtSent         int64   `json:"zap_amt_sent"`
+	ZapCntSent         int     `json:"zap_cnt_sent"`
+	VerifiedZapAmtRecd int64   `json:"verified_zap_amt_recd"`
+	VerifiedZapCntRecd int     `json:"verified_zap_cnt_recd"`
+	FirstCreatedAt     int64   `json:"first_created_at,omitempty"`
+}
+
+// metadataProfile builds the /metadata response body for one pubkey.
+func metadataProfile(pubkey string, graphSize int) MetadataProfile {
+	m := meta.Get(pubkey)
+	score, found := graph.GetScore(pubkey)
+
+	return MetadataProfile{
+		Pubkey:             pubkey,
+		Found:              found,
+		Rank:               normalizeScore(score, graphSize),
+		RawScore:           score,
+		Followers:          m.Followers,
+		PostCnt:            m.PostCount,
+		ReplyCnt:           m.ReplyCount,
+		ReactionsCnt:       m.ReactionsRecd,
+		ZapAmtRecd:         m.ZapAmtRecd,
+		ZapCntRecd:         m.ZapCntRecd,
+		ZapAmtSent:         m.ZapAmtSent,
+		ZapCntSent:         m.ZapCntSent,
+		VerifiedZapAmtRecd: m.VerifiedZapAmtRecd,
+		VerifiedZapCntRecd: m.VerifiedZapCntRecd,
+		FirstCreatedAt:     m.FirstCreated,
+	}
+}
+
+// MetadataBatchResponse is the POST /metadata/batch body, in request order.
+type MetadataBatchResponse struct {
+	Results   []MetadataBatchResult `json:"results"`
+	Count     int                   `json:"count"`
+	Found     int                   `json:"found"`
+	GraphSize int                   `json:"graph_size"`
+}
+
+// MetadataBatchResult is one /metadata/batch profile, or the input as pubkey
+// with error when it can't be read.
+type MetadataBatchResult struct {
+	Pubkey string `json:"pubkey"`
+	*MetadataProfile
+	Error string `json:"error,omitempty"`
+}
+
+// handleMetadataBatch serves POST /metadata/batch with up to 100 pubkeys,
+// returning the same profile as /metadata for each one.
+func handleMetadataBatch(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
 		return
 	}
 
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+	var req struct {
+		Pubkeys []string `json:"pubkeys"`
+	}
+	if apiErr := bindJSON(w, r, &req, 1<<20); apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	if len(req.Pubkeys) == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "pubkeys", Message: "pubkeys array required"})
+		return
+	}
+	if len(req.Pubkeys) > 100 {
+		writeAPIError(w, invalidParam("pubkeys", "max 100 pubkeys per request"))
 		return
 	}
 
-	m := meta.Get(pubkey)
-	score, found := graph.GetScore(pubkey)
 	stats := graph.Stats()
-
-	resp := map[string]interface{}{
-		"pubkey":        pubkey,
-		"found":         found,
-		"rank":          normalizeScore(score, stats.Nodes),
-		"raw_score":     score,
-		"followers":     m.Followers,
-		"post_cnt":      m.PostCount,
-		"reply_cnt":     m.ReplyCount,
-		"reactions_cnt": m.ReactionsRecd,
-		"zap_amt_recd":  m.ZapAmtRecd,
-		"zap_cnt_recd":  m.ZapCntRecd,
-		"zap_amt_sent":  m.ZapAmtSent,
-		"zap_cnt_sent":  m.ZapCntSent,
-	}
-	if m.FirstCreated > 0 {
-		resp["first_created_at"] = m.FirstCreated
+	results := make([]MetadataBatchResult, len(req.Pubkeys))
+	found := 0
+	for i, raw := range req.Pubkeys {
+		pubkey, err := resolvePubkey(raw)
+		if err != nil {
+			results[i] = MetadataBatchResult{Pubkey: raw, Error: err.Error()}
+			continue
+		}
+		profile := metadataProfile(pubkey, stats.Nodes)
+		if profile.Found {
+			found++
+		}
+		results[i] = MetadataBatchResult{Pubkey: pubkey, MetadataProfile: &profile}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(MetadataBatchResponse{
+		Results:   results,
+		Count:     len(results),
+		Found:     found,
+		GraphSize: stats.Nodes,
+	})
 }
 
 // corsMiddleware adds CORS headers so web apps can query the API directly.
@@ -1813,7 +2577,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-None-Match, If-Modified-Since, Authorization, X-API-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified, Cache-Control, X-Graph-Build, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-RateLimit-Tier, X-Account-Balance, X-Zap-Balance, X-Next-Cursor, Link, X-Bloom-Bits, X-Bloom-Hashes, X-Bloom-Count, X-Bloom-FPR, X-Bloom-Hash, X-Bloom-Depth, X-Bloom-Min-Score, X-Schema-Version")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -1885,8 +2650,10 @@ footer a{color:#7c3aed}
 <h3>Authentication &amp; Pricing</h3>
 <p style="color:#aaa;font-size:.9rem;margin:.5rem 0">All endpoints support <strong>CORS</strong> and accept <strong>hex pubkeys</strong>, <strong>npub</strong> (bech32), or <strong>NIP-05 identifiers</strong>.</p>
 <p style="color:#aaa;font-size:.9rem;margin:.5rem 0"><strong>Free tier:</strong> 10 requests/day per IP on priced endpoints. Unpriced endpoints are unlimited.</p>
-<p style="color:#aaa;font-size:.9rem;margin:.5rem 0"><strong>L402 payment flow:</strong> Request → 402 response with Lightning invoice → Pay invoice → Retry with <code style="color:#7c3aed">X-Payment-Hash</code> header.</p>
-<p style="color:#aaa;font-size:.9rem;margin:.5rem 0"><strong>Rate limit:</strong> 100 requests/min per IP.</p>
+<p style="color:#aaa;font-size:.9rem;margin:.5rem 0"><strong>L402 payment flow:</strong> Request → 402 response with Lightning invoice → Pay invoice → Retry with <code style="color:#7c3aed">X-Payment-Hash</code> header, or with <code style="color:#7c3aed">Authorization: L402 &lt;macaroon&gt;:&lt;preimage&gt;</code> using the macaroon from the 402. Macaroons expire, are scoped to one endpoint, and can be bought for up to 100 requests with <code style="color:#7c3aed">?l402_requests=N</code>. <a href="/l402/info" style="color:#7c3aed">GET /l402/info</a> shows pricing and a token's remaining requests.</p>
+<p style="color:#aaa;font-size:.9rem;margin:.5rem 0"><strong>Zap payment:</strong> Get a request ID from <a href="/zap/request" style="color:#7c3aed">GET /zap/request</a> and zap the service pubkey with <code style="color:#7c3aed">wot-credit:&lt;id&gt;</code> in the zap content. The sats become a balance for your pubkey, spent on priced endpoints by requests signed with NIP-98 (<code style="color:#7c3aed">Authorization: Nostr &lt;base64 kind 27235 event&gt;</code>, one event per request). <a href="/zap/balance" style="color:#7c3aed">GET /zap/balance</a> (signed) shows what's left.</p>
+<p style="color:#aaa;font-size:.9rem;margin:.5rem 0"><strong>API keys:</strong> <code style="color:#7c3aed">POST /account</code> with a NIP-98 <code style="color:#7c3aed">Authorization: Nostr</code> header returns an API key for your pubkey. Top it up over Lightning with <code style="color:#7c3aed">POST /account/topup?sats=N</code>, then send <code style="color:#7c3aed">X-API-Key</code> and each priced request is charged to the balance. <code style="color:#7c3aed">GET /account/usage</code> shows the balance and per-endpoint usage.</p>
+<p style="color:#aaa;font-size:.9rem;margin:.5rem 0"><strong>Rate limit:</strong> 100 requests/min per IP. Sign a NIP-42 auth event (kind 22242, <code style="color:#7c3aed">relay</code> tag set to this URL) and send it as <code style="color:#7c3aed">Authorization: Nostr &lt;base64 event&gt;</code> to be limited per pubkey instead: 300/min, or 1000/min for pubkeys scoring 50+. One event can be reused for 10 minutes.</p>
 <p style="color:#aaa;font-size:.9rem;margin:.5rem 0"><strong>Base URL:</strong> <code style="color:#7c3aed">https://wot.klabo.world</code></p>
 <p style="color:#aaa;font-size:.9rem;margin:.5rem 0"><strong>OpenAPI Spec:</strong> <a href="/openapi.json" style="color:#7c3aed">GET /openapi.json</a> — machine-readable API specification</p>
 <p style="color:#aaa;font-size:.9rem;margin:.5rem 0"><strong>API Explorer:</strong> <a href="/swagger" style="color:#7c3aed">Swagger UI</a> — interactive API testing in your browser</p>
@@ -1924,6 +2691,9 @@ footer a{color:#7c3aed}
 <div class="params">
 <div class="params-title">Parameters</div>
 <div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey, npub, or NIP-05 identifier <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">algorithm</span><span class="param-type">string</span><span class="param-desc"><code>pagerank</code> (default) or <code>hits</code> to add hub and authority scores and a curator/producer role</span></div>
+<div class="param"><span class="param-name">normalization</span><span class="param-type">string</span><span class="param-desc"><code>log</code> (default), <code>percentile</code>, <code>zscore</code> or <code>minmax</code>: the curve mapping raw PageRank to 0-100</span></div>
+<div class="param"><span class="param-name">view</span><span class="param-type">string</span><span class="param-desc">A seed view configured by the operator (listed under <code>views</code> in /stats) to score from that seed group's WoT instead; <code>default</code> or omitted for the main seeds</span></div>
 </div>
 <div class="example">
 <div class="example-title">Example</div>
@@ -1933,7 +2703,7 @@ footer a{color:#7c3aed}
 <div class="example-title">Response</div>
 <div class="code-block">{
   "pubkey": "82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2",
-  "score": 21, "raw_score": 0.00847, "found": true,
+  "score": 21, "normalization": "log", "raw_score": 0.00847, "found": true,
   "followers": 87421, "post_count": 1203, "reactions": 54302,
   "zap_amount": 1250000, "zap_count": 892,
   "topics": ["bitcoin", "nostr", "lightning"],
@@ -1951,10 +2721,11 @@ footer a{color:#7c3aed}
 <span class="path">/audit</span>
 <span class="price-tag">5 sats</span>
 </div>
-<div class="desc">Detailed breakdown of all scoring components: PageRank position, engagement metrics, top followers, external assertions, and graph context.</div>
+<div class="desc">Detailed breakdown of all scoring components: PageRank position, engagement metrics, top followers, external assertions, community endorsements, distrust from reports and mutes by trusted accounts, and graph context.</div>
 <div class="params">
 <div class="params-title">Parameters</div>
 <div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey, npub, or NIP-05 <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">normalization</span><span class="param-type">string</span><span class="param-desc"><code>log</code> (default), <code>percentile</code>, <code>zscore</code> or <code>minmax</code>: the curve mapping raw PageRank to 0-100</span></div>
 </div>
 <div class="example">
 <div class="example-title">Example</div>
@@ -1964,6 +2735,32 @@ footer a{color:#7c3aed}
 <div class="try-result"></div>
 </div>
 
+<div class="endpoint-card" id="ep-endorsements">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/endorsements</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Community endorsement breakdown. Members of a pubkey's trust community vouch for it with NIP-32 kind 1985 events (L=wot.endorsement, l=endorse, p=subject). With at least 3 endorsers scoring 30+, the score gets a bounded boost (2 per endorser, max 10, never above the endorsers' median score). Every endorser and why it did or didn't count is listed. Signed events can also be submitted with POST /endorsements.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-migrations">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/migrations</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Key migrations and delegations. An old key announces its successor with a NIP-41 kind 1776 event (p=new pubkey). Once 3 of the old key's followers (or all of them, if fewer) also follow the new key, the new key inherits half the old key's score as inherited_trust, shown on /score as migrated_score, and its account age counts from the old key's first event. NIP-26 delegations seen on crawled notes are listed for both sides. Signed events can also be submitted with POST /migrations.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+</div>
+</div>
+
 <div class="endpoint-card" id="ep-batch">
 <div class="endpoint-header">
 <span class="method method-post">POST</span>
@@ -1982,55 +2779,184 @@ footer a{color:#7c3aed}
 </div>
 </div>
 
-<!-- ===== PERSONALIZED ===== -->
-<h2 id="personalized">Personalized</h2>
-<p class="section-intro">Trust scoring relative to a viewer's perspective, based on follow graph proximity.</p>
-
-<div class="endpoint-card" id="ep-personalized">
+<div class="endpoint-card" id="ep-audit-batch">
 <div class="endpoint-header">
-<span class="method method-get">GET</span>
-<span class="path">/personalized</span>
-<span class="price-tag">2 sats</span>
+<span class="method method-post">POST</span>
+<span class="path">/audit/batch</span>
+<span class="price-tag">10 sats</span>
 </div>
-<div class="desc">Personalized trust score blending 50% global PageRank with 50% follow-graph proximity. Shows mutual follows, shared connections, and trusted followers of the target.</div>
+<div class="desc">The <code>/audit</code> breakdown for up to 50 pubkeys in one request, in request order. A pubkey that can't be resolved gets an <code>error</code> entry instead.</div>
 <div class="params">
-<div class="params-title">Parameters</div>
-<div class="param"><span class="param-name">viewer</span><span class="param-type">string</span><span class="param-desc">Viewer's pubkey <span class="param-req">required</span></span></div>
-<div class="param"><span class="param-name">target</span><span class="param-type">string</span><span class="param-desc">Target pubkey to evaluate <span class="param-req">required</span></span></div>
+<div class="params-title">Request Body (JSON)</div>
+<div class="param"><span class="param-name">pubkeys</span><span class="param-type">string[]</span><span class="param-desc">Array of hex pubkeys or npubs (max 50) <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">normalization</span><span class="param-type">string</span><span class="param-desc"><code>log</code> (default), <code>percentile</code>, <code>zscore</code> or <code>minmax</code></span></div>
 </div>
 <div class="example">
 <div class="example-title">Example</div>
-<div class="code-block">curl "https://wot.klabo.world/personalized?viewer=82341f...&amp;target=32e18..."</div>
+<div class="code-block">curl -X POST "https://wot.klabo.world/audit/batch" \
+  -d '{"pubkeys":["82341f...","32e18..."]}'</div>
 </div>
 </div>
 
-<div class="endpoint-card" id="ep-similar">
+<div class="endpoint-card" id="ep-personalized-batch">
 <div class="endpoint-header">
-<span class="method method-get">GET</span>
-<span class="path">/similar</span>
-<span class="price-tag">2 sats</span>
+<span class="method method-post">POST</span>
+<span class="path">/personalized/batch</span>
+<span class="price-tag">10 sats</span>
 </div>
-<div class="desc">Find users with similar follow patterns using Jaccard similarity (70% follow overlap + 30% WoT score).</div>
+<div class="desc">Personalized scores of up to 100 targets from one viewer, in request order, so a feed client can rank a page of authors with one request. Each result has the <code>/personalized</code> fields; <code>viewer</code> and <code>algorithm</code> are given once at the top.</div>
 <div class="params">
-<div class="params-title">Parameters</div>
-<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Reference pubkey <span class="param-req">required</span></span></div>
-<div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Results to return (1-50, default 20)</span></div>
+<div class="params-title">Request Body (JSON)</div>
+<div class="param"><span class="param-name">viewer</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub of the viewer <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">targets</span><span class="param-type">string[]</span><span class="param-desc">Hex pubkeys or npubs to score (max 100) <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">algorithm</span><span class="param-type">string</span><span class="param-desc"><code>blend</code> (default) or <code>ppr</code></span></div>
+</div>
+<div class="example">
+<div class="example-title">Example</div>
+<div class="code-block">curl -X POST "https://wot.klabo.world/personalized/batch" \
+  -d '{"viewer":"82341f...","targets":["32e18...","3bf0c6..."],"algorithm":"ppr"}'</div>
 </div>
-<button class="try-btn" onclick="tryEndpoint(this,'/similar?pubkey=32e1827635450ebb3c5a7d12c1f8e7b2b514439ac10a67eef3d9fd9c5c68e245&amp;limit=5')">Try it</button>
-<div class="try-result"></div>
 </div>
 
-<div class="endpoint-card" id="ep-recommend">
+<div class="endpoint-card" id="ep-graph-batch">
 <div class="endpoint-header">
-<span class="method method-get">GET</span>
-<span class="path">/recommend</span>
-<span class="price-tag">2 sats</span>
+<span class="method method-post">POST</span>
+<span class="path">/graph/batch</span>
+<span class="price-tag">10 sats</span>
+</div>
+<div class="desc">The <code>/graph</code> neighborhood of up to 25 pubkeys in one request, in request order. <code>depth</code> and <code>limit</code> apply to every pubkey and are clamped as in <code>/graph</code>.</div>
+<div class="params">
+<div class="params-title">Request Body (JSON)</div>
+<div class="param"><span class="param-name">pubkeys</span><span class="param-type">string[]</span><span class="param-desc">Array of hex pubkeys or npubs (max 25) <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">depth</span><span class="param-type">int</span><span class="param-desc">1 (default) or 2 to add follows of follows</span></div>
+<div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Neighbors per pubkey (default 50, max 200)</span></div>
+</div>
+<div class="example">
+<div class="example-title">Example</div>
+<div class="code-block">curl -X POST "https://wot.klabo.world/graph/batch" \
+  -d '{"pubkeys":["82341f...","32e18..."],"limit":20}'</div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-rank">
+<div class="endpoint-header">
+<span class="method method-post">POST</span>
+<span class="path">/rank</span>
+<span class="price-tag">10 sats</span>
+</div>
+<div class="desc">Rank a candidate feed rather than authors: up to 500 events scored by a blend of author WoT score, crawled engagement, recency, and how close the author is to the viewer, sorted best first with each component shown. Full signed events or <code>{id, pubkey, created_at}</code> objects are accepted.</div>
+<div class="params">
+<div class="params-title">Request Body (JSON)</div>
+<div class="param"><span class="param-name">events</span><span class="param-type">object[]</span><span class="param-desc">Events with id, pubkey and created_at (max 500) <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">viewer</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub to personalize for</span></div>
+<div class="param"><span class="param-name">weights</span><span class="param-type">object</span><span class="param-desc">author, engagement, recency, personal (relative; default 0.4, 0.3, 0.2, 0.1)</span></div>
+<div class="param"><span class="param-name">half_life_hours</span><span class="param-type">float</span><span class="param-desc">Age at which recency halves (default 24)</span></div>
+</div>
+<div class="example">
+<div class="example-title">Example</div>
+<div class="code-block">curl -X POST "https://wot.klabo.world/rank" \
+  -d '{"viewer":"82341f...","events":[{"id":"5c83da...","pubkey":"32e18...","created_at":1770710400}]}'</div>
 </div>
-<div class="desc">Friends-of-friends follow recommendations. Shows people your follows trust that you don't yet follow, ranked by mutual connection count.</div>
+</div>
+
+<div class="endpoint-card" id="ep-graphql">
+<div class="endpoint-header">
+<span class="method method-post">POST</span>
+<span class="path">/graphql</span>
+<span class="price-tag">10 sats</span>
+</div>
+<div class="desc">Query the graph, metadata and external assertions with GraphQL, fetching exactly the fields you need in one request. Root fields are <code>profile(pubkey)</code>, <code>profiles(pubkeys)</code>, <code>top(limit)</code> and <code>stats</code>; profiles expose score, rank, percentile, followers and follows (as nested profiles), community, anomalies, spam, activity and assertions. Fields are computed only when selected. <code>GET /graphql</code> returns the schema. Fragments, directives and introspection are not supported.</div>
+<div class="params">
+<div class="params-title">Request Body (JSON)</div>
+<div class="param"><span class="param-name">query</span><span class="param-type">string</span><span class="param-desc">GraphQL query (max 8 levels deep, 1000 profiles) <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">variables</span><span class="param-type">object</span><span class="param-desc">Values for the query's variables</span></div>
+<div class="param"><span class="param-name">operationName</span><span class="param-type">string</span><span class="param-desc">Operation to run when the query defines several</span></div>
+</div>
+<div class="example">
+<div class="example-title">Example</div>
+<div class="code-block">curl -X POST "https://wot.klabo.world/graphql" \
+  -d '{"query":"{ profile(pubkey: \"82341f...\") { score followers(limit: 3) { pubkey score } community { size } anomalies { riskLevel } } }"}'</div>
+</div>
+</div>
+
+<!-- ===== PERSONALIZED ===== -->
+<h2 id="personalized">Personalized</h2>
+<p class="section-intro">Trust scoring relative to a viewer's perspective, based on follow graph proximity.</p>
+
+<div class="endpoint-card" id="ep-personalized">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/personalized</span>
+<span class="price-tag">2 sats</span>
+</div>
+<div class="desc">Personalized trust score blending 50% global PageRank with 50% follow-graph proximity. Shows mutual follows, shared connections, and trusted followers of the target. With <code>algorithm=ppr</code>, scores the target with personalized PageRank — a random walk that restarts at the viewer — instead.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">viewer</span><span class="param-type">string</span><span class="param-desc">Viewer's pubkey <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">target</span><span class="param-type">string</span><span class="param-desc">Target pubkey to evaluate <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">algorithm</span><span class="param-type">string</span><span class="param-desc"><code>blend</code> (default) or <code>ppr</code> for personalized PageRank rooted at the viewer</span></div>
+</div>
+<div class="example">
+<div class="example-title">Example</div>
+<div class="code-block">curl "https://wot.klabo.world/personalized?viewer=82341f...&amp;target=32e18..."</div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-personalized-import">
+<div class="endpoint-header">
+<span class="method method-post">POST</span>
+<span class="path">/personalized/import</span>
+<span class="price-tag">Free</span>
+</div>
+<div class="desc">Submit the viewer's signed kind 3 contact list so <code>/personalized</code> (both algorithms) and <code>/recommend</code> use it right away, even if the crawl never picked the viewer up. The list overlays the graph for that viewer only and expires after 24 hours; a newer list replaces it and an older one is ignored (<code>stale_event</code>). Responses that used it report <code>viewer_follows_source</code> / <code>follows_source</code> as <code>import</code>. <code>DELETE /personalized/import?viewer=</code>, signed by the viewer with NIP-98, drops it.</div>
+<div class="params">
+<div class="params-title">Request Body (JSON)</div>
+<div class="param"><span class="param-name">event</span><span class="param-type">object</span><span class="param-desc">Signed kind 3 event by the viewer <span class="param-req">required</span></span></div>
+</div>
+<div class="example">
+<div class="example-title">Response</div>
+<div class="code-block">{
+  "viewer": "82341f...",
+  "status": "imported",
+  "follows": 312,
+  "event_id": "5c83da...",
+  "event_at": "2026-02-10T06:00:12Z",
+  "expires_at": "2026-02-11T06:01:40Z"
+}</div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-similar">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/similar</span>
+<span class="price-tag">2 sats</span>
+</div>
+<div class="desc">Find users with similar follow patterns using Jaccard similarity (70% follow overlap + 30% WoT score). Candidates come from a MinHash/LSH index over follow sets rebuilt after each crawl.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Reference pubkey <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Results to return (1-50, default 20)</span></div>
+</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/similar?pubkey=32e1827635450ebb3c5a7d12c1f8e7b2b514439ac10a67eef3d9fd9c5c68e245&amp;limit=5')">Try it</button>
+<div class="try-result"></div>
+</div>
+
+<div class="endpoint-card" id="ep-recommend">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/recommend</span>
+<span class="price-tag">2 sats</span>
+</div>
+<div class="desc">Friends-of-friends follow recommendations. Shows people your follows trust that you don't yet follow, ranked by mutual connection count. POST the same options as JSON with an <code>exclude</code> list to hide dismissed suggestions.</div>
 <div class="params">
 <div class="params-title">Parameters</div>
 <div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Your pubkey <span class="param-req">required</span></span></div>
 <div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Results (1-50, default 20)</span></div>
+<div class="param"><span class="param-name">diversity</span><span class="param-type">float</span><span class="param-desc">Penalize widely followed accounts (0-1, default 0)</span></div>
+<div class="param"><span class="param-name">min_followers</span><span class="param-type">int</span><span class="param-desc">Minimum follower count</span></div>
+<div class="param"><span class="param-name">max_followers</span><span class="param-type">int</span><span class="param-desc">Maximum follower count (0 = no limit)</span></div>
+<div class="param"><span class="param-name">topics</span><span class="param-type">string</span><span class="param-desc">Comma-separated hashtags a candidate must have posted under</span></div>
 </div>
 <button class="try-btn" onclick="tryEndpoint(this,'/recommend?pubkey=82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2&amp;limit=5')">Try it</button>
 <div class="try-result"></div>
@@ -2060,15 +2986,22 @@ footer a{color:#7c3aed}
 <span class="path">/graph</span>
 <span class="free">FREE</span>
 </div>
-<div class="desc">Two modes: <strong>Trust Path</strong> (shortest path between two pubkeys via BFS, max 6 hops) or <strong>Neighborhood</strong> (local follow network around a pubkey).</div>
+<div class="desc">Two modes: <strong>Trust Path</strong> (shortest path between two pubkeys via BFS, max 6 hops) or <strong>Neighborhood</strong> (local follow network around a pubkey). Path mode explains every hop: mutual or one-way, follow age, and community, plus spam/sybil classifications for intermediate accounts, with flagged ones counted in flagged_intermediates.</div>
 <div class="params">
 <div class="params-title">Path Mode</div>
 <div class="param"><span class="param-name">from</span><span class="param-type">string</span><span class="param-desc">Source pubkey <span class="param-req">required</span></span></div>
 <div class="param"><span class="param-name">to</span><span class="param-type">string</span><span class="param-desc">Target pubkey <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">max_hops</span><span class="param-type">int</span><span class="param-desc">Maximum hops (1-8, default 6)</span></div>
+<div class="param"><span class="param-name">avoid</span><span class="param-type">string</span><span class="param-desc">Skip intermediates: spam, low_score (comma-separated)</span></div>
+<div class="param"><span class="param-name">min_score</span><span class="param-type">int</span><span class="param-desc">Score intermediates must exceed with avoid=low_score (default 20)</span></div>
+<div class="param"><span class="param-name">k</span><span class="param-type">int</span><span class="param-desc">Number of paths to return, cheapest first (1-5, default 1)</span></div>
+<div class="param"><span class="param-name">weighted</span><span class="param-type">bool</span><span class="param-desc">Rank paths by trust-weighted edge cost instead of hop count (default false)</span></div>
+<div class="param"><span class="param-name">disjoint</span><span class="param-type">bool</span><span class="param-desc">With k, require paths that share no intermediate account (default false)</span></div>
 <div class="params-title" style="margin-top:.5rem">Neighborhood Mode</div>
 <div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Center pubkey <span class="param-req">required</span></span></div>
 <div class="param"><span class="param-name">depth</span><span class="param-type">int</span><span class="param-desc">Graph depth (1-2, default 1)</span></div>
-<div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Max neighbors (1-200, default 50)</span></div>
+<div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Max neighbors (1-200, default 50; 1-5000, default 500, for graph files)</span></div>
+<div class="param"><span class="param-name">format</span><span class="param-type">string</span><span class="param-desc">graphml, gexf or dot to download the neighborhood as a graph file for Gephi, Cytoscape or Graphviz</span></div>
 </div>
 </div>
 
@@ -2111,6 +3044,7 @@ footer a{color:#7c3aed}
 <div class="params">
 <div class="params-title">Parameters</div>
 <div class="param"><span class="param-name">id</span><span class="param-type">string</span><span class="param-desc">NIP-05 identifier (e.g. user@domain.com) <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">lang</span><span class="param-type">string</span><span class="param-desc"><code>en</code>, <code>de</code>, <code>es</code> or <code>ja</code> for <code>trust_level_label</code>; defaults to Accept-Language, then English</span></div>
 </div>
 <div class="example">
 <div class="example-title">Trust Levels</div>
@@ -2143,6 +3077,20 @@ footer a{color:#7c3aed}
 <div class="params">
 <div class="params-title">Parameters</div>
 <div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">lang</span><span class="param-type">string</span><span class="param-desc"><code>en</code>, <code>de</code>, <code>es</code> or <code>ja</code> for <code>trust_level_label</code>; defaults to Accept-Language, then English</span></div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-domain">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/domain</span>
+<span class="price-tag">5 sats</span>
+</div>
+<div class="desc">NIP-05 domain reputation: scores every pubkey verified under a domain (its full nostr.json, plus identifiers already resolved through /nip05) and reports the average and median WoT score, spam rate and best-scored members. Members outside the graph count as 0. render_checkmark says whether the domain's NIP-05 checkmark is worth showing (reputation 10+, at most 20% of in-graph members likely spam).</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">name</span><span class="param-type">string</span><span class="param-desc">Domain, e.g. example.com (a full user@domain identifier also works) <span class="param-req">required</span></span></div>
 </div>
 </div>
 
@@ -2176,6 +3124,113 @@ footer a{color:#7c3aed}
 <div class="try-result"></div>
 </div>
 
+<div class="endpoint-card" id="ep-history">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/history</span>
+<span class="price-tag">2 sats</span>
+</div>
+<div class="desc">Score history recorded at each rebuild: normalized score, rank and follower count per build, oldest first. Unlike /timeline nothing is estimated. The last 120 rebuilds (about 30 days) are kept.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">since</span><span class="param-type">string</span><span class="param-desc">Only rebuilds at or after this time (unix seconds or RFC 3339)</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response (abbreviated)</div>
+<div class="code-block">{
+  "pubkey": "...", "builds_recorded": 120, "oldest_build_at": "2026-01-11T06:00:12Z",
+  "points": [
+    {"build": 311, "at": "2026-02-09T18:00:09Z", "score": 17, "rank": 1204, "followers": 86210},
+    {"build": 312, "at": "2026-02-10T00:00:11Z", "score": 18, "rank": 1187, "followers": 87421}
+  ],
+  "score_change": 1, "rank_change": 17, "followers_change": 1211
+}</div>
+</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/history?pubkey=82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2')">Try it</button>
+<div class="try-result"></div>
+</div>
+
+<div class="endpoint-card" id="ep-contacts-snapshot">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/contacts/snapshot</span>
+<span class="price-tag">2 sats</span>
+</div>
+<div class="desc">Contact list versions observed for a pubkey by the crawler, hints, and partner ingestion, newest first. Each version lists the follows it added and removed; versions that drop at least 20 follows and half of the previous list are flagged as mass unfollows. Pass event_id, or before for the newest version created before a time, to get that version's full follow list and an unsigned kind 3 restore_event to sign and publish. Up to 20 versions are kept per pubkey.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">event_id</span><span class="param-type">string</span><span class="param-desc">Return the full list of this version</span></div>
+<div class="param"><span class="param-name">before</span><span class="param-type">string</span><span class="param-desc">Return the full list of the newest version created before this time (unix seconds or RFC 3339)</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response (abbreviated)</div>
+<div class="code-block">{
+  "pubkey": "...", "version_count": 3, "mass_unfollows": 1,
+  "versions": [
+    {"event_id": "c41e...", "created_at": "2026-02-09T08:12:40Z", "observed_at": "2026-02-09T12:00:03Z", "source": "crawl",
+     "follow_count": 12, "added_count": 0, "removed_count": 402, "removed": ["ab12...", "..."], "mass_unfollow": true},
+    {"event_id": "77d0...", "created_at": "2026-01-30T17:45:02Z", "observed_at": "2026-01-30T18:00:11Z", "source": "hint",
+     "follow_count": 414, "added_count": 2, "removed_count": 0, "added": ["f00d...", "beef..."], "mass_unfollow": false}
+  ],
+  "snapshot": {"event_id": "77d0...", "created_at": "2026-01-30T17:45:02Z", "follows": ["..."],
+    "restore_event": {"kind": 3, "pubkey": "...", "tags": [["p", "..."]], "content": "", "...": "..."}}
+}</div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-growth-sources">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/growth-sources</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Follower acquisition breakdown: which communities followers came from, their score tiers (strong/moderate/weak/unknown), and whether they arrived organically or in bursts. A burst is a day with at least 10 new followers and 5x the pubkey's mean daily rate; bursts are summarized with average follower score and zero-score share, and monthly periods show how the mix changed over time. Burst-driven growth also feeds the burst_acquisition flag in /anomalies.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+</div>
+<div class="example">
+<div class="example-title">Response (abbreviated)</div>
+<div class="code-block">{
+  "pubkey": "...", "total_followers": 1240, "followers_with_dates": 1180,
+  "communities": [{"community_id":17,"followers":610,"share":0.492,"recent_follows":12,"avg_score":41.2}],
+  "tiers": [{"tier":"strong","followers":88,"share":0.071}, ...],
+  "pacing": {"mean_daily_rate":1.4,"burst_follows":420,"organic_follows":760,"burst_ratio":0.356,"classification":"mixed",
+    "bursts": [{"start":"2026-01-03","end":"2026-01-04","follows":420,"avg_score":0.4,"zero_score_share":0.93,"top_community":52}]},
+  "periods": [{"month":"2026-01","new_followers":455,"burst_follows":420,"avg_score":2.1,"zero_score_share":0.87,"top_community":52}]
+}</div>
+</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/growth-sources?pubkey=82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2')">Try it</button>
+<div class="try-result"></div>
+</div>
+
+<div class="endpoint-card" id="ep-churn">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/churn</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Follows and unfollows of a pubkey, taken from the differences between successive kind 3 contact lists of its followers. Reports net growth, the unfollow rate (unfollows over followers at the start of the window), and the highest-scoring accounts that unfollowed and haven't followed again. At least 10 unfollows amounting to 20% of the starting followers is flagged as high_churn, a common sign of bought followers being cleaned out.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">days</span><span class="param-type">int</span><span class="param-desc">Window in days (1-365, default 30)</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response (abbreviated)</div>
+<div class="code-block">{
+  "pubkey": "...", "window_days": 30, "followers": 1240,
+  "follows": 36, "unfollows": 212, "net_growth": -176, "unfollow_rate": 0.149,
+  "trusted_unfollows": 9, "high_churn": false, "observed_since": "2026-01-02T04:11:09Z",
+  "top_unfollowers": [{"pubkey": "ab12...", "score": 71, "unfollowed_at": "2026-02-08T19:20:44Z"}]
+}</div>
+</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/churn?pubkey=82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2')">Try it</button>
+<div class="try-result"></div>
+</div>
+
 <div class="endpoint-card" id="ep-decay">
 <div class="endpoint-header">
 <span class="method method-get">GET</span>
@@ -2187,6 +3242,7 @@ footer a{color:#7c3aed}
 <div class="params-title">Parameters</div>
 <div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
 <div class="param"><span class="param-name">half_life</span><span class="param-type">int</span><span class="param-desc">Half-life in days (1-3650, default 365)</span></div>
+<div class="param"><span class="param-name">dormancy</span><span class="param-type">bool</span><span class="param-desc">Discount by activity: dormant x0.8, abandoned x0.5 (see /active)</span></div>
 </div>
 <div class="example">
 <div class="example-title">Algorithm</div>
@@ -2201,12 +3257,56 @@ decay_score = normalized(sum(weight * pagerank_contribution))</div>
 <span class="path">/decay/top</span>
 <span class="free">FREE</span>
 </div>
-<div class="desc">Top pubkeys by time-decayed score. Shows rank changes vs static PageRank — identifies accounts gaining or losing trust momentum.</div>
+<div class="desc">Top pubkeys by time-decayed score. Shows rank changes vs static PageRank and classifies momentum: rising (decayed/static ratio &ge; 1.1 and follows in the last 30 days at least as fast as the lifetime rate), fading (ratio &le; 0.9 and recent follows slower), or steady. Filter to one community or a follower floor for a "who's gaining traction" feed.</div>
 <div class="params">
 <div class="params-title">Parameters</div>
 <div class="param"><span class="param-name">half_life</span><span class="param-type">int</span><span class="param-desc">Half-life in days (1-3650, default 365)</span></div>
 <div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Results (1-200, default 50)</span></div>
+<div class="param"><span class="param-name">dormancy</span><span class="param-type">bool</span><span class="param-desc">Discount by activity: dormant x0.8, abandoned x0.5 (see /active)</span></div>
+<div class="param"><span class="param-name">community</span><span class="param-type">int</span><span class="param-desc">Only pubkeys in this community id</span></div>
+<div class="param"><span class="param-name">min_followers</span><span class="param-type">int</span><span class="param-desc">Only pubkeys with at least this many followers</span></div>
+<div class="param"><span class="param-name">momentum</span><span class="param-type">string</span><span class="param-desc">rising, steady, or fading</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response</div>
+<div class="code-block">{
+  "entries": [
+    {"pubkey": "abc123...", "decay_score": 58, "static_score": 51, "delta": 7, "decay_rank": 140, "static_rank": 212, "rank_change": 72,
+     "momentum": "rising", "decay_ratio": 1.34, "recent_follows": 96, "follow_velocity": 3.2, "followers": 830, "community_id": 17}
+  ],
+  "half_life_days": 365, "dormancy": false, "community": 17, "momentum": "rising",
+  "graph_size": 51319, "algorithm": "PageRank with exponential time decay"
+}</div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-active">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/active</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Account activity heartbeat. Last seen is the newer of the latest authored event from the metadata crawl (notes, reactions) and the follow list timestamp. Status is active (seen within 30 days), dormant (within 180 days), abandoned (older), or unknown. Posting cadence comes from up to 50 sampled note timestamps. automation scores how automated that cadence looks (clockwork gaps, posting around the clock, bursts seconds apart) with the hour and weekday histograms behind it; /spam weighs its likelihood. The dormancy_discount is what /decay applies with dormancy=true. Also served at /activity.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+</div>
+<div class="example">
+<div class="example-title">Response</div>
+<div class="code-block">{
+  "pubkey": "...", "status": "active",
+  "last_seen": "2026-02-09T21:14:03Z", "last_seen_source": "events", "days_since_last_seen": 1,
+  "last_event": "2026-02-09T21:14:03Z", "follow_list_updated": "2026-01-28T10:02:11Z",
+  "cadence": {"sampled_notes": 20, "notes_per_week": 9.3, "median_gap_hours": 11.5, "sample_span_days": 14.3},
+  "automation": {"likelihood": 0.08, "classification": "human", "indicators": [],
+    "regularity": 0, "round_the_clock": 0.12, "burstiness": 0.05,
+    "intervals": {"gaps": 19, "mean_minutes": 1083.2, "stddev_minutes": 1410.6, "coefficient_of_variation": 1.302, "min_seconds": 4, "burst_gaps": 1},
+    "hour_histogram": [0, 0, 0, ...], "weekday_histogram": [3, 2, 4, 1, 3, 5, 2]},
+  "dormancy_discount": 1, "graph_size": 51319
+}</div>
 </div>
+<button class="try-btn" onclick="tryEndpoint(this,'/active?pubkey=82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2')">Try it</button>
+<div class="try-result"></div>
 </div>
 
 <!-- ===== MODERATION ===== -->
@@ -2219,15 +3319,17 @@ decay_score = normalized(sum(weight * pagerank_contribution))</div>
 <span class="path">/spam</span>
 <span class="price-tag">2 sats</span>
 </div>
-<div class="desc">Multi-signal spam probability analysis. Combines WoT score, follow ratio, account age, engagement, reports, and activity patterns into a 0-100% spam probability with detailed signal breakdown.</div>
+<div class="desc">Multi-signal spam probability analysis. Combines WoT score, follow ratio, account age, engagement, reports, activity patterns and automated posting cadence into a 0-100% spam probability with detailed signal breakdown.</div>
 <div class="params">
 <div class="params-title">Parameters</div>
 <div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">lang</span><span class="param-type">string</span><span class="param-desc"><code>en</code>, <code>de</code>, <code>es</code> or <code>ja</code> for the summary and signal reasons; defaults to Accept-Language, then English</span></div>
 </div>
 <div class="example">
 <div class="example-title">Signal Weights</div>
 <div class="code-block">wot_score: 0.30 | follow_ratio: 0.15 | account_age: 0.15
-engagement: 0.15 | reports: 0.15 | activity_pattern: 0.10
+engagement: 0.10 | reports: 0.15 | activity_pattern: 0.05
+automation_likelihood: 0.10
 
 Thresholds: &gt;= 70%% likely_spam | 40-70%% suspicious | &lt; 40%% likely_human</div>
 </div>
@@ -2255,6 +3357,50 @@ Thresholds: &gt;= 70%% likely_spam | 40-70%% suspicious | &lt; 40%% likely_human
 </div>
 </div>
 
+<div class="endpoint-card" id="ep-spam-event">
+<div class="endpoint-header">
+<span class="method method-post">POST</span>
+<span class="path">/spam/event</span>
+<span class="price-tag">2 sats</span>
+</div>
+<div class="desc">Spam check for a single signed event, for relays running filtering plugins. Combines the author's /spam probability with the content: link density, mention storms, and copies of the same text seen under other event IDs in the last 24 hours. Returns a verdict of accept, flag or reject.</div>
+<div class="params">
+<div class="params-title">Request Body (JSON)</div>
+<div class="param"><span class="param-name">(event)</span><span class="param-type">object</span><span class="param-desc">The full signed Nostr event <span class="param-req">required</span></span></div>
+</div>
+<div class="example">
+<div class="example-title">Signal Weights</div>
+<div class="code-block">author: 0.40 | link_density: 0.20 | mentions: 0.20 | duplicate_content: 0.20
+
+Verdicts: &gt;= 70%% reject | 40-70%% flag | &lt; 40%% accept</div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-reports">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/reports</span>
+<span class="price-tag">2 sats</span>
+</div>
+<div class="desc">Kind 1984 (NIP-56) reports against a pubkey, grouped by report type. Each report is weighted by the reporter's WoT score and loses half its weight every 90 days, so throwaway accounts can't mass-report someone and old reports fade. The weighted total feeds the reports signal in /spam.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+</div>
+<div class="example">
+<div class="example-title">Response (abbreviated)</div>
+<div class="code-block">{
+  "pubkey": "...", "reports": 7, "weighted_reports": 1.12, "trusted_reporters": 2,
+  "top_category": "spam",
+  "categories": [{"type":"spam","reports":5,"weight":1.04}, {"type":"impersonation","reports":2,"weight":0.08}],
+  "reporters": [{"reporter":"...","type":"spam","reporter_score":61,"age_days":12.5,"weight":0.553}],
+  "half_life_days": 90, "assessment": "minor"
+}</div>
+</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/reports?pubkey=32e1827635450ebb3c5a7d12c1f8e7b2b514439ac10a67eef3d9fd9c5c68e245')">Try it</button>
+<div class="try-result"></div>
+</div>
+
 <!-- ===== VERIFICATION ===== -->
 <h2 id="verification">Verification</h2>
 <p class="section-intro">Cross-provider NIP-85 assertion verification.</p>
@@ -2289,13 +3435,90 @@ Thresholds: &gt;= 70%% likely_spam | 40-70%% suspicious | &lt; 40%% likely_human
 </div>
 </div>
 
+<div class="endpoint-card" id="ep-attestation">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/attestation</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Portable score attestation signed by this provider, for use off Nostr (embedding on a website, or proving "WoT &ge; 50" in a login flow). The claim carries iss, sub, score, rank, build, iat, and exp. type=nostr returns a signed kind 21385 event (ephemeral range, never stored by relays) with a NIP-40 expiration tag; type=jws returns an ES256K compact JWS and the signing JWK, whose x coordinate is the provider's Nostr pubkey. With min_score the claim only asserts score_gte and is refused (403) if the subject falls short.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">type</span><span class="param-type">string</span><span class="param-desc">nostr (default) or jws</span></div>
+<div class="param"><span class="param-name">ttl</span><span class="param-type">int</span><span class="param-desc">Validity window in hours (1-168, default 24)</span></div>
+<div class="param"><span class="param-name">min_score</span><span class="param-type">int</span><span class="param-desc">Attest only that score &ge; min_score (0-100)</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response (type=jws&amp;min_score=50)</div>
+<div class="code-block">{
+  "type": "jws",
+  "claim": {"iss": "abc123...", "sub": "def456...", "score_gte": 50, "build": 42, "iat": 1770681600, "exp": 1770768000},
+  "issuer": "abc123...",
+  "expires_at": "2026-02-11T00:00:00Z",
+  "jws": "eyJhbGciOiJFUzI1NksiLCJraWQiOi...",
+  "jwk": {"kty": "EC", "crv": "secp256k1", "alg": "ES256K", "kid": "abc123...", "x": "...", "y": "..."}
+}</div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-challenge">
+<div class="endpoint-header">
+<span class="method method-post">POST</span>
+<span class="path">/challenge</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Score-based CAPTCHA alternative. The caller signs the request with NIP-98 and receives a token asserting "this pubkey's score &ge; threshold as of build X", signed ES256K with the provider key. A site can require the token once at signup or login and verify it at /challenge/verify (or offline against the returned JWK) instead of querying scores on every visitor action. Refused (403) if the caller's score is below the threshold.</div>
+<div class="params">
+<div class="params-title">JSON body</div>
+<div class="param"><span class="param-name">threshold</span><span class="param-type">int</span><span class="param-desc">Minimum score to assert (0-100) <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">audience</span><span class="param-type">string</span><span class="param-desc">Site the token is for (e.g. example.org)</span></div>
+<div class="param"><span class="param-name">nonce</span><span class="param-type">string</span><span class="param-desc">Site-supplied nonce, to bind the token to one session</span></div>
+<div class="param"><span class="param-name">ttl_minutes</span><span class="param-type">int</span><span class="param-desc">Validity window (1-60, default 10)</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response</div>
+<div class="code-block">{
+  "token": "eyJhbGciOiJFUzI1NksiLCJraWQiOi...",
+  "claim": {"purpose": "wot-challenge", "iss": "abc123...", "sub": "def456...", "score_gte": 30, "build": 42, "aud": "example.org", "nonce": "n1", "jti": "9f2c...", "iat": 1770681600, "exp": 1770682200},
+  "jwk": {"kty": "EC", "crv": "secp256k1", "alg": "ES256K", "kid": "abc123...", "x": "...", "y": "..."},
+  "expires_at": "2026-02-10T00:10:00Z"
+}</div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-challenge-verify">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/challenge/verify</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Verify a /challenge token: signature by this provider, expiry, and optionally audience, nonce, and a minimum asserted threshold. Invalid tokens return valid=false with a reason. stale is true when the token was issued against an older graph build than the current one.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">token</span><span class="param-type">string</span><span class="param-desc">Token from POST /challenge <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">audience</span><span class="param-type">string</span><span class="param-desc">Require this audience</span></div>
+<div class="param"><span class="param-name">nonce</span><span class="param-type">string</span><span class="param-desc">Require this nonce</span></div>
+<div class="param"><span class="param-name">min_score</span><span class="param-type">int</span><span class="param-desc">Require score_gte &ge; min_score (0-100)</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response</div>
+<div class="code-block">{
+  "valid": true,
+  "claim": {"purpose": "wot-challenge", "sub": "def456...", "score_gte": 30, "build": 42, "aud": "example.org", "exp": 1770682200},
+  "current_build": 43,
+  "stale": true
+}</div>
+</div>
+</div>
+
 <div class="endpoint-card" id="ep-anomalies">
 <div class="endpoint-header">
 <span class="method method-get">GET</span>
 <span class="path">/anomalies</span>
 <span class="price-tag">3 sats</span>
 </div>
-<div class="desc">Trust anomaly detection: analyzes a pubkey's trust graph for suspicious patterns including follow-farming (high follow-back ratio), ghost/bot followers (zero-score followers), trust concentration (single-source dependency), score-follower divergence, and excessive following. Returns individual anomaly flags with severity levels and an overall risk assessment.</div>
+<div class="desc">Trust anomaly detection: analyzes a pubkey's trust graph for suspicious patterns including follow-farming (high follow-back ratio), ghost/bot followers (zero-score followers), trust concentration (single-source dependency), score-follower divergence, excessive following, mass following and follow bursts whose follows are dampened in scoring (details in mass_follow), and zap wash trading (patterns are listed in zap_wash). Returns individual anomaly flags with severity levels and an overall risk assessment.</div>
 <div class="params">
 <div class="params-title">Parameters</div>
 <div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub to analyze <span class="param-req">required</span></span></div>
@@ -2329,6 +3552,44 @@ Thresholds: &gt;= 70%% likely_spam | 40-70%% suspicious | &lt; 40%% likely_human
 </div>
 </div>
 
+<div class="endpoint-card" id="ep-zap-score">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/zap-score</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Zap-weighted trust score. Sats received are weighted by each sender's own WoT score (score/100), so zaps from unknown keys add nothing, and the boost grows with the log of weighted sats, capped at 10 points. Senders involved in wash trading are excluded entirely: self-zaps, circular loops where at least half the sats come back within 2-3 hops, and alt keys (near-zero score, linked by a follow, sending 90%+ of their sats to this pubkey). Loops settled through the same LNURL service are marked same_ln_service. Detected patterns also appear in /anomalies.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+</div>
+<div class="example">
+<div class="example-title">Response</div>
+<div class="code-block">{
+  "pubkey": "...", "base_score": 38, "zap_boost": 7, "zap_weighted_score": 45,
+  "received_sats": 312000, "counted_sats": 112000, "weighted_sats": 4180.5, "excluded_sats": 200000,
+  "senders": 41, "excluded_senders": 1,
+  "wash_patterns": [{"type":"circular","counterparties":["..."],"sats":200000,"same_ln_service":true,"detail":"200000 sats in, 195000 sats back out to the same pubkey"}],
+  "graph_size": 51319
+}</div>
+</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/zap-score?pubkey=82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2')">Try it</button>
+<div class="try-result"></div>
+</div>
+
+<div class="endpoint-card" id="ep-report-gaming">
+<div class="endpoint-header">
+<span class="method method-post">POST</span>
+<span class="path">/report-gaming</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Report score gaming — follower buying or follow rings — with structured evidence. Requires a NIP-98 Authorization header. Reports are queued and weighted by the reporter's WoT score (score/100); once the combined weight of open reports against a pubkey reaches 1.0, a targeted anomaly sweep runs automatically (covering mutual follows for suspected rings). Operators review the queue with GET /admin/gaming-reports and POST /admin/gaming-reports/review (admin token).</div>
+<div class="example">
+<div class="example-title">POST Body (NIP-98 signed)</div>
+<div class="code-block">{"category":"follow_ring","pubkeys":["&lt;hex&gt;","&lt;hex&gt;"],"event_ids":["&lt;hex&gt;"],"description":"accounts created the same day, all following each other"}</div>
+</div>
+</div>
+
 <!-- ===== SYBIL RESISTANCE ===== -->
 <h2 id="sybil">Sybil Resistance</h2>
 <p class="section-intro">Sybil detection and resistance scoring for relay operators. Combines five graph analysis signals into a single actionable score.</p>
@@ -2393,6 +3654,41 @@ Thresholds: &gt;= 70%% likely_spam | 40-70%% suspicious | &lt; 40%% likely_human
 </div>
 </div>
 
+<div class="endpoint-card" id="ep-org-score">
+<div class="endpoint-header">
+<span class="method method-post">POST</span>
+<span class="path">/org-score</span>
+<span class="price-tag">10 sats</span>
+</div>
+<div class="desc">Aggregate trust profile for a brand or project operating several accounts. Returns each member's score and risk level, the min/median/max member score, the combined external audience and its overlap, and anomalies only visible across the set: members amplifying each other, ghost followers shared between members, several risky members, and weak accounts riding on a trusted flagship.</div>
+<div class="params">
+<div class="params-title">Request Body</div>
+<div class="param"><span class="param-name">pubkeys</span><span class="param-type">string[]</span><span class="param-desc">The organization's hex pubkeys or npubs (2-50) <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">name</span><span class="param-type">string</span><span class="param-desc">Label echoed in the response</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response</div>
+<div class="code-block">{
+  "name": "Example Labs",
+  "members": [
+    {"pubkey": "abc123...", "score": 71, "rank": 210, "followers": 4200, "risk_level": "clean"},
+    {"pubkey": "def456...", "score": 12, "rank": 18400, "followers": 310, "risk_level": "low"}
+  ],
+  "member_count": 2,
+  "min_score": 12,
+  "median_score": 41.5,
+  "max_score": 71,
+  "combined_audience": 4380,
+  "audience_overlap": 0.028,
+  "internal_follow_share": 0.0004,
+  "shared_ghost_followers": 3,
+  "anomalies": [],
+  "risk_level": "clean",
+  "graph_size": 51319
+}</div>
+</div>
+</div>
+
 <!-- ===== TRUST PATHS ===== -->
 <h2 id="trust-paths">Trust Paths</h2>
 <p class="section-intro">Multi-hop trust path analysis. Find and score the trust connections between any two pubkeys through the follow graph.</p>
@@ -2409,6 +3705,8 @@ Thresholds: &gt;= 70%% likely_spam | 40-70%% suspicious | &lt; 40%% likely_human
 <div class="param"><span class="param-name">from</span><span class="param-type">string</span><span class="param-desc">Source hex pubkey or npub <span class="param-req">required</span></span></div>
 <div class="param"><span class="param-name">to</span><span class="param-type">string</span><span class="param-desc">Target hex pubkey or npub <span class="param-req">required</span></span></div>
 <div class="param"><span class="param-name">max_paths</span><span class="param-type">int</span><span class="param-desc">Maximum paths to find (1-5, default 3)</span></div>
+<div class="param"><span class="param-name">weighted</span><span class="param-type">bool</span><span class="param-desc">Find the cheapest paths by trust-weighted edge cost instead of the shortest (default false)</span></div>
+<div class="param"><span class="param-name">disjoint</span><span class="param-type">bool</span><span class="param-desc">Paths share no intermediate account (default true); false requires weighted=true and returns Yen's k cheapest paths</span></div>
 </div>
 <div class="example">
 <div class="example-title">Response</div>
@@ -2451,6 +3749,7 @@ Thresholds: &gt;= 70%% likely_spam | 40-70%% suspicious | &lt; 40%% likely_human
 <div class="params">
 <div class="params-title">Parameters</div>
 <div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">lang</span><span class="param-type">string</span><span class="param-desc"><code>en</code>, <code>de</code>, <code>es</code> or <code>ja</code> for the summary; defaults to Accept-Language, then English</span></div>
 </div>
 <div class="example">
 <div class="example-title">Response</div>
@@ -2565,6 +3864,42 @@ Thresholds: &gt;= 70%% likely_spam | 40-70%% suspicious | &lt; 40%% likely_human
 </div>
 </div>
 
+<div class="endpoint-card" id="ep-simulate">
+<div class="endpoint-header">
+<span class="method method-post">POST</span>
+<span class="path">/simulate</span>
+<span class="price-tag">5 sats</span>
+</div>
+<div class="desc">What-if onboarding: projects a pubkey's score, rank and percentile if the given accounts followed it, plus any other hypothetical follows. Uses an incremental PageRank approximation from the current scores, pushing the change only as far as it reaches, and breaks down how much each new follower contributes directly. Existing follows and self-follows are skipped. Up to 500 follows per request.</div>
+<div class="params">
+<div class="params-title">Request Body (JSON)</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">The pubkey to project, hex or npub <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">followers</span><span class="param-type">string[]</span><span class="param-desc">Accounts that would follow pubkey</span></div>
+<div class="param"><span class="param-name">edges</span><span class="param-type">object[]</span><span class="param-desc">Other hypothetical follows as {"from", "to"}</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response</div>
+<div class="code-block">{
+  "pubkey": "32e1827635...",
+  "edges_added": 20,
+  "edges_skipped": 1,
+  "current": {"score": 12, "raw_score": 0.0000031, "rank": 18204, "percentile": 0.645},
+  "projected": {"score": 41, "raw_score": 0.0000194, "rank": 2410, "percentile": 0.953},
+  "score_delta": 29,
+  "rank_delta": 15794,
+  "percentile_delta": 0.308,
+  "contributions": [
+    {"pubkey": "82341f882b...", "score": 88, "follows": 412, "raw_contribution": 0.0000041, "share": 0.254}
+  ],
+  "affected_count": 3120,
+  "method": "incremental_push",
+  "pushes": 4877,
+  "converged": true,
+  "graph_size": 51319
+}</div>
+</div>
+</div>
+
 <div class="endpoint-card" id="ep-influence-batch">
 <div class="endpoint-header">
 <span class="method method-post">POST</span>
@@ -2610,6 +3945,34 @@ Thresholds: &gt;= 70%% likely_spam | 40-70%% suspicious | &lt; 40%% likely_human
 </div>
 </div>
 
+<div class="endpoint-card" id="ep-reach2">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/reach2</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Estimated unique 2-hop audience: followers plus followers-of-followers, excluding the pubkey itself. Up to 5000 second-hop edges are counted exactly; beyond that the audience is estimated by unioning HyperLogLog sketches (about 3.25% standard error) without materializing the 2-hop set. Sketches of large first-hop audiences are cached per graph build.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+</div>
+<div class="example">
+<div class="example-title">Response</div>
+<div class="code-block">{
+  "pubkey": "32e1827635...",
+  "followers": 1843,
+  "reach2_estimate": 28410,
+  "second_hop_only": 26567,
+  "method": "hyperloglog",
+  "relative_error": 0.0325,
+  "edges_scanned": 9120,
+  "graph_size": 51319
+}</div>
+</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/reach2?pubkey=82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2')">Try it</button>
+<div class="try-result"></div>
+</div>
+
 <!-- ===== TRUST CIRCLES ===== -->
 <h2 id="trust-circles">Trust Circles</h2>
 <p class="section-intro">Mutual-follow trust circle analysis. Discover a pubkey's closest trusted connections and how tightly connected they are.</p>
@@ -2814,6 +4177,40 @@ Thresholds: &gt;= 70%% likely_spam | 40-70%% suspicious | &lt; 40%% likely_human
 </div>
 </div>
 
+<div class="endpoint-card" id="ep-bias">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/bias</span>
+<span class="price-tag">5 sats</span>
+</div>
+<div class="desc">Seed-bias report: how scores depend on proximity to the crawl seeds. Pubkeys are grouped by hops from the nearest seed (-1 for none) with their share of nodes and of total PageRank and their mean, median and p90 score. Each seed's region (the pubkeys nearer to it than to any other seed) gets the same summary plus how much of the last crawl it used against seed_crawl_budget, and region_gini measures how unevenly score is spread across seeds. With a pubkey, node places it: hops, nearest seed, the seeds following it directly, and how its score compares to others at the same distance.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub to place relative to the seeds</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response</div>
+<div class="code-block">{
+  "seeds": 4,
+  "seed_crawl_budget": 20000,
+  "graph_size": 51319,
+  "reachable_share": 0.962,
+  "mean_hops": 1.94,
+  "distance": [
+    {"hops": 0, "nodes": 4, "node_share": 0, "score_share": 0.012, "mean_score": 91.5, "median_score": 92, "p90_score": 95},
+    {"hops": 1, "nodes": 2310, "node_share": 0.045, "score_share": 0.281, "mean_score": 38.2, "median_score": 36, "p90_score": 61},
+    {"hops": 2, "nodes": 47054, "node_share": 0.917, "score_share": 0.694, "mean_score": 11.4, "median_score": 9, "p90_score": 24},
+    {"hops": -1, "nodes": 1951, "node_share": 0.038, "score_share": 0.013, "mean_score": 2.1, "median_score": 0, "p90_score": 6}
+  ],
+  "regions": [
+    {"seed": "82341f88...", "score": 95, "nodes": 21840, "node_share": 0.426, "score_share": 0.402, "mean_score": 13.1, "crawl": {"pubkey": "82341f88...", "reached": 20000, "denied": 3812, "exhausted": true}}
+  ],
+  "region_gini": 0.31,
+  "node": {"pubkey": "abc123...", "score": 24, "hops": 2, "nearest_seed": "82341f88...", "seeds_following": [], "distance_median_score": 9, "distance_percentile": 0.88}
+}</div>
+</div>
+</div>
+
 <!-- ===== CROSS-PROVIDER ===== -->
 <h2 id="cross-provider">Cross-Provider Comparison</h2>
 <p class="section-intro">Compare WoT scores from multiple independent NIP-85 providers to assess consensus.</p>
@@ -2850,167 +4247,561 @@ Thresholds: &gt;= 70%% likely_spam | 40-70%% suspicious | &lt; 40%% likely_human
 </div>
 </div>
 
-<!-- ===== REAL-TIME ===== -->
-<h2 id="realtime">Real-Time Streaming</h2>
-<p class="section-intro">WebSocket endpoint for live score updates pushed after each graph recomputation.</p>
-
-<div class="endpoint-card" id="ep-ws-scores">
+<!-- ===== REAL-TIME ===== -->
+<h2 id="realtime">Real-Time Streaming</h2>
+<p class="section-intro">WebSocket endpoint for live score updates pushed after each graph recomputation.</p>
+
+<div class="endpoint-card" id="ep-ws-scores">
+<div class="endpoint-header">
+<span class="method" style="background:#7c3aed">WS</span>
+<span class="path">/ws/scores</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Subscribe to real-time score updates for specific pubkeys. After connecting, send a subscribe message with pubkeys to watch. You'll receive current scores immediately, then updated scores after each graph recomputation (~6 hours). Each connection can watch up to 100 pubkeys; send an unsubscribe message without pubkeys to drop them all. The server pings every 30 seconds and closes connections that stop answering.</div>
+<div class="example">
+<div class="example-title">Subscribe Message</div>
+<div class="code-block">{"type":"subscribe","pubkeys":["<hex_or_npub>"]}</div>
+</div>
+<div class="example">
+<div class="example-title">Score Response</div>
+<div class="code-block">{
+  "type": "scores",
+  "scores": [
+    {
+      "pubkey": "82341f...",
+      "score": 87,
+      "raw_score": 0.00234,
+      "percentile": 0.9812,
+      "rank": 42
+    }
+  ],
+  "stats": {
+    "nodes": 51319,
+    "edges": 3847201,
+    "updated_at": "2026-02-09T12:00:00Z"
+  }
+}</div>
+</div>
+</div>
+
+<!-- ===== ENGAGEMENT ===== -->
+<h2 id="engagement">Engagement</h2>
+<p class="section-intro">Event-level and metadata scoring for NIP-85 assertions.</p>
+
+<div class="endpoint-card" id="ep-metadata">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/metadata</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Full NIP-85 engagement metadata: posts, replies, reactions, zaps sent/received, and first event timestamp.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-metadata-batch">
+<div class="endpoint-header">
+<span class="method method-post">POST</span>
+<span class="path">/metadata/batch</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Metadata for up to 100 pubkeys in one call. Each result has the same fields as /metadata; unresolvable pubkeys return an error entry in place.</div>
+<div class="params">
+<div class="params-title">Request Body (JSON)</div>
+<div class="param"><span class="param-name">pubkeys</span><span class="param-type">string[]</span><span class="param-desc">Array of hex pubkeys or npubs (max 100) <span class="param-req">required</span></span></div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-event">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/event</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Engagement score for a specific Nostr event (kind 30383): comments, reposts, reactions, zaps.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">id</span><span class="param-type">string</span><span class="param-desc">Event ID (hex) <span class="param-req">required</span></span></div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-external">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/external</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">External identifier scores (NIP-73, kind 30385). Mentions and reactions are weighted by their authors' WoT scores, so unscored accounts can't push a rank up. Without an id parameter, returns top 50 identifiers. With id, returns the specific identifier's engagement data.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">id</span><span class="param-type">string</span><span class="param-desc">External identifier (optional — omit for top 50)</span></div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-external-trending">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/external/trending</span>
+<span class="free">FREE</span>
+<button class="try-btn" onclick="tryEndpoint(this,'/external/trending?window=24h')">Try it</button><div class="try-result"></div>
+</div>
+<div class="desc">Hashtags and URLs trending in the last 24 hours or 7 days, ranked by trust-weighted mentions and reactions. Each entry includes its raw_position in a plain count ranking, so swarm-driven identifiers stand out.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">window</span><span class="param-type">string</span><span class="param-desc">24h (default) or 7d</span></div>
+<div class="param"><span class="param-name">kind</span><span class="param-type">string</span><span class="param-desc">hashtag or url (optional — omit for both)</span></div>
+<div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Max results, 1–100 (default 50)</span></div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-articles-top">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/articles/top</span>
+<span class="free">FREE</span>
+<button class="try-btn" onclick="tryEndpoint(this,'/articles/top?limit=10')">Try it</button><div class="try-result"></div>
+</div>
+<div class="desc">Long-form articles (kind 30023) ranked by a blend of author WoT score (60%) and engagement (40%), with title, summary and hashtags. Articles by unscored authors are left out.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">topic</span><span class="param-type">string</span><span class="param-desc">Hashtag to filter by (optional)</span></div>
+<div class="param"><span class="param-name">min_author_score</span><span class="param-type">int</span><span class="param-desc">Minimum author score, 0–100 (default 0)</span></div>
+<div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Max results, 1–100 (default 20)</span></div>
+</div>
+</div>
+
+<!-- ===== RANKING ===== -->
+<h2 id="ranking">Ranking</h2>
+
+<div class="endpoint-card" id="ep-top">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/top</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Top 50 most-trusted pubkeys by PageRank with normalized scores and follower counts.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">view</span><span class="param-type">string</span><span class="param-desc">A seed view from /stats to rank within that seed group's WoT (default: the main seeds)</span></div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-export">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/export</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Export all pubkeys and scores, highest first. Full graph dump for offline analysis. Add <code>limit</code> to page through with <code>cursor</code> (from <code>X-Next-Cursor</code>), <code>format=ndjson</code>, <code>csv</code> or <code>parquet</code> for the full table with rank, follower counts and community. Gzipped with <code>Accept-Encoding: gzip</code>.</div>
+</div>
+
+<div class="endpoint-card" id="ep-snapshots">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/snapshots</span>
+<span class="free">FREE</span>
+<button class="try-btn" onclick="tryEndpoint(this,'/snapshots')">Try it</button><div class="try-result"></div>
+</div>
+<div class="desc">The score snapshots retained from past rebuilds, newest first: id, build time, node and edge counts, and a SHA-256 of the top 10 pubkeys, plus the retention policy (<code>snapshot_retention</code> snapshots, optionally no older than <code>snapshot_retention_days</code>).</div>
+</div>
+
+<div class="endpoint-card" id="ep-snapshots-export">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/snapshots/{id}/export</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">The full score table as of a retained snapshot, in the <code>/export</code> formats. 404 once the snapshot has been dropped by retention.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">id</span><span class="param-type">string</span><span class="param-desc">Snapshot id from /snapshots <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">format</span><span class="param-type">string</span><span class="param-desc">json (default), ndjson, csv or parquet</span></div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-export-bloom">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/export/bloom</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">A Bloom filter of everyone within <code>depth</code> follow hops of a pubkey, so a client can answer "is this pubkey in my extended WoT?" offline. The body is the raw bit array; <code>X-Bloom-Bits</code>, <code>X-Bloom-Hashes</code> and <code>X-Bloom-Count</code> give its size, hash count and member count. A member's bits are <code>(h1 + i*h2) mod bits</code> for <code>i</code> below the hash count, with <code>h1</code> and <code>h2</code> the first two big-endian uint64s of SHA-256 of the 32-byte pubkey (<code>h2</code> with its low bit set); bit <code>j</code> is bit <code>j%8</code> of byte <code>j/8</code>. A 2-hop set of 50,000 at 1% fits in about 60KB.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">depth</span><span class="param-type">int</span><span class="param-desc">Follow hops (1-3, default 2)</span></div>
+<div class="param"><span class="param-name">fpr</span><span class="param-type">float</span><span class="param-desc">False positive rate (0.0001-0.5, default 0.01)</span></div>
+<div class="param"><span class="param-name">min_score</span><span class="param-type">int</span><span class="param-desc">Only include pubkeys scoring at least this (0-100, default 0)</span></div>
+</div>
+</div>
+
+<!-- ===== INFRASTRUCTURE ===== -->
+<h2 id="infrastructure">Infrastructure</h2>
+
+<div class="endpoint-card" id="ep-relay">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/relay</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Relay trust score combining infrastructure metrics from trustedrelays.xyz (70%) with operator WoT score (30%). Includes uptime, quality, and accessibility data.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">url</span><span class="param-type">string</span><span class="param-desc">Relay URL (e.g. wss://relay.damus.io) <span class="param-req">required</span></span></div>
+</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/relay?url=wss://relay.damus.io')">Try it</button>
+<div class="try-result"></div>
+</div>
+
+<div class="endpoint-card" id="ep-relay-suggest">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/relay/suggest</span>
+<span class="price-tag">5 sats</span>
+</div>
+<div class="desc">Relays to use, ranked by where the user's highest-trust follows publish and read (NIP-65 relay lists), weighted by their WoT scores. Each suggestion shows write and read coverage, the cumulative write coverage of the suggestions so far, and whether it is already in the user's relay list.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Max suggestions (default 10, max 30)</span></div>
+</div>
+<div class="example">
+<div class="example-title">Example</div>
+<div class="code-block">curl "https://wot.klabo.world/relay/suggest?pubkey=82341f...&amp;limit=5"</div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-relay-top">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/relay/top</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Relays ranked for relay selection. Each rebuild reads the NIP-65 relay lists of the top 5,000 pubkeys and fetches NIP-11 documents for the 300 most-used relays to link their operator pubkeys to the graph. rank = trust-weighted adoption (70%) + operator WoT score (30%). The top 100 relays with 3+ users are published as kind 30385 relay trust assertions (d = relay URL).</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Max relays (default 50, max 500)</span></div>
+<div class="param"><span class="param-name">min_users</span><span class="param-type">int</span><span class="param-desc">Only relays listed by at least this many graph members (default 1)</span></div>
+</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/relay/top?limit=10')">Try it</button>
+<div class="try-result"></div>
+</div>
+
+<div class="endpoint-card" id="ep-authorized">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/authorized</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Kind 10040 authorized users who declared trust in this NIP-85 provider.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Filter by specific provider (optional)</span></div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-communities">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/communities</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Trust communities detected via label propagation over the follow graph. With a pubkey, returns that user's community and peers. Add as_of to get the pubkey's community at a past build and its membership change log; community ids stay stable across builds.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Get community for specific pubkey (optional)</span></div>
+<div class="param"><span class="param-name">as_of</span><span class="param-type">string</span><span class="param-desc">Build ID, RFC3339 time, or YYYY-MM-DD (requires pubkey)</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response (as_of)</div>
+<div class="code-block">{
+  "pubkey": "...", "as_of_build": 41, "as_of": "2026-02-08T12:00:00Z",
+  "community_id": 12, "current_community": 407, "migrations": 1,
+  "changes": [
+    {"build": 3, "at": "2026-01-15T06:00:00Z", "from": -1, "to": 12},
+    {"build": 44, "at": "2026-02-09T06:00:00Z", "from": 12, "to": 407}
+  ]
+}</div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-bridges">
 <div class="endpoint-header">
-<span class="method" style="background:#7c3aed">WS</span>
-<span class="path">/ws/scores</span>
+<span class="method method-get">GET</span>
+<span class="path">/bridges</span>
 <span class="free">FREE</span>
 </div>
-<div class="desc">Subscribe to real-time score updates for specific pubkeys. After connecting, send a subscribe message with pubkeys to watch. You'll receive current scores immediately, then updated scores after each graph recomputation (~6 hours).</div>
-<div class="example">
-<div class="example-title">Subscribe Message</div>
-<div class="code-block">{"type":"subscribe","pubkeys":["<hex_or_npub>"]}</div>
+<div class="desc">Cross-community curators: accounts ranked by betweenness on shortest paths between two communities (a and b) or between any two communities. Sources are sampled (seeded by build ID) when communities are large; each bridge lists the communities its follows and followers belong to.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">a</span><span class="param-type">int</span><span class="param-desc">First community id (with b; omit both for global)</span></div>
+<div class="param"><span class="param-name">b</span><span class="param-type">int</span><span class="param-desc">Second community id</span></div>
+<div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Results (1-100, default 20)</span></div>
+<div class="param"><span class="param-name">samples</span><span class="param-type">int</span><span class="param-desc">Sampled sources (1-256, default 64)</span></div>
+<div class="param"><span class="param-name">scope</span><span class="param-type">string</span><span class="param-desc">communities (default) or graph: brokers by graph-wide betweenness, with broker_score</span></div>
 </div>
 <div class="example">
-<div class="example-title">Score Response</div>
+<div class="example-title">Response</div>
 <div class="code-block">{
-  "type": "scores",
-  "scores": [
-    {
-      "pubkey": "82341f...",
-      "score": 87,
-      "raw_score": 0.00234,
-      "percentile": 0.9812,
-      "rank": 42
-    }
+  "mode": "pair", "community_a": 12, "community_b": 407,
+  "bridges": [
+    {"pubkey": "...", "betweenness": 18420.5, "score": 71, "community": 12,
+     "connects": [{"community": 12, "neighbors": 240}, {"community": 407, "neighbors": 88}]}
   ],
-  "stats": {
-    "nodes": 51319,
-    "edges": 3847201,
-    "updated_at": "2026-02-09T12:00:00Z"
-  }
+  "sampled_sources": 64, "candidate_sources": 5210, "exact": false, "graph_size": 51319
 }</div>
 </div>
 </div>
 
-<!-- ===== ENGAGEMENT ===== -->
-<h2 id="engagement">Engagement</h2>
-<p class="section-intro">Event-level and metadata scoring for NIP-85 assertions.</p>
-
-<div class="endpoint-card" id="ep-metadata">
+<div class="endpoint-card" id="ep-communities-map">
 <div class="endpoint-header">
 <span class="method method-get">GET</span>
-<span class="path">/metadata</span>
+<span class="path">/communities/map</span>
 <span class="free">FREE</span>
 </div>
-<div class="desc">Full NIP-85 engagement metadata: posts, replies, reactions, zaps sent/received, and first event timestamp.</div>
+<div class="desc">Macro-level trust map: the largest communities as nodes (size, average and top score, cohesion) and directed edges weighted by how much of one community's follows go to another, plus the accounts with the highest cross-community betweenness. Each edge names the bridges that neighbor both of its communities.</div>
 <div class="params">
 <div class="params-title">Parameters</div>
-<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Communities on the map, largest first (1-100, default 20)</span></div>
+<div class="param"><span class="param-name">min_weight</span><span class="param-type">float</span><span class="param-desc">Drop edges carrying less than this share of the source community's follows (0-1, default 0)</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response</div>
+<div class="code-block">{
+  "communities": [
+    {"id": 12, "size": 5210, "avg_score": 18.4, "top_score": 97, "internal_edges": 412330,
+     "outgoing_edges": 98120, "cohesion": 0.808, "top_members": ["...", "...", "..."]}
+  ],
+  "edges": [
+    {"from": 12, "to": 407, "follows": 31204, "weight": 0.061, "bridges": ["..."]}
+  ],
+  "bridges": [{"pubkey": "...", "betweenness": 18420.5, "score": 71, "community": 12, "connects": [...]}],
+  "bridges_exact": false, "total_communities": 38, "graph_size": 51319
+}</div>
 </div>
 </div>
 
-<div class="endpoint-card" id="ep-event">
+<div class="endpoint-card" id="ep-annotations">
 <div class="endpoint-header">
 <span class="method method-get">GET</span>
-<span class="path">/event</span>
+<span class="path">/annotations</span>
 <span class="free">FREE</span>
 </div>
-<div class="desc">Engagement score for a specific Nostr event (kind 30383): comments, reposts, reactions, zaps.</div>
+<div class="desc">Namespaced third-party annotations on a pubkey (e.g. bitcoin-core:contributor), aggregated per label. Each provider is weighted by its operator-configured namespace weight or its WoT score; confidence is the capped sum. Providers add or remove annotations with POST /annotations, signed with a NIP-98 Authorization header.</div>
 <div class="params">
 <div class="params-title">Parameters</div>
-<div class="param"><span class="param-name">id</span><span class="param-type">string</span><span class="param-desc">Event ID (hex) <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+</div>
+<div class="example">
+<div class="example-title">POST Body (NIP-98 signed)</div>
+<div class="code-block">{"subject":"&lt;hex&gt;","annotations":[{"namespace":"bitcoin-core","label":"contributor"}],"remove":[]}</div>
 </div>
 </div>
 
-<div class="endpoint-card" id="ep-external">
+<div class="endpoint-card" id="ep-publish">
 <div class="endpoint-header">
-<span class="method method-get">GET</span>
-<span class="path">/external</span>
+<span class="method method-post">POST</span>
+<span class="path">/publish</span>
 <span class="free">FREE</span>
 </div>
-<div class="desc">External identifier scores (NIP-73, kind 30385). Without an id parameter, returns top 50 identifiers. With id, returns the specific identifier's engagement data.</div>
-<div class="params">
-<div class="params-title">Parameters</div>
-<div class="param"><span class="param-name">id</span><span class="param-type">string</span><span class="param-desc">External identifier (optional — omit for top 50)</span></div>
-</div>
+<div class="desc">Publish all NIP-85 assertion events (kinds 30382, 30383, 30384, 30385) and NIP-89 handler info to configured relays. Kind 30382 covers the top publish_top_n pubkeys (10,000 by default). Each publish_lists threshold also gets a NIP-51 kind 30000 people list (d tag wot-above-N) of up to 1,000 pubkeys at or above it. The top 100 relays from /relay/top are published as kind 30385 relay trust assertions (counted as relay_30385). Events are signed and queued in chunks and delivered in the background; the response counts what was queued. Requires <code>Authorization: Bearer $ADMIN_TOKEN</code>.</div>
 </div>
 
-<!-- ===== RANKING ===== -->
-<h2 id="ranking">Ranking</h2>
+<div class="endpoint-card" id="ep-publish-status">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/publish/status</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Progress of the publishing queue: events not yet accepted by every relay, and for each relay its queued events, attempts, success rate, current pace and any backoff. Each relay is paced on its own, speeding up while it accepts events and slowing down when it rejects them; rejected events are retried with exponential backoff up to 5 times.</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/publish/status')">Try it</button>
+<div class="try-result"></div>
+</div>
 
-<div class="endpoint-card" id="ep-top">
+<div class="endpoint-card" id="ep-providers">
 <div class="endpoint-header">
 <span class="method method-get">GET</span>
-<span class="path">/top</span>
+<span class="path">/providers</span>
 <span class="free">FREE</span>
 </div>
-<div class="desc">Top 50 most-trusted pubkeys by PageRank with normalized scores and follower counts.</div>
+<div class="desc">External NIP-85 assertion providers and their assertion counts.</div>
 </div>
 
-<div class="endpoint-card" id="ep-export">
+<div class="endpoint-card" id="ep-providers-accuracy">
 <div class="endpoint-header">
 <span class="method method-get">GET</span>
-<span class="path">/export</span>
+<span class="path">/providers/accuracy</span>
 <span class="free">FREE</span>
 </div>
-<div class="desc">Export all pubkeys and scores as a JSON array. Full graph dump for offline analysis.</div>
+<div class="desc">How often each external provider's kind 30382 claims agree with our graph, using the same rank and follower checks as /verify. Providers with at least 10 checked claims are weighted by their accuracy in composite scores; the rest get a weight of 0.5. Refreshed on every rebuild.</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/providers/accuracy')">Try it</button>
+<div class="try-result"></div>
 </div>
 
-<!-- ===== INFRASTRUCTURE ===== -->
-<h2 id="infrastructure">Infrastructure</h2>
+<div class="endpoint-card" id="ep-assertion-schema">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/assertion-schema</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Schema for every tag this service publishes in NIP-85 kinds 30382, 30383, 30384, and 30385: name, value type, units, valid range, whether it is required or repeatable, and what it means. Every assertion is validated against this schema before it is signed, so published events always match it.</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/assertion-schema')">Try it</button>
+<div class="try-result"></div>
+</div>
 
-<div class="endpoint-card" id="ep-relay">
+<div class="endpoint-card" id="ep-assertions">
 <div class="endpoint-header">
 <span class="method method-get">GET</span>
-<span class="path">/relay</span>
+<span class="path">/assertions</span>
 <span class="free">FREE</span>
 </div>
-<div class="desc">Relay trust score combining infrastructure metrics from trustedrelays.xyz (70%) with operator WoT score (30%). Includes uptime, quality, and accessibility data.</div>
+<div class="desc">Raw signed kind 30382 events consumed from external providers, as used for composite scoring. Events are content-addressed by id and can be re-verified offline. Superseded versions are kept for the retention window (default 30 days).</div>
 <div class="params">
 <div class="params-title">Parameters</div>
-<div class="param"><span class="param-name">url</span><span class="param-type">string</span><span class="param-desc">Relay URL (e.g. wss://relay.damus.io) <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">subject</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">provider</span><span class="param-type">string</span><span class="param-desc">Limit to one provider (optional)</span></div>
+<div class="param"><span class="param-name">history</span><span class="param-type">bool</span><span class="param-desc">Include superseded versions (default false)</span></div>
 </div>
-<button class="try-btn" onclick="tryEndpoint(this,'/relay?url=wss://relay.damus.io')">Try it</button>
+</div>
+
+<div class="endpoint-card" id="ep-rebuild-status">
+<div class="endpoint-header">
+<span class="method method-get">GET</span>
+<span class="path">/rebuild/status</span>
+<span class="free">FREE</span>
+</div>
+<div class="desc">Progress of the crawl/scoring rebuild: phase, percent complete, ETA, and per-phase timings. Operators can start a rebuild with POST /rebuild and cancel it with POST /rebuild/cancel (both require the admin token).</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/rebuild/status')">Try it</button>
 <div class="try-result"></div>
 </div>
 
-<div class="endpoint-card" id="ep-authorized">
+<div class="endpoint-card" id="ep-crawl-status">
 <div class="endpoint-header">
 <span class="method method-get">GET</span>
-<span class="path">/authorized</span>
+<span class="path">/crawl/status</span>
 <span class="free">FREE</span>
 </div>
-<div class="desc">Kind 10040 authorized users who declared trust in this NIP-85 provider.</div>
+<div class="desc">Progress of the follow crawl and how hard it presses each relay. Contact lists are fetched by a pool of workers, with a cap on requests in flight and started per second for each relay; failed requests are retried with exponential backoff. Shows the running crawl (depth, queued pubkeys, requests done, failed and retried, events), the last finished crawl, and per-relay requests, errors, retries, failures, events, in-flight requests and average latency since startup. With ?id= returns that on-demand crawl job (see POST /crawl), or 404 once it has expired.</div>
+<button class="try-btn" onclick="tryEndpoint(this,'/crawl/status')">Try it</button>
+<div class="try-result"></div>
+</div>
+
+<div class="endpoint-card" id="ep-crawl">
+<div class="endpoint-header">
+<span class="method method-post">POST</span>
+<span class="path">/crawl</span>
+<span class="price-tag">2 sats</span>
+</div>
+<div class="desc">Crawl a pubkey the graph doesn't know yet, or knows only from an old crawl. Its newest kind 3 contact list, kind 0 profile and recent notes, reactions and zap receipts are fetched from the crawl relays in the background. The contact list is applied to the live graph like a hint, so the pubkey and its follows get scores before the next rebuild. Returns 202 with a job ID; poll GET /crawl/status?id= until state is done or failed. A pubkey already queued, or crawled in the last 10 minutes, gets its existing job back (200, created false). Jobs can be polled for an hour. Limited to 5 requests per minute per IP.</div>
 <div class="params">
-<div class="params-title">Parameters</div>
-<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Filter by specific provider (optional)</span></div>
+<div class="params-title">JSON body</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response (after polling)</div>
+<div class="code-block">{
+  "id": "9f2c41d0a7b3e815",
+  "pubkey": "abc123...",
+  "state": "done",
+  "found_before": false,
+  "found": true,
+  "score": 12,
+  "contact_list": {"status": "updated", "follows": 214, "added": 214, "removed": 0, "rescored": 388},
+  "profile": {"status": "found", "name": "alice", "nip05": "alice@example.com"},
+  "engagement": {"status": "crawled", "posts": 14, "replies": 6, "reactions_sent": 10, "reactions_received": 3, "zaps_received": 2, "zap_amount_received": 2100}
+}</div>
 </div>
 </div>
 
-<div class="endpoint-card" id="ep-communities">
+<div class="endpoint-card" id="ep-hint">
 <div class="endpoint-header">
-<span class="method method-get">GET</span>
-<span class="path">/communities</span>
-<span class="free">FREE</span>
+<span class="method method-post">POST</span>
+<span class="path">/hint</span>
+<span class="price-tag">1 sat</span>
 </div>
-<div class="desc">Trust communities detected via label propagation over the follow graph. With a pubkey, returns that user's community and peers.</div>
+<div class="desc">Tell the service a pubkey just published a new kind 3 contact list, so it is applied now instead of at the next 6-hourly crawl. Attach the signed event to skip the relay fetch; otherwise the newest contact list is fetched from the crawl relays. The follow list is replaced and the change is pushed through the scores incrementally, as far as it reaches (rescored counts the scores that moved); the next full rebuild corrects any drift. Lists no newer than the one already crawled are ignored (stale_event). Limited to 10 hints per minute per IP and one refresh per pubkey per minute.</div>
 <div class="params">
-<div class="params-title">Parameters</div>
-<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Get community for specific pubkey (optional)</span></div>
+<div class="params-title">JSON body</div>
+<div class="param"><span class="param-name">pubkey</span><span class="param-type">string</span><span class="param-desc">Hex pubkey or npub (optional if event is given)</span></div>
+<div class="param"><span class="param-name">event</span><span class="param-type">object</span><span class="param-desc">Signed kind 3 event by pubkey (optional)</span></div>
+<div class="param"><span class="param-name">kind</span><span class="param-type">int</span><span class="param-desc">What changed; only 3 is supported</span></div>
+</div>
+<div class="example">
+<div class="example-title">Response</div>
+<div class="code-block">{
+  "pubkey": "abc123...",
+  "status": "updated",
+  "source": "relays",
+  "added": 3,
+  "removed": 1,
+  "rescored": 412,
+  "event_at": "2026-02-10T14:02:11Z"
+}</div>
 </div>
 </div>
 
-<div class="endpoint-card" id="ep-publish">
+<div class="endpoint-card" id="ep-ingest">
 <div class="endpoint-header">
 <span class="method method-post">POST</span>
-<span class="path">/publish</span>
+<span class="path">/ingest</span>
+<span class="free">PARTNERS</span>
+</div>
+<div class="desc">Partner relays push events to us instead of waiting to be crawled. Accepts batches of up to 500 kind 3 (contact list), 7 (reaction), 9735 (zap receipt) and 1984 (report) events, authenticated with NIP-98 by a pubkey listed in INGEST_PARTNERS. Each event's id and signature are checked and events are deduplicated by id for 24 hours, then applied exactly as the crawler would. Contact lists newer than the graph's replace the author's follows with a local rescore; authors pushed within the last 6 hours are skipped by the next follow crawl. Per-partner counters appear in /stats under ingest_partners.</div>
+<div class="params">
+<div class="params-title">JSON body</div>
+<div class="param"><span class="param-name">events</span><span class="param-type">object[]</span><span class="param-desc">Signed Nostr events (max 500) <span class="param-req">required</span></span></div>
+</div>
+<div class="example">
+<div class="example-title">Response</div>
+<div class="code-block">{
+  "received": 240,
+  "accepted": 231,
+  "duplicates": 7,
+  "rejected": 2,
+  "contact_lists_applied": 18,
+  "contact_lists_stale": 3,
+  "errors": [{"id": "e4f1...", "reason": "unsupported kind 1"}, {"id": "9ab0...", "reason": "invalid signature"}]
+}</div>
+</div>
+</div>
+
+<div class="endpoint-card" id="ep-sandbox-score">
+<div class="endpoint-header">
+<span class="method method-post">POST</span>
+<span class="path">/sandbox/score</span>
 <span class="free">FREE</span>
 </div>
-<div class="desc">Publish all NIP-85 assertion events (kinds 30382, 30383, 30384, 30385) and NIP-89 handler info to configured relays.</div>
+<div class="desc">Score your own mini-graph with the exact production code: PageRank, time-decayed PageRank, community detection, and personalized PageRank when a viewer is given. Up to 5000 edges; node IDs are any strings up to 64 characters. Nothing is added to the served graph. Omitted parameters take the production defaults.</div>
+<div class="params">
+<div class="params-title">JSON body</div>
+<div class="param"><span class="param-name">edges</span><span class="param-type">object[]</span><span class="param-desc">{"from", "to", "created_at"?} follows (max 5000) <span class="param-req">required</span></span></div>
+<div class="param"><span class="param-name">damping</span><span class="param-type">float</span><span class="param-desc">PageRank damping (default 0.85)</span></div>
+<div class="param"><span class="param-name">iterations</span><span class="param-type">int</span><span class="param-desc">PageRank iterations (default 20, max 100)</span></div>
+<div class="param"><span class="param-name">half_life_days</span><span class="param-type">float</span><span class="param-desc">Decay half-life (default 365)</span></div>
+<div class="param"><span class="param-name">community_iterations</span><span class="param-type">int</span><span class="param-desc">Label propagation rounds (default 10, max 100)</span></div>
+<div class="param"><span class="param-name">viewer</span><span class="param-type">string</span><span class="param-desc">Root node for personalized PageRank (optional)</span></div>
+</div>
+<div class="example">
+<div class="example-title">Example</div>
+<div class="code-block">curl -X POST https://wot.klabo.world/sandbox/score -d '{"edges":[{"from":"alice","to":"bob"},{"from":"bob","to":"carol"},{"from":"carol","to":"bob"}],"viewer":"alice"}'</div>
+</div>
 </div>
 
-<div class="endpoint-card" id="ep-providers">
+<div class="endpoint-card" id="ep-analytics-subjects">
 <div class="endpoint-header">
 <span class="method method-get">GET</span>
-<span class="path">/providers</span>
-<span class="free">FREE</span>
+<span class="path">/analytics/subjects</span>
+<span class="free">ADMIN</span>
+</div>
+<div class="desc">Aggregate consumer analytics for the operator: which subjects are queried most, through which endpoints, and which NIP-85 assertion d-tags (kinds 30382, 30383, 30385) are served most often. Counts only — no caller identity is stored. Use it to prioritize publishing and refreshing assertions for subjects people actually look up. Requires the admin token.</div>
+<div class="params">
+<div class="params-title">Parameters</div>
+<div class="param"><span class="param-name">limit</span><span class="param-type">int</span><span class="param-desc">Max subjects and d-tags returned (default 50, max 1000)</span></div>
+<div class="param"><span class="param-name">endpoint</span><span class="param-type">string</span><span class="param-desc">Rank subjects by queries to one endpoint, e.g. /score (optional)</span></div>
+<div class="param"><span class="param-name">kind</span><span class="param-type">int</span><span class="param-desc">Limit d-tags to one assertion kind, 30382-30385 (optional)</span></div>
 </div>
-<div class="desc">External NIP-85 assertion providers and their assertion counts.</div>
 </div>
 
 <div class="endpoint-card" id="ep-stats">
@@ -3030,7 +4821,7 @@ Thresholds: &gt;= 70%% likely_spam | 40-70%% suspicious | &lt; 40%% likely_human
 <span class="path">/health</span>
 <span class="free">FREE</span>
 </div>
-<div class="desc">Health check endpoint. Returns ready/starting status and graph statistics.</div>
+<div class="desc">Health check endpoint. Returns status (starting, ready, degraded when some relays failed the last crawl, stale when none were reachable or data is over 12 hours old), relay_health with data age and per-relay failures and retry times, and graph statistics.</div>
 </div>
 
 <footer>
@@ -3337,41 +5128,84 @@ footer a:hover{text-decoration:underline}
 <div class="endpoint"><span class="method">GET</span><span class="path">/score?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Trust score + metadata</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/audit?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Score audit: full breakdown of why a pubkey has its score</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/personalized?viewer=&lt;hex&gt;&amp;target=&lt;hex&gt;</span><span class="desc">— Personalized trust score</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/personalized/import</span><span class="desc">— Submit the viewer's signed contact list for instant personalization</span></div>
 <div class="endpoint"><span class="method">POST</span><span class="path">/batch</span><span class="desc">— Score multiple pubkeys at once</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/audit/batch</span><span class="desc">— Score audits for up to 50 pubkeys</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/personalized/batch</span><span class="desc">— Personalized scores of up to 100 targets for one viewer</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/graph/batch</span><span class="desc">— Neighborhood graphs for up to 25 pubkeys</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/rank</span><span class="desc">— Rank a candidate feed by author WoT, engagement, recency and viewer</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/graphql</span><span class="desc">— Fetch scores, followers, communities and anomalies in one GraphQL query</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/similar?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Find similar pubkeys by follow overlap</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/recommend?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Follow recommendations (friends-of-friends)</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/graph?from=&lt;hex&gt;&amp;to=&lt;hex&gt;</span><span class="desc">— Trust path finder (shortest connection)</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/graph?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Neighborhood graph (local follow network)</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/metadata?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Full NIP-85 metadata</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/metadata/batch</span><span class="desc">— Metadata for up to 100 pubkeys</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/event?id=&lt;hex&gt;</span><span class="desc">— Event engagement (kind 30383)</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/external?id=&lt;ident&gt;</span><span class="desc">— Identifier score (kind 30385)</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/relay?url=&lt;wss://...&gt;</span><span class="desc">— Relay trust + operator WoT</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/relay/suggest?pubkey=&lt;hex&gt;</span><span class="desc">— Relay suggestions from trusted follows' NIP-65 lists</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/relay/top?limit=50</span><span class="desc">— Relays ranked by trust-weighted usage and operator WoT score</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/compare?a=&lt;pubkey&gt;&amp;b=&lt;pubkey&gt;</span><span class="desc">— Compare two pubkeys trust relationship</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/decay?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Time-decayed trust score (newer follows weigh more)</span></div>
-<div class="endpoint"><span class="method">GET</span><span class="path">/decay/top</span><span class="desc">— Top pubkeys by decay-adjusted score with rank changes</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/decay/top</span><span class="desc">— Top pubkeys by decay-adjusted score with rank changes and rising/steady/fading momentum</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/authorized</span><span class="desc">— Kind 10040 authorized users (who trusts us)</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/communities?pubkey=&lt;hex&gt;</span><span class="desc">— Trust community detection (label propagation)</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/bridges?a=&lt;id&gt;&amp;b=&lt;id&gt;</span><span class="desc">— Accounts bridging communities (cross-community betweenness)</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/communities/map</span><span class="desc">— Inter-community trust map: weighted follow edges between communities, with bridges</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/top</span><span class="desc">— Top 50 scored pubkeys</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/external</span><span class="desc">— Top 50 external identifiers</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/external/trending?window=24h</span><span class="desc">— Trust-weighted trending hashtags and URLs</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/articles/top?topic=&lt;tag&gt;</span><span class="desc">— Trust-curated long-form article feed</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/stats</span><span class="desc">— Service statistics</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/health</span><span class="desc">— Health check</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/rebuild/status</span><span class="desc">— Rebuild progress (phase, percent, ETA)</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/crawl/status</span><span class="desc">— Crawl progress and per-relay request metrics</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/crawl</span><span class="desc">— Crawl an unknown pubkey on demand; poll the job at /crawl/status?id=</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/hint</span><span class="desc">— Hint that a contact list changed; refetch and rescore now</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/ingest</span><span class="desc">— Partner relays push kind 3/7/9735/1984 events in batches (NIP-98)</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/sandbox/score</span><span class="desc">— Score a user-supplied mini-graph with the production algorithms</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/nip05?id=user@domain</span><span class="desc">— NIP-05 verification + WoT trust profile</span></div>
 <div class="endpoint"><span class="method">POST</span><span class="path">/nip05/batch</span><span class="desc">— Bulk NIP-05 verification (up to 50 identifiers)</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/nip05/reverse?pubkey=&lt;hex&gt;</span><span class="desc">— Reverse NIP-05 lookup (pubkey → identity, bidirectional verification)</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/domain?name=example.com</span><span class="desc">— NIP-05 domain reputation: member scores, spam rate, checkmark advice</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/spam?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Spam detection: multi-signal analysis with classification</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/reports?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Trust-weighted kind 1984 reports by category</span></div>
 <div class="endpoint"><span class="method">POST</span><span class="path">/spam/batch</span><span class="desc">— Bulk spam check (up to 100 pubkeys)</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/spam/event</span><span class="desc">— Per-event spam verdict for relay filters</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/weboftrust?pubkey=&lt;hex|npub&gt;</span><span class="desc">— D3.js-compatible trust graph visualization</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/timeline?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Historical trust growth timeline</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/history?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Recorded score, rank and follower count at each rebuild</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/snapshots</span><span class="desc">— Retained score snapshots, each downloadable in full from /snapshots/{id}/export</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/contacts/snapshot?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Observed contact list versions: backup, restore, mass-unfollow audit</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/active?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Activity heartbeat: last seen, posting cadence, automation likelihood, active/dormant/abandoned</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/growth-sources?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Where followers came from: communities, score tiers, burst vs organic pacing</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/churn?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Follow/unfollow log: unfollow rate, net growth, biggest recent unfollowers</span></div>
 <div class="endpoint"><span class="method">POST</span><span class="path">/verify</span><span class="desc">— Cross-provider NIP-85 assertion verification</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/attestation?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Signed, expiring score attestation (Nostr event or JWS)</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/challenge</span><span class="desc">— Short-lived token proving your score &ge; threshold (NIP-98 auth)</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/challenge/verify?token=</span><span class="desc">— Verify a challenge token (signature, expiry, audience, nonce)</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/annotations?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Third-party namespaced annotations (POST with NIP-98 to add)</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/endorsements?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Community endorsements: quorum, bounded boost, endorser breakdown</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/migrations?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Key migrations (NIP-41) and delegations (NIP-26): inherited trust and account age</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/anomalies?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Trust anomaly detection and risk assessment</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/zap-score?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Zap-weighted score with wash-trading checks (self-zaps, zap loops, alt keys)</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/report-gaming</span><span class="desc">— Report follower-buying or follow rings (NIP-98), weighted by reporter score</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/sybil?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Sybil resistance scoring (0-100, multi-signal analysis)</span></div>
 <div class="endpoint"><span class="method">POST</span><span class="path">/sybil/batch</span><span class="desc">— Batch Sybil scoring (up to 50 pubkeys)</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/org-score</span><span class="desc">— Aggregate trust profile for an organization's accounts</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/influence?pubkey=&lt;hex|npub&gt;&amp;other=&lt;hex|npub&gt;</span><span class="desc">— Influence propagation: what-if analysis for follows/unfollows</span></div>
 <div class="endpoint"><span class="method">POST</span><span class="path">/influence/batch</span><span class="desc">— Batch static influence analysis (up to 50 pubkeys, role classification)</span></div>
+<div class="endpoint"><span class="method">POST</span><span class="path">/simulate</span><span class="desc">— What-if onboarding: projected score, rank and percentile if given accounts followed a pubkey</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/reach2?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Estimated unique 2-hop audience (HyperLogLog sketches)</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/network-health</span><span class="desc">— Network topology health: degree distribution, connectivity, Gini, hubs</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/bias?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Seed bias: score by distance from the seeds, per-seed regions and crawl budgets</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/compare-providers?pubkey=&lt;hex|npub&gt;</span><span class="desc">— Cross-provider WoT score comparison with consensus metrics</span></div>
 <div class="endpoint"><span class="method">WS</span><span class="path">/ws/scores</span><span class="desc">— Real-time score streaming via WebSocket (subscribe to pubkey updates)</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/providers</span><span class="desc">— External NIP-85 assertion providers</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/providers/accuracy</span><span class="desc">— Provider accuracy against our graph</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/assertions?subject=&lt;hex&gt;</span><span class="desc">— Raw signed external assertions behind composite scores</span></div>
+<div class="endpoint"><span class="method">GET</span><span class="path">/assertion-schema</span><span class="desc">— Tag schema for published kinds 30382-30385 (types, units, semantics)</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/docs</span><span class="desc">— Interactive API documentation</span></div>
 <div class="endpoint"><span class="method">GET</span><span class="path">/demo</span><span class="desc">— Visual trust dashboard: explore any pubkey's WoT profile</span></div>
 </div>
@@ -3381,10 +5215,10 @@ footer a:hover{text-decoration:underline}
 	<p style="color:#aaa;font-size:.95rem;margin-bottom:1rem">Pay-per-query via Lightning Network. Free tier: 50 requests/day per IP. After that, pay sats per query.</p>
 <div style="display:grid;grid-template-columns:repeat(auto-fit,minmax(180px,1fr));gap:.5rem">
 <div class="kind"><span class="kind-num" style="background:#16a34a">1 sat</span><span class="kind-desc">/score, /decay, /nip05</span></div>
-<div class="kind"><span class="kind-num" style="background:#2563eb">2 sats</span><span class="kind-desc">/personalized, /similar, /recommend, /compare, /nip05/reverse, /timeline, /spam, /verify</span></div>
+<div class="kind"><span class="kind-num" style="background:#2563eb">2 sats</span><span class="kind-desc">/personalized, /similar, /recommend, /compare, /nip05/reverse, /timeline, /history, /spam, /spam/event, /reports, /verify, /crawl</span></div>
 <div class="kind"><span class="kind-num" style="background:#0ea5e9">3 sats</span><span class="kind-desc">/weboftrust, /anomalies, /sybil, /predict</span></div>
-<div class="kind"><span class="kind-num" style="background:#9333ea">5 sats</span><span class="kind-desc">/audit, /nip05/batch, /trust-path, /reputation, /influence, /network-health, /compare-providers</span></div>
-<div class="kind"><span class="kind-num" style="background:#dc2626">10 sats</span><span class="kind-desc">/batch, /spam/batch, /sybil/batch, /influence/batch</span></div>
+<div class="kind"><span class="kind-num" style="background:#9333ea">5 sats</span><span class="kind-desc">/audit, /nip05/batch, /domain, /trust-path, /reputation, /influence, /simulate, /network-health, /bias, /compare-providers, /relay/suggest</span></div>
+<div class="kind"><span class="kind-num" style="background:#dc2626">10 sats</span><span class="kind-desc">/batch, /audit/batch, /personalized/batch, /graph/batch, /rank, /spam/batch, /sybil/batch, /influence/batch, /org-score, /graphql</span></div>
 </div>
 <p style="color:#666;font-size:.85rem;margin-top:.75rem">Endpoints not listed above are free and unlimited. Payment via L402 protocol: request → 402 + invoice → pay → retry with X-Payment-Hash header.</p>
 </div>
@@ -3570,7 +5404,7 @@ const lc=levelColors[d.trust_level]||"#666";
 let html='<div class="score-card fade-in">';
 html+='<div style="display:flex;align-items:baseline;gap:1rem;flex-wrap:wrap">';
 html+='<div class="score-big">'+d.score+'/100</div>';
-html+='<span style="background:'+lc+'22;color:'+lc+';padding:.25rem .75rem;border-radius:6px;font-size:.95rem;font-weight:600;border:1px solid '+lc+'44">'+d.trust_level.replace("_"," ")+'</span>';
+html+='<span style="background:'+lc+'22;color:'+lc+';padding:.25rem .75rem;border-radius:6px;font-size:.95rem;font-weight:600;border:1px solid '+lc+'44">'+(d.trust_level_label||d.trust_level.replace("_"," "))+'</span>';
 html+='</div>';
 html+='<div style="color:#10b981;margin-top:.5rem;font-size:.9rem">&#10003; Verified: '+d.nip05+'</div>';
 html+='<div style="color:#888;margin-top:.25rem;font-family:monospace;font-size:.85rem">'+d.pubkey+'</div>';
@@ -3599,7 +5433,7 @@ if(d.nip05&&d.verified){
 const lc=levelColors[d.trust_level]||"#666";
 html+='<div style="display:flex;align-items:baseline;gap:1rem;flex-wrap:wrap">';
 html+='<div class="score-big">'+d.score+'/100</div>';
-html+='<span style="background:'+lc+'22;color:'+lc+';padding:.25rem .75rem;border-radius:6px;font-size:.95rem;font-weight:600;border:1px solid '+lc+'44">'+d.trust_level.replace("_"," ")+'</span>';
+html+='<span style="background:'+lc+'22;color:'+lc+';padding:.25rem .75rem;border-radius:6px;font-size:.95rem;font-weight:600;border:1px solid '+lc+'44">'+(d.trust_level_label||d.trust_level.replace("_"," "))+'</span>';
 html+='</div>';
 html+='<div style="color:#10b981;margin-top:.5rem;font-size:.95rem">&#10003; '+d.nip05+'</div>';
 if(d.display_name){html+='<div style="color:#ccc;font-size:.9rem;margin-top:.25rem">'+d.display_name+'</div>'}
@@ -3770,181 +5604,399 @@ graphContainer.innerHTML=svg;
 </body>
 </html>`
 
-func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8090"
-	}
-
-	// Seed pubkeys: well-known Nostr accounts for initial graph crawl
-	seeds := []string{
-		"82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2", // jack
-		"fa984bd7dbb282f07e16e7ae87b26a2a7b9b90b7246a44771f0cf5ae58018f52", // pablo
-		"32e1827635450ebb3c5a7d12c1f8e7b2b514439ac10a67eef3d9fd9c5c68e245", // jb55
-		"f2da54d2d1edfe02c052972e2eeb192a5046751ed38e94e2f9be0c156456e2aa", // max (SATMAX)
-	}
-
-	// Crawl depth (1 = direct follows, 2 = follows-of-follows)
-	depth := 2
-	log.Printf("Starting WoT graph crawl with %d seeds, depth %d...", len(seeds), depth)
-
-	ctx := context.Background()
-	go func() {
-		crawlFollows(ctx, seeds, depth)
-		log.Printf("Computing PageRank...")
-		graph.ComputePageRank(20, 0.85)
-		stats := graph.Stats()
-		log.Printf("WoT graph ready: %d nodes, %d edges", stats.Nodes, stats.Edges)
-
-		// Populate follower counts from graph
-		meta.CountFollowers(graph)
-		log.Printf("Follower counts populated")
-
-		// Crawl metadata (notes, reactions, zaps) for top-scored pubkeys
-		topPubkeys := TopNPubkeys(graph, 500)
-		log.Printf("Crawling metadata for top %d pubkeys...", len(topPubkeys))
-		meta.CrawlMetadata(ctx, topPubkeys)
-		log.Printf("Metadata crawl complete")
-
-		// Crawl event engagement for NIP-85 kind 30383/30384
-		log.Printf("Crawling event engagement for top %d pubkeys...", len(topPubkeys))
-		events.CrawlEventEngagement(ctx, topPubkeys)
-		log.Printf("Event engagement crawl complete: %d events, %d addressable",
-			events.EventCount(), events.AddressableCount())
-
-		// Crawl external identifiers (hashtags, URLs) for NIP-85 kind 30385
-		log.Printf("Crawling external identifiers for top %d pubkeys...", len(topPubkeys))
-		external.CrawlExternalIdentifiers(ctx, topPubkeys)
-		log.Printf("External identifier crawl complete: %d identifiers", external.Count())
-
-		// Consume external NIP-85 assertions from other providers
+// rebuildPipeline returns the phases of a full crawl + scoring rebuild.
+// Weights approximate each phase's share of wall-clock time on the production graph.
+// Each rebuild takes the config active when it starts.
+func rebuildPipeline() func() []RebuildPhase {
+	return func() []RebuildPhase {
+		cfg := config.Get()
+		var topPubkeys []string
 		ownPub := ""
-		if nsec, err := getNsec(); err == nil {
-			if _, pub, err := decodeKey(nsec); err == nil {
-				ownPub = pub
-			}
-		}
-		consumeExternalAssertions(ctx, externalAssertions, ownPub)
-
-		// Consume NIP-85 kind 10040 authorizations
-		consumeAuthorizations(ctx, authStore)
-
-		// Consume NIP-51 kind 10000 mute lists
-		consumeMuteLists(ctx, muteStore)
-
-		// Detect trust communities via label propagation
-		log.Printf("Detecting trust communities...")
-		numCommunities := communities.DetectCommunities(graph, 10)
-		log.Printf("Community detection complete: %d non-trivial communities", communities.TotalCommunities())
-		_ = numCommunities
-
-		// Auto-publish NIP-85 events after initial crawl
-		autoPublish(ctx)
+		phases := []RebuildPhase{
+			{Name: "crawl_follows", Weight: 40, Run: func(ctx context.Context, progress func(float64)) {
+				// Requests keep reading the last complete graph until the crawl is done
+				graph.Hold()
+				defer graph.Release()
+				relayLimits.Refresh(ctx, cfg.Relays)
+				ingestStore.GC()
+				crawlMu.Lock()
+				defer crawlMu.Unlock()
+				if report, ok := crawlFollows(ctx, graph, curation.CrawlSeeds(cfg.Seeds), cfg.CrawlDepth, cfg.SeedCrawlBudget, progress); ok {
+					seedCrawls.Record(report)
+				}
+			}},
+			{Name: "prune", Weight: 1, Run: func(ctx context.Context, _ func(float64)) {
+				if cfg.PruneInactiveMonths == 0 && cfg.MaxFollows == 0 {
+					return
+				}
+				r := pruneGraph(cfg, time.Now())
+				log.Printf("Pruned %d inactive and %d orphaned pubkeys; %d follow lists over max_follows (%d follows not counted)",
+					r.InactiveNodes, r.OrphanedNodes, r.CappedNodes, r.CappedEdges)
+			}},
+			{Name: "pagerank", Weight: 5, Run: func(ctx context.Context, _ func(float64)) {
+				if n := massFollows.Detect(graph, massFollowPolicy); n > 0 {
+					log.Printf("Dampening follows of %d mass-follow accounts", n)
+				}
+				if muteScoring.Enabled {
+					// Mutes gathered by earlier rebuilds count against the muted
+					var weight func(from, to string) float64
+					if edgeWeights.Enabled {
+						weight = edgeWeights.Weight
+					}
+					log.Printf("Computing mute-penalized PageRank (%d muters)...", muteStore.TotalMuters())
+					mutePenalties.Set(graph.ComputeMutePenalizedPageRank(cfg.PageRankIterations, cfg.Damping, weight, muteStore.Snapshot(), muteScoring))
+				} else if edgeWeights.Enabled {
+					log.Printf("Computing weighted PageRank (%d interaction pairs)...", interactions.PairCount())
+					graph.ComputeWeightedPageRank(cfg.PageRankIterations, cfg.Damping, edgeWeights.Weight)
+				} else {
+					log.Printf("Computing PageRank...")
+					graph.ComputePageRank(cfg.PageRankIterations, cfg.Damping)
+				}
+				graph.RefreshPercentileBuckets()
+				graph.RefreshRawScoreDistribution()
+				graphBuild.Advance(time.Now())
+				stats := graph.Stats()
+				log.Printf("WoT graph ready: %d nodes, %d edges", stats.Nodes, stats.Edges)
 
-		// Push initial scores to any WebSocket subscribers
-		wsHub.BroadcastScoreUpdate()
+				// Keep the new scores in each pubkey's /history and in /snapshots
+				buildID, _, builtAt := graphBuild.Current()
+				scoreHistory.Record(graph, buildID, builtAt)
+				scoreSnapshots.Record(graph, buildID, builtAt, cfg.SnapshotRetention, cfg.SnapshotRetentionDays)
 
-		// Schedule periodic re-crawl + auto-publish every 6 hours
-		go func() {
-			ticker := time.NewTicker(6 * time.Hour)
-			defer ticker.Stop()
-			for range ticker.C {
-				log.Printf("Starting scheduled re-crawl...")
-				crawlFollows(ctx, seeds, depth)
-				graph.ComputePageRank(20, 0.85)
+				// Populate follower counts from graph
 				meta.CountFollowers(graph)
-				topPubkeys := TopNPubkeys(graph, 500)
+				log.Printf("Follower counts populated")
+			}},
+			{Name: "metadata", Weight: 20, Run: func(ctx context.Context, _ func(float64)) {
+				// Crawl metadata (notes, reactions, zaps) for top-scored pubkeys
+				topPubkeys = TopNPubkeys(graph, 500)
+				log.Printf("Crawling metadata for top %d pubkeys...", len(topPubkeys))
 				meta.CrawlMetadata(ctx, topPubkeys)
+				log.Printf("Metadata crawl complete")
+			}},
+			{Name: "event_engagement", Weight: 10, Run: func(ctx context.Context, _ func(float64)) {
+				// Crawl event engagement for NIP-85 kind 30383/30384
+				log.Printf("Crawling event engagement for top %d pubkeys...", len(topPubkeys))
 				events.CrawlEventEngagement(ctx, topPubkeys)
+				log.Printf("Event engagement crawl complete: %d events, %d addressable",
+					events.EventCount(), events.AddressableCount())
+			}},
+			{Name: "external_identifiers", Weight: 5, Run: func(ctx context.Context, _ func(float64)) {
+				// Crawl external identifiers (hashtags, URLs) for NIP-85 kind 30385
+				log.Printf("Crawling external identifiers for top %d pubkeys...", len(topPubkeys))
 				external.CrawlExternalIdentifiers(ctx, topPubkeys)
+				log.Printf("External identifier crawl complete: %d identifiers", external.Count())
+			}},
+			{Name: "external_assertions", Weight: 5, Run: func(ctx context.Context, _ func(float64)) {
+				// Consume external NIP-85 assertions from other providers
+				if signer, err := signers.Get(ctx); err == nil {
+					ownPub = signer.PublicKey()
+				}
 				consumeExternalAssertions(ctx, externalAssertions, ownPub)
+				if n := assertionArchive.GC(time.Now()); n > 0 {
+					log.Printf("Assertion archive: collected %d superseded events", n)
+				}
+			}},
+			{Name: "provider_accuracy", Weight: 1, Run: func(ctx context.Context, _ func(float64)) {
+				// Check external providers' claims against our graph to weight composite scores
+				providerAccuracy.Set(evaluateProviderAccuracy(graph.Snapshot(), externalAssertions))
+				log.Printf("Provider accuracy complete")
+			}},
+			{Name: "authorizations", Weight: 3, Run: func(ctx context.Context, _ func(float64)) {
+				// Consume NIP-85 kind 10040 authorizations
 				consumeAuthorizations(ctx, authStore)
+			}},
+			{Name: "mute_lists", Weight: 3, Run: func(ctx context.Context, _ func(float64)) {
+				// Consume NIP-51 kind 10000 mute lists
 				consumeMuteLists(ctx, muteStore)
+			}},
+			{Name: "hits", Weight: 2, Run: func(ctx context.Context, _ func(float64)) {
+				// Hub and authority scores for /score?algorithm=hits and /audit
+				hitsScores.Set(ComputeHITS(graph.Snapshot(), cfg.PageRankIterations))
+				log.Printf("HITS complete")
+			}},
+			{Name: "distrust", Weight: 1, Run: func(ctx context.Context, _ func(float64)) {
+				// Propagate distrust from trusted accounts' reports and mutes
+				distrust.Set(ComputeDistrust(graph, reportStore.Snapshot(), muteStore.Snapshot()))
+				log.Printf("Distrust propagation complete: %d pubkeys penalized", distrust.Count())
+			}},
+			{Name: "endorsements", Weight: 2, Run: func(ctx context.Context, _ func(float64)) {
+				// Consume NIP-32 community endorsements
+				consumeEndorsements(ctx, endorsements)
+			}},
+			{Name: "migrations", Weight: 1, Run: func(ctx context.Context, _ func(float64)) {
+				// Consume NIP-41 key migration notices
+				consumeMigrations(ctx, migrations)
+			}},
+			{Name: "communities", Weight: 3, Run: func(ctx context.Context, _ func(float64)) {
+				// Detect trust communities via label propagation
+				log.Printf("Detecting trust communities...")
 				communities.DetectCommunities(graph, 10)
+				buildID, _, _ := graphBuild.Current()
+				communityHistory.Record(communities, buildID, time.Now())
+				log.Printf("Community detection complete: %d non-trivial communities", communities.TotalCommunities())
+			}},
+			{Name: "betweenness", Weight: 3, Run: func(ctx context.Context, _ func(float64)) {
+				// Sampled graph-wide betweenness for broker scores on /audit and /bridges?scope=graph
+				buildID, _, _ := graphBuild.Current()
+				brokerScores.Set(ComputeBrokerScores(graph.Snapshot(), brokerSamples, int64(buildID)))
+				log.Printf("Betweenness complete")
+			}},
+			{Name: "similarity_index", Weight: 2, Run: func(ctx context.Context, _ func(float64)) {
+				// MinHash/LSH over follow sets for /similar
+				similarityIndex.Build(graph.Snapshot())
+				log.Printf("Similarity index complete: %d follow sets", similarityIndex.Stats()["indexed"])
+			}},
+			{Name: "relays", Weight: 3, Run: func(ctx context.Context, _ func(float64)) {
+				// NIP-65 relay usage and NIP-11 operators for /relay/top and relay assertions
+				crawlRelayStore(ctx, relayStore)
+			}},
+			{Name: "publish", Weight: 3, Run: func(ctx context.Context, _ func(float64)) {
 				stats := graph.Stats()
-				log.Printf("Re-crawl complete: %d nodes, %d edges, %d events, %d addressable, %d external, %d ext_assertions, %d auths, %d mute_lists, %d communities",
+				log.Printf("Rebuild complete: %d nodes, %d edges, %d events, %d addressable, %d external, %d ext_assertions, %d auths, %d mute_lists, %d communities",
 					stats.Nodes, stats.Edges, events.EventCount(), events.AddressableCount(), external.Count(),
 					externalAssertions.TotalAssertions(), authStore.TotalAuthorizations(), muteStore.TotalMuters(), communities.TotalCommunities())
 
+				// Auto-publish NIP-85 events
 				autoPublish(ctx)
 
 				// Push updated scores to WebSocket subscribers
 				wsHub.BroadcastScoreUpdate()
+			}},
+		}
+
+		// Every other phase changes served data too, so each one bumps the build
+		// revision and invalidates cached responses even if the rebuild is later
+		// cancelled. PageRank advances the build ID itself.
+		for i := range phases {
+			if phases[i].Name == "pagerank" {
+				continue
 			}
-		}()
-	}()
+			run := phases[i].Run
+			phases[i].Run = func(ctx context.Context, progress func(float64)) {
+				run(ctx, progress)
+				graphBuild.Touch()
+			}
+		}
 
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		stats := graph.Stats()
-		status := "starting"
-		if stats.Nodes > 0 {
-			status = "ready"
+		// A primary hands the finished build to its replicas. Sharing changes
+		// nothing served, so it doesn't touch the revision the replicas copy.
+		if graphSharing.Role == "primary" {
+			phases = append(phases, RebuildPhase{Name: "share_snapshot", Weight: 3, Run: func(ctx context.Context, _ func(float64)) {
+				if err := graphSharing.Share(); err != nil {
+					log.Printf("Sharing graph snapshot failed: %v", err)
+				}
+			}})
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"status":               status,
-			"graph_nodes":          stats.Nodes,
-			"graph_edges":          stats.Edges,
-			"events":               events.EventCount(),
-			"addressable":          events.AddressableCount(),
-			"external":             external.Count(),
-			"external_providers":   externalAssertions.ProviderCount(),
-			"external_assertions":  externalAssertions.TotalAssertions(),
-			"authorizations":       authStore.TotalAuthorizations(),
-			"authorized_users":     authStore.TotalUsers(),
-			"communities":          communities.TotalCommunities(),
-			"mute_lists":           muteStore.TotalMuters(),
-			"muted_pubkeys":        muteStore.TotalMuted(),
-			"uptime":               time.Since(startTime).String(),
-		})
-	})
+		return phases
+	}
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "exporter" {
+		runExporter()
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "strfry" {
+		runStrfry()
+		return
+	}
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8090"
+	}
+
+	// Seeds, relays and PageRank settings: defaults, CONFIG_FILE, then env overrides
+	if err := config.Reload(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	cfg := config.Get()
+	graphSharing = NewGraphSharingFromEnv()
+	if graphSharing.Replica() {
+		log.Printf("Running as a read replica: serving graph snapshots from the %s store", graphSharing.backend)
+	} else {
+		log.Printf("Starting WoT graph crawl with %d seeds, %d relays, depth %d...", len(cfg.Seeds), len(cfg.Relays), cfg.CrawlDepth)
+	}
+
+	if err := curation.Load(); err != nil {
+		log.Printf("Curation load failed: %v", err)
+	}
+	// Events a previous run queued but never delivered
+	if err := publishQueue.Load(); err != nil {
+		log.Printf("Publish queue load failed: %v", err)
+	}
+	if err := zapCredits.Load(); err != nil {
+		log.Printf("Zap credits load failed: %v", err)
+	}
+	if err := accountStore.Load(); err != nil {
+		log.Printf("Accounts load failed: %v", err)
+	}
+	if err := badgeIssuer.Load(); err != nil {
+		log.Printf("Badge awards load failed: %v", err)
+	}
+	if err := dmNotifier.Load(); err != nil {
+		log.Printf("Notification subscribers load failed: %v", err)
+	}
+	if err := scoreSnapshots.Load(); err != nil {
+		log.Printf("Score snapshots load failed: %v", err)
+	}
+
+	// Restore archived external assertions so composite scores survive restarts
+	if n, err := assertionArchive.Load(externalAssertions); err != nil {
+		log.Printf("Assertion archive load failed: %v", err)
+	} else if n > 0 {
+		log.Printf("Loaded %d archived external assertions", n)
+	}
+
+	ctx := context.Background()
+	go config.Watch(ctx, 30*time.Second)
+	rebuilder = NewRebuildController(rebuildPipeline())
+	if graphSharing.Replica() {
+		go graphSharing.Follow(ctx)
+	} else {
+		publishQueue.Start(ctx)
+		go accountStore.Run(ctx)
+		go seedViews.Run(ctx)
+		go queryResponder.Run(ctx)
+	}
+	go func() {
+		if graphSharing.Replica() {
+			return // replicas never crawl; the primary builds for them
+		}
+		rebuilder.Run(ctx, "startup")
+
+		// Schedule periodic re-crawl + auto-publish every 6 hours. If a crawl
+		// reached no relay, retry as soon as the first relay's backoff expires.
+		ticker := time.NewTicker(rebuildInterval)
+		defer ticker.Stop()
+		for {
+			var retry <-chan time.Time
+			if d, ok := relayHealth.RetryIn(); ok {
+				log.Printf("All relays down; retrying crawl in %s", d.Round(time.Second))
+				retry = time.After(d)
+			}
+			select {
+			case <-ticker.C:
+				log.Printf("Starting scheduled re-crawl...")
+				if err := rebuilder.Run(ctx, "scheduled"); err != nil {
+					log.Printf("Scheduled re-crawl skipped: %v", err)
+				}
+			case <-retry:
+				if err := rebuilder.Run(ctx, "relay_retry"); err != nil {
+					log.Printf("Relay retry re-crawl skipped: %v", err)
+				}
+			}
+		}
+	}()
+
+	http.HandleFunc("/health", handleHealth)
+	http.HandleFunc("/assertions", handleAssertions)
+	http.HandleFunc("/assertion-schema", handleAssertionSchema)
+	http.HandleFunc("/rebuild", handleRebuild)
+	http.HandleFunc("/rebuild/status", handleRebuildStatus)
+	http.HandleFunc("/crawl/status", handleCrawlStatus)
+	http.HandleFunc("/rebuild/cancel", handleRebuildCancel)
+	http.HandleFunc("/crawl", handleCrawlRequest)
+	http.HandleFunc("/hint", handleHint)
+	http.HandleFunc("/ingest", handleIngest)
+	http.HandleFunc("/sandbox/score", handleSandboxScore)
+	http.HandleFunc("/analytics/subjects", handleAnalyticsSubjects)
 	http.HandleFunc("/providers", func(w http.ResponseWriter, r *http.Request) {
-		providers := externalAssertions.Providers()
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"providers":        providers,
-			"provider_count":   externalAssertions.ProviderCount(),
-			"total_assertions": externalAssertions.TotalAssertions(),
+		json.NewEncoder(w).Encode(ProvidersResponse{
+			Providers:       externalAssertions.Providers(),
+			ProviderCount:   externalAssertions.ProviderCount(),
+			TotalAssertions: externalAssertions.TotalAssertions(),
 		})
 	})
-	http.HandleFunc("/score", handleScore)
+	http.HandleFunc("/providers/accuracy", handleProviderAccuracy)
+	http.HandleFunc("/zap/request", handleZapRequest)
+	http.HandleFunc("/zap/balance", handleZapBalance)
+	http.HandleFunc("/account", handleAccount)
+	http.Handle("/score", scoreResponseCache.Wrap(handleScore))
 	http.HandleFunc("/audit", handleAudit)
 	http.HandleFunc("/batch", handleBatch)
+	http.HandleFunc("/audit/batch", handleAuditBatch)
+	http.HandleFunc("/personalized/batch", handlePersonalizedBatch)
+	http.HandleFunc("/graph/batch", handleGraphBatch)
+	http.HandleFunc("/rank", handleRank)
+	http.HandleFunc("/graphql", handleGraphQL)
 	http.HandleFunc("/personalized", handlePersonalized)
+	http.HandleFunc("/personalized/import", handleViewerImport)
 	http.HandleFunc("/similar", handleSimilar)
 	http.HandleFunc("/recommend", handleRecommend)
 	http.HandleFunc("/graph", handleGraph)
 	http.HandleFunc("/top", handleTop)
 	http.HandleFunc("/stats", handleStats)
 	http.HandleFunc("/export", handleExport)
+	http.HandleFunc("/export/bloom", handleExportBloom)
+	http.HandleFunc("/snapshots", handleSnapshots)
+	http.HandleFunc("/snapshots/{id}/export", handleSnapshotExport)
 	http.HandleFunc("/publish", handlePublish)
+	http.HandleFunc("/publish/status", handlePublishStatus)
 	http.HandleFunc("/metadata", handleMetadata)
+	http.HandleFunc("/metadata/batch", handleMetadataBatch)
 	http.HandleFunc("/event", handleEventScore)
 	http.HandleFunc("/external", handleExternal)
+	http.HandleFunc("/external/trending", handleExternalTrending)
+	http.HandleFunc("/articles/top", handleTopArticles)
 	http.HandleFunc("/relay", handleRelay)
+	http.HandleFunc("/relay/suggest", handleRelaySuggest)
+	http.HandleFunc("/relay/top", handleRelayTop)
 	http.HandleFunc("/compare", handleCompare)
 	http.HandleFunc("/decay", handleDecay)
 	http.HandleFunc("/decay/top", handleDecayTop)
 	http.HandleFunc("/authorized", handleAuthorized)
 	http.HandleFunc("/communities", handleCommunities)
+	http.HandleFunc("/bridges", handleBridges)
+	http.HandleFunc("/communities/map", handleCommunitiesMap)
 	http.HandleFunc("/nip05", handleNIP05)
 	http.HandleFunc("/nip05/batch", handleNIP05Batch)
 	http.HandleFunc("/nip05/reverse", handleNIP05Reverse)
+	http.HandleFunc("/domain", handleDomain)
 	http.HandleFunc("/timeline", handleTimeline)
+	http.HandleFunc("/history", handleHistory)
+	http.HandleFunc("/contacts/snapshot", handleContactsSnapshot)
+	http.HandleFunc("/active", handleActive)
+	http.HandleFunc("/activity", handleActive)
+	http.HandleFunc("/growth-sources", handleGrowthSources)
+	http.HandleFunc("/churn", handleChurn)
 	http.HandleFunc("/spam", handleSpam)
 	http.HandleFunc("/spam/batch", handleSpamBatch)
+	http.HandleFunc("/spam/event", handleSpamEvent)
+	http.HandleFunc("/reports", handleReports)
 	http.HandleFunc("/weboftrust", handleWebOfTrust)
 	http.HandleFunc("/blocked", handleBlocked)
+	http.HandleFunc("/annotations", handleAnnotations)
+	http.HandleFunc("/endorsements", handleEndorsements)
+	http.HandleFunc("/migrations", handleMigrations)
 	http.HandleFunc("/verify", handleVerify)
+	http.HandleFunc("/attestation", handleAttestation)
+	http.HandleFunc("/challenge", handleChallenge)
+	http.HandleFunc("/challenge/verify", handleChallengeVerify)
 	http.HandleFunc("/anomalies", handleAnomalies)
+	http.HandleFunc("/zap-score", handleZapScore)
+	http.HandleFunc("/report-gaming", handleReportGaming)
+	http.HandleFunc("/admin/gaming-reports", handleAdminGamingReports)
+	http.HandleFunc("/admin/gaming-reports/review", handleAdminGamingReview)
+	http.HandleFunc("/admin/bans", handleAdminCurated("bans", curation.Bans, curation.Ban, curation.Unban))
+	http.HandleFunc("/admin/seeds", handleAdminCurated("seeds", curation.Seeds, curation.Pin, curation.Unpin))
+	http.HandleFunc("/admin/recrawl", handleAdminRebuild("recrawl", nil))
+	http.HandleFunc("/admin/rescore", handleAdminRebuild("rescore", func(name string) bool { return rescorePhases[name] }))
+	http.HandleFunc("/admin/audit-log", handleAdminAuditLog)
 	http.HandleFunc("/sybil", handleSybil)
 	http.HandleFunc("/sybil/batch", handleSybilBatch)
+	http.HandleFunc("/org-score", handleOrgScore)
 	http.HandleFunc("/trust-path", handleTrustPath)
 	http.HandleFunc("/reputation", handleReputation)
 	http.HandleFunc("/predict", handlePredict)
 	http.HandleFunc("/influence", handleInfluence)
 	http.HandleFunc("/influence/batch", handleInfluenceBatch)
+	http.HandleFunc("/simulate", handleSimulate)
+	http.HandleFunc("/reach2", handleReach2)
 	http.HandleFunc("/network-health", handleNetworkHealth)
+	http.HandleFunc("/bias", handleBias)
 	http.HandleFunc("/compare-providers", handleCompareProviders)
 	http.HandleFunc("/trust-circle", handleTrustCircle)
 	http.HandleFunc("/trust-circle/compare", handleTrustCircleCompare)
@@ -3953,8 +6005,12 @@ func main() {
 	http.HandleFunc("/ws/scores", handleWebSocketInfo(wsHub))
 	http.HandleFunc("/openapi.json", handleOpenAPI)
 	http.HandleFunc("/docs", func(w http.ResponseWriter, r *http.Request) {
+		locale, ok := requestLocale(w, r)
+		if !ok {
+			return
+		}
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		fmt.Fprint(w, docsPageHTML)
+		fmt.Fprint(w, localizeHTML(docsPageHTML, locale))
 	})
 	http.HandleFunc("/swagger", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -3968,9 +6024,13 @@ func main() {
 		// Serve HTML for browsers, JSON for API clients
 		accept := r.Header.Get("Accept")
 		if strings.Contains(accept, "text/html") {
+			locale, ok := requestLocale(w, r)
+			if !ok {
+				return
+			}
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			stats := graph.Stats()
-			fmt.Fprintf(w, landingPageHTML,
+			fmt.Fprintf(w, localizeHTML(landingPageHTML, locale),
 				stats.Nodes, stats.Edges, events.EventCount(),
 				events.AddressableCount(), external.Count(),
 				time.Since(startTime).Truncate(time.Second))
@@ -3980,59 +6040,120 @@ func main() {
 		json.NewEncoder(w).Encode(map[string]string{
 			"name":        "WoT Scoring Service",
 			"description": "NIP-85 Trusted Assertions provider. PageRank trust scoring over the Nostr follow graph with full metadata collection.",
-			"endpoints": `/score?pubkey=<hex> — Trust score for a pubkey (kind 30382), with composite scoring from external providers
+			"endpoints": `/score?pubkey=<hex>[&algorithm=hits] — Trust score for a pubkey (kind 30382), with composite scoring from external providers
 /audit?pubkey=<hex> — Score audit: full breakdown of why a pubkey has its score (PageRank, engagement, external, percentile)
-/personalized?viewer=<hex>&target=<hex> — Personalized trust score relative to viewer's follow graph
+/personalized?viewer=<hex>&target=<hex>[&algorithm=ppr] — Personalized trust score relative to viewer's follow graph
+POST /personalized/import — Submit the viewer's signed kind 3 contact list; /personalized and /recommend use it for 24 hours
 POST /batch — Score multiple pubkeys in one request (JSON body: {"pubkeys":["hex1","hex2",...]})
+POST /audit/batch — Score audits for up to 50 pubkeys (JSON body: {"pubkeys":[...]})
+POST /personalized/batch — Personalized scores of up to 100 targets for one viewer (JSON body: {"viewer":"hex","targets":[...]})
+POST /graph/batch — Neighborhood graphs for up to 25 pubkeys (JSON body: {"pubkeys":[...],"depth":1,"limit":50})
+POST /rank — Rank up to 500 events for a feed by author WoT, engagement, recency and viewer (JSON body: {"events":[...],"viewer":"..."})
+POST /graphql — GraphQL query over scores, followers, communities, anomalies, spam and activity (GET for the schema)
 /similar?pubkey=<hex> — Find similar pubkeys by follow-graph overlap (Jaccard + WoT weighted)
 /recommend?pubkey=<hex> — Follow recommendations (friends-of-friends who you don't yet follow)
 /graph?from=<hex>&to=<hex> — Trust path finder (shortest connection between two pubkeys)
-/graph?pubkey=<hex>&depth=1 — Neighborhood graph (local follow network around a pubkey)
+/graph?pubkey=<hex>&depth=1 — Neighborhood graph (local follow network around a pubkey; format=graphml, gexf or dot for a graph file)
 /metadata?pubkey=<hex> — Full NIP-85 metadata (followers, posts, reactions, zaps)
+POST /metadata/batch — Metadata for up to 100 pubkeys in one request (JSON body: {"pubkeys":[...]})
 /event?id=<hex> — Event engagement score (kind 30383)
 /external?id=<identifier> — External identifier score (kind 30385, NIP-73)
 /external — Top 50 external identifiers (hashtags, URLs)
+/external/trending?window=24h — Trust-weighted trending hashtags and URLs (24h or 7d)
+/articles/top?topic=<tag> — Long-form articles ranked by author WoT and engagement
 /relay?url=<wss://...> — Relay trust + operator WoT (via trustedrelays.xyz)
+/relay/suggest?pubkey=<hex> — Relay suggestions from where trusted follows publish and read (NIP-65)
+/relay/top?limit=50 — Relays ranked by trust-weighted NIP-65 usage and NIP-11 operator WoT score
 /decay?pubkey=<hex> — Time-decayed trust score (newer follows weigh more, configurable half-life)
-/decay/top — Top pubkeys by decay-adjusted score with rank changes vs static
+/decay/top — Top pubkeys by decay-adjusted score with rank changes vs static and momentum
+/history?pubkey=<hex>&since= — Recorded score, rank and follower count at each rebuild
 /authorized — Kind 10040 authorized users (who declared trust in this provider)
 /authorized?pubkey=<hex> — Authorizations for a specific provider
 /communities — Top trust communities detected via label propagation
 /communities?pubkey=<hex> — Community membership and peers for a pubkey
+/communities/map — Follow edge weights between communities, with sizes, scores and bridges
 /nip05?id=user@domain — NIP-05 verification + WoT trust profile (resolves NIP-05 to pubkey, returns trust score)
 POST /nip05/batch — Bulk NIP-05 verification (up to 50 identifiers, concurrent resolution)
 /nip05/reverse?pubkey=<hex> — Reverse NIP-05 lookup (find NIP-05 identity from pubkey, verify bidirectionally)
+/domain?name=example.com — NIP-05 domain reputation (member scores, spam rate, checkmark advice)
 /providers — External NIP-85 assertion providers and their assertion counts
-/top — Top 50 scored pubkeys
-/export — All scores as JSON
+/providers/accuracy — How often each provider's claims agree with our graph, and its composite weight
+/top — Top 50 scored pubkeys (?format=ndjson|csv|parquet for table formats, ?view= for a seed view)
+/export — All scores as JSON, NDJSON, CSV or Parquet (?limit=&cursor= to paginate, gzip)
+/export/bloom?pubkey=<hex>&depth=2&fpr=0.01 — Bloom filter of a pubkey's N-hop follow set for offline WoT checks
+/snapshots — Retained score snapshots from past rebuilds (node/edge counts, top-10 hash)
+/snapshots/{id}/export — A snapshot's full score table, in the /export formats
 /stats — Service stats and graph info
-POST /publish — Publish NIP-85 kind 30382/30383/30384/30385 events to relays`,
+POST /publish — Publish NIP-85 kind 30382/30383/30384/30385 events to relays
+/publish/status — Publishing queue depth and per-relay success rates`,
 			"nip":      "85",
 			"operator": "max@klabo.world",
 			"source":   "https://github.com/joelklabo/wot-scoring",
 		})
 	})
 
-	// Rate limiter: 100 requests/minute per IP (free tier)
-	limiter := NewRateLimiter(100, time.Minute)
-	log.Printf("Rate limiting enabled: 100 req/min per IP")
+	// Rate limits: per IP, or per pubkey for NIP-42 authenticated callers
+	log.Printf("Rate limiting enabled (%s): %d req/min per IP, %d per authenticated pubkey, %d per trusted pubkey (score >= %d), %d endpoint limits",
+		rateTiers.Backend, rateTiers.Anonymous.limit, rateTiers.Authenticated.limit, rateTiers.Trusted.limit, rateTiers.TrustedMinScore, len(rateTiers.Endpoints))
 
-	// Build handler chain: CORS -> Rate Limit -> L402 -> handlers
-	var handler http.Handler = http.DefaultServeMux
+	// Build handler chain: CORS -> Rate Limit -> Graph Build -> L402 -> NIP-19 format -> Analytics -> handlers
+	var handler http.Handler = NIP19FormatMiddleware(AnalyticsMiddleware(analytics, http.DefaultServeMux))
 	if L402Enabled() {
 		l402 := NewL402FromEnv()
+		if err := l402.LoadTokenUses(); err != nil {
+			log.Printf("L402 token uses load failed: %v", err)
+		}
+		go l402.RunTokenUses(ctx)
 		handler = l402.Wrap(handler)
 		log.Printf("L402 paywall enabled: %d free requests/day per IP, paid via Lightning", l402.config.FreeTier)
 		http.HandleFunc("/pricing", func(w http.ResponseWriter, r *http.Request) {
 			handlePricing(w, r, l402)
 		})
+		http.HandleFunc("/l402/info", func(w http.ResponseWriter, r *http.Request) {
+			handleL402Info(w, r, l402)
+		})
+		http.HandleFunc("/account/topup", func(w http.ResponseWriter, r *http.Request) {
+			handleAccountTopup(w, r, l402)
+		})
+		http.HandleFunc("/account/usage", func(w http.ResponseWriter, r *http.Request) {
+			handleAccountUsage(w, r, l402)
+		})
+		// Zap credit is kept by the primary, which watches for receipts
+		if !graphSharing.Replica() {
+			go zapCredits.Watch(ctx)
+		}
 	} else {
 		log.Printf("L402 paywall disabled (set LNBITS_URL and LNBITS_KEY to enable)")
 		http.HandleFunc("/pricing", func(w http.ResponseWriter, r *http.Request) {
 			handlePricing(w, r, nil)
 		})
+		http.HandleFunc("/l402/info", func(w http.ResponseWriter, r *http.Request) {
+			handleL402Info(w, r, nil)
+		})
+		http.HandleFunc("/account/topup", func(w http.ResponseWriter, r *http.Request) {
+			handleAccountTopup(w, r, nil)
+		})
+		http.HandleFunc("/account/usage", func(w http.ResponseWriter, r *http.Request) {
+			handleAccountUsage(w, r, nil)
+		})
+	}
+
+	// Unchanged-data checks (If-None-Match, If-Modified-Since) are answered before the paywall
+	handler = GraphBuildMiddleware(graphBuild, handler)
+
+	// Read replicas turn writes away before they reach the paywall
+	handler = ReplicaMiddleware(graphSharing, handler)
+
+	// gRPC ScoreService for high-throughput clients, on its own port
+	if grpcPort := os.Getenv("GRPC_PORT"); grpcPort != "" {
+		go func() {
+			log.Printf("gRPC ScoreService listening on :%s", grpcPort)
+			log.Fatal(newGRPCServer(":"+grpcPort, os.Getenv("GRPC_TOKEN")).ListenAndServe())
+		}()
 	}
 
 	log.Printf("WoT Scoring API listening on :%s", port)
-	log.Fatal(http.ListenAndServe(":"+port, RateLimitMiddleware(limiter, corsMiddleware(handler))))
+	// Every error response, from any layer, leaves in the same {error, code, field} shape;
+	// /v1/... paths are the same endpoints, and JSON objects carry schema_version
+	log.Fatal(http.ListenAndServe(":"+port, APIVersionMiddleware(ErrorEnvelopeMiddleware(TieredRateLimitMiddleware(rateTiers, corsMiddleware(handler))))))
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestGraphGetFollowsAndFollowers(t *testing.T) {
@@ -31,6 +32,44 @@ func TestGraphGetFollowsAndFollowers(t *testing.T) {
 	}
 }
 
+func TestGraphSetFollowsReplaceableSemantics(t *testing.T) {
+	g := NewGraph()
+	t1 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	if added, _, ok := g.SetFollows("alice", []string{"bob", "carol", "bob", "alice"}, t1); !ok || len(added) != 2 {
+		t.Fatalf("expected first list applied with 2 follows, got %v %v", added, ok)
+	}
+	// a re-crawl of the same list must not double-count edges, or invalidate the snapshot
+	s := g.Snapshot()
+	if _, _, ok := g.SetFollows("alice", []string{"bob", "carol"}, t1); ok {
+		t.Error("expected the same list to be ignored")
+	}
+	if g.Snapshot() != s {
+		t.Error("expected an ignored list to keep the snapshot")
+	}
+	if len(g.GetFollowers("bob")) != 1 || g.Stats().Edges != 2 {
+		t.Errorf("expected 2 edges, got %d", g.Stats().Edges)
+	}
+
+	added, removed, ok := g.SetFollows("alice", []string{"carol", "dave"}, t2)
+	if !ok || len(added) != 1 || added[0] != "dave" || len(removed) != 1 || removed[0] != "bob" {
+		t.Fatalf("expected dave added and bob removed, got %v %v %v", added, removed, ok)
+	}
+	if len(g.GetFollowers("bob")) != 0 || len(g.GetFollowers("dave")) != 1 {
+		t.Error("expected followers maps updated")
+	}
+	if !g.GetFollowTime("alice", "carol").Equal(t1) || !g.GetFollowTime("alice", "dave").Equal(t2) {
+		t.Error("expected kept follows to keep their original follow time")
+	}
+
+	// an older list arriving late does not roll the graph back, even after unfollowing everyone
+	g.SetFollows("alice", nil, t2.Add(time.Hour))
+	if _, _, ok := g.SetFollows("alice", []string{"bob"}, t2); ok || len(g.GetFollows("alice")) != 0 {
+		t.Error("expected an older list to be ignored")
+	}
+}
+
 func TestHandleBatch(t *testing.T) {
 	// Set up graph with known scores
 	oldGraph := graph
@@ -860,15 +899,15 @@ func TestDocsPageReturnsHTML(t *testing.T) {
 
 func TestDocsPageContainsAllEndpoints(t *testing.T) {
 	endpoints := []string{
-		"/score", "/audit", "/batch", "/personalized", "/similar",
+		"/score", "/audit", "/batch", "/audit/batch", "/personalized/batch", "/graph/batch", "/rank", "/graphql", "/personalized", "/personalized/import", "/similar",
 		"/recommend", "/compare", "/graph", "/weboftrust",
-		"/nip05", "/nip05/batch", "/nip05/reverse",
-		"/timeline", "/decay", "/decay/top",
-		"/spam", "/spam/batch", "/verify", "/anomalies",
-		"/sybil", "/sybil/batch", "/trust-path", "/reputation", "/predict", "/influence", "/influence/batch", "/network-health", "/compare-providers", "/ws/scores",
-		"/metadata", "/event", "/external",
-		"/top", "/export", "/relay", "/authorized", "/communities",
-		"/publish", "/providers", "/stats", "/health",
+		"/nip05", "/nip05/batch", "/nip05/reverse", "/domain",
+		"/timeline", "/history", "/contacts/snapshot", "/active", "/growth-sources", "/churn", "/decay", "/decay/top",
+		"/spam", "/spam/batch", "/spam/event", "/reports", "/migrations", "/verify", "/attestation", "/challenge", "/challenge/verify", "/anomalies", "/zap-score", "/report-gaming",
+		"/sybil", "/sybil/batch", "/org-score", "/trust-path", "/reputation", "/predict", "/influence", "/influence/batch", "/simulate", "/reach2", "/network-health", "/bias", "/compare-providers", "/ws/scores",
+		"/metadata", "/metadata/batch", "/event", "/external", "/external/trending", "/articles/top",
+		"/top", "/export", "/export/bloom", "/snapshots", "/snapshots/{id}/export", "/relay", "/relay/suggest", "/relay/top", "/authorized", "/communities", "/bridges", "/communities/map",
+		"/publish", "/publish/status", "/providers", "/providers/accuracy", "/stats", "/health", "/crawl/status", "/crawl", "/hint", "/ingest", "/sandbox/score",
 	}
 	for _, ep := range endpoints {
 		if !bytes.Contains([]byte(docsPageHTML), []byte(ep)) {
@@ -876,3 +915,78 @@ func TestDocsPageContainsAllEndpoints(t *testing.T) {
 		}
 	}
 }
+
+func TestMetadataBatchEndpoint(t *testing.T) {
+	oldGraph := graph
+	oldMeta := meta
+	defer func() {
+		graph = oldGraph
+		meta = oldMeta
+	}()
+
+	graph = NewGraph()
+	meta = NewMetaStore()
+
+	alice := padHex(1)
+	bob := padHex(2)
+	graph.AddFollow(alice, bob)
+	graph.AddFollow(bob, alice)
+	graph.ComputePageRank(20, 0.85)
+	meta.Get(alice).PostCount = 7
+
+	body := `{"pubkeys":["` + alice + `","` + padHex(3) + `","npub1invalid"]}`
+	req := httptest.NewRequest(http.MethodPost, "/metadata/batch", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handleMetadataBatch(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Results []map[string]interface{} `json:"results"`
+		Count   int                      `json:"count"`
+		Found   int                      `json:"found"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.Count != 3 || len(resp.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", resp.Count)
+	}
+	if resp.Found != 1 {
+		t.Errorf("expected 1 found, got %d", resp.Found)
+	}
+	if resp.Results[0]["post_cnt"].(float64) != 7 {
+		t.Errorf("expected post_cnt 7 for alice, got %v", resp.Results[0]["post_cnt"])
+	}
+	if resp.Results[1]["found"] != false {
+		t.Errorf("expected unknown pubkey to be not found")
+	}
+	if _, ok := resp.Results[2]["error"]; !ok {
+		t.Errorf("expected error entry for invalid pubkey, got %v", resp.Results[2])
+	}
+}
+
+func TestMetadataBatchValidation(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/metadata/batch", nil)
+	w := httptest.NewRecorder()
+	handleMetadataBatch(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", w.Code)
+	}
+
+	pubkeys := make([]string, 101)
+	for i := range pubkeys {
+		pubkeys[i] = padHex(i)
+	}
+	payload, _ := json.Marshal(map[string]interface{}{"pubkeys": pubkeys})
+	for _, body := range []string{`{"pubkeys":[]}`, `not json`, string(payload)} {
+		req := httptest.NewRequest(http.MethodPost, "/metadata/batch", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handleMetadataBatch(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %.40s, got %d", body, w.Code)
+		}
+	}
+}
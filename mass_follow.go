@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MassFollowPolicy configures how accounts that follow abnormally many pubkeys,
+// or add them in bursts (follow-list apps, follow-back bots), are detected and
+// dampened in scoring. Dampened follows pass on less of the follower's score;
+// the rest is lost rather than moved to its other follows, so a mass follower
+// neither concentrates its vote nor opens thousands of full-strength spam paths.
+type MassFollowPolicy struct {
+	Enabled      bool    `json:"enabled"`
+	MaxDegree    int     `json:"max_degree"`    // out-degree above which an account passes on max_degree/follows of its vote
+	BurstFollows int     `json:"burst_follows"` // follows added in one day, after the first contact list seen, that make a burst
+	BurstWeight  float64 `json:"burst_weight"`  // what a follow added in a burst passes on, relative to an ordinary one
+}
+
+// defaultMassFollowPolicy leaves ordinary follow lists alone: most accounts follow
+// well under a few thousand pubkeys and add them a handful at a time.
+var defaultMassFollowPolicy = MassFollowPolicy{Enabled: true, MaxDegree: 5000, BurstFollows: 500, BurstWeight: 0.25}
+
+// NewMassFollowPolicyFromEnv turns dampening off when MASS_FOLLOW_DAMPENING=off.
+// MASS_FOLLOW_MAX_DEGREE, MASS_FOLLOW_BURST and MASS_FOLLOW_BURST_WEIGHT (0-1)
+// override the defaults.
+func NewMassFollowPolicyFromEnv() MassFollowPolicy {
+	p := defaultMassFollowPolicy
+	p.Enabled = os.Getenv("MASS_FOLLOW_DAMPENING") != "off"
+	for env, field := range map[string]*int{
+		"MASS_FOLLOW_MAX_DEGREE": &p.MaxDegree,
+		"MASS_FOLLOW_BURST":      &p.BurstFollows,
+	} {
+		if v := os.Getenv(env); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 {
+				*field = n
+			} else {
+				log.Printf("Ignoring %s=%q: want a positive integer", env, v)
+			}
+		}
+	}
+	if v := os.Getenv("MASS_FOLLOW_BURST_WEIGHT"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+			p.BurstWeight = f
+		} else {
+			log.Printf("Ignoring MASS_FOLLOW_BURST_WEIGHT=%q: want a number from 0 to 1", v)
+		}
+	}
+	return p
+}
+
+var massFollowPolicy = NewMassFollowPolicyFromEnv()
+
+// FollowBurst is one day on which an account added an abnormal number of follows.
+type FollowBurst struct {
+	Day     string `json:"day"`
+	Follows int    `json:"follows"`
+}
+
+// MassFollower is an account whose follows are dampened, and why.
+type MassFollower struct {
+	Pubkey       string        `json:"pubkey"`
+	Follows      int           `json:"follows"`
+	DegreeWeight float64       `json:"degree_weight"` // share of its vote its follows pass on; 1 below max_degree
+	BurstFollows int           `json:"burst_follows"`
+	Bursts       []FollowBurst `json:"bursts,omitempty"`
+}
+
+type massFollowEntry struct {
+	MassFollower
+	burst map[string]bool // follows added in a burst
+}
+
+// MassFollowStore holds the accounts flagged by the last detection pass. The map
+// is replaced, never changed in place, so PageRank can read it without locking.
+type MassFollowStore struct {
+	mu         sync.RWMutex
+	policy     MassFollowPolicy
+	flagged    map[string]*massFollowEntry
+	detectedAt time.Time
+}
+
+var massFollows = &MassFollowStore{}
+
+// Detect flags g's mass followers under p, replacing the previous pass. Follow
+// bursts are read from follow times: every follow carries the created_at of the
+// contact list it first appeared in, so a day that brought at least
+// p.BurstFollows new follows is a burst, except the account's first day, when
+// the whole list arrives at once. It returns how many accounts were flagged.
+func (s *MassFollowStore) Detect(g *Graph, p MassFollowPolicy) int {
+	flagged := make(map[string]*massFollowEntry)
+	if p.Enabled {
+		g.mu.RLock()
+		for author, follows := range g.follows {
+			e := &massFollowEntry{MassFollower: MassFollower{Pubkey: author, Follows: len(follows), DegreeWeight: 1}}
+			if p.MaxDegree > 0 && len(follows) > p.MaxDegree {
+				e.DegreeWeight = float64(p.MaxDegree) / float64(len(follows))
+			}
+			if p.BurstFollows > 0 && len(follows) >= p.BurstFollows {
+				e.burst = followBursts(g, author, follows, p.BurstFollows, &e.Bursts)
+				e.BurstFollows = len(e.burst)
+			}
+			if e.DegreeWeight < 1 || e.BurstFollows > 0 {
+				flagged[author] = e
+			}
+		}
+		g.mu.RUnlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = p
+	s.flagged = flagged
+	s.detectedAt = time.Now()
+	return len(flagged)
+}
+
+// followBursts returns the follows author added on burst days, recording the
+// days in bursts. Callers hold g.mu.
+func followBursts(g *Graph, author string, follows []string, threshold int, bursts *[]FollowBurst) map[string]bool {
+	byDay := make(map[string][]string)
+	for _, to := range follows {
+		if t := g.followTimes[author+":"+to]; !t.IsZero() {
+			day := t.UTC().Format("2006-01-02")
+			byDay[day] = append(byDay[day], to)
+		}
+	}
+	days := make([]string, 0, len(byDay))
+	for d := range byDay {
+		days = append(days, d)
+	}
+	sort.Strings(days)
+	var burst map[string]bool
+	for _, d := range days[min(1, len(days)):] {
+		if len(byDay[d]) < threshold {
+			continue
+		}
+		if burst == nil {
+			burst = make(map[string]bool)
+		}
+		for _, to := range byDay[d] {
+			burst[to] = true
+		}
+		*bursts = append(*bursts, FollowBurst{Day: d, Follows: len(byDay[d])})
+	}
+	return burst
+}
+
+// Get returns pubkey's entry from the last detection pass.
+func (s *MassFollowStore) Get(pubkey string) (MassFollower, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if e, ok := s.flagged[pubkey]; ok {
+		return e.MassFollower, true
+	}
+	return MassFollower{}, false
+}
+
+// factor returns the weight multiplier for follows, or nil when nothing is
+// dampened.
+func (s *MassFollowStore) factor() func(from, to string) float64 {
+	s.mu.RLock()
+	flagged, weight := s.flagged, s.policy.BurstWeight
+	s.mu.RUnlock()
+	if len(flagged) == 0 {
+		return nil
+	}
+	return func(from, to string) float64 {
+		e, ok := flagged[from]
+		if !ok {
+			return 1
+		}
+		if e.burst[to] {
+			return e.DegreeWeight * weight
+		}
+		return e.DegreeWeight
+	}
+}
+
+// Stats summarizes the policy and the last detection pass for /stats.
+func (s *MassFollowStore) Stats() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	degree, bursting, burstEdges := 0, 0, 0
+	for _, e := range s.flagged {
+		if e.DegreeWeight < 1 {
+			degree++
+		}
+		if e.BurstFollows > 0 {
+			bursting++
+			burstEdges += e.BurstFollows
+		}
+	}
+	stats := map[string]interface{}{
+		"policy":          s.policy,
+		"flagged":         len(s.flagged),
+		"high_out_degree": degree,
+		"follow_bursts":   bursting,
+		"burst_follows":   burstEdges,
+	}
+	if !s.detectedAt.IsZero() {
+		stats["detected_at"] = s.detectedAt.UTC().Format(time.RFC3339)
+	}
+	return stats
+}
+
+// massFollowAnomalies flags pubkey's follow behavior for /anomalies.
+func massFollowAnomalies(m MassFollower, p MassFollowPolicy) []AnomalyFlag {
+	var flags []AnomalyFlag
+	if m.DegreeWeight < 1 {
+		severity := "medium"
+		if m.Follows >= 10*p.MaxDegree {
+			severity = "high"
+		}
+		flags = append(flags, AnomalyFlag{
+			Type:        "mass_following",
+			Severity:    severity,
+			Description: fmt.Sprintf("Follows %d accounts; each follow passes on %.1f%% of the usual trust", m.Follows, m.DegreeWeight*100),
+			Value:       float64(m.Follows),
+			Threshold:   float64(p.MaxDegree),
+		})
+	}
+	if m.BurstFollows > 0 {
+		days := make([]string, len(m.Bursts))
+		for i, b := range m.Bursts {
+			days[i] = b.Day
+		}
+		severity := "medium"
+		if m.BurstFollows*2 >= m.Follows {
+			severity = "high"
+		}
+		flags = append(flags, AnomalyFlag{
+			Type:        "follow_burst",
+			Severity:    severity,
+			Description: fmt.Sprintf("Added %d follows in burst(s) on %s — typical of follow-list apps and follow-back bots; those follows are dampened", m.BurstFollows, strings.Join(days, ", ")),
+			Value:       float64(m.BurstFollows),
+			Threshold:   float64(p.BurstFollows),
+		})
+	}
+	return flags
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testMassFollowPolicy = MassFollowPolicy{Enabled: true, MaxDegree: 10, BurstFollows: 5, BurstWeight: 0.25}
+
+func useMassFollowStore(t *testing.T) {
+	oldStore, oldPolicy := massFollows, massFollowPolicy
+	t.Cleanup(func() { massFollows, massFollowPolicy = oldStore, oldPolicy })
+	massFollows = &MassFollowStore{}
+	massFollowPolicy = testMassFollowPolicy
+}
+
+func TestNewMassFollowPolicyFromEnv(t *testing.T) {
+	t.Setenv("MASS_FOLLOW_MAX_DEGREE", "3000")
+	t.Setenv("MASS_FOLLOW_BURST", "12abc")
+	t.Setenv("MASS_FOLLOW_BURST_WEIGHT", "0.5x")
+	p := NewMassFollowPolicyFromEnv()
+	if p.MaxDegree != 3000 {
+		t.Errorf("expected MASS_FOLLOW_MAX_DEGREE applied, got %d", p.MaxDegree)
+	}
+	if p.BurstFollows != defaultMassFollowPolicy.BurstFollows || p.BurstWeight != defaultMassFollowPolicy.BurstWeight {
+		t.Errorf("expected malformed values ignored, got %+v", p)
+	}
+}
+
+func TestMassFollowDetect(t *testing.T) {
+	useMassFollowStore(t)
+	wide, bursty, normal := padHex(49400), padHex(49401), padHex(49402)
+	var targets []string
+	for i := 0; i < 20; i++ {
+		targets = append(targets, padHex(49410+i))
+	}
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	g := NewGraph()
+	g.SetFollows(wide, targets, day)
+	// the first list arrives whole; six more follows a week later are a burst,
+	// a single one after that isn't
+	g.SetFollows(bursty, targets[:5], day)
+	g.SetFollows(bursty, targets[:11], day.AddDate(0, 0, 7))
+	g.SetFollows(bursty, targets[:12], day.AddDate(0, 0, 8))
+	g.SetFollows(normal, targets[:8], day)
+
+	if n := massFollows.Detect(g, testMassFollowPolicy); n != 2 {
+		t.Fatalf("expected 2 accounts flagged, got %d", n)
+	}
+	m, ok := massFollows.Get(wide)
+	if !ok || m.DegreeWeight != 0.5 || m.BurstFollows != 0 {
+		t.Errorf("expected the wide account's follows halved, got %+v", m)
+	}
+	m, ok = massFollows.Get(bursty)
+	if !ok || m.DegreeWeight != 10.0/12 || m.BurstFollows != 6 || len(m.Bursts) != 1 || m.Bursts[0].Day != "2026-03-08" {
+		t.Errorf("expected one 6-follow burst, got %+v", m)
+	}
+	if _, ok := massFollows.Get(normal); ok {
+		t.Errorf("expected an ordinary account left alone")
+	}
+
+	factor := massFollows.factor()
+	if f := factor(bursty, targets[0]); f != 10.0/12 {
+		t.Errorf("expected a follow from the first list at the degree weight, got %v", f)
+	}
+	if f := factor(bursty, targets[5]); f != 10.0/12*0.25 {
+		t.Errorf("expected a burst follow further dampened, got %v", f)
+	}
+	if f := factor(normal, targets[0]); f != 1 {
+		t.Errorf("expected an ordinary follow undampened, got %v", f)
+	}
+
+	stats := massFollows.Stats()
+	if stats["flagged"] != 2 || stats["high_out_degree"] != 2 || stats["follow_bursts"] != 1 || stats["burst_follows"] != 6 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	off := testMassFollowPolicy
+	off.Enabled = false
+	if n := massFollows.Detect(g, off); n != 0 || massFollows.factor() != nil {
+		t.Errorf("expected nothing flagged when disabled, got %d", n)
+	}
+}
+
+func TestMassFollowDampensPageRank(t *testing.T) {
+	useMassFollowStore(t)
+	spammer, target, friend := padHex(49430), padHex(49431), padHex(49432)
+	g := NewGraph()
+	for i := 0; i < 40; i++ {
+		g.AddFollow(spammer, padHex(49440+i))
+		g.AddFollow(padHex(49440+i), spammer)
+	}
+	g.AddFollow(spammer, target)
+	g.AddFollow(friend, target)
+	g.AddFollow(target, friend)
+
+	g.ComputePageRank(50, 0.85)
+	before, _ := g.GetScore(target)
+	friendBefore, _ := g.GetScore(friend)
+
+	massFollows.Detect(g, testMassFollowPolicy)
+	g.ComputePageRank(50, 0.85)
+	after, _ := g.GetScore(target)
+	friendAfter, _ := g.GetScore(friend)
+	if after >= before {
+		t.Errorf("expected the mass follower's vote to count less, got %v -> %v", before, after)
+	}
+	if friendAfter >= friendBefore {
+		t.Errorf("expected the lost share not to move elsewhere, got %v -> %v", friendBefore, friendAfter)
+	}
+}
+
+func TestAnomaliesMassFollowing(t *testing.T) {
+	useMassFollowStore(t)
+	oldGraph := graph
+	t.Cleanup(func() { graph = oldGraph })
+	graph = NewGraph()
+	bursty := padHex(49403)
+	var targets []string
+	for i := 0; i < 12; i++ {
+		targets = append(targets, padHex(49480+i))
+	}
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	graph.SetFollows(bursty, targets[:2], day)
+	graph.SetFollows(bursty, targets, day.AddDate(0, 1, 0))
+	massFollows.Detect(graph, massFollowPolicy)
+	graph.ComputePageRank(20, 0.85)
+
+	w := httptest.NewRecorder()
+	handleAnomalies(w, httptest.NewRequest("GET", "/anomalies?pubkey="+bursty, nil))
+	var resp AnomaliesResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	types := make(map[string]string)
+	for _, a := range resp.Anomalies {
+		types[a.Type] = a.Severity
+	}
+	if types["mass_following"] != "medium" || types["follow_burst"] != "high" {
+		t.Errorf("expected mass_following and a high follow_burst flag, got %+v", resp.Anomalies)
+	}
+	if resp.MassFollow == nil || resp.MassFollow.BurstFollows != 10 {
+		t.Errorf("expected the dampening in the response, got %+v", resp.MassFollow)
+	}
+}
@@ -13,20 +13,25 @@ import (
 
 // PubkeyMeta holds NIP-85 metadata collected for a single pubkey.
 type PubkeyMeta struct {
-	Followers     int            // number of kind 3 lists that include this pubkey
-	PostCount     int            // kind 1 notes (not replies)
-	ReplyCount    int            // kind 1 notes that are replies (have "e" tag)
-	ReactionsSent int            // kind 7 reactions sent by this pubkey
-	ReactionsRecd int            // kind 7 reactions received by this pubkey
-	ZapAmtRecd    int64          // sats received via kind 9735 zap receipts
-	ZapAmtSent    int64          // sats sent via kind 9735 zap receipts
-	ZapCntRecd    int            // number of zap receipts received
-	ZapCntSent    int            // number of zap receipts sent
-	FirstCreated  int64          // earliest known event timestamp (unix)
-	Topics        map[string]int // hashtag -> count from notes
-	HourBuckets   [24]int        // event count per UTC hour (0-23)
-	ReportsRecd   int            // kind 1984 reports received
-	ReportsSent   int            // kind 1984 reports sent
+	Followers          int            // number of kind 3 lists that include this pubkey
+	PostCount          int            // kind 1 notes (not replies)
+	ReplyCount         int            // kind 1 notes that are replies (have "e" tag)
+	ReactionsSent      int            // kind 7 reactions sent by this pubkey
+	ReactionsRecd      int            // kind 7 reactions received by this pubkey
+	ZapAmtRecd         int64          // sats received via kind 9735 zap receipts
+	ZapAmtSent         int64          // sats sent via kind 9735 zap receipts
+	ZapCntRecd         int            // number of zap receipts received
+	ZapCntSent         int            // number of zap receipts sent
+	VerifiedZapAmtRecd int64          // sats received via zap receipts that passed NIP-57 verification
+	VerifiedZapCntRecd int            // number of verified zap receipts received
+	FirstCreated       int64          // earliest known event timestamp (unix)
+	LastCreated        int64          // latest known authored event timestamp (unix)
+	NoteTimes          []int64        // most recent kind 1 timestamps, newest first (bounded sample)
+	Topics             map[string]int // hashtag -> count from notes
+	HourBuckets        [24]int        // event count per UTC hour (0-23)
+	WeekdayBuckets     [7]int         // note count per UTC weekday (Sunday = 0)
+	ReportsRecd        int            // kind 1984 reports received
+	ReportsSent        int            // kind 1984 reports sent
 }
 
 // MetaStore holds metadata for all crawled pubkeys.
@@ -56,6 +61,98 @@ func (ms *MetaStore) Set(pubkey string, meta *PubkeyMeta) {
 	ms.data[pubkey] = meta
 }
 
+// maxNoteTimes bounds the per-pubkey note timestamp sample used for posting cadence.
+const maxNoteTimes = 50
+
+// recordActivity advances LastCreated. Callers hold ms.mu.
+func (m *PubkeyMeta) recordActivity(ts int64) {
+	if ts > m.LastCreated {
+		m.LastCreated = ts
+	}
+}
+
+// recordNoteTime adds a note timestamp to the newest-first sample, ignoring
+// duplicates from re-crawls. Callers hold ms.mu.
+func (m *PubkeyMeta) recordNoteTime(ts int64) {
+	i := sort.Search(len(m.NoteTimes), func(i int) bool { return m.NoteTimes[i] <= ts })
+	if i < len(m.NoteTimes) && m.NoteTimes[i] == ts {
+		return
+	}
+	if i >= maxNoteTimes {
+		return
+	}
+	m.NoteTimes = append(m.NoteTimes, 0)
+	copy(m.NoteTimes[i+1:], m.NoteTimes[i:])
+	m.NoteTimes[i] = ts
+	if len(m.NoteTimes) > maxNoteTimes {
+		m.NoteTimes = m.NoteTimes[:maxNoteTimes]
+	}
+}
+
+// Activity returns the latest authored event timestamp and a copy of the note
+// timestamp sample for pubkey.
+func (ms *MetaStore) Activity(pubkey string) (last int64, noteTimes []int64) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	m, ok := ms.data[pubkey]
+	if !ok {
+		return 0, nil
+	}
+	return m.LastCreated, append([]int64(nil), m.NoteTimes...)
+}
+
+// Cadence returns pubkey's posting histograms and a copy of its note timestamp
+// sample. Unlike Get it doesn't create an entry for unknown pubkeys.
+func (ms *MetaStore) Cadence(pubkey string) (hours [24]int, weekdays [7]int, noteTimes []int64) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	m, ok := ms.data[pubkey]
+	if !ok {
+		return hours, weekdays, nil
+	}
+	return m.HourBuckets, m.WeekdayBuckets, append([]int64(nil), m.NoteTimes...)
+}
+
+// FirstCreated returns the earliest known event timestamp for pubkey, or 0.
+// Unlike Get it doesn't create an entry for unknown pubkeys.
+func (ms *MetaStore) FirstCreated(pubkey string) int64 {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if m, ok := ms.data[pubkey]; ok {
+		return m.FirstCreated
+	}
+	return 0
+}
+
+// LastCreated returns the latest authored event timestamp for pubkey, or 0.
+// Unlike Get it doesn't create an entry for unknown pubkeys.
+func (ms *MetaStore) LastCreated(pubkey string) int64 {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if m, ok := ms.data[pubkey]; ok {
+		return m.LastCreated
+	}
+	return 0
+}
+
+// MatchTopics returns the topics, among those given, that pubkey has posted
+// hashtags for. Unlike Get it doesn't create an entry for unknown pubkeys.
+func (ms *MetaStore) MatchTopics(pubkey string, topics []string) []string {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	m, ok := ms.data[pubkey]
+	if !ok {
+		return nil
+	}
+	var matched []string
+	for _, t := range topics {
+		if m.Topics[t] > 0 {
+			matched = append(matched, t)
+		}
+	}
+	return matched
+}
+
 // CountFollowers populates the Followers field from the follow graph.
 func (ms *MetaStore) CountFollowers(g *Graph) {
 	g.mu.RLock()
@@ -82,8 +179,8 @@ func (ms *MetaStore) CrawlMetadata(ctx context.Context, pubkeys []string) {
 	}
 	pool := nostr.NewSimplePool(ctx)
 
-	// Crawl notes and reactions in batches
-	batchSize := 100
+	// Crawl notes and reactions in batches (notes ask for the most, 20 per author)
+	batchSize := crawlBatchSize(100, 20)
 	for i := 0; i < len(pubkeys); i += batchSize {
 		end := i + batchSize
 		if end > len(pubkeys) {
@@ -101,6 +198,11 @@ func (ms *MetaStore) CrawlMetadata(ctx context.Context, pubkeys []string) {
 		}
 	}
 
+	// Receipts from LNURL providers not looked up yet are verified now
+	for _, z := range zapVerifier.Resolve(ctx) {
+		ms.creditVerifiedZap(z.Recipient, z.Sats)
+	}
+
 	log.Printf("Metadata crawl complete for %d pubkeys", len(pubkeys))
 }
 
@@ -113,7 +215,7 @@ func (ms *MetaStore) crawlNotes(ctx context.Context, pool *nostr.SimplePool, pub
 		Limit:   len(pubkeys) * 20, // sample up to 20 notes per author
 	}
 
-	evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+	evCh := pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter})
 	for ev := range evCh {
 		m := ms.Get(ev.Event.PubKey)
 
@@ -123,10 +225,13 @@ func (ms *MetaStore) crawlNotes(ctx context.Context, pool *nostr.SimplePool, pub
 		if m.FirstCreated == 0 || ts < m.FirstCreated {
 			m.FirstCreated = ts
 		}
+		m.recordActivity(ts)
+		m.recordNoteTime(ts)
 
-		// Track activity hour (UTC)
-		hour := time.Unix(ts, 0).UTC().Hour()
-		m.HourBuckets[hour]++
+		// Track activity hour and weekday (UTC)
+		at := time.Unix(ts, 0).UTC()
+		m.HourBuckets[at.Hour()]++
+		m.WeekdayBuckets[at.Weekday()]++
 		ms.mu.Unlock()
 
 		// Classify: reply if it has an "e" tag (referencing another event)
@@ -156,6 +261,12 @@ func (ms *MetaStore) crawlNotes(ctx context.Context, pool *nostr.SimplePool, pub
 			m.PostCount++
 		}
 		ms.mu.Unlock()
+		if isReply {
+			interactions.Record(ev.Event)
+		}
+		if delegator, conditions, ok := parseDelegation(ev.Event); ok {
+			migrations.RecordDelegation(delegator, ev.Event.PubKey, conditions, ev.Event.ID, ts)
+		}
 	}
 }
 
@@ -168,24 +279,32 @@ func (ms *MetaStore) crawlReactions(ctx context.Context, pool *nostr.SimplePool,
 		Limit:   len(pubkeys) * 10,
 	}
 
-	evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+	evCh := pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter})
 	for ev := range evCh {
-		m := ms.Get(ev.Event.PubKey)
-		ms.mu.Lock()
-		m.ReactionsSent++
-		ms.mu.Unlock()
+		ms.recordReaction(ev.Event)
+	}
+}
 
-		// Also count as received by the "p" tagged pubkey
-		for _, tag := range ev.Event.Tags {
-			if tag[0] == "p" && len(tag) >= 2 {
-				target := ms.Get(tag[1])
-				ms.mu.Lock()
-				target.ReactionsRecd++
-				ms.mu.Unlock()
-				break // count first p-tag as the reaction target
-			}
+// recordReaction counts a kind 7 reaction as sent by its author and received by
+// its first p-tagged pubkey.
+func (ms *MetaStore) recordReaction(ev *nostr.Event) {
+	m := ms.Get(ev.PubKey)
+	ms.mu.Lock()
+	m.ReactionsSent++
+	m.recordActivity(int64(ev.CreatedAt))
+	ms.mu.Unlock()
+
+	// Also count as received by the "p" tagged pubkey
+	for _, tag := range ev.Tags {
+		if tag[0] == "p" && len(tag) >= 2 {
+			target := ms.Get(tag[1])
+			ms.mu.Lock()
+			target.ReactionsRecd++
+			ms.mu.Unlock()
+			break // count first p-tag as the reaction target
 		}
 	}
+	interactions.Record(ev)
 }
 
 // crawlZaps fetches kind 9735 zap receipt events.
@@ -199,30 +318,49 @@ func (ms *MetaStore) crawlZaps(ctx context.Context, pool *nostr.SimplePool, pubk
 		Limit: len(pubkeys) * 5,
 	}
 
-	evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+	evCh := pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter})
 	for ev := range evCh {
-		amount := extractZapAmount(ev.Event)
-		if amount <= 0 {
-			continue
-		}
+		ms.recordZap(ev.Event)
+	}
+}
 
-		// Find recipient (p-tag) and sender (from bolt11 or description)
-		for _, tag := range ev.Event.Tags {
-			if tag[0] == "p" && len(tag) >= 2 {
-				recipient := ms.Get(tag[1])
-				ms.mu.Lock()
-				recipient.ZapAmtRecd += amount
-				recipient.ZapCntRecd++
-				ms.mu.Unlock()
-				break
-			}
+// recordZap credits a kind 9735 zap receipt's amount to its p-tagged recipient and
+// records the sender -> recipient flow.
+func (ms *MetaStore) recordZap(ev *nostr.Event) {
+	amount := extractZapAmount(ev)
+	if amount <= 0 {
+		return
+	}
+
+	// Find recipient (p-tag) and sender (from bolt11 or description)
+	for _, tag := range ev.Tags {
+		if tag[0] == "p" && len(tag) >= 2 {
+			recipient := ms.Get(tag[1])
+			ms.mu.Lock()
+			recipient.ZapAmtRecd += amount
+			recipient.ZapCntRecd++
+			ms.mu.Unlock()
+			break
 		}
+	}
 
-		// The sender is typically in the "description" tag's event JSON,
-		// but parsing that is complex. We skip sender attribution for now.
+	// Sender -> recipient flows feed zap scoring and wash-trading detection
+	zapStore.Record(ev)
+
+	if zapVerifier.Record(ev) == zapVerified {
+		ms.creditVerifiedZap(ev.Tags.Find("p")[1], amount)
 	}
 }
 
+// creditVerifiedZap adds a receipt that passed NIP-57 verification to its recipient.
+func (ms *MetaStore) creditVerifiedZap(recipient string, sats int64) {
+	m := ms.Get(recipient)
+	ms.mu.Lock()
+	m.VerifiedZapAmtRecd += sats
+	m.VerifiedZapCntRecd++
+	ms.mu.Unlock()
+}
+
 // crawlReports fetches kind 1984 report events to count reports sent and received.
 func (ms *MetaStore) crawlReports(ctx context.Context, pool *nostr.SimplePool, pubkeys []string) {
 	// Reports SENT by these pubkeys
@@ -232,22 +370,31 @@ func (ms *MetaStore) crawlReports(ctx context.Context, pool *nostr.SimplePool, p
 		Limit:   len(pubkeys) * 5,
 	}
 
-	evCh := pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+	evCh := pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter})
 	for ev := range evCh {
-		m := ms.Get(ev.Event.PubKey)
-		ms.mu.Lock()
-		m.ReportsSent++
-		ms.mu.Unlock()
+		ms.recordReport(ev.Event)
+	}
+}
 
-		// Count as received by the p-tagged pubkey
-		for _, tag := range ev.Event.Tags {
-			if tag[0] == "p" && len(tag) >= 2 {
-				target := ms.Get(tag[1])
-				ms.mu.Lock()
-				target.ReportsRecd++
-				ms.mu.Unlock()
-				break
-			}
+// recordReport counts a kind 1984 report as sent by its author and received by
+// its first p-tagged pubkey.
+func (ms *MetaStore) recordReport(ev *nostr.Event) {
+	// Reporter -> target pairs feed distrust propagation
+	reportStore.Record(ev)
+
+	m := ms.Get(ev.PubKey)
+	ms.mu.Lock()
+	m.ReportsSent++
+	ms.mu.Unlock()
+
+	// Count as received by the p-tagged pubkey
+	for _, tag := range ev.Tags {
+		if tag[0] == "p" && len(tag) >= 2 {
+			target := ms.Get(tag[1])
+			ms.mu.Lock()
+			target.ReportsRecd++
+			ms.mu.Unlock()
+			break
 		}
 	}
 }
@@ -320,9 +467,14 @@ func extractZapAmount(ev *nostr.Event) int64 {
 	return 0
 }
 
-// decodeBolt11Amount extracts millisats from a BOLT11 invoice string and converts to sats.
-// BOLT11 format: lnbc<amount><multiplier>1... where multiplier is m(milli), u(micro), n(nano), p(pico)
+// decodeBolt11Amount extracts the amount from a BOLT11 invoice string in sats.
 func decodeBolt11Amount(invoice string) int64 {
+	return decodeBolt11Msats(invoice) / 1000
+}
+
+// decodeBolt11Msats extracts millisats from a BOLT11 invoice string.
+// BOLT11 format: lnbc<amount><multiplier>1... where multiplier is m(milli), u(micro), n(nano), p(pico)
+func decodeBolt11Msats(invoice string) int64 {
 	if len(invoice) < 6 {
 		return 0
 	}
@@ -368,8 +520,7 @@ func decodeBolt11Amount(invoice string) int64 {
 		msats = amount * 100_000_000_000
 	}
 
-	// Convert millisats to sats
-	return msats / 1000
+	return msats
 }
 
 // TopNPubkeys returns the hex pubkeys of the top N scored entries.
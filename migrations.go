@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Key migrations follow the NIP-41 draft: the old key publishes a kind 1776 event
+// naming its successor,
+//
+//	["p", <new pubkey>]
+//
+// A stolen key could name any successor, so a migration only passes on trust once
+// the old key's own followers confirm it by following the new key. The new key then
+// inherits half the old key's score as an explicit component on /score; the
+// published PageRank is not changed.
+//
+// Delegations are NIP-26 "delegation" tags on crawled notes: the delegator signs a
+// token letting the delegatee publish on its behalf under conditions.
+const (
+	migrationKind          = 1776
+	migrationQuorum        = 3   // old-key followers who must also follow the new key
+	migrationInheritFactor = 0.5 // share of the old key's score the new key inherits
+	delegationMaxListed    = 50  // delegations listed per side on /migrations
+)
+
+// KeyMigration is one old key's newest migration notice.
+type KeyMigration struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	EventID   string `json:"event_id"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// Delegation is a NIP-26 delegation seen on events published by the delegatee.
+type Delegation struct {
+	Delegator  string `json:"delegator"`
+	Delegatee  string `json:"delegatee"`
+	Conditions string `json:"conditions"`
+	Events     int    `json:"events"`
+	FirstSeen  int64  `json:"first_seen"`
+	LastSeen   int64  `json:"last_seen"`
+}
+
+// MigrationStore holds migration notices and delegations.
+type MigrationStore struct {
+	mu          sync.RWMutex
+	byFrom      map[string]*KeyMigration          // old key -> newest migration
+	byTo        map[string]map[string]bool        // new key -> old keys
+	delegations map[string]map[string]*Delegation // delegator -> delegatee -> delegation
+	delegators  map[string]map[string]bool        // delegatee -> delegators
+	seen        map[string]bool                   // delegated event IDs already counted
+}
+
+func NewMigrationStore() *MigrationStore {
+	return &MigrationStore{
+		byFrom:      make(map[string]*KeyMigration),
+		byTo:        make(map[string]map[string]bool),
+		delegations: make(map[string]map[string]*Delegation),
+		delegators:  make(map[string]map[string]bool),
+		seen:        make(map[string]bool),
+	}
+}
+
+var migrations = NewMigrationStore()
+
+// AddMigration stores m, keeping only the newest migration per old key. It reports
+// whether m was stored.
+func (s *MigrationStore) AddMigration(m *KeyMigration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing := s.byFrom[m.From]; existing != nil {
+		if existing.CreatedAt >= m.CreatedAt {
+			return false
+		}
+		delete(s.byTo[existing.To], m.From)
+	}
+	s.byFrom[m.From] = m
+	if s.byTo[m.To] == nil {
+		s.byTo[m.To] = make(map[string]bool)
+	}
+	s.byTo[m.To][m.From] = true
+	return true
+}
+
+// MigratedTo returns pubkey's migration to a new key, if it announced one.
+func (s *MigrationStore) MigratedTo(pubkey string) (KeyMigration, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	m, ok := s.byFrom[pubkey]
+	if !ok {
+		return KeyMigration{}, false
+	}
+	return *m, true
+}
+
+// MigratedFrom returns the migrations naming pubkey as the new key.
+func (s *MigrationStore) MigratedFrom(pubkey string) []KeyMigration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]KeyMigration, 0, len(s.byTo[pubkey]))
+	for from := range s.byTo[pubkey] {
+		out = append(out, *s.byFrom[from])
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].From < out[j].From })
+	return out
+}
+
+// RecordDelegation counts a delegated event once per event ID.
+func (s *MigrationStore) RecordDelegation(delegator, delegatee, conditions, eventID string, createdAt int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen[eventID] {
+		return
+	}
+	s.seen[eventID] = true
+	if s.delegations[delegator] == nil {
+		s.delegations[delegator] = make(map[string]*Delegation)
+	}
+	d := s.delegations[delegator][delegatee]
+	if d == nil {
+		d = &Delegation{Delegator: delegator, Delegatee: delegatee, FirstSeen: createdAt}
+		s.delegations[delegator][delegatee] = d
+		if s.delegators[delegatee] == nil {
+			s.delegators[delegatee] = make(map[string]bool)
+		}
+		s.delegators[delegatee][delegator] = true
+	}
+	d.Events++
+	if createdAt < d.FirstSeen {
+		d.FirstSeen = createdAt
+	}
+	if createdAt >= d.LastSeen {
+		d.LastSeen, d.Conditions = createdAt, conditions
+	}
+}
+
+// Delegations returns the delegations pubkey granted (as delegator) and used (as
+// delegatee), most active first.
+func (s *MigrationStore) Delegations(pubkey string) (granted, received []Delegation) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, d := range s.delegations[pubkey] {
+		granted = append(granted, *d)
+	}
+	for delegator := range s.delegators[pubkey] {
+		received = append(received, *s.delegations[delegator][pubkey])
+	}
+	for _, list := range [][]Delegation{granted, received} {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Events != list[j].Events {
+				return list[i].Events > list[j].Events
+			}
+			return list[i].Delegator+list[i].Delegatee < list[j].Delegator+list[j].Delegatee
+		})
+	}
+	return granted, received
+}
+
+// Counts returns how many migrations and delegations are stored.
+func (s *MigrationStore) Counts() (migrationCount, delegationCount int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, byDelegatee := range s.delegations {
+		delegationCount += len(byDelegatee)
+	}
+	return len(s.byFrom), delegationCount
+}
+
+// parseMigration extracts a key migration from a kind 1776 event. Returns nil if
+// the event doesn't name a different, valid successor.
+func parseMigration(ev *nostr.Event) *KeyMigration {
+	if ev.Kind != migrationKind {
+		return nil
+	}
+	for _, tag := range ev.Tags {
+		if len(tag) >= 2 && tag[0] == "p" {
+			if !hex64Pattern.MatchString(tag[1]) || tag[1] == ev.PubKey {
+				return nil
+			}
+			return &KeyMigration{From: ev.PubKey, To: tag[1], EventID: ev.ID, CreatedAt: int64(ev.CreatedAt)}
+		}
+	}
+	return nil
+}
+
+// parseDelegation checks ev's NIP-26 delegation tag, if any: the token must be the
+// delegator's Schnorr signature over sha256("nostr:delegation:<delegatee>:<conditions>")
+// and ev must meet the conditions. ok is false for events without a valid delegation.
+func parseDelegation(ev *nostr.Event) (delegator, conditions string, ok bool) {
+	tag := ev.Tags.GetFirst([]string{"delegation"})
+	if tag == nil || len(*tag) < 4 {
+		return "", "", false
+	}
+	delegator, conditions, token := (*tag)[1], (*tag)[2], (*tag)[3]
+	if !hex64Pattern.MatchString(delegator) || delegator == ev.PubKey {
+		return "", "", false
+	}
+	pkBytes, err := hex.DecodeString(delegator)
+	if err != nil {
+		return "", "", false
+	}
+	pk, err := schnorr.ParsePubKey(pkBytes)
+	if err != nil {
+		return "", "", false
+	}
+	sigBytes, err := hex.DecodeString(token)
+	if err != nil {
+		return "", "", false
+	}
+	sig, err := schnorr.ParseSignature(sigBytes)
+	if err != nil {
+		return "", "", false
+	}
+	hash := sha256.Sum256([]byte("nostr:delegation:" + ev.PubKey + ":" + conditions))
+	if !sig.Verify(hash[:], pk) || !delegationConditionsMet(conditions, ev) {
+		return "", "", false
+	}
+	return delegator, conditions, true
+}
+
+// delegationConditionsMet evaluates NIP-26 conditions such as
+// "kind=1&created_at>1674834236&created_at<1677426236". Any kind= clause may match.
+func delegationConditionsMet(conditions string, ev *nostr.Event) bool {
+	kindAllowed, kindListed := false, false
+	for _, clause := range strings.Split(conditions, "&") {
+		var op string
+		for _, o := range []string{"=", "<", ">"} {
+			if strings.Contains(clause, o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return false
+		}
+		field, value, _ := strings.Cut(clause, op)
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		switch {
+		case field == "kind" && op == "=":
+			kindListed = true
+			kindAllowed = kindAllowed || int64(ev.Kind) == n
+		case field == "created_at" && op == "<":
+			if int64(ev.CreatedAt) >= n {
+				return false
+			}
+		case field == "created_at" && op == ">":
+			if int64(ev.CreatedAt) <= n {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return !kindListed || kindAllowed
+}
+
+// consumeMigrations fetches kind 1776 key migration notices from relays.
+func consumeMigrations(ctx context.Context, store *MigrationStore) {
+	log.Printf("Consuming key migrations (kind %d) from relays...", migrationKind)
+
+	pool := nostr.NewSimplePool(ctx)
+	filter := nostr.Filter{
+		Kinds: []int{migrationKind},
+		Limit: 10000,
+	}
+	for ev := range pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter}) {
+		if m := parseMigration(ev.Event); m != nil {
+			store.AddMigration(m)
+		}
+	}
+
+	n, d := store.Counts()
+	log.Printf("Consumed %d key migrations (%d delegations seen)", n, d)
+}
+
+// MigrationDetail is one migration with whether the old key's followers confirm it.
+type MigrationDetail struct {
+	KeyMigration
+	OldScore    int  `json:"old_score"`
+	Inherited   int  `json:"inherited"`
+	ConfirmedBy int  `json:"confirmed_by"` // old-key followers who follow the new key
+	Quorum      int  `json:"quorum"`
+	Confirmed   bool `json:"confirmed"`
+}
+
+// evaluateMigration measures m against g: the old key's score, how many of its
+// followers also follow the new key, and what the new key inherits once they reach
+// the quorum (or all of them, for old keys with fewer followers).
+func evaluateMigration(g *Graph, m KeyMigration) MigrationDetail {
+	raw, _ := g.GetScore(m.From)
+	d := MigrationDetail{KeyMigration: m, OldScore: normalizeScore(raw, g.Stats().Nodes)}
+	oldFollowers := g.GetFollowers(m.From)
+	d.Quorum = migrationQuorum
+	if len(oldFollowers) < d.Quorum {
+		d.Quorum = len(oldFollowers)
+	}
+	if len(oldFollowers) > 0 {
+		newFollowers := make(map[string]bool)
+		for _, f := range g.GetFollowers(m.To) {
+			newFollowers[f] = true
+		}
+		for _, f := range oldFollowers {
+			if newFollowers[f] {
+				d.ConfirmedBy++
+			}
+		}
+	}
+	d.Confirmed = d.Quorum > 0 && d.ConfirmedBy >= d.Quorum
+	if d.Confirmed {
+		d.Inherited = int(math.Round(float64(d.OldScore) * migrationInheritFactor))
+	}
+	return d
+}
+
+// inheritedTrust returns the confirmed migration into pubkey that passes on the
+// most trust, if any.
+func inheritedTrust(g *Graph, pubkey string) (MigrationDetail, bool) {
+	var best MigrationDetail
+	found := false
+	for _, m := range migrations.MigratedFrom(pubkey) {
+		d := evaluateMigration(g, m)
+		if d.Confirmed && (!found || d.Inherited > best.Inherited) {
+			best, found = d, true
+		}
+	}
+	return best, found
+}
+
+// handleMigrations serves GET /migrations?pubkey= (key migrations into and out of
+// pubkey, inherited trust, account age and NIP-26 delegations) and POST /migrations
+// (submit a signed kind 1776 migration notice directly).
+func handleMigrations(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
+	switch r.Method {
+	case http.MethodGet:
+		q := bindQuery(r)
+		pubkey := q.Pubkey("pubkey", true)
+		if q.Failed(w) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(migrationsFor(g, pubkey))
+	case http.MethodPost:
+		body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+		if err != nil {
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "failed to read body"})
+			return
+		}
+		var ev nostr.Event
+		if err := json.Unmarshal(body, &ev); err != nil {
+			writeAPIError(w, invalidParam("event", "invalid event JSON"))
+			return
+		}
+		if !ev.CheckID() {
+			writeAPIError(w, invalidParam("event", "event id mismatch"))
+			return
+		}
+		if ok, err := ev.CheckSignature(); err != nil || !ok {
+			writeAPIError(w, invalidParam("event", "invalid event signature"))
+			return
+		}
+		m := parseMigration(&ev)
+		if m == nil {
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("not a key migration (kind %d with p=<new pubkey>)", migrationKind)})
+			return
+		}
+		stored := migrations.AddMigration(m)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"accepted":  stored,
+			"migration": evaluateMigration(g, *m),
+		})
+	default:
+		writeAPIError(w, &APIError{Status: http.StatusMethodNotAllowed, Message: "GET or POST required"})
+	}
+}
+
+// migrationsFor builds the /migrations response for pubkey.
+func migrationsFor(g *Graph, pubkey string) map[string]interface{} {
+	resp := map[string]interface{}{
+		"pubkey": pubkey,
+		"rules": map[string]interface{}{
+			"quorum":         migrationQuorum,
+			"inherit_factor": migrationInheritFactor,
+		},
+	}
+
+	if m, ok := migrations.MigratedTo(pubkey); ok {
+		resp["migrated_to"] = evaluateMigration(g, m)
+	}
+	from := []MigrationDetail{}
+	for _, m := range migrations.MigratedFrom(pubkey) {
+		from = append(from, evaluateMigration(g, m))
+	}
+	resp["migrated_from"] = from
+
+	raw, _ := g.GetScore(pubkey)
+	score := normalizeScore(raw, g.Stats().Nodes)
+	resp["score"] = score
+	if best, ok := inheritedTrust(g, pubkey); ok {
+		resp["inherited_trust"] = best
+		resp["migrated_score"] = max(score, best.Inherited)
+	}
+
+	// A confirmed migration carries the old key's history, so account age counts
+	// from the oldest confirmed predecessor's first event
+	own := meta.FirstCreated(pubkey)
+	effective := own
+	age := map[string]interface{}{"first_created": own}
+	for _, d := range from {
+		if !d.Confirmed {
+			continue
+		}
+		if fc := meta.FirstCreated(d.From); fc > 0 && (effective == 0 || fc < effective) {
+			effective = fc
+			age["inherited_from"] = d.From
+		}
+	}
+	age["effective_first_created"] = effective
+	if effective > 0 {
+		age["age_days"] = int(time.Since(time.Unix(effective, 0)).Hours() / 24)
+	}
+	resp["account_age"] = age
+
+	granted, received := migrations.Delegations(pubkey)
+	resp["delegations_granted"] = limitDelegations(granted)
+	resp["delegations_received"] = limitDelegations(received)
+	return resp
+}
+
+func limitDelegations(list []Delegation) []Delegation {
+	if list == nil {
+		return []Delegation{}
+	}
+	if len(list) > delegationMaxListed {
+		return list[:delegationMaxListed]
+	}
+	return list
+}
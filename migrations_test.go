@@ -0,0 +1,281 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func migrationEvent(t *testing.T, sk, to string) nostr.Event {
+	t.Helper()
+	ev := nostr.Event{Kind: migrationKind, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"p", to}}}
+	if err := ev.Sign(sk); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return ev
+}
+
+// delegatedNote returns a kind 1 note by delegateeSK carrying a NIP-26 token from
+// delegatorSK for conditions.
+func delegatedNote(t *testing.T, delegatorSK, delegateeSK, conditions string, createdAt nostr.Timestamp) nostr.Event {
+	t.Helper()
+	delegator, _ := nostr.GetPublicKey(delegatorSK)
+	delegatee, _ := nostr.GetPublicKey(delegateeSK)
+	skBytes, _ := hex.DecodeString(delegatorSK)
+	priv, _ := btcec.PrivKeyFromBytes(skBytes)
+	hash := sha256.Sum256([]byte("nostr:delegation:" + delegatee + ":" + conditions))
+	sig, err := schnorr.Sign(priv, hash[:])
+	if err != nil {
+		t.Fatalf("delegation token: %v", err)
+	}
+	ev := nostr.Event{
+		Kind:      1,
+		CreatedAt: createdAt,
+		Tags:      nostr.Tags{{"delegation", delegator, conditions, hex.EncodeToString(sig.Serialize())}},
+		Content:   "posted on behalf of the delegator",
+	}
+	if err := ev.Sign(delegateeSK); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return ev
+}
+
+func TestParseMigration(t *testing.T) {
+	ev := &nostr.Event{ID: "m1", PubKey: padHex(37001), Kind: migrationKind, CreatedAt: 1000, Tags: nostr.Tags{{"p", padHex(37002)}}}
+	m := parseMigration(ev)
+	if m == nil || m.From != padHex(37001) || m.To != padHex(37002) || m.CreatedAt != 1000 {
+		t.Fatalf("unexpected migration %+v", m)
+	}
+
+	ev.Tags = nostr.Tags{{"p", ev.PubKey}}
+	if parseMigration(ev) != nil {
+		t.Error("expected nil for a migration to the same key")
+	}
+	ev.Tags = nostr.Tags{{"p", "npub1bad"}}
+	if parseMigration(ev) != nil {
+		t.Error("expected nil for an invalid successor")
+	}
+	ev.Tags, ev.Kind = nostr.Tags{{"p", padHex(37002)}}, 1
+	if parseMigration(ev) != nil {
+		t.Error("expected nil for the wrong kind")
+	}
+}
+
+func TestMigrationStoreKeepsNewest(t *testing.T) {
+	store := NewMigrationStore()
+	from := padHex(37010)
+	store.AddMigration(&KeyMigration{From: from, To: padHex(37011), EventID: "new", CreatedAt: 200})
+	if store.AddMigration(&KeyMigration{From: from, To: padHex(37012), EventID: "old", CreatedAt: 100}) {
+		t.Error("expected an older migration to be ignored")
+	}
+	if m, ok := store.MigratedTo(from); !ok || m.EventID != "new" {
+		t.Fatalf("expected the newest migration, got %+v", m)
+	}
+
+	store.AddMigration(&KeyMigration{From: from, To: padHex(37013), EventID: "newer", CreatedAt: 300})
+	if len(store.MigratedFrom(padHex(37011))) != 0 || len(store.MigratedFrom(padHex(37013))) != 1 {
+		t.Error("expected a newer migration to replace the previous successor")
+	}
+}
+
+func TestParseDelegation(t *testing.T) {
+	delegatorSK, delegateeSK := nostr.GeneratePrivateKey(), nostr.GeneratePrivateKey()
+	delegator, _ := nostr.GetPublicKey(delegatorSK)
+
+	ev := delegatedNote(t, delegatorSK, delegateeSK, "kind=1&created_at>1000&created_at<3000", 2000)
+	got, conditions, ok := parseDelegation(&ev)
+	if !ok || got != delegator || conditions != "kind=1&created_at>1000&created_at<3000" {
+		t.Fatalf("expected a valid delegation, got %q %q %v", got, conditions, ok)
+	}
+
+	for name, ev := range map[string]nostr.Event{
+		"expired":    delegatedNote(t, delegatorSK, delegateeSK, "kind=1&created_at<1500", 2000),
+		"wrong kind": delegatedNote(t, delegatorSK, delegateeSK, "kind=0&kind=3", 2000),
+		"bad clause": delegatedNote(t, delegatorSK, delegateeSK, "tag=x", 2000),
+	} {
+		if _, _, ok := parseDelegation(&ev); ok {
+			t.Errorf("%s: expected the delegation to be rejected", name)
+		}
+	}
+
+	// A token signed for another delegatee doesn't verify
+	forged := delegatedNote(t, delegatorSK, nostr.GeneratePrivateKey(), "kind=1", 2000)
+	ev.Tags = forged.Tags
+	if _, _, ok := parseDelegation(&ev); ok {
+		t.Error("expected a token for another delegatee to be rejected")
+	}
+	ev.Tags = nil
+	if _, _, ok := parseDelegation(&ev); ok {
+		t.Error("expected no delegation without a tag")
+	}
+}
+
+func TestMigrationStoreDelegations(t *testing.T) {
+	store := NewMigrationStore()
+	delegator, delegatee := padHex(37020), padHex(37021)
+	store.RecordDelegation(delegator, delegatee, "kind=1", "e1", 200)
+	store.RecordDelegation(delegator, delegatee, "kind=1", "e1", 200)
+	store.RecordDelegation(delegator, delegatee, "kind=1&created_at<900", "e2", 100)
+
+	granted, received := store.Delegations(delegator)
+	if len(granted) != 1 || len(received) != 0 {
+		t.Fatalf("expected one granted delegation, got %+v %+v", granted, received)
+	}
+	d := granted[0]
+	if d.Events != 2 || d.FirstSeen != 100 || d.LastSeen != 200 || d.Conditions != "kind=1" {
+		t.Errorf("unexpected delegation %+v", d)
+	}
+	if _, received := store.Delegations(delegatee); len(received) != 1 || received[0].Delegator != delegator {
+		t.Errorf("expected the delegatee to list its delegator, got %+v", received)
+	}
+	if n, dn := store.Counts(); n != 0 || dn != 1 {
+		t.Errorf("unexpected counts %d %d", n, dn)
+	}
+}
+
+// setupMigrationGraph gives oldKey followers, confirmed of whom also follow newKey.
+func setupMigrationGraph(t *testing.T, oldKey, newKey string, followers, confirmed int) {
+	t.Helper()
+	oldGraph, oldMigrations, oldMeta := graph, migrations, meta
+	t.Cleanup(func() { graph, migrations, meta = oldGraph, oldMigrations, oldMeta })
+	graph, migrations, meta = NewGraph(), NewMigrationStore(), NewMetaStore()
+	for i := 0; i < followers; i++ {
+		f := fmt.Sprintf("%064x", 0x37100+i)
+		graph.AddFollow(f, oldKey)
+		if i < confirmed {
+			graph.AddFollow(f, newKey)
+		}
+	}
+	graph.AddFollow(padHex(37099), newKey)
+	graph.ComputePageRank(20, 0.85)
+}
+
+func TestEvaluateMigrationQuorum(t *testing.T) {
+	oldKey, newKey := padHex(37030), padHex(37031)
+	setupMigrationGraph(t, oldKey, newKey, 6, 2)
+	m := KeyMigration{From: oldKey, To: newKey}
+
+	d := evaluateMigration(graph, m)
+	if d.Confirmed || d.Inherited != 0 || d.ConfirmedBy != 2 || d.Quorum != migrationQuorum || d.OldScore == 0 {
+		t.Fatalf("expected an unconfirmed migration below quorum, got %+v", d)
+	}
+	graph.AddFollow(fmt.Sprintf("%064x", 0x37102), newKey)
+	d = evaluateMigration(graph, m)
+	if !d.Confirmed || d.Inherited == 0 || d.Inherited > d.OldScore {
+		t.Errorf("expected a confirmed migration inheriting part of the old score, got %+v", d)
+	}
+
+	// An old key with fewer followers than the quorum needs all of them
+	small, successor := padHex(37032), padHex(37033)
+	graph.AddFollow(padHex(37034), small)
+	if d := evaluateMigration(graph, KeyMigration{From: small, To: successor}); d.Quorum != 1 || d.Confirmed {
+		t.Errorf("expected a quorum of 1 and no confirmation, got %+v", d)
+	}
+	graph.AddFollow(padHex(37034), successor)
+	if d := evaluateMigration(graph, KeyMigration{From: small, To: successor}); !d.Confirmed {
+		t.Errorf("expected the only follower to confirm, got %+v", d)
+	}
+	if d := evaluateMigration(graph, KeyMigration{From: padHex(37035), To: successor}); d.Confirmed {
+		t.Errorf("expected a key without followers never to confirm, got %+v", d)
+	}
+}
+
+func TestMigrationsEndpoint(t *testing.T) {
+	oldSK := nostr.GeneratePrivateKey()
+	oldKey, _ := nostr.GetPublicKey(oldSK)
+	newKey := padHex(37040)
+	setupMigrationGraph(t, oldKey, newKey, 5, 4)
+	meta.Get(oldKey).FirstCreated = 1000
+	meta.Get(newKey).FirstCreated = 5000
+
+	ev := migrationEvent(t, oldSK, newKey)
+	body, _ := json.Marshal(ev)
+	rr := httptest.NewRecorder()
+	handleMigrations(rr, httptest.NewRequest(http.MethodPost, "/migrations", strings.NewReader(string(body))))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"accepted":true`) {
+		t.Fatalf("expected the migration accepted, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handleMigrations(rr, httptest.NewRequest(http.MethodGet, "/migrations?pubkey="+newKey, nil))
+	var resp struct {
+		Score          int               `json:"score"`
+		MigratedFrom   []MigrationDetail `json:"migrated_from"`
+		InheritedTrust *MigrationDetail  `json:"inherited_trust"`
+		MigratedScore  int               `json:"migrated_score"`
+		AccountAge     struct {
+			FirstCreated          int64  `json:"first_created"`
+			InheritedFrom         string `json:"inherited_from"`
+			EffectiveFirstCreated int64  `json:"effective_first_created"`
+		} `json:"account_age"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.MigratedFrom) != 1 || resp.InheritedTrust == nil || resp.InheritedTrust.From != oldKey {
+		t.Fatalf("expected the confirmed migration from the old key, got %s", rr.Body.String())
+	}
+	if resp.MigratedScore < resp.Score || resp.MigratedScore < resp.InheritedTrust.Inherited {
+		t.Errorf("expected migrated_score to be the larger of score and inherited, got %+v", resp)
+	}
+	if resp.AccountAge.FirstCreated != 5000 || resp.AccountAge.EffectiveFirstCreated != 1000 || resp.AccountAge.InheritedFrom != oldKey {
+		t.Errorf("expected account age from the old key, got %+v", resp.AccountAge)
+	}
+
+	// Tampered or non-migration events are rejected
+	ev.Tags = nostr.Tags{{"p", padHex(37041)}}
+	body, _ = json.Marshal(ev)
+	rr = httptest.NewRecorder()
+	handleMigrations(rr, httptest.NewRequest(http.MethodPost, "/migrations", strings.NewReader(string(body))))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a tampered event, got %d", rr.Code)
+	}
+	note := nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"p", newKey}}}
+	note.Sign(oldSK)
+	body, _ = json.Marshal(note)
+	rr = httptest.NewRecorder()
+	handleMigrations(rr, httptest.NewRequest(http.MethodPost, "/migrations", strings.NewReader(string(body))))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a non-migration event, got %d", rr.Code)
+	}
+
+	for _, q := range []string{"", "?pubkey=npub1bad"} {
+		rr = httptest.NewRecorder()
+		handleMigrations(rr, httptest.NewRequest(http.MethodGet, "/migrations"+q, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("GET /migrations%s: expected 400, got %d", q, rr.Code)
+		}
+	}
+}
+
+func TestScoreShowsInheritedTrust(t *testing.T) {
+	oldKey, newKey := padHex(37050), padHex(37051)
+	setupMigrationGraph(t, oldKey, newKey, 5, 3)
+	migrations.AddMigration(&KeyMigration{From: oldKey, To: newKey, EventID: "m", CreatedAt: 100})
+
+	rr := httptest.NewRecorder()
+	handleScore(rr, httptest.NewRequest(http.MethodGet, "/score?pubkey="+newKey, nil))
+	var resp map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	inherited, ok := resp["inherited_trust"].(map[string]interface{})
+	if !ok || inherited["from"] != oldKey || resp["migrated_score"] == nil {
+		t.Fatalf("expected inherited_trust and migrated_score on /score, got %v", resp)
+	}
+
+	rr = httptest.NewRecorder()
+	handleScore(rr, httptest.NewRequest(http.MethodGet, "/score?pubkey="+oldKey, nil))
+	resp = nil
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp["migrated_to"] != newKey || resp["inherited_trust"] != nil {
+		t.Errorf("expected migrated_to on the old key's score, got %v", resp)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// MuteScoring configures the mute-penalized PageRank mode, in which NIP-51 mutes
+// from trusted accounts are negative edges: a muter spends part of its score
+// against the pubkeys it mutes, in the same pass that its follows share the rest.
+type MuteScoring struct {
+	Enabled  bool    `json:"enabled"`
+	Penalty  float64 `json:"penalty"`         // share of a muter's score spent against its mutes
+	MinScore int     `json:"min_muter_score"` // score (0-100) a muter needs before its mutes count
+}
+
+// defaultMuteScoring lets a trusted muter take a quarter of its score's worth of
+// rank from the accounts it mutes, split evenly across them, matching the weight
+// distrust propagation gives a mute.
+var defaultMuteScoring = MuteScoring{Penalty: distrustMuteWeight, MinScore: distrustMinSourceScore}
+
+// NewMuteScoringFromEnv enables the mode when PAGERANK_MUTES=penalize.
+// MUTE_PENALTY (0-1) and MUTE_MIN_SCORE (0-100) override the defaults.
+func NewMuteScoringFromEnv() MuteScoring {
+	m := defaultMuteScoring
+	m.Enabled = os.Getenv("PAGERANK_MUTES") == "penalize"
+	if v := os.Getenv("MUTE_PENALTY"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 && f <= 1 {
+			m.Penalty = f
+		} else {
+			log.Printf("Ignoring MUTE_PENALTY=%q: want a number from 0 to 1", v)
+		}
+	}
+	if v := os.Getenv("MUTE_MIN_SCORE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 && n <= 100 {
+			m.MinScore = n
+		} else {
+			log.Printf("Ignoring MUTE_MIN_SCORE=%q: want an integer from 0 to 100", v)
+		}
+	}
+	return m
+}
+
+var muteScoring = NewMuteScoringFromEnv()
+
+// MutePenaltyReport is what a mute-penalized rebuild changed: every pubkey's raw
+// score without mutes, and how many muters counted against each muted pubkey.
+type MutePenaltyReport struct {
+	Base   map[string]float64
+	Muters map[string]int
+}
+
+// ComputeMutePenalizedPageRank runs PageRank twice. The first pass, without mutes,
+// decides whose mutes count: muters scoring at least ms.MinScore. The second adds a
+// negative edge from each of them to each pubkey it mutes and is installed as the
+// graph's scores. weight, when not nil, weights follow edges as in
+// ComputeWeightedPageRank.
+func (g *Graph) ComputeMutePenalizedPageRank(iterations int, damping float64, weight func(from, to string) float64, mutes map[string][]string, ms MuteScoring) *MutePenaltyReport {
+	follows, followers := g.FollowsSnapshot()
+	var pg *pageRankGraph
+	if weight != nil {
+		weights, outWeightSum := followWeights(follows, weight)
+		pg = newPageRankGraph(follows, followers, weights, outWeightSum)
+	} else {
+		pg = newPageRankGraph(follows, followers, nil, nil)
+	}
+	base := pg.run(iterations, damping)
+	if base == nil {
+		return nil
+	}
+
+	n := len(base)
+	counted := func(muter string) bool {
+		return normalizeScore(base[muter], n) >= ms.MinScore
+	}
+	report := &MutePenaltyReport{Base: base, Muters: make(map[string]int)}
+	for muter, targets := range mutes {
+		if !counted(muter) {
+			continue
+		}
+		for _, t := range targets {
+			if _, ok := base[t]; ok && t != muter {
+				report.Muters[t]++
+			}
+		}
+	}
+	pg.addMutes(mutes, func(muter string) float64 {
+		if !counted(muter) {
+			return 0
+		}
+		return ms.Penalty
+	})
+	scores := pg.run(iterations, damping)
+
+	g.mu.Lock()
+	g.setComputedScores(scores)
+	g.mu.Unlock()
+	return report
+}
+
+// MutePenaltyStore holds the last mute-penalized rebuild's report.
+type MutePenaltyStore struct {
+	mu     sync.RWMutex
+	report *MutePenaltyReport
+}
+
+var mutePenalties = &MutePenaltyStore{}
+
+func (s *MutePenaltyStore) Set(r *MutePenaltyReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.report = r
+}
+
+// Get returns pubkey's raw score without mutes and its counted muters. ok is false
+// when the last rebuild wasn't mute-penalized or no counted muter muted pubkey.
+func (s *MutePenaltyStore) Get(pubkey string) (base float64, muters int, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.report == nil || s.report.Muters[pubkey] == 0 {
+		return 0, 0, false
+	}
+	return s.report.Base[pubkey], s.report.Muters[pubkey], true
+}
+
+// MutePenaltyAudit is the mute_penalty component of /audit.
+type MutePenaltyAudit struct {
+	CountedMuters        int     `json:"counted_muters"`
+	RawScoreWithoutMutes float64 `json:"raw_score_without_mutes"`
+	ScoreWithoutMutes    int     `json:"score_without_mutes"`
+	ScoreChange          int     `json:"score_change"`
+	Penalty              float64 `json:"penalty"`
+	MinMuterScore        int     `json:"min_muter_score"`
+}
+
+// auditMutePenalty is the mute_penalty component of /audit, or nil when mutes
+// didn't touch pubkey's score.
+func auditMutePenalty(pubkey string, rawScore float64, nodes int) *MutePenaltyAudit {
+	base, muters, ok := mutePenalties.Get(pubkey)
+	if !ok {
+		return nil
+	}
+	without := normalizeScore(base, nodes)
+	with := normalizeScore(rawScore, nodes)
+	return &MutePenaltyAudit{
+		CountedMuters:        muters,
+		RawScoreWithoutMutes: base,
+		ScoreWithoutMutes:    without,
+		ScoreChange:          with - without,
+		Penalty:              muteScoring.Penalty,
+		MinMuterScore:        muteScoring.MinScore,
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// muteGraph has a trusted hub that many accounts follow, a target followed by a
+// few accounts, and a newcomer nobody follows.
+func muteGraph() (g *Graph, hub, target, newcomer string) {
+	g = NewGraph()
+	hub, target, newcomer = padHex(44001), padHex(44002), padHex(44003)
+	for i := 0; i < 30; i++ {
+		g.AddFollow(padHex(44100+i), hub)
+		g.AddFollow(hub, padHex(44100+i))
+	}
+	for i := 0; i < 5; i++ {
+		g.AddFollow(padHex(44100+i), target)
+	}
+	g.AddFollow(newcomer, hub)
+	return g, hub, target, newcomer
+}
+
+func TestComputeMutePenalizedPageRank(t *testing.T) {
+	g, hub, target, newcomer := muteGraph()
+	g.ComputePageRank(20, 0.85)
+	plain := g.ScoresSnapshot()
+
+	// without counted mutes the scores are plain PageRank
+	report := g.ComputeMutePenalizedPageRank(20, 0.85, nil, map[string][]string{newcomer: {target}}, defaultMuteScoring)
+	for pk, s := range g.ScoresSnapshot() {
+		if math.Abs(s-plain[pk]) > 1e-15 {
+			t.Fatalf("%s: expected the newcomer's mute ignored, got %v want %v", pk, s, plain[pk])
+		}
+	}
+	if len(report.Muters) != 0 || report.Base[target] != plain[target] {
+		t.Errorf("expected no counted muters and the plain scores as base, got %+v", report.Muters)
+	}
+
+	report = g.ComputeMutePenalizedPageRank(20, 0.85, nil, map[string][]string{hub: {target, padHex(44999)}}, defaultMuteScoring)
+	scores := g.ScoresSnapshot()
+	if scores[target] >= plain[target]/2 {
+		t.Errorf("expected the hub's mute to cut the target's score, got %v from %v", scores[target], plain[target])
+	}
+	if report.Muters[target] != 1 || len(report.Muters) != 1 {
+		t.Errorf("expected one counted muter of the target only, got %+v", report.Muters)
+	}
+	for pk, s := range scores {
+		if s < 0 {
+			t.Fatalf("%s: expected no negative scores, got %v", pk, s)
+		}
+		if pk != target && s < plain[pk]-1e-15 {
+			t.Errorf("%s: expected only the target to lose score, got %v from %v", pk, s, plain[pk])
+		}
+	}
+
+	// a bigger penalty costs more
+	g.ComputeMutePenalizedPageRank(20, 0.85, nil, map[string][]string{hub: {target}}, MuteScoring{Penalty: 0.01, MinScore: 10})
+	light, _ := g.GetScore(target)
+	g.ComputeMutePenalizedPageRank(20, 0.85, nil, map[string][]string{hub: {target}}, MuteScoring{Penalty: 0.02, MinScore: 10})
+	if s, _ := g.GetScore(target); s <= 0 || light >= plain[target] || s >= light {
+		t.Errorf("expected each step up in penalty to cost more, got %v then %v from %v", light, s, plain[target])
+	}
+	if NewGraph().ComputeMutePenalizedPageRank(20, 0.85, nil, nil, defaultMuteScoring) != nil {
+		t.Error("expected no report for an empty graph")
+	}
+}
+
+func TestNewMuteScoringFromEnv(t *testing.T) {
+	t.Setenv("PAGERANK_MUTES", "penalize")
+	t.Setenv("MUTE_PENALTY", "0.8")
+	t.Setenv("MUTE_MIN_SCORE", "25")
+	if m := NewMuteScoringFromEnv(); !m.Enabled || m.Penalty != 0.8 || m.MinScore != 25 {
+		t.Errorf("unexpected config %+v", m)
+	}
+	t.Setenv("PAGERANK_MUTES", "")
+	t.Setenv("MUTE_PENALTY", "3")
+	t.Setenv("MUTE_MIN_SCORE", "high")
+	if m := NewMuteScoringFromEnv(); m != defaultMuteScoring {
+		t.Errorf("expected out-of-range values ignored, got %+v", m)
+	}
+	t.Setenv("MUTE_PENALTY", "0.5x")
+	t.Setenv("MUTE_MIN_SCORE", "12abc")
+	if m := NewMuteScoringFromEnv(); m != defaultMuteScoring {
+		t.Errorf("expected malformed values ignored, got %+v", m)
+	}
+}
+
+func TestMutePenaltyInAudit(t *testing.T) {
+	oldGraph, oldMeta, oldPenalties := graph, meta, mutePenalties
+	defer func() { graph, meta, mutePenalties = oldGraph, oldMeta, oldPenalties }()
+	meta, mutePenalties = NewMetaStore(), &MutePenaltyStore{}
+	g, hub, target, _ := muteGraph()
+	graph = g
+	mutePenalties.Set(g.ComputeMutePenalizedPageRank(20, 0.85, nil, map[string][]string{hub: {target}}, defaultMuteScoring))
+
+	rr := httptest.NewRecorder()
+	handleAudit(rr, httptest.NewRequest(http.MethodGet, "/audit?pubkey="+target, nil))
+	var audit struct {
+		MutePenalty map[string]interface{} `json:"mute_penalty"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &audit)
+	mp := audit.MutePenalty
+	if mp == nil || mp["counted_muters"].(float64) != 1 || mp["score_change"].(float64) >= 0 {
+		t.Fatalf("expected the mute penalty component, got %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handleAudit(rr, httptest.NewRequest(http.MethodGet, "/audit?pubkey="+hub, nil))
+	audit.MutePenalty = nil
+	if json.Unmarshal(rr.Body.Bytes(), &audit); audit.MutePenalty != nil {
+		t.Errorf("expected no mute penalty for an unmuted pubkey, got %v", audit.MutePenalty)
+	}
+}
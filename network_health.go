@@ -58,14 +58,15 @@ type HubEntry struct {
 }
 
 func handleNetworkHealth(w http.ResponseWriter, r *http.Request) {
-	stats := graph.Stats()
+	g := graph.Snapshot()
+	stats := g.Stats()
 	if stats.Nodes == 0 {
-		http.Error(w, `{"error":"graph not built yet"}`, http.StatusServiceUnavailable)
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "graph not built yet"})
 		return
 	}
 
-	follows, followers := graph.FollowsSnapshot()
-	scores := graph.ScoresSnapshot()
+	follows, followers := g.FollowsSnapshot()
+	scores := g.ScoresSnapshot()
 
 	// Collect all nodes
 	allNodes := make(map[string]bool, stats.Nodes)
@@ -39,11 +39,13 @@ func buildHealthTestGraph() func() {
 		padHex(6): 0.10,
 		padHex(7): 0.05,
 	}
+	graph.changed()
 
 	return func() {
 		graph.follows = oldFollows
 		graph.followers = oldFollowers
 		graph.scores = oldScores
+		graph.changed()
 	}
 }
 
@@ -55,6 +57,7 @@ func TestNetworkHealth_EmptyGraph(t *testing.T) {
 	graph.scores = map[string]float64{}
 	graph.follows = map[string][]string{}
 	graph.followers = map[string][]string{}
+	graph.changed()
 
 	req := httptest.NewRequest("GET", "/network-health", nil)
 	w := httptest.NewRecorder()
@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	neturl "net/url"
 	"strings"
 	"sync"
 	"time"
@@ -30,26 +30,21 @@ func resolveNIP05(identifier string) (pubkey string, relays []string, err error)
 		return "", nil, fmt.Errorf("invalid NIP-05 identifier: name and domain required")
 	}
 
-	url := fmt.Sprintf("https://%s/.well-known/nostr.json?name=%s", domain, name)
+	url := fmt.Sprintf("https://%s/.well-known/nostr.json?name=%s", domain, neturl.QueryEscape(name))
 
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(url)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := externalHTTP.Get(ctx, url)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to fetch NIP-05: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		return "", nil, fmt.Errorf("NIP-05 endpoint returned status %d", resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20)) // 1MB limit
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to read NIP-05 response: %w", err)
-	}
-
 	var nip05 NIP05Response
-	if err := json.Unmarshal(body, &nip05); err != nil {
+	if err := json.Unmarshal(resp.Body, &nip05); err != nil {
 		return "", nil, fmt.Errorf("invalid NIP-05 JSON: %w", err)
 	}
 
@@ -66,68 +61,101 @@ func resolveNIP05(identifier string) (pubkey string, relays []string, err error)
 	if nip05.Relays != nil {
 		userRelays = nip05.Relays[pk]
 	}
+	domainDirectory.Record(domain, name, strings.ToLower(pk))
 
 	return pk, userRelays, nil
 }
 
-// handleNIP05 handles GET /nip05?id=user@domain
-// Resolves a NIP-05 identifier, then returns the WoT trust profile for the resolved pubkey.
-func handleNIP05(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("id")
-	if id == "" {
-		http.Error(w, `{"error":"id parameter required (e.g. user@domain.com)"}`, http.StatusBadRequest)
-		return
-	}
-
-	pubkey, nip05Relays, err := resolveNIP05(id)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"NIP-05 resolution failed: %s"}`, err.Error()), http.StatusBadRequest)
-		return
-	}
+// NIP05TrustResponse is the trust profile /nip05 and /nip05/reverse return for a
+// NIP-05 identity. When /nip05/reverse finds no usable nip05 in the profile,
+// nip05 is null and error says why.
+type NIP05TrustResponse struct {
+	NIP05              *string          `json:"nip05"`
+	Pubkey             string           `json:"pubkey"`
+	DisplayName        string           `json:"display_name,omitempty"` // /nip05/reverse
+	Verified           bool             `json:"verified"`
+	VerifyError        string           `json:"verify_error,omitempty"`
+	Error              string           `json:"error,omitempty"`
+	TrustLevel         string           `json:"trust_level"`
+	TrustLevelLabel    string           `json:"trust_level_label"`
+	Score              int              `json:"score"`
+	RawScore           float64          `json:"raw_score"`
+	Found              bool             `json:"found"`
+	GraphSize          int              `json:"graph_size"`
+	Followers          int              `json:"followers"`
+	PostCount          int              `json:"post_count"`
+	ReplyCount         int              `json:"reply_count"`
+	Reactions          int              `json:"reactions"`
+	NIP05Relays        []string         `json:"nip05_relays,omitempty"`
+	CompositeScore     *int             `json:"composite_score,omitempty"`
+	ExternalAssertions []ExternalSource `json:"external_assertions,omitempty"`
+	Topics             []string         `json:"topics,omitempty"`
+	ActiveHoursStart   *int             `json:"active_hours_start,omitempty"`
+	ActiveHoursEnd     *int             `json:"active_hours_end,omitempty"`
+}
 
-	score, found := graph.GetScore(pubkey)
-	stats := graph.Stats()
+// nip05Profile fills in the trust fields of pubkey's NIP05TrustResponse from g.
+func nip05Profile(g *Graph, locale, pubkey string) NIP05TrustResponse {
+	score, found := g.GetScore(pubkey)
+	stats := g.Stats()
 	m := meta.Get(pubkey)
 	internalScore := normalizeScore(score, stats.Nodes)
-	extAssertions := externalAssertions.GetForSubject(pubkey)
-	compositeScore, extSources := CompositeScore(internalScore, extAssertions, externalAssertions)
-
 	trustLevel := nip05TrustLevel(internalScore, found)
 
-	resp := map[string]interface{}{
-		"nip05":       id,
-		"pubkey":      pubkey,
-		"verified":    true,
-		"trust_level": trustLevel,
-		"score":       internalScore,
-		"raw_score":   score,
-		"found":       found,
-		"graph_size":  stats.Nodes,
-		"followers":   m.Followers,
-		"post_count":  m.PostCount,
-		"reply_count": m.ReplyCount,
-		"reactions":   m.ReactionsRecd,
-	}
-
-	if len(nip05Relays) > 0 {
-		resp["nip05_relays"] = nip05Relays
+	resp := NIP05TrustResponse{
+		Pubkey:          pubkey,
+		TrustLevel:      trustLevel,
+		TrustLevelLabel: trustLevelLabel(locale, trustLevel),
+		Score:           internalScore,
+		RawScore:        score,
+		Found:           found,
+		GraphSize:       stats.Nodes,
+		Followers:       m.Followers,
+		PostCount:       m.PostCount,
+		ReplyCount:      m.ReplyCount,
+		Reactions:       m.ReactionsRecd,
 	}
 
-	if len(extSources) > 0 {
-		resp["composite_score"] = compositeScore
-		resp["external_assertions"] = extSources
+	extAssertions := externalAssertions.GetForSubject(pubkey)
+	if compositeScore, extSources := CompositeScore(internalScore, extAssertions, externalAssertions); len(extSources) > 0 {
+		resp.CompositeScore = &compositeScore
+		resp.ExternalAssertions = extSources
 	}
 
 	// NIP-85 extended metadata
 	if topics := m.TopTopics(5); len(topics) > 0 {
-		resp["topics"] = topics
+		resp.Topics = topics
+	}
+	if activeStart, activeEnd := m.ActiveHours(); activeStart != activeEnd {
+		resp.ActiveHoursStart, resp.ActiveHoursEnd = &activeStart, &activeEnd
+	}
+	return resp
+}
+
+// handleNIP05 handles GET /nip05?id=user@domain
+// Resolves a NIP-05 identifier, then returns the WoT trust profile for the resolved pubkey.
+func handleNIP05(w http.ResponseWriter, r *http.Request) {
+	locale, ok := requestLocale(w, r)
+	if !ok {
+		return
+	}
+	q := bindQuery(r)
+	id := q.String("id", true)
+	if q.Failed(w) {
+		return
 	}
-	activeStart, activeEnd := m.ActiveHours()
-	if activeStart != activeEnd {
-		resp["active_hours_start"] = activeStart
-		resp["active_hours_end"] = activeEnd
+
+	pubkey, nip05Relays, err := resolveNIP05(id)
+	if err != nil {
+		writeAPIError(w, invalidParam("id", "NIP-05 resolution failed: %s", err.Error()))
+		return
 	}
 
+	resp := nip05Profile(graph, locale, pubkey)
+	resp.NIP05 = &id
+	resp.Verified = true
+	resp.NIP05Relays = nip05Relays
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
@@ -151,33 +179,67 @@ func nip05TrustLevel(score int, found bool) string {
 	}
 }
 
-// nip05Result holds the result of a single NIP-05 bulk resolution.
-type nip05Result struct {
-	Index  int
-	Result map[string]interface{}
+// trustLevelLabels are nip05TrustLevel's levels as display text.
+var trustLevelLabels = map[string]string{
+	"highly_trusted": "Highly trusted",
+	"trusted":        "Trusted",
+	"moderate":       "Moderate",
+	"low":            "Low",
+	"untrusted":      "Untrusted",
+	"unknown":        "Unknown",
+}
+
+// trustLevelLabel is level's display text in locale.
+func trustLevelLabel(locale, level string) string {
+	return tr(locale, trustLevelLabels[level])
+}
+
+// NIP05BatchResponse is the POST /nip05/batch body, in request order.
+type NIP05BatchResponse struct {
+	Results   []NIP05BatchResult `json:"results"`
+	Count     int                `json:"count"`
+	GraphSize int                `json:"graph_size"`
+}
+
+// NIP05BatchResult is one /nip05/batch resolution. An identifier that doesn't
+// resolve carries error, is not verified, and has no trust fields.
+type NIP05BatchResult struct {
+	NIP05           string   `json:"nip05"`
+	Pubkey          string   `json:"pubkey,omitempty"`
+	Verified        bool     `json:"verified"`
+	Error           string   `json:"error,omitempty"`
+	TrustLevel      string   `json:"trust_level,omitempty"`
+	TrustLevelLabel string   `json:"trust_level_label,omitempty"`
+	Score           int      `json:"score"`
+	Found           bool     `json:"found"`
+	Followers       int      `json:"followers"`
+	NIP05Relays     []string `json:"nip05_relays,omitempty"`
 }
 
 // handleNIP05Batch handles POST /nip05/batch
 // Resolves multiple NIP-05 identifiers concurrently and returns trust profiles.
 func handleNIP05Batch(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, `{"error":"POST required"}`, http.StatusMethodNotAllowed)
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	locale, ok := requestLocale(w, r)
+	if !ok {
 		return
 	}
 
 	var req struct {
 		Identifiers []string `json:"identifiers"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"invalid JSON body"}`, http.StatusBadRequest)
+	if apiErr := bindJSON(w, r, &req, 1<<20); apiErr != nil {
+		writeAPIError(w, apiErr)
 		return
 	}
 	if len(req.Identifiers) == 0 {
-		http.Error(w, `{"error":"identifiers array required"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "identifiers", Message: "identifiers array required"})
 		return
 	}
 	if len(req.Identifiers) > 50 {
-		http.Error(w, `{"error":"max 50 identifiers per request"}`, http.StatusBadRequest)
+		writeAPIError(w, invalidParam("identifiers", "max 50 identifiers per request"))
 		return
 	}
 
@@ -185,7 +247,7 @@ func handleNIP05Batch(w http.ResponseWriter, r *http.Request) {
 
 	// Resolve all NIP-05 identifiers concurrently
 	var mu sync.Mutex
-	results := make([]map[string]interface{}, len(req.Identifiers))
+	results := make([]NIP05BatchResult, len(req.Identifiers))
 	var wg sync.WaitGroup
 
 	for i, id := range req.Identifiers {
@@ -193,14 +255,11 @@ func handleNIP05Batch(w http.ResponseWriter, r *http.Request) {
 		go func(idx int, identifier string) {
 			defer wg.Done()
 
-			entry := map[string]interface{}{
-				"nip05": identifier,
-			}
+			entry := NIP05BatchResult{NIP05: identifier}
 
 			pubkey, nip05Relays, err := resolveNIP05(identifier)
 			if err != nil {
-				entry["error"] = err.Error()
-				entry["verified"] = false
+				entry.Error = err.Error()
 				mu.Lock()
 				results[idx] = entry
 				mu.Unlock()
@@ -211,16 +270,14 @@ func handleNIP05Batch(w http.ResponseWriter, r *http.Request) {
 			m := meta.Get(pubkey)
 			internalScore := normalizeScore(score, stats.Nodes)
 
-			entry["pubkey"] = pubkey
-			entry["verified"] = true
-			entry["trust_level"] = nip05TrustLevel(internalScore, found)
-			entry["score"] = internalScore
-			entry["found"] = found
-			entry["followers"] = m.Followers
-
-			if len(nip05Relays) > 0 {
-				entry["nip05_relays"] = nip05Relays
-			}
+			entry.Pubkey = pubkey
+			entry.Verified = true
+			entry.TrustLevel = nip05TrustLevel(internalScore, found)
+			entry.TrustLevelLabel = trustLevelLabel(locale, entry.TrustLevel)
+			entry.Score = internalScore
+			entry.Found = found
+			entry.Followers = m.Followers
+			entry.NIP05Relays = nip05Relays
 
 			mu.Lock()
 			results[idx] = entry
@@ -231,10 +288,10 @@ func handleNIP05Batch(w http.ResponseWriter, r *http.Request) {
 	wg.Wait()
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"results":    results,
-		"count":      len(results),
-		"graph_size": stats.Nodes,
+	json.NewEncoder(w).Encode(NIP05BatchResponse{
+		Results:   results,
+		Count:     len(results),
+		GraphSize: stats.Nodes,
 	})
 }
 
@@ -252,7 +309,7 @@ func fetchProfileNIP05(pubkey string) (nip05ID string, displayName string, err e
 	}
 
 	// Query first 3 relays (main relays, skip NIP-85-specific ones)
-	queryRelays := relays
+	queryRelays := config.Relays()
 	if len(queryRelays) > 3 {
 		queryRelays = queryRelays[:3]
 	}
@@ -295,51 +352,23 @@ func fetchProfileNIP05(pubkey string) (nip05ID string, displayName string, err e
 // Given a pubkey, fetches the kind 0 profile, extracts the nip05 field,
 // verifies it resolves back to this pubkey, and returns the verified identity + trust profile.
 func handleNIP05Reverse(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required (hex or npub)"}`, http.StatusBadRequest)
-		return
-	}
-
-	pubkey, resolveErr := resolvePubkey(raw)
-	if resolveErr != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, resolveErr.Error()), http.StatusBadRequest)
+	locale, ok := requestLocale(w, r)
+	if !ok {
 		return
 	}
-
-	if len(pubkey) != 64 {
-		http.Error(w, `{"error":"pubkey must be 64 hex characters"}`, http.StatusBadRequest)
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	pubkey := q.HexPubkey("pubkey", true)
+	if q.Failed(w) {
 		return
 	}
 
-	// Validate hex characters
-	for _, c := range pubkey {
-		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')) {
-			http.Error(w, `{"error":"pubkey must contain only hex characters (0-9, a-f)"}`, http.StatusBadRequest)
-			return
-		}
-	}
-
+	// Still return what we can (trust data) even without NIP-05
+	resp := nip05Profile(g, locale, pubkey)
 	nip05ID, displayName, err := fetchProfileNIP05(pubkey)
+	resp.DisplayName = displayName
 	if err != nil {
-		// Still return what we can (trust data) even without NIP-05
-		score, found := graph.GetScore(pubkey)
-		stats := graph.Stats()
-		internalScore := normalizeScore(score, stats.Nodes)
-
-		resp := map[string]interface{}{
-			"pubkey":     pubkey,
-			"nip05":      nil,
-			"verified":   false,
-			"error":      err.Error(),
-			"score":      internalScore,
-			"found":      found,
-			"graph_size": stats.Nodes,
-		}
-		if displayName != "" {
-			resp["display_name"] = displayName
-		}
-
+		resp.Error = err.Error()
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
 		return
@@ -347,56 +376,12 @@ func handleNIP05Reverse(w http.ResponseWriter, r *http.Request) {
 
 	// Verify the NIP-05 resolves back to this pubkey (anti-spoofing)
 	resolvedPubkey, nip05Relays, verifyErr := resolveNIP05(nip05ID)
-	verified := verifyErr == nil && resolvedPubkey == pubkey
-
-	score, found := graph.GetScore(pubkey)
-	stats := graph.Stats()
-	m := meta.Get(pubkey)
-	internalScore := normalizeScore(score, stats.Nodes)
-	extAssertions := externalAssertions.GetForSubject(pubkey)
-	compositeScore, extSources := CompositeScore(internalScore, extAssertions, externalAssertions)
-	trustLevel := nip05TrustLevel(internalScore, found)
-
-	resp := map[string]interface{}{
-		"pubkey":       pubkey,
-		"nip05":        nip05ID,
-		"verified":     verified,
-		"trust_level":  trustLevel,
-		"score":        internalScore,
-		"raw_score":    score,
-		"found":        found,
-		"graph_size":   stats.Nodes,
-		"followers":    m.Followers,
-		"post_count":   m.PostCount,
-		"reply_count":  m.ReplyCount,
-		"reactions":    m.ReactionsRecd,
-	}
-
-	if displayName != "" {
-		resp["display_name"] = displayName
-	}
-
-	if !verified && verifyErr != nil {
-		resp["verify_error"] = verifyErr.Error()
-	}
-
-	if len(nip05Relays) > 0 {
-		resp["nip05_relays"] = nip05Relays
-	}
-
-	if len(extSources) > 0 {
-		resp["composite_score"] = compositeScore
-		resp["external_assertions"] = extSources
-	}
-
-	if topics := m.TopTopics(5); len(topics) > 0 {
-		resp["topics"] = topics
-	}
-	activeStart, activeEnd := m.ActiveHours()
-	if activeStart != activeEnd {
-		resp["active_hours_start"] = activeStart
-		resp["active_hours_end"] = activeEnd
+	resp.NIP05 = &nip05ID
+	resp.Verified = verifyErr == nil && resolvedPubkey == pubkey
+	if !resp.Verified && verifyErr != nil {
+		resp.VerifyError = verifyErr.Error()
 	}
+	resp.NIP05Relays = nip05Relays
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// nip19PubkeyKeys are JSON keys whose hex values (or arrays of hex values) are pubkeys.
+var nip19PubkeyKeys = map[string]bool{
+	"pubkey": true, "pubkeys": true, "pubkey1": true, "pubkey2": true,
+	"subject": true, "subject_pubkey": true, "user_pubkey": true,
+	"provider": true, "provider_pubkey": true, "operator_pubkey": true,
+	"from": true, "to": true, "source": true, "target": true,
+	"reporter": true, "endorser": true, "author": true,
+	"members": true, "counterparties": true, "path": true,
+	"followers": true, "follows": true,
+	"muted_pubkeys": true, "muted_by_pubkeys": true,
+}
+
+// nip19EventKeys are JSON keys whose hex values are event ids.
+var nip19EventKeys = map[string]bool{
+	"event_id": true, "event_ids": true,
+}
+
+// nip19PathKeys override key meanings on endpoints where a generic key holds a pubkey.
+var nip19PathKeys = map[string]map[string]bool{
+	"/weboftrust": {"id": true},
+}
+
+// nip19AddressPattern matches an addressable event coordinate: <kind>:<pubkey>:<d-tag>.
+var nip19AddressPattern = regexp.MustCompile(`^([0-9]+):([0-9a-f]{64}):(.*)$`)
+
+// nip19Encode converts one string value for ?format=npub output. Addresses become
+// naddr wherever they appear; hex is only re-encoded under known keys so hashes and
+// other 64-char hex values are left alone. Values that fail to encode are kept as-is.
+func nip19Encode(path, key, v string) string {
+	if m := nip19AddressPattern.FindStringSubmatch(v); m != nil {
+		if kind, err := strconv.Atoi(m[1]); err == nil {
+			if naddr, err := nip19.EncodeEntity(m[2], kind, m[3], nil); err == nil {
+				return naddr
+			}
+		}
+		return v
+	}
+	if !hex64Pattern.MatchString(v) {
+		return v
+	}
+	switch {
+	case nip19PubkeyKeys[key] || nip19PathKeys[path][key]:
+		if npub, err := nip19.EncodePublicKey(v); err == nil {
+			return npub
+		}
+	case nip19EventKeys[key]:
+		if nevent, err := nip19.EncodeEvent(v, nil, ""); err == nil {
+			return nevent
+		}
+	}
+	return v
+}
+
+// rewriteNIP19 re-emits one JSON value from dec into out, encoding string values with
+// nip19Encode. Arrays inherit the key they are stored under. Key order and numbers
+// are preserved exactly. Objects carrying both id and sig are signed Nostr events
+// and are copied untouched, since re-encoding their pubkey breaks the signature.
+func rewriteNIP19(dec *json.Decoder, out *bytes.Buffer, path, key string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			type member struct {
+				key   string
+				value json.RawMessage
+			}
+			var members []member
+			hasID, hasSig := false, false
+			for dec.More() {
+				kt, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				m := member{}
+				m.key, _ = kt.(string)
+				if err := dec.Decode(&m.value); err != nil {
+					return err
+				}
+				members = append(members, m)
+				hasID = hasID || m.key == "id"
+				hasSig = hasSig || m.key == "sig"
+			}
+			out.WriteByte('{')
+			for i, m := range members {
+				if i > 0 {
+					out.WriteByte(',')
+				}
+				kb, _ := json.Marshal(m.key)
+				out.Write(kb)
+				out.WriteByte(':')
+				if hasID && hasSig {
+					out.Write(m.value)
+					continue
+				}
+				vdec := json.NewDecoder(bytes.NewReader(m.value))
+				vdec.UseNumber()
+				if err := rewriteNIP19(vdec, out, path, m.key); err != nil {
+					return err
+				}
+			}
+			out.WriteByte('}')
+		case '[':
+			out.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					out.WriteByte(',')
+				}
+				if err := rewriteNIP19(dec, out, path, key); err != nil {
+					return err
+				}
+			}
+			out.WriteByte(']')
+		}
+		_, err := dec.Token() // closing delimiter
+		return err
+	case string:
+		b, _ := json.Marshal(nip19Encode(path, key, t))
+		out.Write(b)
+	case json.Number:
+		out.WriteString(t.String())
+	case bool:
+		out.WriteString(strconv.FormatBool(t))
+	case nil:
+		out.WriteString("null")
+	}
+	return nil
+}
+
+// nip19Recorder buffers a response so it can be rewritten before it is sent.
+type nip19Recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *nip19Recorder) Header() http.Header         { return rec.header }
+func (rec *nip19Recorder) Write(b []byte) (int, error) { return rec.body.Write(b) }
+func (rec *nip19Recorder) WriteHeader(code int)        { rec.status = code }
+
+// NIP19FormatMiddleware implements ?format=npub for every JSON endpoint: pubkeys in
+// responses are returned as npub, event ids as nevent, and addressable coordinates
+// as naddr. ?format=hex (the default) leaves responses untouched.
+func NIP19FormatMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r) // websocket handshakes need the raw writer
+			return
+		}
+		switch r.URL.Query().Get("format") {
+		case "", "hex":
+			next.ServeHTTP(w, r)
+			return
+		case "npub":
+		default:
+			if exportFormatPath(r.URL.Path) && exportFormats[r.URL.Query().Get("format")] {
+				next.ServeHTTP(w, r) // export body formats; keys stay hex
+				return
+			}
+			if r.URL.Path == "/graph" && graphFormats[r.URL.Query().Get("format")] {
+				next.ServeHTTP(w, r) // graph file downloads
+				return
+			}
+			writeAPIError(w, invalidParam("format", "format must be hex or npub"))
+			return
+		}
+
+		rec := &nip19Recorder{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		body := rec.body.Bytes()
+		if strings.HasPrefix(rec.header.Get("Content-Type"), "application/json") {
+			var out bytes.Buffer
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.UseNumber()
+			if err := rewriteNIP19(dec, &out, r.URL.Path, ""); err == nil {
+				if _, err := dec.Token(); err == io.EOF {
+					out.WriteByte('\n')
+					body = out.Bytes()
+				}
+			}
+		}
+		rec.header.Del("Content-Length")
+		w.WriteHeader(rec.status)
+		w.Write(body)
+	})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func TestNIP19Encode(t *testing.T) {
+	pk, id := padHex(22001), padHex(22002)
+	npub, _ := nip19.EncodePublicKey(pk)
+
+	if got := nip19Encode("/score", "pubkey", pk); got != npub {
+		t.Errorf("pubkey: got %s", got)
+	}
+	if got := nip19Encode("/event", "event_id", id); !strings.HasPrefix(got, "nevent1") {
+		t.Errorf("event_id: got %s", got)
+	}
+	if got := nip19Encode("/x", "d", "30023:"+pk+":my-article"); !strings.HasPrefix(got, "naddr1") {
+		t.Errorf("address: got %s", got)
+	}
+	if got := nip19Encode("/weboftrust", "id", pk); got != npub {
+		t.Errorf("weboftrust id: got %s", got)
+	}
+	// Unknown keys and non-hex values are left alone
+	if got := nip19Encode("/rebuild", "id", pk); got != pk {
+		t.Errorf("unknown key must stay hex, got %s", got)
+	}
+	if got := nip19Encode("/score", "pubkey", "alice"); got != "alice" {
+		t.Errorf("non-hex must be unchanged, got %s", got)
+	}
+}
+
+func TestNIP19FormatMiddleware(t *testing.T) {
+	pk, other := padHex(22101), padHex(22102)
+	npub, _ := nip19.EncodePublicKey(pk)
+	otherNpub, _ := nip19.EncodePublicKey(other)
+	payload := `{"pubkey":"` + pk + `","score":12.50,"counterparties":["` + other + `"],"nested":{"from":"` + pk + `","payment_hash":"` + other + `"},"ok":true,"none":null}`
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(payload + "\n"))
+	})
+	h := NIP19FormatMiddleware(next)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/score?pubkey=x", nil))
+	if rr.Body.String() != payload+"\n" {
+		t.Fatalf("default format must pass through, got %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/score?pubkey=x&format=npub", nil))
+	want := `{"pubkey":"` + npub + `","score":12.50,"counterparties":["` + otherNpub + `"],"nested":{"from":"` + npub + `","payment_hash":"` + other + `"},"ok":true,"none":null}` + "\n"
+	if rr.Body.String() != want {
+		t.Errorf("unexpected rewrite:\n got %s\nwant %s", rr.Body.String(), want)
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &v); err != nil {
+		t.Errorf("rewritten body must be valid JSON: %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/score?format=bech32", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown format, got %d", rr.Code)
+	}
+}
+
+func TestNIP19FormatMiddlewareKeepsStatusAndNonJSON(t *testing.T) {
+	h := NIP19FormatMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/docs" {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<p>" + padHex(1) + "</p>"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"pubkey":"` + padHex(1) + `"}`))
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/docs?format=npub", nil))
+	if !strings.Contains(rr.Body.String(), padHex(1)) {
+		t.Error("non-JSON bodies must not be rewritten")
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/report-gaming?format=npub", nil))
+	if rr.Code != http.StatusAccepted || !strings.Contains(rr.Body.String(), "npub1") {
+		t.Errorf("expected 202 with npub body, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestNIP19FormatMiddlewareKeepsSignedEvents(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	ev := nostr.Event{Kind: 30382, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"d", padHex(22103)}, {"rank", "50"}}}
+	ev.Sign(sk)
+	h := NIP19FormatMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"subject": padHex(22103), "events": []*nostr.Event{&ev}})
+	}))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/assertions?pubkey=x&format=npub", nil))
+	var resp struct {
+		Subject string         `json:"subject"`
+		Events  []*nostr.Event `json:"events"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || len(resp.Events) != 1 {
+		t.Fatalf("unexpected body %s", rr.Body.String())
+	}
+	if !strings.HasPrefix(resp.Subject, "npub1") {
+		t.Errorf("expected the subject re-encoded, got %s", resp.Subject)
+	}
+	if ok, err := resp.Events[0].CheckSignature(); !ok || err != nil || resp.Events[0].PubKey != ev.PubKey {
+		t.Errorf("expected the signed event untouched, got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip46"
+)
+
+// EventSigner signs the events this service publishes.
+type EventSigner interface {
+	PublicKey() string
+	Sign(ctx context.Context, ev *nostr.Event) error
+}
+
+// keySigner signs with a secret key held in memory.
+type keySigner struct{ sk, pub string }
+
+func (s keySigner) PublicKey() string { return s.pub }
+
+func (s keySigner) Sign(_ context.Context, ev *nostr.Event) error {
+	ev.PubKey = s.pub
+	return ev.Sign(s.sk)
+}
+
+// nip46Timeout bounds each request. A bunker that asks for approval first
+// (auth_url) has until then to get it.
+const nip46Timeout = 30 * time.Second
+
+// BunkerSigner signs events through a NIP-46 remote signer ("bunker"), so the
+// publisher never holds the provider's secret key. go-nostr's nip46 client
+// exchanges the NIP-44 encrypted requests over the bunker's relays, signed with
+// a separate client key the bunker authorizes.
+type BunkerSigner struct {
+	mu        sync.Mutex
+	clientSK  string
+	clientPub string
+	remote    string // the bunker's pubkey
+	relays    []string
+	secret    string
+	userPub   string // the pubkey events are signed as
+	client    *nip46.BunkerClient
+	requests  int
+	failures  int
+	cancel    context.CancelFunc
+}
+
+// parseBunkerURL splits bunker://<remote-signer-pubkey>?relay=wss://...&secret=...
+func parseBunkerURL(raw string) (remote string, relays []string, secret string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "bunker" {
+		return "", nil, "", fmt.Errorf("NOSTR_BUNKER_URL must be a bunker:// URL")
+	}
+	remote = u.Host
+	if !hex64Pattern.MatchString(remote) {
+		return "", nil, "", fmt.Errorf("NOSTR_BUNKER_URL: invalid remote signer pubkey %q", remote)
+	}
+	for _, r := range u.Query()["relay"] {
+		if r != "" {
+			relays = append(relays, r)
+		}
+	}
+	if len(relays) == 0 {
+		return "", nil, "", fmt.Errorf("NOSTR_BUNKER_URL: at least one relay is required")
+	}
+	return remote, relays, u.Query().Get("secret"), nil
+}
+
+// NewBunkerSigner prepares a client for the bunker at bunkerURL. clientKey (hex
+// or nsec) identifies this service to the bunker; with none, a new key is made,
+// and the bunker has to authorize it again after every restart.
+func NewBunkerSigner(bunkerURL, clientKey string) (*BunkerSigner, error) {
+	remote, relays, secret, err := parseBunkerURL(bunkerURL)
+	if err != nil {
+		return nil, err
+	}
+	b := &BunkerSigner{remote: remote, relays: relays, secret: secret}
+	if clientKey == "" {
+		b.clientSK = nostr.GeneratePrivateKey()
+		b.clientPub, _ = nostr.GetPublicKey(b.clientSK)
+	} else if b.clientSK, b.clientPub, err = decodeKey(clientKey); err != nil {
+		return nil, fmt.Errorf("NOSTR_BUNKER_CLIENT_KEY: %w", err)
+	}
+	return b, nil
+}
+
+// Connect starts listening for the bunker's responses, then authenticates with
+// its secret and asks which pubkey it signs as. The listener runs until Close.
+func (b *BunkerSigner) Connect(ctx context.Context) error {
+	listenCtx, cancel := context.WithCancel(context.Background())
+	client := nip46.NewBunker(listenCtx, b.clientSK, b.remote, b.relays, nil, func(authURL string) {
+		log.Printf("NIP-46: bunker asks to approve a request at %s", authURL)
+	})
+	b.mu.Lock()
+	b.client, b.cancel = client, cancel
+	b.mu.Unlock()
+
+	params := []string{b.remote}
+	if b.secret != "" {
+		params = append(params, b.secret)
+	}
+	if _, err := b.rpc(ctx, "connect", params); err != nil {
+		b.Close()
+		return err
+	}
+	pub, err := b.rpc(ctx, "get_public_key", []string{})
+	if err != nil {
+		b.Close()
+		return err
+	}
+	if !hex64Pattern.MatchString(pub) {
+		b.Close()
+		return fmt.Errorf("nip46: bunker returned invalid pubkey %q", pub)
+	}
+	b.mu.Lock()
+	b.userPub = pub
+	b.mu.Unlock()
+	log.Printf("NIP-46: connected to bunker %s, signing as %s", b.remote, pub)
+	return nil
+}
+
+// Close stops listening for responses.
+func (b *BunkerSigner) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cancel != nil {
+		b.cancel()
+	}
+}
+
+// rpc sends one request and waits for its response.
+func (b *BunkerSigner) rpc(ctx context.Context, method string, params []string) (string, error) {
+	b.mu.Lock()
+	b.requests++
+	client := b.client
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, nip46Timeout)
+	defer cancel()
+	result, err := client.RPC(ctx, method, params)
+	if err == nil {
+		return result, nil
+	}
+	b.mu.Lock()
+	b.failures++
+	b.mu.Unlock()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("nip46 %s: no response from bunker: %w", method, ctx.Err())
+	}
+	return "", fmt.Errorf("nip46 %s: %w", method, err)
+}
+
+func (b *BunkerSigner) PublicKey() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.userPub
+}
+
+// Sign asks the bunker to sign ev and checks that what comes back is ev, signed
+// by the expected pubkey, before taking its id and signature.
+func (b *BunkerSigner) Sign(ctx context.Context, ev *nostr.Event) error {
+	ev.PubKey = b.PublicKey()
+	unsigned, _ := json.Marshal(struct {
+		Kind      int             `json:"kind"`
+		Content   string          `json:"content"`
+		Tags      nostr.Tags      `json:"tags"`
+		CreatedAt nostr.Timestamp `json:"created_at"`
+		PubKey    string          `json:"pubkey"`
+	}{ev.Kind, ev.Content, ev.Tags, ev.CreatedAt, ev.PubKey})
+	result, err := b.rpc(ctx, "sign_event", []string{string(unsigned)})
+	if err != nil {
+		return err
+	}
+
+	var signed nostr.Event
+	if err := json.Unmarshal([]byte(result), &signed); err != nil {
+		return fmt.Errorf("nip46 sign_event: invalid event: %w", err)
+	}
+	want, _ := json.Marshal(ev.Tags)
+	got, _ := json.Marshal(signed.Tags)
+	if signed.PubKey != ev.PubKey || signed.Kind != ev.Kind || signed.CreatedAt != ev.CreatedAt ||
+		signed.Content != ev.Content || string(got) != string(want) {
+		return fmt.Errorf("nip46 sign_event: bunker signed a different event")
+	}
+	if ok, err := signed.CheckSignature(); !ok || err != nil || !signed.CheckID() {
+		return fmt.Errorf("nip46 sign_event: invalid signature")
+	}
+	ev.ID, ev.Sig = signed.ID, signed.Sig
+	return nil
+}
+
+// Status reports the connection for /stats.
+func (b *BunkerSigner) Status() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return map[string]interface{}{
+		"type":          "nip46",
+		"remote_signer": b.remote,
+		"pubkey":        b.userPub,
+		"client_pubkey": b.clientPub,
+		"relays":        b.relays,
+		"requests":      b.requests,
+		"failures":      b.failures,
+	}
+}
+
+// SignerSource picks how published events are signed: through the NIP-46
+// bunker at NOSTR_BUNKER_URL when set, otherwise with the local key from the
+// configured KeyProvider. A bunker connection is made on first use and kept.
+type SignerSource struct {
+	mu        sync.Mutex
+	bunkerURL string
+	clientKey string
+	bunker    *BunkerSigner
+	newBunker func(bunkerURL, clientKey string) (*BunkerSigner, error)
+}
+
+func NewSignerSource(bunkerURL, clientKey string) *SignerSource {
+	return &SignerSource{bunkerURL: bunkerURL, clientKey: clientKey, newBunker: NewBunkerSigner}
+}
+
+var signers = NewSignerSource(os.Getenv("NOSTR_BUNKER_URL"), os.Getenv("NOSTR_BUNKER_CLIENT_KEY"))
+
+// Get returns the signer, connecting to the bunker if it isn't yet. A failed
+// connection is retried on the next call.
+func (s *SignerSource) Get(ctx context.Context) (EventSigner, error) {
+	if s.bunkerURL == "" {
+		nsec, err := loadSecretKey(ctx)
+		if err != nil {
+			return nil, err
+		}
+		sk, pub, err := decodeKey(nsec)
+		if err != nil {
+			return nil, err
+		}
+		return keySigner{sk: sk, pub: pub}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bunker != nil {
+		return s.bunker, nil
+	}
+	b, err := s.newBunker(s.bunkerURL, s.clientKey)
+	if err != nil {
+		return nil, err
+	}
+	if s.clientKey == "" {
+		log.Printf("NIP-46: no NOSTR_BUNKER_CLIENT_KEY set; using a new client key %s for this run", b.clientPub)
+	}
+	if err := b.Connect(ctx); err != nil {
+		return nil, err
+	}
+	s.bunker = b
+	return b, nil
+}
+
+// Status reports which signer is configured, for /stats.
+func (s *SignerSource) Status() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bunker != nil {
+		return s.bunker.Status()
+	}
+	if s.bunkerURL != "" {
+		return map[string]interface{}{"type": "nip46", "connected": false}
+	}
+	st := map[string]interface{}{"type": "local"}
+	if p, err := keyProviderFor(config.Get()); err == nil {
+		st["key_provider"] = p.Name()
+	}
+	return st
+}
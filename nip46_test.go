@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip44"
+	"github.com/nbd-wtf/go-nostr/nip46"
+)
+
+// fakeBunker answers NIP-46 requests the way a remote signer would, signing with userSK.
+type fakeBunker struct {
+	t        *testing.T
+	remoteSK string
+	remote   string
+	userSK   string
+	secret   string
+	mu       sync.Mutex
+	methods  []string
+	authOnce bool                                 // answer the first sign_event with auth_url first
+	tamper   func(ev *nostr.Event, userSK string) // change an event after signing it
+}
+
+func newFakeBunker(t *testing.T, secret string) *fakeBunker {
+	remoteSK := nostr.GeneratePrivateKey()
+	remote, _ := nostr.GetPublicKey(remoteSK)
+	return &fakeBunker{t: t, remoteSK: remoteSK, remote: remote, userSK: nostr.GeneratePrivateKey(), secret: secret}
+}
+
+// url returns a bunker:// URL for f on relay.
+func (f *fakeBunker) url(relay, secret string) string {
+	u := "bunker://" + f.remote + "?relay=" + relay
+	if secret != "" {
+		u += "&secret=" + secret
+	}
+	return u
+}
+
+// handle answers one request event with the events the bunker publishes back.
+func (f *fakeBunker) handle(ev nostr.Event) []*nostr.Event {
+	key, err := nip44.GenerateConversationKey(ev.PubKey, f.remoteSK)
+	if err != nil {
+		f.t.Error(err)
+		return nil
+	}
+	plain, err := nip44.Decrypt(ev.Content, key)
+	if err != nil {
+		f.t.Errorf("bunker could not decrypt request: %v", err)
+		return nil
+	}
+	var req nip46.Request
+	json.Unmarshal([]byte(plain), &req)
+	f.mu.Lock()
+	f.methods = append(f.methods, req.Method)
+	auth := f.authOnce && req.Method == "sign_event"
+	f.authOnce = f.authOnce && !auth
+	f.mu.Unlock()
+
+	resp := nip46.Response{ID: req.ID}
+	switch req.Method {
+	case "connect":
+		if f.secret != "" && (len(req.Params) < 2 || req.Params[1] != f.secret) {
+			resp.Error = "invalid secret"
+		} else {
+			resp.Result = "ack"
+		}
+	case "get_public_key":
+		resp.Result, _ = nostr.GetPublicKey(f.userSK)
+	case "sign_event":
+		var unsigned nostr.Event
+		json.Unmarshal([]byte(req.Params[0]), &unsigned)
+		unsigned.Sign(f.userSK)
+		if f.tamper != nil {
+			f.tamper(&unsigned, f.userSK)
+		}
+		signed, _ := json.Marshal(unsigned)
+		resp.Result = string(signed)
+	default:
+		resp.Error = "unsupported"
+	}
+	var out []*nostr.Event
+	if auth {
+		out = append(out, f.reply(ev.PubKey, key, nip46.Response{ID: req.ID, Result: "auth_url", Error: "https://bunker.example/approve"}))
+	}
+	return append(out, f.reply(ev.PubKey, key, resp))
+}
+
+func (f *fakeBunker) reply(client string, key [32]byte, resp nip46.Response) *nostr.Event {
+	body, _ := json.Marshal(resp)
+	content, _ := nip44.Encrypt(string(body), key)
+	ev := &nostr.Event{CreatedAt: nostr.Now(), Kind: nostr.KindNostrConnect, Tags: nostr.Tags{{"p", client}}, Content: content}
+	ev.Sign(f.remoteSK)
+	return ev
+}
+
+// newBunkerRelay serves a minimal relay that keeps every event, and hands
+// requests addressed to f (when not nil) to the fake bunker.
+func newBunkerRelay(t *testing.T, f *fakeBunker) string {
+	type sub struct {
+		conn    *websocket.Conn
+		id      string
+		filters nostr.Filters
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var subs []sub
+	var stored []*nostr.Event
+	send := func(c *websocket.Conn, msg ...interface{}) {
+		raw, _ := json.Marshal(msg)
+		c.Write(ctx, websocket.MessageText, raw)
+	}
+	broadcast := func(ev *nostr.Event) {
+		mu.Lock()
+		stored = append(stored, ev)
+		targets := append([]sub(nil), subs...)
+		mu.Unlock()
+		for _, s := range targets {
+			if s.filters.Match(ev) {
+				send(s.conn, "EVENT", s.id, ev)
+			}
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.CloseNow()
+		for {
+			_, raw, err := c.Read(ctx)
+			if err != nil {
+				return
+			}
+			var msg []json.RawMessage
+			if json.Unmarshal(raw, &msg) != nil || len(msg) < 2 {
+				continue
+			}
+			var typ string
+			json.Unmarshal(msg[0], &typ)
+			switch typ {
+			case "EVENT":
+				var ev nostr.Event
+				json.Unmarshal(msg[1], &ev)
+				send(c, "OK", ev.ID, true, "")
+				broadcast(&ev)
+				if p := ev.Tags.Find("p"); f != nil && p != nil && p[1] == f.remote {
+					go func() {
+						for _, reply := range f.handle(ev) {
+							broadcast(reply)
+						}
+					}()
+				}
+			case "REQ":
+				var id string
+				json.Unmarshal(msg[1], &id)
+				var filters nostr.Filters
+				for _, rawFilter := range msg[2:] {
+					var filter nostr.Filter
+					json.Unmarshal(rawFilter, &filter)
+					filters = append(filters, filter)
+				}
+				mu.Lock()
+				subs = append(subs, sub{c, id, filters})
+				past := append([]*nostr.Event(nil), stored...)
+				mu.Unlock()
+				// replies published before the subscription arrived still reach it
+				for _, ev := range past {
+					if filters.Match(ev) {
+						send(c, "EVENT", id, ev)
+					}
+				}
+				send(c, "EOSE", id)
+			}
+		}
+	}))
+	t.Cleanup(func() {
+		cancel()
+		srv.Close()
+	})
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestParseBunkerURL(t *testing.T) {
+	remote := padHex(53001)
+	got, relays, secret, err := parseBunkerURL("bunker://" + remote + "?relay=wss://a.example&relay=wss://b.example&secret=s3")
+	if err != nil || got != remote || len(relays) != 2 || secret != "s3" {
+		t.Errorf("unexpected parse %s %v %q %v", got, relays, secret, err)
+	}
+	for _, bad := range []string{
+		"nostrconnect://" + remote + "?relay=wss://a.example",
+		"bunker://nothex?relay=wss://a.example",
+		"bunker://" + remote,
+	} {
+		if _, _, _, err := parseBunkerURL(bad); err == nil {
+			t.Errorf("expected %q refused", bad)
+		}
+	}
+}
+
+func TestBunkerSignerSignsThroughRemote(t *testing.T) {
+	f := newFakeBunker(t, "s3cret")
+	f.authOnce = true
+	b, err := NewBunkerSigner(f.url(newBunkerRelay(t, f), "s3cret"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	userPub, _ := nostr.GetPublicKey(f.userSK)
+	if b.PublicKey() != userPub {
+		t.Fatalf("expected to sign as %s, got %s", userPub, b.PublicKey())
+	}
+
+	// the first sign_event is answered with auth_url; the real response follows
+	ev := nostr.Event{CreatedAt: nostr.Now(), Kind: 30382, Tags: nostr.Tags{{"d", padHex(53002)}, {"rank", "42"}}}
+	if err := b.Sign(context.Background(), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := ev.CheckSignature(); !ok || err != nil || ev.PubKey != userPub {
+		t.Errorf("expected a valid signature by the bunker's user, got %v %v", ok, err)
+	}
+	if s := b.Status(); s["requests"] != 3 || s["failures"] != 0 {
+		t.Errorf("unexpected status %v", s)
+	}
+	if strings.Join(f.methods, ",") != "connect,get_public_key,sign_event" {
+		t.Errorf("unexpected requests %v", f.methods)
+	}
+}
+
+func TestBunkerSignerRejectsBadResponses(t *testing.T) {
+	f := newFakeBunker(t, "right")
+	relay := newBunkerRelay(t, f)
+	b, _ := NewBunkerSigner(f.url(relay, "wrong"), "")
+	if err := b.Connect(context.Background()); err == nil || !strings.Contains(err.Error(), "invalid secret") {
+		t.Errorf("expected the bunker's refusal, got %v", err)
+	}
+
+	b, _ = NewBunkerSigner(f.url(relay, "right"), "")
+	if err := b.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	f.tamper = func(ev *nostr.Event, sk string) {
+		ev.Tags = nostr.Tags{{"d", padHex(53003)}, {"rank", "100"}}
+		ev.Sign(sk)
+	}
+	ev := nostr.Event{CreatedAt: nostr.Now(), Kind: 30382, Tags: nostr.Tags{{"d", padHex(53003)}, {"rank", "1"}}}
+	if err := b.Sign(context.Background(), &ev); err == nil {
+		t.Error("expected a different event from the bunker refused")
+	}
+	f.tamper = func(ev *nostr.Event, _ string) { ev.Sig = strings.Repeat("0", 128) }
+	if err := b.Sign(context.Background(), &ev); err == nil {
+		t.Error("expected a bad signature refused")
+	}
+	if ev.Sig != "" {
+		t.Error("expected the event left unsigned")
+	}
+
+	// a bunker that never answers times out with the caller's context
+	silent, _ := NewBunkerSigner(f.url(newBunkerRelay(t, nil), ""), "")
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := silent.Connect(ctx); err == nil || !strings.Contains(err.Error(), "no response") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestSignerSourceUsesBunkerWhenConfigured(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	t.Setenv("NOSTR_NSEC", sk)
+	local := NewSignerSource("", "")
+	if s, err := local.Get(context.Background()); err != nil || s.PublicKey() != pub {
+		t.Fatalf("expected the local key, got %v", err)
+	}
+	if local.Status()["type"] != "local" {
+		t.Errorf("unexpected status %v", local.Status())
+	}
+
+	f := newFakeBunker(t, "")
+	clientKey := nostr.GeneratePrivateKey()
+	clientPub, _ := nostr.GetPublicKey(clientKey)
+	src := NewSignerSource(f.url(newBunkerRelay(t, f), ""), clientKey)
+	connects := 0
+	src.newBunker = func(bunkerURL, key string) (*BunkerSigner, error) {
+		connects++
+		return NewBunkerSigner(bunkerURL, key)
+	}
+	for i := 0; i < 2; i++ {
+		s, err := src.Get(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if userPub, _ := nostr.GetPublicKey(f.userSK); s.PublicKey() != userPub {
+			t.Errorf("expected the bunker's user pubkey, got %s", s.PublicKey())
+		}
+	}
+	defer src.bunker.Close()
+	if connects != 1 {
+		t.Errorf("expected one connection reused, got %d", connects)
+	}
+	if st := src.Status(); st["client_pubkey"] != clientPub || st["type"] != "nip46" {
+		t.Errorf("expected the configured client key in use, got %v", st)
+	}
+}
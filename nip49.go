@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// NIP-49 private key decryption, for encrypted key files: scrypt derives a key
+// from the password, and XChaCha20-Poly1305 decrypts the secret key.
+
+const (
+	nip49Version = 0x02
+	// nip49MaxLogN caps scrypt's cost. log_n 22 already needs 4 GiB.
+	nip49MaxLogN = 22
+)
+
+var errNIP49Password = errors.New("ncryptsec: wrong password or corrupted key")
+
+// nip49Decrypt decrypts an ncryptsec1... key with password and returns the
+// secret key as hex. The password is used as given; NIP-49 asks clients to
+// NFKC-normalize it, which only matters for non-ASCII passwords.
+func nip49Decrypt(ncryptsec, password string) (string, error) {
+	hrp, data5, err := bech32.DecodeNoLimit(ncryptsec)
+	if err != nil {
+		return "", fmt.Errorf("ncryptsec: %w", err)
+	}
+	if hrp != "ncryptsec" {
+		return "", fmt.Errorf("ncryptsec: unexpected prefix %q", hrp)
+	}
+	data, err := bech32.ConvertBits(data5, 5, 8, false)
+	if err != nil {
+		return "", fmt.Errorf("ncryptsec: %w", err)
+	}
+	// version, log_n, 16-byte salt, 24-byte nonce, key security byte, 32-byte key + 16-byte tag
+	if len(data) != 91 || data[0] != nip49Version {
+		return "", fmt.Errorf("ncryptsec: unsupported format")
+	}
+	logN := int(data[1])
+	if logN < 1 || logN > nip49MaxLogN {
+		return "", fmt.Errorf("ncryptsec: log_n %d out of range", logN)
+	}
+	salt, nonce, ad, sealed := data[2:18], data[18:42], data[42:43], data[43:]
+
+	key, err := scrypt.Key([]byte(password), salt, 1<<logN, 8, 1, 32)
+	if err != nil {
+		return "", fmt.Errorf("ncryptsec: %w", err)
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return "", fmt.Errorf("ncryptsec: %w", err)
+	}
+	sk, err := aead.Open(nil, nonce, sealed, ad)
+	if err != nil {
+		return "", errNIP49Password
+	}
+	return hex.EncodeToString(sk), nil
+}
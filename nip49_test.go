@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+// Vector from the NIP-49 specification.
+func TestNIP49Decrypt(t *testing.T) {
+	const ncryptsec = "ncryptsec1qgg9947rlpvqu76pj5ecreduf9jxhselq2nae2kghhvd5g7dgjtcxfqtd67p9m0w57lspw8gsq6yphnm8623nsl8xn9j4jdzz84zm3frztj3z7s35vpzmqf6ksu8r89qk5z2zxfmu5gv8th8wclt0h4p"
+	sk, err := nip49Decrypt(ncryptsec, "nostr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sk != "3501454135014541350145413501453fefb02227e449e57cf4d3a3ce05378683" {
+		t.Errorf("unexpected key %s", sk)
+	}
+	if _, err := nip49Decrypt(ncryptsec, "nostr!"); err != errNIP49Password {
+		t.Errorf("expected a wrong password refused, got %v", err)
+	}
+	if _, err := nip49Decrypt("nsec1vl029mgpspedva04g90vltkh6fvh240zqtv9k0t9af8935ke9laqsnlfe5", "nostr"); err == nil {
+		t.Error("expected a plain nsec refused")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// nip98MaxSkew is how far a NIP-98 auth event's created_at may drift from now.
+const nip98MaxSkew = 60 * time.Second
+
+// verifyNIP98 authenticates a request signed per NIP-98 (HTTP Auth).
+// The Authorization header must be "Nostr <base64 kind 27235 event>" whose
+// u and method tags match this request. If the event carries a payload tag,
+// it must equal the SHA-256 of body. Returns the signer's pubkey.
+func verifyNIP98(r *http.Request, body []byte) (string, error) {
+	ev, err := verifyNIP98Event(r, body)
+	if err != nil {
+		return "", err
+	}
+	return ev.PubKey, nil
+}
+
+// verifyNIP98Event is verifyNIP98 returning the whole auth event, for callers
+// that also need its id or tags.
+func verifyNIP98Event(r *http.Request, body []byte) (*nostr.Event, error) {
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	if !strings.HasPrefix(auth, "Nostr ") {
+		return nil, fmt.Errorf("missing NIP-98 Authorization header")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(auth, "Nostr ")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 in Authorization header")
+	}
+
+	var ev nostr.Event
+	if err := json.Unmarshal(raw, &ev); err != nil {
+		return nil, fmt.Errorf("invalid auth event JSON")
+	}
+	if ev.Kind != 27235 {
+		return nil, fmt.Errorf("auth event must be kind 27235, got %d", ev.Kind)
+	}
+
+	skew := time.Since(ev.CreatedAt.Time())
+	if skew > nip98MaxSkew || skew < -nip98MaxSkew {
+		return nil, fmt.Errorf("auth event created_at outside allowed window")
+	}
+
+	if !ev.CheckID() {
+		return nil, fmt.Errorf("auth event id mismatch")
+	}
+	if ok, err := ev.CheckSignature(); err != nil || !ok {
+		return nil, fmt.Errorf("invalid auth event signature")
+	}
+
+	u := ev.Tags.Find("u")
+	if u == nil || !nip98URLMatches(u[1], r) {
+		return nil, fmt.Errorf("auth event u tag does not match request URL")
+	}
+	method := ev.Tags.Find("method")
+	if method == nil || !strings.EqualFold(method[1], r.Method) {
+		return nil, fmt.Errorf("auth event method tag does not match request method")
+	}
+
+	if payload := ev.Tags.Find("payload"); payload != nil {
+		sum := sha256.Sum256(body)
+		if !strings.EqualFold(payload[1], hex.EncodeToString(sum[:])) {
+			return nil, fmt.Errorf("auth event payload hash does not match request body")
+		}
+	}
+
+	return &ev, nil
+}
+
+// nip98URLMatches compares the signed URL with the request as the client sent
+// it, /v1 prefix included. The scheme is not compared because TLS is usually
+// terminated by a reverse proxy in front of us.
+func nip98URLMatches(signed string, r *http.Request) bool {
+	u, err := url.Parse(signed)
+	if err != nil {
+		return false
+	}
+	host := r.Host
+	if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+		host = fwd
+	}
+	return strings.EqualFold(u.Host, host) &&
+		u.Path == requestPath(r) &&
+		u.RawQuery == r.URL.RawQuery
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// nip98Header builds a signed NIP-98 Authorization header value.
+func nip98Header(t *testing.T, sk, method, url string, body []byte, createdAt time.Time) string {
+	t.Helper()
+	ev := nostr.Event{
+		Kind:      27235,
+		CreatedAt: nostr.Timestamp(createdAt.Unix()),
+		Tags: nostr.Tags{
+			{"u", url},
+			{"method", method},
+		},
+	}
+	if body != nil {
+		sum := sha256.Sum256(body)
+		ev.Tags = append(ev.Tags, nostr.Tag{"payload", hex.EncodeToString(sum[:])})
+	}
+	if err := ev.Sign(sk); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	raw, _ := json.Marshal(ev)
+	return "Nostr " + base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestVerifyNIP98Valid(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	body := []byte(`{"hello":"world"}`)
+
+	req := httptest.NewRequest("POST", "http://wot.example/annotations", strings.NewReader(string(body)))
+	req.Header.Set("Authorization", nip98Header(t, sk, "POST", "https://wot.example/annotations", body, time.Now()))
+
+	got, err := verifyNIP98(req, body)
+	if err != nil {
+		t.Fatalf("expected valid auth, got %v", err)
+	}
+	if got != pub {
+		t.Errorf("expected pubkey %s, got %s", pub, got)
+	}
+}
+
+func TestVerifyNIP98Rejects(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	body := []byte(`{}`)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		header string
+		body   []byte
+	}{
+		{"missing header", "", body},
+		{"wrong scheme", "Bearer abc", body},
+		{"bad base64", "Nostr !!!", body},
+		{"wrong url", nip98Header(t, sk, "POST", "https://wot.example/other", body, now), body},
+		{"wrong method", nip98Header(t, sk, "GET", "https://wot.example/annotations", body, now), body},
+		{"stale", nip98Header(t, sk, "POST", "https://wot.example/annotations", body, now.Add(-5*time.Minute)), body},
+		{"payload mismatch", nip98Header(t, sk, "POST", "https://wot.example/annotations", body, now), []byte(`{"x":1}`)},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "http://wot.example/annotations", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		if _, err := verifyNIP98(req, tt.body); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestVerifyNIP98TamperedSignature(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	ev := nostr.Event{
+		Kind:      27235,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"u", "https://wot.example/annotations"}, {"method", "POST"}},
+	}
+	ev.Sign(sk)
+	ev.PubKey = padHex(7001)
+	raw, _ := json.Marshal(ev)
+
+	req := httptest.NewRequest("POST", "http://wot.example/annotations", nil)
+	req.Header.Set("Authorization", "Nostr "+base64.StdEncoding.EncodeToString(raw))
+	if _, err := verifyNIP98(req, nil); err == nil {
+		t.Fatal("expected error for tampered event")
+	}
+}
+
+func TestNIP98URLMatchesForwardedHost(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1:8090/annotations?pubkey=abc", nil)
+	req.Header.Set("X-Forwarded-Host", "wot.klabo.world")
+	if !nip98URLMatches("https://wot.klabo.world/annotations?pubkey=abc", req) {
+		t.Error("expected forwarded host to match")
+	}
+	if nip98URLMatches("https://wot.klabo.world/annotations", req) {
+		t.Error("expected query mismatch to fail")
+	}
+}
+
+func TestVerifyNIP98VersionedPath(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	body := []byte(`{}`)
+	for signed, want := range map[string]bool{
+		"https://wot.example/v1/annotations": true,
+		"https://wot.example/annotations":    false,
+	} {
+		var err error
+		h := APIVersionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/annotations" {
+				t.Errorf("expected the handler to see /annotations, got %s", r.URL.Path)
+			}
+			_, err = verifyNIP98(r, body)
+		}))
+		req := httptest.NewRequest("POST", "http://wot.example/v1/annotations", strings.NewReader(string(body)))
+		req.Header.Set("Authorization", nip98Header(t, sk, "POST", signed, body, time.Now()))
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if (err == nil) != want {
+			t.Errorf("%s: expected accepted=%v, got %v", signed, want, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"net/url"
+	"sort"
+)
+
+// normalizationCurves map raw PageRank to the 0-100 score. log is the original
+// curve and the default; the others are fitted to the score distribution of the
+// last rebuild.
+var normalizationCurves = map[string]string{
+	"log":        "log10(raw/avg + 1) * 25, capped at 100",
+	"percentile": "share of scored pubkeys with a lower raw score * 100",
+	"zscore":     "50 + 50/3 * z of ln(raw) against all scored pubkeys, clamped to 0-100",
+	"minmax":     "ln(raw) scaled linearly from the lowest (0) to the highest (100) raw score",
+}
+
+// rawScoreDistribution summarizes the raw scores of one rebuild for the curves
+// that need more than the graph size.
+type rawScoreDistribution struct {
+	sorted          []float64 // ascending, positive scores only
+	logMean, logStd float64
+	logMin, logMax  float64
+}
+
+func computeRawScoreDistribution(scores map[string]float64) *rawScoreDistribution {
+	d := &rawScoreDistribution{sorted: make([]float64, 0, len(scores))}
+	for _, s := range scores {
+		if s > 0 {
+			d.sorted = append(d.sorted, s)
+		}
+	}
+	if len(d.sorted) == 0 {
+		return d
+	}
+	sort.Float64s(d.sorted)
+	d.logMin, d.logMax = math.Log(d.sorted[0]), math.Log(d.sorted[len(d.sorted)-1])
+	for _, s := range d.sorted {
+		d.logMean += math.Log(s)
+	}
+	d.logMean /= float64(len(d.sorted))
+	for _, s := range d.sorted {
+		diff := math.Log(s) - d.logMean
+		d.logStd += diff * diff
+	}
+	d.logStd = math.Sqrt(d.logStd / float64(len(d.sorted)))
+	return d
+}
+
+// Normalize maps raw to 0-100 on curve. total is the graph size the log curve
+// averages over. An unscored pubkey is 0 on every curve.
+func (d *rawScoreDistribution) Normalize(raw float64, total int, curve string) int {
+	if curve == "" || curve == "log" {
+		return normalizeScore(raw, total)
+	}
+	n := len(d.sorted)
+	if raw <= 0 || n == 0 {
+		return 0
+	}
+	var score float64
+	switch curve {
+	case "percentile":
+		if n == 1 {
+			return 100
+		}
+		below := sort.SearchFloat64s(d.sorted, raw)
+		score = float64(below) / float64(n-1) * 100
+	case "zscore":
+		if d.logStd == 0 {
+			return 50
+		}
+		score = 50 + (math.Log(raw)-d.logMean)/d.logStd*50/3
+	case "minmax":
+		if d.logMax == d.logMin {
+			return 100
+		}
+		score = (math.Log(raw) - d.logMin) / (d.logMax - d.logMin) * 100
+	}
+	return int(math.Round(math.Max(0, math.Min(100, score))))
+}
+
+// RefreshRawScoreDistribution recomputes the distribution the fitted curves use.
+// The rebuild calls it with RefreshPercentileBuckets.
+func (g *Graph) RefreshRawScoreDistribution() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.changed()
+	g.dist = computeRawScoreDistribution(g.scores)
+}
+
+// NormalizeScore maps raw to 0-100 on curve ("" for the configured default).
+// Before the first rebuild the fitted curves use the current scores.
+func (g *Graph) NormalizeScore(raw float64, curve string) int {
+	if curve == "" {
+		curve = config.Get().Normalization
+	}
+	g.mu.RLock()
+	d, total := g.dist, len(g.scores)
+	g.mu.RUnlock()
+	if d == nil && curve != "log" {
+		g.mu.RLock()
+		d = computeRawScoreDistribution(g.scores)
+		g.mu.RUnlock()
+	}
+	return d.Normalize(raw, total, curve)
+}
+
+// parseNormalization reads ?normalization=, falling back to the configured curve.
+// ok is false for an unknown curve.
+func parseNormalization(q url.Values) (curve string, ok bool) {
+	curve = q.Get("normalization")
+	if curve == "" {
+		return config.Get().Normalization, true
+	}
+	_, ok = normalizationCurves[curve]
+	return curve, ok
+}
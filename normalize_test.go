@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRawScoreDistributionCurves(t *testing.T) {
+	scores := map[string]float64{"a": 0.001, "b": 0.01, "c": 0.1, "d": 1, "e": 0}
+	d := computeRawScoreDistribution(scores)
+	if len(d.sorted) != 4 {
+		t.Fatalf("expected zero scores left out, got %v", d.sorted)
+	}
+
+	for _, tc := range []struct {
+		curve string
+		raw   float64
+		want  int
+	}{
+		{"percentile", 0.001, 0},
+		{"percentile", 0.01, 33},
+		{"percentile", 1, 100},
+		{"minmax", 0.001, 0},
+		{"minmax", 0.1, 67},
+		{"minmax", 1, 100},
+		{"zscore", math.Sqrt(0.01 * 0.1), 50}, // the geometric mean
+		{"zscore", 1e-12, 0},
+		{"percentile", 0, 0},
+		{"zscore", 0, 0},
+	} {
+		if got := d.Normalize(tc.raw, len(scores), tc.curve); got != tc.want {
+			t.Errorf("%s(%g) = %d, want %d", tc.curve, tc.raw, got, tc.want)
+		}
+	}
+	if got, want := d.Normalize(0.1, len(scores), "log"), normalizeScore(0.1, len(scores)); got != want {
+		t.Errorf("log curve = %d, want normalizeScore's %d", got, want)
+	}
+
+	for _, curve := range []string{"percentile", "zscore", "minmax"} {
+		prev := -1
+		for _, raw := range []float64{0.001, 0.01, 0.1, 1} {
+			got := d.Normalize(raw, len(scores), curve)
+			if got < prev {
+				t.Errorf("%s is not monotonic: %d after %d", curve, got, prev)
+			}
+			prev = got
+		}
+	}
+}
+
+func TestRawScoreDistributionSingleScore(t *testing.T) {
+	d := computeRawScoreDistribution(map[string]float64{"a": 0.5})
+	for curve, want := range map[string]int{"percentile": 100, "zscore": 50, "minmax": 100} {
+		if got := d.Normalize(0.5, 1, curve); got != want {
+			t.Errorf("%s = %d, want %d", curve, got, want)
+		}
+	}
+}
+
+func TestGraphNormalizeScoreBeforeRebuild(t *testing.T) {
+	g := NewGraph()
+	g.AddFollow(padHex(1), padHex(2))
+	g.AddFollow(padHex(3), padHex(2))
+	g.AddFollow(padHex(2), padHex(1))
+	g.ComputePageRank(20, 0.85)
+
+	top, _ := g.GetScore(padHex(2))
+	if got := g.NormalizeScore(top, "percentile"); got != 100 {
+		t.Errorf("expected the top pubkey at percentile 100 without a refreshed distribution, got %d", got)
+	}
+	if got, want := g.NormalizeScore(top, ""), normalizeScore(top, g.NodeCount()); got != want {
+		t.Errorf("expected the configured log curve by default, got %d want %d", got, want)
+	}
+	g.RefreshRawScoreDistribution()
+	if got := g.NormalizeScore(top, "minmax"); got != 100 {
+		t.Errorf("expected the top pubkey at minmax 100, got %d", got)
+	}
+}
+
+func TestNormalizationParam(t *testing.T) {
+	oldGraph, oldMeta := graph, meta
+	defer func() { graph, meta = oldGraph, oldMeta }()
+	meta = NewMetaStore()
+	graph = NewGraph()
+	top := padHex(2)
+	for i := 3; i < 13; i++ {
+		graph.AddFollow(padHex(i), top)
+		graph.AddFollow(padHex(i), padHex(i+1))
+	}
+	graph.ComputePageRank(20, 0.85)
+	graph.RefreshRawScoreDistribution()
+
+	rr := httptest.NewRecorder()
+	handleScore(rr, httptest.NewRequest(http.MethodGet, "/score?pubkey="+top+"&normalization=percentile", nil))
+	var score struct {
+		Score         int    `json:"score"`
+		Normalization string `json:"normalization"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &score)
+	if rr.Code != http.StatusOK || score.Normalization != "percentile" || score.Score != 100 {
+		t.Errorf("expected the top pubkey at percentile 100, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handleScore(rr, httptest.NewRequest(http.MethodGet, "/score?pubkey="+top, nil))
+	score.Normalization = ""
+	json.Unmarshal(rr.Body.Bytes(), &score)
+	if score.Normalization != "log" {
+		t.Errorf("expected the log curve by default, got %q", score.Normalization)
+	}
+
+	for _, path := range []string{"/score", "/audit"} {
+		rr = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path+"?pubkey="+top+"&normalization=sigmoid", nil)
+		if path == "/score" {
+			handleScore(rr, req)
+		} else {
+			handleAudit(rr, req)
+		}
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 for an unknown curve, got %d", path, rr.Code)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	handleAudit(rr, httptest.NewRequest(http.MethodGet, "/audit?pubkey="+top+"&normalization=minmax", nil))
+	var audit struct {
+		PageRank struct {
+			NormalizedScore int    `json:"normalized_score"`
+			Curve           string `json:"normalization_curve"`
+		} `json:"pagerank"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &audit)
+	if audit.PageRank.Curve != "minmax" || audit.PageRank.NormalizedScore != 100 {
+		t.Errorf("expected the top pubkey at minmax 100 in the audit, got %s", rr.Body.String())
+	}
+}
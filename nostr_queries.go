@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// queryRequestKind is a WoT query addressed to a provider. As with NIP-90 job
+	// requests, the answer is the kind 1000 higher and errors are kind 7000
+	// feedback.
+	queryRequestKind  = 5312
+	queryResponseKind = 6312
+	queryFeedbackKind = 7000
+	// queryMaxTargets caps the pubkeys one query asks about.
+	queryMaxTargets = 25
+	// queryRateLimit is how many queries one requester gets answered a minute.
+	queryRateLimit = 30
+	// queryMaxAge is how old a query may be and still be answered. Relays replay
+	// recent events when we resubscribe, and by then older queries' clients have
+	// given up.
+	queryMaxAge = 5 * time.Minute
+	// queryRetryInterval is how long the listener waits before subscribing again
+	// once its relays drop, and between checks while queries are off.
+	queryRetryInterval = time.Minute
+)
+
+// queryMethods are the methods a query can ask for; get-score is the default.
+var queryMethods = []string{"get-score", "get-rank"}
+
+// queryRequest is a parsed kind 5312 query.
+type queryRequest struct {
+	Method  string
+	Targets []string
+	View    string
+}
+
+// parseQuery reads a query's param tags: method, one or more target pubkeys
+// (hex or npub) and an optional seed view. The error is meant for the requester.
+func parseQuery(ev *nostr.Event) (queryRequest, error) {
+	q := queryRequest{Method: "get-score"}
+	for _, tag := range ev.Tags {
+		if len(tag) < 3 || tag[0] != "param" {
+			continue
+		}
+		switch tag[1] {
+		case "method":
+			if !slices.Contains(queryMethods, tag[2]) {
+				return q, fmt.Errorf("method must be one of %s", strings.Join(queryMethods, ", "))
+			}
+			q.Method = tag[2]
+		case "target":
+			pk, err := resolvePubkey(tag[2])
+			if err != nil || !hex64Pattern.MatchString(pk) {
+				return q, fmt.Errorf("invalid target %q", tag[2])
+			}
+			if !slices.Contains(q.Targets, pk) {
+				q.Targets = append(q.Targets, pk)
+			}
+		case "view":
+			q.View = tag[2]
+		}
+	}
+	if len(q.Targets) == 0 {
+		return q, fmt.Errorf("a target param is required")
+	}
+	if len(q.Targets) > queryMaxTargets {
+		return q, fmt.Errorf("at most %d targets are allowed", queryMaxTargets)
+	}
+	return q, nil
+}
+
+// queryResult answers method for pubkey in g, with the fields /score uses.
+func queryResult(g *Graph, method, pubkey string) map[string]interface{} {
+	raw, found := g.GetScore(pubkey)
+	nodes := g.NodeCount()
+	result := map[string]interface{}{
+		"pubkey":     pubkey,
+		"found":      found,
+		"graph_size": nodes,
+	}
+	switch method {
+	case "get-rank":
+		result["rank"] = g.Rank(pubkey)
+		result["percentile"] = math.Round(g.Percentile(pubkey)*10000) / 10000
+	default:
+		result["score"] = g.NormalizeScore(raw, "")
+		result["raw_score"] = raw
+		result["followers"] = len(g.GetFollowers(pubkey))
+	}
+	return result
+}
+
+// QueryResponder answers kind 5312 WoT queries sent to the service pubkey over
+// relays, so pure-Nostr clients can look up scores without HTTP. Each answer is
+// a signed kind 6312 event with the query's e and p tags and a JSON array of
+// results as content; a query that can't be answered gets kind 7000 feedback
+// with an error status. Duplicates (the same query from several relays), stale
+// queries and queries over a requester's rate limit are ignored.
+type QueryResponder struct {
+	mu       sync.Mutex
+	seen     map[string]time.Time   // query id -> when it arrived
+	recent   map[string][]time.Time // requester -> queries answered in the last minute
+	answered map[string]int         // method -> queries answered
+	failed   int
+	limited  int
+	now      func() time.Time
+}
+
+func NewQueryResponder() *QueryResponder {
+	return &QueryResponder{
+		seen:     make(map[string]time.Time),
+		recent:   make(map[string][]time.Time),
+		answered: make(map[string]int),
+		now:      time.Now,
+	}
+}
+
+var queryResponder = NewQueryResponder()
+
+// prune forgets queries older than queryMaxAge and answers older than a minute.
+// Callers hold qr.mu.
+func (qr *QueryResponder) prune(now time.Time) {
+	for id, at := range qr.seen {
+		if now.Sub(at) > queryMaxAge {
+			delete(qr.seen, id)
+		}
+	}
+	for pk, times := range qr.recent {
+		times = slices.DeleteFunc(times, func(at time.Time) bool { return now.Sub(at) >= time.Minute })
+		if len(times) == 0 {
+			delete(qr.recent, pk)
+		} else {
+			qr.recent[pk] = times
+		}
+	}
+}
+
+// Answer builds the unsigned response to ev, a query to pub: a kind 6312 result
+// or kind 7000 error feedback. It returns false for events it ignores.
+func (qr *QueryResponder) Answer(ev *nostr.Event, pub string) (nostr.Event, bool) {
+	now := qr.now()
+	if ev.Kind != queryRequestKind || !ev.Tags.ContainsAny("p", []string{pub}) || now.Sub(ev.CreatedAt.Time()) > queryMaxAge {
+		return nostr.Event{}, false
+	}
+	if ok, err := ev.CheckSignature(); !ok || err != nil {
+		return nostr.Event{}, false
+	}
+
+	qr.mu.Lock()
+	qr.prune(now)
+	if _, dup := qr.seen[ev.ID]; dup {
+		qr.mu.Unlock()
+		return nostr.Event{}, false
+	}
+	qr.seen[ev.ID] = now
+	if len(qr.recent[ev.PubKey]) >= queryRateLimit {
+		qr.limited++
+		qr.mu.Unlock()
+		return nostr.Event{}, false
+	}
+	qr.recent[ev.PubKey] = append(qr.recent[ev.PubKey], now)
+	qr.mu.Unlock()
+
+	q, err := parseQuery(ev)
+	var g *Graph
+	if err == nil {
+		var apiErr *APIError
+		if g, apiErr = seedViewGraph(q.View); apiErr != nil {
+			err = fmt.Errorf("%s", apiErr.Message)
+		} else if g.NodeCount() == 0 {
+			err = fmt.Errorf("graph not built yet")
+		}
+	}
+	if err != nil {
+		qr.mu.Lock()
+		qr.failed++
+		qr.mu.Unlock()
+		return nostr.Event{
+			CreatedAt: nostr.Timestamp(now.Unix()),
+			Kind:      queryFeedbackKind,
+			Tags:      nostr.Tags{{"status", "error", err.Error()}, {"e", ev.ID}, {"p", ev.PubKey}},
+		}, true
+	}
+
+	results := make([]map[string]interface{}, 0, len(q.Targets))
+	for _, pk := range q.Targets {
+		results = append(results, queryResult(g, q.Method, pk))
+	}
+	content, _ := json.Marshal(results)
+	qr.mu.Lock()
+	qr.answered[q.Method]++
+	qr.mu.Unlock()
+	return nostr.Event{
+		CreatedAt: nostr.Timestamp(now.Unix()),
+		Kind:      queryResponseKind,
+		Tags:      nostr.Tags{{"request", ev.String()}, {"e", ev.ID}, {"p", ev.PubKey}},
+		Content:   string(content),
+	}, true
+}
+
+// Handle answers ev, signing with signer and queueing the answer to our relays,
+// where the requester is subscribed. It returns how many events were queued.
+func (qr *QueryResponder) Handle(ctx context.Context, signer EventSigner, ev *nostr.Event) int {
+	resp, ok := qr.Answer(ev, signer.PublicKey())
+	if !ok {
+		return 0
+	}
+	if err := signer.Sign(ctx, &resp); err != nil {
+		log.Printf("Failed to sign kind %d answer to %s: %v", resp.Kind, shortKey(ev.PubKey), err)
+		return 0
+	}
+	return publishQueue.Enqueue([]nostr.Event{resp}, config.Relays())
+}
+
+// subscribeQueries streams the queries addressed to pub on our relays, from
+// since on, until ctx is done. Tests replace it.
+var subscribeQueries = func(ctx context.Context, pub string, since time.Time) <-chan *nostr.Event {
+	pool := nostr.NewSimplePool(ctx)
+	ts := nostr.Timestamp(since.Unix())
+	filter := nostr.Filter{Kinds: []int{queryRequestKind}, Tags: nostr.TagMap{"p": {pub}}, Since: &ts}
+	out := make(chan *nostr.Event)
+	go func() {
+		defer close(out)
+		for ie := range pool.SubscribeMany(ctx, config.Relays(), filter) {
+			select {
+			case out <- ie.Event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// listen answers queries until ctx is done, the relays drop or nostr_queries is
+// turned off.
+func (qr *QueryResponder) listen(ctx context.Context) error {
+	signer, err := signers.Get(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pub := signer.PublicKey()
+	log.Printf("Answering kind %d queries to %s", queryRequestKind, shortKey(pub))
+	for ev := range subscribeQueries(ctx, pub, qr.now().Add(-queryMaxAge)) {
+		if !config.Get().NostrQueries {
+			return nil
+		}
+		qr.Handle(ctx, signer, ev)
+	}
+	return nil
+}
+
+// Run answers queries while nostr_queries is on, until ctx is done.
+func (qr *QueryResponder) Run(ctx context.Context) {
+	for {
+		if config.Get().NostrQueries {
+			if err := qr.listen(ctx); err != nil {
+				log.Printf("Nostr queries paused: %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(queryRetryInterval):
+		}
+	}
+}
+
+// Stats summarizes the queries answered for /stats.
+func (qr *QueryResponder) Stats(cfg Config) map[string]interface{} {
+	qr.mu.Lock()
+	defer qr.mu.Unlock()
+	answered := make(map[string]int, len(qr.answered))
+	for method, n := range qr.answered {
+		answered[method] = n
+	}
+	return map[string]interface{}{
+		"enabled":      cfg.NostrQueries,
+		"request_kind": queryRequestKind,
+		"answered":     answered,
+		"errors":       qr.failed,
+		"rate_limited": qr.limited,
+	}
+}
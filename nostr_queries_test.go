@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func TestParseQuery(t *testing.T) {
+	npub, _ := nip19.EncodePublicKey(padHex(51851))
+	for name, tc := range map[string]struct {
+		tags    nostr.Tags
+		method  string
+		targets int
+		err     string
+	}{
+		"default method": {tags: nostr.Tags{{"param", "target", padHex(51850)}}, method: "get-score", targets: 1},
+		"rank, npub and duplicate": {tags: nostr.Tags{{"param", "method", "get-rank"}, {"param", "target", padHex(51851)},
+			{"param", "target", npub}, {"param", "target", padHex(51850)}}, method: "get-rank", targets: 2},
+		"unknown method": {tags: nostr.Tags{{"param", "method", "get-vibes"}, {"param", "target", padHex(51850)}}, err: "method must be"},
+		"bad target":     {tags: nostr.Tags{{"param", "target", "alice"}}, err: "invalid target"},
+		"no target":      {tags: nostr.Tags{{"p", padHex(51850)}}, err: "a target param is required"},
+	} {
+		q, err := parseQuery(&nostr.Event{Tags: tc.tags})
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: expected %q, got %v", name, tc.err, err)
+			}
+			continue
+		}
+		if err != nil || q.Method != tc.method || len(q.Targets) != tc.targets {
+			t.Errorf("%s: unexpected %+v %v", name, q, err)
+		}
+	}
+}
+
+// setupQueries installs a scored graph around padHex(51850) and returns the
+// service and requester keys.
+func setupQueries(t *testing.T) (serviceSK, servicePub, requesterSK string) {
+	old := graph
+	t.Cleanup(func() { graph = old })
+	graph = NewGraph()
+	for i := 1; i <= 10; i++ {
+		graph.AddFollow(padHex(51850+i), padHex(51850))
+		graph.AddFollow(padHex(51850), padHex(51850+i))
+	}
+	graph.ComputePageRank(20, 0.85)
+	serviceSK = nostr.GeneratePrivateKey()
+	servicePub, _ = nostr.GetPublicKey(serviceSK)
+	return serviceSK, servicePub, nostr.GeneratePrivateKey()
+}
+
+// signedQuery makes a kind 5312 query from sk to pub with params.
+func signedQuery(t *testing.T, sk, pub string, at time.Time, params ...string) *nostr.Event {
+	t.Helper()
+	ev := &nostr.Event{Kind: queryRequestKind, CreatedAt: nostr.Timestamp(at.Unix()), Tags: nostr.Tags{{"p", pub}}}
+	for i := 0; i+1 < len(params); i += 2 {
+		ev.Tags = append(ev.Tags, nostr.Tag{"param", params[i], params[i+1]})
+	}
+	if err := ev.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+	return ev
+}
+
+func TestQueryResponderAnswer(t *testing.T) {
+	_, servicePub, requesterSK := setupQueries(t)
+	qr := NewQueryResponder()
+	now := time.Now()
+	qr.now = func() time.Time { return now }
+
+	query := signedQuery(t, requesterSK, servicePub, now, "method", "get-rank", "target", padHex(51850), "target", padHex(51899))
+	resp, ok := qr.Answer(query, servicePub)
+	if !ok || resp.Kind != queryResponseKind || resp.Tags.FindWithValue("e", query.ID) == nil || resp.Tags.FindWithValue("p", query.PubKey) == nil {
+		t.Fatalf("expected a kind 6312 answer, got %v %+v", ok, resp)
+	}
+	var results []map[string]interface{}
+	if err := json.Unmarshal([]byte(resp.Content), &results); err != nil || len(results) != 2 {
+		t.Fatalf("expected two results, got %s", resp.Content)
+	}
+	if results[0]["rank"] != float64(1) || results[0]["found"] != true || results[1]["found"] != false || results[1]["graph_size"] != float64(11) {
+		t.Errorf("expected the hub ranked first and the stranger not found, got %v", results)
+	}
+	if req := resp.Tags.Find("request"); req == nil || !strings.Contains(req[1], query.ID) {
+		t.Errorf("expected the query echoed in a request tag, got %v", req)
+	}
+
+	// the same query from another relay, someone else's query and stale ones are ignored
+	if _, ok := qr.Answer(query, servicePub); ok {
+		t.Error("expected a duplicate ignored")
+	}
+	if _, ok := qr.Answer(signedQuery(t, requesterSK, padHex(51898), now, "target", padHex(51850)), servicePub); ok {
+		t.Error("expected a query to another provider ignored")
+	}
+	if _, ok := qr.Answer(signedQuery(t, requesterSK, servicePub, now.Add(-time.Hour), "target", padHex(51850)), servicePub); ok {
+		t.Error("expected a stale query ignored")
+	}
+	forged := signedQuery(t, requesterSK, servicePub, now, "target", padHex(51850))
+	forged.Tags = append(forged.Tags, nostr.Tag{"param", "target", padHex(51851)})
+	if _, ok := qr.Answer(forged, servicePub); ok {
+		t.Error("expected an event with a bad signature ignored")
+	}
+
+	resp, ok = qr.Answer(signedQuery(t, requesterSK, servicePub, now, "method", "get-vibes", "target", padHex(51850)), servicePub)
+	if status := resp.Tags.Find("status"); !ok || resp.Kind != queryFeedbackKind || len(status) != 3 || status[1] != "error" {
+		t.Errorf("expected kind 7000 error feedback, got %+v", resp)
+	}
+
+	// two answered so far; the rest of the minute's allowance, then nothing
+	for i := 0; i < queryRateLimit; i++ {
+		qr.Answer(signedQuery(t, requesterSK, servicePub, now, "target", padHex(51860+i)), servicePub)
+	}
+	stats := qr.Stats(defaultConfig)
+	if answered := stats["answered"].(map[string]int); answered["get-rank"] != 1 || answered["get-score"] != queryRateLimit-2 ||
+		stats["errors"] != 1 || stats["rate_limited"] != 2 {
+		t.Errorf("unexpected stats %v", stats)
+	}
+	now = now.Add(time.Minute)
+	if _, ok := qr.Answer(signedQuery(t, requesterSK, servicePub, now, "target", padHex(51850)), servicePub); !ok {
+		t.Error("expected queries answered again a minute later")
+	}
+}
+
+func TestQueryResponderHandle(t *testing.T) {
+	serviceSK, servicePub, requesterSK := setupQueries(t)
+	old := publishQueue
+	t.Cleanup(func() { publishQueue = old })
+	publishQueue = NewPublishQueue("")
+
+	qr := NewQueryResponder()
+	query := signedQuery(t, requesterSK, servicePub, time.Now(), "target", padHex(51850))
+	if n := qr.Handle(context.Background(), keySigner{sk: serviceSK, pub: servicePub}, query); n != 1 || len(publishQueue.items) != 1 {
+		t.Fatalf("expected the answer queued, got %d", n)
+	}
+	for _, item := range publishQueue.items {
+		if ok, _ := item.Event.CheckSignature(); !ok || item.Event.PubKey != servicePub || item.Event.Kind != queryResponseKind {
+			t.Errorf("expected a signed answer from the service, got %+v", item.Event)
+		}
+		if len(item.Relays) != len(config.Relays()) {
+			t.Errorf("expected the answer queued to our relays, got %v", item.Relays)
+		}
+	}
+}
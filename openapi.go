@@ -15,7 +15,7 @@ const openAPISpec = `{
   "openapi": "3.0.3",
   "info": {
     "title": "WoT Scoring API",
-    "description": "NIP-85 Trusted Assertions provider for the Nostr Web of Trust. Computes PageRank trust scores over the Nostr follow graph, publishes all five NIP-85 assertion kinds, and provides 30+ endpoints for trust analysis, spam detection, identity verification, and graph visualization. L402 Lightning micropayments for sustained access.",
+    "description": "NIP-85 Trusted Assertions provider for the Nostr Web of Trust. Computes PageRank trust scores over the Nostr follow graph, publishes all five NIP-85 assertion kinds, and provides 30+ endpoints for trust analysis, spam detection, identity verification, and graph visualization. L402 Lightning micropayments for sustained access. Every JSON endpoint accepts ?format=npub to return pubkeys as npub, event ids as nevent, and addressable coordinates as naddr (NIP-19) instead of hex. Every path is also served under /v1, and successful JSON object responses carry schema_version (currently 1), which only changes when a field is removed, renamed or changes type.",
     "version": "1.0.0",
     "contact": {
       "name": "Max (SATMAX Agent)",
@@ -28,9 +28,13 @@ const openAPISpec = `{
     }
   },
   "servers": [
+    {
+      "url": "https://wot.klabo.world/v1",
+      "description": "Production, pinned to schema version 1"
+    },
     {
       "url": "https://wot.klabo.world",
-      "description": "Production"
+      "description": "Production, current version"
     }
   ],
   "tags": [
@@ -53,7 +57,8 @@ const openAPISpec = `{
     {"name": "Network Analysis", "description": "Graph topology health metrics and network-wide analysis"},
     {"name": "Cross-Provider", "description": "Compare WoT scores across multiple NIP-85 providers for consensus analysis"},
     {"name": "Trust Circles", "description": "Mutual-follow trust circle analysis with cohesion, density, and role metrics"},
-    {"name": "Follow Quality", "description": "Analyze the quality and health of a pubkey's follow list"}
+    {"name": "Follow Quality", "description": "Analyze the quality and health of a pubkey's follow list"},
+    {"name": "Annotations", "description": "Namespaced third-party annotations on pubkeys (NIP-98 authenticated)"}
   ],
   "paths": {
     "/score": {
@@ -61,14 +66,18 @@ const openAPISpec = `{
         "tags": ["Scoring"],
         "operationId": "getScore",
         "summary": "Get trust score for a pubkey",
-        "description": "Returns normalized PageRank trust score (0-100), composite score from external NIP-85 providers, follower count, engagement metrics, topics, active hours, and reports. Accepts hex pubkeys or NIP-19 npub format.",
+        "description": "Returns normalized PageRank trust score (0-100), composite score from external NIP-85 providers, percentile_bucket (1, 5, 10, 25 or 50 for the top N% of scored pubkeys as of the last rebuild, 100 for the rest), follower count, engagement metrics, topics, active hours, and reports. Pubkeys reported or muted by trusted accounts also get distrust_penalty (0-1, from Anti-TrustRank propagation of reports and mutes) and distrust_adjusted_score. With algorithm=hits, also returns HITS hub and authority scores (0-100, normalized like PageRank) and a role: curator (mostly a hub), producer (mostly an authority) or balanced. Accepts hex pubkeys or NIP-19 npub format.",
         "parameters": [
-          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"}
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
+          {"name": "algorithm", "in": "query", "schema": {"type": "string", "enum": ["pagerank", "hits"], "default": "pagerank"}, "description": "hits adds hub and authority scores; score stays PageRank"},
+          {"name": "normalization", "in": "query", "schema": {"type": "string", "enum": ["log", "percentile", "zscore", "minmax"]}, "description": "Curve mapping raw PageRank to 0-100; defaults to the configured curve (log)"},
+          {"name": "view", "in": "query", "schema": {"type": "string", "default": "default"}, "description": "Seed view to score in: one of the operator's seed groups listed under views in /stats, each crawled and scored from its own seeds. The response then carries view, and score, raw_score, graph_size and percentile_bucket come from that view's graph"}
         ],
         "responses": {
           "200": {"description": "Trust score response", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ScoreResponse"}}}},
-          "400": {"description": "Invalid or missing pubkey"},
-          "402": {"description": "L402 payment required (1 sat)"}
+          "400": {"description": "Invalid or missing pubkey, unknown normalization or unknown view"},
+          "402": {"description": "L402 payment required (1 sat)"},
+          "503": {"description": "The view has not been built yet"}
         }
       }
     },
@@ -77,13 +86,14 @@ const openAPISpec = `{
         "tags": ["Scoring"],
         "operationId": "getAudit",
         "summary": "Audit why a pubkey has its score",
-        "description": "Full transparency into score breakdown: PageRank component, engagement metrics, top followers with their scores, and external assertion details.",
+        "description": "Full transparency into score breakdown: PageRank component, engagement metrics, top followers with their scores, and external assertion details. A distrust section explains any penalty from reports and mutes: direct and propagated distrust, counted reporters and muters, and the highest-scored sources with their report types. A hits section gives the HITS hub and authority scores and the curator/producer/balanced role. When PAGERANK_MUTES=penalize, a mute_penalty section shows how many trusted muters counted against the pubkey and its score without mutes. pagerank.normalization_curve names the curve used for normalized_score and the top followers' scores, and pagerank.normalization describes it. broker_score (0-100) and betweenness give the pubkey's sampled graph-wide betweenness from the last rebuild: how many shortest paths between other accounts run through it.",
         "parameters": [
-          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"}
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
+          {"name": "normalization", "in": "query", "schema": {"type": "string", "enum": ["log", "percentile", "zscore", "minmax"]}, "description": "Curve mapping raw PageRank to 0-100; defaults to the configured curve (log)"}
         ],
         "responses": {
           "200": {"description": "Score audit breakdown"},
-          "400": {"description": "Invalid or missing pubkey"},
+          "400": {"description": "Invalid or missing pubkey, or unknown normalization"},
           "402": {"description": "L402 payment required (5 sats)"}
         }
       }
@@ -109,35 +119,238 @@ const openAPISpec = `{
           }
         },
         "responses": {
-          "200": {"description": "Batch score results"},
+          "200": {"description": "Batch score results", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/BatchResponse"}}}},
           "400": {"description": "Invalid request body"},
           "402": {"description": "L402 payment required (10 sats)"}
         }
       }
     },
+    "/audit/batch": {
+      "post": {
+        "tags": ["Scoring"],
+        "operationId": "batchAudit",
+        "summary": "Score audits for up to 50 pubkeys in one request",
+        "description": "The /audit breakdown of each pubkey, in request order. A pubkey that can't be resolved gets an entry with pubkey and error.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["pubkeys"],
+                "properties": {
+                  "pubkeys": {"type": "array", "items": {"type": "string"}, "maxItems": 50, "description": "Array of hex pubkeys or npubs"},
+                  "normalization": {"type": "string", "enum": ["log", "percentile", "zscore", "minmax"], "description": "Curve for the 0-100 scores; defaults to the configured curve"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Audits in request order, with count and graph_size"},
+          "400": {"description": "Invalid request body, too many pubkeys or unknown normalization"},
+          "402": {"description": "L402 payment required (10 sats)"}
+        }
+      }
+    },
+    "/personalized/batch": {
+      "post": {
+        "tags": ["Scoring"],
+        "operationId": "batchPersonalized",
+        "summary": "Personalized scores of up to 100 targets for one viewer",
+        "description": "The /personalized fields for each target, in request order. viewer, algorithm and graph_size are given once at the top level. A target that can't be resolved gets an entry with target and error.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["viewer", "targets"],
+                "properties": {
+                  "viewer": {"type": "string", "description": "Hex pubkey or npub of the viewer"},
+                  "targets": {"type": "array", "items": {"type": "string"}, "maxItems": 100, "description": "Hex pubkeys or npubs to score"},
+                  "algorithm": {"type": "string", "enum": ["blend", "ppr"], "default": "blend"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Personalized scores in request order"},
+          "400": {"description": "Invalid request body, viewer or algorithm, or too many targets"},
+          "402": {"description": "L402 payment required (10 sats)"}
+        }
+      }
+    },
+    "/graph/batch": {
+      "post": {
+        "tags": ["Scoring"],
+        "operationId": "batchGraphNeighborhood",
+        "summary": "Neighborhood graphs for up to 25 pubkeys",
+        "description": "The /graph neighborhood of each pubkey, in request order. depth and limit apply to every pubkey and are clamped as in /graph. A pubkey that can't be resolved gets an entry with pubkey and error.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["pubkeys"],
+                "properties": {
+                  "pubkeys": {"type": "array", "items": {"type": "string"}, "maxItems": 25, "description": "Array of hex pubkeys or npubs"},
+                  "depth": {"type": "integer", "minimum": 1, "maximum": 2, "default": 1},
+                  "limit": {"type": "integer", "minimum": 1, "maximum": 200, "default": 50, "description": "Neighbors per pubkey"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Neighborhoods in request order, with depth, limit and graph_size"},
+          "400": {"description": "Invalid request body or too many pubkeys"},
+          "402": {"description": "L402 payment required (10 sats)"}
+        }
+      }
+    },
+    "/rank": {
+      "post": {
+        "tags": ["Scoring"],
+        "operationId": "rankEvents",
+        "summary": "Rank a candidate feed of events",
+        "description": "Scores up to 500 events, best first, by a weighted blend of four 0-100 components: author (the author's WoT score), engagement (crawled reactions + 2x reposts + 3x comments + zapped sats, 25 points per tenfold), recency (halving every half_life_hours) and personal (100 when the viewer follows the author, otherwise 80k/(k+5) for k of the viewer's follows following them). Weights are relative and default to 0.4/0.3/0.2/0.1; personal is dropped without a viewer. Full signed events or {id, pubkey, created_at} objects are accepted; signatures aren't checked. Duplicate IDs are ranked once.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["events"],
+                "properties": {
+                  "events": {"type": "array", "maxItems": 500, "items": {"type": "object", "required": ["id", "pubkey"], "properties": {"id": {"type": "string"}, "pubkey": {"type": "string"}, "created_at": {"type": "integer"}}}},
+                  "viewer": {"type": "string", "description": "Hex pubkey or npub to personalize for"},
+                  "weights": {"type": "object", "properties": {"author": {"type": "number"}, "engagement": {"type": "number"}, "recency": {"type": "number"}, "personal": {"type": "number"}}},
+                  "half_life_hours": {"type": "number", "default": 24}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Events sorted by score with per-component breakdown and the normalized weights"},
+          "400": {"description": "Invalid request body, event, viewer or weights"},
+          "402": {"description": "L402 payment required (10 sats)"}
+        }
+      }
+    },
+    "/graphql": {
+      "post": {
+        "tags": ["Scoring"],
+        "operationId": "graphqlQuery",
+        "summary": "GraphQL query over scores, followers, communities and anomalies",
+        "description": "Runs a GraphQL query against the follow graph, metadata store and external assertions, so a client can combine score, top followers, community, anomalies, spam and activity for one or more profiles in a single request. Root fields: profile(pubkey), profiles(pubkeys, max 50), top(limit), stats. Fields are computed only when selected. Aliases, arguments and variables are supported; fragments, directives, mutations and introspection are not. Queries are limited to 8 levels of nesting and 1000 resolved profiles. Field errors are returned in the errors array alongside partial data.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["query"],
+                "properties": {
+                  "query": {"type": "string", "description": "GraphQL query document"},
+                  "variables": {"type": "object", "description": "Variable values"},
+                  "operationName": {"type": "string", "description": "Operation to run when the document defines several"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "GraphQL response with data and, for field errors, an errors array"},
+          "400": {"description": "Invalid JSON, missing query, or a query that fails to parse"},
+          "402": {"description": "L402 payment required (10 sats)"}
+        }
+      },
+      "get": {
+        "tags": ["Scoring"],
+        "operationId": "graphqlSchemaOrQuery",
+        "summary": "GraphQL schema, or a query via query parameters",
+        "description": "Without a query parameter, returns the schema in SDL form. With one, runs it like POST.",
+        "parameters": [
+          {"name": "query", "in": "query", "required": false, "schema": {"type": "string"}, "description": "GraphQL query document"},
+          {"name": "variables", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Variable values as a JSON object"},
+          {"name": "operationName", "in": "query", "required": false, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "The schema, or a GraphQL response"},
+          "400": {"description": "Invalid variables or a query that fails to parse"}
+        }
+      }
+    },
     "/personalized": {
       "get": {
         "tags": ["Personalized"],
         "operationId": "getPersonalized",
         "summary": "Personalized trust score relative to a viewer",
-        "description": "Scores a target pubkey from the perspective of a specific viewer. Blends global PageRank (50%) with social proximity signals (50%): direct follow, mutual follow, and trusted follower ratio.",
+        "description": "Scores a target pubkey from the perspective of a specific viewer. By default blends global PageRank (50%) with social proximity signals (50%): direct follow, mutual follow, and trusted follower ratio. With algorithm=ppr, runs personalized PageRank (random walk with restart at the viewer) so the score reflects trust propagated from the viewer's own follow set, and adds ppr_score, ppr_rank and ppr_reachable.",
         "parameters": [
           {"name": "viewer", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Viewer hex pubkey or npub"},
-          {"name": "target", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Target hex pubkey or npub"}
+          {"name": "target", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Target hex pubkey or npub"},
+          {"name": "algorithm", "in": "query", "required": false, "schema": {"type": "string", "enum": ["blend", "ppr"], "default": "blend"}, "description": "blend (global PageRank + proximity) or ppr (personalized PageRank rooted at the viewer)"}
         ],
         "responses": {
-          "200": {"description": "Personalized score with social proximity breakdown"},
+          "200": {"description": "Personalized score with social proximity breakdown", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/PersonalizedResponse"}}}},
           "400": {"description": "Missing or invalid parameters"},
           "402": {"description": "L402 payment required (2 sats)"}
         }
       }
     },
+    "/personalized/import": {
+      "post": {
+        "tags": ["Personalized"],
+        "operationId": "importViewerContacts",
+        "summary": "Import a viewer's signed contact list",
+        "description": "Verifies a signed kind 3 event and uses its p tags as the author's follows in their own /personalized, /personalized/batch and /recommend requests for 24 hours, reported as viewer_follows_source (follows_source in /recommend) = import. A newer list replaces an imported one; an older one returns status stale_event. The served graph is unchanged, and only that viewer's cached /personalized and /recommend responses get a new ETag.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["event"],
+                "properties": {
+                  "event": {"type": "object", "description": "Signed kind 3 event by the viewer"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "viewer, status (imported, replaced or stale_event), follows, event_id, event_at and expires_at"},
+          "400": {"description": "Not a kind 3 event, invalid signature, or created_at in the future"}
+        }
+      },
+      "delete": {
+        "tags": ["Personalized"],
+        "operationId": "deleteViewerContacts",
+        "summary": "Drop a viewer's imported contact list",
+        "description": "Requires a NIP-98 Authorization: Nostr <base64 kind 27235 event> for this URL and method, signed by the viewer.",
+        "parameters": [
+          {"name": "viewer", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Viewer hex pubkey or npub"}
+        ],
+        "responses": {
+          "200": {"description": "status deleted"},
+          "400": {"description": "Invalid viewer"},
+          "401": {"description": "Missing or invalid NIP-98 auth"},
+          "403": {"description": "Auth event not signed by the viewer"},
+          "404": {"description": "No imported contact list for viewer"}
+        }
+      }
+    },
     "/similar": {
       "get": {
         "tags": ["Graph"],
         "operationId": "getSimilar",
         "summary": "Find pubkeys with similar follow graphs",
-        "description": "Jaccard similarity (70%) + WoT score (30%) to discover pubkeys with overlapping follow sets.",
+        "description": "Jaccard similarity (70%) + WoT score (30%) to discover pubkeys with overlapping follow sets. Candidates come from a MinHash/LSH index over follow sets rebuilt after each crawl; method is lsh, or scan before the first build.",
         "parameters": [
           {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
           {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "default": 20, "minimum": 1, "maximum": 50}, "description": "Max results"}
@@ -154,14 +367,49 @@ const openAPISpec = `{
         "tags": ["Graph"],
         "operationId": "getRecommendations",
         "summary": "Follow recommendations via friends-of-friends",
-        "description": "Recommends pubkeys that many of your follows also follow, weighted by mutual follow ratio (60%) and WoT score (40%).",
+        "description": "Recommends pubkeys that many of your follows also follow, weighted by mutual follow ratio (60%) and WoT score (40%). diversity scales the ranking down for widely followed accounts; min_followers, max_followers and topics filter candidates.",
         "parameters": [
           {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
-          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "default": 20, "minimum": 1, "maximum": 50}, "description": "Max results"}
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "default": 20, "minimum": 1, "maximum": 50}, "description": "Max results"},
+          {"name": "diversity", "in": "query", "required": false, "schema": {"type": "number", "default": 0, "minimum": 0, "maximum": 1}, "description": "How strongly to penalize candidates most of the graph already follows"},
+          {"name": "min_followers", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 0}, "description": "Drop candidates with fewer followers"},
+          {"name": "max_followers", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 0}, "description": "Drop candidates with more followers (0 = no limit)"},
+          {"name": "topics", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Comma-separated hashtags; keep candidates that posted under at least one"}
         ],
         "responses": {
           "200": {"description": "Recommended pubkeys with mutual follower counts"},
-          "400": {"description": "Invalid or missing pubkey"},
+          "400": {"description": "Invalid or missing pubkey, or invalid options"},
+          "402": {"description": "L402 payment required (2 sats)"}
+        }
+      },
+      "post": {
+        "tags": ["Graph"],
+        "operationId": "postRecommendations",
+        "summary": "Follow recommendations with an exclude list",
+        "description": "The GET options in a JSON body, plus exclude: suggestions the client has dismissed, which are never returned.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["pubkey"],
+                "properties": {
+                  "pubkey": {"type": "string"},
+                  "limit": {"type": "integer", "default": 20, "minimum": 1, "maximum": 50},
+                  "diversity": {"type": "number", "default": 0, "minimum": 0, "maximum": 1},
+                  "min_followers": {"type": "integer", "minimum": 0},
+                  "max_followers": {"type": "integer", "minimum": 0},
+                  "topics": {"type": "array", "items": {"type": "string"}},
+                  "exclude": {"type": "array", "items": {"type": "string"}, "maxItems": 1000, "description": "Hex pubkeys or npubs to leave out"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Recommended pubkeys with mutual follower counts"},
+          "400": {"description": "Invalid request body or options"},
           "402": {"description": "L402 payment required (2 sats)"}
         }
       }
@@ -171,14 +419,23 @@ const openAPISpec = `{
         "tags": ["Graph"],
         "operationId": "getTrustPath",
         "summary": "Find shortest trust path between two pubkeys",
-        "description": "BFS shortest path through the follow graph (up to 6 hops). Each node annotated with WoT score. Also supports single pubkey info mode.",
+        "description": "BFS shortest path through the follow graph (up to 6 hops). Each node is annotated with WoT score and community; intermediate accounts also carry spam and sybil classifications and a flagged marker. hop_details qualifies each follow edge as mutual or one_way, with follow age, whether both ends share a community, and a plain-language explanation. Also supports single pubkey info mode.",
         "parameters": [
           {"name": "from", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Source hex pubkey or npub (for path mode)"},
           {"name": "to", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Destination hex pubkey or npub (for path mode)"},
-          {"name": "pubkey", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Single pubkey for info mode"}
+          {"name": "max_hops", "in": "query", "required": false, "schema": {"type": "integer", "default": 6, "minimum": 1, "maximum": 8}, "description": "Maximum path length in hops (path mode)"},
+          {"name": "avoid", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Comma-separated intermediate filters: spam (skip likely_spam accounts), low_score (skip accounts scoring at or below min_score)"},
+          {"name": "min_score", "in": "query", "required": false, "schema": {"type": "integer", "default": 20, "minimum": 0, "maximum": 100}, "description": "Score an intermediate must exceed with avoid=low_score"},
+          {"name": "k", "in": "query", "required": false, "schema": {"type": "integer", "default": 1, "minimum": 1, "maximum": 5}, "description": "Number of paths to return, cheapest first, under paths (path mode)"},
+          {"name": "weighted", "in": "query", "required": false, "schema": {"type": "boolean", "default": false}, "description": "Rank paths by trust-weighted edge cost: cheaper through high-scored and mutual follows (path mode)"},
+          {"name": "disjoint", "in": "query", "required": false, "schema": {"type": "boolean", "default": false}, "description": "Return paths that share no intermediate account (path mode)"},
+          {"name": "pubkey", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Single pubkey for info mode"},
+          {"name": "depth", "in": "query", "required": false, "schema": {"type": "integer", "default": 1, "minimum": 1, "maximum": 2}, "description": "Neighborhood depth: 2 adds follows of follows (neighborhood mode)"},
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 1, "maximum": 5000}, "description": "Max neighbors: 1-200, default 50, for JSON; 1-5000, default 500, for graph files (neighborhood mode)"},
+          {"name": "format", "in": "query", "required": false, "schema": {"type": "string", "enum": ["hex", "npub", "graphml", "gexf", "dot"]}, "description": "graphml, gexf or dot streams the neighborhood as a graph file: nodes with score, followers, community and relation, and every follow among them with relation (mutual or follows) and followed_at (neighborhood mode)"}
         ],
         "responses": {
-          "200": {"description": "Trust path with annotated nodes"},
+          "200": {"description": "Trust path with annotated nodes, or the neighborhood as a graph file. With k > 1 or weighted=true, path mode returns GraphKPathsResponse.", "content": {"application/json": {"schema": {"oneOf": [{"$ref": "#/components/schemas/GraphPathResponse"}, {"$ref": "#/components/schemas/GraphKPathsResponse"}, {"$ref": "#/components/schemas/GraphNeighborhood"}]}}, "application/graphml+xml": {}, "application/gexf+xml": {}, "text/vnd.graphviz": {}}},
           "400": {"description": "Invalid parameters"}
         }
       }
@@ -194,7 +451,7 @@ const openAPISpec = `{
           {"name": "b", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Second hex pubkey or npub"}
         ],
         "responses": {
-          "200": {"description": "Detailed comparison with relationship and similarity data"},
+          "200": {"description": "Detailed comparison with relationship and similarity data", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CompareResponse"}}}},
           "400": {"description": "Missing or invalid parameters"},
           "402": {"description": "L402 payment required (2 sats)"}
         }
@@ -224,7 +481,8 @@ const openAPISpec = `{
         "summary": "Resolve NIP-05 identifier to trust profile",
         "description": "Resolves a NIP-05 identifier (user@domain.com) to its pubkey via .well-known/nostr.json, then returns the full WoT trust profile including score, trust level, engagement metrics, and topics.",
         "parameters": [
-          {"name": "id", "in": "query", "required": true, "schema": {"type": "string"}, "description": "NIP-05 identifier (e.g. user@domain.com)"}
+          {"name": "id", "in": "query", "required": true, "schema": {"type": "string"}, "description": "NIP-05 identifier (e.g. user@domain.com)"},
+          {"name": "lang", "in": "query", "schema": {"type": "string", "enum": ["en", "de", "es", "ja"]}, "description": "Language of human-readable fields; defaults to Accept-Language, then en"}
         ],
         "responses": {
           "200": {"description": "Trust profile with NIP-05 verification"},
@@ -239,6 +497,9 @@ const openAPISpec = `{
         "operationId": "batchNIP05",
         "summary": "Resolve up to 50 NIP-05 identifiers concurrently",
         "description": "Batch NIP-05 resolution with trust profiles. Enables clients to verify and trust-score entire contact lists or directories in a single request.",
+        "parameters": [
+          {"name": "lang", "in": "query", "schema": {"type": "string", "enum": ["en", "de", "es", "ja"]}, "description": "Language of human-readable fields; defaults to Accept-Language, then en"}
+        ],
         "requestBody": {
           "required": true,
           "content": {
@@ -267,7 +528,8 @@ const openAPISpec = `{
         "summary": "Reverse NIP-05 lookup from pubkey",
         "description": "Given a pubkey, fetches their kind 0 profile from relays, extracts the NIP-05 identifier, and bidirectionally verifies it resolves back to the same pubkey.",
         "parameters": [
-          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"}
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
+          {"name": "lang", "in": "query", "schema": {"type": "string", "enum": ["en", "de", "es", "ja"]}, "description": "Language of human-readable fields; defaults to Accept-Language, then en"}
         ],
         "responses": {
           "200": {"description": "Reverse NIP-05 lookup result with bidirectional verification"},
@@ -276,6 +538,23 @@ const openAPISpec = `{
         }
       }
     },
+    "/domain": {
+      "get": {
+        "tags": ["Identity"],
+        "operationId": "getDomainReputation",
+        "summary": "NIP-05 domain reputation",
+        "description": "Scores every pubkey verified under a NIP-05 domain: the domain's full /.well-known/nostr.json (cached for an hour) plus identifiers already resolved through /nip05. Members outside the graph count as 0 toward average_score and median_score. spam_rate is the share of in-graph members classified likely_spam. reputation = mean of average and median, scaled by (1 - spam_rate). render_checkmark is true when reputation >= 10 and spam_rate <= 0.2. At most 5000 pubkeys are scored.",
+        "parameters": [
+          {"name": "name", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Domain, e.g. example.com; a user@domain identifier also works"}
+        ],
+        "responses": {
+          "200": {"description": "Domain reputation, trust level, checkmark advice, member counts, score statistics, spam rates and top members"},
+          "400": {"description": "Missing or invalid domain"},
+          "402": {"description": "L402 payment required (5 sats)"},
+          "502": {"description": "The domain's nostr.json could not be read and no members are known"}
+        }
+      }
+    },
     "/decay": {
       "get": {
         "tags": ["Temporal"],
@@ -284,10 +563,11 @@ const openAPISpec = `{
         "description": "Exponential decay where newer follows weigh more. Configurable half-life reveals emerging vs legacy reputation. Shows delta between static and decayed scores.",
         "parameters": [
           {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
-          {"name": "half_life", "in": "query", "required": false, "schema": {"type": "number", "default": 365, "minimum": 1, "maximum": 3650}, "description": "Half-life in days"}
+          {"name": "half_life", "in": "query", "required": false, "schema": {"type": "number", "default": 365, "minimum": 1, "maximum": 3650}, "description": "Half-life in days"},
+          {"name": "dormancy", "in": "query", "required": false, "schema": {"type": "boolean", "default": false}, "description": "Discount decayed scores by account activity: dormant x0.8, abandoned x0.5 (see /active)"}
         ],
         "responses": {
-          "200": {"description": "Decay-adjusted score with static comparison"},
+          "200": {"description": "Decay-adjusted score with static comparison", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DecayResponse"}}}},
           "400": {"description": "Invalid or missing pubkey"},
           "402": {"description": "L402 payment required (1 sat)"}
         }
@@ -298,13 +578,18 @@ const openAPISpec = `{
         "tags": ["Temporal"],
         "operationId": "getDecayTop",
         "summary": "Top pubkeys by decay-adjusted score",
-        "description": "Leaderboard showing rank changes when temporal freshness is factored in. Reveals who is gaining vs losing momentum.",
+        "description": "Leaderboard showing rank changes when temporal freshness is factored in. Each entry has a momentum class: rising when the decayed score is at least 1.1x the static score and follows from the last 30 days arrive at least as fast as the pubkey's lifetime daily rate, fading when the ratio is at most 0.9 and recent follows are slower than the lifetime rate, otherwise steady. Filters by community, minimum followers, and momentum are applied in decay-rank order; ranks stay global.",
         "parameters": [
           {"name": "half_life", "in": "query", "required": false, "schema": {"type": "number", "default": 365, "minimum": 1, "maximum": 3650}, "description": "Half-life in days"},
-          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "default": 50, "minimum": 1, "maximum": 200}, "description": "Max results"}
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "default": 50, "minimum": 1, "maximum": 200}, "description": "Max results"},
+          {"name": "dormancy", "in": "query", "required": false, "schema": {"type": "boolean", "default": false}, "description": "Discount decayed scores by account activity: dormant x0.8, abandoned x0.5 (see /active)"},
+          {"name": "community", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 0}, "description": "Only pubkeys in this community id (see /communities)"},
+          {"name": "min_followers", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 0}, "description": "Only pubkeys with at least this many followers"},
+          {"name": "momentum", "in": "query", "required": false, "schema": {"type": "string", "enum": ["rising", "steady", "fading"]}, "description": "Only pubkeys with this momentum class"}
         ],
         "responses": {
-          "200": {"description": "Ranked list with decay vs static rank changes"}
+          "200": {"description": "Ranked list with decay vs static rank changes, momentum, decay ratio, recent follows, follow velocity, followers, and community", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DecayTopResponse"}}}},
+          "400": {"description": "Invalid community, min_followers, or momentum"}
         }
       }
     },
@@ -323,14 +608,97 @@ const openAPISpec = `{
         }
       }
     },
+    "/history": {
+      "get": {
+        "tags": ["Temporal"],
+        "operationId": "getScoreHistory",
+        "summary": "Recorded score history for a pubkey",
+        "description": "The pubkey's normalized score, rank and follower count as recorded at each rebuild, oldest first, with the change between the first and last point. Unlike /timeline, nothing is estimated: each point is what the service computed at that build. The last 120 rebuilds (about 30 days) are kept, in memory.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
+          {"name": "since", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Only rebuilds at or after this time (unix seconds or RFC 3339)"}
+        ],
+        "responses": {
+          "200": {"description": "Score history points"},
+          "400": {"description": "Invalid pubkey or since"},
+          "404": {"description": "No score history recorded for the pubkey"}
+        }
+      }
+    },
+    "/contacts/snapshot": {
+      "get": {
+        "tags": ["Temporal"],
+        "operationId": "getContactsSnapshot",
+        "summary": "Observed contact list versions for a pubkey",
+        "description": "Kind 3 contact list versions seen by the crawler, hints, and partner ingestion, newest first (up to 20 per pubkey). Each version has its created_at, observed_at, source, follow count, and the follows added and removed since the previous version (up to 100 of each listed). Versions dropping at least 20 follows and half of the previous list are flagged mass_unfollow. With event_id or before, snapshot returns that version's full follow list and an unsigned kind 3 restore_event to sign and publish.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
+          {"name": "event_id", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Include the full list of this version"},
+          {"name": "before", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Include the full list of the newest version created before this time (unix seconds or RFC 3339)"}
+        ],
+        "responses": {
+          "200": {"description": "Versions with diffs and mass_unfollow flags, plus the requested snapshot"},
+          "400": {"description": "Invalid pubkey or before, or both event_id and before given"},
+          "402": {"description": "L402 payment required (2 sats)"},
+          "404": {"description": "No versions observed for pubkey, or no version matches event_id/before"}
+        }
+      }
+    },
+    "/active": {
+      "get": {
+        "tags": ["Temporal"],
+        "operationId": "getActivity",
+        "summary": "Account activity heartbeat",
+        "description": "Last-seen activity for a pubkey, taken from the newer of its latest authored event in the metadata crawl (notes, reactions) and its follow list timestamp. Classified as active (seen within 30 days), dormant (within 180 days), abandoned (older), or unknown (never seen). Includes posting cadence from sampled note timestamps and the dormancy discount /decay applies with dormancy=true. automation gives the likelihood (0-1) that the cadence is automated, classified human, possibly_automated, likely_automated or unknown (fewer than 10 sampled notes), from regularity (1 minus the coefficient of variation of the gaps between notes), round_the_clock (posting through the quietest 6 hours of the UTC hour histogram) and burstiness (gaps under 10 seconds), along with the interval statistics and the hour and weekday histograms. Also served at /activity.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"}
+        ],
+        "responses": {
+          "200": {"description": "Activity status, last seen, posting cadence and automation analysis"},
+          "400": {"description": "Invalid or missing pubkey"}
+        }
+      }
+    },
+    "/growth-sources": {
+      "get": {
+        "tags": ["Temporal"],
+        "operationId": "getGrowthSources",
+        "summary": "Follower acquisition sources for a pubkey",
+        "description": "Breaks down where a pubkey's followers came from: top communities (with recent follows and average follower score), score tiers (strong/moderate/weak/unknown), and burst vs organic pacing reconstructed from follow timestamps. A burst is a day with at least 10 new followers and 5x the mean daily rate. Includes monthly periods with burst counts and zero-score share.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"}
+        ],
+        "responses": {
+          "200": {"description": "Growth source breakdown"},
+          "400": {"description": "Invalid or missing pubkey"}
+        }
+      }
+    },
+    "/churn": {
+      "get": {
+        "tags": ["Temporal"],
+        "operationId": "getChurn",
+        "summary": "Follow/unfollow churn for a pubkey",
+        "description": "Follows and unfollows of a pubkey within the window, logged from the differences between successive kind 3 contact lists of its followers (up to 2000 events kept per pubkey). Reports net_growth, unfollow_rate (unfollows over followers at the start of the window), trusted_unfollows (unfollowers scoring 10+), and up to 20 top_unfollowers by score, leaving out accounts that followed again. high_churn is set for at least 10 unfollows and a rate of 0.2 or more.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
+          {"name": "days", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 1, "maximum": 365, "default": 30}, "description": "Window in days"}
+        ],
+        "responses": {
+          "200": {"description": "Churn metrics and top unfollowers"},
+          "400": {"description": "Invalid or missing pubkey, or days out of range"}
+        }
+      }
+    },
     "/spam": {
       "get": {
         "tags": ["Moderation"],
         "operationId": "checkSpam",
         "summary": "Multi-signal spam classification",
-        "description": "Classifies a pubkey as likely_human, suspicious, or likely_spam using 6 weighted signals: WoT score (30%), follower ratio (15%), account age (15%), engagement (15%), reports (15%), activity pattern (10%).",
+        "description": "Classifies a pubkey as likely_human, suspicious, or likely_spam using 7 weighted signals: WoT score (30%), follower ratio (15%), account age (15%), engagement (10%), trust-weighted reports (15%, see /reports), activity pattern (5%), automation likelihood (10%, see /active).",
         "parameters": [
-          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"}
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
+          {"name": "lang", "in": "query", "schema": {"type": "string", "enum": ["en", "de", "es", "ja"]}, "description": "Language of human-readable fields; defaults to Accept-Language, then en"}
         ],
         "responses": {
           "200": {"description": "Spam analysis with signal breakdown"},
@@ -339,12 +707,31 @@ const openAPISpec = `{
         }
       }
     },
+    "/reports": {
+      "get": {
+        "tags": ["Moderation"],
+        "operationId": "getReports",
+        "summary": "Trust-weighted report analysis",
+        "description": "Kind 1984 (NIP-56) reports against a pubkey, one per reporter, grouped by report type. Each report is weighted by the reporter's normalized score over 100 and halves every 90 days. Assessment is none, untrusted, minor, or significant (weighted total of 1.5 or more).",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"}
+        ],
+        "responses": {
+          "200": {"description": "Report counts, weights, categories and heaviest reporters"},
+          "400": {"description": "Invalid or missing pubkey"},
+          "402": {"description": "L402 payment required (2 sats)"}
+        }
+      }
+    },
     "/spam/batch": {
       "post": {
         "tags": ["Moderation"],
         "operationId": "batchSpam",
         "summary": "Check up to 100 pubkeys for spam",
         "description": "Batch spam filtering for contact lists or relay event feeds. Returns classification and probability for each pubkey plus summary counts.",
+        "parameters": [
+          {"name": "lang", "in": "query", "schema": {"type": "string", "enum": ["en", "de", "es", "ja"]}, "description": "Language of human-readable fields; defaults to Accept-Language, then en"}
+        ],
         "requestBody": {
           "required": true,
           "content": {
@@ -366,25 +753,58 @@ const openAPISpec = `{
         }
       }
     },
-    "/event": {
-      "get": {
-        "tags": ["Engagement"],
-        "operationId": "getEventScore",
-        "summary": "Engagement score for a Nostr event",
-        "description": "Returns engagement metrics (comments, reposts, reactions, zaps) and a normalized rank for a specific event ID.",
-        "parameters": [
-          {"name": "id", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Event ID (hex)"}
-        ],
-        "responses": {
-          "200": {"description": "Event engagement metrics"},
-          "400": {"description": "Missing event ID"}
-        }
-      }
-    },
-    "/external": {
-      "get": {
-        "tags": ["Engagement"],
-        "operationId": "getExternalScore",
+    "/spam/event": {
+      "post": {
+        "tags": ["Moderation"],
+        "operationId": "checkEventSpam",
+        "summary": "Spam verdict for a single event",
+        "description": "Takes a full signed Nostr event as the body and combines four weighted signals: the author's /spam probability (40%), link density (20%), mention storms from p tags or inline nostr: mentions (20%), and copies of the same normalized content seen under other event IDs in the last 24 hours (20%, counting fully at 5 copies or 3 other authors). Verdict is reject (>= 0.7), flag (>= 0.4) or accept. Every checked event is added to the duplicate index.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["id", "pubkey", "created_at", "kind", "tags", "content", "sig"],
+                "properties": {
+                  "id": {"type": "string"},
+                  "pubkey": {"type": "string"},
+                  "created_at": {"type": "integer"},
+                  "kind": {"type": "integer"},
+                  "tags": {"type": "array", "items": {"type": "array", "items": {"type": "string"}}},
+                  "content": {"type": "string"},
+                  "sig": {"type": "string"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Spam probability, verdict, signal breakdown and the author's classification"},
+          "400": {"description": "Invalid event JSON, ID or signature"},
+          "402": {"description": "L402 payment required (2 sats)"}
+        }
+      }
+    },
+    "/event": {
+      "get": {
+        "tags": ["Engagement"],
+        "operationId": "getEventScore",
+        "summary": "Engagement score for a Nostr event",
+        "description": "Returns engagement metrics (comments, reposts, reactions, zaps) and a normalized rank for a specific event ID.",
+        "parameters": [
+          {"name": "id", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Event ID (hex)"}
+        ],
+        "responses": {
+          "200": {"description": "Event engagement metrics"},
+          "400": {"description": "Missing event ID"}
+        }
+      }
+    },
+    "/external": {
+      "get": {
+        "tags": ["Engagement"],
+        "operationId": "getExternalScore",
         "summary": "Score for external identifiers (hashtags, URLs)",
         "description": "Trust-weighted engagement scoring for NIP-73 external identifiers. Without an ID parameter, returns top 50 trending identifiers.",
         "parameters": [
@@ -395,6 +815,40 @@ const openAPISpec = `{
         }
       }
     },
+    "/external/trending": {
+      "get": {
+        "tags": ["Engagement"],
+        "operationId": "getExternalTrending",
+        "summary": "Trust-weighted trending hashtags and URLs",
+        "description": "Ranks hashtags and URLs by mentions and reactions within a 24h or 7d window, each weighted by its author's WoT score. raw_position gives the identifier's place in an unweighted count ranking.",
+        "parameters": [
+          {"name": "window", "in": "query", "required": false, "schema": {"type": "string", "enum": ["24h", "7d"], "default": "24h"}, "description": "Time window"},
+          {"name": "kind", "in": "query", "required": false, "schema": {"type": "string", "enum": ["hashtag", "url"]}, "description": "Only identifiers of this kind"},
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 1, "maximum": 100, "default": 50}, "description": "Max results"}
+        ],
+        "responses": {
+          "200": {"description": "Trending identifiers with trust scores, counts and raw positions"},
+          "400": {"description": "Invalid window, kind or limit"}
+        }
+      }
+    },
+    "/articles/top": {
+      "get": {
+        "tags": ["Engagement"],
+        "operationId": "getTopArticles",
+        "summary": "Trust-curated long-form article feed",
+        "description": "Ranks crawled kind 30023 articles by 0.6 × the author's normalized WoT score plus 0.4 × a 0-100 log-scale engagement rank. Articles by unscored authors are left out.",
+        "parameters": [
+          {"name": "topic", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Only articles with this hashtag (t tag)"},
+          {"name": "min_author_score", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 0, "maximum": 100, "default": 0}, "description": "Minimum normalized author score"},
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 1, "maximum": 100, "default": 20}, "description": "Max results"}
+        ],
+        "responses": {
+          "200": {"description": "Articles with title, summary, hashtags, score components and engagement counts"},
+          "400": {"description": "Invalid min_author_score or limit"}
+        }
+      }
+    },
     "/metadata": {
       "get": {
         "tags": ["Scoring"],
@@ -410,14 +864,47 @@ const openAPISpec = `{
         }
       }
     },
+    "/metadata/batch": {
+      "post": {
+        "tags": ["Scoring"],
+        "operationId": "postMetadataBatch",
+        "summary": "Metadata for up to 100 pubkeys",
+        "description": "Returns the /metadata profile for each pubkey in one call. Invalid pubkeys get an error entry at the same position.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["pubkeys"],
+                "properties": {
+                  "pubkeys": {"type": "array", "items": {"type": "string"}, "maxItems": 100, "description": "Hex pubkeys or npubs"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Per-pubkey metadata profiles with found count"},
+          "400": {"description": "Invalid body, empty list, or more than 100 pubkeys"},
+          "405": {"description": "POST required"}
+        }
+      }
+    },
     "/top": {
       "get": {
         "tags": ["Ranking"],
         "operationId": "getTop",
         "summary": "Top 50 pubkeys by PageRank",
-        "description": "Leaderboard of the highest-ranked pubkeys in the trust graph with normalized scores and follower counts.",
+        "description": "Leaderboard of the highest-ranked pubkeys in the trust graph with normalized scores and follower counts. format=ndjson, csv or parquet returns the same rows as /export in that format.",
+        "parameters": [
+          {"name": "format", "in": "query", "required": false, "schema": {"type": "string", "enum": ["json", "ndjson", "csv", "parquet"], "default": "json"}, "description": "Response format"},
+          {"name": "view", "in": "query", "required": false, "schema": {"type": "string", "default": "default"}, "description": "Seed view to rank within, as for /score"}
+        ],
         "responses": {
-          "200": {"description": "Array of top-ranked pubkeys"}
+          "200": {"description": "Array of top-ranked pubkeys, or the score table in the requested format"},
+          "400": {"description": "Unknown view"},
+          "503": {"description": "The view has not been built yet"}
         }
       }
     },
@@ -426,7 +913,7 @@ const openAPISpec = `{
         "tags": ["Ranking"],
         "operationId": "getStats",
         "summary": "Service statistics",
-        "description": "Graph size, edge count, algorithm parameters, relay list, rate limits, and last build timestamp.",
+        "description": "Graph size, edge count, algorithm parameters, relay list, rate limits, and last build timestamp. config shows the active seeds, relays, crawl depth and PageRank settings, the config file they came from, when it was last loaded and the last reload error. edge_weighting shows whether follow edges are weighted by zaps, reactions and replies (PAGERANK_WEIGHTING=interactions) and the coefficients in use; mute_scoring shows whether trusted accounts' mutes are negative PageRank edges (PAGERANK_MUTES=penalize), the penalty and the minimum muter score; interaction_pairs counts follower/followed pairs with recorded interactions. relay_info reports each configured relay's NIP-11 limitations and whether the crawler uses it normally (ok), with smaller batches (throttled), not at all (skipped: auth or payment required), or with default limits because NIP-11 was unavailable (unknown).",
         "responses": {
           "200": {"description": "Service statistics"}
         }
@@ -437,9 +924,64 @@ const openAPISpec = `{
         "tags": ["Ranking"],
         "operationId": "exportScores",
         "summary": "Export all scores",
-        "description": "Full export of all pubkeys with their raw PageRank scores and normalized ranks. Useful for research and analysis.",
+        "description": "Full export of all pubkeys with their raw PageRank scores and normalized ranks, highest first (ties by pubkey). Useful for research and analysis. Without limit the whole table is returned; with limit, the X-Next-Cursor and Link rel=next headers point at the next page until the last. Cursors are only valid for the graph build that issued them. Bodies are gzipped when the client sends Accept-Encoding: gzip.",
+        "parameters": [
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 1, "maximum": 100000}, "description": "Page size; omit for the full table"},
+          {"name": "cursor", "in": "query", "required": false, "schema": {"type": "string"}, "description": "X-Next-Cursor value from the previous page"},
+          {"name": "format", "in": "query", "required": false, "schema": {"type": "string", "enum": ["json", "ndjson", "csv", "parquet"], "default": "json"}, "description": "json for the {pubkey, rank, raw} array; ndjson, csv and parquet for the full table: rank (position), pubkey, score, raw_score, followers, follows and community"}
+        ],
+        "responses": {
+          "200": {"description": "Scored pubkeys (JSON array, NDJSON, CSV or Parquet)"},
+          "400": {"description": "Invalid limit, cursor or format"},
+          "410": {"description": "The graph was rebuilt since the cursor was issued"},
+          "503": {"description": "Graph not built yet"}
+        }
+      }
+    },
+    "/snapshots": {
+      "get": {
+        "tags": ["Ranking"],
+        "operationId": "listSnapshots",
+        "summary": "Retained score snapshots",
+        "description": "The score snapshots retained from past rebuilds, newest first. Each has an id for /snapshots/{id}/export, the build number and time, node and edge counts, how many pubkeys were scored, and top10_hash: the hex SHA-256 of the ten highest-scored pubkeys joined by newlines in rank order. retention gives the configured snapshot_retention (max_snapshots) and snapshot_retention_days (max_age_days, 0 for no age limit); storage is disk when SNAPSHOTS_DIR is set, memory otherwise.",
+        "responses": {
+          "200": {"description": "Snapshots with the retention policy"}
+        }
+      }
+    },
+    "/snapshots/{id}/export": {
+      "get": {
+        "tags": ["Ranking"],
+        "operationId": "exportSnapshot",
+        "summary": "Export a retained snapshot's scores",
+        "description": "The full score table as of a retained snapshot, highest first, in the same formats as /export. Rows carry the rank, score, follower counts and community the pubkey had at that rebuild. Bodies are gzipped when the client sends Accept-Encoding: gzip.",
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}, "description": "Snapshot id from /snapshots"},
+          {"name": "format", "in": "query", "required": false, "schema": {"type": "string", "enum": ["json", "ndjson", "csv", "parquet"], "default": "json"}, "description": "json for the {pubkey, rank, raw} array; ndjson, csv and parquet for the full table"}
+        ],
+        "responses": {
+          "200": {"description": "Scored pubkeys (JSON array, NDJSON, CSV or Parquet)"},
+          "400": {"description": "Invalid format"},
+          "404": {"description": "No retained snapshot with this id"}
+        }
+      }
+    },
+    "/export/bloom": {
+      "get": {
+        "tags": ["Ranking"],
+        "operationId": "exportBloom",
+        "summary": "Bloom filter of a pubkey's extended follow set",
+        "description": "Builds a Bloom filter of every pubkey within depth follow hops of pubkey (not counting pubkey itself), optionally only those scoring at least min_score, sized for the requested false positive rate. The body is the raw bit array. X-Bloom-Bits (m), X-Bloom-Hashes (k), X-Bloom-Count, X-Bloom-FPR, X-Bloom-Depth and X-Bloom-Min-Score describe it, and X-Bloom-Hash names the hashing: sha256-km, where a member's bits are (h1 + i*h2) mod m for i < k, h1 and h2 being the first two big-endian uint64s of SHA-256 of the 32-byte pubkey with h2's low bit set, and bit j is bit j%8 (least significant first) of byte j/8.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
+          {"name": "depth", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 1, "maximum": 3, "default": 2}, "description": "Follow hops"},
+          {"name": "fpr", "in": "query", "required": false, "schema": {"type": "number", "minimum": 0.0001, "maximum": 0.5, "default": 0.01}, "description": "Target false positive rate"},
+          {"name": "min_score", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 0, "maximum": 100, "default": 0}, "description": "Only include pubkeys scoring at least this"}
+        ],
         "responses": {
-          "200": {"description": "Array of all scored pubkeys"}
+          "200": {"description": "Bloom filter bits (application/octet-stream) with X-Bloom-* headers"},
+          "400": {"description": "Invalid pubkey, depth, fpr or min_score"},
+          "503": {"description": "Graph not built yet"}
         }
       }
     },
@@ -448,7 +990,7 @@ const openAPISpec = `{
         "tags": ["Infrastructure"],
         "operationId": "getRelayTrust",
         "summary": "Trust assessment for a Nostr relay",
-        "description": "Combines infrastructure trust data from trustedrelays.xyz (reliability, quality, uptime) with operator social reputation from PageRank. 70/30 blend of infrastructure and social scores.",
+        "description": "Combines infrastructure trust data from trustedrelays.xyz (reliability, quality, uptime) with operator social reputation from PageRank. 70/30 blend of infrastructure and social scores. When the relay store knows the relay, network carries its /relay/top record, and the operator named in its NIP-11 document is used if trustedrelays.xyz has none.",
         "parameters": [
           {"name": "url", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Relay WebSocket URL (e.g. wss://relay.damus.io)"}
         ],
@@ -458,18 +1000,92 @@ const openAPISpec = `{
         }
       }
     },
+    "/relay/suggest": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "getRelaySuggest",
+        "summary": "Relay suggestions from where a user's trusted follows publish and read",
+        "description": "Reads the NIP-65 relay lists (kind 10002) of the user's 150 highest-scored follows and ranks relays by trust-weighted coverage. Suggestions are picked greedily by the write coverage they add, so cumulative_write_coverage shows how much of the trusted follows' notes the top N relays reach. missing_read_coverage is the trust-weighted share of follows whose read relays the user's own list misses. Relay lists are cached for 6 hours.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "default": 10, "minimum": 1, "maximum": 30}, "description": "Max suggestions"}
+        ],
+        "responses": {
+          "200": {"description": "Relay suggestions with write/read coverage, cumulative coverage and whether each relay is already in the user's list"},
+          "400": {"description": "Invalid or missing pubkey"},
+          "402": {"description": "L402 payment required (5 sats)"},
+          "404": {"description": "Pubkey has no follows in graph"},
+          "429": {"description": "Relay suggestion rate limit exceeded"},
+          "502": {"description": "Relay lists could not be fetched"}
+        }
+      }
+    },
+    "/relay/top": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "getRelayTop",
+        "summary": "Relays ranked by trust-weighted usage and operator reputation",
+        "description": "Each rebuild reads the NIP-65 relay lists of the top 5,000 pubkeys and weights every listed relay by its users' WoT scores. NIP-11 documents of the 300 most-used relays (cached 6 hours) link their operator pubkey to the graph. adoption = 100 * ln(1 + trust_weight) / ln(1 + max trust_weight); rank = adoption * 0.70 + operator_score * 0.30. The top 100 relays with 3+ users are published as kind 30385 relay trust assertions (d = relay URL).",
+        "parameters": [
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "default": 50, "minimum": 1, "maximum": 500}, "description": "Max relays"},
+          {"name": "min_users", "in": "query", "required": false, "schema": {"type": "integer", "default": 1, "minimum": 1}, "description": "Only relays listed by at least this many graph members"}
+        ],
+        "responses": {
+          "200": {"description": "Ranked relays with usage counts, NIP-11 details, operator and operator score"},
+          "400": {"description": "Invalid limit or min_users"}
+        }
+      }
+    },
     "/communities": {
       "get": {
         "tags": ["Infrastructure"],
         "operationId": "getCommunities",
         "summary": "Trust communities via label propagation",
-        "description": "Without a pubkey, returns top 20 communities. With a pubkey, returns the community that pubkey belongs to with top members.",
+        "description": "Without a pubkey, returns top 20 communities. With a pubkey, returns the community that pubkey belongs to with top members. With a pubkey and as_of, returns the pubkey's community at a historical build plus its membership change log. Community ids are kept stable across builds by aligning each run with the previous one by member overlap; history covers the last 120 builds.",
         "parameters": [
-          {"name": "pubkey", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Hex pubkey or npub (optional — omit for top communities)"}
+          {"name": "pubkey", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Hex pubkey or npub (optional — omit for top communities)"},
+          {"name": "as_of", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Build ID, RFC3339 time, or YYYY-MM-DD (latest build at or before it); requires pubkey"}
         ],
         "responses": {
           "200": {"description": "Community data"},
-          "404": {"description": "Pubkey not found in community graph"}
+          "400": {"description": "as_of without pubkey, or unparseable as_of"},
+          "404": {"description": "Pubkey not found in community graph, or no community history for as_of"}
+        }
+      }
+    },
+    "/bridges": {
+      "get": {
+        "tags": ["Network Analysis"],
+        "operationId": "getBridges",
+        "summary": "Accounts bridging trust communities",
+        "description": "Ranks accounts by betweenness counted only on shortest paths between different communities (follows and followers treated as undirected edges, as in community detection). With a and b, only paths between those two communities count; without them, paths between any two communities count. Sources are sampled when there are more candidates than samples, seeded by the build ID so a build always returns the same ranking; betweenness is then scaled up to an estimate. Each bridge lists the communities its neighbors belong to. With scope=graph, accounts are ranked instead by betweenness over the whole graph regardless of communities, computed each rebuild from 256 sampled sources, with a 0-100 broker_score on a log scale against the top broker.",
+        "parameters": [
+          {"name": "a", "in": "query", "required": false, "schema": {"type": "integer"}, "description": "First community id (requires b)"},
+          {"name": "b", "in": "query", "required": false, "schema": {"type": "integer"}, "description": "Second community id (requires a)"},
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "default": 20, "minimum": 1, "maximum": 100}, "description": "Max bridges returned"},
+          {"name": "samples", "in": "query", "required": false, "schema": {"type": "integer", "default": 64, "minimum": 1, "maximum": 256}, "description": "Source nodes sampled for the betweenness estimate"},
+          {"name": "scope", "in": "query", "required": false, "schema": {"type": "string", "enum": ["communities", "graph"], "default": "communities"}, "description": "graph ranks brokers by graph-wide betweenness from the last rebuild (no a, b or samples)"}
+        ],
+        "responses": {
+          "200": {"description": "Bridge accounts with betweenness, score, community, and connected communities"},
+          "400": {"description": "Invalid parameters"},
+          "404": {"description": "Community not found"}
+        }
+      }
+    },
+    "/communities/map": {
+      "get": {
+        "tags": ["Network Analysis"],
+        "operationId": "getCommunitiesMap",
+        "summary": "Inter-community trust map",
+        "description": "The largest communities as nodes, with size, average and top score, internal and outgoing follow counts, and cohesion (the internal share of members' follows of labeled accounts). Directed edges count follows from members of one community to members of another; weight divides that by the source community's labeled follows. bridges lists the accounts with the highest cross-community betweenness, as on /bridges, and each edge names up to three that neighbor both communities.",
+        "parameters": [
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "default": 20, "minimum": 1, "maximum": 100}, "description": "Communities on the map, largest first"},
+          {"name": "min_weight", "in": "query", "required": false, "schema": {"type": "number", "default": 0, "minimum": 0, "maximum": 1}, "description": "Drop edges below this weight"}
+        ],
+        "responses": {
+          "200": {"description": "Communities, weighted edges between them, and bridge accounts"},
+          "400": {"description": "Invalid parameters"}
         }
       }
     },
@@ -478,7 +1094,7 @@ const openAPISpec = `{
         "tags": ["Infrastructure"],
         "operationId": "getAuthorized",
         "summary": "NIP-85 authorization tracking",
-        "description": "Shows which users have explicitly authorized a specific NIP-85 scoring provider via kind 10040 events. Without a pubkey, shows our own authorized users.",
+        "description": "Shows which users have explicitly authorized a specific NIP-85 scoring provider via kind 10040 events. Without a pubkey, shows our own authorized users. Each of our authorizers includes its publishing state (relays, follows, queued, failed, last_published) once kind 30382 assertions about its follows have been queued.",
         "parameters": [
           {"name": "pubkey", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Provider pubkey (optional — defaults to this service)"}
         ],
@@ -498,27 +1114,274 @@ const openAPISpec = `{
         }
       }
     },
+    "/providers/accuracy": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "getProviderAccuracy",
+        "summary": "External provider accuracy",
+        "description": "How often each external provider's kind 30382 claims agree with our graph, using the /verify rank and follower checks on subjects we score. Ranks are normalized to the provider's observed scale first. Providers with at least min_checks checked claims are weighted by their accuracy in composite scores; the rest get default_weight. Refreshed on every rebuild.",
+        "responses": {
+          "200": {"description": "Providers by accuracy with check counts, rank_mae and composite weight"}
+        }
+      }
+    },
+    "/assertions": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "getAssertions",
+        "summary": "Raw external assertion events for a subject",
+        "description": "Returns the full signed kind 30382 events consumed from external NIP-85 providers for a subject, enabling offline audits of composite scoring inputs. Events are content-addressed by their NIP-01 id. Superseded versions are garbage-collected after the retention window (ASSERTION_ARCHIVE_RETENTION_DAYS, default 30).",
+        "parameters": [
+          {"name": "subject", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
+          {"name": "provider", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Limit to one provider pubkey"},
+          {"name": "history", "in": "query", "required": false, "schema": {"type": "boolean"}, "description": "Include superseded versions"}
+        ],
+        "responses": {
+          "200": {"description": "Signed events, newest first", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AssertionsResponse"}}}},
+          "400": {"description": "Missing or invalid subject/provider"}
+        }
+      }
+    },
+    "/assertion-schema": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "getAssertionSchema",
+        "summary": "Tag schema for published NIP-85 assertions",
+        "description": "Describes every tag this service emits in kinds 30382, 30383, 30384, and 30385: name, value type, units, min/max, whether it is required or repeatable, and its meaning. Publishers validate each event against this schema before signing; events that don't match are skipped rather than published.",
+        "responses": {
+          "200": {"description": "Assertion kinds with their tag specs"}
+        }
+      }
+    },
     "/publish": {
       "post": {
         "tags": ["Infrastructure"],
         "operationId": "publishAssertions",
-        "summary": "Publish all NIP-85 assertions to relays",
-        "description": "Triggers publication of all five NIP-85 assertion kinds (30382, 30383, 30384, 30385) plus NIP-89 handler announcement to configured relays.",
+        "summary": "Publish all NIP-85 assertions to relays (admin)",
+        "description": "Signs NIP-85 assertions (kinds 30382, 30383, 30384, 30385) and queues them for the configured relays, then publishes the NIP-89 handler announcement. Kind 30382 covers the top publish_top_n pubkeys (10,000 by default). Each publish_lists threshold also gets a NIP-51 kind 30000 people list (d tag wot-above-N) of up to 1,000 pubkeys at or above it. The top 100 relays from /relay/top are published as kind 30385 relay trust assertions (relay_30385). Users who authorized this service for kind 30382 via kind 10040 also get kind 30382 assertions about up to 1,000 of their follows, sent to their relay hint and the configured relays (authorized_30382). Counts are of events queued; follow delivery with /publish/status. Requires Authorization: Bearer <ADMIN_TOKEN>.",
         "responses": {
           "200": {"description": "Publication counts per kind"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"},
           "405": {"description": "POST required"},
           "503": {"description": "Graph not built yet"}
         }
       }
     },
+    "/publish/status": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "getPublishStatus",
+        "summary": "Publishing queue progress",
+        "description": "Reports the publishing queue: queue_depth (events not yet accepted by every relay), top_n, events enqueued and completed since startup, whether progress survives restarts (resumable), and per relay the queued events, sent/succeeded/failed attempts, events dropped after 5 failed attempts, success_rate, the current interval between events, and retry_at while backing off.",
+        "responses": {
+          "200": {"description": "Queue status"}
+        }
+      }
+    },
+    "/rebuild/status": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "getRebuildStatus",
+        "summary": "Score rebuild progress",
+        "description": "Reports the state of the crawl/scoring rebuild: current phase, percent complete (weighted by typical phase duration), ETA, elapsed time, and per-phase status. State is idle, running, cancelling, completed, or cancelled.",
+        "responses": {
+          "200": {"description": "Rebuild status"}
+        }
+      }
+    },
+    "/crawl/status": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "getCrawlStatus",
+        "summary": "Follow crawl progress and per-relay request metrics",
+        "description": "Reports the crawler worker pool's settings (workers, per-relay concurrency and rate, retries), the running crawl's depth, queued pubkeys, requests done, failed and retried and events received, the last finished crawl, and per-relay counts of requests, errors, retries, failures, events, requests in flight and average latency since startup. With id, returns that on-demand crawl job instead (see POST /crawl).",
+        "parameters": [
+          {"name": "id", "in": "query", "schema": {"type": "string"}, "description": "On-demand crawl job ID from POST /crawl"}
+        ],
+        "responses": {
+          "200": {"description": "Crawl status, or the job: state (queued, running, done, failed), found_before, found, score, contact_list, profile, engagement"},
+          "404": {"description": "Unknown or expired job ID"}
+        }
+      }
+    },
+    "/crawl": {
+      "post": {
+        "tags": ["Infrastructure"],
+        "operationId": "postCrawl",
+        "summary": "Crawl a pubkey on demand",
+        "description": "Fetches a pubkey's newest kind 3 contact list, kind 0 profile and recent notes, reactions and zap receipts from the crawl relays in the background. The contact list is applied to the live graph like a hint. Returns a job to poll at /crawl/status?id=. A pubkey already queued, or crawled in the last 10 minutes, gets its existing job back. Limited to 5 requests per minute per IP.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["pubkey"],
+                "properties": {
+                  "pubkey": {"type": "string", "description": "Hex pubkey or npub"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "202": {"description": "Crawl queued: id, pubkey, state, created, poll"},
+          "200": {"description": "Existing job for the pubkey returned (created false)"},
+          "400": {"description": "Invalid pubkey or body"},
+          "402": {"description": "L402 payment required (2 sats)"},
+          "429": {"description": "Per-IP crawl limit"}
+        }
+      }
+    },
+    "/hint": {
+      "post": {
+        "tags": ["Infrastructure"],
+        "operationId": "postHint",
+        "summary": "Hint that a contact list changed",
+        "description": "Applies a pubkey's new kind 3 contact list now instead of at the next crawl. Attach the signed event to skip the relay fetch; otherwise the newest list is fetched from the crawl relays. The pubkey's follows are replaced and one PageRank step is recomputed for every current and former follow; the next full rebuild refines the scores. Lists no newer than the crawled one are ignored (status stale_event). Limited to 10 hints per minute per IP and one refresh per pubkey per minute.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "properties": {
+                  "pubkey": {"type": "string", "description": "Hex pubkey or npub; optional when event is given"},
+                  "event": {"type": "object", "description": "Signed kind 3 contact list by pubkey"},
+                  "kind": {"type": "integer", "enum": [3], "description": "What changed; only contact lists are supported"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "status (updated, unchanged, stale_event, not_found), source, added, removed, rescored, event_at"},
+          "400": {"description": "Invalid pubkey, kind, or event"},
+          "402": {"description": "L402 payment required (1 sat)"},
+          "429": {"description": "Per-IP hint limit or per-pubkey cooldown"},
+          "502": {"description": "Relay fetch failed"},
+          "503": {"description": "Graph not built yet"}
+        }
+      }
+    },
+    "/ingest": {
+      "post": {
+        "tags": ["Infrastructure"],
+        "operationId": "ingestEvents",
+        "summary": "Partner relays push events in batches",
+        "description": "Accepts up to 500 kind 3, 7, 9735, and 1984 events per batch from partner relays, authenticated with NIP-98 (Authorization: Nostr <base64 kind 27235 event>) by a pubkey listed in INGEST_PARTNERS. Each event's id and signature are verified, events dated more than 10 minutes ahead are rejected, and ids are deduplicated for 24 hours. Accepted events are applied with the crawler's normalization; contact lists newer than the graph's replace the author's follows with a local rescore, and their authors are skipped by follow crawls for 6 hours.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["events"],
+                "properties": {
+                  "events": {"type": "array", "items": {"type": "object"}, "maxItems": 500, "description": "Signed Nostr events"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "received, accepted, duplicates, rejected, contact_lists_applied, contact_lists_stale, and per-event errors"},
+          "400": {"description": "Invalid JSON, empty batch, or more than 500 events"},
+          "401": {"description": "Missing or invalid NIP-98 authorization"},
+          "403": {"description": "Ingest disabled or signer is not a partner"},
+          "405": {"description": "Method not allowed (POST required)"},
+          "413": {"description": "Request body too large"}
+        }
+      }
+    },
+    "/sandbox/score": {
+      "post": {
+        "tags": ["Infrastructure"],
+        "operationId": "sandboxScore",
+        "summary": "Score a user-supplied mini-graph",
+        "description": "Runs the production PageRank, time-decayed PageRank, label-propagation community detection and (with a viewer) personalized PageRank on an edge list you supply, up to 5000 edges. Nothing is added to the served graph. Node IDs are free-form strings up to 64 characters, so examples can use names instead of pubkeys. Duplicate edges and self-follows are ignored and counted. Omitted parameters take the production defaults (damping 0.85, 20 iterations, 365-day half-life, 10 community iterations).",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["edges"],
+                "properties": {
+                  "edges": {"type": "array", "maxItems": 5000, "items": {"type": "object", "required": ["from", "to"], "properties": {"from": {"type": "string"}, "to": {"type": "string"}, "created_at": {"type": "integer", "description": "Unix seconds; used by decay scores only"}}}},
+                  "damping": {"type": "number", "default": 0.85, "description": "Between 0 and 1 exclusive"},
+                  "iterations": {"type": "integer", "default": 20, "minimum": 1, "maximum": 100},
+                  "half_life_days": {"type": "number", "default": 365, "minimum": 1, "maximum": 3650},
+                  "community_iterations": {"type": "integer", "default": 10, "minimum": 1, "maximum": 100},
+                  "viewer": {"type": "string", "description": "Node to root personalized PageRank at (optional)"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Per-node rank, score, pagerank, decay_score, decay_pagerank, ppr, follows, followers and community_id, plus node/edge counts and the parameters used"},
+          "400": {"description": "Invalid JSON, no edges, more than 5000 edges, invalid node IDs, or parameters out of range"},
+          "405": {"description": "Method not allowed (POST required)"}
+        }
+      }
+    },
+    "/rebuild": {
+      "post": {
+        "tags": ["Infrastructure"],
+        "operationId": "startRebuild",
+        "summary": "Trigger a rebuild (admin)",
+        "description": "Starts a full crawl + PageRank + publish rebuild in the background. Requires Authorization: Bearer <ADMIN_TOKEN>.",
+        "responses": {
+          "202": {"description": "Rebuild started; returns initial status"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"},
+          "409": {"description": "A rebuild is already running"}
+        }
+      }
+    },
+    "/rebuild/cancel": {
+      "post": {
+        "tags": ["Infrastructure"],
+        "operationId": "cancelRebuild",
+        "summary": "Cancel the in-flight rebuild (admin)",
+        "description": "Cancels the running rebuild. In-progress relay queries are aborted and remaining phases are skipped, so scores from the previous rebuild stay in place. Requires Authorization: Bearer <ADMIN_TOKEN>.",
+        "responses": {
+          "200": {"description": "Cancellation requested; returns status"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"},
+          "409": {"description": "No rebuild running"}
+        }
+      }
+    },
+    "/analytics/subjects": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "getSubjectAnalytics",
+        "summary": "Assertion consumer analytics (admin)",
+        "description": "Aggregate query counts per subject and endpoint, plus how often each NIP-85 assertion d-tag (kinds 30382, 30383, 30385) is served by the API. Batch requests count once per pubkey. Only counts are kept; no caller identity is stored. Requires Authorization: Bearer <ADMIN_TOKEN>.",
+        "parameters": [
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "default": 50, "minimum": 1, "maximum": 1000}, "description": "Max subjects and d-tags returned"},
+          {"name": "endpoint", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Rank subjects by queries to this endpoint path (e.g. /score)"},
+          {"name": "kind", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 30382, "maximum": 30385}, "description": "Limit d-tags to one assertion kind"}
+        ],
+        "responses": {
+          "200": {"description": "Top subjects and d-tags with tracking totals"},
+          "400": {"description": "Invalid kind"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"}
+        }
+      }
+    },
     "/health": {
       "get": {
         "tags": ["Infrastructure"],
         "operationId": "getHealth",
         "summary": "Health check",
-        "description": "Returns service status (starting/ready), graph size, event counts, external provider stats, authorization counts, and uptime.",
+        "description": "Returns service status (starting, ready, degraded when some relays failed the last crawl, stale when no relay was reachable or data is over 12 hours old), relay_health (data age, last successful crawl, per-relay failures and next retry), graph size, event counts, external provider stats, authorization counts, and uptime.",
         "responses": {
-          "200": {"description": "Health status"}
+          "200": {"description": "Health status", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/HealthResponse"}}}}
         }
       }
     },
@@ -529,63 +1392,483 @@ const openAPISpec = `{
         "summary": "Mute list analysis (NIP-51 kind 10000)",
         "description": "Two modes: (1) pubkey mode returns who a pubkey has muted, (2) target mode returns who has muted a target pubkey with community moderation signal strength. Integrates NIP-51 mute lists with WoT trust scores.",
         "parameters": [
-          {"name": "pubkey", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Hex pubkey or npub — returns their mute list"},
-          {"name": "target", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Hex pubkey or npub — returns who has muted this target"}
+          {"name": "pubkey", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Hex pubkey or npub — returns their mute list"},
+          {"name": "target", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Hex pubkey or npub — returns who has muted this target"}
+        ],
+        "responses": {
+          "200": {"description": "Mute analysis with WoT scores and community signal"},
+          "400": {"description": "Missing or invalid pubkey/target"},
+          "402": {"description": "L402 payment required (2 sats)"}
+        }
+      }
+    },
+    "/annotations": {
+      "get": {
+        "tags": ["Annotations"],
+        "operationId": "getAnnotations",
+        "summary": "Third-party annotations for a pubkey",
+        "description": "Returns namespaced annotations (e.g. bitcoin-core:contributor) attached to a pubkey by third-party providers, aggregated per namespace:label. Each provider's weight is its operator-configured namespace weight, or its normalized WoT score / 100. Confidence is the sum of provider weights, capped at 1.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"}
+        ],
+        "responses": {
+          "200": {"description": "Aggregated annotations with per-provider weights"},
+          "400": {"description": "Missing or invalid pubkey"}
+        }
+      },
+      "post": {
+        "tags": ["Annotations"],
+        "operationId": "postAnnotations",
+        "summary": "Add or remove annotations (NIP-98 signed)",
+        "description": "Requires an Authorization: Nostr header carrying a NIP-98 kind 27235 event for this URL and method. The signer is the provider. Providers need a WoT score of at least 20 or an operator-configured weight for the namespace (ANNOTATION_PROVIDER_WEIGHTS). Namespaces and labels are lowercase [a-z0-9._-], max 64 chars. Up to 20 annotations per request.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["subject"],
+                "properties": {
+                  "subject": {"type": "string", "description": "Hex pubkey or npub being annotated"},
+                  "annotations": {"type": "array", "items": {"type": "object", "properties": {"namespace": {"type": "string"}, "label": {"type": "string"}, "value": {"type": "string"}}}},
+                  "remove": {"type": "array", "items": {"type": "object", "properties": {"namespace": {"type": "string"}, "label": {"type": "string"}}}}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Annotations stored; returns the subject's updated annotations"},
+          "400": {"description": "Invalid body, subject, namespace, or label"},
+          "401": {"description": "Missing or invalid NIP-98 authorization"},
+          "403": {"description": "Provider not trusted for this namespace"}
+        }
+      }
+    },
+    "/endorsements": {
+      "get": {
+        "tags": ["Trust Analysis"],
+        "operationId": "getEndorsements",
+        "summary": "Community endorsement breakdown for a pubkey",
+        "description": "Community endorsements are NIP-32 kind 1985 events (L=wot.endorsement, l=endorse, p=<subject>). Once at least 3 members of the subject's community with WoT score >= 30 endorse it, the score gets a bounded boost: 2 points per endorser, capped at 10, never above the median endorser score, never lowering the score. Endorsements expire after 180 days. Every endorser and the reason it did or did not count is listed; the same breakdown appears in /audit.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"}
+        ],
+        "responses": {
+          "200": {"description": "Endorsers, quorum status, boost, ceiling, and adjusted score"},
+          "400": {"description": "Missing or invalid pubkey"}
+        }
+      },
+      "post": {
+        "tags": ["Trust Analysis"],
+        "operationId": "postEndorsement",
+        "summary": "Submit a signed community endorsement event",
+        "description": "Accepts a signed kind 1985 endorsement event directly, in addition to those crawled from relays.",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"type": "object", "description": "A signed Nostr kind 1985 event"}}}
+        },
+        "responses": {
+          "200": {"description": "Endorsement accepted; returns the subject's updated breakdown"},
+          "400": {"description": "Invalid event, signature, or not an endorsement"}
+        }
+      }
+    },
+    "/migrations": {
+      "get": {
+        "tags": ["Trust Analysis"],
+        "operationId": "getMigrations",
+        "summary": "Key migrations, inherited trust and delegations for a pubkey",
+        "description": "Key migrations are NIP-41 kind 1776 events published by the old key with p=<new pubkey>; the newest one per old key counts. A migration is confirmed once min(3, old key's followers) of the old key's followers also follow the new key. The new key then inherits half the old key's normalized score as inherited_trust (also on /score, with migrated_score = max(score, inherited)) and its account age counts from the old key's first event. The published score is not changed. NIP-26 delegations verified on crawled notes are listed as delegations_granted and delegations_received.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"}
+        ],
+        "responses": {
+          "200": {"description": "migrated_to, migrated_from with confirmation status, inherited_trust, migrated_score, account_age, and delegations"},
+          "400": {"description": "Missing or invalid pubkey"}
+        }
+      },
+      "post": {
+        "tags": ["Trust Analysis"],
+        "operationId": "postMigration",
+        "summary": "Submit a signed key migration event",
+        "description": "Accepts a signed kind 1776 key migration event directly, in addition to those crawled from relays. It replaces the old key's stored migration only if newer.",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"type": "object", "description": "A signed Nostr kind 1776 event"}}}
+        },
+        "responses": {
+          "200": {"description": "Whether the migration was stored, and its confirmation status"},
+          "400": {"description": "Invalid event, signature, or not a key migration"}
+        }
+      }
+    },
+    "/verify": {
+      "post": {
+        "tags": ["Verification"],
+        "operationId": "verifyAssertion",
+        "summary": "Verify a NIP-85 assertion from any provider",
+        "description": "Accepts a NIP-85 kind 30382 event (JSON) and cross-checks it against our own graph data. Verifies cryptographic signature, then compares claimed rank and follower count against our observations. Returns a verdict: consistent (claims match), divergent (claims don't match), unverifiable (no verifiable claims), or invalid (bad signature/structure). Enables multi-provider trust verification.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "description": "A Nostr event (kind 30382) with id, pubkey, sig, tags, etc.",
+                "properties": {
+                  "id": {"type": "string"},
+                  "pubkey": {"type": "string"},
+                  "created_at": {"type": "integer"},
+                  "kind": {"type": "integer", "enum": [30382]},
+                  "tags": {"type": "array", "items": {"type": "array", "items": {"type": "string"}}},
+                  "content": {"type": "string"},
+                  "sig": {"type": "string"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Verification result with per-field checks and overall verdict"},
+          "400": {"description": "Invalid JSON or wrong event kind"},
+          "402": {"description": "L402 payment required (2 sats)"},
+          "405": {"description": "Method not allowed (POST required)"}
+        }
+      }
+    },
+    "/attestation": {
+      "get": {
+        "tags": ["Verification"],
+        "operationId": "getAttestation",
+        "summary": "Signed score attestation for off-Nostr use",
+        "description": "Returns a compact statement of the subject's current score, rank, and graph build ID, signed by this provider's Nostr key and valid for a limited window. type=nostr (default) returns a signed event of kind 21385 (ephemeral range, so relays never store it) whose content is the claim and whose expiration tag (NIP-40) ends the validity window. type=jws returns a compact JWS signed with ES256K plus the signing key as a JWK; its x coordinate is the provider's Nostr pubkey. With min_score the claim only asserts score >= min_score (no exact score or rank) and is refused with 403 if the subject is below it. Claims use JWT names: iss, sub, score, score_gte, rank, build, iat, exp.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"},
+          {"name": "type", "in": "query", "required": false, "schema": {"type": "string", "enum": ["nostr", "jws"], "default": "nostr"}, "description": "Signature format"},
+          {"name": "ttl", "in": "query", "required": false, "schema": {"type": "integer", "default": 24, "minimum": 1, "maximum": 168}, "description": "Validity window in hours"},
+          {"name": "min_score", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 0, "maximum": 100}, "description": "Attest only that the score is at least this value"}
+        ],
+        "responses": {
+          "200": {"description": "Claim plus signed event (type=nostr) or JWS and JWK (type=jws)", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AttestationResponse"}}}},
+          "400": {"description": "Invalid pubkey or parameters"},
+          "403": {"description": "Score is below min_score"},
+          "503": {"description": "Scores not computed yet or no signing key configured"}
+        }
+      }
+    },
+    "/challenge": {
+      "post": {
+        "tags": ["Verification"],
+        "operationId": "postChallenge",
+        "summary": "Issue a score challenge token (NIP-98)",
+        "description": "Score-based CAPTCHA alternative. The caller authenticates with a NIP-98 Authorization header and receives a short-lived compact JWS (ES256K, provider key) asserting that the signer's score was at least threshold as of the current build. Claims: purpose (wot-challenge), iss, sub, score_gte, build, aud, nonce, jti, iat, exp. Sites verify the token at /challenge/verify or offline against the returned JWK.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["threshold"],
+                "properties": {
+                  "threshold": {"type": "integer", "minimum": 0, "maximum": 100},
+                  "audience": {"type": "string", "maxLength": 253, "description": "Site the token is for"},
+                  "nonce": {"type": "string", "maxLength": 128, "description": "Site-supplied nonce binding the token to a session"},
+                  "ttl_minutes": {"type": "integer", "minimum": 1, "maximum": 60, "default": 10}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Token, claim, signing JWK, and expiry"},
+          "400": {"description": "Invalid threshold, audience, nonce, or ttl_minutes"},
+          "401": {"description": "Missing or invalid NIP-98 authorization"},
+          "403": {"description": "Caller's score is below threshold"},
+          "503": {"description": "Scores not computed yet or no signing key configured"}
+        }
+      }
+    },
+    "/challenge/verify": {
+      "get": {
+        "tags": ["Verification"],
+        "operationId": "verifyChallenge",
+        "summary": "Verify a challenge token",
+        "description": "Checks a /challenge token's signature and expiry, and optionally its audience, nonce, and asserted threshold. Invalid tokens return valid=false with a reason. stale is true when the token was issued against an older graph build.",
+        "parameters": [
+          {"name": "token", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Token from POST /challenge"},
+          {"name": "audience", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Require this audience"},
+          {"name": "nonce", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Require this nonce"},
+          {"name": "min_score", "in": "query", "required": false, "schema": {"type": "integer", "minimum": 0, "maximum": 100}, "description": "Require score_gte to be at least this value"}
+        ],
+        "responses": {
+          "200": {"description": "valid, reason, claim, current_build, and stale"},
+          "400": {"description": "Missing token or invalid min_score"},
+          "503": {"description": "No signing key configured"}
+        }
+      }
+    },
+    "/anomalies": {
+      "get": {
+        "tags": ["Trust Analysis"],
+        "operationId": "getAnomalies",
+        "summary": "Trust anomaly detection for a pubkey",
+        "description": "Analyzes a pubkey's trust graph for anomalous patterns: follow-farming (high follow-back ratio), ghost/bot followers (zero-score followers), trust concentration (single-source dependency), score-follower divergence (many followers but low PageRank), excessive following, and zap wash trading (self-zaps, zap loops, alt-key funding; listed in zap_wash). Returns individual anomaly flags with severity levels and an overall risk assessment.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub to analyze"}
+        ],
+        "responses": {
+          "200": {"description": "Anomaly analysis with risk level and individual flags"},
+          "400": {"description": "Missing or invalid pubkey"},
+          "402": {"description": "L402 payment required (3 sats)"}
+        }
+      }
+    },
+    "/zap-score": {
+      "get": {
+        "tags": ["Trust Analysis"],
+        "operationId": "getZapScore",
+        "summary": "Zap-weighted score with wash-trading checks",
+        "description": "Adds a bounded boost (up to 10 points) to the WoT score from zaps received. Sats are weighted by each sender's WoT score (score/100) and the boost grows with the log of weighted sats. Senders in detected wash patterns are excluded: self-zaps, circular loops returning at least half the sats within 2-3 hops, and low-score alt keys linked by a follow that send 90%+ of their sats to the pubkey. Loops settled through the same LNURL service are flagged same_ln_service.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"}
+        ],
+        "responses": {
+          "200": {"description": "Base score, zap boost, sats accounting, and detected wash patterns"},
+          "400": {"description": "Invalid or missing pubkey"}
+        }
+      }
+    },
+    "/report-gaming": {
+      "post": {
+        "tags": ["Trust Analysis"],
+        "operationId": "reportGaming",
+        "summary": "Report score gaming (NIP-98)",
+        "description": "Submit structured evidence of follower buying or follow-ring behavior. The reporter authenticates with a NIP-98 Authorization header; the report is weighted by the reporter's WoT score (score/100). When the combined weight of open reports against a pubkey reaches 1.0, a targeted anomaly sweep runs automatically; for follow_ring reports the sweep also covers the subject's mutual follows. A reporter may hold one open report per pubkey.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["category", "pubkeys"],
+                "properties": {
+                  "category": {"type": "string", "enum": ["follower_buying", "follow_ring", "other"]},
+                  "pubkeys": {"type": "array", "items": {"type": "string"}, "maxItems": 20, "description": "Hex pubkeys or npubs of suspected accounts"},
+                  "event_ids": {"type": "array", "items": {"type": "string"}, "maxItems": 20, "description": "Hex ids of events supporting the report"},
+                  "description": {"type": "string", "maxLength": 2000}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "202": {"description": "Report queued; includes its weight and how many sweeps it triggered"},
+          "400": {"description": "Invalid category, pubkeys, or event ids"},
+          "401": {"description": "Missing or invalid NIP-98 authorization"},
+          "409": {"description": "Reporter already has an open report for a pubkey"}
+        }
+      }
+    },
+    "/admin/gaming-reports": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "listGamingReports",
+        "summary": "Gaming report queue (admin)",
+        "description": "Lists gaming reports, highest weight first, with sweep results and cumulative weight per reported pubkey. Requires Authorization: Bearer <ADMIN_TOKEN>.",
+        "parameters": [
+          {"name": "status", "in": "query", "required": false, "schema": {"type": "string", "enum": ["queued", "reviewed", "dismissed"]}, "description": "Filter by status"},
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "default": 50, "minimum": 1, "maximum": 500}, "description": "Max reports and subjects returned"}
+        ],
+        "responses": {
+          "200": {"description": "Reports and per-subject weights"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"}
+        }
+      }
+    },
+    "/admin/gaming-reports/review": {
+      "post": {
+        "tags": ["Infrastructure"],
+        "operationId": "reviewGamingReport",
+        "summary": "Review a gaming report (admin)",
+        "description": "Marks a report reviewed or dismissed and optionally re-runs the targeted anomaly sweep for its pubkeys. Dismissed reports no longer count toward a pubkey's weight. Requires Authorization: Bearer <ADMIN_TOKEN>.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["id"],
+                "properties": {
+                  "id": {"type": "string"},
+                  "status": {"type": "string", "enum": ["reviewed", "dismissed"]},
+                  "sweep": {"type": "boolean"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Updated report"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"},
+          "404": {"description": "Report not found"}
+        }
+      }
+    },
+    "/admin/bans": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "listBans",
+        "summary": "List bans (admin)",
+        "description": "Requires Authorization: Bearer <ADMIN_TOKEN>.",
+        "responses": {
+          "200": {"description": "Entries with pubkey, reason and added_at, newest first"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"}
+        }
+      },
+      "post": {
+        "tags": ["Infrastructure"],
+        "operationId": "banPubkey",
+        "summary": "Ban a pubkey (admin)",
+        "description": "Banned pubkeys get no PageRank or HITS score, their follows pass on no trust, and they are never published. Takes effect from the next rebuild or POST /admin/rescore. Requires Authorization: Bearer <ADMIN_TOKEN>.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["pubkey"],
+                "properties": {
+                  "pubkey": {"type": "string", "description": "Hex or npub"},
+                  "reason": {"type": "string", "maxLength": 500}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Added"},
+          "400": {"description": "Invalid pubkey or reason"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"},
+          "409": {"description": "Pubkey is both banned and pinned"}
+        }
+      },
+      "delete": {
+        "tags": ["Infrastructure"],
+        "operationId": "removeBans",
+        "summary": "Remove from bans (admin)",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex or npub"}
         ],
         "responses": {
-          "200": {"description": "Mute analysis with WoT scores and community signal"},
-          "400": {"description": "Missing or invalid pubkey/target"},
-          "402": {"description": "L402 payment required (2 sats)"}
+          "200": {"description": "Removed"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"},
+          "404": {"description": "Pubkey not in the list"}
         }
       }
     },
-    "/verify": {
+    "/admin/seeds": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "listSeeds",
+        "summary": "List seeds (admin)",
+        "description": "Requires Authorization: Bearer <ADMIN_TOKEN>.",
+        "responses": {
+          "200": {"description": "Entries with pubkey, reason and added_at, newest first"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"}
+        }
+      },
       "post": {
-        "tags": ["Verification"],
-        "operationId": "verifyAssertion",
-        "summary": "Verify a NIP-85 assertion from any provider",
-        "description": "Accepts a NIP-85 kind 30382 event (JSON) and cross-checks it against our own graph data. Verifies cryptographic signature, then compares claimed rank and follower count against our observations. Returns a verdict: consistent (claims match), divergent (claims don't match), unverifiable (no verifiable claims), or invalid (bad signature/structure). Enables multi-provider trust verification.",
+        "tags": ["Infrastructure"],
+        "operationId": "pinSeed",
+        "summary": "Pin a crawl seed (admin)",
+        "description": "Pinned seeds are crawled from alongside the configured seeds. Takes effect from the next rebuild or POST /admin/rescore. Requires Authorization: Bearer <ADMIN_TOKEN>.",
         "requestBody": {
           "required": true,
           "content": {
             "application/json": {
               "schema": {
                 "type": "object",
-                "description": "A Nostr event (kind 30382) with id, pubkey, sig, tags, etc.",
+                "required": ["pubkey"],
                 "properties": {
-                  "id": {"type": "string"},
-                  "pubkey": {"type": "string"},
-                  "created_at": {"type": "integer"},
-                  "kind": {"type": "integer", "enum": [30382]},
-                  "tags": {"type": "array", "items": {"type": "array", "items": {"type": "string"}}},
-                  "content": {"type": "string"},
-                  "sig": {"type": "string"}
+                  "pubkey": {"type": "string", "description": "Hex or npub"},
+                  "reason": {"type": "string", "maxLength": 500}
                 }
               }
             }
           }
         },
         "responses": {
-          "200": {"description": "Verification result with per-field checks and overall verdict"},
-          "400": {"description": "Invalid JSON or wrong event kind"},
-          "402": {"description": "L402 payment required (2 sats)"},
-          "405": {"description": "Method not allowed (POST required)"}
+          "200": {"description": "Added"},
+          "400": {"description": "Invalid pubkey or reason"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"},
+          "409": {"description": "Pubkey is both banned and pinned"}
+        }
+      },
+      "delete": {
+        "tags": ["Infrastructure"],
+        "operationId": "removeSeeds",
+        "summary": "Remove from seeds (admin)",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex or npub"}
+        ],
+        "responses": {
+          "200": {"description": "Removed"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"},
+          "404": {"description": "Pubkey not in the list"}
         }
       }
     },
-    "/anomalies": {
+    "/admin/recrawl": {
+      "post": {
+        "tags": ["Infrastructure"],
+        "operationId": "adminRecrawl",
+        "summary": "Re-crawl and rebuild (admin)",
+        "description": "Starts a full rebuild: crawl, scoring and publishing. Requires Authorization: Bearer <ADMIN_TOKEN>.",
+        "responses": {
+          "202": {"description": "Rebuild started; progress at /rebuild/status"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"},
+          "409": {"description": "A rebuild is already running"}
+        }
+      }
+    },
+    "/admin/rescore": {
+      "post": {
+        "tags": ["Infrastructure"],
+        "operationId": "adminRescore",
+        "summary": "Rescore the current graph (admin)",
+        "description": "Runs the pagerank, hits, distrust, communities and publish phases over the graph already crawled. Requires Authorization: Bearer <ADMIN_TOKEN>.",
+        "responses": {
+          "202": {"description": "Rebuild started; progress at /rebuild/status"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"},
+          "409": {"description": "A rebuild is already running"}
+        }
+      }
+    },
+    "/admin/audit-log": {
       "get": {
-        "tags": ["Trust Analysis"],
-        "operationId": "getAnomalies",
-        "summary": "Trust anomaly detection for a pubkey",
-        "description": "Analyzes a pubkey's trust graph for anomalous patterns: follow-farming (high follow-back ratio), ghost/bot followers (zero-score followers), trust concentration (single-source dependency), score-follower divergence (many followers but low PageRank), and excessive following. Returns individual anomaly flags with severity levels and an overall risk assessment.",
+        "tags": ["Infrastructure"],
+        "operationId": "getAdminAuditLog",
+        "summary": "Admin audit log (admin)",
+        "description": "Recent admin actions, newest first, with time, action, target, reason and source IP. Requires Authorization: Bearer <ADMIN_TOKEN>.",
         "parameters": [
-          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub to analyze"}
+          {"name": "limit", "in": "query", "required": false, "schema": {"type": "integer", "default": 100, "minimum": 1, "maximum": 1000}, "description": "Max actions returned"}
         ],
         "responses": {
-          "200": {"description": "Anomaly analysis with risk level and individual flags"},
-          "400": {"description": "Missing or invalid pubkey"},
-          "402": {"description": "L402 payment required (3 sats)"}
+          "200": {"description": "Actions"},
+          "400": {"description": "Invalid limit"},
+          "401": {"description": "Missing or invalid admin token"},
+          "403": {"description": "Admin API disabled"}
         }
       }
     },
@@ -633,6 +1916,35 @@ const openAPISpec = `{
         }
       }
     },
+    "/org-score": {
+      "post": {
+        "tags": ["Sybil Resistance"],
+        "operationId": "getOrgScore",
+        "summary": "Aggregate trust profile for an organization's accounts",
+        "description": "Scores a set of pubkeys operated by one brand or project as a unit: per-member score and anomaly risk level, min/median/max member score, combined external audience (unique followers outside the set) and audience overlap, and the share of member followers that are other members. Flags cross-member anomalies (internal_amplification, shared_ghost_followers, risky_members, weak_members); risk_level is the highest flagged severity.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["pubkeys"],
+                "properties": {
+                  "name": {"type": "string", "description": "Label echoed in the response"},
+                  "pubkeys": {"type": "array", "items": {"type": "string"}, "minItems": 2, "maxItems": 50, "description": "The organization's hex pubkeys or npubs"}
+                }
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Aggregate organization trust profile"},
+          "400": {"description": "Invalid JSON, invalid pubkey, or fewer than 2 / more than 50 distinct pubkeys"},
+          "402": {"description": "L402 payment required (10 sats)"},
+          "405": {"description": "Method not allowed (POST required)"}
+        }
+      }
+    },
     "/trust-path": {
       "get": {
         "tags": ["Trust Paths"],
@@ -642,7 +1954,9 @@ const openAPISpec = `{
         "parameters": [
           {"name": "from", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Source hex pubkey or npub"},
           {"name": "to", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Target hex pubkey or npub"},
-          {"name": "max_paths", "in": "query", "required": false, "schema": {"type": "integer", "default": 3, "minimum": 1, "maximum": 5}, "description": "Maximum number of distinct paths to find (1-5, default 3)"}
+          {"name": "max_paths", "in": "query", "required": false, "schema": {"type": "integer", "default": 3, "minimum": 1, "maximum": 5}, "description": "Maximum number of distinct paths to find (1-5, default 3)"},
+          {"name": "weighted", "in": "query", "required": false, "schema": {"type": "boolean", "default": false}, "description": "Find the cheapest paths by trust-weighted edge cost instead of the shortest; each path then carries its cost"},
+          {"name": "disjoint", "in": "query", "required": false, "schema": {"type": "boolean", "default": true}, "description": "Paths share no intermediate account; false requires weighted=true and returns Yen's k cheapest paths"}
         ],
         "responses": {
           "200": {"description": "Trust path analysis with scored paths, diversity metrics, and classification"},
@@ -658,7 +1972,8 @@ const openAPISpec = `{
         "summary": "Comprehensive reputation profile for a pubkey",
         "description": "Computes a composite reputation score (0-100, grade A-F) by combining five dimensions: WoT standing (PageRank percentile), Sybil resistance (follower quality and mutual trust), community integration (cluster membership and quality), anomaly cleanliness (absence of trust manipulation flags), and network diversity (follower spread across graph regions). Returns a detailed breakdown with per-component scores, grades, and a human-readable summary.",
         "parameters": [
-          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub to analyze"}
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub to analyze"},
+          {"name": "lang", "in": "query", "schema": {"type": "string", "enum": ["en", "de", "es", "ja"]}, "description": "Language of human-readable fields; defaults to Accept-Language, then en"}
         ],
         "responses": {
           "200": {"description": "Reputation profile with composite score, grade, component breakdown, and summary"},
@@ -702,6 +2017,60 @@ const openAPISpec = `{
         }
       }
     },
+    "/simulate": {
+      "post": {
+        "tags": ["Influence Analysis"],
+        "operationId": "simulateFollows",
+        "summary": "Project score, rank and percentile after hypothetical new follows",
+        "description": "What-if onboarding: projects how a pubkey's trust would change if the given accounts followed it, plus any other hypothetical follows. Starts from the current PageRank scores and pushes the change along follows until it falls below a tolerance (incremental approximation, no full recompute). Returns current and projected score, rank and percentile, per-follower direct contributions, and convergence details. Existing follows and self-follows are skipped.",
+        "requestBody": {
+          "required": true,
+          "content": {
+            "application/json": {
+              "schema": {
+                "type": "object",
+                "required": ["pubkey"],
+                "properties": {
+                  "pubkey": {"type": "string", "description": "The pubkey to project (hex or npub)"},
+                  "followers": {"type": "array", "items": {"type": "string"}, "description": "Accounts that would follow pubkey"},
+                  "edges": {
+                    "type": "array",
+                    "items": {
+                      "type": "object",
+                      "properties": {"from": {"type": "string"}, "to": {"type": "string"}}
+                    },
+                    "description": "Other hypothetical follows"
+                  }
+                },
+                "description": "followers and edges together may hold up to 500 follows"
+              }
+            }
+          }
+        },
+        "responses": {
+          "200": {"description": "Current and projected standing with per-follower contributions"},
+          "400": {"description": "Invalid JSON, invalid pubkeys, no follows, or more than 500"},
+          "402": {"description": "L402 payment required (5 sats)"},
+          "405": {"description": "Method not allowed (POST required)"},
+          "503": {"description": "Scores have not been computed yet"}
+        }
+      }
+    },
+    "/reach2": {
+      "get": {
+        "tags": ["Influence Analysis"],
+        "operationId": "getReach2",
+        "summary": "Estimated 2-hop audience size",
+        "description": "Estimates how many unique accounts sit within two follower hops of a pubkey (its followers plus their followers, excluding the pubkey itself). Audiences with up to 5000 second-hop edges are counted exactly; larger ones are estimated by unioning HyperLogLog sketches (1024 registers, about 3.25% standard error), with sketches of large first-hop audiences cached per graph build.",
+        "parameters": [
+          {"name": "pubkey", "in": "query", "required": true, "schema": {"type": "string"}, "description": "Hex pubkey or npub"}
+        ],
+        "responses": {
+          "200": {"description": "Follower count, 2-hop reach estimate, and estimation method"},
+          "400": {"description": "Invalid or missing pubkey"}
+        }
+      }
+    },
     "/influence/batch": {
       "post": {
         "tags": ["Influence Analysis"],
@@ -748,6 +2117,29 @@ const openAPISpec = `{
         }
       }
     },
+    "/bias": {
+      "get": {
+        "tags": ["Network Analysis"],
+        "operationId": "getSeedBias",
+        "summary": "How scores depend on proximity to the crawl seeds",
+        "description": "Groups pubkeys by hops from the nearest seed with their share of nodes and PageRank and their mean, median and p90 score, summarizes each seed's region and crawl budget use, and reports the Gini coefficient of score across seed regions. With a pubkey, also places that pubkey relative to the seeds.",
+        "parameters": [
+          {
+            "name": "pubkey",
+            "in": "query",
+            "required": false,
+            "description": "Hex pubkey or npub to place relative to the seeds",
+            "schema": {"type": "string"}
+          }
+        ],
+        "responses": {
+          "200": {"description": "Distance buckets (hops -1 is unreachable), seed regions, region_gini, last crawl budget report and, with a pubkey, node"},
+          "400": {"description": "Invalid pubkey"},
+          "402": {"description": "L402 payment required (5 sats)"},
+          "503": {"description": "Graph not built yet"}
+        }
+      }
+    },
     "/compare-providers": {
       "get": {
         "tags": ["Cross-Provider"],
@@ -775,7 +2167,7 @@ const openAPISpec = `{
         "tags": ["Real-Time"],
         "operationId": "wsScores",
         "summary": "Real-time score streaming via WebSocket",
-        "description": "WebSocket endpoint for live score updates. Connect, subscribe to pubkeys, receive current scores immediately then updates after each graph recomputation (~6h). Protocol: send {type:subscribe,pubkeys:[...]} to watch up to 100 pubkeys. Without WebSocket upgrade, returns endpoint documentation as JSON.",
+        "description": "WebSocket endpoint for live score updates. Connect, subscribe to pubkeys, receive current scores immediately then updates after each graph recomputation (~6h). Protocol: send {type:subscribe,pubkeys:[...]} to watch up to 100 pubkeys per connection (extra pubkeys come back in rejected), {type:unsubscribe,pubkeys:[...]} to stop watching them (omit pubkeys to drop all), and {type:ping} for an application-level pong. The server pings every 30s and closes connections that don't answer. Without WebSocket upgrade, returns endpoint documentation as JSON.",
         "responses": {
           "101": {"description": "WebSocket upgrade successful"},
           "200": {"description": "Endpoint documentation (non-WebSocket request)"}
@@ -859,12 +2251,93 @@ const openAPISpec = `{
         "tags": ["Infrastructure"],
         "operationId": "getPricing",
         "summary": "L402 pricing and free tier",
-        "description": "Returns current L402 paywall metadata including free tier and priced endpoints.",
+        "description": "Returns current L402 paywall metadata including free tier and priced endpoints, and the rate limit tiers: anonymous (per IP), authenticated and trusted (per pubkey, via a NIP-42 kind 22242 event in Authorization: Nostr <base64>).",
         "responses": {
           "200": {"description": "Pricing metadata", "content": {"application/json": {}}}
         }
       }
     },
+    "/l402/info": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "getL402Info",
+        "summary": "L402 token format and status",
+        "description": "Describes how to pay with L402: the Authorization format (L402 <base64 macaroon>:<hex preimage>), the caveats minted macaroons carry (valid_until, endpoints, max_requests), token lifetime, free tier and priced endpoints. Send a macaroon in the Authorization header (preimage optional) or as ?macaroon= to see its payment hash, scope, expiry and remaining requests; checking doesn't use a request.",
+        "parameters": [
+          {"name": "macaroon", "in": "query", "required": false, "schema": {"type": "string"}, "description": "Base64 macaroon to check"}
+        ],
+        "responses": {
+          "200": {"description": "L402 metadata, plus token status when a macaroon was sent", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/L402InfoResponse"}}}}
+        }
+      }
+    },
+    "/zap/request": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "getZapRequest",
+        "summary": "Start a zap payment",
+        "description": "Issues a request ID for paying by zap instead of an LNbits invoice. Zap service_pubkey (NIP-57) with the returned content (wot-credit:<id>) in the zap request and the returned relays in its relays tag. Verified receipts credit the zapped sats to the zapper's pubkey, which pays for priced endpoints when requests carry a NIP-98 Authorization: Nostr <base64 kind 27235 event> bound to the URL, method and body. Each event pays for one request. Returns 503 when zap payments aren't accepted.",
+        "responses": {
+          "200": {"description": "Request ID, zap content, service pubkey and relays", "content": {"application/json": {}}},
+          "503": {"description": "Zap payments not accepted", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      }
+    },
+    "/zap/balance": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "getZapBalance",
+        "summary": "Zap credit balance",
+        "description": "Returns the balance, sats spent and recent credits (with their request IDs) of the pubkey that signed the Authorization: Nostr <base64 kind 22242 event> header.",
+        "responses": {
+          "200": {"description": "Balance and credits", "content": {"application/json": {}}},
+          "401": {"description": "Missing or invalid auth event", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      }
+    },
+    "/account": {
+      "post": {
+        "tags": ["Infrastructure"],
+        "operationId": "createAccount",
+        "summary": "Create an API key account",
+        "description": "Creates a prepaid account for the pubkey signing a NIP-98 Authorization: Nostr <base64 kind 27235 event> bound to this URL and method, and returns its API key, shown only once. Calling it again rotates the key and keeps the balance. Send the key as X-API-Key: priced endpoints are then charged to the account's balance instead of per-request L402 payments, and the remaining balance is returned in X-Account-Balance. An empty balance returns 402 with code insufficient_balance.",
+        "responses": {
+          "201": {"description": "API key and balance", "content": {"application/json": {}}},
+          "401": {"description": "Missing or invalid auth event", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      }
+    },
+    "/account/topup": {
+      "post": {
+        "tags": ["Infrastructure"],
+        "operationId": "topupAccount",
+        "summary": "Top up an account over Lightning",
+        "description": "Returns a Lightning invoice for sats. Once paid, the sats are credited to the account on the next GET /account/usage. Requires X-API-Key. Unpaid invoices expire after 24 hours.",
+        "parameters": [
+          {"name": "sats", "in": "query", "required": true, "schema": {"type": "integer", "minimum": 10, "maximum": 1000000}, "description": "Amount to add"}
+        ],
+        "responses": {
+          "201": {"description": "Top-up invoice (payment_hash, invoice, sats, status)", "content": {"application/json": {}}},
+          "401": {"description": "Missing or unknown API key", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}},
+          "503": {"description": "Lightning payments not configured", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      }
+    },
+    "/account/usage": {
+      "get": {
+        "tags": ["Infrastructure"],
+        "operationId": "getAccountUsage",
+        "summary": "Account balance and usage history",
+        "description": "Returns the account's balance, sats spent, requests and sats per endpoint over the last days (totals and a daily history, newest first), and its top-ups. Paid top-ups are credited first. Requires X-API-Key.",
+        "parameters": [
+          {"name": "days", "in": "query", "required": false, "schema": {"type": "integer", "default": 7, "minimum": 1, "maximum": 30}, "description": "Days of history"}
+        ],
+        "responses": {
+          "200": {"description": "Balance, usage and top-ups", "content": {"application/json": {}}},
+          "401": {"description": "Missing or unknown API key", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}}
+        }
+      }
+    },
     "/openapi.json": {
       "get": {
         "tags": ["Infrastructure"],
@@ -879,26 +2352,449 @@ const openAPISpec = `{
   },
   "components": {
     "schemas": {
+      "Error": {
+        "type": "object",
+        "description": "Body of every 4xx/5xx response. Endpoints may add context fields alongside these.",
+        "properties": {
+          "error": {"type": "string", "description": "Human-readable message"},
+          "code": {"type": "string", "description": "Stable machine-readable code: missing_parameter, invalid_parameter, invalid_json, missing_body, invalid_request, not_found, method_not_allowed, payment_required, payload_too_large, rate_limited, internal_error, upstream_error, unavailable, ..."},
+          "field": {"type": "string", "description": "Query parameter or body field at fault, when there is one"}
+        },
+        "required": ["error", "code"]
+      },
       "ScoreResponse": {
         "type": "object",
+        "required": ["schema_version", "pubkey", "raw_score", "score", "normalization", "found", "graph_size", "followers", "post_count", "reply_count", "reactions", "zap_amount", "zap_count", "verified_zap_amount"],
         "properties": {
+          "schema_version": {"type": "integer", "description": "Response schema version"},
           "pubkey": {"type": "string", "description": "Hex pubkey"},
           "score": {"type": "integer", "description": "Normalized score (0-100)"},
+          "normalization": {"type": "string", "description": "Curve used for score: log, percentile, zscore or minmax"},
+          "view": {"type": "string", "description": "Seed view the score is from; absent for the main seeds"},
           "raw_score": {"type": "number", "description": "Raw PageRank value"},
           "found": {"type": "boolean", "description": "Whether pubkey exists in graph"},
           "graph_size": {"type": "integer", "description": "Total nodes in graph"},
+          "staleness": {
+            "type": "object",
+            "description": "How current the score is: live contact lists update scores incrementally between full rebuilds",
+            "properties": {
+              "source": {"type": "string", "enum": ["full_rebuild", "incremental"]},
+              "computed_at": {"type": "string", "format": "date-time", "description": "Last full computation"},
+              "updated_at": {"type": "string", "format": "date-time", "description": "Last incremental update of this score"},
+              "age_seconds": {"type": "integer", "description": "Seconds since the score last changed"},
+              "updates_since_rebuild": {"type": "integer", "description": "Incremental updates applied to the graph since the last full computation"},
+              "drift_residual": {"type": "number", "description": "Score mass those updates left unpropagated"}
+            }
+          },
           "followers": {"type": "integer"},
           "post_count": {"type": "integer"},
           "reply_count": {"type": "integer"},
           "reactions": {"type": "integer"},
           "zap_amount": {"type": "integer"},
           "zap_count": {"type": "integer"},
-          "composite_score": {"type": "number", "description": "Blended score from multiple NIP-85 providers"},
+          "verified_zap_amount": {"type": "integer", "description": "Sats from zap receipts that passed NIP-57 verification"},
+          "percentile_bucket": {"type": "integer", "enum": [1, 5, 10, 25, 50, 100]},
+          "crawl": {"type": "string", "description": "How to fetch a pubkey that isn't in the graph"},
+          "distrust_penalty": {"type": "number"},
+          "distrust_adjusted_score": {"type": "integer"},
+          "composite_score": {"type": "integer", "description": "Blended score from multiple NIP-85 providers"},
+          "external_assertions": {"type": "array", "items": {"$ref": "#/components/schemas/ExternalSource"}},
+          "endorsed_score": {"type": "integer"},
+          "endorsement_boost": {"type": "integer"},
+          "inherited_trust": {
+            "type": "object",
+            "properties": {
+              "from": {"type": "string"},
+              "old_score": {"type": "integer"},
+              "inherited": {"type": "integer"},
+              "confirmed_by": {"type": "integer"}
+            }
+          },
+          "migrated_score": {"type": "integer"},
+          "migrated_to": {"type": "string"},
+          "annotations": {"type": "array", "items": {"type": "object"}},
           "topics": {"type": "array", "items": {"type": "string"}, "description": "Top hashtag topics"},
           "active_hours_start": {"type": "integer", "description": "Most active hour (UTC)"},
           "active_hours_end": {"type": "integer", "description": "End of active window (UTC)"},
           "reports_received": {"type": "integer"},
-          "reports_sent": {"type": "integer"}
+          "reports_sent": {"type": "integer"},
+          "algorithm": {"type": "string", "description": "hits when requested"},
+          "hub_score": {"type": "integer", "description": "HITS hub score (0-100): follows the accounts that matter"},
+          "authority_score": {"type": "integer", "description": "HITS authority score (0-100): followed by the accounts that curate"},
+          "raw_hub_score": {"type": "number"},
+          "raw_authority_score": {"type": "number"},
+          "role": {"type": "string", "enum": ["curator", "producer", "balanced"]}
+        }
+      },
+      "ExternalSource": {
+        "type": "object",
+        "description": "One external NIP-85 provider's assertion that went into composite_score",
+        "required": ["provider", "raw_rank", "normalized_rank", "weight", "age"],
+        "properties": {
+          "provider": {"type": "string", "description": "Provider pubkey"},
+          "raw_rank": {"type": "integer"},
+          "normalized_rank": {"type": "integer"},
+          "weight": {"type": "number"},
+          "age": {"type": "string", "description": "Time since the assertion was published"}
+        }
+      },
+      "BatchResponse": {
+        "type": "object",
+        "required": ["schema_version", "results", "graph_size"],
+        "properties": {
+          "schema_version": {"type": "integer"},
+          "results": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["pubkey", "score", "found", "followers"],
+              "properties": {
+                "pubkey": {"type": "string", "description": "Hex pubkey, or the input when it couldn't be read"},
+                "score": {"type": "integer"},
+                "found": {"type": "boolean"},
+                "followers": {"type": "integer"},
+                "composite_score": {"type": "integer"},
+                "error": {"type": "string"}
+              }
+            }
+          },
+          "graph_size": {"type": "integer"}
+        }
+      },
+      "PersonalizedResponse": {
+        "type": "object",
+        "required": ["schema_version", "viewer", "target", "algorithm", "personalized_score", "global_score", "found", "viewer_follows_target", "target_follows_viewer", "mutual_follow", "trusted_followers", "trusted_follower_sample", "shared_follows", "viewer_follows_source", "graph_size"],
+        "properties": {
+          "schema_version": {"type": "integer"},
+          "viewer": {"type": "string"},
+          "target": {"type": "string"},
+          "algorithm": {"type": "string", "enum": ["blend", "ppr"]},
+          "personalized_score": {"type": "integer"},
+          "global_score": {"type": "integer"},
+          "found": {"type": "boolean"},
+          "viewer_follows_target": {"type": "boolean"},
+          "target_follows_viewer": {"type": "boolean"},
+          "mutual_follow": {"type": "boolean"},
+          "trusted_followers": {"type": "integer"},
+          "trusted_follower_sample": {"type": "array", "items": {"type": "string"}},
+          "shared_follows": {"type": "integer"},
+          "viewer_follows_source": {"type": "string"},
+          "graph_size": {"type": "integer"},
+          "ppr_score": {"type": "number", "description": "With algorithm=ppr"},
+          "ppr_rank": {"type": "integer", "description": "With algorithm=ppr"},
+          "ppr_reachable": {"type": "integer", "description": "With algorithm=ppr"}
+        }
+      },
+      "HealthResponse": {
+        "type": "object",
+        "required": ["schema_version", "status", "relay_health", "graph_nodes", "graph_edges", "uptime"],
+        "properties": {
+          "schema_version": {"type": "integer"},
+          "status": {"type": "string"},
+          "relay_health": {"type": "object", "description": "Free-form relay diagnostics; keys may change within a schema version"},
+          "graph_nodes": {"type": "integer"},
+          "graph_edges": {"type": "integer"},
+          "events": {"type": "integer"},
+          "addressable": {"type": "integer"},
+          "external": {"type": "integer"},
+          "external_providers": {"type": "integer"},
+          "external_assertions": {"type": "integer"},
+          "authorizations": {"type": "integer"},
+          "authorized_users": {"type": "integer"},
+          "communities": {"type": "integer"},
+          "mute_lists": {"type": "integer"},
+          "muted_pubkeys": {"type": "integer"},
+          "graph_store": {"type": "object", "description": "Free-form storage diagnostics; keys may change within a schema version"},
+          "uptime": {"type": "string"}
+        }
+      },
+      "DecayResponse": {
+        "type": "object",
+        "required": ["schema_version", "pubkey", "decay_score", "static_score", "delta", "half_life_days", "found", "follower_count", "followers_with_time_data", "graph_size"],
+        "properties": {
+          "schema_version": {"type": "integer"},
+          "pubkey": {"type": "string"},
+          "decay_score": {"type": "integer"},
+          "static_score": {"type": "integer"},
+          "delta": {"type": "integer", "description": "decay_score - static_score"},
+          "half_life_days": {"type": "number"},
+          "found": {"type": "boolean"},
+          "follower_count": {"type": "integer"},
+          "followers_with_time_data": {"type": "integer"},
+          "activity_status": {"type": "string", "description": "With dormancy=true"},
+          "dormancy_discount": {"type": "number", "description": "With dormancy=true"},
+          "oldest_follow": {"type": "string", "format": "date-time", "description": "When any follow has a known time"},
+          "newest_follow": {"type": "string", "format": "date-time", "description": "When any follow has a known time"},
+          "graph_size": {"type": "integer"}
+        }
+      },
+      "DecayTopResponse": {
+        "type": "object",
+        "required": ["schema_version", "entries", "half_life_days", "dormancy", "graph_size", "algorithm"],
+        "properties": {
+          "schema_version": {"type": "integer"},
+          "entries": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["pubkey", "decay_score", "static_score", "delta", "decay_rank", "static_rank", "rank_change", "momentum", "decay_ratio", "recent_follows", "follow_velocity", "followers", "community_id"],
+              "properties": {
+                "pubkey": {"type": "string"},
+                "decay_score": {"type": "integer"},
+                "static_score": {"type": "integer"},
+                "delta": {"type": "integer"},
+                "decay_rank": {"type": "integer"},
+                "static_rank": {"type": "integer"},
+                "rank_change": {"type": "integer", "description": "Positive when the pubkey ranks higher with decay"},
+                "momentum": {"type": "string", "enum": ["rising", "steady", "fading"]},
+                "decay_ratio": {"type": "number"},
+                "recent_follows": {"type": "integer"},
+                "follow_velocity": {"type": "number"},
+                "followers": {"type": "integer"},
+                "community_id": {"type": "integer", "description": "-1 when unclustered"}
+              }
+            }
+          },
+          "half_life_days": {"type": "number"},
+          "dormancy": {"type": "boolean"},
+          "community": {"type": "integer", "description": "With the community filter"},
+          "min_followers": {"type": "integer", "description": "With a min_followers filter above 0"},
+          "momentum": {"type": "string", "description": "With the momentum filter"},
+          "graph_size": {"type": "integer"},
+          "algorithm": {"type": "string"}
+        }
+      },
+      "CompareProfile": {
+        "type": "object",
+        "required": ["pubkey", "in_graph", "wot_score", "rank", "percentile", "follows_count", "followers_count"],
+        "properties": {
+          "pubkey": {"type": "string"},
+          "in_graph": {"type": "boolean"},
+          "wot_score": {"type": "integer"},
+          "rank": {"type": "integer"},
+          "percentile": {"type": "number"},
+          "follows_count": {"type": "integer"},
+          "followers_count": {"type": "integer"}
+        }
+      },
+      "ScoredPubkey": {
+        "type": "object",
+        "required": ["pubkey", "wot_score"],
+        "properties": {
+          "pubkey": {"type": "string"},
+          "wot_score": {"type": "integer"}
+        }
+      },
+      "CompareResponse": {
+        "type": "object",
+        "required": ["schema_version", "a", "b", "relationship", "shared_follows_count", "shared_followers_count", "follow_similarity", "top_shared_follows", "top_shared_followers", "trust_path", "graph_size"],
+        "properties": {
+          "schema_version": {"type": "integer"},
+          "a": {"$ref": "#/components/schemas/CompareProfile"},
+          "b": {"$ref": "#/components/schemas/CompareProfile"},
+          "relationship": {"type": "string", "enum": ["mutual", "a_follows_b", "b_follows_a", "none"]},
+          "shared_follows_count": {"type": "integer"},
+          "shared_followers_count": {"type": "integer"},
+          "follow_similarity": {"type": "number", "description": "Jaccard similarity of the follow sets"},
+          "top_shared_follows": {"type": "array", "items": {"$ref": "#/components/schemas/ScoredPubkey"}},
+          "top_shared_followers": {"type": "array", "items": {"$ref": "#/components/schemas/ScoredPubkey"}},
+          "trust_path": {
+            "type": "object",
+            "required": ["found", "hops", "path"],
+            "properties": {
+              "found": {"type": "boolean"},
+              "hops": {"type": "integer"},
+              "path": {"type": "array", "items": {"type": "string"}, "description": "Empty when there is no path within 6 hops"}
+            }
+          },
+          "graph_size": {"type": "integer"}
+        }
+      },
+      "PathNode": {
+        "type": "object",
+        "required": ["pubkey", "wot_score", "community"],
+        "properties": {
+          "pubkey": {"type": "string"},
+          "wot_score": {"type": "integer"},
+          "community": {"type": "integer", "description": "-1 when not in a detected community"},
+          "spam": {"type": "string", "description": "Intermediates only"},
+          "sybil": {"type": "string", "description": "Intermediates only"},
+          "flagged": {"type": "boolean", "description": "Intermediates classified likely_spam or likely_sybil"}
+        }
+      },
+      "PathHop": {
+        "type": "object",
+        "required": ["from", "to", "relation", "same_community", "explanation"],
+        "properties": {
+          "from": {"type": "string"},
+          "to": {"type": "string"},
+          "relation": {"type": "string", "enum": ["mutual", "one_way"]},
+          "followed_at": {"type": "string", "format": "date-time", "description": "When the follow time is known"},
+          "follow_age_days": {"type": "integer", "description": "When the follow time is known"},
+          "same_community": {"type": "boolean"},
+          "explanation": {"type": "string"}
+        }
+      },
+      "GraphPathResponse": {
+        "type": "object",
+        "description": "/graph path mode for a single shortest path. Without a path, path and hop_details are empty.",
+        "required": ["schema_version", "from", "to", "found", "path", "hops", "hop_details", "flagged_intermediates", "options", "excluded_nodes", "graph_size"],
+        "properties": {
+          "schema_version": {"type": "integer"},
+          "from": {"type": "string"},
+          "to": {"type": "string"},
+          "found": {"type": "boolean"},
+          "path": {"type": "array", "items": {"$ref": "#/components/schemas/PathNode"}},
+          "hops": {"type": "integer"},
+          "hop_details": {"type": "array", "items": {"$ref": "#/components/schemas/PathHop"}},
+          "flagged_intermediates": {"type": "integer"},
+          "options": {"type": "object", "description": "The path options that were applied"},
+          "excluded_nodes": {"type": "integer"},
+          "graph_size": {"type": "integer"}
+        }
+      },
+      "GraphNeighborhood": {
+        "type": "object",
+        "description": "/graph neighborhood mode",
+        "required": ["schema_version", "pubkey", "wot_score", "follows_count", "followers_count", "mutual_count", "neighbors", "depth", "graph_size"],
+        "properties": {
+          "schema_version": {"type": "integer"},
+          "pubkey": {"type": "string"},
+          "wot_score": {"type": "integer"},
+          "follows_count": {"type": "integer"},
+          "followers_count": {"type": "integer"},
+          "mutual_count": {"type": "integer"},
+          "neighbors": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "required": ["pubkey", "wot_score", "relation"],
+              "properties": {
+                "pubkey": {"type": "string"},
+                "wot_score": {"type": "integer"},
+                "relation": {"type": "string", "enum": ["follows", "follower", "mutual", "extended"]}
+              }
+            }
+          },
+          "depth": {"type": "integer"},
+          "graph_size": {"type": "integer"}
+        }
+      },
+      "GraphKPath": {
+        "type": "object",
+        "required": ["path", "hops", "cost", "hop_details", "flagged_intermediates"],
+        "properties": {
+          "path": {"type": "array", "items": {"$ref": "#/components/schemas/PathNode"}},
+          "hops": {"type": "integer"},
+          "cost": {"type": "number"},
+          "hop_details": {"type": "array", "items": {"$ref": "#/components/schemas/PathHop"}},
+          "flagged_intermediates": {"type": "integer"}
+        }
+      },
+      "GraphKPathsResponse": {
+        "type": "object",
+        "description": "/graph path mode with k > 1 or weighted=true. The single-path fields repeat the cheapest path.",
+        "required": ["schema_version", "from", "to", "found", "path", "hops", "hop_details", "flagged_intermediates", "paths", "options", "excluded_nodes", "truncated", "graph_size"],
+        "properties": {
+          "schema_version": {"type": "integer"},
+          "from": {"type": "string"},
+          "to": {"type": "string"},
+          "found": {"type": "boolean"},
+          "path": {"type": "array", "items": {"$ref": "#/components/schemas/PathNode"}},
+          "hops": {"type": "integer"},
+          "cost": {"type": "number", "description": "When a path was found"},
+          "hop_details": {"type": "array", "items": {"$ref": "#/components/schemas/PathHop"}},
+          "flagged_intermediates": {"type": "integer"},
+          "paths": {"type": "array", "items": {"$ref": "#/components/schemas/GraphKPath"}, "description": "Cheapest first"},
+          "diversity": {"type": "number", "description": "Share of distinct intermediates across paths, when a path was found"},
+          "options": {"type": "object", "description": "The path options that were applied"},
+          "excluded_nodes": {"type": "integer"},
+          "truncated": {"type": "boolean", "description": "The search hit its node budget"},
+          "graph_size": {"type": "integer"}
+        }
+      },
+      "AttestationResponse": {
+        "type": "object",
+        "required": ["schema_version", "type", "claim", "issuer", "expires_at"],
+        "properties": {
+          "schema_version": {"type": "integer"},
+          "type": {"type": "string", "enum": ["nostr", "jws"]},
+          "claim": {
+            "type": "object",
+            "required": ["iss", "sub", "build", "iat", "exp"],
+            "properties": {
+              "iss": {"type": "string"},
+              "sub": {"type": "string"},
+              "score": {"type": "integer", "description": "Without min_score"},
+              "score_gte": {"type": "integer", "description": "With min_score"},
+              "rank": {"type": "integer", "description": "Without min_score"},
+              "build": {"type": "integer"},
+              "iat": {"type": "integer"},
+              "exp": {"type": "integer"}
+            }
+          },
+          "issuer": {"type": "string"},
+          "expires_at": {"type": "string", "format": "date-time"},
+          "event": {"type": "object", "description": "Signed kind 21385 event, with type=nostr"},
+          "jws": {"type": "string", "description": "Compact JWS, with type=jws"},
+          "jwk": {"type": "object", "additionalProperties": {"type": "string"}, "description": "Signing key, with type=jws"}
+        }
+      },
+      "AssertionsResponse": {
+        "type": "object",
+        "required": ["schema_version", "subject", "history", "count", "events"],
+        "properties": {
+          "schema_version": {"type": "integer"},
+          "subject": {"type": "string"},
+          "provider": {"type": "string", "description": "With the provider filter"},
+          "history": {"type": "boolean"},
+          "count": {"type": "integer"},
+          "events": {"type": "array", "items": {"type": "object"}, "description": "Signed kind 30382 events, newest first"}
+        }
+      },
+      "L402InfoResponse": {
+        "type": "object",
+        "required": ["schema_version", "enabled", "scheme", "authorization", "caveats", "max_requests_per_token", "requests_query_param", "zap"],
+        "properties": {
+          "schema_version": {"type": "integer"},
+          "enabled": {"type": "boolean"},
+          "scheme": {"type": "string"},
+          "authorization": {"type": "string"},
+          "caveats": {"type": "object", "additionalProperties": {"type": "string"}},
+          "max_requests_per_token": {"type": "integer"},
+          "requests_query_param": {"type": "string"},
+          "token_ttl_seconds": {"type": "integer", "description": "When L402 is enabled"},
+          "free_tier_per_ip_per_day": {"type": "integer", "description": "When L402 is enabled"},
+          "priced_endpoints": {
+            "type": "array",
+            "description": "When L402 is enabled",
+            "items": {
+              "type": "object",
+              "properties": {
+                "path": {"type": "string"},
+                "price_sats": {"type": "integer"}
+              }
+            }
+          },
+          "token": {
+            "type": "object",
+            "description": "When a macaroon was presented",
+            "required": ["valid", "paid", "used", "remaining"],
+            "properties": {
+              "valid": {"type": "boolean"},
+              "paid": {"type": "boolean", "description": "A matching preimage was presented"},
+              "error": {"type": "string"},
+              "used": {"type": "integer"},
+              "remaining": {"type": "integer"},
+              "expires_at": {"type": "string", "format": "date-time"},
+              "payment_hash": {"type": "string"},
+              "token_id": {"type": "string"},
+              "valid_until": {"type": "integer"},
+              "endpoints": {"type": "array", "items": {"type": "string"}},
+              "max_requests": {"type": "integer"}
+            }
+          },
+          "zap": {"type": "object", "description": "Free-form zap payment diagnostics; keys may change within a schema version"}
         }
       },
       "Error": {
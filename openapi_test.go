@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -34,15 +36,15 @@ func TestOpenAPIReturnsValidJSON(t *testing.T) {
 
 func TestOpenAPIContainsAllEndpoints(t *testing.T) {
 	endpoints := []string{
-		"/score", "/audit", "/batch", "/personalized", "/similar",
+		"/score", "/audit", "/batch", "/audit/batch", "/personalized/batch", "/graph/batch", "/rank", "/graphql", "/personalized", "/personalized/import", "/similar",
 		"/recommend", "/compare", "/graph", "/weboftrust",
-		"/nip05", "/nip05/batch", "/nip05/reverse",
-		"/timeline", "/decay", "/decay/top",
-		"/spam", "/spam/batch", "/blocked", "/verify", "/anomalies",
-		"/sybil", "/sybil/batch", "/trust-path", "/reputation", "/predict", "/influence", "/influence/batch", "/network-health", "/compare-providers", "/ws/scores",
-		"/metadata", "/event", "/external",
-		"/top", "/export", "/relay", "/authorized", "/communities",
-		"/publish", "/providers", "/health", "/docs", "/swagger", "/openapi.json",
+		"/nip05", "/nip05/batch", "/nip05/reverse", "/domain",
+		"/timeline", "/history", "/contacts/snapshot", "/active", "/growth-sources", "/churn", "/decay", "/decay/top",
+		"/spam", "/spam/batch", "/spam/event", "/blocked", "/annotations", "/endorsements", "/migrations", "/verify", "/attestation", "/challenge", "/challenge/verify", "/anomalies", "/zap-score",
+		"/sybil", "/sybil/batch", "/org-score", "/trust-path", "/reputation", "/predict", "/influence", "/influence/batch", "/simulate", "/reach2", "/network-health", "/bias", "/compare-providers", "/ws/scores",
+		"/metadata", "/metadata/batch", "/event", "/external", "/external/trending", "/articles/top",
+		"/top", "/export", "/export/bloom", "/snapshots", "/snapshots/{id}/export", "/relay", "/relay/suggest", "/relay/top", "/authorized", "/communities", "/bridges", "/communities/map",
+		"/publish", "/publish/status", "/providers", "/providers/accuracy", "/assertions", "/assertion-schema", "/rebuild", "/rebuild/status", "/crawl/status", "/crawl", "/rebuild/cancel", "/hint", "/ingest", "/sandbox/score", "/analytics/subjects", "/report-gaming", "/admin/gaming-reports", "/admin/gaming-reports/review", "/admin/bans", "/admin/seeds", "/admin/recrawl", "/admin/rescore", "/admin/audit-log", "/reports", "/health", "/docs", "/swagger", "/openapi.json", "/l402/info", "/zap/request", "/zap/balance", "/account", "/account/topup", "/account/usage",
 	}
 
 	var spec map[string]interface{}
@@ -105,3 +107,78 @@ func TestOpenAPIHasNIP85Description(t *testing.T) {
 		t.Error("OpenAPI spec should mention PageRank")
 	}
 }
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	var spec struct {
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal([]byte(openAPISpec), &spec); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, m := range regexp.MustCompile(`"#/components/schemas/(\w+)"`).FindAllStringSubmatch(openAPISpec, -1) {
+		if spec.Components.Schemas[m[1]] == nil {
+			t.Errorf("$ref to undefined schema %s", m[1])
+		}
+	}
+}
+
+// TestOpenAPISchemasMatchStructs checks that each typed response schema lists
+// exactly the struct's JSON fields, with the ones that are always present as
+// required.
+func TestOpenAPISchemasMatchStructs(t *testing.T) {
+	var spec struct {
+		Components struct {
+			Schemas map[string]struct {
+				Required   []string                   `json:"required"`
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal([]byte(openAPISpec), &spec); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	for name, v := range map[string]interface{}{
+		"DecayResponse":       DecayResponse{},
+		"DecayTopResponse":    DecayTopResponse{},
+		"CompareResponse":     CompareResponse{},
+		"GraphPathResponse":   GraphPathResponse{},
+		"GraphKPathsResponse": GraphKPathsResponse{},
+		"AttestationResponse": AttestationResponse{},
+		"AssertionsResponse":  AssertionsResponse{},
+		"L402InfoResponse":    L402InfoResponse{},
+	} {
+		schema, ok := spec.Components.Schemas[name]
+		if !ok {
+			t.Errorf("%s: no schema", name)
+			continue
+		}
+		required := map[string]bool{}
+		for _, f := range schema.Required {
+			required[f] = true
+		}
+		fields := map[string]bool{"schema_version": true}
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			tag := typ.Field(i).Tag.Get("json")
+			if tag == "" || tag == "-" {
+				continue
+			}
+			field, opts, _ := strings.Cut(tag, ",")
+			fields[field] = true
+			if _, ok := schema.Properties[field]; !ok {
+				t.Errorf("%s: %s missing from properties", name, field)
+			}
+			if always := opts != "omitempty"; always != required[field] {
+				t.Errorf("%s: %s required=%v, want %v", name, field, required[field], always)
+			}
+		}
+		for field := range schema.Properties {
+			if !fields[field] {
+				t.Errorf("%s: property %s is not in the struct", name, field)
+			}
+		}
+	}
+}
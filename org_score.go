@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+)
+
+const (
+	orgScoreMinMembers = 2
+	orgScoreMaxMembers = 50
+)
+
+// OrgScoreRequest is the POST body for /org-score.
+type OrgScoreRequest struct {
+	Name    string   `json:"name"`
+	Pubkeys []string `json:"pubkeys"`
+}
+
+// OrgMember is one account of the organization.
+type OrgMember struct {
+	Pubkey    string `json:"pubkey"`
+	Score     int    `json:"score"`
+	Rank      int    `json:"rank"`
+	Followers int    `json:"followers"`
+	RiskLevel string `json:"risk_level"`
+}
+
+// OrgScoreResponse is the aggregate trust profile for a set of accounts run by one
+// organization.
+type OrgScoreResponse struct {
+	Name                string        `json:"name,omitempty"`
+	Members             []OrgMember   `json:"members"`
+	MemberCount         int           `json:"member_count"`
+	MinScore            int           `json:"min_score"`
+	MedianScore         float64       `json:"median_score"`
+	MaxScore            int           `json:"max_score"`
+	CombinedAudience    int           `json:"combined_audience"`     // unique followers outside the org
+	AudienceOverlap     float64       `json:"audience_overlap"`      // share of external follows that are duplicates
+	InternalFollowShare float64       `json:"internal_follow_share"` // share of member followers that are other members
+	SharedGhosts        int           `json:"shared_ghost_followers"`
+	Anomalies           []AnomalyFlag `json:"anomalies"`
+	RiskLevel           string        `json:"risk_level"`
+	GraphSize           int           `json:"graph_size"`
+}
+
+// computeOrgScore aggregates members' scores and audiences and looks for patterns
+// only visible across the set: members inflating each other, one low-quality audience
+// following every account, and several members that are individually risky.
+func computeOrgScore(name string, members []string) OrgScoreResponse {
+	stats := graph.Stats()
+	resp := OrgScoreResponse{Name: name, MemberCount: len(members), GraphSize: stats.Nodes}
+
+	isMember := make(map[string]bool, len(members))
+	for _, m := range members {
+		isMember[m] = true
+	}
+
+	scores := make([]int, 0, len(members))
+	audience := make(map[string]int) // external follower -> members followed
+	externalEdges, internalEdges := 0, 0
+	riskCounts := make(map[string]int)
+	for _, m := range members {
+		a := computeAnomalies(m)
+		followers := graph.GetFollowers(m)
+		resp.Members = append(resp.Members, OrgMember{
+			Pubkey:    m,
+			Score:     a.Score,
+			Rank:      a.Rank,
+			Followers: len(followers),
+			RiskLevel: a.RiskLevel,
+		})
+		scores = append(scores, a.Score)
+		riskCounts[a.RiskLevel]++
+		for _, f := range followers {
+			if isMember[f] {
+				internalEdges++
+				continue
+			}
+			audience[f]++
+			externalEdges++
+		}
+	}
+
+	sort.Ints(scores)
+	resp.MinScore, resp.MaxScore = scores[0], scores[len(scores)-1]
+	if mid := len(scores) / 2; len(scores)%2 == 1 {
+		resp.MedianScore = float64(scores[mid])
+	} else {
+		resp.MedianScore = float64(scores[mid-1]+scores[mid]) / 2
+	}
+
+	resp.CombinedAudience = len(audience)
+	if externalEdges > 0 {
+		resp.AudienceOverlap = math.Round((1-float64(len(audience))/float64(externalEdges))*1000) / 1000
+	}
+	if total := externalEdges + internalEdges; total > 0 {
+		resp.InternalFollowShare = math.Round(float64(internalEdges)/float64(total)*1000) / 1000
+	}
+	for f, n := range audience {
+		if n < 2 {
+			continue
+		}
+		raw, ok := graph.GetScore(f)
+		if !ok || normalizeScore(raw, stats.Nodes) < 5 {
+			resp.SharedGhosts++
+		}
+	}
+
+	anomalies := make([]AnomalyFlag, 0)
+
+	// Internal amplification: members make up a large share of each other's followers
+	if total := externalEdges + internalEdges; total >= 20 && resp.InternalFollowShare > 0.25 {
+		severity := "medium"
+		if resp.InternalFollowShare > 0.5 {
+			severity = "high"
+		}
+		anomalies = append(anomalies, AnomalyFlag{
+			Type:        "internal_amplification",
+			Severity:    severity,
+			Description: fmt.Sprintf("%.0f%% of member followers are other members of the organization", resp.InternalFollowShare*100),
+			Value:       resp.InternalFollowShare,
+			Threshold:   0.25,
+		})
+	}
+
+	// Shared ghost audience: the same low-trust accounts follow several members
+	if resp.CombinedAudience > 0 && resp.SharedGhosts >= 10 {
+		ratio := float64(resp.SharedGhosts) / float64(resp.CombinedAudience)
+		if ratio > 0.2 {
+			severity := "medium"
+			if ratio > 0.4 {
+				severity = "high"
+			}
+			anomalies = append(anomalies, AnomalyFlag{
+				Type:        "shared_ghost_followers",
+				Severity:    severity,
+				Description: fmt.Sprintf("%d ghost followers (score < 5) follow more than one member, %.0f%% of the combined audience", resp.SharedGhosts, ratio*100),
+				Value:       math.Round(ratio*1000) / 1000,
+				Threshold:   0.2,
+			})
+		}
+	}
+
+	// Risky members: several accounts are individually flagged
+	if risky := riskCounts["high"] + riskCounts["medium"]; riskCounts["high"] >= 2 || risky*2 >= len(members) && risky >= 2 {
+		severity := "medium"
+		if riskCounts["high"] >= 2 {
+			severity = "high"
+		}
+		anomalies = append(anomalies, AnomalyFlag{
+			Type:        "risky_members",
+			Severity:    severity,
+			Description: fmt.Sprintf("%d of %d members have medium or high anomaly risk", risky, len(members)),
+			Value:       float64(risky),
+			Threshold:   2,
+		})
+	}
+
+	// Weak members: a trusted flagship alongside accounts with no standing of their own
+	if resp.MaxScore >= 50 && resp.MinScore < 5 {
+		anomalies = append(anomalies, AnomalyFlag{
+			Type:        "weak_members",
+			Severity:    "low",
+			Description: fmt.Sprintf("Member scores range from %d to %d; some accounts rely on the organization's name rather than their own trust", resp.MinScore, resp.MaxScore),
+			Value:       float64(resp.MinScore),
+			Threshold:   5,
+		})
+	}
+
+	resp.RiskLevel = "clean"
+	if len(anomalies) > 0 {
+		sort.SliceStable(anomalies, func(i, j int) bool {
+			return severityRank(anomalies[i].Severity) > severityRank(anomalies[j].Severity)
+		})
+		resp.RiskLevel = anomalies[0].Severity
+	}
+	resp.Anomalies = anomalies
+	return resp
+}
+
+// handleOrgScore serves POST /org-score with {"name": "...", "pubkeys": [...]}.
+func handleOrgScore(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req OrgScoreRequest
+	if apiErr := bindJSON(w, r, &req, 1<<20); apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+
+	seen := make(map[string]bool)
+	members := make([]string, 0, len(req.Pubkeys))
+	for _, raw := range req.Pubkeys {
+		pk, err := resolvePubkey(raw)
+		if err != nil || !hex64Pattern.MatchString(pk) {
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid pubkey: %s", raw)})
+			return
+		}
+		if !seen[pk] {
+			seen[pk] = true
+			members = append(members, pk)
+		}
+	}
+	if len(members) < orgScoreMinMembers || len(members) > orgScoreMaxMembers {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("pubkeys must contain %d-%d distinct accounts", orgScoreMinMembers, orgScoreMaxMembers)})
+		return
+	}
+
+	resp := computeOrgScore(req.Name, members)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func postOrgScore(t *testing.T, body interface{}) (*httptest.ResponseRecorder, OrgScoreResponse) {
+	t.Helper()
+	raw, _ := json.Marshal(body)
+	rr := httptest.NewRecorder()
+	handleOrgScore(rr, httptest.NewRequest(http.MethodPost, "/org-score", bytes.NewReader(raw)))
+	var resp OrgScoreResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+func hasOrgFlag(resp OrgScoreResponse, typ string) *AnomalyFlag {
+	for i := range resp.Anomalies {
+		if resp.Anomalies[i].Type == typ {
+			return &resp.Anomalies[i]
+		}
+	}
+	return nil
+}
+
+func TestOrgScoreAggregatesAudience(t *testing.T) {
+	old := graph
+	defer func() { graph = old }()
+	graph = NewGraph()
+
+	a, b := padHex(28001), padHex(28002)
+	// 10 followers of a, 6 of b; 4 follow both
+	for i := 0; i < 10; i++ {
+		graph.AddFollow(padHex(28100+i), a)
+	}
+	for i := 6; i < 12; i++ {
+		graph.AddFollow(padHex(28100+i), b)
+	}
+	graph.AddFollow(a, b)
+	graph.ComputePageRank(20, 0.85)
+
+	rr, resp := postOrgScore(t, map[string]interface{}{"name": "Example Labs", "pubkeys": []string{a, b, a}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if resp.Name != "Example Labs" || resp.MemberCount != 2 || len(resp.Members) != 2 {
+		t.Fatalf("expected 2 deduplicated members, got %+v", resp)
+	}
+	if resp.CombinedAudience != 12 {
+		t.Errorf("expected 12 unique external followers, got %d", resp.CombinedAudience)
+	}
+	// 16 external follow edges, 12 unique
+	if resp.AudienceOverlap != 0.25 {
+		t.Errorf("expected audience overlap 0.25, got %f", resp.AudienceOverlap)
+	}
+	// b has 7 followers, one of them a
+	if resp.InternalFollowShare != 0.059 {
+		t.Errorf("expected internal follow share 1/17, got %f", resp.InternalFollowShare)
+	}
+	if resp.MinScore > resp.MaxScore || resp.MedianScore != float64(resp.MinScore+resp.MaxScore)/2 {
+		t.Errorf("unexpected score spread %d/%f/%d", resp.MinScore, resp.MedianScore, resp.MaxScore)
+	}
+	if hasOrgFlag(resp, "internal_amplification") != nil {
+		t.Error("a single internal follow should not be flagged")
+	}
+}
+
+func TestOrgScoreFlagsFollowRingAndSharedGhosts(t *testing.T) {
+	old := graph
+	defer func() { graph = old }()
+	graph = NewGraph()
+
+	members := []string{padHex(28201), padHex(28202), padHex(28203), padHex(28204)}
+	build := func(outsiders int) {
+		graph = NewGraph()
+		for _, m := range members {
+			for _, other := range members {
+				if m != other {
+					graph.AddFollow(m, other)
+				}
+			}
+		}
+		// otherwise unfollowed accounts that follow every member
+		for i := 0; i < outsiders; i++ {
+			for _, m := range members {
+				graph.AddFollow(padHex(28300+i), m)
+			}
+		}
+		graph.ComputePageRank(20, 0.85)
+	}
+
+	// 12 internal edges out of 20
+	build(2)
+	_, resp := postOrgScore(t, map[string]interface{}{"pubkeys": members})
+	if ring := hasOrgFlag(resp, "internal_amplification"); ring == nil || ring.Severity != "high" || resp.InternalFollowShare != 0.6 {
+		t.Errorf("expected high internal_amplification at 0.6, got %f %+v", resp.InternalFollowShare, resp.Anomalies)
+	}
+	if hasOrgFlag(resp, "shared_ghost_followers") != nil {
+		t.Error("two shared ghosts are below the flag minimum")
+	}
+
+	// 12 internal edges out of 60
+	build(12)
+	_, resp = postOrgScore(t, map[string]interface{}{"pubkeys": members})
+	if hasOrgFlag(resp, "internal_amplification") != nil {
+		t.Errorf("internal share %f should not be flagged", resp.InternalFollowShare)
+	}
+	if resp.SharedGhosts != 12 {
+		t.Errorf("expected 12 shared ghost followers, got %d", resp.SharedGhosts)
+	}
+	ghosts := hasOrgFlag(resp, "shared_ghost_followers")
+	if ghosts == nil || ghosts.Severity != "high" {
+		t.Fatalf("expected high shared_ghost_followers flag, got %+v", resp.Anomalies)
+	}
+	if resp.RiskLevel != "high" || resp.Anomalies[0].Severity != "high" {
+		t.Errorf("expected high risk sorted first, got %s %+v", resp.RiskLevel, resp.Anomalies)
+	}
+}
+
+func TestOrgScoreErrors(t *testing.T) {
+	old := graph
+	defer func() { graph = old }()
+	graph = NewGraph()
+
+	for name, tc := range map[string]struct {
+		body interface{}
+		code int
+	}{
+		"one member": {map[string]interface{}{"pubkeys": []string{padHex(1)}}, http.StatusBadRequest},
+		"duplicates": {map[string]interface{}{"pubkeys": []string{padHex(1), padHex(1)}}, http.StatusBadRequest},
+		"bad pubkey": {map[string]interface{}{"pubkeys": []string{padHex(1), "alice"}}, http.StatusBadRequest},
+		"not a list": {map[string]interface{}{"pubkeys": "abc"}, http.StatusBadRequest},
+	} {
+		if rr, _ := postOrgScore(t, tc.body); rr.Code != tc.code {
+			t.Errorf("%s: expected %d, got %d", name, tc.code, rr.Code)
+		}
+	}
+
+	many := make([]string, orgScoreMaxMembers+1)
+	for i := range many {
+		many[i] = padHex(28400 + i)
+	}
+	if rr, _ := postOrgScore(t, map[string]interface{}{"pubkeys": many}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 above %d members, got %d", orgScoreMaxMembers, rr.Code)
+	}
+
+	rr := httptest.NewRecorder()
+	handleOrgScore(rr, httptest.NewRequest(http.MethodGet, "/org-score", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rr.Code)
+	}
+}
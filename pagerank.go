@@ -0,0 +1,272 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// pageRankParallelMin is the node count below which PageRank runs on one
+// goroutine; smaller graphs finish faster than the workers start.
+const pageRankParallelMin = 20000
+
+// pageRankGraph is the follow graph in compressed sparse row form: node i's
+// followers are in[inStart[i]:inStart[i+1]], as integer ids. Iterating over it
+// touches only preallocated slices, so a PageRank pass allocates nothing.
+type pageRankGraph struct {
+	names    []string
+	inStart  []int
+	in       []int32
+	inWeight []float64 // weight of each in-edge, nil when unweighted
+	out      []float64 // out-degree, or total out-weight when weighted
+
+	// mute edges, indexed like in: node i's muters are mutedBy[muteStart[i]:
+	// muteStart[i+1]], each taking muteWeight of the muter's score from i
+	muteStart  []int
+	mutedBy    []int32
+	muteWeight []float64
+}
+
+// addMutes adds negative edges from each muter to the pubkeys it mutes. Each
+// muter's weight is split evenly across the mutes that land on a node; muters
+// and muted pubkeys outside the graph are ignored.
+func (pg *pageRankGraph) addMutes(mutes map[string][]string, weight func(muter string) float64) {
+	ids := make(map[string]int32, len(pg.names))
+	for i, pk := range pg.names {
+		ids[pk] = int32(i)
+	}
+	byTarget := make([][]int32, len(pg.names))
+	share := make(map[int32]float64)
+	for muter, targets := range mutes {
+		m, ok := ids[muter]
+		if !ok {
+			continue
+		}
+		w := weight(muter)
+		if w <= 0 {
+			continue
+		}
+		n := 0
+		for _, t := range targets {
+			if id, ok := ids[t]; ok && t != muter {
+				byTarget[id] = append(byTarget[id], m)
+				n++
+			}
+		}
+		if n > 0 {
+			share[m] = w / float64(n)
+		}
+	}
+	pg.muteStart = make([]int, len(pg.names)+1)
+	pg.mutedBy, pg.muteWeight = nil, nil
+	for i, muters := range byTarget {
+		pg.muteStart[i] = len(pg.mutedBy)
+		for _, m := range muters {
+			pg.mutedBy = append(pg.mutedBy, m)
+			pg.muteWeight = append(pg.muteWeight, share[m])
+		}
+	}
+	pg.muteStart[len(pg.names)] = len(pg.mutedBy)
+}
+
+// newPageRankGraph indexes every pubkey that follows or is followed. Followers keep
+// the order of followers[node], so sums add up in the same order as a map-based
+// pass would. weights, when not nil, maps from/to pairs to edge weights and
+// outWeight holds each follower's total. Banned pubkeys (see CurationStore) are
+// left out along with their edges, so they get no score and pass none on. With
+// max_follows set, only the first max_follows follows of a longer list count;
+// clients append new follows at the end, so a mass-follow spree is what's left
+// out. The stored lists stay whole.
+func newPageRankGraph(follows, followers map[string][]string, weights map[[2]string]float64, outWeight map[string]float64) *pageRankGraph {
+	banned := curation.BannedSet()
+	maxFollows := config.Get().MaxFollows
+	counted := func(pk string) []string {
+		if list := follows[pk]; maxFollows > 0 && len(list) > maxFollows {
+			return list[:maxFollows]
+		}
+		return follows[pk]
+	}
+	var uncounted map[[2]string]bool
+	if maxFollows > 0 {
+		for pk, list := range follows {
+			if len(list) <= maxFollows {
+				continue
+			}
+			if uncounted == nil {
+				uncounted = make(map[[2]string]bool)
+			}
+			for _, v := range list[maxFollows:] {
+				uncounted[[2]string{pk, v}] = true
+			}
+		}
+	}
+	ids := make(map[string]int32, len(follows))
+	var names []string
+	add := func(pk string) {
+		if _, ok := ids[pk]; !ok && !banned[pk] {
+			ids[pk] = int32(len(names))
+			names = append(names, pk)
+		}
+	}
+	for k := range follows {
+		add(k)
+		for _, v := range counted(k) {
+			add(v)
+		}
+	}
+
+	pg := &pageRankGraph{
+		names:   names,
+		inStart: make([]int, len(names)+1),
+		out:     make([]float64, len(names)),
+	}
+	edges := 0
+	for i, pk := range names {
+		pg.inStart[i] = edges
+		edges += len(followers[pk])
+	}
+	pg.inStart[len(names)] = edges
+	pg.in = make([]int32, 0, edges)
+	if weights != nil {
+		pg.inWeight = make([]float64, 0, edges)
+	}
+	for i, pk := range names {
+		for _, f := range followers[pk] {
+			id, ok := ids[f]
+			if !ok || uncounted[[2]string{f, pk}] {
+				continue // not a node or past the cap; contributes nothing
+			}
+			pg.in = append(pg.in, id)
+			if weights != nil {
+				pg.inWeight = append(pg.inWeight, weights[[2]string{f, pk}])
+			}
+		}
+		pg.inStart[i+1] = len(pg.in)
+		if weights != nil {
+			pg.out[i] = outWeight[pk]
+			for _, v := range follows[pk][len(counted(pk)):] {
+				pg.out[i] -= weights[[2]string{pk, v}]
+			}
+		} else {
+			pg.out[i] = float64(len(counted(pk)))
+		}
+		if banned != nil {
+			// a follow of a banned pubkey no longer takes a share of the rank
+			for _, v := range counted(pk) {
+				if !banned[v] {
+					continue
+				}
+				if weights != nil {
+					pg.out[i] -= weights[[2]string{pk, v}]
+				} else {
+					pg.out[i]--
+				}
+			}
+		}
+	}
+	if factor := massFollows.factor(); factor != nil {
+		pg.dampen(factor)
+	}
+	return pg
+}
+
+// dampen scales each follow's weight by factor(from, to) and leaves the follower's
+// out-weight as it was, so what a dampened follow no longer passes on is lost
+// rather than moved to the follower's other follows.
+func (pg *pageRankGraph) dampen(factor func(from, to string) float64) {
+	for i, pk := range pg.names {
+		for k := pg.inStart[i]; k < pg.inStart[i+1]; k++ {
+			f := factor(pg.names[pg.in[k]], pk)
+			if f >= 1 {
+				continue
+			}
+			if pg.inWeight == nil {
+				pg.inWeight = make([]float64, len(pg.in))
+				for j := range pg.inWeight {
+					pg.inWeight[j] = 1
+				}
+			}
+			pg.inWeight[k] *= f
+		}
+	}
+}
+
+// run iterates PageRank and returns the scores keyed by pubkey. Each iteration
+// splits the nodes across GOMAXPROCS goroutines; they only read the previous
+// iteration's scores and each writes its own range of the next. Mute edges can
+// take a node below zero, so scores are floored there.
+func (pg *pageRankGraph) run(iterations int, damping float64) map[string]float64 {
+	n := len(pg.names)
+	if n == 0 {
+		return nil
+	}
+	base := (1 - damping) / float64(n)
+	cur := make([]float64, n)
+	next := make([]float64, n)
+	share := make([]float64, n) // unweighted: each node's score over its out-degree
+	for i := range cur {
+		cur[i] = 1.0 / float64(n)
+	}
+
+	for it := 0; it < iterations; it++ {
+		if pg.inWeight == nil {
+			parallelRange(n, func(lo, hi int) {
+				for j := lo; j < hi; j++ {
+					if pg.out[j] > 0 {
+						share[j] = cur[j] / pg.out[j]
+					} else {
+						share[j] = 0
+					}
+				}
+			})
+		}
+		parallelRange(n, func(lo, hi int) {
+			for i := lo; i < hi; i++ {
+				sum := 0.0
+				for k := pg.inStart[i]; k < pg.inStart[i+1]; k++ {
+					f := pg.in[k]
+					if pg.inWeight == nil {
+						if pg.out[f] > 0 {
+							sum += share[f]
+						}
+					} else if total := pg.out[f]; total > 0 {
+						sum += cur[f] * pg.inWeight[k] / total
+					}
+				}
+				if pg.muteStart != nil {
+					for k := pg.muteStart[i]; k < pg.muteStart[i+1]; k++ {
+						sum -= cur[pg.mutedBy[k]] * pg.muteWeight[k]
+					}
+				}
+				next[i] = max(base+damping*sum, 0)
+			}
+		})
+		cur, next = next, cur
+	}
+
+	scores := make(map[string]float64, n)
+	for i, pk := range pg.names {
+		scores[pk] = cur[i]
+	}
+	return scores
+}
+
+// parallelRange calls fn over [0, n) split into one contiguous range per CPU, or
+// once for the whole range when n is small, and waits for all of them.
+func parallelRange(n int, fn func(lo, hi int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if n < pageRankParallelMin || workers < 2 {
+		fn(0, n)
+		return
+	}
+	chunk := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for lo := 0; lo < n; lo += chunk {
+		hi := min(lo+chunk, n)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(lo, hi)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// referencePageRank is the straightforward map-based PageRank the CSR version
+// replaced; both must agree exactly.
+func referencePageRank(follows, followers map[string][]string, iterations int, damping float64) map[string]float64 {
+	nodes := make(map[string]bool)
+	for k, vs := range follows {
+		nodes[k] = true
+		for _, v := range vs {
+			nodes[v] = true
+		}
+	}
+	n := float64(len(nodes))
+	scores := make(map[string]float64)
+	for node := range nodes {
+		scores[node] = 1.0 / n
+	}
+	for i := 0; i < iterations; i++ {
+		next := make(map[string]float64)
+		for node := range nodes {
+			sum := 0.0
+			for _, f := range followers[node] {
+				if d := len(follows[f]); d > 0 {
+					sum += scores[f] / float64(d)
+				}
+			}
+			next[node] = (1-damping)/n + damping*sum
+		}
+		scores = next
+	}
+	return scores
+}
+
+func randomFollowGraph(nodes, edges int, seed int64) *Graph {
+	rng := rand.New(rand.NewSource(seed))
+	g := NewGraph()
+	for i := 0; i < edges; i++ {
+		// skewed targets so some accounts are far more followed than others
+		to := int(float64(nodes) * rng.Float64() * rng.Float64())
+		g.AddFollow(padHex(50000+rng.Intn(nodes)), padHex(50000+to))
+	}
+	return g
+}
+
+func TestComputePageRankMatchesReference(t *testing.T) {
+	for _, size := range []int{50, pageRankParallelMin + 500} {
+		g := randomFollowGraph(size, size*4, int64(size))
+		// a repeated follow counts twice, as it always has
+		g.AddFollow(padHex(50001), padHex(50002))
+		g.AddFollow(padHex(50001), padHex(50002))
+
+		g.ComputePageRank(20, 0.85)
+		want := referencePageRank(g.follows, g.followers, 20, 0.85)
+		if len(g.scores) != len(want) {
+			t.Fatalf("size %d: expected %d scores, got %d", size, len(want), len(g.scores))
+		}
+		for pk, s := range want {
+			if g.scores[pk] != s {
+				t.Fatalf("size %d: %s expected %v, got %v", size, pk, s, g.scores[pk])
+			}
+		}
+	}
+}
+
+func TestComputePageRankEmpty(t *testing.T) {
+	g := NewGraph()
+	g.ComputePageRank(20, 0.85)
+	if g.Stats().Nodes != 0 || !g.Stats().LastBuild.IsZero() {
+		t.Error("expected an empty graph left untouched")
+	}
+}
+
+func TestComputeWeightedPageRankUniformMatches(t *testing.T) {
+	g := randomFollowGraph(pageRankParallelMin+100, (pageRankParallelMin+100)*3, 7)
+	g.ComputePageRank(20, 0.85)
+	plain := g.ScoresSnapshot()
+	g.ComputeWeightedPageRank(20, 0.85, func(from, to string) float64 { return 1 })
+	for pk, s := range g.ScoresSnapshot() {
+		if d := s - plain[pk]; d > 1e-15 || d < -1e-15 {
+			t.Fatalf("%s: weighted with equal weights gave %v, plain %v", pk, s, plain[pk])
+		}
+	}
+}
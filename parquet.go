@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// A minimal Parquet writer for exports: flat schemas of INT32, INT64, DOUBLE and
+// UTF-8 string columns, optionally nullable, PLAIN-encoded and uncompressed, one
+// data page per column per row group. Row groups are written as they fill, so
+// memory stays bounded by parquetRowGroupRows however many rows are exported; the
+// footer goes out on Close. See https://parquet.apache.org/docs/file-format/.
+
+const parquetRowGroupRows = 50000
+
+// Parquet physical types.
+const (
+	parquetInt32     int32 = 1
+	parquetInt64     int32 = 2
+	parquetDouble    int32 = 5
+	parquetByteArray int32 = 6
+)
+
+// Thrift enum values used in the metadata.
+const (
+	parquetRequired      = 0
+	parquetOptional      = 1
+	parquetConvertedUTF8 = 0
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+	parquetDataPage      = 0
+)
+
+// ParquetColumn describes one column. String columns are BYTE_ARRAY annotated as UTF-8.
+type ParquetColumn struct {
+	Name     string
+	Type     int32
+	String   bool
+	Optional bool
+}
+
+type parquetColumnBuf struct {
+	values  bytes.Buffer
+	defined []bool // per row, for optional columns
+}
+
+type parquetChunk struct {
+	offset, size int64
+	values       int64
+}
+
+// ParquetWriter streams rows into a Parquet file.
+type ParquetWriter struct {
+	w         io.Writer
+	cols      []ParquetColumn
+	bufs      []parquetColumnBuf
+	rows      int // in the current row group
+	total     int64
+	offset    int64
+	rowGroups [][]parquetChunk
+	groupRows []int64
+	err       error
+}
+
+func NewParquetWriter(w io.Writer, cols []ParquetColumn) *ParquetWriter {
+	pw := &ParquetWriter{w: w, cols: cols, bufs: make([]parquetColumnBuf, len(cols))}
+	pw.write([]byte("PAR1"))
+	return pw
+}
+
+func (pw *ParquetWriter) write(b []byte) {
+	if pw.err != nil {
+		return
+	}
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	pw.err = err
+}
+
+// WriteRow appends one row. values line up with the columns: int, int64, float64
+// or string as the column type needs, or nil for a null in an optional column.
+func (pw *ParquetWriter) WriteRow(values ...interface{}) error {
+	if len(values) != len(pw.cols) {
+		return fmt.Errorf("parquet: %d values for %d columns", len(values), len(pw.cols))
+	}
+	for i, v := range values {
+		if v == nil && !pw.cols[i].Optional {
+			return fmt.Errorf("parquet: null in required column %s", pw.cols[i].Name)
+		}
+	}
+	for i, v := range values {
+		col, buf := pw.cols[i], &pw.bufs[i]
+		if v == nil {
+			buf.defined = append(buf.defined, false)
+			continue
+		}
+		if col.Optional {
+			buf.defined = append(buf.defined, true)
+		}
+		var scratch [8]byte
+		switch col.Type {
+		case parquetInt32:
+			binary.LittleEndian.PutUint32(scratch[:4], uint32(int32(parquetInt(v))))
+			buf.values.Write(scratch[:4])
+		case parquetInt64:
+			binary.LittleEndian.PutUint64(scratch[:], uint64(parquetInt(v)))
+			buf.values.Write(scratch[:])
+		case parquetDouble:
+			binary.LittleEndian.PutUint64(scratch[:], math.Float64bits(v.(float64)))
+			buf.values.Write(scratch[:])
+		case parquetByteArray:
+			s := v.(string)
+			binary.LittleEndian.PutUint32(scratch[:4], uint32(len(s)))
+			buf.values.Write(scratch[:4])
+			buf.values.WriteString(s)
+		}
+	}
+	pw.rows++
+	if pw.rows >= parquetRowGroupRows {
+		pw.flushRowGroup()
+	}
+	return pw.err
+}
+
+func parquetInt(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	}
+	panic(fmt.Sprintf("parquet: %T is not an integer", v))
+}
+
+// flushRowGroup writes the buffered rows as a row group, one page per column.
+func (pw *ParquetWriter) flushRowGroup() {
+	if pw.rows == 0 {
+		return
+	}
+	chunks := make([]parquetChunk, len(pw.cols))
+	for i, col := range pw.cols {
+		buf := &pw.bufs[i]
+		var page bytes.Buffer
+		if col.Optional {
+			levels := parquetRLEBools(buf.defined)
+			var n [4]byte
+			binary.LittleEndian.PutUint32(n[:], uint32(len(levels)))
+			page.Write(n[:])
+			page.Write(levels)
+		}
+		page.Write(buf.values.Bytes())
+
+		var header thriftWriter
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(page.Len()))
+		header.i32(3, int32(page.Len()))
+		header.beginStruct(5)
+		header.i32(1, int32(pw.rows))
+		header.i32(2, parquetEncodingPlain)
+		header.i32(3, parquetEncodingRLE)
+		header.i32(4, parquetEncodingRLE)
+		header.endStruct()
+		header.stop()
+
+		chunks[i] = parquetChunk{offset: pw.offset, size: int64(header.buf.Len() + page.Len()), values: int64(pw.rows)}
+		pw.write(header.buf.Bytes())
+		pw.write(page.Bytes())
+		buf.values.Reset()
+		buf.defined = buf.defined[:0]
+	}
+	pw.rowGroups = append(pw.rowGroups, chunks)
+	pw.groupRows = append(pw.groupRows, int64(pw.rows))
+	pw.total += int64(pw.rows)
+	pw.rows = 0
+}
+
+// parquetRLEBools encodes definition levels (bit width 1) as RLE runs.
+func parquetRLEBools(levels []bool) []byte {
+	var out []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if levels[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+// Close writes any buffered rows and the file footer. It does not close the
+// underlying writer.
+func (pw *ParquetWriter) Close() error {
+	pw.flushRowGroup()
+
+	var meta thriftWriter
+	meta.i32(1, 1) // version
+	meta.beginList(2, thriftStruct, len(pw.cols)+1)
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(pw.cols)))
+	meta.stop()
+	for _, col := range pw.cols {
+		meta.i32(1, col.Type)
+		rep := int32(parquetRequired)
+		if col.Optional {
+			rep = parquetOptional
+		}
+		meta.i32(3, rep)
+		meta.binary(4, col.Name)
+		if col.String {
+			meta.i32(6, parquetConvertedUTF8)
+		}
+		meta.stop()
+	}
+	meta.endList()
+	meta.i64(3, pw.total)
+	meta.beginList(4, thriftStruct, len(pw.rowGroups))
+	for g, chunks := range pw.rowGroups {
+		var size int64
+		meta.beginList(1, thriftStruct, len(chunks))
+		for i, c := range chunks {
+			col := pw.cols[i]
+			size += c.size
+			meta.i64(2, c.offset)
+			meta.beginStruct(3)
+			meta.i32(1, col.Type)
+			meta.beginList(2, thriftI32, 2)
+			meta.listI32(parquetEncodingPlain)
+			meta.listI32(parquetEncodingRLE)
+			meta.endList()
+			meta.beginList(3, thriftBinary, 1)
+			meta.listBinary(col.Name)
+			meta.endList()
+			meta.i32(4, 0) // uncompressed
+			meta.i64(5, c.values)
+			meta.i64(6, c.size)
+			meta.i64(7, c.size)
+			meta.i64(9, c.offset)
+			meta.endStruct()
+			meta.stop()
+		}
+		meta.endList()
+		meta.i64(2, size)
+		meta.i64(3, pw.groupRows[g])
+		meta.stop()
+	}
+	meta.endList()
+	meta.binary(6, "wot-scoring")
+	meta.stop()
+
+	pw.write(meta.buf.Bytes())
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(meta.buf.Len()))
+	pw.write(n[:])
+	pw.write([]byte("PAR1"))
+	return pw.err
+}
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the Thrift compact protocol structs Parquet metadata uses.
+// Field ids within a struct must be written in increasing order.
+type thriftWriter struct {
+	buf    bytes.Buffer
+	last   int16
+	parent []int16
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) varint(v int64) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64((v<<1)^(v>>63))))
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.listBinary(s)
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.parent = append(t.parent, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) endStruct() {
+	t.buf.WriteByte(0)
+	t.last = t.parent[len(t.parent)-1]
+	t.parent = t.parent[:len(t.parent)-1]
+}
+
+// beginList starts a list field. Struct elements are written as their fields
+// followed by stop.
+func (t *thriftWriter) beginList(id int16, elem byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xf0 | elem)
+		t.buf.Write(binary.AppendUvarint(nil, uint64(size)))
+	}
+	t.parent = append(t.parent, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) endList() {
+	t.last = t.parent[len(t.parent)-1]
+	t.parent = t.parent[:len(t.parent)-1]
+}
+
+// stop ends a struct that is a list element (or the top-level struct).
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+	t.last = 0
+}
+
+func (t *thriftWriter) listI32(v int32) {
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) listBinary(s string) {
+	t.buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	t.buf.WriteString(s)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// thriftReader decodes the Thrift compact protocol into map[int16]interface{} for
+// structs, []interface{} for lists, int64 for integers and []byte for binaries.
+type thriftReader struct {
+	b []byte
+	p int
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.p:])
+	r.p += n
+	return v
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		v := r.uvarint()
+		return int64(v>>1) ^ -int64(v&1)
+	case thriftBinary:
+		n := int(r.uvarint())
+		r.p += n
+		return r.b[r.p-n : r.p]
+	case thriftList:
+		h := r.b[r.p]
+		r.p++
+		size := int(h >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		out := make([]interface{}, size)
+		for i := range out {
+			out[i] = r.value(h & 0x0f)
+		}
+		return out
+	case thriftStruct:
+		return r.structure()
+	}
+	panic("unexpected thrift type")
+}
+
+func (r *thriftReader) structure() map[int16]interface{} {
+	out := make(map[int16]interface{})
+	var last int16
+	for {
+		h := r.b[r.p]
+		r.p++
+		if h == 0 {
+			return out
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.value(thriftI32).(int64))
+		}
+		out[id] = r.value(h & 0x0f)
+		last = id
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	var buf bytes.Buffer
+	pw := NewParquetWriter(&buf, []ParquetColumn{
+		{Name: "name", Type: parquetByteArray, String: true},
+		{Name: "score", Type: parquetDouble},
+		{Name: "group", Type: parquetInt32, Optional: true},
+	})
+	rows := parquetRowGroupRows + 3
+	for i := 0; i < rows; i++ {
+		var group interface{}
+		if i%2 == 0 {
+			group = i % 5
+		}
+		if err := pw.WriteRow(padHex(i), float64(i)/2, group); err != nil {
+			t.Fatalf("row %d: %v", i, err)
+		}
+	}
+	if err := pw.WriteRow("x", 1.0, nil, 4); err == nil {
+		t.Error("expected a column count mismatch rejected")
+	}
+	if err := pw.WriteRow(nil, 1.0, nil); err == nil {
+		t.Error("expected a null in a required column rejected")
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("expected PAR1 magic at both ends")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	r := &thriftReader{b: data, p: len(data) - 8 - footerLen}
+	meta := r.structure()
+	if meta[3].(int64) != int64(rows) {
+		t.Errorf("expected %d rows, got %v", rows, meta[3])
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != 4 || string(schema[3].(map[int16]interface{})[4].([]byte)) != "group" || schema[3].(map[int16]interface{})[3].(int64) != parquetOptional {
+		t.Fatalf("unexpected schema %v", schema)
+	}
+	groups := meta[4].([]interface{})
+	if len(groups) != 2 || groups[1].(map[int16]interface{})[3].(int64) != 3 {
+		t.Fatalf("expected a full row group and one of 3 rows, got %v", groups)
+	}
+
+	// read the score and group columns of the second row group back
+	chunks := groups[1].(map[int16]interface{})[1].([]interface{})
+	page := func(col int) (map[int16]interface{}, []byte) {
+		md := chunks[col].(map[int16]interface{})[3].(map[int16]interface{})
+		pr := &thriftReader{b: data, p: int(md[9].(int64))}
+		header := pr.structure()
+		return header, data[pr.p : pr.p+int(header[3].(int64))]
+	}
+	header, body := page(1)
+	if header[5].(map[int16]interface{})[1].(int64) != 3 {
+		t.Errorf("expected 3 values in the page, got %v", header)
+	}
+	if got := math.Float64frombits(binary.LittleEndian.Uint64(body[16:])); got != float64(rows-1)/2 {
+		t.Errorf("expected the last score %v, got %v", float64(rows-1)/2, got)
+	}
+	// rows 50000..50002 alternate defined/null/defined: three RLE runs of one
+	_, body = page(2)
+	levels := body[4 : 4+binary.LittleEndian.Uint32(body)]
+	if !bytes.Equal(levels, []byte{2, 1, 2, 0, 2, 1}) || len(body) != 4+len(levels)+8 {
+		t.Errorf("unexpected definition levels %v or values in %v", levels, body)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// PathNode is one account on a trust path. Spam and Sybil classifications are only
+// computed for intermediate accounts: the endpoints are the caller's choice, the
+// intermediates are what the path's trust actually flows through.
+type PathNode struct {
+	Pubkey    string `json:"pubkey"`
+	WotScore  int    `json:"wot_score"`
+	Community int    `json:"community"`       // -1 when not in a detected community
+	Spam      string `json:"spam,omitempty"`  // likely_human, suspicious, likely_spam
+	Sybil     string `json:"sybil,omitempty"` // genuine, likely_genuine, suspicious, likely_sybil
+	Flagged   bool   `json:"flagged,omitempty"`
+}
+
+// PathHop qualifies one follow edge on a trust path.
+type PathHop struct {
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Relation      string `json:"relation"` // "mutual" or "one_way"
+	FollowedAt    string `json:"followed_at,omitempty"`
+	FollowAgeDays *int   `json:"follow_age_days,omitempty"`
+	SameCommunity bool   `json:"same_community"`
+	Explanation   string `json:"explanation"`
+}
+
+// shortKey abbreviates a pubkey for explanations.
+func shortKey(pk string) string {
+	if len(pk) > 8 {
+		return pk[:8]
+	}
+	return pk
+}
+
+// explainPath annotates a follow path with per-node scores, communities, and
+// spam/Sybil flags, and per-hop relationship qualifiers. It returns the nodes, the
+// hops, and the number of flagged intermediate accounts.
+func explainPath(path []string, now time.Time) ([]PathNode, []PathHop, int) {
+	stats := graph.Stats()
+	nodes := make([]PathNode, len(path))
+	flagged := 0
+	for i, pk := range path {
+		raw, _ := graph.GetScore(pk)
+		node := PathNode{Pubkey: pk, WotScore: normalizeScore(raw, stats.Nodes), Community: -1}
+		if id, ok := communities.GetCommunity(pk); ok {
+			node.Community = id
+		}
+		if i > 0 && i < len(path)-1 {
+			node.Spam = computeSpam(pk, stats.Nodes).Classification
+			node.Sybil = computeSybil(pk).Classification
+			node.Flagged = node.Spam == "likely_spam" || node.Sybil == "likely_sybil"
+			if node.Flagged {
+				flagged++
+			}
+		}
+		nodes[i] = node
+	}
+
+	hops := make([]PathHop, 0, len(path)-1)
+	for i := 0; i+1 < len(path); i++ {
+		from, to := nodes[i], nodes[i+1]
+		hop := PathHop{From: from.Pubkey, To: to.Pubkey, Relation: "one_way"}
+		for _, f := range graph.GetFollows(to.Pubkey) {
+			if f == from.Pubkey {
+				hop.Relation = "mutual"
+				break
+			}
+		}
+		hop.SameCommunity = from.Community >= 0 && from.Community == to.Community
+
+		parts := []string{fmt.Sprintf("%s follows %s", shortKey(from.Pubkey), shortKey(to.Pubkey))}
+		if hop.Relation == "mutual" {
+			parts = append(parts, "mutual")
+		} else {
+			parts = append(parts, "not followed back")
+		}
+		if at := graph.GetFollowTime(from.Pubkey, to.Pubkey); !at.IsZero() {
+			days := int(math.Max(0, now.Sub(at).Hours()/24))
+			hop.FollowedAt = at.UTC().Format(time.RFC3339)
+			hop.FollowAgeDays = &days
+			parts = append(parts, fmt.Sprintf("followed %d days ago", days))
+		}
+		if hop.SameCommunity {
+			parts = append(parts, fmt.Sprintf("both in community %d", from.Community))
+		} else if to.Community >= 0 {
+			parts = append(parts, fmt.Sprintf("into community %d", to.Community))
+		}
+		if to.Flagged {
+			parts = append(parts, fmt.Sprintf("%s is flagged (spam: %s, sybil: %s)", shortKey(to.Pubkey), to.Spam, to.Sybil))
+		}
+		hop.Explanation = parts[0] + " (" + strings.Join(parts[1:], ", ") + ")"
+		hops = append(hops, hop)
+	}
+	return nodes, hops, flagged
+}
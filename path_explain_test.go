@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplainPathQualifiesHops(t *testing.T) {
+	oldGraph, oldCommunities := graph, communities
+	defer func() { graph, communities = oldGraph, oldCommunities }()
+	graph = NewGraph()
+	communities = NewCommunityDetector()
+
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	alice, bob, carol := padHex(20001), padHex(20002), padHex(20003)
+	graph.AddFollowWithTime(alice, bob, now.AddDate(0, 0, -400))
+	graph.AddFollow(bob, alice)
+	graph.AddFollow(bob, carol)
+	graph.ComputePageRank(20, 0.85)
+	communities.labels[alice] = 7
+	communities.labels[bob] = 7
+	communities.labels[carol] = 9
+
+	nodes, hops, _ := explainPath([]string{alice, bob, carol}, now)
+	if len(nodes) != 3 || len(hops) != 2 {
+		t.Fatalf("expected 3 nodes and 2 hops, got %d/%d", len(nodes), len(hops))
+	}
+	if nodes[0].Spam != "" || nodes[2].Sybil != "" {
+		t.Error("endpoints must not carry spam/sybil classifications")
+	}
+	if nodes[1].Spam == "" || nodes[1].Sybil == "" {
+		t.Error("intermediate must carry spam and sybil classifications")
+	}
+	if nodes[1].Community != 7 || nodes[2].Community != 9 {
+		t.Errorf("unexpected communities %d/%d", nodes[1].Community, nodes[2].Community)
+	}
+
+	first := hops[0]
+	if first.Relation != "mutual" || !first.SameCommunity {
+		t.Errorf("expected mutual same-community first hop, got %+v", first)
+	}
+	if first.FollowAgeDays == nil || *first.FollowAgeDays != 400 || first.FollowedAt == "" {
+		t.Errorf("expected 400-day-old follow, got %+v", first)
+	}
+	if !strings.Contains(first.Explanation, "mutual") || !strings.Contains(first.Explanation, "400 days ago") {
+		t.Errorf("unexpected explanation %q", first.Explanation)
+	}
+
+	second := hops[1]
+	if second.Relation != "one_way" || second.SameCommunity || second.FollowAgeDays != nil {
+		t.Errorf("expected undated one-way cross-community hop, got %+v", second)
+	}
+	if !strings.Contains(second.Explanation, "into community 9") {
+		t.Errorf("unexpected explanation %q", second.Explanation)
+	}
+}
+
+func TestExplainPathFlagsSybilIntermediate(t *testing.T) {
+	oldGraph, oldCommunities := graph, communities
+	defer func() { graph, communities = oldGraph, oldCommunities }()
+	graph = NewGraph()
+	communities = NewCommunityDetector()
+
+	// The intermediate has no score, no followers but the source, and follows one account
+	src, mid, dst := padHex(20101), padHex(20102), padHex(20103)
+	graph.AddFollow(src, mid)
+	graph.AddFollow(mid, dst)
+
+	nodes, hops, flagged := explainPath([]string{src, mid, dst}, time.Now())
+	if nodes[1].Sybil != "likely_sybil" || !nodes[1].Flagged || flagged != 1 {
+		t.Fatalf("expected flagged sybil intermediate, got %+v (flagged=%d)", nodes[1], flagged)
+	}
+	if !strings.Contains(hops[0].Explanation, "is flagged") {
+		t.Errorf("expected flag in explanation, got %q", hops[0].Explanation)
+	}
+	if nodes[0].Community != -1 {
+		t.Errorf("expected -1 for unclustered node, got %d", nodes[0].Community)
+	}
+}
+
+func TestHandleGraphPathHopDetails(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+	graph.AddFollow("alice", "bob")
+	graph.AddFollow("bob", "carol")
+	graph.ComputePageRank(20, 0.85)
+
+	w := httptest.NewRecorder()
+	handleGraph(w, httptest.NewRequest(http.MethodGet, "/graph?from=alice&to=carol", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var resp struct {
+		Path       []PathNode `json:"path"`
+		HopDetails []PathHop  `json:"hop_details"`
+		Flagged    *int       `json:"flagged_intermediates"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Path) != 3 || len(resp.HopDetails) != 2 || resp.Flagged == nil {
+		t.Fatalf("unexpected response %s", w.Body.String())
+	}
+	if resp.HopDetails[0].From != "alice" || resp.HopDetails[1].To != "carol" {
+		t.Errorf("hops out of order: %+v", resp.HopDetails)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// pathDefaultMaxHops is the BFS depth used when max_hops is not given.
+	pathDefaultMaxHops = 6
+	// pathMaxHopsCap bounds max_hops; deeper searches reach most of the graph anyway.
+	pathMaxHopsCap = 8
+	// pathDefaultMinScore is the score threshold for avoid=low_score.
+	pathDefaultMinScore = 20
+)
+
+// PathOptions controls path-mode traversal on /graph.
+type PathOptions struct {
+	MaxHops   int      `json:"max_hops"`
+	Avoid     []string `json:"avoid,omitempty"` // "spam", "low_score"
+	MinScore  int      `json:"min_score,omitempty"`
+	K         int      `json:"k,omitempty"`        // paths to return; 0 means 1
+	Weighted  bool     `json:"weighted,omitempty"` // trust-weighted edge costs
+	Disjoint  bool     `json:"disjoint,omitempty"` // paths share no intermediate
+	avoidSpam bool
+	avoidLow  bool
+}
+
+// parsePathOptions reads ?max_hops=, ?avoid=spam,low_score, ?min_score= (the
+// low_score threshold, default 20), ?k= (1-5), ?weighted= and ?disjoint=. Unknown
+// avoid values are rejected so typos don't silently return unfiltered paths.
+func parsePathOptions(q url.Values) (PathOptions, error) {
+	opts := PathOptions{MaxHops: pathDefaultMaxHops}
+	if v := q.Get("k"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > kPathsMax {
+			return opts, fmt.Errorf("k must be 1-%d", kPathsMax)
+		}
+		opts.K = n
+	}
+	for name, dst := range map[string]*bool{"weighted": &opts.Weighted, "disjoint": &opts.Disjoint} {
+		if v := q.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return opts, fmt.Errorf("%s must be true or false", name)
+			}
+			*dst = b
+		}
+	}
+	if v := q.Get("max_hops"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > pathMaxHopsCap {
+			return opts, fmt.Errorf("max_hops must be 1-%d", pathMaxHopsCap)
+		}
+		opts.MaxHops = n
+	}
+	if v := q.Get("avoid"); v != "" {
+		for _, a := range strings.Split(v, ",") {
+			switch a = strings.TrimSpace(a); a {
+			case "spam":
+				opts.avoidSpam = true
+			case "low_score":
+				opts.avoidLow = true
+			case "":
+				continue
+			default:
+				return opts, fmt.Errorf("unknown avoid value %q (use spam, low_score)", a)
+			}
+			opts.Avoid = append(opts.Avoid, a)
+		}
+	}
+	if opts.avoidLow {
+		opts.MinScore = pathDefaultMinScore
+	}
+	if v := q.Get("min_score"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 100 {
+			return opts, fmt.Errorf("min_score must be 0-100")
+		}
+		if !opts.avoidLow {
+			return opts, fmt.Errorf("min_score requires avoid=low_score")
+		}
+		opts.MinScore = n
+	}
+	return opts, nil
+}
+
+// pathFilter returns the adjacency filter for intermediate nodes, or nil when no
+// avoid option is set. Decisions are memoized per request since BFS can reach the
+// same node from many frontiers, and it counts how many distinct nodes were excluded.
+func (o PathOptions) pathFilter(graphSize int, excluded *int) func(string) bool {
+	if !o.avoidSpam && !o.avoidLow {
+		return nil
+	}
+	memo := make(map[string]bool)
+	return func(pk string) bool {
+		if ok, seen := memo[pk]; seen {
+			return ok
+		}
+		ok := true
+		if o.avoidLow {
+			raw, _ := graph.GetScore(pk)
+			ok = normalizeScore(raw, graphSize) > o.MinScore
+		}
+		if ok && o.avoidSpam {
+			ok = computeSpamWithPercentile(pk, graphSize, 0).Classification != "likely_spam"
+		}
+		memo[pk] = ok
+		if !ok {
+			*excluded++
+		}
+		return ok
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParsePathOptions(t *testing.T) {
+	opts, err := parsePathOptions(url.Values{})
+	if err != nil || opts.MaxHops != pathDefaultMaxHops || opts.pathFilter(10, new(int)) != nil {
+		t.Fatalf("unexpected defaults %+v %v", opts, err)
+	}
+
+	opts, err = parsePathOptions(url.Values{"max_hops": {"3"}, "avoid": {"spam, low_score"}})
+	if err != nil || opts.MaxHops != 3 || len(opts.Avoid) != 2 || opts.MinScore != pathDefaultMinScore {
+		t.Fatalf("unexpected options %+v %v", opts, err)
+	}
+	opts, err = parsePathOptions(url.Values{"avoid": {"low_score"}, "min_score": {"45"}})
+	if err != nil || opts.MinScore != 45 {
+		t.Fatalf("expected min_score 45, got %+v %v", opts, err)
+	}
+
+	for _, q := range []url.Values{
+		{"max_hops": {"0"}},
+		{"max_hops": {"9"}},
+		{"max_hops": {"x"}},
+		{"avoid": {"bots"}},
+		{"min_score": {"30"}},
+		{"avoid": {"low_score"}, "min_score": {"101"}},
+	} {
+		if _, err := parsePathOptions(q); err == nil {
+			t.Errorf("expected error for %v", q)
+		}
+	}
+}
+
+// buildDetourGraph links src to dst through a low-score hop (weak) and, one hop
+// longer, through two well-followed accounts (hubA -> hubB).
+func buildDetourGraph() (src, dst, weak string) {
+	src, dst, weak = padHex(21001), padHex(21002), padHex(21003)
+	hubA, hubB := padHex(21004), padHex(21005)
+	graph.AddFollow(src, weak)
+	graph.AddFollow(weak, dst)
+	graph.AddFollow(src, hubA)
+	graph.AddFollow(hubA, hubB)
+	graph.AddFollow(hubB, dst)
+	for i := 0; i < 30; i++ {
+		f := padHex(21100 + i)
+		graph.AddFollow(f, hubA)
+		graph.AddFollow(f, hubB)
+	}
+	graph.ComputePageRank(20, 0.85)
+	graph.scores[weak] = 0
+	return src, dst, weak
+}
+
+func TestBfsPathFilteredAvoidsLowScore(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+	src, dst, weak := buildDetourGraph()
+
+	path, found := bfsPath(src, dst, 6)
+	if !found || len(path) != 3 || path[1] != weak {
+		t.Fatalf("expected unfiltered 2-hop path via weak node, got %v", path)
+	}
+
+	opts, _ := parsePathOptions(url.Values{"avoid": {"low_score"}, "min_score": {"1"}})
+	excluded := 0
+	path, found = bfsPathFiltered(src, dst, opts.MaxHops, opts.pathFilter(graph.Stats().Nodes, &excluded))
+	if !found || len(path) != 4 {
+		t.Fatalf("expected 3-hop detour through hubs, got %v", path)
+	}
+	for _, pk := range path {
+		if pk == weak {
+			t.Error("filtered path must not pass through the low-score node")
+		}
+	}
+	if excluded != 1 {
+		t.Errorf("expected 1 excluded node, got %d", excluded)
+	}
+
+	// The detour needs 3 hops; capping at 2 leaves no path
+	if _, found := bfsPathFiltered(src, dst, 2, opts.pathFilter(graph.Stats().Nodes, new(int))); found {
+		t.Error("expected no path within 2 hops once the weak node is avoided")
+	}
+}
+
+func TestBfsPathFilteredKeepsEndpoints(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+	graph.AddFollow("a", "b")
+
+	// Neither endpoint has a score, but endpoints are never filtered
+	if _, found := bfsPathFiltered("a", "b", 6, func(string) bool { return false }); !found {
+		t.Error("direct follow must be found even when every intermediate is rejected")
+	}
+}
+
+func TestHandleGraphPathOptions(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+	src, dst, _ := buildDetourGraph()
+
+	w := httptest.NewRecorder()
+	handleGraph(w, httptest.NewRequest("GET", "/graph?from="+src+"&to="+dst+"&avoid=low_score&min_score=1&max_hops=4", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Found         bool        `json:"found"`
+		Hops          int         `json:"hops"`
+		Options       PathOptions `json:"options"`
+		ExcludedNodes int         `json:"excluded_nodes"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if !resp.Found || resp.Hops != 3 || resp.ExcludedNodes != 1 || resp.Options.MaxHops != 4 || resp.Options.MinScore != 1 {
+		t.Errorf("unexpected response %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handleGraph(w, httptest.NewRequest("GET", "/graph?from="+src+"&to="+dst+"&avoid=bots", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown avoid value, got %d", w.Code)
+	}
+}
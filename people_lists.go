@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// peopleListKind is the NIP-51 follow set ("people list") kind.
+	peopleListKind = 30000
+	// maxPeopleLists caps publish_lists thresholds.
+	maxPeopleLists = 10
+	// peopleListMaxMembers keeps a list near 75 KB, under common relay message limits.
+	peopleListMaxMembers = 1000
+)
+
+// peopleListD is the d tag of the list for threshold, e.g. "wot-above-20".
+func peopleListD(threshold int) string {
+	return fmt.Sprintf("wot-above-%d", threshold)
+}
+
+// peopleListMembers returns the pubkeys whose normalized score is at least
+// threshold, highest first with ties by pubkey, up to max, and how many qualify
+// in all.
+func peopleListMembers(g *Graph, threshold, max int) (members []string, total int) {
+	entries := g.TopN(0)
+	type member struct {
+		pubkey string
+		score  int
+	}
+	var qualified []member
+	for _, e := range entries {
+		score := g.NormalizeScore(e.Score, "")
+		if score < threshold {
+			break // entries are sorted and every curve is monotonic
+		}
+		qualified = append(qualified, member{e.Pubkey, score})
+	}
+	sort.Slice(qualified, func(i, j int) bool {
+		if qualified[i].score != qualified[j].score {
+			return qualified[i].score > qualified[j].score
+		}
+		return qualified[i].pubkey < qualified[j].pubkey
+	})
+	for _, m := range qualified {
+		if len(members) == max {
+			break
+		}
+		members = append(members, m.pubkey)
+	}
+	return members, len(qualified)
+}
+
+// buildPeopleList makes the unsigned kind 30000 list for threshold: a title and
+// description, then one p tag per member.
+func buildPeopleList(pub string, threshold int, members []string, total int) nostr.Event {
+	description := fmt.Sprintf("Pubkeys with a WoT score of %d or more out of 100, refreshed each rebuild.", threshold)
+	if total > len(members) {
+		description = fmt.Sprintf("The top %d of the %d pubkeys with a WoT score of %d or more out of 100, refreshed each rebuild.",
+			len(members), total, threshold)
+	}
+	tags := make(nostr.Tags, 0, len(members)+3)
+	tags = append(tags,
+		nostr.Tag{"d", peopleListD(threshold)},
+		nostr.Tag{"title", fmt.Sprintf("WoT score %d+", threshold)},
+		nostr.Tag{"description", description},
+	)
+	for _, pk := range members {
+		tags = append(tags, nostr.Tag{"p", pk})
+	}
+	return nostr.Event{
+		PubKey:    pub,
+		CreatedAt: nostr.Now(),
+		Kind:      peopleListKind,
+		Tags:      tags,
+	}
+}
+
+// publishPeopleLists signs a kind 30000 people list for each publish_lists
+// threshold and queues them for the relays, so clients that only understand
+// lists can follow or filter by the WoT. It returns how many were queued.
+func publishPeopleLists(ctx context.Context, signer EventSigner, thresholds []int) (int, error) {
+	if len(thresholds) == 0 {
+		return 0, nil
+	}
+	batch := make([]nostr.Event, 0, len(thresholds))
+	for _, t := range thresholds {
+		if ctx.Err() != nil {
+			break
+		}
+		members, total := peopleListMembers(graph, t, peopleListMaxMembers)
+		ev := buildPeopleList(signer.PublicKey(), t, members, total)
+		if err := signer.Sign(ctx, &ev); err != nil {
+			log.Printf("Failed to sign kind 30000 %s: %v", peopleListD(t), err)
+			continue
+		}
+		batch = append(batch, ev)
+	}
+	queued := publishQueue.Enqueue(batch, config.Relays())
+	log.Printf("Queued %d NIP-51 kind 30000 people lists", queued)
+	return queued, ctx.Err()
+}
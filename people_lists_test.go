@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// buildPeopleListGraph gives padHex(36001+i) 10-i followers, so scores fall in
+// that order, plus the followers themselves at the bottom.
+func buildPeopleListGraph(t *testing.T) {
+	t.Helper()
+	oldGraph := graph
+	t.Cleanup(func() { graph = oldGraph })
+	graph = NewGraph()
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10-i; j++ {
+			graph.AddFollow(padHex(36100+j), padHex(36001+i))
+		}
+	}
+	graph.ComputePageRank(20, 0.85)
+}
+
+func TestPeopleListMembers(t *testing.T) {
+	buildPeopleListGraph(t)
+
+	all, total := peopleListMembers(graph, 1, 1000)
+	if total != len(all) || all[0] != padHex(36001) {
+		t.Fatalf("expected the most-followed pubkey first, got %v of %d", all, total)
+	}
+	for _, pk := range all {
+		raw, _ := graph.GetScore(pk)
+		if graph.NormalizeScore(raw, "") < 1 {
+			t.Errorf("%s is below the threshold", pk)
+		}
+	}
+
+	top, total := peopleListMembers(graph, 1, 3)
+	if len(top) != 3 || total != len(all) || top[0] != all[0] || top[2] != all[2] {
+		t.Errorf("expected the top 3 of %d, got %v", total, top)
+	}
+	raw, _ := graph.GetScore(all[len(all)-1])
+	if higher, _ := peopleListMembers(graph, graph.NormalizeScore(raw, "")+1, 1000); len(higher) >= len(all) {
+		t.Errorf("expected a higher threshold to drop the lowest-scored members, got %d of %d", len(higher), len(all))
+	}
+}
+
+func TestBuildPeopleList(t *testing.T) {
+	ev := buildPeopleList("pub", 20, []string{padHex(36001), padHex(36002)}, 2)
+	if ev.Kind != peopleListKind || ev.Tags.GetD() != "wot-above-20" {
+		t.Fatalf("unexpected list %+v", ev)
+	}
+	if title := ev.Tags.GetFirst([]string{"title"}); title == nil || (*title)[1] != "WoT score 20+" {
+		t.Errorf("unexpected title %v", title)
+	}
+	if p := ev.Tags.GetAll([]string{"p"}); len(p) != 2 || p[0][1] != padHex(36001) {
+		t.Errorf("expected members as p tags in order, got %v", p)
+	}
+
+	capped := buildPeopleList("pub", 20, []string{padHex(36001)}, 5)
+	if desc := capped.Tags.GetFirst([]string{"description"}); desc == nil || !strings.Contains((*desc)[1], "top 1 of the 5") {
+		t.Errorf("expected the description to say the list is capped, got %v", desc)
+	}
+}
+
+func TestPublishPeopleLists(t *testing.T) {
+	buildPeopleListGraph(t)
+	old := publishQueue
+	t.Cleanup(func() { publishQueue = old })
+	publishQueue = NewPublishQueue("")
+
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	signer := keySigner{sk: sk, pub: pub}
+
+	if n, err := publishPeopleLists(context.Background(), signer, nil); n != 0 || err != nil {
+		t.Errorf("expected nothing queued without thresholds, got %d %v", n, err)
+	}
+	n, err := publishPeopleLists(context.Background(), signer, []int{1, 50})
+	if err != nil || n != 2 {
+		t.Fatalf("expected two lists queued, got %d %v", n, err)
+	}
+	item, ok := publishQueue.items["30000:wot-above-1"]
+	if !ok {
+		t.Fatalf("expected wot-above-1 in the queue, got %v", publishQueue.items)
+	}
+	if ok, err := item.Event.CheckSignature(); !ok || err != nil || item.Event.PubKey != pub {
+		t.Errorf("expected a list signed by the service key, got %v %v", ok, err)
+	}
+	if len(item.Event.Tags.GetAll([]string{"p"})) == 0 {
+		t.Error("expected members on the lowest threshold")
+	}
+
+	// a rebuild replaces the queued version rather than adding another
+	publishPeopleLists(context.Background(), signer, []int{1})
+	if depth := publishQueue.Status().QueueDepth; depth != 2 {
+		t.Errorf("expected the list replaced in the queue, got depth %d", depth)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// percentileTiers are the coarse tiers clients get instead of exact ranks: the
+// top 1%, 5%, 10%, 25% and 50% of scored pubkeys.
+var percentileTiers = []int{1, 5, 10, 25, 50}
+
+// PercentileBucket is one tier's boundary, fixed at the last rebuild.
+type PercentileBucket struct {
+	Top         int     `json:"top_percent"`
+	MinRawScore float64 `json:"min_raw_score"` // lowest raw score in the tier
+	MinScore    int     `json:"min_score"`     // the same, normalized
+	Pubkeys     int     `json:"pubkeys"`       // pubkeys in the tier, ties included
+}
+
+// computePercentileBuckets finds each tier's boundary: the score of the pubkey at
+// the tier's cutoff rank. Pubkeys tied with it are in the tier too.
+func computePercentileBuckets(scores map[string]float64) []PercentileBucket {
+	n := len(scores)
+	if n == 0 {
+		return nil
+	}
+	sorted := make([]float64, 0, n)
+	for _, s := range scores {
+		sorted = append(sorted, s)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+
+	buckets := make([]PercentileBucket, len(percentileTiers))
+	for i, top := range percentileTiers {
+		cutoff := max(int(math.Ceil(float64(n)*float64(top)/100)), 1)
+		floor := sorted[cutoff-1]
+		// ties with the boundary score are in, even past the cutoff
+		in := sort.Search(n, func(j int) bool { return sorted[j] < floor })
+		buckets[i] = PercentileBucket{Top: top, MinRawScore: floor, MinScore: normalizeScore(floor, n), Pubkeys: in}
+	}
+	return buckets
+}
+
+// RefreshPercentileBuckets recomputes the tier boundaries from the current
+// scores. The rebuild calls it once scoring is done, so the tiers stay put
+// between rebuilds while hints nudge individual scores.
+func (g *Graph) RefreshPercentileBuckets() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.changed()
+	g.buckets = computePercentileBuckets(g.scores)
+}
+
+// PercentileBuckets returns the tier boundaries from the last rebuild.
+func (g *Graph) PercentileBuckets() []PercentileBucket {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.buckets
+}
+
+// PercentileBucket returns the smallest tier pubkey is in: 1, 5, 10, 25 or 50 for
+// the top N%, 100 for the rest, and 0 if it has no score or no tiers have been
+// computed yet.
+func (g *Graph) PercentileBucket(pubkey string) int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	score, ok := g.scores[pubkey]
+	if !ok || len(g.buckets) == 0 {
+		return 0
+	}
+	for _, b := range g.buckets {
+		if score >= b.MinRawScore {
+			return b.Top
+		}
+	}
+	return 100
+}
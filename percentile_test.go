@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestComputePercentileBuckets(t *testing.T) {
+	scores := make(map[string]float64)
+	for i := 0; i < 200; i++ {
+		scores[padHex(51000+i)] = float64(200 - i) // padHex(51000) scores highest
+	}
+	buckets := computePercentileBuckets(scores)
+	want := map[int]int{1: 2, 5: 10, 10: 20, 25: 50, 50: 100}
+	if len(buckets) != len(percentileTiers) {
+		t.Fatalf("expected %d tiers, got %d", len(percentileTiers), len(buckets))
+	}
+	for _, b := range buckets {
+		if b.Pubkeys != want[b.Top] {
+			t.Errorf("top %d%%: expected %d pubkeys, got %d", b.Top, want[b.Top], b.Pubkeys)
+		}
+		if b.MinRawScore != float64(200-want[b.Top]+1) {
+			t.Errorf("top %d%%: unexpected boundary %v", b.Top, b.MinRawScore)
+		}
+	}
+	if computePercentileBuckets(nil) != nil {
+		t.Error("expected no tiers without scores")
+	}
+}
+
+func TestPercentileBucketTies(t *testing.T) {
+	// three pubkeys: the top 1% cutoff is the first, but the second ties it
+	scores := map[string]float64{padHex(51301): 0.4, padHex(51302): 0.4, padHex(51303): 0.2}
+	buckets := computePercentileBuckets(scores)
+	if buckets[0].Top != 1 || buckets[0].Pubkeys != 2 {
+		t.Errorf("expected both tied pubkeys in the top 1%%, got %+v", buckets[0])
+	}
+}
+
+func TestGraphPercentileBucket(t *testing.T) {
+	g := NewGraph()
+	if g.PercentileBucket(padHex(51401)) != 0 {
+		t.Error("expected 0 before any rebuild")
+	}
+	g.mu.Lock()
+	for i := 0; i < 100; i++ {
+		g.scores[padHex(51400+i)] = float64(100 - i)
+	}
+	g.mu.Unlock()
+	g.RefreshPercentileBuckets()
+
+	cases := map[string]int{
+		padHex(51400): 1,
+		padHex(51403): 5,
+		padHex(51409): 10,
+		padHex(51420): 25,
+		padHex(51449): 50,
+		padHex(51450): 100,
+		padHex(51600): 0, // unscored
+	}
+	for pubkey, want := range cases {
+		if got := g.PercentileBucket(pubkey); got != want {
+			t.Errorf("%s: expected tier %d, got %d", pubkey[58:], want, got)
+		}
+	}
+
+	// the tiers hold until the next rebuild, and the snapshot carries them
+	g.mu.Lock()
+	g.scores[padHex(51450)] = 1000
+	g.mu.Unlock()
+	if got := g.Snapshot().PercentileBucket(padHex(51450)); got != 1 {
+		t.Errorf("expected a score above the old boundary to land in the top tier, got %d", got)
+	}
+	if n := len(g.Snapshot().PercentileBuckets()); n != len(percentileTiers) {
+		t.Errorf("expected the snapshot to carry the tiers, got %d", n)
+	}
+}
+
+func TestScoreIncludesPercentileBucket(t *testing.T) {
+	old := graph
+	defer func() { graph = old }()
+	graph = NewGraph()
+	a, b := padHex(51501), padHex(51502)
+	graph.AddFollow(a, b)
+	graph.AddFollow(b, a)
+	graph.AddFollow(padHex(51503), b)
+	graph.ComputePageRank(20, 0.85)
+	graph.RefreshPercentileBuckets()
+
+	rr := httptest.NewRecorder()
+	handleScore(rr, httptest.NewRequest("GET", "/score?pubkey="+b, nil))
+	var resp map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp["percentile_bucket"] != float64(1) {
+		t.Errorf("expected the top pubkey in the top 1%%, got %v", resp["percentile_bucket"])
+	}
+}
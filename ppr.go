@@ -0,0 +1,115 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	pprIterations = 20
+	pprDamping    = 0.85
+	// pprCacheSize caps the viewers whose personalized PageRank is kept per graph
+	// build; the cache is cleared when full.
+	pprCacheSize = 64
+)
+
+// PersonalizedPageRank runs PageRank with restart: every step the walk either
+// follows an out-edge (with probability damping) or jumps back to root. Dangling
+// mass also returns to root. Only nodes reachable from root get a score, so the
+// vector is kept sparse and the cost is bounded by root's reachable set.
+func (g *Graph) PersonalizedPageRank(root string, iterations int, damping float64) map[string]float64 {
+	return g.personalizedPageRank(root, nil, iterations, damping)
+}
+
+// personalizedPageRank is PersonalizedPageRank with root's out-edges replaced by
+// rootFollows when it is non-nil, as for a viewer who imported their contact list.
+func (g *Graph) personalizedPageRank(root string, rootFollows []string, iterations int, damping float64) map[string]float64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	scores := map[string]float64{root: 1.0}
+	for i := 0; i < iterations; i++ {
+		next := make(map[string]float64, len(scores))
+		next[root] = 1 - damping
+		for node, s := range scores {
+			follows := g.follows[node]
+			if node == root && rootFollows != nil {
+				follows = rootFollows
+			}
+			if len(follows) == 0 {
+				next[root] += damping * s
+				continue
+			}
+			share := damping * s / float64(len(follows))
+			for _, f := range follows {
+				next[f] += share
+			}
+		}
+		scores = next
+	}
+	return scores
+}
+
+// pprRank returns target's 1-based position in viewer's personalized PageRank,
+// not counting the viewer itself, or 0 if the walk never reaches target.
+func pprRank(scores map[string]float64, viewer, target string) int {
+	s, ok := scores[target]
+	if !ok || target == viewer {
+		return 0
+	}
+	rank := 1
+	for pk, v := range scores {
+		if pk != viewer && pk != target && v > s {
+			rank++
+		}
+	}
+	return rank
+}
+
+// pprCache keeps recent personalized PageRank vectors. It is dropped whenever the
+// graph or its build changes.
+type pprCache struct {
+	mu      sync.Mutex
+	graph   *Graph
+	build   uint64
+	rev     uint64
+	results map[string]map[string]float64
+}
+
+var personalizedRanks = &pprCache{}
+
+// get returns viewer's personalized PageRank over the current graph, computing and
+// caching it on first use within the current build. An imported contact list
+// replaces the viewer's crawled follows.
+func (c *pprCache) get(viewer string) map[string]float64 {
+	id, rev, _ := graphBuild.Current()
+	key := viewer
+	overlay, imported := viewerOverlays.Get(viewer, time.Now())
+	if imported {
+		key += ":" + overlay.EventID
+	}
+	c.mu.Lock()
+	if c.graph != graph || c.build != id || c.rev != rev || c.results == nil {
+		c.graph, c.build, c.rev = graph, id, rev
+		c.results = make(map[string]map[string]float64)
+	}
+	if p, ok := c.results[key]; ok {
+		c.mu.Unlock()
+		return p
+	}
+	c.mu.Unlock()
+
+	var p map[string]float64
+	if imported {
+		p = graph.personalizedPageRank(viewer, overlay.Follows, pprIterations, pprDamping)
+	} else {
+		p = graph.PersonalizedPageRank(viewer, pprIterations, pprDamping)
+	}
+	c.mu.Lock()
+	if len(c.results) >= pprCacheSize {
+		c.results = make(map[string]map[string]float64)
+	}
+	c.results[key] = p
+	c.mu.Unlock()
+	return p
+}
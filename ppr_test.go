@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPersonalizedPageRankRestartsAtRoot(t *testing.T) {
+	g := NewGraph()
+	// alice -> bob -> carol; dave is followed by many but outside alice's reach
+	g.AddFollow("alice", "bob")
+	g.AddFollow("bob", "carol")
+	for i := 0; i < 10; i++ {
+		g.AddFollow(padHex(33000+i), "dave")
+	}
+
+	ppr := g.PersonalizedPageRank("alice", 50, 0.85)
+	sum := 0.0
+	for _, v := range ppr {
+		sum += v
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("expected scores to sum to 1, got %f", sum)
+	}
+	if _, ok := ppr["dave"]; ok {
+		t.Error("unreachable node should not be scored")
+	}
+	if !(ppr["alice"] > ppr["bob"] && ppr["bob"] > ppr["carol"] && ppr["carol"] > 0) {
+		t.Errorf("expected trust to decay along the chain, got %v", ppr)
+	}
+	if got := pprRank(ppr, "alice", "bob"); got != 1 {
+		t.Errorf("expected bob ranked 1, got %d", got)
+	}
+	if got := pprRank(ppr, "alice", "dave"); got != 0 {
+		t.Errorf("expected unreached dave ranked 0, got %d", got)
+	}
+}
+
+func TestHandlePersonalizedPPR(t *testing.T) {
+	oldGraph, oldBuild := graph, graphBuild
+	defer func() { graph, graphBuild = oldGraph, oldBuild }()
+	graph, graphBuild = NewGraph(), NewGraphBuild()
+
+	graph.AddFollow("alice", "bob")
+	graph.AddFollow("bob", "carol")
+	for i := 0; i < 30; i++ {
+		graph.AddFollow(padHex(33100+i), "dave")
+	}
+	graph.ComputePageRank(20, 0.85)
+
+	get := func(query string) (int, map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		handlePersonalized(rr, httptest.NewRequest(http.MethodGet, "/personalized?"+query, nil))
+		var resp map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr.Code, resp
+	}
+
+	// globally dave outranks carol, but alice only reaches carol
+	_, blend := get("viewer=alice&target=dave")
+	if blend["algorithm"] != "blend" || blend["personalized_score"].(float64) <= 0 {
+		t.Fatalf("expected a positive blended score for dave, got %v", blend)
+	}
+	_, dave := get("viewer=alice&target=dave&algorithm=ppr")
+	if dave["personalized_score"].(float64) != 0 || dave["ppr_rank"].(float64) != 0 {
+		t.Errorf("expected dave unreached under ppr, got %v", dave)
+	}
+	code, carol := get("viewer=alice&target=carol&algorithm=ppr")
+	if code != http.StatusOK || carol["personalized_score"].(float64) <= 0 || carol["ppr_rank"].(float64) != 2 || carol["ppr_reachable"].(float64) != 3 {
+		t.Errorf("expected carol reached at rank 2 of 3, got %v", carol)
+	}
+
+	// a graph change invalidates the cached vector
+	graph.AddFollow("alice", "dave")
+	graphBuild.Touch()
+	if _, dave = get("viewer=alice&target=dave&algorithm=ppr"); dave["ppr_rank"].(float64) != 1 {
+		t.Errorf("expected dave reached once alice follows dave, got %v", dave)
+	}
+
+	if code, _ := get("viewer=alice&target=bob&algorithm=hits"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown algorithm, got %d", code)
+	}
+}
@@ -37,34 +37,35 @@ type PredictMutual struct {
 }
 
 func handlePredict(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
 	sourceRaw := r.URL.Query().Get("source")
 	targetRaw := r.URL.Query().Get("target")
 	if sourceRaw == "" || targetRaw == "" {
-		http.Error(w, `{"error":"source and target parameters required"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "source and target parameters required"})
 		return
 	}
 
 	source, err := resolvePubkey(sourceRaw)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid source: %s"}`, err.Error()), http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid source: %s", err.Error())})
 		return
 	}
 	target, err := resolvePubkey(targetRaw)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid target: %s"}`, err.Error()), http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid target: %s", err.Error())})
 		return
 	}
 
 	if source == target {
-		http.Error(w, `{"error":"source and target must be different pubkeys"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "source and target must be different pubkeys"})
 		return
 	}
 
-	sourceFollows := graph.GetFollows(source)
-	targetFollows := graph.GetFollows(target)
-	sourceFollowers := graph.GetFollowers(source)
-	targetFollowers := graph.GetFollowers(target)
-	stats := graph.Stats()
+	sourceFollows := g.GetFollows(source)
+	targetFollows := g.GetFollows(target)
+	sourceFollowers := g.GetFollowers(source)
+	targetFollowers := g.GetFollowers(target)
+	stats := g.Stats()
 
 	// Check if source already follows target
 	alreadyFollows := false
@@ -90,7 +91,7 @@ func handlePredict(w http.ResponseWriter, r *http.Request) {
 	for _, sf := range sourceFollows {
 		if targetNeighborSet[sf] {
 			commonNeighbors++
-			score, _ := graph.GetScore(sf)
+			score, _ := g.GetScore(sf)
 			ns := normalizeScore(score, stats.Nodes)
 			mutuals = append(mutuals, PredictMutual{Pubkey: sf, WotScore: ns})
 		}
@@ -101,7 +102,7 @@ func handlePredict(w http.ResponseWriter, r *http.Request) {
 	adamicAdar := 0.0
 	for _, sf := range sourceFollows {
 		if targetNeighborSet[sf] {
-			sfFollowers := graph.GetFollowers(sf)
+			sfFollowers := g.GetFollowers(sf)
 			degree := len(sfFollowers)
 			if degree > 1 {
 				adamicAdar += 1.0 / math.Log(float64(degree))
@@ -138,8 +139,8 @@ func handlePredict(w http.ResponseWriter, r *http.Request) {
 
 	// Signal 5: WoT Score Proximity
 	// How close are their trust scores? Similar-ranked accounts follow each other.
-	sourceScore, _ := graph.GetScore(source)
-	targetScore, _ := graph.GetScore(target)
+	sourceScore, _ := g.GetScore(source)
+	targetScore, _ := g.GetScore(target)
 	sourceNorm := normalizeScore(sourceScore, stats.Nodes)
 	targetNorm := normalizeScore(targetScore, stats.Nodes)
 	scoreDiff := math.Abs(float64(sourceNorm) - float64(targetNorm))
@@ -23,6 +23,9 @@ type PricingResponse struct {
 	PricedEndpoints      []PricingEndpoint   `json:"priced_endpoints,omitempty"`
 	PaymentHints         PricingPaymentHints `json:"payment_hints"`
 	RateLimitPerIPPerMin int                 `json:"rate_limit_per_ip_per_min"`
+	RateLimitTiers       []RateTier          `json:"rate_limit_tiers"`
+	RateLimitEndpoints   []EndpointLimit     `json:"rate_limit_endpoints"`
+	RateLimitBackend     string              `json:"rate_limit_backend"`
 }
 
 func handlePricing(w http.ResponseWriter, r *http.Request, l402 *L402Middleware) {
@@ -33,7 +36,10 @@ func handlePricing(w http.ResponseWriter, r *http.Request, l402 *L402Middleware)
 			QueryParamName: "payment_hash",
 			StatusCode:     http.StatusPaymentRequired,
 		},
-		RateLimitPerIPPerMin: 100, // See main.go: NewRateLimiter(100, time.Minute)
+		RateLimitPerIPPerMin: rateTiers.Anonymous.limit,
+		RateLimitTiers:       rateTiers.Tiers(),
+		RateLimitEndpoints:   rateTiers.EndpointLimits(),
+		RateLimitBackend:     rateTiers.Backend,
 	}
 
 	if l402 != nil {
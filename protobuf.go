@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// A minimal protobuf wire format codec for the gRPC API: varint, 64-bit and
+// length-delimited fields, which cover every type in proto/wot/v1/score.proto.
+// See https://protobuf.dev/programming-guides/encoding/.
+
+// Protobuf wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+var errProtoTruncated = errors.New("protobuf: truncated message")
+
+// protoEncoder appends fields to a message. Like proto3, it leaves out fields
+// holding their zero value.
+type protoEncoder struct {
+	buf []byte
+}
+
+func (e *protoEncoder) tag(field, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+func (e *protoEncoder) Uint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, protoVarint)
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *protoEncoder) Bool(field int, v bool) {
+	if v {
+		e.Uint(field, 1)
+	}
+}
+
+func (e *protoEncoder) Double(field int, v float64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, protoFixed64)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+func (e *protoEncoder) String(field int, v string) {
+	if v == "" {
+		return
+	}
+	e.tag(field, protoBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// Message writes an embedded message, even an empty one, so repeated messages
+// keep their positions.
+func (e *protoEncoder) Message(field int, msg []byte) {
+	e.tag(field, protoBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(msg)))
+	e.buf = append(e.buf, msg...)
+}
+
+// protoField is one decoded field: v holds varint and fixed values, data the
+// contents of length-delimited ones.
+type protoField struct {
+	num      int
+	wireType int
+	v        uint64
+	data     []byte
+}
+
+// decodeProto calls fn for each field of msg in order. Fields fn doesn't know
+// about can simply be ignored, as protobuf requires.
+func decodeProto(msg []byte, fn func(f protoField) error) error {
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		msg = msg[n:]
+		f := protoField{num: int(key >> 3), wireType: int(key & 7)}
+		if f.num == 0 {
+			return errors.New("protobuf: invalid field number 0")
+		}
+		switch f.wireType {
+		case protoVarint:
+			if f.v, n = binary.Uvarint(msg); n <= 0 {
+				return errProtoTruncated
+			}
+			msg = msg[n:]
+		case protoFixed64:
+			if len(msg) < 8 {
+				return errProtoTruncated
+			}
+			f.v, msg = binary.LittleEndian.Uint64(msg), msg[8:]
+		case protoFixed32:
+			if len(msg) < 4 {
+				return errProtoTruncated
+			}
+			f.v, msg = uint64(binary.LittleEndian.Uint32(msg)), msg[4:]
+		case protoBytes:
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || size > uint64(len(msg)-n) {
+				return errProtoTruncated
+			}
+			f.data, msg = msg[n:n+int(size)], msg[n+int(size):]
+		default:
+			return errors.New("protobuf: unsupported wire type")
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestProtoEncoder(t *testing.T) {
+	var e protoEncoder
+	e.String(1, "hi")
+	e.Uint(3, 300)
+	e.Double(4, 0.5)
+	e.Bool(2, false) // zero values are left out
+	e.Uint(5, 0)
+	e.String(7, "")
+	want := []byte{0x0a, 2, 'h', 'i', 0x18, 0xac, 0x02, 0x21, 0, 0, 0, 0, 0, 0, 0xe0, 0x3f}
+	if !bytes.Equal(e.buf, want) {
+		t.Errorf("expected % x, got % x", want, e.buf)
+	}
+
+	var outer protoEncoder
+	outer.Message(1, nil)
+	outer.Message(1, e.buf)
+	var fields []protoField
+	if err := decodeProto(outer.buf, func(f protoField) error {
+		fields = append(fields, f)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 2 || len(fields[0].data) != 0 || !bytes.Equal(fields[1].data, want) {
+		t.Errorf("expected an empty and a full embedded message, got %+v", fields)
+	}
+}
+
+func TestDecodeProto(t *testing.T) {
+	var e protoEncoder
+	e.Uint(1, 7)
+	e.Double(2, math.Pi)
+	e.String(3, "x")
+	msg := append(e.buf, 0x2d, 1, 0, 0, 0) // field 5, fixed32, unknown to the caller
+
+	got := map[int]protoField{}
+	if err := decodeProto(msg, func(f protoField) error {
+		got[f.num] = f
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got[1].v != 7 || math.Float64frombits(got[2].v) != math.Pi || string(got[3].data) != "x" || got[5].v != 1 {
+		t.Errorf("unexpected fields %+v", got)
+	}
+
+	for name, bad := range map[string][]byte{
+		"truncated varint":  {0x08, 0x80},
+		"truncated fixed64": {0x11, 1, 2},
+		"overlong bytes":    {0x1a, 5, 'a'},
+		"field zero":        {0x00, 1},
+		"group wire type":   {0x0b},
+	} {
+		if err := decodeProto(bad, func(protoField) error { return nil }); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
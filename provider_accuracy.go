@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// providerAccuracyMinChecks is how many of a provider's claims must be
+	// checked before its accuracy is used to weight composite scores.
+	providerAccuracyMinChecks = 10
+	// providerDefaultWeight is the composite weight of providers without
+	// enough checked claims.
+	providerDefaultWeight = 0.5
+)
+
+// ProviderAccuracy is how often an external provider's kind 30382 claims agree
+// with our own graph, using the same checks as /verify.
+type ProviderAccuracy struct {
+	Pubkey     string  `json:"pubkey"`
+	Assertions int     `json:"assertions"`
+	Verifiable int     `json:"verifiable"` // assertions about subjects in our graph
+	Checks     int     `json:"checks"`
+	Matches    int     `json:"matches"`
+	Close      int     `json:"close"`
+	Divergent  int     `json:"divergent"`
+	Accuracy   float64 `json:"accuracy"` // share of checks that matched or were close
+	RankMAE    float64 `json:"rank_mae"` // mean absolute error of the normalized rank
+	Weight     float64 `json:"weight"`   // weight of this provider in composite scores
+	Scored     bool    `json:"scored"`   // false until providerAccuracyMinChecks checks
+}
+
+// ProviderAccuracyBoard holds the latest accuracy of every external provider.
+type ProviderAccuracyBoard struct {
+	mu        sync.RWMutex
+	providers map[string]*ProviderAccuracy
+	updated   time.Time
+}
+
+func NewProviderAccuracyBoard() *ProviderAccuracyBoard {
+	return &ProviderAccuracyBoard{providers: make(map[string]*ProviderAccuracy)}
+}
+
+var providerAccuracy = NewProviderAccuracyBoard()
+
+// Set replaces the board with a fresh evaluation.
+func (b *ProviderAccuracyBoard) Set(providers map[string]*ProviderAccuracy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.providers = providers
+	b.updated = time.Now()
+}
+
+// Get returns a provider's accuracy, or nil if it hasn't been evaluated.
+func (b *ProviderAccuracyBoard) Get(pubkey string) *ProviderAccuracy {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.providers[pubkey]
+}
+
+// Weight returns a provider's weight in composite scores.
+func (b *ProviderAccuracyBoard) Weight(pubkey string) float64 {
+	if pa := b.Get(pubkey); pa != nil {
+		return pa.Weight
+	}
+	return providerDefaultWeight
+}
+
+// Report returns every evaluated provider, most accurate first.
+func (b *ProviderAccuracyBoard) Report() ([]*ProviderAccuracy, time.Time) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	out := make([]*ProviderAccuracy, 0, len(b.providers))
+	for _, pa := range b.providers {
+		out = append(out, pa)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Scored != out[j].Scored {
+			return out[i].Scored
+		}
+		if out[i].Accuracy != out[j].Accuracy {
+			return out[i].Accuracy > out[j].Accuracy
+		}
+		return out[i].Pubkey < out[j].Pubkey
+	})
+	return out, b.updated
+}
+
+// all returns every stored assertion.
+func (s *AssertionStore) all() []*ExternalAssertion {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []*ExternalAssertion
+	for _, byProvider := range s.assertions {
+		for _, a := range byProvider {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// evaluateProviderAccuracy checks every stored assertion about a subject in our
+// graph with the /verify checks. Ranks are normalized to the provider's scale
+// first, so providers publishing raw scores aren't penalized for their range.
+func evaluateProviderAccuracy(g *Graph, store *AssertionStore) map[string]*ProviderAccuracy {
+	nodes := g.Stats().Nodes
+	out := make(map[string]*ProviderAccuracy)
+	rankErr := make(map[string]float64)
+	for _, a := range store.all() {
+		pa := out[a.ProviderPubkey]
+		if pa == nil {
+			pa = &ProviderAccuracy{Pubkey: a.ProviderPubkey}
+			out[a.ProviderPubkey] = pa
+		}
+		pa.Assertions++
+		if _, found := g.GetScore(a.SubjectPubkey); !found {
+			continue
+		}
+		pa.Verifiable++
+		norm := NormalizeRank(a.Rank, store.GetProvider(a.ProviderPubkey))
+		for _, c := range verifyClaims(g, nodes, a.SubjectPubkey, norm, true, a.Followers, a.Followers > 0) {
+			pa.Checks++
+			switch c.Status {
+			case "match":
+				pa.Matches++
+			case "close":
+				pa.Close++
+			case "divergent":
+				pa.Divergent++
+			}
+			if c.Field == "rank" {
+				rankErr[a.ProviderPubkey] += math.Abs(float64(c.Claimed.(int) - c.Observed.(int)))
+			}
+		}
+	}
+
+	for pk, pa := range out {
+		pa.Weight = providerDefaultWeight
+		if pa.Checks == 0 {
+			continue
+		}
+		pa.Accuracy = math.Round(float64(pa.Matches+pa.Close)/float64(pa.Checks)*1000) / 1000
+		pa.RankMAE = math.Round(rankErr[pk]/float64(pa.Verifiable)*10) / 10
+		if pa.Checks >= providerAccuracyMinChecks {
+			pa.Scored = true
+			pa.Weight = pa.Accuracy
+		}
+	}
+	return out
+}
+
+// handleProviderAccuracy handles GET /providers/accuracy
+// Reports how often each external provider's claims agree with our graph, and
+// the weight each gets in composite scores.
+func handleProviderAccuracy(w http.ResponseWriter, r *http.Request) {
+	providers, updated := providerAccuracy.Report()
+	resp := map[string]interface{}{
+		"providers":      providers,
+		"provider_count": len(providers),
+		"min_checks":     providerAccuracyMinChecks,
+		"default_weight": providerDefaultWeight,
+		"graph_size":     graph.Stats().Nodes,
+	}
+	if !updated.IsZero() {
+		resp["updated_at"] = updated.UTC().Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// setupProviderAccuracy builds a graph of 12 followed subjects and a store where
+// "good" claims our scores and follower counts, "bad" claims the opposite rank,
+// and "sparse" has too few claims to be scored.
+func setupProviderAccuracy(t *testing.T) *AssertionStore {
+	t.Helper()
+	oldGraph, oldBoard := graph, providerAccuracy
+	t.Cleanup(func() { graph, providerAccuracy = oldGraph, oldBoard })
+	graph, providerAccuracy = NewGraph(), NewProviderAccuracyBoard()
+	for i := 0; i < 12; i++ {
+		for j := 0; j <= i; j++ {
+			graph.AddFollow(padHex(44100+j), padHex(44000+i))
+		}
+	}
+	graph.ComputePageRank(20, 0.85)
+	nodes := graph.Stats().Nodes
+
+	store := NewAssertionStore()
+	now := time.Now().Unix()
+	for i := 0; i < 12; i++ {
+		subject := padHex(44000 + i)
+		raw, _ := graph.GetScore(subject)
+		score := normalizeScore(raw, nodes)
+		followers := len(graph.GetFollowers(subject))
+		store.Add(&ExternalAssertion{ProviderPubkey: "good", SubjectPubkey: subject, Rank: score, Followers: followers, CreatedAt: now})
+		store.Add(&ExternalAssertion{ProviderPubkey: "bad", SubjectPubkey: subject, Rank: 100 - score, CreatedAt: now})
+	}
+	store.Add(&ExternalAssertion{ProviderPubkey: "sparse", SubjectPubkey: padHex(44000), Rank: 50, CreatedAt: now})
+	store.Add(&ExternalAssertion{ProviderPubkey: "sparse", SubjectPubkey: padHex(44999), Rank: 50, CreatedAt: now})
+	return store
+}
+
+func TestEvaluateProviderAccuracy(t *testing.T) {
+	store := setupProviderAccuracy(t)
+	got := evaluateProviderAccuracy(graph, store)
+
+	good := got["good"]
+	if good == nil || good.Assertions != 12 || good.Verifiable != 12 || good.Checks != 24 || good.Accuracy != 1 || good.RankMAE != 0 {
+		t.Fatalf("expected good to agree on every check, got %+v", good)
+	}
+	if !good.Scored || good.Weight != 1 {
+		t.Errorf("expected good weighted by its accuracy, got %+v", good)
+	}
+	bad := got["bad"]
+	if bad == nil || bad.Checks != 12 || bad.Accuracy >= 0.5 || bad.Weight != bad.Accuracy || bad.RankMAE == 0 {
+		t.Errorf("expected bad to diverge, got %+v", bad)
+	}
+	sparse := got["sparse"]
+	if sparse == nil || sparse.Assertions != 2 || sparse.Verifiable != 1 || sparse.Scored || sparse.Weight != providerDefaultWeight {
+		t.Errorf("expected sparse unscored with the default weight, got %+v", sparse)
+	}
+}
+
+func TestCompositeScoreWeightsByAccuracy(t *testing.T) {
+	store := setupProviderAccuracy(t)
+	providerAccuracy.Set(map[string]*ProviderAccuracy{
+		"good": {Pubkey: "good", Weight: 1},
+		"bad":  {Pubkey: "bad", Weight: 0},
+	})
+	externals := []*ExternalAssertion{
+		{ProviderPubkey: "good", Rank: 100, CreatedAt: time.Now().Unix()},
+		{ProviderPubkey: "bad", Rank: 0, CreatedAt: time.Now().Unix()},
+	}
+
+	// Only good counts: 50*0.7 + 100*0.3 = 65
+	score, sources := CompositeScore(50, externals, store)
+	if score != 65 {
+		t.Errorf("expected 65 with bad ignored, got %d", score)
+	}
+	if sources[1].Weight != 0 {
+		t.Errorf("expected bad's weight reported, got %v", sources[1])
+	}
+
+	score, _ = CompositeScore(50, externals[1:], store)
+	if score != 50 {
+		t.Errorf("expected the internal score when every provider has weight 0, got %d", score)
+	}
+}
+
+func TestHandleProviderAccuracy(t *testing.T) {
+	store := setupProviderAccuracy(t)
+	providerAccuracy.Set(evaluateProviderAccuracy(graph, store))
+
+	rr := httptest.NewRecorder()
+	handleProviderAccuracy(rr, httptest.NewRequest(http.MethodGet, "/providers/accuracy", nil))
+	var resp struct {
+		Providers     []ProviderAccuracy `json:"providers"`
+		ProviderCount int                `json:"provider_count"`
+		UpdatedAt     string             `json:"updated_at"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); rr.Code != http.StatusOK || err != nil {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if resp.ProviderCount != 3 || resp.UpdatedAt == "" {
+		t.Fatalf("unexpected response %s", rr.Body.String())
+	}
+	if resp.Providers[0].Pubkey != "good" || resp.Providers[1].Pubkey != "bad" || resp.Providers[2].Pubkey != "sparse" {
+		t.Errorf("expected scored providers by accuracy, then unscored, got %+v", resp.Providers)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// PruneReport is what one pruning pass removed from the graph.
+type PruneReport struct {
+	At            time.Time `json:"at"`
+	InactiveNodes int       `json:"inactive_nodes"` // no contact list or authored event since the cutoff
+	OrphanedNodes int       `json:"orphaned_nodes"` // left without follows or followers by the pruning
+	CappedNodes   int       `json:"capped_nodes"`   // follow lists longer than max_follows
+	CappedEdges   int       `json:"capped_edges"`   // follows past max_follows, not counted by PageRank
+}
+
+// PruneStats keeps the last pruning pass and running totals for /stats.
+type PruneStats struct {
+	mu     sync.Mutex
+	runs   int
+	last   *PruneReport
+	totals PruneReport
+}
+
+var graphPruning = &PruneStats{}
+
+func (s *PruneStats) Record(r PruneReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs++
+	s.last = &r
+	s.totals.At = r.At
+	s.totals.InactiveNodes += r.InactiveNodes
+	s.totals.OrphanedNodes += r.OrphanedNodes
+	s.totals.CappedNodes += r.CappedNodes
+	s.totals.CappedEdges += r.CappedEdges
+}
+
+// Status reports the active policy, the last pass and the totals since startup.
+func (s *PruneStats) Status(cfg Config) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := map[string]interface{}{
+		"inactive_months": cfg.PruneInactiveMonths,
+		"max_follows":     cfg.MaxFollows,
+		"runs":            s.runs,
+		"totals":          s.totals,
+	}
+	if s.last != nil {
+		status["last"] = *s.last
+	}
+	return status
+}
+
+// Prune drops what the graph would otherwise keep forever. With maxFollows above
+// zero, follow lists longer than that are counted in the report but stored in
+// full: PageRank applies the cap (see newPageRankGraph), so a later contact list
+// from the same author isn't diffed against a truncated one. With a non-zero
+// cutoff, pubkeys whose newest contact list and newest authored event (from
+// lastCreated, unix seconds) are both older than it are removed with all their
+// edges. Pubkeys with no known timestamp at all can't be dated and are kept, unless
+// the pruning leaves them with no edges. Pubkeys in keep are never removed. Scores
+// are left to the next PageRank run.
+func (g *Graph) Prune(cutoff time.Time, maxFollows int, keep map[string]bool, lastCreated func(pubkey string) int64) PruneReport {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.changed()
+	report := PruneReport{At: time.Now()}
+
+	if maxFollows > 0 {
+		for _, list := range g.follows {
+			if len(list) > maxFollows {
+				report.CappedNodes++
+				report.CappedEdges += len(list) - maxFollows
+			}
+		}
+	}
+
+	// dirty lists are rebuilt without the removed pubkeys below
+	dirtyFollowers := make(map[string]bool)
+
+	removed := make(map[string]bool)
+	dirtyFollows := make(map[string]bool)
+	remove := func(pk string) {
+		removed[pk] = true
+		for _, to := range g.follows[pk] {
+			dirtyFollowers[to] = true
+			delete(g.followTimes, pk+":"+to)
+		}
+		for _, f := range g.followers[pk] {
+			dirtyFollows[f] = true
+			delete(g.followTimes, f+":"+pk)
+		}
+	}
+	if !cutoff.IsZero() {
+		nodes := make(map[string]bool, len(g.follows)+len(g.followers))
+		for pk := range g.follows {
+			nodes[pk] = true
+		}
+		for pk := range g.followers {
+			nodes[pk] = true
+		}
+		for pk := range nodes {
+			if keep[pk] {
+				continue
+			}
+			last := g.listTimes[pk]
+			for _, to := range g.follows[pk] {
+				if t := g.followTimes[pk+":"+to]; t.After(last) {
+					last = t
+				}
+			}
+			if c := lastCreated(pk); c > 0 && time.Unix(c, 0).After(last) {
+				last = time.Unix(c, 0)
+			}
+			if !last.IsZero() && last.Before(cutoff) {
+				remove(pk)
+				report.InactiveNodes++
+			}
+		}
+	}
+
+	for f := range dirtyFollows {
+		if removed[f] {
+			continue
+		}
+		list := make([]string, 0, len(g.follows[f]))
+		for _, to := range g.follows[f] {
+			if !removed[to] {
+				list = append(list, to)
+			}
+		}
+		g.follows[f] = list
+	}
+	for to := range dirtyFollowers {
+		if removed[to] {
+			continue
+		}
+		list := make([]string, 0, len(g.followers[to]))
+		for _, f := range g.followers[to] {
+			if !removed[f] {
+				list = append(list, f)
+			}
+		}
+		g.followers[to] = list
+	}
+	for pk := range removed {
+		delete(g.follows, pk)
+		delete(g.followers, pk)
+		delete(g.listTimes, pk)
+	}
+
+	// pubkeys only known as the target of what was removed
+	for pk := range dirtyFollowers {
+		if removed[pk] || keep[pk] || len(g.followers[pk]) > 0 || len(g.follows[pk]) > 0 {
+			continue
+		}
+		if _, listed := g.listTimes[pk]; listed {
+			continue // published an empty contact list; dated like anyone else
+		}
+		delete(g.follows, pk)
+		delete(g.followers, pk)
+		report.OrphanedNodes++
+	}
+	return report
+}
+
+// pruneGraph applies cfg's pruning policy to the live graph and records the result.
+func pruneGraph(cfg Config, now time.Time) PruneReport {
+	var cutoff time.Time
+	if cfg.PruneInactiveMonths > 0 {
+		cutoff = now.AddDate(0, -cfg.PruneInactiveMonths, 0)
+	}
+	keep := make(map[string]bool)
+	for _, pk := range curation.CrawlSeeds(cfg.Seeds) {
+		keep[pk] = true
+	}
+	report := graph.Prune(cutoff, cfg.MaxFollows, keep, meta.LastCreated)
+	graphPruning.Record(report)
+	return report
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGraphPruneInactive(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := now.AddDate(-2, 0, 0)
+	active, dead, target, seed, poster, undated := padHex(49301), padHex(49302), padHex(49303), padHex(49304), padHex(49305), padHex(49306)
+
+	g := NewGraph()
+	g.SetFollows(active, []string{dead, seed, undated}, now.AddDate(0, -1, 0))
+	g.SetFollows(dead, []string{target, active}, old)
+	g.SetFollows(seed, []string{active}, old)
+	g.SetFollows(poster, []string{active}, old)
+	s := g.Snapshot()
+
+	lastCreated := func(pk string) int64 {
+		if pk == poster {
+			return now.AddDate(0, 0, -3).Unix() // an old list, but still posting
+		}
+		return 0
+	}
+	r := g.Prune(now.AddDate(0, -12, 0), 0, map[string]bool{seed: true}, lastCreated)
+	if r.InactiveNodes != 1 || r.OrphanedNodes != 1 || r.CappedNodes != 0 {
+		t.Fatalf("expected the dead account and its orphaned follow pruned, got %+v", r)
+	}
+
+	if len(g.GetFollows(dead)) != 0 || len(g.GetFollowers(dead)) != 0 {
+		t.Errorf("expected the dead account's edges removed")
+	}
+	if _, ok := g.followers[target]; ok {
+		t.Errorf("expected the orphaned target dropped")
+	}
+	if got := g.GetFollows(active); len(got) != 2 || got[0] != seed || got[1] != undated {
+		t.Errorf("expected the dead account dropped from follow lists, got %v", got)
+	}
+	if got := g.GetFollowers(active); len(got) != 2 {
+		t.Errorf("expected the seed and the poster kept as followers, got %v", got)
+	}
+	if got := s.GetFollows(dead); len(got) != 2 {
+		t.Errorf("expected the snapshot unchanged, got %v", got)
+	}
+}
+
+func TestGraphPruneMaxFollows(t *testing.T) {
+	spammer, friend := padHex(49310), padHex(49311)
+	var follows []string
+	for i := 0; i < 10; i++ {
+		follows = append(follows, padHex(49320+i))
+	}
+	g := NewGraph()
+	g.SetFollows(spammer, follows, time.Now())
+	g.SetFollows(friend, []string{follows[8]}, time.Now())
+
+	r := g.Prune(time.Time{}, 4, nil, func(string) int64 { return 0 })
+	if r.CappedNodes != 1 || r.CappedEdges != 6 || r.InactiveNodes != 0 || r.OrphanedNodes != 0 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	// The stored list stays whole, so the next contact list isn't diffed against a
+	// truncated one and read as a wave of unfollows
+	if got := g.GetFollows(spammer); len(got) != 10 {
+		t.Errorf("expected all 10 follows stored, got %v", got)
+	}
+	if got := g.GetFollowers(follows[8]); len(got) != 2 {
+		t.Errorf("expected both followers of a capped follow stored, got %v", got)
+	}
+	if r := g.Prune(time.Time{}, 4, nil, func(string) int64 { return 0 }); r.CappedEdges != 6 {
+		t.Errorf("expected the same follows over the cap on a second pass, got %+v", r)
+	}
+}
+
+func TestPageRankMaxFollows(t *testing.T) {
+	oldConfig := config
+	t.Cleanup(func() { config = oldConfig })
+	spammer, friend := padHex(49350), padHex(49351)
+	var follows []string
+	for i := 0; i < 10; i++ {
+		follows = append(follows, padHex(49360+i))
+	}
+	g := NewGraph()
+	g.SetFollows(spammer, follows, time.Now())
+	g.SetFollows(friend, []string{follows[8]}, time.Now())
+
+	config = NewConfigStore("")
+	g.ComputePageRank(20, 0.85)
+	uncapped, _ := g.GetScore(follows[0])
+
+	config.cfg.MaxFollows = 4
+	g.ComputePageRank(20, 0.85)
+	if _, ok := g.GetScore(follows[9]); ok {
+		t.Errorf("expected a follow past the cap with no other followers left unscored")
+	}
+	if _, ok := g.GetScore(follows[8]); !ok {
+		t.Errorf("expected a follow past the cap scored through its other follower")
+	}
+	capped, _ := g.GetScore(follows[0])
+	if capped <= uncapped {
+		t.Errorf("expected counted follows to get a larger share, got %v then %v", uncapped, capped)
+	}
+	if got := g.GetFollows(spammer); len(got) != 10 {
+		t.Errorf("expected the stored list untouched, got %d follows", len(got))
+	}
+
+	// Weighted PageRank leaves the uncounted follows out of the follower's total too
+	g.ComputeWeightedPageRank(20, 0.85, func(from, to string) float64 { return 1 })
+	if weighted, _ := g.GetScore(follows[0]); math.Abs(weighted-capped) > 1e-12 {
+		t.Errorf("expected weighted PageRank with unit weights to match, got %v", weighted)
+	}
+}
+
+func TestPruneGraphRecordsStats(t *testing.T) {
+	oldGraph, oldStats := graph, graphPruning
+	t.Cleanup(func() { graph, graphPruning = oldGraph, oldStats })
+	graphPruning = &PruneStats{}
+	graph = NewGraph()
+	now := time.Now()
+	graph.SetFollows(padHex(49340), []string{padHex(49341), padHex(49342)}, now.AddDate(-3, 0, 0))
+
+	cfg := defaultConfig
+	cfg.PruneInactiveMonths = 6
+	cfg.MaxFollows = 1
+	pruneGraph(cfg, now)
+	pruneGraph(cfg, now)
+
+	status := graphPruning.Status(cfg)
+	totals := status["totals"].(PruneReport)
+	if status["runs"] != 2 || status["inactive_months"] != 6 || totals.InactiveNodes != 1 || totals.CappedEdges != 1 || totals.OrphanedNodes != 2 {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if last := status["last"].(PruneReport); last.InactiveNodes != 0 {
+		t.Errorf("expected the second pass to find nothing, got %+v", last)
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// publishChunkSize is how many kind 30382 events are built and signed before
+	// they are handed to the queue, so relays start receiving the top of the
+	// ranking while the rest is still being signed.
+	publishChunkSize = 500
+	// A relay's pace starts at publishStartInterval between events, speeds up by
+	// a tenth on each success down to publishMinInterval, and doubles on each
+	// failure up to publishMaxInterval.
+	publishStartInterval = 100 * time.Millisecond
+	publishMinInterval   = 20 * time.Millisecond
+	publishMaxInterval   = 10 * time.Second
+	// A rejected event is retried after publishBaseBackoff, doubling per attempt
+	// up to publishMaxBackoff, and given up on after publishMaxAttempts.
+	publishBaseBackoff = 2 * time.Second
+	publishMaxBackoff  = 5 * time.Minute
+	publishMaxAttempts = 5
+	// publishSaveInterval is how often progress is written to PUBLISH_QUEUE_FILE
+	// while relays are being worked through.
+	publishSaveInterval = 30 * time.Second
+	publishSendTimeout  = 10 * time.Second
+)
+
+// publishItem is a signed event some relays have yet to accept.
+type publishItem struct {
+	Seq    int64          `json:"seq"`
+	Event  nostr.Event    `json:"event"`
+	Relays map[string]int `json:"relays"` // relay -> failed attempts, until it accepts the event
+}
+
+// relayPublisher paces one relay and keeps its counters.
+type relayPublisher struct {
+	queue       []*publishItem
+	interval    time.Duration
+	nextAt      time.Time
+	sent        int
+	succeeded   int
+	failed      int
+	dropped     int
+	lastError   string
+	lastErrorAt time.Time
+	active      bool // a worker is draining the queue
+}
+
+// PublishQueue delivers signed assertion events to relays. Each relay has its own
+// queue and worker, so a slow or failing relay never holds up the others. Events
+// are keyed by kind and d tag: queueing a newer version of a replaceable event
+// replaces the one still waiting. With a file set, undelivered events survive a
+// restart and are resumed where each relay left off.
+type PublishQueue struct {
+	mu        sync.Mutex
+	saveMu    sync.Mutex
+	path      string
+	items     map[string]*publishItem // kind:d -> item
+	relays    map[string]*relayPublisher
+	seq       int64
+	enqueued  int
+	completed int
+	lastQueue time.Time
+	lastSave  time.Time
+	ctx       context.Context
+	wg        sync.WaitGroup
+
+	// overridable in tests
+	send          func(ctx context.Context, relay string, ev nostr.Event) error
+	startInterval time.Duration
+	baseBackoff   time.Duration
+}
+
+func NewPublishQueue(path string) *PublishQueue {
+	return &PublishQueue{
+		path:          path,
+		items:         make(map[string]*publishItem),
+		relays:        make(map[string]*relayPublisher),
+		startInterval: publishStartInterval,
+		baseBackoff:   publishBaseBackoff,
+	}
+}
+
+var publishQueue = NewPublishQueue(os.Getenv("PUBLISH_QUEUE_FILE"))
+
+// publishQueueState is what PUBLISH_QUEUE_FILE holds.
+type publishQueueState struct {
+	Items []*publishItem `json:"items"`
+}
+
+// publishKey keys addressable events by kind and d tag, so a newer version
+// replaces a queued one, and other events (spam reports) by id.
+func publishKey(ev *nostr.Event) string {
+	if ev.Kind < 30000 || ev.Kind >= 40000 {
+		return fmt.Sprintf("%d:%s", ev.Kind, ev.ID)
+	}
+	return fmt.Sprintf("%d:%s", ev.Kind, ev.Tags.GetD())
+}
+
+// Load restores undelivered events from the file. A missing file is not an error.
+func (q *PublishQueue) Load() error {
+	if q.path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state publishQueueState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return fmt.Errorf("%s: %w", q.path, err)
+	}
+	sort.Slice(state.Items, func(i, j int) bool { return state.Items[i].Seq < state.Items[j].Seq })
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, item := range state.Items {
+		if len(item.Relays) == 0 {
+			continue
+		}
+		q.items[publishKey(&item.Event)] = item
+		for url := range item.Relays {
+			q.relay(url).queue = append(q.relay(url).queue, item)
+		}
+		q.seq = max(q.seq, item.Seq)
+	}
+	if len(q.items) > 0 {
+		log.Printf("Publish queue: resuming %d undelivered events", len(q.items))
+	}
+	return nil
+}
+
+// save writes the undelivered events to the file.
+func (q *PublishQueue) save() {
+	if q.path == "" {
+		return
+	}
+	q.saveMu.Lock()
+	defer q.saveMu.Unlock()
+	q.mu.Lock()
+	state := publishQueueState{Items: make([]*publishItem, 0, len(q.items))}
+	for _, item := range q.items {
+		state.Items = append(state.Items, item)
+	}
+	raw, err := json.Marshal(state)
+	q.lastSave = time.Now()
+	q.mu.Unlock()
+	if err == nil {
+		err = writeFileAtomic(q.path, raw)
+	}
+	if err != nil {
+		log.Printf("Publish queue: saving progress failed: %v", err)
+	}
+}
+
+// relay returns url's publisher, creating it. Callers hold q.mu.
+func (q *PublishQueue) relay(url string) *relayPublisher {
+	rp := q.relays[url]
+	if rp == nil {
+		rp = &relayPublisher{interval: q.startInterval}
+		q.relays[url] = rp
+	}
+	return rp
+}
+
+// Start begins delivering queued events until ctx is done. Without a send
+// function set, events go out through a relay pool tied to ctx.
+func (q *PublishQueue) Start(ctx context.Context) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.send == nil {
+		pool := nostr.NewSimplePool(ctx)
+		q.send = func(ctx context.Context, url string, ev nostr.Event) error {
+			relay, err := pool.EnsureRelay(url)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(ctx, publishSendTimeout)
+			defer cancel()
+			return relay.Publish(ctx, ev)
+		}
+	}
+	q.ctx = ctx
+	for url := range q.relays {
+		q.wake(url)
+	}
+}
+
+// wake starts url's worker if it has events and none is running. Callers hold q.mu.
+func (q *PublishQueue) wake(url string) {
+	rp := q.relays[url]
+	if q.ctx == nil || rp.active || len(rp.queue) == 0 {
+		return
+	}
+	rp.active = true
+	q.wg.Add(1)
+	go q.work(url)
+}
+
+// Wait blocks until every worker has gone idle.
+func (q *PublishQueue) Wait() {
+	q.wg.Wait()
+}
+
+// Enqueue queues signed events for each of relays and returns how many were
+// queued. An event replaces any queued one with the same kind and d tag.
+func (q *PublishQueue) Enqueue(events []nostr.Event, relays []string) int {
+	if len(events) == 0 || len(relays) == 0 {
+		return 0
+	}
+	q.mu.Lock()
+	for _, ev := range events {
+		key := publishKey(&ev)
+		item := q.items[key]
+		if item == nil {
+			q.seq++
+			item = &publishItem{Seq: q.seq, Relays: make(map[string]int, len(relays))}
+			q.items[key] = item
+		}
+		item.Event = ev
+		for _, url := range relays {
+			if _, queued := item.Relays[url]; !queued {
+				q.relay(url).queue = append(q.relay(url).queue, item)
+			}
+			item.Relays[url] = 0
+		}
+	}
+	q.enqueued += len(events)
+	q.lastQueue = time.Now()
+	for _, url := range relays {
+		q.wake(url)
+	}
+	q.mu.Unlock()
+	q.save()
+	return len(events)
+}
+
+// work delivers url's queue in order, one event at a time at the relay's pace,
+// and exits once the queue is empty or the queue is stopped.
+func (q *PublishQueue) work(url string) {
+	defer q.wg.Done()
+	for {
+		q.mu.Lock()
+		rp := q.relays[url]
+		if len(rp.queue) == 0 || q.ctx.Err() != nil {
+			rp.active = false
+			q.mu.Unlock()
+			q.save()
+			return
+		}
+		item := rp.queue[0]
+		ev := item.Event
+		wait := time.Until(rp.nextAt)
+		q.mu.Unlock()
+
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-q.ctx.Done():
+				continue
+			}
+		}
+		err := q.send(q.ctx, url, ev)
+
+		q.mu.Lock()
+		q.result(url, item, ev.ID, err)
+		due := time.Since(q.lastSave) >= publishSaveInterval
+		q.mu.Unlock()
+		if due {
+			q.save()
+		}
+	}
+}
+
+// result applies the outcome of sending item (as event id) to url. Callers hold q.mu.
+func (q *PublishQueue) result(url string, item *publishItem, id string, err error) {
+	rp := q.relays[url]
+	now := time.Now()
+	rp.sent++
+	// a newer version replaced the event while it was in flight; send that next
+	current := item.Event.ID == id
+
+	if err == nil {
+		rp.succeeded++
+		rp.interval = max(publishMinInterval, rp.interval*9/10)
+		rp.nextAt = now.Add(rp.interval)
+		if current {
+			q.done(url, item)
+		}
+		return
+	}
+
+	rp.failed++
+	rp.lastError, rp.lastErrorAt = err.Error(), now
+	rp.interval = min(publishMaxInterval, rp.interval*2)
+	rp.nextAt = now.Add(rp.interval)
+	if !current {
+		return
+	}
+	item.Relays[url]++
+	attempts := item.Relays[url]
+	if attempts >= publishMaxAttempts {
+		log.Printf("Publish to %s: giving up on kind %d %s after %d attempts: %v", url, item.Event.Kind, item.Event.Tags.GetD(), attempts, err)
+		rp.dropped++
+		q.done(url, item)
+		return
+	}
+	backoff := min(q.baseBackoff<<(attempts-1), publishMaxBackoff)
+	if backoff > rp.interval {
+		rp.nextAt = now.Add(backoff)
+	}
+}
+
+// done takes item off url's queue. Callers hold q.mu.
+func (q *PublishQueue) done(url string, item *publishItem) {
+	rp := q.relays[url]
+	rp.queue = rp.queue[1:]
+	delete(item.Relays, url)
+	if len(item.Relays) == 0 {
+		delete(q.items, publishKey(&item.Event))
+		q.completed++
+	}
+}
+
+// RelayPublishStatus is one relay's delivery progress.
+type RelayPublishStatus struct {
+	Relay       string  `json:"relay"`
+	Queued      int     `json:"queued"`
+	Sent        int     `json:"sent"`
+	Succeeded   int     `json:"succeeded"`
+	Failed      int     `json:"failed"`  // rejected or timed-out attempts
+	Dropped     int     `json:"dropped"` // events given up on after publishMaxAttempts
+	SuccessRate float64 `json:"success_rate"`
+	IntervalMs  int64   `json:"interval_ms"`
+	RetryAt     string  `json:"retry_at,omitempty"` // set while backing off
+	LastError   string  `json:"last_error,omitempty"`
+	LastErrorAt string  `json:"last_error_at,omitempty"`
+	Active      bool    `json:"active"`
+}
+
+// PublishStatus is the /publish/status response.
+type PublishStatus struct {
+	QueueDepth     int                  `json:"queue_depth"` // events not yet accepted by every relay
+	TopN           int                  `json:"top_n"`
+	Enqueued       int                  `json:"enqueued"`
+	Completed      int                  `json:"completed"`
+	LastEnqueuedAt string               `json:"last_enqueued_at,omitempty"`
+	Resumable      bool                 `json:"resumable"` // progress is saved to PUBLISH_QUEUE_FILE
+	Relays         []RelayPublishStatus `json:"relays"`
+}
+
+// Status reports queue depth and each relay's progress.
+func (q *PublishQueue) Status() PublishStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := PublishStatus{
+		QueueDepth: len(q.items),
+		TopN:       config.Get().PublishTopN,
+		Enqueued:   q.enqueued,
+		Completed:  q.completed,
+		Resumable:  q.path != "",
+		Relays:     []RelayPublishStatus{},
+	}
+	if !q.lastQueue.IsZero() {
+		s.LastEnqueuedAt = q.lastQueue.UTC().Format(time.RFC3339)
+	}
+	now := time.Now()
+	for url, rp := range q.relays {
+		r := RelayPublishStatus{
+			Relay:      url,
+			Queued:     len(rp.queue),
+			Sent:       rp.sent,
+			Succeeded:  rp.succeeded,
+			Failed:     rp.failed,
+			Dropped:    rp.dropped,
+			IntervalMs: rp.interval.Milliseconds(),
+			LastError:  rp.lastError,
+			Active:     rp.active,
+		}
+		if rp.sent > 0 {
+			r.SuccessRate = float64(rp.succeeded*10000/rp.sent) / 10000
+		}
+		if rp.nextAt.Sub(now) > rp.interval {
+			r.RetryAt = rp.nextAt.UTC().Format(time.RFC3339)
+		}
+		if !rp.lastErrorAt.IsZero() {
+			r.LastErrorAt = rp.lastErrorAt.UTC().Format(time.RFC3339)
+		}
+		s.Relays = append(s.Relays, r)
+	}
+	sort.Slice(s.Relays, func(i, j int) bool { return s.Relays[i].Relay < s.Relays[j].Relay })
+	return s
+}
+
+// handlePublishStatus serves GET /publish/status.
+func handlePublishStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(publishQueue.Status())
+}
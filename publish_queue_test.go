@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// fastPublishQueue returns a queue with millisecond pacing whose sends go to send.
+func fastPublishQueue(path string, send func(relay string, ev nostr.Event) error) *PublishQueue {
+	q := NewPublishQueue(path)
+	q.startInterval, q.baseBackoff = time.Millisecond, time.Millisecond
+	q.send = func(_ context.Context, relay string, ev nostr.Event) error { return send(relay, ev) }
+	return q
+}
+
+// assertion returns a signed kind 30382 event about subject.
+func assertion(t *testing.T, sk, subject, rank string) nostr.Event {
+	return *signedEvent(t, sk, 30382, time.Now(), nostr.Tag{"d", subject}, nostr.Tag{"rank", rank})
+}
+
+func TestPublishQueuePacesRelaysIndependently(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	var mu sync.Mutex
+	delivered := map[string][]string{}
+	calls := map[string]int{}
+	q := fastPublishQueue("", func(relay string, ev nostr.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[relay]++
+		switch {
+		case relay == "wss://down.example":
+			return errors.New("connection refused")
+		case relay == "wss://flaky.example" && calls[relay] <= 2:
+			return errors.New("rate-limited: slow down")
+		}
+		delivered[relay] = append(delivered[relay], ev.Tags.GetD())
+		return nil
+	})
+
+	a, b := padHex(52001), padHex(52002)
+	q.Enqueue([]nostr.Event{assertion(t, sk, a, "90"), assertion(t, sk, b, "80")}, []string{"wss://ok.example", "wss://flaky.example"})
+	q.Enqueue([]nostr.Event{assertion(t, sk, padHex(52003), "70")}, []string{"wss://down.example"})
+	if s := q.Status(); s.QueueDepth != 3 {
+		t.Fatalf("expected 3 events queued before starting, got %d", s.QueueDepth)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+	q.Wait()
+
+	for _, relay := range []string{"wss://ok.example", "wss://flaky.example"} {
+		if got := delivered[relay]; len(got) != 2 || got[0] != a || got[1] != b {
+			t.Errorf("%s: expected both events in order, got %v", relay, got)
+		}
+	}
+	s := q.Status()
+	if s.QueueDepth != 0 || s.Completed != 3 {
+		t.Errorf("expected the queue drained, got depth %d, completed %d", s.QueueDepth, s.Completed)
+	}
+	byRelay := map[string]RelayPublishStatus{}
+	for _, r := range s.Relays {
+		byRelay[r.Relay] = r
+	}
+	if r := byRelay["wss://flaky.example"]; r.Sent != 4 || r.Failed != 2 || r.SuccessRate != 0.5 || r.LastError == "" {
+		t.Errorf("unexpected flaky relay status %+v", r)
+	}
+	if r := byRelay["wss://down.example"]; r.Failed != publishMaxAttempts || r.Dropped != 1 || r.SuccessRate != 0 {
+		t.Errorf("expected the event dropped after %d attempts, got %+v", publishMaxAttempts, r)
+	}
+	if r := byRelay["wss://ok.example"]; r.SuccessRate != 1 || r.IntervalMs != publishMinInterval.Milliseconds() {
+		t.Errorf("expected the healthy relay at full speed, got %+v", r)
+	}
+}
+
+func TestPublishQueueReplacesQueuedVersion(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	var got []string
+	q := fastPublishQueue("", func(_ string, ev nostr.Event) error {
+		got = append(got, ev.Tags.GetFirst([]string{"rank"}).Value())
+		return nil
+	})
+	subject := padHex(52101)
+	q.Enqueue([]nostr.Event{assertion(t, sk, subject, "10")}, []string{"wss://a.example"})
+	q.Enqueue([]nostr.Event{assertion(t, sk, subject, "20")}, []string{"wss://a.example"})
+	if d := q.Status().QueueDepth; d != 1 {
+		t.Fatalf("expected one queued event per subject, got %d", d)
+	}
+	q.Start(context.Background())
+	q.Wait()
+	if len(got) != 1 || got[0] != "20" {
+		t.Errorf("expected only the newer version sent, got %v", got)
+	}
+}
+
+func TestPublishQueueResumesAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "publish-queue.json")
+	sk := nostr.GeneratePrivateKey()
+	relays := []string{"wss://a.example", "wss://b.example"}
+	q := NewPublishQueue(path)
+	q.Enqueue([]nostr.Event{assertion(t, sk, padHex(52201), "90"), assertion(t, sk, padHex(52202), "80")}, relays)
+	// relay a took the first event before the restart
+	q.mu.Lock()
+	first := q.relays["wss://a.example"].queue[0]
+	q.result("wss://a.example", first, first.Event.ID, nil)
+	q.mu.Unlock()
+	q.save()
+
+	var mu sync.Mutex
+	sent := map[string]int{}
+	resumed := fastPublishQueue(path, func(relay string, _ nostr.Event) error {
+		mu.Lock()
+		sent[relay]++
+		mu.Unlock()
+		return nil
+	})
+	if err := resumed.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if d := resumed.Status().QueueDepth; d != 2 {
+		t.Fatalf("expected 2 undelivered events after reload, got %d", d)
+	}
+	resumed.Start(context.Background())
+	resumed.Wait()
+	if sent["wss://a.example"] != 1 || sent["wss://b.example"] != 2 {
+		t.Errorf("expected each relay to pick up where it left off, got %v", sent)
+	}
+
+	// once drained, the saved queue is empty
+	again := NewPublishQueue(path)
+	if err := again.Load(); err != nil || again.Status().QueueDepth != 0 {
+		t.Errorf("expected nothing left to resume, got %d (%v)", again.Status().QueueDepth, err)
+	}
+}
+
+func TestHandlePublishStatus(t *testing.T) {
+	old := publishQueue
+	defer func() { publishQueue = old }()
+	publishQueue = NewPublishQueue("")
+	publishQueue.Enqueue([]nostr.Event{assertion(t, nostr.GeneratePrivateKey(), padHex(52301), "50")}, []string{"wss://a.example"})
+
+	rr := httptest.NewRecorder()
+	handlePublishStatus(rr, httptest.NewRequest("GET", "/publish/status", nil))
+	var s PublishStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.QueueDepth != 1 || s.TopN != config.Get().PublishTopN || len(s.Relays) != 1 || s.Relays[0].Queued != 1 || s.Relays[0].Active {
+		t.Errorf("unexpected status %+v", s)
+	}
+}
+
+func TestHandlePublishRequiresAdmin(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "")
+	rr := httptest.NewRecorder()
+	handlePublish(rr, httptest.NewRequest("POST", "/publish", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 with the admin API disabled, got %d", rr.Code)
+	}
+
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	rr = httptest.NewRecorder()
+	handlePublish(rr, httptest.NewRequest("POST", "/publish", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without the admin token, got %d", rr.Code)
+	}
+}
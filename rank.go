@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	// rankMaxEvents caps the candidate feed one /rank request can sort.
+	rankMaxEvents = 500
+	// rankDefaultHalfLife is how old an event is when its recency score halves.
+	rankDefaultHalfLife = 24.0 // hours
+)
+
+// RankWeights blends the four 0-100 components of an event's feed score.
+type RankWeights struct {
+	Author     float64 `json:"author"`
+	Engagement float64 `json:"engagement"`
+	Recency    float64 `json:"recency"`
+	Personal   float64 `json:"personal"`
+}
+
+var defaultRankWeights = RankWeights{Author: 0.4, Engagement: 0.3, Recency: 0.2, Personal: 0.1}
+
+// RankItem is one candidate event. A full signed Nostr event decodes into it, and
+// so does {"id", "pubkey"} alone; signatures aren't checked since only the ID,
+// author and timestamp are used.
+type RankItem struct {
+	ID        string `json:"id"`
+	Pubkey    string `json:"pubkey"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// RankedEvent is one event in the /rank response.
+type RankedEvent struct {
+	ID         string           `json:"id"`
+	Pubkey     string           `json:"pubkey"`
+	Score      float64          `json:"score"`
+	Components RankWeights      `json:"components"`
+	Engagement *EventEngagement `json:"engagement,omitempty"`
+	CreatedAt  int64            `json:"created_at,omitempty"`
+}
+
+// engagementComponent puts engagement on 0-100 with 25 points per tenfold,
+// reaching 100 at about 10,000 (reactions + 2×reposts + 3×comments + zapped sats).
+func engagementComponent(e EventEngagement) float64 {
+	eng := float64(e.Reactions) + float64(e.Reposts)*2 + float64(e.Comments)*3 + float64(e.ZapAmount)
+	return math.Min(100, 25*math.Log10(1+eng))
+}
+
+// recencyComponent halves every halfLife hours from 100 at created_at; events
+// dated in the future count as new and unknown timestamps as 0.
+func recencyComponent(createdAt int64, now time.Time, halfLife float64) float64 {
+	if createdAt <= 0 {
+		return 0
+	}
+	age := math.Max(0, now.Sub(time.Unix(createdAt, 0)).Hours())
+	return 100 * math.Pow(0.5, age/halfLife)
+}
+
+// personalComponent is 100 when the viewer follows the author, otherwise
+// 80·k/(k+5) where k of the viewer's follows follow the author.
+func personalComponent(g *Graph, viewerFollows map[string]bool, author string) float64 {
+	if viewerFollows[author] {
+		return 100
+	}
+	k := 0
+	for _, f := range g.GetFollowers(author) {
+		if viewerFollows[f] {
+			k++
+		}
+	}
+	return 80 * float64(k) / float64(k+5)
+}
+
+// handleRank serves POST /rank with {"events": [...], "viewer": "", "weights": {},
+// "half_life_hours": 24}: the candidate feed scored by a blend of author WoT score,
+// crawled engagement, recency and, with a viewer, how close the author is to them,
+// sorted best first. Weights are relative; personal is dropped without a viewer.
+func handleRank(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req struct {
+		Events        []RankItem   `json:"events"`
+		Viewer        string       `json:"viewer"`
+		Weights       *RankWeights `json:"weights"`
+		HalfLifeHours float64      `json:"half_life_hours"`
+	}
+	if apiErr := bindJSON(w, r, &req, 4<<20); apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	if len(req.Events) == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "events", Message: "events array required"})
+		return
+	}
+	if len(req.Events) > rankMaxEvents {
+		writeAPIError(w, invalidParam("events", "max %d events per request", rankMaxEvents))
+		return
+	}
+	halfLife := req.HalfLifeHours
+	if halfLife == 0 {
+		halfLife = rankDefaultHalfLife
+	}
+	if halfLife < 0 || math.IsNaN(halfLife) || math.IsInf(halfLife, 0) {
+		writeAPIError(w, invalidParam("half_life_hours", "half_life_hours must be positive"))
+		return
+	}
+
+	weights := defaultRankWeights
+	if req.Weights != nil {
+		weights = *req.Weights
+	}
+	var viewerFollows map[string]bool
+	viewer := ""
+	if req.Viewer != "" {
+		var err error
+		if viewer, err = resolvePubkey(req.Viewer); err != nil {
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid viewer: %s", err.Error())})
+			return
+		}
+		follows, _ := followsForViewer(g, viewer)
+		viewerFollows = make(map[string]bool, len(follows))
+		for _, f := range follows {
+			viewerFollows[f] = true
+		}
+	} else {
+		weights.Personal = 0
+	}
+	for _, v := range []float64{weights.Author, weights.Engagement, weights.Recency, weights.Personal} {
+		if v < 0 || math.IsNaN(v) {
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "weights must not be negative"})
+			return
+		}
+	}
+	total := weights.Author + weights.Engagement + weights.Recency + weights.Personal
+	if total == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "at least one weight must be positive"})
+		return
+	}
+	weights = RankWeights{
+		Author:     round3(weights.Author / total),
+		Engagement: round3(weights.Engagement / total),
+		Recency:    round3(weights.Recency / total),
+		Personal:   round3(weights.Personal / total),
+	}
+
+	nodes := g.Stats().Nodes
+	now := time.Now()
+	seen := make(map[string]bool, len(req.Events))
+	ranked := make([]RankedEvent, 0, len(req.Events))
+	for i, item := range req.Events {
+		if !hex64Pattern.MatchString(item.ID) {
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("events[%d]: id must be 64 hex characters", i)})
+			return
+		}
+		author, err := resolvePubkey(item.Pubkey)
+		if err != nil || !hex64Pattern.MatchString(author) {
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("events[%d]: pubkey must be 64 hex characters or an npub", i)})
+			return
+		}
+		if seen[item.ID] {
+			continue
+		}
+		seen[item.ID] = true
+
+		ev := RankedEvent{ID: item.ID, Pubkey: author, CreatedAt: item.CreatedAt}
+		raw, _ := g.GetScore(author)
+		ev.Components.Author = float64(normalizeScore(raw, nodes))
+		if e, ok := events.Engagement(item.ID); ok {
+			ev.Engagement = &e
+			ev.Components.Engagement = engagementComponent(e)
+			if ev.CreatedAt == 0 {
+				ev.CreatedAt = e.CreatedAt
+			}
+		}
+		ev.Components.Recency = recencyComponent(ev.CreatedAt, now, halfLife)
+		if viewerFollows != nil {
+			ev.Components.Personal = personalComponent(g, viewerFollows, author)
+		}
+
+		c := ev.Components
+		ev.Score = math.Round((c.Author*weights.Author+c.Engagement*weights.Engagement+c.Recency*weights.Recency+c.Personal*weights.Personal)*10) / 10
+		ev.Components = RankWeights{
+			Author:     math.Round(c.Author*10) / 10,
+			Engagement: math.Round(c.Engagement*10) / 10,
+			Recency:    math.Round(c.Recency*10) / 10,
+			Personal:   math.Round(c.Personal*10) / 10,
+		}
+		ranked = append(ranked, ev)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].CreatedAt > ranked[j].CreatedAt
+	})
+
+	resp := map[string]interface{}{
+		"ranked":          ranked,
+		"count":           len(ranked),
+		"weights":         weights,
+		"half_life_hours": halfLife,
+		"graph_size":      nodes,
+	}
+	if viewer != "" {
+		resp["viewer"] = viewer
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
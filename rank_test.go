@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// buildRankGraph gives star a dozen followers, leaves nobody with none, and has
+// viewer follow friend.
+func buildRankGraph(t *testing.T) (star, nobody, friend, viewer string) {
+	t.Helper()
+	oldGraph, oldEvents := graph, events
+	t.Cleanup(func() { graph, events = oldGraph, oldEvents })
+	graph, events = NewGraph(), NewEventStore()
+	star, nobody, friend, viewer = padHex(32001), padHex(32002), padHex(32003), padHex(32004)
+	for i := 0; i < 12; i++ {
+		graph.AddFollow(padHex(32100+i), star)
+	}
+	graph.AddFollow(star, nobody)
+	graph.AddFollow(viewer, friend)
+	graph.ComputePageRank(20, 0.85)
+	return
+}
+
+func rankID(n int) string {
+	return fmt.Sprintf("%064x", n)
+}
+
+func postRank(t *testing.T, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/rank", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handleRank(w, req)
+	var resp map[string]interface{}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+	}
+	return w, resp
+}
+
+func rankedIDs(resp map[string]interface{}) []string {
+	var ids []string
+	for _, r := range resp["ranked"].([]interface{}) {
+		ids = append(ids, r.(map[string]interface{})["id"].(string))
+	}
+	return ids
+}
+
+func TestRankComponents(t *testing.T) {
+	if got := engagementComponent(EventEngagement{}); got != 0 {
+		t.Errorf("expected no engagement to score 0, got %v", got)
+	}
+	if got := engagementComponent(EventEngagement{Reactions: 9}); math.Abs(got-25) > 1e-9 {
+		t.Errorf("expected 25 points for the first tenfold, got %v", got)
+	}
+	if got := engagementComponent(EventEngagement{ZapAmount: 1_000_000}); got != 100 {
+		t.Errorf("expected engagement to cap at 100, got %v", got)
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	if got := recencyComponent(now.Unix()-24*3600, now, 24); math.Abs(got-50) > 1e-9 {
+		t.Errorf("expected recency to halve after one half-life, got %v", got)
+	}
+	if got := recencyComponent(now.Unix()+3600, now, 24); got != 100 {
+		t.Errorf("expected future events to count as new, got %v", got)
+	}
+	if got := recencyComponent(0, now, 24); got != 0 {
+		t.Errorf("expected an unknown timestamp to score 0, got %v", got)
+	}
+}
+
+func TestRankOrdersFeed(t *testing.T) {
+	star, nobody, _, _ := buildRankGraph(t)
+	now := time.Now().Unix()
+
+	// same age and no engagement: the better-trusted author wins
+	body := fmt.Sprintf(`{"events":[{"id":%q,"pubkey":%q,"created_at":%d},{"id":%q,"pubkey":%q,"created_at":%d}]}`,
+		rankID(1), nobody, now, rankID(2), star, now)
+	w, resp := postRank(t, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ids := rankedIDs(resp); len(ids) != 2 || ids[0] != rankID(2) {
+		t.Errorf("expected the star's event first, got %v", ids)
+	}
+	weights := resp["weights"].(map[string]interface{})
+	if weights["personal"].(float64) != 0 || math.Abs(weights["author"].(float64)-0.444) > 1e-9 {
+		t.Errorf("expected personal dropped and the rest renormalized, got %v", weights)
+	}
+
+	// engagement alone: the crawled event wins even from an unknown author
+	m := events.GetEvent(rankID(1))
+	m.Reactions, m.ZapAmount, m.CreatedAt = 50, 5000, now
+	body = fmt.Sprintf(`{"events":[{"id":%q,"pubkey":%q},{"id":%q,"pubkey":%q}],"weights":{"engagement":1}}`,
+		rankID(2), star, rankID(1), nobody)
+	_, resp = postRank(t, body)
+	ranked := resp["ranked"].([]interface{})
+	first := ranked[0].(map[string]interface{})
+	if first["id"] != rankID(1) || first["engagement"].(map[string]interface{})["zap_sats"].(float64) != 5000 {
+		t.Errorf("expected the zapped event first with its engagement, got %v", first)
+	}
+	// the crawled timestamp fills in for a missing created_at
+	if first["created_at"].(float64) != float64(now) {
+		t.Errorf("expected created_at from the crawl, got %v", first["created_at"])
+	}
+
+	// recency alone: newer first, and duplicates are ranked once
+	body = fmt.Sprintf(`{"events":[{"id":%q,"pubkey":%q,"created_at":%d},{"id":%q,"pubkey":%q,"created_at":%d},{"id":%q,"pubkey":%q,"created_at":%d}],"weights":{"recency":1},"half_life_hours":1}`,
+		rankID(3), star, now-7200, rankID(4), nobody, now, rankID(3), star, now-7200)
+	_, resp = postRank(t, body)
+	if ids := rankedIDs(resp); len(ids) != 2 || ids[0] != rankID(4) {
+		t.Errorf("expected the newer event first and one duplicate dropped, got %v", ids)
+	}
+	older := resp["ranked"].([]interface{})[1].(map[string]interface{})
+	if got := older["components"].(map[string]interface{})["recency"].(float64); got != 25 {
+		t.Errorf("expected two half-lives to leave 25, got %v", got)
+	}
+}
+
+func TestRankPersonalized(t *testing.T) {
+	_, nobody, friend, viewer := buildRankGraph(t)
+	body := fmt.Sprintf(`{"viewer":%q,"events":[{"id":%q,"pubkey":%q},{"id":%q,"pubkey":%q}],"weights":{"personal":1}}`,
+		viewer, rankID(1), nobody, rankID(2), friend)
+	w, resp := postRank(t, body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if resp["viewer"] != viewer {
+		t.Errorf("expected viewer echoed, got %v", resp["viewer"])
+	}
+	first := resp["ranked"].([]interface{})[0].(map[string]interface{})
+	if first["id"] != rankID(2) || first["score"].(float64) != 100 {
+		t.Errorf("expected the followed author's event first at 100, got %v", first)
+	}
+}
+
+func TestRankErrors(t *testing.T) {
+	star, _, _, _ := buildRankGraph(t)
+	ev := fmt.Sprintf(`{"id":%q,"pubkey":%q}`, rankID(1), star)
+	many := strings.TrimSuffix(strings.Repeat(ev+",", rankMaxEvents+1), ",")
+
+	cases := map[string]string{
+		"invalid JSON": `{`,
+		"no events":    `{"events":[]}`,
+		"too many":     `{"events":[` + many + `]}`,
+		"bad id":       fmt.Sprintf(`{"events":[{"id":"abc","pubkey":%q}]}`, star),
+		"bad pubkey":   fmt.Sprintf(`{"events":[{"id":%q,"pubkey":"npub1bad"}]}`, rankID(1)),
+		"bad viewer":   `{"viewer":"npub1bad","events":[` + ev + `]}`,
+		"negative":     `{"events":[` + ev + `],"weights":{"author":-1,"recency":1}}`,
+		"all zero":     `{"events":[` + ev + `],"weights":{"personal":1}}`,
+		"half-life":    `{"events":[` + ev + `],"half_life_hours":-1}`,
+	}
+	for name, body := range cases {
+		if w, _ := postRank(t, body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handleRank(w, httptest.NewRequest(http.MethodGet, "/rank", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", w.Code)
+	}
+}
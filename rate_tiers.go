@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// nostrAuthKind is the NIP-42 client authentication event kind.
+	nostrAuthKind = 22242
+	// nostrAuthMaxAge is how long one signed auth event can be reused, so clients
+	// don't have to sign every request.
+	nostrAuthMaxAge = 10 * time.Minute
+)
+
+// RateTier describes one rate limit tier for /pricing.
+type RateTier struct {
+	Name      string `json:"name"`
+	PerMinute int    `json:"per_minute"`
+	Key       string `json:"key"` // what the limit is counted per: "ip" or "pubkey"
+	MinScore  int    `json:"min_score,omitempty"`
+}
+
+// RateTiers holds the per-tier limiters. Anonymous requests are limited per IP.
+// Requests carrying a valid NIP-42 auth event are limited per pubkey instead: at the
+// authenticated limit, or the trusted limit when the pubkey's score is at least
+// TrustedMinScore. A nil Authenticated limiter disables authentication.
+//
+// Endpoints adds limits for single paths, counted per IP or pubkey like the tier
+// and on top of it. Backend names where the counts are kept: "memory" or "redis".
+type RateTiers struct {
+	Anonymous       *RateLimiter
+	Authenticated   *RateLimiter
+	Trusted         *RateLimiter
+	TrustedMinScore int
+	Endpoints       map[string]*RateLimiter
+	Backend         string
+}
+
+// NewRateTiersFromEnv builds the tiers from RATE_LIMIT_ANONYMOUS (default 100),
+// RATE_LIMIT_AUTHENTICATED (300), RATE_LIMIT_TRUSTED (1000) requests per minute and
+// RATE_LIMIT_TRUSTED_MIN_SCORE (50), and per-endpoint limits from
+// RATE_LIMIT_ENDPOINTS, such as "/batch=20,/export=5" requests per minute.
+// RATE_LIMIT_BACKEND=redis keeps the counts in the Redis server at REDIS_URL so
+// replicas share them; if it can't be reached at startup, counts stay in memory.
+func NewRateTiersFromEnv() *RateTiers {
+	envInt := func(name string, def int) int {
+		if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+			return n
+		}
+		return def
+	}
+	var store RateLimitStore
+	backend := "memory"
+	if os.Getenv("RATE_LIMIT_BACKEND") == "redis" {
+		redis, err := NewRedisRateLimitStore(os.Getenv("REDIS_URL"))
+		if err != nil {
+			log.Printf("Rate limit Redis backend unavailable, counting in memory: %v", err)
+		} else {
+			store, backend = redis, "redis"
+		}
+	}
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+	endpoints, err := parseEndpointLimits(os.Getenv("RATE_LIMIT_ENDPOINTS"), store)
+	if err != nil {
+		log.Printf("Ignoring RATE_LIMIT_ENDPOINTS: %v", err)
+	}
+	return &RateTiers{
+		Anonymous:       NewRateLimiterWithStore("anonymous", envInt("RATE_LIMIT_ANONYMOUS", 100), time.Minute, store),
+		Authenticated:   NewRateLimiterWithStore("authenticated", envInt("RATE_LIMIT_AUTHENTICATED", 300), time.Minute, store),
+		Trusted:         NewRateLimiterWithStore("trusted", envInt("RATE_LIMIT_TRUSTED", 1000), time.Minute, store),
+		TrustedMinScore: envInt("RATE_LIMIT_TRUSTED_MIN_SCORE", 50),
+		Endpoints:       endpoints,
+		Backend:         backend,
+	}
+}
+
+// parseEndpointLimits parses "path=limit,..." into per-minute limiters on store.
+func parseEndpointLimits(s string, store RateLimitStore) (map[string]*RateLimiter, error) {
+	limits := make(map[string]*RateLimiter)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		path, limit, ok := strings.Cut(part, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(limit))
+		path = strings.TrimSpace(path)
+		if !ok || err != nil || n <= 0 || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid endpoint limit %q, want /path=requests_per_minute", part)
+		}
+		limits[path] = NewRateLimiterWithStore("endpoint"+path, n, time.Minute, store)
+	}
+	return limits, nil
+}
+
+var rateTiers = NewRateTiersFromEnv()
+
+// Tiers lists the configured tiers.
+func (t *RateTiers) Tiers() []RateTier {
+	tiers := []RateTier{{Name: "anonymous", PerMinute: t.Anonymous.limit, Key: "ip"}}
+	if t.Authenticated != nil {
+		tiers = append(tiers, RateTier{Name: "authenticated", PerMinute: t.Authenticated.limit, Key: "pubkey"})
+	}
+	if t.Trusted != nil {
+		tiers = append(tiers, RateTier{Name: "trusted", PerMinute: t.Trusted.limit, Key: "pubkey", MinScore: t.TrustedMinScore})
+	}
+	return tiers
+}
+
+// EndpointLimit describes a per-endpoint limit for /pricing.
+type EndpointLimit struct {
+	Path      string `json:"path"`
+	PerMinute int    `json:"per_minute"`
+}
+
+// EndpointLimits lists the per-endpoint limits by path.
+func (t *RateTiers) EndpointLimits() []EndpointLimit {
+	limits := make([]EndpointLimit, 0, len(t.Endpoints))
+	for path, limiter := range t.Endpoints {
+		limits = append(limits, EndpointLimit{Path: path, PerMinute: limiter.limit})
+	}
+	sort.Slice(limits, func(i, j int) bool { return limits[i].Path < limits[j].Path })
+	return limits
+}
+
+// forPubkey picks the tier for an authenticated pubkey.
+func (t *RateTiers) forPubkey(pubkey string) (string, *RateLimiter) {
+	if t.Trusted != nil {
+		if raw, ok := graph.GetScore(pubkey); ok && normalizeScore(raw, graph.Stats().Nodes) >= t.TrustedMinScore {
+			return "trusted", t.Trusted
+		}
+	}
+	return "authenticated", t.Authenticated
+}
+
+// nostrAuthCache remembers auth events whose signatures have been checked, keyed by
+// event id, so a client reusing one event isn't re-verified on every request.
+var nostrAuthCache = struct {
+	sync.Mutex
+	expires map[string]time.Time
+}{expires: make(map[string]time.Time)}
+
+// verifyNostrAuth checks for a NIP-42-style auth event in the Authorization header:
+// "Nostr <base64 kind 22242 event>" whose relay tag names this service's host,
+// signed within the last 10 minutes. The challenge tag is not required, since the
+// relay tag and the age limit already bind the event to us. Headers carrying other
+// kinds, such as NIP-98 events for /ingest or /challenge, are left to their
+// handlers and reported as not present.
+func verifyNostrAuth(r *http.Request) (pubkey string, present bool, err error) {
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	if !strings.HasPrefix(auth, "Nostr ") {
+		return "", false, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(auth, "Nostr ")))
+	if err != nil {
+		return "", false, nil
+	}
+	var ev nostr.Event
+	if err := json.Unmarshal(raw, &ev); err != nil || ev.Kind != nostrAuthKind {
+		return "", false, nil
+	}
+
+	age := time.Since(ev.CreatedAt.Time())
+	if age > nostrAuthMaxAge || age < -nip98MaxSkew {
+		return "", true, fmt.Errorf("auth event expired or not yet valid")
+	}
+	relay := ev.Tags.Find("relay")
+	if relay == nil || !nostrAuthRelayMatches(relay[1], r) {
+		return "", true, fmt.Errorf("auth event relay tag does not match this service")
+	}
+	if !ev.CheckID() {
+		return "", true, fmt.Errorf("auth event id mismatch")
+	}
+
+	nostrAuthCache.Lock()
+	_, verified := nostrAuthCache.expires[ev.ID]
+	nostrAuthCache.Unlock()
+	if !verified {
+		if ok, err := ev.CheckSignature(); err != nil || !ok {
+			return "", true, fmt.Errorf("invalid auth event signature")
+		}
+		now := time.Now()
+		nostrAuthCache.Lock()
+		for id, exp := range nostrAuthCache.expires {
+			if now.After(exp) {
+				delete(nostrAuthCache.expires, id)
+			}
+		}
+		nostrAuthCache.expires[ev.ID] = ev.CreatedAt.Time().Add(nostrAuthMaxAge)
+		nostrAuthCache.Unlock()
+	}
+	return ev.PubKey, true, nil
+}
+
+// nostrAuthRelayMatches compares the relay tag's host with the request's. Like NIP-98,
+// the scheme is ignored; any path is accepted so clients can use the base URL.
+func nostrAuthRelayMatches(relay string, r *http.Request) bool {
+	u, err := url.Parse(relay)
+	if err != nil {
+		return false
+	}
+	host := r.Host
+	if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+		host = fwd
+	}
+	return strings.EqualFold(u.Host, host)
+}
+
+// TieredRateLimitMiddleware is RateLimitMiddleware with authentication: a request
+// with a valid auth event is counted against its pubkey's tier instead of its IP. A
+// request with an invalid one is rejected rather than silently downgraded, so
+// clients notice broken signing. The tier is reported in X-RateLimit-Tier, and the
+// X-RateLimit-Limit, -Remaining and -Reset headers describe whichever of the tier
+// and endpoint limits is closer to running out.
+func TieredRateLimitMiddleware(tiers *RateTiers, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Skip rate limiting for landing page and health check
+		if r.URL.Path == "/" || r.URL.Path == "/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tier, limiter := "anonymous", tiers.Anonymous
+		key := r.RemoteAddr
+		// Use X-Forwarded-For if behind a reverse proxy
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			key = xff
+		}
+		if tiers.Authenticated != nil {
+			pubkey, present, err := verifyNostrAuth(r)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+				return
+			}
+			if present {
+				tier, limiter = tiers.forPubkey(pubkey)
+				key = pubkey
+			}
+		}
+
+		res := limiter.Take(key)
+		endpoint := ""
+		if el, ok := tiers.Endpoints[r.URL.Path]; ok && res.Allowed {
+			if er := el.Take(key); !er.Allowed || er.Remaining < res.Remaining {
+				res, endpoint = er, r.URL.Path
+			}
+		}
+
+		w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", res.Limit))
+		w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", res.Remaining))
+		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", res.Reset.Unix()))
+		w.Header().Set("X-RateLimit-Tier", tier)
+
+		if !res.Allowed {
+			retryAfter := int(time.Until(res.Reset).Seconds()) + 1
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			body := map[string]interface{}{
+				"error":       "rate limit exceeded",
+				"retry_after": retryAfter,
+				"limit":       res.Limit,
+				"tier":        tier,
+			}
+			if endpoint != "" {
+				body["endpoint"] = endpoint
+			}
+			json.NewEncoder(w).Encode(body)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
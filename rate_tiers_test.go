@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func nostrAuthHeader(t *testing.T, sk, relay string, createdAt time.Time) string {
+	t.Helper()
+	ev := nostr.Event{
+		Kind:      nostrAuthKind,
+		CreatedAt: nostr.Timestamp(createdAt.Unix()),
+		Tags:      nostr.Tags{{"relay", relay}, {"challenge", "unused"}},
+	}
+	if err := ev.Sign(sk); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	raw, _ := json.Marshal(ev)
+	return "Nostr " + base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestVerifyNostrAuth(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	now := time.Now()
+
+	req := func(auth string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://wot.example/score", nil)
+		r.Header.Set("Authorization", auth)
+		return r
+	}
+
+	got, present, err := verifyNostrAuth(req(nostrAuthHeader(t, sk, "wss://wot.example", now.Add(-5*time.Minute))))
+	if err != nil || !present || got != pub {
+		t.Fatalf("expected %s, got %q present=%v err=%v", pub, got, present, err)
+	}
+
+	for name, auth := range map[string]string{
+		"expired":     nostrAuthHeader(t, sk, "wss://wot.example", now.Add(-11*time.Minute)),
+		"future":      nostrAuthHeader(t, sk, "wss://wot.example", now.Add(5*time.Minute)),
+		"other relay": nostrAuthHeader(t, sk, "wss://relay.damus.io", now),
+	} {
+		if _, present, err := verifyNostrAuth(req(auth)); !present || err == nil {
+			t.Errorf("%s: expected an error, got present=%v err=%v", name, present, err)
+		}
+	}
+
+	// a tampered signature is caught even though the id still matches
+	var ev nostr.Event
+	raw, _ := base64.StdEncoding.DecodeString(nostrAuthHeader(t, sk, "wss://wot.example", now)[len("Nostr "):])
+	json.Unmarshal(raw, &ev)
+	ev.Sig = ev.Sig[:len(ev.Sig)-2] + "00"
+	raw, _ = json.Marshal(ev)
+	if _, _, err := verifyNostrAuth(req("Nostr " + base64.StdEncoding.EncodeToString(raw))); err == nil {
+		t.Error("expected a bad signature rejected")
+	}
+
+	// NIP-98 and other schemes are left to the handlers
+	for _, auth := range []string{"", "Bearer admin", nip98Header(t, sk, "GET", "http://wot.example/score", nil, now)} {
+		if _, present, err := verifyNostrAuth(req(auth)); present || err != nil {
+			t.Errorf("expected %q ignored, got present=%v err=%v", auth, present, err)
+		}
+	}
+}
+
+func TestTieredRateLimitMiddleware(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+
+	trustedSK, plainSK := nostr.GeneratePrivateKey(), nostr.GeneratePrivateKey()
+	trustedPub, _ := nostr.GetPublicKey(trustedSK)
+	for i := 0; i < 10; i++ {
+		graph.AddFollow(padHex(37000+i), trustedPub)
+	}
+	graph.ComputePageRank(20, 0.85)
+
+	tiers := &RateTiers{
+		Anonymous:       NewRateLimiter(1, time.Minute),
+		Authenticated:   NewRateLimiter(2, time.Minute),
+		Trusted:         NewRateLimiter(3, time.Minute),
+		TrustedMinScore: 10,
+	}
+	handler := TieredRateLimitMiddleware(tiers, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	now := time.Now()
+	send := func(auth string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "http://wot.example/score", nil)
+		r.RemoteAddr = "1.2.3.4:1234"
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+		return rr
+	}
+	allowed := func(auth string) (n int, tier string) {
+		for i := 0; i < 5; i++ {
+			rr := send(auth)
+			tier = rr.Header().Get("X-RateLimit-Tier")
+			if rr.Code != http.StatusOK {
+				break
+			}
+			n++
+		}
+		return n, tier
+	}
+
+	// each tier gets its own budget, counted per pubkey, even from the same IP
+	if n, tier := allowed(""); n != 1 || tier != "anonymous" {
+		t.Errorf("expected 1 anonymous request, got %d (%s)", n, tier)
+	}
+	if n, tier := allowed(nostrAuthHeader(t, plainSK, "https://wot.example", now)); n != 2 || tier != "authenticated" {
+		t.Errorf("expected 2 authenticated requests, got %d (%s)", n, tier)
+	}
+	if n, tier := allowed(nostrAuthHeader(t, trustedSK, "wss://wot.example", now)); n != 3 || tier != "trusted" {
+		t.Errorf("expected 3 trusted requests, got %d (%s)", n, tier)
+	}
+
+	rr := send(nostrAuthHeader(t, plainSK, "wss://wot.example", now.Add(-time.Hour)))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an expired auth event, got %d", rr.Code)
+	}
+
+	// without an authenticated tier, auth headers are ignored
+	anonOnly := RateLimitMiddleware(NewRateLimiter(5, time.Minute), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest(http.MethodGet, "http://wot.example/score", nil)
+	r.Header.Set("Authorization", nostrAuthHeader(t, plainSK, "wss://wot.example", now.Add(-time.Hour)))
+	rr = httptest.NewRecorder()
+	anonOnly.ServeHTTP(rr, r)
+	if rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Tier") != "anonymous" {
+		t.Errorf("expected the anonymous tier, got %d %q", rr.Code, rr.Header().Get("X-RateLimit-Tier"))
+	}
+}
+
+func TestNewRateTiersFromEnv(t *testing.T) {
+	t.Setenv("RATE_LIMIT_AUTHENTICATED", "600")
+	t.Setenv("RATE_LIMIT_TRUSTED_MIN_SCORE", "70")
+	t.Setenv("RATE_LIMIT_TRUSTED", "-5")
+	tiers := NewRateTiersFromEnv().Tiers()
+	if len(tiers) != 3 || tiers[0].PerMinute != 100 || tiers[1].PerMinute != 600 || tiers[2].PerMinute != 1000 || tiers[2].MinScore != 70 {
+		t.Errorf("unexpected tiers %+v", tiers)
+	}
+}
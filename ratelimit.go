@@ -1,121 +1,184 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
+	"log"
+	"math"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// RateLimiter provides per-IP request rate limiting using a sliding window.
+// RateLimitStore holds the request counts behind a RateLimiter: in this process
+// (MemoryRateLimitStore), or in Redis so every replica shares them
+// (RedisRateLimitStore). Counts are kept per key and fixed window.
+type RateLimitStore interface {
+	// Incr counts one request for key in the window starting at start, and
+	// returns the count of that window and of the one before it.
+	Incr(key string, start time.Time, interval time.Duration) (cur, prev int, err error)
+	// Decr takes back a request Incr counted but the limiter turned away, so
+	// clients that keep retrying aren't locked out for good.
+	Decr(key string, start time.Time) error
+}
+
+// RateLimiter limits requests per key (an IP or a pubkey) with a sliding window:
+// the count of the current fixed window plus the previous window's count,
+// weighted by how much of it still overlaps the last interval. This avoids the
+// burst of twice the limit a fixed window allows around its reset.
 type RateLimiter struct {
-	mu       sync.Mutex
-	windows  map[string]*window
+	name     string
 	limit    int
 	interval time.Duration
+	store    RateLimitStore
+	errors   atomic.Int64
+	lastLog  atomic.Int64 // unix seconds of the last logged store error
 }
 
-type window struct {
-	count   int
-	resetAt time.Time
+// RateLimitResult is the outcome of one request against a RateLimiter.
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is when the current window ends, or for a rejected request, when
+	// the next one will be allowed.
+	Reset time.Time
 }
 
-// NewRateLimiter creates a rate limiter allowing limit requests per interval per IP.
+// NewRateLimiter creates an in-memory rate limiter allowing limit requests per
+// interval per IP.
 func NewRateLimiter(limit int, interval time.Duration) *RateLimiter {
-	rl := &RateLimiter{
-		windows:  make(map[string]*window),
-		limit:    limit,
-		interval: interval,
-	}
-	// Cleanup expired entries every minute
-	go func() {
-		ticker := time.NewTicker(time.Minute)
-		defer ticker.Stop()
-		for range ticker.C {
-			rl.cleanup()
-		}
-	}()
-	return rl
+	return NewRateLimiterWithStore("", limit, interval, NewMemoryRateLimitStore())
+}
+
+// NewRateLimiterWithStore creates a rate limiter keeping its counts in store.
+// Limiters sharing a store need distinct names, which prefix their keys.
+func NewRateLimiterWithStore(name string, limit int, interval time.Duration, store RateLimitStore) *RateLimiter {
+	return &RateLimiter{name: name, limit: limit, interval: interval, store: store}
 }
 
 // Allow checks if a request from ip is allowed. Returns remaining requests and whether allowed.
 func (rl *RateLimiter) Allow(ip string) (remaining int, allowed bool) {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+	res := rl.Take(ip)
+	return res.Remaining, res.Allowed
+}
 
+// Take counts a request for key and reports whether it is allowed. When the
+// store fails the request is allowed: an unreachable Redis shouldn't take the
+// API down with it.
+func (rl *RateLimiter) Take(key string) RateLimitResult {
 	now := time.Now()
-	w, ok := rl.windows[ip]
-	if !ok || now.After(w.resetAt) {
-		rl.windows[ip] = &window{count: 1, resetAt: now.Add(rl.interval)}
-		return rl.limit - 1, true
+	start := now.Truncate(rl.interval)
+	end := start.Add(rl.interval)
+	storeKey := rl.name + ":" + key
+
+	cur, prev, err := rl.store.Incr(storeKey, start, rl.interval)
+	if err != nil {
+		rl.storeError(err)
+		return RateLimitResult{Allowed: true, Limit: rl.limit, Remaining: rl.limit, Reset: end}
 	}
 
-	if w.count >= rl.limit {
-		return 0, false
+	weight := 1 - float64(now.Sub(start))/float64(rl.interval)
+	used := int(float64(prev)*weight) + cur
+	if used <= rl.limit {
+		return RateLimitResult{Allowed: true, Limit: rl.limit, Remaining: rl.limit - used, Reset: end}
 	}
 
-	w.count++
-	return rl.limit - w.count, true
+	if err := rl.store.Decr(storeKey, start); err != nil {
+		rl.storeError(err)
+	}
+	return RateLimitResult{Limit: rl.limit, Reset: rl.retryAt(start, cur-1, prev)}
 }
 
-// ResetTime returns when the current window resets for the given IP.
-func (rl *RateLimiter) ResetTime(ip string) time.Time {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	if w, ok := rl.windows[ip]; ok {
-		return w.resetAt
+// retryAt returns when a request would next be allowed, given cur requests
+// counted in the window starting at start and prev in the window before.
+func (rl *RateLimiter) retryAt(start time.Time, cur, prev int) time.Time {
+	// A request is allowed once prev*weight+cur < limit. When this window alone
+	// is over the limit, that happens in the next one, as cur decays in turn.
+	if cur >= rl.limit {
+		start, cur, prev = start.Add(rl.interval), 0, cur
 	}
-	return time.Now()
+	if prev == 0 {
+		return start
+	}
+	frac := 1 - float64(rl.limit-cur)/float64(prev)
+	return start.Add(time.Duration(math.Ceil(frac * float64(rl.interval))))
 }
 
-func (rl *RateLimiter) cleanup() {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-	now := time.Now()
-	for ip, w := range rl.windows {
-		if now.After(w.resetAt) {
-			delete(rl.windows, ip)
-		}
+func (rl *RateLimiter) storeError(err error) {
+	rl.errors.Add(1)
+	now := time.Now().Unix()
+	if last := rl.lastLog.Load(); now-last >= 60 && rl.lastLog.CompareAndSwap(last, now) {
+		log.Printf("Rate limit store error (allowing requests): %v", err)
 	}
 }
 
-// RateLimitMiddleware wraps an http.Handler with rate limiting.
-// Skips rate limiting for the root path and /health.
-func RateLimitMiddleware(limiter *RateLimiter, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip rate limiting for landing page and health check
-		if r.URL.Path == "/" || r.URL.Path == "/health" {
-			next.ServeHTTP(w, r)
-			return
-		}
+// MemoryRateLimitStore keeps counts in this process. They reset on restart and
+// aren't shared between replicas.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	windows map[string]*window
+}
 
-		ip := r.RemoteAddr
-		// Use X-Forwarded-For if behind a reverse proxy
-		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-			ip = xff
+type window struct {
+	start    time.Time
+	interval time.Duration
+	cur      int
+	prev     int
+}
+
+// NewMemoryRateLimitStore creates an in-memory store, dropping idle keys every minute.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{windows: make(map[string]*window)}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.cleanup()
 		}
+	}()
+	return s
+}
 
-		remaining, allowed := limiter.Allow(ip)
-		resetAt := limiter.ResetTime(ip)
-
-		w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", limiter.limit))
-		w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
-		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetAt.Unix()))
-
-		if !allowed {
-			retryAfter := int(time.Until(resetAt).Seconds()) + 1
-			w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter))
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusTooManyRequests)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error":       "rate limit exceeded",
-				"retry_after": retryAfter,
-				"limit":       limiter.limit,
-			})
-			return
+func (s *MemoryRateLimitStore) Incr(key string, start time.Time, interval time.Duration) (int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.windows[key]
+	switch {
+	case !ok:
+		w = &window{start: start, interval: interval}
+		s.windows[key] = w
+	case w.start.Equal(start):
+	case w.start.Add(interval).Equal(start):
+		w.start, w.prev, w.cur = start, w.cur, 0
+	default:
+		w.start, w.prev, w.cur = start, 0, 0
+	}
+	w.cur++
+	return w.cur, w.prev, nil
+}
+
+func (s *MemoryRateLimitStore) Decr(key string, start time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w, ok := s.windows[key]; ok && w.start.Equal(start) && w.cur > 0 {
+		w.cur--
+	}
+	return nil
+}
+
+func (s *MemoryRateLimitStore) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, w := range s.windows {
+		if now.After(w.start.Add(2 * w.interval)) {
+			delete(s.windows, key)
 		}
+	}
+}
 
-		next.ServeHTTP(w, r)
-	})
+// RateLimitMiddleware wraps an http.Handler with per-IP rate limiting.
+// Skips rate limiting for the root path and /health.
+func RateLimitMiddleware(limiter *RateLimiter, next http.Handler) http.Handler {
+	return TieredRateLimitMiddleware(&RateTiers{Anonymous: limiter}, next)
 }
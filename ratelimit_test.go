@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -154,3 +155,100 @@ func TestRateLimitMiddlewareXForwardedFor(t *testing.T) {
 		t.Fatalf("expected 429, got %d", rec.Code)
 	}
 }
+
+func TestRateLimiterSlidingWindow(t *testing.T) {
+	rl := NewRateLimiter(10, time.Minute)
+	start := time.Now().Truncate(time.Minute)
+
+	store := rl.store.(*MemoryRateLimitStore)
+	store.windows[":k"] = &window{start: start.Add(-time.Minute), interval: time.Minute, cur: 10}
+	cur, prev, _ := store.Incr(":k", start, time.Minute)
+	if cur != 1 || prev != 10 {
+		t.Fatalf("expected the window to roll over, got cur=%d prev=%d", cur, prev)
+	}
+	store.Decr(":k", start)
+
+	// with 3 requests in this window, the previous window's 10 must weigh
+	// less than 7, which they do 30% of the way in
+	if got := rl.retryAt(start, 3, 10).Sub(start); got < 18*time.Second || got > 18*time.Second+time.Millisecond {
+		t.Errorf("expected a retry 18s in, got %v", got)
+	}
+	if got := rl.retryAt(start, 10, 0); !got.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected a retry at the next window, got %v", got.Sub(start))
+	}
+	if got := rl.retryAt(start, 20, 4).Sub(start); got < 90*time.Second || got > 90*time.Second+time.Millisecond {
+		t.Errorf("expected a retry once this window's 20 weigh less than 10, got %v", got)
+	}
+}
+
+func TestRateLimiterRejectedRequestsDontCount(t *testing.T) {
+	rl := NewRateLimiter(2, time.Minute)
+	for i := 0; i < 10; i++ {
+		rl.Allow("1.2.3.4")
+	}
+	w := rl.store.(*MemoryRateLimitStore).windows[":1.2.3.4"]
+	if w.cur != 2 {
+		t.Errorf("expected only the 2 allowed requests counted, got %d", w.cur)
+	}
+	res := rl.Take("1.2.3.4")
+	if res.Allowed || res.Remaining != 0 || !res.Reset.After(time.Now()) {
+		t.Errorf("expected a rejection with a future reset, got %+v", res)
+	}
+}
+
+// failingStore is a RateLimitStore whose backend is down.
+type failingStore struct{}
+
+func (failingStore) Incr(string, time.Time, time.Duration) (int, int, error) {
+	return 0, 0, errors.New("connection refused")
+}
+func (failingStore) Decr(string, time.Time) error { return errors.New("connection refused") }
+
+func TestRateLimiterFailsOpen(t *testing.T) {
+	rl := NewRateLimiterWithStore("x", 1, time.Minute, failingStore{})
+	for i := 0; i < 3; i++ {
+		if _, allowed := rl.Allow("1.2.3.4"); !allowed {
+			t.Fatal("expected requests allowed while the store is down")
+		}
+	}
+	if rl.errors.Load() != 3 {
+		t.Errorf("expected 3 store errors counted, got %d", rl.errors.Load())
+	}
+}
+
+func TestEndpointRateLimits(t *testing.T) {
+	endpoints, err := parseEndpointLimits("/batch=1, /export=5", NewMemoryRateLimitStore())
+	if err != nil || len(endpoints) != 2 || endpoints["/export"].limit != 5 {
+		t.Fatalf("unexpected endpoint limits %v, %v", endpoints, err)
+	}
+	for _, bad := range []string{"/batch", "batch=1", "/batch=0", "/batch=x"} {
+		if _, err := parseEndpointLimits(bad, NewMemoryRateLimitStore()); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+
+	tiers := &RateTiers{Anonymous: NewRateLimiter(10, time.Minute), Endpoints: endpoints}
+	handler := TieredRateLimitMiddleware(tiers, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "7.7.7.7:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do("/batch")
+	if rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "1" || rec.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("expected the tighter endpoint limit in the headers, got %d %v", rec.Code, rec.Header())
+	}
+	rec = do("/batch")
+	var body map[string]interface{}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if rec.Code != http.StatusTooManyRequests || body["endpoint"] != "/batch" {
+		t.Errorf("expected the endpoint limit to reject, got %d %v", rec.Code, body)
+	}
+	rec = do("/score")
+	if rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Limit") != "10" || rec.Header().Get("X-RateLimit-Remaining") != "7" {
+		t.Errorf("expected other paths on the tier limit, got %d %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sync"
+)
+
+const (
+	// reach2ExactLimit is the 2-hop edge count up to which /reach2 counts exactly.
+	reach2ExactLimit = 5000
+	// reach2SketchMinFollowers is the follower count from which a first-hop follower's
+	// own audience is cached as a sketch; smaller audiences are cheaper to scan.
+	reach2SketchMinFollowers = 64
+)
+
+// audienceSketches caches follower sketches for accounts with large audiences. The
+// cache is dropped whenever the graph or its build changes.
+type audienceSketches struct {
+	mu       sync.Mutex
+	graph    *Graph
+	build    uint64
+	sketches map[string]*hyperLogLog
+}
+
+var reachSketches = &audienceSketches{}
+
+// followerSketch returns a sketch of pubkey's followers, building and caching it on
+// first use within the current graph build.
+func (a *audienceSketches) followerSketch(pubkey string, followers []string) *hyperLogLog {
+	id, _, _ := graphBuild.Current()
+	a.mu.Lock()
+	if a.graph != graph || a.build != id || a.sketches == nil {
+		a.graph, a.build = graph, id
+		a.sketches = make(map[string]*hyperLogLog)
+	}
+	if h, ok := a.sketches[pubkey]; ok {
+		a.mu.Unlock()
+		return h
+	}
+	a.mu.Unlock()
+
+	h := &hyperLogLog{}
+	for _, f := range followers {
+		h.Add(f)
+	}
+	a.mu.Lock()
+	a.sketches[pubkey] = h
+	a.mu.Unlock()
+	return h
+}
+
+// Reach2Response is the response for GET /reach2.
+type Reach2Response struct {
+	Pubkey         string  `json:"pubkey"`
+	Followers      int     `json:"followers"`
+	Reach2Estimate int     `json:"reach2_estimate"`
+	SecondHopOnly  int     `json:"second_hop_only"`
+	Method         string  `json:"method"` // exact, hyperloglog
+	RelativeError  float64 `json:"relative_error"`
+	EdgesScanned   int     `json:"edges_scanned"`
+	GraphSize      int     `json:"graph_size"`
+}
+
+// computeReach2 estimates the unique accounts within two follower hops of pubkey
+// (followers plus followers-of-followers, excluding pubkey). Small audiences are
+// counted exactly; larger ones are unioned as HyperLogLog sketches so the 2-hop set
+// is never materialized.
+func computeReach2(pubkey string) Reach2Response {
+	followers := graph.GetFollowers(pubkey)
+	resp := Reach2Response{Pubkey: pubkey, Followers: len(followers)}
+
+	edges := 0
+	for _, f := range followers {
+		edges += len(graph.GetFollowers(f))
+	}
+
+	if edges <= reach2ExactLimit {
+		seen := make(map[string]bool, len(followers)+edges)
+		for _, f := range followers {
+			seen[f] = true
+			for _, ff := range graph.GetFollowers(f) {
+				seen[ff] = true
+			}
+		}
+		delete(seen, pubkey)
+		resp.Reach2Estimate = len(seen)
+		resp.Method = "exact"
+		resp.EdgesScanned = edges
+	} else {
+		// pubkey is in the union exactly when it follows one of its own followers
+		follows := make(map[string]bool)
+		for _, f := range graph.GetFollows(pubkey) {
+			follows[f] = true
+		}
+		selfIncluded := false
+
+		h := &hyperLogLog{}
+		for _, f := range followers {
+			h.Add(f)
+			if follows[f] {
+				selfIncluded = true
+			}
+			ffs := graph.GetFollowers(f)
+			if len(ffs) >= reach2SketchMinFollowers {
+				h.Merge(reachSketches.followerSketch(f, ffs))
+				continue
+			}
+			for _, ff := range ffs {
+				h.Add(ff)
+			}
+			resp.EdgesScanned += len(ffs)
+		}
+		est := h.Estimate()
+		if selfIncluded && est > 0 {
+			est--
+		}
+		resp.Reach2Estimate = est
+		resp.Method = "hyperloglog"
+		resp.RelativeError = math.Round(h.RelativeError()*10000) / 10000
+	}
+
+	resp.SecondHopOnly = resp.Reach2Estimate - resp.Followers
+	if resp.SecondHopOnly < 0 {
+		resp.SecondHopOnly = 0
+	}
+	return resp
+}
+
+// handleReach2 serves GET /reach2?pubkey=<hex|npub>.
+func handleReach2(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
+		return
+	}
+
+	resp := computeReach2(pubkey)
+	resp.GraphSize = graph.Stats().Nodes
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestComputeReach2Exact(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+
+	creator := padHex(24001)
+	f1, f2 := padHex(24002), padHex(24003)
+	graph.AddFollow(f1, creator)
+	graph.AddFollow(f2, creator)
+	graph.AddFollow(padHex(24004), f1)
+	graph.AddFollow(padHex(24004), f2) // counted once
+	graph.AddFollow(padHex(24005), f2)
+	graph.AddFollow(f2, f1)      // already a first-hop follower
+	graph.AddFollow(creator, f1) // self is not part of the audience
+
+	r := computeReach2(creator)
+	if r.Method != "exact" || r.Followers != 2 || r.Reach2Estimate != 4 || r.SecondHopOnly != 2 {
+		t.Errorf("unexpected reach %+v", r)
+	}
+}
+
+func TestComputeReach2Sketch(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+
+	// 100 followers, each followed by 80 accounts from an overlapping pool of 6000
+	creator := padHex(24100)
+	exact := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		f := padHex(25000 + i)
+		graph.AddFollow(f, creator)
+		exact[f] = true
+		for j := 0; j < 80; j++ {
+			ff := padHex(30000 + (i*53+j)%6000)
+			graph.AddFollow(ff, f)
+			exact[ff] = true
+		}
+	}
+	// One small first-hop audience is scanned directly; creator is in it
+	small := padHex(25500)
+	graph.AddFollow(small, creator)
+	graph.AddFollow(creator, small)
+	graph.AddFollow(padHex(25501), small)
+	exact[small] = true
+	exact[padHex(25501)] = true
+
+	r := computeReach2(creator)
+	if r.Method != "hyperloglog" || r.RelativeError == 0 {
+		t.Fatalf("expected sketch estimate above the exact limit, got %+v", r)
+	}
+	if r.EdgesScanned != 2 {
+		t.Errorf("expected sketched audiences to skip scanning, got %d edges", r.EdgesScanned)
+	}
+	if diff := math.Abs(float64(r.Reach2Estimate - len(exact))); diff > 0.1*float64(len(exact)) {
+		t.Errorf("estimate %d too far from exact %d", r.Reach2Estimate, len(exact))
+	}
+
+	// Second call is served from the sketch cache and matches
+	if again := computeReach2(creator); again.Reach2Estimate != r.Reach2Estimate {
+		t.Errorf("cached estimate %d differs from %d", again.Reach2Estimate, r.Reach2Estimate)
+	}
+}
+
+func TestReach2Endpoint(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handleReach2(rr, httptest.NewRequest("GET", "/reach2", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without pubkey, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handleReach2(rr, httptest.NewRequest("GET", "/reach2?pubkey="+padHex(24200), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var resp Reach2Response
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.Pubkey != padHex(24200) || resp.Method != "exact" || resp.Reach2Estimate != 0 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RebuildPhase is one step of a score rebuild. Weight is the phase's expected share of
+// total rebuild time, used for percent/ETA reporting. Run may call progress with a
+// fraction in [0,1] to report progress within the phase.
+type RebuildPhase struct {
+	Name   string
+	Weight float64
+	Run    func(ctx context.Context, progress func(float64))
+}
+
+// PhaseStatus reports the outcome of a single phase in the current or last rebuild.
+type PhaseStatus struct {
+	Name       string `json:"name"`
+	State      string `json:"state"` // pending, running, done, skipped
+	DurationMs int64  `json:"duration_ms,omitempty"`
+}
+
+// RebuildStatus is the API response for GET /rebuild/status.
+type RebuildStatus struct {
+	State          string        `json:"state"` // idle, running, cancelling, completed, cancelled
+	Trigger        string        `json:"trigger,omitempty"`
+	Phase          string        `json:"phase,omitempty"`
+	PhaseIndex     int           `json:"phase_index"`
+	PhaseCount     int           `json:"phase_count"`
+	Percent        float64       `json:"percent"`
+	ETASeconds     int64         `json:"eta_seconds,omitempty"`
+	ElapsedSeconds int64         `json:"elapsed_seconds,omitempty"`
+	StartedAt      string        `json:"started_at,omitempty"`
+	FinishedAt     string        `json:"finished_at,omitempty"`
+	LastDurationS  int64         `json:"last_duration_seconds,omitempty"`
+	Runs           int           `json:"runs"`
+	Phases         []PhaseStatus `json:"phases,omitempty"`
+}
+
+// ErrRebuildRunning is returned when a rebuild is requested while one is in flight.
+var ErrRebuildRunning = errors.New("rebuild already running")
+
+// RebuildController runs the crawl/score pipeline one rebuild at a time, tracking
+// progress so long rebuilds on big graphs are observable and can be cancelled.
+// Cancellation is checked between phases and propagated to phases via ctx; phases
+// after the cancellation point are skipped, so scores from the previous PageRank
+// run stay in place rather than being computed from a partial crawl.
+type RebuildController struct {
+	mu           sync.Mutex
+	pipeline     func() []RebuildPhase
+	cancel       context.CancelFunc
+	done         chan struct{}
+	status       RebuildStatus
+	started      time.Time
+	phaseStarted time.Time
+	weights      []float64
+	phaseFrac    float64
+	lastDuration time.Duration
+	now          func() time.Time
+}
+
+// NewRebuildController creates a controller for the given pipeline builder.
+// The builder is called at the start of each rebuild.
+func NewRebuildController(pipeline func() []RebuildPhase) *RebuildController {
+	return &RebuildController{
+		pipeline: pipeline,
+		status:   RebuildStatus{State: "idle"},
+		now:      time.Now,
+	}
+}
+
+// Start begins a rebuild in the background. It returns ErrRebuildRunning if one is in flight.
+func (rc *RebuildController) Start(parent context.Context, trigger string) error {
+	return rc.StartPhases(parent, trigger, nil)
+}
+
+// StartPhases is Start running only the phases keep returns true for, such as
+// rescoring the current graph without crawling. A nil keep runs them all.
+func (rc *RebuildController) StartPhases(parent context.Context, trigger string, keep func(name string) bool) error {
+	rc.mu.Lock()
+	if rc.cancel != nil {
+		rc.mu.Unlock()
+		return ErrRebuildRunning
+	}
+	phases := rc.pipeline()
+	if keep != nil {
+		kept := phases[:0]
+		for _, p := range phases {
+			if keep(p.Name) {
+				kept = append(kept, p)
+			}
+		}
+		phases = kept
+	}
+	ctx, cancel := context.WithCancel(parent)
+	rc.cancel = cancel
+	rc.done = make(chan struct{})
+	rc.started = rc.now()
+	rc.weights = make([]float64, len(phases))
+	rc.phaseFrac = 0
+	statuses := make([]PhaseStatus, len(phases))
+	for i, p := range phases {
+		rc.weights[i] = p.Weight
+		statuses[i] = PhaseStatus{Name: p.Name, State: "pending"}
+	}
+	rc.status = RebuildStatus{
+		State:         "running",
+		Trigger:       trigger,
+		PhaseCount:    len(phases),
+		StartedAt:     rc.started.UTC().Format(time.RFC3339),
+		LastDurationS: int64(rc.lastDuration.Seconds()),
+		Runs:          rc.status.Runs,
+		Phases:        statuses,
+	}
+	done := rc.done
+	rc.mu.Unlock()
+
+	go rc.run(ctx, phases, done)
+	return nil
+}
+
+// Run starts a rebuild and waits for it to finish. If a rebuild is already running,
+// it returns ErrRebuildRunning immediately.
+func (rc *RebuildController) Run(parent context.Context, trigger string) error {
+	if err := rc.Start(parent, trigger); err != nil {
+		return err
+	}
+	rc.Wait()
+	return nil
+}
+
+// Wait blocks until the in-flight rebuild (if any) finishes.
+func (rc *RebuildController) Wait() {
+	rc.mu.Lock()
+	done := rc.done
+	rc.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// Cancel requests cancellation of the in-flight rebuild. Returns false if none is running.
+func (rc *RebuildController) Cancel() bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.cancel == nil {
+		return false
+	}
+	rc.cancel()
+	rc.status.State = "cancelling"
+	return true
+}
+
+func (rc *RebuildController) run(ctx context.Context, phases []RebuildPhase, done chan struct{}) {
+	defer close(done)
+
+	cancelled := false
+	for i, p := range phases {
+		if ctx.Err() != nil {
+			cancelled = true
+			rc.mu.Lock()
+			for j := i; j < len(phases); j++ {
+				rc.status.Phases[j].State = "skipped"
+			}
+			rc.mu.Unlock()
+			break
+		}
+
+		rc.mu.Lock()
+		rc.status.Phase = p.Name
+		rc.status.PhaseIndex = i
+		rc.status.Phases[i].State = "running"
+		rc.phaseStarted = rc.now()
+		rc.phaseFrac = 0
+		rc.mu.Unlock()
+
+		log.Printf("Rebuild phase %d/%d: %s", i+1, len(phases), p.Name)
+		p.Run(ctx, func(frac float64) {
+			rc.mu.Lock()
+			rc.phaseFrac = math.Max(0, math.Min(1, frac))
+			rc.mu.Unlock()
+		})
+
+		rc.mu.Lock()
+		rc.status.Phases[i].State = "done"
+		rc.status.Phases[i].DurationMs = rc.now().Sub(rc.phaseStarted).Milliseconds()
+		rc.mu.Unlock()
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	finished := rc.now()
+	if ctx.Err() != nil {
+		cancelled = true
+	}
+	rc.cancel = nil
+	rc.status.Runs++
+	rc.status.FinishedAt = finished.UTC().Format(time.RFC3339)
+	rc.status.Phase = ""
+	if cancelled {
+		rc.status.State = "cancelled"
+		log.Printf("Rebuild cancelled after %s", finished.Sub(rc.started).Truncate(time.Second))
+		return
+	}
+	rc.status.State = "completed"
+	rc.lastDuration = finished.Sub(rc.started)
+	rc.status.LastDurationS = int64(rc.lastDuration.Seconds())
+}
+
+// Status returns a snapshot with percent complete and ETA filled in.
+func (rc *RebuildController) Status() RebuildStatus {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	s := rc.status
+	s.Phases = append([]PhaseStatus(nil), rc.status.Phases...)
+	if s.State != "running" && s.State != "cancelling" {
+		if s.State == "completed" {
+			s.Percent = 100
+		}
+		return s
+	}
+
+	elapsed := rc.now().Sub(rc.started)
+	s.ElapsedSeconds = int64(elapsed.Seconds())
+
+	total, doneWeight := 0.0, 0.0
+	for i, w := range rc.weights {
+		total += w
+		if i < s.PhaseIndex {
+			doneWeight += w
+		}
+	}
+	if s.PhaseIndex < len(rc.weights) {
+		doneWeight += rc.weights[s.PhaseIndex] * rc.phaseFrac
+	}
+	if total > 0 {
+		s.Percent = math.Round(doneWeight/total*1000) / 10
+	}
+
+	// ETA: extrapolate from progress so far; fall back to the last run's duration.
+	switch {
+	case s.Percent >= 1:
+		remaining := time.Duration(float64(elapsed) * (100 - s.Percent) / s.Percent)
+		s.ETASeconds = int64(remaining.Seconds())
+	case rc.lastDuration > elapsed:
+		s.ETASeconds = int64((rc.lastDuration - elapsed).Seconds())
+	}
+	return s
+}
+
+// Running reports whether a rebuild is in flight.
+func (rc *RebuildController) Running() bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.cancel != nil
+}
+
+// handleRebuildStatus serves GET /rebuild/status.
+func handleRebuildStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rebuilder.Status())
+}
+
+// handleRebuild serves POST /rebuild (admin): start a rebuild in the background.
+func handleRebuild(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if err := rebuilder.Start(context.Background(), "admin"); err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusConflict, Message: err.Error()})
+		return
+	}
+	curation.Record(r, "rebuild", "", "")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(rebuilder.Status())
+}
+
+// handleRebuildCancel serves POST /rebuild/cancel (admin): cancel the in-flight rebuild.
+func handleRebuildCancel(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	if !requireAdmin(w, r) {
+		return
+	}
+	if !rebuilder.Cancel() {
+		writeAPIError(w, &APIError{Status: http.StatusConflict, Message: "no rebuild running"})
+		return
+	}
+	curation.Record(r, "rebuild_cancel", "", "")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rebuilder.Status())
+}
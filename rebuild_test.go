@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRebuildControllerRunsPhasesInOrder(t *testing.T) {
+	var order []string
+	rc := NewRebuildController(func() []RebuildPhase {
+		return []RebuildPhase{
+			{Name: "a", Weight: 1, Run: func(ctx context.Context, _ func(float64)) { order = append(order, "a") }},
+			{Name: "b", Weight: 3, Run: func(ctx context.Context, _ func(float64)) { order = append(order, "b") }},
+		}
+	})
+
+	if err := rc.Run(context.Background(), "test"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(order) != 2 || order[0] != "a" || order[1] != "b" {
+		t.Fatalf("expected phases a,b in order, got %v", order)
+	}
+
+	s := rc.Status()
+	if s.State != "completed" || s.Percent != 100 || s.Runs != 1 || s.Trigger != "test" {
+		t.Errorf("unexpected final status %+v", s)
+	}
+	for _, p := range s.Phases {
+		if p.State != "done" {
+			t.Errorf("phase %s state %s, want done", p.Name, p.State)
+		}
+	}
+}
+
+func TestRebuildControllerProgressAndETA(t *testing.T) {
+	release := make(chan struct{})
+	reported := make(chan struct{})
+	rc := NewRebuildController(func() []RebuildPhase {
+		return []RebuildPhase{
+			{Name: "first", Weight: 1, Run: func(ctx context.Context, _ func(float64)) {}},
+			{Name: "second", Weight: 2, Run: func(ctx context.Context, progress func(float64)) {
+				progress(0.5)
+				close(reported)
+				<-release
+			}},
+			{Name: "third", Weight: 1, Run: func(ctx context.Context, _ func(float64)) {}},
+		}
+	})
+	var clock atomic.Int64
+	clock.Store(1000)
+	rc.now = func() time.Time { return time.Unix(clock.Load(), 0) }
+
+	if err := rc.Start(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	<-reported
+	clock.Add(20)
+
+	s := rc.Status()
+	if s.State != "running" || s.Phase != "second" || s.PhaseIndex != 1 {
+		t.Fatalf("unexpected running status %+v", s)
+	}
+	// (1 + 2*0.5) / 4 = 50%
+	if s.Percent != 50 {
+		t.Errorf("expected 50%% complete, got %v", s.Percent)
+	}
+	if s.ETASeconds != 20 {
+		t.Errorf("expected ETA 20s at 50%% after 20s, got %d", s.ETASeconds)
+	}
+
+	if err := rc.Start(context.Background(), "again"); err != ErrRebuildRunning {
+		t.Errorf("expected ErrRebuildRunning, got %v", err)
+	}
+
+	close(release)
+	rc.Wait()
+	if got := rc.Status(); got.LastDurationS != 20 {
+		t.Errorf("expected last duration 20s, got %d", got.LastDurationS)
+	}
+}
+
+func TestRebuildControllerCancel(t *testing.T) {
+	var thirdRan int32
+	started := make(chan struct{})
+	rc := NewRebuildController(func() []RebuildPhase {
+		return []RebuildPhase{
+			{Name: "crawl", Weight: 1, Run: func(ctx context.Context, _ func(float64)) {
+				close(started)
+				<-ctx.Done()
+			}},
+			{Name: "pagerank", Weight: 1, Run: func(ctx context.Context, _ func(float64)) {
+				atomic.StoreInt32(&thirdRan, 1)
+			}},
+		}
+	})
+
+	if rc.Cancel() {
+		t.Error("cancel should report false when idle")
+	}
+	rc.Start(context.Background(), "test")
+	<-started
+	if !rc.Cancel() {
+		t.Fatal("expected cancel to succeed while running")
+	}
+	rc.Wait()
+
+	if atomic.LoadInt32(&thirdRan) != 0 {
+		t.Error("phases after cancellation must not run")
+	}
+	s := rc.Status()
+	if s.State != "cancelled" {
+		t.Errorf("expected cancelled state, got %s", s.State)
+	}
+	if s.Phases[1].State != "skipped" {
+		t.Errorf("expected pagerank skipped, got %s", s.Phases[1].State)
+	}
+	if rc.Running() {
+		t.Error("controller should be idle after cancellation")
+	}
+	// A new rebuild can start after cancellation
+	if err := rc.Start(context.Background(), "retry"); err != nil {
+		t.Errorf("expected restart after cancel, got %v", err)
+	}
+	rc.Cancel()
+	rc.Wait()
+}
+
+func TestRebuildEndpointsRequireAdmin(t *testing.T) {
+	oldRebuilder := rebuilder
+	defer func() { rebuilder = oldRebuilder }()
+	block := make(chan struct{})
+	rebuilder = NewRebuildController(func() []RebuildPhase {
+		return []RebuildPhase{{Name: "wait", Weight: 1, Run: func(ctx context.Context, _ func(float64)) {
+			select {
+			case <-ctx.Done():
+			case <-block:
+			}
+		}}}
+	})
+	defer close(block)
+
+	t.Setenv("ADMIN_TOKEN", "")
+	rr := httptest.NewRecorder()
+	handleRebuild(rr, httptest.NewRequest("POST", "/rebuild", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 when admin disabled, got %d", rr.Code)
+	}
+
+	t.Setenv("ADMIN_TOKEN", "s3cret")
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/rebuild", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	handleRebuild(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for bad token, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/rebuild", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	handleRebuild(rr, req)
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handleRebuild(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected 409 for concurrent rebuild, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handleRebuildStatus(rr, httptest.NewRequest("GET", "/rebuild/status", nil))
+	var s RebuildStatus
+	json.Unmarshal(rr.Body.Bytes(), &s)
+	if s.State != "running" || s.Trigger != "admin" {
+		t.Errorf("expected running admin rebuild, got %+v", s)
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/rebuild/cancel", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	handleRebuildCancel(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 on cancel, got %d", rr.Code)
+	}
+	rebuilder.Wait()
+	if got := rebuilder.Status().State; got != "cancelled" {
+		t.Errorf("expected cancelled, got %s", got)
+	}
+
+	rr = httptest.NewRecorder()
+	handleRebuildCancel(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected 409 when nothing to cancel, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strings"
+)
+
+// recommendExcludeMax caps the dismissed suggestions a client can send.
+const recommendExcludeMax = 1000
+
+// RecommendRequest is a /recommend query: GET takes it from the URL, POST from a
+// JSON body, which is also the only way to send an exclude list.
+type RecommendRequest struct {
+	Pubkey       string   `json:"pubkey"`
+	Limit        int      `json:"limit"`
+	Diversity    float64  `json:"diversity"`     // 0-1, how hard to penalize widely followed candidates
+	MinFollowers int      `json:"min_followers"` // follower-count band; 0 means unbounded
+	MaxFollowers int      `json:"max_followers"`
+	Topics       []string `json:"topics"`  // hashtags a candidate must have posted under; only crawled for top-scored accounts
+	Exclude      []string `json:"exclude"` // dismissed suggestions, hex or npub
+	excluded     map[string]bool
+}
+
+// parseRecommendRequest reads a /recommend request and validates it. Bad option
+// values are rejected rather than ignored; limit defaults to 20 and is capped at 50.
+func parseRecommendRequest(w http.ResponseWriter, r *http.Request) (RecommendRequest, *APIError) {
+	var req RecommendRequest
+	if r.Method == http.MethodPost {
+		if apiErr := bindJSON(w, r, &req, 256<<10); apiErr != nil {
+			return req, apiErr
+		}
+	} else {
+		q := bindQuery(r)
+		req.Pubkey = q.String("pubkey", false)
+		req.Limit = q.Int("limit", 20, 1, 50)
+		req.MinFollowers = q.Int("min_followers", 0, math.MinInt, math.MaxInt)
+		req.MaxFollowers = q.Int("max_followers", 0, math.MinInt, math.MaxInt)
+		req.Diversity = q.Float("diversity", 0, math.Inf(-1), math.Inf(1))
+		if v := q.String("topics", false); v != "" {
+			req.Topics = strings.Split(v, ",")
+		}
+		if apiErr := q.Err(); apiErr != nil {
+			return req, apiErr
+		}
+	}
+
+	if req.Pubkey == "" {
+		return req, missingParam("pubkey")
+	}
+	pubkey, err := resolvePubkey(req.Pubkey)
+	if err != nil {
+		return req, invalidParam("pubkey", "%s", err.Error())
+	}
+	req.Pubkey = pubkey
+	if req.Limit < 1 {
+		req.Limit = 20
+	}
+	if req.Limit > 50 {
+		req.Limit = 50
+	}
+	if req.Diversity < 0 || req.Diversity > 1 || math.IsNaN(req.Diversity) {
+		return req, invalidParam("diversity", "diversity must be 0-1")
+	}
+	if req.MinFollowers < 0 || req.MaxFollowers < 0 {
+		field := "min_followers"
+		if req.MaxFollowers < 0 {
+			field = "max_followers"
+		}
+		return req, invalidParam(field, "follower bounds must not be negative")
+	}
+	if req.MaxFollowers > 0 && req.MaxFollowers < req.MinFollowers {
+		return req, invalidParam("max_followers", "max_followers must be at least min_followers")
+	}
+
+	topics := req.Topics[:0]
+	for _, t := range req.Topics {
+		if t = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(t), "#")); t != "" {
+			topics = append(topics, t)
+		}
+	}
+	req.Topics = topics
+
+	if len(req.Exclude) > recommendExcludeMax {
+		return req, invalidParam("exclude", "max %d excluded pubkeys", recommendExcludeMax)
+	}
+	req.excluded = make(map[string]bool, len(req.Exclude))
+	for _, raw := range req.Exclude {
+		pk, err := resolvePubkey(raw)
+		if err != nil {
+			return req, invalidParam("exclude", "invalid exclude entry %q", raw)
+		}
+		req.excluded[pk] = true
+	}
+	return req, nil
+}
+
+// inFollowerBand reports whether a candidate with followers followers passes the
+// min_followers/max_followers band.
+func (req RecommendRequest) inFollowerBand(followers int) bool {
+	return followers >= req.MinFollowers && (req.MaxFollowers == 0 || followers <= req.MaxFollowers)
+}
+
+// diversityFactor scales a candidate's ranking score down by how widely followed
+// it already is: popularity is log(1+followers) / log(1+graph size), so an account
+// most of the graph follows loses nearly the full diversity share of its score
+// and a niche one keeps most of it.
+func (req RecommendRequest) diversityFactor(followers, graphSize int) float64 {
+	if req.Diversity == 0 || graphSize < 2 {
+		return 1
+	}
+	popularity := math.Log1p(float64(followers)) / math.Log1p(float64(graphSize))
+	return 1 - req.Diversity*math.Min(popularity, 1)
+}
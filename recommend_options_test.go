@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// setupRecommendGraph has alice's three follows all following both mega, which
+// thirty other accounts follow too, and niche.
+func setupRecommendGraph(t *testing.T) {
+	t.Helper()
+	oldGraph, oldMeta := graph, meta
+	t.Cleanup(func() { graph, meta = oldGraph, oldMeta })
+	graph, meta = NewGraph(), NewMetaStore()
+	for _, f := range []string{"f1", "f2", "f3"} {
+		graph.AddFollow("alice", f)
+		graph.AddFollow(f, "mega")
+		graph.AddFollow(f, "niche")
+	}
+	for i := 0; i < 30; i++ {
+		graph.AddFollow(fmt.Sprintf("o%d", i), "mega")
+	}
+	graph.ComputePageRank(20, 0.85)
+	meta.Set("niche", &PubkeyMeta{Topics: map[string]int{"bitcoin": 4}})
+}
+
+type recommendResult struct {
+	Recommendations []struct {
+		Pubkey    string   `json:"pubkey"`
+		Followers int      `json:"followers_count"`
+		Topics    []string `json:"matched_topics"`
+	} `json:"recommendations"`
+	Filtered int `json:"filtered"`
+}
+
+func (r recommendResult) pubkeys() []string {
+	var pks []string
+	for _, rec := range r.Recommendations {
+		pks = append(pks, rec.Pubkey)
+	}
+	return pks
+}
+
+func recommend(t *testing.T, req *http.Request) (int, recommendResult) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handleRecommend(w, req)
+	var resp recommendResult
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestRecommendDiversityAndFollowerBand(t *testing.T) {
+	setupRecommendGraph(t)
+	get := func(q string) []string {
+		code, resp := recommend(t, httptest.NewRequest(http.MethodGet, "/recommend?pubkey=alice"+q, nil))
+		if code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", q, code)
+		}
+		return resp.pubkeys()
+	}
+
+	if pks := get(""); len(pks) != 2 || pks[0] != "mega" {
+		t.Errorf("expected mega first without diversity, got %v", pks)
+	}
+	if pks := get("&diversity=1"); len(pks) != 2 || pks[0] != "niche" {
+		t.Errorf("expected niche first with diversity, got %v", pks)
+	}
+	if pks := get("&max_followers=10"); len(pks) != 1 || pks[0] != "niche" {
+		t.Errorf("expected mega outside the band, got %v", pks)
+	}
+	if pks := get("&min_followers=10"); len(pks) != 1 || pks[0] != "mega" {
+		t.Errorf("expected niche outside the band, got %v", pks)
+	}
+}
+
+func TestRecommendTopics(t *testing.T) {
+	setupRecommendGraph(t)
+
+	_, resp := recommend(t, httptest.NewRequest(http.MethodGet, "/recommend?pubkey=alice&topics=%23Bitcoin,art", nil))
+	if len(resp.Recommendations) != 1 || resp.Recommendations[0].Pubkey != "niche" || resp.Filtered != 1 {
+		t.Fatalf("expected only niche by topic, got %+v", resp)
+	}
+	if topics := resp.Recommendations[0].Topics; len(topics) != 1 || topics[0] != "bitcoin" {
+		t.Errorf("expected the matched topic reported, got %v", topics)
+	}
+	if _, ok := meta.data["mega"]; ok {
+		t.Error("expected topic matching not to add metadata entries")
+	}
+}
+
+func TestRecommendPostExclude(t *testing.T) {
+	setupRecommendGraph(t)
+
+	body := `{"pubkey":"alice","exclude":["mega"],"diversity":0.5}`
+	code, resp := recommend(t, httptest.NewRequest(http.MethodPost, "/recommend", strings.NewReader(body)))
+	if code != http.StatusOK || len(resp.Recommendations) != 1 || resp.Recommendations[0].Pubkey != "niche" || resp.Filtered != 1 {
+		t.Errorf("expected the dismissed suggestion left out, got %d %+v", code, resp)
+	}
+}
+
+func TestRecommendRejectsInvalidOptions(t *testing.T) {
+	setupRecommendGraph(t)
+
+	for _, q := range []string{"diversity=2", "diversity=x", "min_followers=-1", "min_followers=10&max_followers=5", "max_followers=many"} {
+		if code, _ := recommend(t, httptest.NewRequest(http.MethodGet, "/recommend?pubkey=alice&"+q, nil)); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, code)
+		}
+	}
+	for _, body := range []string{`{"pubkey":"alice","exclude":["npub1bad"]}`, `not json`, `{"exclude":["mega"]}`} {
+		if code, _ := recommend(t, httptest.NewRequest(http.MethodPost, "/recommend", strings.NewReader(body))); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, code)
+		}
+	}
+	if code, _ := recommend(t, httptest.NewRequest(http.MethodPut, "/recommend?pubkey=alice", nil)); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for PUT, got %d", code)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A minimal Redis client for the shared rate limit and graph stores: RESP2 over
+// a small pool of connections, with pipelining and nothing else. See
+// https://redis.io/docs/latest/develop/reference/protocol-spec/.
+
+const (
+	redisPoolSize = 8
+	redisTimeout  = 500 * time.Millisecond
+)
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+type redisConn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+}
+
+type redisClient struct {
+	addr     string
+	useTLS   bool
+	username string
+	password string
+	db       int
+	timeout  time.Duration // per pipeline, including the dial
+	idle     chan *redisConn
+}
+
+// newRedisClient parses a redis:// or rediss:// (TLS) URL, such as
+// redis://:password@host:6379/0. Connections are made on first use.
+func newRedisClient(rawURL string) (*redisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("redis URL must start with redis:// or rediss://")
+	}
+	c := &redisClient{addr: u.Host, useTLS: u.Scheme == "rediss", timeout: redisTimeout, idle: make(chan *redisConn, redisPoolSize)}
+	if u.Port() == "" {
+		c.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.username = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("redis URL: invalid database %q", db)
+		}
+	}
+	return c, nil
+}
+
+func (c *redisClient) dial() (*redisConn, error) {
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: c.timeout}
+	if c.useTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", c.addr, nil)
+	} else {
+		conn, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rc := &redisConn{Conn: conn, r: bufio.NewReader(conn), timeout: c.timeout}
+	var setup [][]string
+	if c.password != "" {
+		if c.username != "" {
+			setup = append(setup, []string{"AUTH", c.username, c.password})
+		} else {
+			setup = append(setup, []string{"AUTH", c.password})
+		}
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	if len(setup) > 0 {
+		replies, err := rc.do(setup)
+		if err == nil {
+			err = firstRedisError(replies)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// Do sends cmds in one pipeline and returns their replies in order. Error
+// replies come back as redisError values rather than failing the whole call.
+func (c *redisClient) Do(cmds ...[]string) ([]interface{}, error) {
+	var conn *redisConn
+	select {
+	case conn = <-c.idle:
+	default:
+		var err error
+		if conn, err = c.dial(); err != nil {
+			return nil, err
+		}
+	}
+	replies, err := conn.do(cmds)
+	if err != nil {
+		conn.Close() // the connection may be out of step with its replies
+		return nil, err
+	}
+	select {
+	case c.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return replies, nil
+}
+
+func (rc *redisConn) do(cmds [][]string) ([]interface{}, error) {
+	rc.SetDeadline(time.Now().Add(rc.timeout))
+	var buf []byte
+	for _, cmd := range cmds {
+		buf = fmt.Appendf(buf, "*%d\r\n", len(cmd))
+		for _, arg := range cmd {
+			buf = fmt.Appendf(buf, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := rc.Write(buf); err != nil {
+		return nil, err
+	}
+	replies := make([]interface{}, len(cmds))
+	for i := range cmds {
+		reply, err := readRedisReply(rc.r)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// readRedisReply reads one reply: a string, int64, redisError, nil, or
+// []interface{} of those.
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+func firstRedisError(replies []interface{}) error {
+	for _, reply := range replies {
+		if err, ok := reply.(redisError); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// RedisRateLimitStore keeps counts in Redis, shared by every replica pointed at
+// the same server. Each window is one key that expires two intervals after the
+// window starts, once it can no longer be the previous window.
+type RedisRateLimitStore struct {
+	client *redisClient
+	prefix string
+}
+
+// NewRedisRateLimitStore connects to the Redis server at rawURL and checks it answers.
+func NewRedisRateLimitStore(rawURL string) (*RedisRateLimitStore, error) {
+	client, err := newRedisClient(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := client.Do([]string{"PING"})
+	if err == nil {
+		err = firstRedisError(replies)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &RedisRateLimitStore{client: client, prefix: "wot:ratelimit:"}, nil
+}
+
+func (s *RedisRateLimitStore) windowKey(key string, start time.Time) string {
+	return s.prefix + key + ":" + strconv.FormatInt(start.UnixMilli(), 10)
+}
+
+func (s *RedisRateLimitStore) Incr(key string, start time.Time, interval time.Duration) (int, int, error) {
+	cur := s.windowKey(key, start)
+	replies, err := s.client.Do(
+		[]string{"INCR", cur},
+		[]string{"PEXPIRE", cur, strconv.FormatInt((2 * interval).Milliseconds(), 10)},
+		[]string{"GET", s.windowKey(key, start.Add(-interval))},
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := firstRedisError(replies); err != nil {
+		return 0, 0, err
+	}
+	n, ok := replies[0].(int64)
+	if !ok {
+		return 0, 0, fmt.Errorf("redis: unexpected INCR reply %v", replies[0])
+	}
+	var prev int
+	if s, ok := replies[2].(string); ok {
+		prev, _ = strconv.Atoi(s)
+	}
+	return int(n), prev, nil
+}
+
+func (s *RedisRateLimitStore) Decr(key string, start time.Time) error {
+	replies, err := s.client.Do([]string{"DECR", s.windowKey(key, start)})
+	if err != nil {
+		return err
+	}
+	return firstRedisError(replies)
+}
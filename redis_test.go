@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves the handful of commands the rate limit and graph stores use,
+// keeping values in maps. Expiry is recorded but not enforced.
+type fakeRedis struct {
+	mu       sync.Mutex
+	values   map[string]int
+	strs     map[string]string
+	ttls     map[string]string
+	password string
+	commands []string
+}
+
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{values: map[string]int{}, strs: map[string]string{}, ttls: map[string]string{}, password: password}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		reply, err := readRedisReply(r)
+		if err != nil {
+			return
+		}
+		items, _ := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i], _ = item.(string)
+		}
+		if len(args) == 0 {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args[0])
+		var out string
+		switch {
+		case args[0] == "AUTH":
+			if args[len(args)-1] == f.password {
+				authed, out = true, "+OK\r\n"
+			} else {
+				out = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			out = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "PING":
+			out = "+PONG\r\n"
+		case args[0] == "SELECT":
+			out = "+OK\r\n"
+		case args[0] == "INCR":
+			f.values[args[1]]++
+			out = fmt.Sprintf(":%d\r\n", f.values[args[1]])
+		case args[0] == "DECR":
+			f.values[args[1]]--
+			out = fmt.Sprintf(":%d\r\n", f.values[args[1]])
+		case args[0] == "PEXPIRE":
+			f.ttls[args[1]] = args[2]
+			out = ":1\r\n"
+		case args[0] == "SET":
+			f.strs[args[1]] = args[2]
+			if len(args) == 5 {
+				f.ttls[args[1]] = args[4]
+			}
+			out = "+OK\r\n"
+		case args[0] == "GET":
+			if s, ok := f.strs[args[1]]; ok {
+				out = fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+			} else if v, ok := f.values[args[1]]; ok {
+				s := strconv.Itoa(v)
+				out = fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+			} else {
+				out = "$-1\r\n"
+			}
+		default:
+			out = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+		conn.Write([]byte(out))
+	}
+}
+
+func TestRedisRateLimitStore(t *testing.T) {
+	f, addr := startFakeRedis(t, "s3cret")
+	if _, err := NewRedisRateLimitStore("redis://:wrong@" + addr); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("expected a bad password to fail, got %v", err)
+	}
+	store, err := NewRedisRateLimitStore("redis://:s3cret@" + addr + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.UnixMilli(1_700_000_040_000)
+	prevKey := store.windowKey("anonymous:1.2.3.4", start.Add(-time.Minute))
+	f.mu.Lock()
+	f.values[prevKey] = 7
+	f.mu.Unlock()
+
+	for want := 1; want <= 3; want++ {
+		cur, prev, err := store.Incr("anonymous:1.2.3.4", start, time.Minute)
+		if err != nil || cur != want || prev != 7 {
+			t.Fatalf("expected cur=%d prev=7, got %d %d %v", want, cur, prev, err)
+		}
+	}
+	if err := store.Decr("anonymous:1.2.3.4", start); err != nil {
+		t.Fatal(err)
+	}
+	cur := store.windowKey("anonymous:1.2.3.4", start)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if cur != "wot:ratelimit:anonymous:1.2.3.4:1700000040000" || f.values[cur] != 2 || f.ttls[cur] != "120000" {
+		t.Errorf("unexpected window %s = %d (ttl %s)", cur, f.values[cur], f.ttls[cur])
+	}
+	if strings.Count(strings.Join(f.commands, " "), "AUTH") > redisPoolSize+1 {
+		t.Errorf("expected pooled connections to be reused, got commands %v", f.commands)
+	}
+}
+
+func TestRedisRateLimiterSharedAcrossReplicas(t *testing.T) {
+	_, addr := startFakeRedis(t, "")
+	var limiters []*RateLimiter
+	for i := 0; i < 2; i++ {
+		store, err := NewRedisRateLimitStore("redis://" + addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		limiters = append(limiters, NewRateLimiterWithStore("anonymous", 3, time.Minute, store))
+	}
+	allowed := 0
+	for i := 0; i < 6; i++ {
+		if _, ok := limiters[i%2].Allow("1.2.3.4"); ok {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("expected 3 requests allowed across both replicas, got %d", allowed)
+	}
+}
+
+func TestRedisStoreUnreachable(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+	if _, err := NewRedisRateLimitStore("redis://" + addr); err == nil {
+		t.Error("expected an error connecting to a closed port")
+	}
+	if _, err := NewRedisRateLimitStore("http://" + addr); err == nil {
+		t.Error("expected an error for a non-redis URL")
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// relayRetryBase is the backoff after a relay's first failure; it doubles with
+	// each consecutive failure up to relayRetryMax (the scheduled re-crawl interval).
+	relayRetryBase = 2 * time.Minute
+	relayRetryMax  = 6 * time.Hour
+	// dataStaleAfter is how old the last successful crawl may get before /health
+	// reports the served data as stale (two missed scheduled re-crawls).
+	dataStaleAfter = 12 * time.Hour
+)
+
+// RelayState is one relay's connection health as seen by the crawler.
+type RelayState struct {
+	URL                 string `json:"url"`
+	Up                  bool   `json:"up"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	LastError           string `json:"last_error,omitempty"`
+	LastSuccess         string `json:"last_success,omitempty"`
+	LastFailure         string `json:"last_failure,omitempty"`
+	NextRetry           string `json:"next_retry,omitempty"`
+
+	lastSuccess time.Time
+	nextRetry   time.Time
+}
+
+// RelayHealth tracks relay reachability across crawls. A relay that fails to connect
+// is left alone until its jittered exponential backoff expires, and failures are
+// logged only when a relay goes down or comes back, not on every attempt.
+type RelayHealth struct {
+	mu         sync.RWMutex
+	relays     map[string]*RelayState
+	lastCrawl  time.Time // last probe, successful or not
+	lastGood   time.Time // last probe that reached at least one relay
+	lastReach  int
+	lastProbed int
+	now        func() time.Time
+	jitter     func() float64 // in [0, 1)
+}
+
+func NewRelayHealth() *RelayHealth {
+	return &RelayHealth{
+		relays: make(map[string]*RelayState),
+		now:    time.Now,
+		jitter: rand.Float64,
+	}
+}
+
+var relayHealth = NewRelayHealth()
+
+// backoff returns the retry delay after n consecutive failures, with +/-25% jitter
+// so relays that failed together are not retried in lockstep.
+func (h *RelayHealth) backoff(n int) time.Duration {
+	d := relayRetryBase
+	for i := 1; i < n && d < relayRetryMax; i++ {
+		d *= 2
+	}
+	if d > relayRetryMax {
+		d = relayRetryMax
+	}
+	return time.Duration(float64(d) * (0.75 + h.jitter()/2))
+}
+
+// Available returns the urls that are up or whose backoff has expired.
+func (h *RelayHealth) Available(urls []string) []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	now := h.now()
+	out := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if s, ok := h.relays[u]; ok && !s.Up && now.Before(s.nextRetry) {
+			continue
+		}
+		out = append(out, u)
+	}
+	return out
+}
+
+// Probe connects to each available url, records the outcome, and returns the
+// relays that answered. It is called at the start of every follow crawl.
+func (h *RelayHealth) Probe(urls []string, connect func(url string) error) []string {
+	available := h.Available(urls)
+	errs := make([]error, len(available))
+	var wg sync.WaitGroup
+	for i, u := range available {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			errs[i] = connect(u)
+		}(i, u)
+	}
+	wg.Wait()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	var reachable []string
+	for i, u := range available {
+		s, ok := h.relays[u]
+		if !ok {
+			s = &RelayState{URL: u, Up: true}
+			h.relays[u] = s
+		}
+		if errs[i] == nil {
+			if !s.Up {
+				log.Printf("Relay %s reachable again after %d failures", u, s.ConsecutiveFailures)
+			}
+			s.Up, s.ConsecutiveFailures, s.LastError = true, 0, ""
+			s.lastSuccess, s.nextRetry = now, time.Time{}
+			reachable = append(reachable, u)
+			continue
+		}
+		if s.Up {
+			log.Printf("Relay %s unreachable: %v", u, errs[i])
+		}
+		s.Up = false
+		s.ConsecutiveFailures++
+		s.LastError = errs[i].Error()
+		s.LastFailure = now.UTC().Format(time.RFC3339)
+		s.nextRetry = now.Add(h.backoff(s.ConsecutiveFailures))
+	}
+
+	h.lastCrawl, h.lastReach, h.lastProbed = now, len(reachable), len(urls)
+	if len(reachable) > 0 {
+		h.lastGood = now
+	} else if len(urls) > 0 {
+		log.Printf("All %d relays unreachable; keeping data from the last successful crawl", len(urls))
+	}
+	return reachable
+}
+
+// RetryIn returns how long until the first failing relay's backoff expires. ok is
+// false unless the last probe was a full outage, since partial failures are retried
+// by the next scheduled crawl.
+func (h *RelayHealth) RetryIn() (d time.Duration, ok bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.lastCrawl.IsZero() || h.lastReach > 0 {
+		return 0, false
+	}
+	var earliest time.Time
+	for _, s := range h.relays {
+		if !s.Up && (earliest.IsZero() || s.nextRetry.Before(earliest)) {
+			earliest = s.nextRetry
+		}
+	}
+	if earliest.IsZero() {
+		return 0, false
+	}
+	if d = earliest.Sub(h.now()); d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// Status classifies served data: "starting" before any graph exists, "stale" when
+// the last crawl reached no relay or the data is older than dataStaleAfter,
+// "degraded" when some relays failed the last crawl, otherwise "ready".
+func (h *RelayHealth) Status(graphNodes int) string {
+	if graphNodes == 0 {
+		return "starting"
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.lastCrawl.IsZero() {
+		return "ready"
+	}
+	if h.lastReach == 0 || h.now().Sub(h.lastGood) > dataStaleAfter {
+		return "stale"
+	}
+	if h.lastReach < h.lastProbed {
+		return "degraded"
+	}
+	return "ready"
+}
+
+// Report summarizes relay health for /health.
+func (h *RelayHealth) Report(urls []string) map[string]interface{} {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	now := h.now()
+
+	states := make([]RelayState, 0, len(urls))
+	failing := 0
+	for _, u := range urls {
+		s, ok := h.relays[u]
+		if !ok {
+			states = append(states, RelayState{URL: u, Up: true})
+			continue
+		}
+		st := *s
+		if !s.lastSuccess.IsZero() {
+			st.LastSuccess = s.lastSuccess.UTC().Format(time.RFC3339)
+		}
+		if !s.Up {
+			failing++
+			st.NextRetry = s.nextRetry.UTC().Format(time.RFC3339)
+		}
+		states = append(states, st)
+	}
+
+	report := map[string]interface{}{
+		"reachable": len(urls) - failing,
+		"failing":   failing,
+		"relays":    states,
+	}
+	if !h.lastGood.IsZero() {
+		report["last_successful_crawl"] = h.lastGood.UTC().Format(time.RFC3339)
+		report["data_age_seconds"] = int(now.Sub(h.lastGood).Seconds())
+	}
+	return report
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRelayHealth returns a tracker on a controllable clock with no jitter
+// (jitter 0.5 maps to exactly the base delay).
+func testRelayHealth() (*RelayHealth, *time.Time) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	h := NewRelayHealth()
+	h.now = func() time.Time { return now }
+	h.jitter = func() float64 { return 0.5 }
+	return h, &now
+}
+
+func connectExcept(down ...string) func(string) error {
+	return func(url string) error {
+		for _, d := range down {
+			if url == d {
+				return errors.New("dial tcp: connection refused")
+			}
+		}
+		return nil
+	}
+}
+
+func TestRelayHealthBackoff(t *testing.T) {
+	h, _ := testRelayHealth()
+	for n, want := range map[int]time.Duration{
+		1:  2 * time.Minute,
+		2:  4 * time.Minute,
+		4:  16 * time.Minute,
+		20: 6 * time.Hour,
+	} {
+		if got := h.backoff(n); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", n, got, want)
+		}
+	}
+	h.jitter = func() float64 { return 0 }
+	if got := h.backoff(1); got != 90*time.Second {
+		t.Errorf("expected -25%% jitter floor, got %s", got)
+	}
+}
+
+func TestRelayHealthPartialFailure(t *testing.T) {
+	h, now := testRelayHealth()
+	urls := []string{"wss://a", "wss://b", "wss://c"}
+
+	got := h.Probe(urls, connectExcept("wss://b"))
+	if len(got) != 2 || h.Status(10) != "degraded" {
+		t.Fatalf("expected 2 reachable and degraded, got %v %s", got, h.Status(10))
+	}
+	if _, ok := h.RetryIn(); ok {
+		t.Error("partial failures should not schedule an early retry")
+	}
+
+	// b is backing off and is not offered to crawls until its retry time
+	if avail := h.Available(urls); len(avail) != 2 {
+		t.Errorf("expected b to be backing off, got %v", avail)
+	}
+	*now = now.Add(3 * time.Minute)
+	if avail := h.Available(urls); len(avail) != 3 {
+		t.Errorf("expected b available after backoff, got %v", avail)
+	}
+
+	h.Probe(urls, connectExcept())
+	if h.Status(10) != "ready" {
+		t.Errorf("expected ready after recovery, got %s", h.Status(10))
+	}
+	report := h.Report(urls)
+	if report["failing"] != 0 || report["reachable"] != 3 {
+		t.Errorf("unexpected report %v", report)
+	}
+}
+
+func TestRelayHealthOutageAndStaleness(t *testing.T) {
+	h, now := testRelayHealth()
+	urls := []string{"wss://a", "wss://b"}
+
+	if h.Status(0) != "starting" || h.Status(10) != "ready" {
+		t.Error("expected starting without a graph and ready before any crawl")
+	}
+
+	h.Probe(urls, connectExcept())
+	*now = now.Add(time.Hour)
+	if got := h.Probe(urls, connectExcept(urls...)); len(got) != 0 {
+		t.Fatalf("expected no reachable relays, got %v", got)
+	}
+	if h.Status(10) != "stale" {
+		t.Errorf("expected stale during an outage, got %s", h.Status(10))
+	}
+	d, ok := h.RetryIn()
+	if !ok || d != 2*time.Minute {
+		t.Errorf("expected retry in 2m, got %s %v", d, ok)
+	}
+
+	report := h.Report(urls)
+	if report["failing"] != 2 || report["data_age_seconds"] != 3600 {
+		t.Errorf("unexpected report %v", report)
+	}
+	states := report["relays"].([]RelayState)
+	if states[0].LastError == "" || states[0].NextRetry == "" || states[0].ConsecutiveFailures != 1 {
+		t.Errorf("expected failure details, got %+v", states[0])
+	}
+
+	// a fresh crawl that reaches a relay but follows a long gap is still fine
+	*now = now.Add(time.Minute * 3)
+	h.Probe(urls, connectExcept())
+	if h.Status(10) != "ready" {
+		t.Errorf("expected ready after outage ends, got %s", h.Status(10))
+	}
+	*now = now.Add(dataStaleAfter + time.Minute)
+	if h.Status(10) != "stale" {
+		t.Errorf("expected stale once data ages past %s, got %s", dataStaleAfter, h.Status(10))
+	}
+}
+
+func TestHealthEndpointReportsRelayHealth(t *testing.T) {
+	old := relayHealth
+	defer func() { relayHealth = old }()
+	relayHealth, _ = testRelayHealth()
+	relayHealth.Probe(config.Relays(), connectExcept(config.Relays()[0]))
+
+	rr := httptest.NewRecorder()
+	handleHealth(rr, httptest.NewRequest("GET", "/health", nil))
+	body := rr.Body.String()
+	if !strings.Contains(body, `"relay_health"`) || !strings.Contains(body, `"failing":1`) {
+		t.Errorf("expected relay_health with one failing relay, got %s", body)
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// relayInfoTTL is how long a fetched NIP-11 document is trusted before refetching.
+const relayInfoTTL = 6 * time.Hour
+
+// RelayLimitation is the "limitation" object of a NIP-11 relay information document.
+// Only the fields the crawler acts on or operators care about are kept.
+type RelayLimitation struct {
+	MaxMessageLength int  `json:"max_message_length,omitempty"`
+	MaxSubscriptions int  `json:"max_subscriptions,omitempty"`
+	MaxFilters       int  `json:"max_filters,omitempty"`
+	MaxLimit         int  `json:"max_limit,omitempty"`
+	AuthRequired     bool `json:"auth_required"`
+	PaymentRequired  bool `json:"payment_required"`
+	RestrictedWrites bool `json:"restricted_writes"`
+}
+
+// RelayInfo is what the crawler knows about one relay and how it treats it.
+type RelayInfo struct {
+	URL           string          `json:"url"`
+	Name          string          `json:"name,omitempty"`
+	Software      string          `json:"software,omitempty"`
+	Version       string          `json:"version,omitempty"`
+	SupportedNIPs []int           `json:"supported_nips,omitempty"`
+	Limitation    RelayLimitation `json:"limitation"`
+	Status        string          `json:"status"` // ok, throttled, skipped, unknown, pending
+	Reason        string          `json:"reason,omitempty"`
+	FetchedAt     string          `json:"fetched_at,omitempty"`
+	Error         string          `json:"error,omitempty"`
+
+	fetched time.Time
+}
+
+// classify sets Status and Reason from the advertised limits. Relays that need
+// NIP-42 auth or payment cannot serve the anonymous crawler, so they are skipped;
+// a max_limit below the crawler's largest request throttles batch sizes.
+func (ri *RelayInfo) classify() {
+	l := ri.Limitation
+	switch {
+	case ri.Error != "":
+		ri.Status, ri.Reason = "unknown", "NIP-11 unavailable; crawling with default limits"
+	case l.AuthRequired:
+		ri.Status, ri.Reason = "skipped", "relay requires NIP-42 auth"
+	case l.PaymentRequired:
+		ri.Status, ri.Reason = "skipped", "relay requires payment"
+	case l.MaxLimit > 0 && l.MaxLimit < crawlMaxRequest:
+		ri.Status, ri.Reason = "throttled", fmt.Sprintf("max_limit %d caps crawl batch sizes", l.MaxLimit)
+	default:
+		ri.Status, ri.Reason = "ok", ""
+	}
+}
+
+// crawlMaxRequest is the largest per-request limit the crawlers ask for by default
+// (100 authors x 20 notes in the metadata crawl).
+const crawlMaxRequest = 2000
+
+// RelayLimits caches NIP-11 documents for the configured relays and turns their
+// advertised limitations into crawl decisions. The crawler sends one filter per
+// REQ and holds a single subscription per relay at a time, so max_filters and
+// max_subscriptions are satisfied by any relay that allows at least one of each.
+type RelayLimits struct {
+	mu     sync.RWMutex
+	client *SafeHTTPClient
+	infos  map[string]*RelayInfo
+}
+
+func NewRelayLimits(client *SafeHTTPClient) *RelayLimits {
+	return &RelayLimits{client: client, infos: make(map[string]*RelayInfo)}
+}
+
+var relayLimits = NewRelayLimits(externalHTTP)
+
+// relayInfoURL maps a relay websocket URL to the HTTP URL serving its NIP-11 document.
+func relayInfoURL(relayURL string) (string, error) {
+	switch {
+	case strings.HasPrefix(relayURL, "wss://"):
+		return "https://" + strings.TrimPrefix(relayURL, "wss://"), nil
+	case strings.HasPrefix(relayURL, "ws://"):
+		return "http://" + strings.TrimPrefix(relayURL, "ws://"), nil
+	}
+	return "", fmt.Errorf("unsupported relay URL %q", relayURL)
+}
+
+// fetch retrieves and classifies one relay's NIP-11 document. Failures are recorded
+// on the returned info rather than returned, so a relay without NIP-11 is still crawled.
+func (rl *RelayLimits) fetch(ctx context.Context, relayURL string) *RelayInfo {
+	ri := &RelayInfo{URL: relayURL, fetched: time.Now()}
+	ri.FetchedAt = ri.fetched.UTC().Format(time.RFC3339)
+	defer ri.classify()
+
+	infoURL, err := relayInfoURL(relayURL)
+	if err != nil {
+		ri.Error = err.Error()
+		return ri
+	}
+	resp, err := rl.client.Do(ctx, http.MethodGet, infoURL, http.Header{"Accept": {"application/nostr+json"}})
+	if err != nil {
+		ri.Error = err.Error()
+		return ri
+	}
+	if resp.StatusCode != http.StatusOK {
+		ri.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		return ri
+	}
+
+	var doc struct {
+		Name          string          `json:"name"`
+		Software      string          `json:"software"`
+		Version       string          `json:"version"`
+		SupportedNIPs []int           `json:"supported_nips"`
+		Limitation    RelayLimitation `json:"limitation"`
+	}
+	if err := json.Unmarshal(resp.Body, &doc); err != nil {
+		ri.Error = "invalid NIP-11 document"
+		return ri
+	}
+	ri.Name, ri.Software, ri.Version = doc.Name, doc.Software, doc.Version
+	ri.SupportedNIPs = doc.SupportedNIPs
+	ri.Limitation = doc.Limitation
+	return ri
+}
+
+// Refresh fetches NIP-11 documents for urls whose cached copy is missing or older
+// than relayInfoTTL. Fetches run concurrently.
+func (rl *RelayLimits) Refresh(ctx context.Context, urls []string) {
+	var wg sync.WaitGroup
+	for _, u := range urls {
+		rl.mu.RLock()
+		ri, ok := rl.infos[u]
+		rl.mu.RUnlock()
+		if ok && time.Since(ri.fetched) < relayInfoTTL {
+			continue
+		}
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			ri := rl.fetch(ctx, u)
+			rl.mu.Lock()
+			rl.infos[u] = ri
+			rl.mu.Unlock()
+			if ri.Status != "ok" {
+				log.Printf("Relay %s: %s (%s)", u, ri.Status, ri.Reason)
+			}
+		}(u)
+	}
+	wg.Wait()
+}
+
+// Crawlable returns the urls the crawler should query, dropping skipped relays. If
+// every relay would be skipped the full list is returned so crawls still run.
+func (rl *RelayLimits) Crawlable(urls []string) []string {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	out := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if ri, ok := rl.infos[u]; ok && ri.Status == "skipped" {
+			continue
+		}
+		out = append(out, u)
+	}
+	if len(out) == 0 {
+		return urls
+	}
+	return out
+}
+
+// BatchSize shrinks a crawl batch so batch*perItem stays within the smallest
+// max_limit advertised by the crawlable relays. It never returns less than 1.
+func (rl *RelayLimits) BatchSize(urls []string, batch, perItem int) int {
+	crawlable := rl.Crawlable(urls)
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	for _, u := range crawlable {
+		ri, ok := rl.infos[u]
+		if !ok || ri.Limitation.MaxLimit <= 0 {
+			continue
+		}
+		if n := ri.Limitation.MaxLimit / perItem; n < batch {
+			batch = n
+		}
+	}
+	if batch < 1 {
+		batch = 1
+	}
+	return batch
+}
+
+// Snapshot returns the known relay info for urls, in the order given. Relays not
+// yet fetched are reported with status "pending".
+func (rl *RelayLimits) Snapshot(urls []string) []RelayInfo {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	out := make([]RelayInfo, 0, len(urls))
+	for _, u := range urls {
+		if ri, ok := rl.infos[u]; ok {
+			out = append(out, *ri)
+			continue
+		}
+		out = append(out, RelayInfo{URL: u, Status: "pending"})
+	}
+	return out
+}
+
+// crawlRelays is the relay list crawls should subscribe to: relays whose NIP-11
+// limits allow anonymous reads and that are not backing off after failures.
+func crawlRelays() []string {
+	return relayHealth.Available(relayLimits.Crawlable(config.Relays()))
+}
+
+// crawlBatchSize is batch adjusted for the configured relays' max_limit, where each
+// batch item asks for up to perItem events.
+func crawlBatchSize(batch, perItem int) int {
+	return relayLimits.BatchSize(config.Relays(), batch, perItem)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nip11Server serves a NIP-11 document per path, or 404 for unknown paths.
+func nip11Server(t *testing.T, docs map[string]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/nostr+json" {
+			http.Error(w, "not a NIP-11 request", http.StatusBadRequest)
+			return
+		}
+		doc, ok := docs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/nostr+json")
+		w.Write([]byte(doc))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testRelayLimits() *RelayLimits {
+	return NewRelayLimits(NewSafeHTTPClient(FetchConfig{
+		Timeout:      2 * time.Second,
+		MaxBodyBytes: 1 << 16,
+		AllowPrivate: true,
+	}))
+}
+
+func TestRelayLimitsClassify(t *testing.T) {
+	srv := nip11Server(t, map[string]string{
+		"/open":   `{"name":"open","software":"strfry","supported_nips":[1,11],"limitation":{"max_limit":5000,"max_filters":10}}`,
+		"/small":  `{"name":"small","limitation":{"max_limit":100}}`,
+		"/auth":   `{"name":"auth","limitation":{"auth_required":true}}`,
+		"/paid":   `{"name":"paid","limitation":{"payment_required":true}}`,
+		"/broken": `not json`,
+	})
+	base := "ws://" + strings.TrimPrefix(srv.URL, "http://")
+	urls := []string{base + "/open", base + "/small", base + "/auth", base + "/paid", base + "/broken", base + "/missing"}
+
+	rl := testRelayLimits()
+	rl.Refresh(context.Background(), urls)
+
+	want := []string{"ok", "throttled", "skipped", "skipped", "unknown", "unknown"}
+	for i, ri := range rl.Snapshot(urls) {
+		if ri.Status != want[i] {
+			t.Errorf("%s: expected %s, got %s (%s)", ri.URL, want[i], ri.Status, ri.Reason)
+		}
+	}
+	open := rl.Snapshot(urls[:1])[0]
+	if open.Name != "open" || open.Software != "strfry" || open.Limitation.MaxFilters != 10 || len(open.SupportedNIPs) != 2 {
+		t.Errorf("unexpected parsed info %+v", open)
+	}
+
+	crawlable := rl.Crawlable(urls)
+	if len(crawlable) != 4 {
+		t.Fatalf("expected auth and paid relays to be skipped, got %v", crawlable)
+	}
+	for _, u := range crawlable {
+		if strings.HasSuffix(u, "/auth") || strings.HasSuffix(u, "/paid") {
+			t.Errorf("%s should be skipped", u)
+		}
+	}
+
+	// the smallest max_limit (100) bounds batch*perItem
+	if n := rl.BatchSize(urls, 50, 10); n != 10 {
+		t.Errorf("expected batch 10, got %d", n)
+	}
+	if n := rl.BatchSize(urls, 50, 1); n != 50 {
+		t.Errorf("expected batch 50 when within max_limit, got %d", n)
+	}
+	if n := rl.BatchSize(urls, 50, 500); n != 1 {
+		t.Errorf("expected batch floor of 1, got %d", n)
+	}
+}
+
+func TestRelayLimitsAllSkippedFallsBack(t *testing.T) {
+	srv := nip11Server(t, map[string]string{"/auth": `{"limitation":{"auth_required":true}}`})
+	urls := []string{"ws://" + strings.TrimPrefix(srv.URL, "http://") + "/auth"}
+
+	rl := testRelayLimits()
+	rl.Refresh(context.Background(), urls)
+	if got := rl.Crawlable(urls); len(got) != 1 {
+		t.Errorf("expected full list when every relay is skipped, got %v", got)
+	}
+}
+
+func TestRelayLimitsRefreshUsesCache(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`{"name":"r"}`))
+	}))
+	defer srv.Close()
+	urls := []string{"ws://" + strings.TrimPrefix(srv.URL, "http://")}
+
+	rl := testRelayLimits()
+	rl.Refresh(context.Background(), urls)
+	rl.Refresh(context.Background(), urls)
+	if hits != 1 {
+		t.Errorf("expected cached NIP-11 document to be reused, got %d fetches", hits)
+	}
+}
+
+func TestRelayInfoURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"wss://relay.damus.io":  "https://relay.damus.io",
+		"ws://localhost:7777/r": "http://localhost:7777/r",
+	} {
+		if got, err := relayInfoURL(in); err != nil || got != want {
+			t.Errorf("relayInfoURL(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := relayInfoURL("https://relay.damus.io"); err == nil {
+		t.Error("expected error for non-websocket URL")
+	}
+}
+
+func TestStatsIncludesRelayInfo(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handleStats(rr, httptest.NewRequest("GET", "/stats", nil))
+	var resp struct {
+		RelayInfo []RelayInfo `json:"relay_info"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.RelayInfo) != len(config.Relays()) {
+		t.Fatalf("expected %d relay_info entries, got %d", len(config.Relays()), len(resp.RelayInfo))
+	}
+	if resp.RelayInfo[0].URL != config.Relays()[0] || resp.RelayInfo[0].Status == "" {
+		t.Errorf("unexpected relay_info entry %+v", resp.RelayInfo[0])
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// relayStoreAuthors is how many of the highest-scored pubkeys have their NIP-65
+	// relay lists crawled each rebuild.
+	relayStoreAuthors = 5000
+	// relayStoreBatch is how many authors' relay lists are asked for per query.
+	relayStoreBatch = 500
+	// relayStoreNIP11 caps the relays, most trusted first, whose NIP-11 documents are
+	// fetched each rebuild; fetches run relayStoreFetchers at a time.
+	relayStoreNIP11    = 300
+	relayStoreFetchers = 16
+	// relayPublishMax and relayPublishMinUsers bound the kind 30385 relay assertions:
+	// the top relays used by at least that many graph members.
+	relayPublishMax      = 100
+	relayPublishMinUsers = 3
+	relayTopLimit        = 50
+	relayTopMax          = 500
+)
+
+// RelayRecord is what the relay store knows about one relay: who in the graph uses
+// it, its NIP-11 document, and its operator's standing in the WoT.
+type RelayRecord struct {
+	URL             string  `json:"url"`
+	Rank            int     `json:"rank"`
+	Adoption        int     `json:"adoption"`     // trust-weighted usage, 0-100 relative to the most used relay
+	Users           int     `json:"users"`        // graph members listing the relay
+	WriteUsers      int     `json:"write_users"`  // ... as a write relay
+	ReadUsers       int     `json:"read_users"`   // ... as a read relay
+	TrustWeight     float64 `json:"trust_weight"` // sum of the users' WoT scores
+	Name            string  `json:"name,omitempty"`
+	Software        string  `json:"software,omitempty"`
+	Contact         string  `json:"contact,omitempty"`
+	SupportedNIPs   []int   `json:"supported_nips,omitempty"`
+	Operator        string  `json:"operator,omitempty"`
+	OperatorScore   int     `json:"operator_score"`
+	OperatorInGraph bool    `json:"operator_in_graph"`
+	NIP11FetchedAt  string  `json:"nip11_fetched_at,omitempty"`
+	NIP11Error      string  `json:"nip11_error,omitempty"`
+}
+
+// relayNIP11 is a cached NIP-11 document, or the error fetching it.
+type relayNIP11 struct {
+	name     string
+	software string
+	contact  string
+	nips     []int
+	operator string
+	err      string
+	fetched  time.Time
+}
+
+// RelayStore ranks every relay seen in the graph's NIP-65 relay lists. A relay's
+// rank blends trust-weighted adoption (70%) with its operator's WoT score (30%),
+// the same split /relay uses for trustedrelays.xyz data. NIP-11 documents, which
+// name the operator, are cached for relayInfoTTL.
+type RelayStore struct {
+	mu      sync.RWMutex
+	client  *SafeHTTPClient
+	records map[string]*RelayRecord
+	docs    map[string]*relayNIP11
+	updated time.Time
+}
+
+func NewRelayStore(client *SafeHTTPClient) *RelayStore {
+	return &RelayStore{
+		client:  client,
+		records: make(map[string]*RelayRecord),
+		docs:    make(map[string]*relayNIP11),
+	}
+}
+
+var relayStore = NewRelayStore(externalHTTP)
+
+// fetchNIP11 reads one relay's information document. Failures are recorded on the
+// result rather than returned, so they are cached like successes.
+func (rs *RelayStore) fetchNIP11(ctx context.Context, relayURL string) *relayNIP11 {
+	doc := &relayNIP11{fetched: time.Now()}
+	infoURL, err := relayInfoURL(relayURL)
+	if err != nil {
+		doc.err = err.Error()
+		return doc
+	}
+	resp, err := rs.client.Do(ctx, http.MethodGet, infoURL, http.Header{"Accept": {"application/nostr+json"}})
+	if err != nil {
+		doc.err = err.Error()
+		return doc
+	}
+	if resp.StatusCode != http.StatusOK {
+		doc.err = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		return doc
+	}
+	var body struct {
+		Name          string `json:"name"`
+		Software      string `json:"software"`
+		Contact       string `json:"contact"`
+		Pubkey        string `json:"pubkey"`
+		SupportedNIPs []int  `json:"supported_nips"`
+	}
+	if err := json.Unmarshal(resp.Body, &body); err != nil {
+		doc.err = "invalid NIP-11 document"
+		return doc
+	}
+	doc.name, doc.software, doc.contact, doc.nips = body.Name, body.Software, body.Contact, body.SupportedNIPs
+	// Some relays publish the operator as an npub despite NIP-11 asking for hex
+	if pk, err := resolvePubkey(strings.TrimSpace(body.Pubkey)); err == nil && hex64Pattern.MatchString(pk) {
+		doc.operator = pk
+	}
+	return doc
+}
+
+// Rebuild replaces the relay records with usage counted from lists (author ->
+// relay list), fetches missing or stale NIP-11 documents for the most trusted
+// relays, and ranks every relay against g.
+func (rs *RelayStore) Rebuild(ctx context.Context, g *Graph, lists map[string]*RelayList) {
+	nodes := g.Stats().Nodes
+	records := make(map[string]*RelayRecord)
+	for author, list := range lists {
+		raw, _ := g.GetScore(author)
+		weight := float64(normalizeScore(raw, nodes))
+		used := make(map[string]bool)
+		count := func(u string) *RelayRecord {
+			rec := records[u]
+			if rec == nil {
+				rec = &RelayRecord{URL: u}
+				records[u] = rec
+			}
+			if !used[u] {
+				used[u] = true
+				rec.Users++
+				rec.TrustWeight += weight
+			}
+			return rec
+		}
+		for _, u := range list.Write {
+			count(u).WriteUsers++
+		}
+		for _, u := range list.Read {
+			count(u).ReadUsers++
+		}
+	}
+
+	byWeight := make([]*RelayRecord, 0, len(records))
+	for _, rec := range records {
+		byWeight = append(byWeight, rec)
+	}
+	sort.Slice(byWeight, func(i, j int) bool {
+		if byWeight[i].TrustWeight != byWeight[j].TrustWeight {
+			return byWeight[i].TrustWeight > byWeight[j].TrustWeight
+		}
+		return byWeight[i].URL < byWeight[j].URL
+	})
+
+	// Fetch NIP-11 for the most trusted relays without a fresh document
+	var stale []string
+	rs.mu.RLock()
+	for i, rec := range byWeight {
+		if i == relayStoreNIP11 {
+			break
+		}
+		if doc, ok := rs.docs[rec.URL]; !ok || time.Since(doc.fetched) >= relayInfoTTL {
+			stale = append(stale, rec.URL)
+		}
+	}
+	rs.mu.RUnlock()
+	fetched := make(map[string]*relayNIP11, len(stale))
+	var fetchedMu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, relayStoreFetchers)
+	for _, u := range stale {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(u string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			doc := rs.fetchNIP11(ctx, u)
+			fetchedMu.Lock()
+			fetched[u] = doc
+			fetchedMu.Unlock()
+		}(u)
+	}
+	wg.Wait()
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for u, doc := range fetched {
+		rs.docs[u] = doc
+	}
+	for u := range rs.docs {
+		if records[u] == nil {
+			delete(rs.docs, u)
+		}
+	}
+
+	maxWeight := 0.0
+	if len(byWeight) > 0 {
+		maxWeight = byWeight[0].TrustWeight
+	}
+	for _, rec := range records {
+		if maxWeight > 0 {
+			rec.Adoption = int(math.Round(100 * math.Log1p(rec.TrustWeight) / math.Log1p(maxWeight)))
+		}
+		rec.TrustWeight = math.Round(rec.TrustWeight*10) / 10
+		if doc, ok := rs.docs[rec.URL]; ok {
+			rec.Name, rec.Software, rec.Contact, rec.SupportedNIPs = doc.name, doc.software, doc.contact, doc.nips
+			rec.NIP11FetchedAt = doc.fetched.UTC().Format(time.RFC3339)
+			rec.NIP11Error = doc.err
+			if doc.operator != "" {
+				raw, inGraph := g.GetScore(doc.operator)
+				rec.Operator, rec.OperatorInGraph = doc.operator, inGraph
+				rec.OperatorScore = normalizeScore(raw, nodes)
+			}
+		}
+		rec.Rank = relayRank(rec.Adoption, rec.OperatorScore)
+	}
+	rs.records = records
+	rs.updated = time.Now()
+}
+
+// relayRank blends adoption (70%) with the operator's WoT score (30%). Relays
+// without a known operator get no operator share.
+func relayRank(adoption, operatorScore int) int {
+	rank := int(math.Round(float64(adoption)*0.7 + float64(operatorScore)*0.3))
+	if rank > 100 {
+		rank = 100
+	}
+	return rank
+}
+
+// Get returns the record for a relay URL.
+func (rs *RelayStore) Get(relayURL string) (RelayRecord, bool) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	rec, ok := rs.records[normalizeRelayURL(relayURL)]
+	if !ok {
+		return RelayRecord{}, false
+	}
+	return *rec, true
+}
+
+// Top returns up to limit relays, highest rank first, with ties broken by users
+// then URL. minUsers drops relays fewer graph members use.
+func (rs *RelayStore) Top(limit, minUsers int) []RelayRecord {
+	rs.mu.RLock()
+	out := make([]RelayRecord, 0, len(rs.records))
+	for _, rec := range rs.records {
+		if rec.Users >= minUsers {
+			out = append(out, *rec)
+		}
+	}
+	rs.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Rank != out[j].Rank {
+			return out[i].Rank > out[j].Rank
+		}
+		if out[i].Users != out[j].Users {
+			return out[i].Users > out[j].Users
+		}
+		return out[i].URL < out[j].URL
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// Stats returns how many relays are known, how many have an operator linked to a
+// pubkey, and when the store was last rebuilt.
+func (rs *RelayStore) Stats() (relays, operators int, updated time.Time) {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	for _, rec := range rs.records {
+		if rec.Operator != "" {
+			operators++
+		}
+	}
+	return len(rs.records), operators, rs.updated
+}
+
+// crawlRelayStore fetches the NIP-65 relay lists of the top-scored pubkeys and
+// rebuilds the relay store from them.
+func crawlRelayStore(ctx context.Context, rs *RelayStore) {
+	authors := TopNPubkeys(graph, relayStoreAuthors)
+	log.Printf("Crawling NIP-65 relay lists for top %d pubkeys...", len(authors))
+
+	lists := make(map[string]*RelayList)
+	batch := crawlBatchSize(relayStoreBatch, 1)
+	for start := 0; start < len(authors) && ctx.Err() == nil; start += batch {
+		end := min(start+batch, len(authors))
+		events, err := fetchRelayLists(ctx, authors[start:end])
+		if err != nil {
+			log.Printf("Relay list crawl: %v", err)
+			continue
+		}
+		for pk, ev := range events {
+			lists[pk] = parseRelayList(ev)
+		}
+	}
+
+	rs.Rebuild(ctx, graph.Snapshot(), lists)
+	relays, operators, _ := rs.Stats()
+	log.Printf("Relay store complete: %d relays from %d relay lists, %d with a linked operator", relays, len(lists), operators)
+}
+
+// publishRelayAssertions signs kind 30385 relay trust assertions, d = relay URL,
+// for the top relays and queues them for the relays.
+func publishRelayAssertions(ctx context.Context, rs *RelayStore, signer EventSigner) (int, error) {
+	top := rs.Top(relayPublishMax, relayPublishMinUsers)
+	if len(top) == 0 {
+		return 0, nil
+	}
+	batch := make([]nostr.Event, 0, len(top))
+	for _, rec := range top {
+		tags := nostr.Tags{
+			{"d", rec.URL},
+			{"rank", strconv.Itoa(rec.Rank)},
+			{"users", strconv.Itoa(rec.Users)},
+			{"write_users", strconv.Itoa(rec.WriteUsers)},
+			{"read_users", strconv.Itoa(rec.ReadUsers)},
+		}
+		if rec.Operator != "" {
+			tags = append(tags, nostr.Tag{"operator", rec.Operator}, nostr.Tag{"operator_rank", strconv.Itoa(rec.OperatorScore)})
+		}
+		ev := nostr.Event{
+			PubKey:    signer.PublicKey(),
+			CreatedAt: nostr.Now(),
+			Kind:      30385,
+			Tags:      tags,
+		}
+		if err := validateAssertionEvent(&ev); err != nil {
+			log.Printf("Skipping kind 30385 for %s: %v", rec.URL, err)
+			continue
+		}
+		if err := signer.Sign(ctx, &ev); err != nil {
+			log.Printf("Failed to sign kind 30385 for %s: %v", rec.URL, err)
+			continue
+		}
+		batch = append(batch, ev)
+	}
+
+	queued := publishQueue.Enqueue(batch, config.Relays())
+	log.Printf("Queued %d kind 30385 (relay trust assertion) events", queued)
+	return queued, ctx.Err()
+}
+
+// handleRelayTop handles GET /relay/top?limit=50&min_users=1
+// Lists relays seen in the graph's NIP-65 relay lists, highest rank first.
+func handleRelayTop(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	limit := q.Int("limit", relayTopLimit, 1, relayTopMax)
+	minUsers := q.Int("min_users", 1, 1, math.MaxInt)
+	if q.Failed(w) {
+		return
+	}
+
+	relays, operators, updated := relayStore.Stats()
+	resp := map[string]interface{}{
+		"relays":         relayStore.Top(limit, minUsers),
+		"total_relays":   relays,
+		"with_operator":  operators,
+		"relay_lists_of": relayStoreAuthors,
+		"formula":        "rank = adoption * 0.70 + operator_score * 0.30",
+		"published_kind": 30385,
+		"min_users":      minUsers,
+		"limit":          limit,
+	}
+	if !updated.IsZero() {
+		resp["updated_at"] = updated.UTC().Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// setupRelayStore serves NIP-11 documents naming operator from /a (hex) and /b
+// (npub); /c has none. It returns the relay URLs, the operator and a hit counter.
+func setupRelayStore(t *testing.T) (urls []string, operator string, hits *atomic.Int32) {
+	t.Helper()
+	oldGraph, oldStore := graph, relayStore
+	t.Cleanup(func() { graph, relayStore = oldGraph, oldStore })
+
+	operator = padHex(43001)
+	npub, _ := nip19.EncodePublicKey(operator)
+	docs := map[string]string{
+		"/a": fmt.Sprintf(`{"name":"Relay A","software":"strfry","pubkey":%q,"supported_nips":[1,11,65]}`, operator),
+		"/b": fmt.Sprintf(`{"name":"Relay B","pubkey":%q}`, npub),
+	}
+	hits = new(atomic.Int32)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		doc, ok := docs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(doc))
+	}))
+	t.Cleanup(srv.Close)
+	base := "ws://" + strings.TrimPrefix(srv.URL, "http://")
+	urls = []string{base + "/a", base + "/b", base + "/c"}
+
+	relayStore = NewRelayStore(NewSafeHTTPClient(FetchConfig{Timeout: 2 * time.Second, MaxBodyBytes: 1 << 16, AllowPrivate: true}))
+	graph = NewGraph()
+	for i := 0; i < 10; i++ {
+		for j := 0; j <= i; j++ {
+			graph.AddFollow(padHex(43100+j), padHex(43010+i))
+		}
+		graph.AddFollow(padHex(43010+i), operator)
+	}
+	graph.ComputePageRank(20, 0.85)
+	return urls, operator, hits
+}
+
+// relayTestLists has every user on a, four on b (write only), one on c (read only).
+func relayTestLists(urls []string) map[string]*RelayList {
+	lists := make(map[string]*RelayList)
+	for i := 0; i < 10; i++ {
+		list := &RelayList{Read: []string{urls[0]}, Write: []string{urls[0]}}
+		if i < 4 {
+			list.Write = append(list.Write, urls[1])
+		}
+		if i == 9 {
+			list.Read = append(list.Read, urls[2])
+		}
+		lists[padHex(43010+i)] = list
+	}
+	return lists
+}
+
+func TestRelayStoreRebuild(t *testing.T) {
+	urls, operator, hits := setupRelayStore(t)
+	relayStore.Rebuild(context.Background(), graph, relayTestLists(urls))
+
+	a, ok := relayStore.Get(urls[0] + "/")
+	if !ok || a.Users != 10 || a.WriteUsers != 10 || a.ReadUsers != 10 || a.Adoption != 100 {
+		t.Fatalf("unexpected record for a: %+v", a)
+	}
+	if a.Operator != operator || !a.OperatorInGraph || a.OperatorScore == 0 || a.Name != "Relay A" || len(a.SupportedNIPs) != 3 {
+		t.Errorf("expected the NIP-11 operator linked to the graph, got %+v", a)
+	}
+	if a.Rank != relayRank(a.Adoption, a.OperatorScore) {
+		t.Errorf("expected rank %d, got %d", relayRank(a.Adoption, a.OperatorScore), a.Rank)
+	}
+	b, _ := relayStore.Get(urls[1])
+	if b.Users != 4 || b.ReadUsers != 0 || b.Operator != operator || b.Adoption >= a.Adoption {
+		t.Errorf("expected b's npub operator linked and lower adoption, got %+v", b)
+	}
+	c, _ := relayStore.Get(urls[2])
+	if c.Users != 1 || c.Operator != "" || c.NIP11Error != "HTTP 404" {
+		t.Errorf("expected c without an operator, got %+v", c)
+	}
+
+	top := relayStore.Top(0, 1)
+	if len(top) != 3 || top[0].URL != urls[0] || top[2].URL != urls[2] {
+		t.Errorf("expected a, b, c by rank, got %+v", top)
+	}
+	if n := len(relayStore.Top(0, 2)); n != 2 {
+		t.Errorf("expected min_users to drop c, got %d relays", n)
+	}
+
+	relayStore.Rebuild(context.Background(), graph, relayTestLists(urls))
+	if hits.Load() != 3 {
+		t.Errorf("expected cached NIP-11 documents to be reused, got %d fetches", hits.Load())
+	}
+	if relays, operators, updated := relayStore.Stats(); relays != 3 || operators != 2 || updated.IsZero() {
+		t.Errorf("unexpected stats %d %d %v", relays, operators, updated)
+	}
+}
+
+func TestRelayRank(t *testing.T) {
+	if got := relayRank(100, 0); got != 70 {
+		t.Errorf("expected adoption alone to give 70, got %d", got)
+	}
+	if got := relayRank(100, 100); got != 100 {
+		t.Errorf("expected 100, got %d", got)
+	}
+	if got := relayRank(50, 20); got != 41 {
+		t.Errorf("expected 41, got %d", got)
+	}
+}
+
+func TestCrawlRelayStore(t *testing.T) {
+	urls, _, _ := setupRelayStore(t)
+	oldFetch := fetchRelayLists
+	t.Cleanup(func() { fetchRelayLists = oldFetch })
+	fetchRelayLists = func(_ context.Context, authors []string) (map[string]*nostr.Event, error) {
+		out := make(map[string]*nostr.Event)
+		for _, pk := range authors {
+			out[pk] = &nostr.Event{Kind: 10002, PubKey: pk, Tags: nostr.Tags{{"r", urls[0]}}}
+		}
+		return out, nil
+	}
+
+	crawlRelayStore(context.Background(), relayStore)
+	a, ok := relayStore.Get(urls[0])
+	if !ok || a.Users != graph.Stats().Nodes {
+		t.Errorf("expected every graph member on a, got %+v", a)
+	}
+}
+
+func TestPublishRelayAssertions(t *testing.T) {
+	urls, operator, _ := setupRelayStore(t)
+	relayStore.Rebuild(context.Background(), graph, relayTestLists(urls))
+	old := publishQueue
+	t.Cleanup(func() { publishQueue = old })
+	publishQueue = NewPublishQueue("")
+
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	n, err := publishRelayAssertions(context.Background(), relayStore, keySigner{sk: sk, pub: pub})
+	if err != nil || n != 2 {
+		t.Fatalf("expected a and b queued (c has too few users), got %d %v", n, err)
+	}
+	item, ok := publishQueue.items["30385:"+urls[0]]
+	if !ok {
+		t.Fatalf("expected a's assertion in the queue, got %v", publishQueue.items)
+	}
+	ev := item.Event
+	if ok, err := ev.CheckSignature(); !ok || err != nil {
+		t.Errorf("expected a signed assertion, got %v %v", ok, err)
+	}
+	if op := ev.Tags.GetFirst([]string{"operator"}); op == nil || (*op)[1] != operator {
+		t.Errorf("expected the operator tag, got %v", ev.Tags)
+	}
+	if users := ev.Tags.GetFirst([]string{"users"}); users == nil || (*users)[1] != "10" {
+		t.Errorf("expected users=10, got %v", ev.Tags)
+	}
+
+	if n, _ := publishRelayAssertions(context.Background(), NewRelayStore(externalHTTP), keySigner{sk: sk, pub: pub}); n != 0 {
+		t.Errorf("expected nothing queued from an empty store, got %d", n)
+	}
+}
+
+func TestHandleRelayTop(t *testing.T) {
+	urls, _, _ := setupRelayStore(t)
+	relayStore.Rebuild(context.Background(), graph, relayTestLists(urls))
+
+	rr := httptest.NewRecorder()
+	handleRelayTop(rr, httptest.NewRequest(http.MethodGet, "/relay/top?limit=2", nil))
+	var resp struct {
+		Relays      []RelayRecord `json:"relays"`
+		TotalRelays int           `json:"total_relays"`
+		UpdatedAt   string        `json:"updated_at"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); rr.Code != http.StatusOK || err != nil {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(resp.Relays) != 2 || resp.Relays[0].URL != urls[0] || resp.TotalRelays != 3 || resp.UpdatedAt == "" {
+		t.Errorf("unexpected response %s", rr.Body.String())
+	}
+
+	for _, q := range []string{"?limit=0", "?limit=x", "?min_users=0"} {
+		rr = httptest.NewRecorder()
+		handleRelayTop(rr, httptest.NewRequest(http.MethodGet, "/relay/top"+q, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("GET /relay/top%s: expected 400, got %d", q, rr.Code)
+		}
+	}
+}
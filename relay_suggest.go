@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// relaySuggestFollows is how many of a user's follows, highest score first, have
+	// their relay lists consulted.
+	relaySuggestFollows = 150
+	relaySuggestLimit   = 10
+	relaySuggestMax     = 30
+	// relayListTTL is how long a fetched NIP-65 relay list is reused.
+	relayListTTL          = 6 * time.Hour
+	relayListFetchTimeout = 10 * time.Second
+)
+
+// relaySuggestLimiter caps suggestions per client IP, on top of the global rate limit,
+// since a cold request queries relays for up to relaySuggestFollows relay lists.
+var relaySuggestLimiter = NewRateLimiter(10, time.Minute)
+
+// fetchRelayLists returns the newest kind 10002 event for each of authors that has one
+// on the crawl relays. Tests replace it.
+var fetchRelayLists = func(ctx context.Context, authors []string) (map[string]*nostr.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, relayListFetchTimeout)
+	defer cancel()
+	pool := nostr.NewSimplePool(ctx)
+	filter := nostr.Filter{Kinds: []int{10002}, Authors: authors, Limit: len(authors)}
+
+	out := make(map[string]*nostr.Event)
+	for ev := range pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter}) {
+		if cur := out[ev.Event.PubKey]; cur == nil || ev.Event.CreatedAt > cur.CreatedAt {
+			out[ev.Event.PubKey] = ev.Event
+		}
+	}
+	return out, nil
+}
+
+// RelayList is a user's NIP-65 relays: where they publish (write) and where they
+// expect mentions (read).
+type RelayList struct {
+	Read  []string
+	Write []string
+}
+
+type relayListEntry struct {
+	list    *RelayList // nil if the author has no relay list
+	fetched time.Time
+}
+
+// relayLists caches fetched NIP-65 relay lists by author.
+var relayLists = struct {
+	sync.Mutex
+	data map[string]relayListEntry
+}{data: make(map[string]relayListEntry)}
+
+// getRelayLists returns the relay lists of authors, fetching the ones not cached in
+// the last relayListTTL in one relay query. Authors without a list are omitted.
+func getRelayLists(ctx context.Context, authors []string) (map[string]*RelayList, error) {
+	now := time.Now()
+	out := make(map[string]*RelayList)
+	var missing []string
+	relayLists.Lock()
+	for _, pk := range authors {
+		e, ok := relayLists.data[pk]
+		if !ok || now.Sub(e.fetched) > relayListTTL {
+			missing = append(missing, pk)
+			continue
+		}
+		if e.list != nil {
+			out[pk] = e.list
+		}
+	}
+	relayLists.Unlock()
+	if len(missing) == 0 {
+		return out, nil
+	}
+
+	events, err := fetchRelayLists(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	relayLists.Lock()
+	defer relayLists.Unlock()
+	for pk, e := range relayLists.data {
+		if now.Sub(e.fetched) > relayListTTL {
+			delete(relayLists.data, pk)
+		}
+	}
+	for _, pk := range missing {
+		var list *RelayList
+		if ev := events[pk]; ev != nil {
+			list = parseRelayList(ev)
+			out[pk] = list
+		}
+		relayLists.data[pk] = relayListEntry{list: list, fetched: now}
+	}
+	return out, nil
+}
+
+// parseRelayList reads the r tags of a kind 10002 event. A tag without a marker is
+// both a read and a write relay. Invalid URLs are dropped.
+func parseRelayList(ev *nostr.Event) *RelayList {
+	list := &RelayList{}
+	read, write := make(map[string]bool), make(map[string]bool)
+	for _, tag := range ev.Tags {
+		if len(tag) < 2 || tag[0] != "r" {
+			continue
+		}
+		u := normalizeRelayURL(tag[1])
+		marker := ""
+		if len(tag) >= 3 {
+			marker = tag[2]
+		}
+		if u == "" {
+			continue
+		}
+		if (marker == "" || marker == "read") && !read[u] {
+			read[u] = true
+			list.Read = append(list.Read, u)
+		}
+		if (marker == "" || marker == "write") && !write[u] {
+			write[u] = true
+			list.Write = append(list.Write, u)
+		}
+	}
+	return list
+}
+
+// normalizeRelayURL lowercases the scheme and host and drops a trailing slash, so
+// the same relay written two ways counts once. It returns "" for non-websocket URLs.
+func normalizeRelayURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "wss" && u.Scheme != "ws") || u.Host == "" {
+		return ""
+	}
+	u.Scheme, u.Host = strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawQuery, u.Fragment = "", ""
+	return u.String()
+}
+
+// RelaySuggestion is one relay in a /relay/suggest response.
+type RelaySuggestion struct {
+	URL string `json:"url"`
+	// Trust-weighted share (0-100) of the consulted follows with relay lists that
+	// publish to / read from this relay.
+	WriteCoverage float64 `json:"write_coverage"`
+	ReadCoverage  float64 `json:"read_coverage"`
+	// Write coverage of this relay together with every suggestion ranked above it.
+	CumulativeWriteCoverage float64 `json:"cumulative_write_coverage"`
+	FollowsWriting          int     `json:"follows_writing"`
+	FollowsReading          int     `json:"follows_reading"`
+	InYourList              bool    `json:"in_your_list"`
+}
+
+// RelaySuggestResponse is the response for GET /relay/suggest.
+type RelaySuggestResponse struct {
+	Pubkey              string            `json:"pubkey"`
+	Suggestions         []RelaySuggestion `json:"suggestions"`
+	FollowsConsidered   int               `json:"follows_considered"`
+	FollowsWithRelays   int               `json:"follows_with_relay_lists"`
+	YourRelays          *RelayListView    `json:"your_relays,omitempty"`
+	MissingReadCoverage float64           `json:"missing_read_coverage"`
+	GraphSize           int               `json:"graph_size"`
+}
+
+// RelayListView is a relay list as returned in responses.
+type RelayListView struct {
+	Read  []string `json:"read"`
+	Write []string `json:"write"`
+}
+
+// suggestRelays ranks relays for pubkey from the relay lists of its follows, weighted
+// by each follow's score. Relays are picked greedily by how much trust-weighted
+// write coverage they add, so the top few suggestions are the smallest set that
+// reaches most of the user's trusted follows' notes; ties go to read coverage.
+func suggestRelays(pubkey string, follows []string, lists map[string]*RelayList, own *RelayList, limit int) RelaySuggestResponse {
+	resp := RelaySuggestResponse{Pubkey: pubkey, FollowsConsidered: len(follows), Suggestions: []RelaySuggestion{}}
+	if own != nil {
+		resp.YourRelays = &RelayListView{Read: own.Read, Write: own.Write}
+	}
+
+	type relayStat struct {
+		writers           map[string]bool
+		writeW, readW     float64
+		writeCnt, readCnt int
+	}
+	stats := make(map[string]*relayStat)
+	stat := func(u string) *relayStat {
+		if stats[u] == nil {
+			stats[u] = &relayStat{writers: make(map[string]bool)}
+		}
+		return stats[u]
+	}
+	weight := make(map[string]float64)
+	total := 0.0
+	for _, f := range follows {
+		list := lists[f]
+		if list == nil || len(list.Read)+len(list.Write) == 0 {
+			continue
+		}
+		w, _ := graph.GetScore(f)
+		if w <= 0 {
+			continue
+		}
+		weight[f] = w
+		total += w
+		resp.FollowsWithRelays++
+		for _, u := range list.Write {
+			s := stat(u)
+			s.writers[f] = true
+			s.writeW += w
+			s.writeCnt++
+		}
+		for _, u := range list.Read {
+			s := stat(u)
+			s.readW += w
+			s.readCnt++
+		}
+	}
+	if total == 0 {
+		return resp
+	}
+	pct := func(w float64) float64 { return math.Round(w/total*1000) / 10 }
+
+	ownRelays := make(map[string]bool)
+	if own != nil {
+		for _, u := range append(append([]string(nil), own.Read...), own.Write...) {
+			ownRelays[u] = true
+		}
+	}
+
+	// read coverage the user is missing: follows with none of their read relays in
+	// the user's list won't see the user's replies and mentions
+	if own != nil {
+		missing := 0.0
+		for f, w := range weight {
+			reached := len(lists[f].Read) == 0
+			for _, u := range lists[f].Read {
+				if ownRelays[u] {
+					reached = true
+					break
+				}
+			}
+			if !reached {
+				missing += w
+			}
+		}
+		resp.MissingReadCoverage = pct(missing)
+	}
+
+	covered := make(map[string]bool)
+	coveredW := 0.0
+	for len(resp.Suggestions) < limit && len(stats) > 0 {
+		best, bestGain := "", -1.0
+		for u, s := range stats {
+			gain := 0.0
+			for f := range s.writers {
+				if !covered[f] {
+					gain += weight[f]
+				}
+			}
+			if best == "" || gain > bestGain {
+				best, bestGain = u, gain
+				continue
+			}
+			if b := stats[best]; gain == bestGain && (s.readW > b.readW || (s.readW == b.readW && u < best)) {
+				best = u
+			}
+		}
+		s := stats[best]
+		delete(stats, best)
+		for f := range s.writers {
+			if !covered[f] {
+				covered[f] = true
+				coveredW += weight[f]
+			}
+		}
+		resp.Suggestions = append(resp.Suggestions, RelaySuggestion{
+			URL:                     best,
+			WriteCoverage:           pct(s.writeW),
+			ReadCoverage:            pct(s.readW),
+			CumulativeWriteCoverage: pct(coveredW),
+			FollowsWriting:          s.writeCnt,
+			FollowsReading:          s.readCnt,
+			InYourList:              ownRelays[best],
+		})
+	}
+	return resp
+}
+
+// topFollowsByScore returns up to n of pubkey's follows, highest score first.
+func topFollowsByScore(pubkey string, n int) []string {
+	follows := append([]string(nil), graph.GetFollows(pubkey)...)
+	scores := make(map[string]float64, len(follows))
+	for _, f := range follows {
+		scores[f], _ = graph.GetScore(f)
+	}
+	sort.SliceStable(follows, func(i, j int) bool { return scores[follows[i]] > scores[follows[j]] })
+	if len(follows) > n {
+		follows = follows[:n]
+	}
+	return follows
+}
+
+// handleRelaySuggest serves GET /relay/suggest?pubkey=: relays to use based on where
+// the user's highest-trust follows publish and read, from their NIP-65 relay lists.
+func handleRelaySuggest(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	pubkey := q.HexPubkey("pubkey", true)
+	limit := q.Int("limit", relaySuggestLimit, 1, relaySuggestMax)
+	if q.Failed(w) {
+		return
+	}
+
+	follows := topFollowsByScore(pubkey, relaySuggestFollows)
+	if len(follows) == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusNotFound, Message: "pubkey has no follows in graph"})
+		return
+	}
+	ip := clientIP(r)
+	if res := relaySuggestLimiter.Take(ip); !res.Allowed {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(time.Until(res.Reset).Seconds())+1))
+		writeAPIError(w, &APIError{Status: http.StatusTooManyRequests, Message: "relay suggestion rate limit exceeded"})
+		return
+	}
+
+	lists, err := getRelayLists(r.Context(), append([]string{pubkey}, follows...))
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadGateway, Message: "failed to fetch relay lists"})
+		return
+	}
+	resp := suggestRelays(pubkey, follows, lists, lists[pubkey], limit)
+	resp.GraphSize = graph.Stats().Nodes
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
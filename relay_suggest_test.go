@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestParseRelayList(t *testing.T) {
+	ev := &nostr.Event{Kind: 10002, Tags: nostr.Tags{
+		{"r", "wss://Relay.Damus.io/"},
+		{"r", "wss://relay.damus.io", "read"},
+		{"r", "wss://nos.lol", "write"},
+		{"r", "wss://inbox.example", "read"},
+		{"r", "https://not-a-relay.example"},
+		{"p", padHex(1)},
+	}}
+	list := parseRelayList(ev)
+	if len(list.Read) != 2 || list.Read[0] != "wss://relay.damus.io" || list.Read[1] != "wss://inbox.example" {
+		t.Errorf("unexpected read relays %v", list.Read)
+	}
+	if len(list.Write) != 2 || list.Write[0] != "wss://relay.damus.io" || list.Write[1] != "wss://nos.lol" {
+		t.Errorf("unexpected write relays %v", list.Write)
+	}
+}
+
+func TestHandleRelaySuggest(t *testing.T) {
+	oldGraph, oldFetch, oldLimiter := graph, fetchRelayLists, relaySuggestLimiter
+	t.Cleanup(func() {
+		graph, fetchRelayLists, relaySuggestLimiter = oldGraph, oldFetch, oldLimiter
+		relayLists.Lock()
+		relayLists.data = make(map[string]relayListEntry)
+		relayLists.Unlock()
+	})
+	relayLists.Lock()
+	relayLists.data = make(map[string]relayListEntry)
+	relayLists.Unlock()
+	relaySuggestLimiter = NewRateLimiter(10, time.Minute)
+
+	user, star, small1, small2 := padHex(34000), padHex(34001), padHex(34002), padHex(34003)
+	graph = NewGraph()
+	graph.AddFollow(user, star)
+	graph.AddFollow(user, small1)
+	graph.AddFollow(user, small2)
+	for i := 0; i < 20; i++ {
+		graph.AddFollow(padHex(34100+i), star)
+	}
+	graph.ComputePageRank(20, 0.85)
+
+	relayList := func(tags ...nostr.Tag) *nostr.Event {
+		return &nostr.Event{Kind: 10002, Tags: tags}
+	}
+	fetches := 0
+	fetchRelayLists = func(ctx context.Context, authors []string) (map[string]*nostr.Event, error) {
+		fetches++
+		return map[string]*nostr.Event{
+			user:   relayList(nostr.Tag{"r", "wss://small.example"}),
+			star:   relayList(nostr.Tag{"r", "wss://star.example", "write"}, nostr.Tag{"r", "wss://star-inbox.example", "read"}),
+			small1: relayList(nostr.Tag{"r", "wss://small.example"}),
+			small2: relayList(nostr.Tag{"r", "wss://small.example"}, nostr.Tag{"r", "wss://star.example", "write"}),
+		}, nil
+	}
+
+	get := func(query string) (*httptest.ResponseRecorder, RelaySuggestResponse) {
+		rr := httptest.NewRecorder()
+		handleRelaySuggest(rr, httptest.NewRequest(http.MethodGet, "/relay/suggest?"+query, nil))
+		var resp RelaySuggestResponse
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr, resp
+	}
+
+	rr, resp := get("pubkey=" + user)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if resp.FollowsConsidered != 3 || resp.FollowsWithRelays != 3 || len(resp.Suggestions) != 3 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	// the highly trusted follow's outbox outweighs the relay two small follows share
+	first, second := resp.Suggestions[0], resp.Suggestions[1]
+	if first.URL != "wss://star.example" || first.FollowsWriting != 2 || first.InYourList {
+		t.Errorf("expected star.example first, got %+v", first)
+	}
+	if second.URL != "wss://small.example" || !second.InYourList || second.CumulativeWriteCoverage != 100 {
+		t.Errorf("expected small.example second completing coverage, got %+v", second)
+	}
+	if second.WriteCoverage >= first.WriteCoverage || second.ReadCoverage <= 0 {
+		t.Errorf("unexpected coverage %+v vs %+v", first, second)
+	}
+	if resp.YourRelays == nil || resp.MissingReadCoverage <= 50 {
+		t.Errorf("expected the star's inbox reported missing from the user's list, got %+v", resp)
+	}
+
+	// relay lists are cached
+	get("pubkey=" + user + "&limit=1")
+	if fetches != 1 {
+		t.Errorf("expected cached relay lists, got %d fetches", fetches)
+	}
+
+	if rr, _ := get("pubkey=" + star); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a pubkey without follows, got %d", rr.Code)
+	}
+	if rr, _ := get("pubkey=nobody"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid pubkey, got %d", rr.Code)
+	}
+}
@@ -1,11 +1,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +19,7 @@ type RelayTrustResponse struct {
 	OperatorPubkey string            `json:"operator_pubkey,omitempty"`
 	RelayTrust     *RelayTrustScores `json:"relay_trust,omitempty"`
 	OperatorWoT    *OperatorWoTScore `json:"operator_wot,omitempty"`
+	Network        *RelayRecord      `json:"network,omitempty"` // usage in the graph's NIP-65 lists, from the relay store
 	CombinedScore  int               `json:"combined_score"`
 	Source         string            `json:"source"`
 }
@@ -104,21 +106,20 @@ func fetchTrustedRelayData(relayURL string) (*trustedRelaysAPIResponse, error) {
 	}
 	trustedRelaysCache.mu.RUnlock()
 
-	apiURL := fmt.Sprintf("https://trustedrelays.xyz/api/relay?url=%s", relayURL)
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(apiURL)
+	apiURL := fmt.Sprintf("https://trustedrelays.xyz/api/relay?url=%s", url.QueryEscape(relayURL))
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	resp, err := externalHTTP.Get(ctx, apiURL)
 	if err != nil {
 		return nil, fmt.Errorf("trustedrelays.xyz fetch failed: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("trustedrelays.xyz returned %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("trustedrelays.xyz returned %d: %s", resp.StatusCode, string(resp.Body))
 	}
 
 	var result trustedRelaysAPIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.Unmarshal(resp.Body, &result); err != nil {
 		return nil, fmt.Errorf("decode trustedrelays response: %w", err)
 	}
 
@@ -136,7 +137,7 @@ func fetchTrustedRelayData(relayURL string) (*trustedRelaysAPIResponse, error) {
 func handleRelay(w http.ResponseWriter, r *http.Request) {
 	relayURL := r.URL.Query().Get("url")
 	if relayURL == "" {
-		http.Error(w, `{"error":"url parameter required (e.g., wss://relay.damus.io)"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "url parameter required (e.g., wss://relay.damus.io)"})
 		return
 	}
 
@@ -202,6 +203,27 @@ func handleRelay(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Fall back to the operator named in the relay's own NIP-11 document
+	if rec, ok := relayStore.Get(relayURL); ok {
+		resp.Network = &rec
+		if resp.OperatorWoT == nil && rec.Operator != "" {
+			resp.OperatorPubkey = rec.Operator
+			if resp.Name == "" {
+				resp.Name = rec.Name
+			}
+			followers := 0
+			if m := meta.Get(rec.Operator); m != nil {
+				followers = m.Followers
+			}
+			resp.OperatorWoT = &OperatorWoTScore{
+				Pubkey:    rec.Operator,
+				WoTScore:  rec.OperatorScore,
+				Followers: followers,
+				InGraph:   rec.OperatorInGraph,
+			}
+		}
+	}
+
 	// Compute combined score
 	resp.CombinedScore = computeCombinedScore(resp.RelayTrust, resp.OperatorWoT)
 
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// reportHalfLife is how long a report takes to lose half its weight.
+	reportHalfLife = 90 * 24 * time.Hour
+	// reportSignificantWeight is the trust-weighted total at which reports count
+	// fully against a pubkey in /spam: two reports from score-75 accounts, say.
+	reportSignificantWeight = 1.5
+	// reportMaxReporters caps the reporters listed by /reports.
+	reportMaxReporters = 50
+)
+
+// nip56ReportTypes are the report types NIP-56 defines. Anything else counts as "other".
+var nip56ReportTypes = map[string]bool{
+	"nudity": true, "malware": true, "profanity": true, "illegal": true,
+	"spam": true, "impersonation": true, "other": true,
+}
+
+// ReportStore keeps who reported whom (kind 1984) and when, so reports can push
+// distrust through the graph and be weighed by who made them. MetaStore only
+// counts them.
+type ReportStore struct {
+	mu         sync.RWMutex
+	seen       map[string]bool              // event id
+	reports    map[string]map[string]string // reporter -> target -> report type
+	reportedBy map[string]map[string]string // target -> reporter -> report type
+	reportedAt map[string]map[string]int64  // target -> reporter -> created_at
+}
+
+func NewReportStore() *ReportStore {
+	return &ReportStore{
+		seen:       make(map[string]bool),
+		reports:    make(map[string]map[string]string),
+		reportedBy: make(map[string]map[string]string),
+		reportedAt: make(map[string]map[string]int64),
+	}
+}
+
+var reportStore = NewReportStore()
+
+// Record stores a kind 1984 report against its first p-tagged pubkey. The report
+// type is the third element of that tag, or of an e tag when the report is about
+// a note (NIP-56). Repeat reports of the same target by the same author count
+// once, keeping the newest. Returns false for other events, reports without a
+// target, self-reports and events already seen.
+func (s *ReportStore) Record(ev *nostr.Event) bool {
+	if ev == nil || ev.Kind != 1984 {
+		return false
+	}
+	target, reportType, noteType := "", "", ""
+	for _, tag := range ev.Tags {
+		if len(tag) >= 2 && tag[0] == "p" && target == "" {
+			target = tag[1]
+			if len(tag) >= 3 {
+				reportType = tag[2]
+			}
+		}
+		if len(tag) >= 3 && tag[0] == "e" && noteType == "" {
+			noteType = tag[2]
+		}
+	}
+	if target == "" || target == ev.PubKey {
+		return false
+	}
+	if reportType == "" {
+		reportType = noteType
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen[ev.ID] {
+		return false
+	}
+	s.seen[ev.ID] = true
+	created := int64(ev.CreatedAt)
+	if prev, ok := s.reportedAt[target][ev.PubKey]; ok && prev > created {
+		return true // an older report from the same reporter
+	}
+	if s.reports[ev.PubKey] == nil {
+		s.reports[ev.PubKey] = make(map[string]string)
+	}
+	if s.reportedBy[target] == nil {
+		s.reportedBy[target] = make(map[string]string)
+		s.reportedAt[target] = make(map[string]int64)
+	}
+	s.reports[ev.PubKey][target] = reportType
+	s.reportedBy[target][ev.PubKey] = reportType
+	s.reportedAt[target][ev.PubKey] = created
+	return true
+}
+
+// ReportedBy returns reporter -> report type for target.
+func (s *ReportStore) ReportedBy(target string) map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]string, len(s.reportedBy[target]))
+	for k, v := range s.reportedBy[target] {
+		out[k] = v
+	}
+	return out
+}
+
+// Snapshot returns each reporter's reported targets.
+func (s *ReportStore) Snapshot() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string][]string, len(s.reports))
+	for reporter, targets := range s.reports {
+		for t := range targets {
+			out[reporter] = append(out[reporter], t)
+		}
+	}
+	return out
+}
+
+// ReportDetail is one reporter's report of a pubkey.
+type ReportDetail struct {
+	Reporter      string  `json:"reporter"`
+	Type          string  `json:"type"`
+	ReporterScore int     `json:"reporter_score"`
+	CreatedAt     int64   `json:"created_at,omitempty"`
+	AgeDays       float64 `json:"age_days"`
+	Weight        float64 `json:"weight"` // reporter_score/100, halved every reportHalfLife
+}
+
+// ReportCategory totals the reports of one type.
+type ReportCategory struct {
+	Type    string  `json:"type"`
+	Reports int     `json:"reports"`
+	Weight  float64 `json:"weight"`
+}
+
+// ReportAnalysis is the /reports response.
+type ReportAnalysis struct {
+	Pubkey           string           `json:"pubkey"`
+	Reports          int              `json:"reports"`
+	WeightedReports  float64          `json:"weighted_reports"`
+	TrustedReporters int              `json:"trusted_reporters"` // reporters scoring at least distrustMinSourceScore
+	TopCategory      string           `json:"top_category,omitempty"`
+	Categories       []ReportCategory `json:"categories"`
+	Reporters        []ReportDetail   `json:"reporters"`
+	HalfLifeDays     float64          `json:"half_life_days"`
+	Assessment       string           `json:"assessment"`
+}
+
+// reportWeight is what one report counts for: the reporter's normalized score
+// over 100, halved for every reportHalfLife since it was made. Undated reports
+// don't decay.
+func reportWeight(reporterScore int, createdAt int64, now time.Time) float64 {
+	w := float64(reporterScore) / 100
+	if createdAt > 0 {
+		if age := now.Sub(time.Unix(createdAt, 0)); age > 0 {
+			w *= math.Pow(0.5, float64(age)/float64(reportHalfLife))
+		}
+	}
+	return w
+}
+
+// Analyze weighs the reports of target by who made them and when. g supplies the
+// reporters' scores.
+func (s *ReportStore) Analyze(target string, g *Graph, now time.Time) ReportAnalysis {
+	s.mu.RLock()
+	details := make([]ReportDetail, 0, len(s.reportedBy[target]))
+	for reporter, reportType := range s.reportedBy[target] {
+		details = append(details, ReportDetail{Reporter: reporter, Type: reportType, CreatedAt: s.reportedAt[target][reporter]})
+	}
+	s.mu.RUnlock()
+
+	a := ReportAnalysis{
+		Pubkey:       target,
+		Reports:      len(details),
+		Categories:   []ReportCategory{},
+		HalfLifeDays: reportHalfLife.Hours() / 24,
+	}
+	nodes := g.NodeCount()
+	byType := make(map[string]*ReportCategory)
+	for i := range details {
+		d := &details[i]
+		if !nip56ReportTypes[d.Type] {
+			d.Type = "other"
+		}
+		raw, _ := g.GetScore(d.Reporter)
+		d.ReporterScore = normalizeScore(raw, nodes)
+		if d.CreatedAt > 0 {
+			d.AgeDays = math.Round(now.Sub(time.Unix(d.CreatedAt, 0)).Hours()/24*10) / 10
+		}
+		d.Weight = math.Round(reportWeight(d.ReporterScore, d.CreatedAt, now)*1000) / 1000
+		if d.ReporterScore >= distrustMinSourceScore {
+			a.TrustedReporters++
+		}
+		a.WeightedReports += d.Weight
+		c := byType[d.Type]
+		if c == nil {
+			c = &ReportCategory{Type: d.Type}
+			byType[d.Type] = c
+		}
+		c.Reports++
+		c.Weight += d.Weight
+	}
+	a.WeightedReports = math.Round(a.WeightedReports*1000) / 1000
+
+	for _, c := range byType {
+		c.Weight = math.Round(c.Weight*1000) / 1000
+		a.Categories = append(a.Categories, *c)
+	}
+	sort.Slice(a.Categories, func(i, j int) bool {
+		if a.Categories[i].Weight != a.Categories[j].Weight {
+			return a.Categories[i].Weight > a.Categories[j].Weight
+		}
+		if a.Categories[i].Reports != a.Categories[j].Reports {
+			return a.Categories[i].Reports > a.Categories[j].Reports
+		}
+		return a.Categories[i].Type < a.Categories[j].Type
+	})
+	if len(a.Categories) > 0 {
+		a.TopCategory = a.Categories[0].Type
+	}
+
+	sort.Slice(details, func(i, j int) bool {
+		if details[i].Weight != details[j].Weight {
+			return details[i].Weight > details[j].Weight
+		}
+		return details[i].Reporter < details[j].Reporter
+	})
+	if len(details) > reportMaxReporters {
+		details = details[:reportMaxReporters]
+	}
+	a.Reporters = details
+	a.Assessment = reportAssessment(a.Reports, a.WeightedReports)
+	return a
+}
+
+// reportAssessment sums up a pubkey's reports: none, untrusted (reported, but
+// only by accounts whose reports carry next to no weight), minor or significant.
+func reportAssessment(reports int, weighted float64) string {
+	switch {
+	case reports == 0:
+		return "none"
+	case weighted >= reportSignificantWeight:
+		return "significant"
+	case weighted < 0.05:
+		return "untrusted"
+	default:
+		return "minor"
+	}
+}
+
+// handleReports serves GET /reports?pubkey=: the kind 1984 reports against a
+// pubkey, by category, each weighted by the reporter's score and its age.
+func handleReports(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reportStore.Analyze(pubkey, graph.Snapshot(), time.Now()))
+}
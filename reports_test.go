@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestReportStoreRecord(t *testing.T) {
+	s := NewReportStore()
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	target := padHex(39001)
+	now := time.Now()
+
+	first := signedEvent(t, sk, 1984, now, nostr.Tag{"p", target, "spam"})
+	again := signedEvent(t, sk, 1984, now.Add(time.Second), nostr.Tag{"p", target, "impersonation"})
+	if !s.Record(first) || !s.Record(again) {
+		t.Fatal("expected both reports recorded")
+	}
+	if s.Record(first) || s.Record(signedEvent(t, sk, 1984, now, nostr.Tag{"p", pub})) || s.Record(signedEvent(t, sk, 1, now, nostr.Tag{"p", target})) {
+		t.Error("expected duplicates, self-reports and other kinds ignored")
+	}
+	if by := s.ReportedBy(target); len(by) != 1 || by[pub] != "impersonation" {
+		t.Errorf("expected one reporter with the latest type, got %v", by)
+	}
+	if snap := s.Snapshot(); len(snap[pub]) != 1 {
+		t.Errorf("expected repeat reports of one target counted once, got %v", snap)
+	}
+}
+
+func TestReportStoreRecordNoteReports(t *testing.T) {
+	s := NewReportStore()
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	target := padHex(49001)
+	now := time.Now()
+
+	// a note report carries its type on the e tag
+	newer := signedEvent(t, sk, 1984, now, nostr.Tag{"e", padHex(49002), "malware"}, nostr.Tag{"p", target})
+	older := signedEvent(t, sk, 1984, now.Add(-time.Hour), nostr.Tag{"p", target, "spam"})
+	s.Record(newer)
+	s.Record(older)
+	if by := s.ReportedBy(target); by[pub] != "malware" {
+		t.Errorf("expected the newer note report's type kept, got %v", by)
+	}
+}
+
+// reportGraph builds a graph where trusted is well followed and newcomer has no
+// followers, and returns it scored.
+func reportGraph() (g *Graph, trusted, newcomer string) {
+	g = NewGraph()
+	trusted, newcomer = padHex(49100), padHex(49101)
+	for i := 0; i < 30; i++ {
+		g.AddFollow(padHex(49200+i), trusted)
+		g.AddFollow(padHex(49200+i), padHex(49200+(i+1)%30))
+	}
+	g.AddFollow(newcomer, trusted)
+	g.ComputePageRank(30, 0.85)
+	return g, trusted, newcomer
+}
+
+func TestReportAnalysisWeighsByTrustAndAge(t *testing.T) {
+	g, trusted, newcomer := reportGraph()
+	target := padHex(49300)
+	now := time.Unix(1_700_000_000, 0)
+	s := NewReportStore()
+	s.reportedBy[target] = map[string]string{trusted: "spam", newcomer: "spam", padHex(49201): "made-up"}
+	s.reportedAt[target] = map[string]int64{
+		trusted:       now.Unix(),
+		newcomer:      now.Unix(),
+		padHex(49201): now.Add(-reportHalfLife).Unix(),
+	}
+
+	a := s.Analyze(target, g, now)
+	if a.Reports != 3 {
+		t.Fatalf("expected 3 reports, got %d", a.Reports)
+	}
+	weights := map[string]ReportDetail{}
+	for _, d := range a.Reporters {
+		weights[d.Reporter] = d
+	}
+	top := weights[trusted]
+	if top.ReporterScore == 0 || top.Weight != math.Round(float64(top.ReporterScore)/100*1000)/1000 {
+		t.Errorf("expected a fresh report weighted by the reporter's score, got %+v", top)
+	}
+	if weights[newcomer].Weight >= top.Weight {
+		t.Errorf("expected the newcomer's report to weigh less, got %+v vs %+v", weights[newcomer], top)
+	}
+	old := weights[padHex(49201)]
+	want := math.Round(float64(old.ReporterScore)/100/2*1000) / 1000
+	if old.Type != "other" || math.Abs(old.Weight-want) > 0.001 || old.AgeDays != 90 {
+		t.Errorf("expected a 90-day-old unknown-type report at half weight %v, got %+v", want, old)
+	}
+	if a.Reporters[0].Reporter != trusted || a.TopCategory != "spam" || len(a.Categories) != 2 {
+		t.Errorf("unexpected ordering %+v", a)
+	}
+	if a.Categories[0].Reports != 2 {
+		t.Errorf("expected 2 spam reports, got %+v", a.Categories[0])
+	}
+}
+
+func TestReportAssessment(t *testing.T) {
+	cases := []struct {
+		reports  int
+		weighted float64
+		want     string
+	}{
+		{0, 0, "none"},
+		{12, 0.01, "untrusted"},
+		{2, 0.6, "minor"},
+		{2, 1.5, "significant"},
+	}
+	for _, c := range cases {
+		if got := reportAssessment(c.reports, c.weighted); got != c.want {
+			t.Errorf("reportAssessment(%d, %v) = %s, want %s", c.reports, c.weighted, got, c.want)
+		}
+	}
+}
+
+func TestSpamSignalReportsWeighted(t *testing.T) {
+	if s := spamSignalReports(20, 0.01); s.Score != 0 || !strings.Contains(s.Reason, "little trust") {
+		t.Errorf("expected untrusted mass reports ignored, got %+v", s)
+	}
+	if s := spamSignalReports(1, 0.75); s.Score != 0.075 {
+		t.Errorf("expected half the weight at half the significant total, got %+v", s)
+	}
+}
+
+func TestHandleReports(t *testing.T) {
+	oldGraph, oldReports := graph, reportStore
+	defer func() { graph, reportStore = oldGraph, oldReports }()
+	g, trusted, _ := reportGraph()
+	graph, reportStore = g, NewReportStore()
+	target := padHex(49400)
+	sk := nostr.GeneratePrivateKey()
+	reportStore.Record(signedEvent(t, sk, 1984, time.Now(), nostr.Tag{"p", target, "impersonation"}))
+	reportStore.reportedBy[target][trusted] = "impersonation"
+	reportStore.reportedAt[target][trusted] = time.Now().Unix()
+
+	w := httptest.NewRecorder()
+	handleReports(w, httptest.NewRequest("GET", "/reports?pubkey="+target, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var a ReportAnalysis
+	json.NewDecoder(w.Body).Decode(&a)
+	if a.Reports != 2 || a.TopCategory != "impersonation" || a.TrustedReporters != 1 || a.HalfLifeDays != 90 {
+		t.Errorf("unexpected analysis %+v", a)
+	}
+
+	w = httptest.NewRecorder()
+	handleReports(w, httptest.NewRequest("GET", "/reports", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a pubkey, got %d", w.Code)
+	}
+}
@@ -42,25 +42,24 @@ type ReputationResponse struct {
 // handleReputation computes a comprehensive reputation profile for a pubkey.
 // GET /reputation?pubkey=<hex|npub>
 func handleReputation(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
+	locale, ok := requestLocale(w, r)
+	if !ok {
 		return
 	}
-
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
 		return
 	}
 
-	stats := graph.Stats()
-	rawScore, found := graph.GetScore(pubkey)
+	stats := g.Stats()
+	rawScore, found := g.GetScore(pubkey)
 	score := normalizeScore(rawScore, stats.Nodes)
-	percentile := graph.Percentile(pubkey)
+	percentile := g.Percentile(pubkey)
 
-	follows := graph.GetFollows(pubkey)
-	followers := graph.GetFollowers(pubkey)
+	follows := g.GetFollows(pubkey)
+	followers := g.GetFollowers(pubkey)
 
 	followSet := make(map[string]bool, len(follows))
 	for _, f := range follows {
@@ -79,7 +78,7 @@ func handleReputation(w http.ResponseWriter, r *http.Request) {
 	followerScoreSum := 0
 	scoredFollowers := 0
 	for _, f := range followers {
-		fRaw, ok := graph.GetScore(f)
+		fRaw, ok := g.GetScore(f)
 		if ok {
 			followerScoreSum += normalizeScore(fRaw, stats.Nodes)
 			scoredFollowers++
@@ -96,7 +95,7 @@ func handleReputation(w http.ResponseWriter, r *http.Request) {
 	for _, f := range followers {
 		if followSet[f] {
 			mutualCount++
-			fRaw, ok := graph.GetScore(f)
+			fRaw, ok := g.GetScore(f)
 			if ok && normalizeScore(fRaw, stats.Nodes) > 50 {
 				highValueMutuals++
 			}
@@ -141,7 +140,7 @@ func handleReputation(w http.ResponseWriter, r *http.Request) {
 		communityScoreSum := 0
 		scoredMembers := 0
 		for _, m := range members {
-			if mRaw, ok := graph.GetScore(m); ok {
+			if mRaw, ok := g.GetScore(m); ok {
 				communityScoreSum += normalizeScore(mRaw, stats.Nodes)
 				scoredMembers++
 			}
@@ -230,7 +229,7 @@ func handleReputation(w http.ResponseWriter, r *http.Request) {
 	grade := gradeFromScoreInt(reputationScore)
 	classification := classifyReputation(reputationScore)
 	confidence := computeConfidence(len(followers), len(follows), found, scoredFollowers)
-	summary := buildReputationSummary(pubkey, reputationScore, grade, score, anomalyCount, communitySize)
+	summary := buildReputationSummary(locale, pubkey, reputationScore, grade, score, anomalyCount, communitySize)
 
 	resp := ReputationResponse{
 		Pubkey:          pubkey,
@@ -385,19 +384,19 @@ func classifyReputation(score int) string {
 	}
 }
 
-func buildReputationSummary(pubkey string, score int, grade string, wotScore int, anomalies int, communitySize int) string {
+func buildReputationSummary(locale, pubkey string, score int, grade string, wotScore int, anomalies int, communitySize int) string {
 	short := pubkey
 	if len(short) > 12 {
 		short = short[:8] + "..." + short[len(short)-4:]
 	}
 
-	anomalyStr := "no anomalies"
+	anomalyStr := tr(locale, "no anomalies")
 	if anomalies == 1 {
-		anomalyStr = "1 anomaly flag"
+		anomalyStr = tr(locale, "1 anomaly flag")
 	} else if anomalies > 1 {
-		anomalyStr = fmt.Sprintf("%d anomaly flags", anomalies)
+		anomalyStr = tr(locale, "%d anomaly flags", anomalies)
 	}
 
-	return fmt.Sprintf("%s: Grade %s (%d/100) — WoT score %d, %s, community of %d",
+	return tr(locale, "%s: Grade %s (%d/100) — WoT score %d, %s, community of %d",
 		short, grade, score, wotScore, anomalyStr, communitySize)
 }
@@ -254,7 +254,7 @@ func TestReputation_GradeFromFloat(t *testing.T) {
 }
 
 func TestReputation_Summary(t *testing.T) {
-	summary := buildReputationSummary("abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890", 75, "B", 50, 1, 25)
+	summary := buildReputationSummary("en", "abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890", 75, "B", 50, 1, 25)
 	if summary == "" {
 		t.Error("expected non-empty summary")
 	}
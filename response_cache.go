@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+)
+
+// responseCacheMaxEntries caps the responses kept per build.
+const responseCacheMaxEntries = 10000
+
+// cachedResponse is one rendered 200 response.
+type cachedResponse struct {
+	contentType string
+	body        []byte
+}
+
+// ResponseCache keeps rendered GET responses keyed on (path, query, build ETag).
+// Scores only change when the build does, so repeat requests for the same pubkey
+// are served from memory until the next rebuild or live write. It wraps a handler
+// directly, inside the paywall and analytics, so a cache hit is still counted and
+// charged. Nothing is cached before the first build.
+type ResponseCache struct {
+	mu      sync.Mutex
+	build   *GraphBuild
+	etag    string // build the entries belong to
+	entries map[string]cachedResponse
+	hits    int64
+	misses  int64
+}
+
+func NewResponseCache(build *GraphBuild) *ResponseCache {
+	return &ResponseCache{build: build, entries: make(map[string]cachedResponse)}
+}
+
+var scoreResponseCache = NewResponseCache(graphBuild)
+
+func (c *ResponseCache) get(key, etag string) (cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.etag != etag {
+		c.etag = etag
+		c.entries = make(map[string]cachedResponse)
+	}
+	resp, ok := c.entries[key]
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return resp, ok
+}
+
+func (c *ResponseCache) put(key, etag string, resp cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.etag != etag {
+		return // the build moved on while the response was rendered
+	}
+	if len(c.entries) >= responseCacheMaxEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = resp
+}
+
+// Stats returns the cache's size and hit counts.
+func (c *ResponseCache) Stats() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return map[string]interface{}{
+		"entries": len(c.entries),
+		"hits":    c.hits,
+		"misses":  c.misses,
+	}
+}
+
+// responseCapture buffers a response so it can be cached and replayed.
+type responseCapture struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rc *responseCapture) WriteHeader(code int) {
+	rc.status = code
+	rc.ResponseWriter.WriteHeader(code)
+}
+
+func (rc *responseCapture) Write(p []byte) (int, error) {
+	rc.body.Write(p)
+	return rc.ResponseWriter.Write(p)
+}
+
+// Wrap serves GET requests for next from the cache, rendering and storing 200
+// responses on a miss. The key uses the sorted query, so parameter order doesn't
+// split entries.
+func (c *ResponseCache) Wrap(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _, _ := c.build.Current()
+		if r.Method != http.MethodGet || id == 0 || seedViewRequested(r) {
+			next(w, r)
+			return
+		}
+		etag := c.build.ETag()
+		key := r.URL.Path + "?" + r.URL.Query().Encode()
+		if resp, ok := c.get(key, etag); ok {
+			w.Header().Set("Content-Type", resp.contentType)
+			w.Write(resp.body)
+			return
+		}
+		rc := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+		next(rc, r)
+		if rc.status == http.StatusOK {
+			c.put(key, etag, cachedResponse{contentType: w.Header().Get("Content-Type"), body: rc.body.Bytes()})
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	b := NewGraphBuild()
+	c := NewResponseCache(b)
+	calls := 0
+	h := c.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("pubkey") == "" {
+			http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"calls":` + strconv.Itoa(calls) + `}`))
+	})
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	get("/score?pubkey=a")
+	get("/score?pubkey=a")
+	if calls != 2 {
+		t.Fatalf("expected nothing cached before the first build, got %d calls", calls)
+	}
+
+	b.Advance(time.Now())
+	first := get("/score?pubkey=a&format=npub")
+	second := get("/score?format=npub&pubkey=a")
+	if calls != 3 || second.Body.String() != first.Body.String() || second.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("expected the reordered query served from cache, got %d calls, %q vs %q", calls, second.Body.String(), first.Body.String())
+	}
+	if get("/score?pubkey=b"); calls != 4 {
+		t.Errorf("expected a different pubkey to miss, got %d calls", calls)
+	}
+
+	get("/score")
+	get("/score")
+	if calls != 6 {
+		t.Errorf("expected errors not cached, got %d calls", calls)
+	}
+
+	b.Touch()
+	if get("/score?pubkey=a&format=npub"); calls != 7 {
+		t.Errorf("expected a new revision to invalidate the cache, got %d calls", calls)
+	}
+	if s := c.Stats(); s["entries"] != 1 || s["hits"].(int64) != 1 {
+		t.Errorf("unexpected stats %v", s)
+	}
+}
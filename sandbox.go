@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	sandboxMaxEdges      = 5000
+	sandboxMaxIDLen      = 64
+	sandboxMaxIterations = 100
+)
+
+// SandboxEdge is one follow in a /sandbox/score graph. CreatedAt (unix seconds) is
+// only used by the decay scores; edges without it count at full weight.
+type SandboxEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+}
+
+// SandboxRequest is the body for POST /sandbox/score. Zero parameters take the
+// production defaults.
+type SandboxRequest struct {
+	Edges               []SandboxEdge `json:"edges"`
+	Damping             float64       `json:"damping"`
+	Iterations          int           `json:"iterations"`
+	HalfLifeDays        float64       `json:"half_life_days"`
+	CommunityIterations int           `json:"community_iterations"`
+	Viewer              string        `json:"viewer,omitempty"`
+}
+
+// SandboxParams are the parameters a sandbox run used.
+type SandboxParams struct {
+	Damping             float64 `json:"damping"`
+	Iterations          int     `json:"iterations"`
+	HalfLifeDays        float64 `json:"half_life_days"`
+	CommunityIterations int     `json:"community_iterations"`
+	Viewer              string  `json:"viewer,omitempty"`
+}
+
+// SandboxNode is one node's results in a sandbox run.
+type SandboxNode struct {
+	ID          string   `json:"id"`
+	Rank        int      `json:"rank"`
+	Score       int      `json:"score"`
+	PageRank    float64  `json:"pagerank"`
+	DecayScore  int      `json:"decay_score"`
+	DecayRaw    float64  `json:"decay_pagerank"`
+	PPR         *float64 `json:"ppr,omitempty"`
+	Follows     int      `json:"follows"`
+	Followers   int      `json:"followers"`
+	CommunityID int      `json:"community_id"` // -1 for nodes that follow no one
+}
+
+// SandboxResponse is the response for POST /sandbox/score.
+type SandboxResponse struct {
+	Nodes         []SandboxNode `json:"nodes"` // by rank
+	NodeCount     int           `json:"node_count"`
+	EdgeCount     int           `json:"edge_count"`
+	IgnoredEdges  int           `json:"ignored_edges"` // duplicates and self-follows
+	Communities   int           `json:"communities"`
+	Parameters    SandboxParams `json:"parameters"`
+	ComputeTimeMs int64         `json:"compute_time_ms"`
+}
+
+// runSandbox scores a user-supplied graph with the production PageRank, decay,
+// personalized PageRank and community detection code. Nothing touches the served
+// graph.
+func runSandbox(req SandboxRequest, p SandboxParams) SandboxResponse {
+	start := time.Now()
+	g := NewGraph()
+	seen := make(map[[2]string]bool, len(req.Edges))
+	resp := SandboxResponse{Parameters: p}
+	for _, e := range req.Edges {
+		key := [2]string{e.From, e.To}
+		if e.From == e.To || seen[key] {
+			resp.IgnoredEdges++
+			continue
+		}
+		seen[key] = true
+		var at time.Time
+		if e.CreatedAt > 0 {
+			at = time.Unix(e.CreatedAt, 0)
+		}
+		g.AddFollowWithTime(e.From, e.To, at)
+		resp.EdgeCount++
+	}
+
+	g.ComputePageRank(p.Iterations, p.Damping)
+	decay := g.ComputeDecayedPageRank(p.Iterations, p.Damping, p.HalfLifeDays)
+	var ppr map[string]float64
+	if p.Viewer != "" {
+		ppr = g.PersonalizedPageRank(p.Viewer, p.Iterations, p.Damping)
+	}
+	cd := NewCommunityDetector()
+	cd.DetectCommunities(g, p.CommunityIterations)
+
+	scores := g.ScoresSnapshot()
+	n := len(scores)
+	resp.NodeCount = n
+	resp.Communities = cd.TotalCommunities()
+	resp.Nodes = make([]SandboxNode, 0, n)
+	for id, raw := range scores {
+		node := SandboxNode{
+			ID:          id,
+			Score:       normalizeScore(raw, n),
+			PageRank:    raw,
+			DecayScore:  normalizeScore(decay[id], n),
+			DecayRaw:    decay[id],
+			Follows:     len(g.GetFollows(id)),
+			Followers:   len(g.GetFollowers(id)),
+			CommunityID: -1,
+		}
+		if ppr != nil {
+			v := ppr[id]
+			node.PPR = &v
+		}
+		if c, ok := cd.GetCommunity(id); ok {
+			node.CommunityID = c
+		}
+		resp.Nodes = append(resp.Nodes, node)
+	}
+	sort.Slice(resp.Nodes, func(i, j int) bool {
+		if resp.Nodes[i].PageRank != resp.Nodes[j].PageRank {
+			return resp.Nodes[i].PageRank > resp.Nodes[j].PageRank
+		}
+		return resp.Nodes[i].ID < resp.Nodes[j].ID
+	})
+	for i := range resp.Nodes {
+		resp.Nodes[i].Rank = i + 1
+	}
+	resp.ComputeTimeMs = time.Since(start).Milliseconds()
+	return resp
+}
+
+// sandboxParams validates req's parameters and fills in the production defaults.
+func sandboxParams(req SandboxRequest) (SandboxParams, error) {
+	cfg := config.Get()
+	p := SandboxParams{Damping: cfg.Damping, Iterations: cfg.PageRankIterations, HalfLifeDays: 365, CommunityIterations: 10, Viewer: req.Viewer}
+	if req.Damping != 0 {
+		if req.Damping <= 0 || req.Damping >= 1 {
+			return p, fmt.Errorf("damping must be between 0 and 1")
+		}
+		p.Damping = req.Damping
+	}
+	if req.Iterations != 0 {
+		if req.Iterations < 1 || req.Iterations > sandboxMaxIterations {
+			return p, fmt.Errorf("iterations must be between 1 and %d", sandboxMaxIterations)
+		}
+		p.Iterations = req.Iterations
+	}
+	if req.HalfLifeDays != 0 {
+		if req.HalfLifeDays < 1 || req.HalfLifeDays > 3650 {
+			return p, fmt.Errorf("half_life_days must be between 1 and 3650")
+		}
+		p.HalfLifeDays = req.HalfLifeDays
+	}
+	if req.CommunityIterations != 0 {
+		if req.CommunityIterations < 1 || req.CommunityIterations > sandboxMaxIterations {
+			return p, fmt.Errorf("community_iterations must be between 1 and %d", sandboxMaxIterations)
+		}
+		p.CommunityIterations = req.CommunityIterations
+	}
+	return p, nil
+}
+
+// handleSandboxScore serves POST /sandbox/score: developers supply a small edge list
+// and get PageRank, decay, community (and, with a viewer, personalized PageRank)
+// results for that graph alone, computed by the same code that scores the network.
+// Node IDs are free-form strings so examples can use names instead of pubkeys.
+func handleSandboxScore(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req SandboxRequest
+	if apiErr := bindJSON(w, r, &req, 1<<20); apiErr != nil {
+		writeAPIError(w, apiErr)
+		return
+	}
+	if len(req.Edges) == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "edges", Message: "edges array required"})
+		return
+	}
+	if len(req.Edges) > sandboxMaxEdges {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("maximum %d edges per sandbox graph", sandboxMaxEdges)})
+		return
+	}
+	for i, e := range req.Edges {
+		if e.From == "" || e.To == "" || len(e.From) > sandboxMaxIDLen || len(e.To) > sandboxMaxIDLen {
+			writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("edge %d: from and to must be 1-%d characters", i, sandboxMaxIDLen)})
+			return
+		}
+	}
+	p, err := sandboxParams(req)
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runSandbox(req, p))
+}
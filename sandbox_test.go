@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func postSandbox(body string) (*httptest.ResponseRecorder, SandboxResponse) {
+	rr := httptest.NewRecorder()
+	handleSandboxScore(rr, httptest.NewRequest(http.MethodPost, "/sandbox/score", bytes.NewBufferString(body)))
+	var resp SandboxResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+func TestSandboxScoreMatchesProduction(t *testing.T) {
+	oldGraph := graph
+	defer func() { graph = oldGraph }()
+	graph = NewGraph()
+
+	rr, resp := postSandbox(`{"edges":[
+		{"from":"alice","to":"bob"},
+		{"from":"bob","to":"carol"},
+		{"from":"carol","to":"bob","created_at":1},
+		{"from":"carol","to":"dave"},
+		{"from":"frank","to":"bob"},
+		{"from":"alice","to":"bob"},
+		{"from":"erin","to":"erin"}
+	],"viewer":"alice"}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if resp.EdgeCount != 5 || resp.IgnoredEdges != 2 || resp.NodeCount != 5 {
+		t.Fatalf("unexpected counts %+v", resp)
+	}
+	if resp.Parameters.Damping != 0.85 || resp.Parameters.Iterations != 20 || resp.Parameters.HalfLifeDays != 365 {
+		t.Errorf("expected production defaults, got %+v", resp.Parameters)
+	}
+
+	// the same graph scored by the production code directly
+	g := NewGraph()
+	g.AddFollow("alice", "bob")
+	g.AddFollow("bob", "carol")
+	g.AddFollow("carol", "bob")
+	g.AddFollow("carol", "dave")
+	g.AddFollow("frank", "bob")
+	g.ComputePageRank(20, 0.85)
+	for i, n := range resp.Nodes {
+		if want, _ := g.GetScore(n.ID); n.PageRank != want || n.Rank != i+1 {
+			t.Errorf("expected %s at rank %d with the production score %f, got %+v", n.ID, i+1, want, n)
+		}
+		if i > 0 && n.PageRank > resp.Nodes[i-1].PageRank {
+			t.Errorf("expected nodes ordered by pagerank, got %s above %s", resp.Nodes[i-1].ID, n.ID)
+		}
+		if n.ID == "bob" && n.Followers != 3 {
+			t.Errorf("expected bob to have 3 followers, got %d", n.Followers)
+		}
+		if n.PPR == nil {
+			t.Fatalf("expected ppr for every node with a viewer, got %+v", n)
+		}
+		// carol -> bob is ancient, so carol's trust flows to dave instead
+		if n.ID == "bob" && n.DecayRaw >= n.PageRank {
+			t.Errorf("expected decay to lower bob's score, got %f >= %f", n.DecayRaw, n.PageRank)
+		}
+		if n.ID == "frank" && *n.PPR != 0 {
+			t.Errorf("frank is unreachable from alice, got ppr %f", *n.PPR)
+		}
+	}
+	if len(graph.GetFollows("alice")) != 0 {
+		t.Error("sandbox must not touch the served graph")
+	}
+}
+
+func TestSandboxScoreValidation(t *testing.T) {
+	var big strings.Builder
+	big.WriteString(`{"edges":[`)
+	for i := 0; i <= sandboxMaxEdges; i++ {
+		if i > 0 {
+			big.WriteString(",")
+		}
+		fmt.Fprintf(&big, `{"from":"a%d","to":"b"}`, i)
+	}
+	big.WriteString(`]}`)
+
+	for name, body := range map[string]string{
+		"invalid json":   `{`,
+		"no edges":       `{"edges":[]}`,
+		"too many edges": big.String(),
+		"empty id":       `{"edges":[{"from":"","to":"b"}]}`,
+		"long id":        `{"edges":[{"from":"` + strings.Repeat("x", 65) + `","to":"b"}]}`,
+		"damping":        `{"edges":[{"from":"a","to":"b"}],"damping":1.5}`,
+		"iterations":     `{"edges":[{"from":"a","to":"b"}],"iterations":1000}`,
+		"half life":      `{"edges":[{"from":"a","to":"b"}],"half_life_days":0.5}`,
+	} {
+		if rr, _ := postSandbox(body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handleSandboxScore(rr, httptest.NewRequest(http.MethodGet, "/sandbox/score", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxScoreHistoryBuilds is how many rebuilds of score history are kept (30 days of
+// 6-hourly rebuilds).
+const maxScoreHistoryBuilds = 120
+
+// ScoreHistoryPoint is a pubkey's standing at one rebuild.
+type ScoreHistoryPoint struct {
+	Build     uint64 `json:"build"`
+	At        string `json:"at"`
+	Score     int    `json:"score"`
+	Rank      int    `json:"rank"`
+	Followers int    `json:"followers"`
+}
+
+// scoreHistoryEntry is a stored change: the pubkey's values from build on, until
+// the next entry. gone marks a build the pubkey was no longer scored at. Fields are
+// kept small since there can be one per pubkey per build.
+type scoreHistoryEntry struct {
+	build     uint32
+	rank      uint32
+	followers uint32
+	score     uint8
+	gone      bool
+}
+
+type scoreHistoryBuild struct {
+	build uint64
+	at    time.Time
+}
+
+// ScoreHistory records every pubkey's normalized score, rank and follower count at
+// each rebuild. Like CommunityHistory it only stores changes, so a pubkey whose
+// standing holds steady costs one entry however many builds pass.
+type ScoreHistory struct {
+	mu      sync.RWMutex
+	builds  []scoreHistoryBuild // oldest first
+	entries map[string][]scoreHistoryEntry
+}
+
+func NewScoreHistory() *ScoreHistory {
+	return &ScoreHistory{entries: make(map[string][]scoreHistoryEntry)}
+}
+
+var scoreHistory = NewScoreHistory()
+
+// Record snapshots g's scores as build. Ranks are 1 + the number of higher scores,
+// as in Graph.Rank.
+func (h *ScoreHistory) Record(g *Graph, build uint64, at time.Time) {
+	scores := g.ScoresSnapshot()
+	sorted := make([]ScoreEntry, 0, len(scores))
+	for pk, s := range scores {
+		sorted = append(sorted, ScoreEntry{Pubkey: pk, Score: s})
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.builds = append(h.builds, scoreHistoryBuild{build: build, at: at})
+	if len(h.builds) > maxScoreHistoryBuilds {
+		h.builds = h.builds[len(h.builds)-maxScoreHistoryBuilds:]
+	}
+	oldest := uint32(h.builds[0].build)
+
+	seen := make(map[string]bool, len(sorted))
+	rank := 0
+	for i, e := range sorted {
+		if i == 0 || e.Score < sorted[i-1].Score {
+			rank = i + 1
+		}
+		seen[e.Pubkey] = true
+		h.add(e.Pubkey, scoreHistoryEntry{
+			build:     uint32(build),
+			rank:      uint32(rank),
+			followers: uint32(len(g.GetFollowers(e.Pubkey))),
+			score:     uint8(normalizeScore(e.Score, len(sorted))),
+		}, oldest)
+	}
+	// also prunes pubkeys that left the graph, and forgets them once they're
+	// gone from every retained build
+	for pk := range h.entries {
+		if !seen[pk] {
+			h.add(pk, scoreHistoryEntry{build: uint32(build), gone: true}, oldest)
+		}
+	}
+}
+
+// add appends e to pubkey's entries if anything changed, then drops entries that
+// no retained build needs. Must be called with h.mu held.
+func (h *ScoreHistory) add(pubkey string, e scoreHistoryEntry, oldest uint32) {
+	list := h.entries[pubkey]
+	if n := len(list); n > 0 {
+		last := list[n-1]
+		last.build = e.build
+		if last != e {
+			list = append(list, e)
+		}
+	} else {
+		list = append(list, e)
+	}
+	// the entry in effect at the oldest retained build is the first one kept
+	for len(list) > 1 && list[1].build <= oldest {
+		list = list[1:]
+	}
+	if len(list) == 1 && list[0].gone && list[0].build <= oldest {
+		delete(h.entries, pubkey)
+		return
+	}
+	h.entries[pubkey] = list
+}
+
+// Points returns pubkey's standing at every retained build at or after since,
+// oldest first, skipping builds it wasn't scored at. ok is false when nothing was
+// ever recorded for pubkey.
+func (h *ScoreHistory) Points(pubkey string, since time.Time) (points []ScoreHistoryPoint, ok bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	list := h.entries[pubkey]
+	if len(list) == 0 {
+		return nil, false
+	}
+	points = []ScoreHistoryPoint{}
+	i := -1
+	for _, b := range h.builds {
+		for i+1 < len(list) && uint64(list[i+1].build) <= b.build {
+			i++
+		}
+		if i < 0 || list[i].gone || b.at.Before(since) {
+			continue
+		}
+		e := list[i]
+		points = append(points, ScoreHistoryPoint{
+			Build:     b.build,
+			At:        b.at.UTC().Format(time.RFC3339),
+			Score:     int(e.score),
+			Rank:      int(e.rank),
+			Followers: int(e.followers),
+		})
+	}
+	return points, true
+}
+
+// Builds returns how many rebuilds are retained and when the oldest ran.
+func (h *ScoreHistory) Builds() (n int, oldest time.Time) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.builds) == 0 {
+		return 0, time.Time{}
+	}
+	return len(h.builds), h.builds[0].at
+}
+
+// handleHistory serves GET /history?pubkey=&since=: the pubkey's normalized score,
+// rank and follower count at each recorded rebuild, oldest first. since (unix
+// seconds or RFC 3339) drops earlier rebuilds.
+func handleHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	raw := q.Get("pubkey")
+	if raw == "" {
+		writeAPIError(w, missingParam("pubkey"))
+		return
+	}
+	pubkey, err := resolvePubkey(raw)
+	if err != nil || !hex64Pattern.MatchString(pubkey) {
+		writeAPIError(w, invalidParam("pubkey", "pubkey must be 64 hex characters or an npub"))
+		return
+	}
+	var since time.Time
+	if v := q.Get("since"); v != "" {
+		if since, err = parseContactTime(v); err != nil {
+			writeAPIError(w, invalidParam("since", "since must be unix seconds or RFC 3339"))
+			return
+		}
+	}
+
+	points, ok := scoreHistory.Points(pubkey, since)
+	if !ok {
+		writeAPIError(w, &APIError{Status: http.StatusNotFound, Message: "no score history recorded for pubkey"})
+		return
+	}
+	builds, oldest := scoreHistory.Builds()
+	resp := map[string]interface{}{
+		"pubkey":          pubkey,
+		"points":          points,
+		"builds_recorded": builds,
+		"oldest_build_at": oldest.UTC().Format(time.RFC3339),
+	}
+	if len(points) > 0 {
+		first, last := points[0], points[len(points)-1]
+		resp["score_change"] = last.Score - first.Score
+		resp["rank_change"] = first.Rank - last.Rank // positive = moved up
+		resp["followers_change"] = last.Followers - first.Followers
+	}
+	if !since.IsZero() {
+		resp["since"] = since.UTC().Format(time.RFC3339)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestScoreHistoryRecord(t *testing.T) {
+	h := NewScoreHistory()
+	star, fan, leaver := padHex(41001), padHex(41002), padHex(41003)
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	g := NewGraph()
+	g.AddFollow(fan, star)
+	g.AddFollow(leaver, star)
+	g.ComputePageRank(20, 0.85)
+	h.Record(g, 1, start)
+	h.Record(g, 2, start.Add(6*time.Hour)) // unchanged
+
+	g2 := NewGraph()
+	g2.AddFollow(fan, star)
+	for i := 0; i < 5; i++ {
+		g2.AddFollow(padHex(41100+i), star)
+	}
+	g2.ComputePageRank(20, 0.85)
+	h.Record(g2, 3, start.Add(12*time.Hour))
+
+	points, ok := h.Points(star, time.Time{})
+	if !ok || len(points) != 3 {
+		t.Fatalf("expected a point per build, got %+v", points)
+	}
+	if points[0].Rank != 1 || points[0].Followers != 2 || points[2].Followers != 6 || points[1].Score != points[0].Score {
+		t.Errorf("unexpected points %+v", points)
+	}
+	if len(h.entries[star]) != 2 {
+		t.Errorf("expected the unchanged build stored once, got %d entries", len(h.entries[star]))
+	}
+
+	// the leaver isn't in the third build
+	if points, _ := h.Points(leaver, time.Time{}); len(points) != 2 || points[1].Build != 2 {
+		t.Errorf("expected the leaver's first two builds only, got %+v", points)
+	}
+	if points, _ := h.Points(star, start.Add(time.Hour)); len(points) != 2 || points[0].Build != 2 {
+		t.Errorf("expected since to drop the first build, got %+v", points)
+	}
+	if _, ok := h.Points(padHex(41999), time.Time{}); ok {
+		t.Error("expected no history for an unknown pubkey")
+	}
+}
+
+func TestScoreHistoryRetention(t *testing.T) {
+	h := NewScoreHistory()
+	a, b := padHex(41201), padHex(41202)
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	g := NewGraph()
+	g.AddFollow(a, b)
+	g.ComputePageRank(20, 0.85)
+	h.Record(g, 1, start)
+
+	// b alternates between two follower counts, a leaves after the first build
+	for build := uint64(2); build <= maxScoreHistoryBuilds+10; build++ {
+		g := NewGraph()
+		g.AddFollow(padHex(41300), b)
+		if build%2 == 0 {
+			g.AddFollow(padHex(41301), b)
+		}
+		g.ComputePageRank(20, 0.85)
+		h.Record(g, build, start.Add(time.Duration(build)*time.Hour))
+	}
+
+	if n, oldest := h.Builds(); n != maxScoreHistoryBuilds || !oldest.Equal(start.Add(11*time.Hour)) {
+		t.Errorf("expected %d builds from hour 11, got %d from %v", maxScoreHistoryBuilds, n, oldest)
+	}
+	if len(h.entries[b]) > maxScoreHistoryBuilds {
+		t.Errorf("expected entries pruned with the builds, got %d", len(h.entries[b]))
+	}
+	if points, _ := h.Points(b, time.Time{}); len(points) != maxScoreHistoryBuilds || points[0].Build != 11 {
+		t.Errorf("expected every retained build, got %d starting %+v", len(points), points[0])
+	}
+	if _, ok := h.entries[a]; ok {
+		t.Error("expected a pubkey gone from every retained build forgotten")
+	}
+}
+
+func TestHandleHistory(t *testing.T) {
+	oldHistory := scoreHistory
+	defer func() { scoreHistory = oldHistory }()
+	scoreHistory = NewScoreHistory()
+
+	star := padHex(41401)
+	g := NewGraph()
+	g.AddFollow(padHex(41402), star)
+	g.ComputePageRank(20, 0.85)
+	scoreHistory.Record(g, 7, time.Unix(1772000000, 0))
+	g.AddFollow(padHex(41403), star)
+	g.AddFollow(padHex(41403), padHex(41402))
+	g.ComputePageRank(20, 0.85)
+	scoreHistory.Record(g, 8, time.Unix(1772021600, 0))
+
+	rr := httptest.NewRecorder()
+	handleHistory(rr, httptest.NewRequest(http.MethodGet, "/history?pubkey="+star, nil))
+	var resp struct {
+		Points          []ScoreHistoryPoint `json:"points"`
+		BuildsRecorded  int                 `json:"builds_recorded"`
+		FollowersChange int                 `json:"followers_change"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	if len(resp.Points) != 2 || resp.BuildsRecorded != 2 || resp.FollowersChange != 1 || resp.Points[1].At != "2026-02-25T12:13:20Z" {
+		t.Errorf("unexpected history %+v", resp)
+	}
+
+	for url, want := range map[string]int{
+		"/history":               http.StatusBadRequest,
+		"/history?pubkey=nobody": http.StatusBadRequest,
+		"/history?pubkey=" + star + "&since=yesterday":  http.StatusBadRequest,
+		"/history?pubkey=" + padHex(41499):              http.StatusNotFound,
+		"/history?pubkey=" + star + "&since=1772010000": http.StatusOK,
+	} {
+		rr := httptest.NewRecorder()
+		handleHistory(rr, httptest.NewRequest(http.MethodGet, url, nil))
+		if rr.Code != want {
+			t.Errorf("%s: expected %d, got %d", url, want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// readSeedsFile reads seeds from a file with one hex pubkey or npub per line. Blank
+// lines and # comments are skipped.
+func readSeedsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var seeds []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			seeds = append(seeds, line)
+		}
+	}
+	return seeds, sc.Err()
+}
+
+// seedBudget splits a crawl between its seeds. Every pubkey the crawl queues is
+// charged to the seed whose neighborhood reached it first, and once a seed has
+// queued limit pubkeys its neighborhood stops growing, so one well-connected seed
+// can't fill the graph with its own corner of the network. A zero limit only keeps
+// count.
+type seedBudget struct {
+	limit   int
+	origin  map[string]string // pubkey -> seed it was reached from
+	reached map[string]int
+	denied  map[string]int
+	seeds   []string
+}
+
+func newSeedBudget(seeds []string, limit int) *seedBudget {
+	b := &seedBudget{
+		limit:   limit,
+		origin:  make(map[string]string, len(seeds)),
+		reached: make(map[string]int, len(seeds)),
+		denied:  make(map[string]int),
+	}
+	for _, s := range seeds {
+		if _, ok := b.origin[s]; !ok {
+			b.origin[s] = s
+			b.seeds = append(b.seeds, s)
+		}
+	}
+	return b
+}
+
+// admit reports whether to, found in from's follows, may be queued, charging it
+// to from's seed the first time it is seen.
+func (b *seedBudget) admit(from, to string) bool {
+	if _, ok := b.origin[to]; ok {
+		return true
+	}
+	seed := b.origin[from]
+	if b.limit > 0 && b.reached[seed] >= b.limit {
+		b.denied[seed]++
+		return false
+	}
+	b.origin[to] = seed
+	b.reached[seed]++
+	return true
+}
+
+// SeedCrawl is one seed's share of a crawl.
+type SeedCrawl struct {
+	Pubkey    string `json:"pubkey"`
+	Reached   int    `json:"reached"`             // pubkeys queued from this seed's neighborhood
+	Denied    int    `json:"denied"`              // follows left out once the budget ran out
+	Exhausted bool   `json:"exhausted,omitempty"` // hit the per-seed budget
+}
+
+// SeedCrawlReport is how the last crawl was split between seeds.
+type SeedCrawlReport struct {
+	At     time.Time   `json:"at"`
+	Budget int         `json:"budget"` // per seed; 0 is no limit
+	Seeds  []SeedCrawl `json:"seeds"`
+}
+
+func (b *seedBudget) report(at time.Time) SeedCrawlReport {
+	r := SeedCrawlReport{At: at, Budget: b.limit, Seeds: make([]SeedCrawl, len(b.seeds))}
+	for i, s := range b.seeds {
+		r.Seeds[i] = SeedCrawl{
+			Pubkey:    s,
+			Reached:   b.reached[s],
+			Denied:    b.denied[s],
+			Exhausted: b.limit > 0 && b.reached[s] >= b.limit,
+		}
+	}
+	return r
+}
+
+// SeedCrawlStats keeps the report of the last crawl.
+type SeedCrawlStats struct {
+	mu   sync.Mutex
+	last *SeedCrawlReport
+}
+
+var seedCrawls = &SeedCrawlStats{}
+
+func (s *SeedCrawlStats) Record(r SeedCrawlReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = &r
+}
+
+// Last returns the last crawl's report, or nil before the first crawl.
+func (s *SeedCrawlStats) Last() *SeedCrawlReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+// seedDistances walks follows breadth-first from all seeds at once. It returns each
+// reachable pubkey's hop count from the nearest seed and the index of that seed;
+// ties go to the seed listed first.
+func seedDistances(follows map[string][]string, seeds []string) (map[string]int, map[string]int) {
+	hops := make(map[string]int)
+	region := make(map[string]int)
+	var frontier []string
+	for i, s := range seeds {
+		if _, ok := hops[s]; !ok {
+			hops[s], region[s] = 0, i
+			frontier = append(frontier, s)
+		}
+	}
+	for d := 1; len(frontier) > 0; d++ {
+		var next []string
+		for _, pk := range frontier {
+			for _, to := range follows[pk] {
+				if _, ok := hops[to]; !ok {
+					hops[to], region[to] = d, region[pk]
+					next = append(next, to)
+				}
+			}
+		}
+		frontier = next
+	}
+	return hops, region
+}
+
+// SeedBiasBucket summarizes the scores of the pubkeys at one distance from the
+// seeds.
+type SeedBiasBucket struct {
+	Hops        int     `json:"hops"` // from the nearest seed; -1 is not reachable from any
+	Nodes       int     `json:"nodes"`
+	NodeShare   float64 `json:"node_share"`
+	ScoreShare  float64 `json:"score_share"` // of the graph's total PageRank
+	MeanScore   float64 `json:"mean_score"`
+	MedianScore int     `json:"median_score"`
+	P90Score    int     `json:"p90_score"`
+}
+
+// SeedRegion is the part of the graph nearer one seed than any other.
+type SeedRegion struct {
+	Seed       string     `json:"seed"`
+	Score      int        `json:"score"` // the seed's own
+	Nodes      int        `json:"nodes"`
+	NodeShare  float64    `json:"node_share"`
+	ScoreShare float64    `json:"score_share"`
+	MeanScore  float64    `json:"mean_score"`
+	Crawl      *SeedCrawl `json:"crawl,omitempty"` // from the last crawl
+}
+
+// SeedBiasNode places one pubkey relative to the seeds.
+type SeedBiasNode struct {
+	Pubkey         string   `json:"pubkey"`
+	Score          int      `json:"score"`
+	Hops           int      `json:"hops"` // -1 when no seed reaches it
+	NearestSeed    string   `json:"nearest_seed,omitempty"`
+	SeedsFollowing []string `json:"seeds_following"`
+	DistanceMedian int      `json:"distance_median_score"` // median score at the same distance
+	DistanceRank   float64  `json:"distance_percentile"`   // share of pubkeys at the same distance scoring lower
+}
+
+// SeedBiasResponse is the response for GET /bias.
+type SeedBiasResponse struct {
+	Seeds           int              `json:"seeds"`
+	SeedCrawlBudget int              `json:"seed_crawl_budget"`
+	GraphSize       int              `json:"graph_size"`
+	ReachableShare  float64          `json:"reachable_share"`
+	MeanHops        float64          `json:"mean_hops"` // over reachable pubkeys
+	Distance        []SeedBiasBucket `json:"distance"`
+	Regions         []SeedRegion     `json:"regions"`     // by score share, largest first
+	RegionGini      float64          `json:"region_gini"` // of score share across regions: 0 is even, towards 1 one seed dominates
+	LastCrawl       *SeedCrawlReport `json:"last_crawl,omitempty"`
+	Node            *SeedBiasNode    `json:"node,omitempty"`
+}
+
+// computeSeedBias reports how g's scores depend on distance from seeds. With a
+// pubkey, the response also places that pubkey.
+func computeSeedBias(g *Graph, seeds []string, pubkey string) SeedBiasResponse {
+	follows, followers := g.FollowsSnapshot()
+	scores := g.ScoresSnapshot()
+	size := g.Stats().Nodes
+	hops, region := seedDistances(follows, seeds)
+
+	nodes := make(map[string]bool, len(scores))
+	for pk := range scores {
+		nodes[pk] = true
+	}
+	for pk := range follows {
+		nodes[pk] = true
+	}
+	for pk := range followers {
+		nodes[pk] = true
+	}
+	total := 0.0
+	for _, s := range scores {
+		total += s
+	}
+
+	type acc struct {
+		raw    float64
+		scores []int
+	}
+	byHops := make(map[int]*acc)
+	byRegion := make([]acc, len(seeds))
+	hopSum, reachable := 0, 0
+	for pk := range nodes {
+		h, ok := hops[pk]
+		if !ok {
+			h = -1
+		} else {
+			hopSum += h
+			reachable++
+		}
+		s := normalizeScore(scores[pk], size)
+		a := byHops[h]
+		if a == nil {
+			a = &acc{}
+			byHops[h] = a
+		}
+		a.raw += scores[pk]
+		a.scores = append(a.scores, s)
+		if ok {
+			r := &byRegion[region[pk]]
+			r.raw += scores[pk]
+			r.scores = append(r.scores, s)
+		}
+	}
+
+	share := func(part, whole float64) float64 {
+		if whole == 0 {
+			return 0
+		}
+		return math.Round(part/whole*1000) / 1000
+	}
+	mean := func(xs []int) float64 {
+		if len(xs) == 0 {
+			return 0
+		}
+		sum := 0
+		for _, x := range xs {
+			sum += x
+		}
+		return math.Round(float64(sum)/float64(len(xs))*10) / 10
+	}
+
+	resp := SeedBiasResponse{
+		Seeds:          len(seeds),
+		GraphSize:      size,
+		ReachableShare: share(float64(reachable), float64(len(nodes))),
+		LastCrawl:      seedCrawls.Last(),
+	}
+	if reachable > 0 {
+		resp.MeanHops = math.Round(float64(hopSum)/float64(reachable)*100) / 100
+	}
+	for h, a := range byHops {
+		sort.Ints(a.scores)
+		resp.Distance = append(resp.Distance, SeedBiasBucket{
+			Hops:        h,
+			Nodes:       len(a.scores),
+			NodeShare:   share(float64(len(a.scores)), float64(len(nodes))),
+			ScoreShare:  share(a.raw, total),
+			MeanScore:   mean(a.scores),
+			MedianScore: a.scores[len(a.scores)/2],
+			P90Score:    a.scores[len(a.scores)*9/10],
+		})
+	}
+	// unreachable last
+	sort.Slice(resp.Distance, func(i, j int) bool {
+		hi, hj := resp.Distance[i].Hops, resp.Distance[j].Hops
+		return hi >= 0 && (hj < 0 || hi < hj)
+	})
+
+	crawled := make(map[string]*SeedCrawl)
+	if resp.LastCrawl != nil {
+		for i := range resp.LastCrawl.Seeds {
+			crawled[resp.LastCrawl.Seeds[i].Pubkey] = &resp.LastCrawl.Seeds[i]
+		}
+	}
+	shares := make([]float64, 0, len(seeds))
+	for i, s := range seeds {
+		if hops[s] != 0 || region[s] != i {
+			continue // listed twice
+		}
+		a := byRegion[i]
+		resp.Regions = append(resp.Regions, SeedRegion{
+			Seed:       s,
+			Score:      normalizeScore(scores[s], size),
+			Nodes:      len(a.scores),
+			NodeShare:  share(float64(len(a.scores)), float64(len(nodes))),
+			ScoreShare: share(a.raw, total),
+			MeanScore:  mean(a.scores),
+			Crawl:      crawled[s],
+		})
+		shares = append(shares, a.raw)
+	}
+	sort.SliceStable(resp.Regions, func(i, j int) bool { return resp.Regions[i].ScoreShare > resp.Regions[j].ScoreShare })
+	sort.Float64s(shares)
+	resp.RegionGini = math.Round(giniCoefficient(shares)*1000) / 1000
+
+	if pubkey != "" {
+		n := &SeedBiasNode{Pubkey: pubkey, Score: normalizeScore(scores[pubkey], size), Hops: -1, SeedsFollowing: []string{}}
+		if h, ok := hops[pubkey]; ok {
+			n.Hops = h
+			n.NearestSeed = seeds[region[pubkey]]
+		}
+		isSeed := make(map[string]bool, len(seeds))
+		for _, s := range seeds {
+			isSeed[s] = true
+		}
+		for _, f := range followers[pubkey] {
+			if isSeed[f] {
+				n.SeedsFollowing = append(n.SeedsFollowing, f)
+			}
+		}
+		if a := byHops[n.Hops]; a != nil {
+			n.DistanceMedian = a.scores[len(a.scores)/2]
+			n.DistanceRank = share(float64(sort.SearchInts(a.scores, n.Score)), float64(len(a.scores)))
+		}
+		resp.Node = n
+	}
+	return resp
+}
+
+// handleBias reports how scores depend on proximity to the seeds, so consumers can
+// judge how fairly the graph covers the network.
+// GET /bias[?pubkey=<hex|npub>]
+func handleBias(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
+	if g.Stats().Nodes == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "graph not built yet"})
+		return
+	}
+	q := bindQuery(r)
+	pubkey := q.HexPubkey("pubkey", false)
+	if q.Failed(w) {
+		return
+	}
+
+	cfg := config.Get()
+	resp := computeSeedBias(g, curation.CrawlSeeds(cfg.Seeds), pubkey)
+	resp.SeedCrawlBudget = cfg.SeedCrawlBudget
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
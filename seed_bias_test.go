@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSeedBudgetCapsEachSeed(t *testing.T) {
+	a, b := padHex(49500), padHex(49501)
+	budget := newSeedBudget([]string{a, b, a}, 2)
+
+	for i := 0; i < 4; i++ {
+		want := i < 2
+		if got := budget.admit(a, padHex(49510+i)); got != want {
+			t.Errorf("follow %d of seed a: admit = %v, want %v", i, got, want)
+		}
+	}
+	// a pubkey already reached is free, and b's budget is its own
+	if !budget.admit(b, padHex(49510)) || !budget.admit(b, padHex(49520)) {
+		t.Errorf("expected b to queue its own follows")
+	}
+	// follows of a pubkey reached from a are charged to a
+	if budget.admit(padHex(49511), padHex(49521)) {
+		t.Errorf("expected a's exhausted budget to cover its whole neighborhood")
+	}
+
+	r := budget.report(time.Now())
+	if len(r.Seeds) != 2 || r.Budget != 2 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	if s := r.Seeds[0]; s.Pubkey != a || s.Reached != 2 || s.Denied != 3 || !s.Exhausted {
+		t.Errorf("unexpected report for a: %+v", s)
+	}
+	if s := r.Seeds[1]; s.Reached != 1 || s.Denied != 0 || s.Exhausted {
+		t.Errorf("unexpected report for b: %+v", s)
+	}
+}
+
+// buildSeedBiasGraph has two seeds: a follows a chain of three, b follows one
+// pubkey, and one pair is reachable from neither.
+func buildSeedBiasGraph() (*Graph, []string) {
+	a, b := padHex(49530), padHex(49531)
+	g := NewGraph()
+	g.AddFollow(a, padHex(49532))
+	g.AddFollow(padHex(49532), padHex(49533))
+	g.AddFollow(padHex(49533), padHex(49534))
+	g.AddFollow(b, padHex(49532))
+	g.AddFollow(b, padHex(49535))
+	g.AddFollow(padHex(49536), padHex(49537))
+	g.AddFollow(padHex(49532), a)
+	g.ComputePageRank(50, 0.85)
+	return g, []string{a, b}
+}
+
+func TestComputeSeedBias(t *testing.T) {
+	g, seeds := buildSeedBiasGraph()
+	resp := computeSeedBias(g, seeds, padHex(49533))
+
+	hops := make(map[int]int)
+	for _, d := range resp.Distance {
+		hops[d.Hops] = d.Nodes
+	}
+	if hops[0] != 2 || hops[1] != 2 || hops[2] != 1 || hops[3] != 1 || hops[-1] != 2 {
+		t.Errorf("unexpected distance buckets %+v", resp.Distance)
+	}
+	if last := resp.Distance[len(resp.Distance)-1]; last.Hops != -1 {
+		t.Errorf("expected unreachable pubkeys last, got %+v", last)
+	}
+	if resp.ReachableShare != 0.75 || resp.MeanHops != 1.17 {
+		t.Errorf("unexpected reach %v, mean hops %v", resp.ReachableShare, resp.MeanHops)
+	}
+
+	if len(resp.Regions) != 2 {
+		t.Fatalf("expected two regions, got %+v", resp.Regions)
+	}
+	nodes := make(map[string]int)
+	for _, r := range resp.Regions {
+		nodes[r.Seed] = r.Nodes
+	}
+	// the shared follow is a's, the seed listed first
+	if nodes[seeds[0]] != 4 || nodes[seeds[1]] != 2 {
+		t.Errorf("unexpected regions %+v", resp.Regions)
+	}
+	if resp.RegionGini <= 0 {
+		t.Errorf("expected uneven regions to have a positive Gini, got %v", resp.RegionGini)
+	}
+
+	n := resp.Node
+	if n == nil || n.Hops != 2 || n.NearestSeed != seeds[0] || len(n.SeedsFollowing) != 0 {
+		t.Errorf("unexpected node %+v", n)
+	}
+	if resp := computeSeedBias(g, seeds, padHex(49532)); len(resp.Node.SeedsFollowing) != 2 {
+		t.Errorf("expected both seeds following, got %+v", resp.Node)
+	}
+	if resp := computeSeedBias(g, seeds, padHex(49537)); resp.Node.Hops != -1 || resp.Node.NearestSeed != "" {
+		t.Errorf("expected an unreachable pubkey, got %+v", resp.Node)
+	}
+}
+
+func TestHandleBias(t *testing.T) {
+	oldGraph, oldCrawls := graph, seedCrawls
+	t.Cleanup(func() { graph, seedCrawls = oldGraph, oldCrawls })
+	seedCrawls = &SeedCrawlStats{}
+
+	graph = NewGraph()
+	w := httptest.NewRecorder()
+	handleBias(w, httptest.NewRequest("GET", "/bias", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before the graph is built, got %d", w.Code)
+	}
+
+	graph, _ = buildSeedBiasGraph()
+	w = httptest.NewRecorder()
+	handleBias(w, httptest.NewRequest("GET", "/bias?pubkey=nobody", nil))
+	if body := decodeAPIError(t, w); w.Code != http.StatusBadRequest || body["field"] != "pubkey" {
+		t.Errorf("expected 400 naming pubkey, got %d %v", w.Code, body)
+	}
+
+	seedCrawls.Record(newSeedBudget(config.Get().Seeds, 10).report(time.Now()))
+	w = httptest.NewRecorder()
+	handleBias(w, httptest.NewRequest("GET", "/bias?pubkey="+padHex(49533), nil))
+	var resp SeedBiasResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d (%v)", w.Code, err)
+	}
+	// the configured seeds aren't in this graph, so nothing is reachable
+	if resp.Seeds != len(config.Get().Seeds) || resp.ReachableShare != 0 || resp.Node == nil || resp.Node.Hops != -1 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if len(resp.Regions) != resp.Seeds || resp.Regions[0].Nodes != 0 {
+		t.Errorf("expected every seed listed with an empty region, got %+v", resp.Regions)
+	}
+	if resp.LastCrawl == nil || resp.LastCrawl.Budget != 10 || resp.Regions[0].Crawl == nil {
+		t.Errorf("expected the last crawl report, got %+v", resp.LastCrawl)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxSeedViews caps the seed views one instance scores.
+	maxSeedViews = 8
+	// seedViewCheckInterval is how often views are checked for a due rebuild.
+	seedViewCheckInterval = time.Minute
+)
+
+var seedViewNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// SeedView is a seed group scored as its own view of the network, such as
+// "bitcoin-devs" or "artists": whose WoT a score is in. Each view crawls
+// CrawlDepth hops from its own seeds and scores that neighborhood with PageRank,
+// every RebuildHours, independently of the main rebuild. Views are configured
+// as [views.<name>] tables in the config file.
+type SeedView struct {
+	Name         string   `json:"name"`
+	Seeds        []string `json:"seeds"`
+	CrawlDepth   int      `json:"crawl_depth"`   // 1 = the seeds' follows, 2 = follows-of-follows
+	RebuildHours int      `json:"rebuild_hours"` // hours between the view's rebuilds
+}
+
+// normalizeViews fills in view defaults, resolves npub seeds to hex and checks
+// every view is usable.
+func normalizeViews(views []SeedView) error {
+	if len(views) > maxSeedViews {
+		return fmt.Errorf("at most %d views are allowed", maxSeedViews)
+	}
+	names := make(map[string]bool, len(views))
+	for i := range views {
+		v := &views[i]
+		if !seedViewNamePattern.MatchString(v.Name) || v.Name == "default" {
+			return fmt.Errorf("invalid view name %q", v.Name)
+		}
+		if names[v.Name] {
+			return fmt.Errorf("view %s is defined twice", v.Name)
+		}
+		names[v.Name] = true
+		if len(v.Seeds) == 0 {
+			return fmt.Errorf("view %s needs at least one seed", v.Name)
+		}
+		seeds := make([]string, 0, len(v.Seeds))
+		for _, s := range v.Seeds {
+			pk, err := resolvePubkey(s)
+			if err != nil || !hex64Pattern.MatchString(pk) {
+				return fmt.Errorf("view %s: invalid seed %q", v.Name, s)
+			}
+			if !slices.Contains(seeds, pk) {
+				seeds = append(seeds, pk)
+			}
+		}
+		v.Seeds = seeds
+		if v.CrawlDepth == 0 {
+			v.CrawlDepth = 2
+		}
+		if v.CrawlDepth < 1 || v.CrawlDepth > 4 {
+			return fmt.Errorf("view %s: crawl_depth must be between 1 and 4", v.Name)
+		}
+		if v.RebuildHours == 0 {
+			v.RebuildHours = int(rebuildInterval / time.Hour)
+		}
+		if v.RebuildHours < 1 || v.RebuildHours > 168 {
+			return fmt.Errorf("view %s: rebuild_hours must be between 1 and 168", v.Name)
+		}
+	}
+	return nil
+}
+
+// seedViewBuild is a view's scored graph from its last rebuild, with the view
+// settings it was built from.
+type seedViewBuild struct {
+	view    SeedView
+	graph   *Graph
+	builtAt time.Time
+	took    time.Duration
+}
+
+// SeedViews holds the latest build of each configured seed view.
+type SeedViews struct {
+	mu     sync.RWMutex
+	builds map[string]*seedViewBuild
+}
+
+func NewSeedViews() *SeedViews {
+	return &SeedViews{builds: make(map[string]*seedViewBuild)}
+}
+
+var seedViews = NewSeedViews()
+
+// crawlSeedView crawls the contact lists within v.CrawlDepth hops of v's seeds
+// into a graph of the view's own, so a view's seeds never change the main
+// scores. It waits for any other follow crawl to finish first.
+var crawlSeedView = func(ctx context.Context, v SeedView) *Graph {
+	crawlMu.Lock()
+	defer crawlMu.Unlock()
+	g := NewGraph()
+	crawlFollows(ctx, g, v.Seeds, v.CrawlDepth, 0, nil)
+	return g
+}
+
+// seedViewSubgraph copies the part of g a crawl from seeds to depth covers: the
+// follows of every pubkey fewer than depth hops from a seed.
+func seedViewSubgraph(g *Graph, seeds []string, depth int) *Graph {
+	sub := NewGraph()
+	seen := make(map[string]bool)
+	queue := seeds
+	for d := 0; d < depth && len(queue) > 0; d++ {
+		var next []string
+		for _, pk := range queue {
+			if seen[pk] {
+				continue
+			}
+			seen[pk] = true
+			for _, target := range g.GetFollows(pk) {
+				sub.AddFollow(pk, target)
+				if !seen[target] {
+					next = append(next, target)
+				}
+			}
+		}
+		queue = next
+	}
+	return sub
+}
+
+// due reports whether v needs a rebuild at now: it was never built, its settings
+// changed, or RebuildHours have passed since its last build.
+func (sv *SeedViews) due(v SeedView, now time.Time) bool {
+	sv.mu.RLock()
+	defer sv.mu.RUnlock()
+	b, ok := sv.builds[v.Name]
+	if !ok || b.view.CrawlDepth != v.CrawlDepth || !slices.Equal(b.view.Seeds, v.Seeds) {
+		return true
+	}
+	return !now.Before(b.builtAt.Add(time.Duration(v.RebuildHours) * time.Hour))
+}
+
+// Rebuild crawls v's neighborhood and scores it with cfg's PageRank settings.
+func (sv *SeedViews) Rebuild(ctx context.Context, cfg Config, v SeedView) {
+	start := time.Now()
+	log.Printf("Rebuilding view %s from %d seeds, depth %d", v.Name, len(v.Seeds), v.CrawlDepth)
+	crawled := crawlSeedView(ctx, v)
+	if ctx.Err() != nil {
+		return // keep the last complete build rather than scoring a partial crawl
+	}
+	g := seedViewSubgraph(crawled, v.Seeds, v.CrawlDepth)
+	g.ComputePageRank(cfg.PageRankIterations, cfg.Damping)
+	g.RefreshPercentileBuckets()
+	g.RefreshRawScoreDistribution()
+	stats := g.Stats()
+
+	sv.mu.Lock()
+	sv.builds[v.Name] = &seedViewBuild{view: v, graph: g, builtAt: time.Now(), took: time.Since(start)}
+	sv.mu.Unlock()
+	log.Printf("View %s ready: %d nodes, %d edges", v.Name, stats.Nodes, stats.Edges)
+}
+
+// RebuildDue rebuilds every configured view that is due at now and forgets
+// views no longer configured.
+func (sv *SeedViews) RebuildDue(ctx context.Context, now time.Time) {
+	cfg := config.Get()
+	sv.mu.Lock()
+	for name := range sv.builds {
+		if !slices.ContainsFunc(cfg.Views, func(v SeedView) bool { return v.Name == name }) {
+			delete(sv.builds, name)
+		}
+	}
+	sv.mu.Unlock()
+	for _, v := range cfg.Views {
+		if ctx.Err() != nil {
+			return
+		}
+		if sv.due(v, now) {
+			sv.Rebuild(ctx, cfg, v)
+		}
+	}
+}
+
+// Run rebuilds views as they come due until ctx is done.
+func (sv *SeedViews) Run(ctx context.Context) {
+	ticker := time.NewTicker(seedViewCheckInterval)
+	defer ticker.Stop()
+	for {
+		sv.RebuildDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Graph returns view name's scored graph, or nil while it hasn't been built.
+func (sv *SeedViews) Graph(name string) *Graph {
+	sv.mu.RLock()
+	defer sv.mu.RUnlock()
+	if b, ok := sv.builds[name]; ok {
+		return b.graph
+	}
+	return nil
+}
+
+// Stats summarizes the configured views for /stats.
+func (sv *SeedViews) Stats(cfg Config) []map[string]interface{} {
+	sv.mu.RLock()
+	defer sv.mu.RUnlock()
+	out := make([]map[string]interface{}, 0, len(cfg.Views))
+	for _, v := range cfg.Views {
+		view := map[string]interface{}{
+			"name":          v.Name,
+			"seeds":         len(v.Seeds),
+			"crawl_depth":   v.CrawlDepth,
+			"rebuild_hours": v.RebuildHours,
+			"built":         false,
+		}
+		if b, ok := sv.builds[v.Name]; ok {
+			stats := b.graph.Stats()
+			view["built"] = true
+			view["nodes"] = stats.Nodes
+			view["edges"] = stats.Edges
+			view["built_at"] = b.builtAt.UTC().Format(time.RFC3339)
+			view["build_seconds"] = round3(b.took.Seconds())
+			view["next_build"] = b.builtAt.Add(time.Duration(v.RebuildHours) * time.Hour).UTC().Format(time.RFC3339)
+		}
+		out = append(out, view)
+	}
+	return out
+}
+
+// seedViewRequested reports whether r asks for a seed view rather than the
+// shared graph. Those responses change with the view's own rebuilds, so the
+// build-keyed caches leave them alone.
+func seedViewRequested(r *http.Request) bool {
+	view := r.URL.Query().Get("view")
+	return view != "" && view != "default"
+}
+
+// viewGraph returns the graph r scores against and the view's name: the shared
+// graph without ?view= (or with view=default), otherwise the named seed view's
+// own graph. It writes the error and returns nil when the view isn't configured
+// or hasn't been built yet.
+func viewGraph(w http.ResponseWriter, r *http.Request) (*Graph, string) {
+	name := r.URL.Query().Get("view")
+	g, apiErr := seedViewGraph(name)
+	if apiErr != nil {
+		writeAPIError(w, apiErr)
+		return nil, ""
+	}
+	if name == "default" {
+		name = ""
+	}
+	return g, name
+}
+
+// seedViewGraph returns view name's graph, or the shared graph for "" and
+// "default". The error is a 400 for a view that isn't configured and a 503 for
+// one that hasn't been built yet.
+func seedViewGraph(name string) (*Graph, *APIError) {
+	if name == "" || name == "default" {
+		return graph.Snapshot(), nil
+	}
+	views := config.Get().Views
+	if !slices.ContainsFunc(views, func(v SeedView) bool { return v.Name == name }) {
+		names := []string{"default"}
+		for _, v := range views {
+			names = append(names, v.Name)
+		}
+		sort.Strings(names[1:])
+		return nil, invalidParam("view", "view must be one of %s", strings.Join(names, ", "))
+	}
+	g := seedViews.Graph(name)
+	if g == nil {
+		return nil, &APIError{Status: http.StatusServiceUnavailable, Message: "view " + name + " not built yet"}
+	}
+	return g, nil
+}
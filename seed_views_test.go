@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSeedViewSubgraph(t *testing.T) {
+	g := NewGraph()
+	// a chain 51700 -> 51701 -> 51702 -> 51703, and 51710 off to the side
+	for i := 0; i < 3; i++ {
+		g.AddFollow(padHex(51700+i), padHex(51701+i))
+	}
+	g.AddFollow(padHex(51710), padHex(51700))
+
+	sub := seedViewSubgraph(g.Snapshot(), []string{padHex(51700)}, 2)
+	if stats := sub.Stats(); stats.Edges != 2 {
+		t.Errorf("expected two hops of follows, got %+v", stats)
+	}
+	if len(sub.GetFollows(padHex(51701))) != 1 || len(sub.GetFollows(padHex(51702))) != 0 || len(sub.GetFollowers(padHex(51700))) != 0 {
+		t.Error("expected the crawl scope to stop at depth and leave out unreached pubkeys")
+	}
+}
+
+// setupSeedViews installs a graph of two clusters, around 51720 and 51740, and a
+// config with an "artists" view seeded at 51740. View crawls return a copy of
+// that graph, standing in for the relays. It returns how often the view was
+// crawled.
+func setupSeedViews(t *testing.T) (crawls *int) {
+	oldGraph, oldConfig, oldViews, oldCrawl := graph, config, seedViews, crawlSeedView
+	t.Cleanup(func() { graph, config, seedViews, crawlSeedView = oldGraph, oldConfig, oldViews, oldCrawl })
+	graph = NewGraph()
+	for i := 1; i < 12; i++ {
+		graph.AddFollow(padHex(51720+i), padHex(51720))
+		graph.AddFollow(padHex(51720), padHex(51720+i))
+	}
+	for i := 1; i < 4; i++ {
+		graph.AddFollow(padHex(51740), padHex(51740+i))
+		graph.AddFollow(padHex(51740+i), padHex(51740))
+	}
+	graph.ComputePageRank(20, 0.85)
+
+	config = NewConfigStore("")
+	config.cfg.Views = []SeedView{{Name: "artists", Seeds: []string{padHex(51740)}, CrawlDepth: 2, RebuildHours: 6}}
+	seedViews = NewSeedViews()
+	crawls = new(int)
+	crawlSeedView = func(ctx context.Context, v SeedView) *Graph {
+		*crawls++
+		return graph.Snapshot()
+	}
+	return crawls
+}
+
+func TestSeedViewsRebuildDue(t *testing.T) {
+	crawls := setupSeedViews(t)
+	now := time.Now()
+
+	seedViews.RebuildDue(context.Background(), now)
+	g := seedViews.Graph("artists")
+	if *crawls != 1 || g == nil || g.Stats().Nodes != 4 {
+		t.Fatalf("expected the view crawled and scored over its 4 pubkeys, got %d crawls", *crawls)
+	}
+	seedViews.RebuildDue(context.Background(), now.Add(time.Hour))
+	if *crawls != 1 {
+		t.Errorf("expected no rebuild within rebuild_hours, got %d crawls", *crawls)
+	}
+	seedViews.RebuildDue(context.Background(), now.Add(7*time.Hour))
+	if *crawls != 2 {
+		t.Errorf("expected a rebuild after rebuild_hours, got %d crawls", *crawls)
+	}
+
+	// new seeds rebuild straight away; a removed view is forgotten
+	config.cfg.Views = []SeedView{{Name: "artists", Seeds: []string{padHex(51720)}, CrawlDepth: 1, RebuildHours: 6}}
+	seedViews.RebuildDue(context.Background(), now.Add(7*time.Hour))
+	if *crawls != 3 || seedViews.Graph("artists").Stats().Nodes != 12 {
+		t.Errorf("expected a rebuild from the new seed, got %d crawls", *crawls)
+	}
+	config.cfg.Views = nil
+	seedViews.RebuildDue(context.Background(), now)
+	if seedViews.Graph("artists") != nil {
+		t.Error("expected the removed view dropped")
+	}
+}
+
+func TestSeedViewCrawlLeavesMainGraph(t *testing.T) {
+	setupSeedViews(t)
+	artist, newcomer := padHex(51740), padHex(51749)
+	crawlSeedView = func(ctx context.Context, v SeedView) *Graph {
+		g := NewGraph()
+		for _, pk := range append(graph.GetFollows(artist), newcomer) {
+			g.AddFollow(artist, pk)
+		}
+		return g
+	}
+	before := graph.Stats()
+
+	seedViews.RebuildDue(context.Background(), time.Now())
+	if _, ok := seedViews.Graph("artists").GetScore(newcomer); !ok {
+		t.Error("expected the view scored over its own crawl")
+	}
+	if after := graph.Stats(); after.Nodes != before.Nodes || after.Edges != before.Edges {
+		t.Errorf("expected the main graph unchanged, got %+v then %+v", before, after)
+	}
+	if _, ok := graph.GetScore(newcomer); ok || len(graph.GetFollowers(newcomer)) != 0 {
+		t.Error("expected a pubkey only the view crawled left out of the main graph")
+	}
+}
+
+func TestSeedViewRequests(t *testing.T) {
+	setupSeedViews(t)
+	artist := padHex(51741)
+
+	score := func(pubkey, query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		handleScore(w, httptest.NewRequest(http.MethodGet, "/score?pubkey="+pubkey+query, nil))
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+	if code, _ := score(artist, "&view=artists"); code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before the view is built, got %d", code)
+	}
+	if code, _ := score(artist, "&view=poets"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown view, got %d", code)
+	}
+
+	seedViews.RebuildDue(context.Background(), time.Now())
+	code, view := score(artist, "&view=artists")
+	if code != http.StatusOK || view["view"] != "artists" || view["graph_size"] != float64(4) || view["found"] != true {
+		t.Fatalf("expected the artists view, got %d %v", code, view)
+	}
+	// the other cluster is scored in the main view only
+	_, main := score(padHex(51721), "")
+	_, outside := score(padHex(51721), "&view=artists")
+	if main["view"] != nil || main["found"] != true || outside["found"] != false {
+		t.Errorf("expected 51721 outside the artists view only: main %v, view %v", main, outside)
+	}
+
+	w := httptest.NewRecorder()
+	handleTop(w, httptest.NewRequest(http.MethodGet, "/top?view=artists", nil))
+	var top []TopEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &top); err != nil || len(top) != 4 || top[0].Pubkey != padHex(51740) {
+		t.Errorf("expected the view's 4 pubkeys led by its seed, got %s", w.Body.String())
+	}
+}
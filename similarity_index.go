@@ -0,0 +1,222 @@
+package main
+
+import (
+	"hash/fnv"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// minhashBands × minhashRows hashes make up a follow set's signature. Two rows
+	// per band put the LSH threshold near Jaccard 0.18: pairs at 0.1 collide in
+	// some band about a quarter of the time, pairs at 0.3 almost always.
+	minhashBands = 32
+	minhashRows  = 2
+	minhashSize  = minhashBands * minhashRows
+	// similarityMinFollows matches /similar, which ignores accounts following
+	// fewer than three others.
+	similarityMinFollows = 3
+	// similarityBucketScanMax caps the members read from one band bucket, so a
+	// bucket of accounts that all follow the same two celebrities stays cheap.
+	similarityBucketScanMax = 2000
+	// similarityCandidateMax caps the candidates /similar computes exact Jaccard
+	// for, picked by estimated similarity.
+	similarityCandidateMax = 1000
+)
+
+// bandEntry places one indexed pubkey in a band's bucket: key packs the band's
+// two signature values.
+type bandEntry struct {
+	key uint64
+	id  int32
+}
+
+// SimilarityIndex is a MinHash/LSH index over every account's follow set, rebuilt
+// after each crawl. /similar reads candidate pubkeys from it instead of comparing
+// the target against every follow list in the graph, then computes exact Jaccard
+// on those candidates alone.
+type SimilarityIndex struct {
+	mu         sync.RWMutex
+	pubkeys    []string
+	ids        map[string]int32
+	sigs       []uint32      // minhashSize values per pubkey, in id order
+	bands      [][]bandEntry // per band, sorted by key
+	builtAt    time.Time
+	buildTime  time.Duration
+	queries    atomic.Int64
+	candidates atomic.Int64
+}
+
+func NewSimilarityIndex() *SimilarityIndex {
+	return &SimilarityIndex{ids: make(map[string]int32)}
+}
+
+var similarityIndex = NewSimilarityIndex()
+
+// minhashSeeds holds one seed per signature position.
+var minhashSeeds = func() [minhashSize]uint64 {
+	var seeds [minhashSize]uint64
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range seeds {
+		x = splitmix64(x)
+		seeds[i] = x
+	}
+	return seeds
+}()
+
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+func pubkeyHash(pk string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(pk))
+	return h.Sum64()
+}
+
+// minhashSignature writes follows' signature into sig.
+func minhashSignature(follows []string, sig []uint32) {
+	for i := range sig {
+		sig[i] = ^uint32(0)
+	}
+	for _, f := range follows {
+		base := pubkeyHash(f)
+		for i, seed := range minhashSeeds {
+			if v := uint32(splitmix64(base ^ seed)); v < sig[i] {
+				sig[i] = v
+			}
+		}
+	}
+}
+
+func bandKey(sig []uint32, band int) uint64 {
+	return uint64(sig[band*minhashRows])<<32 | uint64(sig[band*minhashRows+1])
+}
+
+// Build indexes the follow sets in g, replacing the previous index when done.
+func (x *SimilarityIndex) Build(g *Graph) {
+	start := time.Now()
+	var pubkeys []string
+	for _, pk := range g.AllFollowers() {
+		if len(g.GetFollows(pk)) >= similarityMinFollows {
+			pubkeys = append(pubkeys, pk)
+		}
+	}
+	sort.Strings(pubkeys)
+
+	sigs := make([]uint32, len(pubkeys)*minhashSize)
+	var wg sync.WaitGroup
+	workers := runtime.NumCPU()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(pubkeys); i += workers {
+				minhashSignature(g.GetFollows(pubkeys[i]), sigs[i*minhashSize:(i+1)*minhashSize])
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	ids := make(map[string]int32, len(pubkeys))
+	for i, pk := range pubkeys {
+		ids[pk] = int32(i)
+	}
+	bands := make([][]bandEntry, minhashBands)
+	for b := range bands {
+		entries := make([]bandEntry, len(pubkeys))
+		for i := range pubkeys {
+			entries[i] = bandEntry{key: bandKey(sigs[i*minhashSize:], b), id: int32(i)}
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+		bands[b] = entries
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.pubkeys, x.ids, x.sigs, x.bands = pubkeys, ids, sigs, bands
+	x.builtAt, x.buildTime = time.Now(), time.Since(start)
+}
+
+// Candidates returns up to similarityCandidateMax indexed pubkeys whose follow
+// sets likely overlap follows, most similar first by signature estimate. ok is
+// false before the first build, when callers should fall back to a full scan.
+func (x *SimilarityIndex) Candidates(pubkey string, follows []string) (candidates []string, ok bool) {
+	sig := make([]uint32, minhashSize)
+	minhashSignature(follows, sig)
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if x.builtAt.IsZero() {
+		return nil, false
+	}
+	self, indexed := x.ids[pubkey]
+	seen := make(map[int32]bool)
+	var ids []int32
+	for b, entries := range x.bands {
+		key := bandKey(sig, b)
+		i := sort.Search(len(entries), func(i int) bool { return entries[i].key >= key })
+		for n := 0; i < len(entries) && entries[i].key == key && n < similarityBucketScanMax; i, n = i+1, n+1 {
+			id := entries[i].id
+			if seen[id] || indexed && id == self {
+				continue
+			}
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) > similarityCandidateMax {
+		estimate := make(map[int32]int, len(ids))
+		for _, id := range ids {
+			other := x.sigs[int(id)*minhashSize : (int(id)+1)*minhashSize]
+			for i, v := range sig {
+				if other[i] == v {
+					estimate[id]++
+				}
+			}
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			if estimate[ids[i]] != estimate[ids[j]] {
+				return estimate[ids[i]] > estimate[ids[j]]
+			}
+			return ids[i] < ids[j]
+		})
+		ids = ids[:similarityCandidateMax]
+	}
+
+	candidates = make([]string, len(ids))
+	for i, id := range ids {
+		candidates[i] = x.pubkeys[id]
+	}
+	x.queries.Add(1)
+	x.candidates.Add(int64(len(ids)))
+	return candidates, true
+}
+
+// Stats reports the index size, shape and build time for /stats.
+func (x *SimilarityIndex) Stats() map[string]interface{} {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	stats := map[string]interface{}{
+		"indexed":       len(x.pubkeys),
+		"hashes":        minhashSize,
+		"bands":         minhashBands,
+		"rows_per_band": minhashRows,
+		"build_ms":      x.buildTime.Milliseconds(),
+		"queries":       x.queries.Load(),
+	}
+	if !x.builtAt.IsZero() {
+		stats["built_at"] = x.builtAt.UTC().Format(time.RFC3339)
+	}
+	if q := x.queries.Load(); q > 0 {
+		stats["avg_candidates"] = round3(float64(x.candidates.Load()) / float64(q))
+	}
+	return stats
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSimilarityIndexFindsOverlappingFollowSets(t *testing.T) {
+	g := NewGraph()
+	targets := make([]string, 40)
+	for i := range targets {
+		targets[i] = fmt.Sprintf("t%d", i)
+	}
+	// twin shares 18 of alice's 20 follows; 300 others follow disjoint sets
+	for _, f := range targets[:20] {
+		g.AddFollow("alice", f)
+	}
+	for _, f := range targets[2:22] {
+		g.AddFollow("twin", f)
+	}
+	for i := 0; i < 300; i++ {
+		for j := 0; j < 5; j++ {
+			g.AddFollow(fmt.Sprintf("other%d", i), fmt.Sprintf("x%d-%d", i, j))
+		}
+	}
+	g.AddFollow("idle", "t0") // below similarityMinFollows
+
+	x := NewSimilarityIndex()
+	if _, ok := x.Candidates("alice", g.GetFollows("alice")); ok {
+		t.Fatal("expected no candidates before the first build")
+	}
+	x.Build(g)
+
+	candidates, ok := x.Candidates("alice", g.GetFollows("alice"))
+	if !ok {
+		t.Fatal("expected the built index to answer")
+	}
+	found := false
+	for _, c := range candidates {
+		switch c {
+		case "twin":
+			found = true
+		case "alice", "idle":
+			t.Errorf("unexpected candidate %s", c)
+		}
+	}
+	if !found || len(candidates) > 10 {
+		t.Errorf("expected twin among a handful of candidates, got %v", candidates)
+	}
+
+	stats := x.Stats()
+	if stats["indexed"] != 302 || stats["queries"] != int64(1) || stats["built_at"] == nil {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
+
+func TestHandleSimilarUsesIndex(t *testing.T) {
+	oldGraph, oldIndex := graph, similarityIndex
+	t.Cleanup(func() { graph, similarityIndex = oldGraph, oldIndex })
+	graph, similarityIndex = NewGraph(), NewSimilarityIndex()
+	for _, f := range []string{"bob", "carol", "dave", "frank"} {
+		graph.AddFollow("alice", f)
+		graph.AddFollow("eve", f)
+	}
+	graph.AddFollow("eve", "grace")
+	for _, f := range []string{"heidi", "ivan", "judy"} {
+		graph.AddFollow("mallory", f)
+	}
+	graph.ComputePageRank(20, 0.85)
+
+	similar := func() map[string]interface{} {
+		w := httptest.NewRecorder()
+		handleSimilar(w, httptest.NewRequest("GET", "/similar?pubkey=alice", nil))
+		var resp map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+	if resp := similar(); resp["method"] != "scan" {
+		t.Errorf("expected a full scan before the index is built, got %v", resp)
+	}
+
+	similarityIndex.Build(graph.Snapshot())
+	resp := similar()
+	results, _ := resp["similar"].([]interface{})
+	if resp["method"] != "lsh" || len(results) != 1 || results[0].(map[string]interface{})["pubkey"] != "eve" {
+		t.Errorf("expected eve from the index, got %v", resp)
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+)
+
+const (
+	// simulateMaxEdges caps the hypothetical follows in one /simulate request.
+	simulateMaxEdges = 500
+	// simulateMaxPushes bounds the push loop; the projection is reported as not
+	// converged when it runs out.
+	simulateMaxPushes = 200000
+)
+
+// SimulateEdge is a hypothetical follow.
+type SimulateEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// SimulateRequest is the body of POST /simulate: new followers of pubkey, and
+// any other follows to add alongside them.
+type SimulateRequest struct {
+	Pubkey    string         `json:"pubkey"`
+	Followers []string       `json:"followers"`
+	Edges     []SimulateEdge `json:"edges"`
+}
+
+// SimulatedStanding is a pubkey's score, rank and percentile before or after.
+type SimulatedStanding struct {
+	Score      int     `json:"score"`
+	RawScore   float64 `json:"raw_score"`
+	Rank       int     `json:"rank"`
+	Percentile float64 `json:"percentile"`
+}
+
+// SimulateContribution is what one new follower passes to pubkey directly.
+type SimulateContribution struct {
+	Pubkey  string  `json:"pubkey"`
+	Score   int     `json:"score"`
+	Follows int     `json:"follows"` // including the hypothetical follows
+	Raw     float64 `json:"raw_contribution"`
+	Share   float64 `json:"share"` // of all new followers' direct contribution
+}
+
+// SimulateResponse is the projection for POST /simulate.
+type SimulateResponse struct {
+	Pubkey        string                 `json:"pubkey"`
+	EdgesAdded    int                    `json:"edges_added"`
+	EdgesSkipped  int                    `json:"edges_skipped"` // self-follows and follows that already exist
+	Current       SimulatedStanding      `json:"current"`
+	Projected     SimulatedStanding      `json:"projected"`
+	ScoreDelta    int                    `json:"score_delta"`
+	RankDelta     int                    `json:"rank_delta"` // positive when moving up
+	PercentileGap float64                `json:"percentile_delta"`
+	Contributions []SimulateContribution `json:"contributions"`
+	AffectedCount int                    `json:"affected_count"`
+	Method        string                 `json:"method"`
+	Pushes        int                    `json:"pushes"`
+	Converged     bool                   `json:"converged"`
+	GraphSize     int                    `json:"graph_size"`
+}
+
+// followProjection is PageRank after hypothetical follows, as changes to the
+// current scores.
+type followProjection struct {
+	pushResult
+	g       *Graph
+	scores  map[string]float64
+	added   map[string][]string // from -> new follows
+	scale   float64             // n/n': the teleport share every current score is built on shrinks with new pubkeys
+	newSize int                 // nodes after the follows, counting pubkeys new to the graph
+}
+
+// follows returns pk's follows with the hypothetical ones appended.
+func (fp *followProjection) follows(pk string) []string {
+	if len(fp.added[pk]) == 0 {
+		return fp.g.GetFollows(pk)
+	}
+	return append(append([]string(nil), fp.g.GetFollows(pk)...), fp.added[pk]...)
+}
+
+func (fp *followProjection) outDegree(pk string) int {
+	return len(fp.g.GetFollows(pk)) + len(fp.added[pk])
+}
+
+func (fp *followProjection) projected(pk string) float64 {
+	return fp.scale*fp.scores[pk] + fp.delta[pk]
+}
+
+// projectFollows approximates PageRank with edges added, without a full
+// recompute. The current scores are taken as the fixed point of the old graph;
+// the new edges change the shares their followers pass on, which leaves a
+// residual at those followers' targets. Pubkeys new to the graph shrink the
+// teleport share (1-d)/n, and since PageRank is linear in it the current scores
+// are scaled by n/n' rather than corrected node by node. The residual is then pushed along
+// follows (see pushResidual) until every node's is under the tolerance, so only
+// the part of the graph the change reaches is visited. Edges that are
+// self-follows or already exist are skipped.
+func projectFollows(g *Graph, scores map[string]float64, edges []SimulateEdge, damping float64) (*followProjection, int) {
+	fp := &followProjection{g: g, scores: scores, added: make(map[string][]string), scale: 1}
+	fp.delta = make(map[string]float64)
+	skipped := 0
+	existing := make(map[string]map[string]bool)
+	newNodes := make(map[string]bool)
+	for _, e := range edges {
+		if existing[e.From] == nil {
+			existing[e.From] = make(map[string]bool)
+			for _, t := range g.GetFollows(e.From) {
+				existing[e.From][t] = true
+			}
+		}
+		if e.From == e.To || existing[e.From][e.To] {
+			skipped++
+			continue
+		}
+		existing[e.From][e.To] = true
+		fp.added[e.From] = append(fp.added[e.From], e.To)
+		for _, pk := range []string{e.From, e.To} {
+			if _, ok := scores[pk]; !ok {
+				newNodes[pk] = true
+			}
+		}
+	}
+	fp.newSize = len(scores) + len(newNodes)
+	if fp.newSize == 0 {
+		fp.converged = true
+		return fp, skipped
+	}
+
+	if len(scores) > 0 {
+		fp.scale = float64(len(scores)) / float64(fp.newSize)
+	}
+	base := (1 - damping) / float64(fp.newSize)
+	residual := make(map[string]float64)
+	// New pubkeys start with the teleport share
+	for pk := range newNodes {
+		residual[pk] += base
+	}
+	for from, added := range fp.added {
+		p := fp.scale * scores[from]
+		old := len(g.GetFollows(from))
+		now := old + len(added)
+		if old > 0 {
+			shrink := damping * p * (1/float64(now) - 1/float64(old))
+			for _, t := range g.GetFollows(from) {
+				residual[t] += shrink
+			}
+		}
+		for _, t := range added {
+			residual[t] += damping * p / float64(now)
+		}
+	}
+
+	fp.pushResult = pushResidual(residual, fp.follows, damping, incrementalTolerance*base, simulateMaxPushes)
+	return fp, skipped
+}
+
+// standing ranks raw against the projected scores of every other node.
+func (fp *followProjection) standing(pubkey string) (rank int, percentile float64) {
+	raw := fp.projected(pubkey)
+	rank, below := 1, 0
+	count := func(pk string, s float64) {
+		if pk == pubkey {
+			return
+		}
+		if s > raw {
+			rank++
+		} else if s < raw {
+			below++
+		}
+	}
+	for pk, s := range fp.scores {
+		count(pk, fp.scale*s+fp.delta[pk])
+	}
+	for pk, d := range fp.delta {
+		if _, ok := fp.scores[pk]; !ok {
+			count(pk, d)
+		}
+	}
+	return rank, float64(below) / float64(fp.newSize)
+}
+
+// handleSimulate handles POST /simulate
+// Projects pubkey's score, rank and percentile if the given accounts followed it
+// (and any other edges were added), using an incremental PageRank approximation
+// from the current scores. The counterpart of /influence?action=unfollow.
+func handleSimulate(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req SimulateRequest
+	if e := bindJSON(w, r, &req, 1<<16); e != nil {
+		writeAPIError(w, e)
+		return
+	}
+	if req.Pubkey == "" {
+		writeAPIError(w, missingParam("pubkey"))
+		return
+	}
+	pubkey, err := resolvePubkey(req.Pubkey)
+	if err != nil || !hex64Pattern.MatchString(pubkey) {
+		writeAPIError(w, invalidParam("pubkey", "pubkey must be a hex or npub pubkey"))
+		return
+	}
+	if len(req.Followers)+len(req.Edges) == 0 {
+		writeAPIError(w, missingParam("followers"))
+		return
+	}
+	if len(req.Followers)+len(req.Edges) > simulateMaxEdges {
+		writeAPIError(w, invalidParam("followers", "at most %d followers and edges per simulation", simulateMaxEdges))
+		return
+	}
+	var edges []SimulateEdge
+	for _, f := range req.Followers {
+		from, err := resolvePubkey(f)
+		if err != nil || !hex64Pattern.MatchString(from) {
+			writeAPIError(w, invalidParam("followers", "invalid follower %q", f))
+			return
+		}
+		edges = append(edges, SimulateEdge{From: from, To: pubkey})
+	}
+	for i, e := range req.Edges {
+		from, err1 := resolvePubkey(e.From)
+		to, err2 := resolvePubkey(e.To)
+		if err1 != nil || err2 != nil || !hex64Pattern.MatchString(from) || !hex64Pattern.MatchString(to) {
+			writeAPIError(w, invalidParam("edges", "edge %d: from and to must be hex or npub pubkeys", i))
+			return
+		}
+		edges = append(edges, SimulateEdge{From: from, To: to})
+	}
+
+	g := graph.Snapshot()
+	scores := g.ScoresSnapshot()
+	if len(scores) == 0 {
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "scores have not been computed yet"})
+		return
+	}
+	fp, skipped := projectFollows(g, scores, edges, config.Get().Damping)
+
+	current := SimulatedStanding{RawScore: scores[pubkey], Percentile: g.Percentile(pubkey)}
+	current.Score = g.NormalizeScore(current.RawScore, "")
+	current.Rank = g.Rank(pubkey)
+	if current.Rank == 0 {
+		current.Rank = len(scores) + 1
+	}
+	projected := SimulatedStanding{RawScore: fp.projected(pubkey)}
+	projected.Score = g.NormalizeScore(projected.RawScore, "")
+	projected.Rank, projected.Percentile = fp.standing(pubkey)
+
+	contributions := []SimulateContribution{}
+	total := 0.0
+	for from, added := range fp.added {
+		for _, t := range added {
+			if t != pubkey {
+				continue
+			}
+			out := fp.outDegree(from)
+			c := SimulateContribution{Pubkey: from, Score: g.NormalizeScore(scores[from], ""), Follows: out,
+				Raw: config.Get().Damping * fp.projected(from) / float64(out)}
+			total += c.Raw
+			contributions = append(contributions, c)
+		}
+	}
+	for i := range contributions {
+		if total > 0 {
+			contributions[i].Share = math.Round(contributions[i].Raw/total*1000) / 1000
+		}
+	}
+	sort.Slice(contributions, func(i, j int) bool {
+		if contributions[i].Raw != contributions[j].Raw {
+			return contributions[i].Raw > contributions[j].Raw
+		}
+		return contributions[i].Pubkey < contributions[j].Pubkey
+	})
+
+	affected := 0
+	for _, d := range fp.delta {
+		if d != 0 {
+			affected++
+		}
+	}
+	added := 0
+	for _, a := range fp.added {
+		added += len(a)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SimulateResponse{
+		Pubkey:        pubkey,
+		EdgesAdded:    added,
+		EdgesSkipped:  skipped,
+		Current:       current,
+		Projected:     projected,
+		ScoreDelta:    projected.Score - current.Score,
+		RankDelta:     current.Rank - projected.Rank,
+		PercentileGap: math.Round((projected.Percentile-current.Percentile)*10000) / 10000,
+		Contributions: contributions,
+		AffectedCount: affected,
+		Method:        "incremental_push",
+		Pushes:        fp.pushes,
+		Converged:     fp.converged,
+		GraphSize:     fp.newSize,
+	})
+}
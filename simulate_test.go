@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// buildSimulateEdges is a small community: a well-followed hub, a ring around
+// it, and a newcomer with a single follower.
+func buildSimulateEdges() [][2]string {
+	hub, newcomer := padHex(49000), padHex(49001)
+	var edges [][2]string
+	for i := 49010; i < 49030; i++ {
+		pk := padHex(i)
+		edges = append(edges, [2]string{pk, hub}, [2]string{hub, pk}, [2]string{pk, padHex(49010 + (i-49010+1)%20)})
+	}
+	edges = append(edges, [2]string{padHex(49010), newcomer}, [2]string{newcomer, hub})
+	return edges
+}
+
+func simulateGraph(edges [][2]string) *Graph {
+	g := NewGraph()
+	for _, e := range edges {
+		g.AddFollow(e[0], e[1])
+	}
+	g.ComputePageRank(200, 0.85)
+	return g
+}
+
+func withSimulateGraph(t *testing.T) *Graph {
+	t.Helper()
+	old := graph
+	t.Cleanup(func() { graph = old })
+	graph = simulateGraph(buildSimulateEdges())
+	return graph
+}
+
+func TestProjectFollowsMatchesRecompute(t *testing.T) {
+	g := simulateGraph(buildSimulateEdges())
+	newcomer := padHex(49001)
+	edges := []SimulateEdge{
+		{From: padHex(49000), To: newcomer},
+		{From: padHex(49015), To: newcomer},
+		{From: padHex(49099), To: newcomer}, // not in the graph yet
+		{From: padHex(49010), To: newcomer}, // already follows
+		{From: newcomer, To: newcomer},
+	}
+	fp, skipped := projectFollows(g, g.ScoresSnapshot(), edges, 0.85)
+	if skipped != 2 || !fp.converged {
+		t.Fatalf("expected 2 skipped edges and convergence, got %d %v", skipped, fp.converged)
+	}
+
+	full := buildSimulateEdges()
+	for _, e := range edges[:3] {
+		full = append(full, [2]string{e.From, e.To})
+	}
+	want := simulateGraph(full).ScoresSnapshot()
+	if fp.newSize != len(want) {
+		t.Errorf("expected %d nodes after the follows, got %d", len(want), fp.newSize)
+	}
+	for _, pk := range []string{newcomer, padHex(49000), padHex(49015), padHex(49099)} {
+		got := fp.projected(pk)
+		if diff := math.Abs(got - want[pk]); diff > 0.005*want[pk] {
+			t.Errorf("%s: projected %.6f, recomputed %.6f", pk[len(pk)-5:], got, want[pk])
+		}
+	}
+	if fp.projected(newcomer) <= g.ScoresSnapshot()[newcomer] {
+		t.Errorf("expected the newcomer's score to rise")
+	}
+}
+
+func postSimulate(body string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	handleSimulate(rr, httptest.NewRequest(http.MethodPost, "/simulate", strings.NewReader(body)))
+	return rr
+}
+
+func TestHandleSimulate(t *testing.T) {
+	withSimulateGraph(t)
+	body, _ := json.Marshal(SimulateRequest{
+		Pubkey:    padHex(49001),
+		Followers: []string{padHex(49000), padHex(49020), padHex(49021), padHex(49010)},
+	})
+	rr := postSimulate(string(body))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %s", rr.Code, rr.Body.String())
+	}
+	var resp SimulateResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	if resp.EdgesAdded != 3 || resp.EdgesSkipped != 1 || !resp.Converged || resp.Method != "incremental_push" {
+		t.Errorf("unexpected summary %+v", resp)
+	}
+	if resp.Projected.RawScore <= resp.Current.RawScore || resp.Projected.Rank >= resp.Current.Rank ||
+		resp.RankDelta != resp.Current.Rank-resp.Projected.Rank || resp.Projected.Percentile <= resp.Current.Percentile {
+		t.Errorf("expected the newcomer to move up, got current %+v projected %+v", resp.Current, resp.Projected)
+	}
+	if len(resp.Contributions) != 3 || resp.Contributions[0].Pubkey != padHex(49000) {
+		t.Errorf("expected the hub to contribute most, got %+v", resp.Contributions)
+	}
+	share := 0.0
+	for _, c := range resp.Contributions {
+		share += c.Share
+	}
+	if math.Abs(share-1) > 0.01 {
+		t.Errorf("expected shares to sum to 1, got %f", share)
+	}
+}
+
+func TestHandleSimulateValidation(t *testing.T) {
+	withSimulateGraph(t)
+	many := make([]string, simulateMaxEdges+1)
+	for i := range many {
+		many[i] = padHex(49100 + i)
+	}
+	tooMany, _ := json.Marshal(SimulateRequest{Pubkey: padHex(49001), Followers: many})
+	for body, field := range map[string]string{
+		`{"followers":["` + padHex(49000) + `"]}`:                            "pubkey",
+		`{"pubkey":"nope","followers":["` + padHex(49000) + `"]}`:            "pubkey",
+		`{"pubkey":"` + padHex(49001) + `"}`:                                 "followers",
+		`{"pubkey":"` + padHex(49001) + `","followers":["bad"]}`:             "followers",
+		`{"pubkey":"` + padHex(49001) + `","edges":[{"from":"x","to":"y"}]}`: "edges",
+		string(tooMany): "followers",
+	} {
+		rr := postSimulate(body)
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"field":"`+field+`"`) {
+			t.Errorf("expected 400 naming %s, got %d %s", field, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handleSimulate(rr, httptest.NewRequest(http.MethodGet, "/simulate", bytes.NewReader(nil)))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", rr.Code)
+	}
+
+	graph = NewGraph()
+	if rr := postSimulate(`{"pubkey":"` + padHex(49001) + `","followers":["` + padHex(49000) + `"]}`); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 before scores exist, got %d", rr.Code)
+	}
+}
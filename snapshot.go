@@ -0,0 +1,89 @@
+package main
+
+import "sync/atomic"
+
+// graphVersioning is embedded in Graph to support snapshots. version counts
+// mutations; snap caches the snapshot of the latest published version.
+type graphVersioning struct {
+	version      atomic.Uint64
+	snap         atomic.Pointer[Graph]
+	held         atomic.Int32
+	origin       *Graph      // set on snapshots: the live graph they were taken from
+	scoresShared atomic.Bool // a snapshot or export holds the current score maps
+}
+
+// Snapshot returns an immutable copy of the graph for one request to read from, so
+// a handler that looks at scores, follows and followers several times sees a single
+// consistent state even while the crawler or a hint is changing the live graph.
+//
+// Snapshots are cheap to share: the same one is returned until the graph changes,
+// and a new one is published on the first read after a change. While the crawler
+// holds the graph (see Hold) readers keep the last published snapshot, and the
+// crawler publishes a new one when it releases it.
+//
+// A snapshot shares the follow lists and score map of the graph it came from; the
+// live graph only appends past the end of a list or replaces it, and copies the
+// score map before changing one a snapshot holds, so what a snapshot sees never
+// changes. Its mutating methods panic. Follow timestamps are not
+// copied; GetFollowTime and the decay computation read them from the live graph.
+func (g *Graph) Snapshot() *Graph {
+	if g.origin != nil {
+		return g
+	}
+	if s := g.snap.Load(); s != nil && (s.version.Load() == g.version.Load() || g.held.Load() > 0) {
+		return s
+	}
+	return g.publish()
+}
+
+// publish takes a snapshot of the current state and installs it for readers.
+func (g *Graph) publish() *Graph {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if s := g.snap.Load(); s != nil && s.version.Load() == g.version.Load() {
+		return s // another reader just published this version
+	}
+	s := &Graph{
+		follows:      make(map[string][]string, len(g.follows)),
+		followers:    make(map[string][]string, len(g.followers)),
+		scores:       g.scores,
+		buckets:      g.buckets,
+		dist:         g.dist,
+		lastBuild:    g.lastBuild,
+		scoreUpdated: g.scoreUpdated,
+		drift:        g.drift,
+	}
+	s.origin = g
+	g.scoresShared.Store(true)
+	for k, v := range g.follows {
+		s.follows[k] = v
+	}
+	for k, v := range g.followers {
+		s.followers[k] = v
+	}
+	s.version.Store(g.version.Load())
+	g.snap.Store(s)
+	return s
+}
+
+// Hold keeps readers on the current snapshot while the caller makes a batch of
+// changes, such as a crawl, so they never see it half applied. Release publishes
+// the result.
+func (g *Graph) Hold() {
+	g.Snapshot()
+	g.held.Add(1)
+}
+
+func (g *Graph) Release() {
+	if g.held.Add(-1) == 0 {
+		g.publish()
+	}
+}
+
+// changed records a mutation. Callers hold g.mu for writing.
+func (g *Graph) changed() {
+	if g.origin != nil {
+		panic("graph snapshot is read-only")
+	}
+	g.version.Add(1)
+}
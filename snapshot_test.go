@@ -0,0 +1,134 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnapshotIsolatedFromLiveGraph(t *testing.T) {
+	a, b, c := padHex(42001), padHex(42002), padHex(42003)
+	g := NewGraph()
+	g.AddFollow(a, b)
+	g.AddFollow(c, b)
+	g.ComputePageRank(20, 0.85)
+
+	s := g.Snapshot()
+	if g.Snapshot() != s {
+		t.Error("expected the snapshot reused while the graph is unchanged")
+	}
+	score, _ := s.GetScore(b)
+
+	g.AddFollow(a, c)
+	g.SetFollows(c, []string{a}, time.Unix(1772000000, 0))
+	g.PushScores(c, []string{a}, []string{b}, 0.85)
+	g.ComputePageRank(20, 0.85)
+
+	if got := s.GetFollows(a); len(got) != 1 || got[0] != b {
+		t.Errorf("expected the snapshot's follows unchanged, got %v", got)
+	}
+	if got := s.GetFollowers(b); len(got) != 2 {
+		t.Errorf("expected both followers still in the snapshot, got %v", got)
+	}
+	if got, _ := s.GetScore(b); got != score {
+		t.Errorf("expected the snapshot's score %v unchanged, got %v", score, got)
+	}
+
+	next := g.Snapshot()
+	if next == s {
+		t.Fatal("expected a new snapshot after the graph changed")
+	}
+	if got := next.GetFollowers(b); len(got) != 1 || got[0] != a {
+		t.Errorf("expected the new snapshot to see c's unfollow, got %v", got)
+	}
+	if next.Snapshot() != next {
+		t.Error("expected a snapshot to be its own snapshot")
+	}
+}
+
+func TestSnapshotHold(t *testing.T) {
+	a, b := padHex(42101), padHex(42102)
+	g := NewGraph()
+	g.AddFollow(a, b)
+	before := g.Snapshot()
+
+	g.Hold()
+	g.AddFollow(b, a)
+	if g.Snapshot() != before {
+		t.Error("expected readers kept on the old snapshot while held")
+	}
+	g.Release()
+
+	after := g.Snapshot()
+	if after == before || len(after.GetFollows(b)) != 1 {
+		t.Error("expected the release to publish the batch")
+	}
+}
+
+func TestSnapshotReadOnly(t *testing.T) {
+	g := NewGraph()
+	g.AddFollow(padHex(42201), padHex(42202))
+	s := g.Snapshot()
+	defer func() {
+		if recover() == nil {
+			t.Error("expected mutating a snapshot to panic")
+		}
+	}()
+	s.AddFollow(padHex(42202), padHex(42201))
+}
+
+func TestSnapshotFollowTimes(t *testing.T) {
+	a, b := padHex(42301), padHex(42302)
+	at := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	g := NewGraph()
+	g.AddFollowWithTime(a, b, at)
+	g.ComputePageRank(20, 0.85)
+
+	s := g.Snapshot()
+	if got := s.GetFollowTime(a, b); !got.Equal(at) {
+		t.Errorf("expected the follow time from the live graph, got %v", got)
+	}
+	if len(s.ComputeDecayedPageRank(20, 0.85, 365)) != 2 {
+		t.Error("expected decayed scores for both nodes")
+	}
+}
+
+func TestSnapshotConcurrentReads(t *testing.T) {
+	g := NewGraph()
+	star := padHex(42400)
+	for i := 0; i < 50; i++ {
+		g.AddFollow(padHex(42401+i), star)
+	}
+	g.ComputePageRank(20, 0.85)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			from := padHex(42401 + i%50)
+			g.SetFollows(from, []string{star, padHex(42500 + i)}, time.Unix(int64(1772000000+i), 0))
+			if i%20 == 0 {
+				g.ComputePageRank(5, 0.85)
+			}
+		}
+	}()
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				s := g.Snapshot()
+				followers := s.GetFollowers(star)
+				for _, f := range followers {
+					s.GetFollows(f)
+				}
+				if len(s.GetFollowers(star)) != len(followers) {
+					t.Error("expected a snapshot's follower count to hold steady")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
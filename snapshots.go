@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxScoreSnapshots caps snapshot_retention.
+const maxScoreSnapshots = 1000
+
+// ScoreSnapshot describes the full score table as of one rebuild. Top10Hash is the
+// sha256 of the ten highest-scored pubkeys, newline-separated in rank order, so two
+// snapshots with the same leaders are easy to spot without downloading either.
+type ScoreSnapshot struct {
+	ID        string    `json:"id"`
+	Build     uint64    `json:"build"`
+	At        time.Time `json:"at"`
+	Nodes     int       `json:"nodes"`
+	Edges     int       `json:"edges"`
+	Scored    int       `json:"scored"`
+	Top10Hash string    `json:"top10_hash"`
+}
+
+// ScoreSnapshots keeps the score table of recent rebuilds for /snapshots, so
+// researchers can download the scores as they stood at a past rebuild. When dir
+// is set each table is written to dir/<id>.ndjson.gz with an index.json beside
+// it and reloaded on startup; otherwise tables are kept in memory.
+type ScoreSnapshots struct {
+	mu    sync.RWMutex
+	dir   string
+	list  []ScoreSnapshot        // oldest first
+	rows  map[string][]ExportRow // id -> table, when kept in memory
+	nowFn func() time.Time
+}
+
+func NewScoreSnapshots(dir string) *ScoreSnapshots {
+	return &ScoreSnapshots{dir: dir, rows: make(map[string][]ExportRow), nowFn: time.Now}
+}
+
+// scoreSnapshots is configured with SNAPSHOTS_DIR, e.g. /var/lib/wot-scoring/snapshots.
+var scoreSnapshots = NewScoreSnapshots(os.Getenv("SNAPSHOTS_DIR"))
+
+type snapshotIndex struct {
+	Snapshots []ScoreSnapshot `json:"snapshots"`
+}
+
+// Load reads the saved index, skipping snapshots whose table is missing. A missing
+// index is not an error.
+func (s *ScoreSnapshots) Load() error {
+	if s.dir == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(filepath.Join(s.dir, "index.json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var idx snapshotIndex
+	if err := json.Unmarshal(raw, &idx); err != nil {
+		return fmt.Errorf("%s: %w", s.dir, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.list = s.list[:0]
+	for _, snap := range idx.Snapshots {
+		if _, err := os.Stat(s.tablePath(snap.ID)); err != nil {
+			log.Printf("Score snapshot %s has no table, skipping", snap.ID)
+			continue
+		}
+		s.list = append(s.list, snap)
+	}
+	return nil
+}
+
+func (s *ScoreSnapshots) tablePath(id string) string {
+	return filepath.Join(s.dir, id+".ndjson.gz")
+}
+
+// saveIndex writes the snapshot list out. Callers hold s.mu.
+func (s *ScoreSnapshots) saveIndex() error {
+	if s.dir == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(snapshotIndex{Snapshots: s.list}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, "index.json"), raw)
+}
+
+// snapshotRows builds g's score table in export order, as /export writes it.
+func snapshotRows(g *Graph) []ExportRow {
+	nodes := g.Stats().Nodes
+	scores := g.ScoresSnapshot()
+	entries := make([]ScoreEntry, 0, len(scores))
+	for pk, score := range scores {
+		entries = append(entries, ScoreEntry{Pubkey: pk, Score: score})
+	}
+	sort.Slice(entries, func(i, j int) bool { return exportBefore(entries[i], entries[j]) })
+	rows := make([]ExportRow, len(entries))
+	for i, e := range entries {
+		rows[i] = ExportRow{
+			Rank:      i + 1,
+			Pubkey:    e.Pubkey,
+			Score:     normalizeScore(e.Score, nodes),
+			RawScore:  e.Score,
+			Followers: len(g.GetFollowers(e.Pubkey)),
+			Follows:   len(g.GetFollows(e.Pubkey)),
+		}
+		if c, ok := communities.GetCommunity(e.Pubkey); ok {
+			rows[i].Community = &c
+		}
+	}
+	return rows
+}
+
+// top10Hash hashes the pubkeys of the first ten rows.
+func top10Hash(rows []ExportRow) string {
+	top := make([]string, 0, 10)
+	for _, row := range rows[:min(len(rows), 10)] {
+		top = append(top, row.Pubkey)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(top, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Record snapshots g's scores as build, then drops snapshots beyond the newest
+// keep or older than maxAgeDays (0 is no age limit). With keep 0 nothing is kept.
+func (s *ScoreSnapshots) Record(g *Graph, build uint64, at time.Time, keep, maxAgeDays int) {
+	var rows []ExportRow
+	var snap ScoreSnapshot
+	if keep > 0 {
+		stats := g.Stats()
+		rows = snapshotRows(g)
+		snap = ScoreSnapshot{
+			ID:        graphSnapshotVersion(build, at),
+			Build:     build,
+			At:        at.UTC(),
+			Nodes:     stats.Nodes,
+			Edges:     stats.Edges,
+			Scored:    len(rows),
+			Top10Hash: top10Hash(rows),
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if snap.ID != "" {
+		if err := s.writeTable(snap.ID, rows); err != nil {
+			log.Printf("Score snapshot %s save failed: %v", snap.ID, err)
+		} else {
+			s.list = append(s.list, snap)
+		}
+	}
+	s.prune(keep, maxAgeDays)
+	if err := s.saveIndex(); err != nil {
+		log.Printf("Score snapshot index save failed: %v", err)
+	}
+}
+
+// writeTable stores a snapshot's rows. Callers hold s.mu.
+func (s *ScoreSnapshots) writeTable(id string, rows []ExportRow) error {
+	if s.dir == "" {
+		s.rows[id] = rows
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.tablePath(id), buf.Bytes())
+}
+
+// prune drops the snapshots retention no longer covers. Callers hold s.mu.
+func (s *ScoreSnapshots) prune(keep, maxAgeDays int) {
+	cutoff := time.Time{}
+	if maxAgeDays > 0 {
+		cutoff = s.nowFn().Add(-time.Duration(maxAgeDays) * 24 * time.Hour)
+	}
+	kept := s.list[:0]
+	for i, snap := range s.list {
+		if len(s.list)-i > keep || snap.At.Before(cutoff) {
+			delete(s.rows, snap.ID)
+			if s.dir != "" {
+				if err := os.Remove(s.tablePath(snap.ID)); err != nil && !os.IsNotExist(err) {
+					log.Printf("Score snapshot %s removal failed: %v", snap.ID, err)
+				}
+			}
+			continue
+		}
+		kept = append(kept, snap)
+	}
+	s.list = kept
+}
+
+// List returns the retained snapshots, newest first.
+func (s *ScoreSnapshots) List() []ScoreSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ScoreSnapshot, len(s.list))
+	for i, snap := range s.list {
+		out[len(s.list)-1-i] = snap
+	}
+	return out
+}
+
+// Rows returns a function emitting snapshot id's rows in rank order, or false
+// when id isn't retained.
+func (s *ScoreSnapshots) Rows(id string) (func(emit func(ExportRow) bool), bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	found := false
+	for _, snap := range s.list {
+		if snap.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, false
+	}
+	if s.dir == "" {
+		rows := s.rows[id]
+		return func(emit func(ExportRow) bool) {
+			for _, row := range rows {
+				if !emit(row) {
+					return
+				}
+			}
+		}, true
+	}
+	f, err := os.Open(s.tablePath(id))
+	if err != nil {
+		return nil, false // the table went missing on disk
+	}
+	return func(emit func(ExportRow) bool) {
+		defer f.Close()
+		gz, err := gzip.NewReader(bufio.NewReader(f))
+		if err != nil {
+			log.Printf("Score snapshot %s read failed: %v", id, err)
+			return
+		}
+		dec := json.NewDecoder(gz)
+		for dec.More() {
+			var row ExportRow
+			if err := dec.Decode(&row); err != nil {
+				log.Printf("Score snapshot %s read failed: %v", id, err)
+				return
+			}
+			if !emit(row) {
+				return
+			}
+		}
+	}, true
+}
+
+// handleSnapshots lists the retained score snapshots, newest first, with the
+// retention policy.
+func handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	cfg := config.Get()
+	snaps := scoreSnapshots.List()
+	storage := "memory"
+	if scoreSnapshots.dir != "" {
+		storage = "disk"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"snapshots": snaps,
+		"count":     len(snaps),
+		"retention": map[string]int{
+			"max_snapshots": cfg.SnapshotRetention,
+			"max_age_days":  cfg.SnapshotRetentionDays,
+		},
+		"storage": storage,
+	})
+}
+
+// handleSnapshotExport downloads a retained snapshot's full score table, in the
+// same formats as /export: json (the default, which format=npub rewrites as for
+// any JSON response), ndjson, csv or parquet.
+func handleSnapshotExport(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	q := bindQuery(r)
+	format := q.OneOf("format", "json", "ndjson", "csv", "parquet", "hex", "npub")
+	if q.Failed(w) {
+		return
+	}
+	switch format {
+	case "", "hex", "npub":
+		format = "json"
+	}
+	id := r.PathValue("id")
+	rows, ok := scoreSnapshots.Rows(id)
+	if !ok {
+		writeAPIError(w, &APIError{Status: http.StatusNotFound, Message: "no retained snapshot " + id + "; see /snapshots"})
+		return
+	}
+	serveExportRows(w, r, format, "wot-scores-"+id, rows)
+}
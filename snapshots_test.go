@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// snapshotGraph builds a graph where padHex(51600) is followed by n others.
+func snapshotGraph(n int) *Graph {
+	g := NewGraph()
+	for i := 1; i <= n; i++ {
+		g.AddFollow(padHex(51600+i), padHex(51600))
+		g.AddFollow(padHex(51600), padHex(51600+i))
+	}
+	g.ComputePageRank(20, 0.85)
+	return g
+}
+
+// collectRows returns the rows of snapshot id.
+func collectRows(t *testing.T, s *ScoreSnapshots, id string) []ExportRow {
+	t.Helper()
+	rows, ok := s.Rows(id)
+	if !ok {
+		t.Fatalf("expected snapshot %s retained", id)
+	}
+	var out []ExportRow
+	rows(func(row ExportRow) bool {
+		out = append(out, row)
+		return true
+	})
+	return out
+}
+
+func TestScoreSnapshotsRetention(t *testing.T) {
+	s := NewScoreSnapshots("")
+	now := time.Unix(1_800_000_000, 0)
+	s.nowFn = func() time.Time { return now }
+	g := snapshotGraph(12)
+
+	for build := uint64(1); build <= 3; build++ {
+		s.Record(g, build, now.Add(-time.Duration(3-build)*48*time.Hour), 2, 0)
+	}
+	list := s.List()
+	if len(list) != 2 || list[0].Build != 3 || list[1].Build != 2 {
+		t.Fatalf("expected the newest two snapshots, got %+v", list)
+	}
+	if _, ok := s.Rows(graphSnapshotVersion(1, now.Add(-96*time.Hour))); ok {
+		t.Error("expected the oldest snapshot dropped")
+	}
+	if snap := list[0]; snap.Nodes != 13 || snap.Edges != 24 || snap.Scored != 13 || len(snap.Top10Hash) != 64 {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+	if list[0].Top10Hash != list[1].Top10Hash {
+		t.Error("expected the same leaders to hash the same")
+	}
+	rows := collectRows(t, s, list[0].ID)
+	if len(rows) != 13 || rows[0].Pubkey != padHex(51600) || rows[0].Rank != 1 || rows[0].Followers != 12 {
+		t.Errorf("expected the hub ranked first, got %+v", rows[:1])
+	}
+	hash := list[0].Top10Hash
+
+	// a rebuild 36 hours on with a one day age limit keeps only itself
+	now = now.Add(36 * time.Hour)
+	s.Record(snapshotGraph(5), 4, now, 2, 1)
+	if list := s.List(); len(list) != 1 || list[0].Build != 4 || list[0].Top10Hash == hash {
+		t.Errorf("expected only the new snapshot within a day, got %+v", list)
+	}
+	s.Record(g, 5, now, 0, 0)
+	if list := s.List(); len(list) != 0 || len(s.rows) != 0 {
+		t.Errorf("expected retention 0 to keep nothing, got %+v", list)
+	}
+}
+
+func TestScoreSnapshotsDisk(t *testing.T) {
+	dir := t.TempDir()
+	s := NewScoreSnapshots(dir)
+	at := time.Unix(1_800_000_000, 0)
+	s.Record(snapshotGraph(12), 7, at, 1, 0)
+	id := graphSnapshotVersion(7, at)
+
+	// a restart finds the table again
+	reloaded := NewScoreSnapshots(dir)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if list := reloaded.List(); len(list) != 1 || list[0].ID != id {
+		t.Fatalf("expected the saved snapshot, got %+v", list)
+	}
+	if rows := collectRows(t, reloaded, id); len(rows) != 13 || rows[0].Pubkey != padHex(51600) || rows[12].Rank != 13 {
+		t.Errorf("expected the saved rows, got %d", len(rows))
+	}
+
+	reloaded.Record(snapshotGraph(3), 8, at.Add(time.Hour), 1, 0)
+	if _, err := os.Stat(reloaded.tablePath(id)); !os.IsNotExist(err) {
+		t.Errorf("expected the dropped snapshot's table removed, got %v", err)
+	}
+}
+
+func TestHandleSnapshots(t *testing.T) {
+	old := scoreSnapshots
+	t.Cleanup(func() { scoreSnapshots = old })
+	scoreSnapshots = NewScoreSnapshots("")
+	scoreSnapshots.Record(snapshotGraph(12), 3, time.Now(), 5, 0)
+	id := scoreSnapshots.List()[0].ID
+
+	w := httptest.NewRecorder()
+	handleSnapshots(w, httptest.NewRequest(http.MethodGet, "/snapshots", nil))
+	var resp struct {
+		Snapshots []ScoreSnapshot `json:"snapshots"`
+		Count     int             `json:"count"`
+		Retention map[string]int  `json:"retention"`
+		Storage   string          `json:"storage"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if resp.Count != 1 || resp.Snapshots[0].ID != id || resp.Storage != "memory" ||
+		resp.Retention["max_snapshots"] != config.Get().SnapshotRetention {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	export := func(id, format string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/snapshots/"+id+"/export?format="+format, nil)
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		handleSnapshotExport(w, r)
+		return w
+	}
+	w = export(id, "csv")
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if w.Code != http.StatusOK || err != nil || len(records) != 14 || records[1][1] != padHex(51600) {
+		t.Errorf("expected a header and 13 rows, got %d %v: %v", w.Code, err, records)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="wot-scores-`+id+`.csv"` {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+	var entries []ExportEntry
+	if w = export(id, ""); json.Unmarshal(w.Body.Bytes(), &entries) != nil || len(entries) != 13 {
+		t.Errorf("expected the json export array, got %s", w.Body.String())
+	}
+	if w = export("1-1", "csv"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown snapshot, got %d", w.Code)
+	}
+	if w = export(id, "xml"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown format, got %d", w.Code)
+	}
+}
+
+func TestExportFormatPath(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/export":                  true,
+		"/top":                     true,
+		"/snapshots/3-1700/export": true,
+		"/snapshots//export":       false,
+		"/snapshots/a/b/export":    false,
+		"/snapshots":               false,
+		"/score":                   false,
+	} {
+		if got := exportFormatPath(path); got != want {
+			t.Errorf("%s: expected %v, got %v", path, want, got)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"math"
 	"net/http"
 	"time"
@@ -15,6 +14,8 @@ type SpamSignal struct {
 	Weight float64 `json:"weight"` // how much this contributes to final score
 	Score  float64 `json:"score"`  // weighted contribution (0=human, 1=spam)
 	Reason string  `json:"reason"` // human-readable explanation
+
+	reason message // Reason before localization
 }
 
 // SpamResponse is the full response for the /spam endpoint.
@@ -25,16 +26,35 @@ type SpamResponse struct {
 	Signals        []SpamSignal `json:"signals"`
 	Summary        string       `json:"summary"`
 	GraphSize      int          `json:"graph_size"`
+
+	summary message // Summary before localization
+}
+
+// localize renders the summary and signal reasons in locale.
+func (s *SpamResponse) localize(locale string) {
+	s.Summary = s.summary.In(locale)
+	for i := range s.Signals {
+		if s.Signals[i].reason.format != "" {
+			s.Signals[i].Reason = s.Signals[i].reason.In(locale)
+		}
+	}
 }
 
 // computeSpam analyzes a pubkey for spam indicators and returns a SpamResponse.
 func computeSpam(pubkey string, graphSize int) SpamResponse {
+	return computeSpamWithPercentile(pubkey, graphSize, graph.Percentile(pubkey))
+}
+
+// computeSpamWithPercentile is computeSpam with the score percentile supplied by the
+// caller. The percentile only shapes the wot_score reason text, not the probability,
+// so hot loops that just need the classification can pass 0 and skip the O(n) lookup.
+func computeSpamWithPercentile(pubkey string, graphSize int, percentile float64) SpamResponse {
 	rawScore, found := graph.GetScore(pubkey)
 	score := normalizeScore(rawScore, graphSize)
-	percentile := graph.Percentile(pubkey)
 	followers := graph.GetFollowers(pubkey)
 	follows := graph.GetFollows(pubkey)
 	m := meta.Get(pubkey)
+	reports := reportStore.Analyze(pubkey, graph, time.Now())
 
 	var signals []SpamSignal
 
@@ -42,8 +62,9 @@ func computeSpam(pubkey string, graphSize int) SpamResponse {
 	signals = append(signals, spamSignalFollowRatio(len(followers), len(follows)))
 	signals = append(signals, spamSignalAge(m.FirstCreated))
 	signals = append(signals, spamSignalEngagement(m.ReactionsRecd, m.ZapCntRecd, m.PostCount))
-	signals = append(signals, spamSignalReports(m.ReportsRecd))
+	signals = append(signals, spamSignalReports(reports.Reports, reports.WeightedReports))
 	signals = append(signals, spamSignalActivity(m.PostCount, m.ReplyCount, m.ReactionsSent))
+	signals = append(signals, spamSignalAutomation(pubkeyAutomation(pubkey)))
 
 	var spamProb float64
 	for _, s := range signals {
@@ -58,34 +79,34 @@ func computeSpam(pubkey string, graphSize int) SpamResponse {
 	spamProb = math.Round(spamProb*1000) / 1000
 
 	classification := classifySpam(spamProb)
-	summary := spamSummary(classification, score, len(followers), m.ReportsRecd)
+	summary := spamSummary(classification, score, len(followers), reports.Reports)
 
 	return SpamResponse{
 		Pubkey:          pubkey,
 		SpamProbability: spamProb,
 		Classification:  classification,
 		Signals:         signals,
-		Summary:         summary,
+		Summary:         summary.In("en"),
 		GraphSize:       graphSize,
+		summary:         summary,
 	}
 }
 
 // handleSpam analyzes a pubkey for spam indicators using WoT graph signals.
 func handleSpam(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
+	locale, ok := requestLocale(w, r)
+	if !ok {
 		return
 	}
-
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
 		return
 	}
 
 	stats := graph.Stats()
 	resp := computeSpam(pubkey, stats.Nodes)
+	resp.localize(locale)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
@@ -102,24 +123,27 @@ type SpamBatchResult struct {
 // handleSpamBatch checks up to 100 pubkeys for spam in one request.
 // POST /spam/batch with JSON body: {"pubkeys": ["hex1", "hex2", ...]}
 func handleSpamBatch(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, `{"error":"POST required"}`, http.StatusMethodNotAllowed)
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	locale, ok := requestLocale(w, r)
+	if !ok {
 		return
 	}
 
 	var req struct {
 		Pubkeys []string `json:"pubkeys"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"invalid JSON body"}`, http.StatusBadRequest)
+	if apiErr := bindJSON(w, r, &req, 1<<20); apiErr != nil {
+		writeAPIError(w, apiErr)
 		return
 	}
 	if len(req.Pubkeys) == 0 {
-		http.Error(w, `{"error":"pubkeys array required"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "pubkeys", Message: "pubkeys array required"})
 		return
 	}
 	if len(req.Pubkeys) > 100 {
-		http.Error(w, `{"error":"max 100 pubkeys per request"}`, http.StatusBadRequest)
+		writeAPIError(w, invalidParam("pubkeys", "max 100 pubkeys per request"))
 		return
 	}
 
@@ -137,6 +161,7 @@ func handleSpamBatch(w http.ResponseWriter, r *http.Request) {
 		}
 
 		full := computeSpam(pubkey, stats.Nodes)
+		full.localize(locale)
 		results[i] = SpamBatchResult{
 			Pubkey:          pubkey,
 			SpamProbability: full.SpamProbability,
@@ -180,16 +205,16 @@ func handleSpamBatch(w http.ResponseWriter, r *http.Request) {
 func spamSignalWoT(score int, found bool, percentile float64) SpamSignal {
 	weight := 0.30
 	var raw, spamScore float64
-	var reason string
+	var reason message
 
 	if !found {
 		raw = 0
 		spamScore = weight
-		reason = "Pubkey not found in WoT graph — no trust data available"
+		reason = msg("Pubkey not found in WoT graph — no trust data available")
 	} else if score == 0 {
 		raw = 0
 		spamScore = weight
-		reason = "WoT score is 0 — no measurable trust"
+		reason = msg("WoT score is 0 — no measurable trust")
 	} else {
 		raw = float64(score)
 		// Higher score = less spam. Score 50+ is very trustworthy.
@@ -197,11 +222,11 @@ func spamSignalWoT(score int, found bool, percentile float64) SpamSignal {
 		spamFactor := 1.0 - math.Min(float64(score)/50.0, 1.0)
 		spamScore = math.Round(spamFactor*weight*1000) / 1000
 		if percentile > 0.9 {
-			reason = fmt.Sprintf("WoT score %d (top %.0f%%) — highly trusted", score, (1-percentile)*100)
+			reason = msg("WoT score %d (top %.0f%%) — highly trusted", score, (1-percentile)*100)
 		} else if percentile > 0.5 {
-			reason = fmt.Sprintf("WoT score %d (top %.0f%%) — moderate trust", score, (1-percentile)*100)
+			reason = msg("WoT score %d (top %.0f%%) — moderate trust", score, (1-percentile)*100)
 		} else {
-			reason = fmt.Sprintf("WoT score %d (bottom %.0f%%) — low trust", score, percentile*100)
+			reason = msg("WoT score %d (bottom %.0f%%) — low trust", score, percentile*100)
 		}
 	}
 
@@ -210,37 +235,38 @@ func spamSignalWoT(score int, found bool, percentile float64) SpamSignal {
 		Value:  raw,
 		Weight: weight,
 		Score:  spamScore,
-		Reason: reason,
+		Reason: reason.In("en"),
+		reason: reason,
 	}
 }
 
 func spamSignalFollowRatio(followerCount, followCount int) SpamSignal {
 	weight := 0.15
 	var raw, spamScore float64
-	var reason string
+	var reason message
 
 	if followCount == 0 && followerCount == 0 {
 		raw = 0
 		spamScore = weight * 0.5
-		reason = "No follow data — unknown"
+		reason = msg("No follow data — unknown")
 	} else if followCount == 0 {
 		raw = float64(followerCount)
 		spamScore = 0
-		reason = fmt.Sprintf("%d followers, follows nobody — passive but not spammy", followerCount)
+		reason = msg("%d followers, follows nobody — passive but not spammy", followerCount)
 	} else {
 		ratio := float64(followerCount) / float64(followCount)
 		raw = math.Round(ratio*100) / 100
 
 		if ratio >= 1.0 {
 			spamScore = 0
-			reason = fmt.Sprintf("Ratio %.2f (%d followers / %d following) — healthy", ratio, followerCount, followCount)
+			reason = msg("Ratio %.2f (%d followers / %d following) — healthy", ratio, followerCount, followCount)
 		} else if ratio >= 0.1 {
 			spamFactor := 1.0 - ratio
 			spamScore = math.Round(spamFactor*weight*1000) / 1000
-			reason = fmt.Sprintf("Ratio %.2f (%d followers / %d following) — slightly imbalanced", ratio, followerCount, followCount)
+			reason = msg("Ratio %.2f (%d followers / %d following) — slightly imbalanced", ratio, followerCount, followCount)
 		} else {
 			spamScore = weight
-			reason = fmt.Sprintf("Ratio %.2f (%d followers / %d following) — follows many, few follow back", ratio, followerCount, followCount)
+			reason = msg("Ratio %.2f (%d followers / %d following) — follows many, few follow back", ratio, followerCount, followCount)
 		}
 	}
 
@@ -249,19 +275,20 @@ func spamSignalFollowRatio(followerCount, followCount int) SpamSignal {
 		Value:  raw,
 		Weight: weight,
 		Score:  spamScore,
-		Reason: reason,
+		Reason: reason.In("en"),
+		reason: reason,
 	}
 }
 
 func spamSignalAge(firstCreated int64) SpamSignal {
 	weight := 0.15
 	var raw, spamScore float64
-	var reason string
+	var reason message
 
 	if firstCreated == 0 {
 		raw = 0
 		spamScore = weight * 0.5
-		reason = "No event history — account age unknown"
+		reason = msg("No event history — account age unknown")
 	} else {
 		age := time.Since(time.Unix(firstCreated, 0))
 		days := age.Hours() / 24
@@ -269,18 +296,18 @@ func spamSignalAge(firstCreated int64) SpamSignal {
 
 		if days >= 365 {
 			spamScore = 0
-			reason = fmt.Sprintf("Account %.0f days old — established", days)
+			reason = msg("Account %.0f days old — established", days)
 		} else if days >= 90 {
 			spamFactor := 1.0 - (days / 365.0)
 			spamScore = math.Round(spamFactor*weight*1000) / 1000
-			reason = fmt.Sprintf("Account %.0f days old — moderate age", days)
+			reason = msg("Account %.0f days old — moderate age", days)
 		} else if days >= 7 {
 			spamFactor := 0.7
 			spamScore = math.Round(spamFactor*weight*1000) / 1000
-			reason = fmt.Sprintf("Account %.0f days old — relatively new", days)
+			reason = msg("Account %.0f days old — relatively new", days)
 		} else {
 			spamScore = weight
-			reason = fmt.Sprintf("Account %.0f days old — very new", days)
+			reason = msg("Account %.0f days old — very new", days)
 		}
 	}
 
@@ -289,34 +316,35 @@ func spamSignalAge(firstCreated int64) SpamSignal {
 		Value:  raw,
 		Weight: weight,
 		Score:  spamScore,
-		Reason: reason,
+		Reason: reason.In("en"),
+		reason: reason,
 	}
 }
 
 func spamSignalEngagement(reactionsRecd, zapCntRecd, postCount int) SpamSignal {
-	weight := 0.15
+	weight := 0.10
 	var raw, spamScore float64
-	var reason string
+	var reason message
 
 	totalEngagement := reactionsRecd + zapCntRecd
 	raw = float64(totalEngagement)
 
 	if postCount == 0 && totalEngagement == 0 {
 		spamScore = weight * 0.3
-		reason = "No posts or engagement — lurker (not necessarily spam)"
+		reason = msg("No posts or engagement — lurker (not necessarily spam)")
 	} else if postCount > 0 && totalEngagement == 0 {
 		spamScore = weight * 0.8
-		reason = fmt.Sprintf("%d posts but 0 engagement received — one-way broadcasting", postCount)
+		reason = msg("%d posts but 0 engagement received — one-way broadcasting", postCount)
 	} else if totalEngagement > 0 {
 		// More engagement per post = more human
 		engagementRate := float64(totalEngagement) / math.Max(float64(postCount), 1)
 		if engagementRate >= 1.0 {
 			spamScore = 0
-			reason = fmt.Sprintf("%d reactions + %d zaps received — well-engaged", reactionsRecd, zapCntRecd)
+			reason = msg("%d reactions + %d zaps received — well-engaged", reactionsRecd, zapCntRecd)
 		} else {
 			spamFactor := 1.0 - engagementRate
 			spamScore = math.Round(spamFactor*weight*1000) / 1000
-			reason = fmt.Sprintf("%d reactions + %d zaps on %d posts — some engagement", reactionsRecd, zapCntRecd, postCount)
+			reason = msg("%d reactions + %d zaps on %d posts — some engagement", reactionsRecd, zapCntRecd, postCount)
 		}
 	}
 
@@ -325,25 +353,32 @@ func spamSignalEngagement(reactionsRecd, zapCntRecd, postCount int) SpamSignal {
 		Value:  raw,
 		Weight: weight,
 		Score:  spamScore,
-		Reason: reason,
+		Reason: reason.In("en"),
+		reason: reason,
 	}
 }
 
-func spamSignalReports(reportsRecd int) SpamSignal {
+// spamSignalReports scores reports by their trust-weighted total (see
+// ReportStore.Analyze), so a pile of reports from throwaway accounts counts for
+// little and two from well-trusted ones count fully.
+func spamSignalReports(reportsRecd int, weighted float64) SpamSignal {
 	weight := 0.15
 	var raw, spamScore float64
-	var reason string
+	var reason message
 
-	raw = float64(reportsRecd)
+	raw = weighted
 	if reportsRecd == 0 {
 		spamScore = 0
-		reason = "No reports received"
-	} else if reportsRecd <= 2 {
-		spamScore = weight * 0.5
-		reason = fmt.Sprintf("%d report(s) received — minor flag", reportsRecd)
-	} else {
+		reason = msg("No reports received")
+	} else if weighted >= reportSignificantWeight {
 		spamScore = weight
-		reason = fmt.Sprintf("%d reports received — significant spam signal", reportsRecd)
+		reason = msg("%d reports received, trust-weighted %.2f — significant spam signal", reportsRecd, weighted)
+	} else if weighted < 0.05 {
+		spamScore = 0
+		reason = msg("%d report(s) received, all from accounts with little trust — ignored", reportsRecd)
+	} else {
+		spamScore = math.Round(weighted/reportSignificantWeight*weight*1000) / 1000
+		reason = msg("%d report(s) received, trust-weighted %.2f — minor flag", reportsRecd, weighted)
 	}
 
 	return SpamSignal{
@@ -351,33 +386,34 @@ func spamSignalReports(reportsRecd int) SpamSignal {
 		Value:  raw,
 		Weight: weight,
 		Score:  spamScore,
-		Reason: reason,
+		Reason: reason.In("en"),
+		reason: reason,
 	}
 }
 
 func spamSignalActivity(postCount, replyCount, reactionsSent int) SpamSignal {
-	weight := 0.10
+	weight := 0.05
 	var raw, spamScore float64
-	var reason string
+	var reason message
 
 	totalActivity := postCount + replyCount + reactionsSent
 	raw = float64(totalActivity)
 
 	if totalActivity == 0 {
 		spamScore = weight * 0.3
-		reason = "No activity — inactive account"
+		reason = msg("No activity — inactive account")
 	} else if replyCount == 0 && reactionsSent == 0 && postCount > 5 {
 		spamScore = weight
-		reason = fmt.Sprintf("%d posts but no replies or reactions sent — broadcast-only pattern", postCount)
+		reason = msg("%d posts but no replies or reactions sent — broadcast-only pattern", postCount)
 	} else {
 		interactionRate := float64(replyCount+reactionsSent) / float64(totalActivity)
 		if interactionRate >= 0.3 {
 			spamScore = 0
-			reason = fmt.Sprintf("%.0f%% interaction rate (%d replies, %d reactions sent) — healthy mix", interactionRate*100, replyCount, reactionsSent)
+			reason = msg("%.0f%% interaction rate (%d replies, %d reactions sent) — healthy mix", interactionRate*100, replyCount, reactionsSent)
 		} else {
 			spamFactor := 1.0 - (interactionRate / 0.3)
 			spamScore = math.Round(spamFactor*weight*1000) / 1000
-			reason = fmt.Sprintf("%.0f%% interaction rate — mostly posting, limited engagement", interactionRate*100)
+			reason = msg("%.0f%% interaction rate — mostly posting, limited engagement", interactionRate*100)
 		}
 	}
 
@@ -386,7 +422,35 @@ func spamSignalActivity(postCount, replyCount, reactionsSent int) SpamSignal {
 		Value:  raw,
 		Weight: weight,
 		Score:  spamScore,
-		Reason: reason,
+		Reason: reason.In("en"),
+		reason: reason,
+	}
+}
+
+// spamSignalAutomation weighs how automated the posting cadence looks. Too
+// little posting to judge isn't penalized.
+func spamSignalAutomation(a AutomationAnalysis) SpamSignal {
+	weight := 0.10
+	var reason message
+
+	switch a.Classification {
+	case "unknown":
+		reason = msg("Too few sampled notes to judge posting cadence")
+	case "likely_automated":
+		reason = msg("Posting cadence looks automated (%.0f%% automation likelihood)", a.Likelihood*100)
+	case "possibly_automated":
+		reason = msg("Some automated posting patterns (%.0f%% automation likelihood)", a.Likelihood*100)
+	default:
+		reason = msg("Posting cadence looks human (%.0f%% automation likelihood)", a.Likelihood*100)
+	}
+
+	return SpamSignal{
+		Name:   "automation_likelihood",
+		Value:  a.Likelihood,
+		Weight: weight,
+		Score:  math.Round(a.Likelihood*weight*1000) / 1000,
+		Reason: reason.In("en"),
+		reason: reason,
 	}
 }
 
@@ -400,13 +464,13 @@ func classifySpam(prob float64) string {
 	return "likely_human"
 }
 
-func spamSummary(classification string, score, followers, reports int) string {
+func spamSummary(classification string, score, followers, reports int) message {
 	switch classification {
 	case "likely_spam":
-		return fmt.Sprintf("High spam probability. WoT score %d, %d followers, %d reports. This pubkey shows multiple spam indicators.", score, followers, reports)
+		return msg("High spam probability. WoT score %d, %d followers, %d reports. This pubkey shows multiple spam indicators.", score, followers, reports)
 	case "suspicious":
-		return fmt.Sprintf("Moderate spam risk. WoT score %d, %d followers. Some indicators suggest this may not be a genuine account.", score, followers)
+		return msg("Moderate spam risk. WoT score %d, %d followers. Some indicators suggest this may not be a genuine account.", score, followers)
 	default:
-		return fmt.Sprintf("Likely human. WoT score %d, %d followers. Trust signals are consistent with a real user.", score, followers)
+		return msg("Likely human. WoT score %d, %d followers. Trust signals are consistent with a real user.", score, followers)
 	}
 }
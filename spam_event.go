@@ -0,0 +1,321 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// spamEventWindow is how long content stays in the duplicate index.
+	spamEventWindow = 24 * time.Hour
+	// spamEventMaxContents caps the duplicate index; expired entries go first.
+	spamEventMaxContents = 200000
+	// spamEventMinContent is the shortest normalized content checked for
+	// duplicates, so "gm" and "+" don't flag anyone.
+	spamEventMinContent = 20
+	// spamEventMaxIDs caps the event IDs kept per content hash, which is enough
+	// to tell a resubmitted event from a new copy.
+	spamEventMaxIDs = 64
+)
+
+var (
+	spamURLPattern     = regexp.MustCompile(`(?i)\bhttps?://\S+`)
+	spamMentionPattern = regexp.MustCompile(`nostr:(npub1|nprofile1)[02-9ac-hj-np-z]+`)
+)
+
+// seenContent is one normalized content hash in the duplicate index.
+type seenContent struct {
+	ids      map[string]bool
+	authors  map[string]bool
+	lastSeen time.Time
+}
+
+// ContentSeenStore remembers hashes of recently checked event content, so copies
+// of the same text under new event IDs can be counted.
+type ContentSeenStore struct {
+	mu       sync.Mutex
+	contents map[uint64]*seenContent
+	window   time.Duration
+	max      int
+}
+
+func NewContentSeenStore(window time.Duration, max int) *ContentSeenStore {
+	return &ContentSeenStore{contents: make(map[uint64]*seenContent), window: window, max: max}
+}
+
+var contentSeen = NewContentSeenStore(spamEventWindow, spamEventMaxContents)
+
+// contentHash hashes content after lowercasing and collapsing whitespace, so
+// trivially reformatted copies match. ok is false for content too short to check.
+func contentHash(content string) (hash uint64, ok bool) {
+	normalized := strings.Join(strings.Fields(strings.ToLower(content)), " ")
+	if len([]rune(normalized)) < spamEventMinContent {
+		return 0, false
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	return binary.BigEndian.Uint64(sum[:8]), true
+}
+
+// Observe records that event id by author carried content hash and returns how
+// many other events, and how many other authors, carried it within the window.
+func (s *ContentSeenStore) Observe(hash uint64, id, author string, now time.Time) (copies, authors int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.contents[hash]
+	if ok && now.Sub(c.lastSeen) > s.window {
+		delete(s.contents, hash)
+		ok = false
+	}
+	if !ok {
+		if len(s.contents) >= s.max {
+			s.pruneLocked(now)
+		}
+		c = &seenContent{ids: make(map[string]bool), authors: make(map[string]bool)}
+		s.contents[hash] = c
+	}
+
+	copies = len(c.ids)
+	if c.ids[id] {
+		copies--
+	}
+	authors = len(c.authors)
+	if c.authors[author] {
+		authors--
+	}
+	if len(c.ids) < spamEventMaxIDs {
+		c.ids[id] = true
+	}
+	if len(c.authors) < spamEventMaxIDs {
+		c.authors[author] = true
+	}
+	c.lastSeen = now
+	return copies, authors
+}
+
+// pruneLocked drops expired content, then arbitrary entries if the index is
+// still full. Callers hold s.mu.
+func (s *ContentSeenStore) pruneLocked(now time.Time) {
+	for h, c := range s.contents {
+		if now.Sub(c.lastSeen) > s.window {
+			delete(s.contents, h)
+		}
+	}
+	for h := range s.contents {
+		if len(s.contents) < s.max {
+			break
+		}
+		delete(s.contents, h)
+	}
+}
+
+func (s *ContentSeenStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.contents)
+}
+
+// SpamEventResponse is the response for POST /spam/event.
+type SpamEventResponse struct {
+	EventID         string          `json:"event_id"`
+	Pubkey          string          `json:"pubkey"`
+	Kind            int             `json:"kind"`
+	SpamProbability float64         `json:"spam_probability"`
+	Classification  string          `json:"classification"`
+	Verdict         string          `json:"verdict"` // "accept", "flag" or "reject"
+	Signals         []SpamSignal    `json:"signals"`
+	Author          SpamBatchResult `json:"author"`
+	GraphSize       int             `json:"graph_size"`
+}
+
+// computeEventSpam scores ev by its author's spam probability and the content
+// itself, and records the content for later duplicate checks.
+func computeEventSpam(ev *nostr.Event, graphSize int, now time.Time) SpamEventResponse {
+	author := computeSpam(ev.PubKey, graphSize)
+
+	mentions := make(map[string]bool)
+	for _, tag := range ev.Tags {
+		if len(tag) >= 2 && tag[0] == "p" {
+			mentions[tag[1]] = true
+		}
+	}
+	inline := len(spamMentionPattern.FindAllString(ev.Content, -1))
+
+	var copies, authors int
+	if hash, ok := contentHash(ev.Content); ok {
+		copies, authors = contentSeen.Observe(hash, ev.ID, ev.PubKey, now)
+	}
+
+	signals := []SpamSignal{
+		spamSignalAuthor(author),
+		spamSignalLinks(len(spamURLPattern.FindAllString(ev.Content, -1)), len(strings.Fields(ev.Content))),
+		spamSignalMentions(max(len(mentions), inline)),
+		spamSignalDuplicate(copies, authors),
+	}
+	var prob float64
+	for _, s := range signals {
+		prob += s.Score
+	}
+	prob = math.Round(math.Min(prob, 1)*1000) / 1000
+	classification := classifySpam(prob)
+
+	return SpamEventResponse{
+		EventID:         ev.ID,
+		Pubkey:          ev.PubKey,
+		Kind:            ev.Kind,
+		SpamProbability: prob,
+		Classification:  classification,
+		Verdict:         spamVerdict(classification),
+		Signals:         signals,
+		Author: SpamBatchResult{
+			Pubkey:          author.Pubkey,
+			SpamProbability: author.SpamProbability,
+			Classification:  author.Classification,
+			Summary:         author.Summary,
+		},
+		GraphSize: graphSize,
+	}
+}
+
+// spamVerdict turns a classification into the action a relay filter takes.
+func spamVerdict(classification string) string {
+	switch classification {
+	case "likely_spam":
+		return "reject"
+	case "suspicious":
+		return "flag"
+	default:
+		return "accept"
+	}
+}
+
+// handleSpamEvent checks one signed event for spam: the author's /spam
+// probability plus link density, mention storms and copies of the same content
+// seen in the last 24 hours. POST /spam/event with the event JSON as the body.
+func handleSpamEvent(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 256<<10))
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "failed to read body"})
+		return
+	}
+	var ev nostr.Event
+	if err := json.Unmarshal(body, &ev); err != nil {
+		writeAPIError(w, invalidParam("event", "invalid event JSON"))
+		return
+	}
+	if !ev.CheckID() {
+		writeAPIError(w, invalidParam("event", "event id mismatch"))
+		return
+	}
+	if ok, err := ev.CheckSignature(); err != nil || !ok {
+		writeAPIError(w, invalidParam("event", "invalid event signature"))
+		return
+	}
+
+	resp := computeEventSpam(&ev, graph.Stats().Nodes, time.Now())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// spamSignalAuthor carries the author's own spam probability into the event score.
+func spamSignalAuthor(author SpamResponse) SpamSignal {
+	weight := 0.40
+	return SpamSignal{
+		Name:   "author",
+		Value:  author.SpamProbability,
+		Weight: weight,
+		Score:  math.Round(author.SpamProbability*weight*1000) / 1000,
+		Reason: fmt.Sprintf("Author classified %s (%.0f%% spam probability)", author.Classification, author.SpamProbability*100),
+	}
+}
+
+func spamSignalLinks(links, words int) SpamSignal {
+	weight := 0.20
+	var raw, spamScore float64
+	var reason string
+
+	if links == 0 {
+		reason = "No links"
+	} else {
+		density := float64(links) / math.Max(float64(words), 1)
+		raw = math.Round(density*100) / 100
+		if links >= 5 || density >= 0.5 {
+			spamScore = weight
+			reason = fmt.Sprintf("%d links in %d words — link-heavy", links, words)
+		} else {
+			spamScore = math.Round(density/0.5*weight*1000) / 1000
+			reason = fmt.Sprintf("%d link(s) in %d words", links, words)
+		}
+	}
+
+	return SpamSignal{
+		Name:   "link_density",
+		Value:  raw,
+		Weight: weight,
+		Score:  spamScore,
+		Reason: reason,
+	}
+}
+
+func spamSignalMentions(mentions int) SpamSignal {
+	weight := 0.20
+	var spamScore float64
+	var reason string
+
+	if mentions <= 3 {
+		reason = fmt.Sprintf("%d mention(s) — normal", mentions)
+	} else if mentions >= 20 {
+		spamScore = weight
+		reason = fmt.Sprintf("%d accounts mentioned — mention storm", mentions)
+	} else {
+		spamScore = math.Round(float64(mentions-3)/17*weight*1000) / 1000
+		reason = fmt.Sprintf("%d accounts mentioned — more than usual", mentions)
+	}
+
+	return SpamSignal{
+		Name:   "mentions",
+		Value:  float64(mentions),
+		Weight: weight,
+		Score:  spamScore,
+		Reason: reason,
+	}
+}
+
+// spamSignalDuplicate flags content already seen under other event IDs; copies
+// from three or more other authors look coordinated and count fully.
+func spamSignalDuplicate(copies, authors int) SpamSignal {
+	weight := 0.20
+	var spamScore float64
+	var reason string
+
+	if copies == 0 {
+		reason = "No copies seen in the last 24 hours"
+	} else if authors >= 3 || copies >= 5 {
+		spamScore = weight
+		reason = fmt.Sprintf("Same content seen in %d other events from %d other authors — repeated or coordinated posting", copies, authors)
+	} else {
+		spamScore = math.Round(float64(copies)/5*weight*1000) / 1000
+		reason = fmt.Sprintf("Same content seen in %d other event(s) from %d other author(s)", copies, authors)
+	}
+
+	return SpamSignal{
+		Name:   "duplicate_content",
+		Value:  float64(copies),
+		Weight: weight,
+		Score:  spamScore,
+		Reason: reason,
+	}
+}
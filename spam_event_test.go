@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func withSpamEventStores(t *testing.T) {
+	t.Helper()
+	oldGraph, oldSeen := graph, contentSeen
+	t.Cleanup(func() { graph, contentSeen = oldGraph, oldSeen })
+	graph, contentSeen = NewGraph(), NewContentSeenStore(spamEventWindow, spamEventMaxContents)
+}
+
+func signedNote(t *testing.T, sk, content string, tags nostr.Tags) *nostr.Event {
+	t.Helper()
+	ev := &nostr.Event{Kind: 1, CreatedAt: nostr.Now(), Tags: tags, Content: content}
+	if err := ev.Sign(sk); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return ev
+}
+
+func postSpamEvent(t *testing.T, ev *nostr.Event) SpamEventResponse {
+	t.Helper()
+	body, _ := json.Marshal(ev)
+	w := httptest.NewRecorder()
+	handleSpamEvent(w, httptest.NewRequest(http.MethodPost, "/spam/event", strings.NewReader(string(body))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp SpamEventResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	return resp
+}
+
+func eventSignal(resp SpamEventResponse, name string) SpamSignal {
+	for _, s := range resp.Signals {
+		if s.Name == name {
+			return s
+		}
+	}
+	return SpamSignal{}
+}
+
+func TestContentSeenStore(t *testing.T) {
+	s := NewContentSeenStore(time.Hour, 2)
+	now := time.Unix(1_700_000_000, 0)
+
+	if _, ok := contentHash("gm"); ok {
+		t.Error("expected short content to be skipped")
+	}
+	h1, _ := contentHash("Buy   cheap sats now at my shop")
+	h2, _ := contentHash("buy cheap sats now at my shop")
+	if h1 != h2 {
+		t.Error("expected case and whitespace to be ignored")
+	}
+
+	if copies, _ := s.Observe(h1, "e1", "a", now); copies != 0 {
+		t.Errorf("expected a first sighting, got %d copies", copies)
+	}
+	if copies, authors := s.Observe(h1, "e1", "a", now); copies != 0 || authors != 0 {
+		t.Errorf("expected a resubmitted event not to count, got %d/%d", copies, authors)
+	}
+	if copies, authors := s.Observe(h1, "e2", "b", now); copies != 1 || authors != 1 {
+		t.Errorf("expected one copy from one other author, got %d/%d", copies, authors)
+	}
+	if copies, _ := s.Observe(h1, "e3", "b", now.Add(2*time.Hour)); copies != 0 {
+		t.Errorf("expected content outside the window to be forgotten, got %d", copies)
+	}
+
+	s.Observe(h1+1, "e4", "c", now.Add(2*time.Hour))
+	s.Observe(h1+2, "e5", "c", now.Add(2*time.Hour))
+	if n := s.Len(); n != 2 {
+		t.Errorf("expected the index capped at 2, got %d", n)
+	}
+}
+
+func TestSpamEventCleanNote(t *testing.T) {
+	withSpamEventStores(t)
+	ev := signedNote(t, nostr.GeneratePrivateKey(), "Spent the morning reading about relay design, good stuff", nil)
+
+	resp := postSpamEvent(t, ev)
+	if resp.EventID != ev.ID || resp.Pubkey != ev.PubKey || resp.Kind != 1 {
+		t.Errorf("unexpected event fields %+v", resp)
+	}
+	for _, name := range []string{"link_density", "mentions", "duplicate_content"} {
+		if s := eventSignal(resp, name); s.Score != 0 {
+			t.Errorf("expected no %s signal, got %+v", name, s)
+		}
+	}
+	// an unknown author alone isn't enough to reject a plain note
+	if resp.Verdict == "reject" || resp.Author.Pubkey != ev.PubKey {
+		t.Errorf("expected a plain note not to be rejected, got %s", resp.Verdict)
+	}
+}
+
+func TestSpamEventContentSignals(t *testing.T) {
+	withSpamEventStores(t)
+	var tags nostr.Tags
+	for i := 0; i < 25; i++ {
+		tags = append(tags, nostr.Tag{"p", padHex(33000 + i)})
+	}
+	content := "free sats https://a.example https://b.example https://c.example https://d.example https://e.example"
+	resp := postSpamEvent(t, signedNote(t, nostr.GeneratePrivateKey(), content, tags))
+
+	if s := eventSignal(resp, "link_density"); s.Score != s.Weight {
+		t.Errorf("expected full link signal, got %+v", s)
+	}
+	if s := eventSignal(resp, "mentions"); s.Score != s.Weight || s.Value != 25 {
+		t.Errorf("expected a mention storm, got %+v", s)
+	}
+
+	// the same text from other accounts is counted as coordinated
+	var last SpamEventResponse
+	for i := 0; i < 3; i++ {
+		last = postSpamEvent(t, signedNote(t, nostr.GeneratePrivateKey(), strings.ToUpper(content), tags))
+	}
+	if s := eventSignal(last, "duplicate_content"); s.Score != s.Weight || s.Value != 3 {
+		t.Errorf("expected coordinated copies, got %+v", s)
+	}
+	if last.Verdict != "reject" || last.Classification != "likely_spam" {
+		t.Errorf("expected reject, got %s (%.3f)", last.Verdict, last.SpamProbability)
+	}
+}
+
+func TestSpamEventTrustedAuthor(t *testing.T) {
+	withSpamEventStores(t)
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	for i := 0; i < 30; i++ {
+		graph.AddFollow(fmt.Sprintf("%064x", 34000+i), pk)
+		graph.AddFollow(pk, fmt.Sprintf("%064x", 34000+i))
+	}
+	graph.ComputePageRank(20, 0.85)
+
+	resp := postSpamEvent(t, signedNote(t, sk, "New post up: https://blog.example/relays", nil))
+	author := eventSignal(resp, "author")
+	if author.Value != resp.Author.SpamProbability || author.Score >= author.Weight {
+		t.Errorf("expected the author's probability carried over, got %+v", author)
+	}
+	if resp.Verdict != "accept" {
+		t.Errorf("expected a trusted author's link post accepted, got %s", resp.Verdict)
+	}
+}
+
+func TestSpamEventErrors(t *testing.T) {
+	withSpamEventStores(t)
+	ev := signedNote(t, nostr.GeneratePrivateKey(), "hello world, this is a test note", nil)
+
+	tampered := *ev
+	tampered.Content = "something else entirely"
+	badSig := *ev
+	badSig.Sig = strings.Repeat("0", 128)
+
+	bodies := map[string]string{"invalid JSON": "{"}
+	for name, e := range map[string]*nostr.Event{"id mismatch": &tampered, "bad signature": &badSig} {
+		b, _ := json.Marshal(e)
+		bodies[name] = string(b)
+	}
+	for name, body := range bodies {
+		w := httptest.NewRecorder()
+		handleSpamEvent(w, httptest.NewRequest(http.MethodPost, "/spam/event", strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handleSpamEvent(w, httptest.NewRequest(http.MethodGet, "/spam/event", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET, got %d", w.Code)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// spamReportFloor is the lowest spam_report_min_confidence allowed: the
+	// probability at which /spam starts calling an account likely_spam.
+	spamReportFloor = 0.7
+	// spamReportMaxPerRebuild caps the reports published after one rebuild, so a
+	// detection regression can't flood relays.
+	spamReportMaxPerRebuild = 200
+	// spamReportInterval is how often one pubkey may be reported again.
+	spamReportInterval = 30 * 24 * time.Hour
+	// spamReportRecent is how many published reports /stats lists.
+	spamReportRecent = 20
+)
+
+// SpamReport is an account the service reported as spam, and why.
+type SpamReport struct {
+	Pubkey          string    `json:"pubkey"`
+	SpamProbability float64   `json:"spam_probability"`
+	SybilScore      int       `json:"sybil_score"`
+	SybilClass      string    `json:"sybil_classification"`
+	EventID         string    `json:"event_id,omitempty"`
+	ReportedAt      time.Time `json:"reported_at"`
+}
+
+// SpamReportRun summarizes one publishing pass.
+type SpamReportRun struct {
+	At              time.Time `json:"at"`
+	Scanned         int       `json:"scanned"`
+	Candidates      int       `json:"candidates"`       // at or above the confidence threshold and not rated genuine by /sybil
+	AlreadyReported int       `json:"already_reported"` // reported within the interval, or our report was crawled back
+	Published       int       `json:"published"`
+	Failed          int       `json:"failed"`
+}
+
+// SpamReporter publishes NIP-56 kind 1984 reports, type spam, from the service key
+// for accounts the spam and Sybil checks agree on, so other web-of-trust consumers
+// can use the detection. It remembers whom it reported and when.
+type SpamReporter struct {
+	mu       sync.Mutex
+	reported map[string]time.Time
+	recent   []SpamReport // newest first
+	last     *SpamReportRun
+	total    int
+}
+
+func NewSpamReporter() *SpamReporter {
+	return &SpamReporter{reported: make(map[string]time.Time)}
+}
+
+var spamReporter = NewSpamReporter()
+
+// spamReportCandidates returns the scored pubkeys whose spam probability is at
+// least minConfidence and that /sybil doesn't rate genuine or likely_genuine,
+// most confident first. Seeds are never reported. scanned counts the pubkeys
+// checked.
+func spamReportCandidates(g *Graph, minConfidence float64, seeds []string) (candidates []SpamReport, scanned int) {
+	skip := make(map[string]bool, len(seeds))
+	for _, s := range seeds {
+		skip[s] = true
+	}
+	scores := g.ScoresSnapshot()
+	nodes := len(scores)
+	for pk := range scores {
+		if skip[pk] {
+			continue
+		}
+		scanned++
+		spam := computeSpamWithPercentile(pk, nodes, 0)
+		if spam.SpamProbability < minConfidence {
+			continue
+		}
+		// the Sybil check is slower, so it only confirms what the spam check flags
+		sybil := computeSybil(pk)
+		if sybil.Classification == "genuine" || sybil.Classification == "likely_genuine" {
+			continue
+		}
+		candidates = append(candidates, SpamReport{
+			Pubkey:          pk,
+			SpamProbability: spam.SpamProbability,
+			SybilScore:      sybil.SybilScore,
+			SybilClass:      sybil.Classification,
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].SpamProbability != candidates[j].SpamProbability {
+			return candidates[i].SpamProbability > candidates[j].SpamProbability
+		}
+		return candidates[i].Pubkey < candidates[j].Pubkey
+	})
+	return candidates, scanned
+}
+
+// buildSpamReport builds the unsigned kind 1984 report of c, signed by pub.
+func buildSpamReport(pub string, c SpamReport) nostr.Event {
+	return nostr.Event{
+		PubKey:    pub,
+		CreatedAt: nostr.Now(),
+		Kind:      1984,
+		Tags:      nostr.Tags{{"p", c.Pubkey, "spam"}},
+		Content: fmt.Sprintf("Automated web-of-trust spam detection: spam probability %.2f, Sybil score %d/100 (%s).",
+			c.SpamProbability, c.SybilScore, c.SybilClass),
+	}
+}
+
+// Publish signs and queues a spam report for each candidate above cfg's threshold
+// that hasn't been reported in spamReportInterval, at most spamReportMaxPerRebuild
+// of them. A report by the service key found in the report store also counts, so
+// a restart doesn't report everyone again. It returns how many were queued.
+func (s *SpamReporter) Publish(ctx context.Context, signer EventSigner, cfg Config) (int, error) {
+	if !cfg.PublishSpamReports {
+		return 0, nil
+	}
+	now := time.Now()
+	run := &SpamReportRun{At: now}
+	candidates, scanned := spamReportCandidates(graph, cfg.SpamReportMinConfidence, cfg.Seeds)
+	run.Scanned, run.Candidates = scanned, len(candidates)
+
+	pub := signer.PublicKey()
+	var batch []nostr.Event
+	var published []SpamReport
+	for _, c := range candidates {
+		if ctx.Err() != nil || len(batch) == spamReportMaxPerRebuild {
+			break
+		}
+		if s.reportedSince(c.Pubkey, now.Add(-spamReportInterval)) || reportStore.ReportedBy(c.Pubkey)[pub] != "" {
+			run.AlreadyReported++
+			continue
+		}
+		ev := buildSpamReport(pub, c)
+		if err := signer.Sign(ctx, &ev); err != nil {
+			log.Printf("Failed to sign spam report for %s: %v", shortKey(c.Pubkey), err)
+			run.Failed++
+			continue
+		}
+		c.EventID, c.ReportedAt = ev.ID, now
+		batch = append(batch, ev)
+		published = append(published, c)
+	}
+	run.Published = publishQueue.Enqueue(batch, config.Relays())
+
+	s.mu.Lock()
+	for _, c := range published {
+		s.reported[c.Pubkey] = now
+		s.recent = append([]SpamReport{c}, s.recent...)
+	}
+	if len(s.recent) > spamReportRecent {
+		s.recent = s.recent[:spamReportRecent]
+	}
+	s.total += run.Published
+	s.last = run
+	s.mu.Unlock()
+
+	log.Printf("Queued %d NIP-56 spam reports (%d candidates, %d already reported, %d failed)",
+		run.Published, run.Candidates, run.AlreadyReported, run.Failed)
+	return run.Published, ctx.Err()
+}
+
+// reportedSince reports whether pubkey was reported after since.
+func (s *SpamReporter) reportedSince(pubkey string, since time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	at, ok := s.reported[pubkey]
+	return ok && at.After(since)
+}
+
+// Stats summarizes the settings and published reports for /stats.
+func (s *SpamReporter) Stats(cfg Config) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := map[string]interface{}{
+		"enabled":        cfg.PublishSpamReports,
+		"min_confidence": cfg.SpamReportMinConfidence,
+		"published":      s.total,
+		"recent":         append([]SpamReport{}, s.recent...),
+	}
+	if s.last != nil {
+		stats["last_run"] = *s.last
+	}
+	return stats
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// setupSpamReports installs a graph with two spam accounts, each following 100
+// pubkeys with no followers, posting without engagement and reported, next to an
+// ordinary community.
+func setupSpamReports(t *testing.T) (spammers []string) {
+	oldGraph, oldMeta, oldReports, oldQueue, oldReporter := graph, meta, reportStore, publishQueue, spamReporter
+	t.Cleanup(func() {
+		graph, meta, reportStore, publishQueue, spamReporter = oldGraph, oldMeta, oldReports, oldQueue, oldReporter
+	})
+	graph, meta, reportStore = NewGraph(), NewMetaStore(), NewReportStore()
+	publishQueue, spamReporter = NewPublishQueue(""), NewSpamReporter()
+
+	for i := 0; i < 50; i++ {
+		graph.AddFollow(padHex(49800+i), padHex(49800+(i+1)%50))
+		graph.AddFollow(padHex(49800+i), padHex(49800+(i+7)%50))
+	}
+	spammers = []string{padHex(49900), padHex(49901)}
+	for _, s := range spammers {
+		for i := 0; i < 100; i++ {
+			graph.AddFollow(s, padHex(49800+i%50))
+		}
+		m := meta.Get(s)
+		m.PostCount, m.ReportsRecd = 50, 5
+		m.FirstCreated = time.Now().Add(-24 * time.Hour).Unix()
+	}
+	graph.ComputePageRank(20, 0.85)
+	return spammers
+}
+
+func TestSpamReportCandidates(t *testing.T) {
+	spammers := setupSpamReports(t)
+
+	candidates, scanned := spamReportCandidates(graph, spamReportFloor, []string{spammers[1]})
+	if scanned != graph.Stats().Nodes-1 {
+		t.Errorf("expected every scored pubkey but the seed checked, got %d", scanned)
+	}
+	if len(candidates) != 1 || candidates[0].Pubkey != spammers[0] {
+		t.Fatalf("expected only the unseeded spammer, got %+v", candidates)
+	}
+	c := candidates[0]
+	if c.SpamProbability < spamReportFloor || c.SybilClass == "genuine" || c.SybilClass == "likely_genuine" {
+		t.Errorf("unexpected candidate %+v", c)
+	}
+
+	if candidates, _ := spamReportCandidates(graph, 1, nil); len(candidates) != 0 {
+		t.Errorf("expected nothing at full confidence, got %+v", candidates)
+	}
+}
+
+func TestSpamReporterPublish(t *testing.T) {
+	spammers := setupSpamReports(t)
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	signer := keySigner{sk: sk, pub: pub}
+	cfg := defaultConfig
+	cfg.SpamReportMinConfidence = spamReportFloor
+
+	if n, err := spamReporter.Publish(context.Background(), signer, cfg); n != 0 || err != nil {
+		t.Errorf("expected nothing published while disabled, got %d %v", n, err)
+	}
+
+	cfg.PublishSpamReports = true
+	n, err := spamReporter.Publish(context.Background(), signer, cfg)
+	if err != nil || n != 2 {
+		t.Fatalf("expected both spammers reported, got %d %v", n, err)
+	}
+	if depth := publishQueue.Status().QueueDepth; depth != 2 {
+		t.Errorf("expected two reports queued separately, got depth %d", depth)
+	}
+	var ev nostr.Event
+	for _, item := range publishQueue.items {
+		ev = item.Event
+	}
+	if ok, err := ev.CheckSignature(); !ok || err != nil || ev.Kind != 1984 || ev.PubKey != pub {
+		t.Errorf("expected a kind 1984 report signed by the service key, got %+v", ev)
+	}
+	if p := ev.Tags.GetFirst([]string{"p"}); p == nil || len(*p) != 3 || (*p)[2] != "spam" || ((*p)[1] != spammers[0] && (*p)[1] != spammers[1]) {
+		t.Errorf("expected a NIP-56 spam p tag, got %v", ev.Tags)
+	}
+
+	// the next rebuild doesn't report them again, nor does a restart once our
+	// report has been crawled back
+	if n, _ := spamReporter.Publish(context.Background(), signer, cfg); n != 0 {
+		t.Errorf("expected no repeat reports, got %d", n)
+	}
+	spamReporter = NewSpamReporter()
+	reportStore.Record(&ev)
+	n, _ = spamReporter.Publish(context.Background(), signer, cfg)
+	stats := spamReporter.Stats(cfg)
+	last := stats["last_run"].(SpamReportRun)
+	if n != 1 || last.AlreadyReported != 1 || last.Candidates != 2 {
+		t.Errorf("expected only the spammer without a crawled report reported, got %d %+v", n, last)
+	}
+	if recent := stats["recent"].([]SpamReport); len(recent) != 1 || recent[0].EventID == "" {
+		t.Errorf("expected the report listed, got %+v", recent)
+	}
+}
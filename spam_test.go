@@ -51,8 +51,8 @@ func TestSpamUnknownPubkey(t *testing.T) {
 	if resp.SpamProbability < 0.4 {
 		t.Fatalf("expected spam_probability >= 0.4 for unknown pubkey, got %f", resp.SpamProbability)
 	}
-	if len(resp.Signals) != 6 {
-		t.Fatalf("expected 6 signals, got %d", len(resp.Signals))
+	if len(resp.Signals) != 7 {
+		t.Fatalf("expected 7 signals, got %d", len(resp.Signals))
 	}
 }
 
@@ -231,20 +231,20 @@ func TestSpamSignalAgeOld(t *testing.T) {
 }
 
 func TestSpamSignalReportsNone(t *testing.T) {
-	signal := spamSignalReports(0)
+	signal := spamSignalReports(0, 0)
 	if signal.Score != 0 {
 		t.Fatalf("expected 0 spam score for no reports, got %f", signal.Score)
 	}
 }
 
 func TestSpamSignalReportsMany(t *testing.T) {
-	signal := spamSignalReports(10)
+	signal := spamSignalReports(10, 4.2)
 	if signal.Score != 0.15 {
 		t.Fatalf("expected 0.15 spam score for many reports, got %f", signal.Score)
 	}
 }
 
-func TestSpamResponseHas6Signals(t *testing.T) {
+func TestSpamResponseHas7Signals(t *testing.T) {
 	oldGraph := graph
 	graph = NewGraph()
 	defer func() { graph = oldGraph }()
@@ -257,8 +257,8 @@ func TestSpamResponseHas6Signals(t *testing.T) {
 	var resp SpamResponse
 	json.NewDecoder(w.Body).Decode(&resp)
 
-	if len(resp.Signals) != 6 {
-		t.Fatalf("expected 6 signals, got %d", len(resp.Signals))
+	if len(resp.Signals) != 7 {
+		t.Fatalf("expected 7 signals, got %d", len(resp.Signals))
 	}
 
 	// Verify all signal names are present
@@ -266,7 +266,7 @@ func TestSpamResponseHas6Signals(t *testing.T) {
 	for _, s := range resp.Signals {
 		names[s.Name] = true
 	}
-	expected := []string{"wot_score", "follow_ratio", "account_age_days", "engagement_received", "reports_received", "activity_pattern"}
+	expected := []string{"wot_score", "follow_ratio", "account_age_days", "engagement_received", "reports_received", "activity_pattern", "automation_likelihood"}
 	for _, name := range expected {
 		if !names[name] {
 			t.Fatalf("missing signal: %s", name)
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	strfryDefaultSource   = "https://wot.klabo.world"
+	strfryDefaultMinScore = 1
+	strfryDefaultMaxSpam  = 0.7
+	strfryDefaultCacheTTL = 10 * time.Minute
+	// strfryLookupTimeout bounds one lookup; strfry waits on every decision.
+	strfryLookupTimeout = 5 * time.Second
+	// strfryMaxCache clears the decision cache once it holds this many authors.
+	strfryMaxCache = 100000
+)
+
+// StrfryPolicy is the write policy the strfry plugin enforces. Rules apply in
+// order: denied pubkeys are rejected, allowed pubkeys, exempt kinds and sources
+// not in CheckSources are accepted, then the author must reach MinScore and stay
+// at or under MaxSpam.
+type StrfryPolicy struct {
+	MinScore     int
+	MaxSpam      float64 // 1 skips the spam lookup
+	ExemptKinds  map[int]bool
+	Allow        map[string]bool
+	Deny         map[string]bool
+	CheckSources map[string]bool // strfry sourceType values to check: IP4, IP6, Import, Stream, Sync
+	RejectAction string          // "reject" or "shadowReject"
+	OnError      string          // action when the lookup fails: "accept" or "reject"
+}
+
+// StrfryTrust is what the plugin knows about an event's author.
+type StrfryTrust struct {
+	Score           int
+	Found           bool
+	SpamProbability float64
+}
+
+type strfryCached struct {
+	trust   StrfryTrust
+	fetched time.Time
+}
+
+// strfryInput is one line strfry writes to a plugin's stdin.
+type strfryInput struct {
+	Type       string      `json:"type"`
+	Event      nostr.Event `json:"event"`
+	ReceivedAt int64       `json:"receivedAt"`
+	SourceType string      `json:"sourceType"`
+	SourceInfo string      `json:"sourceInfo"`
+}
+
+// strfryOutput is one decision line read back by strfry.
+type strfryOutput struct {
+	ID     string `json:"id"`
+	Action string `json:"action"` // "accept", "reject" or "shadowReject"
+	Msg    string `json:"msg,omitempty"`
+}
+
+// StrfryPlugin answers strfry's writePolicy plugin protocol: one JSON event per
+// line on stdin, one accept/reject decision per line on stdout. Author trust is
+// looked up on a scoring instance and cached, so a busy author costs one lookup
+// per cache TTL.
+type StrfryPlugin struct {
+	policy StrfryPolicy
+	lookup func(ctx context.Context, pubkey string, withSpam bool) (StrfryTrust, error)
+	ttl    time.Duration
+	cache  map[string]strfryCached
+	now    func() time.Time
+}
+
+func NewStrfryPlugin(policy StrfryPolicy, ttl time.Duration, lookup func(context.Context, string, bool) (StrfryTrust, error)) *StrfryPlugin {
+	return &StrfryPlugin{
+		policy: policy,
+		lookup: lookup,
+		ttl:    ttl,
+		cache:  make(map[string]strfryCached),
+		now:    time.Now,
+	}
+}
+
+// trust returns the author's cached trust, looking it up when missing or stale.
+func (p *StrfryPlugin) trust(ctx context.Context, pubkey string) (StrfryTrust, error) {
+	now := p.now()
+	if c, ok := p.cache[pubkey]; ok && now.Sub(c.fetched) < p.ttl {
+		return c.trust, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, strfryLookupTimeout)
+	defer cancel()
+	t, err := p.lookup(ctx, pubkey, p.policy.MaxSpam < 1)
+	if err != nil {
+		return StrfryTrust{}, err
+	}
+	if len(p.cache) >= strfryMaxCache {
+		p.cache = make(map[string]strfryCached)
+	}
+	p.cache[pubkey] = strfryCached{trust: t, fetched: now}
+	return t, nil
+}
+
+// Decide applies the policy to one input line.
+func (p *StrfryPlugin) Decide(ctx context.Context, in strfryInput) strfryOutput {
+	ev := in.Event
+	accept := strfryOutput{ID: ev.ID, Action: "accept"}
+	reject := func(msg string) strfryOutput {
+		return strfryOutput{ID: ev.ID, Action: p.policy.RejectAction, Msg: "blocked: " + msg}
+	}
+
+	switch {
+	case p.policy.Deny[ev.PubKey]:
+		return reject("pubkey is denied on this relay")
+	case p.policy.Allow[ev.PubKey], p.policy.ExemptKinds[ev.Kind], !p.policy.CheckSources[in.SourceType]:
+		return accept
+	}
+
+	t, err := p.trust(ctx, ev.PubKey)
+	if err != nil {
+		log.Printf("strfry: trust lookup for %s failed: %v", ev.PubKey, err)
+		if p.policy.OnError == "reject" {
+			return strfryOutput{ID: ev.ID, Action: "reject", Msg: "error: trust lookup unavailable, try again later"}
+		}
+		return accept
+	}
+	if t.Score < p.policy.MinScore {
+		if !t.Found {
+			return reject("author is not in the web of trust")
+		}
+		return reject(fmt.Sprintf("WoT score %d is below %d", t.Score, p.policy.MinScore))
+	}
+	if t.SpamProbability > p.policy.MaxSpam {
+		return reject(fmt.Sprintf("spam probability %.2f is above %.2f", t.SpamProbability, p.policy.MaxSpam))
+	}
+	return accept
+}
+
+// Run reads input lines from r until EOF and writes a decision for each to w.
+// Lines that aren't new events are logged and skipped, since there is no event ID
+// to answer with.
+func (p *StrfryPlugin) Run(ctx context.Context, r io.Reader, w io.Writer) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	enc := json.NewEncoder(w)
+	for sc.Scan() {
+		var in strfryInput
+		if err := json.Unmarshal(sc.Bytes(), &in); err != nil {
+			log.Printf("strfry: skipping invalid input: %v", err)
+			continue
+		}
+		if in.Type != "new" {
+			log.Printf("strfry: skipping input of type %q", in.Type)
+			continue
+		}
+		if err := enc.Encode(p.Decide(ctx, in)); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// remoteStrfryLookup looks authors up on a scoring instance with POST /batch and,
+// when withSpam is set, POST /spam/batch.
+func remoteStrfryLookup(source string, client *http.Client) func(context.Context, string, bool) (StrfryTrust, error) {
+	source = strings.TrimRight(source, "/")
+	return func(ctx context.Context, pubkey string, withSpam bool) (StrfryTrust, error) {
+		var scores struct {
+			Results []struct {
+				Score int    `json:"score"`
+				Found bool   `json:"found"`
+				Error string `json:"error"`
+			} `json:"results"`
+		}
+		if err := postPubkeys(ctx, client, source, "/batch", []string{pubkey}, &scores); err != nil {
+			return StrfryTrust{}, err
+		}
+		if len(scores.Results) != 1 || scores.Results[0].Error != "" {
+			return StrfryTrust{}, fmt.Errorf("/batch: no result for %s", pubkey)
+		}
+		t := StrfryTrust{Score: scores.Results[0].Score, Found: scores.Results[0].Found}
+		if !withSpam {
+			return t, nil
+		}
+		var spam struct {
+			Results []struct {
+				SpamProbability float64 `json:"spam_probability"`
+				Classification  string  `json:"classification"`
+			} `json:"results"`
+		}
+		if err := postPubkeys(ctx, client, source, "/spam/batch", []string{pubkey}, &spam); err != nil {
+			return StrfryTrust{}, err
+		}
+		if len(spam.Results) != 1 || spam.Results[0].Classification == "" {
+			return StrfryTrust{}, fmt.Errorf("/spam/batch: no result for %s", pubkey)
+		}
+		t.SpamProbability = spam.Results[0].SpamProbability
+		return t, nil
+	}
+}
+
+// readStrfryPolicy builds the policy from STRFRY_* environment variables.
+func readStrfryPolicy() (StrfryPolicy, error) {
+	p := StrfryPolicy{
+		MinScore:     strfryDefaultMinScore,
+		MaxSpam:      strfryDefaultMaxSpam,
+		ExemptKinds:  make(map[int]bool),
+		Allow:        make(map[string]bool),
+		Deny:         make(map[string]bool),
+		CheckSources: map[string]bool{"IP4": true, "IP6": true},
+		RejectAction: "reject",
+		OnError:      "accept",
+	}
+	if v := os.Getenv("STRFRY_MIN_SCORE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 100 {
+			return p, fmt.Errorf("STRFRY_MIN_SCORE must be 0-100, got %q", v)
+		}
+		p.MinScore = n
+	}
+	if v := os.Getenv("STRFRY_MAX_SPAM"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			return p, fmt.Errorf("STRFRY_MAX_SPAM must be 0-1, got %q", v)
+		}
+		p.MaxSpam = f
+	}
+	for _, v := range splitCommaList(os.Getenv("STRFRY_EXEMPT_KINDS")) {
+		k, err := strconv.Atoi(v)
+		if err != nil || k < 0 {
+			return p, fmt.Errorf("STRFRY_EXEMPT_KINDS: invalid kind %q", v)
+		}
+		p.ExemptKinds[k] = true
+	}
+	for env, set := range map[string]map[string]bool{"STRFRY_ALLOW_PUBKEYS": p.Allow, "STRFRY_DENY_PUBKEYS": p.Deny} {
+		for _, v := range splitCommaList(os.Getenv(env)) {
+			pk, err := resolvePubkey(v)
+			if err != nil || !hex64Pattern.MatchString(pk) {
+				return p, fmt.Errorf("%s: invalid pubkey %q", env, v)
+			}
+			set[pk] = true
+		}
+	}
+	if v := os.Getenv("STRFRY_CHECK_SOURCES"); v != "" {
+		p.CheckSources = make(map[string]bool)
+		for _, s := range splitCommaList(v) {
+			switch s {
+			case "IP4", "IP6", "Import", "Stream", "Sync":
+				p.CheckSources[s] = true
+			default:
+				return p, fmt.Errorf("STRFRY_CHECK_SOURCES: unknown source type %q", s)
+			}
+		}
+	}
+	if v := os.Getenv("STRFRY_REJECT_ACTION"); v != "" {
+		if v != "reject" && v != "shadowReject" {
+			return p, fmt.Errorf("STRFRY_REJECT_ACTION must be reject or shadowReject, got %q", v)
+		}
+		p.RejectAction = v
+	}
+	if v := os.Getenv("STRFRY_ON_ERROR"); v != "" {
+		if v != "accept" && v != "reject" {
+			return p, fmt.Errorf("STRFRY_ON_ERROR must be accept or reject, got %q", v)
+		}
+		p.OnError = v
+	}
+	return p, nil
+}
+
+// runStrfry is the "strfry" mode entry point: a writePolicy plugin that checks
+// event authors against STRFRY_SOURCE. stdout carries only decisions; logs go to
+// stderr, which strfry passes through to its own log.
+func runStrfry() {
+	policy, err := readStrfryPolicy()
+	if err != nil {
+		log.Fatalf("strfry: %v", err)
+	}
+	source := os.Getenv("STRFRY_SOURCE")
+	if source == "" {
+		source = strfryDefaultSource
+	}
+	ttl := strfryDefaultCacheTTL
+	if v := os.Getenv("STRFRY_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			log.Fatalf("strfry: invalid STRFRY_CACHE_TTL %q", v)
+		}
+		ttl = d
+	}
+
+	plugin := NewStrfryPlugin(policy, ttl, remoteStrfryLookup(source, &http.Client{Timeout: strfryLookupTimeout}))
+	log.Printf("strfry: checking authors on %s (min score %d, max spam %.2f)", source, policy.MinScore, policy.MaxSpam)
+	if err := plugin.Run(context.Background(), os.Stdin, os.Stdout); err != nil {
+		log.Fatalf("strfry: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testStrfryPolicy() StrfryPolicy {
+	return StrfryPolicy{
+		MinScore:     10,
+		MaxSpam:      0.5,
+		ExemptKinds:  map[int]bool{0: true},
+		Allow:        map[string]bool{padHex(35001): true},
+		Deny:         map[string]bool{padHex(35002): true},
+		CheckSources: map[string]bool{"IP4": true, "IP6": true},
+		RejectAction: "reject",
+		OnError:      "accept",
+	}
+}
+
+func strfryLine(id, pubkey string, kind int, source string) string {
+	return fmt.Sprintf(`{"type":"new","event":{"id":%q,"pubkey":%q,"kind":%d,"created_at":1770710400,"tags":[],"content":"hi","sig":""},"receivedAt":1770710401,"sourceType":%q,"sourceInfo":"1.2.3.4"}`,
+		id, pubkey, kind, source)
+}
+
+func TestStrfryPluginDecisions(t *testing.T) {
+	trusted, low, spammy, unknown := padHex(35003), padHex(35004), padHex(35005), padHex(35006)
+	lookups := 0
+	lookup := func(_ context.Context, pk string, withSpam bool) (StrfryTrust, error) {
+		lookups++
+		if !withSpam {
+			t.Error("expected the spam lookup with MaxSpam below 1")
+		}
+		switch pk {
+		case trusted:
+			return StrfryTrust{Score: 40, Found: true, SpamProbability: 0.1}, nil
+		case low:
+			return StrfryTrust{Score: 3, Found: true}, nil
+		case spammy:
+			return StrfryTrust{Score: 20, Found: true, SpamProbability: 0.8}, nil
+		}
+		return StrfryTrust{}, nil
+	}
+	p := NewStrfryPlugin(testStrfryPolicy(), time.Minute, lookup)
+
+	input := strings.Join([]string{
+		strfryLine("e1", trusted, 1, "IP4"),
+		strfryLine("e2", low, 1, "IP4"),
+		strfryLine("e3", spammy, 1, "IP6"),
+		strfryLine("e4", unknown, 1, "IP4"),
+		strfryLine("e5", padHex(35002), 1, "IP4"),
+		strfryLine("e6", padHex(35001), 1, "IP4"),
+		strfryLine("e7", unknown, 0, "IP4"),
+		strfryLine("e8", unknown, 1, "Sync"),
+		`not json`,
+		`{"type":"lookback","event":{"id":"e9"}}`,
+		strfryLine("e10", trusted, 1, "IP4"),
+	}, "\n")
+	var out strings.Builder
+	if err := p.Run(context.Background(), strings.NewReader(input), &out); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	want := []struct{ id, action, msg string }{
+		{"e1", "accept", ""},
+		{"e2", "reject", "blocked: WoT score 3 is below 10"},
+		{"e3", "reject", "blocked: spam probability 0.80 is above 0.50"},
+		{"e4", "reject", "blocked: author is not in the web of trust"},
+		{"e5", "reject", "blocked: pubkey is denied on this relay"},
+		{"e6", "accept", ""},
+		{"e7", "accept", ""},
+		{"e8", "accept", ""},
+		{"e10", "accept", ""},
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d decisions, got %d:\n%s", len(want), len(lines), out.String())
+	}
+	for i, w := range want {
+		var got strfryOutput
+		if err := json.Unmarshal([]byte(lines[i]), &got); err != nil {
+			t.Fatalf("invalid output line %q: %v", lines[i], err)
+		}
+		if got.ID != w.id || got.Action != w.action || got.Msg != w.msg {
+			t.Errorf("decision %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+	// e10 reuses e1's lookup
+	if lookups != 4 {
+		t.Errorf("expected 4 lookups with caching, got %d", lookups)
+	}
+}
+
+func TestStrfryPluginCacheAndErrors(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	fail := false
+	lookups := 0
+	lookup := func(context.Context, string, bool) (StrfryTrust, error) {
+		lookups++
+		if fail {
+			return StrfryTrust{}, errors.New("connection refused")
+		}
+		return StrfryTrust{Score: 50, Found: true}, nil
+	}
+	policy := testStrfryPolicy()
+	policy.MaxSpam = 1
+	policy.RejectAction = "shadowReject"
+	p := NewStrfryPlugin(policy, time.Minute, lookup)
+	p.now = func() time.Time { return now }
+
+	in := strfryInput{Type: "new", SourceType: "IP4"}
+	in.Event.ID, in.Event.PubKey, in.Event.Kind = "e1", padHex(35010), 1
+	p.Decide(context.Background(), in)
+	now = now.Add(2 * time.Minute)
+	if p.Decide(context.Background(), in).Action != "accept" || lookups != 2 {
+		t.Errorf("expected a stale entry to be looked up again, got %d lookups", lookups)
+	}
+
+	in.Event.PubKey = padHex(35011)
+	fail = true
+	if got := p.Decide(context.Background(), in); got.Action != "accept" {
+		t.Errorf("expected fail-open by default, got %+v", got)
+	}
+	p.policy.OnError = "reject"
+	if got := p.Decide(context.Background(), in); got.Action != "reject" || !strings.HasPrefix(got.Msg, "error:") {
+		t.Errorf("expected a plain reject on lookup failure, got %+v", got)
+	}
+
+	in.Event.PubKey = padHex(35002)
+	if got := p.Decide(context.Background(), in); got.Action != "shadowReject" {
+		t.Errorf("expected shadowReject for policy rejections, got %+v", got)
+	}
+}
+
+func TestRemoteStrfryLookup(t *testing.T) {
+	srv := exporterSource(t)
+	lookup := remoteStrfryLookup(srv.URL+"/", &http.Client{Timeout: time.Second})
+
+	star, err := lookup(context.Background(), padHex(31001), true)
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if !star.Found || star.Score == 0 || star.SpamProbability >= 0.7 {
+		t.Errorf("expected a trusted, found author, got %+v", star)
+	}
+	stranger, err := lookup(context.Background(), padHex(35020), false)
+	if err != nil || stranger.Found || stranger.Score != 0 || stranger.SpamProbability != 0 {
+		t.Errorf("expected an unknown author without a spam lookup, got %+v %v", stranger, err)
+	}
+}
+
+func TestReadStrfryPolicy(t *testing.T) {
+	p, err := readStrfryPolicy()
+	if err != nil || p.MinScore != strfryDefaultMinScore || p.MaxSpam != strfryDefaultMaxSpam || !p.CheckSources["IP6"] || p.CheckSources["Sync"] {
+		t.Fatalf("unexpected defaults %+v %v", p, err)
+	}
+
+	t.Setenv("STRFRY_MIN_SCORE", "25")
+	t.Setenv("STRFRY_MAX_SPAM", "0.4")
+	t.Setenv("STRFRY_EXEMPT_KINDS", "0, 3,10002")
+	t.Setenv("STRFRY_ALLOW_PUBKEYS", padHex(35030))
+	t.Setenv("STRFRY_CHECK_SOURCES", "IP4,Stream")
+	t.Setenv("STRFRY_REJECT_ACTION", "shadowReject")
+	t.Setenv("STRFRY_ON_ERROR", "reject")
+	p, err = readStrfryPolicy()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if p.MinScore != 25 || p.MaxSpam != 0.4 || !p.ExemptKinds[10002] || !p.Allow[padHex(35030)] ||
+		!p.CheckSources["Stream"] || p.CheckSources["IP6"] || p.RejectAction != "shadowReject" || p.OnError != "reject" {
+		t.Errorf("unexpected policy %+v", p)
+	}
+
+	for env, v := range map[string]string{
+		"STRFRY_MIN_SCORE":     "101",
+		"STRFRY_MAX_SPAM":      "high",
+		"STRFRY_EXEMPT_KINDS":  "one",
+		"STRFRY_DENY_PUBKEYS":  "npub1bad",
+		"STRFRY_CHECK_SOURCES": "Carrier",
+		"STRFRY_REJECT_ACTION": "drop",
+		"STRFRY_ON_ERROR":      "retry",
+	} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, v)
+			if _, err := readStrfryPolicy(); err == nil {
+				t.Errorf("expected %s=%s to be rejected", env, v)
+			}
+		})
+	}
+}
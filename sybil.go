@@ -36,18 +36,18 @@ type SybilResponse struct {
 // handleSybil computes a Sybil resistance score for a pubkey.
 // GET /sybil?pubkey=<hex|npub>
 func handleSybil(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
 		return
 	}
 
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(computeSybil(pubkey))
+}
 
+// computeSybil scores a pubkey's Sybil resistance from five graph signals.
+func computeSybil(pubkey string) SybilResponse {
 	stats := graph.Stats()
 	rawScore, found := graph.GetScore(pubkey)
 	score := normalizeScore(rawScore, stats.Nodes)
@@ -199,7 +199,7 @@ func handleSybil(w http.ResponseWriter, r *http.Request) {
 	// Confidence: higher when we have more data
 	confidence := computeConfidence(len(followers), len(follows), found, scoredFollowers)
 
-	resp := SybilResponse{
+	return SybilResponse{
 		Pubkey:           pubkey,
 		SybilScore:       sybilScore,
 		Classification:   classification,
@@ -213,9 +213,6 @@ func handleSybil(w http.ResponseWriter, r *http.Request) {
 		HighValueMutuals: highValueMutuals,
 		GraphSize:        stats.Nodes,
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
 }
 
 // computeFollowerDiversity measures how spread out followers are across the graph.
@@ -327,24 +324,24 @@ func round3(f float64) float64 {
 // handleSybilBatch scores multiple pubkeys for Sybil resistance.
 // POST /sybil/batch with JSON body {"pubkeys": ["hex1", "hex2", ...]}
 func handleSybilBatch(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, `{"error":"POST required"}`, http.StatusMethodNotAllowed)
+	g := graph.Snapshot()
+	if !requireMethod(w, r, http.MethodPost) {
 		return
 	}
 
 	var req struct {
 		Pubkeys []string `json:"pubkeys"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, `{"error":"invalid JSON body"}`, http.StatusBadRequest)
+	if apiErr := bindJSON(w, r, &req, 1<<20); apiErr != nil {
+		writeAPIError(w, apiErr)
 		return
 	}
 	if len(req.Pubkeys) == 0 {
-		http.Error(w, `{"error":"pubkeys array required"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: "pubkeys", Message: "pubkeys array required"})
 		return
 	}
 	if len(req.Pubkeys) > 50 {
-		http.Error(w, `{"error":"maximum 50 pubkeys per batch"}`, http.StatusBadRequest)
+		writeAPIError(w, invalidParam("pubkeys", "maximum 50 pubkeys per batch"))
 		return
 	}
 
@@ -357,7 +354,7 @@ func handleSybilBatch(w http.ResponseWriter, r *http.Request) {
 		Error          string `json:"error,omitempty"`
 	}
 
-	stats := graph.Stats()
+	stats := g.Stats()
 	results := make([]batchEntry, 0, len(req.Pubkeys))
 
 	for _, raw := range req.Pubkeys {
@@ -367,10 +364,10 @@ func handleSybilBatch(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		rawScore, found := graph.GetScore(pubkey)
+		rawScore, found := g.GetScore(pubkey)
 		score := normalizeScore(rawScore, stats.Nodes)
-		followers := graph.GetFollowers(pubkey)
-		follows := graph.GetFollows(pubkey)
+		followers := g.GetFollowers(pubkey)
+		follows := g.GetFollows(pubkey)
 
 		followSet := make(map[string]bool, len(follows))
 		for _, f := range follows {
@@ -383,7 +380,7 @@ func handleSybilBatch(w http.ResponseWriter, r *http.Request) {
 		mutualCount := 0
 		highValueMutuals := 0
 		for _, f := range followers {
-			fRaw, ok := graph.GetScore(f)
+			fRaw, ok := g.GetScore(f)
 			if ok {
 				ns := normalizeScore(fRaw, stats.Nodes)
 				batchFollowerSum += ns
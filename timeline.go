@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"math"
 	"net/http"
 	"sort"
@@ -34,37 +33,32 @@ type TimelineResponse struct {
 // handleTimeline returns a time-series of follower growth and estimated trust
 // for a given pubkey, reconstructed from follow timestamps.
 func handleTimeline(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
-		return
-	}
-
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
 		return
 	}
 
 	if len(pubkey) != 64 {
-		http.Error(w, `{"error":"pubkey must be 64 hex characters"}`, http.StatusBadRequest)
+		writeAPIError(w, invalidParam("pubkey", "pubkey must be 64 hex characters"))
 		return
 	}
 	for _, c := range pubkey {
 		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
-			http.Error(w, `{"error":"pubkey must be lowercase hex"}`, http.StatusBadRequest)
+			writeAPIError(w, invalidParam("pubkey", "pubkey must be lowercase hex"))
 			return
 		}
 	}
 
-	followers := graph.GetFollowers(pubkey)
+	followers := g.GetFollowers(pubkey)
 	if len(followers) == 0 {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(TimelineResponse{
 			Pubkey:           pubkey,
 			Points:           []TimelinePoint{},
 			CurrentFollowers: 0,
-			GraphSize:        graph.Stats().Nodes,
+			GraphSize:        g.Stats().Nodes,
 		})
 		return
 	}
@@ -76,7 +70,7 @@ func handleTimeline(w http.ResponseWriter, r *http.Request) {
 	}
 	var dated []followEvent
 	for _, f := range followers {
-		t := graph.GetFollowTime(f, pubkey)
+		t := g.GetFollowTime(f, pubkey)
 		if !t.IsZero() {
 			dated = append(dated, followEvent{from: f, at: t})
 		}
@@ -87,12 +81,12 @@ func handleTimeline(w http.ResponseWriter, r *http.Request) {
 		return dated[i].at.Before(dated[j].at)
 	})
 
-	stats := graph.Stats()
+	stats := g.Stats()
 	withoutDates := len(followers) - len(dated)
 
 	if len(dated) == 0 {
 		// No timestamp data — return current state only
-		rawScore, _ := graph.GetScore(pubkey)
+		rawScore, _ := g.GetScore(pubkey)
 		score := normalizeScore(rawScore, stats.Nodes)
 
 		w.Header().Set("Content-Type", "application/json")
@@ -151,7 +145,7 @@ func handleTimeline(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	rawScore, _ := graph.GetScore(pubkey)
+	rawScore, _ := g.GetScore(pubkey)
 	currentScore := normalizeScore(rawScore, stats.Nodes)
 
 	resp := TimelineResponse{
@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"math"
 	"net/http"
 	"sort"
@@ -41,24 +40,19 @@ type CircleMetrics struct {
 }
 
 func handleTrustCircle(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
 		return
 	}
 
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid pubkey: %s"}`, err.Error()), http.StatusBadRequest)
-		return
-	}
-
-	stats := graph.Stats()
-	rawScore, _ := graph.GetScore(pubkey)
+	stats := g.Stats()
+	rawScore, _ := g.GetScore(pubkey)
 	selfScore := normalizeScore(rawScore, stats.Nodes)
 
-	followers := graph.GetFollowers(pubkey)
-	follows := graph.GetFollows(pubkey)
+	followers := g.GetFollowers(pubkey)
+	follows := g.GetFollows(pubkey)
 
 	// Build follows set for mutual detection
 	followSet := make(map[string]bool, len(follows))
@@ -77,12 +71,12 @@ func handleTrustCircle(w http.ResponseWriter, r *http.Request) {
 	// Build circle members with scoring
 	members := make([]CircleMember, 0, len(mutuals))
 	for _, m := range mutuals {
-		mRaw, _ := graph.GetScore(m)
+		mRaw, _ := g.GetScore(m)
 		mScore := normalizeScore(mRaw, stats.Nodes)
-		mPercentile := graph.Percentile(m)
-		mRank := graph.Rank(m)
-		mFollowers := graph.GetFollowers(m)
-		mFollows := graph.GetFollows(m)
+		mPercentile := g.Percentile(m)
+		mRank := g.Rank(m)
+		mFollowers := g.GetFollowers(m)
+		mFollows := g.GetFollows(m)
 
 		// Shared follows: how many pubkeys do both follow?
 		mFollowSet := make(map[string]bool, len(mFollows))
@@ -146,7 +140,7 @@ func handleTrustCircle(w http.ResponseWriter, r *http.Request) {
 	copy(innerCircle, members[:innerSize])
 
 	// Compute circle metrics
-	metrics := computeCircleMetrics(members, graph, stats.Nodes)
+	metrics := computeCircleMetrics(members, g, stats.Nodes)
 
 	resp := TrustCircleResponse{
 		Pubkey:      pubkey,
@@ -49,38 +49,39 @@ type CircleUniqueMember struct {
 }
 
 func handleTrustCircleCompare(w http.ResponseWriter, r *http.Request) {
+	g := graph.Snapshot()
 	raw1 := r.URL.Query().Get("pubkey1")
 	raw2 := r.URL.Query().Get("pubkey2")
 	if raw1 == "" || raw2 == "" {
-		http.Error(w, `{"error":"pubkey1 and pubkey2 parameters required"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "pubkey1 and pubkey2 parameters required"})
 		return
 	}
 
 	pubkey1, err := resolvePubkey(raw1)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid pubkey1: %s"}`, err.Error()), http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid pubkey1: %s", err.Error())})
 		return
 	}
 	pubkey2, err := resolvePubkey(raw2)
 	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid pubkey2: %s"}`, err.Error()), http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid pubkey2: %s", err.Error())})
 		return
 	}
 
 	if pubkey1 == pubkey2 {
-		http.Error(w, `{"error":"pubkey1 and pubkey2 must be different"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "pubkey1 and pubkey2 must be different"})
 		return
 	}
 
-	stats := graph.Stats()
+	stats := g.Stats()
 
 	// Get trust circles for both pubkeys
 	circle1 := getMutualSet(pubkey1)
 	circle2 := getMutualSet(pubkey2)
 
 	// Scores
-	raw1Score, _ := graph.GetScore(pubkey1)
-	raw2Score, _ := graph.GetScore(pubkey2)
+	raw1Score, _ := g.GetScore(pubkey1)
+	raw2Score, _ := g.GetScore(pubkey2)
 	score1 := normalizeScore(raw1Score, stats.Nodes)
 	score2 := normalizeScore(raw2Score, stats.Nodes)
 
@@ -95,7 +96,7 @@ func handleTrustCircleCompare(w http.ResponseWriter, r *http.Request) {
 		if pk == pubkey1 || pk == pubkey2 {
 			continue
 		}
-		rawS, _ := graph.GetScore(pk)
+		rawS, _ := g.GetScore(pk)
 		wotScore := normalizeScore(rawS, stats.Nodes)
 		if circle2[pk] {
 			s1 := mutualStrength(score1, wotScore, countSharedFollows(pubkey1, pk))
@@ -115,7 +116,7 @@ func handleTrustCircleCompare(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		if !circle1[pk] {
-			rawS, _ := graph.GetScore(pk)
+			rawS, _ := g.GetScore(pk)
 			wotScore := normalizeScore(rawS, stats.Nodes)
 			unique2 = append(unique2, CircleUniqueMember{Pubkey: pk, TrustScore: wotScore})
 		}
@@ -6,6 +6,7 @@ import (
 	"math"
 	"net/http"
 	"sort"
+	"strings"
 )
 
 // TrustPathHop is a single node along a trust path.
@@ -21,6 +22,7 @@ type TrustPath struct {
 	Length     int            `json:"length"`      // number of edges
 	TrustScore float64       `json:"trust_score"` // 0.0-1.0 product of hop trust
 	WeakestHop int           `json:"weakest_hop"` // index of lowest-scored node in path
+	Cost       float64       `json:"cost,omitempty"` // trust-weighted edge cost, with weighted=true
 }
 
 // TrustPathResponse is the response for the /trust-path endpoint.
@@ -31,48 +33,56 @@ type TrustPathResponse struct {
 	Paths          []TrustPath `json:"paths"`
 	BestTrust      float64     `json:"best_trust"`      // highest trust_score across paths
 	PathDiversity  int         `json:"path_diversity"`   // number of distinct paths found
+	NodeDiversity  float64     `json:"node_diversity"`   // distinct share of intermediate accounts, 1 when disjoint
+	Weighted       bool        `json:"weighted"`
+	Disjoint       bool        `json:"disjoint"`
 	OverallTrust   float64     `json:"overall_trust"`    // combined trust from all paths
 	Classification string     `json:"classification"`   // "strong", "moderate", "weak", "none"
 	GraphSize      int         `json:"graph_size"`
 }
 
 // handleTrustPath finds and scores trust paths between two pubkeys.
-// GET /trust-path?from=<hex|npub>&to=<hex|npub>&max_paths=5
+// GET /trust-path?from=<hex|npub>&to=<hex|npub>&max_paths=5&weighted=true&disjoint=false
+// By default the paths are the shortest ones that share no intermediate account;
+// weighted=true ranks them by trust-weighted cost instead, and disjoint=false (with
+// weighted) lets them overlap, giving Yen's k cheapest paths.
 func handleTrustPath(w http.ResponseWriter, r *http.Request) {
-	fromRaw := r.URL.Query().Get("from")
-	toRaw := r.URL.Query().Get("to")
-	if fromRaw == "" || toRaw == "" {
-		http.Error(w, `{"error":"from and to parameters required"}`, http.StatusBadRequest)
+	q := bindQuery(r)
+	fromHex := q.Pubkey("from", true)
+	toHex := q.Pubkey("to", true)
+	maxPaths := q.Int("max_paths", 3, 1, 5)
+	weighted := q.Bool("weighted", false)
+	disjoint := q.Bool("disjoint", true)
+	if q.Failed(w) {
 		return
 	}
-
-	fromHex, err := resolvePubkey(fromRaw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid from: %s"}`, err.Error()), http.StatusBadRequest)
-		return
-	}
-	toHex, err := resolvePubkey(toRaw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"invalid to: %s"}`, err.Error()), http.StatusBadRequest)
+	if !disjoint && !weighted {
+		writeAPIError(w, invalidParam("disjoint", "disjoint=false requires weighted=true"))
 		return
 	}
 
-	maxPathsStr := r.URL.Query().Get("max_paths")
-	maxPaths := 3
-	if maxPathsStr != "" {
-		if n, err := fmt.Sscanf(maxPathsStr, "%d", &maxPaths); n != 1 || err != nil || maxPaths < 1 {
-			maxPaths = 3
+	stats := graph.Stats()
+
+	// Find multiple paths using iterative BFS with node exclusion, or by
+	// trust-weighted cost
+	var paths [][]string
+	costs := make(map[string]float64)
+	if weighted {
+		s := newTrustPathSearch(graph, true, 6, nil)
+		var found []WeightedPath
+		if disjoint {
+			found = s.disjoint(fromHex, toHex, maxPaths)
+		} else {
+			found = s.kShortest(fromHex, toHex, maxPaths)
 		}
-		if maxPaths > 5 {
-			maxPaths = 5
+		for _, p := range found {
+			paths = append(paths, p.Nodes)
+			costs[strings.Join(p.Nodes, ",")] = round3(p.Cost)
 		}
+	} else {
+		paths = findMultiplePaths(fromHex, toHex, maxPaths, 6)
 	}
 
-	stats := graph.Stats()
-
-	// Find multiple paths using iterative BFS with node exclusion
-	paths := findMultiplePaths(fromHex, toHex, maxPaths, 6)
-
 	if len(paths) == 0 {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(TrustPathResponse{
@@ -84,6 +94,8 @@ func handleTrustPath(w http.ResponseWriter, r *http.Request) {
 			PathDiversity:  0,
 			OverallTrust:   0,
 			Classification: "none",
+			Weighted:       weighted,
+			Disjoint:       disjoint,
 			GraphSize:      stats.Nodes,
 		})
 		return
@@ -93,6 +105,7 @@ func handleTrustPath(w http.ResponseWriter, r *http.Request) {
 	scoredPaths := make([]TrustPath, 0, len(paths))
 	for _, rawPath := range paths {
 		tp := scoreTrustPath(rawPath, stats.Nodes)
+		tp.Cost = costs[strings.Join(rawPath, ",")]
 		scoredPaths = append(scoredPaths, tp)
 	}
 
@@ -117,8 +130,11 @@ func handleTrustPath(w http.ResponseWriter, r *http.Request) {
 		Paths:          scoredPaths,
 		BestTrust:      round3(bestTrust),
 		PathDiversity:  len(scoredPaths),
+		NodeDiversity:  pathDiversity(paths),
 		OverallTrust:   round3(overallTrust),
 		Classification: classification,
+		Weighted:       weighted,
+		Disjoint:       disjoint,
 		GraphSize:      stats.Nodes,
 	})
 }
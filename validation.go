@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxErrorBody caps how much of an error response the envelope middleware buffers.
+const maxErrorBody = 64 << 10
+
+// APIError is the error body every endpoint returns: a message, a stable
+// machine-readable code, and the parameter at fault when there is one.
+type APIError struct {
+	Status  int    `json:"-"`
+	Message string `json:"error"`
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+}
+
+func (e *APIError) Error() string { return e.Message }
+
+// errorCode is the default code for an HTTP error status.
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request"
+	case http.StatusUnauthorized:
+		return "unauthorized"
+	case http.StatusPaymentRequired:
+		return "payment_required"
+	case http.StatusForbidden:
+		return "forbidden"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusMethodNotAllowed:
+		return "method_not_allowed"
+	case http.StatusConflict:
+		return "conflict"
+	case http.StatusRequestEntityTooLarge:
+		return "payload_too_large"
+	case http.StatusUnsupportedMediaType:
+		return "unsupported_media_type"
+	case http.StatusTooManyRequests:
+		return "rate_limited"
+	case http.StatusInternalServerError:
+		return "internal_error"
+	case http.StatusBadGateway:
+		return "upstream_error"
+	case http.StatusServiceUnavailable:
+		return "unavailable"
+	case http.StatusGatewayTimeout:
+		return "upstream_timeout"
+	}
+	if text := http.StatusText(status); text != "" {
+		return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+	}
+	return "error"
+}
+
+// missingParam is the error for an absent required parameter.
+func missingParam(field string) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: "missing_parameter", Field: field,
+		Message: field + " parameter required"}
+}
+
+// invalidParam is the error for a parameter that failed validation.
+func invalidParam(field, format string, args ...interface{}) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: "invalid_parameter", Field: field,
+		Message: fmt.Sprintf(format, args...)}
+}
+
+// writeAPIError writes e as JSON with its status.
+func writeAPIError(w http.ResponseWriter, e *APIError) {
+	if e.Code == "" {
+		e.Code = errorCode(e.Status)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Del("Content-Length")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(e)
+}
+
+// requireMethod answers 405 unless the request uses one of methods.
+func requireMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeAPIError(w, &APIError{Status: http.StatusMethodNotAllowed, Message: strings.Join(methods, " or ") + " required"})
+	return false
+}
+
+// QueryBinder reads typed query parameters, keeping the first error so a
+// handler can bind everything and check once:
+//
+//	q := bindQuery(r)
+//	limit := q.Int("limit", 50, 1, 200)
+//	pubkey := q.Pubkey("pubkey", true)
+//	if q.Failed(w) {
+//		return
+//	}
+type QueryBinder struct {
+	r   *http.Request
+	err *APIError
+}
+
+func bindQuery(r *http.Request) *QueryBinder {
+	return &QueryBinder{r: r}
+}
+
+func (q *QueryBinder) fail(e *APIError) {
+	if q.err == nil {
+		q.err = e
+	}
+}
+
+// Err returns the first binding error, or nil.
+func (q *QueryBinder) Err() *APIError { return q.err }
+
+// Failed writes the first binding error, if any, and reports whether there was one.
+func (q *QueryBinder) Failed(w http.ResponseWriter) bool {
+	if q.err == nil {
+		return false
+	}
+	writeAPIError(w, q.err)
+	return true
+}
+
+// String returns a parameter, trimmed; required parameters must be non-empty.
+func (q *QueryBinder) String(name string, required bool) string {
+	v := strings.TrimSpace(q.r.URL.Query().Get(name))
+	if v == "" && required {
+		q.fail(missingParam(name))
+	}
+	return v
+}
+
+// Int returns an integer parameter, or def when absent. Values that aren't
+// integers or are below min are rejected; values above max are capped at max.
+func (q *QueryBinder) Int(name string, def, min, max int) int {
+	v := q.r.URL.Query().Get(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		q.fail(invalidParam(name, "%s must be an integer", name))
+		return def
+	}
+	if n < min {
+		q.fail(invalidParam(name, "%s must be at least %d", name, min))
+		return def
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+// IntBetween is Int for parameters that can't be capped without changing what
+// the caller asked for, such as a score threshold in a signed statement: values
+// above max are rejected too.
+func (q *QueryBinder) IntBetween(name string, def, min, max int) int {
+	n := q.Int(name, def, min, math.MaxInt)
+	if n > max {
+		q.fail(invalidParam(name, "%s must be %d-%d", name, min, max))
+		return def
+	}
+	return n
+}
+
+// Float returns a numeric parameter, or def when absent, with the same rules as Int.
+func (q *QueryBinder) Float(name string, def, min, max float64) float64 {
+	v := q.r.URL.Query().Get(name)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || math.IsNaN(f) {
+		q.fail(invalidParam(name, "%s must be a number", name))
+		return def
+	}
+	if f < min {
+		q.fail(invalidParam(name, "%s must be at least %g", name, min))
+		return def
+	}
+	if f > max {
+		return max
+	}
+	return f
+}
+
+// Bool returns a boolean parameter (true/false/1/0), or def when absent.
+func (q *QueryBinder) Bool(name string, def bool) bool {
+	v := q.r.URL.Query().Get(name)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		q.fail(invalidParam(name, "%s must be true or false", name))
+		return def
+	}
+	return b
+}
+
+// OneOf returns a parameter that must be empty or one of allowed.
+func (q *QueryBinder) OneOf(name string, allowed ...string) string {
+	v := q.String(name, false)
+	if v == "" {
+		return ""
+	}
+	for _, a := range allowed {
+		if v == a {
+			return v
+		}
+	}
+	q.fail(invalidParam(name, "%s must be one of %s", name, strings.Join(allowed, ", ")))
+	return ""
+}
+
+// Pubkey returns a hex pubkey from a hex or npub parameter.
+func (q *QueryBinder) Pubkey(name string, required bool) string {
+	v := q.String(name, required)
+	if v == "" {
+		return ""
+	}
+	pk, err := resolvePubkey(v)
+	if err != nil {
+		q.fail(invalidParam(name, "invalid %s: %s", name, err.Error()))
+		return ""
+	}
+	return pk
+}
+
+// HexPubkey is Pubkey for endpoints that need the key itself, rejecting
+// anything that doesn't resolve to 64 hex characters.
+func (q *QueryBinder) HexPubkey(name string, required bool) string {
+	pk := q.Pubkey(name, required)
+	if pk != "" && !hex64Pattern.MatchString(pk) {
+		q.fail(invalidParam(name, "%s must be 64 hex characters or an npub", name))
+		return ""
+	}
+	return pk
+}
+
+// bindJSON decodes a JSON request body of at most maxBytes into v.
+func bindJSON(w http.ResponseWriter, r *http.Request, v interface{}, maxBytes int64) *APIError {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			return &APIError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("body exceeds %d bytes", maxBytes)}
+		case errors.Is(err, io.EOF):
+			return &APIError{Status: http.StatusBadRequest, Code: "missing_body", Message: "request body required"}
+		}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return invalidParam(typeErr.Field, "%s must be %s", typeErr.Field, typeErr.Type.String())
+		}
+		return &APIError{Status: http.StatusBadRequest, Code: "invalid_json", Message: "invalid JSON: " + err.Error()}
+	}
+	return nil
+}
+
+// errorEnvelopeWriter holds back error responses so ErrorEnvelopeMiddleware can
+// rewrite them; everything else is passed straight through.
+type errorEnvelopeWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (ew *errorEnvelopeWriter) WriteHeader(code int) {
+	if code < 200 {
+		ew.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if ew.status != 0 {
+		return
+	}
+	ew.status = code
+	if code < 400 {
+		ew.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (ew *errorEnvelopeWriter) Write(p []byte) (int, error) {
+	if ew.status == 0 {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if ew.status < 400 {
+		return ew.ResponseWriter.Write(p)
+	}
+	if room := maxErrorBody - ew.body.Len(); room > 0 {
+		ew.body.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func (ew *errorEnvelopeWriter) Flush() {
+	if ew.status < 400 {
+		http.NewResponseController(ew.ResponseWriter).Flush()
+	}
+}
+
+func (ew *errorEnvelopeWriter) Unwrap() http.ResponseWriter {
+	return ew.ResponseWriter
+}
+
+// envelope rewrites a held-back error body as an APIError. JSON bodies keep
+// their extra fields and gain a code; plain-text bodies, including
+// hand-written {"error":"..."} strings that aren't valid JSON, become the message.
+func (ew *errorEnvelopeWriter) envelope() {
+	raw := bytes.TrimSpace(ew.body.Bytes())
+	ct := ew.Header().Get("Content-Type")
+	if ct != "" && !strings.HasPrefix(ct, "text/plain") && !strings.HasPrefix(ct, "application/json") {
+		ew.ResponseWriter.WriteHeader(ew.status)
+		ew.ResponseWriter.Write(ew.body.Bytes())
+		return
+	}
+
+	var obj map[string]interface{}
+	if json.Unmarshal(raw, &obj) != nil || obj == nil {
+		msg := string(raw)
+		if strings.HasPrefix(msg, `{"error":"`) && strings.HasSuffix(msg, `"}`) {
+			msg = strings.TrimSuffix(strings.TrimPrefix(msg, `{"error":"`), `"}`)
+		}
+		if msg == "" {
+			msg = http.StatusText(ew.status)
+		}
+		obj = map[string]interface{}{"error": msg}
+	}
+	if _, ok := obj["error"]; !ok {
+		obj["error"] = http.StatusText(ew.status)
+	}
+	if _, ok := obj["code"]; !ok {
+		obj["code"] = errorCode(ew.status)
+	}
+	ew.Header().Set("Content-Type", "application/json")
+	ew.Header().Del("Content-Length")
+	ew.Header().Del("X-Content-Type-Options")
+	ew.ResponseWriter.WriteHeader(ew.status)
+	json.NewEncoder(ew.ResponseWriter).Encode(obj)
+}
+
+// ErrorEnvelopeMiddleware gives every 4xx/5xx response the APIError shape
+// ({error, code, field}) with a JSON content type, whichever handler or
+// middleware produced it. WebSocket upgrades pass through untouched.
+func ErrorEnvelopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ew := &errorEnvelopeWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r)
+		if ew.status >= 400 {
+			ew.envelope()
+		}
+	})
+}
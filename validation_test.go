@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func decodeAPIError(t *testing.T, rr *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got %q", ct)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected a JSON error body, got %q", rr.Body.String())
+	}
+	return body
+}
+
+func TestQueryBinder(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/x?limit=500&ratio=0.5&flag=true&mode=b&pubkey="+padHex(46001), nil)
+	q := bindQuery(r)
+	if got := q.Int("limit", 20, 1, 200); got != 200 {
+		t.Errorf("expected limit capped at 200, got %d", got)
+	}
+	if got := q.Int("missing", 20, 1, 200); got != 20 {
+		t.Errorf("expected the default, got %d", got)
+	}
+	if got := q.Float("ratio", 1, 0, 1); got != 0.5 {
+		t.Errorf("expected 0.5, got %v", got)
+	}
+	if !q.Bool("flag", false) || q.OneOf("mode", "a", "b") != "b" || q.Pubkey("pubkey", true) != padHex(46001) {
+		t.Errorf("unexpected bound values")
+	}
+	if q.Err() != nil {
+		t.Fatalf("unexpected error %+v", q.Err())
+	}
+
+	for query, field := range map[string]string{
+		"limit=abc":        "limit",
+		"limit=0":          "limit",
+		"ratio=NaN":        "ratio",
+		"flag=maybe":       "flag",
+		"mode=c":           "mode",
+		"pubkey=npub1bad":  "pubkey",
+		"limit=x&flag=bad": "limit", // first error wins
+		"min_score=101":    "min_score",
+		"hex=ABCD":         "hex",
+	} {
+		q := bindQuery(httptest.NewRequest(http.MethodGet, "/x?"+query, nil))
+		q.Int("limit", 20, 1, 200)
+		q.Float("ratio", 1, 0, 1)
+		q.Bool("flag", false)
+		q.OneOf("mode", "a", "b")
+		q.Pubkey("pubkey", false)
+		q.IntBetween("min_score", -1, 0, 100)
+		q.HexPubkey("hex", false)
+		if e := q.Err(); e == nil || e.Field != field || e.Code != "invalid_parameter" || e.Status != http.StatusBadRequest {
+			t.Errorf("%s: expected invalid %s, got %+v", query, field, e)
+		}
+	}
+
+	q = bindQuery(httptest.NewRequest(http.MethodGet, "/x", nil))
+	q.Pubkey("pubkey", true)
+	rr := httptest.NewRecorder()
+	if !q.Failed(rr) || rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected a written 400, got %d", rr.Code)
+	}
+	body := decodeAPIError(t, rr)
+	if body["code"] != "missing_parameter" || body["field"] != "pubkey" || body["error"] != "pubkey parameter required" {
+		t.Errorf("unexpected error body %v", body)
+	}
+}
+
+func TestBindJSON(t *testing.T) {
+	var v struct {
+		Limit int `json:"limit"`
+	}
+	for body, want := range map[string]*APIError{
+		`{"limit":5}`:      nil,
+		``:                 {Status: http.StatusBadRequest, Code: "missing_body"},
+		`{"limit":`:        {Status: http.StatusBadRequest, Code: "invalid_json"},
+		`{"limit":"five"}`: {Status: http.StatusBadRequest, Code: "invalid_parameter", Field: "limit"},
+		`{"limit":` + strings.Repeat("1", 100) + `}`: {Status: http.StatusRequestEntityTooLarge},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/x", strings.NewReader(body))
+		got := bindJSON(httptest.NewRecorder(), r, &v, 64)
+		if want == nil {
+			if got != nil || v.Limit != 5 {
+				t.Errorf("%q: expected limit 5, got %+v %d", body, got, v.Limit)
+			}
+			continue
+		}
+		if got == nil || got.Status != want.Status || got.Code != want.Code || got.Field != want.Field {
+			t.Errorf("%q: expected %+v, got %+v", body, want, got)
+		}
+	}
+}
+
+func TestRequireMethod(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if requireMethod(rr, httptest.NewRequest(http.MethodGet, "/x", nil), http.MethodPost) {
+		t.Fatal("expected GET rejected")
+	}
+	body := decodeAPIError(t, rr)
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != "POST" || body["code"] != "method_not_allowed" {
+		t.Errorf("unexpected response %d %v", rr.Code, body)
+	}
+}
+
+func TestErrorEnvelopeMiddleware(t *testing.T) {
+	handlers := map[string]http.HandlerFunc{
+		"/plain": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "something broke", http.StatusInternalServerError)
+		},
+		"/manual": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error":"bad "quoted" value"}`, http.StatusBadRequest)
+		},
+		"/json": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusPaymentRequired)
+			w.Write([]byte(`{"error":"pay up","invoice":"lnbc1"}`))
+		},
+		"/typed": func(w http.ResponseWriter, r *http.Request) {
+			writeAPIError(w, invalidParam("limit", "limit must be an integer"))
+		},
+		"/ok": func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"ok":true}`))
+		},
+	}
+	mux := http.NewServeMux()
+	for path, h := range handlers {
+		mux.Handle(path, h)
+	}
+	h := ErrorEnvelopeMiddleware(mux)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	for path, want := range map[string]map[string]interface{}{
+		"/plain":   {"error": "something broke", "code": "internal_error"},
+		"/manual":  {"error": `bad "quoted" value`, "code": "invalid_request"},
+		"/json":    {"error": "pay up", "code": "payment_required", "invoice": "lnbc1"},
+		"/typed":   {"error": "limit must be an integer", "code": "invalid_parameter", "field": "limit"},
+		"/missing": {"error": "404 page not found", "code": "not_found"},
+	} {
+		rr := serve(path)
+		body := decodeAPIError(t, rr)
+		for k, v := range want {
+			if body[k] != v {
+				t.Errorf("%s: expected %s=%v, got %v", path, k, v, body)
+			}
+		}
+	}
+
+	if rr := serve("/ok"); rr.Code != http.StatusOK || rr.Body.String() != `{"ok":true}` {
+		t.Errorf("expected successful responses untouched, got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestHandlersUseTypedErrors(t *testing.T) {
+	for _, tc := range []struct {
+		path    string
+		handler http.HandlerFunc
+		field   string
+	}{
+		{"/trust-path?from=" + padHex(1) + "&to=" + padHex(2) + "&max_paths=x", handleTrustPath, "max_paths"},
+		{"/trust-path?to=" + padHex(2), handleTrustPath, "from"},
+		{"/trust-path?from=" + padHex(1) + "&to=" + padHex(2) + "&disjoint=false", handleTrustPath, "disjoint"},
+		{"/decay/top?limit=0", handleDecayTop, "limit"},
+		{"/similar?pubkey=" + padHex(1) + "&limit=many", handleSimilar, "limit"},
+		{"/recommend?pubkey=" + padHex(1) + "&diversity=lots", handleRecommend, "diversity"},
+		{"/recommend?pubkey=" + padHex(1) + "&limit=x", handleRecommend, "limit"},
+		{"/relay/top?limit=x", handleRelayTop, "limit"},
+		{"/web-of-trust?pubkey=" + padHex(1) + "&limit=0", handleWebOfTrust, "limit"},
+		{"/score?pubkey=" + padHex(1) + "&algorithm=salsa", handleScore, "algorithm"},
+		{"/personalized?viewer=" + padHex(1), handlePersonalized, "target"},
+		{"/attestation?pubkey=" + padHex(1) + "&min_score=101", handleAttestation, "min_score"},
+		{"/nip05/reverse?pubkey=xyz", handleNIP05Reverse, "pubkey"},
+	} {
+		rr := httptest.NewRecorder()
+		tc.handler(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		body := decodeAPIError(t, rr)
+		if rr.Code != http.StatusBadRequest || body["field"] != tc.field {
+			t.Errorf("GET %s: expected 400 naming %s, got %d %v", tc.path, tc.field, rr.Code, body)
+		}
+	}
+}
@@ -38,19 +38,18 @@ type VerifyResponse struct {
 //
 // POST /verify with JSON body containing a Nostr event.
 func handleVerify(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, `{"error":"POST required"}`, http.StatusMethodNotAllowed)
+	if !requireMethod(w, r, http.MethodPost) {
 		return
 	}
 
 	var ev nostr.Event
 	if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
-		http.Error(w, `{"error":"invalid JSON: `+err.Error()+`"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "invalid_json", Message: "invalid JSON: " + err.Error()})
 		return
 	}
 
 	if ev.Kind != 30382 {
-		http.Error(w, `{"error":"only kind 30382 (NIP-85 user assertions) supported"}`, http.StatusBadRequest)
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "only kind 30382 (NIP-85 user assertions) supported"})
 		return
 	}
 
@@ -128,23 +127,7 @@ func handleVerify(w http.ResponseWriter, r *http.Request) {
 
 	resp.SubjectPubkey = subjectPubkey
 
-	// Get our own data for the subject
-	followers := graph.GetFollowers(subjectPubkey)
-	ourFollowerCount := len(followers)
-	rawScore, _ := graph.GetScore(subjectPubkey)
-	ourScore := normalizeScore(rawScore, stats.Nodes)
-
-	// Check rank
-	if hasRank {
-		check := verifyNumericField("rank", claimedRank, ourScore, 15)
-		resp.Checks = append(resp.Checks, check)
-	}
-
-	// Check followers
-	if hasFollowers {
-		check := verifyNumericField("followers", claimedFollowers, ourFollowerCount, 20)
-		resp.Checks = append(resp.Checks, check)
-	}
+	resp.Checks = append(resp.Checks, verifyClaims(graph, stats.Nodes, subjectPubkey, claimedRank, hasRank, claimedFollowers, hasFollowers)...)
 
 	if !hasRank && !hasFollowers {
 		resp.Verdict = "unverifiable"
@@ -181,6 +164,20 @@ func handleVerify(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// verifyClaims checks a subject's claimed rank and follower count against our
+// graph. Only the claims present are checked.
+func verifyClaims(g *Graph, nodes int, subject string, claimedRank int, hasRank bool, claimedFollowers int, hasFollowers bool) []VerifyCheck {
+	var checks []VerifyCheck
+	if hasRank {
+		rawScore, _ := g.GetScore(subject)
+		checks = append(checks, verifyNumericField("rank", claimedRank, normalizeScore(rawScore, nodes), 15))
+	}
+	if hasFollowers {
+		checks = append(checks, verifyNumericField("followers", claimedFollowers, len(g.GetFollowers(subject)), 20))
+	}
+	return checks
+}
+
 // verifyNumericField compares a claimed value against an observed value.
 // tolerancePercent defines how much deviation counts as "close" vs "divergent".
 func verifyNumericField(field string, claimed, observed, tolerancePercent int) VerifyCheck {
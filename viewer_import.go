@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// viewerOverlayTTL is how long an imported contact list stands in for the
+	// viewer's crawled one.
+	viewerOverlayTTL = 24 * time.Hour
+	// viewerOverlayMax caps the viewers with an imported contact list; the one
+	// closest to expiring is dropped first.
+	viewerOverlayMax = 10000
+)
+
+// ViewerOverlay is a viewer's imported contact list.
+type ViewerOverlay struct {
+	Follows   []string
+	EventID   string
+	EventAt   time.Time
+	ExpiresAt time.Time
+}
+
+// ViewerOverlays holds contact lists that viewers submitted through
+// /personalized/import. They overlay the crawled graph for that viewer's own
+// personalized and recommendation requests only: the served graph and everyone
+// else's scores are unchanged.
+type ViewerOverlays struct {
+	mu       sync.Mutex
+	byViewer map[string]ViewerOverlay
+}
+
+func NewViewerOverlays() *ViewerOverlays {
+	return &ViewerOverlays{byViewer: make(map[string]ViewerOverlay)}
+}
+
+var viewerOverlays = NewViewerOverlays()
+
+// Set stores overlay for viewer. ok is false, and nothing changes, when the
+// viewer's current overlay came from a newer contact list.
+func (o *ViewerOverlays) Set(viewer string, overlay ViewerOverlay, now time.Time) (replaced, ok bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	prev, exists := o.byViewer[viewer]
+	if exists && now.Before(prev.ExpiresAt) {
+		if prev.EventAt.After(overlay.EventAt) {
+			return false, false
+		}
+		replaced = true
+	}
+	if !exists && len(o.byViewer) >= viewerOverlayMax {
+		o.evict(now)
+	}
+	o.byViewer[viewer] = overlay
+	return replaced, true
+}
+
+// evict drops expired overlays, or the one closest to expiring if none have.
+func (o *ViewerOverlays) evict(now time.Time) {
+	var soonest string
+	for viewer, ov := range o.byViewer {
+		if !now.Before(ov.ExpiresAt) {
+			delete(o.byViewer, viewer)
+			continue
+		}
+		if soonest == "" || ov.ExpiresAt.Before(o.byViewer[soonest].ExpiresAt) {
+			soonest = viewer
+		}
+	}
+	if len(o.byViewer) >= viewerOverlayMax {
+		delete(o.byViewer, soonest)
+	}
+}
+
+// Get returns viewer's overlay if it hasn't expired.
+func (o *ViewerOverlays) Get(viewer string, now time.Time) (ViewerOverlay, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	ov, ok := o.byViewer[viewer]
+	if !ok || !now.Before(ov.ExpiresAt) {
+		return ViewerOverlay{}, false
+	}
+	return ov, true
+}
+
+// Delete drops viewer's overlay, if any.
+func (o *ViewerOverlays) Delete(viewer string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, ok := o.byViewer[viewer]
+	delete(o.byViewer, viewer)
+	return ok
+}
+
+// followsForViewer returns viewer's follows: from their imported contact list
+// while it lasts (imported is true), otherwise from g.
+func followsForViewer(g *Graph, viewer string) (follows []string, imported bool) {
+	if ov, ok := viewerOverlays.Get(viewer, time.Now()); ok {
+		return ov.Follows, true
+	}
+	return g.GetFollows(viewer), false
+}
+
+// followsSource names where followsForViewer took the follows from.
+func followsSource(imported bool) string {
+	if imported {
+		return "import"
+	}
+	return "graph"
+}
+
+// ViewerImportResponse is the response for POST /personalized/import.
+type ViewerImportResponse struct {
+	Viewer    string `json:"viewer"`
+	Status    string `json:"status"` // imported, replaced, stale_event, deleted
+	Follows   int    `json:"follows"`
+	EventID   string `json:"event_id,omitempty"`
+	EventAt   string `json:"event_at,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// handleViewerImport serves POST /personalized/import with {"event": <signed kind 3>}:
+// a client whose viewer the crawl missed, or whose contact list just changed, hands
+// it over so /personalized and /recommend reflect it immediately. DELETE with
+// ?viewer= drops the imported list, and must be signed by the viewer with NIP-98.
+func handleViewerImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		q := bindQuery(r)
+		viewer := q.HexPubkey("viewer", true)
+		if q.Failed(w) {
+			return
+		}
+		signer, err := verifyNIP98(r, nil)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Nostr")
+			writeAPIError(w, &APIError{Status: http.StatusUnauthorized, Message: "unauthorized: " + err.Error()})
+			return
+		}
+		if signer != viewer {
+			writeAPIError(w, &APIError{Status: http.StatusForbidden, Message: "only the viewer can delete their imported contact list"})
+			return
+		}
+		if !viewerOverlays.Delete(viewer) {
+			writeAPIError(w, &APIError{Status: http.StatusNotFound, Message: "no imported contact list for viewer"})
+			return
+		}
+		graphBuild.TouchViewer(viewer) // the viewer's cached /personalized and /recommend responses changed
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ViewerImportResponse{Viewer: viewer, Status: "deleted"})
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 512<<10)) // contact lists can hold thousands of p tags
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Message: "failed to read body"})
+		return
+	}
+	var req struct {
+		Event *nostr.Event `json:"event"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusBadRequest, Code: "invalid_json", Message: "invalid JSON: " + err.Error()})
+		return
+	}
+	ev := req.Event
+	if ev == nil || ev.Kind != 3 {
+		writeAPIError(w, invalidParam("event", "event must be a kind 3 contact list"))
+		return
+	}
+	if ok, err := ev.CheckSignature(); !ev.CheckID() || err != nil || !ok {
+		writeAPIError(w, invalidParam("event", "invalid event signature"))
+		return
+	}
+	now := time.Now()
+	if ev.CreatedAt.Time().After(now.Add(10 * time.Minute)) {
+		writeAPIError(w, invalidParam("event", "event created_at is in the future"))
+		return
+	}
+
+	follows := contactListFollows(ev)
+	if follows == nil {
+		follows = []string{}
+	}
+	overlay := ViewerOverlay{
+		Follows:   follows,
+		EventID:   ev.ID,
+		EventAt:   ev.CreatedAt.Time(),
+		ExpiresAt: now.Add(viewerOverlayTTL),
+	}
+	resp := ViewerImportResponse{
+		Viewer:  ev.PubKey,
+		Follows: len(follows),
+		EventID: ev.ID,
+		EventAt: overlay.EventAt.UTC().Format(time.RFC3339),
+	}
+	replaced, ok := viewerOverlays.Set(ev.PubKey, overlay, now)
+	switch {
+	case !ok:
+		resp.Status = "stale_event"
+	case replaced:
+		resp.Status = "replaced"
+	default:
+		resp.Status = "imported"
+	}
+	if ok {
+		resp.ExpiresAt = overlay.ExpiresAt.UTC().Format(time.RFC3339)
+		graphBuild.TouchViewer(ev.PubKey) // the viewer's cached /personalized and /recommend responses changed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
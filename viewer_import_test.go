@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func setupViewerImport(t *testing.T) (sk, viewer string) {
+	t.Helper()
+	oldGraph, oldBuild, oldOverlays := graph, graphBuild, viewerOverlays
+	t.Cleanup(func() { graph, graphBuild, viewerOverlays = oldGraph, oldBuild, oldOverlays })
+	viewerOverlays = NewViewerOverlays()
+
+	sk = nostr.GeneratePrivateKey()
+	viewer, _ = nostr.GetPublicKey(sk)
+	graph = NewGraph()
+	// the viewer isn't in the crawled graph; 1 and 2 both follow 3 and 4
+	for _, f := range []int{1, 2} {
+		graph.AddFollow(padHex(f), padHex(3))
+		graph.AddFollow(padHex(f), padHex(4))
+	}
+	graph.AddFollow(padHex(3), padHex(1))
+	graph.ComputePageRank(20, 0.85)
+	graphBuild = NewGraphBuild()
+	graphBuild.Advance(time.Now())
+	return sk, viewer
+}
+
+func postViewerImport(ev *nostr.Event) (*httptest.ResponseRecorder, ViewerImportResponse) {
+	raw, _ := json.Marshal(map[string]interface{}{"event": ev})
+	rr := httptest.NewRecorder()
+	handleViewerImport(rr, httptest.NewRequest(http.MethodPost, "/personalized/import", bytes.NewReader(raw)))
+	var resp ViewerImportResponse
+	json.Unmarshal(rr.Body.Bytes(), &resp)
+	return rr, resp
+}
+
+func TestViewerImportPersonalizes(t *testing.T) {
+	sk, viewer := setupViewerImport(t)
+
+	personalized := func(algorithm string) map[string]interface{} {
+		rr := httptest.NewRecorder()
+		handlePersonalized(rr, httptest.NewRequest(http.MethodGet, "/personalized?viewer="+viewer+"&target="+padHex(1)+"&algorithm="+algorithm, nil))
+		var resp map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &resp)
+		return resp
+	}
+	if resp := personalized("blend"); resp["viewer_follows_target"] != false || resp["viewer_follows_source"] != "graph" {
+		t.Fatalf("expected no follows before the import, got %v", resp)
+	}
+
+	etag := graphBuild.ETag()
+	viewerETag, _ := graphBuild.viewerValidators(viewer)
+	rr, resp := postViewerImport(contactList(t, sk, time.Now().Add(-time.Minute), padHex(1), padHex(2)))
+	if rr.Code != http.StatusOK || resp.Status != "imported" || resp.Follows != 2 || resp.Viewer != viewer || resp.ExpiresAt == "" {
+		t.Fatalf("unexpected import response %d %s", rr.Code, rr.Body.String())
+	}
+	if got, _ := graphBuild.viewerValidators(viewer); got == viewerETag {
+		t.Error("expected the import to invalidate the viewer's cached responses")
+	}
+	if graphBuild.ETag() != etag {
+		t.Error("expected everyone else's cached responses kept")
+	}
+
+	// a conditional /personalized with the old tag is served again, others still get 304
+	handler := GraphBuildMiddleware(graphBuild, http.HandlerFunc(handlePersonalized))
+	conditional := func(path, tag string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("If-None-Match", tag)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	if code := conditional("/personalized?viewer="+viewer+"&target="+padHex(1), viewerETag); code != http.StatusOK {
+		t.Errorf("expected the viewer's stale tag refreshed, got %d", code)
+	}
+	if code := conditional("/personalized?viewer="+padHex(2)+"&target="+padHex(1), etag); code != http.StatusNotModified {
+		t.Errorf("expected another viewer's tag still current, got %d", code)
+	}
+
+	if resp := personalized("blend"); resp["viewer_follows_target"] != true || resp["viewer_follows_source"] != "import" {
+		t.Errorf("expected the imported follows used, got %v", resp)
+	}
+	if resp := personalized("ppr"); resp["ppr_rank"].(float64) == 0 || resp["ppr_reachable"].(float64) < 4 {
+		t.Errorf("expected personalized PageRank to walk the imported follows, got %v", resp)
+	}
+
+	rr = httptest.NewRecorder()
+	handleRecommend(rr, httptest.NewRequest(http.MethodGet, "/recommend?pubkey="+viewer, nil))
+	var rec struct {
+		Recommendations []map[string]interface{} `json:"recommendations"`
+		Source          string                   `json:"follows_source"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &rec)
+	if rec.Source != "import" || len(rec.Recommendations) == 0 || rec.Recommendations[0]["pubkey"] != padHex(3) && rec.Recommendations[0]["pubkey"] != padHex(4) {
+		t.Errorf("expected recommendations from the imported follows, got %s", rr.Body.String())
+	}
+}
+
+func TestViewerImportReplaceStaleAndDelete(t *testing.T) {
+	sk, viewer := setupViewerImport(t)
+	now := time.Now()
+
+	postViewerImport(contactList(t, sk, now.Add(-time.Hour), padHex(1)))
+	if _, resp := postViewerImport(contactList(t, sk, now.Add(-2*time.Hour), padHex(2))); resp.Status != "stale_event" {
+		t.Errorf("expected an older list ignored, got %s", resp.Status)
+	}
+	if _, resp := postViewerImport(contactList(t, sk, now.Add(-time.Minute), padHex(2), padHex(3))); resp.Status != "replaced" {
+		t.Errorf("expected a newer list to replace, got %s", resp.Status)
+	}
+	if follows, imported := followsForViewer(graph, viewer); !imported || len(follows) != 2 {
+		t.Errorf("expected the newer list's follows, got %v %v", follows, imported)
+	}
+
+	del := func(signer string) int {
+		const url = "http://wot.example/personalized/import?viewer="
+		req := httptest.NewRequest(http.MethodDelete, url+viewer, nil)
+		if signer != "" {
+			req.Header.Set("Authorization", nip98Header(t, signer, http.MethodDelete, url+viewer, nil, time.Now()))
+		}
+		rr := httptest.NewRecorder()
+		handleViewerImport(rr, req)
+		return rr.Code
+	}
+	if code := del(""); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 deleting without NIP-98 auth, got %d", code)
+	}
+	if code := del(nostr.GeneratePrivateKey()); code != http.StatusForbidden {
+		t.Errorf("expected 403 deleting another viewer's list, got %d", code)
+	}
+	if code := del(sk); code != http.StatusOK {
+		t.Errorf("expected delete to succeed, got %d", code)
+	}
+	if code := del(sk); code != http.StatusNotFound {
+		t.Errorf("expected 404 deleting twice, got %d", code)
+	}
+	if _, imported := followsForViewer(graph, viewer); imported {
+		t.Error("expected the crawled follows after delete")
+	}
+}
+
+func TestViewerImportRejectsInvalidEvents(t *testing.T) {
+	sk, _ := setupViewerImport(t)
+
+	tampered := contactList(t, sk, time.Now(), padHex(1))
+	tampered.Tags = append(tampered.Tags, nostr.Tag{"p", padHex(2)})
+	profile := &nostr.Event{Kind: 0, CreatedAt: nostr.Now()}
+	profile.Sign(sk)
+	future := contactList(t, sk, time.Now().Add(time.Hour), padHex(1))
+
+	for name, ev := range map[string]*nostr.Event{"tampered": tampered, "kind 0": profile, "future": future, "missing": nil} {
+		if rr, _ := postViewerImport(ev); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, rr.Code)
+		}
+	}
+	if len(viewerOverlays.byViewer) != 0 {
+		t.Error("expected nothing imported")
+	}
+}
+
+func TestViewerOverlaysExpireAndEvict(t *testing.T) {
+	o := NewViewerOverlays()
+	now := time.Now()
+	o.Set("a", ViewerOverlay{ExpiresAt: now.Add(time.Minute)}, now)
+	if _, ok := o.Get("a", now.Add(2*time.Minute)); ok {
+		t.Error("expected an expired overlay to be ignored")
+	}
+
+	o = NewViewerOverlays()
+	for i := 0; i < viewerOverlayMax; i++ {
+		o.byViewer[fmt.Sprintf("v%d", i)] = ViewerOverlay{ExpiresAt: now.Add(time.Duration(i+1) * time.Minute)}
+	}
+	o.Set("new", ViewerOverlay{ExpiresAt: now.Add(24 * time.Hour)}, now)
+	if len(o.byViewer) != viewerOverlayMax {
+		t.Errorf("expected the cap kept, got %d", len(o.byViewer))
+	}
+	if _, ok := o.byViewer["v0"]; ok {
+		t.Error("expected the overlay closest to expiring evicted")
+	}
+}
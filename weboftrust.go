@@ -2,10 +2,8 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"sort"
-	"strconv"
 )
 
 // WoTNode is a node in the trust graph visualization.
@@ -39,32 +37,21 @@ type WoTGraphResponse struct {
 // handleWebOfTrust returns a D3.js-compatible graph centered on a pubkey.
 // GET /weboftrust?pubkey=<hex|npub>&depth=1&limit=50
 func handleWebOfTrust(w http.ResponseWriter, r *http.Request) {
-	raw := r.URL.Query().Get("pubkey")
-	if raw == "" {
-		http.Error(w, `{"error":"pubkey parameter required"}`, http.StatusBadRequest)
+	g := graph.Snapshot()
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	limit := q.Int("limit", 50, 1, 200)
+	if q.Failed(w) {
 		return
 	}
 
-	pubkey, err := resolvePubkey(raw)
-	if err != nil {
-		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
-		return
-	}
-
-	limit := 50
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 200 {
-			limit = parsed
-		}
-	}
-
-	stats := graph.Stats()
-	rawScore, _ := graph.GetScore(pubkey)
+	stats := g.Stats()
+	rawScore, _ := g.GetScore(pubkey)
 	centerScore := normalizeScore(rawScore, stats.Nodes)
-	rank := graph.Rank(pubkey)
+	rank := g.Rank(pubkey)
 
-	follows := graph.GetFollows(pubkey)
-	followers := graph.GetFollowers(pubkey)
+	follows := g.GetFollows(pubkey)
+	followers := g.GetFollowers(pubkey)
 
 	// Identify mutual connections
 	followSet := make(map[string]bool, len(follows))
@@ -85,7 +72,7 @@ func handleWebOfTrust(w http.ResponseWriter, r *http.Request) {
 	scoreAndSort := func(pks []string) []scored {
 		s := make([]scored, len(pks))
 		for i, pk := range pks {
-			raw, _ := graph.GetScore(pk)
+			raw, _ := g.GetScore(pk)
 			s[i] = scored{pk, normalizeScore(raw, stats.Nodes)}
 		}
 		sort.Slice(s, func(i, j int) bool { return s[i].score > s[j].score })
@@ -125,8 +112,8 @@ func handleWebOfTrust(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if _, exists := nodeMap[pk]; !exists {
-			fFollowers := graph.GetFollowers(pk)
-			fFollows := graph.GetFollows(pk)
+			fFollowers := g.GetFollowers(pk)
+			fFollows := g.GetFollows(pk)
 			nodeMap[pk] = &WoTNode{
 				ID:        pk,
 				Score:     s.score,
@@ -159,8 +146,8 @@ func handleWebOfTrust(w http.ResponseWriter, r *http.Request) {
 			if followSet[pk] {
 				group = "mutual"
 			}
-			fFollowers := graph.GetFollowers(pk)
-			fFollows := graph.GetFollows(pk)
+			fFollowers := g.GetFollowers(pk)
+			fFollows := g.GetFollows(pk)
 			nodeMap[pk] = &WoTNode{
 				ID:        pk,
 				Score:     s.score,
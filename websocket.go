@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,13 +14,25 @@ import (
 	"github.com/coder/websocket/wsjson"
 )
 
+const (
+	// wsMaxSubscriptions caps the pubkeys one connection can watch.
+	wsMaxSubscriptions = 100
+	// wsPingInterval is how often the server pings each connection by default,
+	// and wsPongTimeout how long it waits for the pong before closing it.
+	wsPingInterval = 30 * time.Second
+	wsPongTimeout  = 10 * time.Second
+	wsWriteTimeout = 5 * time.Second
+)
+
 // WSMessage is the envelope for all WebSocket messages.
 type WSMessage struct {
-	Type    string          `json:"type"`
-	Pubkeys []string        `json:"pubkeys,omitempty"`
-	Scores  []WSScoreEntry  `json:"scores,omitempty"`
-	Error   string          `json:"error,omitempty"`
-	Stats   *WSStats        `json:"stats,omitempty"`
+	Type          string         `json:"type"`
+	Pubkeys       []string       `json:"pubkeys,omitempty"`
+	Scores        []WSScoreEntry `json:"scores,omitempty"`
+	Error         string         `json:"error,omitempty"`
+	Rejected      []string       `json:"rejected,omitempty"`      // pubkeys over the subscription cap
+	Subscriptions *int           `json:"subscriptions,omitempty"` // pubkeys watched after the change
+	Stats         *WSStats       `json:"stats,omitempty"`
 }
 
 // WSScoreEntry is a score update for a single pubkey.
@@ -47,16 +61,18 @@ type WSClient struct {
 
 // WSHub manages all active WebSocket clients.
 type WSHub struct {
-	mu      sync.Mutex
-	clients map[*WSClient]bool
-	graph   *Graph
+	mu           sync.Mutex
+	clients      map[*WSClient]bool
+	graph        *Graph
+	pingInterval time.Duration
 }
 
 // NewWSHub creates a new WebSocket hub.
 func NewWSHub(g *Graph) *WSHub {
 	return &WSHub{
-		clients: make(map[*WSClient]bool),
-		graph:   g,
+		clients:      make(map[*WSClient]bool),
+		graph:        g,
+		pingInterval: wsPingInterval,
 	}
 }
 
@@ -74,6 +90,71 @@ func (h *WSHub) Unregister(c *WSClient) {
 	delete(h.clients, c)
 }
 
+// send writes msg to the client, giving up after wsWriteTimeout.
+func (c *WSClient) send(ctx context.Context, msg WSMessage) error {
+	wctx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+	defer cancel()
+	return wsjson.Write(wctx, c.conn, msg)
+}
+
+// subscribe adds pubkeys up to wsMaxSubscriptions, returning the ones watched
+// (including any already were) and the ones over the cap, and the new total.
+func (c *WSClient) subscribe(pubkeys []string) (accepted, rejected []string, total int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, pk := range pubkeys {
+		if !c.pubkeys[pk] && len(c.pubkeys) >= wsMaxSubscriptions {
+			rejected = append(rejected, pk)
+			continue
+		}
+		c.pubkeys[pk] = true
+		accepted = append(accepted, pk)
+	}
+	return accepted, rejected, len(c.pubkeys)
+}
+
+// unsubscribe removes pubkeys, or every subscription when pubkeys is empty, and
+// returns the ones removed and how many remain.
+func (c *WSClient) unsubscribe(pubkeys []string) (removed []string, total int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(pubkeys) == 0 {
+		for pk := range c.pubkeys {
+			removed = append(removed, pk)
+		}
+		clear(c.pubkeys)
+		return removed, 0
+	}
+	for _, pk := range pubkeys {
+		if c.pubkeys[pk] {
+			delete(c.pubkeys, pk)
+			removed = append(removed, pk)
+		}
+	}
+	return removed, len(c.pubkeys)
+}
+
+// keepalive pings the client every interval and closes the connection when a
+// pong doesn't come back within wsPongTimeout. It returns when ctx ends.
+func (c *WSClient) keepalive(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pctx, cancel := context.WithTimeout(ctx, wsPongTimeout)
+			err := c.conn.Ping(pctx)
+			cancel()
+			if err != nil {
+				c.cancel()
+				return
+			}
+		}
+	}
+}
+
 // ClientCount returns the number of connected clients.
 func (h *WSHub) ClientCount() int {
 	h.mu.Lock()
@@ -82,7 +163,8 @@ func (h *WSHub) ClientCount() int {
 }
 
 // BroadcastScoreUpdate pushes updated scores to all clients for their subscribed pubkeys.
-// Call this after each PageRank recompute.
+// Call this after each PageRank recompute. Clients are sent to concurrently, so
+// a slow one can't hold up the rest; one that can't keep up is disconnected.
 func (h *WSHub) BroadcastScoreUpdate() {
 	h.mu.Lock()
 	clients := make([]*WSClient, 0, len(h.clients))
@@ -95,13 +177,15 @@ func (h *WSHub) BroadcastScoreUpdate() {
 		return
 	}
 
-	stats := h.graph.Stats()
+	g := h.graph.Snapshot()
+	stats := g.Stats()
 	wsStats := &WSStats{
 		Nodes:     stats.Nodes,
 		Edges:     stats.Edges,
 		UpdatedAt: time.Now(),
 	}
 
+	var wg sync.WaitGroup
 	for _, client := range clients {
 		client.mu.Lock()
 		pubkeys := make([]string, 0, len(client.pubkeys))
@@ -113,42 +197,47 @@ func (h *WSHub) BroadcastScoreUpdate() {
 		if len(pubkeys) == 0 {
 			continue
 		}
+		sort.Strings(pubkeys)
 
-		scores := h.lookupScores(pubkeys)
 		msg := WSMessage{
 			Type:   "update",
-			Scores: scores,
+			Scores: lookupWSScores(g, pubkeys),
 			Stats:  wsStats,
 		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		err := wsjson.Write(ctx, client.conn, msg)
-		cancel()
-		if err != nil {
-			log.Printf("ws: failed to send update to client: %v", err)
-			client.cancel()
-		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.send(context.Background(), msg); err != nil {
+				log.Printf("ws: failed to send update to client: %v", err)
+				client.cancel()
+			}
+		}()
 	}
+	wg.Wait()
 
 	log.Printf("ws: broadcast score update to %d clients", len(clients))
 }
 
 // lookupScores fetches current scores for the given pubkeys.
 func (h *WSHub) lookupScores(pubkeys []string) []WSScoreEntry {
-	stats := h.graph.Stats()
+	return lookupWSScores(h.graph.Snapshot(), pubkeys)
+}
+
+func lookupWSScores(g *Graph, pubkeys []string) []WSScoreEntry {
+	nodes := g.NodeCount()
 	entries := make([]WSScoreEntry, 0, len(pubkeys))
 	for _, pk := range pubkeys {
-		raw, ok := h.graph.GetScore(pk)
+		raw, ok := g.GetScore(pk)
 		if !ok {
 			entries = append(entries, WSScoreEntry{Pubkey: pk, Score: 0})
 			continue
 		}
 		entries = append(entries, WSScoreEntry{
 			Pubkey:     pk,
-			Score:      normalizeScore(raw, stats.Nodes),
+			Score:      normalizeScore(raw, nodes),
 			RawScore:   raw,
-			Percentile: h.graph.Percentile(pk),
-			Rank:       h.graph.Rank(pk),
+			Percentile: g.Percentile(pk),
+			Rank:       g.Rank(pk),
 		})
 	}
 	return entries
@@ -165,6 +254,7 @@ func (h *WSHub) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	client := &WSClient{
 		conn:    c,
 		pubkeys: make(map[string]bool),
@@ -176,9 +266,10 @@ func (h *WSHub) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		h.Unregister(client)
 		c.CloseNow()
 	}()
+	go client.keepalive(ctx, h.pingInterval)
 
 	// Send welcome message
-	gstats := h.graph.Stats()
+	gstats := h.graph.Snapshot().Stats()
 	welcome := WSMessage{
 		Type: "connected",
 		Stats: &WSStats{
@@ -187,89 +278,102 @@ func (h *WSHub) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			UpdatedAt: time.Now(),
 		},
 	}
-	wctx, wcancel := context.WithTimeout(ctx, 5*time.Second)
-	_ = wsjson.Write(wctx, c, welcome)
-	wcancel()
+	_ = client.send(ctx, welcome)
 
-	// Read loop: process subscribe/unsubscribe messages
+	// Read loop: process subscribe/unsubscribe/ping messages
 	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-
 		var msg WSMessage
-		err := wsjson.Read(ctx, c, &msg)
-		if err != nil {
+		if err := wsjson.Read(ctx, c, &msg); err != nil {
 			return
 		}
 
 		switch msg.Type {
 		case "subscribe":
 			resolved := make([]string, 0, len(msg.Pubkeys))
+			seen := make(map[string]bool, len(msg.Pubkeys))
 			for _, pk := range msg.Pubkeys {
 				hex, err := resolvePubkey(pk)
-				if err != nil {
+				if err != nil || hex == "" || seen[hex] {
 					continue
 				}
+				seen[hex] = true
 				resolved = append(resolved, hex)
 			}
 			if len(resolved) == 0 {
-				errMsg := WSMessage{Type: "error", Error: "no valid pubkeys provided"}
-				wctx, wcancel := context.WithTimeout(ctx, 5*time.Second)
-				_ = wsjson.Write(wctx, c, errMsg)
-				wcancel()
+				_ = client.send(ctx, WSMessage{Type: "error", Error: "no valid pubkeys provided"})
 				continue
 			}
-			// Cap subscriptions at 100 pubkeys
-			client.mu.Lock()
-			for _, pk := range resolved {
-				if len(client.pubkeys) >= 100 {
-					break
-				}
-				client.pubkeys[pk] = true
+			accepted, rejected, total := client.subscribe(resolved)
+			if len(accepted) == 0 {
+				_ = client.send(ctx, WSMessage{
+					Type:          "error",
+					Error:         fmt.Sprintf("subscription limit of %d pubkeys reached", wsMaxSubscriptions),
+					Rejected:      rejected,
+					Subscriptions: &total,
+				})
+				continue
 			}
-			client.mu.Unlock()
 
 			// Send current scores immediately
-			scores := h.lookupScores(resolved)
-			stats := h.graph.Stats()
+			g := h.graph.Snapshot()
+			stats := g.Stats()
 			resp := WSMessage{
-				Type:   "scores",
-				Scores: scores,
+				Type:          "scores",
+				Scores:        lookupWSScores(g, accepted),
+				Rejected:      rejected,
+				Subscriptions: &total,
 				Stats: &WSStats{
 					Nodes:     stats.Nodes,
 					Edges:     stats.Edges,
 					UpdatedAt: time.Now(),
 				},
 			}
-			wctx, wcancel = context.WithTimeout(ctx, 5*time.Second)
-			_ = wsjson.Write(wctx, c, resp)
-			wcancel()
+			if len(rejected) > 0 {
+				resp.Error = fmt.Sprintf("subscription limit of %d pubkeys reached", wsMaxSubscriptions)
+			}
+			_ = client.send(ctx, resp)
 
 		case "unsubscribe":
-			client.mu.Lock()
+			pubkeys := make([]string, 0, len(msg.Pubkeys))
 			for _, pk := range msg.Pubkeys {
-				hex, _ := resolvePubkey(pk)
-				delete(client.pubkeys, hex)
+				if hex, err := resolvePubkey(pk); err == nil {
+					pubkeys = append(pubkeys, hex)
+				}
+			}
+			if len(msg.Pubkeys) > 0 && len(pubkeys) == 0 {
+				_ = client.send(ctx, WSMessage{Type: "error", Error: "no valid pubkeys provided"})
+				continue
 			}
-			client.mu.Unlock()
+			removed, total := client.unsubscribe(pubkeys)
+			sort.Strings(removed)
+			_ = client.send(ctx, WSMessage{Type: "unsubscribed", Pubkeys: removed, Subscriptions: &total})
 
-			ack := WSMessage{Type: "unsubscribed"}
-			wctx, wcancel := context.WithTimeout(ctx, 5*time.Second)
-			_ = wsjson.Write(wctx, c, ack)
-			wcancel()
+		case "ping":
+			_ = client.send(ctx, WSMessage{Type: "pong"})
 
 		default:
-			errMsg := WSMessage{Type: "error", Error: "unknown message type: " + msg.Type}
-			wctx, wcancel := context.WithTimeout(ctx, 5*time.Second)
-			_ = wsjson.Write(wctx, c, errMsg)
-			wcancel()
+			_ = client.send(ctx, WSMessage{Type: "error", Error: "unknown message type: " + msg.Type})
 		}
 	}
 }
 
+// WSInfoResponse documents the /ws/scores protocol for plain HTTP requests.
+type WSInfoResponse struct {
+	Endpoint         string                  `json:"endpoint"`
+	Protocol         string                  `json:"protocol"`
+	ConnectedClients int                     `json:"connected_clients"`
+	Description      string                  `json:"description"`
+	Messages         map[string]WSMessageDoc `json:"messages"`
+	Keepalive        string                  `json:"keepalive"`
+	Responses        map[string]string       `json:"responses"`
+}
+
+// WSMessageDoc describes one client-to-server message type.
+type WSMessageDoc struct {
+	Description string `json:"description"`
+	Example     string `json:"example"`
+}
+
 // handleWebSocketInfo returns information about the WebSocket endpoint (for non-WebSocket requests).
 func handleWebSocketInfo(hub *WSHub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -281,26 +385,33 @@ func handleWebSocketInfo(hub *WSHub) http.HandlerFunc {
 
 		// Otherwise return endpoint documentation
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"endpoint":         "/ws/scores",
-			"protocol":         "websocket",
-			"connected_clients": hub.ClientCount(),
-			"description":      "Real-time WoT score streaming. Subscribe to pubkey score updates pushed after each graph recomputation.",
-			"messages": map[string]interface{}{
-				"subscribe": map[string]interface{}{
-					"description": "Subscribe to score updates for pubkeys (max 100)",
-					"example":     `{"type":"subscribe","pubkeys":["<hex_or_npub>","<hex_or_npub>"]}`,
+		json.NewEncoder(w).Encode(WSInfoResponse{
+			Endpoint:         "/ws/scores",
+			Protocol:         "websocket",
+			ConnectedClients: hub.ClientCount(),
+			Description:      "Real-time WoT score streaming. Subscribe to pubkey score updates pushed after each graph recomputation.",
+			Messages: map[string]WSMessageDoc{
+				"subscribe": {
+					Description: fmt.Sprintf("Subscribe to score updates for pubkeys (max %d per connection; extras come back in rejected)", wsMaxSubscriptions),
+					Example:     `{"type":"subscribe","pubkeys":["<hex_or_npub>","<hex_or_npub>"]}`,
+				},
+				"unsubscribe": {
+					Description: "Unsubscribe from pubkey score updates (no pubkeys: unsubscribe from all)",
+					Example:     `{"type":"unsubscribe","pubkeys":["<hex_or_npub>"]}`,
 				},
-				"unsubscribe": map[string]interface{}{
-					"description": "Unsubscribe from pubkey score updates",
-					"example":     `{"type":"unsubscribe","pubkeys":["<hex_or_npub>"]}`,
+				"ping": {
+					Description: "Application-level keepalive for clients that can't send WebSocket pings",
+					Example:     `{"type":"ping"}`,
 				},
 			},
-			"responses": map[string]interface{}{
-				"connected": "Sent on connection with current graph stats",
-				"scores":    "Sent immediately after subscribe with current scores",
-				"update":    "Pushed after each graph recomputation (~every 6 hours) with updated scores",
-				"error":     "Sent when a message cannot be processed",
+			Keepalive: fmt.Sprintf("The server sends a WebSocket ping every %s and closes the connection if no pong arrives within %s", wsPingInterval, wsPongTimeout),
+			Responses: map[string]string{
+				"connected":    "Sent on connection with current graph stats",
+				"scores":       "Sent immediately after subscribe with current scores and the subscription count",
+				"unsubscribed": "Confirms an unsubscribe with the pubkeys removed and the subscription count",
+				"pong":         "Reply to a ping message",
+				"update":       "Pushed after each graph recomputation (~every 6 hours) with updated scores",
+				"error":        "Sent when a message cannot be processed",
 			},
 		})
 	}
@@ -399,3 +399,121 @@ func TestWSHubConcurrentAccess(t *testing.T) {
 		t.Errorf("expected 0 clients after concurrent ops, got %d", hub.ClientCount())
 	}
 }
+
+// dialTestWS connects to server's /ws/scores and reads the welcome message.
+func dialTestWS(t *testing.T, ctx context.Context, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+	c, _, err := websocket.Dial(ctx, "ws"+server.URL[4:]+"/ws/scores", nil)
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	t.Cleanup(func() { c.CloseNow() })
+	var welcome WSMessage
+	if err := wsjson.Read(ctx, c, &welcome); err != nil {
+		t.Fatalf("read welcome error: %v", err)
+	}
+	return c
+}
+
+// wsRoundTrip sends msg and returns the server's reply.
+func wsRoundTrip(t *testing.T, ctx context.Context, c *websocket.Conn, msg WSMessage) WSMessage {
+	t.Helper()
+	if err := wsjson.Write(ctx, c, msg); err != nil {
+		t.Fatalf("write %s error: %v", msg.Type, err)
+	}
+	var reply WSMessage
+	if err := wsjson.Read(ctx, c, &reply); err != nil {
+		t.Fatalf("read %s reply error: %v", msg.Type, err)
+	}
+	return reply
+}
+
+func TestWSSubscriptionCap(t *testing.T) {
+	_, server := setupTestWSServer(t)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := dialTestWS(t, ctx, server)
+
+	pubkeys := make([]string, wsMaxSubscriptions+5)
+	for i := range pubkeys {
+		pubkeys[i] = padHex(46000 + i)
+	}
+	reply := wsRoundTrip(t, ctx, c, WSMessage{Type: "subscribe", Pubkeys: pubkeys})
+	if reply.Type != "scores" || len(reply.Scores) != wsMaxSubscriptions {
+		t.Fatalf("expected %d scores, got %s with %d", wsMaxSubscriptions, reply.Type, len(reply.Scores))
+	}
+	if len(reply.Rejected) != 5 || reply.Rejected[0] != pubkeys[wsMaxSubscriptions] {
+		t.Errorf("expected the last 5 pubkeys rejected, got %v", reply.Rejected)
+	}
+	if reply.Subscriptions == nil || *reply.Subscriptions != wsMaxSubscriptions {
+		t.Errorf("expected %d subscriptions, got %v", wsMaxSubscriptions, reply.Subscriptions)
+	}
+
+	// resubscribing to a pubkey already held doesn't count against the cap
+	reply = wsRoundTrip(t, ctx, c, WSMessage{Type: "subscribe", Pubkeys: pubkeys[:1]})
+	if reply.Type != "scores" || len(reply.Rejected) != 0 {
+		t.Errorf("expected an existing subscription to be accepted, got %+v", reply)
+	}
+
+	reply = wsRoundTrip(t, ctx, c, WSMessage{Type: "subscribe", Pubkeys: pubkeys[wsMaxSubscriptions:]})
+	if reply.Type != "error" || len(reply.Rejected) != 5 {
+		t.Errorf("expected an error once the cap is reached, got %+v", reply)
+	}
+}
+
+func TestWSUnsubscribeAll(t *testing.T) {
+	_, server := setupTestWSServer(t)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := dialTestWS(t, ctx, server)
+
+	wsRoundTrip(t, ctx, c, WSMessage{Type: "subscribe", Pubkeys: []string{"alice", "bob"}})
+	reply := wsRoundTrip(t, ctx, c, WSMessage{Type: "unsubscribe", Pubkeys: []string{"alice"}})
+	if reply.Type != "unsubscribed" || len(reply.Pubkeys) != 1 || reply.Subscriptions == nil || *reply.Subscriptions != 1 {
+		t.Errorf("expected alice removed with 1 subscription left, got %+v", reply)
+	}
+	reply = wsRoundTrip(t, ctx, c, WSMessage{Type: "unsubscribe"})
+	if reply.Type != "unsubscribed" || len(reply.Pubkeys) != 1 || reply.Pubkeys[0] != "bob" || *reply.Subscriptions != 0 {
+		t.Errorf("expected bob removed with none left, got %+v", reply)
+	}
+}
+
+func TestWSPingMessage(t *testing.T) {
+	_, server := setupTestWSServer(t)
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := dialTestWS(t, ctx, server)
+
+	if reply := wsRoundTrip(t, ctx, c, WSMessage{Type: "ping"}); reply.Type != "pong" {
+		t.Errorf("expected pong, got %+v", reply)
+	}
+}
+
+func TestWSKeepalive(t *testing.T) {
+	hub, server := setupTestWSServer(t)
+	defer server.Close()
+	hub.pingInterval = 20 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// a client that reads answers pings and stays connected
+	c := dialTestWS(t, ctx, server)
+	readCtx := c.CloseRead(ctx)
+	time.Sleep(100 * time.Millisecond)
+	if readCtx.Err() != nil || hub.ClientCount() != 1 {
+		t.Fatalf("expected a responsive client to stay connected")
+	}
+
+	// a client that goes away is unregistered
+	c.CloseNow()
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.ClientCount() != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if hub.ClientCount() != 0 {
+		t.Errorf("expected the dead client to be unregistered")
+	}
+}
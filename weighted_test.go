@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestInteractionStoreRecord(t *testing.T) {
+	s := NewInteractionStore()
+	alice := nostr.GeneratePrivateKey()
+	alicePub, _ := nostr.GetPublicKey(alice)
+	bob := padHex(35001)
+	now := time.Now()
+
+	reaction := signedEvent(t, alice, 7, now, nostr.Tag{"e", padHex(1)}, nostr.Tag{"p", bob})
+	reply := signedEvent(t, alice, 1, now, nostr.Tag{"e", padHex(2)}, nostr.Tag{"p", bob}, nostr.Tag{"p", padHex(35002)})
+	note := signedEvent(t, alice, 1, now, nostr.Tag{"p", bob})
+	self := signedEvent(t, alice, 7, now, nostr.Tag{"p", alicePub})
+
+	if !s.Record(reaction) || !s.Record(reply) {
+		t.Fatal("expected reaction and reply recorded")
+	}
+	if s.Record(reaction) || s.Record(note) || s.Record(self) {
+		t.Error("expected duplicates, plain notes and self-interactions ignored")
+	}
+	if reactions, replies := s.Get(alicePub, bob); reactions != 1 || replies != 1 {
+		t.Errorf("expected 1 reaction and 1 reply, got %d and %d", reactions, replies)
+	}
+	if s.PairCount() != 1 {
+		t.Errorf("expected only the first p tag credited, got %d pairs", s.PairCount())
+	}
+}
+
+func TestEdgeWeights(t *testing.T) {
+	oldInteractions, oldZaps := interactions, zapStore
+	defer func() { interactions, zapStore = oldInteractions, oldZaps }()
+	interactions, zapStore = NewInteractionStore(), NewZapStore()
+
+	sender := nostr.GeneratePrivateKey()
+	senderPub, _ := nostr.GetPublicKey(sender)
+	bob := padHex(35011)
+	now := time.Now()
+	// 1000 sats zapped plus two replies
+	zapStore.Record(signedEvent(t, nostr.GeneratePrivateKey(), 9735, now, nostr.Tag{"p", bob}, nostr.Tag{"P", senderPub}, nostr.Tag{"bolt11", "lnbc10u1pexample"}))
+	for i := 0; i < 2; i++ {
+		interactions.Record(signedEvent(t, sender, 1, now.Add(time.Duration(i)*time.Second), nostr.Tag{"e", padHex(3)}, nostr.Tag{"p", bob}))
+	}
+
+	w := defaultEdgeWeights
+	want := 1 + 0.5*math.Log10(1001) + 0.5
+	if got := w.Weight(senderPub, bob); math.Abs(got-want) > 1e-9 {
+		t.Errorf("expected weight %f, got %f", want, got)
+	}
+	if got := w.Weight(bob, senderPub); got != 1 {
+		t.Errorf("expected a passive follow to weigh 1, got %f", got)
+	}
+	w.MaxBoost = 1
+	if got := w.Weight(senderPub, bob); got != 2 {
+		t.Errorf("expected boost capped at 1, got %f", got)
+	}
+
+	t.Setenv("PAGERANK_WEIGHTING", "interactions")
+	t.Setenv("EDGE_WEIGHT_REPLY", "0.5")
+	t.Setenv("EDGE_WEIGHT_ZAP", "-1")
+	env := NewEdgeWeightsFromEnv()
+	if !env.Enabled || env.Reply != 0.5 || env.Zap != defaultEdgeWeights.Zap {
+		t.Errorf("unexpected config from env %+v", env)
+	}
+	t.Setenv("EDGE_WEIGHT_REPLY", "2abc")
+	if env := NewEdgeWeightsFromEnv(); env.Reply != defaultEdgeWeights.Reply {
+		t.Errorf("expected malformed EDGE_WEIGHT_REPLY ignored, got %v", env.Reply)
+	}
+}
+
+func TestComputeWeightedPageRank(t *testing.T) {
+	g := NewGraph()
+	// alice follows bob and carol but only engages with bob
+	g.AddFollow("alice", "bob")
+	g.AddFollow("alice", "carol")
+	g.ComputePageRank(20, 0.85)
+	bob, _ := g.GetScore("bob")
+	carol, _ := g.GetScore("carol")
+	if bob != carol {
+		t.Fatalf("expected equal unweighted scores, got %f and %f", bob, carol)
+	}
+
+	g.ComputeWeightedPageRank(20, 0.85, func(from, to string) float64 {
+		if to == "bob" {
+			return 3
+		}
+		return 1
+	})
+	bob, _ = g.GetScore("bob")
+	carol, _ = g.GetScore("carol")
+	if bob <= carol {
+		t.Errorf("expected the engaged follow to carry more trust, got bob %f carol %f", bob, carol)
+	}
+
+	// uniform weights reproduce plain PageRank
+	plain := NewGraph()
+	plain.AddFollow("alice", "bob")
+	plain.AddFollow("alice", "carol")
+	plain.ComputePageRank(20, 0.85)
+	g.ComputeWeightedPageRank(20, 0.85, func(string, string) float64 { return 1 })
+	for _, pk := range []string{"alice", "bob", "carol"} {
+		a, _ := g.GetScore(pk)
+		b, _ := plain.GetScore(pk)
+		if math.Abs(a-b) > 1e-12 {
+			t.Errorf("%s: expected %f, got %f", pk, b, a)
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// zapCreditPrefix marks a zap as an API payment: the zap request's content
+	// must contain zapCreditPrefix followed by a request ID.
+	zapCreditPrefix = "wot-credit:"
+	// zapCreditPollInterval is how often relays are asked for new zap receipts.
+	zapCreditPollInterval = time.Minute
+	// zapCreditLookback is how far back receipts are fetched on a fresh start,
+	// and how long credited receipt IDs are remembered to avoid double credit.
+	zapCreditLookback = 7 * 24 * time.Hour
+	// zapCreditHistory caps the credits listed per pubkey on /zap/balance.
+	zapCreditHistory = 20
+)
+
+var zapCreditPattern = regexp.MustCompile(`wot-credit:([a-z0-9-]{8,64})`)
+
+// ZapCredit is one zap credited to a balance.
+type ZapCredit struct {
+	Receipt    string    `json:"receipt"`
+	RequestID  string    `json:"request_id"`
+	Sats       int64     `json:"sats"`
+	CreditedAt time.Time `json:"credited_at"`
+}
+
+// ZapAccount is a zapper's API balance.
+type ZapAccount struct {
+	Balance int64       `json:"balance_sats"`
+	Spent   int64       `json:"spent_sats"`
+	Credits []ZapCredit `json:"credits"` // newest first
+}
+
+// zapCreditsState is what ZAP_CREDITS_FILE holds.
+type zapCreditsState struct {
+	Accounts map[string]*ZapAccount `json:"accounts"`
+	Receipts map[string]int64       `json:"receipts"` // credited receipt id -> receipt created_at
+	Since    int64                  `json:"since"`
+}
+
+// zapPayment is a tagged receipt waiting on its NIP-57 verification.
+type zapPayment struct {
+	Zapper    string
+	RequestID string
+	Sats      int64
+	CreatedAt int64
+}
+
+// ZapCredits is the zap alternative to paying LNbits invoices. A client zaps
+// the service pubkey with "wot-credit:<id>" in the zap request; receipts on our
+// relays are verified like any other zap (see ZapVerifier) and credited to the
+// zapper's pubkey, which then spends the balance by signing requests with an
+// Authorization: Nostr header.
+type ZapCredits struct {
+	mu       sync.Mutex
+	path     string
+	service  string // pubkey zaps must be addressed to; "" until the signer is loaded
+	lud16    string
+	accounts map[string]*ZapAccount
+	credited map[string]int64
+	pending  map[string]zapPayment // receipt id -> payment
+	since    int64
+	dirty    bool
+	total    int // receipts credited
+
+	verifier *ZapVerifier
+	now      func() time.Time
+	// fetch returns the zap receipts addressed to service since a time.
+	fetch func(ctx context.Context, service string, since int64) []*nostr.Event
+}
+
+func NewZapCredits(path, lud16 string) *ZapCredits {
+	return &ZapCredits{
+		path:     path,
+		lud16:    lud16,
+		accounts: make(map[string]*ZapAccount),
+		credited: make(map[string]int64),
+		pending:  make(map[string]zapPayment),
+		verifier: NewZapVerifier(externalHTTP),
+		now:      time.Now,
+		fetch:    fetchZapReceipts,
+	}
+}
+
+var zapCredits = NewZapCredits(os.Getenv("ZAP_CREDITS_FILE"), os.Getenv("ZAP_LUD16"))
+
+// Service returns the pubkey zaps are credited for, or "" when zap payments
+// aren't being accepted.
+func (c *ZapCredits) Service() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.service
+}
+
+// Load restores balances from the file. A missing file is not an error.
+func (c *ZapCredits) Load() error {
+	if c.path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state zapCreditsState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return fmt.Errorf("%s: %w", c.path, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for pk, a := range state.Accounts {
+		c.accounts[pk] = a
+	}
+	for id, at := range state.Receipts {
+		c.credited[id] = at
+	}
+	c.since = state.Since
+	if len(c.accounts) > 0 {
+		log.Printf("Zap credits: restored %d balances", len(c.accounts))
+	}
+	return nil
+}
+
+// save writes balances to the file if they changed.
+func (c *ZapCredits) save() {
+	c.mu.Lock()
+	if c.path == "" || !c.dirty {
+		c.mu.Unlock()
+		return
+	}
+	raw, err := json.Marshal(zapCreditsState{Accounts: c.accounts, Receipts: c.credited, Since: c.since})
+	c.dirty = false
+	c.mu.Unlock()
+	if err == nil {
+		err = writeFileAtomic(c.path, raw)
+	}
+	if err != nil {
+		log.Printf("Zap credits: saving balances failed: %v", err)
+	}
+}
+
+// Observe takes a kind 9735 receipt. Receipts for another pubkey, without a
+// wot-credit tag, or already credited are ignored; the rest are credited once
+// their receipt verifies, now or after Settle.
+func (c *ZapCredits) Observe(ev *nostr.Event) {
+	if ev == nil || ev.Kind != 9735 {
+		return
+	}
+	service := c.Service()
+	if tag := ev.Tags.Find("p"); service == "" || tag == nil || tag[1] != service {
+		return
+	}
+	desc := ev.Tags.Find("description")
+	if desc == nil {
+		return
+	}
+	var req nostr.Event
+	if json.Unmarshal([]byte(desc[1]), &req) != nil {
+		return
+	}
+	m := zapCreditPattern.FindStringSubmatch(req.Content)
+	if m == nil {
+		return
+	}
+
+	c.mu.Lock()
+	if _, ok := c.credited[ev.ID]; ok {
+		c.mu.Unlock()
+		return
+	}
+	c.pending[ev.ID] = zapPayment{Zapper: req.PubKey, RequestID: m[1], Sats: extractZapAmount(ev), CreatedAt: int64(ev.CreatedAt)}
+	c.mu.Unlock()
+
+	switch c.verifier.Record(ev) {
+	case zapVerified:
+		c.credit(ev.ID)
+	case zapPending, "":
+	default:
+		c.mu.Lock()
+		delete(c.pending, ev.ID)
+		c.mu.Unlock()
+	}
+}
+
+// Settle verifies receipts waiting on an LNURL provider lookup and credits them.
+// Receipts that failed are dropped; ones whose lookup was cut short stay for
+// the next poll.
+func (c *ZapCredits) Settle(ctx context.Context) {
+	for _, z := range c.verifier.Resolve(ctx) {
+		c.credit(z.ID)
+	}
+	c.verifier.mu.Lock()
+	waiting := make(map[string]bool, len(c.verifier.pending))
+	for _, z := range c.verifier.pending {
+		waiting[z.ID] = true
+	}
+	c.mu.Lock()
+	for id := range c.pending {
+		if c.verifier.seen[id] && !waiting[id] {
+			delete(c.pending, id)
+		}
+	}
+	c.mu.Unlock()
+	c.verifier.mu.Unlock()
+}
+
+func (c *ZapCredits) credit(receipt string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[receipt]
+	if !ok {
+		return
+	}
+	delete(c.pending, receipt)
+	if _, done := c.credited[receipt]; done || p.Sats <= 0 {
+		return
+	}
+	c.credited[receipt] = p.CreatedAt
+	a := c.accounts[p.Zapper]
+	if a == nil {
+		a = &ZapAccount{}
+		c.accounts[p.Zapper] = a
+	}
+	a.Balance += p.Sats
+	a.Credits = append([]ZapCredit{{Receipt: receipt, RequestID: p.RequestID, Sats: p.Sats, CreditedAt: c.now()}}, a.Credits...)
+	if len(a.Credits) > zapCreditHistory {
+		a.Credits = a.Credits[:zapCreditHistory]
+	}
+	c.total++
+	c.dirty = true
+	log.Printf("Zap credits: credited %d sats to %s (request %s)", p.Sats, p.Zapper, p.RequestID)
+}
+
+// Spend takes sats from pubkey's balance, reporting the balance left and
+// whether it covered the amount.
+func (c *ZapCredits) Spend(pubkey string, sats int64) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a := c.accounts[pubkey]
+	if a == nil {
+		return 0, false
+	}
+	if a.Balance < sats {
+		return a.Balance, false
+	}
+	a.Balance -= sats
+	a.Spent += sats
+	c.dirty = true
+	return a.Balance, true
+}
+
+// Account returns a copy of pubkey's account, or a zero account.
+func (c *ZapCredits) Account(pubkey string) ZapAccount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a := c.accounts[pubkey]
+	if a == nil {
+		return ZapAccount{Credits: []ZapCredit{}}
+	}
+	cp := *a
+	cp.Credits = append([]ZapCredit{}, a.Credits...)
+	return cp
+}
+
+// Poll fetches new receipts, credits the ones that verify, and saves.
+func (c *ZapCredits) Poll(ctx context.Context) {
+	service := c.Service()
+	if service == "" {
+		return
+	}
+	c.mu.Lock()
+	since := c.since
+	if floor := c.now().Add(-zapCreditLookback).Unix(); since < floor {
+		since = floor
+	}
+	c.mu.Unlock()
+
+	newest := since
+	for _, ev := range c.fetch(ctx, service, since) {
+		c.Observe(ev)
+		newest = max(newest, int64(ev.CreatedAt))
+	}
+	c.Settle(ctx)
+
+	c.mu.Lock()
+	// Overlap polls a little: receipts can reach relays late
+	c.since = max(c.since, newest-300)
+	floor := c.now().Add(-zapCreditLookback).Unix()
+	for id, at := range c.credited {
+		if at < floor {
+			delete(c.credited, id)
+		}
+	}
+	for id, p := range c.pending {
+		if p.CreatedAt < floor {
+			delete(c.pending, id)
+		}
+	}
+	c.dirty = true
+	c.mu.Unlock()
+	c.save()
+}
+
+// Watch starts accepting zaps to the signer's pubkey and polls for receipts
+// until ctx ends.
+func (c *ZapCredits) Watch(ctx context.Context) {
+	ticker := time.NewTicker(zapCreditPollInterval)
+	defer ticker.Stop()
+	for {
+		if c.Service() == "" {
+			if signer, err := signers.Get(ctx); err == nil {
+				c.mu.Lock()
+				c.service = signer.PublicKey()
+				c.mu.Unlock()
+				log.Printf("Zap credits: accepting zaps to %s", signer.PublicKey())
+			}
+		}
+		c.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			c.save()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stats reports credited and rejected receipts for /l402/info.
+func (c *ZapCredits) Stats() map[string]interface{} {
+	rejected := c.verifier.Stats()["failed"]
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return map[string]interface{}{
+		"accepting":      c.service != "",
+		"service_pubkey": c.service,
+		"content_prefix": zapCreditPrefix,
+		"credited":       c.total,
+		"rejected":       rejected,
+		"pending":        len(c.pending),
+		"accounts":       len(c.accounts),
+	}
+}
+
+// fetchZapReceipts fetches kind 9735 receipts addressed to service.
+func fetchZapReceipts(ctx context.Context, service string, since int64) []*nostr.Event {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	pool := nostr.NewSimplePool(ctx)
+	ts := nostr.Timestamp(since)
+	filter := nostr.Filter{Kinds: []int{9735}, Tags: nostr.TagMap{"p": {service}}, Since: &ts, Limit: 1000}
+	var out []*nostr.Event
+	for ev := range pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter}) {
+		out = append(out, ev.Event)
+	}
+	return out
+}
+
+// handleZapRequest handles GET /zap/request
+// Issues a request ID and says how to zap it: zap service_pubkey with content
+// in the zap request, listing relays among its relays tag.
+func handleZapRequest(w http.ResponseWriter, r *http.Request) {
+	service := zapCredits.Service()
+	if service == "" {
+		writeAPIError(w, &APIError{Status: http.StatusServiceUnavailable, Message: "zap payments are not being accepted"})
+		return
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	resp := map[string]interface{}{
+		"request_id":     id,
+		"content":        zapCreditPrefix + id,
+		"service_pubkey": service,
+		"relays":         config.Relays(),
+		"auth_header":    zapSpendAuthHeader,
+		"message":        "Zap service_pubkey with content in the zap request (kind 9734) and these relays in its relays tag. The zapped sats are credited to your pubkey within a few minutes; sign each request to a priced endpoint with a fresh NIP-98 event as in auth_header to spend them. Check with GET /zap/balance, signed with a kind 22242 event.",
+	}
+	if zapCredits.lud16 != "" {
+		resp["lud16"] = zapCredits.lud16
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleZapBalance handles GET /zap/balance
+// Returns the signer's balance and recent credits. Requires Authorization: Nostr.
+func handleZapBalance(w http.ResponseWriter, r *http.Request) {
+	pubkey, present, err := verifyNostrAuth(r)
+	if !present {
+		writeAPIError(w, &APIError{Status: http.StatusUnauthorized, Message: "Authorization: Nostr <base64 kind 22242 event> required"})
+		return
+	}
+	if err != nil {
+		writeAPIError(w, &APIError{Status: http.StatusUnauthorized, Message: err.Error()})
+		return
+	}
+	a := zapCredits.Account(pubkey)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pubkey":       pubkey,
+		"balance_sats": a.Balance,
+		"spent_sats":   a.Spent,
+		"credits":      a.Credits,
+	})
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// setupZapCredits swaps in credits accepting zaps to service, with its LNURL
+// provider's key already known.
+func setupZapCredits(t *testing.T, path string) (c *ZapCredits, service, provider string) {
+	t.Helper()
+	old := zapCredits
+	t.Cleanup(func() { zapCredits = old })
+
+	provider = nostr.GeneratePrivateKey()
+	providerPub, _ := nostr.GetPublicKey(provider)
+	service = padHex(47001)
+	endpoint := "https://example.com/.well-known/lnurlp/wot"
+
+	c = NewZapCredits(path, "wot@example.com")
+	c.service = service
+	c.verifier = NewZapVerifier(nil)
+	c.verifier.fetchLud16 = func(ctx context.Context, pubkeys []string) map[string]string {
+		return map[string]string{service: "wot@example.com"}
+	}
+	c.verifier.providers[endpoint] = lnurlProvider{Key: providerPub, FetchedAt: time.Now()}
+	zapCredits = c
+	return c, service, provider
+}
+
+func creditZap(content string) func(req *nostr.Event) {
+	return func(req *nostr.Event) { req.Content = content }
+}
+
+func TestZapCreditsPoll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zap-credits.json")
+	c, service, provider := setupZapCredits(t, path)
+	zapper, forger := nostr.GeneratePrivateKey(), nostr.GeneratePrivateKey()
+	zapperPub, _ := nostr.GetPublicKey(zapper)
+
+	paid := signedZapReceipt(t, provider, zapper, service, 21000, creditZap("thanks! wot-credit:abcd1234"))
+	receipts := []*nostr.Event{
+		paid,
+		signedZapReceipt(t, provider, zapper, service, 5000, creditZap("just a tip")),
+		signedZapReceipt(t, forger, zapper, service, 9000000, creditZap("wot-credit:forged01")),
+		signedZapReceipt(t, provider, zapper, padHex(47002), 5000, creditZap("wot-credit:otherone")),
+	}
+	c.fetch = func(ctx context.Context, svc string, since int64) []*nostr.Event { return receipts }
+
+	c.Poll(context.Background())
+	c.Poll(context.Background()) // the same receipts again
+	a := c.Account(zapperPub)
+	if a.Balance != 21 || len(a.Credits) != 1 || a.Credits[0].RequestID != "abcd1234" || a.Credits[0].Receipt != paid.ID {
+		t.Fatalf("expected only the tagged, provider-signed zap credited once, got %+v", a)
+	}
+	if stats := c.Stats(); stats["credited"] != 1 || stats["pending"] != 0 || stats["rejected"].(map[string]int)[zapProviderMismatch] != 1 {
+		t.Errorf("unexpected stats %v", stats)
+	}
+
+	if left, ok := c.Spend(zapperPub, 5); !ok || left != 16 {
+		t.Errorf("expected 16 sats left, got %d %v", left, ok)
+	}
+	if left, ok := c.Spend(zapperPub, 20); ok || left != 16 {
+		t.Errorf("expected an overdraft refused, got %d %v", left, ok)
+	}
+
+	// Balances and credited receipts survive a restart
+	c.save()
+	restored := NewZapCredits(path, "")
+	if err := restored.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if a := restored.Account(zapperPub); a.Balance != 16 || a.Spent != 5 {
+		t.Errorf("expected the balance restored, got %+v", a)
+	}
+	if _, ok := restored.credited[paid.ID]; !ok {
+		t.Errorf("expected the credited receipt remembered")
+	}
+}
+
+func TestZapCreditsIgnoredUntilAccepting(t *testing.T) {
+	c, service, provider := setupZapCredits(t, "")
+	c.service = ""
+	zapper := nostr.GeneratePrivateKey()
+	zapperPub, _ := nostr.GetPublicKey(zapper)
+	c.Observe(signedZapReceipt(t, provider, zapper, service, 21000, creditZap("wot-credit:abcd1234")))
+	if a := c.Account(zapperPub); a.Balance != 0 {
+		t.Errorf("expected nothing credited, got %+v", a)
+	}
+}
+
+func TestL402SpendsZapCredit(t *testing.T) {
+	c, _, _ := setupZapCredits(t, "")
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	c.accounts[pub] = &ZapAccount{Balance: 2}
+
+	mockLNbits := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"payment_request": "lnbc10n1ptest", "payment_hash": "testhash"})
+	}))
+	defer mockLNbits.Close()
+	handler := newTestL402(t, mockLNbits.URL).Wrap(dummyHandler())
+
+	const url = "http://wot.example/score?pubkey=abc"
+	serve := func(auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// the rate limit event can be replayed for 10 minutes, so it can't spend
+	if w := serve(nostrAuthHeader(t, sk, "wss://wot.example", time.Now())); w.Code != http.StatusPaymentRequired || c.accounts[pub].Balance != 2 {
+		t.Fatalf("expected a kind 22242 event refused for spending, got %d with %d sats left", w.Code, c.accounts[pub].Balance)
+	}
+
+	auth := nip98Header(t, sk, "GET", url, nil, time.Now())
+	if w := serve(auth); w.Code != http.StatusOK || w.Header().Get("X-Zap-Balance") != "1" {
+		t.Fatalf("expected 200 with 1 sat left, got %d %q", w.Code, w.Header().Get("X-Zap-Balance"))
+	}
+	if w := serve(auth); w.Code != http.StatusUnauthorized || c.accounts[pub].Balance != 1 {
+		t.Fatalf("expected a replayed NIP-98 event refused, got %d with %d sats left", w.Code, c.accounts[pub].Balance)
+	}
+
+	w := serve(nip98Header(t, sk, "GET", url, nil, time.Now().Add(-time.Second)))
+	if w.Code != http.StatusOK || w.Header().Get("X-Zap-Balance") != "0" {
+		t.Fatalf("expected 200 with 0 sats left, got %d %q", w.Code, w.Header().Get("X-Zap-Balance"))
+	}
+
+	w = serve(nip98Header(t, sk, "GET", url, nil, time.Now().Add(-2*time.Second)))
+	var body struct {
+		Protocols struct {
+			Zap map[string]interface{} `json:"zap"`
+		} `json:"protocols"`
+	}
+	json.NewDecoder(w.Body).Decode(&body)
+	if w.Code != http.StatusPaymentRequired || body.Protocols.Zap["service_pubkey"] != padHex(47001) || body.Protocols.Zap["balance_sats"] != float64(0) {
+		t.Errorf("expected a 402 offering zap payment, got %d %v", w.Code, body.Protocols.Zap)
+	}
+}
+
+func TestHandleZapRequestAndBalance(t *testing.T) {
+	c, service, _ := setupZapCredits(t, "")
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	c.accounts[pub] = &ZapAccount{Balance: 7, Credits: []ZapCredit{{RequestID: "abcd1234", Sats: 7}}}
+
+	rr := httptest.NewRecorder()
+	handleZapRequest(rr, httptest.NewRequest(http.MethodGet, "/zap/request", nil))
+	var req map[string]interface{}
+	json.Unmarshal(rr.Body.Bytes(), &req)
+	if rr.Code != http.StatusOK || req["service_pubkey"] != service || req["content"] != zapCreditPrefix+req["request_id"].(string) || req["lud16"] != "wot@example.com" {
+		t.Errorf("unexpected zap request %s", rr.Body.String())
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "http://wot.example/zap/balance", nil)
+	r.Header.Set("Authorization", nostrAuthHeader(t, sk, "wss://wot.example", time.Now()))
+	rr = httptest.NewRecorder()
+	handleZapBalance(rr, r)
+	var bal struct {
+		Pubkey  string      `json:"pubkey"`
+		Balance int64       `json:"balance_sats"`
+		Credits []ZapCredit `json:"credits"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &bal)
+	if rr.Code != http.StatusOK || bal.Pubkey != pub || bal.Balance != 7 || len(bal.Credits) != 1 {
+		t.Errorf("unexpected balance %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handleZapBalance(rr, httptest.NewRequest(http.MethodGet, "/zap/balance", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a signed header, got %d", rr.Code)
+	}
+
+	c.service = ""
+	rr = httptest.NewRecorder()
+	handleZapRequest(rr, httptest.NewRequest(http.MethodGet, "/zap/request", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when zaps aren't accepted, got %d", rr.Code)
+	}
+}
+
+func TestZapSpendAuthBindsBody(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pub, _ := nostr.GetPublicKey(sk)
+	const url = "http://wot.example/graphql"
+	body := []byte(`{"query":"{ score }"}`)
+
+	spend := func(auth string, sent []byte) (string, error) {
+		req := httptest.NewRequest("POST", url, bytes.NewReader(sent))
+		req.Header.Set("Authorization", auth)
+		pubkey, _, signed, err := zapSpendAuth(req)
+		if !signed {
+			t.Fatal("expected the request treated as signed")
+		}
+		if rest, _ := io.ReadAll(req.Body); !bytes.Equal(rest, sent) {
+			t.Errorf("expected the body put back for the handler, got %q", rest)
+		}
+		return pubkey, err
+	}
+
+	if got, err := spend(nip98Header(t, sk, "POST", url, body, time.Now()), body); err != nil || got != pub {
+		t.Errorf("expected a payload-bound event accepted, got %q %v", got, err)
+	}
+	if _, err := spend(nip98Header(t, sk, "POST", url, nil, time.Now()), body); err == nil {
+		t.Error("expected an event without a payload tag refused for a request with a body")
+	}
+	if _, err := spend(nip98Header(t, sk, "POST", url, body, time.Now()), []byte(`{"query":"{ other }"}`)); err == nil {
+		t.Error("expected an event signed for another body refused")
+	}
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// zapProviderTTL is how long an LNURL provider's nostrPubkey, or a recipient's
+	// lud16, is trusted before it is fetched again. Failed fetches are retried
+	// after zapProviderRetry.
+	zapProviderTTL   = 24 * time.Hour
+	zapProviderRetry = time.Hour
+	// zapVerifyMaxPending bounds the receipts waiting on a provider lookup.
+	zapVerifyMaxPending = 20000
+	// zapVerifyFetchers is how many LNURL providers are fetched at once.
+	zapVerifyFetchers = 8
+)
+
+// Zap receipt verdicts. Everything but zapVerified and zapPending is a reason a
+// receipt failed.
+const (
+	zapVerified            = "verified"
+	zapPending             = "pending"
+	zapNoZapRequest        = "no_zap_request"
+	zapBadRequest          = "bad_zap_request"
+	zapRecipientMismatch   = "recipient_mismatch"
+	zapBadInvoice          = "bad_invoice"
+	zapDescriptionMismatch = "description_hash_mismatch"
+	zapAmountMismatch      = "amount_mismatch"
+	zapNoLNURL             = "no_lnurl"
+	zapProviderUnreachable = "provider_unreachable"
+	zapProviderNoNostr     = "provider_no_nostr"
+	zapProviderMismatch    = "provider_key_mismatch"
+)
+
+// lnurlProvider is what a recipient's LNURL-pay endpoint says about zaps.
+type lnurlProvider struct {
+	Key       string // nostrPubkey, the only key allowed to sign the recipient's receipts
+	Err       string
+	FetchedAt time.Time
+}
+
+// lud16Entry is a recipient's LNURL-pay endpoint, from the lud16 in their profile.
+type lud16Entry struct {
+	Endpoint  string // "" when the profile has no lightning address
+	FetchedAt time.Time
+}
+
+// pendingZap is a receipt that passed the offline checks and waits for its
+// provider's key.
+type pendingZap struct {
+	ID        string
+	Recipient string
+	Signer    string
+	Sats      int64
+	Endpoint  string // from the zap request's lnurl tag, or "" to use the recipient's lud16
+}
+
+// ZapVerifier checks kind 9735 zap receipts the way NIP-57 (appendix F) tells
+// clients to, since anyone can publish a receipt claiming any amount:
+//
+//   - the description tag holds a signed kind 9734 zap request for the same recipient
+//   - the bolt11 invoice's description hash is the SHA-256 of that description
+//   - the invoice amount matches the zap request's amount tag, when it has one
+//   - the receipt is signed by the nostrPubkey of the recipient's LNURL provider
+//
+// The first three are checked as receipts arrive. The provider's key needs an
+// HTTP fetch, so receipts from providers not yet looked up wait until Resolve,
+// which runs at the end of the metadata crawl.
+type ZapVerifier struct {
+	mu        sync.Mutex
+	client    *SafeHTTPClient
+	providers map[string]lnurlProvider // LNURL-pay endpoint -> provider
+	lud16     map[string]lud16Entry    // recipient -> endpoint
+	seen      map[string]bool          // receipt id
+	pending   []pendingZap
+	dropped   int
+	counts    map[string]int // verdict -> receipts
+	now       func() time.Time
+	// fetchLud16 looks up the lightning addresses of pubkeys without one cached.
+	fetchLud16 func(ctx context.Context, pubkeys []string) map[string]string
+}
+
+func NewZapVerifier(client *SafeHTTPClient) *ZapVerifier {
+	return &ZapVerifier{
+		client:     client,
+		providers:  make(map[string]lnurlProvider),
+		lud16:      make(map[string]lud16Entry),
+		seen:       make(map[string]bool),
+		counts:     make(map[string]int),
+		now:        time.Now,
+		fetchLud16: fetchLud16FromRelays,
+	}
+}
+
+var zapVerifier = NewZapVerifier(externalHTTP)
+
+// checkZapReceipt runs the offline checks on a receipt and returns the verdict
+// (zapPending when they pass), the recipient, and the endpoint named by the zap
+// request's lnurl tag, if any.
+func checkZapReceipt(ev *nostr.Event) (verdict, recipient, endpoint string) {
+	if tag := ev.Tags.Find("p"); tag != nil {
+		recipient = tag[1]
+	}
+	desc := ev.Tags.Find("description")
+	if desc == nil {
+		return zapNoZapRequest, recipient, ""
+	}
+	var req nostr.Event
+	if json.Unmarshal([]byte(desc[1]), &req) != nil || req.Kind != 9734 {
+		return zapNoZapRequest, recipient, ""
+	}
+	if ok, err := req.CheckSignature(); !ok || err != nil {
+		return zapBadRequest, recipient, ""
+	}
+	if tag := req.Tags.Find("p"); tag == nil || tag[1] != recipient {
+		return zapRecipientMismatch, recipient, ""
+	}
+
+	bolt11 := ev.Tags.Find("bolt11")
+	if bolt11 == nil {
+		return zapBadInvoice, recipient, ""
+	}
+	hash, err := bolt11DescriptionHash(bolt11[1])
+	if err != nil {
+		return zapBadInvoice, recipient, ""
+	}
+	if sum := sha256.Sum256([]byte(desc[1])); !bytes.Equal(hash, sum[:]) {
+		return zapDescriptionMismatch, recipient, ""
+	}
+	if tag := req.Tags.Find("amount"); tag != nil {
+		if msats, err := strconv.ParseInt(tag[1], 10, 64); err != nil || msats != decodeBolt11Msats(bolt11[1]) {
+			return zapAmountMismatch, recipient, ""
+		}
+	}
+	if tag := req.Tags.Find("lnurl"); tag != nil {
+		endpoint = decodeLNURL(tag[1])
+	}
+	return zapPending, recipient, endpoint
+}
+
+// Record checks a receipt. A receipt whose provider key is already known is
+// settled at once; otherwise it waits for Resolve and Record returns zapPending.
+// Receipts seen before return "".
+func (v *ZapVerifier) Record(ev *nostr.Event) string {
+	if ev == nil || ev.Kind != 9735 {
+		return ""
+	}
+	verdict, recipient, endpoint := checkZapReceipt(ev)
+	sats := extractZapAmount(ev)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.seen[ev.ID] {
+		return ""
+	}
+	v.seen[ev.ID] = true
+	if verdict != zapPending {
+		v.counts[verdict]++
+		return verdict
+	}
+	z := pendingZap{ID: ev.ID, Recipient: recipient, Signer: ev.PubKey, Sats: sats, Endpoint: endpoint}
+	if verdict, ok := v.settle(z); ok {
+		v.counts[verdict]++
+		return verdict
+	}
+	if len(v.pending) >= zapVerifyMaxPending {
+		v.dropped++
+		delete(v.seen, ev.ID) // let a later crawl try again
+		return zapPending
+	}
+	v.pending = append(v.pending, z)
+	return zapPending
+}
+
+// endpointFor returns z's LNURL-pay endpoint and whether it is known. Callers hold v.mu.
+func (v *ZapVerifier) endpointFor(z pendingZap) (string, bool) {
+	if z.Endpoint != "" {
+		return z.Endpoint, true
+	}
+	e, ok := v.lud16[z.Recipient]
+	if !ok || v.now().Sub(e.FetchedAt) > zapProviderTTL {
+		return "", false
+	}
+	return e.Endpoint, true
+}
+
+// settle gives z's verdict if its endpoint and provider are known. Callers hold v.mu.
+func (v *ZapVerifier) settle(z pendingZap) (string, bool) {
+	endpoint, ok := v.endpointFor(z)
+	if !ok {
+		return "", false
+	}
+	if endpoint == "" {
+		return zapNoLNURL, true
+	}
+	p, ok := v.providers[endpoint]
+	if !ok || !v.fresh(p) {
+		return "", false
+	}
+	switch {
+	case p.Err != "":
+		return zapProviderUnreachable, true
+	case p.Key == "":
+		return zapProviderNoNostr, true
+	case p.Key != z.Signer:
+		return zapProviderMismatch, true
+	}
+	return zapVerified, true
+}
+
+func (v *ZapVerifier) fresh(p lnurlProvider) bool {
+	ttl := zapProviderTTL
+	if p.Err != "" {
+		ttl = zapProviderRetry
+	}
+	return v.now().Sub(p.FetchedAt) <= ttl
+}
+
+// Resolve looks up the lightning addresses and LNURL providers the pending
+// receipts need, settles them, and returns the ones that verified.
+func (v *ZapVerifier) Resolve(ctx context.Context) []pendingZap {
+	v.mu.Lock()
+	pending := v.pending
+	v.pending = nil
+	var recipients []string
+	need := make(map[string]bool)
+	for _, z := range pending {
+		if _, ok := v.endpointFor(z); !ok && !need[z.Recipient] {
+			need[z.Recipient] = true
+			recipients = append(recipients, z.Recipient)
+		}
+	}
+	v.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if len(recipients) > 0 {
+		found := v.fetchLud16(ctx, recipients)
+		now := v.now()
+		v.mu.Lock()
+		for _, pk := range recipients {
+			v.lud16[pk] = lud16Entry{Endpoint: lud16Endpoint(found[pk]), FetchedAt: now}
+		}
+		v.mu.Unlock()
+	}
+
+	v.mu.Lock()
+	var endpoints []string
+	queued := make(map[string]bool)
+	for _, z := range pending {
+		endpoint, _ := v.endpointFor(z)
+		if p, ok := v.providers[endpoint]; endpoint == "" || queued[endpoint] || (ok && v.fresh(p)) {
+			continue
+		}
+		queued[endpoint] = true
+		endpoints = append(endpoints, endpoint)
+	}
+	v.mu.Unlock()
+
+	results := make(chan struct {
+		endpoint string
+		provider lnurlProvider
+	}, len(endpoints))
+	sem := make(chan struct{}, zapVerifyFetchers)
+	var wg sync.WaitGroup
+	for _, endpoint := range endpoints {
+		wg.Add(1)
+		go func(endpoint string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results <- struct {
+				endpoint string
+				provider lnurlProvider
+			}{endpoint, v.fetchProvider(ctx, endpoint)}
+		}(endpoint)
+	}
+	wg.Wait()
+	close(results)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for r := range results {
+		v.providers[r.endpoint] = r.provider
+	}
+	var verified []pendingZap
+	for _, z := range pending {
+		verdict, ok := v.settle(z)
+		if !ok {
+			// the lookup was cut short; try again next crawl
+			delete(v.seen, z.ID)
+			continue
+		}
+		v.counts[verdict]++
+		if verdict == zapVerified {
+			verified = append(verified, z)
+		}
+	}
+	if len(endpoints) > 0 {
+		log.Printf("Zap verification: %d receipts settled, %d verified, %d LNURL providers fetched", len(pending), len(verified), len(endpoints))
+	}
+	return verified
+}
+
+// fetchProvider fetches an LNURL-pay endpoint (LUD-06) for its NIP-57 fields.
+func (v *ZapVerifier) fetchProvider(ctx context.Context, endpoint string) lnurlProvider {
+	p := lnurlProvider{FetchedAt: v.now()}
+	resp, err := v.client.Get(ctx, endpoint)
+	if err != nil {
+		p.Err = err.Error()
+		return p
+	}
+	if resp.StatusCode != http.StatusOK {
+		p.Err = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		return p
+	}
+	var body struct {
+		AllowsNostr bool   `json:"allowsNostr"`
+		NostrPubkey string `json:"nostrPubkey"`
+	}
+	if err := json.Unmarshal(resp.Body, &body); err != nil {
+		p.Err = "invalid LNURL-pay response"
+		return p
+	}
+	if body.AllowsNostr && hex64Pattern.MatchString(body.NostrPubkey) {
+		p.Key = body.NostrPubkey
+	}
+	return p
+}
+
+// Stats reports receipts by verdict, plus the pending and cached counts, for /stats.
+func (v *ZapVerifier) Stats() map[string]interface{} {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	failed := make(map[string]int)
+	for verdict, n := range v.counts {
+		if verdict != zapVerified {
+			failed[verdict] = n
+		}
+	}
+	return map[string]interface{}{
+		"verified":        v.counts[zapVerified],
+		"failed":          failed,
+		"pending":         len(v.pending),
+		"dropped":         v.dropped,
+		"providers_known": len(v.providers),
+	}
+}
+
+var lud16Pattern = regexp.MustCompile(`^[a-z0-9._+-]+@[a-z0-9.-]+\.[a-z]{2,}$`)
+
+// lud16Endpoint turns a lightning address (LUD-16) into its LNURL-pay endpoint.
+func lud16Endpoint(address string) string {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if !lud16Pattern.MatchString(address) {
+		return ""
+	}
+	name, domain, _ := strings.Cut(address, "@")
+	return "https://" + domain + "/.well-known/lnurlp/" + name
+}
+
+// decodeLNURL decodes a bech32 LNURL (LUD-01) to its https URL, or "".
+func decodeLNURL(s string) string {
+	hrp, data, err := decodeBech32(s)
+	if err != nil || hrp != "lnurl" {
+		return ""
+	}
+	u := string(bech32ToBytes(data))
+	if !strings.HasPrefix(u, "https://") {
+		return ""
+	}
+	return u
+}
+
+// fetchLud16FromRelays fetches pubkeys' newest kind 0 profiles and returns their lud16.
+func fetchLud16FromRelays(ctx context.Context, pubkeys []string) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	pool := nostr.NewSimplePool(ctx)
+	newest := make(map[string]*nostr.Event)
+	batchSize := crawlBatchSize(200, 1)
+	for i := 0; i < len(pubkeys); i += batchSize {
+		batch := pubkeys[i:min(i+batchSize, len(pubkeys))]
+		filter := nostr.Filter{Kinds: []int{0}, Authors: batch, Limit: len(batch)}
+		for ev := range pool.SubManyEose(ctx, crawlRelays(), nostr.Filters{filter}) {
+			if cur := newest[ev.Event.PubKey]; cur == nil || ev.Event.CreatedAt > cur.CreatedAt {
+				newest[ev.Event.PubKey] = ev.Event
+			}
+		}
+	}
+	out := make(map[string]string, len(newest))
+	for pk, ev := range newest {
+		var profile struct {
+			Lud16 string `json:"lud16"`
+		}
+		if json.Unmarshal([]byte(ev.Content), &profile) == nil && profile.Lud16 != "" {
+			out[pk] = profile.Lud16
+		}
+	}
+	return out
+}
+
+// bolt11DescriptionHash returns the description hash (the h field) of a BOLT11 invoice.
+func bolt11DescriptionHash(invoice string) ([]byte, error) {
+	hrp, data, err := decodeBech32(invoice)
+	if err != nil {
+		return nil, err
+	}
+	// 7 words of timestamp, the tagged fields, then a 104-word signature
+	if !strings.HasPrefix(hrp, "ln") || len(data) < 7+104 {
+		return nil, errors.New("bolt11: not an invoice")
+	}
+	fields := data[7 : len(data)-104]
+	for len(fields) >= 3 {
+		typ, n := fields[0], int(fields[1])<<5|int(fields[2])
+		fields = fields[3:]
+		if n > len(fields) {
+			return nil, errors.New("bolt11: truncated field")
+		}
+		if typ == 23 && n == 52 { // 'h', 256 bits
+			return bech32ToBytes(fields[:n])[:32], nil
+		}
+		fields = fields[n:]
+	}
+	return nil, errors.New("bolt11: no description hash")
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// decodeBech32 decodes a bech32 string of any length, since invoices and LNURLs
+// run past BIP-173's 90 characters, and checks its checksum. data is 5-bit words
+// without the checksum.
+func decodeBech32(s string) (hrp string, data []byte, err error) {
+	s = strings.ToLower(s)
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, errors.New("bech32: invalid separator position")
+	}
+	hrp = s[:pos]
+	for i := pos + 1; i < len(s); i++ {
+		w := strings.IndexByte(bech32Charset, s[i])
+		if w < 0 {
+			return "", nil, fmt.Errorf("bech32: invalid character %q", s[i])
+		}
+		data = append(data, byte(w))
+	}
+	if bech32Polymod(append(bech32HRPExpand(hrp), data...)) != 1 {
+		return "", nil, errors.New("bech32: invalid checksum")
+	}
+	return hrp, data[:len(data)-6], nil
+}
+
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	out := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+	return out
+}
+
+// bech32ToBytes regroups 5-bit words into bytes, dropping the padding bits.
+func bech32ToBytes(words []byte) []byte {
+	var out []byte
+	acc, bits := uint32(0), uint(0)
+	for _, w := range words {
+		acc = acc<<5 | uint32(w)
+		bits += 5
+		for bits >= 8 {
+			bits -= 8
+			out = append(out, byte(acc>>bits))
+		}
+		acc &= 1<<bits - 1
+	}
+	return out
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// bech32Encode encodes 5-bit words with a checksum, for building test invoices.
+func bech32Encode(hrp string, words []byte) string {
+	values := append(bech32HRPExpand(hrp), words...)
+	mod := bech32Polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ 1
+	var b strings.Builder
+	b.WriteString(hrp + "1")
+	for _, w := range words {
+		b.WriteByte(bech32Charset[w])
+	}
+	for i := 0; i < 6; i++ {
+		b.WriteByte(bech32Charset[(mod>>uint(5*(5-i)))&31])
+	}
+	return b.String()
+}
+
+// bytesToWords regroups bytes into 5-bit words, padding the last one.
+func bytesToWords(data []byte) []byte {
+	var out []byte
+	acc, bits := uint32(0), uint(0)
+	for _, c := range data {
+		acc = acc<<8 | uint32(c)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out = append(out, byte(acc>>bits)&31)
+		}
+		acc &= 1<<bits - 1
+	}
+	if bits > 0 {
+		out = append(out, byte(acc<<(5-bits))&31)
+	}
+	return out
+}
+
+// testInvoice builds a BOLT11 invoice for msats with description hash h and a
+// zeroed signature.
+func testInvoice(msats int64, h []byte) string {
+	words := make([]byte, 7) // timestamp
+	hash := bytesToWords(h)
+	words = append(words, 23, byte(len(hash)>>5), byte(len(hash)&31))
+	words = append(words, hash...)
+	words = append(words, make([]byte, 104)...)
+	return bech32Encode(fmt.Sprintf("lnbc%dn", msats/100), words)
+}
+
+// signedZapReceipt builds a receipt for a zap from sender to recipient, signed by
+// providerSK. edit, when set, can change the zap request before it is signed.
+func signedZapReceipt(t *testing.T, providerSK, senderSK, recipient string, msats int64, edit func(req *nostr.Event)) *nostr.Event {
+	t.Helper()
+	req := &nostr.Event{Kind: 9734, CreatedAt: nostr.Now(), Tags: nostr.Tags{
+		{"p", recipient},
+		{"amount", fmt.Sprintf("%d", msats)},
+	}}
+	if edit != nil {
+		edit(req)
+	}
+	if err := req.Sign(senderSK); err != nil {
+		t.Fatal(err)
+	}
+	desc, _ := json.Marshal(req)
+	sum := sha256.Sum256(desc)
+	return signedEvent(t, providerSK, 9735, time.Now(),
+		nostr.Tag{"p", recipient},
+		nostr.Tag{"P", req.PubKey},
+		nostr.Tag{"bolt11", testInvoice(msats, sum[:])},
+		nostr.Tag{"description", string(desc)},
+	)
+}
+
+func TestBolt11DescriptionHash(t *testing.T) {
+	sum := sha256.Sum256([]byte("zap request"))
+	invoice := testInvoice(21000, sum[:])
+	got, err := bolt11DescriptionHash(invoice)
+	if err != nil || string(got) != string(sum[:]) {
+		t.Fatalf("expected the description hash back, got %x %v", got, err)
+	}
+	if msats := decodeBolt11Msats(invoice); msats != 21000 {
+		t.Errorf("expected 21000 msats, got %d", msats)
+	}
+	corrupt := invoice[:len(invoice)-1] + string(bech32Charset[(strings.IndexByte(bech32Charset, invoice[len(invoice)-1])+1)%32])
+	if _, err := bolt11DescriptionHash(corrupt); err == nil {
+		t.Error("expected a bad checksum to fail")
+	}
+}
+
+func TestDecodeLNURL(t *testing.T) {
+	u := "https://getalby.com/lnurlp/alice"
+	if got := decodeLNURL(bech32Encode("lnurl", bytesToWords([]byte(u)))); got != u {
+		t.Errorf("expected %s, got %q", u, got)
+	}
+	if got := decodeLNURL(bech32Encode("lnurl", bytesToWords([]byte("http://example.com")))); got != "" {
+		t.Errorf("expected plain http refused, got %q", got)
+	}
+	if got := lud16Endpoint("Alice@GetAlby.com"); got != "https://getalby.com/.well-known/lnurlp/alice" {
+		t.Errorf("unexpected lud16 endpoint %q", got)
+	}
+	if got := lud16Endpoint("not an address"); got != "" {
+		t.Errorf("expected an invalid address refused, got %q", got)
+	}
+}
+
+func TestCheckZapReceipt(t *testing.T) {
+	provider, sender := nostr.GeneratePrivateKey(), nostr.GeneratePrivateKey()
+	recipient := padHex(50001)
+	lnurl := bech32Encode("lnurl", bytesToWords([]byte("https://example.com/lnurlp/bob")))
+
+	cases := []struct {
+		name string
+		ev   *nostr.Event
+		want string
+	}{
+		{"valid", signedZapReceipt(t, provider, sender, recipient, 21000, func(req *nostr.Event) {
+			req.Tags = append(req.Tags, nostr.Tag{"lnurl", lnurl})
+		}), zapPending},
+		{"other recipient", signedZapReceipt(t, provider, sender, recipient, 21000, func(req *nostr.Event) {
+			req.Tags[0] = nostr.Tag{"p", padHex(50002)}
+		}), zapRecipientMismatch},
+		{"amount", signedZapReceipt(t, provider, sender, recipient, 21000, func(req *nostr.Event) {
+			req.Tags[1] = nostr.Tag{"amount", "1000000"}
+		}), zapAmountMismatch},
+	}
+	// a real invoice for one zap request, receipted with another
+	paid := signedZapReceipt(t, provider, sender, recipient, 21000, nil)
+	forged := signedZapReceipt(t, provider, sender, recipient, 21000, func(req *nostr.Event) {
+		req.Content = "another request"
+	})
+	forged.Tags[2] = paid.Tags[2]
+	cases = append(cases, struct {
+		name string
+		ev   *nostr.Event
+		want string
+	}{"reused invoice", forged, zapDescriptionMismatch})
+	bare := signedEvent(t, provider, 9735, time.Now(), nostr.Tag{"p", recipient}, nostr.Tag{"bolt11", "lnbc10n1xyz"})
+	cases = append(cases, struct {
+		name string
+		ev   *nostr.Event
+		want string
+	}{"no zap request", bare, zapNoZapRequest})
+
+	for _, c := range cases {
+		verdict, _, endpoint := checkZapReceipt(c.ev)
+		if verdict != c.want {
+			t.Errorf("%s: expected %s, got %s", c.name, c.want, verdict)
+		}
+		if c.name == "valid" && endpoint != "https://example.com/lnurlp/bob" {
+			t.Errorf("expected the endpoint from the lnurl tag, got %q", endpoint)
+		}
+	}
+}
+
+func TestZapVerifierResolve(t *testing.T) {
+	provider, forger, sender := nostr.GeneratePrivateKey(), nostr.GeneratePrivateKey(), nostr.GeneratePrivateKey()
+	providerPub, _ := nostr.GetPublicKey(provider)
+	recipient := padHex(50101)
+	endpoint := "https://example.com/.well-known/lnurlp/alice"
+
+	v := NewZapVerifier(nil)
+	lookups := 0
+	v.fetchLud16 = func(ctx context.Context, pubkeys []string) map[string]string {
+		lookups++
+		return map[string]string{recipient: "alice@example.com"}
+	}
+	v.providers[endpoint] = lnurlProvider{Key: providerPub, FetchedAt: time.Now()}
+
+	good := signedZapReceipt(t, provider, sender, recipient, 50000, nil)
+	fake := signedZapReceipt(t, forger, sender, recipient, 5000000, nil)
+	if v.Record(good) != zapPending || v.Record(fake) != zapPending {
+		t.Fatal("expected receipts to wait for the recipient's lud16")
+	}
+	if v.Record(good) != "" {
+		t.Error("expected a repeat receipt ignored")
+	}
+	verified := v.Resolve(context.Background())
+	if len(verified) != 1 || verified[0].ID != good.ID || verified[0].Sats != 50 {
+		t.Fatalf("expected only the provider-signed receipt verified, got %+v", verified)
+	}
+	stats := v.Stats()
+	if stats["verified"] != 1 || stats["failed"].(map[string]int)[zapProviderMismatch] != 1 || stats["pending"] != 0 {
+		t.Errorf("unexpected stats %v", stats)
+	}
+
+	// with the lud16 and provider cached, later receipts settle straight away
+	if verdict := v.Record(signedZapReceipt(t, provider, sender, recipient, 1000, nil)); verdict != zapVerified {
+		t.Errorf("expected an immediate verdict, got %s", verdict)
+	}
+	if lookups != 1 {
+		t.Errorf("expected one lud16 lookup, got %d", lookups)
+	}
+}
+
+func TestZapVerifierFetchProvider(t *testing.T) {
+	key := padHex(50201)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/nostr":
+			fmt.Fprintf(w, `{"callback":"https://x/cb","allowsNostr":true,"nostrPubkey":%q}`, key)
+		case "/plain":
+			fmt.Fprint(w, `{"callback":"https://x/cb"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	cfg := DefaultFetchConfig
+	cfg.AllowPrivate, cfg.MaxRetries = true, 0
+	v := NewZapVerifier(NewSafeHTTPClient(cfg))
+
+	if p := v.fetchProvider(context.Background(), srv.URL+"/nostr"); p.Key != key || p.Err != "" {
+		t.Errorf("expected the provider key, got %+v", p)
+	}
+	if p := v.fetchProvider(context.Background(), srv.URL+"/plain"); p.Key != "" || p.Err != "" {
+		t.Errorf("expected no key from a provider without zaps, got %+v", p)
+	}
+	if p := v.fetchProvider(context.Background(), srv.URL+"/missing"); p.Err == "" {
+		t.Errorf("expected a 404 to be an error, got %+v", p)
+	}
+}
+
+func TestRecordZapCreditsVerifiedAmount(t *testing.T) {
+	oldMeta, oldZaps, oldVerifier := meta, zapStore, zapVerifier
+	defer func() { meta, zapStore, zapVerifier = oldMeta, oldZaps, oldVerifier }()
+	meta, zapStore, zapVerifier = NewMetaStore(), NewZapStore(), NewZapVerifier(nil)
+
+	provider, sender := nostr.GeneratePrivateKey(), nostr.GeneratePrivateKey()
+	providerPub, _ := nostr.GetPublicKey(provider)
+	recipient := padHex(50301)
+	endpoint := "https://example.com/lnurlp/carol"
+	zapVerifier.providers[endpoint] = lnurlProvider{Key: providerPub, FetchedAt: time.Now()}
+	lnurl := func(req *nostr.Event) {
+		req.Tags = append(req.Tags, nostr.Tag{"lnurl", bech32Encode("lnurl", bytesToWords([]byte(endpoint)))})
+	}
+
+	meta.recordZap(signedZapReceipt(t, provider, sender, recipient, 21000, lnurl))
+	meta.recordZap(signedZapReceipt(t, nostr.GeneratePrivateKey(), sender, recipient, 100000000, lnurl))
+	m := meta.Get(recipient)
+	if m.ZapAmtRecd != 100021 || m.ZapCntRecd != 2 {
+		t.Errorf("expected both receipts in the raw totals, got %d sats / %d", m.ZapAmtRecd, m.ZapCntRecd)
+	}
+	if m.VerifiedZapAmtRecd != 21 || m.VerifiedZapCntRecd != 1 {
+		t.Errorf("expected only the genuine zap verified, got %d sats / %d", m.VerifiedZapAmtRecd, m.VerifiedZapCntRecd)
+	}
+}
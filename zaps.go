@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// zapBoostMax caps how many points zaps can add to a WoT score.
+	zapBoostMax = 10
+	// zapBoostScale converts log10(weighted sats) into points: 1k weighted sats ≈ 6, 100k ≈ 10.
+	zapBoostScale = 2.0
+	// Alt-key heuristic: a sender with a score below zapAltMaxScore that sends at least
+	// zapAltConcentration of its sats to one recipient it is linked to by a follow.
+	zapAltMaxScore      = 5
+	zapAltConcentration = 0.9
+	// zapCycleMaxFanout bounds the 3-cycle search per pubkey.
+	zapCycleMaxFanout = 200
+	// zapCycleMinReturn is the share of inbound sats that must flow back around a
+	// loop for it to count as wash trading rather than friends tipping each other.
+	zapCycleMinReturn = 0.5
+)
+
+// zapFlow aggregates zaps from one sender to one recipient.
+type zapFlow struct {
+	Sats     int64
+	Count    int
+	Services map[string]int // zap receipt signer (LNURL service) pubkey -> receipts
+}
+
+// ZapStore records sender -> recipient zap flows from kind 9735 receipts.
+type ZapStore struct {
+	mu   sync.RWMutex
+	seen map[string]bool                // receipt id
+	out  map[string]map[string]*zapFlow // sender -> recipient -> flow
+	in   map[string]map[string]*zapFlow // recipient -> sender -> flow (same pointers)
+}
+
+func NewZapStore() *ZapStore {
+	return &ZapStore{
+		seen: make(map[string]bool),
+		out:  make(map[string]map[string]*zapFlow),
+		in:   make(map[string]map[string]*zapFlow),
+	}
+}
+
+// zapSender returns the zap sender: the receipt's "P" tag, or the pubkey of the
+// kind 9734 zap request embedded in its "description" tag.
+func zapSender(ev *nostr.Event) string {
+	if tag := ev.Tags.Find("P"); tag != nil && hex64Pattern.MatchString(tag[1]) {
+		return tag[1]
+	}
+	if tag := ev.Tags.Find("description"); tag != nil {
+		var req nostr.Event
+		if json.Unmarshal([]byte(tag[1]), &req) == nil && req.Kind == 9734 && hex64Pattern.MatchString(req.PubKey) {
+			return req.PubKey
+		}
+	}
+	return ""
+}
+
+// Record adds a zap receipt. It returns false for duplicates and receipts without
+// a recognizable sender, recipient, or amount.
+func (zs *ZapStore) Record(ev *nostr.Event) bool {
+	if ev == nil || ev.Kind != 9735 {
+		return false
+	}
+	recipientTag := ev.Tags.Find("p")
+	if recipientTag == nil || !hex64Pattern.MatchString(recipientTag[1]) {
+		return false
+	}
+	recipient := recipientTag[1]
+	sender := zapSender(ev)
+	amount := extractZapAmount(ev)
+	if sender == "" || amount <= 0 {
+		return false
+	}
+
+	zs.mu.Lock()
+	defer zs.mu.Unlock()
+	if zs.seen[ev.ID] {
+		return false
+	}
+	zs.seen[ev.ID] = true
+
+	if zs.out[sender] == nil {
+		zs.out[sender] = make(map[string]*zapFlow)
+	}
+	flow := zs.out[sender][recipient]
+	if flow == nil {
+		flow = &zapFlow{Services: make(map[string]int)}
+		zs.out[sender][recipient] = flow
+		if zs.in[recipient] == nil {
+			zs.in[recipient] = make(map[string]*zapFlow)
+		}
+		zs.in[recipient][sender] = flow
+	}
+	flow.Sats += amount
+	flow.Count++
+	flow.Services[ev.PubKey]++
+	return true
+}
+
+// FlowSats returns the sats sender has zapped recipient.
+func (zs *ZapStore) FlowSats(sender, recipient string) int64 {
+	zs.mu.RLock()
+	defer zs.mu.RUnlock()
+	if flow := zs.out[sender][recipient]; flow != nil {
+		return flow.Sats
+	}
+	return 0
+}
+
+// ReceiptCount returns the number of distinct receipts recorded.
+func (zs *ZapStore) ReceiptCount() int {
+	zs.mu.RLock()
+	defer zs.mu.RUnlock()
+	return len(zs.seen)
+}
+
+// ZapWashPattern is one detected wash-trading pattern around a recipient.
+type ZapWashPattern struct {
+	Type           string   `json:"type"` // self_zap, circular, alt_key
+	Counterparties []string `json:"counterparties,omitempty"`
+	Sats           int64    `json:"sats"` // inbound sats attributed to the pattern
+	SameLNService  bool     `json:"same_ln_service,omitempty"`
+	Detail         string   `json:"detail"`
+}
+
+// sharedService reports whether two flows have receipts signed by the same LNURL service.
+func sharedService(a, b *zapFlow) bool {
+	for svc := range a.Services {
+		if b.Services[svc] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// detectZapWash finds wash-trading patterns in zaps received by pubkey:
+//   - self_zap: pubkey zapped itself
+//   - circular: at least half the sats flow back to the sender through a 2- or 3-hop loop
+//   - alt_key: a near-zero-score sender linked by a follow sends almost all of
+//     its sats to pubkey, the typical shape of an alt key funding its main
+//
+// Where both legs of a loop were settled through the same LNURL service (same
+// receipt signer), SameLNService is set; with custodial wallets this is a weak
+// signal on its own and only qualifies a pattern found by the flow analysis.
+// It returns the patterns and the set of senders whose inbound sats are excluded.
+func detectZapWash(zs *ZapStore, pubkey string) ([]ZapWashPattern, map[string]bool) {
+	zs.mu.RLock()
+	defer zs.mu.RUnlock()
+
+	var patterns []ZapWashPattern
+	excluded := make(map[string]bool)
+	inbound := zs.in[pubkey]
+
+	if self := inbound[pubkey]; self != nil {
+		excluded[pubkey] = true
+		patterns = append(patterns, ZapWashPattern{
+			Type: "self_zap", Sats: self.Sats,
+			Detail: fmt.Sprintf("%d zaps (%d sats) sent to itself", self.Count, self.Sats),
+		})
+	}
+
+	senders := make([]string, 0, len(inbound))
+	for s := range inbound {
+		if s != pubkey {
+			senders = append(senders, s)
+		}
+	}
+	sort.Strings(senders)
+
+	outbound := zs.out[pubkey]
+	for _, s := range senders {
+		in := inbound[s]
+
+		minReturn := int64(math.Ceil(float64(in.Sats) * zapCycleMinReturn))
+
+		// 2-cycle: pubkey zaps the sender back
+		if back := outbound[s]; back != nil && back.Sats >= minReturn {
+			excluded[s] = true
+			patterns = append(patterns, ZapWashPattern{
+				Type: "circular", Counterparties: []string{s}, Sats: in.Sats,
+				SameLNService: sharedService(in, back),
+				Detail:        fmt.Sprintf("%d sats in, %d sats back out to the same pubkey", in.Sats, back.Sats),
+			})
+			continue
+		}
+
+		// 3-cycle: pubkey -> x -> s -> pubkey
+		found := false
+		checked := 0
+		for x, leg1 := range outbound {
+			if checked >= zapCycleMaxFanout {
+				break
+			}
+			checked++
+			if x == s || leg1.Sats < minReturn {
+				continue
+			}
+			if leg2 := zs.out[x][s]; leg2 != nil && leg2.Sats >= minReturn {
+				excluded[s] = true
+				patterns = append(patterns, ZapWashPattern{
+					Type: "circular", Counterparties: []string{x, s}, Sats: in.Sats,
+					SameLNService: sharedService(in, leg1) && sharedService(leg1, leg2),
+					Detail:        fmt.Sprintf("%d sats loop through 3 pubkeys and back", in.Sats),
+				})
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		// Alt key: low-score sender, concentrated on pubkey, linked by a follow
+		raw, _ := graph.GetScore(s)
+		if normalizeScore(raw, graph.Stats().Nodes) >= zapAltMaxScore {
+			continue
+		}
+		var sentTotal int64
+		for _, f := range zs.out[s] {
+			sentTotal += f.Sats
+		}
+		if sentTotal == 0 || float64(in.Sats)/float64(sentTotal) < zapAltConcentration {
+			continue
+		}
+		if !followsEither(s, pubkey) {
+			continue
+		}
+		excluded[s] = true
+		patterns = append(patterns, ZapWashPattern{
+			Type: "alt_key", Counterparties: []string{s}, Sats: in.Sats,
+			Detail: fmt.Sprintf("low-trust sender routes %.0f%% of its zapped sats here", float64(in.Sats)/float64(sentTotal)*100),
+		})
+	}
+	return patterns, excluded
+}
+
+func followsEither(a, b string) bool {
+	for _, f := range graph.GetFollows(a) {
+		if f == b {
+			return true
+		}
+	}
+	for _, f := range graph.GetFollows(b) {
+		if f == a {
+			return true
+		}
+	}
+	return false
+}
+
+// ZapScoreResponse is the response for GET /zap-score.
+type ZapScoreResponse struct {
+	Pubkey           string           `json:"pubkey"`
+	BaseScore        int              `json:"base_score"`
+	ZapBoost         int              `json:"zap_boost"`
+	ZapWeightedScore int              `json:"zap_weighted_score"`
+	ReceivedSats     int64            `json:"received_sats"`
+	CountedSats      int64            `json:"counted_sats"`
+	WeightedSats     float64          `json:"weighted_sats"` // counted sats × sender score / 100
+	ExcludedSats     int64            `json:"excluded_sats"`
+	Senders          int              `json:"senders"`
+	ExcludedSenders  int              `json:"excluded_senders"`
+	WashPatterns     []ZapWashPattern `json:"wash_patterns"`
+	GraphSize        int              `json:"graph_size"`
+}
+
+// computeZapScore derives a bounded zap boost. Each sender's sats are weighted by
+// the sender's own WoT score, so zaps from unknown keys count for nothing, and sats
+// from detected wash patterns are excluded entirely. The boost grows with the log
+// of weighted sats and is capped at zapBoostMax points.
+func computeZapScore(zs *ZapStore, pubkey string) ZapScoreResponse {
+	stats := graph.Stats()
+	raw, _ := graph.GetScore(pubkey)
+	base := normalizeScore(raw, stats.Nodes)
+
+	patterns, excluded := detectZapWash(zs, pubkey)
+	if patterns == nil {
+		patterns = []ZapWashPattern{}
+	}
+	resp := ZapScoreResponse{
+		Pubkey:          pubkey,
+		BaseScore:       base,
+		WashPatterns:    patterns,
+		ExcludedSenders: len(excluded),
+		GraphSize:       stats.Nodes,
+	}
+
+	zs.mu.RLock()
+	for s, flow := range zs.in[pubkey] {
+		resp.Senders++
+		resp.ReceivedSats += flow.Sats
+		if excluded[s] {
+			resp.ExcludedSats += flow.Sats
+			continue
+		}
+		resp.CountedSats += flow.Sats
+		sRaw, _ := graph.GetScore(s)
+		resp.WeightedSats += float64(flow.Sats) * float64(normalizeScore(sRaw, stats.Nodes)) / 100
+	}
+	zs.mu.RUnlock()
+
+	resp.WeightedSats = math.Round(resp.WeightedSats*10) / 10
+	if resp.WeightedSats > 0 {
+		resp.ZapBoost = int(math.Min(zapBoostMax, math.Round(math.Log10(1+resp.WeightedSats)*zapBoostScale)))
+	}
+	resp.ZapWeightedScore = base + resp.ZapBoost
+	if resp.ZapWeightedScore > 100 {
+		resp.ZapWeightedScore = 100
+	}
+	return resp
+}
+
+// handleZapScore serves GET /zap-score?pubkey=<hex|npub>.
+func handleZapScore(w http.ResponseWriter, r *http.Request) {
+	q := bindQuery(r)
+	pubkey := q.Pubkey("pubkey", true)
+	if q.Failed(w) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(computeZapScore(zapStore, pubkey))
+}
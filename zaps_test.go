@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var zapReceiptSeq int
+
+// zapReceipt builds a kind 9735 receipt from sender to recipient for sats, signed by service.
+func zapReceipt(sender, recipient string, sats int, service string) *nostr.Event {
+	zapReceiptSeq++
+	return &nostr.Event{
+		ID:     padHex(900000 + zapReceiptSeq),
+		Kind:   9735,
+		PubKey: service,
+		Tags: nostr.Tags{
+			{"p", recipient},
+			{"P", sender},
+			{"bolt11", fmt.Sprintf("lnbc%du1pzaptest", sats/100)},
+		},
+	}
+}
+
+func TestZapStoreRecord(t *testing.T) {
+	zs := NewZapStore()
+	sender, recipient, svc := padHex(19001), padHex(19002), padHex(19900)
+
+	ev := zapReceipt(sender, recipient, 1000, svc)
+	if !zs.Record(ev) {
+		t.Fatal("expected receipt to be recorded")
+	}
+	if zs.Record(ev) {
+		t.Error("duplicate receipt must be ignored")
+	}
+
+	// Sender taken from the embedded zap request when there is no P tag
+	req, _ := json.Marshal(nostr.Event{Kind: 9734, PubKey: padHex(19003)})
+	ev = zapReceipt(sender, recipient, 500, svc)
+	ev.Tags = nostr.Tags{{"p", recipient}, {"bolt11", "lnbc5u1pzaptest"}, {"description", string(req)}}
+	if !zs.Record(ev) {
+		t.Fatal("expected description sender to be used")
+	}
+	if zs.in[recipient][padHex(19003)].Sats != 500 {
+		t.Errorf("expected 500 sats from description sender, got %d", zs.in[recipient][padHex(19003)].Sats)
+	}
+
+	bad := zapReceipt(sender, recipient, 1000, svc)
+	bad.Tags = nostr.Tags{{"p", recipient}, {"P", sender}}
+	if zs.Record(bad) {
+		t.Error("receipt without amount must be rejected")
+	}
+	if zs.ReceiptCount() != 2 {
+		t.Errorf("expected 2 receipts, got %d", zs.ReceiptCount())
+	}
+	if flow := zs.out[sender][recipient]; flow.Sats != 1000 || flow.Count != 1 || flow.Services[svc] != 1 {
+		t.Errorf("unexpected flow %+v", flow)
+	}
+}
+
+func TestDetectZapWashPatterns(t *testing.T) {
+	oldGraph := graph
+	graph = NewGraph()
+	defer func() { graph = oldGraph }()
+
+	target := padHex(19100)
+	partner, hopA, hopB, alt, fan, friend := padHex(19101), padHex(19102), padHex(19103), padHex(19104), padHex(19105), padHex(19106)
+	for _, pk := range []string{target, partner, hopA, hopB, fan, friend} {
+		graph.AddFollow(pk, target)
+		graph.AddFollow(target, pk)
+		graph.scores[pk] = 1.0
+	}
+	graph.AddFollow(alt, target) // alt has no score
+	svc, other := padHex(19900), padHex(19901)
+
+	zs := NewZapStore()
+	zs.Record(zapReceipt(target, target, 5000, svc))   // self zap
+	zs.Record(zapReceipt(partner, target, 10000, svc)) // 2-cycle
+	zs.Record(zapReceipt(target, partner, 9000, svc))  //
+	zs.Record(zapReceipt(hopB, target, 20000, svc))    // 3-cycle target -> hopA -> hopB -> target
+	zs.Record(zapReceipt(target, hopA, 20000, other))  //
+	zs.Record(zapReceipt(hopA, hopB, 20000, other))    //
+	zs.Record(zapReceipt(alt, target, 30000, svc))     // alt key
+	zs.Record(zapReceipt(fan, target, 2000, svc))      // genuine
+	zs.Record(zapReceipt(friend, target, 10000, svc))  // friends tipping: small return
+	zs.Record(zapReceipt(target, friend, 1000, svc))   //
+
+	patterns, excluded := detectZapWash(zs, target)
+	byType := map[string]int{}
+	for _, p := range patterns {
+		byType[p.Type]++
+		if p.Type == "circular" && len(p.Counterparties) == 1 && !p.SameLNService {
+			t.Error("2-cycle settled through one service should set same_ln_service")
+		}
+		if p.Type == "circular" && len(p.Counterparties) == 2 && p.SameLNService {
+			t.Error("3-cycle through different services must not set same_ln_service")
+		}
+	}
+	if byType["self_zap"] != 1 || byType["circular"] != 2 || byType["alt_key"] != 1 {
+		t.Fatalf("unexpected patterns %+v", patterns)
+	}
+	for _, pk := range []string{target, partner, hopB, alt} {
+		if !excluded[pk] {
+			t.Errorf("expected %s… excluded", pk[:8])
+		}
+	}
+	if excluded[fan] || excluded[friend] {
+		t.Error("genuine senders must not be excluded")
+	}
+}
+
+func TestComputeZapScoreExcludesWash(t *testing.T) {
+	oldGraph := graph
+	graph = NewGraph()
+	defer func() { graph = oldGraph }()
+
+	target, fan, partner := padHex(19200), padHex(19201), padHex(19202)
+	graph.AddFollow(fan, target)
+	graph.AddFollow(partner, target)
+	graph.scores[fan] = 1.0
+	graph.scores[partner] = 1.0
+
+	zs := NewZapStore()
+	zs.Record(zapReceipt(fan, target, 1000, padHex(19900)))
+	zs.Record(zapReceipt(partner, target, 1000000, padHex(19900)))
+	zs.Record(zapReceipt(target, partner, 1000000, padHex(19900)))
+
+	resp := computeZapScore(zs, target)
+	if resp.ReceivedSats != 1001000 || resp.CountedSats != 1000 || resp.ExcludedSats != 1000000 {
+		t.Fatalf("unexpected sats accounting %+v", resp)
+	}
+	if resp.Senders != 2 || resp.ExcludedSenders != 1 {
+		t.Errorf("expected 2 senders with 1 excluded, got %d/%d", resp.Senders, resp.ExcludedSenders)
+	}
+	if resp.ZapBoost <= 0 || resp.ZapBoost > zapBoostMax {
+		t.Errorf("boost %d out of range", resp.ZapBoost)
+	}
+	if resp.ZapWeightedScore != resp.BaseScore+resp.ZapBoost {
+		t.Errorf("weighted score %d != base %d + boost %d", resp.ZapWeightedScore, resp.BaseScore, resp.ZapBoost)
+	}
+
+	// Zaps from a zero-score sender add nothing
+	zs = NewZapStore()
+	zs.Record(zapReceipt(padHex(19203), target, 1000000, padHex(19900)))
+	if resp := computeZapScore(zs, target); resp.ZapBoost != 0 || resp.CountedSats != 1000000 {
+		t.Errorf("expected no boost from unscored sender, got %+v", resp)
+	}
+}
+
+func TestZapScoreEndpoint(t *testing.T) {
+	rr := httptest.NewRecorder()
+	handleZapScore(rr, httptest.NewRequest("GET", "/zap-score", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without pubkey, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handleZapScore(rr, httptest.NewRequest("GET", "/zap-score?pubkey="+padHex(19300), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var resp ZapScoreResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.WashPatterns == nil || resp.Pubkey != padHex(19300) {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestAnomaliesReportsZapWash(t *testing.T) {
+	oldGraph, oldZaps := graph, zapStore
+	defer func() { graph, zapStore = oldGraph, oldZaps }()
+	graph = NewGraph()
+	zapStore = NewZapStore()
+
+	target := padHex(19400)
+	zapStore.Record(zapReceipt(target, target, 5000, padHex(19900)))
+	partner := padHex(19401)
+	zapStore.Record(zapReceipt(partner, target, 5000, padHex(19900)))
+	zapStore.Record(zapReceipt(target, partner, 5000, padHex(19900)))
+
+	resp := computeAnomalies(target)
+	if len(resp.ZapWash) != 2 {
+		t.Fatalf("expected 2 zap wash patterns, got %+v", resp.ZapWash)
+	}
+	found := false
+	for _, a := range resp.Anomalies {
+		if a.Type == "zap_wash_trading" {
+			found = true
+			if a.Severity != "medium" {
+				t.Errorf("self-zap plus loop should be medium, got %s", a.Severity)
+			}
+		}
+	}
+	if !found {
+		t.Error("expected zap_wash_trading anomaly")
+	}
+}